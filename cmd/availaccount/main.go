@@ -1,13 +1,14 @@
 package availaccount
 
 import (
+	"context"
 	"log"
 	"math/big"
 	"math/rand"
 	"os"
+	"strings"
 	"time"
 
-	"github.com/centrifuge/go-substrate-rpc-client/v4/signature"
 	"github.com/hashicorp/go-hclog"
 	"github.com/spf13/cobra"
 
@@ -33,17 +34,18 @@ const (
 //	}
 func GetCommand() *cobra.Command {
 	var balance uint64
-	var availAddr, path string
+	var availAddr, path, passphraseFile string
 	var retry bool
 	cmd := &cobra.Command{
 		Use:   "availaccount",
 		Short: "Create an avail account and deposit the balance",
 		Run: func(cmd *cobra.Command, args []string) {
-			Run(availAddr, path, balance, retry)
+			Run(availAddr, path, passphraseFile, balance, retry)
 		},
 	}
 	cmd.Flags().StringVar(&availAddr, "avail-addr", "ws://127.0.0.1:9944/v1/json-rpc", "Avail JSON-RPC URL")
 	cmd.Flags().StringVar(&path, "path", "./configs/account", "Save path for account memonic file")
+	cmd.Flags().StringVar(&passphraseFile, "keystore-passphrase-file", "", "Path to the passphrase file; when set, the account is saved as an encrypted keystore instead of a plaintext mnemonic")
 	cmd.Flags().Uint64Var(&balance, "balance", 18, "Number of AVLs to deposit on the account")
 	cmd.Flags().BoolVar(&retry, "retry", false, "Retry if account deposit fails")
 	return cmd
@@ -51,11 +53,12 @@ func GetCommand() *cobra.Command {
 
 // Run is responsible for setting up and executing the process of creating an Avail account and
 // depositing a balance into it. It takes the Avail JSON-RPC URL, a file path for saving the
-// account mnemonic, the balance to deposit into the account, and a retry flag to indicate
-// whether the process should be retried if an error occurs.
+// account mnemonic, the path to the keystore passphrase file, which saves the account as an
+// encrypted keystore when not empty, the balance to deposit into the account, and a retry
+// flag to indicate whether the process should be retried if an error occurs.
 // Example usage:
-// Run("ws://127.0.0.1:9944/v1/json-rpc", "./configs/account", 18, false)
-func Run(availAddr, path string, balance uint64, retry bool) {
+// Run("ws://127.0.0.1:9944/v1/json-rpc", "./configs/account", "", 18, false)
+func Run(availAddr, path, passphraseFile string, balance uint64, retry bool) {
 	availClient, err := avail.NewClient(availAddr, hclog.Default())
 	if err != nil {
 		panic(err)
@@ -73,7 +76,7 @@ func Run(availAddr, path string, balance uint64, retry bool) {
 
 	if retry {
 		for {
-			if err = deposit(availClient, availAccount, balance); err == nil {
+			if err = deposit(availClient, avail.NewKeyringSigner(availAccount), balance); err == nil {
 				break
 			}
 
@@ -83,13 +86,28 @@ func Run(availAddr, path string, balance uint64, retry bool) {
 			time.Sleep(time.Duration(seconds) * time.Second)
 		}
 	} else {
-		if err = deposit(availClient, availAccount, balance); err != nil {
+		if err = deposit(availClient, avail.NewKeyringSigner(availAccount), balance); err != nil {
 			panic(err)
 		}
 	}
 
 	log.Printf("Successfully deposited '%d' AVL to '%s'", balance, availAccount.Address)
 
+	if passphraseFile != "" {
+		passphrase, err := os.ReadFile(passphraseFile)
+		if err != nil {
+			panic(err)
+		}
+
+		if err := avail.WriteKeystore(path, availAccount, strings.TrimRight(string(passphrase), "\r\n")); err != nil {
+			panic(err)
+		}
+
+		log.Printf("Successfuly written encrypted keystore into '%s'", path)
+
+		return
+	}
+
 	if err := os.WriteFile(path, []byte(availAccount.URI), 0o644); err != nil {
 		panic(err)
 	}
@@ -105,19 +123,19 @@ func Run(availAddr, path string, balance uint64, retry bool) {
 //	if err := deposit(availClient, availAccount, 1000); err != nil {
 //	   log.Fatalf("deposit error: %v", err)
 //	}
-func deposit(availClient avail.Client, availAccount signature.KeyringPair, balance uint64) (err error) {
+func deposit(availClient avail.Client, availAccount avail.SignatureProvider, balance uint64) (err error) {
 	amount := big.NewInt(0).Mul(big.NewInt(0).SetUint64(balance), big.NewInt(AVL))
 
 	for {
 		if amount.IsUint64() {
-			err = avail.DepositBalance(availClient, availAccount, amount.Uint64(), 0)
+			err = avail.DepositBalance(context.Background(), availClient, availAccount, amount.Uint64())
 			if err != nil {
 				return err
 			}
 
 			break
 		} else {
-			err = avail.DepositBalance(availClient, availAccount, maxUint64, 0)
+			err = avail.DepositBalance(context.Background(), availClient, availAccount, maxUint64)
 			if err != nil {
 				return err
 			}
@@ -0,0 +1,64 @@
+package genesis
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/availproject/op-evm/consensus/avail"
+)
+
+// GetCommand returns a Cobra command for generating the genesis of a new chain from a genesis config.
+// It takes no arguments and returns a pointer to a cobra.Command.
+// Example usage:
+// cmd := GetCommand()
+//
+//	if err := cmd.Execute(); err != nil {
+//	   log.Fatalf("cmd.Execute error: %v", err)
+//	}
+func GetCommand() *cobra.Command {
+	var configPath, outputPath string
+	cmd := &cobra.Command{
+		Use:   "genesis",
+		Short: "Generate the genesis of a new chain from a genesis config",
+		Run: func(cmd *cobra.Command, args []string) {
+			Run(configPath, outputPath)
+		},
+	}
+	cmd.Flags().StringVar(&configPath, "config", "./configs/genesis-config.json", "Path to the genesis config: the chain ID, the block gas limit, the premined accounts, the staked participants and the predeployed contracts")
+	cmd.Flags().StringVar(&outputPath, "output", "./configs/genesis.json", "Save path for the generated genesis")
+	return cmd
+}
+
+// Run reads the genesis config from the config path, generates the genesis with the staking
+// contract and the configured contracts predeployed and the participants staked, and saves it
+// to the output path.
+// Example usage:
+// Run("./configs/genesis-config.json", "./configs/genesis.json")
+func Run(configPath, outputPath string) {
+	bs, err := os.ReadFile(configPath)
+	if err != nil {
+		log.Fatalf("failed to read genesis config: %s", err)
+	}
+
+	var cfg avail.GenesisConfig
+	if err := json.Unmarshal(bs, &cfg); err != nil {
+		log.Fatalf("failed to decode genesis config: %s", err)
+	}
+
+	chain, err := avail.BuildGenesis(cfg)
+	if err != nil {
+		log.Fatalf("failed to generate genesis: %s", err)
+	}
+
+	out, err := json.MarshalIndent(chain, "", "    ")
+	if err != nil {
+		log.Fatalf("failed to encode genesis: %s", err)
+	}
+
+	if err := os.WriteFile(outputPath, out, 0o644); err != nil {
+		log.Fatalf("failed to save genesis: %s", err)
+	}
+}
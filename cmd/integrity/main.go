@@ -0,0 +1,123 @@
+package integrity
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/juju/ansiterm"
+	"github.com/spf13/cobra"
+
+	consensus "github.com/availproject/op-evm/consensus/avail"
+)
+
+// GetCommand returns the command checking the database of a running node for
+// its integrity over its admin JSON-RPC server.
+func GetCommand() *cobra.Command {
+	var adminAddr, jwtSecret, repairFrom string
+	var height uint64
+	cmd := &cobra.Command{
+		Use:   "verify-integrity",
+		Short: "Check the headers and the state of a node up to a block for missing or corrupt entries",
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := Run(adminAddr, jwtSecret, height, repairFrom); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+		},
+	}
+	cmd.Flags().StringVar(&adminAddr, "admin-addr", "http://127.0.0.1:10003", "JSON-RPC URL of the admin server of the node, see --avail-admin-rpc-listen-addr")
+	cmd.Flags().StringVar(&jwtSecret, "admin-jwt-secret", "", "Path to the JWT secret of the admin server of the node the bearer token of the call is signed with, see --avail-admin-rpc-jwt-secret")
+	cmd.Flags().Uint64Var(&height, "height", 0, "Height of the block to check the state at, along with the headers up to it")
+	cmd.Flags().StringVar(&repairFrom, "repair-from", "", "JSON-RPC URL of the state provider to refetch the missing or corrupt trie nodes and codes from; empty only reports them")
+	_ = cmd.MarkFlagRequired("height")
+	return cmd
+}
+
+// Run runs the integrity check of the node at the admin address, with a
+// token signed with the JWT secret of the given file if any, and prints its
+// report. Interrupted, the check picks up from its checkpoint on the next
+// run; the node found inconsistent is an error.
+func Run(adminAddr, jwtSecret string, height uint64, repairFrom string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	client, err := rpc.DialContext(ctx, adminAddr)
+	if err != nil {
+		return fmt.Errorf("failed to dial the admin server %q: %w", adminAddr, err)
+	}
+
+	defer client.Close()
+
+	if jwtSecret != "" {
+		secret, err := consensus.LoadAdminJWTSecret(jwtSecret, false)
+		if err != nil {
+			return err
+		}
+
+		token, err := consensus.NewAdminJWT(secret)
+		if err != nil {
+			return err
+		}
+
+		client.SetHeader("Authorization", "Bearer "+token)
+	}
+
+	var report consensus.IntegrityReport
+	if err := client.CallContext(ctx, &report, consensus.AdminNamespace+"_verifyIntegrity", height, repairFrom); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("integrity check interrupted; run it again to resume")
+		}
+
+		return err
+	}
+
+	printReport(&report)
+
+	if !report.Consistent() {
+		return fmt.Errorf("node inconsistent at block %d", report.Height)
+	}
+
+	return nil
+}
+
+func printReport(report *consensus.IntegrityReport) {
+	tw := ansiterm.NewTabWriter(os.Stdout, 4, 4, 1, ' ', 0)
+	defer tw.Flush()
+
+	fmt.Fprintf(tw, "block\t%d\t%s\n", report.Height, report.Hash)
+	fmt.Fprintf(tw, "state root\t%s\n", report.StateRoot)
+	fmt.Fprintf(tw, "checked\t%d headers, %d trie nodes, %d codes\n", report.Headers, report.TrieNodes, report.Codes)
+	fmt.Fprintf(tw, "issues\t%d (%d repaired)\n", uint64(len(report.Issues))+report.OmittedIssues, report.Repaired)
+
+	if report.Resumed {
+		fmt.Fprintf(tw, "resumed\tfrom the checkpoint of an interrupted check\n")
+	}
+
+	for _, issue := range report.Issues {
+		if issue.Repaired {
+			tw.SetForeground(ansiterm.BrightYellow)
+		} else {
+			tw.SetForeground(ansiterm.BrightRed)
+		}
+
+		where := issue.Path
+		if issue.Kind == consensus.IntegrityHeader {
+			where = fmt.Sprintf("#%d", issue.Number)
+		}
+
+		if issue.Account != nil {
+			where = fmt.Sprintf("account %s %s", issue.Account, where)
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\trepaired: %t\n", issue.Kind, issue.Problem, issue.Hash, where, issue.Repaired)
+		tw.Reset()
+	}
+
+	if report.OmittedIssues > 0 {
+		fmt.Fprintf(tw, "...\t%d more issues omitted\n", report.OmittedIssues)
+	}
+}
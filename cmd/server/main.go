@@ -1,11 +1,21 @@
 package server
 
 import (
+	"context"
+	"crypto/tls"
 	"errors"
 	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/0xPolygon/polygon-edge/helper/common"
+	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/hashicorp/go-hclog"
 	golog "github.com/ipfs/go-log/v2"
 	"github.com/spf13/cobra"
@@ -25,33 +35,139 @@ import (
 //	   log.Fatalf("cmd.Execute error: %v", err)
 //	}
 func GetCommand() *cobra.Command {
-	var bootnode bool
-	var availAddr, path, accountPath, fraudListenAddr string
+	runCfg := RunConfig{RPCLimits: server.DefaultRPCLimitConfig()}
+	var trustedStateRoot string
 	cmd := &cobra.Command{
 		Use:   "server",
 		Short: "Run the Optimistic EVM Rollup",
 		Run: func(cmd *cobra.Command, args []string) {
-			Run(availAddr, path, accountPath, fraudListenAddr, bootnode)
+			if trustedStateRoot != "" {
+				if err := runCfg.TrustedSync.StateRoot.UnmarshalText([]byte(trustedStateRoot)); err != nil {
+					log.Fatalf("invalid trusted state root %q: %s", trustedStateRoot, err)
+				}
+			}
+
+			Run(runCfg)
 		},
 	}
-	cmd.Flags().StringVar(&availAddr, "avail-addr", "ws://127.0.0.1:9944/v1/json-rpc", "Avail JSON-RPC URL")
-	cmd.Flags().StringVar(&path, "config-file", "./configs/bootnode.yaml", "Path to the configuration file")
-	cmd.Flags().StringVar(&accountPath, "account-config-file", "./configs/account", "Path to the account mnemonic file")
-	cmd.Flags().BoolVar(&bootnode, "bootstrap", false, "bootstrap flag must be specified for the first node booting a new network from the genesis")
-	cmd.Flags().StringVar(&fraudListenAddr, "fraud-srv-listen-addr", ":9990", "Fraud server listen address")
+	cmd.Flags().StringSliceVar(&runCfg.AvailAddrs, "avail-addr", []string{"ws://127.0.0.1:9944/v1/json-rpc"}, "Avail JSON-RPC URLs; may be repeated or comma-separated, the first healthy one is used and the rest serve as failover. The 'avail.addrs' of the config file take their place")
+	cmd.Flags().Uint64Var(&runCfg.QueryPageSize, "avail-query-page-size", avail.DefaultQueryPageSize, "Number of historical Avail blocks fetched concurrently when catching up with the chain")
+	cmd.Flags().DurationVar(&runCfg.CallTimeout, "avail-call-timeout", avail.DefaultCallTimeout, "Deadline of a single Avail JSON-RPC call")
+	cmd.Flags().BoolVar(&runCfg.CallIndexFallback, "avail-call-index-fallback", false, "Fall back to the built-in submit_data call index when it can't be found in Avail runtime metadata")
+	cmd.Flags().Uint64Var(&runCfg.Mortality, "avail-mortality", avail.DefaultMortalityPeriod, "Number of Avail blocks a submitted extrinsic stays valid for; 0 submits immortal extrinsics")
+	cmd.Flags().Uint64Var(&runCfg.Tip, "avail-tip", avail.DefaultTip, "Tip paid for the inclusion of the block data extrinsics in Avail")
+	cmd.Flags().Uint64Var(&runCfg.FraudTip, "avail-fraud-tip", consensus.DefaultFraudTip, "Tip paid for the inclusion of the fraud proof and dispute resolution extrinsics in Avail")
+	cmd.Flags().IntVar(&runCfg.Scheduler.MaxPerAvailBlock, "avail-max-submissions-per-block", 0, "Maximum number of block data submissions per Avail block; the excess blocks are queued. 0 submits the blocks right away")
+	cmd.Flags().IntVar(&runCfg.Scheduler.QueueSize, "avail-submission-queue-size", avail.DefaultSubmissionQueueSize, "Number of blocks that can wait for their submission to Avail before block production is held back")
+	cmd.Flags().BoolVar(&runCfg.Scheduler.Batching, "avail-batch-submissions", false, "Coalesce the queued blocks into a single Avail extrinsic")
+	cmd.Flags().StringVar(&runCfg.App.Key, "avail-app-key", avail.ApplicationKey, "Avail application key of the chain; must be unique per chain submitting to the same Avail network")
+	cmd.Flags().Uint64Var(&runCfg.App.ID, "avail-app-id", 0, "Expected Avail AppID of the application key; 0 accepts the one registered on Avail")
+	cmd.Flags().BoolVar(&runCfg.App.Create, "avail-create-app-key", true, "Create the Avail application key when it doesn't exist")
+	cmd.Flags().StringVar(&runCfg.ConfigPath, "config-file", "./configs/bootnode.yaml", "Path to the configuration file; its log level and 'avail' section are reloaded on SIGHUP or 'availAdmin_reloadConfig'")
+	cmd.Flags().StringVar(&runCfg.Signer.Path, "account-config-file", "./configs/account", "Path to the account mnemonic file, or the keystore file with the keystore signer")
+	cmd.Flags().StringVar(&runCfg.Signer.Type, "avail-signer", avail.SignerMnemonic, "Signer of the Avail extrinsics: 'mnemonic' reads the plaintext account mnemonic, 'keystore' decrypts a passphrase encrypted keystore")
+	cmd.Flags().StringVar(&runCfg.Signer.PassphraseFile, "avail-keystore-passphrase-file", "", "Path to the file holding the passphrase of the Avail keystore")
+	cmd.Flags().BoolVar(&runCfg.Bootnode, "bootstrap", false, "bootstrap flag must be specified for the first node booting a new network from the genesis")
+	cmd.Flags().StringVar(&runCfg.FraudListenAddr, "fraud-srv-listen-addr", ":9990", "Fraud server listen address")
+	cmd.Flags().StringVar(&runCfg.SettlementListenAddr, "avail-rpc-listen-addr", ":9991", "Listen address of the JSON-RPC server of the 'avail' namespace serving the Avail settlement info of the blocks and the node status, over HTTP and WebSocket, along with the node health on '/health'; empty disables it")
+	cmd.Flags().Uint64Var(&runCfg.SettlementArchive.Retention, "settlement-archive-retention", 0, "Number of Avail blocks the settlement references of the blocks are held in memory for; the older ones are compacted into checksummed archive files, still served by 'avail_getSettlementInfo'. 0 disables the archival")
+	cmd.Flags().StringVar(&runCfg.SettlementArchive.Dir, "settlement-archive-dir", "", "Directory of the settlement archive files; empty puts them in the 'settlements' directory of the data directory")
+	cmd.Flags().StringVar(&runCfg.AdminListenAddr, "avail-admin-rpc-listen-addr", "", "Listen address of the JSON-RPC server of the 'availAdmin' namespace serving the operator actions, such as resuming the circuit breaker or switching the node role with 'availAdmin_setNodeMode'; empty disables it")
+	cmd.Flags().StringVar(&runCfg.AdminAuth.JWTSecretFile, "avail-admin-rpc-jwt-secret", "", "Path to the hex encoded secret the bearer tokens of the 'availAdmin' calls are signed with, HS256, as in the engine API; created with a random secret if missing. Empty uses the 'admin-jwtsecret' file of the data directory")
+	cmd.Flags().StringVar(&runCfg.AdminAuth.TLSCertFile, "avail-admin-rpc-tls-cert", "", "Path to the TLS certificate the admin JSON-RPC server serves HTTPS with; empty serves plain HTTP")
+	cmd.Flags().StringVar(&runCfg.AdminAuth.TLSKeyFile, "avail-admin-rpc-tls-key", "", "Path to the key of the TLS certificate of the admin JSON-RPC server")
+	cmd.Flags().StringVar(&runCfg.AdminAuth.TLSClientCAFile, "avail-admin-rpc-tls-client-ca", "", "Path to the CA certificates of the clients of the admin JSON-RPC server authenticated with a TLS client certificate instead of a bearer token; empty authenticates them with a token only")
+	cmd.Flags().StringToIntVar(&runCfg.RPCLimits.Rates, "jsonrpc-rate-limit", runCfg.RPCLimits.Rates, "Requests per second a client IP may send to the JSON-RPC server of each method group, 'call' (eth_call, eth_estimateGas), 'trace' (debug_*), 'logs' (eth_getLogs, eth_getFilterLogs) and 'other', as group=rate pairs; the groups left out, or at 0, aren't limited. The requests over the limits get the 'limit exceeded' error with the time to retry after")
+	cmd.Flags().IntVar(&runCfg.RPCLimits.MaxConcurrentExpensive, "jsonrpc-max-concurrent-expensive", runCfg.RPCLimits.MaxConcurrentExpensive, "Most calls, traces and log queries the JSON-RPC server serves at once to all the clients; 0 doesn't cap them")
+	cmd.Flags().StringSliceVar(&runCfg.RPCLimits.Exempt, "jsonrpc-rate-limit-exempt", runCfg.RPCLimits.Exempt, "Networks of the JSON-RPC clients exempt from the rate limits and the concurrency cap, in CIDR notation, such as the loopback ones the components of the node call from")
+	cmd.Flags().BoolVar(&runCfg.ResumeCircuitBreaker, "resume-circuit-breaker", false, "Resume the circuit breaker left tripped by the previous run on the start")
+	cmd.Flags().StringVar(&runCfg.LightSync.StateProvider, "light-sync-state-provider", "", "JSON-RPC URL of the 'avail' namespace of the full node, or snapshot provider, serving the state with --serve-state that the watchtower under the light sync fetches the state from; the blocks are written header-only and re-executed for a sample only, and no blocks are produced. Empty disables the light sync")
+	cmd.Flags().Uint64Var(&runCfg.TrustedSync.Height, "trusted-height", 0, "Height up to which the replay of the Avail history trusts the state of the blocks instead of re-executing them; their structure and producer signatures are still checked. 0 disables the trusted fast sync")
+	cmd.Flags().StringVar(&trustedStateRoot, "trusted-state-root", "", "State root trusted at --trusted-height, such as the one of a publicly attested checkpoint; the block at the height is refused unless it has it")
+	cmd.Flags().StringVar(&runCfg.TrustedSync.Snapshot, "trusted-snapshot", "", "Path of the chain snapshot at --trusted-height the trusted state is taken from")
+	cmd.Flags().BoolVar(&runCfg.Snapshots.Serve, "serve-snapshots", false, "Serve the chain snapshots at the settled head in chunks over the 'avail' JSON-RPC namespace, for the new nodes to bootstrap from with --snapshot-peer")
+	cmd.Flags().BoolVar(&runCfg.Snapshots.ServeState, "serve-state", false, "Serve the raw state trie nodes and code over the 'avail' JSON-RPC namespace, for the watchtowers under the light sync to fetch the state from with --light-sync-state-provider")
+	cmd.Flags().StringVar(&runCfg.Snapshots.Peer, "snapshot-peer", "", "JSON-RPC URL of the 'avail' namespace of the trusted peer serving the chain snapshots; the node short of the latest one downloads and bootstraps from it on the start, checking it against Avail. Empty disables it")
+	cmd.Flags().BoolVar(&runCfg.SyncProgress, "sync-progress", false, "Print the progress of the node syncing the chain from Avail, on the start or catching up, with the percentage done and the time left")
+	cmd.Flags().Uint64Var(&runCfg.LightSync.SamplePercent, "light-sync-sample-percent", consensus.DefaultLightSyncSamplePercent, "Percentage of the blocks the watchtower under the light sync re-executes at random")
+	cmd.Flags().DurationVar(&runCfg.Health.MaxAvailStall, "health-max-avail-stall", consensus.DefaultHealthMaxAvailStall, "Longest time the node may go without an Avail block received before '/health' reports it unhealthy; 0 disables the check")
+	cmd.Flags().DurationVar(&runCfg.Health.MaxBlockAge, "health-max-block-age", consensus.DefaultHealthMaxBlockAge, "Oldest the head of the chain may be before '/health' reports the node degraded; 0 disables the check")
+	cmd.Flags().Uint64Var(&runCfg.Health.MaxSettlementLag, "health-max-settlement-lag", consensus.DefaultHealthMaxSettlementLag, "Number of blocks the head may run ahead of the blocks settled on Avail before '/health' reports the node degraded; 0 disables the check")
 	return cmd
 }
 
-// Run initializes and starts the optimistic EVM rollup server. It takes the Avail JSON-RPC URL, a file path for
-// the configuration file, a file path for the account mnemonic file, a fraud server listen address and a bootnode
-// flag. It does not return a value.
+// RunConfig is the configuration of the optimistic EVM rollup server started
+// by Run.
+type RunConfig struct {
+	AvailAddrs        []string      // Avail JSON-RPC URLs, in the order of preference
+	QueryPageSize     uint64        // Page size of the historical Avail block queries
+	CallTimeout       time.Duration // Deadline of a single Avail JSON-RPC call
+	CallIndexFallback bool          // Fall back to the built-in submit_data call index
+	Mortality         uint64        // Mortality period of the submitted extrinsics
+	Tip               uint64        // Tip of the block data extrinsics
+	FraudTip          uint64        // Tip of the fraud proof and dispute resolution extrinsics
+
+	App               avail.AppConfig
+	Scheduler         avail.SchedulerConfig
+	Signer            avail.SignerConfig
+	SettlementArchive avail.SettlementArchiveConfig
+
+	ConfigPath           string // Path of the configuration file
+	FraudListenAddr      string // Listen address of the fraud server
+	SettlementListenAddr string // Listen address of the settlement info JSON-RPC server; empty disables it
+	AdminListenAddr      string // Listen address of the admin JSON-RPC server; empty disables it
+	AdminAuth            consensus.AdminAuthConfig
+	RPCLimits            server.RPCLimitConfig
+
+	ResumeCircuitBreaker bool // Resume the circuit breaker left tripped by the previous run
+	LightSync            consensus.LightSyncConfig
+	TrustedSync          consensus.TrustedSyncConfig
+	Snapshots            consensus.SnapshotConfig
+	Health               consensus.HealthConfig
+	SyncProgress         bool // Print the sync progress
+	Bootnode             bool // Boot a new network from the genesis
+}
+
+// Run initializes and starts the optimistic EVM rollup server with the given configuration. It does not return a value.
 // Example usage:
-// Run("ws://127.0.0.1:9944/v1/json-rpc", "./configs/bootnode.yaml", "./configs/account", ":9990", false)
-func Run(availAddr, path, accountPath, fraudListenAddr string, bootnode bool) {
+//
+//	Run(RunConfig{
+//		AvailAddrs:      []string{"ws://127.0.0.1:9944/v1/json-rpc"},
+//		QueryPageSize:   avail.DefaultQueryPageSize,
+//		CallTimeout:     avail.DefaultCallTimeout,
+//		Mortality:       avail.DefaultMortalityPeriod,
+//		Tip:             avail.DefaultTip,
+//		FraudTip:        consensus.DefaultFraudTip,
+//		App:             avail.DefaultAppConfig(),
+//		Signer:          avail.SignerConfig{Type: avail.SignerMnemonic, Path: "./configs/account"},
+//		ConfigPath:      "./configs/bootnode.yaml",
+//		FraudListenAddr: ":9990",
+//		RPCLimits:       server.DefaultRPCLimitConfig(),
+//		Health:          consensus.DefaultHealthConfig(),
+//	})
+func Run(rc RunConfig) {
 	// Enable LibP2P logging but only >= warn
 	golog.SetAllLoggers(golog.LevelWarn)
 
-	config, err := config.NewServerConfig(path)
+	// The operational configuration is re-read from the file on the reload
+	// of the config.
+	loadOperational := func() (*consensus.OperationalConfig, error) {
+		return config.NewOperationalConfig(rc.ConfigPath)
+	}
+
+	operational, err := loadOperational()
+	if err != nil {
+		log.Fatalf("failure to get node configuration: %s", err)
+	}
+
+	// The Avail endpoints of the file take the place of the flags.
+	if len(operational.AvailAddrs) > 0 {
+		rc.AvailAddrs = operational.AvailAddrs
+	} else {
+		operational.AvailAddrs = rc.AvailAddrs
+	}
+
+	config, err := config.NewServerConfig(rc.ConfigPath)
 	if err != nil {
 		log.Fatalf("failure to get node configuration: %s", err)
 	}
@@ -59,42 +175,280 @@ func Run(availAddr, path, accountPath, fraudListenAddr string, bootnode bool) {
 	// Enable TxPool P2P gossiping
 	config.Config.Seal = true
 
-	availAccount, err := avail.AccountFromFile(accountPath)
+	availAccount, err := avail.NewSignatureProvider(rc.Signer)
 	if err != nil {
-		log.Fatalf("failed to read Avail account from %q: %s\n", accountPath, err)
+		log.Fatalf("failed to set up Avail %s signer from %q: %s\n", rc.Signer.Type, rc.Signer.Path, err)
 	}
 
-	availClient, err := avail.NewClient(availAddr, hclog.Default())
+	availClient, err := avail.NewFailoverClient(rc.AvailAddrs, hclog.Default(), avail.WithQueryPageSize(rc.QueryPageSize), avail.WithCallTimeout(rc.CallTimeout), avail.WithCallIndexFallback(rc.CallIndexFallback))
 	if err != nil {
 		log.Fatalf("failed to create Avail client: %s\n", err)
 	}
 
-	appID, err := avail.EnsureApplicationKeyExists(availClient, avail.ApplicationKey, availAccount)
+	appID, err := avail.ResolveAppID(context.Background(), availClient, rc.App, availAccount)
 	if err != nil {
 		log.Fatalf("failed to get AppID from Avail: %s\n", err)
 	}
 
-	availSender := avail.NewSender(availClient, appID, availAccount)
+	availSender := avail.NewSender(availClient, appID, availAccount, avail.WithMortality(rc.Mortality), avail.WithTip(rc.Tip))
+
+	closeFn := func() {}
+	if rc.Scheduler.MaxPerAvailBlock > 0 {
+		scheduler := avail.NewSubmissionScheduler(availClient, availSender, rc.Scheduler)
+		availSender, closeFn = scheduler, scheduler.Close
+	}
+
+	if rc.SettlementArchive.Dir == "" {
+		rc.SettlementArchive.Dir = filepath.Join(config.Config.DataDir, "settlements")
+	}
+
+	settlements, err := avail.NewArchivedSettlementIndex(rc.SettlementArchive)
+	if err != nil {
+		log.Fatalf("failed to load the settlement archive from %q: %s\n", rc.SettlementArchive.Dir, err)
+	}
+
+	availSender = avail.RecordSettlements(availSender, settlements)
+
+	// The blocks submitted are followed to the finality of their Avail
+	// blocks, for the "settlements" subscribers, until the shutdown.
+	trackingCtx, stopTracking := context.WithCancel(context.Background())
+	trackingDone := make(chan struct{})
+
+	go func() {
+		defer close(trackingDone)
+		settlements.TrackFinality(trackingCtx, availClient, avail.TargetBlockTime)
+	}()
 
 	cfg := consensus.Config{
 		AvailAccount:      availAccount,
 		AvailClient:       availClient,
 		AvailSender:       availSender,
-		Bootnode:          bootnode,
-		FraudListenerAddr: fraudListenAddr,
+		Bootnode:          rc.Bootnode,
+		FraudListenerAddr: rc.FraudListenAddr,
 		NodeType:          config.NodeType,
 		AvailAppID:        appID,
+		AvailFraudTip:     rc.FraudTip,
+
+		MinSubmissionInterval: rc.Scheduler.MinInterval(),
+		ResumeCircuitBreaker:  rc.ResumeCircuitBreaker,
+		LightSync:             rc.LightSync,
+		TrustedSync:           rc.TrustedSync,
+		Snapshots:             rc.Snapshots,
+		Settlements:           settlements,
+		Health:                rc.Health,
+
+		Operational:           operational,
+		LoadOperationalConfig: loadOperational,
 	}
-	serverInstance, err := server.NewServer(config.Config, cfg)
+
+	if rc.SyncProgress {
+		progress := make(chan consensus.SyncStatus, syncProgressBufferSize)
+		go printSyncProgress(progress)
+
+		cfg.SyncProgress = progress
+	}
+
+	serverInstance, err := server.NewServer(config.Config, rc.RPCLimits, cfg)
 	if err != nil {
 		log.Fatalf("failure to start node: %s", err)
 	}
 
-	if err := HandleSignals(serverInstance.Close); err != nil {
+	if rc.SettlementListenAddr != "" {
+		var status *consensus.StatusAPI
+		if d, ok := serverInstance.Consensus().(*consensus.Avail); ok {
+			status = consensus.NewStatusAPI(d)
+		}
+
+		if err := startAvailRPC(rc.SettlementListenAddr, settlements, status); err != nil {
+			log.Fatalf("failure to start Avail JSON-RPC server: %s", err)
+		}
+	}
+
+	if rc.AdminListenAddr != "" {
+		d, ok := serverInstance.Consensus().(*consensus.Avail)
+		if !ok {
+			log.Fatalf("admin JSON-RPC server requires the Avail consensus")
+		}
+
+		if rc.AdminAuth.JWTSecretFile == "" {
+			rc.AdminAuth.JWTSecretFile = filepath.Join(config.Config.DataDir, "admin-jwtsecret")
+		}
+
+		if err := startAdminRPC(rc.AdminListenAddr, rc.AdminAuth, consensus.NewAdminAPI(d), consensus.NewNodeModeAPI(d)); err != nil {
+			log.Fatalf("failure to start admin JSON-RPC server: %s", err)
+		}
+	}
+
+	// SIGHUP reloads the config instead of stopping the node.
+	if d, ok := serverInstance.Consensus().(*consensus.Avail); ok {
+		go reloadOnHangup(d)
+	}
+
+	signalCh := make(chan os.Signal, 1)
+	signal.Notify(signalCh, os.Interrupt, syscall.SIGTERM)
+
+	if err := handleSignals(signalCh, func() {
+		serverInstance.Close()
+		closeFn()
+		stopTracking()
+		<-trackingDone
+		settlements.Close()
+	}); err != nil {
 		log.Fatalf("handle signal error: %s", err)
 	}
 }
 
+// reloadOnHangup reloads the config of the node on every SIGHUP; the changes
+// applied and rejected are logged by the consensus.
+func reloadOnHangup(d *consensus.Avail) {
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+
+	for range hangups {
+		log.Printf("[SIGNAL] Caught signal: %v, reloading the config\n", syscall.SIGHUP)
+
+		if _, err := d.ReloadConfig(); err != nil {
+			log.Printf("failed to reload the config: %s\n", err)
+		}
+	}
+}
+
+// syncProgressBufferSize is the number of the sync progress events buffered
+// for printing.
+const syncProgressBufferSize = 64
+
+// syncProgressInterval is the interval the sync progress is printed at, along
+// with the start and the end of each stage.
+const syncProgressInterval = 10 * time.Second
+
+// printSyncProgress prints the progress of the node syncing the chain from
+// Avail received on the given channel.
+func printSyncProgress(progress <-chan consensus.SyncStatus) {
+	var (
+		stage   consensus.SyncStage
+		printed time.Time
+	)
+
+	for status := range progress {
+		if status.Stage == stage && status.Percent < 100 && time.Since(printed) < syncProgressInterval {
+			continue
+		}
+
+		stage, printed = status.Stage, time.Now()
+
+		if !status.Syncing() {
+			log.Printf("synced with Avail at block %d\n", status.CurrentBlock)
+			continue
+		}
+
+		log.Printf("syncing with Avail (%s): %.1f%% done, Avail block %d of %d, block %d of ~%d, %.1f Avail blocks/s, ETA %s\n",
+			status.Stage, status.Percent, status.CurrentAvailBlock, status.HighestAvailBlock, status.CurrentBlock, status.HighestBlock,
+			status.BlocksPerSecond, status.ETA.Duration.Round(time.Second))
+	}
+}
+
+// startAvailRPC serves `avail_getSettlementInfo` over HTTP and WebSocket on
+// the given listen address, answering from the settlement index of the
+// submitted blocks, along with `avail_getNodeStatus`, `avail_nodeStatus`,
+// `avail_getRecentConflicts`, `avail_getSettledHead`, `avail_getFraudEvents`,
+// `avail_getDisputeEvents`, `avail_getStateNode`, `avail_getStateCode`,
+// `avail_getSnapshots`, `avail_getSnapshotChunk`, `avail_getBlockStatus`,
+// `avail_getTransactionStatus`, `avail_getParticipants`,
+// `avail_getParticipant`, `avail_syncing`, `avail_health` and, over
+// WebSocket, the "disputes", "syncProgress" and "settlements" subscriptions of
+// `avail_subscribe` when the status API is given; the health of the node is
+// served on `/health` as well, for the probes.
+func startAvailRPC(listenAddr string, settlements *avail.SettlementIndex, status *consensus.StatusAPI) error {
+	rpcServer, err := avail.NewSettlementRPCServer(settlements)
+	if err != nil {
+		return err
+	}
+
+	if status == nil {
+		return serveRPC(listenAddr, withWebsocket(rpcServer), nil, "Avail")
+	}
+
+	if err := rpcServer.RegisterName(avail.SettlementNamespace, status); err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/health", consensus.NewHealthHandler(status))
+	mux.Handle("/", withWebsocket(rpcServer))
+
+	return serveRPC(listenAddr, mux, nil, "Avail")
+}
+
+// withWebsocket serves the WebSocket upgrade requests to the JSON-RPC server
+// over WebSocket, and the other requests over HTTP.
+func withWebsocket(rpcServer *rpc.Server) http.Handler {
+	ws := rpcServer.WebsocketHandler([]string{"*"})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+			ws.ServeHTTP(w, r)
+			return
+		}
+
+		rpcServer.ServeHTTP(w, r)
+	})
+}
+
+// startAdminRPC serves `availAdmin_resumeCircuitBreaker`,
+// `availAdmin_checkBlock`, `availAdmin_verifyIntegrity`,
+// `availAdmin_setNodeMode`, `availAdmin_reloadConfig` and the other operator
+// actions of the 'availAdmin' namespace over HTTP, or HTTPS, on the given
+// listen address, to the calls authenticated with a bearer token or a TLS
+// client certificate only.
+func startAdminRPC(listenAddr string, auth consensus.AdminAuthConfig, admin *consensus.AdminAPI, nodeMode *consensus.NodeModeAPI) error {
+	secret, err := consensus.LoadAdminJWTSecret(auth.JWTSecretFile, true)
+	if err != nil {
+		return err
+	}
+
+	tlsConfig, err := auth.TLSConfig()
+	if err != nil {
+		return err
+	}
+
+	rpcServer := rpc.NewServer()
+	if err := rpcServer.RegisterName(consensus.AdminNamespace, admin); err != nil {
+		return err
+	}
+
+	if err := rpcServer.RegisterName(consensus.AdminNamespace, nodeMode); err != nil {
+		return err
+	}
+
+	return serveRPC(listenAddr, consensus.NewAdminAuthHandler(secret, rpcServer), tlsConfig, "admin")
+}
+
+// serveRPC serves the JSON-RPC server over HTTP, or HTTPS with the TLS
+// configuration, on the given listen address in the background.
+func serveRPC(listenAddr string, handler http.Handler, tlsConfig *tls.Config, name string) error {
+	lis, err := net.Listen("tcp", listenAddr)
+	if err != nil {
+		return err
+	}
+
+	if tlsConfig != nil {
+		lis = tls.NewListener(lis, tlsConfig)
+	}
+
+	srv := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: 60 * time.Second,
+	}
+
+	go func() {
+		if err := srv.Serve(lis); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("%s JSON-RPC server error: %s", name, err)
+		}
+	}()
+
+	return nil
+}
+
 // HandleSignals is a function that handles signals sent to the console. It helps in managing
 // the lifecycle of the server by triggering a shutdown when a termination signal is received.
 // It takes a function to be called when a termination signal is received and returns an error if
@@ -105,7 +459,12 @@ func Run(availAddr, path, accountPath, fraudListenAddr string, bootnode bool) {
 //	   log.Fatalf("handle signal error: %v", err)
 //	}
 func HandleSignals(closeFn func()) error {
-	signalCh := common.GetTerminationSignalCh()
+	return handleSignals(common.GetTerminationSignalCh(), closeFn)
+}
+
+// handleSignals calls closeFn on the first signal received on the channel,
+// failing on the second one or past the timeout.
+func handleSignals(signalCh <-chan os.Signal, closeFn func()) error {
 	sig := <-signalCh
 
 	log.Printf("\n[SIGNAL] Caught signal: %v\n", sig)
@@ -0,0 +1,138 @@
+package status
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/juju/ansiterm"
+	"github.com/spf13/cobra"
+
+	consensus "github.com/availproject/op-evm/consensus/avail"
+	"github.com/availproject/op-evm/pkg/avail"
+)
+
+// GetCommand returns the command printing the condition of a running node
+// from its 'avail' JSON-RPC server.
+func GetCommand() *cobra.Command {
+	var rpcAddr string
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "Print the role, heads, Avail lag, disputes and balances of a running node",
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := Run(rpcAddr); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+		},
+	}
+	cmd.Flags().StringVar(&rpcAddr, "avail-rpc-addr", "http://127.0.0.1:9991", "JSON-RPC URL of the 'avail' server of the node, see --avail-rpc-listen-addr")
+	return cmd
+}
+
+// Run fetches the condition of the node at the given address with
+// `avail_nodeStatus`, and prints it.
+func Run(rpcAddr string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	client, err := rpc.DialContext(ctx, rpcAddr)
+	if err != nil {
+		return fmt.Errorf("failed to dial the node %q: %w", rpcAddr, err)
+	}
+
+	defer client.Close()
+
+	var report consensus.NodeReport
+	if err := client.CallContext(ctx, &report, avail.SettlementNamespace+"_nodeStatus"); err != nil {
+		return err
+	}
+
+	printReport(os.Stdout, &report)
+
+	return nil
+}
+
+func printReport(w io.Writer, report *consensus.NodeReport) {
+	tw := ansiterm.NewTabWriter(w, 4, 4, 1, ' ', 0)
+	defer tw.Flush()
+
+	fmt.Fprintf(tw, "role\t%s\n", report.Role)
+	fmt.Fprintf(tw, "phase\t%s since %s\n", report.Consensus.Phase, report.Consensus.PhaseSince.Format(time.RFC3339))
+
+	if trip := report.Consensus.CircuitBreaker; trip != nil {
+		tw.SetForeground(ansiterm.BrightRed)
+		fmt.Fprintf(tw, "circuit breaker\ttripped: %s\n", trip.Reason)
+		tw.Reset()
+	}
+
+	fmt.Fprintf(tw, "head\t%d\t%s\n", report.Chain.Head, report.Chain.HeadHash)
+	fmt.Fprintf(tw, "settled head\t%d\t%s\n", report.Chain.SettledHead.Number, report.Chain.SettledHead.Hash)
+
+	if a := report.Avail; a != nil {
+		catchingUp := ""
+		if a.CatchingUp {
+			catchingUp = ", catching up"
+		}
+
+		fmt.Fprintf(tw, "avail\tblock %d of %d, %d behind%s\n", a.Cursor, a.Head, a.Lag, catchingUp)
+	}
+
+	if p := report.TxPool; p != nil {
+		fmt.Fprintf(tw, "txpool\t%d pending\n", p.Pending)
+	}
+
+	if p := report.Production; p != nil {
+		state := "producing"
+
+		switch {
+		case !p.Ready:
+			state = "not ready: " + p.ReadinessReason
+		case p.Paused:
+			state = "paused"
+		}
+
+		fmt.Fprintf(tw, "production\t%s\n", state)
+		fmt.Fprintf(tw, "settlement\t%d unsettled blocks, %d blocks behind\n", p.UnsettledBlocks, p.SettlementLag)
+	}
+
+	if d := report.Disputes; d != nil {
+		if d.State != "" {
+			fmt.Fprintf(tw, "dispute state\t%s\n", d.State)
+		}
+
+		fmt.Fprintf(tw, "open disputes\t%d\n", len(d.Open))
+
+		for _, open := range d.Open {
+			escalated := ""
+			if open.EscalatedAt != 0 {
+				escalated = fmt.Sprintf(", escalated at %d", open.EscalatedAt)
+			}
+
+			fmt.Fprintf(tw, "\tsequencer %s, watchtower %s, began at %d%s\n", open.Sequencer, open.Watchtower, open.BeganAt, escalated)
+		}
+	}
+
+	if f := report.FraudProofs; f != nil {
+		fmt.Fprintf(tw, "pending fraud proofs\t%d\n", len(f.Pending))
+
+		for _, e := range f.Pending {
+			fmt.Fprintf(tw, "\tblock %d\t%s, fraud proof %s\n", e.BlockNumber, e.BlockHash, e.FraudProofHash)
+		}
+	}
+
+	if b := report.Balances; b != nil {
+		fmt.Fprintf(tw, "account\t%s\n", b.Account)
+
+		if b.EVM != nil {
+			fmt.Fprintf(tw, "evm balance\t%s wei\n", b.EVM)
+		}
+
+		if b.Avail != nil {
+			fmt.Fprintf(tw, "avail balance\t%s (%d submissions left)\n", b.Avail, *b.SubmissionsRemaining)
+		}
+	}
+}
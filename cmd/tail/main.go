@@ -1,6 +1,7 @@
 package tail
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
@@ -18,33 +19,36 @@ import (
 )
 
 func GetCommand() *cobra.Command {
-	var availAddr, jsonrpcAddr string
+	var availAddr, appKey, jsonrpcAddr string
 	var offset int64
 	cmd := &cobra.Command{
 		Use:   "tail",
 		Short: "Follow OpEVM blockstream from Avail",
 		Run: func(cmd *cobra.Command, args []string) {
-			Run(availAddr, jsonrpcAddr, offset)
+			Run(availAddr, appKey, jsonrpcAddr, offset)
 		},
 	}
 	cmd.Flags().StringVar(&availAddr, "avail-addr", "ws://127.0.0.1:9944/v1/json-rpc", "Avail JSON-RPC URL")
+	cmd.Flags().StringVar(&appKey, "avail-app-key", avail.ApplicationKey, "Avail application key of the chain to follow")
 	cmd.Flags().StringVar(&jsonrpcAddr, "jsonrpc-addr", "http://127.0.0.1:10002/v1/json-rpc", "Optimistic EVM Rollup JSON-RPC URL")
 	cmd.Flags().Int64Var(&offset, "offset", 1, "Block offset; defaults to first block")
 	return cmd
 }
 
-func Run(availAddr, jsonrpcAddr string, offset int64) {
+func Run(availAddr, appKey, jsonrpcAddr string, offset int64) {
+	ctx := context.Background()
+
 	availClient, err := avail.NewClient(availAddr, hclog.NewNullLogger())
 	if err != nil {
 		panic(err)
 	}
 
-	appID, err := avail.QueryAppID(availClient, avail.ApplicationKey)
+	appID, err := avail.QueryAppID(ctx, availClient, appKey)
 	if err != nil {
 		panic(err)
 	}
 
-	callIdx, err := avail.FindCallIndex(availClient)
+	callIdx, err := avail.FindCallIndex(ctx, availClient)
 	if err != nil {
 		panic(err)
 	}
@@ -54,7 +58,7 @@ func Run(availAddr, jsonrpcAddr string, offset int64) {
 		panic(err)
 	}
 
-	availBlkStream := availClient.BlockStream(1)
+	availBlkStream := availClient.BlockStream(ctx, 1)
 
 	tw := ansiterm.NewTabWriter(os.Stdout, 4, 4, 1, ' ', 0)
 
@@ -69,7 +73,7 @@ func Run(availAddr, jsonrpcAddr string, offset int64) {
 				continue
 			}
 
-			printBlock(tw, jsonrpcClnt, b)
+			printBlock(tw, jsonrpcClnt, b.Block)
 		}
 
 		tw.Flush()
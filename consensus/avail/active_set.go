@@ -0,0 +1,51 @@
+package avail
+
+import (
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/pkg/block"
+	"github.com/availproject/op-evm/pkg/blockchain"
+	"github.com/availproject/op-evm/pkg/staking"
+	"github.com/hashicorp/go-hclog"
+)
+
+// stageActiveSet refreshes the active set as of the block, carried by the
+// Avail block at the given height, for the next slot, if the block calls the
+// staking contract and made it to the chain. Failing to, the set in effect
+// stays on until the next refresh.
+func stageActiveSet(set *staking.ActiveSet, blockchain *blockchain.Blockchain, blk *types.Block, availHeight uint64, logger hclog.Logger) {
+	if !staking.TouchesStaking(blk) {
+		return
+	}
+
+	if _, ok := blockchain.GetHeaderByHash(blk.Hash()); !ok {
+		return
+	}
+
+	if err := set.Stage(blk.Header, availHeight); err != nil {
+		logger.Error("failed to refresh the active participant set", "block_number", blk.Number(), "block_hash", blk.Hash(), "error", err)
+		return
+	}
+
+	logger.Debug("active participant set staged for the next slot", "block_number", blk.Number(), "avail_block_number", availHeight)
+}
+
+// activeProducer reports whether the block was sealed by an active sequencer.
+// Without any sequencer staked, every producer passes, the same as with the
+// verifier of the chain.
+func activeProducer(set *staking.ActiveSet, blk *types.Block) (bool, error) {
+	sequencers, err := set.Get()
+	if err != nil {
+		return false, err
+	}
+
+	if len(sequencers) == 0 {
+		return true, nil
+	}
+
+	signer, err := block.AddressRecoverFromHeader(blk.Header)
+	if err != nil {
+		return false, err
+	}
+
+	return set.Contains(signer)
+}
@@ -0,0 +1,166 @@
+package avail
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/pkg/avail"
+	"github.com/availproject/op-evm/pkg/avail/testutil"
+	"github.com/availproject/op-evm/pkg/common"
+	"github.com/availproject/op-evm/pkg/staking"
+	"github.com/availproject/op-evm/pkg/test"
+	avail_types "github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	common_eth "github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestActiveSetFollowsStakingAcrossNodes(t *testing.T) {
+	appID := avail_types.NewUCompactFromUInt(1)
+	fake := testutil.NewFake(appID)
+
+	leader, leaderFraudResolver, _ := newTestSequencerWorkerOf(t, newTestGenesisAvail(t), fake)
+	leaderAccount := accounts.Account{Address: common_eth.Address(leader.nodeAddr)}
+	leaderKey := &keystore.Key{PrivateKey: leader.nodeSignKey}
+
+	sw, _, _ := newTestSequencerWorkerOf(t, newTestGenesisAvail(t), fake)
+
+	// Both nodes run from the genesis, with no sequencer staked.
+	for _, worker := range []*SequencerWorker{leader, sw} {
+		sequencers, err := worker.activeSet.Get()
+		if assert.NoError(t, err) {
+			assert.Empty(t, sequencers)
+		}
+	}
+
+	// writeBlock has the leader include the transactions in a block of
+	// its own, submitted to Avail.
+	writeBlock := func(txs ...*types.Transaction) {
+		t.Helper()
+
+		for _, tx := range txs {
+			if err := leader.txpool.AddTx(tx); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		assert.Eventually(t, func() bool { return leader.txpool.Length() == uint64(len(txs)) }, 5*time.Second, 10*time.Millisecond)
+
+		if err := leader.writeBlock(leaderFraudResolver, leaderAccount, leaderKey); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stakeTx := func(worker *SequencerWorker) *types.Transaction {
+		t.Helper()
+
+		tx, err := staking.StakeTx(worker.nodeAddr, stakeAmount, string(staking.Sequencer), 1_000_000)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return (&testSender{addr: worker.nodeAddr, key: worker.nodeSignKey}).sign(t, tx, 1)
+	}
+
+	// The faucet funds both sequencers, and the leader stakes first.
+	faucet := &testSender{addr: test.FaucetAccount, key: test.FaucetSignKey}
+	fund := func(to types.Address) *types.Transaction {
+		return faucet.sign(t, &types.Transaction{To: &to, Value: big.NewInt(0).Mul(big.NewInt(100), common.ETH), Gas: 21_000}, 1)
+	}
+
+	writeBlock(fund(leader.nodeAddr), fund(sw.nodeAddr))
+	writeBlock(stakeTx(leader))
+
+	// follow has the worker follow the Avail block at the given height,
+	// the way the block stream does, and returns the scheduled leader.
+	decoders := map[*SequencerWorker]*avail.BlockDecoder{}
+	follow := func(worker *SequencerWorker, h uint64) types.Address {
+		t.Helper()
+
+		decoder, ok := decoders[worker]
+		if !ok {
+			decoder = avail.NewBlockDecoder(fake, appID, worker.logger)
+			decoders[worker] = decoder
+		}
+
+		availBlk, _ := fake.Block(h)
+		edgeBlks, _ := decoder.Decode(context.Background(), availBlk)
+
+		worker.advanceActiveSet(h)
+		worker.leaders.Observe(h, len(edgeBlks) > 0)
+
+		scheduled := worker.scheduledLeader()
+
+		for _, decoded := range edgeBlks {
+			if err := worker.applyAvailBlock(decoded, inclusionOf(availBlk, decoded, scheduled)); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		return scheduled
+	}
+
+	// schedule has both nodes follow the Avail blocks up to the head, and
+	// returns the leader they agree on at each height.
+	next := uint64(1)
+	schedule := func() map[uint64]types.Address {
+		t.Helper()
+
+		leaders := map[uint64]types.Address{}
+		for ; next <= fake.Head(); next++ {
+			a, b := follow(leader, next), follow(sw, next)
+			assert.Equal(t, a, b, "avail block %d", next)
+
+			leaders[next] = a
+		}
+
+		return leaders
+	}
+
+	// The leader takes over the slot after the one of its stake.
+	leaderStaked := fake.Head()
+	for fake.Head() < (leaderStaked/availBlockWindowLen+1)*availBlockWindowLen+1 {
+		fake.Produce()
+	}
+
+	for h, l := range schedule() {
+		if h/availBlockWindowLen == leaderStaked/availBlockWindowLen {
+			assert.Equal(t, types.ZeroAddress, l, "avail block %d", h)
+		} else {
+			assert.Equal(t, leader.nodeAddr, l, "avail block %d", h)
+		}
+	}
+
+	// The sequencer stakes mid-run; it's scheduled from the next slot on.
+	writeBlock(stakeTx(sw))
+
+	staked := fake.Head()
+	effective := (staked/availBlockWindowLen + 1) * availBlockWindowLen
+
+	for fake.Head() < effective+3*availBlockWindowLen {
+		fake.Produce()
+	}
+
+	scheduled := false
+
+	for h, l := range schedule() {
+		if h < effective {
+			assert.Equal(t, leader.nodeAddr, l, "avail block %d", h)
+		} else if l == sw.nodeAddr {
+			scheduled = true
+		}
+	}
+
+	assert.True(t, scheduled, "the new sequencer never scheduled")
+
+	for _, worker := range []*SequencerWorker{leader, sw} {
+		sequencers, err := worker.activeSet.Get()
+		if assert.NoError(t, err) {
+			assert.ElementsMatch(t, []types.Address{leader.nodeAddr, sw.nodeAddr}, sequencers)
+		}
+	}
+}
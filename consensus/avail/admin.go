@@ -0,0 +1,208 @@
+package avail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// AdminNamespace is the JSON-RPC namespace of the admin API of the node.
+const AdminNamespace = "availAdmin"
+
+// errNoCircuitBreaker is returned by the admin API of the node running
+// without the circuit breaker.
+var errNoCircuitBreaker = errors.New("circuit breaker not set up")
+
+// AdminAPI serves the operator actions on the consensus of the node over
+// JSON-RPC; it's meant to be served to the operator only, apart from the
+// public APIs.
+type AdminAPI struct {
+	d *Avail
+}
+
+// NewAdminAPI returns the AdminAPI of the consensus.
+func NewAdminAPI(d *Avail) *AdminAPI {
+	return &AdminAPI{d: d}
+}
+
+// ResumeCircuitBreaker resumes the tripped circuit breaker, so the node goes
+// on producing and applying the blocks. It reports whether the circuit
+// breaker was tripped.
+func (api *AdminAPI) ResumeCircuitBreaker() (bool, error) {
+	if api.d.breaker == nil {
+		return false, errNoCircuitBreaker
+	}
+
+	return api.d.breaker.resume()
+}
+
+// CheckBlock checks the block of the given hash or number in full, the way
+// the watchtowers do, re-executing it, and returns the verdict; the
+// watchtowers under the light sync check the blocks out of their sample on
+// demand with it. Asked to with the options, the node running as a
+// watchtower submits the fraud proof of the block failing the check, unless
+// the block is past its challenge window.
+func (api *AdminAPI) CheckBlock(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash, opts *BlockCheckOptions) (*BlockCheck, error) {
+	hdr, err := api.d.headerByNumberOrHash(blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts == nil {
+		opts = &BlockCheckOptions{}
+	}
+
+	return api.d.checkBlock(ctx, hdr, *opts)
+}
+
+// ExportSnapshot writes the chain snapshot at the settled head to the file of
+// the given path on the node, for a new node to start from with
+// ImportSnapshot. It returns the head of the snapshot.
+func (api *AdminAPI) ExportSnapshot(path string) (SettledHead, error) {
+	return exportToFile(path, api.d.exportChainSnapshot)
+}
+
+// ImportSnapshot has the node start from the chain snapshot of the file of the
+// given path on the node, written by ExportSnapshot; the node follows Avail on
+// from the head of the snapshot, which it returns. The snapshots within their
+// challenge window are refused.
+func (api *AdminAPI) ImportSnapshot(path string) (SettledHead, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return SettledHead{}, err
+	}
+
+	defer f.Close()
+
+	return api.d.importChainSnapshot(f)
+}
+
+// ExportStateDiff writes the state diff from the block at the first given
+// height to the settled one at the second to the file of the given path on
+// the node, for a node started from a snapshot at the first to move on to
+// the second with ImportStateDiff. It returns the head of the diff.
+func (api *AdminAPI) ExportStateDiff(fromHeight, toHeight uint64, path string) (SettledHead, error) {
+	return exportToFile(path, func(w io.Writer) (SettledHead, error) {
+		return api.d.exportStateDiff(w, fromHeight, toHeight)
+	})
+}
+
+// ImportStateDiff has the node move on from its head to the head of the state
+// diff of the file of the given path on the node, written by ExportStateDiff,
+// which it returns. The diffs not starting from the head of the node, and the
+// ones within their challenge window, are refused.
+func (api *AdminAPI) ImportStateDiff(path string) (SettledHead, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return SettledHead{}, err
+	}
+
+	defer f.Close()
+
+	return api.d.importStateDiff(f)
+}
+
+// ExportChain writes the chain archive of the canonical blocks of the given
+// range, along with their receipts, to the file of the given path on the
+// node, for another node to import with ImportChain; the archive is
+// independent of the storage engine of the node. It returns the range
+// archived.
+func (api *AdminAPI) ExportChain(fromHeight, toHeight uint64, path string) (ChainArchiveRange, error) {
+	var archived ChainArchiveRange
+
+	_, err := exportToFile(path, func(w io.Writer) (SettledHead, error) {
+		var err error
+
+		archived, err = api.d.exportChain(w, fromHeight, toHeight)
+
+		return SettledHead{Number: archived.To, Hash: archived.Hash}, err
+	})
+
+	return archived, err
+}
+
+// ImportChain writes the blocks of the chain archive of the file of the given
+// path on the node, written by ExportChain, on top of the head of the node,
+// checking and executing them as the blocks seen on Avail; under trusted,
+// they're written short of being executed, the state at them left unheld.
+// The import failing leaves the chain at the last block written. It returns
+// the range of the blocks written.
+func (api *AdminAPI) ImportChain(path string, trusted bool) (ChainArchiveRange, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return ChainArchiveRange{}, err
+	}
+
+	defer f.Close()
+
+	return api.d.importChain(f, trusted)
+}
+
+// VerifyIntegrity checks the database of the node to be internally consistent
+// up to the block at the given height, and returns the report of the entries
+// missing or corrupt; see Avail.VerifyIntegrity. Given the JSON-RPC URL of a
+// state provider, the trie nodes and the codes missing or corrupt are
+// refetched from it. The check interrupted, with the request, resumes on the
+// next call for the same block.
+func (api *AdminAPI) VerifyIntegrity(ctx context.Context, height uint64, repairFrom string) (*IntegrityReport, error) {
+	var opts []IntegrityOption
+
+	if repairFrom != "" {
+		client, err := rpc.DialContext(ctx, repairFrom)
+		if err != nil {
+			return nil, fmt.Errorf("failed to dial the state provider %q: %w", repairFrom, err)
+		}
+
+		defer client.Close()
+
+		opts = append(opts, WithIntegrityRepair(newStateProvider(client, api.d.logger.Named("integrity"))))
+	}
+
+	return api.d.VerifyIntegrity(ctx, height, opts...)
+}
+
+// RemovePoolTransaction removes the transaction of the given hash from the
+// txpool, along with the later ones of its sender if dependents is set, and
+// returns the transactions removed. The later ones kept stay queued until
+// the nonce freed is taken again.
+func (api *AdminAPI) RemovePoolTransaction(hash types.Hash, dependents *bool) (*PoolRemoval, error) {
+	return api.d.removePoolTx(hash, dependents != nil && *dependents)
+}
+
+// ReloadConfig re-reads the config file of the node, applies the hot
+// parameters changed, the log level, the price limit, the block gas target,
+// the Avail endpoints and the escalation webhook, and reports the changes
+// applied and the ones rejected, such as of the chain ID, the data directory
+// or the keys, taking a restart.
+func (api *AdminAPI) ReloadConfig() (*ConfigReload, error) {
+	return api.d.ReloadConfig()
+}
+
+// exportToFile has the export write to the file of the given path, replacing
+// it once the export is complete.
+func exportToFile(path string, export func(io.Writer) (SettledHead, error)) (SettledHead, error) {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return SettledHead{}, err
+	}
+
+	defer os.Remove(f.Name())
+
+	head, err := export(f)
+	if err != nil {
+		f.Close()
+		return SettledHead{}, err
+	}
+
+	if err := f.Close(); err != nil {
+		return SettledHead{}, err
+	}
+
+	return head, os.Rename(f.Name(), path)
+}
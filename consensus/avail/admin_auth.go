@@ -0,0 +1,376 @@
+package avail
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// adminJWTSecretLength is the length of the secret the admin tokens
+	// are signed with, in bytes.
+	adminJWTSecretLength = 32
+
+	// adminJWTMaxDrift is the furthest the issuing time of an admin token
+	// may be from the time of the call, either way, as in the engine API.
+	adminJWTMaxDrift = 60 * time.Second
+
+	// adminMaxRequestSize is the largest JSON-RPC request the admin auth
+	// looks into, as served by the JSON-RPC server.
+	adminMaxRequestSize = 5 * 1024 * 1024
+
+	// errCodeUnauthorized is the JSON-RPC error code of the unauthorized
+	// calls to the admin namespace.
+	errCodeUnauthorized = -32001
+
+	// errCodeParse is the JSON-RPC error code of the requests that don't
+	// parse.
+	errCodeParse = -32700
+)
+
+var (
+	errAdminTokenMissing = errors.New("missing bearer token")
+	errAdminTokenInvalid = errors.New("invalid token")
+	errAdminTokenStale   = errors.New("stale token")
+	errAdminTokenExpired = errors.New("token is expired")
+)
+
+// AdminAuthConfig is the authentication of the calls to the admin namespace
+// of a JSON-RPC listener. The calls carry a JWT signed with the shared
+// secret as bearer token, as in the engine API, or, with a client CA, come
+// over TLS with a client certificate signed by it.
+type AdminAuthConfig struct {
+	// JWTSecretFile is the file of the hex encoded secret of the tokens,
+	// created with a random secret if missing.
+	JWTSecretFile string
+
+	// TLSCertFile and TLSKeyFile are the certificate and key the listener
+	// serves TLS with; empty serves plain HTTP.
+	TLSCertFile string
+	TLSKeyFile  string
+
+	// TLSClientCAFile is the file of the CA certificates the clients
+	// authenticated with a certificate instead of a token are signed by;
+	// empty authenticates the clients with a token only.
+	TLSClientCAFile string
+}
+
+// TLSConfig returns the TLS configuration of the listener, or nil to serve
+// plain HTTP.
+func (c AdminAuthConfig) TLSConfig() (*tls.Config, error) {
+	if c.TLSCertFile == "" && c.TLSKeyFile == "" {
+		if c.TLSClientCAFile != "" {
+			return nil, errors.New("the TLS client CA requires the TLS certificate and key")
+		}
+
+		return nil, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.TLSCertFile, c.TLSKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the TLS certificate: %w", err)
+	}
+
+	cfg := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+
+	if c.TLSClientCAFile != "" {
+		pem, err := os.ReadFile(c.TLSClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the TLS client CA: %w", err)
+		}
+
+		cfg.ClientCAs = x509.NewCertPool()
+		if !cfg.ClientCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in the TLS client CA %q", c.TLSClientCAFile)
+		}
+
+		// The clients without a certificate may still authenticate with a
+		// token.
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	return cfg, nil
+}
+
+// LoadAdminJWTSecret reads the hex encoded secret of the admin tokens from
+// the file; missing, the file is created with a random secret if create is
+// set.
+func LoadAdminJWTSecret(path string, create bool) ([]byte, error) {
+	raw, err := os.ReadFile(path)
+	if os.IsNotExist(err) && create {
+		secret := make([]byte, adminJWTSecretLength)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+
+		if err := os.WriteFile(path, []byte(hex.EncodeToString(secret)), 0o600); err != nil {
+			return nil, fmt.Errorf("failed to write the JWT secret: %w", err)
+		}
+
+		return secret, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read the JWT secret: %w", err)
+	}
+
+	secret, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(string(raw)), "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid JWT secret in %q: %w", path, err)
+	}
+
+	if len(secret) != adminJWTSecretLength {
+		return nil, fmt.Errorf("invalid JWT secret in %q: %d bytes, want %d", path, len(secret), adminJWTSecretLength)
+	}
+
+	return secret, nil
+}
+
+// adminJWTHeader is the header of the admin tokens, HS256 signed.
+type adminJWTHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ,omitempty"`
+}
+
+// adminJWTClaims are the claims of the admin tokens checked: the issuing
+// time, and the expiry if any.
+type adminJWTClaims struct {
+	IssuedAt  *int64 `json:"iat"`
+	ExpiresAt *int64 `json:"exp,omitempty"`
+}
+
+// NewAdminJWT returns a token for the admin namespace signed with the
+// secret, issued now; it's valid for about a minute.
+func NewAdminJWT(secret []byte) (string, error) {
+	return newAdminJWT(secret, time.Now())
+}
+
+func newAdminJWT(secret []byte, issuedAt time.Time) (string, error) {
+	header, err := json.Marshal(adminJWTHeader{Alg: "HS256", Typ: "JWT"})
+	if err != nil {
+		return "", err
+	}
+
+	iat := issuedAt.Unix()
+
+	claims, err := json.Marshal(adminJWTClaims{IssuedAt: &iat})
+	if err != nil {
+		return "", err
+	}
+
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(adminJWTSignature(secret, signed)), nil
+}
+
+func adminJWTSignature(secret []byte, signed string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signed))
+
+	return mac.Sum(nil)
+}
+
+// verifyAdminJWT checks the token is signed with the secret, and issued
+// about now.
+func verifyAdminJWT(secret []byte, token string, now time.Time) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return errAdminTokenInvalid
+	}
+
+	var header adminJWTHeader
+	if err := decodeAdminJWTPart(parts[0], &header); err != nil || header.Alg != "HS256" {
+		return errAdminTokenInvalid
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(sig, adminJWTSignature(secret, parts[0]+"."+parts[1])) {
+		return errAdminTokenInvalid
+	}
+
+	var claims adminJWTClaims
+	if err := decodeAdminJWTPart(parts[1], &claims); err != nil || claims.IssuedAt == nil {
+		return errAdminTokenInvalid
+	}
+
+	if drift := now.Sub(time.Unix(*claims.IssuedAt, 0)); drift > adminJWTMaxDrift || drift < -adminJWTMaxDrift {
+		return errAdminTokenStale
+	}
+
+	if claims.ExpiresAt != nil && !now.Before(time.Unix(*claims.ExpiresAt, 0)) {
+		return errAdminTokenExpired
+	}
+
+	return nil
+}
+
+func decodeAdminJWTPart(part string, v interface{}) error {
+	raw, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(raw, v)
+}
+
+// adminAuthHandler authenticates the calls to the admin namespace of the
+// JSON-RPC server it serves; the calls to the other namespaces are served
+// as they are.
+type adminAuthHandler struct {
+	secret []byte
+	next   http.Handler
+}
+
+// NewAdminAuthHandler returns the handler serving the JSON-RPC server,
+// answering the unauthenticated requests calling the admin namespace with
+// a JSON-RPC error instead; the requests authenticate with a token signed
+// with the secret, or a verified TLS client certificate.
+func NewAdminAuthHandler(secret []byte, next http.Handler) http.Handler {
+	return &adminAuthHandler{secret: secret, next: next}
+}
+
+// adminRequest is a JSON-RPC call as seen by the admin auth.
+type adminRequest struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+}
+
+func (h *adminAuthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, adminMaxRequestSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	// The requests that don't parse are refused; the calls of a batch that
+	// don't, which the JSON-RPC server skips, take the authentication, as
+	// the calls to the admin namespace do.
+	reqs, batch, malformed, err := parseAdminRequests(body)
+	if err != nil {
+		writeAdminErrors(w, []adminRequest{{}}, false, errCodeParse, "parse error")
+		return
+	}
+
+	if !malformed && !callsAdminNamespace(reqs) {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	if err := h.authenticate(r); err != nil {
+		writeAdminErrors(w, reqs, batch, errCodeUnauthorized, "unauthorized: "+err.Error())
+		return
+	}
+
+	h.next.ServeHTTP(w, r)
+}
+
+// authenticate checks the request comes with a verified TLS client
+// certificate, or a valid token.
+func (h *adminAuthHandler) authenticate(r *http.Request) error {
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		return nil
+	}
+
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return errAdminTokenMissing
+	}
+
+	return verifyAdminJWT(h.secret, strings.TrimPrefix(auth, "Bearer "), time.Now())
+}
+
+// parseAdminRequests returns the calls of the request, whether it's a batch,
+// and whether any call of the batch doesn't parse; the calls that don't are
+// returned without an ID or a method. It fails for the request that doesn't
+// parse as a call or a batch.
+func parseAdminRequests(body []byte) ([]adminRequest, bool, bool, error) {
+	body = bytes.TrimSpace(body)
+
+	if len(body) > 0 && body[0] == '[' {
+		var elems []json.RawMessage
+		if err := json.Unmarshal(body, &elems); err != nil {
+			return nil, true, false, err
+		}
+
+		// The calls are parsed one by one, as by the JSON-RPC server.
+		reqs := make([]adminRequest, len(elems))
+		malformed := false
+
+		for i, elem := range elems {
+			if err := json.Unmarshal(elem, &reqs[i]); err != nil {
+				reqs[i], malformed = adminRequest{}, true
+			}
+		}
+
+		return reqs, true, malformed, nil
+	}
+
+	var req adminRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, false, false, err
+	}
+
+	return []adminRequest{req}, false, false, nil
+}
+
+func callsAdminNamespace(reqs []adminRequest) bool {
+	for _, req := range reqs {
+		if strings.HasPrefix(req.Method, AdminNamespace+"_") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// writeAdminErrors answers every call of the request with the JSON-RPC
+// error.
+func writeAdminErrors(w http.ResponseWriter, reqs []adminRequest, batch bool, code int, message string) {
+	type rpcError struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+
+	type rpcResponse struct {
+		Version string          `json:"jsonrpc"`
+		ID      json.RawMessage `json:"id"`
+		Error   rpcError        `json:"error"`
+	}
+
+	resps := make([]rpcResponse, 0, len(reqs))
+	for _, req := range reqs {
+		id := req.ID
+		if len(id) == 0 {
+			id = json.RawMessage("null")
+		}
+
+		resps = append(resps, rpcResponse{Version: "2.0", ID: id, Error: rpcError{Code: code, Message: message}})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if batch {
+		_ = json.NewEncoder(w).Encode(resps)
+		return
+	}
+
+	_ = json.NewEncoder(w).Encode(resps[0])
+}
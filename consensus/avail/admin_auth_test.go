@@ -0,0 +1,251 @@
+package avail
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/availproject/op-evm/pkg/avail"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+// assertUnauthorized asserts the call failed with the JSON-RPC error of the
+// unauthorized calls.
+func assertUnauthorized(t *testing.T, err error) {
+	t.Helper()
+
+	var rpcErr rpc.Error
+	if assert.True(t, errors.As(err, &rpcErr), "not a JSON-RPC error: %v", err) {
+		assert.Equal(t, errCodeUnauthorized, rpcErr.ErrorCode())
+	}
+}
+
+func TestAdminAuth(t *testing.T) {
+	d := newTestGenesisAvail(t)
+	d.breaker = newCircuitBreaker(DefaultCircuitBreakerConfig(), "", systemClock{}, hclog.NewNullLogger())
+
+	// The admin namespace is served along with a public one.
+	srv := rpc.NewServer()
+	if err := srv.RegisterName(AdminNamespace, NewAdminAPI(d)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := srv.RegisterName(avail.SettlementNamespace, NewStatusAPI(d)); err != nil {
+		t.Fatal(err)
+	}
+
+	secretFile := filepath.Join(t.TempDir(), "jwtsecret")
+
+	secret, err := LoadAdminJWTSecret(secretFile, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The secret created is the one read back.
+	reloaded, err := LoadAdminJWTSecret(secretFile, false)
+	assert.NoError(t, err)
+	assert.Equal(t, secret, reloaded)
+
+	httpSrv := httptest.NewServer(NewAdminAuthHandler(secret, srv))
+	defer httpSrv.Close()
+
+	dial := func(token string) *rpc.Client {
+		t.Helper()
+
+		c, err := rpc.Dial(httpSrv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if token != "" {
+			c.SetHeader("Authorization", "Bearer "+token)
+		}
+
+		t.Cleanup(c.Close)
+
+		return c
+	}
+
+	var resumed bool
+
+	// No token.
+	anonymous := dial("")
+	assertUnauthorized(t, anonymous.Call(&resumed, AdminNamespace+"_resumeCircuitBreaker"))
+
+	// The public namespace needs none.
+	var settled SettledHead
+	assert.NoError(t, anonymous.Call(&settled, avail.SettlementNamespace+"_getSettledHead"))
+
+	// A token signed with another secret, and a stale one.
+	other := make([]byte, adminJWTSecretLength)
+
+	forged, err := NewAdminJWT(other)
+	assert.NoError(t, err)
+	assertUnauthorized(t, dial(forged).Call(&resumed, AdminNamespace+"_resumeCircuitBreaker"))
+
+	stale, err := newAdminJWT(secret, time.Now().Add(-2*adminJWTMaxDrift))
+	assert.NoError(t, err)
+	assertUnauthorized(t, dial(stale).Call(&resumed, AdminNamespace+"_resumeCircuitBreaker"))
+
+	assertUnauthorized(t, dial("not.a.token").Call(&resumed, AdminNamespace+"_resumeCircuitBreaker"))
+
+	// A batch calling the admin namespace is refused as a whole.
+	batch := []rpc.BatchElem{
+		{Method: avail.SettlementNamespace + "_getSettledHead", Result: &settled},
+		{Method: AdminNamespace + "_resumeCircuitBreaker", Result: &resumed},
+	}
+	assert.NoError(t, anonymous.BatchCall(batch))
+
+	for _, elem := range batch {
+		assertUnauthorized(t, elem.Error)
+	}
+
+	// A valid token.
+	token, err := NewAdminJWT(secret)
+	assert.NoError(t, err)
+	assert.NoError(t, dial(token).Call(&resumed, AdminNamespace+"_resumeCircuitBreaker"))
+	assert.False(t, resumed)
+}
+
+func TestLoadAdminJWTSecret(t *testing.T) {
+	dir := t.TempDir()
+
+	// Missing, the secret isn't created unless asked to.
+	_, err := LoadAdminJWTSecret(filepath.Join(dir, "missing"), false)
+	assert.Error(t, err)
+
+	short := filepath.Join(dir, "short")
+	if err := os.WriteFile(short, []byte("0x0102"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = LoadAdminJWTSecret(short, false)
+	assert.ErrorContains(t, err, "2 bytes")
+
+	prefixed := filepath.Join(dir, "prefixed")
+	if err := os.WriteFile(prefixed, []byte("0x"+"ab"+"00000000000000000000000000000000000000000000000000000000000000\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	secret, err := LoadAdminJWTSecret(prefixed, false)
+	assert.NoError(t, err)
+	assert.Len(t, secret, adminJWTSecretLength)
+	assert.Equal(t, byte(0xab), secret[0])
+}
+
+// testAdminService counts the calls to it served.
+type testAdminService struct {
+	calls int32
+}
+
+func (s *testAdminService) Ping() bool {
+	atomic.AddInt32(&s.calls, 1)
+	return true
+}
+
+func TestAdminAuthMalformedRequests(t *testing.T) {
+	service := new(testAdminService)
+
+	srv := rpc.NewServer()
+	if err := srv.RegisterName(AdminNamespace, service); err != nil {
+		t.Fatal(err)
+	}
+
+	secret := make([]byte, adminJWTSecretLength)
+
+	httpSrv := httptest.NewServer(NewAdminAuthHandler(secret, srv))
+	defer httpSrv.Close()
+
+	post := func(body, token string) []map[string]interface{} {
+		t.Helper()
+
+		req, err := http.NewRequest(http.MethodPost, httpSrv.URL, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Content-Type", "application/json")
+
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		var raw json.RawMessage
+		if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+			t.Fatal(err)
+		}
+
+		var resps []map[string]interface{}
+		if len(raw) > 0 && raw[0] == '[' {
+			assert.NoError(t, json.Unmarshal(raw, &resps))
+		} else {
+			resps = make([]map[string]interface{}, 1)
+			assert.NoError(t, json.Unmarshal(raw, &resps[0]))
+		}
+
+		return resps
+	}
+
+	errCode := func(resp map[string]interface{}) float64 {
+		e, _ := resp["error"].(map[string]interface{})
+		code, _ := e["code"].(float64)
+
+		return code
+	}
+
+	ping := `{"jsonrpc":"2.0","id":1,"method":"` + AdminNamespace + `_ping"}`
+
+	// A batch with a call that doesn't parse, along with one to the admin
+	// namespace, or alone, takes the authentication as a whole.
+	for _, body := range []string{
+		`[` + ping + `,{"method":1}]`,
+		`[{"jsonrpc":"2.0","id":2,"method":"rpc_modules"},{"method":1}]`,
+	} {
+		resps := post(body, "")
+		if assert.Len(t, resps, 2, body) {
+			for _, resp := range resps {
+				assert.Equal(t, float64(errCodeUnauthorized), errCode(resp), body)
+			}
+		}
+	}
+
+	// The requests that don't parse are refused.
+	for _, body := range []string{`{"method":`, `[` + ping + `,`, `"` + AdminNamespace + `_ping"`} {
+		resps := post(body, "")
+		if assert.Len(t, resps, 1, body) {
+			assert.Equal(t, float64(errCodeParse), errCode(resps[0]), body)
+		}
+	}
+
+	assert.Zero(t, atomic.LoadInt32(&service.calls))
+
+	// Authenticated, the batch is served, the call that doesn't parse
+	// answered with an error.
+	token, err := NewAdminJWT(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resps := post(`[`+ping+`,{"method":1}]`, token)
+	if assert.Len(t, resps, 2) {
+		assert.Equal(t, true, resps[0]["result"])
+		assert.NotZero(t, errCode(resps[1]))
+	}
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&service.calls))
+}
@@ -15,6 +15,7 @@ import (
 	"crypto/ecdsa"
 	"fmt"
 	"math/big"
+	"path/filepath"
 	"strings"
 	"sync"
 	"time"
@@ -26,16 +27,18 @@ import (
 	"github.com/0xPolygon/polygon-edge/network"
 	"github.com/0xPolygon/polygon-edge/secrets"
 	"github.com/0xPolygon/polygon-edge/state"
+	itrie "github.com/0xPolygon/polygon-edge/state/immutable-trie"
 	"github.com/0xPolygon/polygon-edge/txpool"
 	"github.com/0xPolygon/polygon-edge/types"
 	"github.com/availproject/op-evm/consensus/avail/validator"
 	"github.com/availproject/op-evm/pkg/avail"
+	"github.com/availproject/op-evm/pkg/block"
 	"github.com/availproject/op-evm/pkg/blockchain"
 	common_defs "github.com/availproject/op-evm/pkg/common"
 	"github.com/availproject/op-evm/pkg/faucet"
+	"github.com/availproject/op-evm/pkg/pruning"
 	"github.com/availproject/op-evm/pkg/snapshot"
 	"github.com/availproject/op-evm/pkg/staking"
-	"github.com/centrifuge/go-substrate-rpc-client/v4/signature"
 	avail_types "github.com/centrifuge/go-substrate-rpc-client/v4/types"
 	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/accounts/keystore"
@@ -48,8 +51,143 @@ const (
 	// AVL represents 1 Avail token which is equivalent to 10^18 fractions of an Avail token.
 	AVL = 1_000_000_000_000_000_000
 
-	// DefaultBlockProductionIntervalS represents the default interval in seconds for attempting block production.
-	DefaultBlockProductionIntervalS = 1
+	// DefaultBlockTime is the default target time between the blocks produced by the sequencers.
+	DefaultBlockTime = time.Second
+
+	// MinBlockTime is the shortest supported block time; block timestamps
+	// have the resolution of a second.
+	MinBlockTime = time.Second
+
+	// DefaultLeaderTimeoutBlocks is the default number of Avail blocks
+	// without a new block after which the next sequencer takes over the slot.
+	DefaultLeaderTimeoutBlocks = 2
+
+	// DefaultSlotStallTimeout is the default time without a new Avail block
+	// after which the production slots fall back to the wall clock.
+	DefaultSlotStallTimeout = 3 * avail.TargetBlockTime
+
+	// DefaultTxPoolSweepBlocks is the default number of blocks applied to
+	// the chain in between the sweeps of the txpool.
+	DefaultTxPoolSweepBlocks = 10
+
+	// DefaultMaxBlockSizeBytes is the default size limit of the encoded
+	// blocks, the largest block that settles on Avail.
+	DefaultMaxBlockSizeBytes = avail.MaxBlockSize
+
+	// DefaultMaxTimestampDrift is the default time the timestamps of the
+	// blocks received from Avail may run ahead of the local clock.
+	DefaultMaxTimestampDrift = block.DefaultMaxTimestampDrift
+
+	// DefaultFailingSenderThreshold is the default number of failed
+	// transactions in a row past which their sender is banned from the
+	// blocks.
+	DefaultFailingSenderThreshold = 5
+
+	// DefaultFailingSenderBanBlocks is the default number of blocks the
+	// first ban of a sender of failing transactions lasts.
+	DefaultFailingSenderBanBlocks = 10
+
+	// DefaultMaxSettlementLag is the default number of blocks the head of
+	// the chain may run ahead of the blocks settled on Avail before the
+	// block production pauses.
+	DefaultMaxSettlementLag = 4 * availBlockWindowLen
+
+	// DefaultMaxUnresolvedDisputes is the default number of disputes left
+	// unresolved within the circuit breaker window past which the
+	// consensus halts.
+	DefaultMaxUnresolvedDisputes = 3
+
+	// DefaultMaxTaintedBlocks is the default number of blocks targeted by
+	// fraud proofs within the circuit breaker window past which the
+	// consensus halts.
+	DefaultMaxTaintedBlocks = 5
+
+	// DefaultCircuitBreakerWindow is the default number of Avail blocks the
+	// circuit breaker counts the fraud proofs and the disputes over.
+	DefaultCircuitBreakerWindow = 10 * availBlockWindowLen
+
+	// DefaultMaxIdleInterval is the default longest time the chain goes
+	// without a block when the empty blocks aren't produced.
+	DefaultMaxIdleInterval = time.Minute
+
+	// DefaultCatchUpThreshold is the default number of Avail blocks the node
+	// may lag behind the head before it catches up.
+	DefaultCatchUpThreshold = 4 * availBlockWindowLen
+
+	// DefaultCatchUpPageSize is the default number of Avail blocks fetched at
+	// once while catching up.
+	DefaultCatchUpPageSize = 100
+
+	// DefaultCatchUpWorkers is the default number of the Avail blocks
+	// prepared concurrently while catching up.
+	DefaultCatchUpWorkers = 4
+
+	// DefaultSyncPipelineDepth is the default number of the pages of Avail
+	// blocks fetched ahead while syncing the Avail history on the start.
+	DefaultSyncPipelineDepth = 4
+
+	// DefaultMaxReorgDepth is the default number of blocks the fork choice
+	// may roll back to switch to the preferred one of the competing blocks.
+	DefaultMaxReorgDepth = availBlockWindowLen
+
+	// DefaultMaxStartupRewindDepth is the default number of blocks the
+	// startup consistency check may rewind the local chain diverging from
+	// Avail by, past which the node refuses to start.
+	DefaultMaxStartupRewindDepth = availBlockWindowLen
+
+	// DefaultChallengeWindow is the default number of Avail blocks after
+	// its inclusion a block may be challenged with a fraud proof, before it
+	// settles.
+	DefaultChallengeWindow = 2 * availBlockWindowLen
+
+	// DefaultDisputeChallengeWindow is the default number of blocks a
+	// dispute stays open for the fraud proof of its watchtower to be
+	// verified, before the leader ends it.
+	DefaultDisputeChallengeWindow = 10 * availBlockWindowLen
+
+	// DefaultDisputeEscalationMultiple is the default multiple of the
+	// dispute challenge window a dispute stays open for before it escalates.
+	DefaultDisputeEscalationMultiple = 3
+
+	// DefaultReplayCheckpointInterval is the default number of Avail blocks
+	// the replay of the Avail history on the start processes between its
+	// checkpoints.
+	DefaultReplayCheckpointInterval = 1000
+
+	// DefaultLightSyncSamplePercent is the default percentage of the blocks
+	// the watchtowers under the light sync re-execute.
+	DefaultLightSyncSamplePercent = 10
+
+	// DefaultStateRetention is the default number of the latest blocks
+	// whose state is retained by the pruning, well beyond the challenge and
+	// the dispute challenge windows.
+	DefaultStateRetention = 10_000
+
+	// DefaultStatePruneBatchSize is the default number of the trie nodes
+	// deleted at a time by the pruning.
+	DefaultStatePruneBatchSize = 1000
+
+	// DefaultStatePruneBatchInterval is the default pause of the pruning
+	// between the batches of the trie nodes deleted.
+	DefaultStatePruneBatchInterval = 100 * time.Millisecond
+
+	// DefaultSnapshotRetention is the default number of the latest chain
+	// snapshots kept, the older ones removed.
+	DefaultSnapshotRetention = 2
+
+	// DefaultFraudProofQuorum is the default number of distinct staked
+	// watchtowers whose fraud proofs must accuse a block for it to be
+	// disputed; one takes the fraud proof of any single watchtower.
+	DefaultFraudProofQuorum = 1
+
+	// DefaultShutdownTimeout is the default deadline of the graceful shutdown
+	// for the block in flight to settle.
+	DefaultShutdownTimeout = 30 * time.Second
+
+	// DefaultFraudTip is the default tip paid for the inclusion of the fraud
+	// proof and dispute resolution blocks in Avail, to have them prioritized
+	// over the routine blocks.
+	DefaultFraudTip = 10 * avail.DefaultTip
 
 	// StakingPollPeersIntervalMs is the interval in milliseconds to wait for when waiting for peers to come up before staking.
 	StakingPollPeersIntervalMs = 200
@@ -66,11 +204,11 @@ var balanceOnce sync.Once
 // Config is a structure that holds various configuration options required by the Avail consensus protocol.
 type Config struct {
 	AccountFilePath       string
-	AvailAccount          signature.KeyringPair
+	AvailAccount          avail.SignatureProvider
 	AvailClient           avail.Client
 	AvailSender           avail.Sender
 	Blockchain            *blockchain.Blockchain
-	BlockTime             uint64
+	BlockTime             time.Duration
 	Bootnode              bool
 	Chain                 *chain.Chain
 	Context               context.Context
@@ -82,9 +220,66 @@ type Config struct {
 	NodeType              string
 	SecretsManager        secrets.SecretsManager
 	Snapshotter           snapshot.Snapshotter
+	StateStorage          itrie.Storage
 	TxPool                *txpool.TxPool
 	AvailAppID            avail_types.UCompact
+	AvailFraudTip         uint64
 	NumBlockConfirmations uint64
+
+	// MinSubmissionInterval is the shortest interval between the block
+	// submissions the Avail submission pacing sustains; the block time is
+	// raised to it, so blocks aren't produced faster than they settle.
+	MinSubmissionInterval time.Duration
+
+	// ResumeCircuitBreaker resumes the circuit breaker left tripped by the
+	// previous run on the start.
+	ResumeCircuitBreaker bool
+
+	// LightSync is the light sync of the watchtower, if enabled; the state
+	// storage is expected to fetch the state from its provider, see
+	// NewProviderStateStorage.
+	LightSync LightSyncConfig
+
+	// SyncProgress, if set, is sent the progress of the node syncing the
+	// chain from Avail, for the CLI to render; it's drained of the oldest
+	// events the reader falls behind on.
+	SyncProgress chan SyncStatus
+
+	// TrustedSync is the trusted-root fast sync, if enabled; the blocks at or
+	// below the trusted height aren't executed in the replay.
+	TrustedSync TrustedSyncConfig
+
+	// Snapshots configures the serving of the chain snapshots to the new
+	// nodes, and the bootstrapping from the ones of a peer.
+	Snapshots SnapshotConfig
+
+	// PrunableStateStorage is the state storage underlying StateStorage, if
+	// it may be pruned of the state past the retention; nil disables the
+	// pruning.
+	PrunableStateStorage *pruning.Storage
+
+	// Settlements is the index of the Avail inclusions of the blocks the
+	// node submitted, if kept; the block statuses are assembled from it,
+	// along with the blocks seen on Avail.
+	Settlements *avail.SettlementIndex
+
+	// Health is the thresholds of the health checks of the node, served by
+	// `avail_health` and `/health`.
+	Health HealthConfig
+
+	// ByzantinePolicy, if set, turns the sequencer byzantine for testing the
+	// fraud pipeline end to end; see ByzantinePolicy. The node fails to start
+	// with one in the builds without the byzantine tag.
+	ByzantinePolicy ByzantinePolicy
+
+	// Operational is the configuration of the node read from its config
+	// file, if any; its hot parameters set take the place of the engine
+	// params of the chain.
+	Operational *OperationalConfig
+
+	// LoadOperationalConfig re-reads the configuration of the node from its
+	// config file on the reload of the config; nil disables the reload.
+	LoadOperationalConfig func() (*OperationalConfig, error)
 }
 
 // Avail represents the consensus protocol for the Avail network.
@@ -97,33 +292,91 @@ type Avail struct {
 	notifyCh chan struct{}
 	closeCh  chan struct{}
 
+	// ctx is the run context of the consensus; it's canceled on Close and
+	// bounds all the calls made to Avail.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// shutdown closes the close channel and cancels the run context once the
+	// block in flight settles.
+	shutdown *gracefulShutdown
+
+	// role is the run of the loops of the current role of the node, nil
+	// until it starts; roleLock guards it along with the node type, and
+	// switchLock serializes the role switches. See SetNodeMode.
+	roleLock   sync.Mutex
+	switchLock sync.Mutex
+	role       *roleRun
+
 	availAppID avail_types.UCompact
 	signKey    *ecdsa.PrivateKey
 	minerAddr  types.Address
 
-	interval uint64
-	txpool   *txpool.TxPool
+	// bootstrapAccounts is the allowlist of the accounts that may bootstrap
+	// the chain; see bootstrap.
+	bootstrapAccounts []types.Address
+
+	interval  uint64
+	txpool    *txpool.TxPool
+	poolIndex *txPoolIndex // Lists the transactions of the txpool by sender
 
 	chain               *chain.Chain
 	blockchain          *blockchain.Blockchain
 	executor            *state.Executor
 	snapshotter         snapshot.Snapshotter
+	stateStorage        itrie.Storage // Backs the chain snapshots, see exportChainSnapshot
 	snapshotDistributor snapshot.Distributor
 	verifier            blockchain.Verifier
 
-	network        *network.Server // Reference to the networking layer
-	secretsManager secrets.SecretsManager
-	blockTime      time.Duration // Minimum block generation time in seconds
-
-	availAccount signature.KeyringPair
-	availClient  avail.Client
-	availSender  avail.Sender
-	stakingNode  staking.Node
-
-	blockProductionIntervalSec uint64
-	validator                  validator.Validator
-	currentNodeSyncIndex       uint64
-	fraudListenerAddr          string
+	network           *network.Server // Reference to the networking layer
+	secretsManager    secrets.SecretsManager
+	blockTime         time.Duration // Target time between the produced blocks
+	production        ProductionConfig
+	overrides         *productionOverrides
+	reload            *configReload
+	catchUp           CatchUpConfig
+	progress          *syncProgress
+	disputes          *disputeGuard
+	disputeWatcher    *disputeWatcher
+	disputeFeed       *disputeFeed
+	frauds            *fraudCatalog
+	producers         *productionTracker
+	unsettled         *unsettledQueue
+	replay            *replayCheckpoints
+	light             *lightSync         // The light sync of the watchtower; nil for the full one
+	pruner            *statePruner       // The pruner of the state storage; nil without the pruning
+	snapshots         *snapshotStore     // The chain snapshots kept; nil without the serving and the schedule
+	snapshotScheduler *snapshotScheduler // The snapshots on a schedule; nil without the schedule
+	snapshotPeer      *snapshotPeer      // The peer to bootstrap from; nil without one
+	snapshotsDir      string
+	serveSnapshots    bool
+	serveState        bool              // Serves the raw state to the light sync watchtowers
+	trusted           TrustedSyncConfig // The trusted-root fast sync; disabled for the zero height
+	maxStartupRewind  uint64            // The deepest rewind of the startup consistency check
+	settlement        *settlementLag
+	breaker           *circuitBreaker
+	keys              *keyRotation
+	readiness         *readiness
+	forkChoice        *forkChoice
+	phases            *phaseMachine
+	integrityPath     string     // The checkpoint of the integrity check; see VerifyIntegrity
+	integrityLock     sync.Mutex // Held by the integrity check running
+	health            HealthConfig
+	healthClock       clock // The system clock if nil
+
+	availAccount   avail.SignatureProvider
+	availClient    avail.Client
+	availSender    avail.Sender
+	settlements    *avail.SettlementIndex // The Avail inclusions of the blocks submitted; nil if not kept
+	stakingNode    staking.Node
+	balanceMonitor *avail.BalanceMonitor
+
+	validator            validator.Validator
+	currentNodeSyncIndex uint64
+	fraudListenerAddr    string
+	fraudTip             uint64
+	fraudQuorum          uint64
+	byzantine            *byzantineSequencer
 }
 
 // New creates and initializes a new instance of the Avail consensus protocol with the provided configuration.
@@ -146,29 +399,83 @@ func New(config Config) (consensus.Consensus, error) {
 
 	asq := staking.NewActiveParticipantsQuerier(config.Blockchain, config.Executor, logger)
 
+	ctx := config.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	blockTime, err := resolveBlockTime(config.BlockTime, config.MinSubmissionInterval, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+
 	d := &Avail{
-		logger:                     logger,
-		notifyCh:                   make(chan struct{}),
-		chain:                      config.Chain,
-		closeCh:                    make(chan struct{}),
-		blockchain:                 config.Blockchain,
-		executor:                   config.Executor,
-		snapshotter:                config.Snapshotter,
-		verifier:                   staking.NewVerifier(asq, logger.Named("verifier")),
-		txpool:                     config.TxPool,
-		secretsManager:             config.SecretsManager,
-		network:                    config.Network,
-		blockTime:                  time.Duration(config.BlockTime) * time.Second,
-		nodeType:                   MechanismType(config.NodeType),
-		signKey:                    signKey,
-		minerAddr:                  minerAddr,
-		validator:                  validator.New(config.Blockchain, minerAddr, logger),
-		blockProductionIntervalSec: DefaultBlockProductionIntervalS,
-		availAccount:               config.AvailAccount,
-		availClient:                config.AvailClient,
-		availSender:                config.AvailSender,
-		availAppID:                 config.AvailAppID,
-		fraudListenerAddr:          config.FraudListenerAddr,
+		ctx:               ctx,
+		cancel:            cancel,
+		logger:            logger,
+		notifyCh:          make(chan struct{}),
+		chain:             config.Chain,
+		closeCh:           make(chan struct{}),
+		blockchain:        config.Blockchain,
+		executor:          config.Executor,
+		snapshotter:       config.Snapshotter,
+		stateStorage:      config.StateStorage,
+		verifier:          staking.NewVerifier(asq, logger.Named("verifier")),
+		txpool:            config.TxPool,
+		secretsManager:    config.SecretsManager,
+		network:           config.Network,
+		blockTime:         blockTime,
+		nodeType:          MechanismType(config.NodeType),
+		signKey:           signKey,
+		minerAddr:         minerAddr,
+		validator:         validator.New(config.Blockchain, minerAddr, logger),
+		availAccount:      config.AvailAccount,
+		availClient:       config.AvailClient,
+		availSender:       config.AvailSender,
+		settlements:       config.Settlements,
+		health:            config.Health,
+		availAppID:        config.AvailAppID,
+		fraudListenerAddr: config.FraudListenerAddr,
+		fraudTip:          config.AvailFraudTip,
+	}
+
+	if d.fraudTip == 0 {
+		d.fraudTip = DefaultFraudTip
+	}
+
+	// The index covers the transactions from the start on, the ones loaded from
+	// the journal before the consensus starts included.
+	if d.txpool != nil {
+		d.poolIndex = newTxPoolIndex(ctx, d.txpool)
+	}
+
+	if config.ByzantinePolicy != nil {
+		if d.byzantine, err = newByzantineSequencer(config.ByzantinePolicy, logger); err != nil {
+			return nil, err
+		}
+
+		logger.Warn("the sequencer is byzantine; it produces the invalid blocks of its policy")
+	}
+
+	shutdownTimeout := DefaultShutdownTimeout
+
+	shutdownTimeoutRaw, ok := config.Config.Config["shutdownTimeout"]
+	if ok {
+		if shutdownTimeout, ok = configDuration(shutdownTimeoutRaw); !ok {
+			return nil, fmt.Errorf("shutdownTimeout expected duration")
+		}
+	}
+
+	d.shutdown = newGracefulShutdown(d.closeCh, cancel, shutdownTimeout)
+
+	// A genesis generated by BuildGenesis carries its genesis config; the
+	// local genesis edited after the fact doesn't match it anymore.
+	if genesisRaw, ok := config.Config.Config["genesis"]; ok && config.Chain != nil {
+		if err := verifyGenesis(genesisRaw, config.Chain); err != nil {
+			return nil, err
+		}
 	}
 
 	if config.Network != nil {
@@ -192,6 +499,28 @@ func New(config Config) (consensus.Consensus, error) {
 		d.nodeType = BootstrapSequencer
 	}
 
+	if config.LightSync.Enabled() {
+		if d.nodeType != WatchTower {
+			return nil, fmt.Errorf("invalid avail node type provided: light sync is only supported by the %s type, not %s", WatchTower, d.nodeType)
+		}
+
+		if config.LightSync.SamplePercent > 100 {
+			return nil, fmt.Errorf("light sync sample of %d%% exceeds 100%%", config.LightSync.SamplePercent)
+		}
+
+		d.light = newLightSync(config.LightSync.SamplePercent)
+		d.blockchain.SetHeaderOnly(true)
+
+		logger.Info("light sync enabled: blocks written header-only, block production disabled", "state_provider", config.LightSync.StateProvider, "sample_percent", config.LightSync.SamplePercent)
+	}
+
+	bootstrapAccountsRaw, ok := config.Config.Config["bootstrapAccounts"]
+	if ok {
+		if d.bootstrapAccounts, ok = configAddresses(bootstrapAccountsRaw); !ok {
+			return nil, fmt.Errorf("bootstrapAccounts expected list of addresses")
+		}
+	}
+
 	rawInterval, ok := config.Config.Config["interval"]
 	if ok {
 		interval, ok := rawInterval.(uint64)
@@ -202,17 +531,560 @@ func New(config Config) (consensus.Consensus, error) {
 		d.interval = interval
 	}
 
-	blockProductionIntervalSecRaw, ok := config.Config.Config["blockProductionIntervalSec"]
+	if _, ok := config.Config.Config["blockProductionIntervalSec"]; ok {
+		d.logger.Warn("blockProductionIntervalSec is superseded by blockTime; ignoring it", "block_time", d.blockTime)
+	}
+
+	d.production = DefaultProductionConfig()
+	d.catchUp = DefaultCatchUpConfig()
+	d.progress = newSyncProgress(systemClock{})
+	if config.SyncProgress != nil {
+		d.progress.subscribeChan(config.SyncProgress)
+	}
+	d.disputes = newDisputeGuard(minerAddr, asq, logger.Named("disputes"))
+	d.readiness = newReadiness()
+	d.phases = newPhaseMachine(systemClock{}, logger.Named("phases"))
+
+	if d.unsettled, err = loadUnsettledQueue(config.Config.Path); err != nil {
+		return nil, err
+	}
+
+	replayCheckpointInterval := uint64(DefaultReplayCheckpointInterval)
+
+	replayCheckpointIntervalRaw, ok := config.Config.Config["replayCheckpointInterval"]
+	if ok {
+		if replayCheckpointInterval, ok = configUint64(replayCheckpointIntervalRaw); !ok {
+			return nil, fmt.Errorf("replayCheckpointInterval expected int")
+		}
+	}
+
+	d.replay = newReplayCheckpoints(config.Config.Path, replayCheckpointInterval, logger.Named("replay"))
+
+	if config.Config.Path != "" {
+		d.integrityPath = filepath.Join(config.Config.Path, IntegrityCheckpointFileName)
+	}
+
+	produceEmptyBlocksRaw, ok := config.Config.Config["produceEmptyBlocks"]
+	if ok {
+		produceEmptyBlocks, ok := produceEmptyBlocksRaw.(bool)
+		if !ok {
+			return nil, fmt.Errorf("produceEmptyBlocks expected bool")
+		}
+
+		d.production.ProduceEmptyBlocks = produceEmptyBlocks
+	}
+
+	maxIdleIntervalRaw, ok := config.Config.Config["maxIdleInterval"]
+	if ok {
+		maxIdleInterval, ok := configDuration(maxIdleIntervalRaw)
+		if !ok {
+			return nil, fmt.Errorf("maxIdleInterval expected duration")
+		}
+
+		d.production.MaxIdleInterval = maxIdleInterval
+	}
+
+	blockGasTargetRaw, ok := config.Config.Config["blockGasTarget"]
+	if ok {
+		blockGasTarget, ok := configUint64(blockGasTargetRaw)
+		if !ok {
+			return nil, fmt.Errorf("blockGasTarget expected int")
+		}
+
+		d.production.BlockGasTarget = blockGasTarget
+	}
+
+	maxTxsPerBlockRaw, ok := config.Config.Config["maxTxsPerBlock"]
+	if ok {
+		maxTxsPerBlock, ok := configUint64(maxTxsPerBlockRaw)
+		if !ok {
+			return nil, fmt.Errorf("maxTxsPerBlock expected int")
+		}
+
+		d.production.MaxTxsPerBlock = maxTxsPerBlock
+	}
+
+	maxTxsPerSenderRaw, ok := config.Config.Config["maxTxsPerSender"]
+	if ok {
+		maxTxsPerSender, ok := configUint64(maxTxsPerSenderRaw)
+		if !ok {
+			return nil, fmt.Errorf("maxTxsPerSender expected int")
+		}
+
+		d.production.MaxTxsPerSender = maxTxsPerSender
+	}
+
+	maxBlockSizeBytesRaw, ok := config.Config.Config["maxBlockSizeBytes"]
+	if ok {
+		maxBlockSizeBytes, ok := configUint64(maxBlockSizeBytesRaw)
+		if !ok {
+			return nil, fmt.Errorf("maxBlockSizeBytes expected int")
+		}
+
+		if maxBlockSizeBytes > avail.MaxBlockSize {
+			return nil, fmt.Errorf("max block size of %d bytes exceeds the %d bytes that settle on Avail", maxBlockSizeBytes, avail.MaxBlockSize)
+		}
+
+		d.production.MaxBlockSizeBytes = maxBlockSizeBytes
+	}
+
+	maxTimestampDriftRaw, ok := config.Config.Config["maxTimestampDrift"]
+	if ok {
+		maxTimestampDrift, ok := configDuration(maxTimestampDriftRaw)
+		if !ok {
+			return nil, fmt.Errorf("maxTimestampDrift expected duration")
+		}
+
+		d.production.MaxTimestampDrift = maxTimestampDrift
+	}
+
+	leaderTimeoutBlocksRaw, ok := config.Config.Config["leaderTimeoutBlocks"]
+	if ok {
+		leaderTimeoutBlocks, ok := configUint64(leaderTimeoutBlocksRaw)
+		if !ok {
+			return nil, fmt.Errorf("leaderTimeoutBlocks expected int")
+		}
+
+		d.production.LeaderTimeoutBlocks = leaderTimeoutBlocks
+	}
+
+	autoStakeRaw, ok := config.Config.Config["autoStake"]
+	if ok {
+		autoStake, ok := autoStakeRaw.(bool)
+		if !ok {
+			return nil, fmt.Errorf("autoStake expected bool")
+		}
+
+		d.production.AutoStake = autoStake
+	}
+
+	slotStallTimeoutRaw, ok := config.Config.Config["slotStallTimeout"]
+	if ok {
+		slotStallTimeout, ok := configDuration(slotStallTimeoutRaw)
+		if !ok {
+			return nil, fmt.Errorf("slotStallTimeout expected duration")
+		}
+
+		d.production.SlotStallTimeout = slotStallTimeout
+	}
+
+	txOrderingRaw, ok := config.Config.Config["txOrdering"]
+	if ok {
+		txOrdering, ok := txOrderingRaw.(string)
+		if !ok {
+			return nil, fmt.Errorf("txOrdering expected string")
+		}
+
+		if d.production.TxOrdering, err = ParseTxOrdering(txOrdering); err != nil {
+			return nil, err
+		}
+	}
+
+	priceLimitRaw, ok := config.Config.Config["priceLimit"]
+	if ok {
+		priceLimit, ok := configUint64(priceLimitRaw)
+		if !ok {
+			return nil, fmt.Errorf("priceLimit expected int")
+		}
+
+		d.production.PriceLimit = priceLimit
+	}
+
+	txPoolSweepBlocksRaw, ok := config.Config.Config["txPoolSweepBlocks"]
+	if ok {
+		txPoolSweepBlocks, ok := configUint64(txPoolSweepBlocksRaw)
+		if !ok {
+			return nil, fmt.Errorf("txPoolSweepBlocks expected int")
+		}
+
+		d.production.TxPoolSweepBlocks = txPoolSweepBlocks
+	}
+
+	failingSenderThresholdRaw, ok := config.Config.Config["failingSenderThreshold"]
+	if ok {
+		failingSenderThreshold, ok := configUint64(failingSenderThresholdRaw)
+		if !ok {
+			return nil, fmt.Errorf("failingSenderThreshold expected int")
+		}
+
+		d.production.FailingSenderThreshold = failingSenderThreshold
+	}
+
+	failingSenderBanBlocksRaw, ok := config.Config.Config["failingSenderBanBlocks"]
+	if ok {
+		failingSenderBanBlocks, ok := configUint64(failingSenderBanBlocksRaw)
+		if !ok {
+			return nil, fmt.Errorf("failingSenderBanBlocks expected int")
+		}
+
+		d.production.FailingSenderBanBlocks = failingSenderBanBlocks
+	}
+
+	maxSettlementLagRaw, ok := config.Config.Config["maxSettlementLag"]
+	if ok {
+		maxSettlementLag, ok := configUint64(maxSettlementLagRaw)
+		if !ok {
+			return nil, fmt.Errorf("maxSettlementLag expected int")
+		}
+
+		d.production.MaxSettlementLag = maxSettlementLag
+	}
+
+	d.settlement = newSettlementLag(d.production.MaxSettlementLag, logger.Named("settlement"))
+
+	breakerConfig := DefaultCircuitBreakerConfig()
+
+	maxUnresolvedDisputesRaw, ok := config.Config.Config["circuitBreakerMaxUnresolvedDisputes"]
+	if ok {
+		maxUnresolvedDisputes, ok := configUint64(maxUnresolvedDisputesRaw)
+		if !ok {
+			return nil, fmt.Errorf("circuitBreakerMaxUnresolvedDisputes expected int")
+		}
+
+		breakerConfig.MaxUnresolvedDisputes = maxUnresolvedDisputes
+	}
+
+	maxTaintedBlocksRaw, ok := config.Config.Config["circuitBreakerMaxTaintedBlocks"]
+	if ok {
+		maxTaintedBlocks, ok := configUint64(maxTaintedBlocksRaw)
+		if !ok {
+			return nil, fmt.Errorf("circuitBreakerMaxTaintedBlocks expected int")
+		}
+
+		breakerConfig.MaxTaintedBlocks = maxTaintedBlocks
+	}
+
+	breakerWindowRaw, ok := config.Config.Config["circuitBreakerWindow"]
+	if ok {
+		breakerWindow, ok := configUint64(breakerWindowRaw)
+		if !ok {
+			return nil, fmt.Errorf("circuitBreakerWindow expected int")
+		}
+
+		breakerConfig.Window = breakerWindow
+	}
+
+	if d.breaker, err = loadCircuitBreaker(breakerConfig, config.Config.Path, systemClock{}, logger.Named("circuit_breaker")); err != nil {
+		return nil, err
+	}
+
+	if config.ResumeCircuitBreaker {
+		if _, err := d.breaker.resume(); err != nil {
+			return nil, fmt.Errorf("failed to resume the circuit breaker: %w", err)
+		}
+	}
+
+	keyRotationHeightRaw, ok := config.Config.Config["keyRotationHeight"]
+	if ok {
+		keyRotationHeight, ok := configUint64(keyRotationHeightRaw)
+		if !ok {
+			return nil, fmt.Errorf("keyRotationHeight expected int")
+		}
+
+		d.production.KeyRotationHeight = keyRotationHeight
+	}
+
+	var nextSignKey *ecdsa.PrivateKey
+	if d.production.KeyRotationHeight > 0 {
+		bs, err := config.SecretsManager.GetSecret(NextValidatorKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the next sign key of the key rotation: %w", err)
+		}
+
+		if nextSignKey, err = crypto.BytesToECDSAPrivateKey(bs); err != nil {
+			return nil, fmt.Errorf("next sign key decoding failed: %w", err)
+		}
+	}
+
+	d.keys = newKeyRotation(signKey, nextSignKey, d.production.KeyRotationHeight, logger.Named("key_rotation"))
+
+	if !d.production.ProduceEmptyBlocks && d.production.MaxIdleInterval < d.blockTime {
+		return nil, fmt.Errorf("max idle interval %s is shorter than the block time of %s", d.production.MaxIdleInterval, d.blockTime)
+	}
+
+	catchUpThresholdRaw, ok := config.Config.Config["catchUpThreshold"]
+	if ok {
+		catchUpThreshold, ok := configUint64(catchUpThresholdRaw)
+		if !ok {
+			return nil, fmt.Errorf("catchUpThreshold expected int")
+		}
+
+		d.catchUp.Threshold = catchUpThreshold
+	}
+
+	catchUpPageSizeRaw, ok := config.Config.Config["catchUpPageSize"]
+	if ok {
+		catchUpPageSize, ok := configUint64(catchUpPageSizeRaw)
+		if !ok || catchUpPageSize == 0 {
+			return nil, fmt.Errorf("catchUpPageSize expected positive int")
+		}
+
+		d.catchUp.PageSize = catchUpPageSize
+	}
+
+	catchUpWorkersRaw, ok := config.Config.Config["catchUpWorkers"]
 	if ok {
-		blockProductionIntervalSec, ok := blockProductionIntervalSecRaw.(uint64)
+		catchUpWorkers, ok := configUint64(catchUpWorkersRaw)
+		if !ok || catchUpWorkers == 0 {
+			return nil, fmt.Errorf("catchUpWorkers expected positive int")
+		}
+
+		d.catchUp.Workers = catchUpWorkers
+	}
+
+	syncPipelineDepthRaw, ok := config.Config.Config["syncPipelineDepth"]
+	if ok {
+		syncPipelineDepth, ok := configUint64(syncPipelineDepthRaw)
 		if !ok {
-			return nil, fmt.Errorf("blockProductionIntervalSec expected int")
+			return nil, fmt.Errorf("syncPipelineDepth expected int")
+		}
+
+		d.catchUp.PipelineDepth = syncPipelineDepth
+	}
+
+	maxReorgDepth := uint64(DefaultMaxReorgDepth)
+
+	maxReorgDepthRaw, ok := config.Config.Config["maxReorgDepth"]
+	if ok {
+		if maxReorgDepth, ok = configUint64(maxReorgDepthRaw); !ok {
+			return nil, fmt.Errorf("maxReorgDepth expected int")
+		}
+	}
+
+	d.maxStartupRewind = DefaultMaxStartupRewindDepth
+
+	maxStartupRewindRaw, ok := config.Config.Config["maxStartupRewindDepth"]
+	if ok {
+		if d.maxStartupRewind, ok = configUint64(maxStartupRewindRaw); !ok {
+			return nil, fmt.Errorf("maxStartupRewindDepth expected int")
+		}
+	}
+
+	challengeWindow := NewChallengeWindow(DefaultChallengeWindow)
+
+	challengeWindowRaw, ok := config.Config.Config["challengeWindow"]
+	if ok {
+		if challengeWindow, err = parseChallengeWindow(challengeWindowRaw); err != nil {
+			return nil, err
+		}
+	}
+
+	// The lifecycles of the disputes are published to the subscribers of
+	// the status API.
+	d.disputeFeed = newDisputeFeed()
+
+	settled := newSettledHead(d.blockchain, challengeWindow, logger.Named("settled_head"))
+	d.forkChoice = newForkChoice(d.blockchain, d.minerAddr, maxReorgDepth, settled, d.disputeFeed, logger.Named("fork_choice"))
+
+	disputeChallengeWindow := uint64(DefaultDisputeChallengeWindow)
+
+	disputeChallengeWindowRaw, ok := config.Config.Config["disputeChallengeWindow"]
+	if ok {
+		if disputeChallengeWindow, ok = configUint64(disputeChallengeWindowRaw); !ok || disputeChallengeWindow == 0 {
+			return nil, fmt.Errorf("disputeChallengeWindow expected positive int")
+		}
+	}
+
+	d.fraudQuorum = DefaultFraudProofQuorum
+
+	fraudProofQuorumRaw, ok := config.Config.Config["fraudProofQuorum"]
+	if ok {
+		if d.fraudQuorum, ok = configUint64(fraudProofQuorumRaw); !ok || d.fraudQuorum == 0 {
+			return nil, fmt.Errorf("fraudProofQuorum expected positive int")
+		}
+	}
+
+	// The disputes are tracked from the blocks written to the chain on every
+	// node; the one leading the slot ends them.
+	d.disputeWatcher = newDisputeWatcher(d.blockchain, d.executor, disputeChallengeWindow, d.disputeFeed, logger.Named("dispute_watcher"))
+	d.blockchain.RegisterPostCommitHook(d.disputeWatcher.observe)
+
+	escalationMultiple := uint64(DefaultDisputeEscalationMultiple)
+
+	escalationMultipleRaw, ok := config.Config.Config["disputeEscalationMultiple"]
+	if ok {
+		if escalationMultiple, ok = configUint64(escalationMultipleRaw); !ok || escalationMultiple == 0 {
+			return nil, fmt.Errorf("disputeEscalationMultiple expected positive int")
+		}
+	}
+
+	var escalationWebhook string
+
+	escalationWebhookRaw, ok := config.Config.Config["disputeEscalationWebhook"]
+	if ok {
+		if escalationWebhook, ok = escalationWebhookRaw.(string); !ok {
+			return nil, fmt.Errorf("disputeEscalationWebhook expected string")
+		}
+	}
+
+	var escalationSubmit bool
+
+	escalationSubmitRaw, ok := config.Config.Config["disputeEscalationSubmit"]
+	if ok {
+		if escalationSubmit, ok = escalationSubmitRaw.(bool); !ok {
+			return nil, fmt.Errorf("disputeEscalationSubmit expected bool")
+		}
+	}
+
+	// The hot parameters of the config file take the place of the engine
+	// params, changed on the reload of the file.
+	escalationWebhook = d.initConfigReload(config.Operational, config.LoadOperationalConfig, escalationWebhook)
+
+	// The disputes the leaders fail to end escalate; any staked sequencer
+	// may end them then, if so configured.
+	d.disputeWatcher.setEscalation(escalationMultiple, escalationSubmit, newEscalationWebhook(escalationWebhook, logger.Named("escalation_webhook")))
+
+	// The fraud events are cataloged on every node, from the fraud proofs
+	// seen on Avail to the end of their disputes on the chain.
+	if d.frauds, err = loadFraudCatalog(config.Config.Path, logger.Named("fraud_catalog")); err != nil {
+		return nil, err
+	}
+
+	d.blockchain.RegisterPostCommitHook(d.frauds.observe)
+
+	// The last blocks of the miners are tracked for `avail_getParticipants`.
+	d.producers = newProductionTracker()
+	d.blockchain.RegisterPostCommitHook(d.producers.observe)
+
+	stateRetention := uint64(DefaultStateRetention)
+
+	stateRetentionRaw, ok := config.Config.Config["stateRetention"]
+	if ok {
+		if stateRetention, ok = configUint64(stateRetentionRaw); !ok {
+			return nil, fmt.Errorf("stateRetention expected int")
+		}
+	}
+
+	pruneConfig := pruning.PruneConfig{BatchSize: DefaultStatePruneBatchSize, BatchInterval: DefaultStatePruneBatchInterval}
+
+	statePruneBatchSizeRaw, ok := config.Config.Config["statePruneBatchSize"]
+	if ok {
+		statePruneBatchSize, ok := configUint64(statePruneBatchSizeRaw)
+		if !ok || statePruneBatchSize == 0 {
+			return nil, fmt.Errorf("statePruneBatchSize expected positive int")
+		}
+
+		pruneConfig.BatchSize = int(statePruneBatchSize)
+	}
+
+	statePruneBatchIntervalRaw, ok := config.Config.Config["statePruneBatchInterval"]
+	if ok {
+		if pruneConfig.BatchInterval, ok = configDuration(statePruneBatchIntervalRaw); !ok {
+			return nil, fmt.Errorf("statePruneBatchInterval expected duration")
+		}
+	}
+
+	// The state past the retention is pruned on the full nodes; a zero
+	// retention keeps it all. The settled head and the replay checkpoint
+	// keep theirs.
+	if config.PrunableStateStorage != nil && stateRetention > 0 && d.light == nil {
+		d.pruner = newStatePruner(config.PrunableStateStorage, d.blockchain, stateRetention, pruneConfig, d.protectedStateRoots, logger.Named("state_pruner"))
+		d.blockchain.RegisterPostCommitHook(func(blk *types.Block, _ []*types.Receipt) {
+			d.pruner.observe(d.ctx, blk)
+		})
+	}
+
+	// The trust the chain rests on is logged loudly, for it to be audited
+	// along with the status API.
+	if config.TrustedSync.Enabled() {
+		if config.TrustedSync.StateRoot == types.ZeroHash {
+			return nil, fmt.Errorf("trusted sync at height %d expects the trusted state root", config.TrustedSync.Height)
+		}
+
+		d.trusted = config.TrustedSync
+		d.blockchain.SetTrustedRoot(d.trusted.Height, d.trusted.StateRoot)
+
+		logger.Warn("TRUSTED FAST SYNC ENABLED: blocks at or below the trusted height are not re-executed, their state is trusted", "trusted_height", d.trusted.Height, "trusted_state_root", d.trusted.StateRoot, "trusted_snapshot", d.trusted.Snapshot)
+	}
+
+	d.snapshotsDir = filepath.Join(config.Config.Path, SnapshotsDirName)
+
+	snapshotChunkSize := uint64(snapshot.DefaultChunkSize)
+
+	snapshotChunkSizeRaw, ok := config.Config.Config["snapshotChunkSize"]
+	if ok {
+		if snapshotChunkSize, ok = configUint64(snapshotChunkSizeRaw); !ok || snapshotChunkSize == 0 {
+			return nil, fmt.Errorf("snapshotChunkSize expected positive int")
+		}
+	}
+
+	// The snapshots are taken at the settled blocks every snapshotInterval
+	// blocks; zero takes them on demand of the serving only.
+	var snapshotInterval uint64
+
+	snapshotIntervalRaw, ok := config.Config.Config["snapshotInterval"]
+	if ok {
+		if snapshotInterval, ok = configUint64(snapshotIntervalRaw); !ok {
+			return nil, fmt.Errorf("snapshotInterval expected int")
 		}
+	}
 
-		d.blockProductionIntervalSec = blockProductionIntervalSec
+	snapshotRetention := uint64(DefaultSnapshotRetention)
+
+	snapshotRetentionRaw, ok := config.Config.Config["snapshotRetention"]
+	if ok {
+		if snapshotRetention, ok = configUint64(snapshotRetentionRaw); !ok || snapshotRetention == 0 {
+			return nil, fmt.Errorf("snapshotRetention expected positive int")
+		}
 	}
 
-	d.stakingNode = staking.NewNode(d.blockchain, d.executor, d.availSender, d.logger, staking.NodeType(d.nodeType))
+	d.serveSnapshots = config.Snapshots.Serve
+	d.serveState = config.Snapshots.ServeState
+
+	if d.serveSnapshots || snapshotInterval > 0 {
+		if d.snapshots, err = newSnapshotStore(d.snapshotsDir, snapshotChunkSize, int(snapshotRetention), systemClock{}, logger.Named("snapshots")); err != nil {
+			return nil, fmt.Errorf("failed to set up the snapshots: %w", err)
+		}
+	}
+
+	if snapshotInterval > 0 {
+		d.snapshotScheduler = newSnapshotScheduler(snapshotInterval, d.takeChainSnapshot, logger.Named("snapshot_scheduler"))
+		d.forkChoice.settled.onSettle(d.snapshotScheduler.observe)
+	}
+
+	if config.Snapshots.Peer != "" {
+		if d.snapshotPeer, err = dialSnapshotPeer(config.Snapshots.Peer); err != nil {
+			return nil, err
+		}
+	}
+
+	balanceMonitorConfig := avail.DefaultBalanceMonitorConfig()
+
+	lowBalancePolicyRaw, ok := config.Config.Config["lowBalancePolicy"]
+	if ok {
+		lowBalancePolicy, ok := lowBalancePolicyRaw.(string)
+		if !ok {
+			return nil, fmt.Errorf("lowBalancePolicy expected string")
+		}
+
+		if balanceMonitorConfig.Policy, err = avail.ParseLowBalancePolicy(lowBalancePolicy); err != nil {
+			return nil, err
+		}
+	}
+
+	balancePollBlocksRaw, ok := config.Config.Config["balancePollBlocks"]
+	if ok {
+		balancePollBlocks, ok := configUint64(balancePollBlocksRaw)
+		if !ok {
+			return nil, fmt.Errorf("balancePollBlocks expected int")
+		}
+
+		balanceMonitorConfig.PollBlocks = balancePollBlocks
+	}
+
+	minSubmissionsLeftRaw, ok := config.Config.Config["minSubmissionsLeft"]
+	if ok {
+		minSubmissionsLeft, ok := configUint64(minSubmissionsLeftRaw)
+		if !ok {
+			return nil, fmt.Errorf("minSubmissionsLeft expected int")
+		}
+
+		balanceMonitorConfig.MinSubmissionsLeft = minSubmissionsLeft
+	}
+
+	d.balanceMonitor = avail.NewBalanceMonitor(avail.AccountBalanceFunc(d.availClient, d.availAccount), balanceMonitorConfig, d.logger)
+
+	d.stakingNode = staking.NewNode(d.blockchain, d.executor, stakingSender{d.availSender}, d.logger, staking.NodeType(d.nodeType))
 
 	return d, nil
 }
@@ -268,7 +1140,19 @@ func (d *Avail) Start() error {
 			time.Sleep(2 * time.Second)
 		}
 
-		// Sync the node from Avail.
+		// Sync the node from Avail, from the snapshot of the peer if any.
+		_ = d.phases.enter(PhaseSyncing)
+
+		d.bootstrapFromSnapshotPeer()
+
+		if err := d.applyTrustedState(); err != nil {
+			panic(fmt.Sprintf("failure to apply the trusted state: %s", err))
+		}
+
+		if _, err := d.checkChainConsistency(); err != nil {
+			panic(fmt.Sprintf("failure to check the local chain against Avail: %s", err))
+		}
+
 		var err error
 		d.currentNodeSyncIndex, err = d.syncNodeUntil(d.syncConditionFn)
 		if err != nil {
@@ -294,30 +1178,44 @@ func (d *Avail) Start() error {
 }
 
 // startBootstrapSequencer starts the process for a BootstrapSequencer node type.
-// It initializes a new Sequencer, syncs the node, and ensures the node is staked.
-// If the node successfully syncs and stakes, it starts running the Sequencer worker.
-// Note: The function panics if it fails to sync the node, ensure the node is staked, or run the Sequencer worker.
+// It takes the node through the bootstrap handoff first: only the candidate that
+// bootstraps the chain goes on as the bootstrap sequencer, while the others sync
+// the chain from Avail and run as regular sequencers.
+// Note: The function panics if it fails to bootstrap, sync the node or run the Sequencer worker.
 func (d *Avail) startBootstrapSequencer() {
-	activeParticipantsQuerier := staking.NewActiveParticipantsQuerier(d.blockchain, d.executor, d.logger)
+	state, err := d.bootstrap()
+	if err != nil {
+		panic(err)
+	}
 
-	sequencerWorker, _ := NewSequencer(
-		d.logger.Named(d.nodeType.LogString()), d.blockchain, d.executor, d.txpool,
-		d.snapshotter, d.snapshotDistributor,
-		d.availClient, d.availAccount, d.availAppID, d.signKey,
-		d.minerAddr, d.nodeType, activeParticipantsQuerier, d.stakingNode, d.availSender, d.closeCh,
-		d.blockTime, d.blockProductionIntervalSec, d.currentNodeSyncIndex,
-		d.fraudListenerAddr,
-	)
+	if state != BootstrapDone {
+		d.logger.Info("handing off to the bootstrapped chain; running as a sequencer", "bootstrap_state", state)
 
-	// Sync the node from Avail.
-	var err error
-	d.currentNodeSyncIndex, err = d.syncNode()
+		d.setNodeType(Sequencer)
+
+		_ = d.phases.enter(PhaseSyncing)
+		if d.currentNodeSyncIndex, err = d.syncNode(); err != nil {
+			panic(err)
+		}
+
+		d.startSequencer()
+
+		return
+	}
+
+	role := d.beginRole()
+	defer role.end()
+
+	sequencerWorker, err := d.newSequencer(role)
 	if err != nil {
 		panic(err)
 	}
 
-	d.logger.Info("About to process node staking...", "node_type", d.nodeType)
-	if err := d.ensureStaked(nil, activeParticipantsQuerier); err != nil {
+	// Sync the node from Avail.
+	_ = d.phases.enter(PhaseSyncing)
+
+	d.currentNodeSyncIndex, err = d.syncNode()
+	if err != nil {
 		panic(err)
 	}
 
@@ -327,22 +1225,15 @@ func (d *Avail) startBootstrapSequencer() {
 }
 
 // startSequencer starts the process for a Sequencer node type.
-// It initializes a new Sequencer, ensures the node is staked, and runs the Sequencer worker.
-// Note: The function panics if it fails to ensure the node is staked or run the Sequencer worker.
+// It initializes a new Sequencer and runs the Sequencer worker, which stakes the node if need be
+// and produces blocks only once the stake shows up in the staking contract.
+// Note: The function panics if it fails to run the Sequencer worker.
 func (d *Avail) startSequencer() {
-	activeParticipantsQuerier := staking.NewActiveParticipantsQuerier(d.blockchain, d.executor, d.logger)
+	role := d.beginRole()
+	defer role.end()
 
-	sequencerWorker, _ := NewSequencer(
-		d.logger.Named(d.nodeType.LogString()), d.blockchain, d.executor, d.txpool,
-		d.snapshotter, d.snapshotDistributor,
-		d.availClient, d.availAccount, d.availAppID, d.signKey,
-		d.minerAddr, d.nodeType, activeParticipantsQuerier, d.stakingNode, d.availSender, d.closeCh,
-		d.blockTime, d.blockProductionIntervalSec, d.currentNodeSyncIndex,
-		d.fraudListenerAddr,
-	)
-
-	d.logger.Info("About to process node staking...", "node_type", d.nodeType)
-	if err := d.ensureStaked(nil, activeParticipantsQuerier); err != nil {
+	sequencerWorker, err := d.newSequencer(role)
+	if err != nil {
 		panic(err)
 	}
 
@@ -355,6 +1246,9 @@ func (d *Avail) startSequencer() {
 // It ensures the node is staked and runs the WatchTower process.
 // Note: The function panics if it fails to ensure the node is staked or run the WatchTower process.
 func (d *Avail) startWatchTower() {
+	role := d.beginRole()
+	defer role.end()
+
 	activeParticipantsQuerier := staking.NewActiveParticipantsQuerier(d.blockchain, d.executor, d.logger)
 	key := &keystore.Key{PrivateKey: d.signKey}
 
@@ -363,8 +1257,10 @@ func (d *Avail) startWatchTower() {
 		panic(err)
 	}
 
+	_ = d.phases.enter(PhaseActive)
+
 	acc := accounts.Account{Address: common.Address(d.minerAddr)}
-	d.runWatchTower(activeParticipantsQuerier, d.currentNodeSyncIndex, acc, key)
+	d.runWatchTower(role, d.currentNodeSyncIndex, acc, key)
 }
 
 // ensureAccountBalance verifies the account balance of the miner.
@@ -433,6 +1329,148 @@ func (d *Avail) ensureAccountBalance() error {
 	return nil
 }
 
+// BlockTime returns the target time between the blocks produced by the sequencers.
+func (d *Avail) BlockTime() time.Duration {
+	return d.blockTime
+}
+
+// resolveBlockTime validates the configured block time, defaulting to
+// DefaultBlockTime. Block times shorter than the Avail submissions can be
+// paced at are raised to the submission interval.
+func resolveBlockTime(blockTime, minSubmissionInterval time.Duration, logger hclog.Logger) (time.Duration, error) {
+	if blockTime == 0 {
+		blockTime = DefaultBlockTime
+	}
+
+	if blockTime < MinBlockTime {
+		return 0, fmt.Errorf("block time %s is shorter than the minimum of %s", blockTime, MinBlockTime)
+	}
+
+	if blockTime < minSubmissionInterval {
+		logger.Warn("block time is shorter than the Avail submissions can be paced at; raising it", "block_time", blockTime, "submission_interval", minSubmissionInterval)
+		blockTime = minSubmissionInterval
+	}
+
+	return blockTime, nil
+}
+
+// ProductionConfig configures which slots the sequencers produce blocks in,
+// and how much goes into the blocks.
+type ProductionConfig struct {
+	// ProduceEmptyBlocks makes the sequencers produce a block in every slot,
+	// even when there are no transactions pending.
+	ProduceEmptyBlocks bool
+
+	// MaxIdleInterval is the longest time the chain goes without a block
+	// when the empty blocks aren't produced; a heartbeat block is produced
+	// once it elapses, so the liveness and staking activity checks keep
+	// working.
+	MaxIdleInterval time.Duration
+
+	// BlockGasTarget is the gas the sequencers aim to fill the blocks up to;
+	// the transactions that would take a block past it are left for the
+	// next one. The gas limit of the block is enforced regardless. Zero
+	// means no target.
+	BlockGasTarget uint64
+
+	// MaxTxsPerBlock is the most transactions in a block, on top of the
+	// dispute resolution ones; zero means no limit.
+	MaxTxsPerBlock uint64
+
+	// MaxTxsPerSender is the most transactions of a sender in a block, the
+	// lowest nonces of its highest-priced ones; the rest are left for the
+	// next block. The node's own system transactions don't count. Zero means
+	// no limit.
+	MaxTxsPerSender uint64
+
+	// MaxBlockSizeBytes is the size limit of the RLP-encoded blocks; the
+	// transactions that would take a block past it are left for the next
+	// one, and the watchtowers take the blocks over it for invalid, as they
+	// can't settle on Avail. Zero means no limit.
+	MaxBlockSizeBytes uint64
+
+	// MaxTimestampDrift is the time the timestamps of the blocks received
+	// from Avail may run ahead of the local clock; the blocks past it, or
+	// stamped before their parents, are rejected, and the watchtowers take
+	// them for invalid. Zero leaves the timestamps ahead unchecked.
+	MaxTimestampDrift time.Duration
+
+	// TxOrdering is the order the pending transactions are included in.
+	TxOrdering TxOrdering
+
+	// PriceLimit is the lowest effective gas price, in wei, of the user
+	// transactions admitted to the txpool over JSON-RPC and included in the
+	// blocks; the node's own staking and dispute resolution transactions
+	// bypass it.
+	// Zero means no limit.
+	PriceLimit uint64
+
+	// TxPoolSweepBlocks is the number of blocks applied to the chain in
+	// between the sweeps of the txpool, dropping the transactions that can
+	// never execute; zero disables the sweeps.
+	TxPoolSweepBlocks uint64
+
+	// FailingSenderThreshold is the number of transactions of a sender
+	// failing in a row, reverted or erroring out in the block building,
+	// past which the sender is banned from the blocks; the node's own
+	// system transactions are exempt. Zero disables the bans.
+	FailingSenderThreshold uint64
+
+	// FailingSenderBanBlocks is the number of blocks the first ban of a
+	// sender lasts; each ban in a row lasts twice the previous one, up to
+	// 64 times the first.
+	FailingSenderBanBlocks uint64
+
+	// MaxSettlementLag is the number of blocks the head of the chain may run
+	// ahead of the blocks seen settled on Avail; the block production pauses
+	// once it's reached, until the settlement catches up. Zero means no
+	// limit.
+	MaxSettlementLag uint64
+
+	// LeaderTimeoutBlocks is the number of Avail blocks without a new block
+	// after which the next sequencer takes over the slot from its leader;
+	// zero disables the failover.
+	LeaderTimeoutBlocks uint64
+
+	// SlotStallTimeout is the time without a new Avail block after which
+	// the production slots, otherwise made by the Avail blocks, fall back to
+	// the wall clock, every block time, until the Avail blocks come in again.
+	SlotStallTimeout time.Duration
+
+	// AutoStake makes the sequencer not staked yet stake on the start; it
+	// produces blocks only once the stake shows up in the staking contract.
+	AutoStake bool
+
+	// KeyRotationHeight is the first block signed by the next key of the
+	// sequencer, read from the secrets manager; the next key is staked ahead
+	// of it and the current one unstaked past it. Zero disables the key
+	// rotation.
+	KeyRotationHeight uint64
+}
+
+// DefaultProductionConfig returns the ProductionConfig producing a block in every slot.
+func DefaultProductionConfig() ProductionConfig {
+	return ProductionConfig{
+		ProduceEmptyBlocks:     true,
+		MaxIdleInterval:        DefaultMaxIdleInterval,
+		TxOrdering:             TxOrderingPrice,
+		LeaderTimeoutBlocks:    DefaultLeaderTimeoutBlocks,
+		SlotStallTimeout:       DefaultSlotStallTimeout,
+		TxPoolSweepBlocks:      DefaultTxPoolSweepBlocks,
+		MaxBlockSizeBytes:      DefaultMaxBlockSizeBytes,
+		MaxTimestampDrift:      DefaultMaxTimestampDrift,
+		MaxSettlementLag:       DefaultMaxSettlementLag,
+		FailingSenderThreshold: DefaultFailingSenderThreshold,
+		FailingSenderBanBlocks: DefaultFailingSenderBanBlocks,
+		AutoStake:              true,
+	}
+}
+
+// BalanceMonitor returns the monitor of the Avail submission account balance.
+func (d *Avail) BalanceMonitor() *avail.BalanceMonitor {
+	return d.balanceMonitor
+}
+
 // GetAccountBalance retrieves the balance of an account.
 // It fetches the latest header from Avail and returns the balance associated with the specified address.
 // If the balance is not found or any error occurs, an error is returned.
@@ -455,7 +1493,7 @@ func (d *Avail) GetAccountBalance(addr types.Address) (*big.Int, error) {
 // and if the syncer has reached the Avail HEAD.
 // The function returns true if the conditions are met; otherwise, it returns false.
 func (d *Avail) syncConditionFn(blk *avail_types.SignedBlock) bool {
-	hdr, err := d.availClient.GetLatestHeader()
+	hdr, err := d.availClient.GetLatestHeader(d.ctx)
 	if err != nil {
 		d.logger.Error("couldn't fetch latest block hash from Avail", "error", err)
 		return false
@@ -491,6 +1529,69 @@ func (d *Avail) syncConditionFn(blk *avail_types.SignedBlock) bool {
 	return false
 }
 
+// configUint64 converts a numeric engine configuration value to uint64.
+// Values decoded from the JSON genesis are float64, while the ones set in
+// code are usually uint64.
+func configUint64(raw interface{}) (uint64, bool) {
+	switch v := raw.(type) {
+	case uint64:
+		return v, true
+	case int:
+		return uint64(v), v >= 0
+	case float64:
+		return uint64(v), v >= 0 && v == float64(uint64(v))
+	default:
+		return 0, false
+	}
+}
+
+// configDuration converts a duration engine configuration value, such as
+// "30s", to time.Duration.
+func configDuration(raw interface{}) (time.Duration, bool) {
+	switch v := raw.(type) {
+	case time.Duration:
+		return v, v > 0
+	case string:
+		d, err := time.ParseDuration(v)
+		return d, err == nil && d > 0
+	default:
+		return 0, false
+	}
+}
+
+// configAddresses converts a list of addresses engine configuration value to
+// []types.Address.
+func configAddresses(raw interface{}) ([]types.Address, bool) {
+	var strs []string
+
+	switch v := raw.(type) {
+	case []string:
+		strs = v
+	case []interface{}:
+		for _, elem := range v {
+			str, ok := elem.(string)
+			if !ok {
+				return nil, false
+			}
+
+			strs = append(strs, str)
+		}
+	default:
+		return nil, false
+	}
+
+	addrs := make([]types.Address, 0, len(strs))
+	for _, str := range strs {
+		if types.IsValidAddress(str) != nil {
+			return nil, false
+		}
+
+		addrs = append(addrs, types.StringToAddress(str))
+	}
+
+	return addrs, true
+}
+
 // REQUIRED BASE INTERFACE METHODS //
 
 // VerifyHeader verifies the validity of a block header.
@@ -521,10 +1622,11 @@ func (d *Avail) PreCommitState(header *types.Header, tx *state.Transition) error
 	return d.verifier.PreCommitState(header, tx)
 }
 
-// GetSyncProgression returns the progression of the node's sync process.
-// As of now, the function returns nil as the sync progression is not implemented.
+// GetSyncProgression returns the progression of the node's sync process for
+// `eth_syncing`: the local chain syncing from Avail, in the replay on the
+// start or the catch-up; nil while the node follows the live Avail blocks.
 func (d *Avail) GetSyncProgression() *progress.Progression {
-	return nil
+	return d.progress.Progression()
 }
 
 // GetBridgeProvider returns an instance of BridgeDataProvider.
@@ -553,8 +1655,40 @@ func (d *Avail) FilterExtra(extra []byte) ([]byte, error) {
 }
 
 // Close closes the Avail consensus.
-// It closes the internal close channel and returns nil.
+// It closes the internal close channel, so no new blocks are started, waits for the block in flight,
+// if any, to be included in Avail and written to the local chain, up to the shutdown timeout,
+// and then cancels the run context, halts the consensus phase and returns nil.
 func (d *Avail) Close() error {
-	close(d.closeCh)
+	d.switchLock.Lock()
+	d.roleLock.Lock()
+	role := d.role
+	d.roleLock.Unlock()
+
+	if role != nil {
+		role.shutdown.run(d.logger)
+
+		// The consensus halts once the loops of the role are out.
+		select {
+		case <-role.done:
+		case <-time.After(d.shutdown.timeout):
+			d.logger.Warn("the node role did not stop in time", "timeout", d.shutdown.timeout)
+		}
+	}
+
+	d.switchLock.Unlock()
+
+	d.shutdown.run(d.logger)
+
+	// The prune in flight stops with the run context, short of the state
+	// storage closed under it.
+	d.pruner.wait()
+	d.snapshotScheduler.wait()
+
+	if d.snapshotPeer != nil {
+		d.snapshotPeer.close()
+	}
+
+	_ = d.phases.enter(PhaseHalted)
+
 	return nil
 }
@@ -0,0 +1,124 @@
+package avail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/consensus/avail/watchtower"
+)
+
+// BlockCheckOptions are the options of the check of a block on demand, as
+// taken by `availAdmin_checkBlock`.
+type BlockCheckOptions struct {
+	// SubmitFraudProof submits the fraud proof of the block failing the
+	// check, provided the node runs as a watchtower and the block is within
+	// its challenge window.
+	SubmitFraudProof bool `json:"submitFraudproof"`
+}
+
+// BlockCheck is the outcome of the check of a block on demand, as returned by
+// `availAdmin_checkBlock`.
+type BlockCheck struct {
+	Number    uint64     `json:"number"`
+	Hash      types.Hash `json:"hash"`
+	Canonical bool       `json:"canonical"`
+
+	// Valid is set for the block passing the check; otherwise Error is the
+	// reason it failed, and Disputable whether the failure calls for a fraud
+	// proof.
+	Valid      bool   `json:"valid"`
+	Error      string `json:"error,omitempty"`
+	Disputable bool   `json:"disputable"`
+
+	// ChallengeWindowClosed is set for the block past its challenge window at
+	// the Avail head at AvailHeight, whose fraud proof is refused.
+	AvailHeight           uint64 `json:"availHeight"`
+	ChallengeWindowClosed bool   `json:"challengeWindowClosed"`
+
+	// FraudProof is the hash of the fraud proof submitted for the block
+	// failing the check, if any; otherwise SubmissionRefused is why the fraud
+	// proof asked for wasn't submitted.
+	FraudProof        *types.Hash `json:"fraudProof,omitempty"`
+	SubmissionRefused string      `json:"submissionRefused,omitempty"`
+}
+
+// checkBlock checks the block of the given header in full, re-executing it;
+// under the light sync, on the state fetched from the state provider. Asked
+// to, the node running as a watchtower submits the fraud proof of the block
+// failing the check within its challenge window.
+func (d *Avail) checkBlock(ctx context.Context, hdr *types.Header, opts BlockCheckOptions) (*BlockCheck, error) {
+	if hdr.Number == 0 {
+		return nil, fmt.Errorf("genesis block not checked")
+	}
+
+	blk, ok := d.blockchain.GetBlockByHash(hdr.Hash, true)
+	if !ok {
+		return nil, fmt.Errorf("block %s not found", hdr.Hash)
+	}
+
+	check := &BlockCheck{Number: blk.Number(), Hash: blk.Hash(), Valid: true}
+
+	if canonical, ok := d.blockchain.GetHeaderByNumber(blk.Number()); ok {
+		check.Canonical = canonical.Hash == blk.Hash()
+	}
+
+	availHead, err := d.availClient.GetLatestHeader(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the Avail head: %w", err)
+	}
+
+	check.AvailHeight = uint64(availHead.Number)
+
+	var settled *settledHead
+	if d.forkChoice != nil {
+		settled = d.forkChoice.settled
+	}
+
+	var windowErr *ChallengeWindowError
+	if err := settled.challengeable(blk.Header, check.AvailHeight); errors.As(err, &windowErr) {
+		check.ChallengeWindowClosed = true
+	}
+
+	logger := d.logger.Named("watchtower")
+	wt := watchtower.New(d.blockchain, d.executor, d.txpool, logger, d.minerAddr, d.signKey, d.production.MaxBlockSizeBytes, watchtower.WithMaxTimestampDrift(d.production.MaxTimestampDrift))
+
+	observeLightSyncCheck("full")
+
+	err = wt.Check(blk)
+	if err == nil {
+		return check, nil
+	}
+
+	check.Valid, check.Error, check.Disputable = false, err.Error(), disputable(blk, err)
+
+	if !opts.SubmitFraudProof || !check.Disputable {
+		return check, nil
+	}
+
+	d.roleLock.Lock()
+	role, nodeType := d.role, d.nodeType
+	d.roleLock.Unlock()
+
+	switch {
+	case nodeType != WatchTower || role == nil:
+		check.SubmissionRefused = "node not running as a watchtower"
+		return check, nil
+	case check.ChallengeWindowClosed:
+		check.SubmissionRefused = "block past its challenge window"
+		return check, nil
+	}
+
+	logger.Info("Block verification failed on demand. constructing fraudproof", "block_number", blk.Header.Number, "block_hash", blk.Header.Hash, "error", err)
+
+	fp, err := d.submitFraudProof(role.ctx, wt, blk, logger)
+	if err != nil {
+		return check, err
+	}
+
+	hash := fp.Hash()
+	check.FraudProof = &hash
+
+	return check, nil
+}
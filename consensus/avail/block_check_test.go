@@ -0,0 +1,124 @@
+package avail
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/pkg/avail/testutil"
+	"github.com/availproject/op-evm/pkg/block"
+	"github.com/availproject/op-evm/pkg/test"
+	avail_types "github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdminCheckBlock(t *testing.T) {
+	appID := avail_types.NewUCompactFromUInt(1)
+	fake := testutil.NewFake(appID)
+
+	producer := newTestGenesisAvail(t)
+	activity := newTestActivity(producer, fake)
+	activity.settle(t, 3)
+
+	// The producer settles a block with a transfer of a funded account signed
+	// by another.
+	other, otherKey := test.NewAccount(t)
+	forged := (&testSender{addr: activity.funded[0], key: otherKey}).sign(t, &types.Transaction{To: &other, Value: big.NewInt(1), Gas: 21_000}, 5000)
+
+	fraudulent := buildTestBlock(t, producer, forged)
+
+	if _, err := fake.SendAndWaitForStatus(producer.ctx, fraudulent, avail_types.ExtrinsicStatus{IsInBlock: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The watchtower follows it, funded by the genesis for its fraud proofs.
+	d, _ := newTestLightAvail(t, fake, appID, producer, 0)
+	d.minerAddr, d.signKey = test.FaucetAccount, test.FaucetSignKey
+	d.availSender = fake
+
+	if _, err := syncTestAvail(d, fake); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := rpc.NewServer()
+	if err := srv.RegisterName(AdminNamespace, NewAdminAPI(d)); err != nil {
+		t.Fatal(err)
+	}
+
+	c := rpc.DialInProc(srv)
+	defer c.Close()
+
+	checkBlock := func(blockNrOrHash interface{}, submit bool) *BlockCheck {
+		t.Helper()
+
+		var check BlockCheck
+		if err := c.Call(&check, AdminNamespace+"_checkBlock", blockNrOrHash, BlockCheckOptions{SubmitFraudProof: submit}); err != nil {
+			t.Fatal(err)
+		}
+
+		return &check
+	}
+
+	// The valid block checks out, by number.
+	check := checkBlock(fmt.Sprintf("0x%x", fraudulent.Number()-1), true)
+	assert.True(t, check.Valid, check.Error)
+	assert.True(t, check.Canonical)
+	assert.Equal(t, fraudulent.ParentHash(), check.Hash)
+	assert.Nil(t, check.FraudProof)
+	assert.Empty(t, check.SubmissionRefused)
+
+	// The fabricated one doesn't, by hash, nor as the head.
+	check = checkBlock(fraudulent.Hash(), false)
+	assert.False(t, check.Valid)
+	assert.NotEmpty(t, check.Error)
+	assert.True(t, check.Disputable)
+	assert.False(t, check.ChallengeWindowClosed)
+	assert.Nil(t, check.FraudProof)
+	assert.Empty(t, check.SubmissionRefused)
+
+	assert.False(t, checkBlock("latest", false).Valid)
+
+	// The fraud proof is submitted by the node running as a watchtower only.
+	check = checkBlock(fraudulent.Hash(), true)
+	assert.Nil(t, check.FraudProof)
+	assert.Equal(t, "node not running as a watchtower", check.SubmissionRefused)
+
+	d.role = &roleRun{ctx: context.Background()}
+
+	check = checkBlock(fraudulent.Hash(), true)
+	assert.Empty(t, check.SubmissionRefused)
+
+	if assert.NotNil(t, check.FraudProof) {
+		blks, err := fake.EdgeBlocks()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		fp := blks[len(blks)-1]
+		assert.Equal(t, *check.FraudProof, fp.Hash())
+
+		target, ok := block.GetExtraDataFraudProofTarget(fp.Header)
+		assert.True(t, ok)
+		assert.Equal(t, fraudulent.Hash(), target)
+	}
+
+	// Past the challenge window, the verdict holds, while the fraud proof is
+	// refused.
+	for i := 0; i < DefaultChallengeWindow; i++ {
+		fake.Produce()
+	}
+
+	check = checkBlock(fraudulent.Hash(), true)
+	assert.False(t, check.Valid)
+	assert.True(t, check.ChallengeWindowClosed)
+	assert.Equal(t, uint64(fake.Head()), check.AvailHeight)
+	assert.Nil(t, check.FraudProof)
+	assert.Equal(t, "block past its challenge window", check.SubmissionRefused)
+
+	// The unknown block is an error.
+	var unknown BlockCheck
+	assert.Error(t, c.Call(&unknown, AdminNamespace+"_checkBlock", types.StringToHash("0x1234")))
+}
@@ -0,0 +1,145 @@
+package avail
+
+import (
+	"context"
+
+	"github.com/availproject/op-evm/pkg/avail"
+	"github.com/availproject/op-evm/pkg/block"
+	"github.com/availproject/op-evm/pkg/blockchain"
+	avail_types "github.com/centrifuge/go-substrate-rpc-client/v4/types"
+)
+
+// preparedBlock is an Avail block prepared ahead of the write of its Edge
+// blocks: decoded, with the signers of the Edge blocks it carries whole
+// recovered, or the error decoding it.
+type preparedBlock struct {
+	*avail_types.SignedBlock
+	extracted *avail.ExtractedBlock
+	err       error
+}
+
+// edgeBlocks returns the Edge blocks of the prepared Avail block, assembled
+// by the decoder; it's called in the order of the Avail blocks.
+func (p *preparedBlock) edgeBlocks(decoder *avail.BlockDecoder) ([]avail.EdgeBlock, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	return decoder.Assemble(p.extracted)
+}
+
+// prepareAvailBlock decodes the Avail block and recovers the signers of the
+// Edge blocks it carries whole: the signer of the header, kept by the seal
+// cache for the validation and the fraud checks, and the senders of the
+// transactions, kept by the transactions for the write. The ones failing to
+// recover are left to fail those the same as without the recovery.
+func prepareAvailBlock(ctx context.Context, bc *blockchain.Blockchain, decoder *avail.BlockDecoder, blk *avail_types.SignedBlock) *preparedBlock {
+	extracted, err := decoder.Extract(ctx, blk)
+	if err != nil {
+		return &preparedBlock{SignedBlock: blk, err: err}
+	}
+
+	for _, edgeBlk := range extracted.Blocks() {
+		_, _ = block.AddressRecoverFromHeader(edgeBlk.Header)
+		_ = bc.RecoverSenders(edgeBlk)
+	}
+
+	return &preparedBlock{SignedBlock: blk, extracted: extracted}
+}
+
+// prepareAvailBlocks prepares the Avail blocks on the given number of workers,
+// concurrently, and returns them over the channel in their order, for their
+// Edge blocks to be written in order. The workers go up to twice their number
+// of blocks ahead of the one the channel waits to hand out, and wait for the
+// consumer past it. The channel is closed once all the blocks are handed out,
+// or the context is canceled; the consumer stopping early cancels it.
+func (sw *SequencerWorker) prepareAvailBlocks(ctx context.Context, decoder *avail.BlockDecoder, blks []*avail_types.SignedBlock, workers int) <-chan *preparedBlock {
+	in := make(chan *avail_types.SignedBlock)
+
+	go func() {
+		defer close(in)
+
+		for _, blk := range blks {
+			select {
+			case in <- blk:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return prepareAvailBlockStream(ctx, sw.blockchain, decoder, in, workers)
+}
+
+// prepareAvailBlockStream prepares the Avail blocks coming in over the given
+// channel the same as prepareAvailBlocks, for the stage fetching them to run
+// ahead of the one preparing them. The output channel is closed once the
+// input one is, and all its blocks handed out.
+func prepareAvailBlockStream(ctx context.Context, bc *blockchain.Blockchain, decoder *avail.BlockDecoder, blks <-chan *avail_types.SignedBlock, workers int) <-chan *preparedBlock {
+	if workers < 1 {
+		workers = 1
+	}
+
+	type job struct {
+		blk *avail_types.SignedBlock
+		res chan<- *preparedBlock
+	}
+
+	jobs := make(chan job)
+	pending := make(chan chan *preparedBlock, 2*workers)
+	out := make(chan *preparedBlock)
+
+	for i := 0; i < workers; i++ {
+		go func() {
+			for j := range jobs {
+				j.res <- prepareAvailBlock(ctx, bc, decoder, j.blk)
+			}
+		}()
+	}
+
+	// The blocks go to the workers in their order, each with the slot of its
+	// result queued up in the same order; the queue bounds the blocks
+	// prepared ahead.
+	go func() {
+		defer close(pending)
+		defer close(jobs)
+
+		for blk := range blks {
+			res := make(chan *preparedBlock, 1)
+
+			select {
+			case pending <- res:
+			case <-ctx.Done():
+				return
+			}
+
+			select {
+			case jobs <- job{blk: blk, res: res}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	go func() {
+		defer close(out)
+
+		for res := range pending {
+			var p *preparedBlock
+
+			select {
+			case p = <-res:
+			case <-ctx.Done():
+				return
+			}
+
+			select {
+			case out <- p:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out
+}
@@ -0,0 +1,319 @@
+package avail
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/0xPolygon/polygon-edge/blockchain/storage"
+	"github.com/0xPolygon/polygon-edge/chain"
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/consensus/avail/validator"
+	"github.com/availproject/op-evm/pkg/avail"
+	"github.com/availproject/op-evm/pkg/avail/testutil"
+	"github.com/availproject/op-evm/pkg/blockchain"
+	pkg_common "github.com/availproject/op-evm/pkg/common"
+	"github.com/availproject/op-evm/pkg/test"
+	avail_types "github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+// testBacklog is the chain of a leader, for a sequencer on the same genesis
+// to catch up with once settled on Avail.
+type testBacklog struct {
+	chain *chain.Chain
+	blks  []*types.Block
+}
+
+// newTestBacklog returns the backlog of the given number of blocks of the
+// leader, each with the given number of transfers.
+func newTestBacklog(tb testing.TB, blocks, txs int) *testBacklog {
+	tb.Helper()
+
+	genesis, err := test.NewChain(getGenesisBasePath())
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	// The senders are funded in the genesis, the same for every node.
+	senders := make([]*testSender, txs)
+	for i := range senders {
+		addr, key := test.NewAccount(tb)
+		genesis.Genesis.Alloc[addr] = &chain.GenesisAccount{Balance: big.NewInt(0).Mul(big.NewInt(1000), pkg_common.ETH)}
+		senders[i] = &testSender{addr: addr, key: key}
+	}
+
+	addr, key := test.NewAccount(tb)
+	leader, fraudResolver, _ := newTestSequencerWorkerOf(tb, newTestGenesisAvailOf(tb, genesis, addr, key), testutil.NewFake(avail_types.NewUCompactFromUInt(1)))
+	account := accounts.Account{Address: common.Address(leader.nodeAddr)}
+
+	backlog := &testBacklog{chain: genesis}
+
+	for n := 0; n < blocks; n++ {
+		for _, s := range senders {
+			if err := leader.txpool.AddTx(s.transfer(tb, 1)); err != nil {
+				tb.Fatal(err)
+			}
+		}
+
+		assert.Eventually(tb, func() bool { return leader.txpool.Length() == uint64(txs) }, 5*time.Second, 10*time.Millisecond)
+
+		if err := leader.writeBlock(fraudResolver, account, &keystore.Key{PrivateKey: leader.nodeSignKey}); err != nil {
+			tb.Fatal(err)
+		}
+
+		blk, ok := leader.blockchain.GetBlockByNumber(leader.blockchain.Header().Number, true)
+		if !ok || len(blk.Transactions) != txs {
+			tb.Fatalf("block %d not written with its %d transactions", n+1, txs)
+		}
+
+		backlog.blks = append(backlog.blks, blk)
+	}
+
+	return backlog
+}
+
+// settle settles the blocks of the backlog on a new fake Avail, each in an
+// Avail block of its own, the Avail block of the edge block numbered the
+// same, changed by tamper, when given, on the way.
+func (bl *testBacklog) settle(tb testing.TB, tamper func(*types.Block) *types.Block) *testutil.Fake {
+	tb.Helper()
+
+	return bl.settleOn(tb, testutil.NewFake(avail_types.NewUCompactFromUInt(1)), tamper)
+}
+
+// settleOn settles the blocks of the backlog on the given fake Avail, the
+// same as settle.
+func (bl *testBacklog) settleOn(tb testing.TB, fake *testutil.Fake, tamper func(*types.Block) *types.Block) *testutil.Fake {
+	tb.Helper()
+
+	for _, blk := range bl.blks {
+		// The blocks go through Avail encoded, as the other nodes get them.
+		decoded := new(types.Block)
+		if err := decoded.UnmarshalRLP(blk.MarshalRLP()); err != nil {
+			tb.Fatal(err)
+		}
+
+		if tamper != nil {
+			decoded = tamper(decoded)
+		}
+
+		if _, err := fake.SendAndWaitForStatus(context.Background(), decoded, avail_types.ExtrinsicStatus{IsInBlock: true}); err != nil {
+			tb.Fatal(err)
+		}
+	}
+
+	return fake
+}
+
+// newCatchingUp returns the sequencer on the genesis of the backlog, on the
+// given storage, catching up with the given fake Avail on the given number of
+// workers, with the Avail blocks of the backlog.
+func (bl *testBacklog) newCatchingUp(tb testing.TB, fake *testutil.Fake, db storage.Storage, workers uint64) (*SequencerWorker, *Fraud, *avail.BlockDecoder, []*avail_types.SignedBlock) {
+	tb.Helper()
+
+	addr, key := test.NewAccount(tb)
+
+	sw, fraudResolver, _ := newTestSequencerWorkerOf(tb, newTestGenesisAvailOn(tb, bl.chain, db, addr, key), fake)
+	sw.availClient = fake
+	sw.catchUp.Workers = workers
+
+	blks, err := fake.Query(context.Background(), 1, fake.Head())
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	return sw, fraudResolver, avail.NewBlockDecoder(fake, avail_types.NewUCompactFromUInt(1), sw.logger), blks
+}
+
+func newTestMemoryStorage(tb testing.TB) storage.Storage {
+	tb.Helper()
+
+	db, err := test.NewMemoryStorage()
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	return db
+}
+
+// failingBlockStorage is the storage failing to write the body of the block
+// of the given hash.
+type failingBlockStorage struct {
+	storage.Storage
+	hash types.Hash
+}
+
+func (s *failingBlockStorage) WriteBody(hash types.Hash, body *types.Body) error {
+	if hash == s.hash {
+		return fmt.Errorf("disk full")
+	}
+
+	return s.Storage.WriteBody(hash, body)
+}
+
+func TestCatchUpPipelinePreparesInOrder(t *testing.T) {
+	backlog := newTestBacklog(t, 40, 3)
+	fake := backlog.settle(t, nil)
+
+	sw, _, decoder, blks := backlog.newCatchingUp(t, fake, newTestMemoryStorage(t), 8)
+
+	i := 0
+	for p := range sw.prepareAvailBlocks(context.Background(), decoder, blks, 8) {
+		// The Avail blocks come out in their order, prepared.
+		if !assert.Same(t, blks[i], p.SignedBlock) {
+			return
+		}
+
+		edgeBlks, err := p.edgeBlocks(decoder)
+		if assert.NoError(t, err) && assert.Len(t, edgeBlks, 1) {
+			assert.Equal(t, backlog.blks[i].Hash(), edgeBlks[0].Hash())
+
+			for _, tx := range edgeBlks[0].Transactions {
+				assert.NotEqual(t, types.ZeroAddress, tx.From)
+			}
+		}
+
+		i++
+	}
+
+	assert.Equal(t, len(blks), i)
+}
+
+func TestCatchUpPipelineWritesInOrder(t *testing.T) {
+	backlog := newTestBacklog(t, 40, 3)
+	fake := backlog.settle(t, nil)
+
+	sw, fraudResolver, decoder, blks := backlog.newCatchingUp(t, fake, newTestMemoryStorage(t), 8)
+
+	cursor, err := sw.applyAvailBlocks(decoder, validator.New(sw.blockchain, sw.nodeAddr, sw.logger), fraudResolver, blks, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, fake.Head()+1, cursor)
+
+	// The children following their parents, every block makes the chain.
+	for _, blk := range backlog.blks {
+		hdr, ok := sw.blockchain.GetHeaderByNumber(blk.Number())
+		if assert.True(t, ok, "block %d not written", blk.Number()) {
+			assert.Equal(t, blk.Hash(), hdr.Hash)
+		}
+	}
+}
+
+func TestCatchUpPipelineStopsAtBadBlock(t *testing.T) {
+	const bad = 15
+
+	backlog := newTestBacklog(t, 30, 3)
+
+	// The block in the middle is changed under its seal.
+	fake := backlog.settle(t, func(blk *types.Block) *types.Block {
+		if blk.Number() == bad {
+			blk.Header.Timestamp++
+			blk.Header.ComputeHash()
+		}
+
+		return blk
+	})
+
+	sw, fraudResolver, decoder, blks := backlog.newCatchingUp(t, fake, newTestMemoryStorage(t), 8)
+
+	// The bad block fails its check and is skipped; its children, prepared
+	// ahead, are left without their parent.
+	cursor, err := sw.applyAvailBlocks(decoder, validator.New(sw.blockchain, sw.nodeAddr, sw.logger), fraudResolver, blks, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, fake.Head()+1, cursor)
+
+	assert.Equal(t, backlog.blks[bad-2].Hash(), sw.blockchain.Header().Hash)
+
+	for _, blk := range backlog.blks[bad-1:] {
+		_, ok := sw.blockchain.GetHeaderByHash(blk.Hash())
+		assert.False(t, ok, "block %d written", blk.Number())
+	}
+}
+
+func TestCatchUpPipelineStopsOnStorageFailure(t *testing.T) {
+	const failing = 15
+
+	backlog := newTestBacklog(t, 30, 3)
+	fake := backlog.settle(t, nil)
+
+	db := &failingBlockStorage{Storage: newTestMemoryStorage(t), hash: backlog.blks[failing-1].Hash()}
+	sw, fraudResolver, decoder, blks := backlog.newCatchingUp(t, fake, db, 8)
+	v := validator.New(sw.blockchain, sw.nodeAddr, sw.logger)
+
+	// The write stops at the failing block, the blocks prepared past it left
+	// unwritten, to pick up from its Avail block.
+	cursor, err := sw.applyAvailBlocks(decoder, v, fraudResolver, blks, 1)
+	assert.ErrorIs(t, err, blockchain.ErrStorage)
+	assert.Equal(t, uint64(failing), cursor)
+	assert.Equal(t, backlog.blks[failing-2].Hash(), sw.blockchain.Header().Hash)
+
+	for _, blk := range backlog.blks[failing-1:] {
+		_, ok := sw.blockchain.GetHeaderByHash(blk.Hash())
+		assert.False(t, ok, "block %d written", blk.Number())
+	}
+
+	// The storage back, the catch-up picks up from there.
+	db.hash = types.ZeroHash
+
+	cursor, err = sw.applyAvailBlocks(decoder, v, fraudResolver, blks[cursor-1:], cursor)
+	assert.NoError(t, err)
+	assert.Equal(t, fake.Head()+1, cursor)
+	assert.Equal(t, backlog.blks[len(backlog.blks)-1].Hash(), sw.blockchain.Header().Hash)
+}
+
+// BenchmarkCatchUpPipeline measures the catch-up with a backlog of 200 Avail
+// blocks, each carrying a block of 8 transfers, applied one Avail block at a
+// time, serially, and through the pipeline on a number of workers.
+func BenchmarkCatchUpPipeline(b *testing.B) {
+	const blocks, txs = 200, 8
+
+	backlog := newTestBacklog(b, blocks, txs)
+	fake := backlog.settle(b, nil)
+
+	run := func(b *testing.B, workers uint64, apply func(*SequencerWorker, *avail.BlockDecoder, validator.Validator, *Fraud, []*avail_types.SignedBlock) error) {
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+
+			sw, fraudResolver, decoder, blks := backlog.newCatchingUp(b, fake, newTestMemoryStorage(b), workers)
+			v := validator.New(sw.blockchain, sw.nodeAddr, sw.logger)
+
+			b.StartTimer()
+
+			if err := apply(sw, decoder, v, fraudResolver, blks); err != nil {
+				b.Fatal(err)
+			}
+
+			if sw.blockchain.Header().Number != blocks {
+				b.Fatalf("caught up with block %d, want %d", sw.blockchain.Header().Number, blocks)
+			}
+		}
+
+		b.ReportMetric(float64(blocks*b.N)/b.Elapsed().Seconds(), "blocks/s")
+	}
+
+	b.Run("serial", func(b *testing.B) {
+		run(b, 1, func(sw *SequencerWorker, decoder *avail.BlockDecoder, v validator.Validator, fraudResolver *Fraud, blks []*avail_types.SignedBlock) error {
+			for _, blk := range blks {
+				if _, err := sw.applyAvailBlocks(decoder, v, fraudResolver, []*avail_types.SignedBlock{blk}, uint64(blk.Block.Header.Number)); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+	})
+
+	for _, workers := range []uint64{1, 4, 8} {
+		b.Run(fmt.Sprintf("pipeline-%d", workers), func(b *testing.B) {
+			run(b, workers, func(sw *SequencerWorker, decoder *avail.BlockDecoder, v validator.Validator, fraudResolver *Fraud, blks []*avail_types.SignedBlock) error {
+				_, err := sw.applyAvailBlocks(decoder, v, fraudResolver, blks, 1)
+				return err
+			})
+		})
+	}
+}
@@ -0,0 +1,239 @@
+package avail
+
+import (
+	"fmt"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// BlockStage is the stage of a block in its lifecycle, from written locally to
+// settled on Avail, or disputed and reorged out, as returned by
+// `avail_getBlockStatus`.
+type BlockStage string
+
+const (
+	// BlockLocal is the block written to the local chain, not seen on Avail
+	// yet.
+	BlockLocal BlockStage = "local"
+
+	// BlockAvailIncluded is the block included in an Avail block, within its
+	// challenge window.
+	BlockAvailIncluded BlockStage = "availIncluded"
+
+	// BlockAvailFinalized is the block included in a finalized Avail block,
+	// within its challenge window.
+	BlockAvailFinalized BlockStage = "availFinalized"
+
+	// BlockSettled is the canonical block past its challenge window, final
+	// but for a dispute resolution.
+	BlockSettled BlockStage = "settled"
+
+	// BlockDisputed is the block accused by a fraud proof, its dispute
+	// unresolved.
+	BlockDisputed BlockStage = "disputed"
+
+	// BlockReorgedOut is the block off the canonical chain, forked out by the
+	// fork choice or rolled back by a dispute resolution.
+	BlockReorgedOut BlockStage = "reorgedOut"
+)
+
+// BlockStatus is the status of a block, as returned by `avail_getBlockStatus`:
+// its stage, along with the details it's assembled from, the inclusion in
+// Avail, the challenge window and the dispute of the block, if any.
+type BlockStatus struct {
+	Number    uint64     `json:"number"`
+	Hash      types.Hash `json:"hash"`
+	Stage     BlockStage `json:"stage"`
+	Canonical bool       `json:"canonical"`
+
+	// AvailIncluded is set for the block seen on Avail, in the Avail block at
+	// AvailBlock, if known; the Avail blocks of the blocks settled long ago
+	// are no longer tracked.
+	AvailIncluded  bool    `json:"availIncluded"`
+	AvailBlock     *uint64 `json:"availBlock,omitempty"`
+	AvailFinalized bool    `json:"availFinalized"`
+
+	// ChallengeableUntil is the Avail height the challenge window of the
+	// block closes at: the fraud proofs against it are taken in the Avail
+	// blocks below it. It's unset for the blocks not seen on Avail, and the
+	// settled ones.
+	ChallengeableUntil *uint64 `json:"challengeableUntil,omitempty"`
+	Settled            bool    `json:"settled"`
+
+	// DisputedBy is the watchtower accusing the block of fraud, if any, and
+	// DisputeOutcome how the dispute went.
+	DisputedBy     *types.Address `json:"disputedBy,omitempty"`
+	DisputeOutcome FraudOutcome   `json:"disputeOutcome,omitempty"`
+
+	// ReorgedOut is set for the block off the canonical chain, and
+	// ReorgedByFraud for the one rolled back along with its ancestor, or
+	// itself, accused of fraud.
+	ReorgedOut     bool `json:"reorgedOut"`
+	ReorgedByFraud bool `json:"reorgedByFraud"`
+}
+
+// TransactionStatus is the status of a transaction, the one of the block it's
+// in, as returned by `avail_getTransactionStatus`.
+type TransactionStatus struct {
+	TransactionHash  types.Hash  `json:"transactionHash"`
+	TransactionIndex uint64      `json:"transactionIndex"`
+	Block            BlockStatus `json:"block"`
+}
+
+// GetBlockStatus returns the status of the block of the given hash or number;
+// "latest" and "pending" stand for the head, "finalized" and "safe" for the
+// settled head. An unknown block is an error.
+func (api *StatusAPI) GetBlockStatus(blockNrOrHash rpc.BlockNumberOrHash) (*BlockStatus, error) {
+	hdr, err := api.d.headerByNumberOrHash(blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+
+	return api.d.BlockStatus(hdr.Hash)
+}
+
+// GetTransactionStatus returns the status of the transaction of the given
+// hash, through the block it's in. An unknown transaction is an error.
+func (api *StatusAPI) GetTransactionStatus(txHash types.Hash) (*TransactionStatus, error) {
+	return api.d.TransactionStatus(txHash)
+}
+
+// BlockStatus returns the status of the block of the given hash, assembled
+// from the blocks seen on Avail, the settled head, the settlement index of the
+// blocks submitted and the fraud catalog.
+func (d *Avail) BlockStatus(hash types.Hash) (*BlockStatus, error) {
+	hdr, ok := d.blockchain.GetHeaderByHash(hash)
+	if !ok {
+		return nil, fmt.Errorf("block %s not found", hash)
+	}
+
+	status := &BlockStatus{Number: hdr.Number, Hash: hdr.Hash}
+
+	if canonical, ok := d.blockchain.GetHeaderByNumber(hdr.Number); ok {
+		status.Canonical = canonical.Hash == hdr.Hash
+	}
+
+	var settled *settledHead
+	if d.forkChoice != nil {
+		settled = d.forkChoice.settled
+	}
+
+	availBlock, window, included := settled.inclusion(hdr)
+	if !included && d.forkChoice != nil {
+		availBlock, included = d.forkChoice.inclusion(hdr.Hash)
+	}
+
+	if d.settlements != nil {
+		if res, ok := d.settlements.Get(hdr.Hash); ok {
+			status.AvailFinalized = res.Finalized
+
+			if !included {
+				availBlock, included = res.BlockNumber, true
+			}
+		}
+	}
+
+	if included {
+		status.AvailIncluded = true
+		status.AvailBlock = &availBlock
+	}
+
+	status.Settled = status.Canonical && hdr.Number <= d.SettledHead().Number
+
+	switch {
+	case status.Settled:
+		// The settled blocks are past their inclusion in Avail, but for the
+		// genesis.
+		status.AvailIncluded = status.AvailIncluded || hdr.Number > 0
+	case included && settled != nil:
+		until := availBlock + window
+		status.ChallengeableUntil = &until
+	}
+
+	if e, ok := d.frauds.event(hdr.Hash); ok {
+		status.DisputedBy = &e.Watchtower
+		status.DisputeOutcome = e.Outcome
+	}
+
+	if !status.Canonical {
+		status.ReorgedOut = true
+		status.ReorgedByFraud = d.rolledBackByFraud(hdr)
+	}
+
+	switch {
+	case status.ReorgedOut:
+		status.Stage = BlockReorgedOut
+	case status.DisputeOutcome == FraudDisputed:
+		status.Stage = BlockDisputed
+	case status.Settled:
+		status.Stage = BlockSettled
+	case status.AvailFinalized:
+		status.Stage = BlockAvailFinalized
+	case status.AvailIncluded:
+		status.Stage = BlockAvailIncluded
+	default:
+		status.Stage = BlockLocal
+	}
+
+	return status, nil
+}
+
+// TransactionStatus returns the status of the transaction of the given hash,
+// through the block it's in.
+func (d *Avail) TransactionStatus(txHash types.Hash) (*TransactionStatus, error) {
+	blockHash, ok := d.blockchain.ReadTxLookup(txHash)
+	if !ok {
+		return nil, fmt.Errorf("transaction %s not found", txHash)
+	}
+
+	blk, ok := d.blockchain.GetBlockByHash(blockHash, true)
+	if !ok {
+		return nil, fmt.Errorf("block %s of transaction %s not found", blockHash, txHash)
+	}
+
+	status := &TransactionStatus{TransactionHash: txHash}
+
+	found := false
+
+	for i, tx := range blk.Transactions {
+		if tx.Hash == txHash {
+			status.TransactionIndex, found = uint64(i), true
+			break
+		}
+	}
+
+	if !found {
+		return nil, fmt.Errorf("transaction %s not found in block %s", txHash, blockHash)
+	}
+
+	block, err := d.BlockStatus(blockHash)
+	if err != nil {
+		return nil, err
+	}
+
+	status.Block = *block
+
+	return status, nil
+}
+
+// rolledBackByFraud reports whether the block off the canonical chain was
+// rolled back along with the block accused of fraud, itself or one of its
+// ancestors off the canonical chain, short of the fraud proof rejected.
+func (d *Avail) rolledBackByFraud(hdr *types.Header) bool {
+	for h, ok := hdr, true; ok; h, ok = d.blockchain.GetHeaderByHash(h.ParentHash) {
+		if canonical, ok := d.blockchain.GetHeaderByNumber(h.Number); ok && canonical.Hash == h.Hash {
+			return false
+		}
+
+		if e, ok := d.frauds.event(h.Hash); ok && e.Outcome != FraudRejected {
+			return true
+		}
+
+		if h.Number == 0 {
+			break
+		}
+	}
+
+	return false
+}
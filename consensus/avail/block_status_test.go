@@ -0,0 +1,157 @@
+package avail
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/pkg/avail"
+	"github.com/availproject/op-evm/pkg/block"
+	"github.com/availproject/op-evm/pkg/test"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+// testBlockStatus returns the status of the block of the given hash over the
+// status API.
+func testBlockStatus(t *testing.T, c *rpc.Client, hash types.Hash) BlockStatus {
+	t.Helper()
+
+	var status BlockStatus
+	if err := c.Call(&status, avail.SettlementNamespace+"_getBlockStatus", hash); err != nil {
+		t.Fatal(err)
+	}
+
+	return status
+}
+
+func TestBlockStatusLifecycle(t *testing.T) {
+	d := newTestGenesisAvail(t)
+	d.forkChoice = newForkChoice(d.blockchain, d.minerAddr, DefaultMaxReorgDepth, newSettledHead(d.blockchain, NewChallengeWindow(3), hclog.NewNullLogger()), nil, hclog.NewNullLogger())
+	d.frauds = newFraudCatalog("", hclog.NewNullLogger())
+	d.settlements = avail.NewSettlementIndex()
+
+	srv := rpc.NewServer()
+	if err := srv.RegisterName(avail.SettlementNamespace, NewStatusAPI(d)); err != nil {
+		t.Fatal(err)
+	}
+
+	c := rpc.DialInProc(srv)
+	defer c.Close()
+
+	faucet := &testSender{addr: test.FaucetAccount, key: test.FaucetSignKey}
+	transfer := func() *types.Transaction {
+		return faucet.sign(t, &types.Transaction{To: &types.ZeroAddress, Value: big.NewInt(1), Gas: 21_000}, 1)
+	}
+
+	// The block is written locally, not seen on Avail yet.
+	tx1 := transfer()
+	blk1 := buildTestBlock(t, d, tx1)
+
+	if err := d.blockchain.WriteBlock(blk1, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	status := testBlockStatus(t, c, blk1.Hash())
+	assert.Equal(t, BlockLocal, status.Stage)
+	assert.True(t, status.Canonical)
+	assert.False(t, status.AvailIncluded)
+	assert.Nil(t, status.ChallengeableUntil)
+
+	var txStatus TransactionStatus
+	if err := c.Call(&txStatus, avail.SettlementNamespace+"_getTransactionStatus", tx1.Hash); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, uint64(0), txStatus.TransactionIndex)
+	assert.Equal(t, blk1.Hash(), txStatus.Block.Hash)
+	assert.Equal(t, BlockLocal, txStatus.Block.Stage)
+
+	// It's seen in the Avail block 5, challengeable up to the Avail block 8.
+	if _, err := d.forkChoice.apply(blk1, blockInclusion{availBlock: 5}, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	status = testBlockStatus(t, c, blk1.Hash())
+	assert.Equal(t, BlockAvailIncluded, status.Stage)
+	assert.Equal(t, uint64(5), *status.AvailBlock)
+	assert.Equal(t, uint64(8), *status.ChallengeableUntil)
+
+	// The Avail block goes final.
+	d.settlements.Record(blk1.Hash(), blk1.Number(), avail.SubmitResult{BlockNumber: 5, Finalized: true})
+
+	status = testBlockStatus(t, c, blk1.Hash())
+	assert.Equal(t, BlockAvailFinalized, status.Stage)
+	assert.True(t, status.AvailFinalized)
+
+	// The challenge window closes, and the block settles; by number, as the
+	// finalized block, as well.
+	d.forkChoice.settle(8)
+
+	var settled BlockStatus
+	if err := c.Call(&settled, avail.SettlementNamespace+"_getBlockStatus", "finalized"); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, BlockSettled, settled.Stage)
+	assert.Equal(t, blk1.Hash(), settled.Hash)
+	assert.True(t, settled.Settled)
+	assert.True(t, settled.AvailIncluded)
+	assert.Nil(t, settled.ChallengeableUntil)
+
+	// The next blocks are seen on Avail, and the first of them is accused of
+	// fraud within its window.
+	tx2 := transfer()
+	blk2 := buildTestBlock(t, d, tx2)
+
+	if _, err := d.forkChoice.apply(blk2, blockInclusion{availBlock: 9}, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	blk3 := buildTestBlock(t, d)
+
+	if _, err := d.forkChoice.apply(blk3, blockInclusion{availBlock: 10}, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	watchtower := types.StringToAddress("0xbeef")
+	fraudBlk := &types.Block{Header: &types.Header{
+		Number:    3,
+		Miner:     watchtower.Bytes(),
+		ExtraData: block.EncodeExtraDataFields(map[string][]byte{block.KeyFraudProofOf: blk2.Hash().Bytes()}),
+	}}
+	fraudBlk.Header.ComputeHash()
+
+	d.frauds.detected(fraudBlk, blk2.Header, 10, nil)
+
+	status = testBlockStatus(t, c, blk2.Hash())
+	assert.Equal(t, BlockDisputed, status.Stage)
+	assert.Equal(t, watchtower, *status.DisputedBy)
+	assert.Equal(t, FraudDisputed, status.DisputeOutcome)
+	assert.Equal(t, uint64(12), *status.ChallengeableUntil)
+
+	assert.Equal(t, BlockAvailIncluded, testBlockStatus(t, c, blk3.Hash()).Stage)
+
+	// The fraudulent blocks are rolled back; the settled block holds.
+	if _, err := rollBackFraudulentBlocks(d.blockchain, blk1.Hash(), "test", nil, nil, hclog.NewNullLogger()); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, blk := range []*types.Block{blk2, blk3} {
+		status = testBlockStatus(t, c, blk.Hash())
+		assert.Equal(t, BlockReorgedOut, status.Stage)
+		assert.False(t, status.Canonical)
+		assert.True(t, status.ReorgedOut)
+		assert.True(t, status.ReorgedByFraud)
+	}
+
+	assert.Equal(t, BlockSettled, testBlockStatus(t, c, blk1.Hash()).Stage)
+
+	// The unknown block and transaction are errors, not empty statuses.
+	unknown := types.StringToHash("0x1234")
+
+	assert.Error(t, c.Call(&status, avail.SettlementNamespace+"_getBlockStatus", unknown))
+	assert.Error(t, c.Call(&status, avail.SettlementNamespace+"_getBlockStatus", "0x64"))
+	assert.Error(t, c.Call(&txStatus, avail.SettlementNamespace+"_getTransactionStatus", unknown))
+}
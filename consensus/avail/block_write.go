@@ -0,0 +1,85 @@
+package avail
+
+import (
+	"errors"
+
+	"github.com/availproject/op-evm/pkg/avail"
+	"github.com/availproject/op-evm/pkg/blockchain"
+	"github.com/hashicorp/go-hclog"
+)
+
+// blockWrite is how a block received from Avail was written to the chain.
+type blockWrite int
+
+const (
+	// blockWritten is the block extending the chain.
+	blockWritten blockWrite = iota
+
+	// blockDuplicate is the block already in the chain, such as the one seen
+	// again after reconnecting to Avail; it isn't written again.
+	blockDuplicate
+
+	// blockConflicting is the block competing with a canonical one at its
+	// height; it's written as a fork and left to the fork choice.
+	blockConflicting
+
+	// blockRejected is the block failing to write on its own, such as the
+	// one without a known parent; it's skipped.
+	blockRejected
+
+	// blockHalted is the block held back by the tripped circuit breaker;
+	// it's skipped.
+	blockHalted
+)
+
+// writeAvailBlock writes the validated block received from Avail, included at
+// the given inclusion, through the fork choice, unless the circuit breaker
+// holds it back. The duplicates, the conflicting blocks and the rejected ones
+// leave the handler going on with the next blocks; only the storage failing a
+// write is returned as an error, and aborts the handling of the Avail blocks.
+func writeAvailBlock(fc *forkChoice, breaker *circuitBreaker, decoded avail.EdgeBlock, inclusion blockInclusion, source string, logger hclog.Logger) (blockWrite, error) {
+	edgeBlk := decoded.Block
+
+	if !breaker.admits(edgeBlk) {
+		logger.Warn("block held back by the tripped circuit breaker", "block_number", edgeBlk.Number(), "block_hash", edgeBlk.Hash())
+
+		return blockHalted, nil
+	}
+
+	write, err := fc.apply(edgeBlk, inclusion, source)
+
+	switch {
+	case errors.Is(err, blockchain.ErrStorage):
+		logger.Error(
+			"failed to store edge block received from avail",
+			"edge_block_hash", edgeBlk.Hash(),
+			"extrinsic_index", decoded.ExtrinsicIndex,
+			"submitter", decoded.Submitter.ToHexString(),
+			"error", err,
+		)
+
+		return write, err
+
+	case err != nil:
+		logger.Warn(
+			"failed to write edge block received from avail",
+			"edge_block_hash", edgeBlk.Hash(),
+			"extrinsic_index", decoded.ExtrinsicIndex,
+			"submitter", decoded.Submitter.ToHexString(),
+			"error", err,
+		)
+
+		return blockRejected, nil
+
+	case write == blockDuplicate:
+		logger.Debug("skipped block already in the chain", "block_number", edgeBlk.Number(), "block_hash", edgeBlk.Hash())
+
+	case write == blockConflicting:
+		logger.Info("block conflicting with the chain left to the fork choice", "block_number", edgeBlk.Number(), "block_hash", edgeBlk.Hash())
+
+	default:
+		logger.Debug("wrote block to blockchain from Avail", "block_number", edgeBlk.Number())
+	}
+
+	return write, nil
+}
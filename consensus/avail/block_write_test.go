@@ -0,0 +1,143 @@
+package avail
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/0xPolygon/polygon-edge/blockchain/storage"
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/pkg/avail/testutil"
+	"github.com/availproject/op-evm/pkg/blockchain"
+	"github.com/availproject/op-evm/pkg/test"
+	avail_types "github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// failingStorage is the storage failing to write the block bodies while
+// failing is set.
+type failingStorage struct {
+	storage.Storage
+	failing atomic.Bool
+}
+
+func (s *failingStorage) WriteBody(hash types.Hash, body *types.Body) error {
+	if s.failing.Load() {
+		return errors.New("disk full")
+	}
+
+	return s.Storage.WriteBody(hash, body)
+}
+
+// newTestSyncingAvail returns the consensus of a sequencer, on the storage
+// given, syncing the chain from the fake Avail.
+func newTestSyncingAvail(t *testing.T, fake *testutil.Fake, appID avail_types.UCompact, db storage.Storage) *Avail {
+	t.Helper()
+
+	chain, err := test.NewChain(getGenesisBasePath())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addr, key := test.NewAccount(t)
+
+	d := newTestGenesisAvailOn(t, chain, db, addr, key)
+	d.availClient = fake
+	d.availAppID = appID
+
+	return d
+}
+
+// settleTestBlocks settles on Avail, in order, the block of a on top of the
+// genesis, the one of b competing with it, and the child of the block of a.
+func settleTestBlocks(t *testing.T, fake *testutil.Fake) (blkA, blkB, childA *types.Block) {
+	t.Helper()
+
+	a, b := newTestGenesisAvail(t), newTestGenesisAvail(t)
+
+	blkA = buildTestBlock(t, a)
+	settleTestBlock(t, a, fake, blkA)
+
+	blkB = buildTestBlock(t, b)
+	settleTestBlock(t, b, fake, blkB)
+
+	childA = buildTestBlock(t, a)
+	settleTestBlock(t, a, fake, childA)
+
+	return blkA, blkB, childA
+}
+
+// syncTestAvail syncs the chain of the sequencer up to the Avail head.
+func syncTestAvail(d *Avail, fake *testutil.Fake) (uint64, error) {
+	head := fake.Head()
+
+	return d.syncNodeUntil(func(blk *avail_types.SignedBlock) bool {
+		return uint64(blk.Block.Header.Number) == head
+	})
+}
+
+func TestSyncSkipsDuplicatesAndConflicts(t *testing.T) {
+	appID := avail_types.NewUCompactFromUInt(1)
+	fake := testutil.NewFake(appID)
+
+	blkA, blkB, childA := settleTestBlocks(t, fake)
+
+	// Every Avail block shows up twice, as after reconnecting to Avail.
+	fake.DuplicateDelivery(true)
+
+	db, err := test.NewMemoryStorage()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := newTestSyncingAvail(t, fake, appID, db)
+
+	cursor, err := syncTestAvail(d, fake)
+	assert.NoError(t, err)
+	assert.Equal(t, fake.Head(), cursor)
+
+	// The conflicting block is kept as a fork, and the sync goes on past it.
+	_, ok := d.blockchain.GetHeaderByHash(blkB.Hash())
+	assert.True(t, ok)
+
+	canonical, ok := d.blockchain.GetHeaderByNumber(1)
+	if assert.True(t, ok) {
+		assert.Equal(t, blkA.Hash(), canonical.Hash)
+	}
+
+	assert.Equal(t, childA.Hash(), d.blockchain.Header().Hash)
+}
+
+func TestSyncAbortsOnStorageFailure(t *testing.T) {
+	appID := avail_types.NewUCompactFromUInt(1)
+	fake := testutil.NewFake(appID)
+
+	_, _, childA := settleTestBlocks(t, fake)
+
+	db, err := test.NewMemoryStorage()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	failing := &failingStorage{Storage: db}
+	d := newTestSyncingAvail(t, fake, appID, failing)
+
+	// The storage failing, the sync stops at the first block, to be fetched
+	// again.
+	failing.failing.Store(true)
+
+	cursor, err := syncTestAvail(d, fake)
+	assert.ErrorIs(t, err, blockchain.ErrStorage)
+	assert.Equal(t, uint64(1), cursor)
+	assert.Equal(t, uint64(0), d.blockchain.Header().Number)
+
+	_, ok := d.blockchain.GetHeaderByHash(childA.Hash())
+	assert.False(t, ok)
+
+	// The storage back, the sync picks up where it stopped.
+	failing.failing.Store(false)
+
+	_, err = syncTestAvail(d, fake)
+	assert.NoError(t, err)
+	assert.Equal(t, childA.Hash(), d.blockchain.Header().Hash)
+}
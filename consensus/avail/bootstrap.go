@@ -0,0 +1,176 @@
+package avail
+
+import (
+	"errors"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/pkg/avail"
+	avail_types "github.com/centrifuge/go-substrate-rpc-client/v4/types"
+)
+
+// BootstrapState is the stage of the handoff of a bootstrap sequencer
+// candidate, the node started with the bootstrap flag, to either the chain it
+// bootstraps or the chain bootstrapped by another candidate.
+//
+// A candidate starts out as BootstrapCandidate. An account off the bootstrap
+// allowlist ends up BootstrapNotAllowed, while a candidate finding settlement
+// data of the app on Avail ends up BootstrapFollowing; both sync the chain and
+// run as regular sequencers. Otherwise the candidate goes BootstrapSubmitting
+// and submits the first block of the chain, staking itself. Candidates
+// started at the same time may all get that far: the first block settled on
+// Avail wins, and its candidate becomes BootstrapDone while the others fall
+// back to BootstrapFollowing.
+type BootstrapState uint32
+
+const (
+	// BootstrapCandidate is the state of the candidate yet to decide.
+	BootstrapCandidate BootstrapState = iota
+
+	// BootstrapNotAllowed is the state of the candidate whose account isn't
+	// on the bootstrap allowlist.
+	BootstrapNotAllowed
+
+	// BootstrapFollowing is the state of the candidate that found the chain
+	// bootstrapped already.
+	BootstrapFollowing
+
+	// BootstrapSubmitting is the state of the candidate submitting the first
+	// block of the chain.
+	BootstrapSubmitting
+
+	// BootstrapDone is the state of the candidate that bootstrapped the
+	// chain.
+	BootstrapDone
+)
+
+// String returns the name of the bootstrap state.
+func (s BootstrapState) String() string {
+	switch s {
+	case BootstrapCandidate:
+		return "candidate"
+	case BootstrapNotAllowed:
+		return "not allowed"
+	case BootstrapFollowing:
+		return "following"
+	case BootstrapSubmitting:
+		return "submitting"
+	case BootstrapDone:
+		return "done"
+	default:
+		return "unknown"
+	}
+}
+
+// bootstrapAllowed reports whether the account of the node is on the
+// bootstrap allowlist. Without an allowlist configured, any node started
+// with the bootstrap flag is a candidate.
+func (d *Avail) bootstrapAllowed() bool {
+	if len(d.bootstrapAccounts) == 0 {
+		return true
+	}
+
+	for _, addr := range d.bootstrapAccounts {
+		if addr == d.minerAddr {
+			return true
+		}
+	}
+
+	return false
+}
+
+// bootstrap takes the bootstrap sequencer candidate through the handoff and
+// returns the state it ends up in; only the candidate in BootstrapDone
+// bootstrapped the chain, having written its first block.
+func (d *Avail) bootstrap() (BootstrapState, error) {
+	state := BootstrapCandidate
+
+	transition := func(next BootstrapState, msg string, args ...interface{}) {
+		d.logger.Info(msg, append([]interface{}{"from", state, "to", next}, args...)...)
+		state = next
+	}
+
+	if !d.bootstrapAllowed() {
+		transition(BootstrapNotAllowed, "account is not on the bootstrap allowlist; syncing the chain instead", "address", d.minerAddr)
+		return state, nil
+	}
+
+	hdr, err := d.availClient.GetLatestHeader(d.ctx)
+	if err != nil {
+		return state, err
+	}
+
+	first, err := d.firstSettledBlock(uint64(hdr.Number))
+	if err != nil {
+		return state, err
+	}
+
+	if first != nil {
+		transition(BootstrapFollowing, "found the chain settled on avail; syncing it instead of bootstrapping", "block_number", first.Number(), "block_hash", first.Hash())
+		return state, nil
+	}
+
+	transition(BootstrapSubmitting, "avail holds no settlement data of the app; bootstrapping the chain")
+
+	// Staking smart contract does not support `BootstrapSequencer` MachineType.
+	blk, err := d.buildStakingBlock(Sequencer.String())
+	if err != nil {
+		return state, err
+	}
+
+	res, err := d.availSender.SendAndWaitForStatus(d.ctx, blk, avail_types.ExtrinsicStatus{IsInBlock: true})
+	if err != nil {
+		d.logger.Error("error while submitting data to avail", "error", err)
+		return state, err
+	}
+
+	// Another candidate may have found Avail empty as well; the first block
+	// settled on Avail bootstraps the chain.
+	if first, err = d.firstSettledBlock(res.BlockNumber); err != nil {
+		return state, err
+	}
+
+	if first == nil || first.Hash() != blk.Hash() {
+		transition(BootstrapFollowing, "another bootstrap sequencer settled the chain first; syncing it instead", "block_hash", blk.Hash())
+		return state, nil
+	}
+
+	if err := d.blockchain.WriteBlock(blk, d.nodeType.String()); err != nil {
+		return state, err
+	}
+
+	transition(BootstrapDone, "bootstrapped the chain", "block_hash", blk.Hash(), "avail_block_number", res.BlockNumber)
+
+	return state, nil
+}
+
+// firstSettledBlock returns the first block of the app settled on Avail, up
+// to the Avail block of the given height, or nil if there's none. The Avail
+// blocks are fetched a page at a time.
+func (d *Avail) firstSettledBlock(to uint64) (*types.Block, error) {
+	decoder := avail.NewBlockDecoder(d.availClient, d.availAppID, d.logger)
+
+	for from := uint64(1); from <= to; from += d.catchUp.PageSize {
+		end := from + d.catchUp.PageSize - 1
+		if end > to {
+			end = to
+		}
+
+		blks, err := d.availClient.Query(d.ctx, from, end)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, blk := range blks {
+			edgeBlks, err := decoder.Decode(d.ctx, blk)
+			if len(edgeBlks) > 0 {
+				return edgeBlks[0].Block, nil
+			}
+
+			if err != nil && !errors.Is(err, avail.ErrNoExtrinsicFound) {
+				return nil, err
+			}
+		}
+	}
+
+	return nil, nil
+}
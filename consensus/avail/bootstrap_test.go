@@ -0,0 +1,134 @@
+package avail
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/0xPolygon/polygon-edge/chain"
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/pkg/avail/testutil"
+	"github.com/availproject/op-evm/pkg/common"
+	"github.com/availproject/op-evm/pkg/test"
+	avail_types "github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestBootstrapCandidates returns the consensus of n allowlisted bootstrap
+// sequencer candidates, funded in the genesis of the chain they share.
+func newTestBootstrapCandidates(t *testing.T, fake *testutil.Fake, appID avail_types.UCompact, n int) []*Avail {
+	t.Helper()
+
+	genesis, err := test.NewChain(getGenesisBasePath())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addrs := make([]types.Address, n)
+	keys := make([]*ecdsa.PrivateKey, n)
+
+	for i := range addrs {
+		addrs[i], keys[i] = test.NewAccount(t)
+		genesis.Genesis.Alloc[addrs[i]] = &chain.GenesisAccount{Balance: big.NewInt(0).Mul(big.NewInt(100), common.ETH)}
+	}
+
+	candidates := make([]*Avail, n)
+
+	for i := range candidates {
+		d := newTestGenesisAvailOf(t, genesis, addrs[i], keys[i])
+		d.nodeType = BootstrapSequencer
+		d.availClient = fake
+		d.availSender = fake
+		d.availAppID = appID
+		d.catchUp = DefaultCatchUpConfig()
+		d.bootstrapAccounts = addrs
+
+		candidates[i] = d
+	}
+
+	return candidates
+}
+
+func TestBootstrapSequencerCandidates(t *testing.T) {
+	appID := avail_types.NewUCompactFromUInt(1)
+	fake := testutil.NewFake(appID, testutil.WithTxPool())
+
+	all := newTestBootstrapCandidates(t, fake, appID, 3)
+	candidates, late := all[:2], all[2]
+
+	// Both candidates find Avail empty and submit at once.
+	states := make([]BootstrapState, len(candidates))
+
+	var wg sync.WaitGroup
+	for i, d := range candidates {
+		wg.Add(1)
+
+		go func(i int, d *Avail) {
+			defer wg.Done()
+
+			var err error
+			if states[i], err = d.bootstrap(); err != nil {
+				t.Error(err)
+			}
+		}(i, d)
+	}
+
+	assert.Eventually(t, func() bool { return fake.Pending() == len(candidates) }, 5*time.Second, 10*time.Millisecond)
+	fake.Produce()
+	wg.Wait()
+
+	// Exactly one bootstraps; the other follows its chain.
+	var winner *Avail
+
+	for i, d := range candidates {
+		switch states[i] {
+		case BootstrapDone:
+			if assert.Nil(t, winner, "more than one candidate bootstrapped the chain") {
+				winner = d
+			}
+
+			assert.Equal(t, uint64(1), d.blockchain.Header().Number)
+
+		case BootstrapFollowing:
+			assert.Equal(t, uint64(0), d.blockchain.Header().Number)
+
+		default:
+			t.Errorf("unexpected bootstrap state %q", states[i])
+		}
+	}
+
+	if !assert.NotNil(t, winner, "no candidate bootstrapped the chain") {
+		return
+	}
+
+	blks, err := fake.EdgeBlocks()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if assert.Len(t, blks, len(candidates)) {
+		assert.Equal(t, winner.blockchain.Header().Hash, blks[0].Hash())
+	}
+
+	// A candidate started later finds the chain bootstrapped.
+	state, err := late.bootstrap()
+	assert.NoError(t, err)
+	assert.Equal(t, BootstrapFollowing, state)
+	assert.Equal(t, 0, fake.Pending())
+}
+
+func TestBootstrapSequencerNotAllowed(t *testing.T) {
+	appID := avail_types.NewUCompactFromUInt(1)
+	fake := testutil.NewFake(appID)
+
+	// Only the first of the candidates is on the allowlist.
+	d := newTestBootstrapCandidates(t, fake, appID, 2)[1]
+	d.bootstrapAccounts = d.bootstrapAccounts[:1]
+
+	state, err := d.bootstrap()
+	assert.NoError(t, err)
+	assert.Equal(t, BootstrapNotAllowed, state)
+	assert.Equal(t, uint64(0), fake.Head())
+}
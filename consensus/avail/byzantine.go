@@ -0,0 +1,47 @@
+package avail
+
+// FraudClass is a class of the invalid blocks a byzantine sequencer produces,
+// see ByzantinePolicy.
+type FraudClass string
+
+const (
+	// FraudNone is an honest block.
+	FraudNone FraudClass = ""
+
+	// FraudWrongStateRoot is a block sealed with a state root other than the
+	// one of the execution of its transactions: the one of its parent.
+	FraudWrongStateRoot FraudClass = "wrong_state_root"
+
+	// FraudBadSignature is a block with a transaction sent from the sequencer,
+	// but signed by another key.
+	FraudBadSignature FraudClass = "bad_signature"
+
+	// FraudCensoredDisputeTx is a block leaving the dispute resolution
+	// transactions out, the ones in the txpool and the ones ending the
+	// disputes due.
+	FraudCensoredDisputeTx FraudClass = "censored_dispute_tx"
+
+	// FraudFutureTimestamp is a block stamped further ahead of the clock than
+	// the timestamp drift the nodes allow.
+	FraudFutureTimestamp FraudClass = "future_timestamp"
+)
+
+// ByzantinePolicy instructs a byzantine sequencer which of its blocks to make
+// invalid, and how; the sequencer behaves normally otherwise. It's meant for
+// testing the fraud pipeline end to end, from the detection to the slash, and
+// takes effect in the builds with the byzantine tag only: elsewhere, the node
+// configured with one fails to start.
+type ByzantinePolicy interface {
+	// Fraud returns the fraud class of the block of the given number, or
+	// FraudNone for an honest block.
+	Fraud(number uint64) FraudClass
+}
+
+// ByzantineSchedule is the ByzantinePolicy of the fraud classes of the blocks,
+// by number; the blocks left out are honest.
+type ByzantineSchedule map[uint64]FraudClass
+
+// Fraud returns the fraud class scheduled for the block of the given number.
+func (s ByzantineSchedule) Fraud(number uint64) FraudClass {
+	return s[number]
+}
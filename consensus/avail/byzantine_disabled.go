@@ -0,0 +1,33 @@
+//go:build !byzantine
+
+package avail
+
+import (
+	"errors"
+	"time"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/hashicorp/go-hclog"
+)
+
+// errByzantineBuild is the error of a byzantine policy configured in a build
+// without the byzantine tag.
+var errByzantineBuild = errors.New("byzantine policy configured in a build without the byzantine tag")
+
+// byzantineSequencer is left out of the builds without the byzantine tag: no
+// sequencer turns byzantine in them, whatever its config.
+type byzantineSequencer struct{}
+
+func newByzantineSequencer(ByzantinePolicy, hclog.Logger) (*byzantineSequencer, error) {
+	return nil, errByzantineBuild
+}
+
+func (*byzantineSequencer) stamp(*types.Header, time.Time) {}
+
+func (*byzantineSequencer) censors(uint64) bool { return false }
+
+func (*byzantineSequencer) forge(uint64, types.Address, uint64, uint64, transitionInterface) []*types.Transaction {
+	return nil
+}
+
+func (*byzantineSequencer) corruptRoot(_ uint64, _, root types.Hash) types.Hash { return root }
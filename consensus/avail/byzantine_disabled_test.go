@@ -0,0 +1,16 @@
+//go:build !byzantine
+
+package avail
+
+import (
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestByzantinePolicyRequiresBuildTag(t *testing.T) {
+	b, err := newByzantineSequencer(ByzantineSchedule{1: FraudWrongStateRoot}, hclog.NewNullLogger())
+	assert.ErrorIs(t, err, errByzantineBuild)
+	assert.Nil(t, b)
+}
@@ -0,0 +1,114 @@
+//go:build byzantine
+
+package avail
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/0xPolygon/polygon-edge/crypto"
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/pkg/block"
+	"github.com/hashicorp/go-hclog"
+)
+
+// byzantineTimestampLead is how far ahead of the clock the blocks of
+// FraudFutureTimestamp are stamped, past the drift the nodes allow by default.
+const byzantineTimestampLead = 2 * block.DefaultMaxTimestampDrift
+
+// byzantineSequencer has the sequencer make invalid the blocks its policy
+// instructs to; it's compiled in the builds with the byzantine tag only. The
+// nil byzantineSequencer is honest.
+type byzantineSequencer struct {
+	policy ByzantinePolicy
+	logger hclog.Logger
+}
+
+func newByzantineSequencer(policy ByzantinePolicy, logger hclog.Logger) (*byzantineSequencer, error) {
+	return &byzantineSequencer{policy: policy, logger: logger.Named("byzantine")}, nil
+}
+
+// fraud returns the fraud class of the block of the given number.
+func (b *byzantineSequencer) fraud(number uint64) FraudClass {
+	if b == nil {
+		return FraudNone
+	}
+
+	return b.policy.Fraud(number)
+}
+
+// stamp stamps the header byzantineTimestampLead ahead of the clock, for
+// FraudFutureTimestamp.
+func (b *byzantineSequencer) stamp(header *types.Header, now time.Time) {
+	if b.fraud(header.Number) != FraudFutureTimestamp {
+		return
+	}
+
+	header.Timestamp = uint64(now.Add(byzantineTimestampLead).Unix())
+	b.logger.Warn("stamping the block in the future", "block_number", header.Number, "timestamp", header.Timestamp)
+}
+
+// censors reports whether the dispute resolution transactions are left out of
+// the block of the given number, for FraudCensoredDisputeTx.
+func (b *byzantineSequencer) censors(number uint64) bool {
+	return b.fraud(number) == FraudCensoredDisputeTx
+}
+
+// forge writes a transfer sent from the sequencer, with the given nonce, but
+// signed by a throwaway key to the block of the given number, for
+// FraudBadSignature. It returns the transaction written, if any.
+func (b *byzantineSequencer) forge(number uint64, from types.Address, nonce, baseFee uint64, transition transitionInterface) []*types.Transaction {
+	if b.fraud(number) != FraudBadSignature {
+		return nil
+	}
+
+	key, err := crypto.GenerateECDSAKey()
+	if err != nil {
+		b.logger.Error("failed to generate the key to forge the transaction with", "error", err)
+		return nil
+	}
+
+	gasPrice := new(big.Int).SetUint64(baseFee)
+	if gasPrice.Sign() == 0 {
+		gasPrice.SetUint64(1)
+	}
+
+	tx, err := (&crypto.FrontierSigner{}).SignTx(&types.Transaction{
+		From:     from,
+		Nonce:    nonce,
+		To:       &from,
+		Value:    big.NewInt(0),
+		Gas:      21_000,
+		GasPrice: gasPrice,
+	}, key)
+	if err != nil {
+		b.logger.Error("failed to sign the forged transaction", "error", err)
+		return nil
+	}
+
+	tx.ComputeHash()
+
+	if err := transition.Write(tx); err != nil {
+		b.logger.Error("failed to write the forged transaction", "hash", tx.Hash, "error", err)
+		return nil
+	}
+
+	b.logger.Warn("forged a transaction of the sequencer", "block_number", number, "hash", tx.Hash)
+
+	return []*types.Transaction{tx}
+}
+
+// corruptRoot returns the state root to seal the block of the given number
+// with, out of the ones of its parent and its execution: the one of its parent
+// for FraudWrongStateRoot, as if none of its transactions were executed. The
+// state it points at exists, for the nodes taking the block unverified to go
+// on reading it.
+func (b *byzantineSequencer) corruptRoot(number uint64, parent, root types.Hash) types.Hash {
+	if b.fraud(number) != FraudWrongStateRoot {
+		return root
+	}
+
+	b.logger.Warn("sealing the block with the state root of its parent", "block_number", number, "state_root", parent)
+
+	return parent
+}
@@ -0,0 +1,270 @@
+//go:build byzantine
+
+package avail
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/consensus/avail/validator"
+	"github.com/availproject/op-evm/consensus/avail/watchtower"
+	"github.com/availproject/op-evm/pkg/avail/testutil"
+	"github.com/availproject/op-evm/pkg/block"
+	"github.com/availproject/op-evm/pkg/common"
+	"github.com/availproject/op-evm/pkg/staking"
+	"github.com/availproject/op-evm/pkg/test"
+	avail_types "github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	eth_common "github.com/ethereum/go-ethereum/common"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+// byzantineNetwork is an honest sequencer, a staked byzantine one writing its
+// blocks to the same chain, and a watchtower checking them. The tests set the
+// policy of the byzantine sequencer once the chain is set up.
+type byzantineNetwork struct {
+	sw            *SequencerWorker
+	fraudResolver *Fraud
+	apq           staking.ActiveParticipants
+	byzantine     *byzantineSequencer
+	addr          types.Address
+	key           *keystore.Key
+	stake         *big.Int
+	watchtower    watchtower.WatchTower
+}
+
+func newByzantineNetwork(t *testing.T) *byzantineNetwork {
+	t.Helper()
+
+	d, apq := NewTestAvail(t, Sequencer)
+	sw, fraudResolver, _ := newTestSequencerWorkerOf(t, d, testutil.NewFake(avail_types.NewUCompactFromUInt(1)))
+
+	byzantine, err := newByzantineSequencer(ByzantineSchedule{}, hclog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addr, key := test.NewAccount(t)
+	watchtowerAddr, watchtowerKey := test.NewAccount(t)
+
+	for _, a := range []types.Address{addr, watchtowerAddr} {
+		test.DepositBalance(t, a, big.NewInt(0).Mul(big.NewInt(1000), common.ETH), sw.blockchain, sw.executor)
+	}
+
+	// The honest sequencer, the byzantine one and the watchtower stake.
+	sender := staking.NewTestAvailSender()
+	stake := big.NewInt(0).Mul(big.NewInt(10), common.ETH)
+
+	if err := staking.Stake(sw.blockchain, sw.executor, sender, hclog.Default(), string(staking.Sequencer), sw.nodeAddr, sw.nodeSignKey, stake, 1_000_000, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := staking.Stake(sw.blockchain, sw.executor, sender, hclog.Default(), string(staking.Sequencer), addr, key, stake, 1_000_000, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := staking.Stake(sw.blockchain, sw.executor, sender, hclog.Default(), string(staking.WatchTower), watchtowerAddr, watchtowerKey, stake, 1_000_000, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	wt := watchtower.New(sw.blockchain, sw.executor, sw.txpool, hclog.Default(), watchtowerAddr, watchtowerKey, 0)
+
+	// The honest fraud resolver re-executes the accused blocks itself.
+	fraudResolver.watchtower = wt
+
+	return &byzantineNetwork{
+		sw:            sw,
+		fraudResolver: fraudResolver,
+		apq:           apq,
+		byzantine:     byzantine,
+		addr:          addr,
+		key:           &keystore.Key{PrivateKey: key},
+		stake:         stake,
+		watchtower:    wt,
+	}
+}
+
+// writeByzantineBlock has the byzantine sequencer write its next block, and
+// returns it.
+func (n *byzantineNetwork) writeByzantineBlock(t *testing.T) *types.Block {
+	t.Helper()
+
+	n.sw.byzantine = n.byzantine
+	defer func() { n.sw.byzantine = nil }()
+
+	if err := n.sw.writeBlock(n.fraudResolver, accounts.Account{Address: eth_common.Address(n.addr)}, n.key); err != nil {
+		t.Fatal(err)
+	}
+
+	blk, _ := n.sw.blockchain.GetBlockByNumber(n.sw.blockchain.Header().Number, true)
+	assert.Equal(t, n.addr, types.BytesToAddress(blk.Header.Miner))
+
+	return blk
+}
+
+// addTransfers adds the given number of transfers of a new user to the txpool.
+func (n *byzantineNetwork) addTransfers(t *testing.T, count int) {
+	t.Helper()
+
+	user := newTestSender(t, n.sw)
+
+	for i := 0; i < count; i++ {
+		tx := user.transfer(t, 1)
+		if err := n.sw.txpool.AddTx(tx); err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Eventually(t, func() bool { return pooledTxs(n.sw)[tx.Hash] }, 5*time.Second, 10*time.Millisecond)
+	}
+}
+
+// prove has the watchtower prove the given block fraudulent, and returns the
+// fraud proof.
+func (n *byzantineNetwork) prove(t *testing.T, fraudulent *types.Block) *types.Block {
+	t.Helper()
+
+	assert.Error(t, n.watchtower.Check(fraudulent))
+
+	fraudProof, err := n.watchtower.ConstructFraudproof(fraudulent)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The dispute resolution block takes the transaction beginning the
+	// dispute from the txpool.
+	assert.Eventually(t, func() bool {
+		promoted, _ := n.sw.txpool.GetTxs(false)
+		return len(promoted[types.BytesToAddress(fraudProof.Header.Miner)]) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	return fraudProof
+}
+
+// slash has the honest sequencer resolve the dispute of the given fraud proof.
+// It asserts the byzantine sequencer is slashed and the fraudulent block rolled
+// back.
+func (n *byzantineNetwork) slash(t *testing.T, fraudulent, fraudProof *types.Block) {
+	t.Helper()
+
+	n.fraudResolver.SetBlock(fraudProof)
+	n.fraudResolver.SetChainStatus(ChainProcessingDisabled)
+
+	slashed, err := n.fraudResolver.CheckAndSlash()
+	if !assert.NoError(t, err) || !assert.True(t, slashed) {
+		return
+	}
+
+	// The state of the fraudulent block is unknown; the stake is read past
+	// the roll back.
+	stake, err := n.apq.GetBalance(n.addr)
+	assert.NoError(t, err)
+	assert.Equal(t, -1, stake.Cmp(n.stake), "byzantine sequencer not slashed")
+
+	canonical, ok := n.sw.blockchain.GetHeaderByNumber(fraudulent.Number())
+	if assert.True(t, ok) {
+		assert.NotEqual(t, fraudulent.Hash(), canonical.Hash, "fraudulent block still canonical")
+	}
+
+	assert.False(t, n.fraudResolver.IsChainDisabled())
+}
+
+func TestByzantineWrongStateRoot(t *testing.T) {
+	n := newByzantineNetwork(t)
+
+	n.addTransfers(t, 2)
+
+	number := n.sw.blockchain.Header().Number + 1
+	n.byzantine.policy = ByzantineSchedule{number: FraudWrongStateRoot}
+
+	fraudulent := n.writeByzantineBlock(t)
+	assert.Len(t, fraudulent.Transactions, 2)
+
+	n.slash(t, fraudulent, n.prove(t, fraudulent))
+}
+
+func TestByzantineBadSignature(t *testing.T) {
+	n := newByzantineNetwork(t)
+
+	number := n.sw.blockchain.Header().Number + 1
+	n.byzantine.policy = ByzantineSchedule{number: FraudBadSignature}
+
+	fraudulent := n.writeByzantineBlock(t)
+	if !assert.Len(t, fraudulent.Transactions, 1) {
+		return
+	}
+
+	assert.Equal(t, n.addr, fraudulent.Transactions[0].From)
+	assert.ErrorIs(t, n.watchtower.Check(fraudulent), block.ErrInvalidTxSignature)
+
+	fraudProof := n.prove(t, fraudulent)
+
+	violation, txIndex, ok := block.GetExtraDataFraudProofViolation(fraudProof.Header)
+	assert.True(t, ok)
+	assert.Equal(t, block.FraudViolationTxSignature, violation)
+	assert.Zero(t, txIndex)
+
+	n.slash(t, fraudulent, fraudProof)
+}
+
+func TestByzantineCensoredDisputeTx(t *testing.T) {
+	n := newByzantineNetwork(t)
+
+	user := newTestSender(t, n.sw)
+	n.addTransfers(t, 1)
+
+	// The byzantine sequencer seals a wrong state root, then censors the
+	// dispute the watchtower raises against it.
+	number := n.sw.blockchain.Header().Number + 1
+	n.byzantine.policy = ByzantineSchedule{number: FraudWrongStateRoot, number + 1: FraudCensoredDisputeTx}
+
+	fraudulent := n.writeByzantineBlock(t)
+
+	fraudProof := n.prove(t, fraudulent)
+
+	begin := n.sw.poolIndex.txs(types.BytesToAddress(fraudProof.Header.Miner))[0]
+
+	transfer := user.transfer(t, 1)
+	if err := n.sw.txpool.AddTx(transfer); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Eventually(t, func() bool { return pooledTxs(n.sw)[transfer.Hash] }, 5*time.Second, 10*time.Millisecond)
+
+	censoring := n.writeByzantineBlock(t)
+
+	included := make(map[types.Hash]bool, len(censoring.Transactions))
+	for _, tx := range censoring.Transactions {
+		included[tx.Hash] = true
+	}
+
+	assert.True(t, included[transfer.Hash], "user transaction left out")
+	assert.False(t, included[begin.Hash], "dispute transaction included")
+	assert.True(t, pooledTxs(n.sw)[begin.Hash], "dispute transaction dropped")
+
+	n.slash(t, fraudulent, fraudProof)
+
+	// The censoring block builds on the fraudulent one, and goes with it.
+	if canonical, ok := n.sw.blockchain.GetHeaderByNumber(censoring.Number()); ok {
+		assert.NotEqual(t, censoring.Hash(), canonical.Hash, "censoring block still canonical")
+	}
+}
+
+func TestByzantineFutureTimestamp(t *testing.T) {
+	n := newByzantineNetwork(t)
+
+	parent := n.sw.blockchain.Header()
+	n.byzantine.policy = ByzantineSchedule{parent.Number + 1: FraudFutureTimestamp}
+
+	fraudulent := n.writeByzantineBlock(t)
+	assert.Greater(t, fraudulent.Header.Timestamp, uint64(time.Now().Add(block.DefaultMaxTimestampDrift).Unix()))
+
+	// The honest nodes refuse the block received from Avail, and the
+	// watchtower flags it; it never makes their chains to be disputed.
+	v := validator.New(n.sw.blockchain, n.sw.nodeAddr, n.sw.logger)
+	assert.ErrorIs(t, v.Check(fraudulent), block.ErrTimestampTooFarAhead)
+	assert.ErrorIs(t, n.watchtower.Check(fraudulent), block.ErrTimestampTooFarAhead)
+}
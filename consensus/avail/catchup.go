@@ -0,0 +1,181 @@
+package avail
+
+import (
+	"context"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/consensus/avail/validator"
+	"github.com/availproject/op-evm/pkg/avail"
+	avail_types "github.com/centrifuge/go-substrate-rpc-client/v4/types"
+)
+
+// CatchUpConfig configures the catch-up of a sequencer far behind the Avail head.
+type CatchUpConfig struct {
+	// Threshold is the number of Avail blocks the node may lag behind the
+	// head and still follow the live blocks; past it, the node catches up
+	// first, without producing blocks or checking them for frauds, until
+	// within the threshold of the head.
+	Threshold uint64
+
+	// PageSize is the number of Avail blocks fetched and applied at once
+	// while catching up.
+	PageSize uint64
+
+	// Workers is the number of the Avail blocks decoded, and the signers of
+	// their edge blocks recovered, concurrently while catching up, ahead of
+	// writing the edge blocks in order.
+	Workers uint64
+
+	// PipelineDepth is the number of the pages of Avail blocks fetched
+	// ahead, concurrently, while syncing the Avail history on the start; 0
+	// syncs it block by block off the block stream.
+	PipelineDepth uint64
+}
+
+// DefaultCatchUpConfig returns the default CatchUpConfig.
+func DefaultCatchUpConfig() CatchUpConfig {
+	return CatchUpConfig{
+		Threshold: DefaultCatchUpThreshold,
+		PageSize:  DefaultCatchUpPageSize,
+		Workers:   DefaultCatchUpWorkers,
+
+		PipelineDepth: DefaultSyncPipelineDepth,
+	}
+}
+
+// catchUpWithAvail brings the node far behind the Avail head up to its tip,
+// starting from the Avail block at the cursor. The Avail blocks are fetched in
+// pages and the edge blocks in them are applied to the local chain a page at
+// a time, with the txpool reset once at the end; the block production and the
+// fraud checks wait for the live blocks. It returns the Avail block to follow
+// the live blocks from, once within the catch-up threshold of the head; a
+// storage failure writing a block aborts the catch-up.
+func (sw *SequencerWorker) catchUpWithAvail(decoder *avail.BlockDecoder, validator validator.Validator, fraudResolver *Fraud, cursor uint64) (uint64, error) {
+	defer func() {
+		if sw.progress.catchingUp.Swap(false) {
+			sw.progress.live(sw.blockchain.Header().Number)
+		}
+	}()
+
+	for {
+		hdr, err := sw.availClient.GetLatestHeader(sw.ctx)
+		if err != nil {
+			return cursor, err
+		}
+
+		head := uint64(hdr.Number)
+
+		sw.progress.cursor.Store(cursor)
+		sw.progress.head.Store(head)
+
+		if cursor > head || head-cursor < sw.catchUp.Threshold {
+			break
+		}
+
+		if !sw.progress.catchingUp.Swap(true) {
+			sw.logger.Info("far behind the Avail head; catching up", "avail_cursor", cursor, "avail_head", head)
+			sw.progress.begin(SyncStageCatchUp, cursor-1, head, sw.blockchain.Header().Number)
+		}
+
+		sw.progress.target(head)
+
+		to := cursor + sw.catchUp.PageSize - 1
+		if to > head {
+			to = head
+		}
+
+		blks, err := sw.availClient.Query(sw.ctx, cursor, to)
+		if err != nil {
+			return cursor, err
+		}
+
+		if next, err := sw.applyAvailBlocks(decoder, validator, fraudResolver, blks, cursor); err != nil {
+			return next, err
+		}
+
+		cursor = to + 1
+		sw.progress.advance(to, sw.blockchain.Header().Number)
+		sw.progress.seen(to)
+
+		sw.logger.Info("catching up with Avail", "avail_cursor", cursor, "avail_head", head, "block_number", sw.blockchain.Header().Number)
+	}
+
+	if sw.progress.CatchingUp() {
+		// Clear out the executed transactions from the TxPool, once for all
+		// the blocks written.
+		sw.txpool.ResetWithHeaders(sw.blockchain.Header())
+		sw.logger.Info("caught up with Avail", "avail_cursor", cursor, "block_number", sw.blockchain.Header().Number)
+	}
+
+	return cursor, nil
+}
+
+// applyAvailBlocks applies the page of the Avail blocks, from the one at the
+// cursor on, to the local chain. The Avail blocks are decoded, and the signers
+// of their Edge blocks recovered, ahead on the catch-up workers, while the
+// Edge blocks are checked and written strictly in the order of the Avail
+// blocks. On a storage failure writing a block, or the node shutting down, it
+// returns the error with the cursor of the Avail block to pick up from.
+func (sw *SequencerWorker) applyAvailBlocks(decoder *avail.BlockDecoder, validator validator.Validator, fraudResolver *Fraud, blks []*avail_types.SignedBlock, cursor uint64) (uint64, error) {
+	ctx, cancel := context.WithCancel(sw.ctx)
+	defer cancel()
+
+	for p := range sw.prepareAvailBlocks(ctx, decoder, blks, int(sw.catchUp.Workers)) {
+		availBlk := p.SignedBlock
+
+		edgeBlks, err := p.edgeBlocks(decoder)
+		if len(edgeBlks) == 0 && err != nil && err != avail.ErrNoExtrinsicFound {
+			sw.logger.Warn("unexpected error while extracting OpEVM blocks from Avail block", "block_number", availBlk.Block.Header.Number, "error", err)
+		}
+
+		// Keep up with the leader schedule for when the production resumes.
+		sw.advanceActiveSet(uint64(availBlk.Block.Header.Number))
+		sw.leaders.Observe(uint64(availBlk.Block.Header.Number), len(edgeBlks) > 0)
+
+		// The fork choice prefers the blocks of the scheduled leader.
+		var leader types.Address
+		if len(edgeBlks) > 0 {
+			leader = sw.scheduledLeader()
+		}
+
+		for _, decoded := range edgeBlks {
+			edgeBlk := decoded.Block
+
+			// The fraud proofs and the defenses are left out, the same as
+			// when syncing on the start; the known blocks only go to the
+			// fork choice.
+			if fraudResolver.IsFraudProofBlock(edgeBlk) || fraudResolver.IsDisputeDefenseBlock(edgeBlk) {
+				continue
+			}
+
+			if _, known := sw.blockchain.GetHeaderByHash(edgeBlk.Header.Hash); !known {
+				if err := validator.Check(edgeBlk); err != nil {
+					sw.logger.Warn(
+						"failed to validate edge block received from avail",
+						"edge_block_hash", edgeBlk.Hash(),
+						"extrinsic_index", decoded.ExtrinsicIndex,
+						"submitter", decoded.Submitter.ToHexString(),
+						"error", err,
+					)
+
+					continue
+				}
+			}
+
+			if _, err := writeAvailBlock(sw.forkChoice, sw.breaker, decoded, inclusionOf(availBlk, decoded, leader), sw.nodeType.String(), sw.logger); err != nil {
+				return uint64(availBlk.Block.Header.Number), err
+			}
+
+			sw.settleAvailBlock(edgeBlk)
+			stageActiveSet(sw.activeSet, sw.blockchain, edgeBlk, uint64(availBlk.Block.Header.Number), sw.logger)
+		}
+
+		sw.breaker.observe(uint64(availBlk.Block.Header.Number), edgeBlks)
+		sw.forkChoice.settle(uint64(availBlk.Block.Header.Number))
+
+		cursor = uint64(availBlk.Block.Header.Number) + 1
+	}
+
+	// The blocks stop short of the page only with the node shutting down.
+	return cursor, sw.ctx.Err()
+}
@@ -0,0 +1,202 @@
+package avail
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"testing"
+	"time"
+
+	"github.com/0xPolygon/polygon-edge/blockchain/storage"
+	"github.com/0xPolygon/polygon-edge/chain"
+	itrie "github.com/0xPolygon/polygon-edge/state/immutable-trie"
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/consensus/avail/validator"
+	"github.com/availproject/op-evm/pkg/avail"
+	"github.com/availproject/op-evm/pkg/avail/testutil"
+	"github.com/availproject/op-evm/pkg/staking"
+	"github.com/availproject/op-evm/pkg/test"
+	avail_types "github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestGenesisAvail returns the consensus of a sequencer, with an account of
+// its own, on a chain holding the genesis block only.
+func newTestGenesisAvail(t testing.TB) *Avail {
+	t.Helper()
+
+	chain, err := test.NewChain(getGenesisBasePath())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addr, key := test.NewAccount(t)
+
+	return newTestGenesisAvailOf(t, chain, addr, key)
+}
+
+// newTestGenesisAvailOf returns the consensus of a sequencer with the given
+// account on the chain of the given genesis.
+func newTestGenesisAvailOf(t testing.TB, chain *chain.Chain, addr types.Address, key *ecdsa.PrivateKey) *Avail {
+	t.Helper()
+
+	db, err := test.NewMemoryStorage()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return newTestGenesisAvailOn(t, chain, db, addr, key)
+}
+
+// newTestGenesisAvailOn is newTestGenesisAvailOf with the chain on the given
+// storage.
+func newTestGenesisAvailOn(t testing.TB, chain *chain.Chain, db storage.Storage, addr types.Address, key *ecdsa.PrivateKey) *Avail {
+	t.Helper()
+
+	return newTestGenesisAvailOnState(t, chain, db, itrie.NewMemoryStorage(), addr, key)
+}
+
+// newTestGenesisAvailOnState is newTestGenesisAvailOn with the state on the
+// given state storage.
+func newTestGenesisAvailOnState(t testing.TB, chain *chain.Chain, db storage.Storage, stateStorage itrie.Storage, addr types.Address, key *ecdsa.PrivateKey) *Avail {
+	t.Helper()
+
+	executor, blockchain, txpool, err := test.NewBlockchainWithTxPoolOnState(chain, staking.NewVerifier(new(staking.DumbActiveParticipants), hclog.Default()), db, stateStorage)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	asq := staking.NewActiveParticipantsQuerier(blockchain, executor, hclog.Default())
+	blockchain.SetConsensus(staking.NewVerifier(asq, hclog.Default()))
+
+	return &Avail{
+		ctx:          context.Background(),
+		logger:       hclog.Default(),
+		notifyCh:     make(chan struct{}),
+		closeCh:      make(chan struct{}),
+		blockchain:   blockchain,
+		executor:     executor,
+		stateStorage: stateStorage,
+		txpool:       txpool,
+		poolIndex:    newTestTxPoolIndex(t, txpool),
+		blockTime:    time.Second,
+		nodeType:     Sequencer,
+		signKey:      key,
+		minerAddr:    addr,
+		forkChoice:   newForkChoice(blockchain, addr, DefaultMaxReorgDepth, newSettledHead(blockchain, NewChallengeWindow(DefaultChallengeWindow), hclog.Default()), nil, hclog.Default()),
+		breaker:      newCircuitBreaker(DefaultCircuitBreakerConfig(), "", systemClock{}, hclog.Default()),
+		keys:         newKeyRotation(key, nil, 0, hclog.Default()),
+		phases:       newTestPhaseMachine(),
+	}
+}
+
+// queryHookClient is the fake Avail calling the hook before every query.
+type queryHookClient struct {
+	*testutil.Fake
+	hook func()
+}
+
+func (c *queryHookClient) Query(ctx context.Context, from, to uint64) ([]*avail_types.SignedBlock, error) {
+	c.hook()
+	return c.Fake.Query(ctx, from, to)
+}
+
+func TestSequencerCatchUp(t *testing.T) {
+	appID := avail_types.NewUCompactFromUInt(1)
+	fake := testutil.NewFake(appID)
+
+	// The sequencer is offline from the genesis on, while the leader produces.
+	leader, leaderFraudResolver, _ := newTestSequencerWorkerOf(t, newTestGenesisAvail(t), fake)
+	account := accounts.Account{Address: common.Address(leader.nodeAddr)}
+
+	d := newTestGenesisAvail(t)
+
+	// The backlog of 500 Avail blocks, with a block of the leader in every tenth.
+	const backlog = 500
+	for h := 0; h < backlog; h++ {
+		if h%10 != 0 {
+			fake.Produce()
+			continue
+		}
+
+		if err := leader.writeBlock(leaderFraudResolver, account, &keystore.Key{PrivateKey: leader.nodeSignKey}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tip := leader.blockchain.Header()
+	assert.Equal(t, uint64(backlog/10), tip.Number)
+	assert.Equal(t, uint64(backlog), fake.Head())
+
+	sw, fraudResolver, _ := newTestSequencerWorkerOf(t, d, fake)
+	sw.availAppID = appID
+	sw.catchUp = CatchUpConfig{Threshold: availBlockWindowLen, PageSize: 20}
+	d.progress = sw.progress
+
+	clock, stop := startWriteBlocksLoop(t, sw, fraudResolver)
+	defer stop()
+
+	// The slots pass while catching up; the production resumes only on top of the tip.
+	pages := 0
+	sw.availClient = &queryHookClient{Fake: fake, hook: func() {
+		pages++
+
+		clock.tick()
+		clock.tick()
+
+		assert.NotEqual(t, sw.nodeAddr, types.BytesToAddress(sw.blockchain.Header().Miner))
+
+		status, err := NewStatusAPI(d).GetNodeStatus()
+		if assert.NoError(t, err) {
+			assert.True(t, status.CatchingUp)
+			assert.Equal(t, uint64(backlog), status.AvailHead)
+
+			if assert.NotNil(t, status.Sync) {
+				assert.Equal(t, SyncStageCatchUp, status.Sync.Stage)
+				assert.Equal(t, hexutil.Uint64(backlog), status.Sync.HighestAvailBlock)
+			}
+		}
+	}}
+
+	decoder := avail.NewBlockDecoder(sw.availClient, appID, sw.logger)
+
+	cursor, err := sw.catchUpWithAvail(decoder, validator.New(sw.blockchain, sw.nodeAddr, sw.logger), fraudResolver, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, backlog/20, pages)
+	assert.LessOrEqual(t, fake.Head()+1-cursor, uint64(availBlockWindowLen))
+	assert.False(t, sw.progress.CatchingUp())
+	assert.Nil(t, sw.progress.Status())
+	assert.Equal(t, tip.Hash, sw.blockchain.Header().Hash)
+
+	clock.tick()
+	waitForBlock(t, sw, tip.Number+1)
+
+	assert.Equal(t, sw.nodeAddr, types.BytesToAddress(sw.blockchain.Header().Miner))
+}
+
+func TestSequencerCatchUpWithinThreshold(t *testing.T) {
+	appID := avail_types.NewUCompactFromUInt(1)
+	fake := testutil.NewFake(appID)
+
+	for h := 0; h < availBlockWindowLen-1; h++ {
+		fake.Produce()
+	}
+
+	sw, fraudResolver, _ := newTestSequencerWorker(t, fake)
+	sw.catchUp = CatchUpConfig{Threshold: availBlockWindowLen, PageSize: 20}
+	sw.availClient = &queryHookClient{Fake: fake, hook: func() { t.Error("queried Avail within the catch-up threshold") }}
+
+	decoder := avail.NewBlockDecoder(sw.availClient, appID, sw.logger)
+
+	cursor, err := sw.catchUpWithAvail(decoder, validator.New(sw.blockchain, sw.nodeAddr, sw.logger), fraudResolver, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), cursor)
+	assert.False(t, sw.progress.CatchingUp())
+}
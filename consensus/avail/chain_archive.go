@@ -0,0 +1,174 @@
+package avail
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/0xPolygon/polygon-edge/types/buildroot"
+	"github.com/availproject/op-evm/consensus/avail/validator"
+	"github.com/availproject/op-evm/pkg/block"
+	"github.com/availproject/op-evm/pkg/snapshot"
+)
+
+// ChainArchiveRange is the range of the blocks of a chain archive exported or
+// imported, and the hash of the last one; the empty range, of the hash of the
+// zero block, for none imported.
+type ChainArchiveRange struct {
+	From uint64     `json:"from"`
+	To   uint64     `json:"to"`
+	Hash types.Hash `json:"hash"`
+}
+
+// exportChain writes the chain archive of the canonical blocks of the given
+// range to w, along with their receipts; the blocks not executed, as the
+// ones below the trusted height, go without.
+func (d *Avail) exportChain(w io.Writer, from, to uint64) (ChainArchiveRange, error) {
+	if head := d.blockchain.Header().Number; to > head {
+		return ChainArchiveRange{}, fmt.Errorf("block %d past the head at %d", to, head)
+	}
+
+	aw, err := snapshot.NewArchiveWriter(w, d.blockchain.Genesis(), from, to)
+	if err != nil {
+		return ChainArchiveRange{}, err
+	}
+
+	archived := ChainArchiveRange{From: from, To: to}
+
+	for number := from; number <= to; number++ {
+		blk, ok := d.blockchain.GetBlockByNumber(number, true)
+		if !ok {
+			return ChainArchiveRange{}, fmt.Errorf("block %d not found", number)
+		}
+
+		receipts, err := d.blockchain.GetReceiptsByHash(blk.Hash())
+		if err != nil {
+			receipts = nil
+		}
+
+		if err := aw.Write(blk, receipts); err != nil {
+			return ChainArchiveRange{}, err
+		}
+
+		archived.Hash = blk.Hash()
+	}
+
+	d.logger.Info("chain archive exported", "from_block_number", from, "block_number", to, "block_hash", archived.Hash)
+
+	return archived, nil
+}
+
+// importChain reads the chain archive from r, and writes its blocks on top of
+// the head of the node, one at a time, for the chain to stand at the last
+// block written on any failure: the blocks held already are checked to be
+// the ones of the archive, and skipped. The blocks are checked and executed
+// on the normal path of the ones seen on Avail; under trusted, they're
+// checked for their seal and their roots, short of being executed, their
+// receipts taken from the archive and the state at them left unheld. The
+// blocks aren't settled until seen on Avail. It returns the range of the
+// blocks written.
+func (d *Avail) importChain(r io.Reader, trusted bool) (ChainArchiveRange, error) {
+	ar, err := snapshot.NewArchiveReader(r)
+	if err != nil {
+		return ChainArchiveRange{}, err
+	}
+
+	header := ar.Header()
+	if genesis := d.blockchain.Genesis(); header.Genesis != genesis {
+		return ChainArchiveRange{}, fmt.Errorf("chain archive of genesis %s, expected %s", header.Genesis, genesis)
+	}
+
+	if head := d.blockchain.Header().Number; header.From > head+1 {
+		return ChainArchiveRange{}, fmt.Errorf("chain archive from block %d leaves a gap past the head at %d", header.From, head)
+	}
+
+	v := validator.New(d.blockchain, d.minerAddr, d.logger, validator.WithMaxTimestampDrift(d.production.MaxTimestampDrift))
+
+	var imported ChainArchiveRange
+
+	for {
+		blk, receipts, err := ar.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return imported, d.importChainFailed(imported, err)
+		}
+
+		if held, ok := d.blockchain.GetHeaderByNumber(blk.Number()); ok {
+			if held.Hash != blk.Hash() {
+				return imported, d.importChainFailed(imported, fmt.Errorf("block %d (%s) of the archive conflicts with the chain (%s)", blk.Number(), blk.Hash(), held.Hash))
+			}
+
+			continue
+		}
+
+		if trusted {
+			err = d.writeTrustedArchiveBlock(blk, receipts)
+		} else {
+			err = d.writeArchiveBlock(v, blk)
+		}
+
+		if err != nil {
+			return imported, d.importChainFailed(imported, fmt.Errorf("block %d: %w", blk.Number(), err))
+		}
+
+		if imported.Hash == types.ZeroHash {
+			imported.From = blk.Number()
+		}
+
+		imported.To, imported.Hash = blk.Number(), blk.Hash()
+	}
+
+	d.logger.Info("chain archive imported", "from_block_number", imported.From, "block_number", imported.To, "block_hash", imported.Hash, "trusted", trusted)
+
+	return imported, nil
+}
+
+// writeArchiveBlock checks the block of a chain archive as the ones seen on
+// Avail are, and writes it once its execution checks out against its header.
+func (d *Avail) writeArchiveBlock(v validator.Validator, blk *types.Block) error {
+	if err := v.Check(blk); err != nil {
+		return err
+	}
+
+	fblk, err := d.blockchain.VerifyFinalizedBlock(blk)
+	if err != nil {
+		return err
+	}
+
+	return d.blockchain.WriteFullBlock(fblk, block.SourceArchive)
+}
+
+// writeTrustedArchiveBlock checks the block of a chain archive for its seal,
+// its parent and its roots, and writes it along with the receipts of the
+// archive, short of executing it.
+func (d *Avail) writeTrustedArchiveBlock(blk *types.Block, receipts []*types.Receipt) error {
+	if head := d.blockchain.Header(); blk.ParentHash() != head.Hash {
+		return fmt.Errorf("parent %s not the head %s", blk.ParentHash(), head.Hash)
+	}
+
+	if _, err := block.AddressRecoverFromHeader(blk.Header); err != nil {
+		return err
+	}
+
+	if root := buildroot.CalculateTransactionsRoot(blk.Transactions); root != blk.Header.TxRoot {
+		return fmt.Errorf("transactions root %s, expected %s", root, blk.Header.TxRoot)
+	}
+
+	if root := buildroot.CalculateReceiptsRoot(receipts); root != blk.Header.ReceiptsRoot {
+		return fmt.Errorf("receipts root %s, expected %s", root, blk.Header.ReceiptsRoot)
+	}
+
+	return d.blockchain.WriteFullBlock(&types.FullBlock{Block: blk, Receipts: receipts}, block.SourceArchive)
+}
+
+// importChainFailed logs the import of the chain archive failing past the
+// blocks imported, and returns the error.
+func (d *Avail) importChainFailed(imported ChainArchiveRange, err error) error {
+	d.logger.Error("chain archive import failed", "imported_from_block_number", imported.From, "imported_block_number", imported.To, "head", d.blockchain.Header().Number, "error", err)
+
+	return err
+}
@@ -0,0 +1,161 @@
+package avail
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/availproject/op-evm/pkg/avail/testutil"
+	"github.com/availproject/op-evm/pkg/snapshot"
+	avail_types "github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChainArchive(t *testing.T) {
+	const blocks = 100
+
+	appID := avail_types.NewUCompactFromUInt(1)
+	fake := testutil.NewFake(appID)
+
+	producer := newTestGenesisAvail(t)
+	activity := newTestActivity(producer, fake)
+	activity.settle(t, blocks)
+
+	path := filepath.Join(t.TempDir(), "chain.archive")
+
+	archived, err := NewAdminAPI(producer).ExportChain(1, blocks, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	head := producer.blockchain.Header()
+	assert.Equal(t, ChainArchiveRange{From: 1, To: blocks, Hash: head.Hash}, archived)
+
+	// assertImported asserts the blocks of the node are the ones of the
+	// producer up to the given height, receipts included.
+	assertImported := func(d *Avail, to uint64) {
+		t.Helper()
+
+		for number := uint64(1); number <= to; number++ {
+			h, _ := producer.blockchain.GetHeaderByNumber(number)
+			imported, ok := d.blockchain.GetHeaderByNumber(number)
+
+			if !assert.True(t, ok, "block %d", number) || !assert.Equal(t, h.Hash, imported.Hash) {
+				return
+			}
+
+			if number%10 != 1 {
+				continue
+			}
+
+			want, err := producer.blockchain.GetReceiptsByHash(h.Hash)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := d.blockchain.GetReceiptsByHash(h.Hash)
+			if assert.NoError(t, err, "receipts of block %d", number) {
+				assert.NotEmpty(t, got)
+				assert.Equal(t, want, got, "receipts of block %d", number)
+			}
+		}
+	}
+
+	// The fresh node imports the blocks executing them, and comes to the
+	// same head and state.
+	verified := newTestGenesisAvail(t)
+
+	imported, err := NewAdminAPI(verified).ImportChain(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, archived, imported)
+	assert.Equal(t, head.Hash, verified.blockchain.Header().Hash)
+	assert.Equal(t, head.StateRoot, verified.blockchain.Header().StateRoot)
+	assert.Equal(t, activity.storage(t, producer), activity.storage(t, verified))
+	assertImported(verified, blocks)
+
+	// The blocks held already are skipped.
+	imported, err = NewAdminAPI(verified).ImportChain(path, false)
+	assert.NoError(t, err)
+	assert.Equal(t, ChainArchiveRange{}, imported)
+
+	// The trusted import writes the blocks along with the receipts of the
+	// archive, short of executing them.
+	fast := newTestGenesisAvail(t)
+
+	imported, err = NewAdminAPI(fast).ImportChain(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, archived, imported)
+	assert.Equal(t, head.Hash, fast.blockchain.Header().Hash)
+	assertImported(fast, blocks)
+
+	// The archive of another chain is refused.
+	other := newTestGenesisAvail(t)
+	settleTestBlock(t, other, fake, buildTestBlock(t, other))
+
+	_, err = NewAdminAPI(other).ImportChain(path, false)
+	assert.Error(t, err)
+	assert.Equal(t, uint64(1), other.blockchain.Header().Number)
+}
+
+func TestChainArchivePartialImport(t *testing.T) {
+	const blocks = 20
+
+	appID := avail_types.NewUCompactFromUInt(1)
+	fake := testutil.NewFake(appID)
+
+	producer := newTestGenesisAvail(t)
+	newTestActivity(producer, fake).settle(t, blocks)
+
+	var buf bytes.Buffer
+	if _, err := producer.exportChain(&buf, 1, blocks); err != nil {
+		t.Fatal(err)
+	}
+
+	archive := buf.Bytes()
+
+	// The truncated archive leaves the chain at the last block complete.
+	d := newTestGenesisAvail(t)
+
+	imported, err := d.importChain(bytes.NewReader(archive[:len(archive)*2/3]), false)
+	assert.True(t, errors.Is(err, snapshot.ErrArchiveTruncated), "unexpected error: %v", err)
+	assert.Greater(t, imported.To, uint64(1))
+	assert.Less(t, imported.To, uint64(blocks))
+	assert.Equal(t, imported.To, d.blockchain.Header().Number)
+	assert.Equal(t, imported.Hash, d.blockchain.Header().Hash)
+
+	h, _ := producer.blockchain.GetHeaderByNumber(imported.To)
+	assert.Equal(t, h.StateRoot, d.blockchain.Header().StateRoot)
+
+	// The import resumes past the blocks held, and the corrupt record stops
+	// it short of the block.
+	corrupt := append([]byte(nil), archive...)
+	corrupt[len(corrupt)-10] ^= 0xff
+
+	resumed, err := d.importChain(bytes.NewReader(corrupt), false)
+	assert.True(t, errors.Is(err, snapshot.ErrArchiveCorrupt), "unexpected error: %v", err)
+	assert.Equal(t, imported.To+1, resumed.From)
+	assert.Equal(t, uint64(blocks-1), d.blockchain.Header().Number)
+
+	resumed, err = d.importChain(bytes.NewReader(archive), false)
+	assert.NoError(t, err)
+	assert.Equal(t, ChainArchiveRange{From: blocks, To: blocks, Hash: producer.blockchain.Header().Hash}, resumed)
+
+	// The archive of another format version is refused.
+	path := filepath.Join(t.TempDir(), "chain.archive")
+	archive[11] = 0xff
+
+	if err := os.WriteFile(path, archive, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = NewAdminAPI(newTestGenesisAvail(t)).ImportChain(path, false)
+	assert.True(t, errors.Is(err, snapshot.ErrArchiveVersion), "unexpected error: %v", err)
+}
@@ -0,0 +1,141 @@
+package avail
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/pkg/avail"
+)
+
+// startupCheckSource is the source of the rewind of the startup consistency
+// check.
+const startupCheckSource = "startup-check"
+
+// ErrStartupRewindTooDeep is returned by the startup consistency check for
+// the local chain diverging from Avail deeper than the rewind may go; the
+// node refuses to start, for the operator to look into it.
+var ErrStartupRewindTooDeep = errors.New("local chain diverges from Avail past the max startup rewind depth")
+
+// checkChainConsistency checks the local chain against the blocks recorded on
+// Avail over the last challenge window on the start, for the node not to go
+// on from a head diverging from Avail, as the one written short of its
+// submission before a crash, or the one of a fraud reorg missed while
+// offline. The canonical blocks from the head down, absent from the window
+// or conflicting with the ones recorded in it, are rewound down to the last
+// block recorded, the blocks below the window taken for consistent. A rewind
+// deeper than the max depth is refused. It returns the number of the blocks
+// rewound.
+func (d *Avail) checkChainConsistency() (uint64, error) {
+	head := d.blockchain.Header()
+	if head.Number == 0 || d.availClient == nil {
+		return 0, nil
+	}
+
+	recorded, lowest, err := d.recordedAvailBlocks(head.Number)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch the blocks recorded on Avail: %w", err)
+	}
+
+	// Short of any block recorded in the window, there is nothing to check
+	// against.
+	if len(recorded) == 0 {
+		d.logger.Debug("no blocks recorded on Avail over the challenge window; startup consistency check skipped", "block_number", head.Number)
+		return 0, nil
+	}
+
+	settled := d.forkChoice.settled.Number()
+
+	var discarded []*types.Header
+
+	target := head
+
+	for target.Number > 0 && target.Number >= lowest && target.Number > settled && !recorded[target.Hash] {
+		discarded = append(discarded, target)
+
+		parent, ok := d.blockchain.GetHeaderByHash(target.ParentHash)
+		if !ok {
+			return 0, fmt.Errorf("parent %s of block %d not found", target.ParentHash, target.Number)
+		}
+
+		target = parent
+	}
+
+	if len(discarded) == 0 {
+		d.logger.Info("local chain consistent with Avail", "block_number", head.Number, "block_hash", head.Hash)
+		return 0, nil
+	}
+
+	hashes := make([]string, len(discarded))
+	for i, h := range discarded {
+		hashes[i] = h.Hash.String()
+	}
+
+	depth := uint64(len(discarded))
+	if depth > d.maxStartupRewind {
+		d.logger.Error("local chain diverges from Avail past the max startup rewind depth; refusing to start", "block_number", head.Number, "consistent_block_number", target.Number, "depth", depth, "max_depth", d.maxStartupRewind, "diverging_hashes", hashes)
+		return 0, fmt.Errorf("%w: %d blocks down to block %d, at most %d", ErrStartupRewindTooDeep, depth, target.Number, d.maxStartupRewind)
+	}
+
+	if _, err := d.blockchain.Reorg(target, d.maxStartupRewind, startupCheckSource); err != nil {
+		return 0, err
+	}
+
+	observeStartupRewind(depth)
+
+	d.logger.Warn("local chain diverged from Avail; rewound to the last consistent block", "old_block_number", head.Number, "block_number", target.Number, "block_hash", target.Hash, "depth", depth, "discarded_hashes", hashes)
+
+	return depth, nil
+}
+
+// recordedAvailBlocks returns the hashes of the blocks recorded on Avail over
+// the challenge window of the block of the given number, back from the Avail
+// head, and the lowest number of them.
+func (d *Avail) recordedAvailBlocks(number uint64) (map[types.Hash]bool, uint64, error) {
+	hdr, err := d.availClient.GetLatestHeader(d.ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	to := uint64(hdr.Number)
+
+	from := uint64(1)
+	if window := d.forkChoice.settled.window.At(number); to > window {
+		from = to - window + 1
+	}
+
+	if _, err := avail.FindCallIndex(d.ctx, d.availClient); err != nil {
+		return nil, 0, err
+	}
+
+	decoder := avail.NewBlockDecoder(d.availClient, d.availAppID, d.logger)
+
+	recorded := make(map[types.Hash]bool)
+
+	var lowest uint64
+
+	for lo := from; lo <= to; lo += DefaultCatchUpPageSize {
+		hi := lo + DefaultCatchUpPageSize - 1
+		if hi > to {
+			hi = to
+		}
+
+		blks, err := d.availClient.Query(d.ctx, lo, hi)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to query Avail blocks %d to %d: %w", lo, hi, err)
+		}
+
+		for _, blk := range blks {
+			edgeBlks, _ := decoder.Decode(d.ctx, blk)
+			for _, decoded := range edgeBlks {
+				recorded[decoded.Block.Hash()] = true
+
+				if n := decoded.Block.Number(); lowest == 0 || n < lowest {
+					lowest = n
+				}
+			}
+		}
+	}
+
+	return recorded, lowest, nil
+}
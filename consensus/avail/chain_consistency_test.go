@@ -0,0 +1,79 @@
+package avail
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/availproject/op-evm/pkg/avail/testutil"
+	avail_types "github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChainConsistencyRewind(t *testing.T) {
+	const window = 10
+
+	appID := avail_types.NewUCompactFromUInt(1)
+	fake := testutil.NewFake(appID)
+
+	producer := newTestGenesisAvail(t)
+	for i := 0; i < 5; i++ {
+		settleTestBlock(t, producer, fake, buildTestBlock(t, producer))
+	}
+
+	d := newTestSnapshotAvail(t, fake, appID, window)
+	d.maxStartupRewind = 1
+
+	if _, err := syncTestAvail(d, fake); err != nil {
+		t.Fatal(err)
+	}
+
+	consistent := d.blockchain.Header()
+	assert.Equal(t, producer.blockchain.Header().Hash, consistent.Hash)
+
+	// The node consistent with Avail is left as it is.
+	depth, err := d.checkChainConsistency()
+	assert.NoError(t, err)
+	assert.Zero(t, depth)
+
+	// The node writes two blocks short of submitting them, while the chain
+	// goes on on Avail with a block conflicting with the first of them.
+	for i := 0; i < 2; i++ {
+		if err := d.blockchain.WriteBlock(buildTestBlock(t, d), "test"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	settleTestBlock(t, producer, fake, buildTestBlock(t, producer))
+
+	diverged := d.blockchain.Header()
+	assert.Equal(t, consistent.Number+2, diverged.Number)
+
+	// The rewind past the max depth is refused, the chain left as it is.
+	_, err = d.checkChainConsistency()
+	assert.True(t, errors.Is(err, ErrStartupRewindTooDeep), "unexpected error: %v", err)
+	assert.Equal(t, diverged.Hash, d.blockchain.Header().Hash)
+
+	// The chain is rewound to the last block recorded on Avail.
+	d.maxStartupRewind = DefaultMaxStartupRewindDepth
+
+	depth, err = d.checkChainConsistency()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), depth)
+	assert.Equal(t, consistent.Hash, d.blockchain.Header().Hash)
+
+	_, ok := d.blockchain.GetHeaderByNumber(diverged.Number)
+	assert.False(t, ok)
+
+	// The node resumes from it, on to the chain on Avail.
+	settleTestBlock(t, producer, fake, buildTestBlock(t, producer))
+
+	if _, err := syncTestAvail(d, fake); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, producer.blockchain.Header().Hash, d.blockchain.Header().Hash)
+
+	depth, err = d.checkChainConsistency()
+	assert.NoError(t, err)
+	assert.Zero(t, depth)
+}
@@ -0,0 +1,177 @@
+package avail
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/pkg/avail"
+	"github.com/availproject/op-evm/pkg/snapshot"
+)
+
+var (
+	// ErrSnapshotChallengeable is returned for a chain snapshot taken at a
+	// block still within its challenge window: a fraud proof may yet fork it
+	// out, so it's no ground to start a node from.
+	ErrSnapshotChallengeable = errors.New("chain snapshot within its challenge window")
+
+	// errNoStateStorage is returned by the chain snapshots of the node
+	// running without access to its state storage.
+	errNoStateStorage = errors.New("state storage not set up")
+)
+
+// exportChainSnapshot writes the chain snapshot at the settled head to w, and
+// returns the head.
+func (d *Avail) exportChainSnapshot(w io.Writer) (SettledHead, error) {
+	return d.exportChainSnapshotAt(w, d.forkChoice.settled.Head())
+}
+
+// exportChainSnapshotAt writes the chain snapshot at the given settled block
+// to w, and returns it.
+func (d *Avail) exportChainSnapshotAt(w io.Writer, head SettledHead) (SettledHead, error) {
+	if d.stateStorage == nil {
+		return SettledHead{}, errNoStateStorage
+	}
+
+	if head.Number == 0 {
+		return SettledHead{}, fmt.Errorf("no block settled to snapshot")
+	}
+
+	headers := make([]*types.Header, 0, head.Number+1)
+
+	for number := uint64(0); number <= head.Number; number++ {
+		h, ok := d.blockchain.GetHeaderByNumber(number)
+		if !ok {
+			return SettledHead{}, fmt.Errorf("header %d not found", number)
+		}
+
+		headers = append(headers, h)
+	}
+
+	if headers[head.Number].Hash != head.Hash {
+		return SettledHead{}, fmt.Errorf("settled head %s no longer canonical", head.Hash)
+	}
+
+	cs, err := snapshot.NewChainSnapshot(headers, head.AvailBlock, d.stateStorage)
+	if err != nil {
+		return SettledHead{}, err
+	}
+
+	if err := snapshot.WriteChainSnapshot(w, cs); err != nil {
+		return SettledHead{}, err
+	}
+
+	d.logger.Info("chain snapshot exported", "block_number", head.Number, "block_hash", head.Hash, "avail_block_number", head.AvailBlock)
+
+	return head, nil
+}
+
+// importChainSnapshot reads the chain snapshot from r, and has the node start
+// from it: the state and the headers are written, and the block becomes the
+// settled head, for the node to follow Avail on from it. The snapshot is
+// checked against the chain of the node, the headers from the genesis on, the
+// state root of the block and its inclusion in Avail; it's refused unless
+// its challenge window has passed. The blocks of the node, if any, are to be
+// the ones of the snapshot.
+func (d *Avail) importChainSnapshot(r io.Reader) (SettledHead, error) {
+	if d.stateStorage == nil {
+		return SettledHead{}, errNoStateStorage
+	}
+
+	cs, err := snapshot.ReadChainSnapshot(r)
+	if err != nil {
+		return SettledHead{}, fmt.Errorf("failed to decode chain snapshot: %w", err)
+	}
+
+	headers, err := cs.DecodeHeaders()
+	if err != nil {
+		return SettledHead{}, err
+	}
+
+	if genesis := d.blockchain.Genesis(); headers[0].Hash != genesis {
+		return SettledHead{}, fmt.Errorf("chain snapshot of genesis %s, expected %s", headers[0].Hash, genesis)
+	}
+
+	last := headers[len(headers)-1]
+	head := SettledHead{Number: last.Number, Hash: last.Hash, AvailBlock: cs.AvailBlock}
+
+	if err := d.checkSnapshotSettled(head); err != nil {
+		return SettledHead{}, err
+	}
+
+	local := d.blockchain.Header()
+	if local.Number > head.Number || headers[local.Number].Hash != local.Hash {
+		return SettledHead{}, fmt.Errorf("chain snapshot doesn't extend the chain at block %d (%s)", local.Number, local.Hash)
+	}
+
+	if err := cs.ApplyState(last.StateRoot, d.stateStorage); err != nil {
+		return SettledHead{}, err
+	}
+
+	if local.Number < head.Number {
+		if err := d.blockchain.WriteHeaders(headers[local.Number+1:]); err != nil {
+			return SettledHead{}, fmt.Errorf("failed to write the snapshot headers: %w", err)
+		}
+	}
+
+	d.forkChoice.settled.restore(head)
+
+	d.logger.Info("chain snapshot imported", "block_number", head.Number, "block_hash", head.Hash, "avail_block_number", head.AvailBlock)
+
+	return head, nil
+}
+
+// checkSnapshotSettled checks the head of the chain snapshot is included in
+// Avail at the block it's said to be, and its challenge window has passed.
+func (d *Avail) checkSnapshotSettled(head SettledHead) error {
+	latest, err := d.availClient.GetLatestHeader(d.ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get the latest Avail header: %w", err)
+	}
+
+	window := d.forkChoice.settled.window.At(head.Number)
+	if head.AvailBlock+window > uint64(latest.Number) {
+		return fmt.Errorf("%w: block %d included at Avail block %d, window of %d Avail blocks, Avail at %d", ErrSnapshotChallengeable, head.Number, head.AvailBlock, window, latest.Number)
+	}
+
+	_, err = d.availInclusion(head.Hash, head.AvailBlock, head.AvailBlock)
+
+	return err
+}
+
+// availInclusion returns the number of the Avail block, between the given
+// ones, the block of the given hash is included in, looking for it from the
+// latest one back.
+func (d *Avail) availInclusion(hash types.Hash, from, to uint64) (uint64, error) {
+	if from == 0 {
+		from = 1
+	}
+
+	decoder := avail.NewBlockDecoder(d.availClient, d.availAppID, d.logger)
+
+	for hi := to; hi >= from; {
+		lo := from
+		if hi-lo >= DefaultCatchUpPageSize {
+			lo = hi - DefaultCatchUpPageSize + 1
+		}
+
+		blks, err := d.availClient.Query(d.ctx, lo, hi)
+		if err != nil {
+			return 0, fmt.Errorf("failed to query Avail blocks %d to %d: %w", lo, hi, err)
+		}
+
+		for i := len(blks) - 1; i >= 0; i-- {
+			edgeBlks, _ := decoder.Decode(d.ctx, blks[i])
+			for _, decoded := range edgeBlks {
+				if decoded.Block.Hash() == hash {
+					return uint64(blks[i].Block.Header.Number), nil
+				}
+			}
+		}
+
+		hi = lo - 1
+	}
+
+	return 0, fmt.Errorf("block %s not found in Avail blocks %d to %d", hash, from, to)
+}
@@ -0,0 +1,172 @@
+package avail
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/availproject/op-evm/consensus/avail/validator"
+	"github.com/availproject/op-evm/pkg/avail"
+	"github.com/availproject/op-evm/pkg/avail/testutil"
+	"github.com/availproject/op-evm/pkg/snapshot"
+	avail_types "github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestSnapshotAvail returns the consensus of a sequencer on a chain holding
+// the genesis block only, following the fake Avail with the given challenge
+// window.
+func newTestSnapshotAvail(t *testing.T, fake *testutil.Fake, appID avail_types.UCompact, window uint64) *Avail {
+	t.Helper()
+
+	d := newTestGenesisAvail(t)
+	d.availClient = fake
+	d.availAppID = appID
+	d.forkChoice = newForkChoice(d.blockchain, d.minerAddr, DefaultMaxReorgDepth, newSettledHead(d.blockchain, NewChallengeWindow(window), hclog.Default()), nil, hclog.Default())
+
+	return d
+}
+
+// followTestAvail has the sequencer follow Avail up to its head, settling the
+// blocks past their challenge window.
+func followTestAvail(t *testing.T, d *Avail, fake *testutil.Fake) {
+	t.Helper()
+
+	sw, fraudResolver, _ := newTestSequencerWorkerOf(t, d, fake)
+	sw.availClient = fake
+	sw.availAppID = d.availAppID
+	sw.catchUp = CatchUpConfig{Threshold: 0, PageSize: 20}
+
+	cursor := d.forkChoice.availCursor()
+	if cursor == 0 {
+		cursor = 1
+	}
+
+	decoder := avail.NewBlockDecoder(fake, d.availAppID, sw.logger)
+	if _, err := sw.catchUpWithAvail(decoder, validator.New(sw.blockchain, sw.nodeAddr, sw.logger), fraudResolver, cursor); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestChainSnapshotSync(t *testing.T) {
+	const window = 2
+
+	appID := avail_types.NewUCompactFromUInt(1)
+	fake := testutil.NewFake(appID)
+
+	producer := newTestGenesisAvail(t)
+	for i := 0; i < 4; i++ {
+		settleTestBlock(t, producer, fake, buildTestBlock(t, producer))
+	}
+
+	// The source node follows Avail, and exports the snapshot at its settled
+	// head.
+	source := newTestSnapshotAvail(t, fake, appID, window)
+	followTestAvail(t, source, fake)
+
+	settled := source.forkChoice.settled.Head()
+	if !assert.NotZero(t, settled.Number) || !assert.Less(t, settled.Number, producer.blockchain.Header().Number) {
+		return
+	}
+
+	path := filepath.Join(t.TempDir(), "snapshot")
+
+	exported, err := NewAdminAPI(source).ExportSnapshot(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, settled, exported)
+
+	// The chain goes on past the snapshot.
+	for i := 0; i < 2; i++ {
+		settleTestBlock(t, producer, fake, buildTestBlock(t, producer))
+	}
+
+	// A new node boots from the snapshot, and syncs on from Avail.
+	target := newTestSnapshotAvail(t, fake, appID, window)
+
+	imported, err := NewAdminAPI(target).ImportSnapshot(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, exported, imported)
+	assert.Equal(t, exported.Hash, target.blockchain.Header().Hash)
+	assert.Equal(t, exported, target.forkChoice.settled.Head())
+	assert.Equal(t, exported.AvailBlock, target.getNextAvailBlockNumber())
+
+	// The blocks past the snapshot execute on its state.
+	if _, err := syncTestAvail(target, fake); err != nil {
+		t.Fatal(err)
+	}
+
+	followTestAvail(t, source, fake)
+
+	assert.Equal(t, producer.blockchain.Header().Hash, source.blockchain.Header().Hash)
+	assert.Equal(t, source.blockchain.Header().Hash, target.blockchain.Header().Hash)
+	assert.Equal(t, source.blockchain.Header().StateRoot, target.blockchain.Header().StateRoot)
+}
+
+func TestChainSnapshotImportRefused(t *testing.T) {
+	const window = 2
+
+	appID := avail_types.NewUCompactFromUInt(1)
+	fake := testutil.NewFake(appID)
+
+	producer := newTestGenesisAvail(t)
+	for i := 0; i < 4; i++ {
+		settleTestBlock(t, producer, fake, buildTestBlock(t, producer))
+	}
+
+	source := newTestSnapshotAvail(t, fake, appID, window)
+	followTestAvail(t, source, fake)
+
+	var buf bytes.Buffer
+	if _, err := source.exportChainSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	exported := buf.Bytes()
+
+	// The node of a longer challenge window finds the snapshot still
+	// challengeable.
+	target := newTestSnapshotAvail(t, fake, appID, 100)
+
+	_, err := target.importChainSnapshot(bytes.NewReader(exported))
+	assert.True(t, errors.Is(err, ErrSnapshotChallengeable), "unexpected error: %v", err)
+	assert.Zero(t, target.blockchain.Header().Number)
+
+	// The snapshot missing a trie node of its state is refused.
+	cs, err := snapshot.ReadChainSnapshot(bytes.NewReader(exported))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	last := len(cs.Nodes.Keys) - 1
+	cs.Nodes.Keys, cs.Nodes.Values = cs.Nodes.Keys[:last], cs.Nodes.Values[:last]
+
+	var incomplete bytes.Buffer
+	if err := snapshot.WriteChainSnapshot(&incomplete, cs); err != nil {
+		t.Fatal(err)
+	}
+
+	target = newTestSnapshotAvail(t, fake, appID, window)
+
+	_, err = target.importChainSnapshot(&incomplete)
+	assert.True(t, errors.Is(err, snapshot.ErrIncompleteState), "unexpected error: %v", err)
+	assert.Zero(t, target.blockchain.Header().Number)
+
+	// The snapshot of a node past it in the chain is refused.
+	followTestAvail(t, target, fake)
+
+	_, err = target.importChainSnapshot(bytes.NewReader(exported))
+	assert.Error(t, err)
+
+	// The snapshot is refused unless it's a file on the node.
+	_, err = NewAdminAPI(target).ImportSnapshot(filepath.Join(t.TempDir(), "missing"))
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+}
@@ -0,0 +1,102 @@
+package avail
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+// ChallengeWindowChange sets the challenge window, in Avail blocks, of the
+// blocks from the given number on.
+type ChallengeWindowChange struct {
+	Block  uint64 `json:"block"`
+	Window uint64 `json:"window"`
+}
+
+// ChallengeWindow is the schedule of the challenge window of the chain: the
+// number of Avail blocks after its inclusion a block may be challenged with a
+// fraud proof, before it settles. A change applies to the blocks from its
+// number on, leaving the ones before it to the window they were produced
+// under; the blocks before the first change have DefaultChallengeWindow.
+// It's set by the "challengeWindow" key of the engine configuration, either
+// a number of Avail blocks or a list of the changes in block order.
+type ChallengeWindow []ChallengeWindowChange
+
+// NewChallengeWindow returns the ChallengeWindow of the given number of Avail
+// blocks for every block.
+func NewChallengeWindow(window uint64) ChallengeWindow {
+	return ChallengeWindow{{Block: 0, Window: window}}
+}
+
+// At returns the challenge window of the block of the given number.
+func (w ChallengeWindow) At(number uint64) uint64 {
+	window := uint64(DefaultChallengeWindow)
+
+	for _, change := range w {
+		if change.Block > number {
+			break
+		}
+
+		window = change.Window
+	}
+
+	return window
+}
+
+// parseChallengeWindow converts the challenge window engine configuration
+// value to ChallengeWindow.
+func parseChallengeWindow(raw interface{}) (ChallengeWindow, error) {
+	if window, ok := configUint64(raw); ok {
+		return NewChallengeWindow(window), nil
+	}
+
+	bs, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("challengeWindow expected int or list of changes: %w", err)
+	}
+
+	var w ChallengeWindow
+	if err := json.Unmarshal(bs, &w); err != nil {
+		return nil, fmt.Errorf("challengeWindow expected int or list of changes: %w", err)
+	}
+
+	for i := 1; i < len(w); i++ {
+		if w[i].Block <= w[i-1].Block {
+			return nil, fmt.Errorf("challengeWindow changes expected in block order, got block %d after %d", w[i].Block, w[i-1].Block)
+		}
+	}
+
+	return w, nil
+}
+
+// ChallengeWindowError is returned for the fraud proofs challenging a block
+// past its challenge window; it's a rejection of the fraud proof.
+type ChallengeWindowError struct {
+	// Number and Hash identify the challenged block.
+	Number uint64
+	Hash   types.Hash
+
+	// IncludedAt is the Avail height the block was included at; zero when
+	// no longer tracked past the settlement.
+	IncludedAt uint64
+
+	// Window is the challenge window of the block, in Avail blocks.
+	Window uint64
+
+	// AvailHeight is the Avail height the challenge came in at.
+	AvailHeight uint64
+}
+
+// Error describes the block past its challenge window.
+func (e *ChallengeWindowError) Error() string {
+	return fmt.Sprintf(
+		"%s: block %d (%s) included at Avail block %d is past its challenge window of %d Avail blocks at Avail block %d",
+		ErrFraudProofRejected, e.Number, e.Hash, e.IncludedAt, e.Window, e.AvailHeight,
+	)
+}
+
+// Is reports whether the target is ErrFraudProofRejected.
+func (e *ChallengeWindowError) Is(target error) bool {
+	return target == ErrFraudProofRejected
+}
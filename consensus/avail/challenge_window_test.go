@@ -0,0 +1,107 @@
+package avail
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/availproject/op-evm/pkg/avail"
+	"github.com/availproject/op-evm/pkg/avail/testutil"
+	avail_types "github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseChallengeWindow(t *testing.T) {
+	w, err := parseChallengeWindow(float64(5))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(5), w.At(0))
+	assert.Equal(t, uint64(5), w.At(1000))
+
+	// The change applies from its block on.
+	w, err = parseChallengeWindow([]interface{}{
+		map[string]interface{}{"block": float64(0), "window": float64(5)},
+		map[string]interface{}{"block": float64(100), "window": float64(20)},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(5), w.At(99))
+	assert.Equal(t, uint64(20), w.At(100))
+
+	// The blocks before the first change have the default window.
+	w, err = parseChallengeWindow([]interface{}{
+		map[string]interface{}{"block": float64(10), "window": float64(5)},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(DefaultChallengeWindow), w.At(9))
+	assert.Equal(t, uint64(5), w.At(10))
+
+	_, err = parseChallengeWindow([]interface{}{
+		map[string]interface{}{"block": float64(10), "window": float64(5)},
+		map[string]interface{}{"block": float64(10), "window": float64(6)},
+	})
+	assert.ErrorContains(t, err, "block order")
+
+	_, err = parseChallengeWindow("long")
+	assert.Error(t, err)
+}
+
+func TestFraudProofChallengeWindow(t *testing.T) {
+	const (
+		window   = 3
+		included = 10
+	)
+
+	testCases := []struct {
+		name        string
+		availHeight uint64
+		changed     bool
+		rejected    bool
+	}{
+		{name: "just inside", availHeight: included + window - 1},
+		{name: "just outside", availHeight: included + window, rejected: true},
+		{name: "window widened after the block", availHeight: included + window, changed: true, rejected: true},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			sw, fraudResolver, _ := newTestSequencerWorker(t, testutil.NewFake(avail_types.NewUCompactFromUInt(1)))
+
+			accused, fraudProof, watchtowerAddr := newTestFraudProof(t, sw, fraudResolver)
+			fraudResolver.watchtower = testFraudulentBlocks{}
+
+			// A change of the window only applies to the blocks from its
+			// height on.
+			challengeWindow := NewChallengeWindow(window)
+			if tc.changed {
+				challengeWindow = append(challengeWindow, ChallengeWindowChange{Block: accused.Number() + 1, Window: 100 * window})
+			}
+
+			fraudResolver.settled = newSettledHead(sw.blockchain, challengeWindow, hclog.Default())
+			fraudResolver.settled.include(accused.Header, included)
+
+			err := fraudResolver.verifyFraudProof(fraudProof, tc.availHeight)
+			set := fraudResolver.CheckAndSetFraudBlock(tc.availHeight, []avail.EdgeBlock{{Block: fraudProof}})
+
+			if !tc.rejected {
+				assert.NoError(t, err)
+				assert.True(t, set)
+				assert.Zero(t, fraudResolver.RejectedFraudProofs(watchtowerAddr))
+
+				return
+			}
+
+			var windowErr *ChallengeWindowError
+			if assert.True(t, errors.As(err, &windowErr)) {
+				assert.Equal(t, accused.Hash(), windowErr.Hash)
+				assert.Equal(t, uint64(included), windowErr.IncludedAt)
+				assert.Equal(t, uint64(window), windowErr.Window)
+			}
+
+			assert.ErrorIs(t, err, ErrFraudProofRejected)
+			assert.False(t, set)
+			assert.Nil(t, fraudResolver.GetBlock())
+			assert.Equal(t, uint64(1), fraudResolver.RejectedFraudProofs(watchtowerAddr))
+		})
+	}
+}
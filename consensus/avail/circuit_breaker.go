@@ -0,0 +1,300 @@
+package avail
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/armon/go-metrics"
+	"github.com/availproject/op-evm/pkg/avail"
+	"github.com/availproject/op-evm/pkg/block"
+	"github.com/hashicorp/go-hclog"
+)
+
+// CircuitBreakerFileName is the name of the file, in the consensus data
+// directory, the trip of the circuit breaker is persisted to.
+const CircuitBreakerFileName = "circuit_breaker.json"
+
+// CircuitBreakerConfig configures when the circuit breaker trips.
+type CircuitBreakerConfig struct {
+	// MaxUnresolvedDisputes is the most disputes begun within the window
+	// that may remain unresolved; zero means no limit.
+	MaxUnresolvedDisputes uint64
+
+	// MaxTaintedBlocks is the most blocks the fraud proofs seen within the
+	// window may target; zero means no limit.
+	MaxTaintedBlocks uint64
+
+	// Window is the number of Avail blocks the fraud proofs and the disputes
+	// are counted over.
+	Window uint64
+}
+
+// DefaultCircuitBreakerConfig returns the default CircuitBreakerConfig.
+func DefaultCircuitBreakerConfig() CircuitBreakerConfig {
+	return CircuitBreakerConfig{
+		MaxUnresolvedDisputes: DefaultMaxUnresolvedDisputes,
+		MaxTaintedBlocks:      DefaultMaxTaintedBlocks,
+		Window:                DefaultCircuitBreakerWindow,
+	}
+}
+
+// CircuitBreakerTrip is the record of the trip of the circuit breaker, as
+// persisted and reported by the status API.
+type CircuitBreakerTrip struct {
+	Reason             string    `json:"reason"`
+	AvailBlock         uint64    `json:"availBlock"`
+	UnresolvedDisputes int       `json:"unresolvedDisputes"`
+	TaintedBlocks      int       `json:"taintedBlocks"`
+	TrippedAt          time.Time `json:"trippedAt"`
+}
+
+// circuitBreaker halts the consensus of the node once the fraud proofs keep
+// coming while the disputes they raise don't resolve, such as with a broken
+// staking contract, rather than have it keep building on a contested chain.
+// Tripped, the node produces no blocks and applies none received from Avail
+// but the dispute resolution ones, until resumed over the admin API or on a
+// restart with the resume flag; the trip is persisted to a file in the data
+// directory, if any, to outlive a plain restart.
+type circuitBreaker struct {
+	config  CircuitBreakerConfig
+	path    string
+	clock   clock
+	metrics *fraudMetrics
+	logger  hclog.Logger
+
+	lock     sync.Mutex
+	tainted  map[types.Hash]uint64 // Avail heights of the fraud proofs, by target block
+	disputes map[types.Hash]uint64 // Avail heights of the unresolved disputes, by fraud proof block
+	trip     *CircuitBreakerTrip
+}
+
+// newCircuitBreaker returns the closed circuitBreaker persisting its trip to
+// the given data directory, or kept in memory if it's empty.
+func newCircuitBreaker(config CircuitBreakerConfig, dataDir string, clock clock, logger hclog.Logger) *circuitBreaker {
+	b := &circuitBreaker{
+		config:   config,
+		clock:    clock,
+		metrics:  newFraudMetrics(metrics.Default()),
+		logger:   logger,
+		tainted:  make(map[types.Hash]uint64),
+		disputes: make(map[types.Hash]uint64),
+	}
+
+	if dataDir != "" {
+		b.path = filepath.Join(dataDir, CircuitBreakerFileName)
+	}
+
+	return b
+}
+
+// loadCircuitBreaker returns the circuitBreaker persisted to the given data
+// directory, tripped still if the previous run left it tripped.
+func loadCircuitBreaker(config CircuitBreakerConfig, dataDir string, clock clock, logger hclog.Logger) (*circuitBreaker, error) {
+	b := newCircuitBreaker(config, dataDir, clock, logger)
+	if b.path == "" {
+		return b, nil
+	}
+
+	bs, err := os.ReadFile(b.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return b, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	trip := &CircuitBreakerTrip{}
+	if err := json.Unmarshal(bs, trip); err != nil {
+		return nil, fmt.Errorf("invalid circuit breaker file %q: %w", b.path, err)
+	}
+
+	b.trip = trip
+	observeCircuitBreaker(true)
+
+	logger.Error("consensus halted by the circuit breaker in a previous run; resume it over the admin API or restart with the resume flag", "reason", trip.Reason, "tripped_at", trip.TrippedAt)
+
+	return b, nil
+}
+
+// Tripped reports whether the circuit breaker halts the consensus.
+func (b *circuitBreaker) Tripped() bool {
+	return b.Trip() != nil
+}
+
+// Trip returns the record of the trip of the circuit breaker, or nil if it's
+// closed.
+func (b *circuitBreaker) Trip() *CircuitBreakerTrip {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.trip == nil {
+		return nil
+	}
+
+	trip := *b.trip
+
+	return &trip
+}
+
+// observe counts the fraud proofs and the disputes among the edge blocks of
+// the Avail block at the given height, and trips the circuit breaker once
+// either exceeds its limit within the window.
+func (b *circuitBreaker) observe(availHeight uint64, blks []avail.EdgeBlock) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	for _, decoded := range blks {
+		h := decoded.Block.Header
+
+		// A fraud proof taints its target block and raises a dispute, which
+		// the block ending the dispute resolution of the fraud proof resolves.
+		if target, ok := block.GetExtraDataFraudProofTarget(h); ok {
+			if _, seen := b.tainted[target]; !seen {
+				b.tainted[target] = availHeight
+			}
+
+			if _, seen := b.disputes[h.Hash]; !seen {
+				b.disputes[h.Hash] = availHeight
+			}
+		}
+
+		if target, ok := block.GetExtraDataEndDisputeResolutionTarget(h); ok {
+			delete(b.disputes, target)
+		}
+	}
+
+	// The events out of the window no longer count.
+	if availHeight > b.config.Window {
+		oldest := availHeight - b.config.Window
+
+		for _, events := range []map[types.Hash]uint64{b.tainted, b.disputes} {
+			for target, height := range events {
+				if height <= oldest {
+					delete(events, target)
+				}
+			}
+		}
+	}
+
+	b.metrics.taintedBlocks(len(b.tainted))
+
+	if b.trip != nil {
+		return
+	}
+
+	var reason string
+
+	switch {
+	case b.config.MaxUnresolvedDisputes > 0 && uint64(len(b.disputes)) > b.config.MaxUnresolvedDisputes:
+		reason = fmt.Sprintf("%d unresolved disputes within %d Avail blocks, over the limit of %d", len(b.disputes), b.config.Window, b.config.MaxUnresolvedDisputes)
+	case b.config.MaxTaintedBlocks > 0 && uint64(len(b.tainted)) > b.config.MaxTaintedBlocks:
+		reason = fmt.Sprintf("%d blocks targeted by fraud proofs within %d Avail blocks, over the limit of %d", len(b.tainted), b.config.Window, b.config.MaxTaintedBlocks)
+	default:
+		return
+	}
+
+	b.trip = &CircuitBreakerTrip{
+		Reason:             reason,
+		AvailBlock:         availHeight,
+		UnresolvedDisputes: len(b.disputes),
+		TaintedBlocks:      len(b.tainted),
+		TrippedAt:          b.clock.Now(),
+	}
+
+	observeCircuitBreaker(true)
+
+	b.logger.Error(
+		"circuit breaker tripped; halting block production and application until resumed over the admin API or on a restart with the resume flag",
+		"reason", reason,
+		"avail_block_number", availHeight,
+	)
+
+	if err := b.saveLocked(); err != nil {
+		b.logger.Error("failed to persist the circuit breaker trip", "error", err)
+	}
+}
+
+// admits reports whether the block received from Avail may be applied; once
+// tripped, the circuit breaker admits the dispute resolution blocks only.
+func (b *circuitBreaker) admits(blk *types.Block) bool {
+	if b == nil || !b.Tripped() {
+		return true
+	}
+
+	return isDisputeResolutionBlock(blk)
+}
+
+// resume closes the circuit breaker, clearing the fraud proofs and the
+// disputes counted so far. It reports whether the circuit breaker was
+// tripped.
+func (b *circuitBreaker) resume() (bool, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.trip == nil {
+		return false, nil
+	}
+
+	b.logger.Warn("circuit breaker resumed; the consensus goes on", "reason", b.trip.Reason, "tripped_at", b.trip.TrippedAt)
+
+	b.trip = nil
+	b.tainted = make(map[types.Hash]uint64)
+	b.disputes = make(map[types.Hash]uint64)
+
+	observeCircuitBreaker(false)
+
+	if b.path == "" {
+		return true, nil
+	}
+
+	if err := os.Remove(b.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return true, err
+	}
+
+	return true, nil
+}
+
+// saveLocked persists the trip; it writes a temporary file first and renames
+// it over the circuit breaker file. It must be called with the lock held.
+func (b *circuitBreaker) saveLocked() error {
+	if b.path == "" {
+		return nil
+	}
+
+	bs, err := json.Marshal(b.trip)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(b.path), 0o700); err != nil {
+		return err
+	}
+
+	tmp := b.path + ".tmp"
+	if err := os.WriteFile(tmp, bs, 0o600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, b.path)
+}
+
+// isDisputeResolutionBlock reports whether the block begins or ends a
+// dispute resolution.
+func isDisputeResolutionBlock(blk *types.Block) bool {
+	if _, ok := block.GetExtraDataBeginDisputeResolutionTarget(blk.Header); ok {
+		return true
+	}
+
+	if _, ok := block.GetExtraDataEndDisputeResolutionTarget(blk.Header); ok {
+		return true
+	}
+
+	return isDisputeResolutionFork(blk)
+}
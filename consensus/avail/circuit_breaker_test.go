@@ -0,0 +1,216 @@
+package avail
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/pkg/avail"
+	"github.com/availproject/op-evm/pkg/avail/testutil"
+	"github.com/availproject/op-evm/pkg/block"
+	avail_types "github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+// testExtraBlock returns the edge block at the given number carrying the
+// given extra data field.
+func testExtraBlock(number uint64, key string, target types.Hash) avail.EdgeBlock {
+	h := &types.Header{
+		Number:    number,
+		ExtraData: block.EncodeExtraDataFields(map[string][]byte{key: target.Bytes()}),
+	}
+	h.ComputeHash()
+
+	return avail.EdgeBlock{Block: &types.Block{Header: h}}
+}
+
+// testFraudProof returns the fraud proof block against the given block.
+func testFraudProof(target types.Hash) avail.EdgeBlock {
+	return testExtraBlock(1, block.KeyFraudProofOf, target)
+}
+
+// testEndDispute returns the block ending the dispute resolution of the
+// given fraud proof block.
+func testEndDispute(fraudProof avail.EdgeBlock) avail.EdgeBlock {
+	return testExtraBlock(2, block.KeyEndDisputeResolutionOf, fraudProof.Block.Hash())
+}
+
+func TestCircuitBreakerTripsOnUnresolvedDisputes(t *testing.T) {
+	b := newCircuitBreaker(CircuitBreakerConfig{MaxUnresolvedDisputes: 2, Window: 10}, "", newFakeClock(time.Unix(1_000, 0)), hclog.NewNullLogger())
+
+	fp1 := testFraudProof(types.StringToHash("1"))
+	fp2 := testFraudProof(types.StringToHash("2"))
+	fp3 := testFraudProof(types.StringToHash("3"))
+	fp4 := testFraudProof(types.StringToHash("4"))
+
+	b.observe(1, []avail.EdgeBlock{fp1, fp2})
+	assert.False(t, b.Tripped())
+
+	// The resolved disputes don't count.
+	b.observe(2, []avail.EdgeBlock{testEndDispute(fp1), fp3})
+	assert.False(t, b.Tripped())
+
+	// Neither do the ones out of the window.
+	b.observe(11, nil)
+	b.observe(12, []avail.EdgeBlock{fp4})
+	assert.False(t, b.Tripped())
+
+	b.observe(13, []avail.EdgeBlock{testFraudProof(types.StringToHash("5"))})
+	assert.False(t, b.Tripped())
+
+	b.observe(14, []avail.EdgeBlock{testFraudProof(types.StringToHash("6"))})
+
+	trip := b.Trip()
+	if assert.NotNil(t, trip) {
+		assert.Equal(t, uint64(14), trip.AvailBlock)
+		assert.Equal(t, 3, trip.UnresolvedDisputes)
+		assert.Equal(t, time.Unix(1_000, 0), trip.TrippedAt)
+	}
+
+	// Tripped, only the dispute resolution blocks are admitted.
+	assert.False(t, b.admits(testExtraBlock(3, "", types.ZeroHash).Block))
+	assert.True(t, b.admits(testEndDispute(fp4).Block))
+	assert.True(t, b.admits(testExtraBlock(3, block.KeyBeginDisputeResolutionOf, types.StringToHash("8")).Block))
+
+	// Resuming closes it, the counts starting over.
+	resumed, err := b.resume()
+	assert.NoError(t, err)
+	assert.True(t, resumed)
+	assert.False(t, b.Tripped())
+	assert.True(t, b.admits(testExtraBlock(3, "", types.ZeroHash).Block))
+
+	b.observe(15, []avail.EdgeBlock{testFraudProof(types.StringToHash("7"))})
+	assert.False(t, b.Tripped())
+
+	resumed, err = b.resume()
+	assert.NoError(t, err)
+	assert.False(t, resumed)
+}
+
+func TestCircuitBreakerTripsOnTaintedBlocks(t *testing.T) {
+	b := newCircuitBreaker(CircuitBreakerConfig{MaxTaintedBlocks: 2, Window: 10}, "", systemClock{}, hclog.NewNullLogger())
+
+	fp1 := testFraudProof(types.StringToHash("1"))
+	fp2 := testFraudProof(types.StringToHash("2"))
+
+	// The resolved disputes still taint their targets; the same target
+	// counts once.
+	b.observe(1, []avail.EdgeBlock{fp1, fp2, testEndDispute(fp1), testEndDispute(fp2)})
+	b.observe(2, []avail.EdgeBlock{fp1})
+	assert.False(t, b.Tripped())
+
+	b.observe(3, []avail.EdgeBlock{testFraudProof(types.StringToHash("3"))})
+
+	trip := b.Trip()
+	if assert.NotNil(t, trip) {
+		assert.Equal(t, 3, trip.TaintedBlocks)
+	}
+
+	// Zero means no limit.
+	b = newCircuitBreaker(CircuitBreakerConfig{Window: 10}, "", systemClock{}, hclog.NewNullLogger())
+	for i := 0; i < 10; i++ {
+		b.observe(1, []avail.EdgeBlock{testFraudProof(types.BytesToHash([]byte{byte(i + 1)}))})
+	}
+
+	assert.False(t, b.Tripped())
+}
+
+func TestCircuitBreakerPersistsTrip(t *testing.T) {
+	dir := t.TempDir()
+	config := CircuitBreakerConfig{MaxUnresolvedDisputes: 1, Window: 10}
+
+	b, err := loadCircuitBreaker(config, dir, systemClock{}, hclog.NewNullLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b.observe(1, []avail.EdgeBlock{testFraudProof(types.StringToHash("1")), testFraudProof(types.StringToHash("2"))})
+	assert.True(t, b.Tripped())
+
+	// A restart leaves it tripped.
+	b, err = loadCircuitBreaker(config, dir, systemClock{}, hclog.NewNullLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	trip := b.Trip()
+	if assert.NotNil(t, trip) {
+		assert.Equal(t, uint64(1), trip.AvailBlock)
+	}
+
+	// Resuming removes the file.
+	if _, err := b.resume(); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = os.Stat(filepath.Join(dir, CircuitBreakerFileName))
+	assert.True(t, os.IsNotExist(err))
+
+	b, err = loadCircuitBreaker(config, dir, systemClock{}, hclog.NewNullLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.False(t, b.Tripped())
+}
+
+func TestSequencerHaltedByCircuitBreaker(t *testing.T) {
+	d, _ := NewTestAvail(t, Sequencer)
+	d.breaker = newCircuitBreaker(CircuitBreakerConfig{MaxUnresolvedDisputes: 1, Window: 10}, "", systemClock{}, hclog.NewNullLogger())
+
+	sw, _, _ := newTestSequencerWorkerOf(t, d, testutil.NewFake(avail_types.NewUCompactFromUInt(1)))
+	d.progress, d.disputes, d.readiness = sw.progress, sw.disputes, sw.readiness
+
+	assert.NoError(t, sw.phases.enter(PhaseSyncing))
+	sw.updatePhase()
+
+	// The fraud proofs pile up unresolved.
+	sw.breaker.observe(1, []avail.EdgeBlock{testFraudProof(types.StringToHash("1")), testFraudProof(types.StringToHash("2"))})
+	sw.updatePhase()
+
+	status := d.Status()
+	assert.Equal(t, string(PhaseCircuitBroken), status.Phase)
+	assert.True(t, status.ProductionPaused)
+	assert.NotNil(t, status.CircuitBreaker)
+
+	// The blocks received from Avail are held back.
+	blk := buildTestBlock(t, d)
+	head := sw.blockchain.Header().Number
+
+	write, err := writeAvailBlock(sw.forkChoice, sw.breaker, avail.EdgeBlock{Block: blk}, blockInclusion{availBlock: 2}, sw.nodeType.String(), sw.logger)
+	assert.NoError(t, err)
+	assert.Equal(t, blockHalted, write)
+	assert.Equal(t, head, sw.blockchain.Header().Number)
+
+	// The operator resumes it over the admin API.
+	srv := rpc.NewServer()
+	if err := srv.RegisterName(AdminNamespace, NewAdminAPI(d)); err != nil {
+		t.Fatal(err)
+	}
+
+	c := rpc.DialInProc(srv)
+	defer c.Close()
+
+	var resumed bool
+	if err := c.Call(&resumed, "availAdmin_resumeCircuitBreaker"); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.True(t, resumed)
+
+	sw.updatePhase()
+
+	status = d.Status()
+	assert.Equal(t, string(PhaseActive), status.Phase)
+	assert.False(t, status.ProductionPaused)
+	assert.Nil(t, status.CircuitBreaker)
+
+	write, err = writeAvailBlock(sw.forkChoice, sw.breaker, avail.EdgeBlock{Block: blk}, blockInclusion{availBlock: 3}, sw.nodeType.String(), sw.logger)
+	assert.NoError(t, err)
+	assert.Equal(t, blockWritten, write)
+	assert.Equal(t, blk.Hash(), sw.blockchain.Header().Hash)
+}
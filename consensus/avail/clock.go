@@ -0,0 +1,44 @@
+package avail
+
+import "time"
+
+// clock is the source of time of the block production.
+type clock interface {
+	// Now returns the current time.
+	Now() time.Time
+
+	// NewTicker returns a ticker delivering the time every period.
+	NewTicker(period time.Duration) ticker
+}
+
+// ticker delivers ticks at intervals.
+type ticker interface {
+	// C returns the channel on which the ticks are delivered.
+	C() <-chan time.Time
+
+	// Stop turns off the ticker.
+	Stop()
+}
+
+// systemClock is the clock backed by the system time.
+type systemClock struct{}
+
+// Now returns the current system time.
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// NewTicker returns a time.Ticker delivering the time every period.
+func (systemClock) NewTicker(period time.Duration) ticker {
+	return systemTicker{time.NewTicker(period)}
+}
+
+// systemTicker is a ticker backed by time.Ticker.
+type systemTicker struct {
+	*time.Ticker
+}
+
+// C returns the channel on which the ticks are delivered.
+func (t systemTicker) C() <-chan time.Time {
+	return t.Ticker.C
+}
@@ -0,0 +1,20 @@
+package avail
+
+import (
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+// RegisterPreCommitHook registers the hook to run right before every block
+// is written to the chain, whichever way the node came by it. The hooks run
+// in the order they were registered, and an error of any of them aborts the
+// write of the block with it.
+func (d *Avail) RegisterPreCommitHook(hook func(blk *types.Block) error) {
+	d.blockchain.RegisterPreCommitHook(hook)
+}
+
+// RegisterPostCommitHook registers the hook to run with every block written
+// to the chain and its receipts. The hooks are best-effort: they run in the
+// background, in the order they were registered, with their panics recovered.
+func (d *Avail) RegisterPostCommitHook(hook func(blk *types.Block, receipts []*types.Receipt)) {
+	d.blockchain.RegisterPostCommitHook(hook)
+}
@@ -0,0 +1,98 @@
+package avail
+
+import (
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/pkg/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommitHooks(t *testing.T) {
+	d, _ := NewTestAvail(t, Sequencer)
+
+	errVetoed := errors.New("vetoed")
+
+	var (
+		lock     sync.Mutex
+		calls    []string
+		vetoing  = true
+		received = make(chan *types.Block, 1)
+	)
+
+	record := func(call string) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		calls = append(calls, call)
+	}
+
+	d.RegisterPreCommitHook(func(blk *types.Block) error {
+		record("pre 1")
+		return nil
+	})
+	d.RegisterPreCommitHook(func(blk *types.Block) error {
+		record("pre 2")
+
+		if vetoing {
+			return errVetoed
+		}
+
+		return nil
+	})
+	d.RegisterPreCommitHook(func(blk *types.Block) error {
+		record("pre 3")
+		return nil
+	})
+
+	// A post-commit hook panicking leaves the next ones running.
+	d.RegisterPostCommitHook(func(blk *types.Block, receipts []*types.Receipt) {
+		record("post 1")
+		panic("post-commit hook failure")
+	})
+	d.RegisterPostCommitHook(func(blk *types.Block, receipts []*types.Receipt) {
+		record("post 2")
+
+		assert.Len(t, receipts, len(blk.Transactions))
+		received <- blk
+	})
+
+	// The vetoed block isn't written.
+	head := d.blockchain.Header()
+	blk := &types.Block{Header: &types.Header{
+		ParentHash: head.Hash,
+		Number:     head.Number + 1,
+		GasLimit:   head.GasLimit,
+		StateRoot:  head.StateRoot,
+		Timestamp:  head.Timestamp + 1,
+	}}
+	blk.Header.ComputeHash()
+
+	err := d.blockchain.WriteBlock(blk, Sequencer.String())
+	assert.ErrorIs(t, err, errVetoed)
+	assert.Equal(t, head.Hash, d.blockchain.Header().Hash)
+	assert.Equal(t, []string{"pre 1", "pre 2"}, calls)
+
+	// The block written is passed to the post-commit hooks, in the order
+	// they were registered.
+	calls, vetoing = nil, false
+
+	addr, _ := test.NewAccount(t)
+	test.DepositBalance(t, addr, big.NewInt(1), d.blockchain, d.executor)
+
+	select {
+	case written := <-received:
+		assert.Equal(t, d.blockchain.Header().Hash, written.Hash())
+	case <-time.After(5 * time.Second):
+		t.Fatal("post-commit hooks not run")
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	assert.Equal(t, []string{"pre 1", "pre 2", "pre 3", "post 1", "post 2"}, calls)
+}
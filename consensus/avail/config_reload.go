@@ -0,0 +1,307 @@
+package avail
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/availproject/op-evm/pkg/avail"
+	"github.com/hashicorp/go-hclog"
+)
+
+// errReloadUnsupported is returned for the reload of the config of the node
+// started without a config file to re-read.
+var errReloadUnsupported = errors.New("config reload not set up")
+
+// OperationalConfig is the configuration of the node read from its config
+// file, as far as the reload goes. The hot parameters left unset keep the
+// values of the engine params of the chain, or of the flags; the other
+// parameters of the file are read on the start only.
+type OperationalConfig struct {
+	// The hot parameters, applied on the reload: the log level, the price
+	// limit and the block gas target of the sequencer, the Avail endpoints,
+	// in the order of preference, and the dispute escalation webhook.
+	LogLevel          string
+	PriceLimit        *uint64
+	BlockGasTarget    *uint64
+	AvailAddrs        []string
+	EscalationWebhook *string
+
+	// The immutable parameters, taking a restart to change.
+	ChainID       int64
+	DataDir       string
+	SecretsConfig string
+}
+
+// ConfigChange is a parameter changed in the config file, by its key.
+type ConfigChange struct {
+	Param string `json:"param"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+
+	// Reason is why the change wasn't applied, if it wasn't.
+	Reason string `json:"reason,omitempty"`
+}
+
+// ConfigReload is the report of the reload of the config: the changes
+// applied, and the ones rejected.
+type ConfigReload struct {
+	Applied  []ConfigChange `json:"applied"`
+	Rejected []ConfigChange `json:"rejected"`
+}
+
+// productionOverrides are the production parameters changed by the reload
+// of the config, shared by the node and its workers; they take the place of
+// the ones of the ProductionConfig once set.
+type productionOverrides struct {
+	lock           sync.RWMutex
+	priceLimit     *uint64
+	blockGasTarget *uint64
+}
+
+// priceLimitOr returns the price limit set, or the given one if none.
+func (o *productionOverrides) priceLimitOr(limit uint64) uint64 {
+	if o == nil {
+		return limit
+	}
+
+	o.lock.RLock()
+	defer o.lock.RUnlock()
+
+	if o.priceLimit != nil {
+		return *o.priceLimit
+	}
+
+	return limit
+}
+
+// blockGasTargetOr returns the block gas target set, or the given one if
+// none.
+func (o *productionOverrides) blockGasTargetOr(target uint64) uint64 {
+	if o == nil {
+		return target
+	}
+
+	o.lock.RLock()
+	defer o.lock.RUnlock()
+
+	if o.blockGasTarget != nil {
+		return *o.blockGasTarget
+	}
+
+	return target
+}
+
+func (o *productionOverrides) setPriceLimit(limit uint64) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	o.priceLimit = &limit
+}
+
+func (o *productionOverrides) setBlockGasTarget(target uint64) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	o.blockGasTarget = &target
+}
+
+// configReload re-reads the config file of the node and applies the hot
+// parameters changed, against the running config.
+type configReload struct {
+	load func() (*OperationalConfig, error)
+
+	lock    sync.Mutex
+	running OperationalConfig
+}
+
+// initConfigReload applies the hot parameters set in the config file over
+// the ones of the engine params, and sets up the reload of the file. It
+// returns the escalation webhook to use.
+func (d *Avail) initConfigReload(op *OperationalConfig, load func() (*OperationalConfig, error), webhook string) string {
+	d.overrides = new(productionOverrides)
+
+	if op == nil {
+		op = &OperationalConfig{}
+	}
+
+	if op.PriceLimit != nil {
+		d.production.PriceLimit = *op.PriceLimit
+	}
+
+	if op.BlockGasTarget != nil {
+		d.production.BlockGasTarget = *op.BlockGasTarget
+	}
+
+	if op.EscalationWebhook != nil {
+		webhook = *op.EscalationWebhook
+	}
+
+	priceLimit, blockGasTarget := d.production.PriceLimit, d.production.BlockGasTarget
+
+	d.reload = &configReload{
+		load: load,
+		running: OperationalConfig{
+			LogLevel:          d.logger.GetLevel().String(),
+			PriceLimit:        &priceLimit,
+			BlockGasTarget:    &blockGasTarget,
+			AvailAddrs:        op.AvailAddrs,
+			EscalationWebhook: &webhook,
+			ChainID:           op.ChainID,
+			DataDir:           op.DataDir,
+			SecretsConfig:     op.SecretsConfig,
+		},
+	}
+
+	return webhook
+}
+
+// ReloadConfig re-reads the config file of the node and applies the hot
+// parameters changed, logging them; the changes of the immutable ones, and
+// the hot ones failing to apply, are rejected and left as they run.
+func (d *Avail) ReloadConfig() (*ConfigReload, error) {
+	if d.reload == nil || d.reload.load == nil {
+		return nil, errReloadUnsupported
+	}
+
+	next, err := d.reload.load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the config: %w", err)
+	}
+
+	d.reload.lock.Lock()
+	defer d.reload.lock.Unlock()
+
+	running := &d.reload.running
+	report := &ConfigReload{Applied: []ConfigChange{}, Rejected: []ConfigChange{}}
+
+	reject := func(change ConfigChange, reason string) {
+		change.Reason = reason
+		report.Rejected = append(report.Rejected, change)
+
+		d.logger.Warn("config change rejected", "param", change.Param, "from", change.From, "to", change.To, "reason", reason)
+	}
+
+	apply := func(change ConfigChange, set func() error) bool {
+		if err := set(); err != nil {
+			reject(change, err.Error())
+			return false
+		}
+
+		report.Applied = append(report.Applied, change)
+
+		d.logger.Info("config change applied", "param", change.Param, "from", change.From, "to", change.To)
+
+		return true
+	}
+
+	// The immutable parameters.
+	immutable := []ConfigChange{
+		{Param: "chain_id", From: strconv.FormatInt(running.ChainID, 10), To: strconv.FormatInt(next.ChainID, 10)},
+		{Param: "data_dir", From: running.DataDir, To: next.DataDir},
+		{Param: "secrets_config", From: running.SecretsConfig, To: next.SecretsConfig},
+	}
+
+	for _, change := range immutable {
+		if change.From != change.To {
+			reject(change, "takes a restart")
+		}
+	}
+
+	// The hot parameters.
+	if next.LogLevel != "" {
+		level, to := hclog.LevelFromString(next.LogLevel), next.LogLevel
+		if level != hclog.NoLevel {
+			to = level.String()
+		}
+
+		change := ConfigChange{Param: "log_level", From: running.LogLevel, To: to}
+
+		if change.From != change.To {
+			if apply(change, func() error {
+				if level == hclog.NoLevel {
+					return fmt.Errorf("invalid log level %q", next.LogLevel)
+				}
+
+				d.logger.SetLevel(level)
+
+				return nil
+			}) {
+				running.LogLevel = change.To
+			}
+		}
+	}
+
+	if next.PriceLimit != nil && *next.PriceLimit != *running.PriceLimit {
+		limit := *next.PriceLimit
+		change := ConfigChange{Param: "avail.price_limit", From: strconv.FormatUint(*running.PriceLimit, 10), To: strconv.FormatUint(limit, 10)}
+
+		if apply(change, func() error { d.overrides.setPriceLimit(limit); return nil }) {
+			running.PriceLimit = &limit
+		}
+	}
+
+	if next.BlockGasTarget != nil && *next.BlockGasTarget != *running.BlockGasTarget {
+		target := *next.BlockGasTarget
+		change := ConfigChange{Param: "avail.block_gas_target", From: strconv.FormatUint(*running.BlockGasTarget, 10), To: strconv.FormatUint(target, 10)}
+
+		if apply(change, func() error { d.overrides.setBlockGasTarget(target); return nil }) {
+			running.BlockGasTarget = &target
+		}
+	}
+
+	if len(next.AvailAddrs) > 0 && strings.Join(next.AvailAddrs, ",") != strings.Join(running.AvailAddrs, ",") {
+		addrs := next.AvailAddrs
+		change := ConfigChange{Param: "avail.addrs", From: strings.Join(running.AvailAddrs, ","), To: strings.Join(addrs, ",")}
+
+		if apply(change, func() error {
+			setter, ok := d.availClient.(avail.EndpointSetter)
+			if !ok {
+				return errors.New("the Avail client can't change its endpoints")
+			}
+
+			return setter.SetEndpoints(addrs)
+		}) {
+			running.AvailAddrs = addrs
+		}
+	}
+
+	if next.EscalationWebhook != nil && *next.EscalationWebhook != *running.EscalationWebhook {
+		webhook := *next.EscalationWebhook
+		change := ConfigChange{Param: "avail.dispute_escalation_webhook", From: redactWebhook(*running.EscalationWebhook), To: redactWebhook(webhook)}
+
+		if apply(change, func() error {
+			if d.disputeWatcher == nil {
+				return errors.New("no dispute watcher")
+			}
+
+			d.disputeWatcher.setEscalationWebhook(newEscalationWebhook(webhook, d.logger.Named("escalation_webhook")))
+
+			return nil
+		}) {
+			running.EscalationWebhook = &webhook
+		}
+	}
+
+	d.logger.Info("config reloaded", "applied", len(report.Applied), "rejected", len(report.Rejected))
+
+	return report, nil
+}
+
+// redactWebhook returns the webhook URL of the report, cut to its host as
+// the path and the query of the webhooks tend to carry their secret.
+func redactWebhook(webhook string) string {
+	u, err := url.Parse(webhook)
+	if err != nil || u.Host == "" {
+		return webhook
+	}
+
+	if u.Path == "" && u.RawQuery == "" {
+		return u.Scheme + "://" + u.Host
+	}
+
+	return u.Scheme + "://" + u.Host + "/…"
+}
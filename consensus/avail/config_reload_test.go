@@ -0,0 +1,100 @@
+package avail
+
+import (
+	"io"
+	"testing"
+
+	"github.com/availproject/op-evm/pkg/avail/testutil"
+	avail_types "github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReloadConfig(t *testing.T) {
+	a, _ := NewTestAvail(t, Sequencer)
+	a.logger = hclog.New(&hclog.LoggerOptions{Level: hclog.Info, Output: io.Discard})
+
+	sw, _, _ := newTestSequencerWorkerOf(t, a, testutil.NewFake(avail_types.NewUCompactFromUInt(1)))
+	user := newTestSender(t, sw)
+
+	// The config file as it was on the start, and as it's reloaded.
+	priceLimit := uint64(1)
+	started := OperationalConfig{LogLevel: "INFO", PriceLimit: &priceLimit, ChainID: 100, DataDir: "./data", SecretsConfig: "./secrets.json"}
+	file := started
+
+	a.initConfigReload(&started, func() (*OperationalConfig, error) {
+		next := file
+		return &next, nil
+	}, "")
+
+	sw.overrides = a.overrides
+
+	assert.NoError(t, a.AddTx(user.transfer(t, 5)))
+
+	srv := rpc.NewServer()
+	if err := srv.RegisterName(AdminNamespace, NewAdminAPI(a)); err != nil {
+		t.Fatal(err)
+	}
+
+	c := rpc.DialInProc(srv)
+	defer c.Close()
+
+	reload := func() *ConfigReload {
+		t.Helper()
+
+		var report ConfigReload
+		if err := c.Call(&report, AdminNamespace+"_reloadConfig"); err != nil {
+			t.Fatal(err)
+		}
+
+		return &report
+	}
+
+	// Nothing changed, nothing applied.
+	report := reload()
+	assert.Empty(t, report.Applied)
+	assert.Empty(t, report.Rejected)
+
+	// The price limit and the log level are applied; the data directory is
+	// rejected and left as it runs.
+	raised := uint64(10)
+	file.PriceLimit, file.LogLevel, file.DataDir = &raised, "debug", "./elsewhere"
+
+	report = reload()
+	assert.Equal(t, []ConfigChange{
+		{Param: "log_level", From: "info", To: "debug"},
+		{Param: "avail.price_limit", From: "1", To: "10"},
+	}, report.Applied)
+	assert.Equal(t, []ConfigChange{
+		{Param: "data_dir", From: "./data", To: "./elsewhere", Reason: "takes a restart"},
+	}, report.Rejected)
+
+	assert.Equal(t, hclog.Debug, a.logger.GetLevel())
+	assert.ErrorIs(t, a.AddTx(user.transfer(t, 5)), ErrUnderpricedTx)
+	assert.Equal(t, raised, sw.overrides.priceLimitOr(sw.production.PriceLimit))
+
+	// The applied changes run; the rejected one is rejected again, along
+	// with the hot ones failing to apply.
+	target := uint64(1_000_000)
+	file.BlockGasTarget, file.LogLevel, file.AvailAddrs = &target, "loud", []string{"ws://127.0.0.1:1"}
+
+	report = reload()
+	assert.Equal(t, []ConfigChange{
+		{Param: "avail.block_gas_target", From: "0", To: "1000000"},
+	}, report.Applied)
+
+	if assert.Len(t, report.Rejected, 3) {
+		assert.Equal(t, "data_dir", report.Rejected[0].Param)
+		assert.Equal(t, ConfigChange{Param: "log_level", From: "debug", To: "loud", Reason: `invalid log level "loud"`}, report.Rejected[1])
+		assert.Equal(t, "avail.addrs", report.Rejected[2].Param)
+	}
+
+	assert.Equal(t, target, sw.overrides.blockGasTargetOr(sw.production.BlockGasTarget))
+	assert.Equal(t, hclog.Debug, a.logger.GetLevel())
+
+	// The node started without a config file can't reload it.
+	b, _ := NewTestAvail(t, Sequencer)
+	_, err := b.ReloadConfig()
+	assert.ErrorIs(t, err, errReloadUnsupported)
+}
@@ -0,0 +1,157 @@
+package avail
+
+import (
+	"sync"
+	"time"
+
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+// conflictLogSize is the number of the recent conflicts kept; the oldest
+// ones are overwritten first.
+const conflictLogSize = 64
+
+// ConflictResolution is how the fork choice resolved a conflict.
+type ConflictResolution string
+
+const (
+	// ConflictKept is the resolution of the conflict leaving the canonical
+	// block in place.
+	ConflictKept ConflictResolution = "kept"
+
+	// ConflictReorged is the resolution of the conflict reorganizing the
+	// chain onto the preferred block.
+	ConflictReorged ConflictResolution = "reorged"
+
+	// ConflictDeferred is the resolution of the conflict left for once the
+	// canonical block shows up on Avail.
+	ConflictDeferred ConflictResolution = "deferred"
+
+	// ConflictRefused is the resolution of the conflict whose preferred
+	// block is too deep to reorganize onto.
+	ConflictRefused ConflictResolution = "refused"
+)
+
+// Conflict is the record of a block seen on Avail competing with a block the
+// node produced at the same height, as returned by `avail_getRecentConflicts`.
+type Conflict struct {
+	Height           uint64             `json:"height"`
+	OwnBlockHash     types.Hash         `json:"ownBlockHash"`
+	WinningBlockHash types.Hash         `json:"winningBlockHash"`
+	WinnerMiner      types.Address      `json:"winnerMiner"`
+	Resolution       ConflictResolution `json:"resolution"`
+	ReorgDepth       uint64             `json:"reorgDepth"`
+	ObservedAt       time.Time          `json:"observedAt"`
+}
+
+// conflictLog keeps the recent conflicts in memory, in a ring buffer.
+type conflictLog struct {
+	lock    sync.Mutex
+	records [conflictLogSize]Conflict
+	next    int
+	full    bool
+}
+
+// add records the conflict, overwriting the oldest one once full.
+func (l *conflictLog) add(c Conflict) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.records[l.next] = c
+	l.next = (l.next + 1) % conflictLogSize
+
+	if l.next == 0 {
+		l.full = true
+	}
+}
+
+// Recent returns the recorded conflicts, the most recent first.
+func (l *conflictLog) Recent() []Conflict {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	n := l.next
+	if l.full {
+		n = conflictLogSize
+	}
+
+	recent := make([]Conflict, 0, n)
+	for i := 1; i <= n; i++ {
+		recent = append(recent, l.records[(l.next-i+conflictLogSize)%conflictLogSize])
+	}
+
+	return recent
+}
+
+// ownCompetitorLocked returns the hash of the block of the node at the height
+// of the given header, if one of the two blocks is the node's own and the
+// other one competes with it. It must be called with the lock held.
+func (fc *forkChoice) ownCompetitorLocked(header *types.Header) (types.Hash, bool) {
+	if fc.self == types.ZeroAddress {
+		return types.ZeroHash, false
+	}
+
+	candidates := append([]types.Hash(nil), fc.byNumber[header.Number]...)
+	if canonical, ok := fc.blockchain.GetHeaderByNumber(header.Number); ok {
+		candidates = append(candidates, canonical.Hash)
+	}
+
+	if types.BytesToAddress(header.Miner) == fc.self {
+		for _, hash := range candidates {
+			if hash != header.Hash {
+				return header.Hash, true
+			}
+		}
+
+		return types.ZeroHash, false
+	}
+
+	for _, hash := range candidates {
+		if hash == header.Hash {
+			continue
+		}
+
+		if h, ok := fc.blockchain.GetHeaderByHash(hash); ok && types.BytesToAddress(h.Miner) == fc.self {
+			return hash, true
+		}
+	}
+
+	return types.ZeroHash, false
+}
+
+// noteConflictLocked records the conflict at the given height with the own
+// block of the node, as resolved by the fork choice. It must be called with
+// the lock held.
+func (fc *forkChoice) noteConflictLocked(number uint64, own types.Hash, resolution ConflictResolution, depth uint64) {
+	c := Conflict{
+		Height:       number,
+		OwnBlockHash: own,
+		Resolution:   resolution,
+		ReorgDepth:   depth,
+		ObservedAt:   time.Now(),
+	}
+
+	if winner, ok := fc.blockchain.GetHeaderByNumber(number); ok {
+		c.WinningBlockHash = winner.Hash
+		c.WinnerMiner = types.BytesToAddress(winner.Miner)
+	}
+
+	fc.conflicts.add(c)
+	observeConflict(c, fc.self)
+
+	fc.logger.Info(
+		"block competing with an own block",
+		"block_number", number,
+		"own_block_hash", own,
+		"winning_block_hash", c.WinningBlockHash,
+		"winner", c.WinnerMiner,
+		"resolution", resolution,
+		"depth", depth,
+	)
+}
+
+// RecentConflicts returns the recent conflicts with the own blocks of the
+// node, the most recent first.
+func (fc *forkChoice) RecentConflicts() []Conflict {
+	return fc.conflicts.Recent()
+}
@@ -0,0 +1,81 @@
+package avail
+
+import (
+	"testing"
+
+	"github.com/availproject/op-evm/pkg/avail"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConflictLogOverwritesOldest(t *testing.T) {
+	var l conflictLog
+
+	assert.Empty(t, l.Recent())
+
+	for height := uint64(1); height <= conflictLogSize+2; height++ {
+		l.add(Conflict{Height: height})
+	}
+
+	recent := l.Recent()
+	if assert.Len(t, recent, conflictLogSize) {
+		assert.Equal(t, uint64(conflictLogSize+2), recent[0].Height)
+		assert.Equal(t, uint64(3), recent[conflictLogSize-1].Height)
+	}
+}
+
+func TestForkChoiceRecordsConflicts(t *testing.T) {
+	a, b := newTestGenesisAvail(t), newTestGenesisAvail(t)
+
+	blkA := buildTestBlock(t, a)
+	blkB := buildTestBlock(t, b)
+
+	// a has its own block written ahead of the Avail stream, and the block of
+	// b, the scheduled leader, competes with it.
+	if err := a.blockchain.WriteBlock(blkA, a.nodeType.String()); err != nil {
+		t.Fatal(err)
+	}
+
+	inclusionB := blockInclusion{availBlock: 1, extrinsicIndex: 0, byLeader: true}
+
+	for i := 0; i < 2; i++ {
+		_, err := a.forkChoice.apply(blkB, inclusionB, a.nodeType.String())
+		assert.NoError(t, err)
+	}
+
+	assert.Equal(t, blkB.Hash(), a.blockchain.Header().Hash)
+
+	// The conflict is recorded once and read back over JSON-RPC.
+	srv, err := avail.NewSettlementRPCServer(avail.NewSettlementIndex())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := srv.RegisterName(avail.SettlementNamespace, NewStatusAPI(a)); err != nil {
+		t.Fatal(err)
+	}
+
+	c := rpc.DialInProc(srv)
+	defer c.Close()
+
+	var conflicts []Conflict
+	if err := c.Call(&conflicts, "avail_getRecentConflicts"); err != nil {
+		t.Fatal(err)
+	}
+
+	if assert.Len(t, conflicts, 1) {
+		conflict := conflicts[0]
+		assert.Equal(t, uint64(1), conflict.Height)
+		assert.Equal(t, blkA.Hash(), conflict.OwnBlockHash)
+		assert.Equal(t, blkB.Hash(), conflict.WinningBlockHash)
+		assert.Equal(t, b.minerAddr, conflict.WinnerMiner)
+		assert.Equal(t, ConflictReorged, conflict.Resolution)
+		assert.Equal(t, uint64(1), conflict.ReorgDepth)
+		assert.False(t, conflict.ObservedAt.IsZero())
+	}
+
+	// The node without a block of its own at the height has no conflict.
+	_, err = b.forkChoice.apply(blkA, blockInclusion{availBlock: 1}, b.nodeType.String())
+	assert.NoError(t, err)
+	assert.Empty(t, b.forkChoice.RecentConflicts())
+}
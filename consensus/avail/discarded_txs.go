@@ -0,0 +1,138 @@
+package avail
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/0xPolygon/polygon-edge/state"
+	"github.com/0xPolygon/polygon-edge/txpool"
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/pkg/blockchain"
+	"github.com/hashicorp/go-hclog"
+)
+
+// discardedBlocks returns the blocks from the old head down to, but not
+// including, the fork point, the oldest first.
+func discardedBlocks(bc *blockchain.Blockchain, oldHead *types.Header, forkPoint types.Hash) []*types.Block {
+	fork, ok := bc.GetHeaderByHash(forkPoint)
+	if !ok {
+		return nil
+	}
+
+	var discarded []*types.Block
+
+	for h := oldHead; h != nil && h.Number > fork.Number && h.Hash != forkPoint; {
+		blk, ok := bc.GetBlockByHash(h.Hash, true)
+		if !ok {
+			break
+		}
+
+		discarded = append([]*types.Block{blk}, discarded...)
+
+		if h, ok = bc.GetHeaderByHash(h.ParentHash); !ok {
+			break
+		}
+	}
+
+	return discarded
+}
+
+// requeueDiscardedTxs puts the transactions of the blocks a dispute
+// resolution block forked out of the chain, from the old head down to the
+// fork point, back into the txpool, for them to make it into the honest
+// blocks to come. The transactions of the malicious sequencer, the miner of
+// the first of the discarded blocks, are left out, and so are the ones
+// conflicting with the replacement chain: the ones whose nonce is taken on it
+// already, the ones re-included in it among them. It returns the number of
+// the transactions put back.
+//
+// The txpool keeps the next nonces of the senders past the discarded blocks,
+// so the senders put back are rolled back to the nonces of the state first,
+// their pending transactions put back along.
+func requeueDiscardedTxs(bc *blockchain.Blockchain, executor *state.Executor, txp *txpool.TxPool, index *txPoolIndex, oldHead *types.Header, forkPoint types.Hash, logger hclog.Logger) int {
+	discarded := discardedBlocks(bc, oldHead, forkPoint)
+	if len(discarded) == 0 {
+		return 0
+	}
+
+	malicious := types.BytesToAddress(discarded[0].Header.Miner)
+
+	head := bc.Header()
+
+	txn, err := executor.BeginTxn(head.StateRoot, head, types.ZeroAddress)
+	if err != nil {
+		logger.Error("failed to put the transactions of the discarded blocks back into the txpool", "error", err)
+		return 0
+	}
+
+	bySender := make(map[types.Address][]*types.Transaction)
+	conflicting := 0
+
+	for _, blk := range discarded {
+		for _, tx := range blk.Transactions {
+			if tx.From == malicious || tx.From == types.ZeroAddress {
+				continue
+			}
+
+			if tx.Nonce < txn.GetNonce(tx.From) {
+				conflicting++
+				continue
+			}
+
+			bySender[tx.From] = append(bySender[tx.From], tx)
+		}
+	}
+
+	requeued := 0
+
+	for addr, txs := range bySender {
+		nonce := txn.GetNonce(addr)
+
+		// The pending transactions of the sender go back in along with the
+		// discarded ones, in the order of their nonces.
+		fromBlocks := make(map[types.Hash]bool, len(txs))
+		for _, tx := range txs {
+			fromBlocks[tx.Hash] = true
+		}
+
+		for _, tx := range index.txs(addr) {
+			if tx.Nonce >= nonce && !fromBlocks[tx.Hash] {
+				txs = append(txs, tx)
+			}
+		}
+
+		sort.SliceStable(txs, func(i, j int) bool { return txs[i].Nonce < txs[j].Nonce })
+
+		// Dropping the sender rolls its next nonce back to the one of the
+		// transaction given, so it's the nonce of the state.
+		if txp.GetNonce(addr) > nonce {
+			drop := txs[0].Copy()
+			drop.Nonce = nonce
+			txp.Drop(drop)
+		}
+
+		for _, tx := range txs {
+			if err := txp.AddTx(tx.Copy()); err != nil {
+				if !errors.Is(err, txpool.ErrAlreadyKnown) {
+					logger.Debug("failed to put transaction back into the txpool", "hash", tx.Hash, "from", addr, "error", err)
+				}
+
+				continue
+			}
+
+			if fromBlocks[tx.Hash] {
+				requeued++
+			}
+		}
+	}
+
+	logger.Info(
+		"put the transactions of the discarded blocks back into the txpool",
+		"discarded_blocks", len(discarded),
+		"malicious_sequencer", malicious,
+		"requeued", requeued,
+		"conflicting", conflicting,
+	)
+
+	return requeued
+}
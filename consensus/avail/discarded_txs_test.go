@@ -0,0 +1,125 @@
+package avail
+
+import (
+	"testing"
+	"time"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/pkg/avail"
+	"github.com/availproject/op-evm/pkg/avail/testutil"
+	"github.com/availproject/op-evm/pkg/block"
+	"github.com/availproject/op-evm/pkg/staking"
+	avail_types "github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDisputeResolutionRequeuesDiscardedTxs(t *testing.T) {
+	sw, fraudResolver, _ := newTestSequencerWorker(t, testutil.NewFake(avail_types.NewUCompactFromUInt(1)))
+
+	alice, bob := newTestSender(t, sw), newTestSender(t, sw)
+	malicious, watchtower := newTestSender(t, sw), newTestSender(t, sw)
+
+	factory := block.NewBlockBuilderFactory(sw.blockchain, sw.executor, sw.logger)
+
+	// The transfer of alice, submitted to the node, goes in the block of the
+	// malicious sequencer, along with a transfer of bob and one of its own.
+	a0 := alice.transfer(t, 1)
+	if err := sw.txpool.AddTx(a0); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Eventually(t, func() bool { return sw.txpool.Length() == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	b0, m0 := bob.transfer(t, 1), malicious.transfer(t, 1)
+
+	bb, err := factory.FromBlockchainHead()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bb.SetCoinbaseAddress(malicious.addr)
+	bb.SignWith(malicious.key)
+	bb.AddTransactions(a0, b0, m0)
+
+	fraudulent, err := bb.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sw.applyAvailBlock(avail.EdgeBlock{Block: fraudulent}, blockInclusion{availBlock: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, fraudulent.Hash(), sw.blockchain.Header().Hash)
+	assert.Eventually(t, func() bool { return sw.txpool.Length() == 0 }, 5*time.Second, 10*time.Millisecond)
+
+	// The block proven fraudulent, the dispute resolution block forks it out
+	// of the chain; another transfer of bob takes the nonce of his one.
+	begin, err := staking.BeginDisputeResolutionTx(watchtower.addr, malicious.addr, 1_000_000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bob.nonce = 0
+	b0Replaced := bob.transfer(t, 2)
+
+	bb, err = factory.FromParentHash(fraudulent.ParentHash())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bb.SetCoinbaseAddress(watchtower.addr)
+	bb.SignWith(watchtower.key)
+	bb.AddTransactions(watchtower.sign(t, begin, 1), b0Replaced)
+
+	dispute, err := bb.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sw.applyAvailBlock(avail.EdgeBlock{Block: dispute}, blockInclusion{availBlock: 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, dispute.Hash(), sw.blockchain.Header().Hash)
+	assert.Equal(t, fraudulent.Number(), sw.blockchain.Header().Number)
+
+	// Only the transfer of alice goes back into the txpool; seen again, the
+	// dispute resolution block puts nothing back twice.
+	assert.Eventually(t, func() bool { return pooledTxs(sw)[a0.Hash] }, 5*time.Second, 10*time.Millisecond)
+
+	if err := sw.applyAvailBlock(avail.EdgeBlock{Block: dispute}, blockInclusion{availBlock: 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	pooled := pooledTxs(sw)
+	assert.Len(t, pooled, 1)
+	assert.False(t, pooled[b0.Hash], "conflicting transaction put back")
+	assert.False(t, pooled[m0.Hash], "transaction of the malicious sequencer put back")
+
+	// The transfer of alice executes in the next honest block.
+	account := accounts.Account{Address: common.Address(sw.nodeAddr)}
+	if err := sw.writeBlock(fraudResolver, account, &keystore.Key{PrivateKey: sw.nodeSignKey}); err != nil {
+		t.Fatal(err)
+	}
+
+	head := sw.blockchain.Header()
+	assert.Equal(t, dispute.Hash(), head.ParentHash)
+
+	blk, _ := sw.blockchain.GetBlockByHash(head.Hash, true)
+
+	included := make([]types.Hash, 0, len(blk.Transactions))
+	for _, tx := range blk.Transactions {
+		included = append(included, tx.Hash)
+	}
+
+	assert.Equal(t, []types.Hash{a0.Hash}, included)
+
+	receipts, err := sw.blockchain.GetReceiptsByHash(head.Hash)
+	if assert.NoError(t, err) && assert.Len(t, receipts, 1) {
+		assert.Equal(t, types.ReceiptSuccess, *receipts[0].Status)
+	}
+}
@@ -0,0 +1,219 @@
+package avail
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/0xPolygon/polygon-edge/types/buildroot"
+	"github.com/availproject/op-evm/pkg/avail"
+	"github.com/availproject/op-evm/pkg/block"
+	"github.com/availproject/op-evm/pkg/blockchain"
+	stypes "github.com/centrifuge/go-substrate-rpc-client/v4/types"
+)
+
+// disputeDefenseInterval is the number of Avail blocks the defenses of a
+// sequencer are accepted apart at least; the ones in between are dropped.
+const disputeDefenseInterval = 10
+
+// defenseOutcome is how a defense of a disputed sequencer went.
+type defenseOutcome string
+
+const (
+	// defenseSubmitted is a defense of the node submitted to Avail.
+	defenseSubmitted defenseOutcome = "submitted"
+
+	// defenseAccepted is a defense seen on Avail, taken into account by the
+	// verdict of its dispute.
+	defenseAccepted defenseOutcome = "accepted"
+
+	// defenseDropped is a defense seen on Avail, dropped for not coming from
+	// the sequencer of a disputed block, or too soon after its last one.
+	defenseDropped defenseOutcome = "dropped"
+)
+
+// ConstructDefense re-verifies the block of the node accused of fraud and
+// returns the defense of it if the block holds: a block signed by the node,
+// built on the parent of the accused block the same as the fraud proofs,
+// carrying the results of its re-execution. The block holds if the signatures
+// of its transactions are valid, and the results of its re-execution are the
+// ones of the block. The check of the watchtower is no good for it, as the
+// disputed sequencer is out of the active set the signer of the block is
+// checked against.
+func (f *Fraud) ConstructDefense(accused *types.Block) (*types.Block, error) {
+	if miner := types.BytesToAddress(accused.Header.Miner); miner != f.nodeAddr {
+		return nil, fmt.Errorf("block %s of %s isn't of the node", accused.Hash(), miner)
+	}
+
+	if err := block.VerifyTxSignatures(accused, f.blockchain.TxSigner()); err != nil {
+		return nil, fmt.Errorf("block %s doesn't hold: %w", accused.Hash(), err)
+	}
+
+	defense, err := executionResults(f.blockchain, accused)
+	if err != nil {
+		return nil, fmt.Errorf("block %s can't be re-executed: %w", accused.Hash(), err)
+	}
+
+	if !defense.Matches(accused.Header) {
+		return nil, fmt.Errorf("block %s doesn't hold: re-execution results differ", accused.Hash())
+	}
+
+	bb, err := block.NewBlockBuilderFactory(f.blockchain, f.executor, f.logger).FromParentHash(accused.ParentHash())
+	if err != nil {
+		return nil, err
+	}
+
+	return bb.
+		SetCoinbaseAddress(f.nodeAddr).
+		SetGasLimit(accused.Header.GasLimit).
+		SetExtraDataField(block.KeyDisputeDefenseOf, accused.Hash().Bytes()).
+		SetExtraDataField(block.KeyDisputeDefenseResult, defense.Bytes()).
+		SignWith(f.nodeSignKey).
+		Build()
+}
+
+// executionResults re-executes the block on the state of its parent and
+// returns the results of it.
+func executionResults(b *blockchain.Blockchain, blk *types.Block) (block.DisputeDefense, error) {
+	result, err := b.ExecuteBlock(blk)
+	if err != nil {
+		return block.DisputeDefense{}, err
+	}
+
+	return block.DisputeDefense{
+		StateRoot:    result.Root,
+		ReceiptsRoot: buildroot.CalculateReceiptsRoot(result.Receipts),
+		GasUsed:      result.TotalGas,
+	}, nil
+}
+
+// Defend submits the defense of the block of the node accused of fraud to
+// Avail, see ConstructDefense. A block is defended once; the block that
+// doesn't hold isn't re-verified either.
+func (f *Fraud) Defend(accused *types.Block) error {
+	if _, ok := f.defended[accused.Hash()]; ok {
+		return nil
+	}
+
+	f.defended[accused.Hash()] = struct{}{}
+
+	defense, err := f.ConstructDefense(accused)
+	if err != nil {
+		return err
+	}
+
+	f.logger.Info("Sending dispute defense block to the Avail", "hash", defense.Hash(), "defended_block_hash", accused.Hash())
+
+	if _, err := f.availSender.SendAndWaitForStatus(f.ctx, defense, stypes.ExtrinsicStatus{IsInBlock: true}, avail.WithTip(f.fraudTip)); err != nil {
+		// The submission is retried on the next Avail block.
+		delete(f.defended, accused.Hash())
+		return err
+	}
+
+	observeDefense(defenseSubmitted)
+
+	return nil
+}
+
+// IsDisputeDefenseBlock checks if the given block is the defense of a block
+// accused of fraud, by its sequencer.
+func (f *Fraud) IsDisputeDefenseBlock(blk *types.Block) bool {
+	_, _, ok := block.GetExtraDataDisputeDefense(blk.Header)
+	return ok
+}
+
+// defendDisputes has the fraud resolver defend the blocks of the sequencer
+// the fraud proofs of the open disputes accuse.
+func (sw *SequencerWorker) defendDisputes(fraudResolver *Fraud) {
+	if sw.disputeWatcher == nil {
+		return
+	}
+
+	for _, hash := range sw.disputeWatcher.disputedBlocks(sw.nodeAddr) {
+		blk, ok := sw.blockchain.GetBlockByHash(hash, true)
+		if !ok {
+			continue
+		}
+
+		if err := fraudResolver.Defend(blk); err != nil {
+			sw.logger.Warn("failed to defend the disputed block", "block_hash", hash, "error", err)
+		}
+	}
+}
+
+// disputedBlocks returns the hashes of the blocks of the sequencer accused by
+// the fraud proof of its open dispute, if any.
+func (w *disputeWatcher) disputedBlocks(sequencer types.Address) []types.Hash {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	d, ok := w.open[sequencer]
+	if !ok {
+		return nil
+	}
+
+	target, ok := w.proofs[d.watchtower]
+	if !ok {
+		return nil
+	}
+
+	return []types.Hash{target}
+}
+
+// observeDefenses records the defenses among the edge blocks received from
+// Avail, in the Avail block at the given height, for the verdict of their
+// dispute. A defense is accepted only from the sequencer of the defended
+// block, under the open dispute of a fraud proof of it, and a sequencer's
+// defenses only disputeDefenseInterval Avail blocks apart.
+func (w *disputeWatcher) observeDefenses(availHeight uint64, blks []avail.EdgeBlock) {
+	for _, decoded := range blks {
+		target, defense, ok := block.GetExtraDataDisputeDefense(decoded.Block.Header)
+		if !ok {
+			continue
+		}
+
+		if err := w.acceptDefense(availHeight, decoded.Block.Header, target, defense); err != nil {
+			observeDefense(defenseDropped)
+			w.logger.Warn("dispute defense dropped", "defense_block_hash", decoded.Block.Hash(), "block_hash", target, "error", err)
+
+			continue
+		}
+
+		observeDefense(defenseAccepted)
+		w.logger.Info("dispute defense accepted", "defense_block_hash", decoded.Block.Hash(), "block_hash", target, "avail_block_number", availHeight)
+	}
+}
+
+func (w *disputeWatcher) acceptDefense(availHeight uint64, h *types.Header, target types.Hash, defense block.DisputeDefense) error {
+	defended, ok := w.blockchain.GetHeaderByHash(target)
+	if !ok {
+		return errors.New("defended block not found")
+	}
+
+	sequencer := types.BytesToAddress(defended.Miner)
+
+	signer, err := block.AddressRecoverFromHeader(h)
+	if err != nil {
+		return err
+	}
+
+	if signer != sequencer {
+		return fmt.Errorf("signed by %s, not the sequencer %s of the block", signer, sequencer)
+	}
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if d, ok := w.open[sequencer]; !ok || w.proofs[d.watchtower] != target {
+		return errors.New("block not under dispute")
+	}
+
+	if last, ok := w.defendedAt[sequencer]; ok && availHeight < last+disputeDefenseInterval {
+		return fmt.Errorf("last defense of the sequencer accepted at avail block %d", last)
+	}
+
+	w.defendedAt[sequencer] = availHeight
+	w.defenses[target] = defense
+
+	return nil
+}
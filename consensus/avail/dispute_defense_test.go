@@ -0,0 +1,221 @@
+package avail
+
+import (
+	"bytes"
+	"math/big"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/consensus/avail/watchtower"
+	"github.com/availproject/op-evm/pkg/avail"
+	"github.com/availproject/op-evm/pkg/avail/testutil"
+	"github.com/availproject/op-evm/pkg/block"
+	"github.com/availproject/op-evm/pkg/common"
+	"github.com/availproject/op-evm/pkg/staking"
+	"github.com/availproject/op-evm/pkg/test"
+	avail_types "github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDisputeDefense(t *testing.T) {
+	const window = 3
+
+	tests := []struct {
+		name    string
+		defense bool
+	}{
+		{name: "without defense"},
+		{name: "with defense", defense: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, apq := NewTestAvail(t, Sequencer)
+			sw, fraudResolver, _ := newTestSequencerWorkerOf(t, d, testutil.NewFake(avail_types.NewUCompactFromUInt(1)))
+
+			sw.disputeWatcher = newDisputeWatcher(sw.blockchain, sw.executor, window, nil, hclog.Default())
+			sw.blockchain.RegisterPostCommitHook(sw.disputeWatcher.observe)
+
+			fraudResolver.watchtower = watchtower.New(sw.blockchain, sw.executor, sw.txpool, hclog.Default(), sw.nodeAddr, sw.nodeSignKey, 0)
+
+			sender := staking.NewTestAvailSender()
+			stake := big.NewInt(0).Mul(big.NewInt(10), common.ETH)
+
+			// The local sequencer, the accused one and the watchtower stake.
+			accused, accusedKey := test.NewAccount(t)
+			watchtowerAddr, watchtowerKey := test.NewAccount(t)
+
+			for _, addr := range []types.Address{accused, watchtowerAddr} {
+				test.DepositBalance(t, addr, big.NewInt(0).Mul(big.NewInt(1000), common.ETH), sw.blockchain, sw.executor)
+			}
+
+			if err := staking.Stake(sw.blockchain, sw.executor, sender, hclog.Default(), string(staking.Sequencer), sw.nodeAddr, sw.nodeSignKey, stake, 1_000_000, "test"); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := staking.Stake(sw.blockchain, sw.executor, sender, hclog.Default(), string(staking.Sequencer), accused, accusedKey, stake, 1_000_000, "test"); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := staking.Stake(sw.blockchain, sw.executor, sender, hclog.Default(), string(staking.WatchTower), watchtowerAddr, watchtowerKey, stake, 1_000_000, "test"); err != nil {
+				t.Fatal(err)
+			}
+
+			// The accused sequencer writes a valid block, which the watchtower
+			// falsely accuses of fraud.
+			bb, err := block.NewBlockBuilderFactory(sw.blockchain, sw.executor, sw.logger).FromBlockchainHead()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			bb.SetCoinbaseAddress(accused)
+			bb.SignWith(accusedKey)
+
+			accusedBlk, err := bb.Build()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := sw.applyAvailBlock(avail.EdgeBlock{Block: accusedBlk}, blockInclusion{availBlock: 1}); err != nil {
+				t.Fatal(err)
+			}
+
+			dr := staking.NewDisputeResolution(sw.blockchain, sw.executor, sender, hclog.Default())
+			if err := dr.Begin(accused, watchtowerKey); err != nil {
+				t.Fatal(err)
+			}
+
+			began := sw.blockchain.Header().Number
+
+			assert.Eventually(t, func() bool {
+				sw.disputeWatcher.lock.Lock()
+				defer sw.disputeWatcher.lock.Unlock()
+
+				_, ok := sw.disputeWatcher.open[accused]
+				return ok
+			}, 5*time.Second, 10*time.Millisecond)
+
+			// The dispute accuses no block of the sequencer until the fraud
+			// proof shows up.
+			assert.Empty(t, sw.disputeWatcher.disputedBlocks(accused))
+
+			sw.disputeWatcher.observeFraudProofs([]avail.EdgeBlock{{Block: &types.Block{Header: &types.Header{
+				Miner:     watchtowerAddr.Bytes(),
+				ExtraData: block.EncodeExtraDataFields(map[string][]byte{block.KeyFraudProofOf: accusedBlk.Hash().Bytes()}),
+			}}}})
+
+			assert.Equal(t, []types.Hash{accusedBlk.Hash()}, sw.disputeWatcher.disputedBlocks(accused))
+
+			if tt.defense {
+				// The accused sequencer defends its block on Avail, once.
+				accusedAvail := testutil.NewFake(avail_types.NewUCompactFromUInt(1))
+				accusedWatchtower := watchtower.New(sw.blockchain, sw.executor, nil, hclog.Default(), accused, accusedKey, 0)
+				accusedResolver := NewFraudResolver(sw.ctx, hclog.Default(), sw.blockchain, sw.executor, sw.txpool, sw.poolIndex, accusedWatchtower, nil, nil, nil, new(atomic.Bool), accused, accusedKey, accusedAvail, 0, DefaultFraudProofQuorum, Sequencer)
+
+				for i := 0; i < 2; i++ {
+					if err := accusedResolver.Defend(accusedBlk); err != nil {
+						t.Fatal(err)
+					}
+				}
+
+				submitted, err := accusedAvail.EdgeBlocks()
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if !assert.Len(t, submitted, 1) {
+					return
+				}
+
+				defense := submitted[0]
+				assert.True(t, accusedResolver.IsDisputeDefenseBlock(defense))
+
+				// The defense is accepted only from the accused sequencer, and
+				// once in a while.
+				_, err = fraudResolver.ConstructDefense(accusedBlk)
+				assert.Error(t, err)
+
+				forged, err := block.WriteSeal(watchtowerKey, defense.Header)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				sw.disputeWatcher.observeDefenses(1, []avail.EdgeBlock{{Block: &types.Block{Header: forged}}})
+				assert.Empty(t, sw.disputeWatcher.defenses)
+
+				sw.disputeWatcher.observeDefenses(2, []avail.EdgeBlock{{Block: defense}})
+				sw.disputeWatcher.observeDefenses(2+disputeDefenseInterval-1, []avail.EdgeBlock{{Block: defense}})
+				assert.Len(t, sw.disputeWatcher.defenses, 1)
+				assert.Equal(t, uint64(2), sw.disputeWatcher.defendedAt[accused])
+			}
+
+			accusedStakeBefore, err := apq.GetBalance(accused)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			watchtowerStakeBefore, err := apq.GetBalance(watchtowerAddr)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			clock, stop := startWriteBlocksLoop(t, sw, fraudResolver)
+			defer stop()
+
+			for number := began + 1; number <= began+window+2; number++ {
+				clock.tick()
+				waitForBlock(t, sw, number)
+			}
+
+			endTx, _ := staking.EndDisputeResolutionTx(types.ZeroAddress, accused, 0)
+
+			var (
+				ends    int
+				slashed []types.Address
+			)
+
+			for number := began + 1; number <= sw.blockchain.Header().Number; number++ {
+				blk, ok := sw.blockchain.GetBlockByNumber(number, true)
+				if !assert.True(t, ok) {
+					continue
+				}
+
+				for _, tx := range blk.Transactions {
+					if staker, ok := staking.SlashedStaker(tx); ok {
+						slashed = append(slashed, staker)
+					} else if bytes.HasPrefix(tx.Input, endTx.Input[:4]) {
+						ends++
+					}
+				}
+			}
+
+			// Either way the dispute is over; the check of the leader can't
+			// tell the block of the disputed sequencer valid, so it's up to
+			// the defense which of the two is slashed.
+			inProbation, err := apq.InProbation(accused)
+			assert.NoError(t, err)
+			assert.False(t, inProbation)
+
+			accusedStakeAfter, err := apq.GetBalance(accused)
+			assert.NoError(t, err)
+
+			watchtowerStakeAfter, err := apq.GetBalance(watchtowerAddr)
+			assert.NoError(t, err)
+
+			if tt.defense {
+				assert.Equal(t, 0, ends)
+				assert.Equal(t, []types.Address{watchtowerAddr}, slashed)
+				assert.Equal(t, accusedStakeBefore, accusedStakeAfter)
+				assert.Equal(t, -1, watchtowerStakeAfter.Cmp(watchtowerStakeBefore))
+			} else {
+				assert.Equal(t, 1, ends)
+				assert.Equal(t, []types.Address{accused}, slashed)
+				assert.Equal(t, -1, accusedStakeAfter.Cmp(accusedStakeBefore))
+				assert.Equal(t, watchtowerStakeBefore, watchtowerStakeAfter)
+			}
+		})
+	}
+}
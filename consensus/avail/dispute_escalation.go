@@ -0,0 +1,333 @@
+package avail
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/pkg/staking"
+	"github.com/hashicorp/go-hclog"
+)
+
+// escalationWebhookTimeout bounds the post of an escalated dispute to the
+// escalation webhook.
+const escalationWebhookTimeout = 10 * time.Second
+
+// EscalatedDispute is a dispute left open past its escalation deadline, a
+// multiple of the challenge window: the leaders failed to end it, and the
+// disputed sequencer stays paused meanwhile. It's reported by the status API
+// and posted to the escalation webhook.
+type EscalatedDispute struct {
+	Sequencer  types.Address `json:"sequencer"`
+	Watchtower types.Address `json:"watchtower"`
+
+	// BeganAt is the number of the block the dispute began in, and
+	// EscalatedAt the one it escalated at.
+	BeganAt     uint64 `json:"beganAt"`
+	EscalatedAt uint64 `json:"escalatedAt"`
+}
+
+// disputeEscalation is how the disputes left open too long escalate.
+type disputeEscalation struct {
+	// after is the number of blocks a dispute stays open before it
+	// escalates.
+	after uint64
+
+	// submit has the node submit the transactions ending the escalated
+	// disputes to the txpool when it doesn't lead the slot.
+	submit bool
+
+	webhook *escalationWebhook
+}
+
+// setEscalation has the disputes open for the given multiple of the
+// challenge window escalate, posted to the webhook, if any, and ended by the
+// node out of its slots when submit is set.
+func (w *disputeWatcher) setEscalation(multiple uint64, submit bool, webhook *escalationWebhook) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.escalation = disputeEscalation{after: multiple * w.window, submit: submit, webhook: webhook}
+}
+
+// setEscalationWebhook has the disputes escalating from now on posted to the
+// webhook, or to none if nil.
+func (w *disputeWatcher) setEscalationWebhook(webhook *escalationWebhook) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.escalation.webhook = webhook
+}
+
+// escalateDue marks the disputes open past the escalation deadline as of the
+// last block observed as escalated, and returns the ones newly escalated. It
+// must be called with the lock held.
+func (w *disputeWatcher) escalateDue() []EscalatedDispute {
+	var escalated []EscalatedDispute
+
+	for _, d := range w.open {
+		if d.escalatedAt != 0 || w.observed < d.beganAt+w.escalation.after {
+			continue
+		}
+
+		d.escalatedAt = w.observed
+		escalated = append(escalated, d.escalated())
+	}
+
+	return escalated
+}
+
+// notifyEscalation raises the alert of the dispute escalated in the block.
+func (w *disputeWatcher) notifyEscalation(e EscalatedDispute, blk *types.Block) {
+	w.lock.Lock()
+	target, webhook := w.proofs[e.Watchtower], w.escalation.webhook
+	w.lock.Unlock()
+
+	w.logger.Error("dispute unresolved past the escalation deadline; the disputed sequencer stays paused", "sequencer_addr", e.Sequencer, "watchtower_addr", e.Watchtower, "began_at", e.BeganAt, "escalated_at", e.EscalatedAt)
+	observeDisputeEscalation()
+
+	w.feed.publish(DisputeEvent{
+		Type:             DisputeEscalated,
+		Sequencer:        e.Sequencer,
+		Watchtower:       e.Watchtower,
+		AccusedBlockHash: target,
+		BlockNumber:      blk.Number(),
+		BlockHash:        blk.Hash(),
+	})
+
+	webhook.notify(e)
+}
+
+// escalated returns the disputes open past the escalation deadline, the
+// oldest first.
+func (w *disputeWatcher) escalated() []EscalatedDispute {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	var escalated []EscalatedDispute
+
+	for _, d := range w.open {
+		if d.escalatedAt != 0 {
+			escalated = append(escalated, d.escalated())
+		}
+	}
+
+	sort.Slice(escalated, func(i, j int) bool { return escalated[i].BeganAt < escalated[j].BeganAt })
+
+	return escalated
+}
+
+// dueEscalated returns the escalated disputes the node is to submit the
+// transactions ending of as of the block of the given number, marking them as
+// submitted: the ones not being ended by the leader, of other parties than
+// the node. The submission is repeated every challenge window while the
+// dispute stays open.
+func (w *disputeWatcher) dueEscalated(number uint64, self types.Address) []openDispute {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if !w.escalation.submit {
+		return nil
+	}
+
+	var due []openDispute
+
+	for _, d := range w.open {
+		if d.escalatedAt == 0 || d.endingAt != 0 || d.sequencer == self || d.watchtower == self {
+			continue
+		}
+
+		if d.submittedAt != 0 && number < d.submittedAt+w.window {
+			continue
+		}
+
+		d.submittedAt = number
+		due = append(due, *d)
+	}
+
+	return due
+}
+
+// escalatedEndTxs returns the signed transaction ending the escalated
+// dispute, by its verdict, to be submitted to the txpool. Unlike the leader,
+// the node submits a single transaction, for the ones of several nodes not to
+// be split across blocks: the slash of the party at fault, if any, ends the
+// dispute on the staking contract by itself.
+func (w *disputeWatcher) escalatedEndTxs(d openDispute, from types.Address, nonce uint64, signKey *ecdsa.PrivateKey, check func(*types.Block) error) ([]*types.Transaction, error) {
+	verdict := w.verdict(d, check)
+
+	end, slash, err := settlementTxs(d, verdict, from)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := end
+	if slash != nil {
+		tx = slash
+	}
+
+	w.logger.Warn("ending the escalated dispute out of the slot", "sequencer_addr", d.sequencer, "watchtower_addr", d.watchtower, "began_at", d.beganAt, "verdict", verdict)
+
+	return signDisputeTxs([]*types.Transaction{tx}, nonce, signKey)
+}
+
+// admit reports whether the transaction of the txpool settles a dispute,
+// ending it or slashing a party of it, and whether it's to be written to the
+// block, given the disputes settled in it so far, by the disputed sequencer.
+// The escalated disputes may be settled by any staked sequencer, so their
+// settlements come in more than once: only the first one is written, while
+// the dispute is open and not being ended by the leader; the others would
+// revert, or slash twice.
+func (w *disputeWatcher) admit(tx *types.Transaction, settled map[types.Address]bool) (bool, bool) {
+	if w == nil {
+		return false, false
+	}
+
+	account, ended := staking.EndedDispute(tx)
+	if !ended {
+		var ok bool
+		if account, ok = staking.SlashedStaker(tx); !ok {
+			return false, false
+		}
+	}
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	for sequencer, d := range w.open {
+		if sequencer != account && d.watchtower != account {
+			continue
+		}
+
+		if d.endingAt != 0 || settled[sequencer] {
+			return true, false
+		}
+
+		settled[sequencer] = true
+
+		return true, true
+	}
+
+	return true, false
+}
+
+// submitEscalatedDisputeEnds submits the transactions ending the escalated
+// disputes to the txpool, signed by the node out of its slots, for the
+// leaders to come to write; the leaders failing to end them, any staked
+// sequencer does.
+func (sw *SequencerWorker) submitEscalatedDisputeEnds(fraudResolver *Fraud, from types.Address, signKey *ecdsa.PrivateKey) {
+	if sw.disputeWatcher == nil {
+		return
+	}
+
+	due := sw.disputeWatcher.dueEscalated(sw.blockchain.Header().Number+1, from)
+	if len(due) == 0 {
+		return
+	}
+
+	var check func(*types.Block) error
+	if fraudResolver.watchtower != nil {
+		check = fraudResolver.watchtower.Check
+	}
+
+	nonce, err := sw.nextNonce(from)
+	if err != nil {
+		sw.logger.Error("failed to get the nonce to end the escalated disputes with", "error", err)
+		return
+	}
+
+	for _, d := range due {
+		txs, err := sw.disputeWatcher.escalatedEndTxs(d, from, nonce, signKey, check)
+		if err != nil {
+			sw.logger.Error("failed to construct the transaction ending the escalated dispute", "sequencer_addr", d.sequencer, "error", err)
+			continue
+		}
+
+		for _, tx := range txs {
+			if err := sw.txpool.AddTx(tx); err != nil {
+				sw.logger.Error("failed to submit the transaction ending the escalated dispute", "sequencer_addr", d.sequencer, "hash", tx.Hash, "error", err)
+				break
+			}
+
+			nonce++
+		}
+	}
+}
+
+// nextNonce returns the nonce of the next transaction of the account, past
+// the ones of its waiting in the txpool.
+func (sw *SequencerWorker) nextNonce(addr types.Address) (uint64, error) {
+	head := sw.blockchain.Header()
+
+	txn, err := sw.executor.BeginTxn(head.StateRoot, head, types.ZeroAddress)
+	if err != nil {
+		return 0, err
+	}
+
+	nonce := txn.GetNonce(addr)
+
+	for _, tx := range sw.poolIndex.txs(addr) {
+		if tx.Nonce >= nonce {
+			nonce = tx.Nonce + 1
+		}
+	}
+
+	return nonce, nil
+}
+
+// escalationWebhook posts the escalated disputes, as JSON, to the URL of the
+// alerting of the operators. The nil escalationWebhook posts nothing.
+type escalationWebhook struct {
+	url    string
+	client *http.Client
+	logger hclog.Logger
+}
+
+// newEscalationWebhook returns the escalationWebhook posting to the given
+// URL, or nil for none.
+func newEscalationWebhook(url string, logger hclog.Logger) *escalationWebhook {
+	if url == "" {
+		return nil
+	}
+
+	return &escalationWebhook{url: url, client: &http.Client{Timeout: escalationWebhookTimeout}, logger: logger}
+}
+
+// notify posts the escalated dispute in the background.
+func (h *escalationWebhook) notify(e EscalatedDispute) {
+	if h == nil {
+		return
+	}
+
+	go func() {
+		if err := h.post(e); err != nil {
+			h.logger.Error("failed to post the escalated dispute to the webhook", "sequencer_addr", e.Sequencer, "error", err)
+		}
+	}()
+}
+
+// post posts the escalated dispute.
+func (h *escalationWebhook) post(e EscalatedDispute) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	resp, err := h.client.Post(h.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook responded %s", resp.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,269 @@
+package avail
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/pkg/avail"
+	"github.com/availproject/op-evm/pkg/avail/testutil"
+	"github.com/availproject/op-evm/pkg/block"
+	"github.com/availproject/op-evm/pkg/common"
+	"github.com/availproject/op-evm/pkg/staking"
+	"github.com/availproject/op-evm/pkg/test"
+	avail_types "github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDisputeWatcherEscalatesDisputes(t *testing.T) {
+	const (
+		window   = 3
+		multiple = 2
+		began    = 10
+	)
+
+	posted := make(chan EscalatedDispute, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var e EscalatedDispute
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&e))
+		posted <- e
+	}))
+	defer srv.Close()
+
+	feed := newDisputeFeed()
+	events, unsubscribe := feed.subscribe()
+	defer unsubscribe()
+
+	w := newDisputeWatcher(nil, nil, window, feed, hclog.NewNullLogger())
+	w.setEscalation(multiple, true, newEscalationWebhook(srv.URL, hclog.NewNullLogger()))
+
+	sequencer, watchtower, self := types.StringToAddress("1"), types.StringToAddress("2"), types.StringToAddress("3")
+	assert.True(t, w.begin(sequencer, watchtower, began))
+	assert.True(t, w.begin(self, watchtower, began+1))
+
+	observe := func(number uint64) {
+		w.observe(&types.Block{Header: &types.Header{Number: number}}, nil)
+	}
+
+	// The dispute escalates once open for the multiple of the window.
+	observe(began + multiple*window - 1)
+	assert.Empty(t, w.escalated())
+	assert.Empty(t, w.dueEscalated(began+multiple*window, types.ZeroAddress))
+
+	observe(began + multiple*window)
+
+	want := EscalatedDispute{Sequencer: sequencer, Watchtower: watchtower, BeganAt: began, EscalatedAt: began + multiple*window}
+	assert.Equal(t, []EscalatedDispute{want}, w.escalated())
+
+	select {
+	case e := <-posted:
+		assert.Equal(t, want, e)
+	case <-time.After(5 * time.Second):
+		t.Fatal("escalated dispute not posted to the webhook")
+	}
+
+	for e := range events {
+		if e.Type == DisputeEscalated {
+			assert.Equal(t, sequencer, e.Sequencer)
+			assert.Equal(t, uint64(began+multiple*window), e.BlockNumber)
+			break
+		}
+	}
+
+	// Escalating once, the dispute is submitted for ending every window,
+	// unless the node is a party of it.
+	observe(began + multiple*window + 1)
+	assert.Len(t, w.escalated(), 2)
+
+	number := uint64(began + multiple*window + 2)
+	due := w.dueEscalated(number, self)
+	if assert.Len(t, due, 1) {
+		assert.Equal(t, sequencer, due[0].sequencer)
+	}
+
+	assert.Empty(t, w.dueEscalated(number+window-1, self))
+	assert.Len(t, w.dueEscalated(number+window, self), 1)
+
+	// The disputes being ended by the leader are left to it.
+	w.lock.Lock()
+	w.open[sequencer].endingAt = number + 2*window
+	w.lock.Unlock()
+
+	assert.Empty(t, w.dueEscalated(number+2*window, self))
+}
+
+func TestDisputeWatcherEscalationSubmitOff(t *testing.T) {
+	w := newDisputeWatcher(nil, nil, 1, nil, hclog.NewNullLogger())
+	w.setEscalation(1, false, nil)
+
+	sequencer := types.StringToAddress("1")
+	w.begin(sequencer, types.StringToAddress("2"), 1)
+	w.observe(&types.Block{Header: &types.Header{Number: 5}}, nil)
+
+	assert.Len(t, w.escalated(), 1)
+	assert.Empty(t, w.dueEscalated(6, types.ZeroAddress))
+}
+
+func TestEscalatedDisputeEndedOutOfSlot(t *testing.T) {
+	const (
+		window   = 3
+		multiple = 2
+	)
+
+	d, apq := NewTestAvail(t, Sequencer)
+	sw, fraudResolver, _ := newTestSequencerWorkerOf(t, d, testutil.NewFake(avail_types.NewUCompactFromUInt(1)))
+
+	// The leader never ends the dispute itself.
+	sw.disputeWatcher = newDisputeWatcher(sw.blockchain, sw.executor, 1_000_000, nil, hclog.Default())
+	sw.blockchain.RegisterPostCommitHook(sw.disputeWatcher.observe)
+
+	sender := staking.NewTestAvailSender()
+	stake := big.NewInt(0).Mul(big.NewInt(10), common.ETH)
+
+	accused, accusedKey := test.NewAccount(t)
+	watchtower, watchtowerKey := test.NewAccount(t)
+
+	// Two other staked sequencers end the escalated dispute out of their
+	// slots.
+	type submitter struct {
+		sw            *SequencerWorker
+		fraudResolver *Fraud
+	}
+
+	var submitters []submitter
+
+	for i := 0; i < 2; i++ {
+		nl, nlFraudResolver, _ := newTestSequencerWorkerOf(t, d, testutil.NewFake(avail_types.NewUCompactFromUInt(1)))
+		nl.nodeAddr, nl.nodeSignKey = test.NewAccount(t)
+		nlFraudResolver.watchtower = testFraudulentBlocks{}
+
+		nl.disputeWatcher = newDisputeWatcher(nl.blockchain, nl.executor, window, nil, hclog.Default())
+		nl.disputeWatcher.setEscalation(multiple, true, nil)
+		sw.blockchain.RegisterPostCommitHook(nl.disputeWatcher.observe)
+
+		submitters = append(submitters, submitter{sw: nl, fraudResolver: nlFraudResolver})
+	}
+
+	for _, addr := range []types.Address{accused, watchtower, submitters[0].sw.nodeAddr, submitters[1].sw.nodeAddr} {
+		test.DepositBalance(t, addr, big.NewInt(0).Mul(big.NewInt(1000), common.ETH), sw.blockchain, sw.executor)
+	}
+
+	if err := staking.Stake(sw.blockchain, sw.executor, sender, hclog.Default(), string(staking.Sequencer), sw.nodeAddr, sw.nodeSignKey, stake, 1_000_000, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := staking.Stake(sw.blockchain, sw.executor, sender, hclog.Default(), string(staking.Sequencer), accused, accusedKey, stake, 1_000_000, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := staking.Stake(sw.blockchain, sw.executor, sender, hclog.Default(), string(staking.WatchTower), watchtower, watchtowerKey, stake, 1_000_000, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range submitters {
+		if err := staking.Stake(sw.blockchain, sw.executor, sender, hclog.Default(), string(staking.Sequencer), s.sw.nodeAddr, s.sw.nodeSignKey, stake, 1_000_000, "test"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	target := sw.blockchain.Header().Hash
+
+	dr := staking.NewDisputeResolution(sw.blockchain, sw.executor, sender, hclog.Default())
+	if err := dr.Begin(accused, watchtowerKey); err != nil {
+		t.Fatal(err)
+	}
+
+	began := sw.blockchain.Header().Number
+
+	for _, s := range submitters {
+		s.sw.disputeWatcher.observeFraudProofs([]avail.EdgeBlock{{Block: &types.Block{Header: &types.Header{
+			Miner:     watchtower.Bytes(),
+			ExtraData: block.EncodeExtraDataFields(map[string][]byte{block.KeyFraudProofOf: target.Bytes()}),
+		}}}})
+	}
+
+	stakeBefore, err := apq.GetBalance(accused)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clock, stop := startWriteBlocksLoop(t, sw, fraudResolver)
+	defer stop()
+
+	// The dispute escalates, and both submitters end it out of their slots.
+	for number := began + 1; number <= began+multiple*window; number++ {
+		clock.tick()
+		waitForBlock(t, sw, number)
+	}
+
+	for _, s := range submitters {
+		assert.Len(t, s.sw.disputeWatcher.escalated(), 1)
+		s.sw.submitEscalatedDisputeEnds(s.fraudResolver, s.sw.nodeAddr, s.sw.nodeSignKey)
+	}
+
+	for _, s := range submitters {
+		s := s
+		assert.Eventually(t, func() bool {
+			promoted, _ := sw.txpool.GetTxs(false)
+			return len(promoted[s.sw.nodeAddr]) == 1
+		}, 5*time.Second, 10*time.Millisecond)
+	}
+
+	for number := began + multiple*window + 1; number <= began+multiple*window+2; number++ {
+		clock.tick()
+		waitForBlock(t, sw, number)
+	}
+
+	inProbation, err := apq.InProbation(accused)
+	assert.NoError(t, err)
+	assert.False(t, inProbation)
+
+	endTx, _ := staking.EndDisputeResolutionTx(types.ZeroAddress, accused, 0)
+	slashTx, _ := staking.SlashStakerTx(types.ZeroAddress, accused, 0)
+
+	var ends, slashes int
+
+	for number := began + 1; number <= sw.blockchain.Header().Number; number++ {
+		blk, ok := sw.blockchain.GetBlockByNumber(number, true)
+		if !assert.True(t, ok) {
+			continue
+		}
+
+		receipts, err := sw.blockchain.GetReceiptsByHash(blk.Hash())
+		assert.NoError(t, err)
+
+		for i, tx := range blk.Transactions {
+			switch {
+			case bytes.Equal(tx.Input[:4], endTx.Input[:4]):
+				ends++
+			case bytes.Equal(tx.Input[:4], slashTx.Input[:4]):
+				slashes++
+			default:
+				continue
+			}
+
+			assert.NotEqual(t, sw.nodeAddr, tx.From)
+			assert.Equal(t, types.ReceiptSuccess, *receipts[i].Status)
+		}
+	}
+
+	// The slash of the sequencer at fault ends the dispute by itself.
+	assert.Equal(t, 0, ends)
+	assert.Equal(t, 1, slashes)
+
+	stakeAfter, err := apq.GetBalance(accused)
+	assert.NoError(t, err)
+	assert.Equal(t, new(big.Int).Div(new(big.Int).Mul(stakeBefore, big.NewInt(99)), big.NewInt(100)), stakeAfter, "sequencer not slashed exactly once")
+
+	// The duplicate settlement is dropped from the txpool.
+	promoted, _ := sw.txpool.GetTxs(true)
+	for _, s := range submitters {
+		assert.Empty(t, promoted[s.sw.nodeAddr])
+	}
+}
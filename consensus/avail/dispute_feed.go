@@ -0,0 +1,188 @@
+package avail
+
+import (
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+const (
+	// disputeFeedSize is the number of the most recent dispute events kept
+	// for `avail_getDisputeEvents`, and buffered for each subscriber.
+	disputeFeedSize = 1024
+
+	// disputeEventsPageSize is the most dispute events returned at once by
+	// `avail_getDisputeEvents`.
+	disputeEventsPageSize = 100
+)
+
+// DisputeEventType is the kind of a dispute event.
+type DisputeEventType string
+
+const (
+	// DisputeOpened is the event of a dispute begun on the staking contract.
+	DisputeOpened DisputeEventType = "dispute_opened"
+
+	// DisputeEscalated is the event of a dispute left open past its
+	// escalation deadline.
+	DisputeEscalated DisputeEventType = "dispute_escalated"
+
+	// DisputeResolved is the event of a dispute ended on the staking
+	// contract, with the slash of either party or without one.
+	DisputeResolved DisputeEventType = "dispute_resolved"
+
+	// SequencerSlashed is the event of a disputed sequencer slashed.
+	SequencerSlashed DisputeEventType = "sequencer_slashed"
+
+	// WatchtowerSlashed is the event of a watchtower slashed for its fraud
+	// proof refuted.
+	WatchtowerSlashed DisputeEventType = "watchtower_slashed"
+
+	// BlocksReorgedOut is the event of the blocks proven fraudulent, and
+	// their descendants, rolled out of the chain.
+	BlocksReorgedOut DisputeEventType = "blocks_reorged_out"
+)
+
+// DisputeEvent is an event of the lifecycle of a dispute, as pushed to the
+// subscribers of the "disputes" subscription and returned by
+// `avail_getDisputeEvents`. The addresses and hashes not known for the event
+// are zero.
+type DisputeEvent struct {
+	// Cursor orders the events; the events following it are polled with
+	// it.
+	Cursor uint64           `json:"cursor"`
+	Type   DisputeEventType `json:"type"`
+
+	Sequencer  types.Address `json:"sequencer"`
+	Watchtower types.Address `json:"watchtower"`
+
+	// AccusedBlockHash is the hash of the block accused by the fraud proof
+	// of the dispute, once seen on Avail.
+	AccusedBlockHash types.Hash `json:"accusedBlockHash"`
+
+	// BlockNumber and BlockHash are of the block the event took place in;
+	// for the blocks reorged out, of the honest tip the chain is rolled
+	// back to.
+	BlockNumber uint64     `json:"blockNumber"`
+	BlockHash   types.Hash `json:"blockHash"`
+	TxHash      types.Hash `json:"txHash"`
+
+	// Outcome is how the dispute resolved, as the outcome of its fraud
+	// event, or how the slash went.
+	Outcome string `json:"outcome,omitempty"`
+
+	// SlashedAmount is the stake slashed off the staker of a slash event,
+	// paid by the staking contract to RewardRecipient: the watchtower of a
+	// sequencer slashed, the sequencer of a watchtower slashed.
+	SlashedAmount   *big.Int      `json:"slashedAmount,omitempty"`
+	RewardRecipient types.Address `json:"rewardRecipient"`
+
+	// ReorgedOut are the hashes of the blocks rolled out of the chain, the
+	// newest first.
+	ReorgedOut []types.Hash `json:"reorgedOut,omitempty"`
+
+	Time time.Time `json:"time"`
+}
+
+// DisputeEventsPage is a page of the dispute events, as returned by
+// `avail_getDisputeEvents`; Cursor is the one to poll the events following
+// the page with.
+type DisputeEventsPage struct {
+	Events []DisputeEvent `json:"events"`
+	Cursor uint64         `json:"cursor"`
+}
+
+// disputeFeed publishes the dispute events, from the staking events of the
+// blocks written to the chain and the rollbacks of the fraudulent blocks, to
+// its subscribers, and keeps the most recent disputeFeedSize of them for
+// polling. The events are kept in memory only; the cursors start over on a
+// restart. A subscriber falling disputeFeedSize events behind misses the
+// following ones, to be polled with the cursor of the last one received. The
+// nil disputeFeed publishes nothing.
+type disputeFeed struct {
+	lock   sync.Mutex
+	cursor uint64         // cursor of the last event published
+	events []DisputeEvent // the most recent, oldest first
+	subs   map[chan DisputeEvent]struct{}
+}
+
+func newDisputeFeed() *disputeFeed {
+	return &disputeFeed{subs: make(map[chan DisputeEvent]struct{})}
+}
+
+// publish publishes the event, stamped with the next cursor.
+func (f *disputeFeed) publish(e DisputeEvent) {
+	if f == nil {
+		return
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.cursor++
+
+	e.Cursor = f.cursor
+	e.Time = time.Now()
+
+	f.events = append(f.events, e)
+	if len(f.events) > disputeFeedSize {
+		f.events = f.events[len(f.events)-disputeFeedSize:]
+	}
+
+	for ch := range f.subs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// subscribe returns the channel the events published from now on are sent
+// to, and the function to unsubscribe with.
+func (f *disputeFeed) subscribe() (<-chan DisputeEvent, func()) {
+	ch := make(chan DisputeEvent, disputeFeedSize)
+
+	f.lock.Lock()
+	f.subs[ch] = struct{}{}
+	f.lock.Unlock()
+
+	return ch, func() {
+		f.lock.Lock()
+		delete(f.subs, ch)
+		f.lock.Unlock()
+	}
+}
+
+// since returns the page of the events kept following the given cursor. A
+// cursor ahead of the last event is of a previous run; the events are
+// returned from the first one kept then.
+func (f *disputeFeed) since(cursor uint64) DisputeEventsPage {
+	page := DisputeEventsPage{Events: []DisputeEvent{}, Cursor: cursor}
+	if f == nil {
+		return page
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if cursor > f.cursor {
+		cursor, page.Cursor = 0, 0
+	}
+
+	for _, e := range f.events {
+		if e.Cursor <= cursor {
+			continue
+		}
+
+		if len(page.Events) == disputeEventsPageSize {
+			break
+		}
+
+		page.Events = append(page.Events, e)
+		page.Cursor = e.Cursor
+	}
+
+	return page
+}
@@ -0,0 +1,158 @@
+package avail
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/pkg/avail"
+	"github.com/availproject/op-evm/pkg/avail/testutil"
+	"github.com/availproject/op-evm/pkg/block"
+	"github.com/availproject/op-evm/pkg/common"
+	"github.com/availproject/op-evm/pkg/staking"
+	"github.com/availproject/op-evm/pkg/test"
+	avail_types "github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDisputeFeedLifecycle(t *testing.T) {
+	const window = 3
+
+	d, _ := NewTestAvail(t, Sequencer)
+	sw, fraudResolver, _ := newTestSequencerWorkerOf(t, d, testutil.NewFake(avail_types.NewUCompactFromUInt(1)))
+
+	d.disputeFeed = newDisputeFeed()
+	sw.disputeWatcher = newDisputeWatcher(sw.blockchain, sw.executor, window, d.disputeFeed, hclog.Default())
+	sw.blockchain.RegisterPostCommitHook(sw.disputeWatcher.observe)
+
+	fraudResolver.watchtower = testFraudulentBlocks{}
+
+	srv := rpc.NewServer()
+	if err := srv.RegisterName(avail.SettlementNamespace, NewStatusAPI(d)); err != nil {
+		t.Fatal(err)
+	}
+
+	c := rpc.DialInProc(srv)
+	defer c.Close()
+
+	events := make(chan DisputeEvent, disputeFeedSize)
+
+	sub, err := c.Subscribe(context.Background(), avail.SettlementNamespace, events, "disputes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+
+	sender := staking.NewTestAvailSender()
+	stake := big.NewInt(0).Mul(big.NewInt(10), common.ETH)
+
+	// The local sequencer, the accused one and the watchtower stake.
+	accused, accusedKey := test.NewAccount(t)
+	watchtower, watchtowerKey := test.NewAccount(t)
+
+	for _, addr := range []types.Address{accused, watchtower} {
+		test.DepositBalance(t, addr, big.NewInt(0).Mul(big.NewInt(1000), common.ETH), sw.blockchain, sw.executor)
+	}
+
+	if err := staking.Stake(sw.blockchain, sw.executor, sender, hclog.Default(), string(staking.Sequencer), sw.nodeAddr, sw.nodeSignKey, stake, 1_000_000, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := staking.Stake(sw.blockchain, sw.executor, sender, hclog.Default(), string(staking.Sequencer), accused, accusedKey, stake, 1_000_000, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := staking.Stake(sw.blockchain, sw.executor, sender, hclog.Default(), string(staking.WatchTower), watchtower, watchtowerKey, stake, 1_000_000, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The fraud proof of the watchtower shows up on Avail ahead of the
+	// dispute it begins.
+	target := sw.blockchain.Header().Hash
+
+	sw.disputeWatcher.observeFraudProofs([]avail.EdgeBlock{{Block: &types.Block{Header: &types.Header{
+		Miner:     watchtower.Bytes(),
+		ExtraData: block.EncodeExtraDataFields(map[string][]byte{block.KeyFraudProofOf: target.Bytes()}),
+	}}}})
+
+	dr := staking.NewDisputeResolution(sw.blockchain, sw.executor, sender, hclog.Default())
+	if err := dr.Begin(accused, watchtowerKey); err != nil {
+		t.Fatal(err)
+	}
+
+	began := sw.blockchain.Header()
+
+	clock, stop := startWriteBlocksLoop(t, sw, fraudResolver)
+	defer stop()
+
+	for number := began.Number + 1; number <= began.Number+window+2; number++ {
+		clock.tick()
+		waitForBlock(t, sw, number)
+	}
+
+	var received []DisputeEvent
+
+	for len(received) < 3 {
+		select {
+		case e := <-events:
+			received = append(received, e)
+		case err := <-sub.Err():
+			t.Fatal(err)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("received %d dispute events, expected 3", len(received))
+		}
+	}
+
+	// The dispute opens, and ends with the slash of the sequencer in the
+	// block of the leader past the window.
+	opened, slashed, resolved := received[0], received[1], received[2]
+
+	assert.Equal(t, DisputeOpened, opened.Type)
+	assert.Equal(t, began.Hash, opened.BlockHash)
+	assert.Equal(t, began.Number, opened.BlockNumber)
+
+	assert.Equal(t, SequencerSlashed, slashed.Type)
+	assert.NotEqual(t, types.ZeroHash, slashed.TxHash)
+	assert.NotEmpty(t, slashed.Outcome)
+	assert.Equal(t, watchtower, slashed.RewardRecipient)
+	assert.Equal(t, big.NewInt(0).Div(stake, big.NewInt(100)), slashed.SlashedAmount)
+
+	assert.Equal(t, DisputeResolved, resolved.Type)
+	assert.Equal(t, string(FraudSequencerSlashed), resolved.Outcome)
+	assert.Equal(t, slashed.BlockHash, resolved.BlockHash)
+	assert.NotEqual(t, types.ZeroHash, resolved.TxHash)
+
+	for i, e := range received {
+		assert.Equal(t, uint64(i+1), e.Cursor)
+		assert.Equal(t, accused, e.Sequencer)
+		assert.Equal(t, watchtower, e.Watchtower)
+		assert.Equal(t, target, e.AccusedBlockHash)
+	}
+
+	// Polling from a cursor returns the events following it; a cursor of a
+	// previous run, all of them.
+	poll := func(cursor uint64) DisputeEventsPage {
+		t.Helper()
+
+		var page DisputeEventsPage
+		if err := c.Call(&page, "avail_getDisputeEvents", cursor); err != nil {
+			t.Fatal(err)
+		}
+
+		return page
+	}
+
+	page := poll(1)
+	if assert.Len(t, page.Events, 2) {
+		assert.Equal(t, slashed.TxHash, page.Events[0].TxHash)
+		assert.Equal(t, resolved.TxHash, page.Events[1].TxHash)
+	}
+
+	assert.Equal(t, uint64(3), page.Cursor)
+	assert.Empty(t, poll(page.Cursor).Events)
+	assert.Len(t, poll(100).Events, 3)
+}
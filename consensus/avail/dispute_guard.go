@@ -0,0 +1,131 @@
+package avail
+
+import (
+	"math/big"
+	"sync/atomic"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/pkg/staking"
+	"github.com/hashicorp/go-hclog"
+)
+
+// DisputeState is the state of the local sequencer with respect to the
+// disputes against it.
+type DisputeState uint32
+
+const (
+	// DisputeNone is the state of the sequencer not under dispute.
+	DisputeNone DisputeState = iota
+
+	// DisputeActive is the state of the sequencer under an active dispute;
+	// the block production is paused until the dispute ends.
+	DisputeActive
+
+	// DisputeSlashed is the state of the sequencer slashed in the end of a
+	// dispute; the block production stays halted until the operator steps in.
+	DisputeSlashed
+)
+
+// String returns the name of the state, as reported by the status API.
+func (s DisputeState) String() string {
+	switch s {
+	case DisputeNone:
+		return "none"
+	case DisputeActive:
+		return "active"
+	case DisputeSlashed:
+		return "slashed"
+	default:
+		return "unknown"
+	}
+}
+
+// disputeGuard pauses the block production of the local sequencer while it's
+// the subject of an active dispute. The sequencer resumes once the dispute
+// ends in its favor, and stays halted if the dispute ends with its stake
+// slashed.
+type disputeGuard struct {
+	addr   types.Address
+	apq    staking.ActiveParticipants
+	logger hclog.Logger
+
+	state atomic.Uint32
+
+	// stake is the stake of the sequencer when the dispute began; only
+	// accessed from Observe.
+	stake *big.Int
+}
+
+func newDisputeGuard(addr types.Address, apq staking.ActiveParticipants, logger hclog.Logger) *disputeGuard {
+	return &disputeGuard{
+		addr:   addr,
+		apq:    apq,
+		logger: logger,
+	}
+}
+
+// State returns the dispute state of the sequencer.
+func (g *disputeGuard) State() DisputeState {
+	return DisputeState(g.state.Load())
+}
+
+// Paused reports whether the block production is paused due to a dispute.
+func (g *disputeGuard) Paused() bool {
+	return g.State() != DisputeNone
+}
+
+// Observe checks the disputes against the sequencer at the head of the
+// chain; it's called once the blocks of an Avail block have been applied.
+func (g *disputeGuard) Observe() {
+	state := g.State()
+	if state == DisputeSlashed {
+		return
+	}
+
+	inProbation, err := g.apq.InProbation(g.addr)
+	if err != nil {
+		g.logger.Warn("failed to check if the sequencer is under dispute", "error", err)
+		return
+	}
+
+	switch {
+	case inProbation && state == DisputeNone:
+		stake, err := g.apq.GetBalance(g.addr)
+		if err != nil {
+			g.logger.Warn("failed to query the stake of the sequencer under dispute", "error", err)
+		}
+
+		g.stake = stake
+		g.setState(DisputeActive)
+		g.logger.Warn("sequencer is under dispute; pausing block production until it ends", "sequencer_addr", g.addr)
+
+	case !inProbation && state == DisputeActive:
+		stake, err := g.apq.GetBalance(g.addr)
+		if err != nil {
+			// Stay paused until the outcome is known.
+			g.logger.Warn("failed to query the stake of the sequencer after the dispute", "error", err)
+			return
+		}
+
+		if g.stake != nil && stake != nil && stake.Cmp(g.stake) < 0 {
+			g.setState(DisputeSlashed)
+			g.logger.Error(
+				"sequencer was slashed in the dispute; block production is halted until the operator intervenes",
+				"sequencer_addr", g.addr,
+				"stake_before", g.stake,
+				"stake_after", stake,
+			)
+
+			return
+		}
+
+		g.stake = nil
+		g.setState(DisputeNone)
+		g.logger.Info("dispute ended in favor of the sequencer; resuming block production", "sequencer_addr", g.addr)
+	}
+}
+
+func (g *disputeGuard) setState(state DisputeState) {
+	g.state.Store(uint32(state))
+	observeDisputeState(state)
+}
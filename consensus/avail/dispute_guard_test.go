@@ -0,0 +1,112 @@
+package avail
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/pkg/avail/testutil"
+	"github.com/availproject/op-evm/pkg/common"
+	"github.com/availproject/op-evm/pkg/staking"
+	"github.com/availproject/op-evm/pkg/test"
+	avail_types "github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSequencerPausesUnderDispute(t *testing.T) {
+	d, _ := NewTestAvail(t, Sequencer)
+	sw, fraudResolver, _ := newTestSequencerWorkerOf(t, d, testutil.NewFake(avail_types.NewUCompactFromUInt(1)))
+	d.disputes = sw.disputes
+
+	// The staked watchtower opens a dispute against the local sequencer.
+	watchtowerAddr, watchtowerKey := test.NewAccount(t)
+	test.DepositBalance(t, watchtowerAddr, big.NewInt(0).Mul(big.NewInt(1000), common.ETH), sw.blockchain, sw.executor)
+
+	sender := staking.NewTestAvailSender()
+	if err := staking.Stake(sw.blockchain, sw.executor, sender, hclog.Default(), string(staking.WatchTower), watchtowerAddr, watchtowerKey, big.NewInt(0).Mul(big.NewInt(10), common.ETH), 1_000_000, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	dr := staking.NewDisputeResolution(sw.blockchain, sw.executor, sender, hclog.Default())
+	if err := dr.Begin(sw.nodeAddr, watchtowerKey); err != nil {
+		t.Fatal(err)
+	}
+
+	sw.disputes.Observe()
+	assert.Equal(t, DisputeActive, sw.disputes.State())
+
+	status, err := NewStatusAPI(d).GetNodeStatus()
+	if assert.NoError(t, err) {
+		assert.True(t, status.ProductionPaused)
+		assert.Equal(t, "active", status.DisputeState)
+	}
+
+	paused := sw.blockchain.Header().Number
+	clock, stop := startWriteBlocksLoop(t, sw, fraudResolver)
+	defer stop()
+
+	// No blocks while under dispute.
+	clock.tick()
+	clock.tick()
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, paused, sw.blockchain.Header().Number)
+
+	// The dispute ends in favor of the sequencer.
+	if err := dr.End(sw.nodeAddr, watchtowerKey); err != nil {
+		t.Fatal(err)
+	}
+
+	sw.disputes.Observe()
+	assert.Equal(t, DisputeNone, sw.disputes.State())
+
+	status, err = NewStatusAPI(d).GetNodeStatus()
+	if assert.NoError(t, err) {
+		assert.False(t, status.ProductionPaused)
+		assert.Equal(t, "none", status.DisputeState)
+	}
+
+	ended := sw.blockchain.Header().Number
+	clock.tick()
+	waitForBlock(t, sw, ended+1)
+
+	assert.Equal(t, sw.nodeAddr, types.BytesToAddress(sw.blockchain.Header().Miner))
+}
+
+// testDisputedParticipants is the staking state of a sequencer under dispute.
+type testDisputedParticipants struct {
+	staking.ActiveParticipants
+	inProbation bool
+	stake       *big.Int
+}
+
+func (p *testDisputedParticipants) InProbation(types.Address) (bool, error) {
+	return p.inProbation, nil
+}
+
+func (p *testDisputedParticipants) GetBalance(types.Address) (*big.Int, error) {
+	return p.stake, nil
+}
+
+func TestDisputeGuardSlashed(t *testing.T) {
+	addr, _ := test.NewAccount(t)
+	apq := &testDisputedParticipants{inProbation: true, stake: big.NewInt(10)}
+	g := newDisputeGuard(addr, apq, hclog.NewNullLogger())
+
+	g.Observe()
+	assert.Equal(t, DisputeActive, g.State())
+
+	// The dispute ends with the stake slashed; the sequencer stays halted.
+	apq.inProbation = false
+	apq.stake = big.NewInt(0)
+
+	g.Observe()
+	assert.Equal(t, DisputeSlashed, g.State())
+	assert.True(t, g.Paused())
+
+	apq.stake = big.NewInt(10)
+
+	g.Observe()
+	assert.Equal(t, DisputeSlashed, g.State())
+}
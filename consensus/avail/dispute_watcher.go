@@ -0,0 +1,438 @@
+package avail
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"sync"
+
+	"github.com/0xPolygon/polygon-edge/crypto"
+	"github.com/0xPolygon/polygon-edge/state"
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/armon/go-metrics"
+	"github.com/availproject/op-evm/pkg/avail"
+	"github.com/availproject/op-evm/pkg/block"
+	"github.com/availproject/op-evm/pkg/blockchain"
+	"github.com/availproject/op-evm/pkg/staking"
+	"github.com/hashicorp/go-hclog"
+)
+
+// disputeTxGasLimit is the gas limit of the transactions ending the disputes.
+const disputeTxGasLimit = 1_000_000
+
+// openDispute is a dispute begun on the staking contract and not ended yet.
+type openDispute struct {
+	sequencer  types.Address
+	watchtower types.Address
+
+	// beganAt is the number of the block the dispute began in.
+	beganAt uint64
+
+	// endingAt is the number of the block built with the transactions ending
+	// the dispute, until the block is written; 0 when none is in flight.
+	endingAt uint64
+
+	// escalatedAt is the number of the block the dispute escalated at, and
+	// submittedAt the one the node last submitted the transactions ending it
+	// for; 0 when none.
+	escalatedAt uint64
+	submittedAt uint64
+}
+
+// escalated returns the escalated dispute.
+func (d *openDispute) escalated() EscalatedDispute {
+	return EscalatedDispute{Sequencer: d.sequencer, Watchtower: d.watchtower, BeganAt: d.beganAt, EscalatedAt: d.escalatedAt}
+}
+
+// disputeWatcher tracks the disputes open on the staking contract, from the
+// staking events of the blocks written to the chain, and has the leader end
+// the ones open past the challenge window. A dispute ends in favor of the
+// watchtower only when its fraud proof, seen on Avail, shows the disputed
+// block to be fraudulent; the disputed sequencer is slashed then. A fraud
+// proof refuted by the defense of the sequencer, see observeDefenses, has its
+// watchtower slashed instead. The slashes written to the chain are verified
+// against the staking contract, and the failed ones retried by the leaders to
+// come, see observeSlash, along with the rewards the contract pays with them.
+// The disputes left open past a multiple of the challenge window escalate, see
+// setEscalation. The disputes opened, escalated and resolved, and the slashes,
+// are published to the dispute feed, if any.
+type disputeWatcher struct {
+	blockchain *blockchain.Blockchain
+	executor   *state.Executor
+	window     uint64
+	escalation disputeEscalation
+	feed       *disputeFeed
+	metrics    *fraudMetrics
+	logger     hclog.Logger
+
+	lock       sync.Mutex
+	open       map[types.Address]*openDispute      // by disputed sequencer
+	proofs     map[types.Address]types.Hash        // fraud proof targets, by watchtower
+	defenses   map[types.Hash]block.DisputeDefense // accepted defenses, by disputed block hash
+	defendedAt map[types.Address]uint64            // Avail height of the last defense accepted, by sequencer
+	slashes    map[types.Address]*pendingSlash     // failed slashes, by disputed sequencer
+	observed   uint64                              // number of the last block observed
+}
+
+func newDisputeWatcher(b *blockchain.Blockchain, e *state.Executor, window uint64, feed *disputeFeed, logger hclog.Logger) *disputeWatcher {
+	return &disputeWatcher{
+		blockchain: b,
+		executor:   e,
+		window:     window,
+		escalation: disputeEscalation{after: DefaultDisputeEscalationMultiple * window},
+		feed:       feed,
+		metrics:    newFraudMetrics(metrics.Default()),
+		logger:     logger,
+		open:       make(map[types.Address]*openDispute),
+		proofs:     make(map[types.Address]types.Hash),
+		defenses:   make(map[types.Hash]block.DisputeDefense),
+		defendedAt: make(map[types.Address]uint64),
+		slashes:    make(map[types.Address]*pendingSlash),
+	}
+}
+
+// seed picks up the disputes open on the staking contract at the head of the
+// chain, begun before the node started; their challenge window runs from
+// the head.
+func (w *disputeWatcher) seed(dr staking.DisputeResolution) error {
+	sequencers, err := dr.Get(staking.Sequencer)
+	if err != nil {
+		return err
+	}
+
+	head := w.blockchain.Header().Number
+
+	for _, sequencer := range sequencers {
+		watchtower, err := dr.GetWatchtowerAddr(sequencer)
+		if err != nil {
+			return err
+		}
+
+		w.begin(sequencer, watchtower, head)
+	}
+
+	return nil
+}
+
+// observe records the disputes begun and ended, and checks the slashes, in
+// the block written to the chain; it's run as a post-commit hook.
+func (w *disputeWatcher) observe(blk *types.Block, receipts []*types.Receipt) {
+	slashed := make(map[types.Address]bool)
+
+	for i, tx := range blk.Transactions {
+		if sequencer, ok := staking.SlashedStaker(tx); ok && i < len(receipts) {
+			w.observeSlash(blk, sequencer, tx.Hash, receipts[i])
+
+			if status := receipts[i].Status; status != nil && *status == types.ReceiptSuccess {
+				slashed[sequencer] = true
+			}
+		}
+	}
+
+	for i, receipt := range receipts {
+		var (
+			watchtower types.Address
+			txHash     types.Hash
+		)
+
+		if i < len(blk.Transactions) {
+			watchtower, txHash = blk.Transactions[i].From, blk.Transactions[i].Hash
+		}
+
+		for _, log := range receipt.Logs {
+			event, ok := staking.ParseDisputeEvent(log)
+			if !ok {
+				continue
+			}
+
+			if !event.Began {
+				w.end(event.Account, blk, txHash, slashed)
+				continue
+			}
+
+			if w.begin(event.Account, watchtower, blk.Number()) {
+				w.metrics.disputeOpened(event.Account)
+
+				w.lock.Lock()
+				target := w.proofs[watchtower]
+				w.lock.Unlock()
+
+				w.feed.publish(DisputeEvent{
+					Type:             DisputeOpened,
+					Sequencer:        event.Account,
+					Watchtower:       watchtower,
+					AccusedBlockHash: target,
+					BlockNumber:      blk.Number(),
+					BlockHash:        blk.Hash(),
+					TxHash:           txHash,
+				})
+			}
+		}
+	}
+
+	w.lock.Lock()
+
+	w.observed = blk.Number()
+
+	// The block built to end the dispute didn't make it into the chain; the
+	// next leader tries again.
+	for _, d := range w.open {
+		if d.endingAt != 0 && d.endingAt <= w.observed {
+			d.endingAt = 0
+		}
+	}
+
+	for _, p := range w.slashes {
+		if p.slashingAt != 0 && p.slashingAt <= w.observed {
+			p.slashingAt = 0
+		}
+	}
+
+	escalated := w.escalateDue()
+	w.metrics.openDisputes(len(w.open))
+	w.lock.Unlock()
+
+	for _, e := range escalated {
+		w.notifyEscalation(e, blk)
+	}
+
+	observeEscalatedDisputes(len(w.escalated()))
+}
+
+// observeFraudProofs records the targets of the fraud proofs among the edge
+// blocks received from Avail, by the watchtower raising them.
+func (w *disputeWatcher) observeFraudProofs(blks []avail.EdgeBlock) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	for _, decoded := range blks {
+		if target, ok := block.GetExtraDataFraudProofTarget(decoded.Block.Header); ok {
+			w.proofs[types.BytesToAddress(decoded.Block.Header.Miner)] = target
+		}
+	}
+}
+
+// begin opens the dispute of the sequencer, and reports whether it wasn't
+// open already.
+func (w *disputeWatcher) begin(sequencer, watchtower types.Address, number uint64) bool {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if _, ok := w.open[sequencer]; ok {
+		return false
+	}
+
+	w.open[sequencer] = &openDispute{sequencer: sequencer, watchtower: watchtower, beganAt: number}
+	w.logger.Info("dispute begun", "sequencer_addr", sequencer, "watchtower_addr", watchtower, "block_number", number)
+
+	return true
+}
+
+// end closes the dispute of the account, the disputed sequencer or, when
+// slashed, the watchtower, by the transaction of the given hash in the
+// block; the dispute resolves by the stakers slashed in the block.
+func (w *disputeWatcher) end(account types.Address, blk *types.Block, txHash types.Hash, slashed map[types.Address]bool) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	for sequencer, d := range w.open {
+		if sequencer == account || d.watchtower == account {
+			target := w.proofs[d.watchtower]
+
+			delete(w.open, sequencer)
+			delete(w.defenses, target)
+			delete(w.proofs, d.watchtower)
+			w.logger.Info("dispute ended", "sequencer_addr", sequencer, "watchtower_addr", d.watchtower)
+
+			outcome := FraudDismissed
+
+			switch {
+			case slashed[sequencer]:
+				outcome = FraudSequencerSlashed
+			case slashed[d.watchtower]:
+				outcome = FraudWatchtowerSlashed
+			}
+
+			w.metrics.disputeResolved(sequencer, outcome)
+
+			w.feed.publish(DisputeEvent{
+				Type:             DisputeResolved,
+				Sequencer:        sequencer,
+				Watchtower:       d.watchtower,
+				AccusedBlockHash: target,
+				BlockNumber:      blk.Number(),
+				BlockHash:        blk.Hash(),
+				TxHash:           txHash,
+				Outcome:          string(outcome),
+			})
+		}
+	}
+}
+
+// due returns the disputes open past the challenge window as of the block
+// of the given number, marking them as being ended in it.
+func (w *disputeWatcher) due(number uint64) []openDispute {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	var due []openDispute
+
+	for _, d := range w.open {
+		if d.endingAt != 0 || number < d.beganAt+w.window {
+			continue
+		}
+
+		d.endingAt = number
+		due = append(due, *d)
+	}
+
+	return due
+}
+
+// disputeVerdict is how a dispute past the challenge window ends.
+type disputeVerdict string
+
+const (
+	// disputeDismissed is a dispute ended without a slash.
+	disputeDismissed disputeVerdict = "dismissed"
+
+	// disputeUpheld is a dispute ended with the slash of the sequencer.
+	disputeUpheld disputeVerdict = "upheld"
+
+	// disputeRefuted is a dispute ended with the slash of the watchtower, its
+	// fraud proof refuted by the defense of the sequencer.
+	disputeRefuted disputeVerdict = "refuted"
+)
+
+// verdict decides the dispute by the check of the watchtower on the block of
+// the fraud proof of the dispute's watchtower: the fraud is upheld when the
+// check finds the block fraudulent. The check of the block of a disputed
+// sequencer fails on its signer, out of the active set for the dispute, too;
+// the fraud is upheld on that as well, unless the defense of the sequencer
+// refutes the fraud proof: the results of the re-execution of the block it
+// claims are the ones of the block, and of the re-execution of the leader.
+// The fraud proof refuted by a defense the check doesn't find fraudulent
+// either, or not refuted, is dismissed; so is the dispute without a fraud
+// proof, or one targeting an unknown block.
+func (w *disputeWatcher) verdict(d openDispute, check func(*types.Block) error) disputeVerdict {
+	w.lock.Lock()
+	target, ok := w.proofs[d.watchtower]
+	defense, defended := w.defenses[target]
+	w.lock.Unlock()
+
+	if !ok || check == nil {
+		return disputeDismissed
+	}
+
+	blk, ok := w.blockchain.GetBlockByHash(target, true)
+	if !ok {
+		w.logger.Warn("block of the fraud proof not found; the dispute isn't upheld", "sequencer_addr", d.sequencer, "block_hash", target)
+		return disputeDismissed
+	}
+
+	err := check(blk)
+	if err != nil && !errors.Is(err, staking.ErrNotActiveSequencer) {
+		return disputeUpheld
+	}
+
+	unrefuted := disputeDismissed
+	if err != nil {
+		unrefuted = disputeUpheld
+	}
+
+	if !defended {
+		return unrefuted
+	}
+
+	if !defense.Matches(blk.Header) {
+		w.logger.Warn("defense of the sequencer doesn't match the block; the fraud proof isn't refuted", "sequencer_addr", d.sequencer, "block_hash", target)
+		return unrefuted
+	}
+
+	results, err := executionResults(w.blockchain, blk)
+	if err != nil || results != defense {
+		w.logger.Warn("defense of the sequencer doesn't match the re-execution; the fraud proof isn't refuted", "sequencer_addr", d.sequencer, "block_hash", target, "error", err)
+		return unrefuted
+	}
+
+	return disputeRefuted
+}
+
+// endTxs returns the signed transactions of the leader ending the dispute, by
+// its verdict: the end of the dispute resolution, followed by the slash of the
+// disputed sequencer when the fraud is upheld. A refuted fraud proof has the
+// slash of the watchtower alone, which ends the dispute as well; the
+// watchtower can't be slashed once the dispute ended.
+func (w *disputeWatcher) endTxs(d openDispute, from types.Address, nonce uint64, signKey *ecdsa.PrivateKey, check func(*types.Block) error) ([]*types.Transaction, error) {
+	verdict := w.verdict(d, check)
+
+	end, slash, err := settlementTxs(d, verdict, from)
+	if err != nil {
+		return nil, err
+	}
+
+	var txs []*types.Transaction
+	if end != nil {
+		txs = append(txs, end)
+	}
+
+	if slash != nil {
+		txs = append(txs, slash)
+	}
+
+	txs, err = signDisputeTxs(txs, nonce, signKey)
+	if err != nil {
+		return nil, err
+	}
+
+	w.logger.Info("ending dispute past the challenge window", "sequencer_addr", d.sequencer, "watchtower_addr", d.watchtower, "began_at", d.beganAt, "verdict", verdict)
+
+	return txs, nil
+}
+
+// settlementTxs returns the unsigned transactions settling the dispute by its
+// verdict: the end of the dispute resolution, unless the fraud proof is
+// refuted, and the slash of the party at fault, if any.
+func settlementTxs(d openDispute, verdict disputeVerdict, from types.Address) (end, slash *types.Transaction, err error) {
+	if verdict != disputeRefuted {
+		if end, err = staking.EndDisputeResolutionTx(from, d.sequencer, disputeTxGasLimit); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var slashed *types.Address
+
+	switch verdict {
+	case disputeUpheld:
+		slashed = &d.sequencer
+	case disputeRefuted:
+		slashed = &d.watchtower
+	}
+
+	if slashed != nil {
+		if slash, err = staking.SlashStakerTx(from, *slashed, disputeTxGasLimit); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return end, slash, nil
+}
+
+// signDisputeTxs signs the transactions of the leader, in order, from the
+// given nonce on.
+func signDisputeTxs(txs []*types.Transaction, nonce uint64, signKey *ecdsa.PrivateKey) ([]*types.Transaction, error) {
+	signer := &crypto.FrontierSigner{}
+	signed := make([]*types.Transaction, len(txs))
+
+	for i, tx := range txs {
+		tx.Nonce = nonce + uint64(i)
+
+		var err error
+		if signed[i], err = signer.SignTx(tx, signKey); err != nil {
+			return nil, err
+		}
+
+		signed[i].ComputeHash()
+	}
+
+	return signed, nil
+}
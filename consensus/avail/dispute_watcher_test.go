@@ -0,0 +1,188 @@
+package avail
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/pkg/avail"
+	"github.com/availproject/op-evm/pkg/avail/testutil"
+	"github.com/availproject/op-evm/pkg/block"
+	"github.com/availproject/op-evm/pkg/common"
+	"github.com/availproject/op-evm/pkg/staking"
+	"github.com/availproject/op-evm/pkg/test"
+	avail_types "github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+// testFraudulentBlocks is the watchtower finding every block fraudulent.
+type testFraudulentBlocks struct{}
+
+func (testFraudulentBlocks) Apply(*types.Block) error          { return nil }
+func (testFraudulentBlocks) Check(*types.Block) error          { return errors.New("fraudulent block") }
+func (testFraudulentBlocks) CheckStructure(*types.Block) error { return errors.New("fraudulent block") }
+func (testFraudulentBlocks) ConstructFraudproof(*types.Block) (*types.Block, error) {
+	return nil, nil
+}
+
+func TestDisputeWatcherEndsDisputes(t *testing.T) {
+	const window = 3
+
+	tests := []struct {
+		name  string
+		proof bool
+	}{
+		{name: "no fraud proof"},
+		{name: "fraud upheld", proof: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, apq := NewTestAvail(t, Sequencer)
+			sw, fraudResolver, _ := newTestSequencerWorkerOf(t, d, testutil.NewFake(avail_types.NewUCompactFromUInt(1)))
+
+			registry, sink := newTestRegistry(t)
+
+			sw.disputeWatcher = newDisputeWatcher(sw.blockchain, sw.executor, window, nil, hclog.Default())
+			sw.disputeWatcher.metrics = newFraudMetrics(registry)
+			sw.blockchain.RegisterPostCommitHook(sw.disputeWatcher.observe)
+
+			sender := staking.NewTestAvailSender()
+			stake := big.NewInt(0).Mul(big.NewInt(10), common.ETH)
+
+			// The local sequencer, the accused one and the watchtower stake.
+			accused, accusedKey := test.NewAccount(t)
+			watchtower, watchtowerKey := test.NewAccount(t)
+
+			for _, addr := range []types.Address{accused, watchtower} {
+				test.DepositBalance(t, addr, big.NewInt(0).Mul(big.NewInt(1000), common.ETH), sw.blockchain, sw.executor)
+			}
+
+			if err := staking.Stake(sw.blockchain, sw.executor, sender, hclog.Default(), string(staking.Sequencer), sw.nodeAddr, sw.nodeSignKey, stake, 1_000_000, "test"); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := staking.Stake(sw.blockchain, sw.executor, sender, hclog.Default(), string(staking.Sequencer), accused, accusedKey, stake, 1_000_000, "test"); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := staking.Stake(sw.blockchain, sw.executor, sender, hclog.Default(), string(staking.WatchTower), watchtower, watchtowerKey, stake, 1_000_000, "test"); err != nil {
+				t.Fatal(err)
+			}
+
+			target := sw.blockchain.Header().Hash
+
+			dr := staking.NewDisputeResolution(sw.blockchain, sw.executor, sender, hclog.Default())
+			if err := dr.Begin(accused, watchtowerKey); err != nil {
+				t.Fatal(err)
+			}
+
+			began := sw.blockchain.Header().Number
+
+			assert.Eventually(t, func() bool {
+				sw.disputeWatcher.lock.Lock()
+				defer sw.disputeWatcher.lock.Unlock()
+
+				_, ok := sw.disputeWatcher.open[accused]
+				return ok
+			}, 5*time.Second, 10*time.Millisecond)
+
+			metric := func(key string) float64 {
+				m, _ := metricOf(sink, key)
+				return m.sum
+			}
+
+			assert.Equal(t, float64(1), metric("test.avail.dispute.opened;accused="+accused.String()))
+			assert.Equal(t, float64(1), metric("test.avail.dispute.open"))
+
+			if tt.proof {
+				fraudResolver.watchtower = testFraudulentBlocks{}
+				sw.disputeWatcher.observeFraudProofs([]avail.EdgeBlock{{Block: &types.Block{Header: &types.Header{
+					Miner:     watchtower.Bytes(),
+					ExtraData: block.EncodeExtraDataFields(map[string][]byte{block.KeyFraudProofOf: target.Bytes()}),
+				}}}})
+			}
+
+			stakeBefore, err := apq.GetBalance(accused)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			clock, stop := startWriteBlocksLoop(t, sw, fraudResolver)
+			defer stop()
+
+			// The dispute stays open for the window, and ends in the block of
+			// the leader past it.
+			for number := began + 1; number <= began+window+2; number++ {
+				clock.tick()
+				waitForBlock(t, sw, number)
+
+				inProbation, err := apq.InProbation(accused)
+				assert.NoError(t, err)
+				assert.Equal(t, number < began+window, inProbation, "block %d", number)
+			}
+
+			stop()
+
+			endTx, _ := staking.EndDisputeResolutionTx(types.ZeroAddress, accused, 0)
+			slashTx, _ := staking.SlashStakerTx(types.ZeroAddress, accused, 0)
+
+			var ends, slashes int
+
+			for number := began + 1; number <= sw.blockchain.Header().Number; number++ {
+				blk, ok := sw.blockchain.GetBlockByNumber(number, true)
+				if !assert.True(t, ok) {
+					continue
+				}
+
+				receipts, err := sw.blockchain.GetReceiptsByHash(blk.Hash())
+				assert.NoError(t, err)
+
+				for i, tx := range blk.Transactions {
+					switch {
+					case bytes.Equal(tx.Input[:4], endTx.Input[:4]):
+						ends++
+					case bytes.Equal(tx.Input[:4], slashTx.Input[:4]):
+						slashes++
+					default:
+						continue
+					}
+
+					assert.Equal(t, sw.nodeAddr, tx.From)
+					assert.Equal(t, types.ReceiptSuccess, *receipts[i].Status)
+				}
+			}
+
+			assert.Equal(t, 1, ends)
+
+			stakeAfter, err := apq.GetBalance(accused)
+			assert.NoError(t, err)
+
+			outcome := FraudDismissed
+			if tt.proof {
+				outcome = FraudSequencerSlashed
+			}
+
+			// The outcome is recorded by the post-commit hook of the block
+			// ending the dispute, in the background.
+			assert.Eventually(t, func() bool {
+				return metric(fmt.Sprintf("test.avail.dispute.resolved;accused=%s;outcome=%s", accused, outcome)) == 1
+			}, 5*time.Second, 10*time.Millisecond)
+			assert.Zero(t, metric("test.avail.dispute.open"))
+
+			if tt.proof {
+				assert.Equal(t, 1, slashes)
+				assert.Equal(t, -1, stakeAfter.Cmp(stakeBefore))
+				assert.Equal(t, float64(1), metric("test.avail.dispute.executed_slashes;staker="+accused.String()))
+			} else {
+				assert.Equal(t, 0, slashes)
+				assert.Equal(t, stakeBefore, stakeAfter)
+			}
+		})
+	}
+}
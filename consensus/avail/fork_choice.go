@@ -0,0 +1,342 @@
+package avail
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/armon/go-metrics"
+	"github.com/availproject/op-evm/pkg/avail"
+	"github.com/availproject/op-evm/pkg/blockchain"
+	"github.com/availproject/op-evm/pkg/staking"
+	avail_types "github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/hashicorp/go-hclog"
+)
+
+// errReorgBelowSettledHead is returned when the fork choice refuses to fork
+// out the blocks settled on Avail.
+var errReorgBelowSettledHead = errors.New("reorg below the settled head")
+
+// blockInclusion is where on Avail a block settled, and whether the sequencer
+// scheduled to lead the slot produced it.
+type blockInclusion struct {
+	availBlock     uint64
+	extrinsicIndex int
+	byLeader       bool
+}
+
+// preferredOver reports whether the block included at in is preferred over
+// the one included at other: the block of the scheduled leader goes first,
+// then the one included in Avail first.
+func (in blockInclusion) preferredOver(other blockInclusion) bool {
+	if in.byLeader != other.byLeader {
+		return in.byLeader
+	}
+
+	if in.availBlock != other.availBlock {
+		return in.availBlock < other.availBlock
+	}
+
+	return in.extrinsicIndex < other.extrinsicIndex
+}
+
+// seenBlock is a block seen on Avail.
+type seenBlock struct {
+	header    *types.Header
+	inclusion blockInclusion
+
+	// disputeFork is set for the dispute resolution blocks, which win over
+	// the blocks they fork out.
+	disputeFork bool
+}
+
+// forkChoice decides between the blocks settled on Avail competing for the
+// same height, so that every node following Avail keeps the same one no
+// matter which it got first. The competing blocks are kept as forks of the
+// chain, and the chain is reorganized whenever the preferred block at a
+// height isn't the canonical one, along with the seen descendants of the
+// preferred block. Only the blocks seen on Avail take part: a canonical block
+// yet to be seen, such as the own block of the sequencer written ahead of the
+// Avail stream, is weighed once it shows up, unless the block of the
+// scheduled leader beats it anyway. The conflicts with the own blocks of the
+// node are recorded, see Conflict. The blocks up to the settled head are
+// final: only a dispute resolution forks them out.
+type forkChoice struct {
+	blockchain *blockchain.Blockchain
+	self       types.Address
+	maxDepth   uint64
+	settled    *settledHead
+	feed       *disputeFeed
+	metrics    *fraudMetrics
+	logger     hclog.Logger
+	conflicts  conflictLog
+
+	lock     sync.Mutex
+	seen     map[types.Hash]*seenBlock
+	byNumber map[uint64][]types.Hash
+	followed uint64 // The last Avail block followed, see settle
+}
+
+// newForkChoice returns the forkChoice of the chain of the node of the given
+// address, reorganizing at most maxDepth blocks deep and never below the
+// settled head, if tracked. The blocks forked out by the dispute resolutions
+// are published to the dispute feed, if any.
+func newForkChoice(blockchain *blockchain.Blockchain, self types.Address, maxDepth uint64, settled *settledHead, feed *disputeFeed, logger hclog.Logger) *forkChoice {
+	return &forkChoice{
+		blockchain: blockchain,
+		self:       self,
+		maxDepth:   maxDepth,
+		settled:    settled,
+		feed:       feed,
+		metrics:    newFraudMetrics(metrics.Default()),
+		logger:     logger,
+		seen:       make(map[types.Hash]*seenBlock),
+		byNumber:   make(map[uint64][]types.Hash),
+	}
+}
+
+// apply writes the validated block settled on Avail at the given inclusion,
+// and then applies the fork choice at its height. The block on top of the
+// head extends the chain, while the one competing with a canonical block is
+// written as a fork. The dispute resolution blocks fork the chain on their
+// own: the chain is rolled back to their parent, the honest tip, and they're
+// written on top of it. A block competing with an own block of the node is
+// recorded as a conflict. It returns how the block was written.
+func (fc *forkChoice) apply(blk *types.Block, inclusion blockInclusion, source string) (blockWrite, error) {
+	fc.lock.Lock()
+	defer fc.lock.Unlock()
+
+	write := blockDuplicate
+
+	if _, known := fc.blockchain.GetHeaderByHash(blk.Hash()); !known {
+		head := fc.blockchain.Header()
+
+		if blk.ParentHash() == head.Hash || isDisputeResolutionFork(blk) {
+			write = blockWritten
+
+			if blk.ParentHash() != head.Hash {
+				if _, err := rollBackFraudulentBlocks(fc.blockchain, blk.ParentHash(), source, fc.feed, fc.metrics, fc.logger); err != nil {
+					return write, err
+				}
+			}
+
+			if err := fc.blockchain.WriteBlock(blk, source); err != nil {
+				return write, err
+			}
+		} else {
+			write = blockConflicting
+
+			if err := fc.blockchain.WriteForkBlock(blk, source); err != nil {
+				return write, err
+			}
+		}
+	}
+
+	fc.recordLocked(blk.Header, inclusion, isDisputeResolutionFork(blk))
+	fc.settled.include(blk.Header, inclusion.availBlock)
+
+	// A dispute resolution may have forked the settled blocks out.
+	if write == blockWritten {
+		fc.settled.check()
+	}
+
+	own, conflicting := fc.ownCompetitorLocked(blk.Header)
+
+	resolution, depth, err := fc.chooseLocked(blk.Number(), source)
+
+	// A block seen again is no new conflict.
+	if conflicting && write != blockDuplicate {
+		fc.noteConflictLocked(blk.Number(), own, resolution, depth)
+	}
+
+	return write, err
+}
+
+// recordLocked notes the block seen on Avail, whether it's a dispute
+// resolution fork; a block seen again keeps its first inclusion. The blocks
+// too deep to reorganize are forgotten. It must be called with the lock
+// held.
+func (fc *forkChoice) recordLocked(header *types.Header, inclusion blockInclusion, disputeFork bool) {
+	if _, ok := fc.seen[header.Hash]; !ok {
+		fc.seen[header.Hash] = &seenBlock{header: header, inclusion: inclusion, disputeFork: disputeFork}
+		fc.byNumber[header.Number] = append(fc.byNumber[header.Number], header.Hash)
+	}
+
+	head := fc.blockchain.Header().Number
+	if head <= fc.maxDepth+1 {
+		return
+	}
+
+	for number, hashes := range fc.byNumber {
+		if number < head-fc.maxDepth-1 {
+			for _, hash := range hashes {
+				delete(fc.seen, hash)
+			}
+
+			delete(fc.byNumber, number)
+		}
+	}
+}
+
+// inclusion returns the Avail height the block of the given hash was seen at,
+// for the blocks seen recent enough to reorganize.
+func (fc *forkChoice) inclusion(hash types.Hash) (uint64, bool) {
+	fc.lock.Lock()
+	defer fc.lock.Unlock()
+
+	sb, ok := fc.seen[hash]
+	if !ok {
+		return 0, false
+	}
+
+	return sb.inclusion.availBlock, true
+}
+
+// preferredLocked returns the preferred block, out of the seen ones at the
+// given height on top of the given parent, or nil if there's none. A
+// dispute resolution fork goes before the blocks it competes with, so the
+// blocks proven fraudulent never come back. It must be called with the lock
+// held.
+func (fc *forkChoice) preferredLocked(number uint64, parent types.Hash) *seenBlock {
+	var preferred *seenBlock
+
+	for _, hash := range fc.byNumber[number] {
+		sb := fc.seen[hash]
+		if sb.header.ParentHash != parent {
+			continue
+		}
+
+		switch {
+		case preferred == nil:
+			preferred = sb
+		case sb.disputeFork != preferred.disputeFork:
+			if sb.disputeFork {
+				preferred = sb
+			}
+		case sb.inclusion.preferredOver(preferred.inclusion):
+			preferred = sb
+		}
+	}
+
+	return preferred
+}
+
+// chooseLocked makes the preferred block at the given height, out of the
+// ones on top of the canonical parent, canonical along with its preferred
+// seen descendants. It returns how the choice went, along with the depth of
+// the reorg made, if any. It must be called with the lock held.
+func (fc *forkChoice) chooseLocked(number uint64, source string) (ConflictResolution, uint64, error) {
+	if number == 0 {
+		return ConflictKept, 0, nil
+	}
+
+	parent, ok := fc.blockchain.GetHeaderByNumber(number - 1)
+	if !ok {
+		return ConflictKept, 0, nil
+	}
+
+	canonical, ok := fc.blockchain.GetHeaderByNumber(number)
+	if !ok {
+		return ConflictKept, 0, nil
+	}
+
+	preferred := fc.preferredLocked(number, parent.Hash)
+	if preferred == nil || preferred.header.Hash == canonical.Hash {
+		return ConflictKept, 0, nil
+	}
+
+	if _, seen := fc.seen[canonical.Hash]; !seen && !preferred.inclusion.byLeader {
+		fc.logger.Debug("canonical block not seen on avail yet; deferring the fork choice", "block_number", number, "block_hash", canonical.Hash)
+		return ConflictDeferred, 0, nil
+	}
+
+	if settled := fc.settled.Number(); number <= settled {
+		observeRefusedReorg()
+		fc.logger.Error("refusing to switch to the preferred block below the settled head", "block_number", number, "block_hash", preferred.header.Hash, "settled_head", settled)
+
+		return ConflictRefused, 0, errReorgBelowSettledHead
+	}
+
+	newHead := preferred.header
+	for {
+		child := fc.preferredLocked(newHead.Number+1, newHead.Hash)
+		if child == nil {
+			break
+		}
+
+		newHead = child.header
+	}
+
+	depth, err := fc.blockchain.Reorg(newHead, fc.maxDepth, source)
+	if errors.Is(err, blockchain.ErrReorgTooDeep) {
+		observeRefusedReorg()
+		fc.logger.Error("refusing to switch to the preferred block", "block_number", number, "block_hash", preferred.header.Hash, "error", err)
+
+		return ConflictRefused, 0, err
+	}
+
+	if err != nil {
+		return ConflictKept, 0, err
+	}
+
+	observeReorg(depth)
+
+	fc.logger.Warn(
+		"switched to the preferred block of the competing ones",
+		"block_number", number,
+		"block_hash", preferred.header.Hash,
+		"replaced_block_hash", canonical.Hash,
+		"depth", depth,
+		"head", newHead.Number,
+	)
+
+	return ConflictReorged, depth, nil
+}
+
+// settle moves the settled head on at the Avail block at the given height,
+// once its blocks are applied.
+func (fc *forkChoice) settle(availHeight uint64) {
+	fc.lock.Lock()
+	defer fc.lock.Unlock()
+
+	if availHeight > fc.followed {
+		fc.followed = availHeight
+	}
+
+	fc.settled.advance(availHeight)
+}
+
+// availCursor returns the Avail block to follow on from, the one after the
+// last one followed; zero if none was.
+func (fc *forkChoice) availCursor() uint64 {
+	fc.lock.Lock()
+	defer fc.lock.Unlock()
+
+	if fc.followed == 0 {
+		return 0
+	}
+
+	return fc.followed + 1
+}
+
+// isDisputeResolutionFork reports whether the block begins a dispute
+// resolution, forking the chain at the disputed block.
+func isDisputeResolutionFork(blk *types.Block) bool {
+	for _, tx := range blk.Transactions {
+		if ok, err := staking.IsBeginDisputeResolutionTx(tx); err == nil && ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// inclusionOf returns the inclusion of the decoded block in the Avail block,
+// given the sequencer scheduled to lead the slot.
+func inclusionOf(availBlk *avail_types.SignedBlock, decoded avail.EdgeBlock, leader types.Address) blockInclusion {
+	return blockInclusion{
+		availBlock:     uint64(availBlk.Block.Header.Number),
+		extrinsicIndex: decoded.ExtrinsicIndex,
+		byLeader:       leader != types.ZeroAddress && types.BytesToAddress(decoded.Header.Miner) == leader,
+	}
+}
@@ -0,0 +1,148 @@
+package avail
+
+import (
+	"testing"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/consensus/avail/validator"
+	"github.com/availproject/op-evm/pkg/avail"
+	"github.com/availproject/op-evm/pkg/avail/testutil"
+	"github.com/availproject/op-evm/pkg/block"
+	"github.com/availproject/op-evm/pkg/blockchain"
+	avail_types "github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// buildTestBlock returns a block of the sequencer on top of its head, with the
+// given transactions, if any.
+func buildTestBlock(t *testing.T, d *Avail, txs ...*types.Transaction) *types.Block {
+	t.Helper()
+
+	bb, err := block.NewBlockBuilderFactory(d.blockchain, d.executor, d.logger).FromBlockchainHead()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bb.SetCoinbaseAddress(d.minerAddr)
+	bb.AddTransactions(txs...)
+	bb.SignWith(d.signKey)
+
+	blk, err := bb.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return blk
+}
+
+// settleTestBlock submits the block to Avail and writes it to the chain of
+// the sequencer, the way the sequencer settles its blocks.
+func settleTestBlock(t *testing.T, d *Avail, fake *testutil.Fake, blk *types.Block) {
+	t.Helper()
+
+	if _, err := fake.SendAndWaitForStatus(d.ctx, blk, avail_types.ExtrinsicStatus{IsInBlock: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.blockchain.WriteBlock(blk, d.nodeType.String()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestForkChoiceConverges(t *testing.T) {
+	appID := avail_types.NewUCompactFromUInt(1)
+	fake := testutil.NewFake(appID)
+
+	a, b, observer := newTestGenesisAvail(t), newTestGenesisAvail(t), newTestGenesisAvail(t)
+
+	// Both sequencers produce block 1; the block of a settles on Avail first,
+	// while b goes on with a block on top of its own.
+	blkA := buildTestBlock(t, a)
+	blkB := buildTestBlock(t, b)
+
+	settleTestBlock(t, a, fake, blkA)
+	settleTestBlock(t, b, fake, blkB)
+	settleTestBlock(t, b, fake, buildTestBlock(t, b))
+
+	assert.Equal(t, uint64(2), b.blockchain.Header().Number)
+
+	// Every node follows Avail and converges on the block settled first.
+	for _, d := range []*Avail{a, b, observer} {
+		sw, fraudResolver, _ := newTestSequencerWorkerOf(t, d, fake)
+		sw.availClient = fake
+		sw.availAppID = appID
+		sw.catchUp = CatchUpConfig{Threshold: 0, PageSize: 20}
+
+		decoder := avail.NewBlockDecoder(fake, appID, sw.logger)
+
+		if _, err := sw.catchUpWithAvail(decoder, validator.New(sw.blockchain, sw.nodeAddr, sw.logger), fraudResolver, 1); err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, blkA.Hash(), d.blockchain.Header().Hash)
+
+		canonical, ok := d.blockchain.GetHeaderByNumber(1)
+		if assert.True(t, ok) {
+			assert.Equal(t, blkA.Hash(), canonical.Hash)
+		}
+
+		_, ok = d.blockchain.GetHeaderByNumber(2)
+		assert.False(t, ok)
+	}
+}
+
+func TestForkChoicePrefersScheduledLeader(t *testing.T) {
+	a, b := newTestGenesisAvail(t), newTestGenesisAvail(t)
+
+	blkA := buildTestBlock(t, a)
+	blkB := buildTestBlock(t, b)
+
+	// b has its own block written ahead of the Avail stream.
+	if err := b.blockchain.WriteBlock(blkB, b.nodeType.String()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The block of b settles on Avail later, but b is the scheduled leader.
+	inclusionA := blockInclusion{availBlock: 1, extrinsicIndex: 0}
+	inclusionB := blockInclusion{availBlock: 2, extrinsicIndex: 0, byLeader: true}
+
+	for _, d := range []*Avail{a, b} {
+		fc := newForkChoice(d.blockchain, d.minerAddr, DefaultMaxReorgDepth, nil, nil, d.logger)
+
+		_, err := fc.apply(blkA, inclusionA, d.nodeType.String())
+		assert.NoError(t, err)
+
+		_, err = fc.apply(blkB, inclusionB, d.nodeType.String())
+		assert.NoError(t, err)
+
+		assert.Equal(t, blkB.Hash(), d.blockchain.Header().Hash)
+	}
+}
+
+func TestForkChoiceBoundsReorgDepth(t *testing.T) {
+	a, b := newTestGenesisAvail(t), newTestGenesisAvail(t)
+
+	blkA := buildTestBlock(t, a)
+
+	blkB := buildTestBlock(t, b)
+	if err := b.blockchain.WriteBlock(blkB, b.nodeType.String()); err != nil {
+		t.Fatal(err)
+	}
+
+	childB := buildTestBlock(t, b)
+	if err := b.blockchain.WriteBlock(childB, b.nodeType.String()); err != nil {
+		t.Fatal(err)
+	}
+
+	fc := newForkChoice(b.blockchain, b.minerAddr, 1, nil, nil, b.logger)
+
+	write, err := fc.apply(blkA, blockInclusion{availBlock: 1}, b.nodeType.String())
+	assert.NoError(t, err)
+	assert.Equal(t, blockConflicting, write)
+
+	write, err = fc.apply(blkB, blockInclusion{availBlock: 2}, b.nodeType.String())
+	assert.ErrorIs(t, err, blockchain.ErrReorgTooDeep)
+	assert.Equal(t, blockDuplicate, write)
+
+	assert.Equal(t, childB.Hash(), b.blockchain.Header().Hash)
+}
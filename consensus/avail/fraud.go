@@ -2,10 +2,12 @@ package avail
 
 import (
 	"bytes"
+	"context"
 	"crypto/ecdsa"
 	"errors"
 	"fmt"
 	"runtime"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -13,6 +15,7 @@ import (
 	"github.com/0xPolygon/polygon-edge/state"
 	"github.com/0xPolygon/polygon-edge/txpool"
 	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/armon/go-metrics"
 	"github.com/availproject/op-evm/consensus/avail/watchtower"
 	"github.com/availproject/op-evm/pkg/avail"
 	"github.com/availproject/op-evm/pkg/block"
@@ -31,21 +34,37 @@ var (
 // Fraud is a structure that represents the state of a node in a blockchain system that is capable of detecting and handling fraudulent activities.
 // It contains various state data and services required to perform its function.
 type Fraud struct {
+	ctx                    context.Context        // ctx bounds the submissions of the fraud proof and dispute resolution blocks to Avail.
 	logger                 hclog.Logger           // logger provides a logging interface for the fraud detection system.
 	blockchain             *blockchain.Blockchain // blockchain is a reference to the blockchain being monitored.
 	executor               *state.Executor        // executor is a reference to the state executor.
 	txpool                 *txpool.TxPool         // txpool refers to the transaction pool where incoming transactions are stored.
+	poolIndex              *txPoolIndex           // poolIndex lists the transactions of the txpool by sender.
 	watchtower             watchtower.WatchTower  // watchtower is a reference to the watchtower consensus algorithm.
+	settled                *settledHead           // settled is the soft finality of the chain, bounding the blocks open to fraud proofs.
+	catalog                *fraudCatalog          // catalog records the fraud proofs seen, verified or not.
+	feed                   *disputeFeed           // feed publishes the blocks rolled back by the dispute resolutions.
+	metrics                *fraudMetrics          // metrics records the fraud proofs verified and rejected, and the blocks rolled back.
 	blockProductionEnabled *atomic.Bool           // blockProductionEnabled is an atomic boolean representing whether the block production is enabled.
 
 	nodeAddr    types.Address     // nodeAddr represents the address of the node.
 	nodeSignKey *ecdsa.PrivateKey // nodeSignKey is the node's private key for signing transactions.
 	availSender avail.Sender      // availSender represents a sender in the Avail network.
+	fraudTip    uint64            // fraudTip is the tip paid for the inclusion of the dispute resolution blocks in Avail.
 	nodeType    MechanismType     // nodeType specifies the type of the node.
 
 	fraudBlock          *types.Block       // fraudBlock is the block suspected of fraud.
 	lastFraudDisputedTx *types.Transaction // lastFraudDisputedTx is the last transaction that was disputed for fraud.
 	chainProcessStatus  uint32             // chainProcessStatus represents the status of the chain processing.
+
+	verifyTimeout time.Duration            // verifyTimeout bounds the verification of the fraud proofs.
+	rejectedLock  sync.Mutex               // rejectedLock guards rejected.
+	rejected      map[types.Address]uint64 // rejected counts the rejected fraud proofs, by watchtower.
+
+	quorum      uint64                     // quorum is the number of distinct staked watchtowers whose fraud proofs must accuse a block.
+	accusations map[types.Hash]*accusation // accusations are the accused blocks short of the quorum, by hash.
+
+	defended map[types.Hash]struct{} // defended are the blocks of the node defended against fraud proofs, by hash.
 }
 
 // SetBlock sets the block suspected of fraud.
@@ -88,11 +107,69 @@ func (f *Fraud) IsReadyToSlash() bool {
 	return false
 }
 
-// CheckAndSetFraudBlock checks a list of blocks and sets a block suspected of fraud if it finds one.
-// This is done by analyzing the extra data attached to a block.
-func (f *Fraud) CheckAndSetFraudBlock(blocks []*types.Block) bool {
-	for _, blk := range blocks {
+// CheckAndSetFraudBlock checks a list of blocks, received in the Avail block at the given height, and sets
+// a block suspected of fraud if it finds one. This is done by analyzing the extra data attached to a block.
+// The accusation of the fraud proof is verified first; the fraud proofs that don't verify, or challenge a
+// block past its challenge window, are rejected and counted against their watchtower. Either way, the fraud
+// proof is recorded in the fraud catalog, if any. Under a quorum of watchtowers, the block is set once the
+// fraud proofs of the quorum accuse the same block, halting the chain; the accusations short of it expire
+// with the challenge window of their block. A fraud proof processed already, as delivered again by a replay
+// of Avail after a restart too, is ignored, and so is one of a block whose dispute already ended.
+func (f *Fraud) CheckAndSetFraudBlock(availHeight uint64, blocks []avail.EdgeBlock) bool {
+	f.expireAccusations(availHeight)
+
+	for _, decoded := range blocks {
+		blk := decoded.Block
 		if fraudProofBlockHash, exists := block.GetExtraDataFraudProofTarget(blk.Header); exists {
+			if f.catalog.replayed(blk) {
+				observeReplayedFraudProof()
+				f.logger.Debug("fraud proof processed already; ignoring it", "watchtower_fraud_block_hash", blk.Hash(), "probation_block_hash", fraudProofBlockHash)
+				continue
+			}
+
+			if f.catalog.closed(fraudProofBlockHash) {
+				observeStaleFraudProof()
+				f.logger.Info("fraud proof of a block whose dispute already ended; ignoring it", "watchtower_fraud_block_hash", blk.Hash(), "probation_block_hash", fraudProofBlockHash)
+				continue
+			}
+
+			var (
+				accused      *types.Header
+				accusedMiner types.Address
+			)
+
+			if h, ok := f.blockchain.GetHeaderByHash(fraudProofBlockHash); ok {
+				accused, accusedMiner = h, types.BytesToAddress(h.Miner)
+			}
+
+			f.metrics.fraudProofObserved(accusedMiner)
+
+			if err := f.verifyFraudProof(blk, availHeight); err != nil {
+				f.catalog.detected(blk, accused, availHeight, err)
+				f.catalog.process(blk)
+				f.rejectFraudProof(blk, accusedMiner, err)
+				continue
+			}
+
+			f.metrics.fraudProofVerified(accusedMiner)
+			f.catalog.detected(blk, accused, availHeight, nil)
+
+			if !f.accuse(blk, availHeight) {
+				continue
+			}
+
+			f.catalog.process(blk)
+
+			// Under a quorum, the chain halts on it rather than on the begin
+			// dispute resolution transaction of a single watchtower.
+			if f.quorum > 1 {
+				if tx, ok := f.txpool.GetPendingTx(f.beginDisputeTxHashOf(blk)); ok {
+					f.lastFraudDisputedTx = tx
+				}
+
+				f.SetChainStatus(ChainProcessingDisabled)
+			}
+
 			f.logger.Info(
 				"Fraud proof parent hash block discovered. Continuing with fraud dispute resolution...",
 				"probation_block_hash", fraudProofBlockHash,
@@ -126,8 +203,18 @@ func (f *Fraud) EndDisputeResolution() {
 // ShouldStopProducingBlocks contains the main logic of the fraud detection system.
 // It monitors the transaction pool and checks for any transactions indicating fraudulent activities.
 // If it detects a fraud, it will update the chain status to disabled and stop producing new blocks.
+// It returns once the run context of the fraud resolver is canceled, or right away under a quorum of
+// watchtowers: the chain halts on the quorum of the fraud proofs seen on Avail then, see CheckAndSetFraudBlock.
 func (f *Fraud) ShouldStopProducingBlocks(activeParticipantsQuerier staking.ActiveParticipants) {
+	if f.quorum > 1 {
+		return
+	}
+
 	for {
+		if f.ctx.Err() != nil {
+			return
+		}
+
 		// We've already received begin dispute resolution transaction. Now it's time to wait for
 		// processing prior we check tx pool again...
 		if f.IsChainDisabled() {
@@ -135,10 +222,13 @@ func (f *Fraud) ShouldStopProducingBlocks(activeParticipantsQuerier staking.Acti
 			continue
 		}
 
-		f.txpool.Prepare(f.txpool.GetBaseFee())
+		f.txpool.Prepare(f.blockchain.CalculateBaseFee(f.blockchain.Header()))
 
 	innerLoop:
 		for {
+			if f.ctx.Err() != nil {
+				return
+			}
 
 			tx := f.txpool.Peek()
 			if tx == nil {
@@ -208,7 +298,7 @@ func (f *Fraud) ShouldStopProducingBlocks(activeParticipantsQuerier staking.Acti
 // logs its discovery, and returns it with a nil error.
 // If no matching transaction is found, the function returns a nil transaction and an error stating the transaction hash was not found.
 func (f *Fraud) DiscoverDisputeResolutionTx(hash types.Hash) (*types.Transaction, error) {
-	f.txpool.Prepare(f.txpool.GetBaseFee())
+	f.txpool.Prepare(f.blockchain.CalculateBaseFee(f.blockchain.Header()))
 
 	for {
 		tx := f.txpool.Peek()
@@ -236,7 +326,12 @@ func (f *Fraud) DiscoverDisputeResolutionTx(hash types.Hash) (*types.Transaction
 // GetBeginDisputeResolutionTxHash retrieves the hash of the transaction that initiated the dispute resolution process.
 // This is done by extracting the dispute resolution target from the extra data in the fraud block's header.
 func (f *Fraud) GetBeginDisputeResolutionTxHash() types.Hash {
-	hash, _ := block.GetExtraDataBeginDisputeResolutionTarget(f.fraudBlock.Header)
+	return f.beginDisputeTxHashOf(f.fraudBlock)
+}
+
+// beginDisputeTxHashOf returns the hash of the begin dispute resolution transaction of the fraud proof block.
+func (f *Fraud) beginDisputeTxHashOf(fraudBlk *types.Block) types.Hash {
+	hash, _ := block.GetExtraDataBeginDisputeResolutionTarget(fraudBlk.Header)
 	return hash
 }
 
@@ -405,45 +500,40 @@ func (f *Fraud) slashNode(maliciousAddr types.Address, maliciousHeader *types.He
 }
 
 // produceBeginDisputeResolutionBlock initiates the creation of a dispute resolution block to flag a potential fraudulent activity by a node.
-// Depending on the node type, it will either create a new block by forking the chain (in case of a sequencer node) or just create a block from the current head of the blockchain (in case of a watchtower node).
-// The function then sets the block number, coinbase address, and signs the block.
+// In case of a sequencer node, the chain is first rolled back to the parent of the malicious block, its descendants discarded and the
+// transactions of the honest users in them put back into the transaction pool; the block is then created from the head of the blockchain,
+// the honest tip in case of a sequencer node.
+// The function then sets the coinbase address, and signs the block.
 // It fetches the transaction hash for beginning the dispute resolution from the fraudulent block and discovers the associated transaction, which is then added to the dispute resolution block.
 // The block is built and sent to the Avail network. On successful submission, the block is written to the blockchain.
 // The function also resets the transaction pool with the current block header to remove stale transactions.
 // It logs the successful creation and addition of the dispute resolution block to the blockchain, then returns the block and a nil error.
 // If at any point an error occurs, the function logs the error and returns a nil block along with the error.
 func (f *Fraud) produceBeginDisputeResolutionBlock(blockBuilderFactory block.BlockBuilderFactory, maliciousAddr types.Address, maliciousHeader *types.Header, nodeType MechanismType) (*types.Block, error) {
-	var bb block.Builder
-	var err error
-
-	chainTD, found := f.blockchain.GetChainTD()
-	if !found {
-		return nil, fmt.Errorf("couldn't get chain TD: %s", err)
-	}
-
 	// We are going to fork the chain but only if the malicious participant is sequencer.
 	// Otherwise we are making sure we slash the watchtower and continue normal operation...
 	switch nodeType {
 	case Sequencer:
-		bb, err = blockBuilderFactory.FromParentHash(maliciousHeader.ParentHash)
-		if err != nil {
+		oldHead := f.blockchain.Header()
+
+		if _, err := rollBackFraudulentBlocks(f.blockchain, maliciousHeader.ParentHash, f.nodeType.String(), f.feed, f.metrics, f.logger); err != nil {
+			f.logger.Error("failed to roll the fraudulent blocks out of the chain", "malicious_block_hash", maliciousHeader.Hash, "error", err)
 			return nil, err
 		}
 
-		// Force sequential block number.
-		bb.SetBlockNumber(f.blockchain.Header().Number + 1)
-
-		// Increase difficulty by one to cause reorg, since we are forking the chain.
-		bb.SetDifficulty(chainTD.Uint64() + 1)
+		// The transactions of the honest users in the blocks rolled out of
+		// the chain go back into the txpool.
+		requeueDiscardedTxs(f.blockchain, f.executor, f.txpool, f.poolIndex, oldHead, maliciousHeader.ParentHash, f.logger)
 	case WatchTower:
-		bb, err = blockBuilderFactory.FromBlockchainHead()
-		if err != nil {
-			return nil, err
-		}
 	default:
 		panic("unsupported node type: " + nodeType)
 	}
 
+	bb, err := blockBuilderFactory.FromBlockchainHead()
+	if err != nil {
+		return nil, err
+	}
+
 	bb.SetCoinbaseAddress(f.nodeAddr)
 	bb.SignWith(f.nodeSignKey)
 
@@ -474,7 +564,7 @@ func (f *Fraud) produceBeginDisputeResolutionBlock(blockBuilderFactory block.Blo
 		"parent_block_hash", maliciousHeader.ParentHash,
 	)
 
-	err = f.availSender.SendAndWaitForStatus(blk, stypes.ExtrinsicStatus{IsInBlock: true})
+	_, err = f.availSender.SendAndWaitForStatus(f.ctx, blk, stypes.ExtrinsicStatus{IsInBlock: true}, avail.WithTip(f.fraudTip))
 	if err != nil {
 		f.logger.Error("error while submitting begin dispute resolution block to avail", "error", err)
 		return nil, err
@@ -539,7 +629,7 @@ func (f *Fraud) produceSlashBlock(blockBuilderFactory block.BlockBuilderFactory,
 		return nil, err
 	}
 
-	slashBlk.AddTransactions(dtx)
+	slashBlk.AddTransactions(dtx.ComputeHash())
 
 	// Used to ensure we can end fraud dispute for a specific fraud block on all of the nodes!
 	slashBlk.SetExtraDataField(block.KeyEndDisputeResolutionOf, f.fraudBlock.Hash().Bytes())
@@ -557,7 +647,7 @@ func (f *Fraud) produceSlashBlock(blockBuilderFactory block.BlockBuilderFactory,
 		"parent_block_hash", maliciousHeader.ParentHash,
 	)
 
-	err = f.availSender.SendAndWaitForStatus(blk, stypes.ExtrinsicStatus{IsInBlock: true})
+	_, err = f.availSender.SendAndWaitForStatus(f.ctx, blk, stypes.ExtrinsicStatus{IsInBlock: true}, avail.WithTip(f.fraudTip))
 	if err != nil {
 		f.logger.Error("error while submitting slashing block to avail", "error", err)
 		return nil, err
@@ -586,20 +676,35 @@ func (f *Fraud) produceSlashBlock(blockBuilderFactory block.BlockBuilderFactory,
 
 // NewFraudResolver creates a new FraudResolver instance which is used to detect and handle fraudulent activity within the blockchain network.
 // The FraudResolver uses several components such as a logger, a blockchain, an executor, a transaction pool, and a watchtower to perform its functions.
+// The fraud proofs are accepted within the challenge window of the settled head, if tracked, and recorded in the given fraud catalog, if any;
+// the blocks rolled back by the dispute resolutions are published to the given dispute feed, if any.
+// A block is disputed once the fraud proofs of the given quorum of distinct staked watchtowers accuse it; a quorum of 1 takes any single one.
 // It also requires several settings such as the node address, node signing key, a sender for Avail network communication, and the node type (sequencer or watchtower).
 // The created FraudResolver also includes information on the status of chain processing and block production.
-func NewFraudResolver(logger hclog.Logger, b *blockchain.Blockchain, e *state.Executor, txp *txpool.TxPool, w watchtower.WatchTower, blockProductionEnabled *atomic.Bool, nodeAddr types.Address, nodeSignKey *ecdsa.PrivateKey, availSender avail.Sender, nodeType MechanismType) *Fraud {
+func NewFraudResolver(ctx context.Context, logger hclog.Logger, b *blockchain.Blockchain, e *state.Executor, txp *txpool.TxPool, poolIndex *txPoolIndex, w watchtower.WatchTower, settled *settledHead, catalog *fraudCatalog, feed *disputeFeed, blockProductionEnabled *atomic.Bool, nodeAddr types.Address, nodeSignKey *ecdsa.PrivateKey, availSender avail.Sender, fraudTip uint64, quorum uint64, nodeType MechanismType) *Fraud {
 	return &Fraud{
+		ctx:                    ctx,
 		logger:                 logger,
 		blockchain:             b,
 		executor:               e,
 		txpool:                 txp,
+		poolIndex:              poolIndex,
 		watchtower:             w,
+		settled:                settled,
+		catalog:                catalog,
+		feed:                   feed,
+		metrics:                newFraudMetrics(metrics.Default()),
 		nodeAddr:               nodeAddr,
 		nodeType:               nodeType,
 		nodeSignKey:            nodeSignKey,
 		availSender:            availSender,
+		fraudTip:               fraudTip,
 		chainProcessStatus:     ChainProcessingEnabled,
 		blockProductionEnabled: blockProductionEnabled,
+		verifyTimeout:          fraudProofVerifyTimeout,
+		rejected:               make(map[types.Address]uint64),
+		quorum:                 quorum,
+		accusations:            make(map[types.Hash]*accusation),
+		defended:               make(map[types.Hash]struct{}),
 	}
 }
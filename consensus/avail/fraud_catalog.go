@@ -0,0 +1,506 @@
+package avail
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/pkg/block"
+	"github.com/availproject/op-evm/pkg/staking"
+	"github.com/hashicorp/go-hclog"
+)
+
+// FraudCatalogFileName is the name of the file, in the consensus data
+// directory, the fraud catalog is persisted to.
+const FraudCatalogFileName = "fraud_catalog.json"
+
+// fraudEventsPageSize is the most fraud events returned at once by
+// `avail_getFraudEvents`.
+const fraudEventsPageSize = 100
+
+// FraudOutcome is how a fraud event was resolved.
+type FraudOutcome string
+
+const (
+	// FraudDisputed is the outcome of the fraud events whose fraud proof
+	// verified, while the dispute is unresolved.
+	FraudDisputed FraudOutcome = "disputed"
+
+	// FraudRejected is the outcome of the fraud events whose fraud proof
+	// didn't verify.
+	FraudRejected FraudOutcome = "rejected"
+
+	// FraudSequencerSlashed is the outcome of the fraud events whose dispute
+	// ended with the slash of the sequencer of the block.
+	FraudSequencerSlashed FraudOutcome = "sequencer_slashed"
+
+	// FraudWatchtowerSlashed is the outcome of the fraud events whose dispute
+	// ended with the slash of the watchtower raising the fraud proof.
+	FraudWatchtowerSlashed FraudOutcome = "watchtower_slashed"
+
+	// FraudDismissed is the outcome of the fraud events whose dispute ended
+	// without a slash.
+	FraudDismissed FraudOutcome = "dismissed"
+
+	// FraudExpired is the outcome of the fraud events whose accusation
+	// expired short of the quorum of watchtowers.
+	FraudExpired FraudOutcome = "expired"
+)
+
+// FraudEvent is the record of a block accused of fraud, from the fraud proof
+// seen on Avail to the resolution of its dispute, as returned by
+// `avail_getFraudEvents`. The hashes of the dispute transactions not written
+// to the chain yet are zero.
+type FraudEvent struct {
+	BlockHash   types.Hash    `json:"blockHash"`
+	BlockNumber uint64        `json:"blockNumber"`
+	Miner       types.Address `json:"miner"`
+	Watchtower  types.Address `json:"watchtower"`
+
+	// FraudProofHash is the hash of the fraud proof block, seen in the Avail
+	// block at AvailBlock.
+	FraudProofHash types.Hash `json:"fraudProofHash"`
+	AvailBlock     uint64     `json:"availBlock"`
+
+	BeginDisputeTxHash types.Hash `json:"beginDisputeTxHash"`
+	EndDisputeTxHash   types.Hash `json:"endDisputeTxHash"`
+	SlashTxHash        types.Hash `json:"slashTxHash"`
+
+	Outcome FraudOutcome `json:"outcome"`
+
+	// Reason is why the fraud proof was rejected, if it was.
+	Reason string `json:"reason,omitempty"`
+
+	DetectedAt time.Time  `json:"detectedAt"`
+	UpdatedAt  time.Time  `json:"updatedAt"`
+	ResolvedAt *time.Time `json:"resolvedAt,omitempty"`
+}
+
+// FraudEventsPage is a page of the fraud events, as returned by
+// `avail_getFraudEvents`; Next is the block number to query the next page
+// from, if any.
+type FraudEventsPage struct {
+	Events []FraudEvent `json:"events"`
+	Next   *uint64      `json:"next,omitempty"`
+}
+
+// fraudProofID identifies a fraud proof by the block it accuses and the
+// watchtower raising it.
+type fraudProofID struct {
+	BlockHash  types.Hash    `json:"blockHash"`
+	Watchtower types.Address `json:"watchtower"`
+}
+
+// fraudCatalog records the fraud events seen by the node, one per accused
+// block, and updates them as their disputes progress on the chain. It keeps
+// the fraud proofs processed as well, for the ones delivered again by a
+// replay of Avail to be told apart. The catalog is persisted to a file in the
+// data directory, if any, to outlive restarts; observing the same fraud proof
+// or dispute transaction again leaves it unchanged.
+type fraudCatalog struct {
+	path   string
+	logger hclog.Logger
+
+	lock      sync.Mutex
+	events    []*FraudEvent // in the order detected
+	processed map[fraudProofID]struct{}
+}
+
+// fraudCatalogFile is the content of the fraud catalog file.
+type fraudCatalogFile struct {
+	Events    []*FraudEvent  `json:"events"`
+	Processed []fraudProofID `json:"processed,omitempty"`
+}
+
+// newFraudCatalog returns an empty fraudCatalog persisted to the given data
+// directory, or kept in memory if it's empty.
+func newFraudCatalog(dataDir string, logger hclog.Logger) *fraudCatalog {
+	c := &fraudCatalog{logger: logger, processed: make(map[fraudProofID]struct{})}
+	if dataDir != "" {
+		c.path = filepath.Join(dataDir, FraudCatalogFileName)
+	}
+
+	return c
+}
+
+// loadFraudCatalog returns the fraudCatalog persisted to the given data
+// directory, with the fraud events recorded by the previous runs.
+func loadFraudCatalog(dataDir string, logger hclog.Logger) (*fraudCatalog, error) {
+	c := newFraudCatalog(dataDir, logger)
+	if c.path == "" {
+		return c, nil
+	}
+
+	bs, err := os.ReadFile(c.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var file fraudCatalogFile
+	if err := json.Unmarshal(bs, &file); err != nil {
+		return nil, fmt.Errorf("invalid fraud catalog file %q: %w", c.path, err)
+	}
+
+	c.events = file.Events
+
+	for _, id := range file.Processed {
+		c.processed[id] = struct{}{}
+	}
+
+	return c, nil
+}
+
+// fraudProofIDOf returns the identifier of the fraud proof block.
+func fraudProofIDOf(fraudBlk *types.Block) fraudProofID {
+	target, _ := block.GetExtraDataFraudProofTarget(fraudBlk.Header)
+	return fraudProofID{BlockHash: target, Watchtower: types.BytesToAddress(fraudBlk.Header.Miner)}
+}
+
+// replayed reports whether the fraud proof was processed already, in this
+// run or a previous one.
+func (c *fraudCatalog) replayed(fraudBlk *types.Block) bool {
+	if c == nil {
+		return false
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	_, ok := c.processed[fraudProofIDOf(fraudBlk)]
+
+	return ok
+}
+
+// process records the fraud proof as processed: rejected, or raising the
+// dispute of its block.
+func (c *fraudCatalog) process(fraudBlk *types.Block) {
+	if c == nil {
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	id := fraudProofIDOf(fraudBlk)
+	if _, ok := c.processed[id]; ok {
+		return
+	}
+
+	c.processed[id] = struct{}{}
+	c.saveLocked()
+}
+
+// closed reports whether the dispute of the block of the given hash ended,
+// with the slash of either party or without one.
+func (c *fraudCatalog) closed(target types.Hash) bool {
+	if c == nil {
+		return false
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for _, e := range c.events {
+		if e.BlockHash != target {
+			continue
+		}
+
+		switch e.Outcome {
+		case FraudSequencerSlashed, FraudWatchtowerSlashed, FraudDismissed:
+			return true
+		}
+	}
+
+	return false
+}
+
+// detected records the fraud event of the fraud proof seen in the Avail
+// block at the given height, accusing the block of the given header, nil if
+// unknown; err is why the fraud proof was rejected, if it was. A fraud proof
+// of a block already accused is ignored.
+func (c *fraudCatalog) detected(fraudBlk *types.Block, accused *types.Header, availHeight uint64, err error) {
+	if c == nil {
+		return
+	}
+
+	target, ok := block.GetExtraDataFraudProofTarget(fraudBlk.Header)
+	if !ok {
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for _, e := range c.events {
+		if e.BlockHash == target {
+			return
+		}
+	}
+
+	now := time.Now()
+
+	e := &FraudEvent{
+		BlockHash:      target,
+		Watchtower:     types.BytesToAddress(fraudBlk.Header.Miner),
+		FraudProofHash: fraudBlk.Hash(),
+		AvailBlock:     availHeight,
+		Outcome:        FraudDisputed,
+		DetectedAt:     now,
+		UpdatedAt:      now,
+	}
+
+	e.BeginDisputeTxHash, _ = block.GetExtraDataBeginDisputeResolutionTarget(fraudBlk.Header)
+
+	if accused != nil {
+		e.BlockNumber = accused.Number
+		e.Miner = types.BytesToAddress(accused.Miner)
+	}
+
+	if err != nil {
+		e.Outcome = FraudRejected
+		e.Reason = err.Error()
+		e.ResolvedAt = &now
+	}
+
+	c.events = append(c.events, e)
+	c.saveLocked()
+}
+
+// event returns the fraud event of the block of the given hash, if it was
+// accused.
+func (c *fraudCatalog) event(target types.Hash) (FraudEvent, bool) {
+	if c == nil {
+		return FraudEvent{}, false
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for _, e := range c.events {
+		if e.BlockHash == target {
+			return *e, true
+		}
+	}
+
+	return FraudEvent{}, false
+}
+
+// expired resolves the unresolved fraud event of the block of the given hash,
+// whose accusation expired short of the quorum of watchtowers.
+func (c *fraudCatalog) expired(target types.Hash) {
+	if c == nil {
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for _, e := range c.events {
+		if e.BlockHash != target || e.Outcome != FraudDisputed {
+			continue
+		}
+
+		now := time.Now()
+
+		e.Outcome = FraudExpired
+		e.UpdatedAt = now
+		e.ResolvedAt = &now
+
+		c.saveLocked()
+	}
+}
+
+// observe updates the unresolved fraud events with the dispute transactions
+// of the block written to the chain: the end of the dispute and the slash of
+// either party resolve them. It's run as a post-commit hook.
+func (c *fraudCatalog) observe(blk *types.Block, receipts []*types.Receipt) {
+	if c == nil {
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	var (
+		ended   = make(map[*FraudEvent]types.Hash)
+		slashed = make(map[*FraudEvent]types.Hash)
+	)
+
+	for i, tx := range blk.Transactions {
+		if i >= len(receipts) {
+			break
+		}
+
+		receipt := receipts[i]
+
+		for _, log := range receipt.Logs {
+			if event, ok := staking.ParseDisputeEvent(log); ok && !event.Began {
+				// A slash ends the dispute as well, after the transaction
+				// ending it, if any.
+				if e := c.latestLocked(event.Account, FraudDisputed); e != nil && ended[e] == types.ZeroHash {
+					ended[e] = receipt.TxHash
+				}
+			}
+		}
+
+		staker, ok := staking.SlashedStaker(tx)
+		if !ok || receipt.Status == nil || *receipt.Status != types.ReceiptSuccess {
+			continue
+		}
+
+		// The slash retried after the dispute ended resolves it still.
+		if e := c.latestLocked(staker, FraudDisputed, FraudDismissed); e != nil {
+			e.Outcome = FraudSequencerSlashed
+			if staker != e.Miner {
+				e.Outcome = FraudWatchtowerSlashed
+			}
+
+			slashed[e] = receipt.TxHash
+		}
+	}
+
+	if len(ended) == 0 && len(slashed) == 0 {
+		return
+	}
+
+	now := time.Now()
+
+	for e, hash := range ended {
+		e.EndDisputeTxHash = hash
+		if _, ok := slashed[e]; !ok {
+			e.Outcome = FraudDismissed
+		}
+	}
+
+	for e, hash := range slashed {
+		e.SlashTxHash = hash
+		ended[e] = e.EndDisputeTxHash
+	}
+
+	for e := range ended {
+		e.UpdatedAt = now
+		e.ResolvedAt = &now
+		c.logger.Info("fraud event resolved", "block_hash", e.BlockHash, "miner", e.Miner, "watchtower", e.Watchtower, "outcome", e.Outcome)
+	}
+
+	c.saveLocked()
+}
+
+// latestLocked returns the latest fraud event of the account, as the miner of
+// the accused block or the watchtower accusing it, with one of the given
+// outcomes. It must be called with the lock held.
+func (c *fraudCatalog) latestLocked(account types.Address, outcomes ...FraudOutcome) *FraudEvent {
+	for i := len(c.events) - 1; i >= 0; i-- {
+		e := c.events[i]
+		if e.Miner != account && e.Watchtower != account {
+			continue
+		}
+
+		for _, outcome := range outcomes {
+			if e.Outcome == outcome {
+				return e
+			}
+		}
+	}
+
+	return nil
+}
+
+// Events returns the page of the fraud events of the blocks numbered from and
+// to the given ones, inclusive, of the given miner if not nil, by block
+// number. A page holds either all the events of a block number or none.
+func (c *fraudCatalog) Events(from, to uint64, miner *types.Address) FraudEventsPage {
+	page := FraudEventsPage{Events: []FraudEvent{}}
+	if c == nil {
+		return page
+	}
+
+	c.lock.Lock()
+
+	var events []FraudEvent
+
+	for _, e := range c.events {
+		if e.BlockNumber < from || e.BlockNumber > to || (miner != nil && e.Miner != *miner) {
+			continue
+		}
+
+		events = append(events, *e)
+	}
+
+	c.lock.Unlock()
+
+	sort.SliceStable(events, func(i, j int) bool { return events[i].BlockNumber < events[j].BlockNumber })
+
+	if len(events) <= fraudEventsPageSize {
+		page.Events = append(page.Events, events...)
+		return page
+	}
+
+	// The page ends before the events of the block number cut through, or
+	// after them if they fill it up on their own.
+	end := fraudEventsPageSize
+	for end > 0 && events[end-1].BlockNumber == events[fraudEventsPageSize].BlockNumber {
+		end--
+	}
+
+	if end == 0 {
+		end = fraudEventsPageSize
+		for end < len(events) && events[end].BlockNumber == events[end-1].BlockNumber {
+			end++
+		}
+	}
+
+	page.Events = append(page.Events, events[:end]...)
+
+	if end < len(events) {
+		next := events[end].BlockNumber
+		page.Next = &next
+	}
+
+	return page
+}
+
+// saveLocked persists the catalog; it writes a temporary file first and
+// renames it over the catalog file. It must be called with the lock held.
+func (c *fraudCatalog) saveLocked() {
+	if c.path == "" {
+		return
+	}
+
+	if err := c.writeLocked(); err != nil {
+		c.logger.Error("failed to persist the fraud catalog", "path", c.path, "error", err)
+	}
+}
+
+func (c *fraudCatalog) writeLocked() error {
+	file := fraudCatalogFile{Events: c.events}
+	for id := range c.processed {
+		file.Processed = append(file.Processed, id)
+	}
+
+	bs, err := json.Marshal(file)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o700); err != nil {
+		return err
+	}
+
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, bs, 0o600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, c.path)
+}
@@ -0,0 +1,387 @@
+package avail
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/consensus/avail/watchtower"
+	"github.com/availproject/op-evm/pkg/avail"
+	"github.com/availproject/op-evm/pkg/avail/testutil"
+	"github.com/availproject/op-evm/pkg/block"
+	"github.com/availproject/op-evm/pkg/common"
+	"github.com/availproject/op-evm/pkg/staking"
+	"github.com/availproject/op-evm/pkg/test"
+	avail_types "github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFraudCatalogSurvivesRestart(t *testing.T) {
+	d, _ := NewTestAvail(t, Sequencer)
+	sw, fraudResolver, _ := newTestSequencerWorkerOf(t, d, testutil.NewFake(avail_types.NewUCompactFromUInt(1)))
+
+	dir := t.TempDir()
+
+	catalog := newFraudCatalog(dir, hclog.Default())
+	fraudResolver.catalog = catalog
+	sw.blockchain.RegisterPostCommitHook(catalog.observe)
+
+	sender := staking.NewTestAvailSender()
+	stake := big.NewInt(0).Mul(big.NewInt(10), common.ETH)
+
+	// The local sequencer, two malicious ones and two watchtowers stake.
+	malicious1, maliciousKey1 := test.NewAccount(t)
+	malicious2, maliciousKey2 := test.NewAccount(t)
+	watchtowerAddr1, watchtowerKey1 := test.NewAccount(t)
+	watchtowerAddr2, watchtowerKey2 := test.NewAccount(t)
+
+	for _, addr := range []types.Address{malicious1, malicious2, watchtowerAddr1, watchtowerAddr2} {
+		test.DepositBalance(t, addr, big.NewInt(0).Mul(big.NewInt(1000), common.ETH), sw.blockchain, sw.executor)
+	}
+
+	stakers := []struct {
+		node staking.NodeType
+		addr types.Address
+		key  *ecdsa.PrivateKey
+	}{
+		{staking.Sequencer, sw.nodeAddr, sw.nodeSignKey},
+		{staking.Sequencer, malicious1, maliciousKey1},
+		{staking.Sequencer, malicious2, maliciousKey2},
+		{staking.WatchTower, watchtowerAddr1, watchtowerKey1},
+		{staking.WatchTower, watchtowerAddr2, watchtowerKey2},
+	}
+
+	for _, s := range stakers {
+		if err := staking.Stake(sw.blockchain, sw.executor, sender, hclog.Default(), string(s.node), s.addr, s.key, stake, 1_000_000, "test"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Both malicious sequencers produce a block, one on top of the other,
+	// and a watchtower raises a fraud proof of each. Only the transaction
+	// beginning the dispute of the first one makes it to the txpool.
+	var accused, fraudProofs []*types.Block
+
+	for i, m := range []struct {
+		addr types.Address
+		key  *ecdsa.PrivateKey
+		wt   watchtower.WatchTower
+	}{
+		{malicious1, maliciousKey1, watchtower.New(sw.blockchain, sw.executor, sw.txpool, hclog.Default(), watchtowerAddr1, watchtowerKey1, 0)},
+		{malicious2, maliciousKey2, watchtower.New(sw.blockchain, sw.executor, nil, hclog.Default(), watchtowerAddr2, watchtowerKey2, 0)},
+	} {
+		bb, err := block.NewBlockBuilderFactory(sw.blockchain, sw.executor, sw.logger).FromBlockchainHead()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		bb.SetCoinbaseAddress(m.addr)
+		bb.SignWith(m.key)
+
+		blk, err := bb.Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := sw.applyAvailBlock(avail.EdgeBlock{Block: blk}, blockInclusion{availBlock: uint64(i + 1)}); err != nil {
+			t.Fatal(err)
+		}
+
+		fraudProof, err := m.wt.ConstructFraudproof(blk)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		accused = append(accused, blk)
+		fraudProofs = append(fraudProofs, fraudProof)
+	}
+
+	assert.Eventually(t, func() bool {
+		promoted, _ := sw.txpool.GetTxs(false)
+		return len(promoted[watchtowerAddr1]) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// The fraud proofs show up on Avail; the one of the first block is
+	// resolved by slashing its sequencer, while the other one is left open.
+	fraudResolver.watchtower = testFraudulentBlocks{}
+
+	assert.True(t, fraudResolver.CheckAndSetFraudBlock(3, []avail.EdgeBlock{{Block: fraudProofs[1]}}))
+	assert.True(t, fraudResolver.CheckAndSetFraudBlock(4, []avail.EdgeBlock{{Block: fraudProofs[0]}}))
+
+	// Seen again, as after a resync, the fraud proofs are recorded once.
+	fraudResolver.CheckAndSetFraudBlock(4, []avail.EdgeBlock{{Block: fraudProofs[0]}})
+
+	fraudResolver.SetChainStatus(ChainProcessingDisabled)
+
+	slashed, err := fraudResolver.CheckAndSlash()
+	if !assert.NoError(t, err) || !assert.True(t, slashed) {
+		return
+	}
+
+	head := sw.blockchain.Header()
+
+	assert.Eventually(t, func() bool {
+		page := catalog.Events(0, head.Number, &malicious1)
+		return len(page.Events) == 1 && page.Events[0].Outcome == FraudSequencerSlashed
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// The node restarts, and serves the fraud events recorded before.
+	reloaded, err := loadFraudCatalog(dir, hclog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d.frauds = reloaded
+
+	srv := rpc.NewServer()
+	if err := srv.RegisterName(avail.SettlementNamespace, NewStatusAPI(d)); err != nil {
+		t.Fatal(err)
+	}
+
+	c := rpc.DialInProc(srv)
+	defer c.Close()
+
+	fraudEvents := func(args ...interface{}) FraudEventsPage {
+		t.Helper()
+
+		var page FraudEventsPage
+		if err := c.Call(&page, "avail_getFraudEvents", args...); err != nil {
+			t.Fatal(err)
+		}
+
+		return page
+	}
+
+	page := fraudEvents(0, head.Number, malicious1)
+	if assert.Len(t, page.Events, 1) {
+		e := page.Events[0]
+		disputeTxHash, _ := block.GetExtraDataBeginDisputeResolutionTarget(fraudProofs[0].Header)
+
+		assert.Equal(t, accused[0].Hash(), e.BlockHash)
+		assert.Equal(t, accused[0].Number(), e.BlockNumber)
+		assert.Equal(t, watchtowerAddr1, e.Watchtower)
+		assert.Equal(t, fraudProofs[0].Hash(), e.FraudProofHash)
+		assert.Equal(t, uint64(4), e.AvailBlock)
+		assert.Equal(t, disputeTxHash, e.BeginDisputeTxHash)
+		assert.NotEqual(t, types.ZeroHash, e.SlashTxHash)
+		assert.NotEqual(t, types.ZeroHash, e.EndDisputeTxHash)
+		assert.Equal(t, FraudSequencerSlashed, e.Outcome)
+		assert.NotNil(t, e.ResolvedAt)
+	}
+
+	page = fraudEvents(0, head.Number, malicious2)
+	if assert.Len(t, page.Events, 1) {
+		e := page.Events[0]
+
+		assert.Equal(t, accused[1].Hash(), e.BlockHash)
+		assert.Equal(t, malicious2, e.Miner)
+		assert.Equal(t, watchtowerAddr2, e.Watchtower)
+		assert.Equal(t, FraudDisputed, e.Outcome)
+		assert.Equal(t, types.ZeroHash, e.SlashTxHash)
+		assert.Nil(t, e.ResolvedAt)
+	}
+
+	// Without a miner, both show up, by block number; the range filters them.
+	page = fraudEvents(0, head.Number)
+	if assert.Len(t, page.Events, 2) {
+		assert.Equal(t, accused[0].Hash(), page.Events[0].BlockHash)
+		assert.Equal(t, accused[1].Hash(), page.Events[1].BlockHash)
+		assert.Nil(t, page.Next)
+	}
+
+	assert.Empty(t, fraudEvents(accused[1].Number(), head.Number, malicious1).Events)
+
+	var bad FraudEventsPage
+	assert.Error(t, c.Call(&bad, "avail_getFraudEvents", 2, 1))
+}
+
+func TestFraudProofReplayAcrossRestart(t *testing.T) {
+	sw, fraudResolver, _ := newTestSequencerWorker(t, testutil.NewFake(avail_types.NewUCompactFromUInt(1)))
+
+	dir := t.TempDir()
+
+	catalog := newFraudCatalog(dir, hclog.Default())
+	fraudResolver.catalog = catalog
+	fraudResolver.watchtower = testFraudulentBlocks{}
+	sw.blockchain.RegisterPostCommitHook(catalog.observe)
+
+	sender := staking.NewTestAvailSender()
+	stake := big.NewInt(0).Mul(big.NewInt(10), common.ETH)
+
+	malicious, maliciousKey := test.NewAccount(t)
+	watchtowerAddr1, watchtowerKey1 := test.NewAccount(t)
+	watchtowerAddr2, watchtowerKey2 := test.NewAccount(t)
+
+	for _, addr := range []types.Address{malicious, watchtowerAddr1, watchtowerAddr2} {
+		test.DepositBalance(t, addr, big.NewInt(0).Mul(big.NewInt(1000), common.ETH), sw.blockchain, sw.executor)
+	}
+
+	if err := staking.Stake(sw.blockchain, sw.executor, sender, hclog.Default(), string(staking.Sequencer), sw.nodeAddr, sw.nodeSignKey, stake, 1_000_000, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := staking.Stake(sw.blockchain, sw.executor, sender, hclog.Default(), string(staking.Sequencer), malicious, maliciousKey, stake, 1_000_000, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	bb, err := block.NewBlockBuilderFactory(sw.blockchain, sw.executor, sw.logger).FromBlockchainHead()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bb.SetCoinbaseAddress(malicious)
+	bb.SignWith(maliciousKey)
+
+	accused, err := bb.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sw.applyAvailBlock(avail.EdgeBlock{Block: accused}, blockInclusion{availBlock: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	fraudProof, err := watchtower.New(sw.blockchain, sw.executor, sw.txpool, hclog.Default(), watchtowerAddr1, watchtowerKey1, 0).ConstructFraudproof(accused)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lateFraudProof, err := watchtower.New(sw.blockchain, sw.executor, nil, hclog.Default(), watchtowerAddr2, watchtowerKey2, 0).ConstructFraudproof(accused)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Eventually(t, func() bool {
+		promoted, _ := sw.txpool.GetTxs(false)
+		return len(promoted[watchtowerAddr1]) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// restart has the node come back up on the fraud catalog persisted.
+	restart := func() *Fraud {
+		t.Helper()
+
+		reloaded, err := loadFraudCatalog(dir, hclog.Default())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sw.blockchain.RegisterPostCommitHook(reloaded.observe)
+
+		return NewFraudResolver(sw.ctx, hclog.Default(), sw.blockchain, sw.executor, sw.txpool, sw.poolIndex, testFraudulentBlocks{}, nil, reloaded, nil, new(atomic.Bool), sw.nodeAddr, sw.nodeSignKey, sw.availSender, DefaultFraudTip, DefaultFraudProofQuorum, Sequencer)
+	}
+
+	assert.True(t, fraudResolver.CheckAndSetFraudBlock(2, []avail.EdgeBlock{{Block: fraudProof}}))
+
+	// Replayed after a restart while the dispute is open, the fraud proof
+	// doesn't raise it again.
+	replayed := restart()
+	assert.False(t, replayed.CheckAndSetFraudBlock(2, []avail.EdgeBlock{{Block: fraudProof}}))
+	assert.Nil(t, replayed.GetBlock())
+	assert.False(t, replayed.IsChainDisabled())
+
+	fraudResolver.SetChainStatus(ChainProcessingDisabled)
+
+	slashed, err := fraudResolver.CheckAndSlash()
+	if !assert.NoError(t, err) || !assert.True(t, slashed) {
+		return
+	}
+
+	assert.Eventually(t, func() bool {
+		return catalog.closed(accused.Hash())
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Replayed after the dispute ended, neither the fraud proof nor a late
+	// one of another watchtower raises it again.
+	head := sw.blockchain.Header()
+
+	replayed = restart()
+
+	for _, fraudBlk := range []*types.Block{fraudProof, lateFraudProof} {
+		assert.False(t, replayed.CheckAndSetFraudBlock(2, []avail.EdgeBlock{{Block: fraudBlk}}))
+		assert.Nil(t, replayed.GetBlock())
+		assert.False(t, replayed.IsChainDisabled())
+
+		slashed, err := replayed.CheckAndSlash()
+		assert.NoError(t, err)
+		assert.False(t, slashed)
+	}
+
+	assert.Equal(t, head.Hash, sw.blockchain.Header().Hash)
+
+	var slashes []types.Address
+
+	for number := uint64(1); number <= head.Number; number++ {
+		blk, ok := sw.blockchain.GetBlockByNumber(number, true)
+		if !assert.True(t, ok) {
+			continue
+		}
+
+		for _, tx := range blk.Transactions {
+			if staker, ok := staking.SlashedStaker(tx); ok {
+				slashes = append(slashes, staker)
+			}
+		}
+	}
+
+	assert.Equal(t, []types.Address{malicious}, slashes)
+	assert.Len(t, replayed.catalog.Events(0, head.Number, &malicious).Events, 1)
+}
+
+func TestFraudCatalogPages(t *testing.T) {
+	catalog := newFraudCatalog("", hclog.NewNullLogger())
+
+	miner := types.StringToAddress("1")
+
+	// Two events at every block number but the crowded one.
+	for number := uint64(1); number <= fraudEventsPageSize; number++ {
+		n := 2
+		if number == 60 {
+			n = fraudEventsPageSize + 1
+		}
+
+		for i := 0; i < n; i++ {
+			catalog.events = append(catalog.events, &FraudEvent{BlockNumber: number, Miner: miner, Outcome: FraudDisputed})
+		}
+	}
+
+	var pages [][]FraudEvent
+
+	for from := uint64(0); ; {
+		page := catalog.Events(from, fraudEventsPageSize, nil)
+		pages = append(pages, page.Events)
+
+		if page.Next == nil {
+			break
+		}
+
+		if !assert.Greater(t, *page.Next, from) {
+			return
+		}
+
+		from = *page.Next
+	}
+
+	// A page never cuts through the events of a block number; the crowded
+	// one fills a page on its own.
+	var total int
+
+	for _, events := range pages {
+		total += len(events)
+
+		last := events[len(events)-1].BlockNumber
+		if last == 60 {
+			assert.Len(t, events, fraudEventsPageSize+1)
+			continue
+		}
+
+		assert.LessOrEqual(t, len(events), fraudEventsPageSize)
+	}
+
+	assert.Equal(t, 2*(fraudEventsPageSize-1)+fraudEventsPageSize+1, total)
+	assert.Len(t, catalog.Events(0, fraudEventsPageSize, &types.ZeroAddress).Events, 0)
+}
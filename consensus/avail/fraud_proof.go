@@ -0,0 +1,149 @@
+package avail
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/consensus/avail/watchtower"
+	"github.com/availproject/op-evm/pkg/block"
+)
+
+// fraudProofVerifyTimeout bounds the re-execution of the block accused by a
+// fraud proof; the accusations not verified in time are rejected.
+const fraudProofVerifyTimeout = 10 * time.Second
+
+// ErrFraudProofRejected is returned for the fraud proofs whose accusation
+// can't be verified locally.
+var ErrFraudProofRejected = errors.New("fraud proof rejected")
+
+// verifyFraudProof confirms the accusation of the fraud proof block received
+// in the Avail block at the given height: the accused block must be within
+// its challenge window, and the violation the fraud proof claims must hold.
+// An invalid transaction signature is checked on the transaction pointed at
+// alone. Otherwise, the accused block is re-executed from its parent by the
+// check of the watchtower; the accusation stands only if the check finds the
+// block invalid. Those fraud proofs carry no evidence besides the target, so
+// the re-execution is all there is to go by.
+func (f *Fraud) verifyFraudProof(fraudBlk *types.Block, availHeight uint64) error {
+	target, ok := block.GetExtraDataFraudProofTarget(fraudBlk.Header)
+	if !ok {
+		return fmt.Errorf("%w: no fraud proof target", ErrFraudProofRejected)
+	}
+
+	violation, txIndex, ok := block.GetExtraDataFraudProofViolation(fraudBlk.Header)
+	if !ok {
+		return fmt.Errorf("%w: malformed violation of block %s", ErrFraudProofRejected, target)
+	}
+
+	if violation == block.FraudViolationExecution && f.watchtower == nil {
+		return fmt.Errorf("%w: no watchtower to check block %s", ErrFraudProofRejected, target)
+	}
+
+	accused, ok := f.blockchain.GetBlockByHash(target, true)
+	if !ok {
+		return fmt.Errorf("%w: accused block %s not found", ErrFraudProofRejected, target)
+	}
+
+	if err := f.settled.challengeable(accused.Header, availHeight); err != nil {
+		return err
+	}
+
+	if violation == block.FraudViolationTxSignature {
+		return f.verifyTxSignatureViolation(fraudBlk, accused, txIndex)
+	}
+
+	// The check runs apart, so a block slow to execute doesn't hold up the
+	// processing of the blocks past it for longer than the timeout.
+	checked := make(chan error, 1)
+	go func() { checked <- f.watchtower.Check(accused) }()
+
+	timer := time.NewTimer(f.verifyTimeout)
+	defer timer.Stop()
+
+	select {
+	case err := <-checked:
+		switch {
+		case err == nil:
+			return fmt.Errorf("%w: accused block %s is valid", ErrFraudProofRejected, target)
+		case errors.Is(err, watchtower.ErrParentBlockNotFound):
+			return fmt.Errorf("%w: accused block %s can't be re-executed: %s", ErrFraudProofRejected, target, err)
+		}
+
+		f.logger.Info("fraud proof verified", "watchtower_fraud_block_hash", fraudBlk.Hash(), "probation_block_hash", target, "violation", err)
+
+		return nil
+	case <-timer.C:
+		return fmt.Errorf("%w: check of accused block %s timed out after %s", ErrFraudProofRejected, target, f.verifyTimeout)
+	case <-f.ctx.Done():
+		return f.ctx.Err()
+	}
+}
+
+// verifyTxSignatureViolation confirms the transaction of the accused block at
+// the given index has an invalid signature.
+func (f *Fraud) verifyTxSignatureViolation(fraudBlk, accused *types.Block, txIndex uint64) error {
+	if txIndex >= uint64(len(accused.Transactions)) {
+		return fmt.Errorf("%w: transaction %d out of the %d of accused block %s", ErrFraudProofRejected, txIndex, len(accused.Transactions), accused.Hash())
+	}
+
+	tx := accused.Transactions[txIndex]
+
+	err := block.VerifyTxSignature(tx, f.blockchain.TxSigner())
+	if err == nil {
+		return fmt.Errorf("%w: signature of transaction %d (%s) of accused block %s is valid", ErrFraudProofRejected, txIndex, tx.Hash, accused.Hash())
+	}
+
+	f.logger.Info("fraud proof verified", "watchtower_fraud_block_hash", fraudBlk.Hash(), "probation_block_hash", accused.Hash(), "tx_index", txIndex, "tx_hash", tx.Hash, "violation", err)
+
+	return nil
+}
+
+// rejectFraudProof counts the rejected fraud proof, of a block of the given
+// accused miner, the zero address if unknown, against the watchtower raising it, and keeps the dispute it begins out of the chain: its begin
+// dispute resolution transaction is dropped from the txpool, and the chain
+// processing halted on it resumes.
+func (f *Fraud) rejectFraudProof(fraudBlk *types.Block, accused types.Address, reason error) {
+	watchtowerAddr := types.BytesToAddress(fraudBlk.Header.Miner)
+
+	f.rejectedLock.Lock()
+	f.rejected[watchtowerAddr]++
+	rejected := f.rejected[watchtowerAddr]
+	f.rejectedLock.Unlock()
+
+	f.metrics.fraudProofRejected(accused)
+
+	f.logger.Warn(
+		"Rejected fraud proof of watchtower",
+		"watchtower_addr", watchtowerAddr,
+		"watchtower_fraud_block_hash", fraudBlk.Hash(),
+		"rejected_fraud_proofs", rejected,
+		"reason", reason,
+	)
+
+	disputeTxHash, ok := block.GetExtraDataBeginDisputeResolutionTarget(fraudBlk.Header)
+	if !ok {
+		return
+	}
+
+	if tx, ok := f.txpool.GetPendingTx(disputeTxHash); ok {
+		f.txpool.Drop(tx)
+	}
+
+	// No verified fraud proof is being resolved; the halt is all down to the
+	// rejected one.
+	if f.IsChainDisabled() && f.fraudBlock == nil && f.lastFraudDisputedTx != nil && f.lastFraudDisputedTx.Hash == disputeTxHash {
+		f.logger.Warn("Chain is leaving fraud dispute mode entered on the rejected fraud proof...", "dispute_resolution_tx_hash", disputeTxHash)
+		f.SetChainStatus(ChainProcessingEnabled)
+	}
+}
+
+// RejectedFraudProofs returns the number of the fraud proofs of the
+// watchtower rejected for their accusation not verifying.
+func (f *Fraud) RejectedFraudProofs(watchtowerAddr types.Address) uint64 {
+	f.rejectedLock.Lock()
+	defer f.rejectedLock.Unlock()
+
+	return f.rejected[watchtowerAddr]
+}
@@ -0,0 +1,191 @@
+package avail
+
+import (
+	"encoding/binary"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/consensus/avail/watchtower"
+	"github.com/availproject/op-evm/pkg/avail"
+	"github.com/availproject/op-evm/pkg/avail/testutil"
+	"github.com/availproject/op-evm/pkg/block"
+	"github.com/availproject/op-evm/pkg/test"
+	avail_types "github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestFraudProof writes a valid block with the sequencer and has a
+// watchtower, checking the blocks for the fraud resolver, accuse it anyway.
+// It returns the accused block, the fraud proof and the watchtower address.
+func newTestFraudProof(t *testing.T, sw *SequencerWorker, fraudResolver *Fraud) (*types.Block, *types.Block, types.Address) {
+	t.Helper()
+
+	// The fraud proof is built on the parent of the accused block.
+	watchtowerAddr, watchtowerKey := test.NewAccount(t)
+	test.DepositBalance(t, watchtowerAddr, big.NewInt(0).Mul(big.NewInt(1000), big.NewInt(1e18)), sw.blockchain, sw.executor)
+
+	addTransfers(t, sw, 3)
+
+	account := accounts.Account{Address: common.Address(sw.nodeAddr)}
+	if err := sw.writeBlock(fraudResolver, account, &keystore.Key{PrivateKey: sw.nodeSignKey}); err != nil {
+		t.Fatal(err)
+	}
+
+	accused, _ := sw.blockchain.GetBlockByNumber(sw.blockchain.Header().Number, true)
+
+	wt := watchtower.New(sw.blockchain, sw.executor, sw.txpool, hclog.Default(), watchtowerAddr, watchtowerKey, 0)
+
+	fraudProof, err := wt.ConstructFraudproof(accused)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fraudResolver.watchtower = wt
+
+	return accused, fraudProof, watchtowerAddr
+}
+
+func TestFraudResolverVerifiesFraudProofs(t *testing.T) {
+	testCases := []struct {
+		name       string
+		fraudulent bool
+	}{
+		{name: "bogus fraud proof of a valid block"},
+		{name: "fraud proof of an invalid block", fraudulent: true},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			sw, fraudResolver, _ := newTestSequencerWorker(t, testutil.NewFake(avail_types.NewUCompactFromUInt(1)))
+
+			_, fraudProof, watchtowerAddr := newTestFraudProof(t, sw, fraudResolver)
+
+			if tc.fraudulent {
+				fraudResolver.watchtower = testFraudulentBlocks{}
+			}
+
+			// The begin dispute resolution transaction of the fraud proof
+			// halted the chain.
+			disputeTxHash, _ := block.GetExtraDataBeginDisputeResolutionTarget(fraudProof.Header)
+
+			disputeTx, ok := sw.txpool.GetPendingTx(disputeTxHash)
+			if !assert.True(t, ok) {
+				return
+			}
+
+			assert.Eventually(t, func() bool {
+				promoted, _ := sw.txpool.GetTxs(false)
+				return len(promoted[watchtowerAddr]) == 1
+			}, 5*time.Second, 10*time.Millisecond)
+
+			fraudResolver.SetChainStatus(ChainProcessingDisabled)
+			fraudResolver.lastFraudDisputedTx = disputeTx
+
+			set := fraudResolver.CheckAndSetFraudBlock(1, []avail.EdgeBlock{{Block: fraudProof}})
+
+			_, pending := sw.txpool.GetPendingTx(disputeTx.Hash)
+
+			if tc.fraudulent {
+				assert.True(t, set)
+				assert.Equal(t, fraudProof, fraudResolver.GetBlock())
+				assert.True(t, fraudResolver.IsChainDisabled())
+				assert.Zero(t, fraudResolver.RejectedFraudProofs(watchtowerAddr))
+				assert.True(t, pending)
+
+				return
+			}
+
+			// The accusation is rejected and counted against the watchtower.
+			assert.False(t, set)
+			assert.Nil(t, fraudResolver.GetBlock())
+			assert.Equal(t, uint64(1), fraudResolver.RejectedFraudProofs(watchtowerAddr))
+
+			// The dispute never begins.
+			assert.False(t, fraudResolver.IsChainDisabled())
+			assert.False(t, pending)
+		})
+	}
+}
+
+func TestFraudResolverVerifiesTxSignatureFraudProofs(t *testing.T) {
+	sw, fraudResolver, _ := newTestSequencerWorker(t, testutil.NewFake(avail_types.NewUCompactFromUInt(1)))
+
+	alice, bob := newTestSender(t, sw), newTestSender(t, sw)
+
+	watchtowerAddr, watchtowerKey := test.NewAccount(t)
+	test.DepositBalance(t, watchtowerAddr, big.NewInt(0).Mul(big.NewInt(1000), big.NewInt(1e18)), sw.blockchain, sw.executor)
+
+	// The malicious sequencer includes a transfer of alice signed by bob.
+	malicious := newTestSender(t, sw)
+	valid := alice.transfer(t, 1)
+	forged := (&testSender{addr: alice.addr, key: bob.key, nonce: alice.nonce}).transfer(t, 1)
+
+	bb, err := block.NewBlockBuilderFactory(sw.blockchain, sw.executor, sw.logger).FromBlockchainHead()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bb.SetCoinbaseAddress(malicious.addr)
+	bb.SignWith(malicious.key)
+	bb.AddTransactions(valid, forged)
+
+	accused, err := bb.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !assert.Len(t, accused.Transactions, 2) {
+		return
+	}
+
+	if err := sw.applyAvailBlock(avail.EdgeBlock{Block: accused}, blockInclusion{availBlock: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The watchtower trips on the signature, and points its fraud proof at
+	// the forged transaction.
+	wt := watchtower.New(sw.blockchain, sw.executor, nil, hclog.Default(), watchtowerAddr, watchtowerKey, 0)
+	assert.ErrorIs(t, wt.Check(accused), block.ErrInvalidTxSignature)
+
+	fraudProof, err := wt.ConstructFraudproof(accused)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	violation, txIndex, ok := block.GetExtraDataFraudProofViolation(fraudProof.Header)
+	assert.True(t, ok)
+	assert.Equal(t, block.FraudViolationTxSignature, violation)
+	assert.Equal(t, uint64(1), txIndex)
+
+	// withTxIndex returns the fraud proof pointing at the transaction of the
+	// given index instead.
+	withTxIndex := func(index uint64) *types.Block {
+		kv, err := block.DecodeExtraDataFields(fraudProof.Header.ExtraData)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		kv[block.KeyFraudProofTxIndex] = binary.BigEndian.AppendUint64(nil, index)
+
+		h := fraudProof.Header.Copy()
+		h.ExtraData = block.EncodeExtraDataFields(kv)
+
+		return &types.Block{Header: h.ComputeHash()}
+	}
+
+	// The signature alone is checked: there's no watchtower to re-execute
+	// the block.
+	fraudResolver.watchtower = nil
+
+	assert.NoError(t, fraudResolver.verifyFraudProof(fraudProof, 1))
+	assert.ErrorIs(t, fraudResolver.verifyFraudProof(withTxIndex(0), 1), ErrFraudProofRejected, "fabricated fraud proof of a valid transaction")
+	assert.ErrorIs(t, fraudResolver.verifyFraudProof(withTxIndex(2), 1), ErrFraudProofRejected, "transaction index out of range")
+}
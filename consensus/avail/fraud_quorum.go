@@ -0,0 +1,117 @@
+package avail
+
+import (
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/pkg/block"
+	"github.com/availproject/op-evm/pkg/staking"
+)
+
+// accusation is the block accused of fraud by the verified fraud proofs of
+// the watchtowers, short of the quorum.
+type accusation struct {
+	accused *types.Header
+
+	// proofs are the fraud proofs accusing the block, by watchtower.
+	proofs map[types.Address]*types.Block
+}
+
+// accuse counts the verified fraud proof, seen in the Avail block at the
+// given height, towards the quorum of its accused block, and reports whether
+// the quorum is reached with it. Without a quorum, a single fraud proof is
+// enough. Only the fraud proofs of distinct staked watchtowers count; the
+// begin dispute resolution transactions of the others accusing the block are
+// dropped once the quorum is reached, as the one of the fraud proof
+// completing it begins the dispute, and their fraud proofs are processed.
+// The fraud proofs short of the quorum aren't; counted again, as on a replay
+// of Avail after a restart, they accuse the block the same.
+func (f *Fraud) accuse(fraudBlk *types.Block, availHeight uint64) bool {
+	if f.quorum <= 1 {
+		return true
+	}
+
+	target, _ := block.GetExtraDataFraudProofTarget(fraudBlk.Header)
+	watchtowerAddr := types.BytesToAddress(fraudBlk.Header.Miner)
+
+	staked, err := staking.NewActiveParticipantsQuerier(f.blockchain, f.executor, f.logger).Contains(watchtowerAddr, staking.WatchTower)
+	if err != nil || !staked {
+		f.logger.Warn("fraud proof of an unstaked watchtower doesn't count towards the quorum", "watchtower_addr", watchtowerAddr, "probation_block_hash", target, "error", err)
+		return false
+	}
+
+	a, ok := f.accusations[target]
+	if !ok {
+		accused, ok := f.blockchain.GetHeaderByHash(target)
+		if !ok {
+			return false
+		}
+
+		a = &accusation{accused: accused, proofs: make(map[types.Address]*types.Block)}
+		f.accusations[target] = a
+	}
+
+	a.proofs[watchtowerAddr] = fraudBlk
+
+	if uint64(len(a.proofs)) < f.quorum {
+		f.logger.Info(
+			"fraud proof short of the quorum of watchtowers",
+			"watchtower_addr", watchtowerAddr,
+			"probation_block_hash", target,
+			"watchtowers", len(a.proofs),
+			"quorum", f.quorum,
+			"avail_block_number", availHeight,
+		)
+
+		return false
+	}
+
+	delete(f.accusations, target)
+
+	for addr, proof := range a.proofs {
+		if addr != watchtowerAddr {
+			f.dropDisputeTx(proof)
+			f.catalog.process(proof)
+		}
+	}
+
+	f.logger.Warn("quorum of watchtowers reached on the fraud of the block", "probation_block_hash", target, "watchtowers", len(a.proofs))
+
+	return true
+}
+
+// expireAccusations expires the accusations short of the quorum whose
+// accused block is past its challenge window as of the Avail block at the
+// given height. Their watchtowers aren't penalized; the begin dispute
+// resolution transactions of their fraud proofs are dropped.
+func (f *Fraud) expireAccusations(availHeight uint64) {
+	for target, a := range f.accusations {
+		if err := f.settled.challengeable(a.accused, availHeight); err == nil {
+			continue
+		}
+
+		delete(f.accusations, target)
+
+		watchtowers := make([]types.Address, 0, len(a.proofs))
+		for addr, proof := range a.proofs {
+			watchtowers = append(watchtowers, addr)
+			f.dropDisputeTx(proof)
+		}
+
+		f.catalog.expired(target)
+		observeExpiredAccusation()
+
+		f.logger.Info("accusation expired short of the quorum of watchtowers", "probation_block_hash", target, "watchtowers", watchtowers, "quorum", f.quorum)
+	}
+}
+
+// dropDisputeTx drops the begin dispute resolution transaction of the fraud
+// proof from the txpool, if it's there.
+func (f *Fraud) dropDisputeTx(fraudBlk *types.Block) {
+	disputeTxHash, ok := block.GetExtraDataBeginDisputeResolutionTarget(fraudBlk.Header)
+	if !ok {
+		return
+	}
+
+	if tx, ok := f.txpool.GetPendingTx(disputeTxHash); ok {
+		f.txpool.Drop(tx)
+	}
+}
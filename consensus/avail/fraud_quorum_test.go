@@ -0,0 +1,188 @@
+package avail
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/consensus/avail/watchtower"
+	"github.com/availproject/op-evm/pkg/avail"
+	"github.com/availproject/op-evm/pkg/avail/testutil"
+	"github.com/availproject/op-evm/pkg/block"
+	"github.com/availproject/op-evm/pkg/common"
+	"github.com/availproject/op-evm/pkg/staking"
+	"github.com/availproject/op-evm/pkg/test"
+	avail_types "github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestAccusation has a malicious sequencer write a block and the given
+// number of staked watchtowers, plus an unstaked one, raise a fraud proof of
+// it, under a quorum of two. It returns the accused block and the fraud
+// proofs, the one of the unstaked watchtower last.
+func newTestAccusation(t *testing.T, sw *SequencerWorker, fraudResolver *Fraud, watchtowers int) (*types.Block, []*types.Block) {
+	t.Helper()
+
+	fraudResolver.quorum = 2
+	fraudResolver.watchtower = testFraudulentBlocks{}
+
+	sender := staking.NewTestAvailSender()
+	stake := big.NewInt(0).Mul(big.NewInt(10), common.ETH)
+
+	if err := staking.Stake(sw.blockchain, sw.executor, sender, hclog.Default(), string(staking.Sequencer), sw.nodeAddr, sw.nodeSignKey, stake, 1_000_000, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	type account struct {
+		addr types.Address
+		key  *ecdsa.PrivateKey
+	}
+
+	newAccount := func() account {
+		addr, key := test.NewAccount(t)
+		test.DepositBalance(t, addr, big.NewInt(0).Mul(big.NewInt(1000), common.ETH), sw.blockchain, sw.executor)
+
+		return account{addr, key}
+	}
+
+	var staked []account
+
+	for i := 0; i < watchtowers; i++ {
+		wt := newAccount()
+		if err := staking.Stake(sw.blockchain, sw.executor, sender, hclog.Default(), string(staking.WatchTower), wt.addr, wt.key, stake, 1_000_000, "test"); err != nil {
+			t.Fatal(err)
+		}
+
+		staked = append(staked, wt)
+	}
+
+	malicious, unstaked := newAccount(), newAccount()
+
+	bb, err := block.NewBlockBuilderFactory(sw.blockchain, sw.executor, sw.logger).FromBlockchainHead()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bb.SetCoinbaseAddress(malicious.addr)
+	bb.SignWith(malicious.key)
+
+	accused, err := bb.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sw.applyAvailBlock(avail.EdgeBlock{Block: accused}, blockInclusion{availBlock: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	var fraudProofs []*types.Block
+
+	for _, wt := range append(staked, unstaked) {
+		fraudProof, err := watchtower.New(sw.blockchain, sw.executor, sw.txpool, hclog.Default(), wt.addr, wt.key, 0).ConstructFraudproof(accused)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		fraudProofs = append(fraudProofs, fraudProof)
+	}
+
+	// The begin dispute resolution transactions of the staked watchtowers
+	// make it to the txpool.
+	assert.Eventually(t, func() bool {
+		promoted, _ := sw.txpool.GetTxs(false)
+
+		for _, wt := range staked {
+			if len(promoted[wt.addr]) != 1 {
+				return false
+			}
+		}
+
+		return true
+	}, 5*time.Second, 10*time.Millisecond)
+
+	return accused, fraudProofs
+}
+
+func TestFraudProofQuorum(t *testing.T) {
+	sw, fraudResolver, _ := newTestSequencerWorker(t, testutil.NewFake(avail_types.NewUCompactFromUInt(1)))
+
+	accused, fraudProofs := newTestAccusation(t, sw, fraudResolver, 2)
+	unstaked := fraudProofs[2]
+
+	// A single accusation does nothing, however many times it's seen, and
+	// neither does the one of an unstaked watchtower.
+	for _, fraudProof := range []*types.Block{fraudProofs[0], fraudProofs[0], unstaked} {
+		assert.False(t, fraudResolver.CheckAndSetFraudBlock(2, []avail.EdgeBlock{{Block: fraudProof}}))
+		assert.Nil(t, fraudResolver.GetBlock())
+		assert.False(t, fraudResolver.IsChainDisabled())
+	}
+
+	// The accusation of a second watchtower reaches the quorum, and the
+	// dispute resolution follows.
+	assert.True(t, fraudResolver.CheckAndSetFraudBlock(3, []avail.EdgeBlock{{Block: fraudProofs[1]}}))
+	assert.Equal(t, fraudProofs[1], fraudResolver.GetBlock())
+	assert.True(t, fraudResolver.IsChainDisabled())
+	assert.Empty(t, fraudResolver.accusations)
+
+	// The first watchtower's transaction beginning the dispute is dropped,
+	// the second one's begins it.
+	disputeTxHash0, _ := block.GetExtraDataBeginDisputeResolutionTarget(fraudProofs[0].Header)
+	_, pending := sw.txpool.GetPendingTx(disputeTxHash0)
+	assert.False(t, pending)
+
+	slashed, err := fraudResolver.CheckAndSlash()
+	if !assert.NoError(t, err) || !assert.True(t, slashed) {
+		return
+	}
+
+	dispute, ok := sw.blockchain.GetBlockByNumber(accused.Number(), true)
+	if assert.True(t, ok) {
+		disputeTxHash1, _ := block.GetExtraDataBeginDisputeResolutionTarget(fraudProofs[1].Header)
+
+		assert.NotEqual(t, accused.Hash(), dispute.Hash())
+		assert.Equal(t, accused.ParentHash(), dispute.ParentHash())
+
+		if assert.Len(t, dispute.Transactions, 1) {
+			assert.Equal(t, disputeTxHash1, dispute.Transactions[0].Hash)
+		}
+	}
+
+	for _, fraudProof := range fraudProofs {
+		assert.Zero(t, fraudResolver.RejectedFraudProofs(types.BytesToAddress(fraudProof.Header.Miner)))
+	}
+}
+
+func TestFraudProofQuorumExpires(t *testing.T) {
+	const (
+		window   = 3
+		included = 10
+	)
+
+	sw, fraudResolver, _ := newTestSequencerWorker(t, testutil.NewFake(avail_types.NewUCompactFromUInt(1)))
+
+	accused, fraudProofs := newTestAccusation(t, sw, fraudResolver, 1)
+
+	fraudResolver.settled = newSettledHead(sw.blockchain, NewChallengeWindow(window), hclog.Default())
+	fraudResolver.settled.include(accused.Header, included)
+
+	assert.False(t, fraudResolver.CheckAndSetFraudBlock(included+1, []avail.EdgeBlock{{Block: fraudProofs[0]}}))
+	assert.Len(t, fraudResolver.accusations, 1)
+
+	// The challenge window of the block runs out short of the quorum; the
+	// accusation expires, without a penalty for its watchtower.
+	assert.False(t, fraudResolver.CheckAndSetFraudBlock(included+window-1, nil))
+	assert.Len(t, fraudResolver.accusations, 1)
+
+	assert.False(t, fraudResolver.CheckAndSetFraudBlock(included+window, nil))
+	assert.Empty(t, fraudResolver.accusations)
+	assert.Nil(t, fraudResolver.GetBlock())
+	assert.False(t, fraudResolver.IsChainDisabled())
+	assert.Zero(t, fraudResolver.RejectedFraudProofs(types.BytesToAddress(fraudProofs[0].Header.Miner)))
+
+	disputeTxHash, _ := block.GetExtraDataBeginDisputeResolutionTarget(fraudProofs[0].Header)
+	_, pending := sw.txpool.GetPendingTx(disputeTxHash)
+	assert.False(t, pending)
+}
@@ -0,0 +1,79 @@
+package avail
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/pkg/blockchain"
+	"github.com/hashicorp/go-hclog"
+)
+
+// rollBackFraudulentBlocks rolls the chain back to the honest tip, the parent
+// of the block proven fraudulent, for the dispute resolution block to extend.
+// The fraudulent block and its descendants lose their canonical heights and
+// their state is left behind; the transactions in them are for the caller to
+// put back into the txpool. The challenge window bounds how deep the fraud
+// may be, so the depth isn't capped by the reorg limit of the fork choice.
+// The blocks rolled back are published to the dispute feed, if any, and
+// recorded to the fraud metrics. It returns the number of the blocks rolled
+// back.
+func rollBackFraudulentBlocks(bc *blockchain.Blockchain, honestTip types.Hash, source string, feed *disputeFeed, m *fraudMetrics, logger hclog.Logger) (uint64, error) {
+	head := bc.Header()
+	if head.Hash == honestTip {
+		return 0, nil
+	}
+
+	tip, ok := bc.GetHeaderByHash(honestTip)
+	if !ok {
+		return 0, fmt.Errorf("honest tip %s of the fraudulent blocks not found", honestTip)
+	}
+
+	// The blocks on top of the honest tip, the fraudulent one last.
+	var reorgedOut []*types.Header
+
+	for h, ok := head, true; ok && h.Number > tip.Number; h, ok = bc.GetHeaderByHash(h.ParentHash) {
+		reorgedOut = append(reorgedOut, h)
+	}
+
+	depth, err := bc.Reorg(tip, math.MaxUint64, source)
+	if err != nil {
+		return depth, err
+	}
+
+	var accused types.Address
+	if len(reorgedOut) > 0 {
+		accused = types.BytesToAddress(reorgedOut[len(reorgedOut)-1].Miner)
+	}
+
+	m.reorg(accused, depth)
+
+	if len(reorgedOut) > 0 {
+		fraudulent := reorgedOut[len(reorgedOut)-1]
+
+		e := DisputeEvent{
+			Type:             BlocksReorgedOut,
+			Sequencer:        types.BytesToAddress(fraudulent.Miner),
+			AccusedBlockHash: fraudulent.Hash,
+			BlockNumber:      tip.Number,
+			BlockHash:        tip.Hash,
+		}
+
+		for _, h := range reorgedOut {
+			e.ReorgedOut = append(e.ReorgedOut, h.Hash)
+		}
+
+		feed.publish(e)
+	}
+
+	logger.Warn(
+		"rolled the blocks proven fraudulent out of the chain",
+		"depth", depth,
+		"old_head", head.Hash,
+		"old_head_number", head.Number,
+		"honest_tip", tip.Hash,
+		"honest_tip_number", tip.Number,
+	)
+
+	return depth, nil
+}
@@ -0,0 +1,175 @@
+package avail
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/consensus/avail/watchtower"
+	"github.com/availproject/op-evm/pkg/avail"
+	"github.com/availproject/op-evm/pkg/avail/testutil"
+	"github.com/availproject/op-evm/pkg/block"
+	"github.com/availproject/op-evm/pkg/test"
+	avail_types "github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDisputeResolutionRollsBackFraudulentBlocks(t *testing.T) {
+	sw, fraudResolver, _ := newTestSequencerWorker(t, testutil.NewFake(avail_types.NewUCompactFromUInt(1)))
+
+	alice, bob, malicious := newTestSender(t, sw), newTestSender(t, sw), newTestSender(t, sw)
+
+	watchtowerAddr, watchtowerKey := test.NewAccount(t)
+	test.DepositBalance(t, watchtowerAddr, big.NewInt(0).Mul(big.NewInt(1000), big.NewInt(1e18)), sw.blockchain, sw.executor)
+
+	honestTip := sw.blockchain.Header()
+
+	// The malicious sequencer includes a transfer of alice in its block.
+	a0, m0 := alice.transfer(t, 1), malicious.transfer(t, 1)
+
+	bb, err := block.NewBlockBuilderFactory(sw.blockchain, sw.executor, sw.logger).FromBlockchainHead()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bb.SetCoinbaseAddress(malicious.addr)
+	bb.SignWith(malicious.key)
+	bb.AddTransactions(a0, m0)
+
+	fraudulent, err := bb.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sw.applyAvailBlock(avail.EdgeBlock{Block: fraudulent}, blockInclusion{availBlock: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Three honest blocks, with a transfer of bob each, build on top of it.
+	account := accounts.Account{Address: common.Address(sw.nodeAddr)}
+	key := &keystore.Key{PrivateKey: sw.nodeSignKey}
+
+	var bobTxs []*types.Transaction
+
+	descendants := make([]*types.Header, 0, 3)
+
+	for i := 0; i < 3; i++ {
+		tx := bob.transfer(t, 1)
+		if err := sw.txpool.AddTx(tx); err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Eventually(t, func() bool { return sw.txpool.Length() == 1 }, 5*time.Second, 10*time.Millisecond)
+
+		if err := sw.writeBlock(fraudResolver, account, key); err != nil {
+			t.Fatal(err)
+		}
+
+		bobTxs = append(bobTxs, tx)
+		descendants = append(descendants, sw.blockchain.Header())
+	}
+
+	assert.Equal(t, fraudulent.Number()+3, sw.blockchain.Header().Number)
+
+	// The watchtower proves the block fraudulent, and the dispute resolves
+	// against the malicious sequencer.
+	wt := watchtower.New(sw.blockchain, sw.executor, sw.txpool, hclog.Default(), watchtowerAddr, watchtowerKey, 0)
+
+	fraudProof, err := wt.ConstructFraudproof(fraudulent)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Eventually(t, func() bool {
+		promoted, _ := sw.txpool.GetTxs(false)
+		return len(promoted[watchtowerAddr]) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	fraudResolver.watchtower = testFraudulentBlocks{}
+	fraudResolver.SetBlock(fraudProof)
+	fraudResolver.SetChainStatus(ChainProcessingDisabled)
+
+	slashed, err := fraudResolver.CheckAndSlash()
+	if !assert.NoError(t, err) || !assert.True(t, slashed) {
+		return
+	}
+
+	// The chain goes on from the honest tip: the dispute resolution block
+	// takes the height of the fraudulent one, followed by the slash block.
+	dispute, ok := sw.blockchain.GetHeaderByNumber(fraudulent.Number())
+	if !assert.True(t, ok) {
+		return
+	}
+
+	assert.Equal(t, honestTip.Hash, dispute.ParentHash)
+	assert.NotEqual(t, fraudulent.Hash(), dispute.Hash)
+
+	head := sw.blockchain.Header()
+	assert.Equal(t, dispute.Hash, head.ParentHash)
+	assert.False(t, fraudResolver.IsChainDisabled())
+
+	for _, h := range descendants {
+		if canonical, ok := sw.blockchain.GetHeaderByNumber(h.Number); ok {
+			assert.NotEqual(t, h.Hash, canonical.Hash, "discarded block %d still canonical", h.Number)
+		}
+	}
+
+	// None of the transactions of the discarded blocks is in the state of
+	// the head.
+	txn, err := sw.executor.BeginTxn(head.StateRoot, head, types.ZeroAddress)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Zero(t, txn.GetNonce(alice.addr))
+	assert.Zero(t, txn.GetNonce(bob.addr))
+
+	// The transactions of the honest users are back in the txpool, and make
+	// it into the next block.
+	assert.Eventually(t, func() bool {
+		pooled := pooledTxs(sw)
+		return pooled[a0.Hash] && pooled[bobTxs[0].Hash] && pooled[bobTxs[1].Hash] && pooled[bobTxs[2].Hash]
+	}, 5*time.Second, 10*time.Millisecond)
+
+	assert.False(t, pooledTxs(sw)[m0.Hash], "transaction of the malicious sequencer put back")
+
+	if err := sw.writeBlock(fraudResolver, account, key); err != nil {
+		t.Fatal(err)
+	}
+
+	head = sw.blockchain.Header()
+
+	blk, _ := sw.blockchain.GetBlockByHash(head.Hash, true)
+
+	included := make(map[types.Hash]bool, len(blk.Transactions))
+	for _, tx := range blk.Transactions {
+		included[tx.Hash] = true
+	}
+
+	assert.Len(t, included, 4)
+	assert.True(t, included[a0.Hash])
+
+	for _, tx := range bobTxs {
+		assert.True(t, included[tx.Hash])
+	}
+
+	receipts, err := sw.blockchain.GetReceiptsByHash(head.Hash)
+	if assert.NoError(t, err) && assert.Len(t, receipts, 4) {
+		for _, r := range receipts {
+			assert.Equal(t, types.ReceiptSuccess, *r.Status)
+		}
+	}
+
+	txn, err = sw.executor.BeginTxn(head.StateRoot, head, types.ZeroAddress)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, uint64(1), txn.GetNonce(alice.addr))
+	assert.Equal(t, uint64(3), txn.GetNonce(bob.addr))
+}
@@ -1,8 +1,11 @@
 package avail
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"sync"
+	"time"
 )
 
 // FraudServer is a server for managing and performing fraud detection operations.
@@ -44,11 +47,28 @@ func (fs *FraudServer) PrimeFraud() {
 
 // ListenAndServe starts the FraudServer and listens for incoming HTTP requests on the specified address.
 // It sets up a HTTP handler at "/fraud/prime" to prime the fraud detection operation for the next invocation.
-func (fs *FraudServer) ListenAndServe(addr string) error {
+// The server is shut down, freeing the address, once the context is canceled.
+func (fs *FraudServer) ListenAndServe(ctx context.Context, addr string) error {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/fraud/prime", func(w http.ResponseWriter, _ *http.Request) {
 		fs.PrimeFraud()
 		w.WriteHeader(http.StatusAccepted)
 	})
-	return http.ListenAndServe(addr, mux)
+
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 60 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		_ = srv.Close()
+	}()
+
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
 }
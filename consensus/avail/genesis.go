@@ -0,0 +1,467 @@
+package avail
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/0xPolygon/polygon-edge/chain"
+	"github.com/0xPolygon/polygon-edge/crypto"
+	"github.com/0xPolygon/polygon-edge/helper/hex"
+	"github.com/0xPolygon/polygon-edge/state"
+	itrie "github.com/0xPolygon/polygon-edge/state/immutable-trie"
+	"github.com/0xPolygon/polygon-edge/types"
+	staking_contract "github.com/availproject/op-evm-contracts/staking/pkg/staking"
+	"github.com/availproject/op-evm/pkg/staking"
+	"github.com/hashicorp/go-hclog"
+	"github.com/umbracle/ethgo/abi"
+)
+
+const (
+	// genesisStakingMinParticipants and genesisStakingMaxParticipants are
+	// the participant limits the staking predeploy is constructed with.
+	genesisStakingMinParticipants = 1
+	genesisStakingMaxParticipants = 10
+
+	// genesisTxGasLimit is the gas limit of the transactions run to generate
+	// the genesis state.
+	genesisTxGasLimit = 20_000_000
+)
+
+var (
+	errGenesisNoParticipants = errors.New("genesis config has no participants")
+	errGenesisNoSequencer    = errors.New("genesis config has no sequencer participant")
+)
+
+// GenesisConfig is the configuration the genesis of a chain is generated
+// from. It's kept in the "genesis" key of the engine configuration of the
+// generated chain, so every node can regenerate the genesis on the start and
+// catch the local one drifting from it.
+type GenesisConfig struct {
+	ChainID       int64                `json:"chainID"`
+	BlockGasLimit uint64               `json:"blockGasLimit"`
+	Premine       []GenesisPremine     `json:"premine"`
+	Participants  []GenesisParticipant `json:"participants"`
+	Predeploys    []GenesisPredeploy   `json:"predeploys,omitempty"`
+}
+
+// GenesisPremine is an account funded in the genesis. The balance is a
+// decimal or 0x prefixed hex number of wei.
+type GenesisPremine struct {
+	Address types.Address `json:"address"`
+	Balance string        `json:"balance"`
+}
+
+// GenesisParticipant is an account staked in the genesis, paying the stake
+// out of its premine.
+type GenesisParticipant struct {
+	Address  types.Address    `json:"address"`
+	NodeType staking.NodeType `json:"nodeType"`
+}
+
+// GenesisPredeploy is a contract deployed in the genesis at the given address,
+// such as a multicall or a create2 deployer. Its deployment bytecode is run
+// with the constructor arguments, ABI encoded, at the generation of the
+// genesis, and the code and the storage it leaves are placed at the address;
+// the constructor runs at the address of a contract created by the zero
+// address, so the one keeping its own address in its storage doesn't fit.
+// The bytecode and the arguments are 0x prefixed hex. The premine of the
+// address, if any, is its balance.
+type GenesisPredeploy struct {
+	Address  types.Address `json:"address"`
+	Bytecode string        `json:"bytecode"`
+	Args     string        `json:"args,omitempty"`
+}
+
+// decode returns the deployment bytecode of the predeploy and its constructor
+// arguments.
+func (p *GenesisPredeploy) decode() ([]byte, []byte, error) {
+	code, err := hex.DecodeHex(p.Bytecode)
+	if err != nil || len(code) == 0 {
+		return nil, nil, fmt.Errorf("genesis predeploy at %s has invalid bytecode", p.Address)
+	}
+
+	if p.Args == "" {
+		return code, nil, nil
+	}
+
+	args, err := hex.DecodeHex(p.Args)
+	if err != nil {
+		return nil, nil, fmt.Errorf("genesis predeploy at %s has invalid constructor arguments: %w", p.Address, err)
+	}
+
+	return code, args, nil
+}
+
+// Validate checks that the genesis config is complete and that the premine of
+// every participant covers its stake.
+func (c *GenesisConfig) Validate() error {
+	if c.ChainID <= 0 {
+		return fmt.Errorf("genesis config has invalid chain ID %d", c.ChainID)
+	}
+
+	if c.BlockGasLimit == 0 {
+		return errors.New("genesis config has no block gas limit")
+	}
+
+	premine, err := c.premine()
+	if err != nil {
+		return err
+	}
+
+	if len(c.Participants) == 0 {
+		return errGenesisNoParticipants
+	}
+
+	stake := genesisStake()
+	participants := make(map[types.Address]struct{}, len(c.Participants))
+	hasSequencer := false
+
+	for _, p := range c.Participants {
+		if _, ok := participants[p.Address]; ok {
+			return fmt.Errorf("genesis participant %s is listed more than once", p.Address)
+		}
+
+		participants[p.Address] = struct{}{}
+
+		switch p.NodeType {
+		case staking.Sequencer:
+			hasSequencer = true
+		case staking.WatchTower:
+		default:
+			return fmt.Errorf("genesis participant %s has unknown node type %q", p.Address, p.NodeType)
+		}
+
+		balance, ok := premine[p.Address]
+		if !ok || balance.Cmp(stake) < 0 {
+			return fmt.Errorf("genesis participant %s premine doesn't cover its stake of %s wei", p.Address, stake)
+		}
+	}
+
+	if !hasSequencer {
+		return errGenesisNoSequencer
+	}
+
+	predeploys := make(map[types.Address]struct{}, len(c.Predeploys))
+
+	for _, p := range c.Predeploys {
+		switch _, ok := predeploys[p.Address]; {
+		case ok:
+			return fmt.Errorf("genesis predeploy at %s is listed more than once", p.Address)
+		case p.Address == types.ZeroAddress, p.Address == staking.AddrStakingContract:
+			return fmt.Errorf("genesis predeploy at reserved address %s", p.Address)
+		}
+
+		predeploys[p.Address] = struct{}{}
+
+		if _, _, err := p.decode(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// premine returns the parsed balances of the premined accounts.
+func (c *GenesisConfig) premine() (map[types.Address]*big.Int, error) {
+	premine := make(map[types.Address]*big.Int, len(c.Premine))
+
+	for _, p := range c.Premine {
+		if _, ok := premine[p.Address]; ok {
+			return nil, fmt.Errorf("genesis premine of %s is listed more than once", p.Address)
+		}
+
+		balance, err := types.ParseUint256orHex(&p.Balance)
+		if err != nil {
+			return nil, fmt.Errorf("genesis premine of %s has invalid balance %q: %w", p.Address, p.Balance, err)
+		}
+
+		premine[p.Address] = balance
+	}
+
+	return premine, nil
+}
+
+// BuildGenesis generates the chain spec of the genesis config: the premined
+// accounts, the staking contract and the configured contracts predeployed,
+// and the participants staked, on the chain parameters of the
+// configs/genesis.json network. A predeploy failing its constructor fails the
+// generation, with the revert reason.
+func BuildGenesis(cfg GenesisConfig) (*chain.Chain, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	params := &chain.Params{
+		Forks:   chain.AllForksEnabled,
+		ChainID: cfg.ChainID,
+		Engine: map[string]interface{}{
+			"avail": map[string]interface{}{
+				"mechanisms": []string{BootstrapSequencer.String(), Sequencer.String(), WatchTower.String()},
+				"blockTime":  DefaultBlockTime.String(),
+				"genesis":    cfg,
+			},
+		},
+		BurnContract: map[uint64]string{
+			0: types.ZeroAddress.String(),
+		},
+	}
+
+	premine, err := cfg.premine()
+	if err != nil {
+		return nil, err
+	}
+
+	alloc := make(map[types.Address]*chain.GenesisAccount, len(premine)+1)
+	for addr, balance := range premine {
+		alloc[addr] = &chain.GenesisAccount{Balance: balance}
+	}
+
+	predeploy, err := stakingPredeploy(params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate staking predeploy: %w", err)
+	}
+
+	alloc[staking.AddrStakingContract] = predeploy
+
+	for _, p := range cfg.Predeploys {
+		code, args, err := p.decode()
+		if err != nil {
+			return nil, err
+		}
+
+		deployed, err := PredeployContract(params, code, args)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate predeploy at %s: %w", p.Address, err)
+		}
+
+		if premined, ok := alloc[p.Address]; ok {
+			deployed.Balance = premined.Balance
+		}
+
+		alloc[p.Address] = deployed
+	}
+
+	// The participants stake with real stake transactions, so the contract
+	// state is the one of a staked participant.
+	txs := make([]*types.Transaction, 0, len(cfg.Participants))
+
+	for _, p := range cfg.Participants {
+		tx, err := staking.StakeTx(p.Address, genesisStake(), string(p.NodeType), genesisTxGasLimit)
+		if err != nil {
+			return nil, err
+		}
+
+		// The genesis has no fee recipient to pay.
+		tx.GasPrice = big.NewInt(0)
+		txs = append(txs, tx)
+	}
+
+	if err := applyGenesisTxs(params, alloc, txs); err != nil {
+		return nil, fmt.Errorf("failed to stake genesis participants: %w", err)
+	}
+
+	return &chain.Chain{
+		Name: "op-evm",
+		Genesis: &chain.Genesis{
+			GasLimit:   cfg.BlockGasLimit,
+			Difficulty: 1,
+			Alloc:      alloc,
+		},
+		Params: params,
+	}, nil
+}
+
+// GenesisHash returns the hash of the genesis block of the chain.
+func GenesisHash(c *chain.Chain) (types.Hash, error) {
+	root, err := newGenesisExecutor(c.Params).WriteGenesis(c.Genesis.Alloc, types.ZeroHash)
+	if err != nil {
+		return types.ZeroHash, err
+	}
+
+	genesis := *c.Genesis
+	genesis.StateRoot = root
+
+	return genesis.GenesisHeader().ComputeHash().Hash, nil
+}
+
+// verifyGenesis regenerates the genesis from the genesis config in the engine
+// configuration, and checks that the local chain matches it.
+func verifyGenesis(raw interface{}, local *chain.Chain) error {
+	bs, err := json.Marshal(raw)
+	if err != nil {
+		return fmt.Errorf("genesis expected genesis config: %w", err)
+	}
+
+	var cfg GenesisConfig
+	if err := json.Unmarshal(bs, &cfg); err != nil {
+		return fmt.Errorf("genesis expected genesis config: %w", err)
+	}
+
+	expected, err := BuildGenesis(cfg)
+	if err != nil {
+		return err
+	}
+
+	if local.Params.ChainID != expected.Params.ChainID {
+		return fmt.Errorf("local chain ID %d doesn't match the genesis config chain ID %d", local.Params.ChainID, expected.Params.ChainID)
+	}
+
+	expectedHash, err := GenesisHash(expected)
+	if err != nil {
+		return err
+	}
+
+	localHash, err := GenesisHash(local)
+	if err != nil {
+		return err
+	}
+
+	if localHash != expectedHash {
+		return fmt.Errorf("local genesis %s doesn't match the genesis %s generated from the genesis config", localHash, expectedHash)
+	}
+
+	return nil
+}
+
+// genesisStake is the stake of the genesis participants, the one of the stake
+// transactions.
+func genesisStake() *big.Int {
+	tx, err := staking.StakeTx(types.ZeroAddress, nil, string(staking.Sequencer), genesisTxGasLimit)
+	if err != nil {
+		panic(err)
+	}
+
+	return tx.Value
+}
+
+// stakingPredeploy deploys the staking contract and returns its account, to
+// be relocated to the staking contract address.
+func stakingPredeploy(params *chain.Params) (*chain.GenesisAccount, error) {
+	code, err := hex.DecodeHex(staking_contract.StakingBin)
+	if err != nil {
+		return nil, err
+	}
+
+	args, err := abi.MustNewABI(staking_contract.StakingABI).Constructor.Inputs.Encode([]interface{}{
+		big.NewInt(genesisStakingMinParticipants),
+		big.NewInt(genesisStakingMaxParticipants),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return PredeployContract(params, code, args)
+}
+
+// PredeployContract runs the deployment bytecode of a contract with the given
+// ABI encoded constructor arguments in an ephemeral EVM on the chain
+// parameters, and returns the account of the contract deployed, its code and
+// its storage, to be placed in the genesis alloc at the address of choice.
+// The constructor reverting is an error with the revert reason.
+func PredeployContract(params *chain.Params, code, args []byte) (*chain.GenesisAccount, error) {
+	deployer := types.ZeroAddress
+	alloc := map[types.Address]*chain.GenesisAccount{}
+
+	err := applyGenesisTxs(params, alloc, []*types.Transaction{{
+		From:     deployer,
+		Input:    append(append([]byte{}, code...), args...),
+		Gas:      genesisTxGasLimit,
+		GasPrice: big.NewInt(0),
+		Value:    big.NewInt(0),
+	}})
+	if err != nil {
+		return nil, err
+	}
+
+	deployed, ok := alloc[crypto.CreateAddress(deployer, 0)]
+	if !ok || len(deployed.Code) == 0 {
+		return nil, errors.New("contract not deployed")
+	}
+
+	deployed.Balance = big.NewInt(0)
+	deployed.Nonce = 0
+
+	return deployed, nil
+}
+
+// applyGenesisTxs applies the transactions on top of the alloc, and updates
+// the alloc with the accounts they modified.
+func applyGenesisTxs(params *chain.Params, alloc map[types.Address]*chain.GenesisAccount, txs []*types.Transaction) error {
+	executor := newGenesisExecutor(params)
+
+	root, err := executor.WriteGenesis(alloc, types.ZeroHash)
+	if err != nil {
+		return err
+	}
+
+	header := &types.Header{GasLimit: genesisTxGasLimit * uint64(len(txs))}
+
+	transition, err := executor.BeginTxn(root, header, types.ZeroAddress)
+	if err != nil {
+		return err
+	}
+
+	for _, tx := range txs {
+		res, err := transition.Apply(tx)
+		if err != nil {
+			return fmt.Errorf("transaction of %s: %w", tx.From, err)
+		}
+
+		if res.Reverted() {
+			if reason, err := abi.UnpackRevertError(res.ReturnValue); err == nil {
+				return fmt.Errorf("transaction of %s failed: %w: %s", tx.From, res.Err, reason)
+			}
+		}
+
+		if res.Failed() {
+			return fmt.Errorf("transaction of %s failed: %w", tx.From, res.Err)
+		}
+	}
+
+	for _, obj := range transition.Txn().Commit(true) {
+		if obj.Deleted {
+			delete(alloc, obj.Address)
+			continue
+		}
+
+		account, ok := alloc[obj.Address]
+		if !ok {
+			account = &chain.GenesisAccount{}
+			alloc[obj.Address] = account
+		}
+
+		account.Balance = obj.Balance
+		account.Nonce = obj.Nonce
+
+		if obj.DirtyCode {
+			account.Code = obj.Code
+		}
+
+		for _, entry := range obj.Storage {
+			if account.Storage == nil {
+				account.Storage = map[types.Hash]types.Hash{}
+			}
+
+			key := types.BytesToHash(entry.Key)
+			if entry.Deleted {
+				delete(account.Storage, key)
+			} else {
+				account.Storage[key] = types.BytesToHash(entry.Val)
+			}
+		}
+	}
+
+	return nil
+}
+
+// newGenesisExecutor returns an executor on an in-memory state.
+func newGenesisExecutor(params *chain.Params) *state.Executor {
+	executor := state.NewExecutor(params, itrie.NewState(itrie.NewMemoryStorage()), hclog.NewNullLogger())
+	executor.GetHash = func(*types.Header) state.GetHashByNumber {
+		return func(uint64) types.Hash { return types.ZeroHash }
+	}
+
+	return executor
+}
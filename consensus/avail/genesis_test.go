@@ -0,0 +1,292 @@
+package avail
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/0xPolygon/polygon-edge/chain"
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/pkg/avail/testutil"
+	"github.com/availproject/op-evm/pkg/staking"
+	"github.com/availproject/op-evm/pkg/test"
+	avail_types "github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+// testPredeployBin is the deployment bytecode of a contract keeping its
+// constructor argument, a uint256, in its first slot and returning it on any
+// call; the constructor reverts with "zero value" on the zero argument.
+const testPredeployBin = "0x60208038036000396000518015601e57600055600b80602b6000396000f35b6064603660003960646000fd" +
+	"60005460005260206000f3" +
+	"08c379a0000000000000000000000000000000000000000000000000000000000000002000000000000000000000000000000000000000000000000000000000000000" +
+	"0a7a65726f2076616c756500000000000000000000000000000000000000000000"
+
+func TestBuildGenesis(t *testing.T) {
+	seq1, _ := test.NewAccount(t)
+	seq2, _ := test.NewAccount(t)
+	watchtower, _ := test.NewAccount(t)
+	user, _ := test.NewAccount(t)
+
+	cfg := GenesisConfig{
+		ChainID:       100,
+		BlockGasLimit: 0x500000,
+		Premine: []GenesisPremine{
+			{Address: seq1, Balance: "1000000000000000000000"},
+			{Address: seq2, Balance: "0x3635c9adc5dea00000"},
+			{Address: watchtower, Balance: "1000000000000000000000"},
+			{Address: user, Balance: "5"},
+		},
+		Participants: []GenesisParticipant{
+			{Address: seq1, NodeType: staking.Sequencer},
+			{Address: seq2, NodeType: staking.Sequencer},
+			{Address: watchtower, NodeType: staking.WatchTower},
+		},
+	}
+
+	generated, err := BuildGenesis(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The nodes boot from the generated genesis written out as JSON.
+	bs, err := json.Marshal(generated)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	local := new(chain.Chain)
+	if err := json.Unmarshal(bs, local); err != nil {
+		t.Fatal(err)
+	}
+
+	engine := local.Params.Engine["avail"].(map[string]interface{})
+	assert.NoError(t, verifyGenesis(engine["genesis"], local))
+
+	executor, blockchain, _, err := test.NewBlockchainWithTxPool(local, staking.NewVerifier(new(staking.DumbActiveParticipants), hclog.Default()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	asq := staking.NewActiveParticipantsQuerier(blockchain, executor, hclog.Default())
+
+	sequencers, err := asq.Get(staking.Sequencer)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []types.Address{seq1, seq2}, sequencers)
+
+	watchtowers, err := asq.Get(staking.WatchTower)
+	assert.NoError(t, err)
+	assert.Equal(t, []types.Address{watchtower}, watchtowers)
+
+	// The stakes are paid out of the premine.
+	stake := genesisStake()
+	premine := new(big.Int).Mul(big.NewInt(1000), big.NewInt(AVL))
+	assert.Equal(t, new(big.Int).Sub(premine, stake), local.Genesis.Alloc[seq1].Balance)
+	assert.Equal(t, new(big.Int).Mul(stake, big.NewInt(3)), local.Genesis.Alloc[staking.AddrStakingContract].Balance)
+	assert.Equal(t, big.NewInt(5), local.Genesis.Alloc[user].Balance)
+	assert.Equal(t, uint64(0x500000), blockchain.Header().GasLimit)
+
+	// A local genesis edited after the fact drifts from the genesis config.
+	local.Genesis.Alloc[user].Balance = big.NewInt(6)
+	assert.ErrorContains(t, verifyGenesis(engine["genesis"], local), "doesn't match the genesis")
+
+	local.Genesis.Alloc[user].Balance = big.NewInt(5)
+	local.Params.ChainID = 101
+	assert.ErrorContains(t, verifyGenesis(engine["genesis"], local), "chain ID")
+}
+
+func TestGenesisConfigValidate(t *testing.T) {
+	seq, _ := test.NewAccount(t)
+	watchtower, _ := test.NewAccount(t)
+
+	funded := []GenesisPremine{
+		{Address: seq, Balance: "1000000000000000000000"},
+		{Address: watchtower, Balance: "1000000000000000000000"},
+	}
+
+	tests := []struct {
+		name         string
+		premine      []GenesisPremine
+		participants []GenesisParticipant
+		predeploys   []GenesisPredeploy
+		err          string
+	}{
+		{
+			name:         "valid",
+			premine:      funded,
+			participants: []GenesisParticipant{{Address: seq, NodeType: staking.Sequencer}},
+		},
+		{
+			name:    "no participants",
+			premine: funded,
+			err:     errGenesisNoParticipants.Error(),
+		},
+		{
+			name:         "no sequencer",
+			premine:      funded,
+			participants: []GenesisParticipant{{Address: watchtower, NodeType: staking.WatchTower}},
+			err:          errGenesisNoSequencer.Error(),
+		},
+		{
+			name:         "unknown node type",
+			premine:      funded,
+			participants: []GenesisParticipant{{Address: seq, NodeType: "validator"}},
+			err:          "unknown node type",
+		},
+		{
+			name:    "duplicate participant",
+			premine: funded,
+			participants: []GenesisParticipant{
+				{Address: seq, NodeType: staking.Sequencer},
+				{Address: seq, NodeType: staking.WatchTower},
+			},
+			err: "more than once",
+		},
+		{
+			name:         "stake not covered",
+			premine:      []GenesisPremine{{Address: seq, Balance: "1000"}},
+			participants: []GenesisParticipant{{Address: seq, NodeType: staking.Sequencer}},
+			err:          "doesn't cover its stake",
+		},
+		{
+			name:         "participant not premined",
+			participants: []GenesisParticipant{{Address: seq, NodeType: staking.Sequencer}},
+			err:          "doesn't cover its stake",
+		},
+		{
+			name:         "invalid balance",
+			premine:      []GenesisPremine{{Address: seq, Balance: "lots"}},
+			participants: []GenesisParticipant{{Address: seq, NodeType: staking.Sequencer}},
+			err:          "invalid balance",
+		},
+		{
+			name:         "valid predeploy",
+			premine:      funded,
+			participants: []GenesisParticipant{{Address: seq, NodeType: staking.Sequencer}},
+			predeploys:   []GenesisPredeploy{{Address: types.StringToAddress("0xc0ffee"), Bytecode: testPredeployBin, Args: "0x2a"}},
+		},
+		{
+			name:         "duplicate predeploy",
+			premine:      funded,
+			participants: []GenesisParticipant{{Address: seq, NodeType: staking.Sequencer}},
+			predeploys: []GenesisPredeploy{
+				{Address: types.StringToAddress("0xc0ffee"), Bytecode: testPredeployBin},
+				{Address: types.StringToAddress("0xc0ffee"), Bytecode: testPredeployBin},
+			},
+			err: "more than once",
+		},
+		{
+			name:         "predeploy at staking contract",
+			premine:      funded,
+			participants: []GenesisParticipant{{Address: seq, NodeType: staking.Sequencer}},
+			predeploys:   []GenesisPredeploy{{Address: staking.AddrStakingContract, Bytecode: testPredeployBin}},
+			err:          "reserved address",
+		},
+		{
+			name:         "predeploy without bytecode",
+			premine:      funded,
+			participants: []GenesisParticipant{{Address: seq, NodeType: staking.Sequencer}},
+			predeploys:   []GenesisPredeploy{{Address: types.StringToAddress("0xc0ffee")}},
+			err:          "invalid bytecode",
+		},
+		{
+			name:         "invalid predeploy arguments",
+			premine:      funded,
+			participants: []GenesisParticipant{{Address: seq, NodeType: staking.Sequencer}},
+			predeploys:   []GenesisPredeploy{{Address: types.StringToAddress("0xc0ffee"), Bytecode: testPredeployBin, Args: "0xzz"}},
+			err:          "invalid constructor arguments",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := GenesisConfig{
+				ChainID:       100,
+				BlockGasLimit: 0x500000,
+				Premine:       tt.premine,
+				Participants:  tt.participants,
+				Predeploys:    tt.predeploys,
+			}
+
+			err := cfg.Validate()
+			if tt.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestGenesisPredeploy(t *testing.T) {
+	seq, key := test.NewAccount(t)
+	addr := types.StringToAddress("0xc0ffee")
+
+	cfg := GenesisConfig{
+		ChainID:       100,
+		BlockGasLimit: 0x500000,
+		Premine: []GenesisPremine{
+			{Address: seq, Balance: "1000000000000000000000"},
+			{Address: addr, Balance: "7"},
+		},
+		Participants: []GenesisParticipant{{Address: seq, NodeType: staking.Sequencer}},
+		Predeploys: []GenesisPredeploy{
+			{Address: addr, Bytecode: testPredeployBin, Args: types.BytesToHash(big.NewInt(42).Bytes()).String()},
+		},
+	}
+
+	generated, err := BuildGenesis(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The code and the storage of the constructor are placed at the address,
+	// with the premine of it.
+	predeploy := generated.Genesis.Alloc[addr]
+	if !assert.NotNil(t, predeploy) {
+		return
+	}
+
+	assert.NotEmpty(t, predeploy.Code)
+	assert.Equal(t, map[types.Hash]types.Hash{{}: types.BytesToHash(big.NewInt(42).Bytes())}, predeploy.Storage)
+	assert.Equal(t, big.NewInt(7), predeploy.Balance)
+
+	// A chain booted from the genesis calls it on block 1.
+	bs, err := json.Marshal(generated)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	local := new(chain.Chain)
+	if err := json.Unmarshal(bs, local); err != nil {
+		t.Fatal(err)
+	}
+
+	d := newTestGenesisAvailOf(t, local, seq, key)
+	settleTestBlock(t, d, testutil.NewFake(avail_types.NewUCompactFromUInt(1)), buildTestBlock(t, d))
+
+	head := d.blockchain.Header()
+	if !assert.Equal(t, uint64(1), head.Number) {
+		return
+	}
+
+	transition, err := d.executor.BeginTxn(head.StateRoot, head, types.ZeroAddress)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := transition.Apply(&types.Transaction{To: &addr, Gas: 100_000, GasPrice: big.NewInt(0), Value: big.NewInt(0)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NoError(t, res.Err)
+	assert.Equal(t, types.BytesToHash(big.NewInt(42).Bytes()).Bytes(), res.ReturnValue)
+
+	// The constructor reverting fails the build with its reason.
+	cfg.Predeploys[0].Args = types.ZeroHash.String()
+
+	_, err = BuildGenesis(cfg)
+	assert.ErrorContains(t, err, "zero value")
+}
@@ -0,0 +1,307 @@
+package avail
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/availproject/op-evm/pkg/staking"
+)
+
+const (
+	// DefaultHealthMaxAvailStall is the default longest time the node may go
+	// without an Avail block received before it's unhealthy.
+	DefaultHealthMaxAvailStall = 2 * time.Minute
+
+	// DefaultHealthMaxBlockAge is the default oldest the head of the chain
+	// may be before the node is degraded.
+	DefaultHealthMaxBlockAge = 5 * DefaultMaxIdleInterval
+
+	// DefaultHealthMaxSettlementLag is the default number of blocks the head
+	// may run ahead of the blocks settled on Avail before the node is
+	// degraded; half the lag pausing the production.
+	DefaultHealthMaxSettlementLag = DefaultMaxSettlementLag / 2
+
+	// healthAvailTimeout bounds the call checking the connectivity of the
+	// Avail client.
+	healthAvailTimeout = 5 * time.Second
+)
+
+// HealthState is the state of the node, or of one of its health checks, as
+// returned by `avail_health` and `/health`.
+type HealthState string
+
+const (
+	// HealthHealthy is the state of the node working as expected.
+	HealthHealthy HealthState = "healthy"
+
+	// HealthDegraded is the state of the node working, short of some of its
+	// duties, such as while syncing or waiting for its stake.
+	HealthDegraded HealthState = "degraded"
+
+	// HealthUnhealthy is the state of the node failing at its duties, such
+	// as with the Avail stream stalled or the consensus halted.
+	HealthUnhealthy HealthState = "unhealthy"
+)
+
+// healthSeverity orders the health states, the worst last.
+var healthSeverity = map[HealthState]int{
+	HealthHealthy:   0,
+	HealthDegraded:  1,
+	HealthUnhealthy: 2,
+}
+
+// The health checks of the node.
+const (
+	HealthCheckAvail      = "avail"
+	HealthCheckChain      = "chain"
+	HealthCheckConsensus  = "consensus"
+	HealthCheckSettlement = "settlement"
+	HealthCheckStaking    = "staking"
+)
+
+// HealthConfig is the thresholds of the health checks of the node; zero
+// disables the check of the threshold.
+type HealthConfig struct {
+	// MaxAvailStall is the longest time the node may go without an Avail
+	// block received, syncing or following the live blocks.
+	MaxAvailStall time.Duration
+
+	// MaxBlockAge is the oldest the head of the chain may be.
+	MaxBlockAge time.Duration
+
+	// MaxSettlementLag is the number of blocks the head may run ahead of the
+	// blocks settled on Avail.
+	MaxSettlementLag uint64
+}
+
+// DefaultHealthConfig returns the default HealthConfig.
+func DefaultHealthConfig() HealthConfig {
+	return HealthConfig{
+		MaxAvailStall:    DefaultHealthMaxAvailStall,
+		MaxBlockAge:      DefaultHealthMaxBlockAge,
+		MaxSettlementLag: DefaultHealthMaxSettlementLag,
+	}
+}
+
+// HealthCheck is the outcome of a health check of the node.
+type HealthCheck struct {
+	Name   string      `json:"name"`
+	State  HealthState `json:"state"`
+	Detail string      `json:"detail"`
+}
+
+// Health is the health of the node, as returned by `avail_health` and
+// `/health`: the worst state of its checks, along with them.
+type Health struct {
+	State  HealthState   `json:"state"`
+	Checks []HealthCheck `json:"checks"`
+}
+
+// Health returns the health of the node: whether the Avail client is
+// connected and the Avail stream advancing, how old the head of the chain is,
+// whether the consensus runs, how far the settlement on Avail lags and
+// whether the node is staked for its role.
+func (api *StatusAPI) Health(ctx context.Context) (*Health, error) {
+	return api.d.Health(ctx), nil
+}
+
+// NewHealthHandler returns the handler serving the health of the node over
+// HTTP, for the liveness and readiness probes: 200 for the healthy and the
+// degraded node, 503 for the unhealthy one, along with the checks.
+func NewHealthHandler(api *StatusAPI) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+
+			return
+		}
+
+		health := api.d.Health(r.Context())
+
+		code := http.StatusOK
+		if health.State == HealthUnhealthy {
+			code = http.StatusServiceUnavailable
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+
+		_ = json.NewEncoder(w).Encode(health)
+	})
+}
+
+// Health returns the health of the node; see StatusAPI.Health.
+func (d *Avail) Health(ctx context.Context) *Health {
+	now := time.Now()
+	if d.healthClock != nil {
+		now = d.healthClock.Now()
+	}
+
+	health := &Health{
+		State: HealthHealthy,
+		Checks: []HealthCheck{
+			d.checkAvailHealth(ctx, now),
+			d.checkChainHealth(now),
+			d.checkConsensusHealth(),
+			d.checkSettlementHealth(),
+			d.checkStakingHealth(),
+		},
+	}
+
+	for _, c := range health.Checks {
+		if healthSeverity[c.State] > healthSeverity[health.State] {
+			health.State = c.State
+		}
+	}
+
+	observeHealth(health)
+
+	return health
+}
+
+// checkAvailHealth checks the Avail client is connected, and the Avail stream
+// advancing within the threshold.
+func (d *Avail) checkAvailHealth(ctx context.Context, now time.Time) HealthCheck {
+	c := HealthCheck{Name: HealthCheckAvail, State: HealthHealthy}
+
+	if d.availClient == nil {
+		c.State, c.Detail = HealthUnhealthy, "Avail client not set up"
+		return c
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, healthAvailTimeout)
+	defer cancel()
+
+	head, err := d.availClient.GetLatestHeader(ctx)
+	if err != nil {
+		c.State, c.Detail = HealthUnhealthy, fmt.Sprintf("Avail unreachable: %s", err)
+		return c
+	}
+
+	seen, at := d.progress.LastSeen()
+
+	switch {
+	case at.IsZero():
+		c.State, c.Detail = HealthDegraded, fmt.Sprintf("no Avail block received yet, Avail head at %d", head.Number)
+	case d.health.MaxAvailStall > 0 && now.Sub(at) > d.health.MaxAvailStall:
+		c.State, c.Detail = HealthUnhealthy, fmt.Sprintf("Avail stream stalled at block %d for %s, Avail head at %d", seen, now.Sub(at).Round(time.Second), head.Number)
+	default:
+		c.Detail = fmt.Sprintf("Avail block %d received %s ago, Avail head at %d", seen, now.Sub(at).Round(time.Second), head.Number)
+	}
+
+	return c
+}
+
+// checkChainHealth checks the head of the chain is no older than the
+// threshold.
+func (d *Avail) checkChainHealth(now time.Time) HealthCheck {
+	c := HealthCheck{Name: HealthCheckChain, State: HealthHealthy}
+
+	head := d.blockchain.Header()
+	if head == nil {
+		c.State, c.Detail = HealthUnhealthy, "no head block"
+		return c
+	}
+
+	age := now.Sub(time.Unix(int64(head.Timestamp), 0)).Round(time.Second)
+	c.Detail = fmt.Sprintf("head block %d is %s old", head.Number, age)
+
+	if d.health.MaxBlockAge > 0 && age > d.health.MaxBlockAge {
+		c.State = HealthDegraded
+	}
+
+	return c
+}
+
+// checkConsensusHealth checks the consensus isn't halted; syncing, or paused
+// by a dispute, it's degraded.
+func (d *Avail) checkConsensusHealth() HealthCheck {
+	c := HealthCheck{Name: HealthCheckConsensus, State: HealthHealthy}
+
+	if d.phases == nil {
+		c.Detail = "phase not tracked"
+		return c
+	}
+
+	phase, since := d.phases.Phase()
+	c.Detail = fmt.Sprintf("%s since %s", phase, since.UTC().Format(time.RFC3339))
+
+	switch phase {
+	case PhaseHalted, PhaseCircuitBroken:
+		c.State = HealthUnhealthy
+	case PhaseBootstrapping, PhaseSyncing, PhasePausedByDispute, PhaseSwitchingRole:
+		c.State = HealthDegraded
+	}
+
+	return c
+}
+
+// checkSettlementHealth checks the head runs no further ahead of the blocks
+// settled on Avail than the threshold; with the production paused by the
+// lag, the node is unhealthy.
+func (d *Avail) checkSettlementHealth() HealthCheck {
+	c := HealthCheck{Name: HealthCheckSettlement, State: HealthHealthy}
+
+	if d.settlement == nil {
+		c.Detail = "settlement lag not tracked"
+		return c
+	}
+
+	lag := d.settlement.Lag()
+	c.Detail = fmt.Sprintf("head %d blocks ahead of the settled block %d", lag, d.settlement.Settled())
+
+	switch {
+	case d.settlement.Paused():
+		c.State = HealthUnhealthy
+		c.Detail += "; block production paused"
+	case d.health.MaxSettlementLag > 0 && lag >= d.health.MaxSettlementLag:
+		c.State = HealthDegraded
+	}
+
+	return c
+}
+
+// checkStakingHealth checks the node is staked for its role: the sequencer
+// ready to produce blocks, the watchtower among the active ones.
+func (d *Avail) checkStakingHealth() HealthCheck {
+	c := HealthCheck{Name: HealthCheckStaking, State: HealthHealthy}
+
+	mode := d.NodeMode()
+
+	if mode == WatchTower {
+		staked, err := staking.NewActiveParticipantsQuerier(d.blockchain, d.executor, d.logger).Contains(d.minerAddr, staking.WatchTower)
+
+		switch {
+		case err != nil:
+			c.State, c.Detail = HealthDegraded, fmt.Sprintf("failed to check the stake of the watchtower: %s", err)
+		case !staked:
+			c.State, c.Detail = HealthUnhealthy, "watchtower not staked"
+		default:
+			c.Detail = "watchtower staked"
+		}
+
+		return c
+	}
+
+	if d.readiness == nil {
+		c.Detail = fmt.Sprintf("%s readiness not tracked", mode)
+		return c
+	}
+
+	reason := d.readiness.Reason()
+	c.Detail = fmt.Sprintf("%s %s", mode, reason)
+
+	switch reason {
+	case ReadinessReady:
+	case ReadinessNotStaked, ReadinessInProbation:
+		c.State = HealthUnhealthy
+	default:
+		c.State = HealthDegraded
+	}
+
+	return c
+}
@@ -0,0 +1,256 @@
+package avail
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/availproject/op-evm/pkg/avail"
+	"github.com/availproject/op-evm/pkg/avail/testutil"
+	avail_types "github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+// healthTestClient is the fake Avail whose connection may be cut.
+type healthTestClient struct {
+	*testutil.Fake
+	down bool
+}
+
+func (c *healthTestClient) GetLatestHeader(ctx context.Context) (*avail_types.Header, error) {
+	if c.down {
+		return nil, errors.New("connection refused")
+	}
+
+	return c.Fake.GetLatestHeader(ctx)
+}
+
+// newTestHealthyAvail returns the consensus of a sequencer passing every
+// health check: connected to Avail and receiving its blocks, active, staked
+// and settled, its head fresh.
+func newTestHealthyAvail(t *testing.T) (*Avail, *healthTestClient, *fakeClock) {
+	t.Helper()
+
+	d := newTestGenesisAvail(t)
+	client := &healthTestClient{Fake: testutil.NewFake(avail_types.NewUCompactFromUInt(1))}
+	clock := newFakeClock(time.Unix(int64(d.blockchain.Header().Timestamp), 0).Add(10 * time.Second))
+
+	d.availClient = client
+	d.healthClock = clock
+	d.health = HealthConfig{MaxAvailStall: time.Minute, MaxBlockAge: 10 * time.Minute, MaxSettlementLag: 5}
+	d.progress = newSyncProgress(clock)
+	d.settlement = newSettlementLag(10, hclog.NewNullLogger())
+	d.readiness = newReadiness()
+	d.readiness.set(ReadinessReady)
+
+	for _, phase := range []Phase{PhaseSyncing, PhaseActive} {
+		if err := d.phases.enter(phase); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	d.progress.seen(1)
+
+	return d, client, clock
+}
+
+// healthCheckOf returns the health check of the given name.
+func healthCheckOf(health *Health, name string) HealthCheck {
+	for _, c := range health.Checks {
+		if c.Name == name {
+			return c
+		}
+	}
+
+	return HealthCheck{}
+}
+
+func TestHealth(t *testing.T) {
+	d, _, _ := newTestHealthyAvail(t)
+
+	health := d.Health(context.Background())
+	assert.Equal(t, HealthHealthy, health.State)
+
+	if assert.Len(t, health.Checks, 5) {
+		for _, c := range health.Checks {
+			assert.Equal(t, HealthHealthy, c.State, c.Name)
+			assert.NotEmpty(t, c.Detail, c.Name)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		breaks func(d *Avail, client *healthTestClient, clock *fakeClock)
+		check  string
+		state  HealthState
+		detail string
+	}{
+		{
+			name:   "Avail unreachable",
+			breaks: func(_ *Avail, client *healthTestClient, _ *fakeClock) { client.down = true },
+			check:  HealthCheckAvail,
+			state:  HealthUnhealthy,
+			detail: "Avail unreachable: connection refused",
+		},
+		{
+			name:   "Avail stream stalled",
+			breaks: func(_ *Avail, _ *healthTestClient, clock *fakeClock) { clock.advance(2 * time.Minute) },
+			check:  HealthCheckAvail,
+			state:  HealthUnhealthy,
+			detail: "Avail stream stalled at block 1 for 2m0s, Avail head at 0",
+		},
+		{
+			name:   "no Avail block received",
+			breaks: func(d *Avail, _ *healthTestClient, clock *fakeClock) { d.progress = newSyncProgress(clock) },
+			check:  HealthCheckAvail,
+			state:  HealthDegraded,
+			detail: "no Avail block received yet, Avail head at 0",
+		},
+		{
+			name: "head too old",
+			breaks: func(d *Avail, _ *healthTestClient, clock *fakeClock) {
+				clock.advance(15 * time.Minute)
+				d.progress.seen(2)
+			},
+			check:  HealthCheckChain,
+			state:  HealthDegraded,
+			detail: "head block 0 is 15m10s old",
+		},
+		{
+			name:   "consensus halted by the circuit breaker",
+			breaks: func(d *Avail, _ *healthTestClient, _ *fakeClock) { _ = d.phases.enter(PhaseCircuitBroken) },
+			check:  HealthCheckConsensus,
+			state:  HealthUnhealthy,
+		},
+		{
+			name:   "consensus syncing",
+			breaks: func(d *Avail, _ *healthTestClient, _ *fakeClock) { _ = d.phases.enter(PhaseSyncing) },
+			check:  HealthCheckConsensus,
+			state:  HealthDegraded,
+		},
+		{
+			name:   "settlement lagging",
+			breaks: func(d *Avail, _ *healthTestClient, _ *fakeClock) { d.settlement.observe(6) },
+			check:  HealthCheckSettlement,
+			state:  HealthDegraded,
+			detail: "head 6 blocks ahead of the settled block 0",
+		},
+		{
+			name:   "production paused by the settlement lag",
+			breaks: func(d *Avail, _ *healthTestClient, _ *fakeClock) { d.settlement.observe(12) },
+			check:  HealthCheckSettlement,
+			state:  HealthUnhealthy,
+			detail: "head 12 blocks ahead of the settled block 0; block production paused",
+		},
+		{
+			name:   "sequencer joining",
+			breaks: func(d *Avail, _ *healthTestClient, _ *fakeClock) { d.readiness.set(ReadinessJoining) },
+			check:  HealthCheckStaking,
+			state:  HealthDegraded,
+			detail: "sequencer joining",
+		},
+		{
+			name:   "sequencer not staked",
+			breaks: func(d *Avail, _ *healthTestClient, _ *fakeClock) { d.readiness.set(ReadinessNotStaked) },
+			check:  HealthCheckStaking,
+			state:  HealthUnhealthy,
+			detail: "sequencer not staked",
+		},
+		{
+			name:   "watchtower not staked",
+			breaks: func(d *Avail, _ *healthTestClient, _ *fakeClock) { d.setNodeType(WatchTower) },
+			check:  HealthCheckStaking,
+			state:  HealthUnhealthy,
+			detail: "watchtower not staked",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, client, clock := newTestHealthyAvail(t)
+			tt.breaks(d, client, clock)
+
+			health := d.Health(context.Background())
+			assert.Equal(t, tt.state, health.State)
+
+			// The broken check alone fails; the others hold.
+			for _, c := range health.Checks {
+				if c.Name != tt.check {
+					assert.Equal(t, HealthHealthy, c.State, c.Name)
+					continue
+				}
+
+				assert.Equal(t, tt.state, c.State)
+
+				if tt.detail != "" {
+					assert.Equal(t, tt.detail, c.Detail)
+				}
+			}
+		})
+	}
+}
+
+func TestHealthServed(t *testing.T) {
+	d, client, _ := newTestHealthyAvail(t)
+	api := NewStatusAPI(d)
+
+	srv := rpc.NewServer()
+	if err := srv.RegisterName(avail.SettlementNamespace, api); err != nil {
+		t.Fatal(err)
+	}
+
+	c := rpc.DialInProc(srv)
+	defer c.Close()
+
+	var health Health
+	if err := c.Call(&health, avail.SettlementNamespace+"_health"); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, HealthHealthy, health.State)
+	assert.Len(t, health.Checks, 5)
+
+	handler := NewHealthHandler(api)
+
+	get := func() (int, Health) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+
+		var health Health
+		if err := json.Unmarshal(rec.Body.Bytes(), &health); err != nil {
+			t.Fatal(err)
+		}
+
+		return rec.Code, health
+	}
+
+	code, health := get()
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, HealthHealthy, health.State)
+
+	// The degraded node still passes the probes.
+	d.readiness.set(ReadinessJoining)
+
+	code, health = get()
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, HealthDegraded, health.State)
+
+	// The unhealthy one doesn't, with the checks telling why.
+	client.down = true
+
+	code, health = get()
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, HealthUnhealthy, health.State)
+	assert.Equal(t, HealthUnhealthy, healthCheckOf(&health, HealthCheckAvail).State)
+	assert.Equal(t, HealthDegraded, healthCheckOf(&health, HealthCheckStaking).State)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/health", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
@@ -0,0 +1,675 @@
+package avail
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/0xPolygon/polygon-edge/crypto"
+	"github.com/0xPolygon/polygon-edge/state"
+	itrie "github.com/0xPolygon/polygon-edge/state/immutable-trie"
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/pkg/blockchain"
+	"github.com/hashicorp/go-hclog"
+	"github.com/umbracle/fastrlp"
+)
+
+// IntegrityCheckpointFileName is the name of the file, in the consensus data
+// directory, the progress of an interrupted integrity check is persisted to.
+const IntegrityCheckpointFileName = "integrity_check.json"
+
+const (
+	// integrityProgressInterval is the number of the entries checked between
+	// the progress reports, and the checkpoints, of the integrity check.
+	integrityProgressInterval = 10_000
+
+	// maxIntegrityIssues is the number of the issues listed by the report of
+	// the integrity check; the ones past it are counted only.
+	maxIntegrityIssues = 1_000
+)
+
+// Kinds of the entries of the database checked by the integrity check.
+const (
+	IntegrityHeader   = "header"
+	IntegrityTrieNode = "trieNode"
+	IntegrityCode     = "code"
+)
+
+// Problems of the entries found by the integrity check.
+const (
+	// IntegrityMissing is the entry referenced missing from the database.
+	IntegrityMissing = "missing"
+
+	// IntegrityCorrupt is the entry not hashing to its reference.
+	IntegrityCorrupt = "corrupt"
+
+	// IntegrityDisconnected is the canonical header not the child of the
+	// canonical one below it.
+	IntegrityDisconnected = "disconnected"
+)
+
+// errIntegrityCheckRunning is returned for the integrity check started while
+// another one is running.
+var errIntegrityCheckRunning = errors.New("integrity check already running")
+
+// IntegrityIssue is an entry of the database found missing or corrupt by the
+// integrity check, pinpointed by its hash, and the height of the header, or
+// the account and the path of the trie node in its trie.
+type IntegrityIssue struct {
+	Kind    string     `json:"kind"`
+	Problem string     `json:"problem"`
+	Hash    types.Hash `json:"hash"`
+
+	// Number is the height of the header.
+	Number uint64 `json:"number,omitempty"`
+
+	// Account is the hash of the address of the account the node of the
+	// storage trie, or the code, belongs to.
+	Account *types.Hash `json:"account,omitempty"`
+
+	// Path is the path of the trie node in its trie, in hex nibbles.
+	Path string `json:"path,omitempty"`
+
+	// Repaired tells whether the entry was refetched from the repair source.
+	Repaired bool `json:"repaired"`
+}
+
+// IntegrityReport is the outcome of the integrity check of the database up to
+// the block at its height: the number of the entries checked and the issues
+// found.
+type IntegrityReport struct {
+	Height    uint64     `json:"height"`
+	Hash      types.Hash `json:"hash"`
+	StateRoot types.Hash `json:"stateRoot"`
+
+	Headers   uint64 `json:"headers"`
+	TrieNodes uint64 `json:"trieNodes"`
+	Codes     uint64 `json:"codes"`
+
+	Issues        []IntegrityIssue `json:"issues"`
+	OmittedIssues uint64           `json:"omittedIssues"`
+	Repaired      uint64           `json:"repaired"`
+
+	// Resumed tells whether the check picked up from the checkpoint of an
+	// earlier one interrupted.
+	Resumed bool `json:"resumed"`
+
+	// Complete tells whether the check went through all the entries; the
+	// one interrupted resumes from its checkpoint on the next call for the
+	// same block.
+	Complete bool `json:"complete"`
+}
+
+// Consistent tells whether the check went through all the entries and found
+// them all in place, or repaired.
+func (r *IntegrityReport) Consistent() bool {
+	if !r.Complete || r.OmittedIssues > 0 {
+		return false
+	}
+
+	for _, issue := range r.Issues {
+		if !issue.Repaired {
+			return false
+		}
+	}
+
+	return true
+}
+
+// IntegrityProgress is the progress of a running integrity check.
+type IntegrityProgress struct {
+	Height    uint64 `json:"height"`
+	Stage     string `json:"stage"`
+	Headers   uint64 `json:"headers"`
+	TrieNodes uint64 `json:"trieNodes"`
+	Codes     uint64 `json:"codes"`
+	Pending   int    `json:"pending"`
+	Issues    int    `json:"issues"`
+}
+
+// RepairSource is the source the integrity check refetches the trie nodes and
+// the codes missing or corrupt from, by their hash.
+type RepairSource interface {
+	StateNode(hash types.Hash) ([]byte, bool)
+	StateCode(hash types.Hash) ([]byte, bool)
+}
+
+// IntegrityOption configures the integrity check.
+type IntegrityOption func(*integrityCheck)
+
+// WithIntegrityRepair has the integrity check refetch the trie nodes and the
+// codes missing or corrupt from the given source, and write them back.
+func WithIntegrityRepair(source RepairSource) IntegrityOption {
+	return func(c *integrityCheck) {
+		c.repair = source
+	}
+}
+
+// WithIntegrityProgress has the integrity check report its progress to the
+// given function, along with the log, every so many entries checked.
+func WithIntegrityProgress(fn func(IntegrityProgress)) IntegrityOption {
+	return func(c *integrityCheck) {
+		c.progress = fn
+	}
+}
+
+// withIntegrityProgressEvery has the integrity check report its progress, and
+// checkpoint it, every given number of the entries checked.
+func withIntegrityProgressEvery(n uint64) IntegrityOption {
+	return func(c *integrityCheck) {
+		c.progressEvery = n
+	}
+}
+
+// integrityNode is a trie node the integrity check is yet to go through.
+type integrityNode struct {
+	Hash    types.Hash  `json:"hash"`
+	Storage bool        `json:"storage,omitempty"`
+	Account *types.Hash `json:"account,omitempty"`
+	Path    string      `json:"path,omitempty"`
+}
+
+// integrityCheckpoint is the progress of the integrity check, persisted for
+// the interrupted one to pick up from: the report so far, the next header to
+// check, and the trie nodes yet to go through, depth first.
+type integrityCheckpoint struct {
+	Report       IntegrityReport `json:"report"`
+	NextHeader   uint64          `json:"nextHeader"`
+	ParentHash   types.Hash      `json:"parentHash"`
+	StateStarted bool            `json:"stateStarted"`
+	Pending      []integrityNode `json:"pending"`
+}
+
+// integrityCheck walks the canonical header chain from the genesis up to a
+// height, checking the headers to hash to their canonical hash and to follow
+// each other, and the trie of the state at the height, down to the storage
+// tries and the codes of the accounts, checking every node to hash to its
+// reference; a state root walked through entirely is recomputable from its
+// nodes. The entries are read from the storage, past the caches.
+type integrityCheck struct {
+	bc            *blockchain.Blockchain
+	storage       itrie.Storage
+	path          string
+	repair        RepairSource
+	progress      func(IntegrityProgress)
+	progressEvery uint64
+	logger        hclog.Logger
+
+	cp     integrityCheckpoint
+	steps  uint64
+	issues map[string]struct{}
+	seen   map[types.Hash]struct{}
+}
+
+// VerifyIntegrity checks the database of the node to be internally consistent
+// up to the block at the given height: the canonical header chain from the
+// genesis, contiguous and each header hashing to its canonical hash, and the
+// state at the height, every trie node referenced present and hashing to its
+// reference, down to the storage tries and the codes. It returns the report of
+// the entries missing or corrupt. The check is interrupted with the context,
+// its progress checkpointed to the data directory, and the next check of the
+// same block picks up from there.
+func (d *Avail) VerifyIntegrity(ctx context.Context, height uint64, opts ...IntegrityOption) (*IntegrityReport, error) {
+	if !d.integrityLock.TryLock() {
+		return nil, errIntegrityCheckRunning
+	}
+
+	defer d.integrityLock.Unlock()
+
+	if head := d.blockchain.Header().Number; height > head {
+		return nil, fmt.Errorf("block %d past the head at %d", height, head)
+	}
+
+	if d.stateStorage == nil {
+		return nil, errNoStateStorage
+	}
+
+	c := &integrityCheck{
+		bc:            d.blockchain,
+		storage:       d.stateStorage,
+		path:          d.integrityPath,
+		progressEvery: integrityProgressInterval,
+		logger:        d.logger.Named("integrity"),
+		issues:        make(map[string]struct{}),
+		seen:          make(map[types.Hash]struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	c.begin(height)
+
+	if err := c.run(ctx); err != nil {
+		c.save()
+		c.logger.Warn("integrity check interrupted; it resumes from its checkpoint on the next run", "block_number", height, "headers", c.cp.Report.Headers, "trie_nodes", c.cp.Report.TrieNodes, "issues", len(c.cp.Report.Issues))
+
+		report := c.cp.Report
+
+		return &report, err
+	}
+
+	c.cp.Report.Complete = true
+	c.complete()
+
+	report := c.cp.Report
+	observeIntegrityCheck(&report)
+
+	c.logger.Info("integrity check complete", "block_number", height, "block_hash", report.Hash, "state_root", report.StateRoot, "headers", report.Headers, "trie_nodes", report.TrieNodes, "codes", report.Codes, "issues", len(report.Issues)+int(report.OmittedIssues), "repaired", report.Repaired, "consistent", report.Consistent())
+
+	return &report, nil
+}
+
+// begin sets the check of the block at the given height up, from the
+// checkpoint of the interrupted check of the same block, if any.
+func (c *integrityCheck) begin(height uint64) {
+	hash, hdr, _ := c.bc.ReadCanonicalHeader(height)
+
+	if cp, ok := c.load(); ok && cp.Report.Height == height && cp.Report.Hash == hash {
+		c.cp = cp
+		c.cp.Report.Resumed = true
+
+		for _, issue := range c.cp.Report.Issues {
+			c.issues[issueKey(issue)] = struct{}{}
+		}
+
+		c.logger.Info("integrity check resumed", "block_number", height, "next_header", cp.NextHeader, "pending", len(cp.Pending))
+
+		return
+	}
+
+	c.cp = integrityCheckpoint{Report: IntegrityReport{Height: height, Hash: hash}}
+
+	// The state is taken at the root of the header, the one stored under its
+	// canonical hash; the header found missing or corrupt by the walk of the
+	// headers leaves no state to check.
+	if hdr != nil && hdr.Hash == hash {
+		c.cp.Report.StateRoot = hdr.StateRoot
+	}
+}
+
+// run goes through the headers, and then the trie nodes, until all are
+// checked or the context is done.
+func (c *integrityCheck) run(ctx context.Context) error {
+	for ; c.cp.NextHeader <= c.cp.Report.Height; c.cp.NextHeader++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		c.checkHeader(c.cp.NextHeader)
+		c.step()
+	}
+
+	if !c.cp.StateStarted {
+		c.cp.StateStarted = true
+
+		if root := c.cp.Report.StateRoot; root != types.EmptyRootHash && root != types.ZeroHash {
+			c.cp.Pending = append(c.cp.Pending, integrityNode{Hash: root})
+		}
+	}
+
+	for len(c.cp.Pending) > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		n := c.cp.Pending[len(c.cp.Pending)-1]
+		c.cp.Pending = c.cp.Pending[:len(c.cp.Pending)-1]
+
+		c.visit(n)
+		c.step()
+	}
+
+	return nil
+}
+
+// checkHeader checks the canonical header at the given height to hash to its
+// canonical hash, and to be the child of the one below it.
+func (c *integrityCheck) checkHeader(number uint64) {
+	c.cp.Report.Headers++
+
+	hash, hdr, ok := c.bc.ReadCanonicalHeader(number)
+	parent := c.cp.ParentHash
+	c.cp.ParentHash = hash
+
+	switch {
+	case !ok, hdr == nil:
+		c.addIssue(IntegrityIssue{Kind: IntegrityHeader, Problem: IntegrityMissing, Hash: hash, Number: number})
+
+	case hdr.Hash != hash:
+		c.addIssue(IntegrityIssue{Kind: IntegrityHeader, Problem: IntegrityCorrupt, Hash: hash, Number: number})
+
+	case hdr.Number != number, number > 0 && parent != types.ZeroHash && hdr.ParentHash != parent:
+		c.addIssue(IntegrityIssue{Kind: IntegrityHeader, Problem: IntegrityDisconnected, Hash: hash, Number: number})
+	}
+}
+
+// visit checks the trie node, repairing it when missing or corrupt, and
+// queues up the nodes below it.
+func (c *integrityCheck) visit(n integrityNode) {
+	c.cp.Report.TrieNodes++
+
+	data, ok := c.storage.Get(n.Hash.Bytes())
+
+	problem := ""
+
+	switch {
+	case !ok:
+		problem = IntegrityMissing
+	case types.BytesToHash(crypto.Keccak256(data)) != n.Hash:
+		problem = IntegrityCorrupt
+	}
+
+	if problem != "" {
+		issue := IntegrityIssue{Kind: IntegrityTrieNode, Problem: problem, Hash: n.Hash, Account: n.Account, Path: n.Path}
+		data, issue.Repaired = c.repairNode(n.Hash)
+		c.addIssue(issue)
+
+		if !issue.Repaired {
+			return
+		}
+	}
+
+	// The values parsed point into the parser, so each node gets its own.
+	var p fastrlp.Parser
+
+	v, err := p.Parse(data)
+	if err == nil {
+		err = c.walk(v, n)
+	}
+
+	if err != nil {
+		c.logger.Warn("invalid trie node", "hash", n.Hash, "path", n.Path, "error", err)
+		c.addIssue(IntegrityIssue{Kind: IntegrityTrieNode, Problem: IntegrityCorrupt, Hash: n.Hash, Account: n.Account, Path: n.Path})
+	}
+}
+
+// walk queues up the trie nodes below the given one, stored or embedded in
+// its parent, n, and goes through the accounts of its leaves.
+func (c *integrityCheck) walk(v *fastrlp.Value, n integrityNode) error {
+	if v.Type() != fastrlp.TypeArray {
+		return fmt.Errorf("trie node expected to be a list")
+	}
+
+	switch v.Elems() {
+	case 2:
+		nibbles, leaf := compactNibbles(v.Get(0).Raw())
+		path := n.Path + nibbles
+
+		if leaf {
+			return c.leaf(v.Get(1).Raw(), n, path)
+		}
+
+		return c.child(v.Get(1), n, path)
+
+	case 17:
+		for i := 15; i >= 0; i-- {
+			if err := c.child(v.Get(i), n, n.Path+hexNibble(byte(i))); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("trie node has incorrect number of elements")
+}
+
+// child goes through the child of a trie node at the given path, the node
+// embedded in it, or queues up the one referenced by hash.
+func (c *integrityCheck) child(v *fastrlp.Value, parent integrityNode, path string) error {
+	if v.Type() == fastrlp.TypeArray {
+		embedded := parent
+		embedded.Path = path
+
+		return c.walk(v, embedded)
+	}
+
+	if len(v.Raw()) == 0 {
+		return nil
+	}
+
+	c.cp.Pending = append(c.cp.Pending, integrityNode{Hash: types.BytesToHash(v.Raw()), Storage: parent.Storage, Account: parent.Account, Path: path})
+
+	return nil
+}
+
+// leaf goes through the account held by the leaf of the account trie at the
+// given path, queueing up the root of its storage trie and checking its code,
+// each once; the storage slots are skipped.
+func (c *integrityCheck) leaf(value []byte, n integrityNode, path string) error {
+	if n.Storage {
+		return nil
+	}
+
+	var account state.Account
+	if err := account.UnmarshalRlp(value); err != nil {
+		return fmt.Errorf("invalid account: %w", err)
+	}
+
+	addrHash := types.StringToHash(path)
+
+	if root := account.Root; root != types.EmptyRootHash && root != types.ZeroHash && c.once(root) {
+		c.cp.Pending = append(c.cp.Pending, integrityNode{Hash: root, Storage: true, Account: &addrHash})
+	}
+
+	if code := types.BytesToHash(account.CodeHash); code != types.EmptyCodeHash && code != types.ZeroHash && c.once(code) {
+		c.checkCode(code, addrHash)
+	}
+
+	return nil
+}
+
+// checkCode checks the code of the given hash, repairing it when missing or
+// corrupt.
+func (c *integrityCheck) checkCode(hash, account types.Hash) {
+	c.cp.Report.Codes++
+
+	code, ok := c.storage.GetCode(hash)
+
+	switch {
+	case !ok:
+		c.addIssue(IntegrityIssue{Kind: IntegrityCode, Problem: IntegrityMissing, Hash: hash, Account: &account, Repaired: c.repairCode(hash)})
+	case types.BytesToHash(crypto.Keccak256(code)) != hash:
+		c.addIssue(IntegrityIssue{Kind: IntegrityCode, Problem: IntegrityCorrupt, Hash: hash, Account: &account, Repaired: c.repairCode(hash)})
+	}
+}
+
+// repairNode refetches the trie node of the given hash from the repair source,
+// and writes it back once it checks out against its hash.
+func (c *integrityCheck) repairNode(hash types.Hash) ([]byte, bool) {
+	if c.repair == nil {
+		return nil, false
+	}
+
+	data, ok := c.repair.StateNode(hash)
+	if !ok || types.BytesToHash(crypto.Keccak256(data)) != hash {
+		return nil, false
+	}
+
+	c.storage.Put(hash.Bytes(), data)
+	c.cp.Report.Repaired++
+
+	return data, true
+}
+
+// repairCode refetches the code of the given hash from the repair source, and
+// writes it back once it checks out against its hash.
+func (c *integrityCheck) repairCode(hash types.Hash) bool {
+	if c.repair == nil {
+		return false
+	}
+
+	code, ok := c.repair.StateCode(hash)
+	if !ok || types.BytesToHash(crypto.Keccak256(code)) != hash {
+		return false
+	}
+
+	c.storage.SetCode(hash, code)
+	c.cp.Report.Repaired++
+
+	return true
+}
+
+// once tells whether the storage trie or the code of the given hash is seen
+// for the first time by the check, shared by several accounts otherwise.
+func (c *integrityCheck) once(hash types.Hash) bool {
+	if _, ok := c.seen[hash]; ok {
+		return false
+	}
+
+	c.seen[hash] = struct{}{}
+
+	return true
+}
+
+// addIssue adds the issue to the report, once; the ones past the max are
+// counted only.
+func (c *integrityCheck) addIssue(issue IntegrityIssue) {
+	key := issueKey(issue)
+	if _, ok := c.issues[key]; ok {
+		return
+	}
+
+	c.issues[key] = struct{}{}
+
+	if len(c.cp.Report.Issues) >= maxIntegrityIssues {
+		c.cp.Report.OmittedIssues++
+		return
+	}
+
+	c.logger.Warn("integrity issue found", "kind", issue.Kind, "problem", issue.Problem, "hash", issue.Hash, "number", issue.Number, "path", issue.Path, "repaired", issue.Repaired)
+
+	c.cp.Report.Issues = append(c.cp.Report.Issues, issue)
+}
+
+// issueKey is the key the issues are told apart by.
+func issueKey(issue IntegrityIssue) string {
+	return fmt.Sprintf("%s/%s/%d/%s", issue.Kind, issue.Hash, issue.Number, issue.Path)
+}
+
+// step counts the entry checked, reporting the progress, and checkpointing
+// it, every so many of them.
+func (c *integrityCheck) step() {
+	c.steps++
+	if c.progressEvery == 0 || c.steps%c.progressEvery != 0 {
+		return
+	}
+
+	p := IntegrityProgress{
+		Height:    c.cp.Report.Height,
+		Stage:     IntegrityHeader,
+		Headers:   c.cp.Report.Headers,
+		TrieNodes: c.cp.Report.TrieNodes,
+		Codes:     c.cp.Report.Codes,
+		Pending:   len(c.cp.Pending),
+		Issues:    len(c.cp.Report.Issues) + int(c.cp.Report.OmittedIssues),
+	}
+
+	if c.cp.StateStarted {
+		p.Stage = IntegrityTrieNode
+	}
+
+	c.logger.Info("integrity check in progress", "block_number", p.Height, "stage", p.Stage, "headers", p.Headers, "trie_nodes", p.TrieNodes, "codes", p.Codes, "pending", p.Pending, "issues", p.Issues)
+	observeIntegrityProgress(p)
+
+	if c.progress != nil {
+		c.progress(p)
+	}
+
+	c.save()
+}
+
+// load returns the checkpoint of the interrupted check, if any.
+func (c *integrityCheck) load() (integrityCheckpoint, bool) {
+	if c.path == "" {
+		return integrityCheckpoint{}, false
+	}
+
+	bs, err := os.ReadFile(c.path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			c.logger.Warn("failed to read the integrity checkpoint; starting over", "error", err)
+		}
+
+		return integrityCheckpoint{}, false
+	}
+
+	var cp integrityCheckpoint
+	if err := json.Unmarshal(bs, &cp); err != nil {
+		c.logger.Warn("invalid integrity checkpoint; starting over", "error", err)
+		return integrityCheckpoint{}, false
+	}
+
+	return cp, true
+}
+
+// save persists the checkpoint of the check.
+func (c *integrityCheck) save() {
+	if c.path == "" {
+		return
+	}
+
+	bs, err := json.Marshal(c.cp)
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(c.path), 0o700)
+	}
+
+	tmp := c.path + ".tmp"
+	if err == nil {
+		err = os.WriteFile(tmp, bs, 0o600)
+	}
+
+	if err == nil {
+		err = os.Rename(tmp, c.path)
+	}
+
+	if err != nil {
+		c.logger.Error("failed to persist the integrity checkpoint", "error", err)
+	}
+}
+
+// complete removes the checkpoint of the check completed.
+func (c *integrityCheck) complete() {
+	if c.path == "" {
+		return
+	}
+
+	if err := os.Remove(c.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		c.logger.Error("failed to remove the integrity checkpoint", "error", err)
+	}
+}
+
+// compactNibbles returns the nibbles of the hex-prefix encoded key of a trie
+// node, in hex, and whether it's the key of a leaf, by its terminator flag.
+func compactNibbles(key []byte) (string, bool) {
+	if len(key) == 0 {
+		return "", false
+	}
+
+	flag := key[0] >> 4
+
+	nibbles := make([]byte, 0, 2*len(key))
+	if flag&1 == 1 {
+		nibbles = append(nibbles, key[0]&0x0f)
+	}
+
+	for _, b := range key[1:] {
+		nibbles = append(nibbles, b>>4, b&0x0f)
+	}
+
+	path := make([]byte, len(nibbles))
+	for i, n := range nibbles {
+		path[i] = hexNibble(n)[0]
+	}
+
+	return string(path), flag >= 2
+}
+
+// hexNibble returns the hex digit of the nibble.
+func hexNibble(n byte) string {
+	return string("0123456789abcdef"[n&0x0f])
+}
@@ -0,0 +1,279 @@
+package avail
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/0xPolygon/polygon-edge/blockchain/storage"
+	"github.com/0xPolygon/polygon-edge/crypto"
+	itrie "github.com/0xPolygon/polygon-edge/state/immutable-trie"
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/pkg/avail"
+	"github.com/availproject/op-evm/pkg/avail/testutil"
+	avail_types "github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/umbracle/fastrlp"
+)
+
+// newTestIntegrityCopy returns the node holding a copy of the chain of the
+// producer settled on Avail, synced off it, with its storage.
+func newTestIntegrityCopy(t *testing.T, fake *testutil.Fake, appID avail_types.UCompact) (*Avail, storage.Storage) {
+	t.Helper()
+
+	db := newTestMemoryStorage(t)
+
+	d := newTestSyncingAvail(t, fake, appID, db)
+	d.integrityPath = filepath.Join(t.TempDir(), IntegrityCheckpointFileName)
+
+	if _, err := syncTestAvail(d, fake); err != nil {
+		t.Fatal(err)
+	}
+
+	return d, db
+}
+
+// corruptTestNode overwrites the trie node of the given hash in the state
+// storage with garbage.
+func corruptTestNode(d *Avail, hash types.Hash) {
+	d.stateStorage.Put(hash.Bytes(), []byte("garbage"))
+}
+
+// testTrieChild returns the first child of the branch node of the given hash
+// referenced by hash, and its nibble.
+func testTrieChild(t *testing.T, d *Avail, hash types.Hash) (types.Hash, byte) {
+	t.Helper()
+
+	data, ok := d.stateStorage.Get(hash.Bytes())
+	if !ok {
+		t.Fatalf("trie node %s not found", hash)
+	}
+
+	var p fastrlp.Parser
+
+	v, err := p.Parse(data)
+	if err != nil || v.Elems() != 17 {
+		t.Fatalf("trie node %s not a branch", hash)
+	}
+
+	for i := 0; i < 16; i++ {
+		if child := v.Get(i); child.Type() == fastrlp.TypeBytes && len(child.Raw()) == types.HashLength {
+			return types.BytesToHash(child.Raw()), byte(i)
+		}
+	}
+
+	t.Fatalf("trie node %s has no child referenced by hash", hash)
+
+	return types.Hash{}, 0
+}
+
+// settleTestIntegrity settles some activity on Avail, a contract with its
+// storage among it, and returns the producer of it.
+func settleTestIntegrity(t *testing.T, fake *testutil.Fake) (*Avail, *testActivity) {
+	t.Helper()
+
+	producer := newTestGenesisAvail(t)
+
+	activity := newTestActivity(producer, fake)
+	activity.settle(t, 12)
+
+	return producer, activity
+}
+
+func TestVerifyIntegrity(t *testing.T) {
+	appID := avail_types.NewUCompactFromUInt(1)
+	fake := testutil.NewFake(appID)
+
+	producer, activity := settleTestIntegrity(t, fake)
+
+	d, _ := newTestIntegrityCopy(t, fake, appID)
+	head := d.blockchain.Header()
+
+	if !assert.Equal(t, producer.blockchain.Header().Hash, head.Hash) {
+		return
+	}
+
+	// The copy checks out, over the admin API as well.
+	report, err := NewAdminAPI(d).VerifyIntegrity(context.Background(), head.Number, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.True(t, report.Consistent())
+	assert.Empty(t, report.Issues)
+	assert.Equal(t, head.Hash, report.Hash)
+	assert.Equal(t, head.StateRoot, report.StateRoot)
+	assert.Equal(t, head.Number+1, report.Headers)
+	assert.NotZero(t, report.TrieNodes)
+	assert.NotZero(t, report.Codes)
+
+	// A node of the account trie, and the root of the storage trie of the
+	// contract, go corrupt.
+	child, nibble := testTrieChild(t, d, head.StateRoot)
+	corruptTestNode(d, child)
+
+	account, err := itrie.NewState(d.stateStorage).NewSnapshotAt(head.StateRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contract, err := account.(*itrie.Snapshot).GetAccount(activity.contract)
+	if err != nil || contract == nil {
+		t.Fatalf("contract not found: %v", err)
+	}
+
+	corruptTestNode(d, contract.Root)
+
+	report, err = d.VerifyIntegrity(context.Background(), head.Number)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.True(t, report.Complete)
+	assert.False(t, report.Consistent())
+
+	// The report pinpoints both: the node of the account trie by its path,
+	// and the root of the storage trie by its account. The contract may sit
+	// below the corrupt node of the account trie, its storage out of reach.
+	contractHash := types.BytesToHash(crypto.Keccak256(activity.contract.Bytes()))
+
+	assert.Contains(t, report.Issues, IntegrityIssue{Kind: IntegrityTrieNode, Problem: IntegrityCorrupt, Hash: child, Path: hexNibble(nibble)})
+
+	if contractHash.String()[2] != hexNibble(nibble)[0] {
+		assert.Len(t, report.Issues, 2)
+		assert.Contains(t, report.Issues, IntegrityIssue{Kind: IntegrityTrieNode, Problem: IntegrityCorrupt, Hash: contract.Root, Account: &contractHash})
+	} else {
+		assert.Len(t, report.Issues, 1)
+	}
+}
+
+func TestVerifyIntegrityHeaders(t *testing.T) {
+	appID := avail_types.NewUCompactFromUInt(1)
+	fake := testutil.NewFake(appID)
+
+	settleTestIntegrity(t, fake)
+
+	d, db := newTestIntegrityCopy(t, fake, appID)
+	head := d.blockchain.Header()
+
+	// The header of a block goes corrupt, under its canonical hash, short of
+	// the caches of the chain.
+	hdr, ok := d.blockchain.GetHeaderByNumber(5)
+	if !ok {
+		t.Fatal("header 5 not found")
+	}
+
+	corrupt := hdr.Copy()
+	corrupt.GasUsed++
+
+	if err := db.WriteHeader(corrupt); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := d.VerifyIntegrity(context.Background(), head.Number)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []IntegrityIssue{{Kind: IntegrityHeader, Problem: IntegrityCorrupt, Hash: hdr.Hash, Number: 5}}, report.Issues)
+
+	// The block past the head is refused.
+	_, err = d.VerifyIntegrity(context.Background(), head.Number+1)
+	assert.Error(t, err)
+}
+
+func TestVerifyIntegrityResumes(t *testing.T) {
+	appID := avail_types.NewUCompactFromUInt(1)
+	fake := testutil.NewFake(appID)
+
+	settleTestIntegrity(t, fake)
+
+	d, _ := newTestIntegrityCopy(t, fake, appID)
+	head := d.blockchain.Header()
+
+	full, err := d.VerifyIntegrity(context.Background(), head.Number)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The check is interrupted midway through the state.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	reports := 0
+
+	partial, err := d.VerifyIntegrity(ctx, head.Number, withIntegrityProgressEvery(5), WithIntegrityProgress(func(p IntegrityProgress) {
+		if reports++; p.Stage == IntegrityTrieNode && p.TrieNodes >= full.TrieNodes/2 {
+			cancel()
+		}
+	}))
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.False(t, partial.Complete)
+	assert.Less(t, partial.TrieNodes, full.TrieNodes)
+	assert.NotZero(t, reports)
+	assert.FileExists(t, d.integrityPath)
+
+	// The next check picks up from there, and goes through the rest.
+	resumed, err := d.VerifyIntegrity(context.Background(), head.Number)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.True(t, resumed.Resumed)
+	assert.True(t, resumed.Consistent())
+	assert.Equal(t, full.Headers, resumed.Headers)
+	assert.Equal(t, full.TrieNodes, resumed.TrieNodes)
+	assert.Equal(t, full.Codes, resumed.Codes)
+
+	_, err = os.Stat(d.integrityPath)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestVerifyIntegrityRepair(t *testing.T) {
+	appID := avail_types.NewUCompactFromUInt(1)
+	fake := testutil.NewFake(appID)
+
+	producer, _ := settleTestIntegrity(t, fake)
+
+	d, _ := newTestIntegrityCopy(t, fake, appID)
+	head := d.blockchain.Header()
+
+	child, _ := testTrieChild(t, d, head.StateRoot)
+	corruptTestNode(d, child)
+
+	// The producer serves the state to repair from.
+	producer.serveState = true
+
+	srv := rpc.NewServer()
+	if err := srv.RegisterName(avail.SettlementNamespace, NewStatusAPI(producer)); err != nil {
+		t.Fatal(err)
+	}
+
+	c := rpc.DialInProc(srv)
+	defer c.Close()
+
+	report, err := d.VerifyIntegrity(context.Background(), head.Number, WithIntegrityRepair(newStateProvider(c, hclog.NewNullLogger())))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if assert.Len(t, report.Issues, 1) {
+		assert.Equal(t, child, report.Issues[0].Hash)
+		assert.True(t, report.Issues[0].Repaired)
+	}
+
+	assert.Equal(t, uint64(1), report.Repaired)
+	assert.True(t, report.Consistent())
+
+	// The repaired node holds.
+	report, err = d.VerifyIntegrity(context.Background(), head.Number)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Empty(t, report.Issues)
+	assert.True(t, report.Consistent())
+}
@@ -0,0 +1,246 @@
+package avail
+
+import (
+	"crypto/ecdsa"
+	"sync"
+
+	"github.com/0xPolygon/polygon-edge/crypto"
+	"github.com/0xPolygon/polygon-edge/txpool"
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/pkg/staking"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/hashicorp/go-hclog"
+)
+
+// NextValidatorKey is the name of the secret holding the next signing key of
+// the sequencer, the key rotation switches the block signing over to.
+const NextValidatorKey = "validator-next-key"
+
+// KeyRotationState is the state of the rotation of the signing key of the
+// sequencer, as reported by the status API.
+type KeyRotationState string
+
+const (
+	// KeyRotationStaking is the state of the rotation staking the next key,
+	// while the current one still signs the blocks.
+	KeyRotationStaking KeyRotationState = "staking next key"
+
+	// KeyRotationRetiring is the state of the rotation past the switch,
+	// unstaking the previous key while the next one signs the blocks.
+	KeyRotationRetiring KeyRotationState = "retiring previous key"
+
+	// KeyRotationDone is the state of the completed rotation.
+	KeyRotationDone KeyRotationState = "done"
+)
+
+// KeyRotationStatus is the status of the rotation of the signing key of the
+// sequencer.
+type KeyRotationStatus struct {
+	State KeyRotationState `json:"state"`
+
+	// CurrentKey is the address of the key the rotation started from, and
+	// NextKey the one it rotates to.
+	CurrentKey types.Address `json:"currentKey"`
+	NextKey    types.Address `json:"nextKey"`
+
+	// SwitchHeight is the first block signed by the next key, provided it's
+	// staked by then, and SwitchedAt the one it actually was, once switched.
+	SwitchHeight uint64 `json:"switchHeight"`
+	SwitchedAt   uint64 `json:"switchedAt,omitempty"`
+}
+
+// keyRotation rotates the signing key of the sequencer without stopping the
+// block production. The next key is staked while the current one still
+// signs the blocks; from the switch height on, once the next key is staked,
+// the blocks are signed by the next key, and the current one is unstaked.
+// With no next key, the current key signs all the blocks.
+type keyRotation struct {
+	logger hclog.Logger
+
+	lock         sync.Mutex
+	current      *ecdsa.PrivateKey
+	next         *ecdsa.PrivateKey
+	switchHeight uint64
+	switchedAt   uint64
+	state        KeyRotationState
+	submitted    bool // Whether the staking transaction of the state is in the txpool
+}
+
+// newKeyRotation returns the keyRotation switching from the current key over
+// to the next one at the given height; a nil next key rotates nothing.
+func newKeyRotation(current, next *ecdsa.PrivateKey, switchHeight uint64, logger hclog.Logger) *keyRotation {
+	r := &keyRotation{
+		logger:       logger,
+		current:      current,
+		next:         next,
+		switchHeight: switchHeight,
+		state:        KeyRotationDone,
+	}
+
+	if next != nil {
+		r.state = KeyRotationStaking
+	}
+
+	return r
+}
+
+// signer returns the key signing the blocks.
+func (r *keyRotation) signer() *ecdsa.PrivateKey {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.state == KeyRotationStaking {
+		return r.current
+	}
+
+	if r.next != nil {
+		return r.next
+	}
+
+	return r.current
+}
+
+// Address returns the address of the key signing the blocks.
+func (r *keyRotation) Address() types.Address {
+	return crypto.PubKeyToAddress(&r.signer().PublicKey)
+}
+
+// owns reports whether the address is of any of the keys of the rotation.
+func (r *keyRotation) owns(addr types.Address) bool {
+	if r == nil {
+		return false
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for _, key := range []*ecdsa.PrivateKey{r.current, r.next} {
+		if key != nil && crypto.PubKeyToAddress(&key.PublicKey) == addr {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Status returns the status of the rotation, or nil if there's none.
+func (r *keyRotation) Status() *KeyRotationStatus {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.next == nil {
+		return nil
+	}
+
+	return &KeyRotationStatus{
+		State:        r.state,
+		CurrentKey:   crypto.PubKeyToAddress(&r.current.PublicKey),
+		NextKey:      crypto.PubKeyToAddress(&r.next.PublicKey),
+		SwitchHeight: r.switchHeight,
+		SwitchedAt:   r.switchedAt,
+	}
+}
+
+// advance moves the rotation on ahead of the block at the given height: it
+// submits the staking transaction of the next key, switches the signing over
+// to it at the switch height once it's staked, and then submits the unstaking
+// transaction of the previous key, until it's unstaked.
+func (r *keyRotation) advance(number uint64, txp *txpool.TxPool, apq staking.ActiveParticipants) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	switch r.state {
+	case KeyRotationStaking:
+		addr := crypto.PubKeyToAddress(&r.next.PublicKey)
+
+		staked, err := apq.Contains(addr, staking.Sequencer)
+		if err != nil {
+			r.logger.Error("failed to check the stake of the next key", "address", addr, "error", err)
+			return
+		}
+
+		if !staked {
+			if !r.submitted {
+				r.submitTx(txp, r.next, func() (*types.Transaction, error) {
+					return staking.StakeTx(addr, stakeAmount, string(staking.Sequencer), 1_000_000)
+				})
+			}
+
+			if number >= r.switchHeight {
+				r.logger.Warn("next key not staked yet; the current key goes on signing the blocks", "address", addr, "block_number", number, "switch_height", r.switchHeight)
+			}
+
+			return
+		}
+
+		if number < r.switchHeight {
+			return
+		}
+
+		r.state, r.switchedAt, r.submitted = KeyRotationRetiring, number, false
+
+		r.logger.Info("switched block signing over to the next key", "address", addr, "block_number", number)
+
+		fallthrough
+
+	case KeyRotationRetiring:
+		addr := crypto.PubKeyToAddress(&r.current.PublicKey)
+
+		staked, err := apq.Contains(addr, staking.Sequencer)
+		if err != nil {
+			r.logger.Error("failed to check the stake of the previous key", "address", addr, "error", err)
+			return
+		}
+
+		if staked {
+			if !r.submitted {
+				r.submitTx(txp, r.current, func() (*types.Transaction, error) {
+					return staking.UnStakeTx(addr, 1_000_000)
+				})
+			}
+
+			return
+		}
+
+		r.state = KeyRotationDone
+
+		r.logger.Info("key rotation done; previous key unstaked", "address", addr)
+	}
+}
+
+// submitTx signs the staking transaction with the given key and adds it to
+// the txpool; it's submitted again on the next block should it fail.
+func (r *keyRotation) submitTx(txp *txpool.TxPool, key *ecdsa.PrivateKey, newTx func() (*types.Transaction, error)) {
+	tx, err := newTx()
+	if err != nil {
+		r.logger.Error("failed to build the staking transaction of the key rotation", "error", err)
+		return
+	}
+
+	tx.Nonce = txp.GetNonce(tx.From)
+
+	tx, err = (&crypto.FrontierSigner{}).SignTx(tx, key)
+	if err != nil {
+		r.logger.Error("failed to sign the staking transaction of the key rotation", "error", err)
+		return
+	}
+
+	if err := txp.AddTx(tx); err != nil {
+		r.logger.Error("failed to add the staking transaction of the key rotation to the txpool", "address", tx.From, "error", err)
+		return
+	}
+
+	r.submitted = true
+}
+
+// signerAccount returns the account and the key signing the block at the
+// given height, moving the key rotation on first.
+func (sw *SequencerWorker) signerAccount(number uint64) (accounts.Account, *keystore.Key) {
+	sw.keys.advance(number, sw.txpool, sw.apq)
+
+	key := sw.keys.signer()
+
+	return accounts.Account{Address: common.Address(crypto.PubKeyToAddress(&key.PublicKey))}, &keystore.Key{PrivateKey: key}
+}
@@ -0,0 +1,100 @@
+package avail
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/availproject/op-evm/pkg/avail/testutil"
+	"github.com/availproject/op-evm/pkg/block"
+	"github.com/availproject/op-evm/pkg/common"
+	"github.com/availproject/op-evm/pkg/staking"
+	"github.com/availproject/op-evm/pkg/test"
+	avail_types "github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSequencerRotatesSigningKey(t *testing.T) {
+	sw, fraudResolver, _ := newTestSequencerWorker(t, testutil.NewFake(avail_types.NewUCompactFromUInt(1)))
+
+	// The sequencer is staked with its current key, and the next key is
+	// funded for its stake.
+	if err := staking.Stake(sw.blockchain, sw.executor, staking.NewTestAvailSender(), hclog.Default(), string(staking.Sequencer), sw.nodeAddr, sw.nodeSignKey, stakeAmount, 1_000_000, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	nextAddr, nextKey := test.NewAccount(t)
+	test.DepositBalance(t, nextAddr, big.NewInt(0).Mul(big.NewInt(1000), common.ETH), sw.blockchain, sw.executor)
+
+	base := sw.blockchain.Header().Number
+	switchHeight := base + 3
+
+	sw.keys = newKeyRotation(sw.nodeSignKey, nextKey, switchHeight, sw.logger)
+
+	clock, stop := startWriteBlocksLoop(t, sw, fraudResolver)
+	defer stop()
+
+	// The previous key is unstaked once its unstaking transaction, submitted
+	// at the switch, makes it into a block. The switch itself waits for the
+	// staking transaction of the next key to make it into one.
+	produced := uint64(0)
+	for i := uint64(1); i <= 15; i++ {
+		clock.tick()
+		waitForBlock(t, sw, base+i)
+		produced = i
+
+		if i >= 5 && sw.keys.Status().State == KeyRotationDone {
+			break
+		}
+
+		// Both keys and the switch height show during the transition.
+		if i == 2 {
+			status := sw.keys.Status()
+			if assert.NotNil(t, status) {
+				assert.Equal(t, KeyRotationStaking, status.State)
+				assert.Equal(t, sw.nodeAddr, status.CurrentKey)
+				assert.Equal(t, nextAddr, status.NextKey)
+				assert.Equal(t, switchHeight, status.SwitchHeight)
+			}
+		}
+	}
+
+	stop()
+
+	status := sw.keys.Status()
+	if !assert.NotNil(t, status) {
+		return
+	}
+
+	assert.Equal(t, KeyRotationDone, status.State)
+	assert.GreaterOrEqual(t, status.SwitchedAt, switchHeight)
+
+	// Every block is sealed by the key of its height.
+	for n := base + 1; n <= base+produced; n++ {
+		hdr, ok := sw.blockchain.GetHeaderByNumber(n)
+		if !assert.True(t, ok, "block %d", n) {
+			continue
+		}
+
+		signer, err := block.AddressRecoverFromHeader(hdr)
+		assert.NoError(t, err)
+
+		expected := sw.nodeAddr
+		if n >= status.SwitchedAt {
+			expected = nextAddr
+		}
+
+		assert.Equal(t, expected, signer, "block %d", n)
+	}
+
+	// The previous key is unstaked for good.
+	staked, err := sw.apq.Contains(sw.nodeAddr, staking.Sequencer)
+	assert.NoError(t, err)
+	assert.False(t, staked)
+
+	staked, err = sw.apq.Contains(nextAddr, staking.Sequencer)
+	assert.NoError(t, err)
+	assert.True(t, staked)
+
+	assert.Equal(t, nextAddr, sw.keys.Address())
+}
@@ -0,0 +1,112 @@
+package avail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/consensus/avail/watchtower"
+	"github.com/availproject/op-evm/pkg/avail"
+	"github.com/availproject/op-evm/pkg/block"
+	"github.com/availproject/op-evm/pkg/staking"
+	avail_types "github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/hashicorp/go-hclog"
+)
+
+// errLightSyncNoProduction is returned switching the watchtower under the
+// light sync over to the sequencer; it holds no state to produce blocks on.
+var errLightSyncNoProduction = errors.New("block production disabled under the light sync")
+
+// LightSyncConfig is the configuration of the light sync of the watchtowers.
+// Under the light sync, the watchtower follows Avail writing the blocks
+// header-only, tracking the header chain and the active participants, and
+// checks the blocks short of executing them; only a random sample of the
+// blocks, and the ones checked on demand with AdminAPI.CheckBlock, are
+// re-executed, on the state fetched from the state provider as needed. The
+// watchtower under the light sync produces no blocks.
+type LightSyncConfig struct {
+	// StateProvider is the JSON-RPC URL of the full node, or of the snapshot
+	// provider, serving the state by hash; empty disables the light sync.
+	StateProvider string
+
+	// SamplePercent is the percentage of the blocks re-executed at random.
+	SamplePercent uint64
+}
+
+// Enabled reports whether the light sync is configured.
+func (c LightSyncConfig) Enabled() bool {
+	return c.StateProvider != ""
+}
+
+// lightSync draws the blocks of the watchtower under the light sync to
+// re-execute.
+type lightSync struct {
+	samplePercent uint64
+	draw          func() uint64 // Draws a percentile, from 0 to 99
+}
+
+func newLightSync(samplePercent uint64) *lightSync {
+	return &lightSync{
+		samplePercent: samplePercent,
+		draw:          func() uint64 { return uint64(rand.Intn(100)) },
+	}
+}
+
+// sampled reports whether the next block is drawn for the re-execution.
+func (l *lightSync) sampled() bool {
+	return l.draw() < l.samplePercent
+}
+
+// checkWatchedBlock checks the block watched with the watchtower: in full, or
+// under the light sync, short of its execution unless it's drawn for the
+// sample.
+func (d *Avail) checkWatchedBlock(wt watchtower.WatchTower, blk *types.Block) error {
+	if d.light == nil {
+		return wt.Check(blk)
+	}
+
+	if d.light.sampled() {
+		observeLightSyncCheck("full")
+		return wt.Check(blk)
+	}
+
+	observeLightSyncCheck("structure")
+
+	return wt.CheckStructure(blk)
+}
+
+// disputable reports whether the block failing the check of the watchtower
+// with the given error calls for a fraud proof.
+func disputable(blk *types.Block, err error) bool {
+	// TODO: We should implement something like SafeCheck() to not return errors that should not
+	// result in creating fraud proofs for blocks/transactions that should not be checked.
+	if errors.Is(err, staking.ErrNotActiveSequencer) {
+		return false
+	}
+
+	// Skip processing of fraudproof block. It's not written to blockchain on sequencers either.
+	_, isFraudProof := block.GetExtraDataFraudProofTarget(blk.Header)
+
+	return !isFraudProof
+}
+
+// submitFraudProof constructs the fraud proof of the block failing the check
+// of the watchtower, and submits it to Avail. It returns the fraud proof.
+func (d *Avail) submitFraudProof(ctx context.Context, wt watchtower.WatchTower, blk *types.Block, logger hclog.Logger) (*types.Block, error) {
+	fp, err := wt.ConstructFraudproof(blk)
+	if err != nil {
+		return nil, fmt.Errorf("failed to construct fraudproof for block: %w", err)
+	}
+
+	logger.Info("Submitting fraudproof", "block_hash", fp.Header.Hash)
+
+	if _, err := d.availSender.SendAndWaitForStatus(ctx, fp, avail_types.ExtrinsicStatus{IsInBlock: true}, avail.WithTip(d.fraudTip)); err != nil {
+		return nil, fmt.Errorf("submitting fraud proof to avail failed: %w", err)
+	}
+
+	logger.Info("Submitted fraudproof", "block_number", fp.Header.Number, "block_hash", fp.Header.Hash, "txns", len(fp.Transactions))
+
+	return fp, nil
+}
@@ -0,0 +1,177 @@
+package avail
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	itrie "github.com/0xPolygon/polygon-edge/state/immutable-trie"
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/consensus/avail/watchtower"
+	"github.com/availproject/op-evm/pkg/avail"
+	"github.com/availproject/op-evm/pkg/avail/testutil"
+	"github.com/availproject/op-evm/pkg/block"
+	"github.com/availproject/op-evm/pkg/test"
+	avail_types "github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestLightAvail returns the consensus of a watchtower under the light sync,
+// re-executing the given percentage of the blocks on the state fetched from
+// the given full node, along with its local state storage.
+func newTestLightAvail(t *testing.T, fake *testutil.Fake, appID avail_types.UCompact, provider *Avail, samplePercent uint64) (*Avail, itrie.Storage) {
+	t.Helper()
+
+	provider.serveState = true
+
+	srv := rpc.NewServer()
+	t.Cleanup(srv.Stop)
+
+	if err := srv.RegisterName(avail.SettlementNamespace, NewStatusAPI(provider)); err != nil {
+		t.Fatal(err)
+	}
+
+	chain, err := test.NewChain(getGenesisBasePath())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := test.NewMemoryStorage()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	local := itrie.NewMemoryStorage()
+	addr, key := test.NewAccount(t)
+
+	d := newTestGenesisAvailOnState(t, chain, db, newProviderStateStorage(local, rpc.DialInProc(srv), hclog.Default()), addr, key)
+	d.availClient = fake
+	d.availAppID = appID
+	d.nodeType = WatchTower
+	d.light = newLightSync(samplePercent)
+	d.blockchain.SetHeaderOnly(true)
+
+	return d, local
+}
+
+// newTestLightWatchTower returns the watchtower of the light node, funded by
+// the genesis for its fraud proofs.
+func newTestLightWatchTower(d *Avail) watchtower.WatchTower {
+	return watchtower.New(d.blockchain, d.executor, nil, hclog.Default(), test.FaucetAccount, test.FaucetSignKey, 0)
+}
+
+func TestLightSyncSampledCheck(t *testing.T) {
+	appID := avail_types.NewUCompactFromUInt(1)
+	fake := testutil.NewFake(appID)
+
+	producer := newTestGenesisAvail(t)
+	activity := newTestActivity(producer, fake)
+	activity.settle(t, 5)
+
+	light, local := newTestLightAvail(t, fake, appID, producer, 0)
+
+	if _, err := syncTestAvail(light, fake); err != nil {
+		t.Fatal(err)
+	}
+
+	// The light node follows the header chain without executing the blocks.
+	head := producer.blockchain.Header()
+	assert.Equal(t, head.Hash, light.blockchain.Header().Hash)
+
+	_, err := light.blockchain.GetReceiptsByHash(head.Hash)
+	assert.Error(t, err)
+
+	_, ok := local.Get(head.StateRoot.Bytes())
+	assert.False(t, ok, "state of the head held before any check")
+
+	// Out of the sample, the blocks are checked short of their execution.
+	wt := newTestLightWatchTower(light)
+
+	for n := uint64(1); n <= head.Number; n++ {
+		blk, ok := light.blockchain.GetBlockByNumber(n, true)
+		if !assert.True(t, ok) {
+			return
+		}
+
+		assert.NoError(t, light.checkWatchedBlock(wt, blk))
+	}
+
+	// The sampled block is re-executed on the state fetched from the full
+	// node.
+	light.light.draw = func() uint64 { return 0 }
+	light.light.samplePercent = 1
+
+	blk, _ := light.blockchain.GetBlockByNumber(head.Number, true)
+	assert.NoError(t, light.checkWatchedBlock(wt, blk))
+
+	parent, _ := light.blockchain.GetHeaderByHash(blk.ParentHash())
+	_, ok = local.Get(parent.StateRoot.Bytes())
+	assert.True(t, ok, "state of the parent of the sampled block not fetched")
+
+	// The state past the sample is fetched as well, on demand.
+	check, err := NewAdminAPI(light).CheckBlock(context.Background(), rpc.BlockNumberOrHashWithNumber(rpc.BlockNumber(head.Number-1)), nil)
+	if assert.NoError(t, err) {
+		assert.True(t, check.Valid, check.Error)
+		assert.Nil(t, check.FraudProof)
+	}
+
+	assert.Equal(t, activity.storage(t, producer), activity.storage(t, light))
+
+	// The light node produces no blocks.
+	assert.True(t, light.Status().LightSync)
+	assert.True(t, errors.Is(light.SetNodeMode(Sequencer), errLightSyncNoProduction))
+}
+
+func TestLightSyncDetectsBadSignature(t *testing.T) {
+	appID := avail_types.NewUCompactFromUInt(1)
+	fake := testutil.NewFake(appID)
+
+	producer := newTestGenesisAvail(t)
+	activity := newTestActivity(producer, fake)
+	activity.settle(t, 3)
+
+	// The producer includes a transfer of a funded account signed by
+	// another, and settles it on Avail.
+	other, otherKey := test.NewAccount(t)
+	from := activity.funded[0]
+	forged := (&testSender{addr: from, key: otherKey}).sign(t, &types.Transaction{To: &other, Value: big.NewInt(1), Gas: 21_000}, 5000)
+
+	fraudulent := buildTestBlock(t, producer, forged)
+	if !assert.Len(t, fraudulent.Transactions, 1) {
+		return
+	}
+
+	if _, err := fake.SendAndWaitForStatus(producer.ctx, fraudulent, avail_types.ExtrinsicStatus{IsInBlock: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The light node, re-executing none of the blocks, still trips on the
+	// signature.
+	light, _ := newTestLightAvail(t, fake, appID, producer, 0)
+
+	if _, err := syncTestAvail(light, fake); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, fraudulent.Hash(), light.blockchain.Header().Hash)
+
+	wt := newTestLightWatchTower(light)
+
+	err := light.checkWatchedBlock(wt, fraudulent)
+	assert.ErrorIs(t, err, block.ErrInvalidTxSignature)
+	assert.True(t, disputable(fraudulent, err))
+
+	// The fraud proof is built on the state fetched from the full node.
+	fraudProof, err := wt.ConstructFraudproof(fraudulent)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	violation, txIndex, ok := block.GetExtraDataFraudProofViolation(fraudProof.Header)
+	assert.True(t, ok)
+	assert.Equal(t, block.FraudViolationTxSignature, violation)
+	assert.Zero(t, txIndex)
+}
@@ -0,0 +1,450 @@
+package avail
+
+import (
+	"time"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/armon/go-metrics"
+	"github.com/availproject/op-evm/pkg/pruning"
+)
+
+// observeUnfitDisputeTx records a dispute resolution transaction that doesn't
+// fit in a block even on its own.
+func observeUnfitDisputeTx() {
+	metrics.IncrCounter([]string{"avail", "sequencer", "unfit_dispute_txs"}, 1)
+}
+
+// observeDisputeState records the dispute state of the local sequencer; any
+// state but DisputeNone pauses the block production.
+func observeDisputeState(state DisputeState) {
+	metrics.SetGauge([]string{"avail", "sequencer", "dispute_state"}, float32(state))
+}
+
+// observeUnsettledBlocks records the number of produced blocks waiting for
+// their inclusion in Avail to be confirmed.
+func observeUnsettledBlocks(n int) {
+	metrics.SetGauge([]string{"avail", "sequencer", "unsettled_blocks"}, float32(n))
+}
+
+// observeReorg records a reorg of the given depth made by the fork choice.
+func observeReorg(depth uint64) {
+	metrics.IncrCounter([]string{"avail", "fork_choice", "reorgs"}, 1)
+	metrics.AddSample([]string{"avail", "fork_choice", "reorg_depth"}, float32(depth))
+}
+
+// observeRefusedReorg records a reorg the fork choice refused for going
+// deeper than allowed.
+func observeRefusedReorg() {
+	metrics.IncrCounter([]string{"avail", "fork_choice", "refused_reorgs"}, 1)
+}
+
+// observeTxPoolSweep records the transactions a sweep of the txpool dropped
+// for never being able to execute.
+func observeTxPoolSweep(sweep txPoolSweep) {
+	metrics.IncrCounter([]string{"avail", "txpool_sweep", "sweeps"}, 1)
+	metrics.IncrCounter([]string{"avail", "txpool_sweep", "used_nonce_txs"}, float32(sweep.usedNonce))
+	metrics.IncrCounter([]string{"avail", "txpool_sweep", "underfunded_txs"}, float32(sweep.underfunded))
+}
+
+// observePhase records the phase of the consensus of the node, the gauge of
+// the phase entered set and the ones of the others cleared.
+func observePhase(phase Phase) {
+	for _, p := range phases {
+		var v float32
+		if p == phase {
+			v = 1
+		}
+
+		metrics.SetGaugeWithLabels([]string{"avail", "consensus", "phase"}, v, []metrics.Label{{Name: "phase", Value: string(p)}})
+	}
+}
+
+// observeConflict records a block competing with an own block of the node,
+// counted as won or lost once the fork choice decided.
+func observeConflict(c Conflict, self types.Address) {
+	metrics.IncrCounter([]string{"avail", "fork_choice", "conflicts"}, 1)
+
+	if c.Resolution == ConflictDeferred {
+		return
+	}
+
+	if c.WinnerMiner == self {
+		metrics.IncrCounter([]string{"avail", "fork_choice", "conflicts_won"}, 1)
+	} else {
+		metrics.IncrCounter([]string{"avail", "fork_choice", "conflicts_lost"}, 1)
+	}
+}
+
+// observeSettlementLag records the number of blocks the head of the chain is
+// ahead of the blocks settled on Avail, and whether it pauses the block
+// production.
+func observeSettlementLag(lag uint64, paused bool) {
+	var v float32
+	if paused {
+		v = 1
+	}
+
+	metrics.SetGauge([]string{"avail", "sequencer", "settlement_lag"}, float32(lag))
+	metrics.SetGauge([]string{"avail", "sequencer", "settlement_paused"}, v)
+}
+
+// observeCircuitBreaker records whether the circuit breaker halts the
+// consensus, and counts its trips.
+func observeCircuitBreaker(tripped bool) {
+	var v float32
+	if tripped {
+		v = 1
+
+		metrics.IncrCounter([]string{"avail", "circuit_breaker", "trips"}, 1)
+	}
+
+	metrics.SetGauge([]string{"avail", "circuit_breaker", "tripped"}, v)
+}
+
+// observeSettledHead records the number of the settled head of the chain.
+func observeSettledHead(number uint64) {
+	metrics.SetGauge([]string{"avail", "settled_head"}, float32(number))
+}
+
+// observeReplayCheckpoint records the Avail block of the last checkpoint of
+// the replay persisted.
+func observeReplayCheckpoint(availBlock uint64) {
+	metrics.SetGauge([]string{"avail", "replay", "checkpoint"}, float32(availBlock))
+}
+
+// observeReplayCheckpointDiscarded counts the replay checkpoints discarded on
+// the start, not matching the database.
+func observeReplayCheckpointDiscarded() {
+	metrics.IncrCounter([]string{"avail", "replay", "discarded_checkpoints"}, 1)
+}
+
+// observeSettledHeadRewind counts the settled head moving back by the given
+// number of blocks, forked out by a dispute resolution.
+func observeSettledHeadRewind(depth uint64) {
+	metrics.IncrCounter([]string{"avail", "settled_head", "rewinds"}, 1)
+	metrics.IncrCounter([]string{"avail", "settled_head", "rewound_blocks"}, float32(depth))
+}
+
+// observeStartupRewind records the rewind of the local chain diverging from
+// Avail on the start, by its depth.
+func observeStartupRewind(depth uint64) {
+	metrics.IncrCounter([]string{"avail", "startup_check", "rewinds"}, 1)
+	metrics.IncrCounter([]string{"avail", "startup_check", "rewound_blocks"}, float32(depth))
+}
+
+// observeNodeModeSwitch counts the switches of the role of the node, by the
+// role switched to.
+func observeNodeModeSwitch(mode MechanismType) {
+	metrics.IncrCounterWithLabels([]string{"avail", "node_mode", "switches"}, 1, []metrics.Label{{Name: "mode", Value: string(mode)}})
+}
+
+// observeSenderBan records a sender banned from the blocks for its
+// repeatedly failing transactions.
+func observeSenderBan() {
+	metrics.IncrCounter([]string{"avail", "sequencer", "sender_bans"}, 1)
+}
+
+// observeReplayedFraudProof records a fraud proof delivered again after it
+// was processed, ignored.
+func observeReplayedFraudProof() {
+	metrics.IncrCounter([]string{"avail", "fraud", "replayed_fraud_proofs"}, 1)
+}
+
+// observeStaleFraudProof records a fraud proof of a block whose dispute
+// already ended, ignored.
+func observeStaleFraudProof() {
+	metrics.IncrCounter([]string{"avail", "fraud", "stale_fraud_proofs"}, 1)
+}
+
+// observeExpiredAccusation records an accusation expired short of the quorum
+// of watchtowers.
+func observeExpiredAccusation() {
+	metrics.IncrCounter([]string{"avail", "fraud", "expired_accusations"}, 1)
+}
+
+// observeSlash records the outcome of a slash of a disputed sequencer.
+func observeSlash(outcome slashOutcome) {
+	metrics.IncrCounterWithLabels([]string{"avail", "dispute", "slashes"}, 1, []metrics.Label{{Name: "outcome", Value: string(outcome)}})
+}
+
+// observeReward records the outcome of the reward of a slash.
+func observeReward(outcome rewardOutcome) {
+	metrics.IncrCounterWithLabels([]string{"avail", "dispute", "rewards"}, 1, []metrics.Label{{Name: "outcome", Value: string(outcome)}})
+}
+
+// observeDefense records the outcome of a defense of a disputed sequencer.
+func observeDefense(outcome defenseOutcome) {
+	metrics.IncrCounterWithLabels([]string{"avail", "dispute", "defenses"}, 1, []metrics.Label{{Name: "outcome", Value: string(outcome)}})
+}
+
+// observeDisputeEscalation records a dispute escalated, left open past its
+// escalation deadline.
+func observeDisputeEscalation() {
+	metrics.IncrCounter([]string{"avail", "dispute", "escalations"}, 1)
+}
+
+// observeEscalatedDisputes records the number of the escalated disputes open.
+func observeEscalatedDisputes(n int) {
+	metrics.SetGauge([]string{"avail", "dispute", "escalated"}, float32(n))
+}
+
+// observeLightSyncCheck counts the checks of the blocks watched under the
+// light sync, by kind: "structure" for the ones checked short of their
+// execution, "full" for the ones re-executed.
+func observeLightSyncCheck(kind string) {
+	metrics.IncrCounterWithLabels([]string{"avail", "light_sync", "checks"}, 1, []metrics.Label{{Name: "kind", Value: kind}})
+}
+
+// observeStateFetch counts the trie nodes and the code fetched from the state
+// provider under the light sync, and the fetches failed.
+func observeStateFetch(ok bool) {
+	if !ok {
+		metrics.IncrCounter([]string{"avail", "light_sync", "failed_state_fetches"}, 1)
+		return
+	}
+
+	metrics.IncrCounter([]string{"avail", "light_sync", "state_fetches"}, 1)
+}
+
+// observeStatePrune records a prune of the state storage: the trie nodes
+// deleted, and the ones retained.
+func observeStatePrune(res pruning.PruneResult) {
+	metrics.IncrCounter([]string{"avail", "state_pruning", "pruned_nodes"}, float32(res.Pruned))
+	metrics.SetGauge([]string{"avail", "state_pruning", "retained_nodes"}, float32(res.Retained))
+}
+
+// observeScheduledSnapshot records a chain snapshot taken on the schedule at
+// the given block, or the failure to take it.
+func observeScheduledSnapshot(number uint64, ok bool) {
+	if !ok {
+		metrics.IncrCounter([]string{"avail", "snapshots", "failed"}, 1)
+		return
+	}
+
+	metrics.IncrCounter([]string{"avail", "snapshots", "taken"}, 1)
+	metrics.SetGauge([]string{"avail", "snapshots", "latest"}, float32(number))
+}
+
+// observeIntegrityProgress records the entries checked by the running
+// integrity check, and the issues found.
+func observeIntegrityProgress(p IntegrityProgress) {
+	metrics.SetGauge([]string{"avail", "integrity", "checked_entries"}, float32(p.Headers+p.TrieNodes+p.Codes))
+	metrics.SetGauge([]string{"avail", "integrity", "issues"}, float32(p.Issues))
+}
+
+// observeIntegrityCheck records an integrity check complete: the issues found,
+// and the ones repaired.
+func observeIntegrityCheck(report *IntegrityReport) {
+	metrics.IncrCounter([]string{"avail", "integrity", "checks"}, 1)
+	metrics.SetGauge([]string{"avail", "integrity", "checked_entries"}, float32(report.Headers+report.TrieNodes+report.Codes))
+	metrics.SetGauge([]string{"avail", "integrity", "issues"}, float32(uint64(len(report.Issues))+report.OmittedIssues))
+	metrics.IncrCounter([]string{"avail", "integrity", "repaired"}, float32(report.Repaired))
+}
+
+// observeHealth records the state of the health checks of the node: 0 for
+// healthy, 1 for degraded and 2 for unhealthy.
+func observeHealth(health *Health) {
+	metrics.SetGauge([]string{"avail", "health"}, float32(healthSeverity[health.State]))
+
+	for _, c := range health.Checks {
+		metrics.SetGaugeWithLabels([]string{"avail", "health", "check"}, float32(healthSeverity[c.State]), []metrics.Label{{Name: "check", Value: c.Name}})
+	}
+}
+
+// sequencerMetrics is the metrics of the block production of the sequencer,
+// on the metrics registry of the node, labeled with the role and the account
+// of the node. The nil sequencerMetrics records nothing.
+type sequencerMetrics struct {
+	registry *metrics.Metrics
+	labels   []metrics.Label
+}
+
+// newSequencerMetrics returns the sequencerMetrics of the node of the given
+// role and account on the registry.
+func newSequencerMetrics(registry *metrics.Metrics, role MechanismType, account types.Address) *sequencerMetrics {
+	return &sequencerMetrics{
+		registry: registry,
+		labels: []metrics.Label{
+			{Name: "role", Value: string(role)},
+			{Name: "account", Value: account.String()},
+		},
+	}
+}
+
+// producedSlot counts the slot the sequencer produced its block with the
+// given number of transactions in.
+func (m *sequencerMetrics) producedSlot(txs int) {
+	if m == nil {
+		return
+	}
+
+	m.registry.IncrCounterWithLabels([]string{"avail", "production", "produced_slots"}, 1, m.labels)
+	m.registry.IncrCounterWithLabels([]string{"avail", "production", "included_txs"}, float32(txs), m.labels)
+	m.registry.AddSampleWithLabels([]string{"avail", "production", "txs_per_block"}, float32(txs), m.labels)
+}
+
+// missedSlot counts the slot the sequencer led without producing its block.
+func (m *sequencerMetrics) missedSlot() {
+	if m == nil {
+		return
+	}
+
+	m.registry.IncrCounterWithLabels([]string{"avail", "production", "missed_slots"}, 1, m.labels)
+}
+
+// buildLatency records the time the block took to build, from the given
+// start until sealed.
+func (m *sequencerMetrics) buildLatency(start time.Time) {
+	if m == nil {
+		return
+	}
+
+	m.registry.MeasureSinceWithLabels([]string{"avail", "production", "build_latency"}, start, m.labels)
+}
+
+// settleLatency records the time the block took to settle on Avail, from the
+// given start of its submission until included in an Avail block.
+func (m *sequencerMetrics) settleLatency(start time.Time) {
+	if m == nil {
+		return
+	}
+
+	m.registry.MeasureSinceWithLabels([]string{"avail", "production", "settle_latency"}, start, m.labels)
+}
+
+// txPoolDepth records the number of the transactions in the txpool as the
+// ones of the block are selected.
+func (m *sequencerMetrics) txPoolDepth(n uint64) {
+	if m == nil {
+		return
+	}
+
+	m.registry.SetGaugeWithLabels([]string{"avail", "production", "txpool_depth"}, float32(n), m.labels)
+}
+
+// settlementLag records the number of blocks the head of the chain is ahead
+// of the blocks settled on Avail.
+func (m *sequencerMetrics) settlementLag(lag uint64) {
+	if m == nil {
+		return
+	}
+
+	m.registry.SetGaugeWithLabels([]string{"avail", "production", "settlement_lag"}, float32(lag), m.labels)
+}
+
+// unknownAccused is the accused label of the fraud metrics of a block not
+// known to the chain.
+const unknownAccused = "unknown"
+
+// fraudMetrics is the metrics of the fraud pipeline, from the fraud proofs
+// seen on Avail to the disputes they raise, the slashes ending them and the
+// fraudulent blocks rolled back, on the metrics registry of the node. The
+// metrics of an accusation are labeled with the miner of the accused block,
+// as known to the chain, keeping their cardinality to the sequencers; the
+// ones of a block not known are labeled unknownAccused. The nil fraudMetrics
+// records nothing.
+type fraudMetrics struct {
+	registry *metrics.Metrics
+}
+
+// newFraudMetrics returns the fraudMetrics on the registry.
+func newFraudMetrics(registry *metrics.Metrics) *fraudMetrics {
+	return &fraudMetrics{registry: registry}
+}
+
+// accusedLabel returns the label of the given accused sequencer, the zero
+// address for unknown.
+func accusedLabel(accused types.Address) metrics.Label {
+	if accused == types.ZeroAddress {
+		return metrics.Label{Name: "accused", Value: unknownAccused}
+	}
+
+	return metrics.Label{Name: "accused", Value: accused.String()}
+}
+
+// fraudProofObserved counts a fraud proof seen on Avail, to be verified.
+func (m *fraudMetrics) fraudProofObserved(accused types.Address) {
+	if m == nil {
+		return
+	}
+
+	m.registry.IncrCounterWithLabels([]string{"avail", "fraud", "fraud_proofs"}, 1, []metrics.Label{accusedLabel(accused)})
+}
+
+// fraudProofVerified counts a fraud proof whose accusation verified.
+func (m *fraudMetrics) fraudProofVerified(accused types.Address) {
+	if m == nil {
+		return
+	}
+
+	m.registry.IncrCounterWithLabels([]string{"avail", "fraud", "verified_fraud_proofs"}, 1, []metrics.Label{accusedLabel(accused)})
+}
+
+// fraudProofRejected counts a fraud proof rejected for its accusation not
+// verifying.
+func (m *fraudMetrics) fraudProofRejected(accused types.Address) {
+	if m == nil {
+		return
+	}
+
+	m.registry.IncrCounterWithLabels([]string{"avail", "fraud", "rejected_fraud_proofs"}, 1, []metrics.Label{accusedLabel(accused)})
+}
+
+// disputeOpened counts a dispute begun against the sequencer.
+func (m *fraudMetrics) disputeOpened(sequencer types.Address) {
+	if m == nil {
+		return
+	}
+
+	m.registry.IncrCounterWithLabels([]string{"avail", "dispute", "opened"}, 1, []metrics.Label{accusedLabel(sequencer)})
+}
+
+// disputeResolved counts a dispute of the sequencer ended, by its outcome.
+func (m *fraudMetrics) disputeResolved(sequencer types.Address, outcome FraudOutcome) {
+	if m == nil {
+		return
+	}
+
+	m.registry.IncrCounterWithLabels([]string{"avail", "dispute", "resolved"}, 1, []metrics.Label{accusedLabel(sequencer), {Name: "outcome", Value: string(outcome)}})
+}
+
+// slashExecuted counts a slash of the staker that took its stake.
+func (m *fraudMetrics) slashExecuted(staker types.Address) {
+	if m == nil {
+		return
+	}
+
+	m.registry.IncrCounterWithLabels([]string{"avail", "dispute", "executed_slashes"}, 1, []metrics.Label{{Name: "staker", Value: staker.String()}})
+}
+
+// reorg records the block of the accused sequencer proven fraudulent, and its
+// descendants, rolled out of the chain by a dispute resolution.
+func (m *fraudMetrics) reorg(accused types.Address, depth uint64) {
+	if m == nil {
+		return
+	}
+
+	labels := []metrics.Label{accusedLabel(accused)}
+
+	m.registry.IncrCounterWithLabels([]string{"avail", "fraud", "reorgs"}, 1, labels)
+	m.registry.AddSampleWithLabels([]string{"avail", "fraud", "reorg_depth"}, float32(depth), labels)
+}
+
+// openDisputes records the number of the disputes open.
+func (m *fraudMetrics) openDisputes(n int) {
+	if m == nil {
+		return
+	}
+
+	m.registry.SetGauge([]string{"avail", "dispute", "open"}, float32(n))
+}
+
+// taintedBlocks records the number of the blocks targeted by the fraud proofs
+// within the window of the circuit breaker.
+func (m *fraudMetrics) taintedBlocks(n int) {
+	if m == nil {
+		return
+	}
+
+	m.registry.SetGauge([]string{"avail", "fraud", "tainted_blocks"}, float32(n))
+}
@@ -0,0 +1,217 @@
+package avail
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/armon/go-metrics"
+	"github.com/availproject/op-evm/consensus/avail/watchtower"
+	"github.com/availproject/op-evm/pkg/avail"
+	"github.com/availproject/op-evm/pkg/avail/testutil"
+	"github.com/availproject/op-evm/pkg/block"
+	"github.com/availproject/op-evm/pkg/test"
+	avail_types "github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestRegistry returns the metrics registry recording into the returned
+// in-memory sink.
+func newTestRegistry(t *testing.T) (*metrics.Metrics, *metrics.InmemSink) {
+	t.Helper()
+
+	sink := metrics.NewInmemSink(time.Hour, time.Hour)
+
+	conf := metrics.DefaultConfig("test")
+	conf.EnableHostname = false
+	conf.EnableRuntimeMetrics = false
+
+	registry, err := metrics.New(conf, sink)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return registry, sink
+}
+
+// testMetric is the recorded value of a metric.
+type testMetric struct {
+	count int     // Values recorded, for the counters and the samples
+	sum   float64 // Sum of the values, or the value of the gauge
+}
+
+// metricOf returns the metric of the given key, its name and labels as
+// flattened by the in-memory sink, and whether it was recorded.
+func metricOf(sink *metrics.InmemSink, key string) (testMetric, bool) {
+	var (
+		m  testMetric
+		ok bool
+	)
+
+	for _, interval := range sink.Data() {
+		interval.RLock()
+
+		if c, found := interval.Counters[key]; found {
+			m.count, m.sum, ok = m.count+c.Count, m.sum+c.Sum, true
+		}
+
+		if s, found := interval.Samples[key]; found {
+			m.count, m.sum, ok = m.count+s.Count, m.sum+s.Sum, true
+		}
+
+		if g, found := interval.Gauges[key]; found {
+			m.sum, ok = float64(g.Value), true
+		}
+
+		interval.RUnlock()
+	}
+
+	return m, ok
+}
+
+func TestSequencerProductionMetrics(t *testing.T) {
+	fake := testutil.NewFake(avail_types.NewUCompactFromUInt(1))
+	sw, fraudResolver, _ := newTestSequencerWorker(t, fake)
+
+	registry, sink := newTestRegistry(t)
+	sw.metrics = newSequencerMetrics(registry, sw.nodeType, sw.nodeAddr)
+
+	key := func(name string) string {
+		return fmt.Sprintf("test.avail.production.%s;role=%s;account=%s", name, sw.nodeType, sw.nodeAddr)
+	}
+
+	sender := newTestSender(t, sw)
+	parent := sw.blockchain.Header().Number
+
+	clock, stop := startWriteBlocksLoop(t, sw, fraudResolver)
+	defer stop()
+
+	// The slot produces the block of the transactions pending.
+	for _, price := range []int64{1, 1} {
+		assert.NoError(t, sw.txpool.AddTx(sender.transfer(t, price)))
+	}
+
+	assert.Eventually(t, func() bool { return sw.txpool.Length() == 2 }, 5*time.Second, 10*time.Millisecond)
+
+	clock.tick()
+	waitForBlock(t, sw, parent+1)
+
+	assert.Eventually(t, func() bool {
+		m, ok := metricOf(sink, key("produced_slots"))
+		return ok && m.sum == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	included, _ := metricOf(sink, key("included_txs"))
+	assert.Equal(t, float64(2), included.sum)
+
+	perBlock, _ := metricOf(sink, key("txs_per_block"))
+	assert.Equal(t, 1, perBlock.count)
+
+	depth, ok := metricOf(sink, key("txpool_depth"))
+	assert.True(t, ok)
+	assert.Equal(t, float64(2), depth.sum)
+
+	for _, name := range []string{"build_latency", "settle_latency"} {
+		latency, _ := metricOf(sink, key(name))
+		assert.Equal(t, 1, latency.count, name)
+	}
+
+	_, ok = metricOf(sink, key("settlement_lag"))
+	assert.True(t, ok)
+
+	// The submission failing, the slot is missed.
+	fake.DropSubmissions(1)
+	assert.NoError(t, sw.txpool.AddTx(sender.transfer(t, 1)))
+	assert.Eventually(t, func() bool { return sw.txpool.Length() == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	clock.tick()
+
+	assert.Eventually(t, func() bool {
+		m, ok := metricOf(sink, key("missed_slots"))
+		return ok && m.sum == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	produced, _ := metricOf(sink, key("produced_slots"))
+	assert.Equal(t, float64(1), produced.sum)
+}
+
+func TestFraudPipelineMetrics(t *testing.T) {
+	sw, fraudResolver, _ := newTestSequencerWorker(t, testutil.NewFake(avail_types.NewUCompactFromUInt(1)))
+
+	registry, sink := newTestRegistry(t)
+	m := newFraudMetrics(registry)
+	fraudResolver.metrics = m
+
+	counter := func(name, accused string) float64 {
+		c, _ := metricOf(sink, fmt.Sprintf("test.avail.fraud.%s;accused=%s", name, accused))
+		return c.sum
+	}
+
+	// Another watchtower accuses the block, once found fraudulent.
+	otherAddr, otherKey := test.NewAccount(t)
+	test.DepositBalance(t, otherAddr, big.NewInt(0).Mul(big.NewInt(1000), big.NewInt(1e18)), sw.blockchain, sw.executor)
+
+	accused, fraudProof, watchtowerAddr := newTestFraudProof(t, sw, fraudResolver)
+	miner := types.BytesToAddress(accused.Header.Miner).String()
+
+	assert.Eventually(t, func() bool {
+		promoted, _ := sw.txpool.GetTxs(false)
+		return len(promoted[watchtowerAddr]) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// The bogus fraud proof of the valid block is rejected, and so is the one
+	// of a block not known to the chain.
+	assert.False(t, fraudResolver.CheckAndSetFraudBlock(1, []avail.EdgeBlock{{Block: fraudProof}}))
+
+	unknown := &types.Block{Header: &types.Header{
+		Miner:     watchtowerAddr.Bytes(),
+		ExtraData: block.EncodeExtraDataFields(map[string][]byte{block.KeyFraudProofOf: types.StringToHash("1").Bytes()}),
+	}}
+	assert.False(t, fraudResolver.CheckAndSetFraudBlock(1, []avail.EdgeBlock{{Block: unknown}}))
+
+	assert.Equal(t, float64(1), counter("fraud_proofs", miner))
+	assert.Equal(t, float64(1), counter("rejected_fraud_proofs", miner))
+	assert.Equal(t, float64(1), counter("fraud_proofs", unknownAccused))
+	assert.Equal(t, float64(1), counter("rejected_fraud_proofs", unknownAccused))
+	assert.Zero(t, counter("verified_fraud_proofs", miner))
+
+	// The fraud proof of another watchtower, of the block found fraudulent,
+	// is verified.
+	verified, err := watchtower.New(sw.blockchain, sw.executor, sw.txpool, hclog.Default(), otherAddr, otherKey, 0).ConstructFraudproof(accused)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fraudResolver.watchtower = testFraudulentBlocks{}
+
+	assert.True(t, fraudResolver.CheckAndSetFraudBlock(2, []avail.EdgeBlock{{Block: verified}}))
+
+	assert.Equal(t, float64(2), counter("fraud_proofs", miner))
+	assert.Equal(t, float64(1), counter("verified_fraud_proofs", miner))
+	assert.Equal(t, float64(1), counter("rejected_fraud_proofs", miner))
+
+	// The fraud proofs taint the accused block within the window of the
+	// circuit breaker.
+	breaker := newCircuitBreaker(CircuitBreakerConfig{Window: 10}, "", systemClock{}, hclog.NewNullLogger())
+	breaker.metrics = m
+	breaker.observe(2, []avail.EdgeBlock{{Block: fraudProof}, {Block: verified}})
+
+	tainted, _ := metricOf(sink, "test.avail.fraud.tainted_blocks")
+	assert.Equal(t, float64(1), tainted.sum)
+
+	// The fraudulent block is rolled back.
+	depth, err := rollBackFraudulentBlocks(sw.blockchain, accused.ParentHash(), "test", nil, m, hclog.NewNullLogger())
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, uint64(1), depth)
+	assert.Equal(t, float64(1), counter("reorgs", miner))
+
+	sample, _ := metricOf(sink, fmt.Sprintf("test.avail.fraud.reorg_depth;accused=%s", miner))
+	assert.Equal(t, 1, sample.count)
+	assert.Equal(t, float64(1), sample.sum)
+}
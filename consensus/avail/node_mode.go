@@ -0,0 +1,193 @@
+package avail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/availproject/op-evm/pkg/staking"
+)
+
+var (
+	// errInvalidNodeMode is returned switching the node over to a role other
+	// than the sequencer or the watchtower.
+	errInvalidNodeMode = errors.New("invalid node mode")
+
+	// errRoleNotRunning is returned switching the role of the node before
+	// the role has started, or once the node is closing.
+	errRoleNotRunning = errors.New("node role not running")
+
+	// errNodeInDispute is returned switching the role of the node involved
+	// in an active dispute.
+	errNodeInDispute = errors.New("node involved in an active dispute")
+)
+
+// roleRun is the run of the loops of the role of the node: the block
+// production of the sequencer or the checks of the watchtower. It's stopped
+// apart from the consensus when the node switches its role.
+type roleRun struct {
+	ctx      context.Context
+	shutdown *gracefulShutdown
+	done     chan struct{} // Closed once the loops of the role return
+}
+
+// end marks the loops of the role returned.
+func (r *roleRun) end() {
+	close(r.done)
+}
+
+// beginRole starts the run of the role of the node, on a run context and a
+// shutdown of its own.
+func (d *Avail) beginRole() *roleRun {
+	ctx, cancel := context.WithCancel(d.ctx)
+
+	role := &roleRun{
+		ctx:      ctx,
+		shutdown: newGracefulShutdown(make(chan struct{}), cancel, d.shutdown.timeout),
+		done:     make(chan struct{}),
+	}
+
+	d.roleLock.Lock()
+	d.role = role
+	d.roleLock.Unlock()
+
+	return role
+}
+
+// NodeMode returns the role the node runs as.
+func (d *Avail) NodeMode() MechanismType {
+	d.roleLock.Lock()
+	defer d.roleLock.Unlock()
+
+	return d.nodeType
+}
+
+func (d *Avail) setNodeType(nodeType MechanismType) {
+	d.roleLock.Lock()
+	defer d.roleLock.Unlock()
+
+	d.nodeType = nodeType
+}
+
+// SetNodeMode switches the node over to the given role, the sequencer or the
+// watchtower, without restarting it: the loops of the current role are
+// stopped, unstaking the node, the node is staked for the new role, and the
+// loops of the new role are started over the same chain, following Avail on
+// from where the previous role left off. The switch is refused while the node
+// is involved in an active dispute; switching to the current role is a no-op.
+// The watchtower under the light sync is refused the switch to the sequencer.
+//
+// Once the loops of the current role are stopped, the new role is started in
+// any case: should the staking fail, the new role stakes the node on its own,
+// as on the start.
+func (d *Avail) SetNodeMode(mode MechanismType) error {
+	if mode != Sequencer && mode != WatchTower {
+		return fmt.Errorf("%w: %q", errInvalidNodeMode, mode)
+	}
+
+	if d.light != nil && mode == Sequencer {
+		return errLightSyncNoProduction
+	}
+
+	d.switchLock.Lock()
+	defer d.switchLock.Unlock()
+
+	current := d.NodeMode()
+	if current == mode || (current == BootstrapSequencer && mode == Sequencer) {
+		return nil
+	}
+
+	d.roleLock.Lock()
+	role := d.role
+	d.roleLock.Unlock()
+
+	if role == nil || d.ctx.Err() != nil {
+		return errRoleNotRunning
+	}
+
+	if err := d.checkNotInDispute(); err != nil {
+		return err
+	}
+
+	d.logger.Info("switching the node role", "from", current, "to", mode)
+
+	// The loops of the role unstake the node on their way out.
+	role.shutdown.run(d.logger)
+	<-role.done
+
+	_ = d.phases.enter(PhaseSwitchingRole)
+
+	d.setNodeType(mode)
+	d.stakingNode = staking.NewNode(d.blockchain, d.executor, stakingSender{d.availSender}, d.logger, staking.NodeType(mode))
+
+	if d.stakingNode.ShouldStake(d.signKey) {
+		if err := d.stakingNode.Stake(stakeAmount, d.signKey); err != nil {
+			d.logger.Error("failed to stake the node for its new role; left to the role", "node_type", mode, "error", err)
+		}
+	}
+
+	if cursor := d.forkChoice.availCursor(); cursor > 0 {
+		d.currentNodeSyncIndex = cursor
+	}
+
+	_ = d.phases.enter(PhaseSyncing)
+
+	switch mode {
+	case Sequencer:
+		go d.startSequencer()
+
+	case WatchTower:
+		go d.startWatchTower()
+	}
+
+	observeNodeModeSwitch(mode)
+
+	d.logger.Info("switched the node role", "node_type", mode, "avail_cursor", d.currentNodeSyncIndex)
+
+	return nil
+}
+
+// checkNotInDispute returns errNodeInDispute if the node is involved in an
+// active dispute, as the sequencer disputed or as the watchtower that raised
+// the dispute.
+func (d *Avail) checkNotInDispute() error {
+	if d.disputes != nil && d.disputes.State() == DisputeActive {
+		return errNodeInDispute
+	}
+
+	dr := staking.NewDisputeResolution(d.blockchain, d.executor, nil, d.logger)
+
+	for _, nodeType := range []staking.NodeType{staking.Sequencer, staking.WatchTower} {
+		involved, err := dr.Contains(d.minerAddr, nodeType)
+		if err != nil {
+			return fmt.Errorf("failed to check the disputes of the node: %w", err)
+		}
+
+		if involved {
+			return errNodeInDispute
+		}
+	}
+
+	return nil
+}
+
+// NodeModeAPI switches the role of the node over JSON-RPC; like the
+// AdminAPI, it's served under the admin namespace, to the operator only.
+type NodeModeAPI struct {
+	d *Avail
+}
+
+// NewNodeModeAPI returns the NodeModeAPI of the consensus.
+func NewNodeModeAPI(d *Avail) *NodeModeAPI {
+	return &NodeModeAPI{d: d}
+}
+
+// SetNodeMode switches the node over to the given role, `sequencer` or
+// `watchtower`, and returns the role it runs as.
+func (api *NodeModeAPI) SetNodeMode(mode string) (string, error) {
+	if err := api.d.SetNodeMode(MechanismType(mode)); err != nil {
+		return "", err
+	}
+
+	return string(api.d.NodeMode()), nil
+}
@@ -0,0 +1,175 @@
+package avail
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/availproject/op-evm/consensus/avail/validator"
+	"github.com/availproject/op-evm/pkg/avail"
+	"github.com/availproject/op-evm/pkg/avail/testutil"
+	"github.com/availproject/op-evm/pkg/block"
+	"github.com/availproject/op-evm/pkg/common"
+	"github.com/availproject/op-evm/pkg/staking"
+	"github.com/availproject/op-evm/pkg/test"
+	avail_types "github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestRoleAvail returns the consensus of a node of the given type, staked
+// for its role, running its role over the fake Avail as on the start.
+func newTestRoleAvail(t *testing.T, nodeType MechanismType) (*Avail, staking.ActiveParticipants, *testutil.Fake) {
+	t.Helper()
+
+	appID := avail_types.NewUCompactFromUInt(1)
+	fake := testutil.NewFake(appID)
+
+	d, apq := NewTestAvail(t, nodeType)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	d.ctx = ctx
+	d.shutdown = newGracefulShutdown(d.closeCh, cancel, DefaultShutdownTimeout)
+	d.availClient = fake
+	d.availAppID = appID
+	d.availSender = fake
+	d.stakingNode = staking.NewNode(d.blockchain, d.executor, stakingSender{fake}, d.logger, staking.NodeType(nodeType))
+	d.snapshotter = testSnapshotter{}
+	d.snapshotDistributor = &testDistributor{}
+	d.production = DefaultProductionConfig()
+	d.production.SlotStallTimeout = 0
+	d.catchUp = DefaultCatchUpConfig()
+	d.progress = new(syncProgress)
+	d.disputes = newDisputeGuard(d.minerAddr, apq, d.logger)
+	d.unsettled = newUnsettledQueue("")
+	d.settlement = newSettlementLag(DefaultMaxSettlementLag, d.logger)
+	d.readiness = newReadiness()
+	d.balanceMonitor = avail.NewBalanceMonitor(func(context.Context) (*big.Int, error) {
+		return big.NewInt(0).Mul(big.NewInt(1000), big.NewInt(avail.AVL)), nil
+	}, avail.DefaultBalanceMonitorConfig(), d.logger)
+
+	t.Cleanup(func() { _ = d.Close() })
+
+	// Avail carries the whole chain, as if it was synced from it.
+	for n := uint64(1); n <= d.blockchain.Header().Number; n++ {
+		blk, ok := d.blockchain.GetBlockByNumber(n, true)
+		if !ok {
+			t.Fatalf("block %d not found", n)
+		}
+
+		if _, err := fake.SendAndWaitForStatus(ctx, blk, avail_types.ExtrinsicStatus{IsInBlock: true}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := d.stakingNode.Stake(stakeAmount, d.signKey); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NoError(t, d.phases.enter(PhaseSyncing))
+
+	switch nodeType {
+	case Sequencer:
+		go d.startSequencer()
+	case WatchTower:
+		go d.startWatchTower()
+	}
+
+	assert.Eventually(t, func() bool {
+		d.roleLock.Lock()
+		defer d.roleLock.Unlock()
+
+		return d.role != nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	return d, apq, fake
+}
+
+func TestNodeModeSwitchWatchTowerToSequencer(t *testing.T) {
+	d, apq, fake := newTestRoleAvail(t, WatchTower)
+
+	assert.Eventually(t, func() bool { return d.Status().Phase == string(PhaseActive) }, 5*time.Second, 10*time.Millisecond)
+
+	srv := rpc.NewServer()
+	if err := srv.RegisterName(AdminNamespace, NewNodeModeAPI(d)); err != nil {
+		t.Fatal(err)
+	}
+
+	c := rpc.DialInProc(srv)
+	defer c.Close()
+
+	var mode string
+	if err := c.Call(&mode, "availAdmin_setNodeMode", "sequencer"); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, string(Sequencer), mode)
+	assert.Equal(t, string(Sequencer), d.Status().NodeType)
+
+	// The node is staked for its new role only.
+	staked, err := apq.Contains(d.minerAddr, staking.Sequencer)
+	assert.NoError(t, err)
+	assert.True(t, staked)
+
+	staked, err = apq.Contains(d.minerAddr, staking.WatchTower)
+	assert.NoError(t, err)
+	assert.False(t, staked)
+
+	// It goes on producing blocks, settled on Avail, as Avail moves on.
+	base := d.blockchain.Header().Number
+
+	assert.Eventually(t, func() bool {
+		fake.Produce()
+		return d.blockchain.Header().Number > base
+	}, 15*time.Second, 100*time.Millisecond)
+
+	produced, ok := d.blockchain.GetBlockByNumber(base+1, true)
+	if !assert.True(t, ok) {
+		return
+	}
+
+	signer, err := block.AddressRecoverFromHeader(produced.Header)
+	assert.NoError(t, err)
+	assert.Equal(t, d.minerAddr, signer)
+
+	blks, err := fake.EdgeBlocks()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	submitted := false
+	for _, blk := range blks {
+		submitted = submitted || blk.Hash() == produced.Hash()
+	}
+
+	assert.True(t, submitted, "block %d not submitted to Avail", produced.Number())
+
+	assert.NoError(t, validator.New(d.blockchain, d.minerAddr, d.logger).Check(produced))
+}
+
+func TestNodeModeSwitchRefusedInDispute(t *testing.T) {
+	d, _, _ := newTestRoleAvail(t, Sequencer)
+
+	// A watchtower opens a dispute against the sequencer.
+	watchtowerAddr, watchtowerKey := test.NewAccount(t)
+	test.DepositBalance(t, watchtowerAddr, big.NewInt(0).Mul(big.NewInt(1000), common.ETH), d.blockchain, d.executor)
+
+	sender := staking.NewTestAvailSender()
+	if err := staking.Stake(d.blockchain, d.executor, sender, hclog.Default(), string(staking.WatchTower), watchtowerAddr, watchtowerKey, stakeAmount, 1_000_000, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	dr := staking.NewDisputeResolution(d.blockchain, d.executor, sender, hclog.Default())
+	if err := dr.Begin(d.minerAddr, watchtowerKey); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.ErrorIs(t, d.SetNodeMode(WatchTower), errNodeInDispute)
+	assert.Equal(t, Sequencer, d.NodeMode())
+
+	// Neither is a role other than the sequencer and the watchtower.
+	assert.ErrorIs(t, d.SetNodeMode(BootstrapSequencer), errInvalidNodeMode)
+}
@@ -0,0 +1,220 @@
+package avail
+
+import (
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+// NodeReport is the condition of the node as one document, as returned by
+// `avail_nodeStatus`. The sections of the components disabled for the role
+// of the node, or for the node altogether, are omitted.
+type NodeReport struct {
+	Role string `json:"role"`
+
+	Consensus ConsensusReport `json:"consensus"`
+	Chain     ChainReport     `json:"chain"`
+
+	Avail       *AvailReport       `json:"avail,omitempty"`
+	TxPool      *TxPoolReport      `json:"txpool,omitempty"`
+	Production  *ProductionReport  `json:"production,omitempty"`
+	Disputes    *DisputesReport    `json:"disputes,omitempty"`
+	FraudProofs *FraudProofsReport `json:"fraudProofs,omitempty"`
+	Balances    *BalancesReport    `json:"balances,omitempty"`
+}
+
+// ConsensusReport is the phase of the consensus of the node, entered at
+// PhaseSince, along with the trip of the circuit breaker halting it, if any.
+type ConsensusReport struct {
+	Phase          string              `json:"phase"`
+	PhaseSince     time.Time           `json:"phaseSince"`
+	CircuitBreaker *CircuitBreakerTrip `json:"circuitBreaker,omitempty"`
+}
+
+// ChainReport is the local head of the chain and the settled head.
+type ChainReport struct {
+	Head        uint64      `json:"head"`
+	HeadHash    types.Hash  `json:"headHash"`
+	SettledHead SettledHead `json:"settledHead"`
+}
+
+// AvailReport is the progress of the node following Avail: the Avail block
+// at the cursor, and the Lag of it behind the Avail head.
+type AvailReport struct {
+	Cursor     uint64 `json:"cursor"`
+	Head       uint64 `json:"head"`
+	Lag        uint64 `json:"lag"`
+	CatchingUp bool   `json:"catchingUp"`
+}
+
+// TxPoolReport is the depth of the transaction pool.
+type TxPoolReport struct {
+	Pending uint64 `json:"pending"`
+}
+
+// ProductionReport is the state of the block production of the sequencer.
+type ProductionReport struct {
+	Paused          bool   `json:"paused"`
+	Ready           bool   `json:"ready"`
+	ReadinessReason string `json:"readinessReason,omitempty"`
+
+	// UnsettledBlocks is the number of produced blocks waiting for their
+	// inclusion in Avail to be confirmed, and SettlementLag the number of
+	// blocks the head was ahead of the highest one settled when last
+	// observed.
+	UnsettledBlocks  int    `json:"unsettledBlocks"`
+	SettlementLag    uint64 `json:"settlementLag"`
+	SettlementPaused bool   `json:"settlementPaused"`
+}
+
+// DisputesReport is the disputes open on the staking contract, the oldest
+// first; State is the one of the disputes against the sequencer itself.
+type DisputesReport struct {
+	State string          `json:"state,omitempty"`
+	Open  []DisputeReport `json:"open"`
+}
+
+// DisputeReport is a dispute open on the staking contract, begun in the block
+// BeganAt, escalated at EscalatedAt if it did.
+type DisputeReport struct {
+	Sequencer   types.Address `json:"sequencer"`
+	Watchtower  types.Address `json:"watchtower"`
+	BeganAt     uint64        `json:"beganAt"`
+	EscalatedAt uint64        `json:"escalatedAt,omitempty"`
+}
+
+// FraudProofsReport is the fraud proofs of the watchtower whose disputes are
+// unresolved, the oldest first.
+type FraudProofsReport struct {
+	Pending []FraudEvent `json:"pending"`
+}
+
+// BalancesReport is the balances of the account of the node, on the chain
+// and on Avail; the Avail one, and the submissions it pays for, once
+// polled.
+type BalancesReport struct {
+	Account              types.Address `json:"account"`
+	EVM                  *big.Int      `json:"evm,omitempty"`
+	Avail                *big.Int      `json:"avail,omitempty"`
+	SubmissionsRemaining *uint64       `json:"submissionsRemaining,omitempty"`
+}
+
+// NodeStatus returns the condition of the node as one document, gathered
+// from the status of its components.
+func (api *StatusAPI) NodeStatus() (*NodeReport, error) {
+	return api.d.Report(), nil
+}
+
+// Report returns the condition of the node as one document, gathered from
+// the status of its components.
+func (d *Avail) Report() *NodeReport {
+	role := d.NodeMode()
+	status := d.Status()
+
+	report := &NodeReport{
+		Role: string(role),
+		Consensus: ConsensusReport{
+			Phase:          status.Phase,
+			PhaseSince:     status.PhaseSince,
+			CircuitBreaker: status.CircuitBreaker,
+		},
+		Chain: ChainReport{
+			Head:        status.BlockNumber,
+			HeadHash:    status.BlockHash,
+			SettledHead: d.SettledHead(),
+		},
+	}
+
+	if d.progress != nil {
+		report.Avail = &AvailReport{Cursor: status.AvailCursor, Head: status.AvailHead, CatchingUp: status.CatchingUp}
+		if status.AvailHead > status.AvailCursor {
+			report.Avail.Lag = status.AvailHead - status.AvailCursor
+		}
+	}
+
+	if d.txpool != nil {
+		report.TxPool = &TxPoolReport{Pending: d.txpool.Length()}
+	}
+
+	if role != WatchTower {
+		report.Production = &ProductionReport{
+			Paused:           status.ProductionPaused,
+			Ready:            status.Ready,
+			ReadinessReason:  status.ReadinessReason,
+			UnsettledBlocks:  status.UnsettledBlocks,
+			SettlementLag:    status.SettlementLag,
+			SettlementPaused: status.SettlementPaused,
+		}
+	}
+
+	if d.disputeWatcher != nil {
+		report.Disputes = &DisputesReport{Open: d.disputeWatcher.openReports()}
+		if role != WatchTower {
+			report.Disputes.State = status.DisputeState
+		}
+	}
+
+	if role == WatchTower && d.frauds != nil {
+		report.FraudProofs = &FraudProofsReport{Pending: d.frauds.pending(d.minerAddr)}
+	}
+
+	report.Balances = d.balancesReport()
+
+	return report
+}
+
+// balancesReport returns the balances of the account of the node, or nil
+// without one.
+func (d *Avail) balancesReport() *BalancesReport {
+	if d.minerAddr == types.ZeroAddress {
+		return nil
+	}
+
+	report := &BalancesReport{Account: d.minerAddr}
+
+	if balance, err := d.GetAccountBalance(d.minerAddr); err == nil {
+		report.EVM = balance
+	}
+
+	if d.balanceMonitor != nil && d.balanceMonitor.Polled() {
+		remaining := d.balanceMonitor.SubmissionsRemaining()
+		report.Avail, report.SubmissionsRemaining = d.balanceMonitor.Balance(), &remaining
+	}
+
+	return report
+}
+
+// openReports returns the disputes open, the oldest first.
+func (w *disputeWatcher) openReports() []DisputeReport {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	open := make([]DisputeReport, 0, len(w.open))
+
+	for _, d := range w.open {
+		open = append(open, DisputeReport{Sequencer: d.sequencer, Watchtower: d.watchtower, BeganAt: d.beganAt, EscalatedAt: d.escalatedAt})
+	}
+
+	sort.Slice(open, func(i, j int) bool { return open[i].BeganAt < open[j].BeganAt })
+
+	return open
+}
+
+// pending returns the fraud events of the fraud proofs of the watchtower
+// whose disputes are unresolved, in the order detected.
+func (c *fraudCatalog) pending(watchtower types.Address) []FraudEvent {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	pending := []FraudEvent{}
+
+	for _, e := range c.events {
+		if e.Watchtower == watchtower && e.Outcome == FraudDisputed {
+			pending = append(pending, *e)
+		}
+	}
+
+	return pending
+}
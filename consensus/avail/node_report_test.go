@@ -0,0 +1,116 @@
+package avail
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/pkg/avail"
+	"github.com/availproject/op-evm/pkg/staking"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestReportedAvail returns the consensus of the node of the given role,
+// with a dispute open between other parties.
+func newTestReportedAvail(t *testing.T, role MechanismType) *Avail {
+	t.Helper()
+
+	d := newTestGenesisAvail(t)
+	d.setNodeType(role)
+	d.progress = newSyncProgress(systemClock{})
+	d.frauds = newFraudCatalog("", hclog.NewNullLogger())
+	d.disputeWatcher = newDisputeWatcher(d.blockchain, d.executor, DefaultChallengeWindow, nil, hclog.NewNullLogger())
+	d.disputeWatcher.begin(types.StringToAddress("0x1"), types.StringToAddress("0x2"), 1)
+	d.disputes = newDisputeGuard(d.minerAddr, new(staking.DumbActiveParticipants), hclog.NewNullLogger())
+	d.readiness = newReadiness()
+	d.readiness.set(ReadinessReady)
+
+	return d
+}
+
+// testNodeStatus returns the sections of the node status of the node over
+// the status API.
+func testNodeStatus(t *testing.T, d *Avail) map[string]json.RawMessage {
+	t.Helper()
+
+	srv := rpc.NewServer()
+	if err := srv.RegisterName(avail.SettlementNamespace, NewStatusAPI(d)); err != nil {
+		t.Fatal(err)
+	}
+
+	c := rpc.DialInProc(srv)
+	defer c.Close()
+
+	var sections map[string]json.RawMessage
+	if err := c.Call(&sections, avail.SettlementNamespace+"_nodeStatus"); err != nil {
+		t.Fatal(err)
+	}
+
+	return sections
+}
+
+func TestNodeStatusSections(t *testing.T) {
+	sequencer := newTestReportedAvail(t, Sequencer)
+	watchtower := newTestReportedAvail(t, WatchTower)
+
+	// The watchtower's fraud proof is pending, its other one resolved.
+	watchtower.frauds.events = []*FraudEvent{
+		{BlockHash: types.StringToHash("0xa"), Watchtower: watchtower.minerAddr, Outcome: FraudDisputed},
+		{BlockHash: types.StringToHash("0xb"), Watchtower: watchtower.minerAddr, Outcome: FraudSequencerSlashed},
+	}
+
+	seq, wt := testNodeStatus(t, sequencer), testNodeStatus(t, watchtower)
+
+	// Both report the common sections; the Avail balance, never polled, is
+	// omitted.
+	for _, sections := range []map[string]json.RawMessage{seq, wt} {
+		for _, name := range []string{"role", "consensus", "chain", "avail", "txpool", "disputes", "balances"} {
+			assert.Contains(t, sections, name)
+		}
+
+		var balances map[string]json.RawMessage
+		if assert.NoError(t, json.Unmarshal(sections["balances"], &balances)) {
+			assert.Contains(t, balances, "evm")
+			assert.NotContains(t, balances, "avail")
+			assert.NotContains(t, balances, "submissionsRemaining")
+		}
+	}
+
+	assert.JSONEq(t, `"sequencer"`, string(seq["role"]))
+	assert.JSONEq(t, `"watchtower"`, string(wt["role"]))
+
+	// The sequencer reports its production and the dispute state of its
+	// own, not the fraud proofs.
+	assert.Contains(t, seq, "production")
+	assert.NotContains(t, seq, "fraudProofs")
+
+	var production ProductionReport
+	if assert.NoError(t, json.Unmarshal(seq["production"], &production)) {
+		assert.True(t, production.Ready)
+		assert.False(t, production.Paused)
+	}
+
+	var disputes DisputesReport
+	if assert.NoError(t, json.Unmarshal(seq["disputes"], &disputes)) {
+		assert.Equal(t, DisputeNone.String(), disputes.State)
+		assert.Equal(t, []DisputeReport{{Sequencer: types.StringToAddress("0x1"), Watchtower: types.StringToAddress("0x2"), BeganAt: 1}}, disputes.Open)
+	}
+
+	// The watchtower reports its pending fraud proofs instead, the open
+	// disputes without the state of the sequencer.
+	assert.NotContains(t, wt, "production")
+	assert.Contains(t, wt, "fraudProofs")
+
+	var fraudProofs FraudProofsReport
+	if assert.NoError(t, json.Unmarshal(wt["fraudProofs"], &fraudProofs)) && assert.Len(t, fraudProofs.Pending, 1) {
+		assert.Equal(t, types.StringToHash("0xa"), fraudProofs.Pending[0].BlockHash)
+	}
+
+	var wtDisputes map[string]json.RawMessage
+	if assert.NoError(t, json.Unmarshal(wt["disputes"], &wtDisputes)) {
+		assert.NotContains(t, wtDisputes, "state")
+		assert.Contains(t, wtDisputes, "open")
+	}
+}
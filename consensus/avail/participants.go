@@ -0,0 +1,182 @@
+package avail
+
+import (
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/pkg/staking"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// productionScanDepth is the most blocks looked back through for the last
+// block of a sequencer not tracked by the production tracker as of the block
+// asked about.
+const productionScanDepth = 1024
+
+// Participant is the standing of a staked sequencer or watchtower as of a
+// block, as returned by `avail_getParticipants` and `avail_getParticipant`.
+type Participant struct {
+	Address  types.Address `json:"address"`
+	NodeType string        `json:"nodeType"`
+	Stake    *big.Int      `json:"stake"`
+
+	// InProbation is set for the sequencer in probation, accused by a fraud
+	// proof, and Disputed for either party of a dispute in progress.
+	InProbation bool `json:"inProbation"`
+	Disputed    bool `json:"disputed"`
+
+	// LastProducedBlock is the last block of the sequencer up to the block
+	// asked about, if any is known locally.
+	LastProducedBlock *uint64 `json:"lastProducedBlock,omitempty"`
+}
+
+// Participants are the staked participants as of the block of the given
+// number and hash, the sequencers first, as returned by
+// `avail_getParticipants`.
+type Participants struct {
+	BlockNumber  uint64        `json:"blockNumber"`
+	BlockHash    types.Hash    `json:"blockHash"`
+	Participants []Participant `json:"participants"`
+}
+
+// GetParticipants returns the staked sequencers and watchtowers as of the
+// block of the given hash or number, the head if none, from the staking
+// contract at its state, along with the last blocks of the sequencers.
+func (api *StatusAPI) GetParticipants(blockNrOrHash *rpc.BlockNumberOrHash) (*Participants, error) {
+	hdr, err := api.d.participantsHeader(blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+
+	return api.d.Participants(hdr)
+}
+
+// GetParticipant returns the standing of the staked participant of the given
+// address as of the block of the given hash or number, the head if none. The
+// participant not staked then is an error.
+func (api *StatusAPI) GetParticipant(addr types.Address, blockNrOrHash *rpc.BlockNumberOrHash) (*Participant, error) {
+	hdr, err := api.d.participantsHeader(blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+
+	participants, err := api.d.Participants(hdr)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range participants.Participants {
+		if participants.Participants[i].Address == addr {
+			return &participants.Participants[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("participant %s not staked at block %d", addr, hdr.Number)
+}
+
+// participantsHeader returns the header of the block of the given hash or
+// number, the head if none.
+func (d *Avail) participantsHeader(blockNrOrHash *rpc.BlockNumberOrHash) (*types.Header, error) {
+	if blockNrOrHash == nil {
+		return d.blockchain.Header(), nil
+	}
+
+	return d.headerByNumberOrHash(*blockNrOrHash)
+}
+
+// Participants returns the staked participants as of the block of the given
+// header, queried from the staking contract at its state, along with the
+// last blocks of the sequencers up to it.
+func (d *Avail) Participants(hdr *types.Header) (*Participants, error) {
+	statuses, err := staking.QueryParticipantStatuses(d.blockchain, d.executor, hdr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query the participants at block %d: %w", hdr.Number, err)
+	}
+
+	participants := &Participants{BlockNumber: hdr.Number, BlockHash: hdr.Hash, Participants: make([]Participant, 0, len(statuses))}
+
+	for _, s := range statuses {
+		p := Participant{
+			Address:     s.Address,
+			NodeType:    string(s.NodeType),
+			Stake:       s.Stake,
+			InProbation: s.InProbation,
+			Disputed:    s.Disputed,
+		}
+
+		if s.NodeType == staking.Sequencer {
+			p.LastProducedBlock = d.lastProducedBlock(s.Address, hdr)
+		}
+
+		participants.Participants = append(participants.Participants, p)
+	}
+
+	return participants, nil
+}
+
+// lastProducedBlock returns the number of the last canonical block of the
+// miner up to the block of the given header: the one tracked if it's the
+// miner's still, otherwise the one found looking back productionScanDepth
+// blocks at most.
+func (d *Avail) lastProducedBlock(miner types.Address, hdr *types.Header) *uint64 {
+	if number, ok := d.producers.last(miner); ok && number <= hdr.Number {
+		if h, ok := d.blockchain.GetHeaderByNumber(number); ok && types.BytesToAddress(h.Miner) == miner {
+			return &number
+		}
+	}
+
+	for number := hdr.Number; number > 0 && hdr.Number-number < productionScanDepth; number-- {
+		h, ok := d.blockchain.GetHeaderByNumber(number)
+		if !ok {
+			break
+		}
+
+		if types.BytesToAddress(h.Miner) == miner {
+			return &number
+		}
+	}
+
+	return nil
+}
+
+// productionTracker tracks the last block of every miner written to the
+// chain. The blocks are tracked in memory only, from the start of the node.
+// The nil productionTracker tracks nothing.
+type productionTracker struct {
+	lock   sync.Mutex
+	blocks map[types.Address]uint64
+}
+
+func newProductionTracker() *productionTracker {
+	return &productionTracker{blocks: make(map[types.Address]uint64)}
+}
+
+// observe tracks the block written to the chain as the last one of its
+// miner. It's run as a post-commit hook.
+func (t *productionTracker) observe(blk *types.Block, _ []*types.Receipt) {
+	if t == nil || blk.Number() == 0 {
+		return
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.blocks[types.BytesToAddress(blk.Header.Miner)] = blk.Number()
+}
+
+// last returns the number of the last block of the miner written to the
+// chain, or false if none was.
+func (t *productionTracker) last(miner types.Address) (uint64, bool) {
+	if t == nil {
+		return 0, false
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	number, ok := t.blocks[miner]
+
+	return number, ok
+}
@@ -0,0 +1,127 @@
+package avail
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/pkg/avail"
+	"github.com/availproject/op-evm/pkg/common"
+	"github.com/availproject/op-evm/pkg/staking"
+	"github.com/availproject/op-evm/pkg/test"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParticipants(t *testing.T) {
+	d := newTestGenesisAvail(t)
+	d.producers = newProductionTracker()
+	d.blockchain.RegisterPostCommitHook(d.producers.observe)
+
+	srv := rpc.NewServer()
+	if err := srv.RegisterName(avail.SettlementNamespace, NewStatusAPI(d)); err != nil {
+		t.Fatal(err)
+	}
+
+	c := rpc.DialInProc(srv)
+	defer c.Close()
+
+	// The node produces the blocks as the sequencer; the watchtower stakes
+	// after it.
+	sequencer := &testSender{addr: d.minerAddr, key: d.signKey}
+	wtAddr, wtKey := test.NewAccount(t)
+	watchtower := &testSender{addr: wtAddr, key: wtKey}
+
+	writeBlock := func(txs ...*types.Transaction) *types.Block {
+		t.Helper()
+
+		blk := buildTestBlock(t, d, txs...)
+		if err := d.blockchain.WriteBlock(blk, "test"); err != nil {
+			t.Fatal(err)
+		}
+
+		return blk
+	}
+
+	stakeTx := func(s *testSender, nodeType staking.NodeType) *types.Transaction {
+		t.Helper()
+
+		tx, err := staking.StakeTx(s.addr, stakeAmount, string(nodeType), 1_000_000)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return s.sign(t, tx, 1)
+	}
+
+	faucet := &testSender{addr: test.FaucetAccount, key: test.FaucetSignKey}
+	fund := func(to types.Address) *types.Transaction {
+		return faucet.sign(t, &types.Transaction{To: &to, Value: big.NewInt(0).Mul(big.NewInt(100), common.ETH), Gas: 21_000}, 1)
+	}
+
+	writeBlock(fund(sequencer.addr), fund(watchtower.addr))
+	staked := writeBlock(stakeTx(sequencer, staking.Sequencer))
+	writeBlock(stakeTx(watchtower, staking.WatchTower))
+	head := writeBlock()
+
+	getParticipants := func(args ...interface{}) Participants {
+		t.Helper()
+
+		var participants Participants
+		if err := c.Call(&participants, avail.SettlementNamespace+"_getParticipants", args...); err != nil {
+			t.Fatal(err)
+		}
+
+		return participants
+	}
+
+	// At the head, both are staked, the sequencer having produced it.
+	latest := getParticipants()
+	assert.Equal(t, head.Number(), latest.BlockNumber)
+	assert.Equal(t, head.Hash(), latest.BlockHash)
+
+	if assert.Len(t, latest.Participants, 2) {
+		seq, wt := latest.Participants[0], latest.Participants[1]
+
+		assert.Equal(t, sequencer.addr, seq.Address)
+		assert.Equal(t, string(staking.Sequencer), seq.NodeType)
+		assert.Equal(t, stakeAmount, seq.Stake)
+		assert.False(t, seq.InProbation)
+		assert.False(t, seq.Disputed)
+
+		if assert.NotNil(t, seq.LastProducedBlock) {
+			assert.Equal(t, head.Number(), *seq.LastProducedBlock)
+		}
+
+		assert.Equal(t, watchtower.addr, wt.Address)
+		assert.Equal(t, string(staking.WatchTower), wt.NodeType)
+		assert.Equal(t, stakeAmount, wt.Stake)
+		assert.False(t, wt.Disputed)
+		assert.Nil(t, wt.LastProducedBlock)
+	}
+
+	// At the block staking the sequencer, on its state, the watchtower isn't
+	// staked yet, and the sequencer's last block is that one.
+	earlier := getParticipants(rpc.BlockNumberOrHashWithNumber(rpc.BlockNumber(staked.Number())))
+	assert.Equal(t, staked.Hash(), earlier.BlockHash)
+
+	if assert.Len(t, earlier.Participants, 1) {
+		assert.Equal(t, sequencer.addr, earlier.Participants[0].Address)
+
+		if assert.NotNil(t, earlier.Participants[0].LastProducedBlock) {
+			assert.Equal(t, staked.Number(), *earlier.Participants[0].LastProducedBlock)
+		}
+	}
+
+	assert.Equal(t, earlier, getParticipants(staked.Hash()))
+
+	// A single participant is looked up by address.
+	var wt Participant
+	if err := c.Call(&wt, avail.SettlementNamespace+"_getParticipant", watchtower.addr); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, latest.Participants[1], wt)
+
+	assert.Error(t, c.Call(&wt, avail.SettlementNamespace+"_getParticipant", watchtower.addr, rpc.BlockNumberOrHashWithNumber(rpc.BlockNumber(staked.Number()))))
+}
@@ -0,0 +1,176 @@
+package avail
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+)
+
+// Phase is the phase of the consensus of the node, as reported by the status
+// API.
+type Phase string
+
+const (
+	// PhaseBootstrapping is the phase of the node starting up, before it
+	// syncs the chain; the bootstrap sequencer bootstraps the chain in it.
+	PhaseBootstrapping Phase = "bootstrapping"
+
+	// PhaseSyncing is the phase of the node syncing the chain from Avail,
+	// catching up with the Avail head.
+	PhaseSyncing Phase = "syncing"
+
+	// PhaseWaitingForStake is the phase of the synced sequencer waiting to be
+	// ready to produce blocks; see ReadinessReason for why.
+	PhaseWaitingForStake Phase = "waiting for stake"
+
+	// PhaseActive is the phase of the sequencer taking part in the
+	// sequencing.
+	PhaseActive Phase = "active"
+
+	// PhasePausedByDispute is the phase of the sequencer whose production is
+	// paused by a dispute against it.
+	PhasePausedByDispute Phase = "paused by dispute"
+
+	// PhaseCircuitBroken is the phase of the node whose consensus is halted
+	// by the circuit breaker, until resumed.
+	PhaseCircuitBroken Phase = "halted by circuit breaker"
+
+	// PhaseSwitchingRole is the phase of the node switching its role
+	// between the sequencer and the watchtower, in between the stop of the
+	// loops of the previous role and the start of the next one.
+	PhaseSwitchingRole Phase = "switching role"
+
+	// PhaseHalted is the phase of the node done with the consensus, for good.
+	PhaseHalted Phase = "halted"
+)
+
+// phases lists every phase, in the order of the lifecycle.
+var phases = []Phase{
+	PhaseBootstrapping,
+	PhaseSyncing,
+	PhaseWaitingForStake,
+	PhaseActive,
+	PhasePausedByDispute,
+	PhaseCircuitBroken,
+	PhaseSwitchingRole,
+	PhaseHalted,
+}
+
+// phaseTransitions lists the phases every phase may transition to.
+var phaseTransitions = map[Phase][]Phase{
+	PhaseBootstrapping:   {PhaseSyncing, PhaseHalted},
+	PhaseSyncing:         {PhaseWaitingForStake, PhaseActive, PhasePausedByDispute, PhaseCircuitBroken, PhaseSwitchingRole, PhaseHalted},
+	PhaseWaitingForStake: {PhaseSyncing, PhaseActive, PhasePausedByDispute, PhaseCircuitBroken, PhaseSwitchingRole, PhaseHalted},
+	PhaseActive:          {PhaseSyncing, PhaseWaitingForStake, PhasePausedByDispute, PhaseCircuitBroken, PhaseSwitchingRole, PhaseHalted},
+	PhasePausedByDispute: {PhaseSyncing, PhaseWaitingForStake, PhaseActive, PhaseCircuitBroken, PhaseSwitchingRole, PhaseHalted},
+	PhaseCircuitBroken:   {PhaseSyncing, PhaseWaitingForStake, PhaseActive, PhasePausedByDispute, PhaseSwitchingRole, PhaseHalted},
+	PhaseSwitchingRole:   {PhaseSyncing, PhaseHalted},
+	PhaseHalted:          {},
+}
+
+// ErrIllegalPhaseTransition is the error of a transition between phases the
+// lifecycle of the node doesn't allow.
+var ErrIllegalPhaseTransition = errors.New("illegal phase transition")
+
+// phaseMachine tracks the phase of the consensus of the node, allowing only
+// the transitions the lifecycle of the node does.
+type phaseMachine struct {
+	lock  sync.Mutex
+	phase Phase
+	since time.Time
+
+	clock  clock
+	logger hclog.Logger
+
+	// strict makes an illegal transition panic rather than fail; tests set it.
+	strict bool
+}
+
+func newPhaseMachine(clock clock, logger hclog.Logger) *phaseMachine {
+	m := &phaseMachine{
+		phase:  PhaseBootstrapping,
+		since:  clock.Now(),
+		clock:  clock,
+		logger: logger,
+	}
+
+	observePhase(m.phase)
+
+	return m
+}
+
+// Phase returns the current phase along with the time it was entered.
+func (m *phaseMachine) Phase() (Phase, time.Time) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return m.phase, m.since
+}
+
+// enter transitions to the given phase; staying in the current phase is a
+// no-op. An illegal transition leaves the phase as is and returns
+// ErrIllegalPhaseTransition.
+func (m *phaseMachine) enter(phase Phase) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if phase == m.phase {
+		return nil
+	}
+
+	if !phaseTransitionAllowed(m.phase, phase) {
+		err := fmt.Errorf("%w: from %q to %q", ErrIllegalPhaseTransition, m.phase, phase)
+		if m.strict {
+			panic(err)
+		}
+
+		m.logger.Error("refused to change the consensus phase", "from", m.phase, "to", phase, "error", err)
+
+		return err
+	}
+
+	m.logger.Info("consensus phase changed", "from", m.phase, "to", phase, "after", m.clock.Now().Sub(m.since))
+
+	m.phase, m.since = phase, m.clock.Now()
+	observePhase(phase)
+
+	return nil
+}
+
+// phaseTransitionAllowed reports whether the transition between the phases
+// given is legal.
+func phaseTransitionAllowed(from, to Phase) bool {
+	for _, p := range phaseTransitions[from] {
+		if p == to {
+			return true
+		}
+	}
+
+	return false
+}
+
+// updatePhase enters the phase of the sequencer following the state it
+// observed at the last Avail block.
+func (sw *SequencerWorker) updatePhase() {
+	phase := PhaseActive
+
+	switch {
+	case sw.breaker.Tripped():
+		phase = PhaseCircuitBroken
+
+	case sw.progress.CatchingUp():
+		phase = PhaseSyncing
+
+	case sw.disputes.Paused():
+		phase = PhasePausedByDispute
+
+	case !sw.readiness.Ready():
+		phase = PhaseWaitingForStake
+	}
+
+	// An illegal transition is logged by the phase machine.
+	_ = sw.phases.enter(phase)
+}
@@ -0,0 +1,134 @@
+package avail
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/availproject/op-evm/pkg/avail/testutil"
+	"github.com/availproject/op-evm/pkg/common"
+	"github.com/availproject/op-evm/pkg/staking"
+	"github.com/availproject/op-evm/pkg/test"
+	avail_types "github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestPhaseMachine returns the phase machine panicking on an illegal
+// transition.
+func newTestPhaseMachine() *phaseMachine {
+	m := newPhaseMachine(systemClock{}, hclog.NewNullLogger())
+	m.strict = true
+
+	return m
+}
+
+func TestPhaseMachineTransitions(t *testing.T) {
+	clock := newFakeClock(time.Unix(1_000, 0))
+
+	m := newPhaseMachine(clock, hclog.NewNullLogger())
+
+	phase, since := m.Phase()
+	assert.Equal(t, PhaseBootstrapping, phase)
+	assert.Equal(t, clock.Now(), since)
+
+	clock.advance(time.Minute)
+	assert.NoError(t, m.enter(PhaseSyncing))
+
+	// Staying in the phase keeps the time it was entered.
+	clock.advance(time.Minute)
+	assert.NoError(t, m.enter(PhaseSyncing))
+
+	phase, since = m.Phase()
+	assert.Equal(t, PhaseSyncing, phase)
+	assert.Equal(t, time.Unix(1_060, 0), since)
+
+	// An illegal transition fails, the phase left as is.
+	assert.ErrorIs(t, m.enter(PhaseBootstrapping), ErrIllegalPhaseTransition)
+
+	phase, _ = m.Phase()
+	assert.Equal(t, PhaseSyncing, phase)
+
+	// Nothing follows the halt.
+	assert.NoError(t, m.enter(PhaseHalted))
+	assert.ErrorIs(t, m.enter(PhaseActive), ErrIllegalPhaseTransition)
+
+	// The tests make an illegal transition panic.
+	assert.Panics(t, func() { _ = newTestPhaseMachine().enter(PhaseActive) })
+}
+
+func TestSequencerPhaseLifecycle(t *testing.T) {
+	d, _ := NewTestAvail(t, Sequencer)
+	sw, _, _ := newTestSequencerWorkerOf(t, d, testutil.NewFake(avail_types.NewUCompactFromUInt(1)))
+	d.progress, d.disputes, d.readiness = sw.progress, sw.disputes, sw.readiness
+
+	var observed []Phase
+
+	record := func() {
+		phase := Phase(d.Status().Phase)
+		if len(observed) == 0 || observed[len(observed)-1] != phase {
+			observed = append(observed, phase)
+		}
+	}
+
+	record()
+
+	// The sequencer starts by syncing the chain, catching up with Avail.
+	assert.NoError(t, sw.phases.enter(PhaseSyncing))
+	sw.progress.catchingUp.Store(true)
+	sw.updatePhase()
+	record()
+
+	// Caught up, it waits for its stake to show up.
+	sw.progress.catchingUp.Store(false)
+	sw.readiness.set(ReadinessStaking)
+	sw.updatePhase()
+	record()
+
+	sw.readiness.set(ReadinessReady)
+	sw.updatePhase()
+	record()
+
+	// A watchtower opens a dispute against it, which it wins.
+	watchtowerAddr, watchtowerKey := test.NewAccount(t)
+	test.DepositBalance(t, watchtowerAddr, big.NewInt(0).Mul(big.NewInt(1000), common.ETH), sw.blockchain, sw.executor)
+
+	sender := staking.NewTestAvailSender()
+	if err := staking.Stake(sw.blockchain, sw.executor, sender, hclog.Default(), string(staking.WatchTower), watchtowerAddr, watchtowerKey, big.NewInt(0).Mul(big.NewInt(10), common.ETH), 1_000_000, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	dr := staking.NewDisputeResolution(sw.blockchain, sw.executor, sender, hclog.Default())
+	if err := dr.Begin(sw.nodeAddr, watchtowerKey); err != nil {
+		t.Fatal(err)
+	}
+
+	sw.disputes.Observe()
+	sw.updatePhase()
+	record()
+
+	if err := dr.End(sw.nodeAddr, watchtowerKey); err != nil {
+		t.Fatal(err)
+	}
+
+	sw.disputes.Observe()
+	sw.updatePhase()
+	record()
+
+	// Stopped, it's halted for good.
+	assert.NoError(t, sw.phases.enter(PhaseHalted))
+	record()
+
+	assert.Equal(t, []Phase{
+		PhaseBootstrapping,
+		PhaseSyncing,
+		PhaseWaitingForStake,
+		PhaseActive,
+		PhasePausedByDispute,
+		PhaseActive,
+		PhaseHalted,
+	}, observed)
+
+	_, since := d.phases.Phase()
+	assert.Equal(t, since, d.Status().PhaseSince)
+}
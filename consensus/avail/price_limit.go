@@ -0,0 +1,62 @@
+package avail
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+// ErrUnderpricedTx is the error of the user transaction priced under the
+// price limit of the sequencer.
+var ErrUnderpricedTx = errors.New("transaction underpriced")
+
+// checkPriceLimit returns ErrUnderpricedTx for the user transaction whose
+// effective gas price, at the given base fee, is under the price limit. The
+// system transactions, built by the node to stake and resolve the disputes
+// and signed by the keys it owns, bypass the limit; so does every
+// transaction with a zero limit.
+func checkPriceLimit(tx *types.Transaction, baseFee, limit uint64, own func(types.Address) bool) error {
+	if limit == 0 || isSystemTx(tx, own) {
+		return nil
+	}
+
+	price := tx.GetGasPrice(baseFee)
+	if price.Cmp(new(big.Int).SetUint64(limit)) < 0 {
+		return fmt.Errorf("%w: gas price of %s wei is under the price limit of %d wei", ErrUnderpricedTx, price, limit)
+	}
+
+	return nil
+}
+
+// checkFeeCap returns ErrUnderpricedTx for the dynamic-fee transaction whose
+// fee cap is under the given base fee; the legacy transactions pass.
+func checkFeeCap(tx *types.Transaction, baseFee uint64) error {
+	if tx.Type != types.DynamicFeeTx || tx.GasFeeCap == nil {
+		return nil
+	}
+
+	if tx.GasFeeCap.Cmp(new(big.Int).SetUint64(baseFee)) < 0 {
+		return fmt.Errorf("%w: fee cap of %s wei is under the base fee of %d wei", ErrUnderpricedTx, tx.GasFeeCap, baseFee)
+	}
+
+	return nil
+}
+
+// AddTx adds the transaction submitted by a user to the txpool, rejecting it
+// with ErrUnderpricedTx if priced under the price limit of the sequencer, or
+// if a dynamic-fee one, capped under the base fee of the next block.
+func (d *Avail) AddTx(tx *types.Transaction) error {
+	baseFee := d.blockchain.CalculateBaseFee(d.blockchain.Header())
+
+	if err := checkPriceLimit(tx, baseFee, d.overrides.priceLimitOr(d.production.PriceLimit), d.keys.owns); err != nil {
+		return err
+	}
+
+	if err := checkFeeCap(tx, baseFee); err != nil {
+		return err
+	}
+
+	return d.txpool.AddTx(tx)
+}
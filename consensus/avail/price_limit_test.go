@@ -0,0 +1,116 @@
+package avail
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/pkg/avail/testutil"
+	"github.com/availproject/op-evm/pkg/staking"
+	avail_types "github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckPriceLimit(t *testing.T) {
+	node := types.StringToAddress("0x01")
+
+	dynamic := func(feeCap, tipCap int64) *types.Transaction {
+		return &types.Transaction{Type: types.DynamicFeeTx, To: &types.ZeroAddress, GasFeeCap: big.NewInt(feeCap), GasTipCap: big.NewInt(tipCap)}
+	}
+
+	testCases := []struct {
+		name        string
+		tx          *types.Transaction
+		limit       uint64
+		underpriced bool
+	}{
+		{"no limit", &types.Transaction{To: &types.ZeroAddress, GasPrice: big.NewInt(1)}, 0, false},
+		{"legacy under the limit", &types.Transaction{To: &types.ZeroAddress, GasPrice: big.NewInt(99)}, 100, true},
+		{"legacy at the limit", &types.Transaction{To: &types.ZeroAddress, GasPrice: big.NewInt(100)}, 100, false},
+		{"dynamic fee capped under the limit", dynamic(90, 50), 100, true},
+		{"dynamic fee tipped up to the limit", dynamic(200, 50), 100, false},
+		{"system under the limit", &types.Transaction{From: node, To: &staking.AddrStakingContract, GasPrice: big.NewInt(1)}, 100, false},
+		{"staking call of a user under the limit", &types.Transaction{From: types.StringToAddress("0x02"), To: &staking.AddrStakingContract, GasPrice: big.NewInt(0)}, 100, true},
+	}
+
+	own := func(addr types.Address) bool { return addr == node }
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			err := checkPriceLimit(tc.tx, 50, tc.limit, own)
+			if tc.underpriced {
+				assert.ErrorIs(t, err, ErrUnderpricedTx)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestSequencerPriceLimit(t *testing.T) {
+	const priceLimit = 10_000
+
+	a, _ := NewTestAvail(t, Sequencer)
+	a.production.PriceLimit = priceLimit
+
+	sw, fraudResolver, _ := newTestSequencerWorkerOf(t, a, testutil.NewFake(avail_types.NewUCompactFromUInt(1)))
+	sw.production.PriceLimit = priceLimit
+
+	user, staker, node := newTestSender(t, sw), newTestSender(t, sw), newNodeTestSender(t, sw)
+
+	// Submitted over JSON-RPC, the transaction under the limit is rejected,
+	// while the one at the limit is admitted.
+	assert.ErrorIs(t, a.AddTx(user.transfer(t, priceLimit-1)), ErrUnderpricedTx)
+
+	user.nonce--
+
+	atLimit := user.transfer(t, priceLimit)
+	assert.NoError(t, a.AddTx(atLimit))
+
+	// The zero-priced call of the staking contract by a user is rejected
+	// like any other user transaction.
+	userStake, err := staking.StakeTx(staker.addr, big.NewInt(0), string(Sequencer), 1_000_000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.ErrorIs(t, a.AddTx(staker.sign(t, userStake, 0)), ErrUnderpricedTx)
+
+	// The staking transaction of the node bypasses the limit.
+	stake, err := staking.StakeTx(node.addr, big.NewInt(0), string(Sequencer), 1_000_000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stakeTx := node.sign(t, stake, 5000)
+	assert.NoError(t, a.AddTx(stakeTx))
+
+	// The transaction under the limit slipping into the txpool some other
+	// way, such as gossiped by a peer, is left out of the block.
+	if err := sw.txpool.AddTx(user.transfer(t, 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Eventually(t, func() bool { return sw.txpool.Length() == 3 }, 5*time.Second, 10*time.Millisecond)
+
+	account := accounts.Account{Address: common.Address(sw.nodeAddr)}
+	if err := sw.writeBlock(fraudResolver, account, &keystore.Key{PrivateKey: sw.nodeSignKey}); err != nil {
+		t.Fatal(err)
+	}
+
+	blk, _ := sw.blockchain.GetBlockByNumber(sw.blockchain.Header().Number, true)
+
+	included := make([]types.Hash, 0, len(blk.Transactions))
+	for _, tx := range blk.Transactions {
+		included = append(included, tx.Hash)
+	}
+
+	assert.Equal(t, []types.Hash{stakeTx.Hash, atLimit.Hash}, included)
+	assert.Zero(t, sw.txpool.Length())
+}
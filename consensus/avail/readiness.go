@@ -0,0 +1,237 @@
+package avail
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/0xPolygon/polygon-edge/crypto"
+	"github.com/0xPolygon/polygon-edge/txpool"
+	"github.com/availproject/op-evm/pkg/common"
+	"github.com/availproject/op-evm/pkg/staking"
+	"github.com/hashicorp/go-hclog"
+)
+
+// ReadinessReason is why the sequencer is, or isn't yet, ready to produce
+// blocks, as reported by the status API.
+type ReadinessReason string
+
+const (
+	// ReadinessCheckingStake is the reason of the sequencer checking its
+	// stake on the start.
+	ReadinessCheckingStake ReadinessReason = "checking stake"
+
+	// ReadinessStaking is the reason of the sequencer whose stake has been
+	// submitted and is yet to show up in the staking contract.
+	ReadinessStaking ReadinessReason = "staking"
+
+	// ReadinessNotStaked is the reason of the sequencer not among the active
+	// staked sequencers, with no stake of its own on the way.
+	ReadinessNotStaked ReadinessReason = "not staked"
+
+	// ReadinessInProbation is the reason of the sequencer under probation.
+	ReadinessInProbation ReadinessReason = "in probation"
+
+	// ReadinessJoining is the reason of the staked sequencer waiting for a
+	// fresh Avail block window to join the network.
+	ReadinessJoining ReadinessReason = "joining"
+
+	// ReadinessReady is the reason of the sequencer ready to produce blocks.
+	ReadinessReady ReadinessReason = "ready"
+)
+
+// stakeAmount is the amount the sequencers and the watchtowers stake.
+var stakeAmount = big.NewInt(0).Mul(big.NewInt(10), common.ETH)
+
+// readiness gates the block production of the sequencer until its stake
+// shows up in the staking contract; until then, the node follows the chain
+// only.
+type readiness struct {
+	lock   sync.Mutex
+	reason ReadinessReason
+
+	// ready is closed once the sequencer is ready for the first time.
+	ready chan struct{}
+}
+
+func newReadiness() *readiness {
+	return &readiness{
+		reason: ReadinessCheckingStake,
+		ready:  make(chan struct{}),
+	}
+}
+
+// Ready reports whether the sequencer is ready to produce blocks.
+func (r *readiness) Ready() bool {
+	return r.Reason() == ReadinessReady
+}
+
+// Reason returns why the sequencer is, or isn't, ready.
+func (r *readiness) Reason() ReadinessReason {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.reason
+}
+
+// set records why the sequencer is, or isn't, ready.
+func (r *readiness) set(reason ReadinessReason) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.reason = reason
+
+	if reason == ReadinessReady {
+		select {
+		case <-r.ready:
+		default:
+			close(r.ready)
+		}
+	}
+}
+
+// wait returns the channel closed once the sequencer is ready for the first time.
+func (r *readiness) wait() <-chan struct{} {
+	return r.ready
+}
+
+// submitStakeTx signs the staking transaction of the node and adds it to the
+// txpool, for the active sequencer to include it in a block. Adding it is
+// retried up to 10 times.
+// The stake of a node not staked yet is a user transaction to the active
+// sequencer, so it's priced at the given price limit, the one the node
+// shares with the rest of the network.
+func submitStakeTx(txp *txpool.TxPool, signKey *ecdsa.PrivateKey, nodeType string, gasPrice uint64, logger hclog.Logger) error {
+	addr := crypto.PubKeyToAddress(&signKey.PublicKey)
+
+	tx, err := staking.StakeTx(addr, stakeAmount, nodeType, 1_000_000)
+	if err != nil {
+		return err
+	}
+
+	tx.GasPrice = new(big.Int).SetUint64(gasPrice)
+
+	txSigner := &crypto.FrontierSigner{}
+	tx, err = txSigner.SignTx(tx, signKey)
+	if err != nil {
+		return err
+	}
+
+	for retries := 0; retries < 10; retries++ {
+		logger.Info("Submitting stake to the tx pool", "retry", retries)
+		// Submit staking transaction for execution by active sequencer.
+		if err = txp.AddTx(tx); err != nil {
+			logger.Error("failure to add staking tx to the txpool", "error", err)
+			time.Sleep(1 * time.Second)
+			continue
+		}
+
+		logger.Info("Stake submitted to the tx pool", "retry", retries)
+		return nil
+	}
+
+	return err
+}
+
+// stakeIfNeeded is the start of the readiness gate: it checks the stake of
+// the sequencer and, with the auto-staking enabled, stakes the sequencer not
+// staked yet. The production waits for the stake to show up in the staking
+// contract, which is up to the active sequencer including it in a block.
+func (sw *SequencerWorker) stakeIfNeeded() error {
+	sw.readiness.set(ReadinessCheckingStake)
+
+	inProbation, err := sw.apq.InProbation(sw.nodeAddr)
+	if err != nil {
+		return err
+	}
+
+	if inProbation {
+		sw.readiness.set(ReadinessInProbation)
+		return errors.New("participant is under probation")
+	}
+
+	staked, err := sw.apq.Contains(sw.nodeAddr, staking.Sequencer)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case staked:
+		sw.logger.Info("Node is successfully staked...")
+		sw.readiness.set(ReadinessJoining)
+
+	case !sw.production.AutoStake:
+		sw.logger.Warn("node is not staked and auto-staking is disabled; following the chain until staked", "address", sw.nodeAddr)
+		sw.readiness.set(ReadinessNotStaked)
+
+	default:
+		if err := submitStakeTx(sw.txpool, sw.nodeSignKey, string(staking.Sequencer), sw.overrides.priceLimitOr(sw.production.PriceLimit), sw.logger); err != nil {
+			sw.readiness.set(ReadinessNotStaked)
+			return err
+		}
+
+		sw.readiness.set(ReadinessStaking)
+	}
+
+	return nil
+}
+
+// observeReadiness checks the stake of the sequencer at the Avail block of
+// the given height and updates the readiness accordingly. It reports whether
+// the sequencer is ready to take part in the sequencing.
+func (sw *SequencerWorker) observeReadiness(activeSequencersQuerier staking.ActiveSequencers, availHeight int64) bool {
+	// Periodically verify that we are staked, before proceeding with sequencer
+	// logic. In the unexpected case of being slashed and dropping below the
+	// required sequencer staking threshold, we must stop processing, because
+	// otherwise we just get slashed more.
+	// While the key rotates, the key signing the blocks is the one to be staked.
+	addr := sw.keys.Address()
+
+	sequencerStaked, sequencerError := activeSequencersQuerier.Contains(addr)
+	if sequencerError != nil {
+		sw.logger.Error("failed to check if my account is among active staked sequencers; cannot continue", "error", sequencerError)
+		return false
+	}
+
+	if !sequencerStaked {
+		sw.logger.Warn("my account is not among active staked sequencers; cannot continue", "address", addr.String())
+
+		// The stake on the way is waited for.
+		if sw.readiness.Reason() != ReadinessStaking {
+			sw.readiness.set(ReadinessNotStaked)
+		}
+
+		return false
+	}
+
+	if sw.availBlockNumWhenStaked == nil {
+		sw.availBlockNumWhenStaked = new(int64)
+		*sw.availBlockNumWhenStaked = availHeight
+		sw.logger.Debug("staking observed in the blockchain; storing avail block number", "block_number", availHeight)
+	}
+
+	// Only proceed with the sequencing logic after the "join window" changes to
+	// next one. This logic is needed because on a new node, that joins in the
+	// middle of the Avail block window, the ActiveSequencer cache is not
+	// consistent with the other nodes in the network. When all the nodes renew
+	// their active sequencer list on a start of the new block window, they gain
+	// coherent view into who is the next "leader" (i.e. the active sequencer
+	// allowed to produce a block).
+	if sw.nodeType != BootstrapSequencer && (*sw.availBlockNumWhenStaked/availBlockWindowLen) == (availHeight/availBlockWindowLen) {
+		sw.logger.Debug("sequencer account staked, but waiting for a fresh Avail block window after joining the network")
+		sw.readiness.set(ReadinessJoining)
+
+		return false
+	}
+
+	sw.logger.Debug("past the point of sequencer ramp up window", "block_number", availHeight)
+
+	if !sw.readiness.Ready() {
+		sw.logger.Info("sequencer is staked and ready; starting block production", "address", addr)
+		sw.readiness.set(ReadinessReady)
+	}
+
+	return true
+}
@@ -0,0 +1,148 @@
+package avail
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/pkg/avail/testutil"
+	"github.com/availproject/op-evm/pkg/common"
+	"github.com/availproject/op-evm/pkg/staking"
+	"github.com/availproject/op-evm/pkg/test"
+	avail_types "github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	common_eth "github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+// syncFromAvail writes the blocks on Avail the local chain misses, the way
+// the sequencer applies the blocks of the others.
+func syncFromAvail(t *testing.T, sw *SequencerWorker, fake *testutil.Fake) {
+	t.Helper()
+
+	blks, err := fake.EdgeBlocks()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, blk := range blks {
+		if _, known := sw.blockchain.GetHeaderByHash(blk.Hash()); known {
+			continue
+		}
+
+		if err := sw.blockchain.WriteBlock(blk, sw.nodeType.String()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sw.txpool.ResetWithHeaders(sw.blockchain.Header())
+}
+
+func TestSequencerReadinessGate(t *testing.T) {
+	fake := testutil.NewFake(avail_types.NewUCompactFromUInt(1))
+
+	leader, leaderFraudResolver, _ := newTestSequencerWorkerOf(t, newTestGenesisAvail(t), fake)
+	leaderAccount := accounts.Account{Address: common_eth.Address(leader.nodeAddr)}
+	leaderKey := &keystore.Key{PrivateKey: leader.nodeSignKey}
+
+	d := newTestGenesisAvail(t)
+	sw, fraudResolver, _ := newTestSequencerWorkerOf(t, d, fake)
+	sw.readiness = newReadiness()
+	d.readiness = sw.readiness
+
+	activeSequencers := staking.NewRandomizedActiveSequencersQuerier(func() int64 { return 0 }, sw.apq)
+
+	// The faucet funds the sequencer through the leader.
+	faucet := &testSender{addr: test.FaucetAccount, key: test.FaucetSignKey}
+	funding := faucet.sign(t, &types.Transaction{To: &sw.nodeAddr, Value: big.NewInt(0).Mul(big.NewInt(100), common.ETH), Gas: 21_000}, 1)
+
+	if err := leader.txpool.AddTx(funding); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Eventually(t, func() bool { return leader.txpool.Length() > 0 }, 5*time.Second, 10*time.Millisecond)
+
+	if err := leader.writeBlock(leaderFraudResolver, leaderAccount, leaderKey); err != nil {
+		t.Fatal(err)
+	}
+
+	syncFromAvail(t, sw, fake)
+
+	// The sequencer starts unstaked, with the auto-staking enabled.
+	clock, stop := startWriteBlocksLoop(t, sw, fraudResolver)
+	defer stop()
+
+	if err := sw.stakeIfNeeded(); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, ReadinessStaking, sw.readiness.Reason())
+	assert.False(t, sw.observeReadiness(activeSequencers, int64(fake.Head())))
+	assert.Equal(t, ReadinessStaking, sw.readiness.Reason())
+
+	status, err := NewStatusAPI(d).GetNodeStatus()
+	if assert.NoError(t, err) {
+		assert.False(t, status.Ready)
+		assert.Equal(t, "staking", status.ReadinessReason)
+	}
+
+	assert.Eventually(t, func() bool { return sw.txpool.Length() > 0 }, 5*time.Second, 10*time.Millisecond)
+	sw.txpool.Prepare(sw.txpool.GetBaseFee())
+
+	stakeTx := sw.txpool.Peek()
+	if stakeTx == nil {
+		t.Fatal("stake transaction not in the txpool")
+	}
+
+	// The leader includes the stake; a copy, as the txpool rehashes the
+	// transactions it takes in.
+	if err := leader.txpool.AddTx(stakeTx.Copy()); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Eventually(t, func() bool { return leader.txpool.Length() > 0 }, 5*time.Second, 10*time.Millisecond)
+
+	if err := leader.writeBlock(leaderFraudResolver, leaderAccount, leaderKey); err != nil {
+		t.Fatal(err)
+	}
+
+	syncFromAvail(t, sw, fake)
+
+	stakeBlock := sw.blockchain.Header().Number
+
+	// Staked, the sequencer joins on the next Avail block window.
+	staked := int64(fake.Head())
+	assert.False(t, sw.observeReadiness(activeSequencers, staked))
+	assert.Equal(t, ReadinessJoining, sw.readiness.Reason())
+
+	for {
+		fake.Produce()
+
+		h := int64(fake.Head())
+		if h/availBlockWindowLen != staked/availBlockWindowLen {
+			assert.True(t, sw.observeReadiness(activeSequencers, h))
+			break
+		}
+
+		assert.False(t, sw.observeReadiness(activeSequencers, h))
+	}
+
+	status, err = NewStatusAPI(d).GetNodeStatus()
+	if assert.NoError(t, err) {
+		assert.True(t, status.Ready)
+		assert.Equal(t, "ready", status.ReadinessReason)
+	}
+
+	// Nothing produced before the stake showed up; the first block comes after it.
+	for n := uint64(1); n <= stakeBlock; n++ {
+		hdr, _ := sw.blockchain.GetHeaderByNumber(n)
+		assert.Equal(t, leader.nodeAddr, types.BytesToAddress(hdr.Miner))
+	}
+
+	clock.tick()
+	waitForBlock(t, sw, stakeBlock+1)
+
+	assert.Equal(t, sw.nodeAddr, types.BytesToAddress(sw.blockchain.Header().Miner))
+}
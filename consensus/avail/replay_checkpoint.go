@@ -0,0 +1,231 @@
+package avail
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/0xPolygon/polygon-edge/state"
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/pkg/blockchain"
+	"github.com/hashicorp/go-hclog"
+)
+
+// ReplayCheckpointFileName is the name of the file, in the consensus data
+// directory, the replay checkpoint is persisted to.
+const ReplayCheckpointFileName = "replay_checkpoint.json"
+
+// ReplayCheckpoint is the progress of the replay of the Avail history on the
+// start, for a node restarted in the middle of it to pick up from: the last
+// Avail block processed, and the head of the local chain after it, along
+// with its state root.
+type ReplayCheckpoint struct {
+	AvailBlock uint64     `json:"availBlock"`
+	Number     uint64     `json:"number"`
+	Hash       types.Hash `json:"hash"`
+	StateRoot  types.Hash `json:"stateRoot"`
+}
+
+// replayCheckpoints checkpoints the replay of the Avail history every given
+// number of Avail blocks processed. The checkpoints are persisted to a file in
+// the data directory in the background, the latest one only, so the replay
+// isn't held up by the writes; the file is removed once the replay completes.
+// Without a data directory, or with a zero interval, nothing is checkpointed.
+type replayCheckpoints struct {
+	path   string
+	every  uint64
+	logger hclog.Logger
+
+	lock    sync.Mutex
+	last    uint64            // The Avail block of the last checkpoint taken
+	pending *ReplayCheckpoint // The checkpoint waiting for the write in flight
+	writing bool
+	written sync.WaitGroup
+}
+
+// newReplayCheckpoints returns the replayCheckpoints of the replay checkpointed
+// every given number of Avail blocks to the given data directory.
+func newReplayCheckpoints(dataDir string, every uint64, logger hclog.Logger) *replayCheckpoints {
+	c := &replayCheckpoints{every: every, logger: logger}
+	if dataDir != "" && every > 0 {
+		c.path = filepath.Join(dataDir, ReplayCheckpointFileName)
+	}
+
+	return c
+}
+
+// load returns the checkpoint persisted by the previous run, if any.
+func (c *replayCheckpoints) load() (ReplayCheckpoint, bool, error) {
+	if c == nil || c.path == "" {
+		return ReplayCheckpoint{}, false, nil
+	}
+
+	bs, err := os.ReadFile(c.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ReplayCheckpoint{}, false, nil
+	}
+
+	if err != nil {
+		return ReplayCheckpoint{}, false, err
+	}
+
+	var cp ReplayCheckpoint
+	if err := json.Unmarshal(bs, &cp); err != nil {
+		return ReplayCheckpoint{}, false, fmt.Errorf("invalid replay checkpoint file %q: %w", c.path, err)
+	}
+
+	return cp, true, nil
+}
+
+// resume returns the Avail block to resume the replay from, the one after the
+// checkpoint persisted by the previous run; zero if there's none to resume
+// from. The checkpoint holds only if its block is in the local chain, with the
+// state root recorded, and the state at it is in the database; otherwise it's
+// discarded, and the replay starts over from the first Avail block, the
+// blocks already in the chain skipped.
+func (c *replayCheckpoints) resume(bc *blockchain.Blockchain, executor *state.Executor) uint64 {
+	cp, ok, err := c.load()
+	if err != nil {
+		c.logger.Warn("failed to load the replay checkpoint; ignoring it", "error", err)
+		return 0
+	}
+
+	if !ok {
+		return 0
+	}
+
+	if err := verifyReplayCheckpoint(cp, bc, executor); err != nil {
+		c.logger.Warn("replay checkpoint doesn't match the database; replaying from the start", "avail_block_number", cp.AvailBlock, "block_number", cp.Number, "error", err)
+		observeReplayCheckpointDiscarded()
+
+		return 1
+	}
+
+	c.lock.Lock()
+	c.last = cp.AvailBlock
+	c.lock.Unlock()
+
+	c.logger.Info("resuming the replay from the checkpoint", "avail_block_number", cp.AvailBlock, "block_number", cp.Number, "block_hash", cp.Hash)
+
+	return cp.AvailBlock + 1
+}
+
+// verifyReplayCheckpoint checks the block of the checkpoint is canonical in
+// the local chain, with the state root recorded, and the state at it is in
+// the database; the checkpoint of a block rewound out of the chain is no
+// longer good.
+func verifyReplayCheckpoint(cp ReplayCheckpoint, bc *blockchain.Blockchain, executor *state.Executor) error {
+	h, ok := bc.GetHeaderByNumber(cp.Number)
+	if !ok || h.Hash != cp.Hash {
+		return fmt.Errorf("block %d (%s) not found", cp.Number, cp.Hash)
+	}
+
+	if h.StateRoot != cp.StateRoot {
+		return fmt.Errorf("state root %s recorded, block has %s", cp.StateRoot, h.StateRoot)
+	}
+
+	if _, err := executor.State().NewSnapshotAt(cp.StateRoot); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// processed notes the Avail block processed by the replay, with the given
+// head of the local chain after it, and checkpoints it once the interval
+// passed since the last checkpoint.
+func (c *replayCheckpoints) processed(availBlock uint64, head *types.Header) {
+	if c == nil || c.path == "" {
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if availBlock < c.last+c.every {
+		return
+	}
+
+	c.last = availBlock
+	c.pending = &ReplayCheckpoint{AvailBlock: availBlock, Number: head.Number, Hash: head.Hash, StateRoot: head.StateRoot}
+
+	if c.writing {
+		return
+	}
+
+	c.writing = true
+	c.written.Add(1)
+
+	go c.write()
+}
+
+// write persists the pending checkpoints until there's none left.
+func (c *replayCheckpoints) write() {
+	defer c.written.Done()
+
+	for {
+		c.lock.Lock()
+		cp := c.pending
+		c.pending = nil
+
+		if cp == nil {
+			c.writing = false
+			c.lock.Unlock()
+
+			return
+		}
+		c.lock.Unlock()
+
+		if err := c.save(*cp); err != nil {
+			c.logger.Error("failed to persist the replay checkpoint", "avail_block_number", cp.AvailBlock, "error", err)
+			continue
+		}
+
+		observeReplayCheckpoint(cp.AvailBlock)
+	}
+}
+
+// save writes the checkpoint to the file.
+func (c *replayCheckpoints) save(cp ReplayCheckpoint) error {
+	bs, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o700); err != nil {
+		return err
+	}
+
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, bs, 0o600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, c.path)
+}
+
+// flush waits for the checkpoints taken to be persisted.
+func (c *replayCheckpoints) flush() {
+	if c == nil {
+		return
+	}
+
+	c.written.Wait()
+}
+
+// complete removes the checkpoint of the replay completed, for the node to
+// follow Avail on from its head on the next start.
+func (c *replayCheckpoints) complete() {
+	if c == nil || c.path == "" {
+		return
+	}
+
+	c.flush()
+
+	if err := os.Remove(c.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		c.logger.Error("failed to remove the replay checkpoint", "error", err)
+	}
+}
@@ -0,0 +1,201 @@
+package avail
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/availproject/op-evm/pkg/avail/testutil"
+	"github.com/availproject/op-evm/pkg/test"
+	avail_types "github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+// testReplayInterrupted interrupts the replay, the way the node crashing
+// in the middle of it does.
+type testReplayInterrupted struct{}
+
+// settleTestHistory settles on Avail the given number of blocks of a
+// sequencer, with an empty Avail block after each.
+func settleTestHistory(t *testing.T, fake *testutil.Fake, blocks int) {
+	t.Helper()
+
+	producer := newTestGenesisAvail(t)
+
+	for i := 0; i < blocks; i++ {
+		settleTestBlock(t, producer, fake, buildTestBlock(t, producer))
+		fake.Produce()
+	}
+}
+
+// newTestReplayingAvail returns the consensus of a node replaying the Avail
+// history, checkpointing it to the given directory every given number of
+// Avail blocks.
+func newTestReplayingAvail(t *testing.T, fake *testutil.Fake, appID avail_types.UCompact, dataDir string, every uint64) *Avail {
+	t.Helper()
+
+	db, err := test.NewMemoryStorage()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := newTestSyncingAvail(t, fake, appID, db)
+	d.replay = newReplayCheckpoints(dataDir, every, hclog.Default())
+
+	return d
+}
+
+// restartTestAvail returns the consensus of the node restarted on the
+// database of the given one.
+func restartTestAvail(d *Avail, dataDir string, every uint64) *Avail {
+	return &Avail{
+		ctx:         context.Background(),
+		logger:      d.logger,
+		notifyCh:    make(chan struct{}),
+		closeCh:     make(chan struct{}),
+		blockchain:  d.blockchain,
+		executor:    d.executor,
+		txpool:      d.txpool,
+		poolIndex:   d.poolIndex,
+		blockTime:   d.blockTime,
+		nodeType:    d.nodeType,
+		signKey:     d.signKey,
+		minerAddr:   d.minerAddr,
+		availClient: d.availClient,
+		availAppID:  d.availAppID,
+		forkChoice:  newForkChoice(d.blockchain, d.minerAddr, DefaultMaxReorgDepth, newSettledHead(d.blockchain, NewChallengeWindow(DefaultChallengeWindow), hclog.Default()), nil, hclog.Default()),
+		breaker:     newCircuitBreaker(DefaultCircuitBreakerConfig(), "", systemClock{}, hclog.Default()),
+		keys:        d.keys,
+		phases:      newTestPhaseMachine(),
+		replay:      newReplayCheckpoints(dataDir, every, hclog.Default()),
+	}
+}
+
+// interruptTestReplay replays the Avail history up to the Avail block of the
+// given number, where it's cut short.
+func interruptTestReplay(t *testing.T, d *Avail, at uint64) {
+	t.Helper()
+
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(testReplayInterrupted); !ok {
+				panic(r)
+			}
+		}
+	}()
+
+	_, err := d.syncNodeUntil(func(blk *avail_types.SignedBlock) bool {
+		if uint64(blk.Block.Header.Number) == at {
+			panic(testReplayInterrupted{})
+		}
+
+		return false
+	})
+
+	t.Fatalf("replay not interrupted: %v", err)
+}
+
+// readTestReplayCheckpoint reads the replay checkpoint persisted to the given
+// directory.
+func readTestReplayCheckpoint(t *testing.T, dataDir string) ReplayCheckpoint {
+	t.Helper()
+
+	bs, err := os.ReadFile(filepath.Join(dataDir, ReplayCheckpointFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var cp ReplayCheckpoint
+	if err := json.Unmarshal(bs, &cp); err != nil {
+		t.Fatal(err)
+	}
+
+	return cp
+}
+
+func TestReplayResumesFromCheckpoints(t *testing.T) {
+	const every = 3
+
+	appID := avail_types.NewUCompactFromUInt(1)
+	fake := testutil.NewFake(appID)
+
+	settleTestHistory(t, fake, 10)
+
+	// The reference node replays the history in one pass.
+	ref := newTestReplayingAvail(t, fake, appID, "", 0)
+	if _, err := syncTestAvail(ref, fake); err != nil {
+		t.Fatal(err)
+	}
+
+	dataDir := t.TempDir()
+	d := newTestReplayingAvail(t, fake, appID, dataDir, every)
+
+	// The replay is cut short at several points, off the checkpoints, and
+	// resumes from the last checkpoint each time.
+	for _, at := range []uint64{4, 11, 17} {
+		interruptTestReplay(t, d, at)
+
+		cp := readTestReplayCheckpoint(t, dataDir)
+		assert.Equal(t, at-at%every, cp.AvailBlock)
+		assert.GreaterOrEqual(t, d.blockchain.Header().Number, cp.Number)
+
+		d = restartTestAvail(d, dataDir, every)
+		assert.Equal(t, cp.AvailBlock+1, d.getNextAvailBlockNumber())
+	}
+
+	if _, err := syncTestAvail(d, fake); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, ref.blockchain.Header().Hash, d.blockchain.Header().Hash)
+	assert.Equal(t, ref.blockchain.Header().StateRoot, d.blockchain.Header().StateRoot)
+
+	// The replay completed, the checkpoint is gone.
+	_, err := os.Stat(filepath.Join(dataDir, ReplayCheckpointFileName))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestReplayCheckpointNotMatchingDatabase(t *testing.T) {
+	const every = 3
+
+	appID := avail_types.NewUCompactFromUInt(1)
+	fake := testutil.NewFake(appID)
+
+	settleTestHistory(t, fake, 6)
+
+	ref := newTestReplayingAvail(t, fake, appID, "", 0)
+	if _, err := syncTestAvail(ref, fake); err != nil {
+		t.Fatal(err)
+	}
+
+	dataDir := t.TempDir()
+	d := newTestReplayingAvail(t, fake, appID, dataDir, every)
+
+	interruptTestReplay(t, d, 8)
+
+	// The checkpoint records a state root other than the one of its block.
+	cp := readTestReplayCheckpoint(t, dataDir)
+	cp.StateRoot[0] ^= 0xff
+
+	bs, err := json.Marshal(cp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dataDir, ReplayCheckpointFileName), bs, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// The checkpoint is discarded, and the replay starts over.
+	d = restartTestAvail(d, dataDir, every)
+	assert.Equal(t, uint64(1), d.getNextAvailBlockNumber())
+
+	if _, err := syncTestAvail(d, fake); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, ref.blockchain.Header().Hash, d.blockchain.Header().Hash)
+}
@@ -0,0 +1,157 @@
+package avail
+
+import (
+	"sync"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/hashicorp/go-hclog"
+)
+
+// maxSenderBanDoublings is the number of times the ban of a repeatedly
+// failing sender doubles at most.
+const maxSenderBanDoublings = 6
+
+// senderRecord is the failure record of a sender.
+type senderRecord struct {
+	failures    uint64 // Consecutive failures of the transactions of the sender
+	bans        uint64 // Bans of the sender in a row, doubling each ban
+	bannedUntil uint64 // First block the sender is no longer banned in
+	lastBan     uint64 // Blocks the last ban lasted
+	lastFailure uint64 // Block of the last failure
+}
+
+// senderBans keeps the senders whose transactions keep failing, reverting
+// or erroring out in the block building, out of the transaction selection,
+// so that they can't keep the sequencer busy executing them in every slot.
+// A sender is banned for a number of blocks once its transactions failed a
+// number of times in a row; each ban in a row lasts twice the previous one,
+// and a successful transaction of the sender clears its record. The bans are
+// kept in memory and are local to the sequencer. The system transactions of
+// the node are exempt; the calls of the staking contract by any other sender
+// count like the rest.
+type senderBans struct {
+	threshold uint64
+	banBlocks uint64
+	own       func(types.Address) bool // Whether the address is of one of the node's keys
+	logger    hclog.Logger
+
+	lock    sync.Mutex
+	senders map[types.Address]*senderRecord
+}
+
+// newSenderBans returns the senderBans banning a sender for the given number
+// of blocks, at first, once its transactions failed the given number of
+// times in a row; a zero threshold or ban never bans. The system
+// transactions of the addresses owned are exempt.
+func newSenderBans(threshold, banBlocks uint64, own func(types.Address) bool, logger hclog.Logger) *senderBans {
+	return &senderBans{
+		threshold: threshold,
+		banBlocks: banBlocks,
+		own:       own,
+		logger:    logger,
+		senders:   make(map[types.Address]*senderRecord),
+	}
+}
+
+func (b *senderBans) enabled() bool {
+	return b != nil && b.threshold > 0 && b.banBlocks > 0
+}
+
+// Banned reports whether the sender is banned from the block of the given
+// number.
+func (b *senderBans) Banned(sender types.Address, number uint64) bool {
+	if !b.enabled() {
+		return false
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	r, ok := b.senders[sender]
+
+	return ok && number < r.bannedUntil
+}
+
+// fail notes a failed transaction of the sender in the block of the given
+// number, banning the sender once its failures in a row reach the
+// threshold.
+func (b *senderBans) fail(tx *types.Transaction, number uint64) {
+	if !b.enabled() || isSystemTx(tx, b.own) {
+		return
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	r, ok := b.senders[tx.From]
+	if !ok {
+		r = new(senderRecord)
+		b.senders[tx.From] = r
+	}
+
+	r.failures++
+	r.lastFailure = number
+
+	if r.failures < b.threshold {
+		return
+	}
+
+	doublings := r.bans
+	if doublings > maxSenderBanDoublings {
+		doublings = maxSenderBanDoublings
+	}
+
+	r.failures = 0
+	r.bans++
+	r.lastBan = b.banBlocks << doublings
+	r.bannedUntil = number + 1 + r.lastBan
+
+	observeSenderBan()
+
+	b.logger.Warn(
+		"banning sender of repeatedly failing transactions",
+		"sender", tx.From,
+		"threshold", b.threshold,
+		"bans", r.bans,
+		"banned_blocks", r.lastBan,
+		"banned_until", r.bannedUntil,
+	)
+}
+
+// succeed notes a successful transaction of the sender, clearing its record.
+func (b *senderBans) succeed(tx *types.Transaction) {
+	if !b.enabled() {
+		return
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	delete(b.senders, tx.From)
+}
+
+// prune forgets, as of the block of the given number, the senders that
+// haven't failed for as long as a first ban lasts, and whose ban, if any,
+// expired as long ago as it lasted.
+func (b *senderBans) prune(number uint64) {
+	if !b.enabled() {
+		return
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	for sender, r := range b.senders {
+		if number >= r.lastFailure+b.banBlocks && number >= r.bannedUntil+r.lastBan {
+			delete(b.senders, sender)
+		}
+	}
+}
+
+// Len returns the number of senders on record, banned or failing.
+func (b *senderBans) Len() int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return len(b.senders)
+}
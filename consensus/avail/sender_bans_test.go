@@ -0,0 +1,154 @@
+package avail
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/pkg/avail/testutil"
+	"github.com/availproject/op-evm/pkg/staking"
+	avail_types "github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSenderBansGrowAndExpire(t *testing.T) {
+	sender, node := types.StringToAddress("0x01"), types.StringToAddress("0x02")
+	bans := newSenderBans(2, 3, func(addr types.Address) bool { return addr == node }, hclog.NewNullLogger())
+
+	tx := &types.Transaction{From: sender, To: &types.ZeroAddress}
+
+	// The ban comes once the failures in a row reach the threshold.
+	bans.fail(tx, 10)
+	assert.False(t, bans.Banned(sender, 11))
+
+	bans.fail(tx, 10)
+	assert.True(t, bans.Banned(sender, 11))
+	assert.True(t, bans.Banned(sender, 13))
+	assert.False(t, bans.Banned(sender, 14))
+
+	// The next ban in a row lasts twice as long.
+	bans.fail(tx, 14)
+	bans.fail(tx, 14)
+	assert.True(t, bans.Banned(sender, 20))
+	assert.False(t, bans.Banned(sender, 21))
+
+	// The record goes once the ban expired as long ago as it lasted.
+	bans.prune(26)
+	assert.Equal(t, 1, bans.Len())
+
+	bans.prune(27)
+	assert.Zero(t, bans.Len())
+
+	// A successful transaction clears the failures.
+	bans.fail(tx, 30)
+	bans.succeed(tx)
+	bans.fail(tx, 30)
+	assert.False(t, bans.Banned(sender, 31))
+
+	// The system transactions of the node are exempt.
+	stake := &types.Transaction{From: node, To: &staking.AddrStakingContract}
+	for i := 0; i < 5; i++ {
+		bans.fail(stake, 40)
+	}
+
+	assert.False(t, bans.Banned(node, 41))
+
+	// The calls of the staking contract by anyone else count like the rest.
+	user := types.StringToAddress("0x03")
+	userStake := &types.Transaction{From: user, To: &staking.AddrStakingContract}
+	bans.fail(userStake, 50)
+	bans.fail(userStake, 50)
+	assert.True(t, bans.Banned(user, 51))
+}
+
+func TestSequencerBansFailingSender(t *testing.T) {
+	sw, fraudResolver, _ := newTestSequencerWorker(t, testutil.NewFake(avail_types.NewUCompactFromUInt(1)))
+	sw.senderBans = newSenderBans(3, 2, sw.keys.owns, sw.logger)
+
+	spammer, user := newTestSender(t, sw), newTestSender(t, sw)
+
+	// The contract creation running into an invalid opcode always reverts.
+	revert := func() *types.Transaction {
+		return spammer.sign(t, &types.Transaction{Input: []byte{0xfe}, Value: big.NewInt(0), Gas: 100_000}, 100)
+	}
+
+	addTxs := func(txs ...*types.Transaction) {
+		t.Helper()
+
+		pending := sw.txpool.Length() + uint64(len(txs))
+
+		for _, tx := range txs {
+			if err := sw.txpool.AddTx(tx); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		assert.Eventually(t, func() bool { return sw.txpool.Length() == pending }, 5*time.Second, 10*time.Millisecond)
+	}
+
+	account := accounts.Account{Address: common.Address(sw.nodeAddr)}
+
+	// writeBlock writes the next block and returns the number of the
+	// transactions of the spammer and of the user in it.
+	writeBlock := func() (spammed, used int) {
+		t.Helper()
+
+		if err := sw.writeBlock(fraudResolver, account, &keystore.Key{PrivateKey: sw.nodeSignKey}); err != nil {
+			t.Fatal(err)
+		}
+
+		blk, _ := sw.blockchain.GetBlockByNumber(sw.blockchain.Header().Number, true)
+		for _, tx := range blk.Transactions {
+			switch tx.From {
+			case spammer.addr:
+				spammed++
+			case user.addr:
+				used++
+			}
+		}
+
+		return spammed, used
+	}
+
+	addTxs(revert(), revert(), revert(), revert(), revert(), user.transfer(t, 10))
+
+	// The spammer goes first, until banned; the user isn't affected.
+	spammed, used := writeBlock()
+	assert.Equal(t, 3, spammed)
+	assert.Equal(t, 1, used)
+
+	// While banned, its transactions aren't attempted, left in the pool.
+	addTxs(user.transfer(t, 10))
+
+	for i := 0; i < 2; i++ {
+		spammed, used = writeBlock()
+		assert.Zero(t, spammed)
+		assert.Equal(t, 1-i, used)
+	}
+
+	assert.Equal(t, uint64(2), sw.txpool.Length())
+
+	// Once the ban expires, the spammer is attempted again, and banned for
+	// longer on failing again.
+	addTxs(revert())
+
+	spammed, _ = writeBlock()
+	assert.Equal(t, 3, spammed)
+	assert.True(t, sw.senderBans.Banned(spammer.addr, sw.blockchain.Header().Number+4))
+
+	// Calling the staking contract is no way around the ban.
+	stake, err := staking.StakeTx(spammer.addr, big.NewInt(0), string(Sequencer), 1_000_000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addTxs(spammer.sign(t, stake, 100))
+
+	spammed, _ = writeBlock()
+	assert.Zero(t, spammed)
+}
@@ -1,10 +1,12 @@
 package avail
 
 import (
-	"bytes"
+	"context"
 	"crypto/ecdsa"
+	"errors"
 	"fmt"
 	"log"
+	"math"
 	"sync/atomic"
 	"time"
 
@@ -13,6 +15,7 @@ import (
 	"github.com/0xPolygon/polygon-edge/state"
 	"github.com/0xPolygon/polygon-edge/txpool"
 	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/armon/go-metrics"
 	"github.com/availproject/op-evm/consensus/avail/validator"
 	"github.com/availproject/op-evm/consensus/avail/watchtower"
 	"github.com/availproject/op-evm/pkg/avail"
@@ -20,7 +23,6 @@ import (
 	"github.com/availproject/op-evm/pkg/blockchain"
 	"github.com/availproject/op-evm/pkg/snapshot"
 	"github.com/availproject/op-evm/pkg/staking"
-	"github.com/centrifuge/go-substrate-rpc-client/v4/signature"
 	avail_types "github.com/centrifuge/go-substrate-rpc-client/v4/types"
 	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/accounts/keystore"
@@ -33,33 +35,67 @@ const availBlockWindowLen = 7
 // TransitionInterface represents an interface for write transitions.
 type transitionInterface interface {
 	Write(txn *types.Transaction) error
+	TotalGas() uint64
+	Receipts() []*types.Receipt
 }
 
 // SequencerWorker represents the struct for a Sequencer Worker.
 // A Sequencer Worker is responsible for handling operations like running the block production process,
 // snapshot processing, fraud detection and resolution, handling sequencer staking/unstaking, and more.
 type SequencerWorker struct {
-	logger                     hclog.Logger
-	blockchain                 *blockchain.Blockchain
-	executor                   *state.Executor
-	txpool                     *txpool.TxPool
-	snapshotter                snapshot.Snapshotter
-	snapshotDistributor        snapshot.Distributor
-	apq                        staking.ActiveParticipants
-	availAppID                 avail_types.UCompact
-	availClient                avail.Client
-	availAccount               signature.KeyringPair
-	nodeSignKey                *ecdsa.PrivateKey
-	nodeAddr                   types.Address
-	nodeType                   MechanismType
-	stakingNode                staking.Node
-	availSender                avail.Sender
-	fraudServer                *FraudServer
-	closeCh                    <-chan struct{}
-	blockTime                  time.Duration // Minimum block generation time in seconds
-	blockProductionIntervalSec uint64
-	blockProductionEnabled     *atomic.Bool
-	currentNodeSyncIndex       uint64
+	logger                 hclog.Logger
+	blockchain             *blockchain.Blockchain
+	executor               *state.Executor
+	txpool                 *txpool.TxPool
+	poolIndex              *txPoolIndex
+	snapshotter            snapshot.Snapshotter
+	snapshotDistributor    snapshot.Distributor
+	apq                    staking.ActiveParticipants
+	availAppID             avail_types.UCompact
+	availClient            avail.Client
+	availAccount           avail.SignatureProvider
+	nodeSignKey            *ecdsa.PrivateKey
+	nodeAddr               types.Address
+	nodeType               MechanismType
+	stakingNode            staking.Node
+	availSender            avail.Sender
+	balanceMonitor         *avail.BalanceMonitor
+	fraudServer            *FraudServer
+	ctx                    context.Context
+	closeCh                <-chan struct{}
+	shutdown               *gracefulShutdown
+	blockTime              time.Duration // Target time between the produced blocks
+	production             ProductionConfig
+	overrides              *productionOverrides
+	catchUp                CatchUpConfig
+	progress               *syncProgress
+	disputes               *disputeGuard
+	disputeWatcher         *disputeWatcher
+	frauds                 *fraudCatalog
+	unsettled              *unsettledQueue
+	settlement             *settlementLag
+	breaker                *circuitBreaker
+	keys                   *keyRotation
+	readiness              *readiness
+	forkChoice             *forkChoice
+	phases                 *phaseMachine
+	txArrivals             *txArrivals
+	senderBans             *senderBans
+	metrics                *sequencerMetrics
+	leaders                *staking.LeaderSchedule
+	activeSet              *staking.ActiveSet
+	slots                  chan uint64 // Heights of the Avail blocks making the production slots
+	clock                  clock
+	blockProductionEnabled *atomic.Bool
+	currentNodeSyncIndex   uint64
+	fraudTip               uint64
+	fraudQuorum            uint64
+	lastTxPoolSweep        uint64 // Head of the chain at the last sweep of the txpool
+	slotAvailHeight        uint64 // Height of the Avail block of the slot the blocks are produced in
+
+	// byzantine makes the blocks of the policy of a byzantine sequencer
+	// invalid; it's nil for the honest one.
+	byzantine *byzantineSequencer
 
 	// availBlockNumWhenStaked is a used to fence the sequencing logic until
 	// this node is staked and there is a start of a fresh new Avail block window.
@@ -67,6 +103,10 @@ type SequencerWorker struct {
 	// yet or it's not visible in the blockchain yet. The value gets set when the
 	// staking is visible and it must not be modified afterwards.
 	availBlockNumWhenStaked *int64
+
+	// blockPhaseHook, if set, is called as the block in flight enters each
+	// phase; the tests use it to shut down mid-block.
+	blockPhaseHook func(blockPhase)
 }
 
 // Run starts the main operation of the SequencerWorker.
@@ -74,24 +114,28 @@ type SequencerWorker struct {
 // block production, snapshot processing, and more.
 // Errors from these tasks are handled and appropriately logged.
 func (sw *SequencerWorker) Run(account accounts.Account, key *keystore.Key) error {
-	t := new(atomic.Int64)
+	// The sequencer syncs the chain first; the consensus is halted once
+	// closed, while the role switch leaves it running. An illegal transition
+	// is logged by the phase machine.
+	_ = sw.phases.enter(PhaseSyncing)
 
-	// Return same seed value for the period of  `availWindowLen`.
-	randomSeedFn := func() int64 {
-		return t.Load() / availBlockWindowLen
-	}
+	t := new(atomic.Int64)
 
-	activeSequencersQuerier := staking.NewCachingRandomizedActiveSequencersQuerier(randomSeedFn, sw.apq)
-	validator := validator.New(sw.blockchain, sw.nodeAddr, sw.logger)
-	watchTower := watchtower.New(sw.blockchain, sw.executor, sw.txpool, sw.logger, types.Address(account.Address), key.PrivateKey)
+	// The leader schedule follows the active set, switching over to the
+	// one refreshed by the staking changes at the start of the next slot.
+	activeSequencersQuerier := sw.activeSet
+	validator := validator.New(sw.blockchain, sw.nodeAddr, sw.logger, validator.WithMaxTimestampDrift(sw.production.MaxTimestampDrift), validator.WithClock(sw.clock.Now))
+	watchTower := watchtower.New(sw.blockchain, sw.executor, sw.txpool, sw.logger, types.Address(account.Address), key.PrivateKey, sw.production.MaxBlockSizeBytes, watchtower.WithMaxTimestampDrift(sw.production.MaxTimestampDrift), watchtower.WithClock(sw.clock.Now))
 
-	fraudResolver := NewFraudResolver(sw.logger, sw.blockchain, sw.executor, sw.txpool, watchTower, sw.blockProductionEnabled, sw.nodeAddr, sw.nodeSignKey, sw.availSender, sw.nodeType)
+	fraudResolver := NewFraudResolver(sw.ctx, sw.logger, sw.blockchain, sw.executor, sw.txpool, sw.poolIndex, watchTower, sw.forkChoice.settled, sw.frauds, sw.forkChoice.feed, sw.blockProductionEnabled, sw.nodeAddr, sw.nodeSignKey, sw.availSender, sw.fraudTip, sw.fraudQuorum, sw.nodeType)
 
-	callIdx, err := avail.FindCallIndex(sw.availClient)
-	if err != nil {
+	// The call index is re-discovered whenever Avail runtime gets upgraded.
+	if _, err := avail.FindCallIndex(sw.ctx, sw.availClient); err != nil {
 		return fmt.Errorf("failed to discover avail call index: %s", err)
 	}
 
+	decoder := avail.NewBlockDecoder(sw.availClient, sw.availAppID, sw.logger)
+
 	// XXX: Remove this when Avail balance can be sustained reasonably.
 	go func() {
 		for {
@@ -110,36 +154,75 @@ func (sw *SequencerWorker) Run(account accounts.Account, key *keystore.Key) erro
 		}
 	}()
 
+	// Keep track of the order the transactions arrive in the pool.
+	go func() {
+		if err := sw.txArrivals.track(sw.ctx, sw.txpool); err != nil {
+			sw.logger.Error("failed to track txpool arrivals", "error", err)
+		}
+	}()
+
+	// The disputes begun before the start are left to the watcher as well.
+	if sw.disputeWatcher != nil {
+		if err := sw.disputeWatcher.seed(staking.NewDisputeResolution(sw.blockchain, sw.executor, nil, sw.logger)); err != nil {
+			sw.logger.Warn("failed to pick up the open disputes", "error", err)
+		}
+	}
+
 	// Check if block production should be stopped due to inbound dispute resolution tx found in txpool.
 	go fraudResolver.ShouldStopProducingBlocks(sw.apq)
 
+	// The readiness gate: stake the sequencer, if need be, and follow the
+	// chain only until the stake shows up in the staking contract.
+	if err := sw.stakeIfNeeded(); err != nil {
+		sw.logger.Error("failed to stake the sequencer; following the chain until staked", "error", err)
+	}
+
 	// Write blocks to the local blockchain and avail in intervals uless block production is stopped.
-	go sw.runWriteBlocksLoop(activeSequencersQuerier, fraudResolver, account, key)
+	go sw.runWriteBlocksLoopWhenReady(activeSequencersQuerier, fraudResolver, account, key)
+
+	// Far behind the Avail head, catch up before following the live blocks.
+	cursor, err := sw.catchUpWithAvail(decoder, validator, fraudResolver, sw.currentNodeSyncIndex)
+	if errors.Is(err, blockchain.ErrStorage) {
+		return err
+	}
+
+	if err != nil {
+		sw.logger.Warn("failed to catch up with Avail; following the live blocks", "avail_cursor", cursor, "error", err)
+	}
 
 	// BlockStream watcher must be started after the staking is done. Otherwise
 	// the stream is out-of-sync.
-	availBlockStream := sw.availClient.BlockStream(sw.currentNodeSyncIndex)
+	availBlockStream := sw.availClient.BlockStream(sw.ctx, cursor)
 	defer availBlockStream.Close()
 
+	// The stream channel is closed once the run context is canceled; the
+	// shutdown itself is handled on the close channel.
+	availBlockCh := availBlockStream.Chan()
+
 	sw.logger.Info("Block stream successfully started.", "node_type", sw.nodeType)
 
 	for {
 		var blk *avail_types.SignedBlock
 
 		select {
-		case blk = <-availBlockStream.Chan():
+		case b, ok := <-availBlockCh:
+			if !ok {
+				availBlockCh = nil
+				continue
+			}
+
 			// Processed below in the for-loop's main body.
+			blk = b
 
 		case ss := <-sw.snapshotDistributor.Receive():
-			err = sw.processStorageSnapshot(ss)
-			if err != nil {
+			if err := sw.processStorageSnapshot(ss); err != nil {
 				return err
 			}
 
 			continue
 
 		case <-sw.closeCh:
-			if err := sw.stakingNode.UnStake(sw.nodeSignKey); err != nil {
+			if err := sw.stakingNode.UnStake(sw.keys.signer()); err != nil {
 				sw.logger.Error("failed to unstake the node", "error", err)
 				return err
 			}
@@ -149,11 +232,18 @@ func (sw *SequencerWorker) Run(account accounts.Account, key *keystore.Key) erro
 		// Time `t` is [mostly] monotonic clock, backed by Avail. It's used for all
 		// time sensitive logic in sequencer, such as block generation timeouts.
 		t.Store(int64(blk.Block.Header.Number))
+		sw.progress.seen(uint64(blk.Block.Header.Number))
+
+		// Keep an eye on the Avail account balance; the low-balance policy
+		// may pause the block production until the account is topped up.
+		if err := sw.balanceMonitor.OnAvailBlock(sw.ctx, uint64(blk.Block.Header.Number)); err != nil {
+			sw.logger.Warn("failed to poll Avail account balance", "error", err)
+		}
 
 		// So this is the situation...
 		// Here we are not looking for if current node should be producing or not producing the block.
 		// What we are interested, prior to fraud resolver, if block is containing fraud check request.
-		edgeBlks, err := avail.BlockFromAvail(blk, sw.availAppID, callIdx, sw.logger)
+		edgeBlks, err := decoder.Decode(sw.ctx, blk)
 		if len(edgeBlks) == 0 && err != nil {
 			sw.logger.Error("cannot extract Edge block from Avail block", "block_number", blk.Block.Header.Number, "error", err)
 			// It is expected that not all Avail blocks contain an OpEVM block. On any other error,
@@ -164,9 +254,22 @@ func (sw *SequencerWorker) Run(account accounts.Account, key *keystore.Key) erro
 			}
 		}
 
+		// Every node follows the leader schedule on the same Avail blocks; the
+		// leader's turn passes on when its blocks don't show up.
+		sw.advanceActiveSet(uint64(blk.Block.Header.Number))
+		sw.leaders.Observe(uint64(blk.Block.Header.Number), len(edgeBlks) > 0)
+
+		// The fork choice prefers the blocks of the scheduled leader.
+		var leader types.Address
+		if len(edgeBlks) > 0 {
+			leader = sw.scheduledLeader()
+		}
+
 		// Write down blocks received from avail to make sure we're synced before processing with the
 		// fraud check or writing down new blocks...
-		for _, edgeBlk := range edgeBlks {
+		for _, decoded := range edgeBlks {
+			edgeBlk := decoded.Block
+
 			// In case that dispute resolution is ended, please make sure to set fraud resolution block
 			// to nil so whole chain and corrupted node can continue making our day good!
 			// Block does not have to be written into the chain as it's already written with syncer...
@@ -179,6 +282,12 @@ func (sw *SequencerWorker) Run(account accounts.Account, key *keystore.Key) erro
 				fraudResolver.EndDisputeResolution()
 			}
 
+			// The defenses of the disputed sequencers are for the dispute
+			// watcher only.
+			if fraudResolver.IsDisputeDefenseBlock(edgeBlk) {
+				continue
+			}
+
 			// We cannot write the fraud proof block to the blockchain at all due to following reasons:
 			// - Block number already exists and block won't be written.
 			// - Watchtower has syncer disabled and when writing block, next block can come in rejecting this block.
@@ -187,22 +296,15 @@ func (sw *SequencerWorker) Run(account accounts.Account, key *keystore.Key) erro
 			_, blkAlreadyKnown := sw.blockchain.GetHeaderByHash(edgeBlk.Header.Hash)
 			if !blkAlreadyKnown || !fraudResolver.IsFraudProofBlock(edgeBlk) {
 				if err := validator.Check(edgeBlk); err == nil {
-					if err := sw.blockchain.WriteBlock(edgeBlk, sw.nodeType.String()); err != nil {
-						sw.logger.Warn(
-							"failed to write edge block received from avail",
-							"edge_block_hash", edgeBlk.Hash(),
-							"error", err,
-						)
-					} else {
-						// Clear out the executed transactions from the TxPool after the block
-						// has been written.
-						sw.txpool.ResetWithHeaders(edgeBlk.Header)
-						sw.logger.Debug("wrote block to blockchain from Avail", "block_number", edgeBlk.Header.Number)
+					if err := sw.applyAvailBlock(decoded, inclusionOf(blk, decoded, leader)); err != nil {
+						return err
 					}
 				} else {
 					sw.logger.Warn(
 						"failed to validate edge block received from avail",
 						"edge_block_hash", edgeBlk.Hash(),
+						"extrinsic_index", decoded.ExtrinsicIndex,
+						"submitter", decoded.Submitter.ToHexString(),
 						"error", err,
 					)
 				}
@@ -212,41 +314,34 @@ func (sw *SequencerWorker) Run(account accounts.Account, key *keystore.Key) erro
 		sw.logger.Warn("Current header", "number", sw.blockchain.Header().Number)
 
 		// Go through the blocks from avail and make sure to set fraud block in case it was discovered...
-		fraudResolver.CheckAndSetFraudBlock(edgeBlks)
-
-		// Periodically verify that we are staked, before proceeding with sequencer
-		// logic. In the unexpected case of being slashed and dropping below the
-		// required sequencer staking threshold, we must stop processing, because
-		// otherwise we just get slashed more.
-		sequencerStaked, sequencerError := activeSequencersQuerier.Contains(sw.nodeAddr)
-		if sequencerError != nil {
-			sw.logger.Error("failed to check if my account is among active staked sequencers; cannot continue", "error", sequencerError)
-			continue
+		fraudResolver.CheckAndSetFraudBlock(uint64(blk.Block.Header.Number), edgeBlks)
+
+		// The fraud proofs decide the outcome of the disputes left open.
+		if sw.disputeWatcher != nil {
+			sw.disputeWatcher.observeFraudProofs(edgeBlks)
+			sw.disputeWatcher.observeDefenses(uint64(blk.Block.Header.Number), edgeBlks)
 		}
 
-		if !sequencerStaked {
-			sw.logger.Warn("my account is not among active staked sequencers; cannot continue", "address", sw.nodeAddr.String())
-			continue
-		} else {
-			if sw.availBlockNumWhenStaked == nil {
-				sw.availBlockNumWhenStaked = new(int64)
-				*sw.availBlockNumWhenStaked = t.Load()
-				sw.logger.Debug("staking observed in the blockchain; storing avail block number", "block_number", blk.Block.Header.Number)
-			}
+		// The blocks of this sequencer accused of fraud get its defense.
+		sw.defendDisputes(fraudResolver)
 
-			// Only proceed with the sequencing logic after the "join window" changes to
-			// next one. This logic is needed because on a new node, that joins in the
-			// middle of the Avail block window, the ActiveSequencer cache is not
-			// consistent with the other nodes in the network. When all the nodes renew
-			// their active sequencer list on a start of the new block window, they gain
-			// coherent view into who is the next "leader" (i.e. the active sequencer
-			// allowed to produce a block).
-			if sw.nodeType != BootstrapSequencer && (*sw.availBlockNumWhenStaked/availBlockWindowLen) == (t.Load()/availBlockWindowLen) {
-				sw.logger.Debug("sequencer account staked, but waiting for a fresh Avail block window after joining the network")
-				continue
-			} else {
-				sw.logger.Debug("past the point of sequencer ramp up window", "block_number", blk.Block.Header.Number)
-			}
+		// Pause the block production while this sequencer is under dispute.
+		sw.disputes.Observe()
+
+		// The circuit breaker counts the fraud proofs and the disputes.
+		sw.breaker.observe(uint64(blk.Block.Header.Number), edgeBlks)
+
+		// The blocks past their challenge window settle.
+		sw.forkChoice.settle(uint64(blk.Block.Header.Number))
+
+		// The sequencing logic waits for the stake to show up in the staking contract.
+		ready := sw.observeReadiness(activeSequencersQuerier, t.Load())
+
+		// The phase follows the state observed at the Avail block.
+		sw.updatePhase()
+
+		if !ready {
+			continue
 		}
 
 		// Will check the block for fraudlent behaviour and slash parties accordingly.
@@ -274,14 +369,81 @@ func (sw *SequencerWorker) Run(account accounts.Account, key *keystore.Key) erro
 			sw.logger.Debug("it's not my turn; disable block producing", "t", availBlockNum)
 			sw.blockProductionEnabled.Store(false)
 		}
+
+		// The Avail block makes the next production slot.
+		sw.announceSlot(uint64(availBlockNum))
 	}
 }
 
+// applyAvailBlock writes the validated edge block received from Avail to the
+// chain through the fork choice, and updates the txpool accordingly. The
+// transactions of the honest users in the blocks a dispute resolution block
+// forks out of the chain go back into the txpool. It returns an error only
+// on a storage failure.
+func (sw *SequencerWorker) applyAvailBlock(decoded avail.EdgeBlock, inclusion blockInclusion) error {
+	oldHead := sw.blockchain.Header()
+
+	write, err := writeAvailBlock(sw.forkChoice, sw.breaker, decoded, inclusion, sw.nodeType.String(), sw.logger)
+	if err != nil {
+		return err
+	}
+
+	edgeBlk := decoded.Block
+
+	// The blocks seen again settle the own blocks written ahead of Avail.
+	sw.settleAvailBlock(edgeBlk)
+
+	// The staking changes take effect at the next slot.
+	stageActiveSet(sw.activeSet, sw.blockchain, edgeBlk, inclusion.availBlock, sw.logger)
+
+	if write != blockWritten {
+		return nil
+	}
+
+	// Clear out the executed transactions from the TxPool after the block
+	// has been written.
+	sw.txpool.ResetWithHeaders(edgeBlk.Header)
+
+	if isDisputeResolutionFork(edgeBlk) && edgeBlk.ParentHash() != oldHead.Hash {
+		requeueDiscardedTxs(sw.blockchain, sw.executor, sw.txpool, sw.poolIndex, oldHead, edgeBlk.ParentHash(), sw.logger)
+	}
+
+	return nil
+}
+
+// scheduledLeader returns the sequencer scheduled to lead the current slot,
+// out of the active staked sequencers, or the zero address if there's none.
+func (sw *SequencerWorker) scheduledLeader() types.Address {
+	sequencers, err := sw.activeSet.Get()
+	if err != nil {
+		sw.logger.Error("querying staked sequencers failed", "error", err)
+		return types.ZeroAddress
+	}
+
+	leader, err := sw.leaders.Leader(sequencers)
+	if err != nil {
+		return types.ZeroAddress
+	}
+
+	return leader
+}
+
+// advanceActiveSet switches the active set over to the one staged by the
+// staking changes once the Avail block at the given height starts its slot.
+func (sw *SequencerWorker) advanceActiveSet(availHeight uint64) {
+	if !sw.activeSet.Advance(availHeight) {
+		return
+	}
+
+	sequencers, _ := sw.activeSet.Get()
+	sw.logger.Info("active sequencer set changed", "avail_block_number", availHeight, "sequencers", len(sequencers))
+}
+
 // IsNextSequencer checks if the current worker is the next sequencer.
 // It queries the staked sequencers from the active sequencers querier, and
-// compares the first sequencer with the node address of the current worker.
+// compares the leader of the current slot with the node address of the current worker.
 // If an error occurs during the querying, it logs the error and returns false.
-// It returns true if the first sequencer is the current worker, false otherwise.
+// It returns true if the leader is the current worker, false otherwise.
 func (sw *SequencerWorker) IsNextSequencer(activeSequencersQuerier staking.ActiveSequencers) bool {
 	sequencers, err := activeSequencersQuerier.Get()
 	if err != nil {
@@ -289,7 +451,14 @@ func (sw *SequencerWorker) IsNextSequencer(activeSequencersQuerier staking.Activ
 		return false
 	}
 
-	return bytes.Equal(sequencers[0].Bytes(), sw.nodeAddr.Bytes())
+	leader, err := sw.leaders.Leader(sequencers)
+	if err != nil {
+		sw.logger.Error("no leader for the slot", "error", err)
+		return false
+	}
+
+	// Both keys take the turns of the node while its key rotates.
+	return sw.keys.owns(leader)
 }
 
 // processStorageSnapshot processes a snapshot received from a peer.
@@ -340,7 +509,7 @@ func (sw *SequencerWorker) processStorageSnapshot(ss *snapshot.Snapshot) error {
 // If the balance is less than 5 AVL, it deposits more tokens. Otherwise, it logs the healthy balance.
 // It returns an error if one occurs during the process.
 func (sw *SequencerWorker) ensureEnoughAvailBalance() error {
-	balance, err := avail.GetBalance(sw.availClient, sw.availAccount)
+	balance, err := avail.GetBalance(sw.ctx, sw.availClient, sw.availAccount)
 	if err != nil {
 		return err
 	}
@@ -350,7 +519,7 @@ func (sw *SequencerWorker) ensureEnoughAvailBalance() error {
 		maxUint64 := uint64(^uint64(0) >> 1)
 		sw.logger.Info("account balance for Avail account has dropped below 5 AVL; depositing more tokens", "balance", float64(balance.Uint64()/avail.AVL), "deposit", float64(maxUint64/avail.AVL))
 
-		err := avail.DepositBalance(sw.availClient, sw.availAccount, maxUint64, 0)
+		err := avail.DepositBalance(sw.ctx, sw.availClient, sw.availAccount, maxUint64)
 		if err != nil {
 			return err
 		}
@@ -361,45 +530,58 @@ func (sw *SequencerWorker) ensureEnoughAvailBalance() error {
 	return nil
 }
 
-// runWriteBlocksLoop runs a loop that produces blocks at an interval defined in the blockProductionIntervalSec config option.
-// The loop listens for a tick from a ticker and a signal from the close channel.
-// When it receives a tick and block production is enabled, and the chain is not disabled,
-// and the current worker is the next sequencer, it writes a block.
-// When it receives a signal from the close channel, it stops the loop.
+// runWriteBlocksLoop runs a loop that produces blocks in the slots defined by the Avail blocks.
+// Every new Avail block, handed over by Run once its blocks are applied, makes a slot in which
+// the leader produces at most one block, provided block production is enabled and the chain is
+// not disabled. Should the Avail block stream stall beyond the slot stall timeout, the slots fall
+// back to the wall clock, every block time, until the Avail blocks come in again.
+// When it receives a signal from the close channel, it stops the loop; the block in flight is
+// tracked by the graceful shutdown, which waits for it to settle.
 func (sw *SequencerWorker) runWriteBlocksLoop(activeSequencersQuerier staking.ActiveSequencers, fraudResolver *Fraud, myAccount accounts.Account, signKey *keystore.Key) {
-	t := time.NewTicker(time.Duration(sw.blockProductionIntervalSec) * time.Second)
+	var (
+		lastSlot   uint64
+		lastSlotAt = sw.clock.Now()
+		stalled    bool
+	)
+
+	// The chain the production starts from counts as settled.
+	sw.settlement.settle(sw.blockchain.Header().Number)
+
+	t := sw.clock.NewTicker(sw.blockTime)
 	defer t.Stop()
 
 	for {
 		select {
-		case <-t.C:
-			if !sw.blockProductionEnabled.Load() {
+		case slot := <-sw.slots:
+			// The stream may deliver an Avail block more than once.
+			if slot <= lastSlot {
 				continue
 			}
 
-			// Means we are processing the disputed (fraud) block verification and should not create new
-			// blocks anywhere...
-			if fraudResolver.IsChainDisabled() {
-				continue
+			lastSlot, lastSlotAt = slot, sw.clock.Now()
+			sw.slotAvailHeight = slot
+
+			if stalled {
+				stalled = false
+				sw.logger.Info("Avail blocks are coming in again; back to the Avail slots", "avail_block_number", slot)
 			}
 
-			if !sw.IsNextSequencer(activeSequencersQuerier) {
-				sw.logger.Warn(
-					"it is not my turn to produce the block",
-					"sequencer_addr", myAccount.Address,
-				)
+			if !sw.produceInSlot(activeSequencersQuerier, fraudResolver, myAccount, signKey) {
+				return
+			}
+
+		case now := <-t.C():
+			if now.Sub(lastSlotAt) < sw.production.SlotStallTimeout {
 				continue
-			} else {
-				sw.logger.Warn(
-					"it is my turn to produce the block",
-					"sequencer_addr", myAccount.Address,
-				)
 			}
 
-			sw.logger.Debug("writing a new block", "sequencer_addr", myAccount.Address)
+			if !stalled {
+				stalled = true
+				sw.logger.Warn("Avail block stream stalled; falling back to the wall clock slots", "last_avail_block_number", lastSlot, "since", lastSlotAt)
+			}
 
-			if err := sw.writeBlock(fraudResolver, myAccount, signKey); err != nil {
-				sw.logger.Error("failed to mine block", "error", err)
+			if !sw.produceInSlot(activeSequencersQuerier, fraudResolver, myAccount, signKey) {
+				return
 			}
 
 		case <-sw.closeCh:
@@ -409,11 +591,209 @@ func (sw *SequencerWorker) runWriteBlocksLoop(activeSequencersQuerier staking.Ac
 	}
 }
 
+// runWriteBlocksLoopWhenReady runs the runWriteBlocksLoop once the sequencer
+// is ready to produce blocks.
+func (sw *SequencerWorker) runWriteBlocksLoopWhenReady(activeSequencersQuerier staking.ActiveSequencers, fraudResolver *Fraud, myAccount accounts.Account, signKey *keystore.Key) {
+	select {
+	case <-sw.readiness.wait():
+	case <-sw.closeCh:
+		return
+	}
+
+	sw.runWriteBlocksLoop(activeSequencersQuerier, fraudResolver, myAccount, signKey)
+}
+
+// announceSlot hands the slot of the Avail block at the given height over to
+// the block production; a slot still waiting to be taken is superseded.
+func (sw *SequencerWorker) announceSlot(availHeight uint64) {
+	for {
+		select {
+		case sw.slots <- availHeight:
+			return
+		default:
+		}
+
+		select {
+		case <-sw.slots:
+		default:
+		}
+	}
+}
+
+// produceInSlot produces the block of the slot, if it's this node's turn and
+// nothing holds the production back. It reports false once the production
+// must stop for good.
+func (sw *SequencerWorker) produceInSlot(activeSequencersQuerier staking.ActiveSequencers, fraudResolver *Fraud, myAccount accounts.Account, signKey *keystore.Key) bool {
+	// The txpool is swept in between the blocks, never under the one built.
+	sw.sweepTxPoolIfDue()
+
+	// The slots go to the unsettled blocks first; new blocks are
+	// built on top of them only once they settle.
+	if sw.unsettled.Len() > 0 {
+		if !sw.shutdown.beginBlock() {
+			return false
+		}
+
+		err := sw.resubmitUnsettled()
+		sw.shutdown.endBlock()
+
+		if err != nil {
+			sw.logger.Error("failed to resubmit unsettled blocks", "unsettled_blocks", sw.unsettled.Len(), "error", err)
+		}
+
+		return true
+	}
+
+	if !sw.blockProductionEnabled.Load() {
+		return true
+	}
+
+	if !sw.readiness.Ready() {
+		sw.logger.Debug("block production waiting for the sequencer to be ready", "reason", sw.readiness.Reason())
+		return true
+	}
+
+	if sw.disputes.Paused() {
+		sw.logger.Debug("block production paused due to a dispute against the sequencer", "dispute_state", sw.disputes.State())
+		return true
+	}
+
+	// Blocks are produced on top of the tip only.
+	if sw.progress.CatchingUp() {
+		sw.logger.Debug("block production deferred while catching up with Avail")
+		return true
+	}
+
+	// Submissions to Avail would fail anyway when the account runs out of funds.
+	if sw.balanceMonitor.Paused() {
+		sw.logger.Debug("block production paused due to low Avail account balance")
+		return true
+	}
+
+	// The tripped circuit breaker halts the production until resumed.
+	if sw.breaker.Tripped() {
+		sw.logger.Debug("block production halted by the circuit breaker")
+		return true
+	}
+
+	// Blocks are held back while too many of them wait to be seen settled
+	// on Avail.
+	paused := sw.settlement.observe(sw.blockchain.Header().Number)
+	sw.metrics.settlementLag(sw.settlement.Lag())
+
+	if paused {
+		sw.logger.Debug("block production paused due to the lag of the settlement on Avail", "lag", sw.settlement.Lag())
+		return true
+	}
+
+	// Means we are processing the disputed (fraud) block verification and should not create new
+	// blocks anywhere...
+	if fraudResolver.IsChainDisabled() {
+		return true
+	}
+
+	// The key rotation moves on ahead of the block, which is signed by the
+	// key of its height.
+	myAccount, signKey = sw.signerAccount(sw.blockchain.Header().Number + 1)
+
+	if !sw.IsNextSequencer(activeSequencersQuerier) {
+		sw.logger.Warn(
+			"it is not my turn to produce the block",
+			"sequencer_addr", myAccount.Address,
+		)
+
+		// The disputes the leaders failed to end are ended out of the slot.
+		sw.submitEscalatedDisputeEnds(fraudResolver, types.Address(myAccount.Address), signKey.PrivateKey)
+
+		return true
+	}
+
+	sw.logger.Warn(
+		"it is my turn to produce the block",
+		"sequencer_addr", myAccount.Address,
+	)
+
+	if !sw.shouldProduceBlock() {
+		sw.logger.Debug("no transactions pending; skipping the slot", "sequencer_addr", myAccount.Address)
+		return true
+	}
+
+	// No new blocks once shutting down.
+	if !sw.shutdown.beginBlock() {
+		return false
+	}
+
+	sw.logger.Debug("writing a new block", "sequencer_addr", myAccount.Address)
+
+	err := sw.writeBlock(fraudResolver, myAccount, signKey)
+	sw.shutdown.endBlock()
+
+	if errors.Is(err, errShuttingDown) {
+		sw.logger.Info("abandoned the block being built due to shutdown")
+		return false
+	}
+
+	if err != nil {
+		sw.logger.Error("failed to mine block", "error", err)
+		sw.metrics.missedSlot()
+
+		if sw.haltsBlockProduction(err) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// shouldProduceBlock reports whether a block is to be produced in the slot.
+// Unless the empty blocks are produced, the slot is skipped when there are no
+// transactions pending, up until the chain has been idle for the max idle
+// interval. Dispute resolution and staking transactions go through the pool
+// as well, so they always force the production.
+func (sw *SequencerWorker) shouldProduceBlock() bool {
+	if sw.production.ProduceEmptyBlocks || sw.txpool.Length() > 0 {
+		return true
+	}
+
+	// Heartbeat block.
+	parentTime := time.Unix(int64(sw.blockchain.Header().Timestamp), 0)
+
+	return sw.clock.Now().Sub(parentTime) >= sw.production.MaxIdleInterval
+}
+
+// haltsBlockProduction applies the halt policy to the error of a block that
+// couldn't be written; it reports whether block production must stop.
+func (sw *SequencerWorker) haltsBlockProduction(err error) bool {
+	switch {
+	case errors.Is(err, avail.ErrSigningFailed):
+		// The blocks can't be submitted to Avail until the signer is fixed.
+		sw.logger.Error("Avail signer failed; stopping block production", "error", err)
+		return true
+	case errors.Is(err, avail.ErrInsufficientBalance):
+		// Have the balance monitor apply the low-balance policy right away
+		// instead of on its next poll.
+		if sw.balanceMonitor != nil {
+			if err := sw.balanceMonitor.Poll(sw.ctx); err != nil {
+				sw.logger.Warn("failed to poll Avail account balance", "error", err)
+			}
+		}
+	case errors.Is(err, avail.ErrConnection), errors.Is(err, avail.ErrSubscriptionClosed):
+		sw.logger.Warn("Avail unavailable; block will be produced again on the next tick", "error", err)
+	case errors.Is(err, avail.ErrBlobTooLarge), errors.Is(err, avail.ErrInvalidTransaction):
+		// Not transient, but specific to the block; the next one may pass.
+		sw.logger.Error("block rejected by Avail", "error", err)
+	}
+
+	return false
+}
+
 // writeBlock writes a block.
 // It generates a new block based on transactions from the pool, and writes the block to the blockchain.
 // It also distributes the snapshot of the block to other sequencers over P2P.
+// Once shutting down, the block is abandoned before its submission to Avail with errShuttingDown.
 // It returns an error if one occurs during the process.
 func (sw *SequencerWorker) writeBlock(fraudResolver *Fraud, myAccount accounts.Account, signKey *keystore.Key) error {
+	start := time.Now()
 	parent := sw.blockchain.Header()
 
 	header := &types.Header{
@@ -422,7 +802,7 @@ func (sw *SequencerWorker) writeBlock(fraudResolver *Fraud, myAccount accounts.A
 		Miner:      myAccount.Address.Bytes(),
 		Nonce:      types.Nonce{},
 		GasLimit:   parent.GasLimit, // Inherit from parent for now, will need to adjust dynamically later.
-		Timestamp:  uint64(time.Now().Unix()),
+		Timestamp:  uint64(sw.clock.Now().Unix()),
 	}
 
 	// calculate gas limit based on parent header
@@ -432,16 +812,18 @@ func (sw *SequencerWorker) writeBlock(fraudResolver *Fraud, myAccount accounts.A
 	}
 
 	header.GasLimit = gasLimit
+	header.BaseFee = sw.blockchain.CalculateBaseFee(parent)
 
 	// set the timestamp
 	parentTime := time.Unix(int64(parent.Timestamp), 0)
 	headerTime := parentTime.Add(sw.blockTime)
 
-	if headerTime.Before(time.Now()) {
-		headerTime = time.Now()
+	if now := sw.clock.Now(); headerTime.Before(now) {
+		headerTime = now
 	}
 
 	header.Timestamp = uint64(headerTime.Unix())
+	sw.byzantine.stamp(header, sw.clock.Now())
 
 	// we need to include in the extra field the current set of validators
 	err = block.AssignExtraValidators(header, ValidatorSet{types.StringToAddress(myAccount.Address.Hex())})
@@ -449,6 +831,14 @@ func (sw *SequencerWorker) writeBlock(fraudResolver *Fraud, myAccount accounts.A
 		return err
 	}
 
+	// Reference the Avail block of the slot; none is known before the first
+	// slot comes in.
+	if sw.slotAvailHeight > 0 {
+		if err := block.AssignExtraAvailReference(header, sw.slotAvailHeight); err != nil {
+			return err
+		}
+	}
+
 	// Begin snapshot for P2P state distribution.
 	sw.snapshotter.Begin()
 
@@ -456,12 +846,29 @@ func (sw *SequencerWorker) writeBlock(fraudResolver *Fraud, myAccount accounts.A
 	// during block generation.
 	defer func() { sw.snapshotter.End() }()
 
-	transition, err := sw.executor.BeginTxn(parent.StateRoot, header, types.StringToAddress(myAccount.Address.Hex()))
+	txn, err := sw.executor.BeginTxn(parent.StateRoot, header, types.StringToAddress(myAccount.Address.Hex()))
 	if err != nil {
 		return err
 	}
 
-	txns := sw.writeTransactions(fraudResolver, gasLimit, transition)
+	transition := block.NewTransition(txn)
+
+	sw.enterBlockPhase(blockBuilding)
+
+	// The transactions go in up to the bytes left under the size limit of
+	// the block, once sealed.
+	sizeBudget := uint64(math.MaxUint64)
+	if max := sw.production.MaxBlockSizeBytes; max > 0 {
+		sizeBudget = block.TxsSizeBudget(header, max)
+	}
+
+	sw.metrics.txPoolDepth(sw.txpool.Length())
+
+	// The leader ends the disputes open past the challenge window ahead of
+	// the other transactions.
+	txns := sw.writeDisputeEndTxs(fraudResolver, header.Number, types.Address(myAccount.Address), signKey.PrivateKey, txn, transition)
+	txns = append(txns, sw.writeTransactions(fraudResolver, gasLimit, header.BaseFee, sizeBudget, transition)...)
+	txns = append(txns, sw.byzantine.forge(header.Number, types.Address(myAccount.Address), txn.GetNonce(types.Address(myAccount.Address)), header.BaseFee, transition)...)
 
 	// XXX: Following fraud function is only called when the fraud server is
 	// actively listening and the fraud has been primed by making corresponding
@@ -482,7 +889,7 @@ func (sw *SequencerWorker) writeBlock(fraudResolver *Fraud, myAccount accounts.A
 	_, root := transition.Commit()
 
 	// Update the header
-	header.StateRoot = root
+	header.StateRoot = sw.byzantine.corruptRoot(header.Number, parent.StateRoot, root)
 	header.GasUsed = transition.TotalGas()
 
 	// Build the actual block
@@ -505,6 +912,8 @@ func (sw *SequencerWorker) writeBlock(fraudResolver *Fraud, myAccount accounts.A
 	// is sealed after all the committed seals
 	blk.Header.ComputeHash()
 
+	sw.metrics.buildLatency(start)
+
 	sw.logger.Info(
 		"Sending new block to avail",
 		"sequencer_node_addr", sw.nodeAddr,
@@ -513,21 +922,51 @@ func (sw *SequencerWorker) writeBlock(fraudResolver *Fraud, myAccount accounts.A
 		"block_parent_hash", blk.ParentHash(),
 	)
 
+	// Abandon the block rather than submit it once shutting down; nothing
+	// of it has left the node yet.
+	select {
+	case <-sw.closeCh:
+		return errShuttingDown
+	default:
+	}
+
+	// The block is retried until it settles once it leaves the node.
+	if err := sw.unsettled.push(blk); err != nil {
+		return fmt.Errorf("failed to queue the block until it settles: %w", err)
+	}
+
+	sw.enterBlockPhase(blockSubmitting)
+
 	// Submit block without waiting for status.
-	err = sw.availSender.SendAndWaitForStatus(blk, avail_types.ExtrinsicStatus{IsInBlock: true})
+	submitted := time.Now()
+
+	res, err := sw.availSender.SendAndWaitForStatus(sw.ctx, blk, avail_types.ExtrinsicStatus{IsInBlock: true}, sw.unsettled.submittedHook(sw.logger, blk))
 	if err != nil {
-		sw.logger.Error("Error while submitting data to avail", "error", err)
+		sw.logger.Error("Error while submitting data to avail; the block is queued for resubmission", "block_number", blk.Number(), "error", err)
+		sw.failSubmission(err, blk)
+
 		return err
 	}
 
+	if err := sw.unsettled.included(res.BlockNumber, res.Finalized, blk.Hash()); err != nil {
+		sw.logger.Error("failed to record the inclusion of the block in Avail", "block_number", blk.Number(), "error", err)
+	}
+
+	sw.metrics.settleLatency(submitted)
+
 	sw.logger.Info(
 		"Block successfully sent to avail. Writing block to local chain...",
 		"sequencer_node_addr", sw.nodeAddr,
 		"block_number", blk.Number(),
 		"block_hash", blk.Hash(),
 		"block_parent_hash", blk.ParentHash(),
+		"avail_block_number", res.BlockNumber,
+		"avail_block_hash", res.BlockHash.Hex(),
+		"avail_extrinsic_index", res.ExtrinsicIndex,
 	)
 
+	sw.enterBlockPhase(blockWriting)
+
 	// Write the block to the blockchain
 	if err := sw.blockchain.WriteBlock(blk, sw.nodeType.String()); err != nil {
 		return err
@@ -541,6 +980,12 @@ func (sw *SequencerWorker) writeBlock(fraudResolver *Fraud, myAccount accounts.A
 		"block_parent_hash", blk.ParentHash(),
 	)
 
+	if err := sw.unsettled.remove(blk.Hash()); err != nil {
+		sw.logger.Error("failed to take the settled block off the unsettled queue", "block_number", blk.Number(), "error", err)
+	}
+
+	sw.metrics.producedSlot(len(blk.Transactions))
+
 	// After the block has been written we reset the txpool to remove stale transactions.
 	sw.txpool.ResetWithHeaders(blk.Header)
 
@@ -563,16 +1008,65 @@ func (sw *SequencerWorker) writeBlock(fraudResolver *Fraud, myAccount accounts.A
 	return nil
 }
 
+// enterBlockPhase reports the phase of the block in flight to the hook, if any.
+func (sw *SequencerWorker) enterBlockPhase(phase blockPhase) {
+	if sw.blockPhaseHook != nil {
+		sw.blockPhaseHook(phase)
+	}
+}
+
 // writeTransactions writes transactions.
-// It gets transactions from the transaction pool, and writes the transactions to a state transition.
+// It gets transactions from the transaction pool, and writes the transactions to a state transition,
+// up to the gas target and the max transaction count of the block, in the configured order at the base
+// fee of the block, dropping the user transactions under the price limit and leaving the dynamic-fee
+// ones capped under the base fee pending.
+// The dispute resolution transactions of the node and of the staked participants are written ahead of
+// all the others, with the gas held back for them, regardless of the gas target and the max transaction
+// count.
+// The encoded transactions take up to sizeBudget bytes; the first one that doesn't fit ends the block,
+// leaving the rest pending.
 // It returns a slice of successful transactions that have been written without errors.
-func (sw *SequencerWorker) writeTransactions(fraudResolver *Fraud, gasLimit uint64, transition transitionInterface) []*types.Transaction {
-	var successful []*types.Transaction
+func (sw *SequencerWorker) writeTransactions(fraudResolver *Fraud, gasLimit, baseFee, sizeBudget uint64, transition transitionInterface) []*types.Transaction {
+	var (
+		successful []*types.Transaction
+		size       uint64
+	)
+
+	kinds := sw.newTxKinds()
+
+	pending, mark := sw.pendingTxs(baseFee, kinds)
+	defer func() { sw.pruneTxArrivals(mark, successful) }()
+
+	// The senders of the repeatedly failing transactions are left out of the
+	// block while banned.
+	number := sw.blockchain.Header().Number + 1
+	sw.senderBans.prune(number)
+
+	// Gas held back for the dispute resolution transactions not written yet.
+	reserved := pending.gas(kinds.dispute)
+	release := func(tx *types.Transaction) {
+		if tx.Gas < reserved {
+			reserved -= tx.Gas
+		} else {
+			reserved = 0
+		}
+	}
+
+	var userTxs uint64
+
+	// The disputes settled by the transactions of the txpool written, by the
+	// disputed sequencer.
+	settled := make(map[types.Address]bool)
 
-	sw.txpool.Prepare(sw.txpool.GetBaseFee())
+	// The user transactions included of each sender, for the per-sender cap.
+	senderTxs := make(map[types.Address]uint64)
+
+	// The price limit and the gas target, as last reloaded.
+	priceLimit := sw.overrides.priceLimitOr(sw.production.PriceLimit)
+	gasTarget := sw.overrides.blockGasTargetOr(sw.production.BlockGasTarget)
 
 	for {
-		tx := sw.txpool.Peek()
+		tx := pending.Peek()
 		if tx == nil {
 			break
 		}
@@ -582,31 +1076,157 @@ func (sw *SequencerWorker) writeTransactions(fraudResolver *Fraud, gasLimit uint
 			break
 		}
 
-		// returned err is omitted below since it's used for debugging purposes and is
-		// therefore not useful in this context.
-		isFraudProofInProgress, _ := staking.IsBeginDisputeResolutionTx(tx)
-		if isFraudProofInProgress {
+		dispute := kinds.dispute(tx)
+
+		// The byzantine sequencer censoring the disputes leaves them in the txpool.
+		if dispute && sw.byzantine.censors(number) {
+			release(tx)
+			pending.Skip()
+			continue
+		}
+
+		// The dispute raised by the fraud proof of a watchtower goes in the
+		// dispute resolution block of the fraud resolver instead.
+		if dispute && sw.isFraudProofDisputeTx(tx) {
 			sw.logger.Debug("sequencer found begin dispute resolution tx; stopping block production")
 			break
 		}
 
+		// The escalated disputes are settled out of the txpool, possibly
+		// more than once; the settlements of a dispute settled already
+		// would revert or slash twice.
+		if settles, admit := sw.disputeWatcher.admit(tx, settled); settles && !admit {
+			sw.logger.Debug("dropping transaction settling a dispute settled already", "hash", tx.Hash.String(), "from", tx.From)
+			sw.txpool.Drop(tx)
+
+			if dispute {
+				release(tx)
+			}
+
+			pending.Skip()
+
+			continue
+		}
+
+		if !dispute {
+			// The transactions of the banned senders are left in the pool,
+			// for when the ban expires.
+			if !isSystemTx(tx, sw.keys.owns) && sw.senderBans.Banned(tx.From, number) {
+				sw.logger.Debug("skipping transactions of banned sender", "hash", tx.Hash.String(), "from", tx.From)
+				pending.Skip()
+
+				continue
+			}
+
+			// The transactions under the price limit may come in bypassing
+			// the JSON-RPC admission, such as the gossiped ones.
+			if err := checkPriceLimit(tx, baseFee, priceLimit, sw.keys.owns); err != nil {
+				sw.logger.Debug("dropping transaction under the price limit", "hash", tx.Hash.String(), "error", err)
+				sw.txpool.Drop(tx)
+				pending.Skip()
+
+				continue
+			}
+
+			// The dynamic-fee transactions capped under the base fee wait in
+			// the pool for it to come down.
+			if err := checkFeeCap(tx, baseFee); err != nil {
+				sw.logger.Debug("skipping transaction capped under the base fee", "hash", tx.Hash.String(), "error", err)
+				pending.Skip()
+
+				continue
+			}
+
+			// The sender's transactions come in nonce order; the rest of them
+			// wait for the next block once it reaches the cap.
+			if max := sw.production.MaxTxsPerSender; max > 0 && !isSystemTx(tx, sw.keys.owns) && senderTxs[tx.From] >= max {
+				sw.logger.Debug("sender reached max transaction count", "from", tx.From, "max_txs", max)
+				pending.Skip()
+
+				continue
+			}
+
+			if max := sw.production.MaxTxsPerBlock; max > 0 && userTxs >= max {
+				sw.logger.Debug("block reached max transaction count", "max_txs", max)
+				break
+			}
+
+			// The target is soft; a transaction of any size makes it in an empty block.
+			if gasTarget > 0 && len(successful) > 0 && transition.TotalGas()+tx.Gas > gasTarget {
+				sw.logger.Debug("block reached gas target", "gas_target", gasTarget, "gas_used", transition.TotalGas())
+				break
+			}
+
+			if reserved > 0 && transition.TotalGas()+tx.Gas+reserved > gasLimit {
+				sw.logger.Debug("gas held back for dispute resolution transactions", "hash", tx.Hash.String(), "reserved_gas", reserved)
+				pending.Skip()
+
+				continue
+			}
+		}
+
+		// A block over the size limit can't settle on Avail.
+		txSize := block.EncodedTxSize(tx)
+		if size+txSize > sizeBudget {
+			sw.logger.Debug("block reached max size", "hash", tx.Hash.String(), "size", size, "size_budget", sizeBudget)
+			break
+		}
+
 		if err := transition.Write(tx); err != nil {
 			if _, ok := err.(*state.GasLimitReachedTransitionApplicationError); ok { // nolint:errorlint
-				sw.logger.Warn("transaction reached gas limit during excution", "hash", tx.Hash.String())
-				break
+				if !dispute {
+					sw.logger.Warn("transaction reached gas limit during excution", "hash", tx.Hash.String())
+					break
+				}
+
+				if tx.Gas > gasLimit {
+					sw.logger.Error("dispute resolution transaction exceeds the block gas limit; it can't be included", "hash", tx.Hash.String(), "gas", tx.Gas, "gas_limit", gasLimit)
+					observeUnfitDisputeTx()
+				} else {
+					sw.logger.Warn("dispute resolution transaction doesn't fit in the block; leaving it for the next one", "hash", tx.Hash.String(), "gas", tx.Gas)
+				}
 			} else if appErr, ok := err.(*state.TransitionApplicationError); ok && appErr.IsRecoverable { // nolint:errorlint
 				sw.logger.Warn("transaction caused application error", "hash", tx.Hash.String())
 				sw.txpool.Demote(tx)
+				sw.senderBans.fail(tx, number)
 			} else {
 				sw.logger.Error("transaction caused unknown error", "error", err)
 				sw.txpool.Drop(tx)
+				sw.senderBans.fail(tx, number)
+			}
+
+			if dispute {
+				release(tx)
 			}
 
+			// The rest of the sender's transactions can't go in without this one.
+			pending.Skip()
+
 			continue
 		}
 
 		// no errors, pop the tx from the pool
-		sw.txpool.Pop(tx)
+		pending.Shift()
+
+		size += txSize
+
+		if dispute {
+			release(tx)
+		} else {
+			userTxs++
+
+			if !isSystemTx(tx, sw.keys.owns) {
+				senderTxs[tx.From]++
+			}
+
+			// The reverted transactions make it in, yet count against
+			// their sender.
+			if receipts := transition.Receipts(); len(receipts) > 0 && receipts[len(receipts)-1].Status != nil && *receipts[len(receipts)-1].Status == types.ReceiptFailed {
+				sw.senderBans.fail(tx, number)
+			} else {
+				sw.senderBans.succeed(tx)
+			}
+		}
 
 		successful = append(successful, tx)
 	}
@@ -614,44 +1234,138 @@ func (sw *SequencerWorker) writeTransactions(fraudResolver *Fraud, gasLimit uint
 	return successful
 }
 
-// NewSequencer creates a new SequencerWorker.
-// It returns an error if one occurs during the creation.
-func NewSequencer(
-	logger hclog.Logger, b *blockchain.Blockchain, e *state.Executor, txp *txpool.TxPool,
-	snapshotter snapshot.Snapshotter, snapshotDistributor snapshot.Distributor,
-	availClient avail.Client, availAccount signature.KeyringPair, availAppID avail_types.UCompact,
-	nodeSignKey *ecdsa.PrivateKey, nodeAddr types.Address, nodeType MechanismType,
-	apq staking.ActiveParticipants, stakingNode staking.Node, availSender avail.Sender, closeCh <-chan struct{},
-	blockTime time.Duration, blockProductionIntervalSec uint64, currentNodeSyncIndex uint64,
-	fraudListenerAddr string,
-) (*SequencerWorker, error) {
+// writeDisputeEndTxs writes the transactions ending the disputes open past the challenge window to the
+// block of the given number, signed by the leader, along with the ones retrying the failed slashes due.
+// The outcome of a dispute is decided by the check of the watchtower of the fraud resolver.
+// It returns the transactions written.
+func (sw *SequencerWorker) writeDisputeEndTxs(fraudResolver *Fraud, number uint64, from types.Address, signKey *ecdsa.PrivateKey, txn *state.Transition, transition transitionInterface) []*types.Transaction {
+	if sw.disputeWatcher == nil || sw.byzantine.censors(number) {
+		return nil
+	}
+
+	var check func(*types.Block) error
+	if fraudResolver.watchtower != nil {
+		check = fraudResolver.watchtower.Check
+	}
+
+	var written []*types.Transaction
+
+	for _, d := range sw.disputeWatcher.due(number) {
+		txs, err := sw.disputeWatcher.endTxs(d, from, txn.GetNonce(from), signKey, check)
+		if err != nil {
+			sw.logger.Error("failed to construct the transactions ending the dispute", "sequencer_addr", d.sequencer, "error", err)
+			continue
+		}
+
+		for _, tx := range txs {
+			if err := transition.Write(tx); err != nil {
+				sw.logger.Error("failed to write the transaction ending the dispute", "sequencer_addr", d.sequencer, "hash", tx.Hash, "error", err)
+				break
+			}
+
+			written = append(written, tx)
+		}
+	}
+
+	// The failed slashes are retried once their backoff is over.
+	for _, sequencer := range sw.disputeWatcher.dueSlashes(number) {
+		tx, err := sw.disputeWatcher.slashTx(sequencer, from, txn.GetNonce(from), signKey)
+		if err != nil {
+			sw.logger.Error("failed to construct the transaction retrying the slash", "sequencer_addr", sequencer, "error", err)
+			continue
+		}
+
+		if err := transition.Write(tx); err != nil {
+			sw.logger.Error("failed to write the transaction retrying the slash", "sequencer_addr", sequencer, "hash", tx.Hash, "error", err)
+			continue
+		}
+
+		written = append(written, tx)
+	}
+
+	return written
+}
+
+// isFraudProofDisputeTx reports whether the dispute resolution transaction is
+// the one of a watchtower's fraud proof, to be handled by the fraud resolver.
+// The transactions of unknown origin are taken for ones.
+func (sw *SequencerWorker) isFraudProofDisputeTx(tx *types.Transaction) bool {
+	if begin, _ := staking.IsBeginDisputeResolutionTx(tx); !begin {
+		return false
+	}
+
+	if sw.apq == nil {
+		return true
+	}
+
+	isWatchtower, err := sw.apq.Contains(tx.From, staking.WatchTower)
+	if err != nil {
+		sw.logger.Debug("failure while checking if tx from is active watchtower", "error", err)
+		return true
+	}
+
+	return isWatchtower
+}
+
+// newSequencer creates the SequencerWorker of the node, running for the role
+// it's taking on. It returns an error if one occurs during the creation.
+func (d *Avail) newSequencer(role *roleRun) (*SequencerWorker, error) {
+	logger := d.logger.Named(d.nodeType.LogString())
+
 	sw := &SequencerWorker{
-		logger:                     logger,
-		blockchain:                 b,
-		executor:                   e,
-		txpool:                     txp,
-		snapshotter:                snapshotter,
-		snapshotDistributor:        snapshotDistributor,
-		apq:                        apq,
-		availAppID:                 availAppID,
-		availClient:                availClient,
-		availAccount:               availAccount,
-		nodeSignKey:                nodeSignKey,
-		nodeAddr:                   nodeAddr,
-		nodeType:                   nodeType,
-		stakingNode:                stakingNode,
-		availSender:                availSender,
-		fraudServer:                NewFraudServer(),
-		blockTime:                  blockTime,
-		blockProductionIntervalSec: blockProductionIntervalSec,
-		blockProductionEnabled:     new(atomic.Bool),
-		currentNodeSyncIndex:       currentNodeSyncIndex,
-		closeCh:                    closeCh,
-	}
-
-	if len(fraudListenerAddr) > 0 {
+		logger:                 logger,
+		blockchain:             d.blockchain,
+		executor:               d.executor,
+		txpool:                 d.txpool,
+		poolIndex:              d.poolIndex,
+		snapshotter:            d.snapshotter,
+		snapshotDistributor:    d.snapshotDistributor,
+		apq:                    staking.NewActiveParticipantsQuerier(d.blockchain, d.executor, d.logger),
+		availAppID:             d.availAppID,
+		availClient:            d.availClient,
+		availAccount:           d.availAccount,
+		nodeSignKey:            d.signKey,
+		nodeAddr:               d.minerAddr,
+		nodeType:               d.nodeType,
+		stakingNode:            d.stakingNode,
+		availSender:            d.availSender,
+		balanceMonitor:         d.balanceMonitor,
+		fraudServer:            NewFraudServer(),
+		blockTime:              d.blockTime,
+		production:             d.production,
+		overrides:              d.overrides,
+		catchUp:                d.catchUp,
+		progress:               d.progress,
+		disputes:               d.disputes,
+		disputeWatcher:         d.disputeWatcher,
+		frauds:                 d.frauds,
+		unsettled:              d.unsettled,
+		settlement:             d.settlement,
+		breaker:                d.breaker,
+		keys:                   d.keys,
+		readiness:              d.readiness,
+		forkChoice:             d.forkChoice,
+		phases:                 d.phases,
+		txArrivals:             newTxArrivals(),
+		senderBans:             newSenderBans(d.production.FailingSenderThreshold, d.production.FailingSenderBanBlocks, d.keys.owns, logger),
+		metrics:                newSequencerMetrics(metrics.Default(), d.nodeType, d.minerAddr),
+		leaders:                staking.NewLeaderSchedule(availBlockWindowLen, d.production.LeaderTimeoutBlocks),
+		activeSet:              staking.NewActiveSet(d.blockchain, d.executor, availBlockWindowLen),
+		slots:                  make(chan uint64, 1),
+		clock:                  systemClock{},
+		blockProductionEnabled: new(atomic.Bool),
+		currentNodeSyncIndex:   d.currentNodeSyncIndex,
+		ctx:                    role.ctx,
+		closeCh:                role.shutdown.closing(),
+		shutdown:               role.shutdown,
+		fraudTip:               d.fraudTip,
+		fraudQuorum:            d.fraudQuorum,
+		byzantine:              d.byzantine,
+	}
+
+	if len(d.fraudListenerAddr) > 0 {
 		go func() {
-			err := sw.fraudServer.ListenAndServe(fraudListenerAddr)
+			err := sw.fraudServer.ListenAndServe(role.ctx, d.fraudListenerAddr)
 			if err != nil {
 				log.Fatalf("fraud server: %s", err)
 			}
@@ -0,0 +1,1109 @@
+package avail
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/0xPolygon/polygon-edge/crypto"
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/consensus/avail/validator"
+	"github.com/availproject/op-evm/consensus/avail/watchtower"
+	"github.com/availproject/op-evm/pkg/avail"
+	"github.com/availproject/op-evm/pkg/avail/testutil"
+	"github.com/availproject/op-evm/pkg/block"
+	"github.com/availproject/op-evm/pkg/snapshot"
+	"github.com/availproject/op-evm/pkg/staking"
+	"github.com/availproject/op-evm/pkg/test"
+	avail_types "github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+// testSnapshotter is a snapshot.Snapshotter that doesn't gather any changes.
+type testSnapshotter struct{}
+
+func (testSnapshotter) Begin()                         {}
+func (testSnapshotter) End() *snapshot.Snapshot        { return &snapshot.Snapshot{} }
+func (testSnapshotter) Apply(*snapshot.Snapshot) error { return nil }
+
+// testDistributor is a snapshot.Distributor that collects the sent snapshots.
+type testDistributor struct {
+	sent []*snapshot.Snapshot
+}
+
+func (d *testDistributor) Receive() <-chan *snapshot.Snapshot { return nil }
+func (d *testDistributor) Close() error                       { return nil }
+
+func (d *testDistributor) Send(s *snapshot.Snapshot) error {
+	d.sent = append(d.sent, s)
+	return nil
+}
+
+func newTestSequencerWorker(t *testing.T, sender avail.Sender) (*SequencerWorker, *Fraud, *testDistributor) {
+	t.Helper()
+
+	a, _ := NewTestAvail(t, Sequencer)
+
+	return newTestSequencerWorkerOf(t, a, sender)
+}
+
+// newTestSequencerWorkerOf returns the sequencer worker of the given consensus.
+func newTestSequencerWorkerOf(t testing.TB, a *Avail, sender avail.Sender) (*SequencerWorker, *Fraud, *testDistributor) {
+	t.Helper()
+
+	distributor := &testDistributor{}
+
+	ctx, cancel := context.WithCancel(a.ctx)
+	t.Cleanup(cancel)
+
+	closeCh := make(chan struct{})
+	apq := staking.NewActiveParticipantsQuerier(a.blockchain, a.executor, a.logger)
+
+	sw := &SequencerWorker{
+		logger:                 a.logger,
+		blockchain:             a.blockchain,
+		executor:               a.executor,
+		txpool:                 a.txpool,
+		poolIndex:              a.poolIndex,
+		snapshotter:            testSnapshotter{},
+		snapshotDistributor:    distributor,
+		apq:                    apq,
+		availSender:            sender,
+		fraudServer:            NewFraudServer(),
+		nodeSignKey:            a.signKey,
+		nodeAddr:               a.minerAddr,
+		nodeType:               Sequencer,
+		ctx:                    ctx,
+		closeCh:                closeCh,
+		shutdown:               newGracefulShutdown(closeCh, cancel, DefaultShutdownTimeout),
+		blockTime:              a.blockTime,
+		production:             DefaultProductionConfig(),
+		overrides:              a.overrides,
+		catchUp:                DefaultCatchUpConfig(),
+		progress:               new(syncProgress),
+		disputes:               newDisputeGuard(a.minerAddr, apq, a.logger),
+		unsettled:              newUnsettledQueue(""),
+		settlement:             newSettlementLag(DefaultMaxSettlementLag, a.logger),
+		breaker:                a.breaker,
+		keys:                   a.keys,
+		readiness:              newReadiness(),
+		forkChoice:             a.forkChoice,
+		phases:                 a.phases,
+		txArrivals:             newTxArrivals(),
+		senderBans:             newSenderBans(DefaultFailingSenderThreshold, DefaultFailingSenderBanBlocks, a.keys.owns, a.logger),
+		leaders:                staking.NewLeaderSchedule(availBlockWindowLen, DefaultLeaderTimeoutBlocks),
+		activeSet:              staking.NewActiveSet(a.blockchain, a.executor, availBlockWindowLen),
+		slots:                  make(chan uint64, 1),
+		clock:                  systemClock{},
+		blockProductionEnabled: new(atomic.Bool),
+	}
+
+	// The clock makes every slot, unless the test hands the Avail slots over.
+	sw.production.SlotStallTimeout = 0
+	sw.readiness.set(ReadinessReady)
+
+	fraudResolver := NewFraudResolver(a.ctx, a.logger, a.blockchain, a.executor, a.txpool, a.poolIndex, nil, a.forkChoice.settled, a.frauds, a.forkChoice.feed, sw.blockProductionEnabled, a.minerAddr, a.signKey, sender, DefaultFraudTip, DefaultFraudProofQuorum, Sequencer)
+
+	return sw, fraudResolver, distributor
+}
+
+func TestSequencerWriteBlockSubmitsToAvail(t *testing.T) {
+	fake := testutil.NewFake(avail_types.NewUCompactFromUInt(1))
+	sw, fraudResolver, distributor := newTestSequencerWorker(t, fake)
+
+	parent := sw.blockchain.Header().Number
+	account := accounts.Account{Address: common.Address(sw.nodeAddr)}
+
+	if err := sw.writeBlock(fraudResolver, account, &keystore.Key{PrivateKey: sw.nodeSignKey}); err != nil {
+		t.Fatal(err)
+	}
+
+	head := sw.blockchain.Header()
+	assert.Equal(t, parent+1, head.Number)
+
+	// The block written locally is the one submitted to Avail.
+	submitted, err := fake.EdgeBlocks()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if assert.Len(t, submitted, 1) {
+		assert.Equal(t, head.Hash, submitted[0].Hash())
+	}
+
+	if assert.Len(t, distributor.sent, 1) {
+		assert.Equal(t, head.Hash, distributor.sent[0].BlockHash)
+	}
+}
+
+func TestSequencerWriteBlockDroppedSubmission(t *testing.T) {
+	fake := testutil.NewFake(avail_types.NewUCompactFromUInt(1))
+	sw, fraudResolver, distributor := newTestSequencerWorker(t, fake)
+
+	parent := sw.blockchain.Header().Number
+	account := accounts.Account{Address: common.Address(sw.nodeAddr)}
+	key := &keystore.Key{PrivateKey: sw.nodeSignKey}
+
+	fake.DropSubmissions(1)
+
+	err := sw.writeBlock(fraudResolver, account, key)
+	assert.True(t, errors.Is(err, avail.ErrExtrinsicDropped), "unexpected error: %v", err)
+
+	// Blocks that didn't make it to Avail are neither written nor distributed.
+	assert.Equal(t, parent, sw.blockchain.Header().Number)
+	assert.Empty(t, distributor.sent)
+	assert.Equal(t, uint64(0), fake.Head())
+
+	// The next attempt goes through.
+	if err := sw.writeBlock(fraudResolver, account, key); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, parent+1, sw.blockchain.Header().Number)
+	assert.Equal(t, uint64(1), fake.Head())
+}
+
+func TestSequencerWriteBlockRecordsSettlement(t *testing.T) {
+	fake := testutil.NewFake(avail_types.NewUCompactFromUInt(1))
+	settlements := avail.NewSettlementIndex()
+	sw, fraudResolver, _ := newTestSequencerWorker(t, avail.RecordSettlements(fake, settlements))
+
+	account := accounts.Account{Address: common.Address(sw.nodeAddr)}
+
+	if err := sw.writeBlock(fraudResolver, account, &keystore.Key{PrivateKey: sw.nodeSignKey}); err != nil {
+		t.Fatal(err)
+	}
+
+	srv, err := avail.NewSettlementRPCServer(settlements)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := rpc.DialInProc(srv)
+	defer c.Close()
+
+	head := sw.blockchain.Header()
+
+	var info *avail.SettlementInfo
+	if err := c.Call(&info, "avail_getSettlementInfo", head.Hash); err != nil {
+		t.Fatal(err)
+	}
+
+	if assert.NotNil(t, info) {
+		assert.Equal(t, head.Hash, info.BlockHash)
+		assert.Equal(t, fake.Head(), info.AvailBlockNumber)
+		assert.False(t, info.AvailFinalized)
+	}
+
+	// A resubmission of the block updates its record.
+	blk, ok := sw.blockchain.GetBlockByHash(head.Hash, true)
+	if !ok {
+		t.Fatal("block not found")
+	}
+
+	fake.Produce()
+
+	sender := avail.RecordSettlements(fake, settlements)
+	if _, err := sender.SendAndWaitForStatus(context.Background(), blk, avail_types.ExtrinsicStatus{IsFinalized: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Call(&info, "avail_getSettlementInfo", head.Hash); err != nil {
+		t.Fatal(err)
+	}
+
+	if assert.NotNil(t, info) {
+		assert.Equal(t, uint64(3), info.AvailBlockNumber)
+		assert.True(t, info.AvailFinalized)
+	}
+
+	// Blocks never submitted have no settlement info.
+	info = nil
+	if err := c.Call(&info, "avail_getSettlementInfo", head.ParentHash); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Nil(t, info)
+}
+
+func TestSequencerHaltPolicy(t *testing.T) {
+	sw, _, _ := newTestSequencerWorker(t, testutil.NewFake(avail_types.NewUCompactFromUInt(1)))
+
+	config := avail.DefaultBalanceMonitorConfig()
+	config.Policy = avail.LowBalancePolicyPause
+
+	balanceFn := func(context.Context) (*big.Int, error) { return big.NewInt(0), nil }
+	sw.balanceMonitor = avail.NewBalanceMonitor(balanceFn, config, sw.logger)
+
+	assert.True(t, sw.haltsBlockProduction(fmt.Errorf("%w: no key", avail.ErrSigningFailed)))
+	assert.False(t, sw.haltsBlockProduction(&avail.Error{Kind: avail.ErrConnection, Err: errors.New("connection refused")}))
+	assert.False(t, sw.balanceMonitor.Paused())
+
+	// Running out of funds pauses block production instead of halting it.
+	assert.False(t, sw.haltsBlockProduction(&avail.Error{Kind: avail.ErrInsufficientBalance, Err: errors.New("Inability to pay some fees")}))
+	assert.True(t, sw.balanceMonitor.Paused())
+}
+
+// fakeClock is a clock whose time moves only when its ticker ticks.
+type fakeClock struct {
+	lock   sync.Mutex
+	now    time.Time
+	period time.Duration
+	ticks  chan time.Time
+
+	// started is closed once the ticker is created.
+	started chan struct{}
+}
+
+func newFakeClock(now time.Time) *fakeClock {
+	return &fakeClock{now: now, ticks: make(chan time.Time), started: make(chan struct{})}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.now
+}
+
+func (c *fakeClock) NewTicker(period time.Duration) ticker {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.period = period
+	close(c.started)
+
+	return fakeTicker{c.ticks}
+}
+
+// tick advances the time by the period of the ticker and delivers the tick.
+func (c *fakeClock) tick() {
+	<-c.started
+
+	c.lock.Lock()
+	c.now = c.now.Add(c.period)
+	now := c.now
+	c.lock.Unlock()
+
+	c.ticks <- now
+}
+
+// advance moves the time forward without a tick.
+func (c *fakeClock) advance(d time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+type fakeTicker struct {
+	ch chan time.Time
+}
+
+func (t fakeTicker) C() <-chan time.Time { return t.ch }
+func (t fakeTicker) Stop()               {}
+
+// testSequencers is a staking.ActiveSequencers with a fixed set of sequencers.
+type testSequencers []types.Address
+
+func (s testSequencers) Get() ([]types.Address, error) { return s, nil }
+
+func (s testSequencers) Contains(addr types.Address) (bool, error) {
+	for _, a := range s {
+		if a == addr {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// startWriteBlocksLoop runs the block production of the sequencer, as the only
+// one, on a fake clock starting at the head timestamp. The returned function
+// shuts the production down gracefully.
+func startWriteBlocksLoop(t *testing.T, sw *SequencerWorker, fraudResolver *Fraud) (*fakeClock, func()) {
+	t.Helper()
+
+	clock := newFakeClock(time.Unix(int64(sw.blockchain.Header().Timestamp), 0))
+
+	sw.clock = clock
+	sw.balanceMonitor = avail.NewBalanceMonitor(nil, avail.DefaultBalanceMonitorConfig(), sw.logger)
+	sw.blockProductionEnabled.Store(true)
+
+	account := accounts.Account{Address: common.Address(sw.nodeAddr)}
+
+	done := make(chan struct{})
+	go func() {
+		sw.runWriteBlocksLoopWhenReady(testSequencers{sw.nodeAddr}, fraudResolver, account, &keystore.Key{PrivateKey: sw.nodeSignKey})
+		close(done)
+	}()
+
+	return clock, func() {
+		sw.shutdown.run(sw.logger)
+		<-done
+	}
+}
+
+// waitForBlock waits until the head of the chain is the given block.
+func waitForBlock(t *testing.T, sw *SequencerWorker, number uint64) {
+	t.Helper()
+
+	assert.Eventually(t, func() bool { return sw.blockchain.Header().Number == number }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestSequencerProductionCadence(t *testing.T) {
+	for _, blockTime := range []time.Duration{time.Second, 5 * time.Second} {
+		blockTime := blockTime
+
+		t.Run(blockTime.String(), func(t *testing.T) {
+			sw, fraudResolver, _ := newTestSequencerWorker(t, testutil.NewFake(avail_types.NewUCompactFromUInt(1)))
+			sw.blockTime = blockTime
+
+			parent := sw.blockchain.Header().Number
+			clock, stop := startWriteBlocksLoop(t, sw, fraudResolver)
+
+			const blocks = 3
+			for i := uint64(1); i <= blocks; i++ {
+				clock.tick()
+				waitForBlock(t, sw, parent+i)
+			}
+
+			stop()
+
+			// The blocks are timestamped at the cadence of the block time.
+			for n := parent + 1; n <= parent+blocks; n++ {
+				prev, _ := sw.blockchain.GetHeaderByNumber(n - 1)
+				hdr, _ := sw.blockchain.GetHeaderByNumber(n)
+
+				assert.Equal(t, uint64(blockTime.Seconds()), hdr.Timestamp-prev.Timestamp, "block %d", n)
+			}
+
+			last, _ := sw.blockchain.GetHeaderByNumber(parent + blocks)
+			assert.Equal(t, uint64(clock.Now().Unix()), last.Timestamp)
+		})
+	}
+}
+
+func TestSequencerSkipsEmptySlots(t *testing.T) {
+	sw, fraudResolver, _ := newTestSequencerWorker(t, testutil.NewFake(avail_types.NewUCompactFromUInt(1)))
+	sw.production = ProductionConfig{MaxIdleInterval: 3 * time.Second}
+
+	parent := sw.blockchain.Header().Number
+	clock, stop := startWriteBlocksLoop(t, sw, fraudResolver)
+	defer stop()
+
+	// The slot is done with once the next tick is taken.
+	clock.tick()
+	clock.tick()
+	assert.Equal(t, parent, sw.blockchain.Header().Number)
+
+	// Heartbeat once the chain has been idle for the max idle interval...
+	clock.tick()
+	waitForBlock(t, sw, parent+1)
+
+	blk, _ := sw.blockchain.GetBlockByNumber(parent+1, true)
+	assert.Empty(t, blk.Transactions)
+	assert.Equal(t, uint64(clock.Now().Unix()), blk.Header.Timestamp)
+
+	// ...and again the max idle interval later.
+	clock.tick()
+	clock.tick()
+	assert.Equal(t, parent+1, sw.blockchain.Header().Number)
+
+	clock.tick()
+	waitForBlock(t, sw, parent+2)
+}
+
+func TestSequencerDisputeForcesProduction(t *testing.T) {
+	sw, fraudResolver, _ := newTestSequencerWorker(t, testutil.NewFake(avail_types.NewUCompactFromUInt(1)))
+	sw.production = ProductionConfig{MaxIdleInterval: time.Hour}
+
+	probationAddr, _ := test.NewAccount(t)
+
+	tx, err := staking.BeginDisputeResolutionTx(sw.nodeAddr, probationAddr, 1_000_000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err = (&crypto.FrontierSigner{}).SignTx(tx, sw.nodeSignKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parent := sw.blockchain.Header().Number
+	clock, stop := startWriteBlocksLoop(t, sw, fraudResolver)
+	defer stop()
+
+	clock.tick()
+	clock.tick()
+	assert.Equal(t, parent, sw.blockchain.Header().Number)
+
+	if err := sw.txpool.AddTx(tx); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Eventually(t, func() bool { return sw.txpool.Length() > 0 }, 5*time.Second, 10*time.Millisecond)
+
+	clock.tick()
+	waitForBlock(t, sw, parent+1)
+}
+
+func TestResolveBlockTime(t *testing.T) {
+	logger := hclog.NewNullLogger()
+
+	blockTime, err := resolveBlockTime(0, 0, logger)
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultBlockTime, blockTime)
+
+	blockTime, err = resolveBlockTime(5*time.Second, 0, logger)
+	assert.NoError(t, err)
+	assert.Equal(t, 5*time.Second, blockTime)
+
+	_, err = resolveBlockTime(500*time.Millisecond, 0, logger)
+	assert.Error(t, err)
+
+	_, err = resolveBlockTime(-time.Second, 0, logger)
+	assert.Error(t, err)
+
+	// Blocks aren't produced faster than the Avail submissions are paced.
+	blockTime, err = resolveBlockTime(time.Second, 10*time.Second, logger)
+	assert.NoError(t, err)
+	assert.Equal(t, 10*time.Second, blockTime)
+}
+
+// addTransfers adds the given number of transfers from the sequencer account to the pool.
+func addTransfers(t *testing.T, sw *SequencerWorker, n int) {
+	t.Helper()
+
+	to, _ := test.NewAccount(t)
+
+	for i := 0; i < n; i++ {
+		tx, err := (&crypto.FrontierSigner{}).SignTx(&types.Transaction{
+			From:     sw.nodeAddr,
+			To:       &to,
+			Value:    big.NewInt(1),
+			GasPrice: big.NewInt(5000),
+			Gas:      21_000,
+			Nonce:    uint64(i),
+		}, sw.nodeSignKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := sw.txpool.AddTx(tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	assert.Eventually(t, func() bool { return sw.txpool.Length() == uint64(n) }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestSequencerBlockCapacity(t *testing.T) {
+	testCases := []struct {
+		name       string
+		production ProductionConfig
+		wantTxs    int
+	}{
+		{
+			name:       "unlimited",
+			production: ProductionConfig{},
+			wantTxs:    10,
+		},
+		{
+			name:       "gas target",
+			production: ProductionConfig{BlockGasTarget: 70_000, MaxTxsPerBlock: 5},
+			wantTxs:    3,
+		},
+		{
+			name:       "max txs",
+			production: ProductionConfig{BlockGasTarget: 1_000_000, MaxTxsPerBlock: 4},
+			wantTxs:    4,
+		},
+		{
+			name:       "gas target below a transaction",
+			production: ProductionConfig{BlockGasTarget: 1_000},
+			wantTxs:    1,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			sw, fraudResolver, _ := newTestSequencerWorker(t, testutil.NewFake(avail_types.NewUCompactFromUInt(1)))
+			sw.production = tc.production
+
+			const pending = 10
+			addTransfers(t, sw, pending)
+
+			account := accounts.Account{Address: common.Address(sw.nodeAddr)}
+			if err := sw.writeBlock(fraudResolver, account, &keystore.Key{PrivateKey: sw.nodeSignKey}); err != nil {
+				t.Fatal(err)
+			}
+
+			blk, _ := sw.blockchain.GetBlockByNumber(sw.blockchain.Header().Number, true)
+			assert.Len(t, blk.Transactions, tc.wantTxs)
+			assert.LessOrEqual(t, blk.Header.GasUsed, blk.Header.GasLimit)
+
+			if tc.production.BlockGasTarget > 0 && tc.wantTxs > 1 {
+				assert.LessOrEqual(t, blk.Header.GasUsed, tc.production.BlockGasTarget)
+			}
+
+			// The transactions left out remain pending.
+			assert.Equal(t, uint64(pending-tc.wantTxs), sw.txpool.Length())
+		})
+	}
+}
+
+func TestSequencerMaxTxsPerSender(t *testing.T) {
+	sw, fraudResolver, _ := newTestSequencerWorker(t, testutil.NewFake(avail_types.NewUCompactFromUInt(1)))
+	sw.production = ProductionConfig{MaxTxsPerSender: 5}
+
+	// The sender dumping the transactions pays above the other one.
+	whale, other := newTestSender(t, sw), newTestSender(t, sw)
+
+	const pending = 22
+	for i := 0; i < 20; i++ {
+		if err := sw.txpool.AddTx(whale.transfer(t, 100)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := sw.txpool.AddTx(other.transfer(t, 10)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	assert.Eventually(t, func() bool { return sw.txpool.Length() == pending }, 5*time.Second, 10*time.Millisecond)
+
+	account := accounts.Account{Address: common.Address(sw.nodeAddr)}
+	if err := sw.writeBlock(fraudResolver, account, &keystore.Key{PrivateKey: sw.nodeSignKey}); err != nil {
+		t.Fatal(err)
+	}
+
+	blk, _ := sw.blockchain.GetBlockByNumber(sw.blockchain.Header().Number, true)
+	assert.Len(t, blk.Transactions, 5+2)
+
+	// The lowest nonces of each sender go in, in order.
+	nonces := map[types.Address][]uint64{}
+	for _, tx := range blk.Transactions {
+		nonces[tx.From] = append(nonces[tx.From], tx.Nonce)
+	}
+
+	assert.Equal(t, []uint64{0, 1, 2, 3, 4}, nonces[whale.addr])
+	assert.Equal(t, []uint64{0, 1}, nonces[other.addr])
+
+	// The transactions over the cap remain pending for the next block.
+	assert.Equal(t, uint64(pending-7), sw.txpool.Length())
+}
+
+func TestSequencerMaxTxsPerSenderCountsStakingCalls(t *testing.T) {
+	sw, fraudResolver, _ := newTestSequencerWorker(t, testutil.NewFake(avail_types.NewUCompactFromUInt(1)))
+	sw.production = ProductionConfig{MaxTxsPerSender: 3}
+
+	// The calls of the staking contract by a user are capped like the rest.
+	user := newTestSender(t, sw)
+
+	const pending = 6
+	for i := 0; i < pending; i++ {
+		stake, err := staking.StakeTx(user.addr, big.NewInt(0), string(Sequencer), 200_000)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := sw.txpool.AddTx(user.sign(t, stake, 100)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	assert.Eventually(t, func() bool { return sw.txpool.Length() == pending }, 5*time.Second, 10*time.Millisecond)
+
+	account := accounts.Account{Address: common.Address(sw.nodeAddr)}
+	if err := sw.writeBlock(fraudResolver, account, &keystore.Key{PrivateKey: sw.nodeSignKey}); err != nil {
+		t.Fatal(err)
+	}
+
+	blk, _ := sw.blockchain.GetBlockByNumber(sw.blockchain.Header().Number, true)
+	assert.Len(t, blk.Transactions, 3)
+	assert.Equal(t, uint64(pending-3), sw.txpool.Length())
+}
+
+func TestSequencerMaxBlockSize(t *testing.T) {
+	sw, fraudResolver, _ := newTestSequencerWorker(t, testutil.NewFake(avail_types.NewUCompactFromUInt(1)))
+	sw.production = ProductionConfig{MaxBlockSizeBytes: 64 * 1024}
+
+	// The calldata of the transactions reaches the byte cap well before the
+	// gas cap.
+	sender := newTestSender(t, sw)
+
+	const pending = 10
+	for i := 0; i < pending; i++ {
+		tx := sender.sign(t, &types.Transaction{To: &types.ZeroAddress, Value: big.NewInt(1), Gas: 100_000, Input: make([]byte, 16*1024)}, 5000)
+		if err := sw.txpool.AddTx(tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	assert.Eventually(t, func() bool { return sw.txpool.Length() == pending }, 5*time.Second, 10*time.Millisecond)
+
+	account := accounts.Account{Address: common.Address(sw.nodeAddr)}
+	if err := sw.writeBlock(fraudResolver, account, &keystore.Key{PrivateKey: sw.nodeSignKey}); err != nil {
+		t.Fatal(err)
+	}
+
+	blk, _ := sw.blockchain.GetBlockByNumber(sw.blockchain.Header().Number, true)
+	assert.Len(t, blk.Transactions, 3)
+	assert.LessOrEqual(t, block.EncodedSize(blk), sw.production.MaxBlockSizeBytes)
+	assert.Less(t, blk.Header.GasUsed, blk.Header.GasLimit/2)
+
+	// The transactions left out remain pending.
+	assert.Equal(t, uint64(pending-3), sw.txpool.Length())
+}
+
+func TestSequencerIncludesDisputeTxs(t *testing.T) {
+	sw, fraudResolver, _ := newTestSequencerWorker(t, testutil.NewFake(avail_types.NewUCompactFromUInt(1)))
+
+	users, disputer := newTestSender(t, sw), newNodeTestSender(t, sw)
+
+	// Contract creations spinning until they run out of gas, more of them
+	// than fit in the block gas limit.
+	const pending, gas = 9, 1_000_000
+	for i := 0; i < pending; i++ {
+		tx := users.sign(t, &types.Transaction{Value: big.NewInt(0), Gas: gas, Input: []byte{0x5b, 0x60, 0x00, 0x56}}, 100)
+		if err := sw.txpool.AddTx(tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	end, err := staking.EndDisputeResolutionTx(disputer.addr, types.StringToAddress("0x1"), gas)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The dispute resolution transaction pays the least.
+	dispute := disputer.sign(t, end, 1)
+	if err := sw.txpool.AddTx(dispute); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Eventually(t, func() bool { return sw.txpool.Length() == pending+1 }, 5*time.Second, 10*time.Millisecond)
+
+	account := accounts.Account{Address: common.Address(sw.nodeAddr)}
+	if err := sw.writeBlock(fraudResolver, account, &keystore.Key{PrivateKey: sw.nodeSignKey}); err != nil {
+		t.Fatal(err)
+	}
+
+	blk, _ := sw.blockchain.GetBlockByNumber(sw.blockchain.Header().Number, true)
+	if !assert.NotEmpty(t, blk.Transactions) {
+		t.FailNow()
+	}
+
+	assert.Equal(t, dispute.Hash, blk.Transactions[0].Hash)
+
+	// The user transactions fill the rest of the block and the others remain pending.
+	included := uint64(len(blk.Transactions) - 1)
+	assert.Less(t, included, uint64(pending))
+	assert.Equal(t, pending-included, sw.txpool.Length())
+	assert.Greater(t, blk.Header.GasUsed+gas, blk.Header.GasLimit)
+}
+
+func TestSequencerIgnoresUnstakedDisputeTxs(t *testing.T) {
+	sw, fraudResolver, _ := newTestSequencerWorker(t, testutil.NewFake(avail_types.NewUCompactFromUInt(1)))
+
+	users, unstaked := newTestSender(t, sw), newTestSender(t, sw)
+
+	// Contract creations spinning until they run out of gas, more of them
+	// than fit in the block gas limit.
+	const pending, gas = 9, 1_000_000
+	for i := 0; i < pending; i++ {
+		tx := users.sign(t, &types.Transaction{Value: big.NewInt(0), Gas: gas, Input: []byte{0x5b, 0x60, 0x00, 0x56}}, 100)
+		if err := sw.txpool.AddTx(tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	end, err := staking.EndDisputeResolutionTx(unstaked.addr, types.StringToAddress("0x1"), gas)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The dispute resolution call of an account staked as neither a
+	// sequencer nor a watchtower is a user transaction, paying the least.
+	dispute := unstaked.sign(t, end, 1)
+	if err := sw.txpool.AddTx(dispute); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Eventually(t, func() bool { return sw.txpool.Length() == pending+1 }, 5*time.Second, 10*time.Millisecond)
+
+	account := accounts.Account{Address: common.Address(sw.nodeAddr)}
+	if err := sw.writeBlock(fraudResolver, account, &keystore.Key{PrivateKey: sw.nodeSignKey}); err != nil {
+		t.Fatal(err)
+	}
+
+	blk, _ := sw.blockchain.GetBlockByNumber(sw.blockchain.Header().Number, true)
+	if !assert.NotEmpty(t, blk.Transactions) {
+		t.FailNow()
+	}
+
+	// No gas is held back for it; the user transactions fill the block and
+	// it's left pending.
+	for _, tx := range blk.Transactions {
+		assert.NotEqual(t, dispute.Hash, tx.Hash)
+	}
+
+	assert.Greater(t, blk.Header.GasUsed+gas, blk.Header.GasLimit)
+	assert.Equal(t, pending+1-uint64(len(blk.Transactions)), sw.txpool.Length())
+}
+
+// followAvailSlots hands the Avail blocks produced from now on over to the
+// block production as slots, the way Run does.
+func followAvailSlots(t *testing.T, sw *SequencerWorker, fake *testutil.Fake) {
+	t.Helper()
+
+	bs := fake.BlockStream(sw.ctx, 0)
+	t.Cleanup(bs.Close)
+
+	go func() {
+		for blk := range bs.Chan() {
+			sw.announceSlot(uint64(blk.Block.Header.Number))
+		}
+	}()
+}
+
+func TestSequencerProducesOncePerAvailSlot(t *testing.T) {
+	fake := testutil.NewFake(avail_types.NewUCompactFromUInt(1), testutil.WithTxPool())
+	fake.DuplicateDelivery(true)
+
+	sw, fraudResolver, _ := newTestSequencerWorker(t, fake)
+	sw.production.SlotStallTimeout = time.Hour
+	followAvailSlots(t, sw, fake)
+
+	base := sw.blockchain.Header().Number
+	clock, stop := startWriteBlocksLoop(t, sw, fraudResolver)
+	defer stop()
+
+	// The clock makes no slots while the Avail blocks come in.
+	clock.tick()
+	clock.tick()
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, 0, fake.Pending())
+
+	// The block of every slot goes in the next Avail block.
+	const slots = 3
+	for i := uint64(1); i <= slots; i++ {
+		fake.Produce()
+
+		waitForBlock(t, sw, base+i-1)
+		assert.Eventually(t, func() bool { return fake.Pending() == 1 }, 5*time.Second, 10*time.Millisecond)
+	}
+
+	sw.blockProductionEnabled.Store(false)
+	fake.Produce()
+	waitForBlock(t, sw, base+slots)
+
+	for n := uint64(1); n <= slots+1; n++ {
+		blk, ok := fake.Block(n)
+		if !assert.True(t, ok) {
+			continue
+		}
+
+		if n == 1 {
+			assert.Empty(t, blk.Block.Extrinsics)
+		} else {
+			assert.Len(t, blk.Block.Extrinsics, 1, "Avail block %d", n)
+		}
+	}
+}
+
+func TestSequencerFallsBackToClockSlots(t *testing.T) {
+	fake := testutil.NewFake(avail_types.NewUCompactFromUInt(1))
+
+	sw, fraudResolver, _ := newTestSequencerWorker(t, fake)
+	sw.production.SlotStallTimeout = 3 * sw.blockTime
+
+	base := sw.blockchain.Header().Number
+	clock, stop := startWriteBlocksLoop(t, sw, fraudResolver)
+	defer stop()
+
+	// No slots short of the stall timeout.
+	clock.tick()
+	clock.tick()
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, base, sw.blockchain.Header().Number)
+
+	// With the Avail stream stalled, a slot every block time.
+	clock.tick()
+	waitForBlock(t, sw, base+1)
+
+	clock.tick()
+	waitForBlock(t, sw, base+2)
+
+	// Back to the Avail slots once the Avail blocks come in again.
+	fake.Produce()
+	sw.announceSlot(fake.Head())
+	waitForBlock(t, sw, base+3)
+
+	clock.tick()
+	clock.tick()
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, base+3, sw.blockchain.Header().Number)
+
+	clock.tick()
+	waitForBlock(t, sw, base+4)
+}
+
+func TestSequencerReferencesAvailSlot(t *testing.T) {
+	fake := testutil.NewFake(avail_types.NewUCompactFromUInt(1), testutil.WithTxPool())
+
+	sw, fraudResolver, _ := newTestSequencerWorker(t, fake)
+	sw.production.SlotStallTimeout = time.Hour
+	followAvailSlots(t, sw, fake)
+
+	base := sw.blockchain.Header().Number
+	_, stop := startWriteBlocksLoop(t, sw, fraudResolver)
+	defer stop()
+
+	// A block in the slot of each of the Avail blocks 1, 2 and 3, settled in
+	// the next Avail block; it's submitted before the next one comes in.
+	for i := uint64(1); i <= 3; i++ {
+		fake.Produce()
+		waitForBlock(t, sw, base+i-1)
+		assert.Eventually(t, func() bool { return fake.Pending() == 1 }, 5*time.Second, 10*time.Millisecond)
+	}
+
+	// The block of the third slot settles and no other follows.
+	sw.blockProductionEnabled.Store(false)
+	fake.Produce()
+	waitForBlock(t, sw, base+3)
+
+	first, ok := sw.blockchain.GetHeaderByNumber(base + 1)
+	if !assert.True(t, ok) {
+		t.FailNow()
+	}
+
+	second, ok := sw.blockchain.GetHeaderByNumber(base + 2)
+	if !assert.True(t, ok) {
+		t.FailNow()
+	}
+
+	for availHeight, h := range map[uint64]*types.Header{1: first, 2: second} {
+		ref, ok := block.GetExtraDataAvailReference(h)
+		if assert.True(t, ok) {
+			assert.Equal(t, availHeight, ref)
+		}
+	}
+
+	assert.NoError(t, block.VerifyAvailReference(second, first))
+
+	// A block on top of the second one referencing the Avail block before
+	// its parent's is rejected; one without a reference, as produced before
+	// the reference, passes.
+	v := validator.New(sw.blockchain, sw.nodeAddr, sw.logger)
+
+	build := func(ref []byte) *types.Block {
+		bb, err := block.NewBlockBuilderFactory(sw.blockchain, sw.executor, sw.logger).FromParentHash(second.Hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		bb.SetCoinbaseAddress(sw.nodeAddr)
+		bb.SignWith(sw.nodeSignKey)
+
+		if ref != nil {
+			bb.SetExtraDataField(block.KeyAvailReference, ref)
+		}
+
+		blk, err := bb.Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return blk
+	}
+
+	assert.ErrorIs(t, v.Check(build(binary.BigEndian.AppendUint64(nil, 1))), block.ErrAvailReferenceRegressed)
+	assert.NoError(t, v.Check(build(binary.BigEndian.AppendUint64(nil, 2))))
+	assert.NoError(t, v.Check(build(nil)))
+}
+
+func TestSequencerRejectsOffTimeBlocks(t *testing.T) {
+	sw, _, _ := newTestSequencerWorker(t, testutil.NewFake(avail_types.NewUCompactFromUInt(1)))
+
+	// The parent is stamped with the current time.
+	addr, _ := test.NewAccount(t)
+	test.DepositBalance(t, addr, big.NewInt(1), sw.blockchain, sw.executor)
+
+	parent := sw.blockchain.Header()
+	now := time.Unix(int64(parent.Timestamp), 0)
+
+	build := func(timestamp time.Time) *types.Block {
+		bb, err := block.NewBlockBuilderFactory(sw.blockchain, sw.executor, sw.logger).FromParentHash(parent.Hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		blk, err := bb.SetCoinbaseAddress(sw.nodeAddr).SetTimestamp(uint64(timestamp.Unix())).SignWith(sw.nodeSignKey).Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return blk
+	}
+
+	clock := func() time.Time { return now }
+
+	v := validator.New(sw.blockchain, sw.nodeAddr, sw.logger, validator.WithClock(clock))
+	wt := watchtower.New(sw.blockchain, sw.executor, nil, sw.logger, sw.nodeAddr, sw.nodeSignKey, 0, watchtower.WithClock(clock))
+
+	testCases := []struct {
+		name string
+		blk  *types.Block
+		want error
+	}{
+		{"on time", build(now.Add(time.Second)), nil},
+		{"an hour in the future", build(now.Add(time.Hour)), block.ErrTimestampTooFarAhead},
+		{"before the parent", build(now.Add(-time.Second)), block.ErrTimestampBeforeParent},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// The nodes reject the block, and the watchtowers take it for
+			// fraudulent.
+			assert.ErrorIs(t, v.Check(tc.blk), tc.want)
+			assert.ErrorIs(t, wt.Check(tc.blk), tc.want)
+		})
+	}
+
+	// The clock catching up, the block of an hour ahead is on time.
+	later := validator.New(sw.blockchain, sw.nodeAddr, sw.logger, validator.WithClock(func() time.Time { return now.Add(time.Hour) }))
+	assert.NoError(t, later.Check(testCases[1].blk))
+}
+
+func TestSequencerDynamicFeeTxs(t *testing.T) {
+	a, _ := NewTestAvail(t, Sequencer)
+
+	sw, fraudResolver, _ := newTestSequencerWorkerOf(t, a, testutil.NewFake(avail_types.NewUCompactFromUInt(1)))
+
+	legacy, dynamic := newTestSender(t, sw), newTestSender(t, sw)
+	recipient := types.StringToAddress("0x1234")
+
+	baseFee := sw.blockchain.CalculateBaseFee(sw.blockchain.Header())
+	assert.NotZero(t, baseFee)
+
+	signer := crypto.NewLondonSigner(100, true, crypto.NewEIP155Signer(100, true))
+
+	dynamicTransfer := func(feeCap, tipCap uint64) *types.Transaction {
+		t.Helper()
+
+		tx, err := signer.SignTx(&types.Transaction{
+			Type:      types.DynamicFeeTx,
+			From:      dynamic.addr,
+			Nonce:     dynamic.nonce,
+			To:        &recipient,
+			Value:     big.NewInt(1),
+			Gas:       50_000,
+			GasFeeCap: new(big.Int).SetUint64(feeCap),
+			GasTipCap: new(big.Int).SetUint64(tipCap),
+		}, dynamic.key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		dynamic.nonce++
+
+		return tx.ComputeHash()
+	}
+
+	// The dynamic-fee transaction capped under the base fee is rejected.
+	assert.ErrorIs(t, a.AddTx(dynamicTransfer(baseFee-1, 1)), ErrUnderpricedTx)
+
+	dynamic.nonce--
+
+	// The legacy transaction priced under the base fee still goes in, as it
+	// did before the base fee.
+	const legacyPrice, tip = 100, 3
+
+	legacyTx := legacy.sign(t, &types.Transaction{To: &recipient, Value: big.NewInt(1), Gas: 21_000}, legacyPrice)
+	dynamicTx := dynamicTransfer(2*baseFee, tip)
+
+	assert.NoError(t, a.AddTx(legacyTx))
+	assert.NoError(t, a.AddTx(dynamicTx))
+	assert.Eventually(t, func() bool { return sw.txpool.Length() == 2 }, 5*time.Second, 10*time.Millisecond)
+
+	burn := types.ZeroAddress
+
+	balances := func() map[types.Address]*big.Int {
+		t.Helper()
+
+		head := sw.blockchain.Header()
+
+		txn, err := sw.executor.BeginTxn(head.StateRoot, head, types.ZeroAddress)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got := make(map[types.Address]*big.Int)
+		for _, addr := range []types.Address{legacy.addr, dynamic.addr, sw.nodeAddr, burn} {
+			got[addr] = txn.GetBalance(addr)
+		}
+
+		return got
+	}
+
+	before := balances()
+
+	account := accounts.Account{Address: common.Address(sw.nodeAddr)}
+	if err := sw.writeBlock(fraudResolver, account, &keystore.Key{PrivateKey: sw.nodeSignKey}); err != nil {
+		t.Fatal(err)
+	}
+
+	blk, _ := sw.blockchain.GetBlockByNumber(sw.blockchain.Header().Number, true)
+	assert.Equal(t, baseFee, blk.Header.BaseFee)
+	assert.Len(t, blk.Transactions, 2)
+
+	receipts, err := sw.blockchain.GetReceiptsByHash(blk.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gasUsed := make(map[types.Hash]uint64, len(receipts))
+
+	var total uint64
+	for _, r := range receipts {
+		gasUsed[r.TxHash] = r.GasUsed
+		total += r.GasUsed
+	}
+
+	assert.Equal(t, total, blk.Header.GasUsed)
+
+	// The legacy transaction pays its gas price in full to the coinbase, the
+	// dynamic-fee one pays the base fee plus its tip, the base fee burnt.
+	legacyFee := new(big.Int).SetUint64(gasUsed[legacyTx.Hash] * legacyPrice)
+	dynamicFee := new(big.Int).SetUint64(gasUsed[dynamicTx.Hash] * (baseFee + tip))
+	tips := new(big.Int).SetUint64(gasUsed[dynamicTx.Hash] * tip)
+	burnt := new(big.Int).SetUint64(gasUsed[dynamicTx.Hash] * baseFee)
+
+	after := balances()
+	spent := func(addr types.Address) *big.Int { return new(big.Int).Sub(before[addr], after[addr]) }
+	earned := func(addr types.Address) *big.Int { return new(big.Int).Sub(after[addr], before[addr]) }
+
+	assert.Equal(t, new(big.Int).Add(legacyFee, big.NewInt(1)), spent(legacy.addr))
+	assert.Equal(t, new(big.Int).Add(dynamicFee, big.NewInt(1)), spent(dynamic.addr))
+	assert.Equal(t, new(big.Int).Add(legacyFee, tips), earned(sw.nodeAddr))
+	assert.Equal(t, burnt, earned(burn))
+
+	assert.NoError(t, validator.New(sw.blockchain, sw.nodeAddr, sw.logger).Check(blk))
+}
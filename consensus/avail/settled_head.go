@@ -0,0 +1,286 @@
+package avail
+
+import (
+	"sync"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/pkg/blockchain"
+	"github.com/hashicorp/go-hclog"
+)
+
+// SettledHead is the highest block of the chain settled on Avail past the
+// challenge window, as returned by `avail_getSettledHead`.
+type SettledHead struct {
+	Number     uint64     `json:"number"`
+	Hash       types.Hash `json:"hash"`
+	AvailBlock uint64     `json:"availBlock"`
+}
+
+// settledHead tracks the soft finality of the chain: the highest canonical
+// block included in Avail whose challenge window, the Avail blocks a fraud
+// proof against it may come in, has passed. The settled blocks are final to
+// the fork choice; the settled head never moves back, but for a dispute
+// resolution forking the chain out below it, which is flagged loudly. The
+// finality of the Avail blocks themselves isn't tracked yet, so the inclusion
+// is what the window counts from. Each block has the window in force at its
+// number.
+type settledHead struct {
+	blockchain *blockchain.Blockchain
+	window     ChallengeWindow
+	logger     hclog.Logger
+
+	lock     sync.Mutex
+	head     SettledHead
+	included map[types.Hash]uint64 // Avail heights of the blocks seen on Avail above the head, by hash
+	settle   func(SettledHead)     // Called on each block settled, under the lock; nil for none
+}
+
+// newSettledHead returns the settledHead of the chain settling the blocks
+// their challenge window after their inclusion, starting from the genesis.
+func newSettledHead(blockchain *blockchain.Blockchain, window ChallengeWindow, logger hclog.Logger) *settledHead {
+	s := &settledHead{
+		blockchain: blockchain,
+		window:     window,
+		logger:     logger,
+		included:   make(map[types.Hash]uint64),
+	}
+
+	if genesis, ok := blockchain.GetHeaderByNumber(0); ok {
+		s.head.Hash = genesis.Hash
+	}
+
+	return s
+}
+
+// Head returns the settled head.
+func (s *settledHead) Head() SettledHead {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.head
+}
+
+// Number returns the number of the settled head; zero without any settled
+// head tracked.
+func (s *settledHead) Number() uint64 {
+	if s == nil {
+		return 0
+	}
+
+	return s.Head().Number
+}
+
+// onSettle has fn called on each block settled as the head advances, in
+// order, with the lock held: fn is to return quickly, short of calling back
+// into the settled head.
+func (s *settledHead) onSettle(fn func(SettledHead)) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.settle = fn
+}
+
+// include notes the block seen on Avail at the given height; a block seen
+// again keeps its first inclusion.
+func (s *settledHead) include(h *types.Header, availHeight uint64) {
+	if s == nil {
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if h.Number <= s.head.Number {
+		return
+	}
+
+	if _, ok := s.included[h.Hash]; !ok {
+		s.included[h.Hash] = availHeight
+	}
+}
+
+// advance settles the canonical blocks, on top of the settled head, whose
+// challenge window has passed at the Avail block at the given height.
+func (s *settledHead) advance(availHeight uint64) {
+	if s == nil {
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.rewindLocked()
+
+	head := s.head
+
+	var settled []SettledHead
+
+	for {
+		h, ok := s.blockchain.GetHeaderByNumber(head.Number + 1)
+		if !ok {
+			break
+		}
+
+		included, ok := s.included[h.Hash]
+		if !ok || included+s.window.At(h.Number) > availHeight {
+			break
+		}
+
+		head = SettledHead{Number: h.Number, Hash: h.Hash, AvailBlock: included}
+		settled = append(settled, head)
+	}
+
+	if head.Number == s.head.Number {
+		return
+	}
+
+	s.head = head
+
+	for hash := range s.included {
+		if h, ok := s.blockchain.GetHeaderByHash(hash); !ok || h.Number <= head.Number {
+			delete(s.included, hash)
+		}
+	}
+
+	observeSettledHead(head.Number)
+
+	if s.settle != nil {
+		for _, head := range settled {
+			s.settle(head)
+		}
+	}
+
+	s.logger.Debug("settled head advanced", "block_number", head.Number, "block_hash", head.Hash, "avail_block_number", head.AvailBlock)
+}
+
+// inclusion returns the Avail height the block was seen at, for the blocks
+// above the settled head and the settled head itself, along with the
+// challenge window in force at its number.
+func (s *settledHead) inclusion(h *types.Header) (uint64, uint64, bool) {
+	if s == nil {
+		return 0, 0, false
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	window := s.window.At(h.Number)
+
+	if included, ok := s.included[h.Hash]; ok {
+		return included, window, true
+	}
+
+	if h.Hash == s.head.Hash && h.Number > 0 {
+		return s.head.AvailBlock, window, true
+	}
+
+	return 0, window, false
+}
+
+// challengeable returns a ChallengeWindowError if the challenge window of the
+// block has passed at the Avail block at the given height. The blocks not seen
+// on Avail yet are open to challenges.
+func (s *settledHead) challengeable(h *types.Header, availHeight uint64) error {
+	if s == nil {
+		return nil
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	window := s.window.At(h.Number)
+
+	if included, ok := s.included[h.Hash]; ok {
+		if included+window > availHeight {
+			return nil
+		}
+
+		return &ChallengeWindowError{Number: h.Number, Hash: h.Hash, IncludedAt: included, Window: window, AvailHeight: availHeight}
+	}
+
+	if h.Number > s.head.Number {
+		return nil
+	}
+
+	// The settled blocks are no longer tracked, but the settled head.
+	err := &ChallengeWindowError{Number: h.Number, Hash: h.Hash, Window: window, AvailHeight: availHeight}
+	if h.Hash == s.head.Hash {
+		err.IncludedAt = s.head.AvailBlock
+	}
+
+	return err
+}
+
+// check moves the settled head back should the chain have been forked out
+// below it, which only a dispute resolution may do.
+func (s *settledHead) check() {
+	if s == nil {
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.rewindLocked()
+}
+
+// rewindLocked moves the settled head back to the highest of its ancestors
+// left in the chain, if it's no longer canonical. It must be called with the
+// lock held.
+func (s *settledHead) rewindLocked() {
+	if canonical, ok := s.blockchain.GetHeaderByNumber(s.head.Number); ok && canonical.Hash == s.head.Hash {
+		return
+	}
+
+	prev := s.head
+
+	h, ok := s.blockchain.GetHeaderByHash(s.head.Hash)
+	for ok && h.Number > 0 {
+		if canonical, found := s.blockchain.GetHeaderByNumber(h.Number); found && canonical.Hash == h.Hash {
+			break
+		}
+
+		h, ok = s.blockchain.GetHeaderByHash(h.ParentHash)
+	}
+
+	if !ok {
+		h, _ = s.blockchain.GetHeaderByNumber(0)
+	}
+
+	s.head = SettledHead{Number: h.Number, Hash: h.Hash}
+
+	observeSettledHeadRewind(prev.Number - h.Number)
+
+	s.logger.Error(
+		"settled head forked out of the chain by a dispute resolution; moving it back",
+		"settled_block_number", prev.Number,
+		"settled_block_hash", prev.Hash,
+		"block_number", h.Number,
+		"block_hash", h.Hash,
+	)
+}
+
+// restore sets the settled head to the one of the chain snapshot imported,
+// unless it's settled past it already.
+func (s *settledHead) restore(head SettledHead) {
+	if s == nil {
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if head.Number <= s.head.Number {
+		return
+	}
+
+	s.head = head
+
+	for hash := range s.included {
+		if h, ok := s.blockchain.GetHeaderByHash(hash); !ok || h.Number <= head.Number {
+			delete(s.included, hash)
+		}
+	}
+
+	observeSettledHead(head.Number)
+}
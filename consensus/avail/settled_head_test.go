@@ -0,0 +1,97 @@
+package avail
+
+import (
+	"testing"
+
+	"github.com/availproject/op-evm/consensus/avail/validator"
+	"github.com/availproject/op-evm/pkg/avail"
+	"github.com/availproject/op-evm/pkg/avail/testutil"
+	avail_types "github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSettledHeadFollowsChallengeWindow(t *testing.T) {
+	appID := avail_types.NewUCompactFromUInt(1)
+	fake := testutil.NewFake(appID)
+
+	a, b, observer := newTestGenesisAvail(t), newTestGenesisAvail(t), newTestGenesisAvail(t)
+
+	blk1 := buildTestBlock(t, a)
+	settleTestBlock(t, a, fake, blk1)
+
+	blk2 := buildTestBlock(t, a)
+	settleTestBlock(t, a, fake, blk2)
+
+	included := fake.Head()
+
+	const window = 3
+
+	observer.forkChoice = newForkChoice(observer.blockchain, observer.minerAddr, DefaultMaxReorgDepth, newSettledHead(observer.blockchain, NewChallengeWindow(window), observer.logger), nil, observer.logger)
+
+	sw, fraudResolver, _ := newTestSequencerWorkerOf(t, observer, fake)
+	sw.availClient = fake
+	sw.availAppID = appID
+	sw.catchUp = CatchUpConfig{Threshold: 0, PageSize: 20}
+
+	decoder := avail.NewBlockDecoder(fake, appID, sw.logger)
+
+	cursor := uint64(1)
+	follow := func() {
+		t.Helper()
+
+		next, err := sw.catchUpWithAvail(decoder, validator.New(sw.blockchain, sw.nodeAddr, sw.logger), fraudResolver, cursor)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		cursor = next
+	}
+
+	srv := rpc.NewServer()
+	if err := srv.RegisterName(avail.SettlementNamespace, NewStatusAPI(observer)); err != nil {
+		t.Fatal(err)
+	}
+
+	c := rpc.DialInProc(srv)
+	defer c.Close()
+
+	settledHead := func() SettledHead {
+		t.Helper()
+
+		var head SettledHead
+		if err := c.Call(&head, "avail_getSettledHead"); err != nil {
+			t.Fatal(err)
+		}
+
+		return head
+	}
+
+	// Both blocks are on the chain, but still open to fraud proofs.
+	follow()
+	assert.Equal(t, blk2.Hash(), observer.blockchain.Header().Hash)
+	assert.Equal(t, uint64(0), settledHead().Number)
+
+	// The Avail chain moves on past the challenge window of the blocks.
+	for fake.Head() < included+window {
+		fake.Produce()
+	}
+
+	follow()
+
+	head := settledHead()
+	assert.Equal(t, uint64(2), head.Number)
+	assert.Equal(t, blk2.Hash(), head.Hash)
+	assert.Equal(t, included, head.AvailBlock)
+
+	// A competing block below the settled head is refused, even from the
+	// scheduled leader.
+	competing := buildTestBlock(t, b)
+
+	write, err := observer.forkChoice.apply(competing, blockInclusion{availBlock: fake.Head() + 1, byLeader: true}, observer.nodeType.String())
+	assert.ErrorIs(t, err, errReorgBelowSettledHead)
+	assert.Equal(t, blockConflicting, write)
+
+	assert.Equal(t, blk2.Hash(), observer.blockchain.Header().Hash)
+	assert.Equal(t, uint64(2), settledHead().Number)
+}
@@ -0,0 +1,41 @@
+package avail
+
+import (
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/pkg/avail"
+)
+
+// settlementStage is the stage of a block sent to a "settlements"
+// subscriber.
+type settlementStage struct {
+	hash  types.Hash
+	stage string
+}
+
+// settlementBackfill returns the settlement events of the current stages of
+// the canonical blocks numbered from and to the given ones, inclusive, in
+// their order: the inclusion in Avail of the blocks recorded in the
+// settlement index, followed by the finality of their Avail blocks if final.
+func (d *Avail) settlementBackfill(from, to uint64) []avail.SettlementEvent {
+	var events []avail.SettlementEvent
+
+	for number := from; number <= to; number++ {
+		hdr, ok := d.blockchain.GetHeaderByNumber(number)
+		if !ok {
+			break
+		}
+
+		info, ok := d.settlements.GetInfo(hdr.Hash)
+		if !ok {
+			continue
+		}
+
+		events = append(events, avail.SettlementEvent{Stage: avail.SettlementIncluded, BlockNumber: number, SettlementInfo: info})
+
+		if info.AvailFinalized {
+			events = append(events, avail.SettlementEvent{Stage: avail.SettlementFinalized, BlockNumber: number, SettlementInfo: info})
+		}
+	}
+
+	return events
+}
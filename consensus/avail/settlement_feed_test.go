@@ -0,0 +1,133 @@
+package avail
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/pkg/avail"
+	"github.com/availproject/op-evm/pkg/avail/testutil"
+	"github.com/availproject/op-evm/pkg/test"
+	avail_types "github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/assert"
+)
+
+// nextSettlementEvent returns the next event received on the channel.
+func nextSettlementEvent(t *testing.T, events <-chan avail.SettlementEvent) avail.SettlementEvent {
+	t.Helper()
+
+	select {
+	case e := <-events:
+		return e
+	case <-time.After(5 * time.Second):
+		t.Fatal("no settlement event received")
+	}
+
+	return avail.SettlementEvent{}
+}
+
+func TestSettlementsSubscription(t *testing.T) {
+	d := newTestGenesisAvail(t)
+	d.settlements = avail.NewSettlementIndex()
+
+	fake := testutil.NewFake(avail_types.NewUCompactFromUInt(1))
+	fake.DelayFinality(2)
+
+	sender := avail.RecordSettlements(fake, d.settlements)
+
+	srv := rpc.NewServer()
+	if err := srv.RegisterName(avail.SettlementNamespace, NewStatusAPI(d)); err != nil {
+		t.Fatal(err)
+	}
+
+	c := rpc.DialInProc(srv)
+	defer c.Close()
+
+	ctx := context.Background()
+
+	live := make(chan avail.SettlementEvent, 4)
+
+	sub, err := c.Subscribe(ctx, avail.SettlementNamespace, live, "settlements")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer sub.Unsubscribe()
+
+	faucet := &testSender{addr: test.FaucetAccount, key: test.FaucetSignKey}
+	blk := buildTestBlock(t, d, faucet.sign(t, &types.Transaction{To: &types.ZeroAddress, Value: big.NewInt(1), Gas: 21_000}, 1))
+
+	if err := d.blockchain.WriteBlock(blk, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The block goes into an Avail block, not final yet.
+	res, err := sender.SendAndWaitForStatus(ctx, blk, avail_types.ExtrinsicStatus{IsInBlock: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	included := nextSettlementEvent(t, live)
+	assert.Equal(t, avail.SettlementIncluded, included.Stage)
+	assert.Equal(t, blk.Number(), included.BlockNumber)
+	assert.Equal(t, blk.Hash(), included.BlockHash)
+	assert.Equal(t, res.BlockNumber, included.AvailBlockNumber)
+	assert.Equal(t, res.BlockHash.Hex(), included.AvailBlockHash)
+	assert.False(t, included.AvailFinalized)
+
+	if err := d.settlements.CheckFinality(ctx, fake); err != nil {
+		t.Fatal(err)
+	}
+
+	// The Avail block goes final once two more are built on top of it.
+	fake.Produce()
+	fake.Produce()
+
+	if err := d.settlements.CheckFinality(ctx, fake); err != nil {
+		t.Fatal(err)
+	}
+
+	finalized := nextSettlementEvent(t, live)
+	assert.Equal(t, avail.SettlementFinalized, finalized.Stage)
+	assert.Equal(t, blk.Number(), finalized.BlockNumber)
+	assert.Equal(t, blk.Hash(), finalized.BlockHash)
+	assert.Equal(t, res.BlockNumber, finalized.AvailBlockNumber)
+	assert.True(t, finalized.AvailFinalized)
+
+	// Checked anew, the block isn't published final twice.
+	if err := d.settlements.CheckFinality(ctx, fake); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case e := <-live:
+		t.Fatalf("unexpected settlement event %+v", e)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	info, ok := d.settlements.GetInfo(blk.Hash())
+	assert.True(t, ok)
+	assert.True(t, info.AvailFinalized)
+
+	// The subscriber joining later is backfilled with both stages of the
+	// block.
+	backfilled := make(chan avail.SettlementEvent, 4)
+
+	late, err := c.Subscribe(ctx, avail.SettlementNamespace, backfilled, "settlements", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer late.Unsubscribe()
+
+	e := nextSettlementEvent(t, backfilled)
+	assert.Equal(t, avail.SettlementIncluded, e.Stage)
+	assert.Equal(t, blk.Hash(), e.BlockHash)
+
+	e = nextSettlementEvent(t, backfilled)
+	assert.Equal(t, avail.SettlementFinalized, e.Stage)
+	assert.Equal(t, blk.Hash(), e.BlockHash)
+}
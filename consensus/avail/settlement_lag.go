@@ -0,0 +1,93 @@
+package avail
+
+import (
+	"sync/atomic"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/hashicorp/go-hclog"
+)
+
+// settlementLag holds the block production back while the head of the chain
+// runs too far ahead of the blocks seen settled on Avail, so that a slowdown
+// of Avail doesn't widen the window of the blocks a crash may lose. The
+// blocks seen on Avail, while catching up as well as following the live
+// blocks, settle the chain up to their height; the production resumes once
+// the lag shrinks under the threshold again.
+type settlementLag struct {
+	max    uint64
+	logger hclog.Logger
+
+	settled atomic.Uint64
+	lag     atomic.Uint64
+	paused  atomic.Bool
+}
+
+// newSettlementLag returns the settlementLag pausing the production at the
+// given lag; zero never pauses it.
+func newSettlementLag(max uint64, logger hclog.Logger) *settlementLag {
+	return &settlementLag{
+		max:    max,
+		logger: logger,
+	}
+}
+
+// settle notes the chain settled on Avail up to the given block.
+func (l *settlementLag) settle(number uint64) {
+	for {
+		settled := l.settled.Load()
+		if number <= settled || l.settled.CompareAndSwap(settled, number) {
+			return
+		}
+	}
+}
+
+// Settled returns the highest block seen settled on Avail.
+func (l *settlementLag) Settled() uint64 {
+	return l.settled.Load()
+}
+
+// Lag returns the number of blocks the head was ahead of the settled ones
+// when last observed.
+func (l *settlementLag) Lag() uint64 {
+	return l.lag.Load()
+}
+
+// Paused reports whether the block production is paused due to the lag.
+func (l *settlementLag) Paused() bool {
+	return l.paused.Load()
+}
+
+// observe measures the lag of the given head behind the blocks settled on
+// Avail, and reports whether the block production is to be paused.
+func (l *settlementLag) observe(head uint64) bool {
+	settled := l.settled.Load()
+
+	var lag uint64
+	if head > settled {
+		lag = head - settled
+	}
+
+	paused := l.max > 0 && lag >= l.max
+
+	l.lag.Store(lag)
+
+	if l.paused.Swap(paused) != paused {
+		if paused {
+			l.logger.Warn("chain too far ahead of the blocks settled on Avail; pausing block production", "lag", lag, "max_lag", l.max, "settled_block", settled)
+		} else {
+			l.logger.Info("settlement on Avail caught up; resuming block production", "lag", lag, "settled_block", settled)
+		}
+	}
+
+	observeSettlementLag(lag, paused)
+
+	return paused
+}
+
+// settleAvailBlock notes the chain settled up to the block seen on Avail,
+// provided it's the canonical block at its height.
+func (sw *SequencerWorker) settleAvailBlock(blk *types.Block) {
+	if canonical, ok := sw.blockchain.GetHeaderByNumber(blk.Number()); ok && canonical.Hash == blk.Hash() {
+		sw.settlement.settle(blk.Number())
+	}
+}
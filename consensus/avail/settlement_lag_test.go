@@ -0,0 +1,91 @@
+package avail
+
+import (
+	"testing"
+	"time"
+
+	"github.com/availproject/op-evm/pkg/avail"
+	"github.com/availproject/op-evm/pkg/avail/testutil"
+	avail_types "github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+// followAvailBlocks applies the edge blocks of the Avail blocks streamed by
+// the fake to the chain, the way Run does.
+func followAvailBlocks(t *testing.T, sw *SequencerWorker, fake *testutil.Fake) {
+	t.Helper()
+
+	bs := fake.BlockStream(sw.ctx, 0)
+	t.Cleanup(bs.Close)
+
+	go func() {
+		for blk := range bs.Chan() {
+			decoded, err := avail.BlockFromAvail(blk, avail_types.NewUCompactFromUInt(1), fake.SubmitDataCallIndex(), sw.logger)
+			if err != nil {
+				continue
+			}
+
+			for _, d := range decoded {
+				_ = sw.applyAvailBlock(d, blockInclusion{availBlock: uint64(blk.Block.Header.Number)})
+			}
+		}
+	}()
+}
+
+func TestSettlementLag(t *testing.T) {
+	l := newSettlementLag(3, hclog.NewNullLogger())
+
+	l.settle(10)
+	l.settle(8)
+	assert.Equal(t, uint64(10), l.Settled())
+
+	assert.False(t, l.observe(12))
+	assert.Equal(t, uint64(2), l.Lag())
+
+	assert.True(t, l.observe(13))
+	assert.True(t, l.Paused())
+
+	// A head behind the settled blocks, such as after a reorg, doesn't lag.
+	assert.False(t, l.observe(9))
+	assert.Equal(t, uint64(0), l.Lag())
+
+	// Zero never pauses.
+	assert.False(t, newSettlementLag(0, l.logger).observe(1_000))
+}
+
+func TestSequencerPausesOnSettlementLag(t *testing.T) {
+	fake := testutil.NewFake(avail_types.NewUCompactFromUInt(1))
+
+	sw, fraudResolver, _ := newTestSequencerWorker(t, fake)
+	sw.settlement = newSettlementLag(3, sw.logger)
+
+	// Avail includes the blocks, but they don't come back through the
+	// stream for now.
+	fake.StallDelivery(true)
+	followAvailBlocks(t, sw, fake)
+
+	base := sw.blockchain.Header().Number
+	clock, stop := startWriteBlocksLoop(t, sw, fraudResolver)
+	defer stop()
+
+	for i := uint64(1); i <= 3; i++ {
+		clock.tick()
+		waitForBlock(t, sw, base+i)
+	}
+
+	// At the threshold, the production pauses.
+	clock.tick()
+	clock.tick()
+	assert.Eventually(t, sw.settlement.Paused, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, base+3, sw.blockchain.Header().Number)
+	assert.Equal(t, uint64(3), sw.settlement.Lag())
+
+	// Once the submissions come through, it resumes.
+	fake.StallDelivery(false)
+	assert.Eventually(t, func() bool { return sw.settlement.Settled() == base+3 }, 5*time.Second, 10*time.Millisecond)
+
+	clock.tick()
+	waitForBlock(t, sw, base+4)
+	assert.False(t, sw.settlement.Paused())
+}
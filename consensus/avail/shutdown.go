@@ -0,0 +1,118 @@
+package avail
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+)
+
+// errShuttingDown is returned for the block abandoned before its submission
+// to Avail because of the shutdown.
+var errShuttingDown = errors.New("shutting down")
+
+// blockPhase is the phase of the block in flight.
+type blockPhase int
+
+const (
+	// blockBuilding is the phase of executing the transactions of the block.
+	blockBuilding blockPhase = iota
+
+	// blockSubmitting is the phase of waiting for the inclusion of the block in Avail.
+	blockSubmitting
+
+	// blockWriting is the phase of writing the block included in Avail to the local chain.
+	blockWriting
+)
+
+// gracefulShutdown sequences the shutdown of the block production so that the
+// local chain doesn't diverge from Avail: the new slots are no longer taken,
+// the block in flight settles, and only then the run context is canceled.
+//
+// The block still being built is abandoned, while the one submitted to Avail
+// is waited for to be included and written to the local chain. The timeout
+// bounds the whole procedure; past it, the submission is aborted and the
+// block, if it makes it to Avail after all, is synced on the next start.
+type gracefulShutdown struct {
+	closeCh chan struct{}
+	cancel  context.CancelFunc
+	timeout time.Duration
+
+	lock     sync.Mutex
+	closed   bool
+	inflight chan struct{} // Closed once the block in flight settles; nil when idle
+}
+
+// newGracefulShutdown returns the gracefulShutdown closing the close channel
+// and canceling the run context with the given cancel function.
+func newGracefulShutdown(closeCh chan struct{}, cancel context.CancelFunc, timeout time.Duration) *gracefulShutdown {
+	return &gracefulShutdown{
+		closeCh: closeCh,
+		cancel:  cancel,
+		timeout: timeout,
+	}
+}
+
+// closing returns the channel closed once the shutdown starts.
+func (s *gracefulShutdown) closing() <-chan struct{} {
+	return s.closeCh
+}
+
+// beginBlock marks the start of a block in flight; it reports false, and the
+// block must not be started, once the shutdown has started.
+func (s *gracefulShutdown) beginBlock() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.closed {
+		return false
+	}
+
+	s.inflight = make(chan struct{})
+
+	return true
+}
+
+// endBlock marks the block in flight settled.
+func (s *gracefulShutdown) endBlock() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.inflight != nil {
+		close(s.inflight)
+		s.inflight = nil
+	}
+}
+
+// run shuts down the block production, returning once the block in flight
+// settles or the timeout passes, and the run context is canceled. Only the
+// first call does the work; the others return right away.
+func (s *gracefulShutdown) run(logger hclog.Logger) {
+	s.lock.Lock()
+	if s.closed {
+		s.lock.Unlock()
+		return
+	}
+
+	s.closed = true
+	close(s.closeCh)
+	inflight := s.inflight
+	s.lock.Unlock()
+
+	if inflight != nil {
+		logger.Info("waiting for the block in flight to settle before shutting down", "timeout", s.timeout)
+
+		deadline := time.NewTimer(s.timeout)
+		defer deadline.Stop()
+
+		select {
+		case <-inflight:
+		case <-deadline.C:
+			logger.Warn("block in flight didn't settle before the shutdown timeout; aborting it", "timeout", s.timeout)
+		}
+	}
+
+	s.cancel()
+}
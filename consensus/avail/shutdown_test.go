@@ -0,0 +1,163 @@
+package avail
+
+import (
+	"testing"
+	"time"
+
+	"github.com/availproject/op-evm/pkg/avail/testutil"
+	avail_types "github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+// assertNoDivergence restarts the sequencer against Avail: the blocks on Avail
+// the local chain misses are synced, as on the next start, after which the
+// blocks produced since the base must be the same on both.
+func assertNoDivergence(t *testing.T, sw *SequencerWorker, fake *testutil.Fake, base uint64) {
+	t.Helper()
+
+	blks, err := fake.EdgeBlocks()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, blk := range blks {
+		assert.Equal(t, base+uint64(i)+1, blk.Number())
+
+		if hdr, ok := sw.blockchain.GetHeaderByNumber(blk.Number()); ok {
+			assert.Equal(t, blk.Hash(), hdr.Hash, "block %d", blk.Number())
+			continue
+		}
+
+		if err := sw.blockchain.WriteBlock(blk, sw.nodeType.String()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// No blocks Avail doesn't know of.
+	assert.Equal(t, base+uint64(len(blks)), sw.blockchain.Header().Number)
+}
+
+func TestSequencerGracefulShutdown(t *testing.T) {
+	testCases := []struct {
+		name  string
+		phase blockPhase
+
+		// txPool holds the submission in the Avail pool until produced.
+		txPool  bool
+		timeout time.Duration
+
+		// written is whether the block is on the local chain once shut down.
+		written bool
+	}{
+		{
+			name:    "building",
+			phase:   blockBuilding,
+			written: false,
+		},
+		{
+			name:    "submitting",
+			phase:   blockSubmitting,
+			txPool:  true,
+			written: true,
+		},
+		{
+			name:    "submission timeout",
+			phase:   blockSubmitting,
+			txPool:  true,
+			timeout: 100 * time.Millisecond,
+			written: false,
+		},
+		{
+			name:    "writing",
+			phase:   blockWriting,
+			written: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			var opts []testutil.FakeOption
+			if tc.txPool {
+				opts = append(opts, testutil.WithTxPool())
+			}
+
+			fake := testutil.NewFake(avail_types.NewUCompactFromUInt(1), opts...)
+			sw, fraudResolver, _ := newTestSequencerWorker(t, fake)
+
+			if tc.timeout > 0 {
+				sw.shutdown.timeout = tc.timeout
+			}
+
+			// Shut down as the block enters the phase.
+			stopped := make(chan struct{})
+			sw.blockPhaseHook = func(phase blockPhase) {
+				if phase != tc.phase {
+					return
+				}
+
+				go func() {
+					sw.shutdown.run(sw.logger)
+					close(stopped)
+				}()
+
+				<-sw.closeCh
+			}
+
+			base := sw.blockchain.Header().Number
+			clock, stop := startWriteBlocksLoop(t, sw, fraudResolver)
+
+			clock.tick()
+
+			if tc.txPool && tc.timeout == 0 {
+				assert.Eventually(t, func() bool { return fake.Pending() == 1 }, 5*time.Second, 10*time.Millisecond)
+
+				// The shutdown waits for the inclusion.
+				select {
+				case <-stopped:
+					t.Fatal("shut down before the block in flight settled")
+				case <-time.After(50 * time.Millisecond):
+				}
+
+				fake.Produce()
+			}
+
+			select {
+			case <-stopped:
+			case <-time.After(5 * time.Second):
+				t.Fatal("shutdown timed out")
+			}
+
+			stop()
+
+			if tc.written {
+				assert.Equal(t, base+1, sw.blockchain.Header().Number)
+			} else {
+				assert.Equal(t, base, sw.blockchain.Header().Number)
+			}
+
+			// The aborted submission makes it to Avail after all.
+			if fake.Pending() > 0 {
+				fake.Produce()
+			}
+
+			assertNoDivergence(t, sw, fake, base)
+		})
+	}
+}
+
+func TestGracefulShutdownIdle(t *testing.T) {
+	closeCh := make(chan struct{})
+	canceled := false
+
+	s := newGracefulShutdown(closeCh, func() { canceled = true }, time.Hour)
+	s.run(hclog.NewNullLogger())
+
+	assert.True(t, canceled)
+	assert.False(t, s.beginBlock())
+
+	// Only the first call shuts down.
+	s.run(nil)
+}
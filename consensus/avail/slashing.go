@@ -0,0 +1,381 @@
+package avail
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"math/big"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/pkg/staking"
+)
+
+const (
+	// maxSlashAttempts is the number of the failed slash transactions of a
+	// disputed sequencer after which its slash is given up.
+	maxSlashAttempts = 5
+
+	// maxSlashRetryBackoff caps the blocks between the retries of a slash.
+	maxSlashRetryBackoff = 16
+)
+
+// slashOutcome is how a slash of a disputed sequencer went.
+type slashOutcome string
+
+const (
+	// slashVerified is a slash whose sequencer lost stake and left the
+	// active set.
+	slashVerified slashOutcome = "verified"
+
+	// slashStillActive is a slash whose sequencer lost stake, but is in the
+	// active set still.
+	slashStillActive slashOutcome = "still_active"
+
+	// slashUnverified is a slash that couldn't be checked against the state.
+	slashUnverified slashOutcome = "unverified"
+
+	// slashFailed is a slash that failed, or took no stake, to be retried.
+	slashFailed slashOutcome = "failed"
+
+	// slashAbandoned is a slash failed maxSlashAttempts times, given up.
+	slashAbandoned slashOutcome = "abandoned"
+)
+
+// rewardOutcome is how the reward of a slash went: the staking contract pays
+// the amount slashed off a disputed sequencer to the watchtower accusing it,
+// and the one slashed off a watchtower to the sequencer it accused. The share
+// of the stake slashed is the contract's slash percentage.
+type rewardOutcome string
+
+const (
+	// rewardPaid is a reward whose recipient's balance grew by the amount
+	// slashed.
+	rewardPaid rewardOutcome = "paid"
+
+	// rewardMismatch is a reward whose recipient's balance changed by other
+	// than the amount slashed in the block.
+	rewardMismatch rewardOutcome = "mismatch"
+
+	// rewardUnpaid is a slash the contract reported no reward of, or paid
+	// to no party of a dispute.
+	rewardUnpaid rewardOutcome = "unpaid"
+
+	// rewardUnverified is a reward that couldn't be checked against the
+	// state.
+	rewardUnverified rewardOutcome = "unverified"
+)
+
+// pendingSlash is the slash of a disputed sequencer whose transaction failed,
+// to be retried by the leaders to come.
+type pendingSlash struct {
+	// attempts is the number of the slash transactions failed.
+	attempts uint64
+
+	// retryAt is the number of the block the next attempt may go in.
+	retryAt uint64
+
+	// slashingAt is the number of the block built with the retry, until the
+	// block is written; 0 when none is in flight.
+	slashingAt uint64
+}
+
+// slashResult is a slash of a disputed sequencer, as checked against the
+// state of the staking contract before and after its block.
+type slashResult struct {
+	sequencer   types.Address
+	number      uint64
+	stakeBefore *big.Int
+	stakeAfter  *big.Int
+
+	// active is set for the sequencer in the active set after the slash.
+	active bool
+}
+
+// outcome returns how the slash went; the slash taking no stake failed.
+func (r slashResult) outcome() slashOutcome {
+	switch {
+	case r.stakeAfter.Cmp(r.stakeBefore) >= 0:
+		return slashFailed
+	case r.active:
+		return slashStillActive
+	default:
+		return slashVerified
+	}
+}
+
+// slashRetryBackoff returns the number of blocks to wait before retrying a
+// slash failed the given number of times, doubling with every failure.
+func slashRetryBackoff(attempts uint64) uint64 {
+	backoff := uint64(1)
+
+	for i := uint64(1); i < attempts && backoff < maxSlashRetryBackoff; i++ {
+		backoff *= 2
+	}
+
+	if backoff > maxSlashRetryBackoff {
+		return maxSlashRetryBackoff
+	}
+
+	return backoff
+}
+
+// observeSlash checks the slash of the sequencer by the transaction of the
+// given hash in the block, with the given receipt: a slash that went through is verified
+// against the state of the staking contract, while the one that failed, or
+// took no stake, is scheduled for a retry.
+func (w *disputeWatcher) observeSlash(blk *types.Block, sequencer types.Address, txHash types.Hash, receipt *types.Receipt) {
+	if receipt.Status == nil || *receipt.Status != types.ReceiptSuccess {
+		w.slashFailed(sequencer, blk.Number(), "slash transaction failed")
+		return
+	}
+
+	result, err := w.verifySlash(sequencer, blk.Header)
+	if err != nil {
+		w.lock.Lock()
+		delete(w.slashes, sequencer)
+		w.lock.Unlock()
+
+		observeSlash(slashUnverified)
+		w.logger.Error("failed to verify the slash of the disputed sequencer", "sequencer_addr", sequencer, "block_number", blk.Number(), "error", err)
+
+		return
+	}
+
+	outcome := result.outcome()
+	if outcome == slashFailed {
+		w.slashFailed(sequencer, blk.Number(), "slash took no stake")
+		return
+	}
+
+	w.lock.Lock()
+	delete(w.slashes, sequencer)
+	w.lock.Unlock()
+
+	observeSlash(outcome)
+	w.metrics.slashExecuted(sequencer)
+
+	reward := w.verifyReward(blk, sequencer, receipt)
+	w.publishSlash(blk, sequencer, txHash, outcome, reward)
+
+	logArgs := []interface{}{
+		"sequencer_addr", sequencer,
+		"block_number", blk.Number(),
+		"stake_before", result.stakeBefore,
+		"stake_after", result.stakeAfter,
+	}
+
+	if outcome == slashStillActive {
+		w.logger.Warn("slashed sequencer is in the active set still", logArgs...)
+		return
+	}
+
+	w.logger.Info("sequencer slashed", logArgs...)
+}
+
+// verifyReward checks the reward of the slash of the staker in the block,
+// reported by the slash event of the given receipt, against the balance of its
+// recipient before and after the block. It returns the slash event, if any.
+// The reward is paid by the slash transaction itself; the leader builds none of
+// its own, as the share of the stake rewarded is up to the staking contract.
+func (w *disputeWatcher) verifyReward(blk *types.Block, staker types.Address, receipt *types.Receipt) *staking.SlashEvent {
+	var event *staking.SlashEvent
+
+	for _, log := range receipt.Logs {
+		if e, ok := staking.ParseSlashEvent(log); ok {
+			event = &e
+			break
+		}
+	}
+
+	if event == nil || event.FeeRecipient == types.ZeroAddress {
+		observeReward(rewardUnpaid)
+		w.logger.Warn("slash paid no reward", "staker_addr", staker, "block_number", blk.Number())
+
+		return event
+	}
+
+	before, after, err := w.balanceChange(event.FeeRecipient, blk.Header)
+	if err != nil {
+		observeReward(rewardUnverified)
+		w.logger.Error("failed to verify the reward of the slash", "staker_addr", staker, "recipient_addr", event.FeeRecipient, "block_number", blk.Number(), "error", err)
+
+		return event
+	}
+
+	logArgs := []interface{}{
+		"staker_addr", staker,
+		"recipient_addr", event.FeeRecipient,
+		"block_number", blk.Number(),
+		"reward", event.SlashedAmount,
+		"balance_before", before,
+		"balance_after", after,
+	}
+
+	if new(big.Int).Sub(after, before).Cmp(event.SlashedAmount) != 0 {
+		observeReward(rewardMismatch)
+		w.logger.Warn("reward of the slash doesn't match the balance change of its recipient", logArgs...)
+
+		return event
+	}
+
+	observeReward(rewardPaid)
+	w.logger.Info("slash reward paid", logArgs...)
+
+	return event
+}
+
+// balanceChange returns the balance of the account before and after the
+// block of the given header.
+func (w *disputeWatcher) balanceChange(addr types.Address, header *types.Header) (*big.Int, *big.Int, error) {
+	parent, ok := w.blockchain.GetHeaderByHash(header.ParentHash)
+	if !ok {
+		return nil, nil, fmt.Errorf("parent %s of block %d not found", header.ParentHash, header.Number)
+	}
+
+	var balances [2]*big.Int
+
+	for i, h := range []*types.Header{parent, header} {
+		txn, err := w.executor.BeginTxn(h.StateRoot, h, addr)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		balances[i] = txn.GetBalance(addr)
+	}
+
+	return balances[0], balances[1], nil
+}
+
+// publishSlash publishes the slash of the staker by the transaction of the
+// given hash in the block, with its slash event if any, to the dispute feed:
+// of the watchtower of an open dispute, or else of a disputed sequencer, its
+// dispute possibly ended before a retry of the slash.
+func (w *disputeWatcher) publishSlash(blk *types.Block, staker types.Address, txHash types.Hash, outcome slashOutcome, event *staking.SlashEvent) {
+	e := DisputeEvent{
+		Type:        SequencerSlashed,
+		Sequencer:   staker,
+		BlockNumber: blk.Number(),
+		BlockHash:   blk.Hash(),
+		TxHash:      txHash,
+		Outcome:     string(outcome),
+	}
+
+	if event != nil {
+		e.SlashedAmount, e.RewardRecipient = event.SlashedAmount, event.FeeRecipient
+	}
+
+	w.lock.Lock()
+
+	for _, d := range w.open {
+		if d.sequencer != staker && d.watchtower != staker {
+			continue
+		}
+
+		if d.watchtower == staker {
+			e.Type = WatchtowerSlashed
+		}
+
+		e.Sequencer, e.Watchtower = d.sequencer, d.watchtower
+		e.AccusedBlockHash = w.proofs[d.watchtower]
+
+		break
+	}
+
+	w.lock.Unlock()
+
+	w.feed.publish(e)
+}
+
+// verifySlash queries the stake of the sequencer slashed in the block of the
+// given header, before and after the block, and whether it's left in the
+// active set.
+func (w *disputeWatcher) verifySlash(sequencer types.Address, header *types.Header) (slashResult, error) {
+	parent, ok := w.blockchain.GetHeaderByHash(header.ParentHash)
+	if !ok {
+		return slashResult{}, fmt.Errorf("parent %s of block %d not found", header.ParentHash, header.Number)
+	}
+
+	before, _, err := staking.QuerySequencerStake(w.blockchain, w.executor, parent, sequencer)
+	if err != nil {
+		return slashResult{}, err
+	}
+
+	after, active, err := staking.QuerySequencerStake(w.blockchain, w.executor, header, sequencer)
+	if err != nil {
+		return slashResult{}, err
+	}
+
+	return slashResult{
+		sequencer:   sequencer,
+		number:      header.Number,
+		stakeBefore: before,
+		stakeAfter:  after,
+		active:      active,
+	}, nil
+}
+
+// slashFailed schedules the retry of the slash of the sequencer failed in the
+// block of the given number, backing off with every failure, or gives the
+// slash up after maxSlashAttempts.
+func (w *disputeWatcher) slashFailed(sequencer types.Address, number uint64, reason string) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	p, ok := w.slashes[sequencer]
+	if !ok {
+		p = &pendingSlash{}
+		w.slashes[sequencer] = p
+	}
+
+	p.attempts++
+	p.slashingAt = 0
+
+	if p.attempts >= maxSlashAttempts {
+		delete(w.slashes, sequencer)
+		observeSlash(slashAbandoned)
+		w.logger.Error("giving up the slash of the disputed sequencer", "sequencer_addr", sequencer, "attempts", p.attempts, "reason", reason)
+
+		return
+	}
+
+	p.retryAt = number + slashRetryBackoff(p.attempts)
+	observeSlash(slashFailed)
+	w.logger.Warn("slash of the disputed sequencer failed; retrying", "sequencer_addr", sequencer, "attempts", p.attempts, "retry_at", p.retryAt, "reason", reason)
+}
+
+// dueSlashes returns the sequencers whose failed slash is due for a retry in
+// the block of the given number, marking them as being slashed in it.
+func (w *disputeWatcher) dueSlashes(number uint64) []types.Address {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	var due []types.Address
+
+	for sequencer, p := range w.slashes {
+		if p.slashingAt != 0 || number < p.retryAt {
+			continue
+		}
+
+		p.slashingAt = number
+		due = append(due, sequencer)
+	}
+
+	return due
+}
+
+// slashTx returns the signed transaction of the leader retrying the slash of
+// the sequencer.
+func (w *disputeWatcher) slashTx(sequencer, from types.Address, nonce uint64, signKey *ecdsa.PrivateKey) (*types.Transaction, error) {
+	slash, err := staking.SlashStakerTx(from, sequencer, disputeTxGasLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	txs, err := signDisputeTxs([]*types.Transaction{slash}, nonce, signKey)
+	if err != nil {
+		return nil, err
+	}
+
+	w.logger.Info("retrying the slash of the disputed sequencer", "sequencer_addr", sequencer)
+
+	return txs[0], nil
+}
@@ -0,0 +1,242 @@
+package avail
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/consensus/avail/watchtower"
+	"github.com/availproject/op-evm/pkg/avail"
+	"github.com/availproject/op-evm/pkg/avail/testutil"
+	"github.com/availproject/op-evm/pkg/block"
+	"github.com/availproject/op-evm/pkg/common"
+	"github.com/availproject/op-evm/pkg/staking"
+	"github.com/availproject/op-evm/pkg/test"
+	avail_types "github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlashRetryBackoff(t *testing.T) {
+	for attempts, backoff := range map[uint64]uint64{1: 1, 2: 2, 3: 4, 4: 8, 5: 16, 6: 16, 40: 16} {
+		assert.Equal(t, backoff, slashRetryBackoff(attempts), "attempts %d", attempts)
+	}
+}
+
+func TestDisputeWatcherRetriesFailedSlashes(t *testing.T) {
+	w := newDisputeWatcher(nil, nil, 3, nil, hclog.NewNullLogger())
+
+	sequencer, _ := test.NewAccount(t)
+
+	slash, err := staking.SlashStakerTx(types.ZeroAddress, sequencer, disputeTxGasLimit)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	failed := types.ReceiptFailed
+
+	// observeBlock observes the block of the given number, with a failing
+	// slash of the sequencer if slashed.
+	observeBlock := func(number uint64, slashed bool) {
+		blk := &types.Block{Header: &types.Header{Number: number}}
+
+		var receipts []*types.Receipt
+		if slashed {
+			blk.Transactions = []*types.Transaction{slash}
+			receipts = []*types.Receipt{{Status: &failed}}
+		}
+
+		w.observe(blk, receipts)
+	}
+
+	observeBlock(10, true)
+
+	// The first retry follows right away, in the next block.
+	assert.Empty(t, w.dueSlashes(10))
+	assert.Equal(t, []types.Address{sequencer}, w.dueSlashes(11))
+	assert.Empty(t, w.dueSlashes(11), "retry in flight")
+
+	// The block of the retry didn't make it to the chain; the next leader
+	// retries.
+	observeBlock(11, false)
+	assert.Equal(t, []types.Address{sequencer}, w.dueSlashes(12))
+
+	// Every failure doubles the backoff.
+	observeBlock(12, true)
+	assert.Empty(t, w.dueSlashes(13))
+	assert.Equal(t, []types.Address{sequencer}, w.dueSlashes(14))
+
+	observeBlock(14, true)
+	assert.Empty(t, w.dueSlashes(17))
+	assert.Equal(t, []types.Address{sequencer}, w.dueSlashes(18))
+
+	observeBlock(18, true)
+	assert.Equal(t, []types.Address{sequencer}, w.dueSlashes(26))
+
+	// The slash is given up after failing maxSlashAttempts times.
+	observeBlock(26, true)
+	assert.Empty(t, w.dueSlashes(1000))
+	assert.Empty(t, w.slashes)
+}
+
+func TestDisputeWatcherSlashesFraudulentSequencer(t *testing.T) {
+	const window = 3
+
+	d, apq := NewTestAvail(t, Sequencer)
+	sw, fraudResolver, _ := newTestSequencerWorkerOf(t, d, testutil.NewFake(avail_types.NewUCompactFromUInt(1)))
+
+	sw.disputeWatcher = newDisputeWatcher(sw.blockchain, sw.executor, window, nil, hclog.Default())
+	sw.blockchain.RegisterPostCommitHook(sw.disputeWatcher.observe)
+
+	sender := staking.NewTestAvailSender()
+	stake := big.NewInt(0).Mul(big.NewInt(10), common.ETH)
+
+	// The local sequencer, the malicious one and the watchtower stake.
+	malicious, maliciousKey := test.NewAccount(t)
+	watchtowerAddr, watchtowerKey := test.NewAccount(t)
+
+	for _, addr := range []types.Address{malicious, watchtowerAddr} {
+		test.DepositBalance(t, addr, big.NewInt(0).Mul(big.NewInt(1000), common.ETH), sw.blockchain, sw.executor)
+	}
+
+	if err := staking.Stake(sw.blockchain, sw.executor, sender, hclog.Default(), string(staking.Sequencer), sw.nodeAddr, sw.nodeSignKey, stake, 1_000_000, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := staking.Stake(sw.blockchain, sw.executor, sender, hclog.Default(), string(staking.Sequencer), malicious, maliciousKey, stake, 1_000_000, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := staking.Stake(sw.blockchain, sw.executor, sender, hclog.Default(), string(staking.WatchTower), watchtowerAddr, watchtowerKey, stake, 1_000_000, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The malicious sequencer produces its block, found fraudulent by the
+	// check of the watchtower.
+	bb, err := block.NewBlockBuilderFactory(sw.blockchain, sw.executor, sw.logger).FromBlockchainHead()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bb.SetCoinbaseAddress(malicious)
+	bb.SignWith(maliciousKey)
+
+	maliciousBlk, err := bb.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sw.applyAvailBlock(avail.EdgeBlock{Block: maliciousBlk}, blockInclusion{availBlock: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	fraudResolver.watchtower = testFraudulentBlocks{}
+
+	// The watchtower raises its fraud proof, seen on Avail, and disputes the
+	// malicious sequencer.
+	fraudProof, err := watchtower.New(sw.blockchain, sw.executor, nil, hclog.Default(), watchtowerAddr, watchtowerKey, 0).ConstructFraudproof(maliciousBlk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sw.disputeWatcher.observeFraudProofs([]avail.EdgeBlock{{Block: fraudProof}})
+
+	if err := staking.NewDisputeResolution(sw.blockchain, sw.executor, sender, hclog.Default()).Begin(malicious, watchtowerKey); err != nil {
+		t.Fatal(err)
+	}
+
+	began := sw.blockchain.Header().Number
+
+	assert.Eventually(t, func() bool {
+		sw.disputeWatcher.lock.Lock()
+		defer sw.disputeWatcher.lock.Unlock()
+
+		_, ok := sw.disputeWatcher.open[malicious]
+		return ok
+	}, 5*time.Second, 10*time.Millisecond)
+
+	stakeBefore, err := apq.GetBalance(malicious)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	balanceBefore, err := d.GetAccountBalance(watchtowerAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clock, stop := startWriteBlocksLoop(t, sw, fraudResolver)
+	defer stop()
+
+	// The leader past the challenge window upholds the fraud and slashes.
+	for number := began + 1; number <= began+window+1; number++ {
+		clock.tick()
+		waitForBlock(t, sw, number)
+	}
+
+	var slashBlk *types.Block
+
+	for number := began + 1; number <= sw.blockchain.Header().Number; number++ {
+		blk, _ := sw.blockchain.GetBlockByNumber(number, true)
+
+		for _, tx := range blk.Transactions {
+			if slashed, ok := staking.SlashedStaker(tx); ok && slashed == malicious {
+				assert.Nil(t, slashBlk, "slashed twice")
+				slashBlk = blk
+			}
+		}
+	}
+
+	if !assert.NotNil(t, slashBlk) {
+		return
+	}
+
+	stakeAfter, err := apq.GetBalance(malicious)
+	assert.NoError(t, err)
+	assert.Equal(t, -1, stakeAfter.Cmp(stakeBefore))
+
+	// The slash verifies against the state of the staking contract, and
+	// isn't retried.
+	result, err := sw.disputeWatcher.verifySlash(malicious, slashBlk.Header)
+	if assert.NoError(t, err) {
+		assert.Equal(t, stakeBefore, result.stakeBefore)
+		assert.Equal(t, stakeAfter, result.stakeAfter)
+		assert.NotEqual(t, slashFailed, result.outcome())
+	}
+
+	// The watchtower is rewarded with the stake slashed, the default slash
+	// percentage of the staking contract.
+	reward := big.NewInt(0).Div(stakeBefore, big.NewInt(100))
+	assert.Equal(t, reward, big.NewInt(0).Sub(stakeBefore, stakeAfter))
+
+	balanceAfter, err := d.GetAccountBalance(watchtowerAddr)
+	assert.NoError(t, err)
+	assert.Equal(t, reward, big.NewInt(0).Sub(balanceAfter, balanceBefore))
+
+	receipts, err := sw.blockchain.GetReceiptsByHash(slashBlk.Hash())
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	for i, tx := range slashBlk.Transactions {
+		if _, ok := staking.SlashedStaker(tx); !ok {
+			continue
+		}
+
+		event := sw.disputeWatcher.verifyReward(slashBlk, malicious, receipts[i])
+		if assert.NotNil(t, event) {
+			assert.Equal(t, watchtowerAddr, event.FeeRecipient)
+			assert.Equal(t, reward, event.SlashedAmount)
+		}
+	}
+
+	assert.Eventually(t, func() bool {
+		sw.disputeWatcher.lock.Lock()
+		defer sw.disputeWatcher.lock.Unlock()
+
+		return sw.disputeWatcher.observed >= slashBlk.Number()
+	}, 5*time.Second, 10*time.Millisecond)
+
+	assert.Empty(t, sw.disputeWatcher.dueSlashes(sw.blockchain.Header().Number+maxSlashRetryBackoff))
+}
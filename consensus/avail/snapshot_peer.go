@@ -0,0 +1,164 @@
+package avail
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/pkg/avail"
+	"github.com/availproject/op-evm/pkg/snapshot"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	// snapshotChunkTimeout is the deadline of a single fetch of a snapshot
+	// chunk from the snapshot peer.
+	snapshotChunkTimeout = time.Minute
+
+	// snapshotChunkRetries is the number of the times a snapshot chunk
+	// failing its hash, or failing to be fetched, is fetched again.
+	snapshotChunkRetries = 3
+)
+
+// SnapshotConfig configures the chain snapshots distributed between the
+// nodes: a node may serve the snapshots at its settled head in chunks, and a
+// new one bootstrap from the latest snapshot of a trusted peer on the start,
+// before replaying Avail on from it.
+type SnapshotConfig struct {
+	// Serve serves the snapshots over `avail_getSnapshots` and
+	// `avail_getSnapshotChunk`.
+	Serve bool
+
+	// ServeState serves the raw trie nodes and code of the state over
+	// `avail_getStateNode` and `avail_getStateCode`, for the watchtowers
+	// under the light sync to fetch the state from.
+	ServeState bool
+
+	// Peer is the JSON-RPC URL of the 'avail' namespace of the peer to
+	// bootstrap from; empty disables the bootstrapping.
+	Peer string
+}
+
+// snapshotPeer is the client of the trusted peer serving the chain snapshots
+// over `avail_getSnapshots` and `avail_getSnapshotChunk`. The peer is trusted
+// to serve the snapshots, not their content: the chunks are checked against
+// the manifest, and the snapshot assembled against the chain and Avail on
+// the import.
+type snapshotPeer struct {
+	client *rpc.Client
+}
+
+// dialSnapshotPeer returns the snapshotPeer of the given JSON-RPC URL.
+func dialSnapshotPeer(url string) (*snapshotPeer, error) {
+	client, err := rpc.Dial(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial the snapshot peer %q: %w", url, err)
+	}
+
+	return &snapshotPeer{client: client}, nil
+}
+
+// snapshots returns the manifests of the snapshots the peer serves, the
+// latest first.
+func (p *snapshotPeer) snapshots(ctx context.Context) ([]*SnapshotManifest, error) {
+	var manifests []*SnapshotManifest
+	if err := p.client.CallContext(ctx, &manifests, avail.SettlementNamespace+"_getSnapshots"); err != nil {
+		return nil, err
+	}
+
+	return manifests, nil
+}
+
+// chunk fetches the chunk of the given index of the snapshot at the block of
+// the given hash.
+func (p *snapshotPeer) chunk(ctx context.Context, hash types.Hash, index int) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, snapshotChunkTimeout)
+	defer cancel()
+
+	var chunk []byte
+	if err := p.client.CallContext(ctx, &chunk, avail.SettlementNamespace+"_getSnapshotChunk", hash, index); err != nil {
+		return nil, err
+	}
+
+	return chunk, nil
+}
+
+// close closes the client of the peer.
+func (p *snapshotPeer) close() {
+	p.client.Close()
+}
+
+// bootstrapFromPeer has the node start from the latest chain snapshot the
+// peer serves, downloaded to the snapshots directory, unless the node is at
+// or past it already. The download resumes from the one left by the previous
+// run. It reports whether the node was bootstrapped, and the head of the
+// snapshot.
+func (d *Avail) bootstrapFromPeer(ctx context.Context, peer *snapshotPeer, dir string) (SettledHead, bool, error) {
+	manifests, err := peer.snapshots(ctx)
+	if err != nil {
+		return SettledHead{}, false, fmt.Errorf("failed to list the snapshots of the peer: %w", err)
+	}
+
+	if len(manifests) == 0 || manifests[0].Head.Number <= d.blockchain.Header().Number {
+		return SettledHead{}, false, nil
+	}
+
+	m := manifests[0]
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return SettledHead{}, false, err
+	}
+
+	path := filepath.Join(dir, snapshotName(m.Head)+".download")
+
+	d.logger.Info("downloading chain snapshot from the peer", "block_number", m.Head.Number, "block_hash", m.Head.Hash, "size", m.Size, "chunks", m.Chunks())
+
+	fetched, err := snapshot.Download(ctx, path, &m.Manifest, func(ctx context.Context, index int) ([]byte, error) {
+		return peer.chunk(ctx, m.Head.Hash, index)
+	}, snapshotChunkRetries)
+	if err != nil {
+		return SettledHead{}, false, fmt.Errorf("failed to download the snapshot at block %d: %w", m.Head.Number, err)
+	}
+
+	d.logger.Info("chain snapshot downloaded", "block_number", m.Head.Number, "chunks_fetched", fetched, "chunks_resumed", m.Chunks()-fetched)
+
+	f, err := os.Open(path)
+	if err != nil {
+		return SettledHead{}, false, err
+	}
+
+	head, err := d.importChainSnapshot(f)
+	f.Close()
+
+	if err != nil {
+		// The snapshot assembled is no good; it's fetched anew next time.
+		os.Remove(path)
+		return SettledHead{}, false, err
+	}
+
+	if head != m.Head {
+		d.logger.Warn("chain snapshot head differs from its manifest", "manifest_block_hash", m.Head.Hash, "block_hash", head.Hash)
+	}
+
+	return head, true, os.Remove(path)
+}
+
+// bootstrapFromSnapshotPeer has the node start from the latest chain snapshot
+// of the snapshot peer, if any. The node failing to bootstrap from it
+// replays Avail from its head instead.
+func (d *Avail) bootstrapFromSnapshotPeer() {
+	if d.snapshotPeer == nil {
+		return
+	}
+
+	head, ok, err := d.bootstrapFromPeer(d.ctx, d.snapshotPeer, d.snapshotsDir)
+	switch {
+	case err != nil:
+		d.logger.Error("failed to bootstrap from the snapshot peer; replaying Avail instead", "error", err)
+	case ok:
+		d.logger.Info("bootstrapped from the snapshot peer", "block_number", head.Number, "block_hash", head.Hash, "avail_block_number", head.AvailBlock)
+	}
+}
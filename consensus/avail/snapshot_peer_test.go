@@ -0,0 +1,239 @@
+package avail
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/pkg/avail"
+	"github.com/availproject/op-evm/pkg/avail/testutil"
+	"github.com/availproject/op-evm/pkg/snapshot"
+	avail_types "github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+// flakySnapshotAPI is the status API serving corrupt snapshot chunks: the
+// first fetch of the chunks of corruptOnce, and every fetch of the chunk of
+// corrupt, if set.
+type flakySnapshotAPI struct {
+	*StatusAPI
+
+	lock        sync.Mutex
+	fetches     map[int]int
+	corruptOnce map[int]bool
+	corrupt     int
+}
+
+func (api *flakySnapshotAPI) GetSnapshotChunk(hash types.Hash, index int) ([]byte, error) {
+	chunk, err := api.StatusAPI.GetSnapshotChunk(hash, index)
+	if err != nil {
+		return nil, err
+	}
+
+	api.lock.Lock()
+	defer api.lock.Unlock()
+
+	api.fetches[index]++
+
+	if index == api.corrupt || (api.corruptOnce[index] && api.fetches[index] == 1) {
+		chunk[0] ^= 0xff
+	}
+
+	return chunk, nil
+}
+
+// servedChunks returns the number of the chunks fetched from the API, and
+// resets it.
+func (api *flakySnapshotAPI) servedChunks() int {
+	api.lock.Lock()
+	defer api.lock.Unlock()
+
+	n := 0
+	for _, fetches := range api.fetches {
+		n += fetches
+	}
+
+	api.fetches = make(map[int]int)
+
+	return n
+}
+
+func TestSnapshotPeerBootstrap(t *testing.T) {
+	const window = 2
+
+	appID := avail_types.NewUCompactFromUInt(1)
+	fake := testutil.NewFake(appID)
+
+	producer := newTestGenesisAvail(t)
+	for i := 0; i < 4; i++ {
+		settleTestBlock(t, producer, fake, buildTestBlock(t, producer))
+	}
+
+	// The source node follows Avail, and serves the snapshots at its
+	// settled head in small chunks.
+	source := newTestSnapshotAvail(t, fake, appID, window)
+	followTestAvail(t, source, fake)
+
+	store, err := newSnapshotStore(t.TempDir(), 256, DefaultSnapshotRetention, systemClock{}, hclog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	source.snapshots = store
+	source.serveSnapshots = true
+
+	api := &flakySnapshotAPI{StatusAPI: NewStatusAPI(source), fetches: make(map[int]int), corruptOnce: map[int]bool{1: true}, corrupt: 3}
+
+	srv := rpc.NewServer()
+	if err := srv.RegisterName(avail.SettlementNamespace, api); err != nil {
+		t.Fatal(err)
+	}
+
+	defer srv.Stop()
+
+	peer := &snapshotPeer{client: rpc.DialInProc(srv)}
+	defer peer.close()
+
+	manifests, err := peer.snapshots(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	settled := source.forkChoice.settled.Head()
+	if assert.Len(t, manifests, 1) {
+		assert.Equal(t, settled, manifests[0].Head)
+		assert.Greater(t, manifests[0].Chunks(), 4)
+	}
+
+	chunks := manifests[0].Chunks()
+
+	// The chain goes on past the snapshot.
+	for i := 0; i < 2; i++ {
+		settleTestBlock(t, producer, fake, buildTestBlock(t, producer))
+	}
+
+	// The new node fails to download the chunk served corrupt on every
+	// fetch, past the one corrupt on the first fetch only.
+	target := newTestSnapshotAvail(t, fake, appID, window)
+	dir := t.TempDir()
+
+	_, ok, err := target.bootstrapFromPeer(context.Background(), peer, dir)
+	assert.False(t, ok)
+	assert.True(t, errors.Is(err, snapshot.ErrChunkCorrupt), "unexpected error: %v", err)
+	assert.Zero(t, target.blockchain.Header().Number)
+	assert.Equal(t, 3+1+snapshotChunkRetries+1, api.servedChunks())
+
+	// The download resumes past the chunks fetched, and the node boots from
+	// the snapshot.
+	api.corrupt = -1
+
+	head, ok, err := target.bootstrapFromPeer(context.Background(), peer, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.True(t, ok)
+	assert.Equal(t, settled, head)
+	assert.Equal(t, chunks-3, api.servedChunks())
+	assert.Equal(t, settled.Hash, target.blockchain.Header().Hash)
+	assert.Equal(t, settled, target.forkChoice.settled.Head())
+
+	downloads, _ := filepath.Glob(filepath.Join(dir, "*.download"))
+	assert.Empty(t, downloads)
+
+	// The node at the snapshot has nothing to bootstrap from.
+	_, ok, err = target.bootstrapFromPeer(context.Background(), peer, dir)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	// The blocks past the snapshot execute on its state.
+	if _, err := syncTestAvail(target, fake); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, producer.blockchain.Header().Hash, target.blockchain.Header().Hash)
+	assert.Equal(t, producer.blockchain.Header().StateRoot, target.blockchain.Header().StateRoot)
+}
+
+func TestSnapshotServing(t *testing.T) {
+	const window = 2
+
+	appID := avail_types.NewUCompactFromUInt(1)
+	fake := testutil.NewFake(appID)
+
+	producer := newTestGenesisAvail(t)
+	for i := 0; i < 4; i++ {
+		settleTestBlock(t, producer, fake, buildTestBlock(t, producer))
+	}
+
+	source := newTestSnapshotAvail(t, fake, appID, window)
+
+	// The node not serving the snapshots refuses to list them.
+	_, err := NewStatusAPI(source).GetSnapshots()
+	assert.ErrorIs(t, err, errNoSnapshotServing)
+
+	dir := t.TempDir()
+
+	store, err := newSnapshotStore(dir, snapshot.DefaultChunkSize, DefaultSnapshotRetention, systemClock{}, hclog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	source.snapshots = store
+	source.serveSnapshots = true
+
+	// No snapshot is taken short of a settled block.
+	manifests, err := NewStatusAPI(source).GetSnapshots()
+	assert.NoError(t, err)
+	assert.Empty(t, manifests)
+
+	// A snapshot is taken as the settled head moves, the latest kept.
+	var heads []SettledHead
+
+	for i := 0; i < DefaultSnapshotRetention+1; i++ {
+		settleTestBlock(t, producer, fake, buildTestBlock(t, producer))
+		followTestAvail(t, source, fake)
+
+		manifests, err := NewStatusAPI(source).GetSnapshots()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		heads = append(heads, source.forkChoice.settled.Head())
+		assert.Equal(t, heads[len(heads)-1], manifests[0].Head)
+	}
+
+	manifests, err = NewStatusAPI(source).GetSnapshots()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if assert.Len(t, manifests, DefaultSnapshotRetention) {
+		assert.Equal(t, heads[2], manifests[0].Head)
+		assert.Equal(t, heads[1], manifests[1].Head)
+	}
+
+	_, err = NewStatusAPI(source).GetSnapshotChunk(heads[0].Hash, 0)
+	assert.Error(t, err)
+
+	// The snapshots served are kept across restarts.
+	reloaded, err := newSnapshotStore(dir, snapshot.DefaultChunkSize, DefaultSnapshotRetention, systemClock{}, hclog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	source.snapshots = reloaded
+
+	chunk, err := NewStatusAPI(source).GetSnapshotChunk(heads[2].Hash, 0)
+	if assert.NoError(t, err) {
+		assert.NoError(t, manifests[0].CheckChunk(0, chunk))
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*"))
+	assert.Len(t, files, 2*DefaultSnapshotRetention)
+}
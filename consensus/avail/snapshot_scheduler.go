@@ -0,0 +1,112 @@
+package avail
+
+import (
+	"sync"
+
+	"github.com/hashicorp/go-hclog"
+)
+
+// snapshotScheduler takes the chain snapshots on a schedule, at every settled
+// block whose number is a multiple of the interval, in the background of the
+// chain: the blocks settled are observed under the lock of the settled head,
+// and the snapshot is taken short of it. The blocks coming due while a
+// snapshot is taken are coalesced to the latest of them.
+type snapshotScheduler struct {
+	interval uint64
+	take     func(SettledHead) (*SnapshotManifest, error)
+	logger   hclog.Logger
+
+	lock    sync.Mutex
+	running *SettledHead // The block the snapshot in flight is taken at; nil for none
+	pending *SettledHead // The block the next snapshot is due at; nil for none
+	done    sync.WaitGroup
+}
+
+func newSnapshotScheduler(interval uint64, take func(SettledHead) (*SnapshotManifest, error), logger hclog.Logger) *snapshotScheduler {
+	return &snapshotScheduler{
+		interval: interval,
+		take:     take,
+		logger:   logger,
+	}
+}
+
+// observe starts the snapshot at the settled block in the background, if due
+// at it.
+func (s *snapshotScheduler) observe(head SettledHead) {
+	if s == nil || head.Number%s.interval != 0 {
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.running != nil {
+		s.pending = &head
+		return
+	}
+
+	s.running = &head
+	s.done.Add(1)
+
+	go s.run(head)
+}
+
+// run takes the snapshot at the given block, and the ones coming due
+// meanwhile.
+func (s *snapshotScheduler) run(head SettledHead) {
+	defer s.done.Done()
+
+	for {
+		m, err := s.take(head)
+		if err != nil {
+			s.logger.Error("failed to take the scheduled chain snapshot", "block_number", head.Number, "block_hash", head.Hash, "error", err)
+		}
+
+		observeScheduledSnapshot(head.Number, err == nil && m != nil)
+
+		s.lock.Lock()
+
+		if s.pending == nil {
+			s.running = nil
+			s.lock.Unlock()
+
+			return
+		}
+
+		head = *s.pending
+		s.pending = nil
+		s.running = &head
+
+		s.lock.Unlock()
+	}
+}
+
+// heads returns the blocks the snapshots in flight and due are taken at, for
+// their state to be kept by the pruning.
+func (s *snapshotScheduler) heads() []SettledHead {
+	if s == nil {
+		return nil
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var heads []SettledHead
+
+	for _, head := range []*SettledHead{s.running, s.pending} {
+		if head != nil {
+			heads = append(heads, *head)
+		}
+	}
+
+	return heads
+}
+
+// wait waits for the snapshots in flight and due, if any.
+func (s *snapshotScheduler) wait() {
+	if s == nil {
+		return
+	}
+
+	s.done.Wait()
+}
@@ -0,0 +1,155 @@
+package avail
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/availproject/op-evm/pkg/avail/testutil"
+	"github.com/availproject/op-evm/pkg/snapshot"
+	avail_types "github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshotScheduler(t *testing.T) {
+	const (
+		window    = 2
+		interval  = 10
+		retention = 2
+	)
+
+	appID := avail_types.NewUCompactFromUInt(1)
+	fake := testutil.NewFake(appID)
+
+	producer := newTestGenesisAvail(t)
+
+	now := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	dir := t.TempDir()
+
+	store, err := newSnapshotStore(dir, snapshot.DefaultChunkSize, retention, newFakeClock(now), hclog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := newTestSnapshotAvail(t, fake, appID, window)
+	d.snapshots = store
+	d.serveSnapshots = true
+	d.snapshotScheduler = newSnapshotScheduler(interval, d.takeChainSnapshot, hclog.Default())
+	d.forkChoice.settled.onSettle(d.snapshotScheduler.observe)
+
+	// The node follows Avail as the chain goes on, each snapshot taken by
+	// the time the next one is due.
+	for i := 0; i < 35; i++ {
+		settleTestBlock(t, producer, fake, buildTestBlock(t, producer))
+
+		if i%interval == interval-1 || i == 34 {
+			followTestAvail(t, d, fake)
+			d.snapshotScheduler.wait()
+		}
+	}
+
+	settled := d.forkChoice.settled.Head()
+	assert.GreaterOrEqual(t, settled.Number, uint64(3*interval))
+	assert.Less(t, settled.Number, uint64(4*interval))
+
+	// The snapshots are taken at the multiples of the interval, the latest
+	// kept, and listed short of one taken at the settled head.
+	manifests, err := NewStatusAPI(d).GetSnapshots()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !assert.Len(t, manifests, retention) {
+		return
+	}
+
+	for i, number := range []uint64{3 * interval, 2 * interval} {
+		m := manifests[i]
+		h, _ := d.blockchain.GetHeaderByNumber(number)
+
+		assert.Equal(t, number, m.Head.Number)
+		assert.Equal(t, h.Hash, m.Head.Hash)
+		assert.Equal(t, h.StateRoot, m.StateRoot)
+		assert.Equal(t, now, m.CreatedAt)
+
+		// The manifest is the one of the snapshot file.
+		f, err := os.Open(filepath.Join(dir, snapshotName(m.Head)+".snapshot"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		fm, err := snapshot.NewManifest(f, m.ChunkSize)
+		f.Close()
+
+		if assert.NoError(t, err) {
+			assert.Equal(t, *fm, m.Manifest)
+		}
+
+		// The manifest on disk is the one listed.
+		onDisk, err := readSnapshotManifest(filepath.Join(dir, snapshotName(m.Head)+".json"))
+		if assert.NoError(t, err) {
+			assert.Equal(t, m.Head, onDisk.Head)
+			assert.Equal(t, m.StateRoot, onDisk.StateRoot)
+			assert.True(t, m.CreatedAt.Equal(onDisk.CreatedAt))
+		}
+	}
+
+	// The snapshot past the retention is removed, along with its manifest.
+	files, _ := filepath.Glob(filepath.Join(dir, "*"))
+	assert.Len(t, files, 2*retention)
+
+	// The snapshots are kept across restarts, the incomplete ones removed.
+	incomplete := filepath.Join(dir, "40-0x00.snapshot")
+	if err := os.WriteFile(incomplete, []byte("incomplete"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := newSnapshotStore(dir, snapshot.DefaultChunkSize, retention, systemClock{}, hclog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, manifests, reloaded.list())
+	assert.NoFileExists(t, incomplete)
+}
+
+func TestSnapshotSchedulerCoalesces(t *testing.T) {
+	var (
+		taken   []uint64
+		started = make(chan struct{})
+		release = make(chan struct{})
+	)
+
+	s := newSnapshotScheduler(10, func(head SettledHead) (*SnapshotManifest, error) {
+		if len(taken) == 0 {
+			close(started)
+			<-release
+		}
+
+		taken = append(taken, head.Number)
+
+		return &SnapshotManifest{Head: head}, nil
+	}, hclog.Default())
+
+	for number := uint64(1); number <= 10; number++ {
+		s.observe(SettledHead{Number: number})
+	}
+
+	<-started
+
+	// The blocks coming due while the snapshot is taken are coalesced to the
+	// latest, their state kept meanwhile.
+	for number := uint64(11); number <= 30; number++ {
+		s.observe(SettledHead{Number: number})
+	}
+
+	assert.Equal(t, []SettledHead{{Number: 10}, {Number: 30}}, s.heads())
+
+	close(release)
+	s.wait()
+
+	assert.Equal(t, []uint64{10, 30}, taken)
+	assert.Empty(t, s.heads())
+}
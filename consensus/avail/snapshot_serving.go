@@ -0,0 +1,306 @@
+package avail
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/pkg/snapshot"
+	"github.com/hashicorp/go-hclog"
+)
+
+// SnapshotsDirName is the name of the directory in the data directory the
+// chain snapshots served and scheduled are kept in.
+const SnapshotsDirName = "snapshots"
+
+// errNoSnapshotServing is returned by the status API of the node not serving
+// the chain snapshots.
+var errNoSnapshotServing = errors.New("snapshot serving not enabled")
+
+// errNoStateServing is returned by the status API of the node not serving
+// the raw state.
+var errNoStateServing = errors.New("state serving not enabled")
+
+// SnapshotManifest describes a chain snapshot kept by the node, as listed by
+// `avail_getSnapshots`: the settled block it's taken at and its state root,
+// when it was taken, and the manifest of its chunks, fetched with
+// `avail_getSnapshotChunk`.
+type SnapshotManifest struct {
+	Head      SettledHead `json:"head"`
+	StateRoot types.Hash  `json:"stateRoot"`
+	CreatedAt time.Time   `json:"createdAt"`
+	snapshot.Manifest
+}
+
+// snapshotStore keeps the chain snapshots of the node, in files of the
+// snapshots directory, each along with its manifest, written once the
+// snapshot is complete. The latest retention snapshots are kept, the older
+// ones removed.
+type snapshotStore struct {
+	dir       string
+	chunkSize uint64
+	retention int
+	clock     clock
+	logger    hclog.Logger
+
+	lock      sync.Mutex
+	manifests []*SnapshotManifest // The oldest first
+}
+
+// newSnapshotStore returns the snapshotStore of the given directory, loading
+// the snapshots left by the previous run; the ones it left incomplete are
+// removed.
+func newSnapshotStore(dir string, chunkSize uint64, retention int, clock clock, logger hclog.Logger) (*snapshotStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	s := &snapshotStore{dir: dir, chunkSize: chunkSize, retention: retention, clock: clock, logger: logger}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	held := make(map[string]bool)
+
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), ".json")
+
+		m, err := readSnapshotManifest(path)
+		if err != nil {
+			logger.Warn("dropping unreadable snapshot manifest", "path", path, "error", err)
+			s.remove(name)
+
+			continue
+		}
+
+		held[name] = true
+		s.manifests = append(s.manifests, m)
+	}
+
+	// The snapshots without a manifest, and the files written short of
+	// their rename, were left incomplete.
+	snapshots, err := filepath.Glob(filepath.Join(dir, "*.snapshot*"))
+	if err != nil {
+		return nil, err
+	}
+
+	manifests, err := filepath.Glob(filepath.Join(dir, "*.json.*"))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, path := range append(snapshots, manifests...) {
+		if !held[strings.TrimSuffix(filepath.Base(path), ".snapshot")] {
+			logger.Warn("removing incomplete snapshot", "path", path)
+			os.Remove(path)
+		}
+	}
+
+	sort.Slice(s.manifests, func(i, j int) bool { return s.manifests[i].Head.Number < s.manifests[j].Head.Number })
+
+	s.pruneLocked()
+
+	return s, nil
+}
+
+// readSnapshotManifest reads the snapshot manifest of the given path.
+func readSnapshotManifest(path string) (*SnapshotManifest, error) {
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	m := new(SnapshotManifest)
+	if err := json.Unmarshal(bs, m); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// snapshotName returns the name of the files of the snapshot at the given
+// head.
+func snapshotName(head SettledHead) string {
+	return fmt.Sprintf("%d-%s", head.Number, head.Hash)
+}
+
+// list returns the manifests of the snapshots kept, the latest first.
+func (s *snapshotStore) list() []*SnapshotManifest {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	manifests := make([]*SnapshotManifest, len(s.manifests))
+	for i, m := range s.manifests {
+		manifests[len(manifests)-1-i] = m
+	}
+
+	return manifests
+}
+
+// latest returns the number of the latest snapshot kept; zero without any.
+func (s *snapshotStore) latest() uint64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if len(s.manifests) == 0 {
+		return 0
+	}
+
+	return s.manifests[len(s.manifests)-1].Head.Number
+}
+
+// take takes the snapshot at the given settled block of the given state root
+// with the given export, unless it's kept already, and drops the ones past
+// the retention. The snapshot is written to its file first, and its manifest
+// next, each replacing the file once complete; the export runs short of the
+// lock, so the snapshots kept are served meanwhile.
+func (s *snapshotStore) take(head SettledHead, stateRoot types.Hash, export func(w io.Writer, head SettledHead) (SettledHead, error)) (*SnapshotManifest, error) {
+	if m := s.manifest(head.Hash); m != nil {
+		return m, nil
+	}
+
+	name := snapshotName(head)
+	path := filepath.Join(s.dir, name+".snapshot")
+
+	if _, err := exportToFile(path, func(w io.Writer) (SettledHead, error) { return export(w, head) }); err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest, err := snapshot.NewManifest(f, s.chunkSize)
+	f.Close()
+
+	if err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+
+	m := &SnapshotManifest{Head: head, StateRoot: stateRoot, CreatedAt: s.clock.Now().UTC(), Manifest: *manifest}
+
+	bs, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := exportToFile(filepath.Join(s.dir, name+".json"), func(w io.Writer) (SettledHead, error) {
+		_, err := w.Write(bs)
+		return head, err
+	}); err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.manifests = append(s.manifests, m)
+	sort.Slice(s.manifests, func(i, j int) bool { return s.manifests[i].Head.Number < s.manifests[j].Head.Number })
+	s.pruneLocked()
+
+	s.logger.Info("chain snapshot taken", "block_number", head.Number, "block_hash", head.Hash, "state_root", stateRoot, "size", m.Size, "chunks", m.Chunks())
+
+	return m, nil
+}
+
+// pruneLocked removes the snapshots past the retention. The lock is held.
+func (s *snapshotStore) pruneLocked() {
+	for s.retention > 0 && len(s.manifests) > s.retention {
+		s.remove(snapshotName(s.manifests[0].Head))
+		s.manifests = s.manifests[1:]
+	}
+}
+
+// remove removes the files of the snapshot of the given name.
+func (s *snapshotStore) remove(name string) {
+	for _, ext := range []string{".json", ".snapshot"} {
+		if err := os.Remove(filepath.Join(s.dir, name+ext)); err != nil && !os.IsNotExist(err) {
+			s.logger.Warn("failed to remove snapshot file", "path", filepath.Join(s.dir, name+ext), "error", err)
+		}
+	}
+}
+
+// manifest returns the manifest of the snapshot kept at the block of the
+// given hash, if any.
+func (s *snapshotStore) manifest(hash types.Hash) *SnapshotManifest {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, m := range s.manifests {
+		if m.Head.Hash == hash {
+			return m
+		}
+	}
+
+	return nil
+}
+
+// chunk returns the chunk of the given index of the snapshot at the block of
+// the given hash.
+func (s *snapshotStore) chunk(hash types.Hash, index int) ([]byte, error) {
+	m := s.manifest(hash)
+	if m == nil {
+		return nil, fmt.Errorf("no snapshot served at block %s", hash)
+	}
+
+	f, err := os.Open(filepath.Join(s.dir, snapshotName(m.Head)+".snapshot"))
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	return m.ReadChunk(f, index)
+}
+
+// takeChainSnapshot takes the chain snapshot at the given settled block into
+// the snapshot store.
+func (d *Avail) takeChainSnapshot(head SettledHead) (*SnapshotManifest, error) {
+	h, ok := d.blockchain.GetHeaderByHash(head.Hash)
+	if !ok {
+		return nil, fmt.Errorf("settled block %s not found", head.Hash)
+	}
+
+	return d.snapshots.take(head, h.StateRoot, d.exportChainSnapshotAt)
+}
+
+// servedSnapshots returns the manifests of the chain snapshots the node
+// serves, the latest first. Short of the snapshot scheduler, a snapshot is
+// taken at the settled head first if it moved past the latest one.
+func (d *Avail) servedSnapshots() ([]*SnapshotManifest, error) {
+	if d.snapshots == nil || !d.serveSnapshots {
+		return nil, errNoSnapshotServing
+	}
+
+	if head := d.forkChoice.settled.Head(); d.snapshotScheduler == nil && head.Number > d.snapshots.latest() {
+		if _, err := d.takeChainSnapshot(head); err != nil {
+			return nil, err
+		}
+	}
+
+	return d.snapshots.list(), nil
+}
+
+// servedSnapshotChunk returns the chunk of the given index of the chain
+// snapshot served at the block of the given hash.
+func (d *Avail) servedSnapshotChunk(hash types.Hash, index int) ([]byte, error) {
+	if d.snapshots == nil || !d.serveSnapshots {
+		return nil, errNoSnapshotServing
+	}
+
+	return d.snapshots.chunk(hash, index)
+}
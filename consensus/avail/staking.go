@@ -1,20 +1,38 @@
 package avail
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"math/big"
 	"sync"
 	"time"
 
 	"github.com/0xPolygon/polygon-edge/crypto"
-	stypes "github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/0xPolygon/polygon-edge/types"
 
+	"github.com/availproject/op-evm/pkg/avail"
 	"github.com/availproject/op-evm/pkg/block"
-	"github.com/availproject/op-evm/pkg/common"
 	"github.com/availproject/op-evm/pkg/staking"
 )
 
+// stakingSubmitTimeout bounds the submission of a staking block to Avail.
+const stakingSubmitTimeout = time.Minute
+
+// stakingSender adapts the Avail sender to the staking.Sender. Nodes unstake
+// on shutdown, after the run context is canceled, so the staking blocks are
+// submitted with a deadline of their own instead.
+type stakingSender struct {
+	sender avail.Sender
+}
+
+// Send submits the staking block to Avail.
+func (s stakingSender) Send(blk *types.Block) error {
+	ctx, cancel := context.WithTimeout(context.Background(), stakingSubmitTimeout)
+	defer cancel()
+
+	return s.sender.Send(ctx, blk)
+}
+
 // ensureStaked verifies whether a node is staked in the network.
 // It takes as arguments a WaitGroup and an ActiveParticipants object.
 // It determines the node type and checks if the node is under probation.
@@ -54,12 +72,9 @@ func (d *Avail) ensureStaked(wg *sync.WaitGroup, activeParticipantsQuerier staki
 		return nil
 	}
 
+	// The bootstrap sequencer stakes in the very block bootstrapping the
+	// chain; see bootstrap.
 	switch MechanismType(d.nodeType) {
-	case BootstrapSequencer:
-		// Staking smart contract does not support `BootstrapSequencer` MachineType.
-		if returnErr := d.stakeParticipant(false, Sequencer.String()); returnErr != nil {
-			return returnErr
-		}
 	case Sequencer, WatchTower:
 		staked, returnErr := d.stakeParticipantThroughTxPool(activeParticipantsQuerier)
 		if returnErr != nil {
@@ -74,73 +89,38 @@ func (d *Avail) ensureStaked(wg *sync.WaitGroup, activeParticipantsQuerier staki
 	return nil
 }
 
-// stakeParticipant stakes a participant in the network.
-// It takes as arguments a boolean value indicating whether to wait for discovery of additional peers
-// before pushing the block towards the rest of the community, and a string representing the node type.
-// It first builds a staking block, signs it, and then submits it to the Avail network.
-// After a successful submission, it writes the block to the local blockchain.
-// Function is used only if staked participant is bootstrap sequencer.
-func (d *Avail) stakeParticipant(shouldWait bool, nodeType string) error {
-	// Bootnode does not need to wait for any additional peers to be discovered prior pushing the
-	// block towards rest of the community, however, sequencers and watchtowers must!
-	if shouldWait {
-		for {
-			if d.network.GetBootnodeConnCount() > 0 {
-				break
-			}
-
-			time.Sleep(1 * time.Second)
-			continue
-		}
-	}
-
-	// First, build the staking block.
+// buildStakingBlock builds the block staking the node as a participant of
+// the given type on top of the head of the chain, signed by the node. The
+// bootstrap sequencer submits it to Avail as the first block of the chain.
+func (d *Avail) buildStakingBlock(nodeType string) (*types.Block, error) {
 	blockBuilderFactory := block.NewBlockBuilderFactory(d.blockchain, d.executor, d.logger)
 	bb, err := blockBuilderFactory.FromBlockchainHead()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	bb.SetCoinbaseAddress(d.minerAddr)
 	bb.SignWith(d.signKey)
 
-	stakeAmount := big.NewInt(0).Mul(big.NewInt(10), common.ETH)
 	tx, err := staking.StakeTx(d.minerAddr, stakeAmount, nodeType, 1_000_000)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	txSigner := &crypto.FrontierSigner{}
 	tx, err = txSigner.SignTx(tx, d.signKey)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	bb.AddTransactions(tx)
 	blk, err := bb.Build()
 	if err != nil {
 		d.logger.Error("failed to build staking block", "node_type", nodeType, "error", err)
-		return err
+		return nil, err
 	}
 
-	d.logger.Debug("sending block with staking tx to Avail")
-	err = d.availSender.SendAndWaitForStatus(blk, stypes.ExtrinsicStatus{IsInBlock: true})
-	if err != nil {
-		d.logger.Error("error while submitting data to avail", "error", err)
-		return err
-	}
-
-	d.logger.Info(
-		"Successfully wrote staking block to the blockchain",
-		"hash", blk.Hash().String(),
-	)
-
-	err = d.blockchain.WriteBlock(blk, d.nodeType.String())
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return blk, nil
 }
 
 // stakeParticipantThroughTxPool stakes a participant through the transaction pool.
@@ -164,31 +144,7 @@ func (d *Avail) stakeParticipantThroughTxPool(activeParticipantsQuerier staking.
 	// txpool tx will be added but bootstrap sequencer won't receive it.
 	time.Sleep(5 * time.Second)
 
-	stakeAmount := big.NewInt(0).Mul(big.NewInt(10), common.ETH)
-	tx, err := staking.StakeTx(d.minerAddr, stakeAmount, d.nodeType.String(), 1_000_000)
-	if err != nil {
-		return false, err
-	}
-
-	txSigner := &crypto.FrontierSigner{}
-	tx, err = txSigner.SignTx(tx, d.signKey)
-	if err != nil {
-		return false, err
-	}
-
-	for retries := 0; retries < 10; retries++ {
-		d.logger.Info("Submitting stake to the tx pool", "retry", retries)
-		// Submit staking transaction for execution by active sequencer.
-		if err := d.txpool.AddTx(tx); err != nil {
-			d.logger.Error("failure to add staking tx to the txpool err: %s", err)
-			time.Sleep(1 * time.Second)
-			continue
-		}
-		d.logger.Info("Stake submitted to the tx pool", "retry", retries)
-		break
-	}
-
-	if err != nil {
+	if err := submitStakeTx(d.txpool, d.signKey, d.nodeType.String(), d.overrides.priceLimitOr(d.production.PriceLimit), d.logger); err != nil {
 		return false, err
 	}
 
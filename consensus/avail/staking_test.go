@@ -1,12 +1,14 @@
 package avail
 
 import (
+	"context"
 	"math/big"
 	"os"
 	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/0xPolygon/polygon-edge/txpool"
 	"github.com/availproject/op-evm/pkg/avail"
 	"github.com/availproject/op-evm/pkg/common"
 	"github.com/availproject/op-evm/pkg/staking"
@@ -19,6 +21,17 @@ func getGenesisBasePath() string {
 	return filepath.Join(path, "..", "..")
 }
 
+// newTestTxPoolIndex returns the index of the txpool, for the duration of
+// the test.
+func newTestTxPoolIndex(t testing.TB, pool *txpool.TxPool) *txPoolIndex {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	return newTxPoolIndex(ctx, pool)
+}
+
 func NewTestAvail(t *testing.T, nodeType MechanismType) (*Avail, staking.ActiveParticipants) {
 	chain, err := test.NewChain(getGenesisBasePath())
 	if err != nil {
@@ -41,9 +54,10 @@ func NewTestAvail(t *testing.T, nodeType MechanismType) (*Avail, staking.ActiveP
 	blockchain.SetConsensus(verifier)
 
 	sender := avail.NewBlackholeSender()
-	stakingNode := staking.NewNode(blockchain, executor, sender, hclog.Default(), staking.NodeType(nodeType))
+	stakingNode := staking.NewNode(blockchain, executor, stakingSender{sender}, hclog.Default(), staking.NodeType(nodeType))
 
 	return &Avail{
+		ctx:         context.Background(),
 		logger:      hclog.Default(),
 		notifyCh:    make(chan struct{}),
 		closeCh:     make(chan struct{}),
@@ -51,11 +65,16 @@ func NewTestAvail(t *testing.T, nodeType MechanismType) (*Avail, staking.ActiveP
 		executor:    executor,
 		verifier:    verifier,
 		txpool:      txpool,
+		poolIndex:   newTestTxPoolIndex(t, txpool),
 		blockTime:   time.Duration(1) * time.Second,
 		nodeType:    nodeType,
 		signKey:     sequencerSignKey,
 		minerAddr:   sequencerAddr,
 		availSender: sender,
 		stakingNode: stakingNode,
+		forkChoice:  newForkChoice(blockchain, sequencerAddr, DefaultMaxReorgDepth, newSettledHead(blockchain, NewChallengeWindow(DefaultChallengeWindow), hclog.Default()), nil, hclog.Default()),
+		breaker:     newCircuitBreaker(DefaultCircuitBreakerConfig(), "", systemClock{}, hclog.Default()),
+		keys:        newKeyRotation(sequencerSignKey, nil, 0, hclog.Default()),
+		phases:      newTestPhaseMachine(),
 	}, asq
 }
@@ -0,0 +1,132 @@
+package avail
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/pkg/snapshot"
+)
+
+// ErrStateDiffBase is returned for a state diff not starting from the head of
+// the node, or from a state the node doesn't hold.
+var ErrStateDiffBase = errors.New("state diff base doesn't match the local state")
+
+// exportStateDiff writes the state diff from the block at the given height to
+// the settled one at the given later height to w, and returns the head of the
+// diff, the later block.
+func (d *Avail) exportStateDiff(w io.Writer, from, to uint64) (SettledHead, error) {
+	if d.stateStorage == nil {
+		return SettledHead{}, errNoStateStorage
+	}
+
+	if from >= to {
+		return SettledHead{}, fmt.Errorf("no blocks to diff from block %d to %d", from, to)
+	}
+
+	settled := d.forkChoice.settled.Head()
+	if to > settled.Number {
+		return SettledHead{}, fmt.Errorf("block %d not settled, settled head at %d", to, settled.Number)
+	}
+
+	headers := make([]*types.Header, 0, to-from+1)
+
+	for number := from; number <= to; number++ {
+		h, ok := d.blockchain.GetHeaderByNumber(number)
+		if !ok {
+			return SettledHead{}, fmt.Errorf("header %d not found", number)
+		}
+
+		headers = append(headers, h)
+	}
+
+	if err := d.checkStateHeld(headers[0]); err != nil {
+		return SettledHead{}, err
+	}
+
+	if canonical, ok := d.blockchain.GetHeaderByNumber(settled.Number); !ok || canonical.Hash != settled.Hash {
+		return SettledHead{}, fmt.Errorf("settled head %s no longer canonical", settled.Hash)
+	}
+
+	last := headers[len(headers)-1]
+	head := SettledHead{Number: last.Number, Hash: last.Hash, AvailBlock: settled.AvailBlock}
+
+	// The blocks settled below the head are no longer tracked; the Avail
+	// block of the last one is looked up on Avail.
+	if head.Number < settled.Number {
+		availBlock, err := d.availInclusion(head.Hash, 1, settled.AvailBlock)
+		if err != nil {
+			return SettledHead{}, err
+		}
+
+		head.AvailBlock = availBlock
+	}
+
+	sd, err := snapshot.NewStateDiff(headers, head.AvailBlock, d.stateStorage)
+	if err != nil {
+		return SettledHead{}, err
+	}
+
+	if err := snapshot.WriteStateDiff(w, sd); err != nil {
+		return SettledHead{}, err
+	}
+
+	d.logger.Info("state diff exported", "from_block_number", from, "block_number", head.Number, "block_hash", head.Hash, "avail_block_number", head.AvailBlock, "trie_nodes", len(sd.Nodes.Keys))
+
+	return head, nil
+}
+
+// importStateDiff reads the state diff from r, and has the node move on from
+// its head to the last block of the diff: the state and the headers are
+// written, and the block becomes the settled head, for the node to follow
+// Avail on from it. The diff must start from the head of the node, with its
+// state in the database; the state at the last block is checked against its
+// header, and the block against its inclusion in Avail, the diff being
+// refused unless its challenge window has passed. Nothing is written unless
+// every check passes.
+func (d *Avail) importStateDiff(r io.Reader) (SettledHead, error) {
+	if d.stateStorage == nil {
+		return SettledHead{}, errNoStateStorage
+	}
+
+	sd, err := snapshot.ReadStateDiff(r)
+	if err != nil {
+		return SettledHead{}, fmt.Errorf("failed to decode state diff: %w", err)
+	}
+
+	headers, err := sd.DecodeHeaders()
+	if err != nil {
+		return SettledHead{}, err
+	}
+
+	base, local := headers[0], d.blockchain.Header()
+	if base.Hash != local.Hash {
+		return SettledHead{}, fmt.Errorf("%w: diff from block %d (%s), local head at block %d (%s)", ErrStateDiffBase, base.Number, base.Hash, local.Number, local.Hash)
+	}
+
+	if _, err := d.executor.State().NewSnapshotAt(base.StateRoot); err != nil {
+		return SettledHead{}, fmt.Errorf("%w: %v", ErrStateDiffBase, err)
+	}
+
+	last := headers[len(headers)-1]
+	head := SettledHead{Number: last.Number, Hash: last.Hash, AvailBlock: sd.AvailBlock}
+
+	if err := d.checkSnapshotSettled(head); err != nil {
+		return SettledHead{}, err
+	}
+
+	if err := sd.ApplyState(last.StateRoot, d.stateStorage); err != nil {
+		return SettledHead{}, err
+	}
+
+	if err := d.blockchain.WriteHeaders(headers[1:]); err != nil {
+		return SettledHead{}, fmt.Errorf("failed to write the diff headers: %w", err)
+	}
+
+	d.forkChoice.settled.restore(head)
+
+	d.logger.Info("state diff imported", "from_block_number", base.Number, "block_number", head.Number, "block_hash", head.Hash, "avail_block_number", head.AvailBlock)
+
+	return head, nil
+}
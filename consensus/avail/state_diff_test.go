@@ -0,0 +1,287 @@
+package avail
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/0xPolygon/polygon-edge/crypto"
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/pkg/avail/testutil"
+	"github.com/availproject/op-evm/pkg/snapshot"
+	"github.com/availproject/op-evm/pkg/test"
+	avail_types "github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// testStorageContract is the init code of the contract storing 42 in its
+// first slot on the creation, and the word of the call data on every call.
+const testStorageContract = "602a600055" + "6007601160003960076000f3" + "60003560005500"
+
+// testActivity produces the blocks of a chain with some activity going on:
+// transfers to new accounts and to the ones funded before, and the creation
+// of a contract along with the updates to its storage.
+type testActivity struct {
+	producer *Avail
+	fake     *testutil.Fake
+	faucet   *testSender
+	contract types.Address
+	funded   []types.Address
+	slot     uint64 // The value of the first slot of the contract
+}
+
+func newTestActivity(producer *Avail, fake *testutil.Fake) *testActivity {
+	faucet := &testSender{addr: test.FaucetAccount, key: test.FaucetSignKey}
+
+	return &testActivity{producer: producer, fake: fake, faucet: faucet}
+}
+
+// settle settles on Avail the given number of blocks of the producer, with
+// some activity in each.
+func (a *testActivity) settle(t *testing.T, blocks int) {
+	t.Helper()
+
+	for i := 0; i < blocks; i++ {
+		to, _ := test.NewAccount(t)
+		txs := []*types.Transaction{a.faucet.sign(t, &types.Transaction{To: &to, Value: big.NewInt(1e18), Gas: 21_000}, 5000)}
+
+		if len(a.funded) > 0 {
+			txs = append(txs, a.faucet.sign(t, &types.Transaction{To: &a.funded[i%len(a.funded)], Value: big.NewInt(1), Gas: 21_000}, 5000))
+		}
+
+		a.funded = append(a.funded, to)
+
+		switch {
+		case a.contract == types.ZeroAddress:
+			a.contract = crypto.CreateAddress(a.faucet.addr, a.faucet.nonce)
+			a.slot = 42
+
+			code, _ := hex.DecodeString(testStorageContract)
+			txs = append(txs, a.faucet.sign(t, &types.Transaction{Input: code, Value: big.NewInt(0), Gas: 200_000}, 5000))
+
+		default:
+			// Every fourth update clears the slot, deleting it from the
+			// storage of the contract.
+			a.slot++
+			if a.slot%4 == 0 {
+				a.slot = 0
+			}
+
+			txs = append(txs, a.faucet.sign(t, &types.Transaction{To: &a.contract, Input: types.BytesToHash(new(big.Int).SetUint64(a.slot).Bytes()).Bytes(), Value: big.NewInt(0), Gas: 50_000}, 5000))
+		}
+
+		settleTestBlock(t, a.producer, a.fake, buildTestBlock(t, a.producer, txs...))
+	}
+}
+
+// storage returns the first slot of the contract in the state of the node.
+func (a *testActivity) storage(t *testing.T, d *Avail) types.Hash {
+	t.Helper()
+
+	head := d.blockchain.Header()
+
+	txn, err := d.executor.BeginTxn(head.StateRoot, head, types.ZeroAddress)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return txn.GetStorage(a.contract, types.Hash{})
+}
+
+// produceTestAvail produces the given number of empty Avail blocks.
+func produceTestAvail(fake *testutil.Fake, blocks int) {
+	for i := 0; i < blocks; i++ {
+		fake.Produce()
+	}
+}
+
+func TestStateDiffSync(t *testing.T) {
+	const window = 2
+
+	appID := avail_types.NewUCompactFromUInt(1)
+	fake := testutil.NewFake(appID)
+
+	producer := newTestGenesisAvail(t)
+	activity := newTestActivity(producer, fake)
+
+	for i := 0; i < 3; i++ {
+		settleTestBlock(t, producer, fake, buildTestBlock(t, producer))
+	}
+
+	produceTestAvail(fake, window)
+
+	// The source node exports the snapshot at its settled head.
+	source := newTestSnapshotAvail(t, fake, appID, window)
+	followTestAvail(t, source, fake)
+
+	base := source.forkChoice.settled.Head()
+	if !assert.Equal(t, uint64(3), base.Number) {
+		return
+	}
+
+	dir := t.TempDir()
+
+	if _, err := NewAdminAPI(source).ExportSnapshot(filepath.Join(dir, "snapshot")); err != nil {
+		t.Fatal(err)
+	}
+
+	// The chain goes on for 50 blocks of activity, and a few more past them,
+	// all settled.
+	activity.settle(t, 52)
+	produceTestAvail(fake, window)
+
+	followTestAvail(t, source, fake)
+	assert.Equal(t, producer.blockchain.Header().Hash, source.forkChoice.settled.Head().Hash)
+
+	to := base.Number + 50
+
+	exported, err := NewAdminAPI(source).ExportStateDiff(base.Number, to, filepath.Join(dir, "diff"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	target, ok := producer.blockchain.GetHeaderByNumber(to)
+	if !assert.True(t, ok) {
+		return
+	}
+
+	assert.Equal(t, target.Hash, exported.Hash)
+
+	// The diff holds the code of the contract, created past the base.
+	f, err := os.Open(filepath.Join(dir, "diff"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sd, err := snapshot.ReadStateDiff(f)
+	f.Close()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, sd.CodeHashes, 1)
+	assert.NotEmpty(t, sd.Nodes.Keys)
+
+	// A new node boots from the snapshot, and moves on with the diff.
+	node := newTestSnapshotAvail(t, fake, appID, window)
+
+	if _, err := NewAdminAPI(node).ImportSnapshot(filepath.Join(dir, "snapshot")); err != nil {
+		t.Fatal(err)
+	}
+
+	imported, err := NewAdminAPI(node).ImportStateDiff(filepath.Join(dir, "diff"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, exported, imported)
+	assert.Equal(t, target.Hash, node.blockchain.Header().Hash)
+	assert.Equal(t, target.StateRoot, node.blockchain.Header().StateRoot)
+	assert.Equal(t, exported, node.forkChoice.settled.Head())
+	assert.Equal(t, exported.AvailBlock, node.getNextAvailBlockNumber())
+
+	// The blocks past the diff execute on its state.
+	if _, err := syncTestAvail(node, fake); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, producer.blockchain.Header().Hash, node.blockchain.Header().Hash)
+	assert.Equal(t, producer.blockchain.Header().StateRoot, node.blockchain.Header().StateRoot)
+	assert.Equal(t, activity.storage(t, producer), activity.storage(t, node))
+}
+
+func TestStateDiffRefused(t *testing.T) {
+	const window = 2
+
+	appID := avail_types.NewUCompactFromUInt(1)
+	fake := testutil.NewFake(appID)
+
+	producer := newTestGenesisAvail(t)
+	activity := newTestActivity(producer, fake)
+
+	for i := 0; i < 2; i++ {
+		settleTestBlock(t, producer, fake, buildTestBlock(t, producer))
+	}
+
+	produceTestAvail(fake, window)
+
+	source := newTestSnapshotAvail(t, fake, appID, window)
+	followTestAvail(t, source, fake)
+
+	base := source.forkChoice.settled.Head()
+
+	var snap bytes.Buffer
+	if _, err := source.exportChainSnapshot(&snap); err != nil {
+		t.Fatal(err)
+	}
+
+	activity.settle(t, 5)
+
+	// The blocks still within their challenge window aren't exported.
+	followTestAvail(t, source, fake)
+
+	settled := source.forkChoice.settled.Head()
+
+	_, err := source.exportStateDiff(&bytes.Buffer{}, base.Number, settled.Number+1)
+	assert.Error(t, err)
+
+	_, err = source.exportStateDiff(&bytes.Buffer{}, settled.Number, settled.Number)
+	assert.Error(t, err)
+
+	var diff bytes.Buffer
+	if _, err := source.exportStateDiff(&diff, base.Number, settled.Number); err != nil {
+		t.Fatal(err)
+	}
+
+	exported := diff.Bytes()
+
+	last, ok := producer.blockchain.GetHeaderByNumber(settled.Number)
+	if !assert.True(t, ok) {
+		return
+	}
+
+	// The node not holding the base of the diff refuses it, and is left as
+	// it was.
+	node := newTestSnapshotAvail(t, fake, appID, window)
+
+	_, err = node.importStateDiff(bytes.NewReader(exported))
+	assert.True(t, errors.Is(err, ErrStateDiffBase), "unexpected error: %v", err)
+	assert.Zero(t, node.blockchain.Header().Number)
+
+	_, err = node.executor.State().NewSnapshotAt(last.StateRoot)
+	assert.Error(t, err)
+
+	// The diff missing a trie node of the state is refused.
+	if _, err := node.importChainSnapshot(bytes.NewReader(snap.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+
+	sd, err := snapshot.ReadStateDiff(bytes.NewReader(exported))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n := len(sd.Nodes.Keys) - 1
+	sd.Nodes.Keys, sd.Nodes.Values = sd.Nodes.Keys[:n], sd.Nodes.Values[:n]
+
+	var incomplete bytes.Buffer
+	if err := snapshot.WriteStateDiff(&incomplete, sd); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = node.importStateDiff(&incomplete)
+	assert.True(t, errors.Is(err, snapshot.ErrIncompleteState), "unexpected error: %v", err)
+	assert.Equal(t, base.Hash, node.blockchain.Header().Hash)
+
+	// The diff of the node holding its base applies.
+	head, err := node.importStateDiff(bytes.NewReader(exported))
+	if assert.NoError(t, err) {
+		assert.Equal(t, last.Hash, head.Hash)
+		assert.Equal(t, last.StateRoot, node.blockchain.Header().StateRoot)
+	}
+}
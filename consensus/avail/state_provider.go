@@ -0,0 +1,129 @@
+package avail
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/0xPolygon/polygon-edge/crypto"
+	itrie "github.com/0xPolygon/polygon-edge/state/immutable-trie"
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/pkg/avail"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/hashicorp/go-hclog"
+)
+
+// stateFetchTimeout is the deadline of a single fetch of a trie node or a
+// code from the state provider.
+const stateFetchTimeout = 10 * time.Second
+
+// providerStateStorage is the state storage of the watchtowers under the light
+// sync: the trie nodes and the code missing from the local state storage are
+// fetched by hash from the state provider, the JSON-RPC endpoint of a full
+// node, or of a snapshot provider, serving `avail_getStateNode` and
+// `avail_getStateCode`. The ones fetched are checked against their hash, and
+// kept in the local storage; a failed fetch is a miss.
+type providerStateStorage struct {
+	itrie.Storage // The local state storage
+	*stateProvider
+}
+
+// stateProvider fetches the trie nodes and the codes by hash from the state
+// provider; it's the RepairSource of the integrity check.
+type stateProvider struct {
+	client *rpc.Client
+	logger hclog.Logger
+}
+
+// NewProviderStateStorage returns the state storage over the given local one,
+// fetching the state missing from it from the state provider of the given
+// JSON-RPC URL.
+func NewProviderStateStorage(local itrie.Storage, url string, logger hclog.Logger) (itrie.Storage, error) {
+	client, err := rpc.Dial(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial the state provider %q: %w", url, err)
+	}
+
+	return newProviderStateStorage(local, client, logger), nil
+}
+
+func newProviderStateStorage(local itrie.Storage, client *rpc.Client, logger hclog.Logger) *providerStateStorage {
+	return &providerStateStorage{Storage: local, stateProvider: newStateProvider(client, logger)}
+}
+
+func newStateProvider(client *rpc.Client, logger hclog.Logger) *stateProvider {
+	return &stateProvider{client: client, logger: logger}
+}
+
+// Get returns the trie node of the given hash, fetching it from the state
+// provider when missing from the local storage.
+func (s *providerStateStorage) Get(k []byte) ([]byte, bool) {
+	if v, ok := s.Storage.Get(k); ok || len(k) != types.HashLength {
+		return v, ok
+	}
+
+	v, ok := s.StateNode(types.BytesToHash(k))
+	if ok {
+		s.Storage.Put(k, v)
+	}
+
+	return v, ok
+}
+
+// GetCode returns the code of the given hash, fetching it from the state
+// provider when missing from the local storage.
+func (s *providerStateStorage) GetCode(hash types.Hash) ([]byte, bool) {
+	if code, ok := s.Storage.GetCode(hash); ok {
+		return code, true
+	}
+
+	code, ok := s.StateCode(hash)
+	if ok {
+		s.Storage.SetCode(hash, code)
+	}
+
+	return code, ok
+}
+
+// Close closes the client of the state provider along with the local storage.
+func (s *providerStateStorage) Close() error {
+	s.client.Close()
+
+	return s.Storage.Close()
+}
+
+// StateNode fetches the trie node of the given hash from the state provider.
+func (s *stateProvider) StateNode(hash types.Hash) ([]byte, bool) {
+	return s.fetch(avail.SettlementNamespace+"_getStateNode", hash)
+}
+
+// StateCode fetches the code of the given hash from the state provider.
+func (s *stateProvider) StateCode(hash types.Hash) ([]byte, bool) {
+	return s.fetch(avail.SettlementNamespace+"_getStateCode", hash)
+}
+
+// fetch calls the given method of the state provider for the data of the
+// given hash, checking the data returned hashes to it.
+func (s *stateProvider) fetch(method string, hash types.Hash) ([]byte, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), stateFetchTimeout)
+	defer cancel()
+
+	var v []byte
+	if err := s.client.CallContext(ctx, &v, method, hash); err != nil {
+		s.logger.Warn("failed to fetch the state from the provider", "method", method, "hash", hash, "error", err)
+		observeStateFetch(false)
+
+		return nil, false
+	}
+
+	if types.BytesToHash(crypto.Keccak256(v)) != hash {
+		s.logger.Warn("state provider returned data not matching its hash; discarding it", "method", method, "hash", hash)
+		observeStateFetch(false)
+
+		return nil, false
+	}
+
+	observeStateFetch(true)
+
+	return v, true
+}
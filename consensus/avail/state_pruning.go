@@ -0,0 +1,179 @@
+package avail
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/pkg/blockchain"
+	"github.com/availproject/op-evm/pkg/pruning"
+	"github.com/hashicorp/go-hclog"
+)
+
+// statePruner prunes the state storage of the state of the blocks past the
+// retention, the given number of the latest canonical blocks, in the
+// background of the chain: a prune starts once the blocks written moved the
+// retention a tenth of it past the last one, and deletes the trie nodes in
+// batches, paced not to hold up the writes. The state at the protected roots,
+// the settled head the chain snapshots are taken at and the replay
+// checkpoint, is kept past the retention.
+type statePruner struct {
+	storage    *pruning.Storage
+	blockchain *blockchain.Blockchain
+	retention  uint64
+	config     pruning.PruneConfig
+	protected  func() []types.Hash
+	logger     hclog.Logger
+
+	lock     sync.Mutex
+	running  bool
+	boundary uint64 // The lowest block whose state the last prune retained
+	done     sync.WaitGroup
+}
+
+func newStatePruner(storage *pruning.Storage, blockchain *blockchain.Blockchain, retention uint64, config pruning.PruneConfig, protected func() []types.Hash, logger hclog.Logger) *statePruner {
+	return &statePruner{
+		storage:    storage,
+		blockchain: blockchain,
+		retention:  retention,
+		config:     config,
+		protected:  protected,
+		logger:     logger,
+	}
+}
+
+// step returns the number of blocks the retention moves between the prunes.
+func (p *statePruner) step() uint64 {
+	if p.retention < 10 {
+		return 1
+	}
+
+	return p.retention / 10
+}
+
+// observe starts a prune in the background, bound to the given context, once
+// the block written moved the retention far enough past the last one.
+func (p *statePruner) observe(ctx context.Context, blk *types.Block) {
+	if p == nil || blk.Number() < p.retention {
+		return
+	}
+
+	boundary := blk.Number() - p.retention + 1
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.running || boundary < p.boundary+p.step() {
+		return
+	}
+
+	p.running = true
+	p.done.Add(1)
+
+	go func() {
+		defer p.done.Done()
+
+		_, _ = p.prune(ctx)
+
+		p.lock.Lock()
+		p.running = false
+		p.lock.Unlock()
+	}()
+}
+
+// prune prunes the state storage of the state of the blocks past the
+// retention from the head.
+func (p *statePruner) prune(ctx context.Context) (pruning.PruneResult, error) {
+	var boundary uint64
+
+	// The roots are taken from the head at the start of the prune, the
+	// states written from then on kept by the storage.
+	roots := func() ([]types.Hash, error) {
+		head := p.blockchain.Header()
+		if head.Number >= p.retention {
+			boundary = head.Number - p.retention + 1
+		}
+
+		roots := p.protected()
+
+		for number := boundary; number <= head.Number; number++ {
+			h, ok := p.blockchain.GetHeaderByNumber(number)
+			if !ok {
+				return nil, fmt.Errorf("header %d not found", number)
+			}
+
+			roots = append(roots, h.StateRoot)
+		}
+
+		return roots, nil
+	}
+
+	res, err := p.storage.Prune(ctx, roots, p.config)
+	observeStatePrune(res)
+
+	if err != nil {
+		p.logger.Error("failed to prune the state", "boundary", boundary, "pruned_nodes", res.Pruned, "error", err)
+		return res, err
+	}
+
+	p.lock.Lock()
+	p.boundary = boundary
+	p.lock.Unlock()
+
+	p.logger.Info("state pruned", "boundary", boundary, "retained_nodes", res.Retained, "pruned_nodes", res.Pruned)
+
+	return res, nil
+}
+
+// wait waits for the prune in flight, if any.
+func (p *statePruner) wait() {
+	if p == nil {
+		return
+	}
+
+	p.done.Wait()
+}
+
+// protectedStateRoots returns the state roots kept past the retention: the
+// ones of the settled head, of the replay checkpoint and of the scheduled
+// chain snapshots in flight, if any.
+func (d *Avail) protectedStateRoots() []types.Hash {
+	var roots []types.Hash
+
+	if h, ok := d.blockchain.GetHeaderByHash(d.forkChoice.settled.Head().Hash); ok {
+		roots = append(roots, h.StateRoot)
+	}
+
+	if cp, ok, err := d.replay.load(); err == nil && ok {
+		roots = append(roots, cp.StateRoot)
+	}
+
+	for _, head := range d.snapshotScheduler.heads() {
+		if h, ok := d.blockchain.GetHeaderByHash(head.Hash); ok {
+			roots = append(roots, h.StateRoot)
+		}
+	}
+
+	return roots
+}
+
+// StateRetention returns the number of the latest blocks whose state is
+// retained, or 0 if the state isn't pruned.
+func (d *Avail) StateRetention() uint64 {
+	if d.pruner == nil {
+		return 0
+	}
+
+	return d.pruner.retention
+}
+
+// checkStateHeld returns pruning.ErrStatePruned for the block whose state was
+// pruned from the state storage.
+func (d *Avail) checkStateHeld(h *types.Header) error {
+	if d.pruner == nil || !d.pruner.storage.Pruned(h.StateRoot) {
+		return nil
+	}
+
+	return fmt.Errorf("%w: block %d", pruning.ErrStatePruned, h.Number)
+}
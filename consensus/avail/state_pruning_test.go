@@ -0,0 +1,112 @@
+package avail
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/pkg/avail/testutil"
+	"github.com/availproject/op-evm/pkg/pruning"
+	"github.com/availproject/op-evm/pkg/test"
+	avail_types "github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestPrunedAvail returns the consensus on a state storage pruned of the
+// state past the given retention, along with the storage.
+func newTestPrunedAvail(t *testing.T, retention uint64) (*Avail, *pruning.Storage) {
+	t.Helper()
+
+	chain, err := test.NewChain(getGenesisBasePath())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := test.NewMemoryStorage()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	storage := pruning.NewMemoryStorage()
+	addr, key := test.NewAccount(t)
+
+	d := newTestGenesisAvailOnState(t, chain, db, storage, addr, key)
+	d.pruner = newStatePruner(storage, d.blockchain, retention, pruning.PruneConfig{BatchSize: 8}, d.protectedStateRoots, hclog.Default())
+
+	return d, storage
+}
+
+func TestStatePruning(t *testing.T) {
+	appID := avail_types.NewUCompactFromUInt(1)
+	fake := testutil.NewFake(appID)
+
+	d, storage := newTestPrunedAvail(t, 4)
+	activity := newTestActivity(d, fake)
+	activity.settle(t, 12)
+
+	head := d.blockchain.Header()
+
+	// The replay checkpoint keeps its state past the retention.
+	checkpoint, _ := d.blockchain.GetHeaderByNumber(3)
+
+	d.replay = newReplayCheckpoints(t.TempDir(), 1, hclog.Default())
+	if err := d.replay.save(ReplayCheckpoint{AvailBlock: 1, Number: checkpoint.Number, Hash: checkpoint.Hash, StateRoot: checkpoint.StateRoot}); err != nil {
+		t.Fatal(err)
+	}
+
+	d.forkChoice.settled.restore(SettledHead{Number: head.Number, Hash: head.Hash, AvailBlock: fake.Head()})
+
+	before, err := storage.NodeCount()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The block written past the retention starts the prune.
+	blk, _ := d.blockchain.GetBlockByNumber(head.Number, true)
+	d.pruner.observe(context.Background(), blk)
+	d.pruner.wait()
+
+	after, err := storage.NodeCount()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Less(t, after, before)
+
+	st := pruning.NewState(d.executor.State(), storage)
+
+	for number := uint64(1); number <= head.Number; number++ {
+		h, _ := d.blockchain.GetHeaderByNumber(number)
+
+		_, err := st.NewSnapshotAt(h.StateRoot)
+		if number > head.Number-4 || number == checkpoint.Number {
+			assert.NoError(t, err, "state of block %d", number)
+		} else {
+			assert.ErrorIs(t, err, pruning.ErrStatePruned, "state of block %d", number)
+		}
+	}
+
+	// The state diffs start from the state retained.
+	_, err = d.exportStateDiff(&bytes.Buffer{}, 5, head.Number)
+	assert.ErrorIs(t, err, pruning.ErrStatePruned)
+
+	_, err = d.exportStateDiff(&bytes.Buffer{}, head.Number-2, head.Number)
+	assert.NoError(t, err)
+
+	// The chain goes on from the state retained, pruned again as the
+	// retention moves on.
+	activity.settle(t, 2)
+	assert.Equal(t, types.BytesToHash(new(big.Int).SetUint64(activity.slot).Bytes()), activity.storage(t, d))
+
+	next := d.blockchain.Header()
+
+	nextBlk, _ := d.blockchain.GetBlockByNumber(next.Number, true)
+	d.pruner.observe(context.Background(), nextBlk)
+	d.pruner.wait()
+
+	_, err = st.NewSnapshotAt(head.StateRoot)
+	assert.NoError(t, err)
+}
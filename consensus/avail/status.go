@@ -0,0 +1,477 @@
+package avail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/0xPolygon/polygon-edge/helper/common"
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/pkg/avail"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// errNoDisputeFeed is returned by the status API of the node running without
+// the dispute feed.
+var errNoDisputeFeed = errors.New("dispute feed not set up")
+
+// errNoSettlementIndex is returned by the status API of the node running
+// without the settlement index.
+var errNoSettlementIndex = errors.New("settlement index not set up")
+
+// settlementBackfillLimit is the most blocks the settlements are backfilled
+// from on a "settlements" subscription.
+const settlementBackfillLimit = 1024
+
+// NodeStatus is the status of the node, as returned by `avail_getNodeStatus`.
+type NodeStatus struct {
+	NodeType         string          `json:"nodeType"`
+	BlockNumber      uint64          `json:"blockNumber"`
+	BlockHash        types.Hash      `json:"blockHash"`
+	BlockTime        common.Duration `json:"blockTime"`
+	ProductionPaused bool            `json:"productionPaused"`
+
+	// Ready is set once the sequencer's stake shows up in the staking
+	// contract; until then, the node follows the chain only, for the reason
+	// given.
+	Ready           bool   `json:"ready"`
+	ReadinessReason string `json:"readinessReason"`
+
+	// DisputeState is the state of the disputes against the sequencer; the
+	// production is paused under any but "none".
+	DisputeState string `json:"disputeState"`
+
+	// CatchingUp is set while the node catches up with the Avail head, from
+	// the Avail block at the cursor; no blocks are produced meanwhile.
+	CatchingUp  bool   `json:"catchingUp"`
+	AvailCursor uint64 `json:"availCursor"`
+	AvailHead   uint64 `json:"availHead"`
+
+	// Sync is the progress of the node syncing the chain from Avail, in the
+	// replay on the start or the catch-up; unset while it follows the live
+	// Avail blocks.
+	Sync *SyncStatus `json:"sync,omitempty"`
+
+	// Phase is the phase of the consensus of the node, entered at PhaseSince.
+	Phase      string    `json:"phase"`
+	PhaseSince time.Time `json:"phaseSince"`
+
+	// UnsettledBlocks is the number of produced blocks waiting for their
+	// inclusion in Avail to be confirmed.
+	UnsettledBlocks int `json:"unsettledBlocks"`
+
+	// SettledBlock is the highest block seen settled on Avail, and
+	// SettlementLag the number of blocks the head was ahead of it when last
+	// observed; the production is paused while it's too far behind.
+	SettledBlock     uint64 `json:"settledBlock"`
+	SettlementLag    uint64 `json:"settlementLag"`
+	SettlementPaused bool   `json:"settlementPaused"`
+
+	// CircuitBreaker is the trip of the circuit breaker halting the
+	// consensus, if any.
+	CircuitBreaker *CircuitBreakerTrip `json:"circuitBreaker,omitempty"`
+
+	// KeyRotation is the rotation of the signing key of the sequencer, if
+	// any.
+	KeyRotation *KeyRotationStatus `json:"keyRotation,omitempty"`
+
+	// EscalatedDisputes are the disputes left open past their escalation
+	// deadline, the oldest first; they call for the operators' attention.
+	EscalatedDisputes []EscalatedDispute `json:"escalatedDisputes,omitempty"`
+
+	// TrustedSync is the trusted-root fast sync of the node, if enabled: the
+	// blocks at or below its height weren't executed, their state trusted.
+	TrustedSync *TrustedSyncStatus `json:"trustedSync,omitempty"`
+
+	// LightSync is set for the watchtower under the light sync, which
+	// re-executes a sample of the blocks only, and produces none.
+	LightSync bool `json:"lightSync"`
+}
+
+// StatusAPI serves the status of the node over JSON-RPC, along with the
+// recent conflicts with the own blocks of the node for debugging, the fraud
+// events it has seen, the lifecycles of the disputes and the staked
+// participants.
+type StatusAPI struct {
+	d *Avail
+}
+
+// NewStatusAPI returns the StatusAPI of the consensus.
+func NewStatusAPI(d *Avail) *StatusAPI {
+	return &StatusAPI{d: d}
+}
+
+// GetNodeStatus returns the current status of the node.
+func (api *StatusAPI) GetNodeStatus() (*NodeStatus, error) {
+	return api.d.Status(), nil
+}
+
+// GetRecentConflicts returns the recent blocks seen on Avail competing with
+// the own blocks of the node, the most recent first.
+func (api *StatusAPI) GetRecentConflicts() ([]Conflict, error) {
+	if api.d.forkChoice == nil {
+		return []Conflict{}, nil
+	}
+
+	return api.d.forkChoice.RecentConflicts(), nil
+}
+
+// GetSettledHead returns the highest block settled on Avail past the
+// challenge window.
+func (api *StatusAPI) GetSettledHead() (*SettledHead, error) {
+	head := api.d.SettledHead()
+
+	return &head, nil
+}
+
+// GetFraudEvents returns the fraud events of the blocks numbered from and to
+// the given ones, inclusive, of the given miner if any, by block number. The
+// events are paged; the next page, if any, is queried from the block number
+// returned along.
+func (api *StatusAPI) GetFraudEvents(from, to uint64, miner *types.Address) (*FraudEventsPage, error) {
+	if from > to {
+		return nil, fmt.Errorf("block range from %d to %d is empty", from, to)
+	}
+
+	page := api.d.frauds.Events(from, to, miner)
+
+	return &page, nil
+}
+
+// Disputes subscribes to the dispute events published from now on, the
+// `avail_subscribe` subscription of "disputes"; it's served over WebSocket. A
+// subscriber falling behind misses events, to be polled with
+// `avail_getDisputeEvents` from the cursor of the last one received.
+func (api *StatusAPI) Disputes(ctx context.Context) (*rpc.Subscription, error) {
+	if api.d.disputeFeed == nil {
+		return nil, errNoDisputeFeed
+	}
+
+	notifier, ok := rpc.NotifierFromContext(ctx)
+	if !ok {
+		return nil, rpc.ErrNotificationsUnsupported
+	}
+
+	sub := notifier.CreateSubscription()
+	events, unsubscribe := api.d.disputeFeed.subscribe()
+
+	go func() {
+		defer unsubscribe()
+
+		for {
+			select {
+			case e := <-events:
+				if err := notifier.Notify(sub.ID, e); err != nil {
+					return
+				}
+			case <-sub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+
+	return sub, nil
+}
+
+// Settlements subscribes to the settlement events of the blocks, as they go
+// into an Avail block and as that goes final, the `avail_subscribe`
+// subscription of "settlements"; it's served over WebSocket. Given a block
+// number, the current stages of the canonical blocks from it up to the head
+// are sent first, up to settlementBackfillLimit blocks. A subscriber falling
+// behind misses events, to be looked up with `avail_getSettlementInfo`.
+func (api *StatusAPI) Settlements(ctx context.Context, from *uint64) (*rpc.Subscription, error) {
+	if api.d.settlements == nil {
+		return nil, errNoSettlementIndex
+	}
+
+	notifier, ok := rpc.NotifierFromContext(ctx)
+	if !ok {
+		return nil, rpc.ErrNotificationsUnsupported
+	}
+
+	head := api.d.blockchain.Header().Number
+	if from != nil && *from <= head && head-*from >= settlementBackfillLimit {
+		return nil, fmt.Errorf("backfill from block %d spans more than %d blocks", *from, settlementBackfillLimit)
+	}
+
+	sub := notifier.CreateSubscription()
+
+	// The live events are subscribed to ahead of the backfill, not to miss
+	// any in between; the ones backfilled already are skipped.
+	events, unsubscribe := api.d.settlements.Subscribe()
+
+	var backfill []avail.SettlementEvent
+	if from != nil {
+		backfill = api.d.settlementBackfill(*from, head)
+	}
+
+	go func() {
+		defer unsubscribe()
+
+		sent := make(map[settlementStage]struct{}, len(backfill))
+
+		for _, e := range backfill {
+			if err := notifier.Notify(sub.ID, e); err != nil {
+				return
+			}
+
+			sent[settlementStage{e.BlockHash, e.Stage}] = struct{}{}
+		}
+
+		for {
+			select {
+			case e := <-events:
+				if _, ok := sent[settlementStage{e.BlockHash, e.Stage}]; ok {
+					continue
+				}
+
+				if err := notifier.Notify(sub.ID, e); err != nil {
+					return
+				}
+			case <-sub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+
+	return sub, nil
+}
+
+// GetSnapshots returns the manifests of the chain snapshots the node serves,
+// the latest first, for the new nodes to bootstrap from; a snapshot is taken
+// at the settled head first if it moved past the latest one.
+func (api *StatusAPI) GetSnapshots() ([]*SnapshotManifest, error) {
+	return api.d.servedSnapshots()
+}
+
+// GetSnapshotChunk returns the chunk of the given index of the chain snapshot
+// served at the block of the given hash, to be checked against its hash in
+// the manifest.
+func (api *StatusAPI) GetSnapshotChunk(hash types.Hash, index int) ([]byte, error) {
+	return api.d.servedSnapshotChunk(hash, index)
+}
+
+// Syncing returns the progress of the node syncing the chain from Avail, in
+// the shape of `eth_syncing` extended with the Avail side of it; false while
+// the node follows the live Avail blocks.
+func (api *StatusAPI) Syncing() (interface{}, error) {
+	if status := api.d.progress.Status(); status != nil {
+		return status, nil
+	}
+
+	return false, nil
+}
+
+// SyncProgress subscribes to the progress of the node syncing the chain from
+// Avail from now on, the `avail_subscribe` subscription of "syncProgress";
+// it's served over WebSocket. The last event of a sync is of the live stage.
+func (api *StatusAPI) SyncProgress(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, ok := rpc.NotifierFromContext(ctx)
+	if !ok {
+		return nil, rpc.ErrNotificationsUnsupported
+	}
+
+	sub := notifier.CreateSubscription()
+	events, unsubscribe := api.d.progress.subscribe()
+
+	go func() {
+		defer unsubscribe()
+
+		for {
+			select {
+			case e := <-events:
+				if err := notifier.Notify(sub.ID, e); err != nil {
+					return
+				}
+			case <-sub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+
+	return sub, nil
+}
+
+// GetDisputeEvents returns the page of the recent dispute events following
+// the given cursor, 0 for the oldest one kept, in the order published; the
+// events following the page are polled with the cursor returned along.
+func (api *StatusAPI) GetDisputeEvents(cursor uint64) (*DisputeEventsPage, error) {
+	if api.d.disputeFeed == nil {
+		return nil, errNoDisputeFeed
+	}
+
+	page := api.d.disputeFeed.since(cursor)
+
+	return &page, nil
+}
+
+// GetStateNode returns the trie node of the state of the given hash, for the
+// watchtowers under the light sync to fetch the state they re-execute the
+// blocks on. It's refused unless the node serves the state.
+func (api *StatusAPI) GetStateNode(hash types.Hash) ([]byte, error) {
+	if !api.d.serveState {
+		return nil, errNoStateServing
+	}
+
+	if api.d.stateStorage == nil {
+		return nil, errNoStateStorage
+	}
+
+	node, ok := api.d.stateStorage.Get(hash.Bytes())
+	if !ok {
+		return nil, fmt.Errorf("state trie node %s not found", hash)
+	}
+
+	return node, nil
+}
+
+// GetStateCode returns the contract code of the given hash, for the
+// watchtowers under the light sync. It's refused unless the node serves the
+// state.
+func (api *StatusAPI) GetStateCode(hash types.Hash) ([]byte, error) {
+	if !api.d.serveState {
+		return nil, errNoStateServing
+	}
+
+	if api.d.stateStorage == nil {
+		return nil, errNoStateStorage
+	}
+
+	code, ok := api.d.stateStorage.GetCode(hash)
+	if !ok {
+		return nil, fmt.Errorf("code %s not found", hash)
+	}
+
+	return code, nil
+}
+
+// SettledHead returns the highest block settled on Avail past the challenge
+// window, the genesis if the settlement isn't tracked.
+func (d *Avail) SettledHead() SettledHead {
+	if d.forkChoice == nil || d.forkChoice.settled == nil {
+		if genesis, ok := d.blockchain.GetHeaderByNumber(0); ok {
+			return SettledHead{Hash: genesis.Hash}
+		}
+
+		return SettledHead{}
+	}
+
+	return d.forkChoice.settled.Head()
+}
+
+// headerByNumberOrHash returns the header of the block of the given hash, or
+// the canonical one of the given number; "latest" and "pending" stand for the
+// head, "finalized" and "safe" for the settled head.
+func (d *Avail) headerByNumberOrHash(blockNrOrHash rpc.BlockNumberOrHash) (*types.Header, error) {
+	if hash, ok := blockNrOrHash.Hash(); ok {
+		hdr, ok := d.blockchain.GetHeaderByHash(types.Hash(hash))
+		if !ok {
+			return nil, fmt.Errorf("block %s not found", types.Hash(hash))
+		}
+
+		return hdr, nil
+	}
+
+	number, ok := blockNrOrHash.Number()
+	if !ok {
+		return nil, fmt.Errorf("block hash or number expected")
+	}
+
+	var n uint64
+
+	switch number {
+	case rpc.LatestBlockNumber, rpc.PendingBlockNumber:
+		n = d.blockchain.Header().Number
+	case rpc.FinalizedBlockNumber, rpc.SafeBlockNumber:
+		n = d.SettledHead().Number
+	default:
+		if number < 0 {
+			return nil, fmt.Errorf("block %d not supported", number)
+		}
+
+		n = uint64(number)
+	}
+
+	hdr, ok := d.blockchain.GetHeaderByNumber(n)
+	if !ok {
+		return nil, fmt.Errorf("block %d not found", n)
+	}
+
+	return hdr, nil
+}
+
+// Status returns the current status of the node.
+func (d *Avail) Status() *NodeStatus {
+	status := &NodeStatus{
+		NodeType:  string(d.NodeMode()),
+		BlockTime: common.Duration{Duration: d.blockTime},
+	}
+
+	if hdr := d.blockchain.Header(); hdr != nil {
+		status.BlockNumber = hdr.Number
+		status.BlockHash = hdr.Hash
+	}
+
+	if d.balanceMonitor != nil {
+		status.ProductionPaused = d.balanceMonitor.Paused()
+	}
+
+	if d.readiness != nil {
+		status.ReadinessReason = string(d.readiness.Reason())
+		status.Ready = d.readiness.Ready()
+	}
+
+	if d.disputes != nil {
+		status.DisputeState = d.disputes.State().String()
+		status.ProductionPaused = status.ProductionPaused || d.disputes.Paused()
+	}
+
+	if d.progress != nil {
+		status.CatchingUp = d.progress.CatchingUp()
+		status.AvailCursor = d.progress.cursor.Load()
+		status.AvailHead = d.progress.head.Load()
+		status.Sync = d.progress.Status()
+	}
+
+	if d.phases != nil {
+		phase, since := d.phases.Phase()
+		status.Phase = string(phase)
+		status.PhaseSince = since
+	}
+
+	if d.unsettled != nil {
+		status.UnsettledBlocks = d.unsettled.Len()
+	}
+
+	if d.settlement != nil {
+		status.SettledBlock = d.settlement.Settled()
+		status.SettlementLag = d.settlement.Lag()
+		status.SettlementPaused = d.settlement.Paused()
+		status.ProductionPaused = status.ProductionPaused || status.SettlementPaused
+	}
+
+	if d.breaker != nil {
+		status.CircuitBreaker = d.breaker.Trip()
+		status.ProductionPaused = status.ProductionPaused || status.CircuitBreaker != nil
+	}
+
+	if d.keys != nil {
+		status.KeyRotation = d.keys.Status()
+	}
+
+	if d.disputeWatcher != nil {
+		status.EscalatedDisputes = d.disputeWatcher.escalated()
+	}
+
+	status.LightSync = d.light != nil
+	status.TrustedSync = d.trustedSyncStatus()
+
+	return status
+}
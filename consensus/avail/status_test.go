@@ -0,0 +1,47 @@
+package avail
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatusAPIReportsBlockTime(t *testing.T) {
+	d, _ := NewTestAvail(t, Sequencer)
+	d.blockTime = 5 * time.Second
+
+	status, err := NewStatusAPI(d).GetNodeStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	head := d.blockchain.Header()
+
+	assert.Equal(t, string(Sequencer), status.NodeType)
+	assert.Equal(t, 5*time.Second, status.BlockTime.Duration)
+	assert.Equal(t, head.Number, status.BlockNumber)
+	assert.Equal(t, head.Hash, status.BlockHash)
+	assert.False(t, status.ProductionPaused)
+}
+
+func TestStatusAPIServesStateOnlyWhenEnabled(t *testing.T) {
+	d := newTestGenesisAvail(t)
+	root := d.blockchain.Header().StateRoot
+	api := NewStatusAPI(d)
+
+	// The raw state is refused by default.
+	_, err := api.GetStateNode(root)
+	assert.ErrorIs(t, err, errNoStateServing)
+
+	_, err = api.GetStateCode(root)
+	assert.ErrorIs(t, err, errNoStateServing)
+
+	d.serveState = true
+
+	node, err := api.GetStateNode(root)
+	if assert.NoError(t, err) {
+		stored, _ := d.stateStorage.Get(root.Bytes())
+		assert.Equal(t, stored, node)
+	}
+}
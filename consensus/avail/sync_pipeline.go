@@ -0,0 +1,173 @@
+package avail
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/availproject/op-evm/pkg/avail"
+	"github.com/availproject/op-evm/pkg/blockchain"
+	avail_types "github.com/centrifuge/go-substrate-rpc-client/v4/types"
+)
+
+// maxSyncFetchRetries is the number of attempts made to fetch a page of the
+// Avail blocks of the history, before the sync gives up.
+const maxSyncFetchRetries = 3
+
+// fetchedPage is a page of the Avail blocks fetched ahead by the syncPipeline,
+// or the error fetching it.
+type fetchedPage struct {
+	from, to uint64
+	blks     []*avail_types.SignedBlock
+	err      error
+}
+
+// syncPipeline runs the sync of the Avail history on the start as a pipeline
+// of stages: the pages of Avail blocks are fetched ahead, several of them in
+// flight, the Avail blocks decoded and the signers of their edge blocks
+// recovered on the workers, and the edge blocks written strictly in the order
+// of the Avail blocks by the consumer of the pipeline. The stages are joined
+// by bounded queues, for the fetches and the decoding to run no further ahead
+// of the writes than the depth and the workers allow.
+type syncPipeline struct {
+	client   avail.Client
+	pageSize uint64
+	depth    int
+	workers  int
+
+	// err is the error fetching the page the pipeline stopped at, set once
+	// the output channel is closed.
+	err error
+}
+
+// newSyncPipeline returns the syncPipeline of the catch-up configuration, the
+// pages of its size, and up to its pipeline depth of them in flight.
+func newSyncPipeline(client avail.Client, cfg CatchUpConfig) *syncPipeline {
+	p := &syncPipeline{
+		client:   client,
+		pageSize: cfg.PageSize,
+		depth:    int(cfg.PipelineDepth),
+		workers:  int(cfg.Workers),
+	}
+
+	if p.pageSize == 0 {
+		p.pageSize = DefaultCatchUpPageSize
+	}
+
+	if p.depth < 1 {
+		p.depth = 1
+	}
+
+	return p
+}
+
+// run runs the pipeline over the Avail blocks of the given range, and returns
+// the channel of the prepared ones, in their order. The Avail block failing to
+// decode is handed out with its error, the ones past it going on. The channel
+// is closed past the last block, on a page failing to fetch, with the error
+// left for Err, or on the context canceled; the consumer stopping early
+// cancels it.
+func (p *syncPipeline) run(ctx context.Context, bc *blockchain.Blockchain, decoder *avail.BlockDecoder, from, to uint64) <-chan *preparedBlock {
+	return prepareAvailBlockStream(ctx, bc, decoder, p.fetch(ctx, from, to), p.workers)
+}
+
+// Err returns the error fetching the page the pipeline stopped at, or nil;
+// it's to be called once the channel of the pipeline is closed.
+func (p *syncPipeline) Err() error {
+	return p.err
+}
+
+// fetch fetches the pages of the Avail blocks of the range, up to the depth
+// of them in flight past the one handed out, and hands their blocks out over
+// the channel, in order.
+func (p *syncPipeline) fetch(ctx context.Context, from, to uint64) <-chan *avail_types.SignedBlock {
+	ctx, cancel := context.WithCancel(ctx)
+
+	pending := make(chan chan fetchedPage, p.depth)
+	out := make(chan *avail_types.SignedBlock)
+
+	// The pages are fetched concurrently, each with the slot of its result
+	// queued up in the order of the pages; the queue bounds the pages in
+	// flight.
+	go func() {
+		defer close(pending)
+
+		for lo := from; lo <= to; lo += p.pageSize {
+			hi := lo + p.pageSize - 1
+			if hi > to || hi < lo {
+				hi = to
+			}
+
+			res := make(chan fetchedPage, 1)
+
+			select {
+			case pending <- res:
+			case <-ctx.Done():
+				return
+			}
+
+			go func(lo, hi uint64) {
+				res <- p.fetchPage(ctx, lo, hi)
+			}(lo, hi)
+
+			if hi == to {
+				return
+			}
+		}
+	}()
+
+	go func() {
+		defer cancel()
+		defer close(out)
+
+		for res := range pending {
+			var page fetchedPage
+
+			select {
+			case page = <-res:
+			case <-ctx.Done():
+				return
+			}
+
+			if page.err != nil {
+				if ctx.Err() == nil {
+					p.err = fmt.Errorf("failed to fetch Avail blocks %d to %d: %w", page.from, page.to, page.err)
+				}
+
+				return
+			}
+
+			for _, blk := range page.blks {
+				select {
+				case out <- blk:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return out
+}
+
+// fetchPage fetches the page of the Avail blocks of the given range, retrying
+// it on a failure.
+func (p *syncPipeline) fetchPage(ctx context.Context, from, to uint64) fetchedPage {
+	page := fetchedPage{from: from, to: to}
+
+	for attempt := 0; attempt < maxSyncFetchRetries && ctx.Err() == nil; attempt++ {
+		page.blks, page.err = p.client.Query(ctx, from, to)
+		if page.err == nil && uint64(len(page.blks)) != to-from+1 {
+			page.err = fmt.Errorf("%d blocks fetched, expected %d", len(page.blks), to-from+1)
+		}
+
+		if page.err == nil {
+			return page
+		}
+	}
+
+	if page.err == nil {
+		page.err = ctx.Err()
+	}
+
+	return page
+}
@@ -0,0 +1,279 @@
+package avail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/pkg/avail"
+	"github.com/availproject/op-evm/pkg/avail/testutil"
+	"github.com/availproject/op-evm/pkg/staking"
+	"github.com/availproject/op-evm/pkg/test"
+	avail_types "github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types/codec"
+	"github.com/stretchr/testify/assert"
+)
+
+// faultyQueryClient is the fake Avail passing every query through the given
+// function first, failing it on its error.
+type faultyQueryClient struct {
+	*testutil.Fake
+	query func(ctx context.Context, from, to uint64) error
+}
+
+func (c *faultyQueryClient) Query(ctx context.Context, from, to uint64) ([]*avail_types.SignedBlock, error) {
+	if err := c.query(ctx, from, to); err != nil {
+		return nil, err
+	}
+
+	return c.Fake.Query(ctx, from, to)
+}
+
+// newSyncing returns the node on the genesis of the backlog, syncing the
+// Avail history off the given client through the pipeline of the given
+// depth, in pages of 8 Avail blocks.
+func (bl *testBacklog) newSyncing(tb testing.TB, client avail.Client, depth uint64) *Avail {
+	tb.Helper()
+
+	addr, key := test.NewAccount(tb)
+
+	d := newTestGenesisAvailOn(tb, bl.chain, newTestMemoryStorage(tb), addr, key)
+	d.availClient = client
+	d.availAppID = avail_types.NewUCompactFromUInt(1)
+	d.catchUp = CatchUpConfig{PageSize: 8, Workers: 4, PipelineDepth: depth}
+
+	return d
+}
+
+// assertSynced asserts the node holds the blocks of the backlog up to the
+// given number, and none past it.
+func (bl *testBacklog) assertSynced(t *testing.T, d *Avail, to uint64) {
+	t.Helper()
+
+	for _, blk := range bl.blks {
+		hdr, ok := d.blockchain.GetHeaderByNumber(blk.Number())
+		if blk.Number() > to {
+			assert.False(t, ok, "block %d written", blk.Number())
+		} else if assert.True(t, ok, "block %d not written", blk.Number()) {
+			assert.Equal(t, blk.Hash(), hdr.Hash)
+		}
+	}
+}
+
+// syncHistory syncs the node with Avail up to the Avail block of the given
+// number.
+func syncHistory(d *Avail, head uint64) (uint64, error) {
+	return d.syncNodeUntil(func(blk *avail_types.SignedBlock) bool {
+		return uint64(blk.Block.Header.Number) == head
+	})
+}
+
+func TestSyncPipeline(t *testing.T) {
+	const blocks = 40
+
+	backlog := newTestBacklog(t, blocks, 2)
+	fake := backlog.settle(t, nil)
+
+	for _, depth := range []uint64{0, 1, 3} {
+		t.Run(fmt.Sprintf("depth-%d", depth), func(t *testing.T) {
+			var queries atomic.Int64
+
+			d := backlog.newSyncing(t, &faultyQueryClient{Fake: fake, query: func(context.Context, uint64, uint64) error {
+				queries.Add(1)
+				return nil
+			}}, depth)
+
+			cursor, err := syncHistory(d, fake.Head())
+			assert.NoError(t, err)
+			assert.Equal(t, fake.Head(), cursor)
+			backlog.assertSynced(t, d, blocks)
+
+			// The history is fetched a page at a time through the pipeline,
+			// off the block stream without it.
+			if depth == 0 {
+				assert.Zero(t, queries.Load())
+			} else {
+				assert.Equal(t, int64((blocks+7)/8), queries.Load())
+			}
+		})
+	}
+}
+
+func TestSyncPipelinePassesOverBadBlob(t *testing.T) {
+	const (
+		blocks = 20
+		bad    = 10
+	)
+
+	appID := avail_types.NewUCompactFromUInt(1)
+
+	backlog := newTestBacklog(t, blocks, 2)
+
+	// The blob failing to decode goes in the Avail block ahead of the one of
+	// the block in the middle.
+	fake := testutil.NewFake(appID)
+	backlog.settleOn(t, fake, func(blk *types.Block) *types.Block {
+		if blk.Number() == bad {
+			args, err := codec.Encode([]byte("not a block"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ext := avail_types.NewExtrinsic(avail_types.Call{CallIndex: fake.SubmitDataCallIndex(), Args: args})
+			ext.Signature.AppID = appID
+
+			fake.Produce(ext)
+		}
+
+		return blk
+	})
+
+	d := backlog.newSyncing(t, fake, 2)
+
+	cursor, err := syncHistory(d, fake.Head())
+	assert.NoError(t, err)
+	assert.Equal(t, fake.Head(), cursor)
+	backlog.assertSynced(t, d, blocks)
+}
+
+func TestSyncPipelineFetchFailure(t *testing.T) {
+	const (
+		blocks  = 30
+		failing = 17
+	)
+
+	backlog := newTestBacklog(t, blocks, 2)
+	fake := backlog.settle(t, nil)
+
+	errFetch := errors.New("connection reset")
+
+	var (
+		broken   atomic.Bool
+		attempts atomic.Int64
+	)
+
+	broken.Store(true)
+
+	d := backlog.newSyncing(t, &faultyQueryClient{Fake: fake, query: func(_ context.Context, from, to uint64) error {
+		if broken.Load() && from <= failing && failing <= to {
+			attempts.Add(1)
+			return errFetch
+		}
+
+		return nil
+	}}, 3)
+
+	// The sync stops at the page failing to fetch, past its retries, with
+	// the blocks ahead of it written.
+	cursor, err := syncHistory(d, fake.Head())
+	assert.ErrorIs(t, err, errFetch)
+	assert.Equal(t, uint64(16), cursor)
+	assert.Equal(t, int64(maxSyncFetchRetries), attempts.Load())
+	backlog.assertSynced(t, d, 16)
+
+	// Avail back, the sync picks up from there.
+	broken.Store(false)
+
+	cursor, err = syncHistory(d, fake.Head())
+	assert.NoError(t, err)
+	assert.Equal(t, fake.Head(), cursor)
+	backlog.assertSynced(t, d, blocks)
+}
+
+func TestSyncPipelineShutdown(t *testing.T) {
+	const (
+		blocks = 30
+		held   = 16
+	)
+
+	backlog := newTestBacklog(t, blocks, 2)
+	fake := backlog.settle(t, nil)
+
+	// The pages past the held block hang until the pipeline is canceled.
+	canceled := make(chan struct{}, blocks)
+
+	d := backlog.newSyncing(t, &faultyQueryClient{Fake: fake, query: func(ctx context.Context, from, to uint64) error {
+		if to <= held {
+			return nil
+		}
+
+		<-ctx.Done()
+		canceled <- struct{}{}
+
+		return ctx.Err()
+	}}, 3)
+
+	d.stakingNode = staking.NewNode(d.blockchain, d.executor, stakingSender{fake}, d.logger, staking.NodeType(d.nodeType))
+
+	done := make(chan error, 1)
+
+	go func() {
+		_, err := syncHistory(d, fake.Head())
+		done <- err
+	}()
+
+	assert.Eventually(t, func() bool { return d.blockchain.Header().Number == held }, 10*time.Second, 10*time.Millisecond)
+
+	// The node shutting down, the sync returns, and the fetches in flight
+	// are canceled.
+	close(d.closeCh)
+
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("sync not stopped on the shutdown")
+	}
+
+	select {
+	case <-canceled:
+	case <-time.After(10 * time.Second):
+		t.Fatal("fetches in flight not canceled")
+	}
+
+	backlog.assertSynced(t, d, held)
+}
+
+// BenchmarkSyncPipeline measures the sync of a history of 1,000 Avail blocks,
+// each carrying a block of 2 transfers, off a remote Avail answering a page
+// of 100 Avail blocks in 100ms, block by block off the block stream, and
+// through the pipeline of a number of pages in flight.
+func BenchmarkSyncPipeline(b *testing.B) {
+	const blocks = 1000
+
+	backlog := newTestBacklog(b, blocks, 2)
+	fake := backlog.settleOn(b, testutil.NewFake(avail_types.NewUCompactFromUInt(1), testutil.WithQueryLatency(100*time.Millisecond, DefaultCatchUpPageSize)), nil)
+
+	for _, depth := range []uint64{0, 1, 4} {
+		name := fmt.Sprintf("pipeline-%d", depth)
+		if depth == 0 {
+			name = "sequential"
+		}
+
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+
+				d := backlog.newSyncing(b, fake, depth)
+				d.catchUp.PageSize = DefaultCatchUpPageSize
+				d.catchUp.Workers = DefaultCatchUpWorkers
+
+				b.StartTimer()
+
+				if _, err := syncHistory(d, fake.Head()); err != nil {
+					b.Fatal(err)
+				}
+
+				if d.blockchain.Header().Number != blocks {
+					b.Fatalf("synced up to block %d, want %d", d.blockchain.Header().Number, blocks)
+				}
+			}
+
+			b.ReportMetric(float64(blocks*b.N)/b.Elapsed().Seconds(), "blocks/s")
+		})
+	}
+}
@@ -0,0 +1,363 @@
+package avail
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/0xPolygon/polygon-edge/helper/common"
+	"github.com/0xPolygon/polygon-edge/helper/progress"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// syncProgressFeedSize is the number of the sync progress events buffered for
+// each subscriber.
+const syncProgressFeedSize = 64
+
+// SyncStage is the stage of the node syncing the chain from Avail.
+type SyncStage string
+
+const (
+	// SyncStageReplay is the replay of the Avail history on the start, up to
+	// the Avail head.
+	SyncStageReplay SyncStage = "replay"
+
+	// SyncStageCatchUp is the catch-up of the sequencer fallen far behind the
+	// Avail head.
+	SyncStageCatchUp SyncStage = "catch-up"
+
+	// SyncStageLive is the node following the live Avail blocks; it's not
+	// syncing.
+	SyncStageLive SyncStage = "live"
+)
+
+// SyncStatus is the progress of the node syncing the chain from Avail, as
+// returned by `avail_syncing` while syncing, and pushed to the subscribers of
+// the "syncProgress" subscription. StartingBlock, CurrentBlock and HighestBlock are
+// the ones of `eth_syncing`, of the local chain: the head at the start of the
+// stage, the current one, and the one estimated at the Avail head, at the
+// rate of the blocks per Avail block so far.
+type SyncStatus struct {
+	Stage SyncStage `json:"stage"`
+
+	StartingBlock hexutil.Uint64 `json:"startingBlock"`
+	CurrentBlock  hexutil.Uint64 `json:"currentBlock"`
+	HighestBlock  hexutil.Uint64 `json:"highestBlock"`
+
+	// StartingAvailBlock is the last Avail block processed before the
+	// stage, CurrentAvailBlock the last one processed, and
+	// HighestAvailBlock the Avail head the stage syncs to.
+	StartingAvailBlock hexutil.Uint64 `json:"startingAvailBlock"`
+	CurrentAvailBlock  hexutil.Uint64 `json:"currentAvailBlock"`
+	HighestAvailBlock  hexutil.Uint64 `json:"highestAvailBlock"`
+
+	// ProcessedAvailBlocks is the number of the Avail blocks processed in
+	// the stage, out of TotalAvailBlocks, Percent of them.
+	ProcessedAvailBlocks hexutil.Uint64 `json:"processedAvailBlocks"`
+	TotalAvailBlocks     hexutil.Uint64 `json:"totalAvailBlocks"`
+	Percent              float64        `json:"percent"`
+
+	// BlocksPerSecond is the rate of the Avail blocks processed since the
+	// start of the stage, and ETA the time left to the Avail head at it;
+	// zero until known.
+	BlocksPerSecond float64         `json:"blocksPerSecond"`
+	ETA             common.Duration `json:"eta"`
+}
+
+// Syncing reports whether the status is of a node syncing.
+func (s SyncStatus) Syncing() bool {
+	return s.Stage != SyncStageLive
+}
+
+// syncProgress is the progress of the node following the Avail chain, as
+// reported by the status API: the stage of the sync, replaying the Avail
+// history on the start or catching up with the Avail head, and how far it
+// went. The progress is published to the subscribers on every Avail block
+// processed; a subscriber falling syncProgressFeedSize events behind misses
+// the oldest ones, the latest kept. The nil syncProgress tracks nothing.
+type syncProgress struct {
+	catchingUp atomic.Bool
+	cursor     atomic.Uint64
+	head       atomic.Uint64
+
+	// lastSeen is the last Avail block received, syncing or following the
+	// live blocks, and lastSeenAt the Unix time in nanoseconds it was, for the
+	// health check to tell the Avail stream advancing; zero until one is.
+	lastSeen   atomic.Uint64
+	lastSeenAt atomic.Int64
+
+	clock clock // The system clock if nil
+
+	lock         sync.Mutex
+	stage        SyncStage
+	since        time.Time
+	startAvail   uint64
+	currentAvail uint64
+	highestAvail uint64
+	startBlock   uint64
+	currentBlock uint64
+	subs         map[chan SyncStatus]struct{}
+}
+
+func newSyncProgress(clock clock) *syncProgress {
+	return &syncProgress{clock: clock}
+}
+
+// CatchingUp reports whether the node is catching up with the Avail head.
+func (p *syncProgress) CatchingUp() bool {
+	return p.catchingUp.Load()
+}
+
+// begin starts the stage of the sync following the given Avail block,
+// towards the given Avail head, from the given head of the local chain.
+func (p *syncProgress) begin(stage SyncStage, availBlock, availHead, head uint64) {
+	if p == nil {
+		return
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.stage = stage
+	p.since = p.now()
+	p.startAvail, p.currentAvail, p.highestAvail = availBlock, availBlock, availHead
+	p.startBlock, p.currentBlock = head, head
+
+	if p.highestAvail < availBlock {
+		p.highestAvail = availBlock
+	}
+
+	p.publishLocked()
+}
+
+// advance notes the Avail block processed, with the given head of the local
+// chain after it.
+func (p *syncProgress) advance(availBlock, head uint64) {
+	if p == nil {
+		return
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.stage == "" || p.stage == SyncStageLive {
+		return
+	}
+
+	if availBlock < p.currentAvail {
+		return
+	}
+
+	p.currentAvail, p.currentBlock = availBlock, head
+
+	if p.highestAvail < availBlock {
+		p.highestAvail = availBlock
+	}
+
+	p.publishLocked()
+}
+
+// seen notes the Avail block received, syncing or following the live blocks.
+func (p *syncProgress) seen(availBlock uint64) {
+	if p == nil {
+		return
+	}
+
+	p.lastSeen.Store(availBlock)
+	p.lastSeenAt.Store(p.now().UnixNano())
+}
+
+// LastSeen returns the last Avail block received and when; the zero time if
+// none was.
+func (p *syncProgress) LastSeen() (uint64, time.Time) {
+	if p == nil {
+		return 0, time.Time{}
+	}
+
+	at := p.lastSeenAt.Load()
+	if at == 0 {
+		return 0, time.Time{}
+	}
+
+	return p.lastSeen.Load(), time.Unix(0, at)
+}
+
+// target moves the Avail head the stage syncs to up to the given one.
+func (p *syncProgress) target(availHead uint64) {
+	if p == nil {
+		return
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.highestAvail < availHead {
+		p.highestAvail = availHead
+	}
+}
+
+// live ends the sync, with the node following the live Avail blocks from the
+// given head of the local chain.
+func (p *syncProgress) live(head uint64) {
+	if p == nil {
+		return
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.stage == SyncStageLive {
+		return
+	}
+
+	p.stage = SyncStageLive
+	p.currentBlock = head
+
+	p.publishLocked()
+}
+
+// Status returns the progress of the sync; nil while the node isn't
+// syncing.
+func (p *syncProgress) Status() *SyncStatus {
+	if p == nil {
+		return nil
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.stage == "" || p.stage == SyncStageLive {
+		return nil
+	}
+
+	status := p.statusLocked()
+
+	return &status
+}
+
+// Progression returns the progress of the sync for `eth_syncing`; nil while
+// the node isn't syncing.
+func (p *syncProgress) Progression() *progress.Progression {
+	status := p.Status()
+	if status == nil {
+		return nil
+	}
+
+	return &progress.Progression{
+		SyncType:      progress.ChainSyncType(status.Stage),
+		StartingBlock: uint64(status.StartingBlock),
+		CurrentBlock:  uint64(status.CurrentBlock),
+		HighestBlock:  uint64(status.HighestBlock),
+	}
+}
+
+// subscribe returns the channel the progress is sent to from now on, and the
+// function to unsubscribe with.
+func (p *syncProgress) subscribe() (<-chan SyncStatus, func()) {
+	ch := make(chan SyncStatus, syncProgressFeedSize)
+
+	return ch, p.subscribeChan(ch)
+}
+
+// subscribeChan has the progress sent to the given channel from now on, and
+// returns the function to unsubscribe with. The channel is drained of the
+// oldest event when full.
+func (p *syncProgress) subscribeChan(ch chan SyncStatus) func() {
+	if p == nil {
+		return func() {}
+	}
+
+	p.lock.Lock()
+	if p.subs == nil {
+		p.subs = make(map[chan SyncStatus]struct{})
+	}
+
+	p.subs[ch] = struct{}{}
+	p.lock.Unlock()
+
+	return func() {
+		p.lock.Lock()
+		delete(p.subs, ch)
+		p.lock.Unlock()
+	}
+}
+
+// publishLocked sends the progress to the subscribers. The lock is held.
+func (p *syncProgress) publishLocked() {
+	if len(p.subs) == 0 {
+		return
+	}
+
+	status := p.statusLocked()
+
+	for ch := range p.subs {
+		select {
+		case ch <- status:
+			continue
+		default:
+		}
+
+		// The subscriber fell behind; the oldest event makes room for the
+		// latest.
+		select {
+		case <-ch:
+		default:
+		}
+
+		select {
+		case ch <- status:
+		default:
+		}
+	}
+}
+
+// statusLocked returns the progress of the sync. The lock is held.
+func (p *syncProgress) statusLocked() SyncStatus {
+	status := SyncStatus{
+		Stage:              p.stage,
+		StartingBlock:      hexutil.Uint64(p.startBlock),
+		CurrentBlock:       hexutil.Uint64(p.currentBlock),
+		HighestBlock:       hexutil.Uint64(p.currentBlock),
+		StartingAvailBlock: hexutil.Uint64(p.startAvail),
+		CurrentAvailBlock:  hexutil.Uint64(p.currentAvail),
+		HighestAvailBlock:  hexutil.Uint64(p.highestAvail),
+		Percent:            100,
+	}
+
+	if p.stage == SyncStageLive {
+		return status
+	}
+
+	processed, total := p.currentAvail-p.startAvail, p.highestAvail-p.startAvail
+	status.ProcessedAvailBlocks, status.TotalAvailBlocks = hexutil.Uint64(processed), hexutil.Uint64(total)
+
+	if total > 0 {
+		status.Percent = float64(processed) * 100 / float64(total)
+	}
+
+	if processed == 0 {
+		return status
+	}
+
+	// The blocks left are estimated at the rate of the blocks per Avail
+	// block so far.
+	left := total - processed
+	if p.currentBlock > p.startBlock {
+		status.HighestBlock += hexutil.Uint64(left * (p.currentBlock - p.startBlock) / processed)
+	}
+
+	if elapsed := p.now().Sub(p.since); elapsed > 0 {
+		status.BlocksPerSecond = float64(processed) / elapsed.Seconds()
+		status.ETA = common.Duration{Duration: time.Duration(float64(left) / status.BlocksPerSecond * float64(time.Second))}
+	}
+
+	return status
+}
+
+func (p *syncProgress) now() time.Time {
+	if p.clock == nil {
+		return time.Now()
+	}
+
+	return p.clock.Now()
+}
@@ -0,0 +1,174 @@
+package avail
+
+import (
+	"testing"
+	"time"
+
+	"github.com/availproject/op-evm/pkg/avail/testutil"
+	"github.com/availproject/op-evm/pkg/test"
+	avail_types "github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// drainSyncProgress returns the sync progress events buffered on the channel.
+func drainSyncProgress(events <-chan SyncStatus) []SyncStatus {
+	var statuses []SyncStatus
+
+	for {
+		select {
+		case s := <-events:
+			statuses = append(statuses, s)
+		default:
+			return statuses
+		}
+	}
+}
+
+func TestSyncProgress(t *testing.T) {
+	clock := newFakeClock(time.Unix(0, 0))
+	p := newSyncProgress(clock)
+
+	assert.Nil(t, p.Status())
+
+	events, unsubscribe := p.subscribe()
+	defer unsubscribe()
+
+	// The backlog of 100 Avail blocks past the Avail block 20, a block in
+	// every tenth, replayed at an Avail block per second.
+	p.begin(SyncStageReplay, 20, 120, 2)
+
+	for i := uint64(1); i <= 100; i++ {
+		clock.advance(time.Second)
+		p.advance(20+i, 2+i/10)
+
+		switch i {
+		case 25:
+			status := p.Status()
+			if assert.NotNil(t, status) {
+				assert.Equal(t, SyncStageReplay, status.Stage)
+				assert.Equal(t, 25.0, status.Percent)
+				assert.Equal(t, hexutil.Uint64(25), status.ProcessedAvailBlocks)
+				assert.Equal(t, hexutil.Uint64(100), status.TotalAvailBlocks)
+				assert.Equal(t, hexutil.Uint64(2), status.StartingBlock)
+				assert.Equal(t, hexutil.Uint64(4), status.CurrentBlock)
+				assert.Equal(t, hexutil.Uint64(10), status.HighestBlock)
+				assert.Equal(t, 1.0, status.BlocksPerSecond)
+				assert.Equal(t, 75*time.Second, status.ETA.Duration)
+			}
+
+		case 50:
+			// The Avail head moving on is synced to as well.
+			p.target(170)
+
+			status := p.Status()
+			if assert.NotNil(t, status) {
+				assert.InDelta(t, 100.0/3, status.Percent, 0.01)
+				assert.Equal(t, hexutil.Uint64(170), status.HighestAvailBlock)
+				assert.Equal(t, 100*time.Second, status.ETA.Duration)
+			}
+
+			progression := p.Progression()
+			if assert.NotNil(t, progression) {
+				assert.Equal(t, uint64(7), progression.CurrentBlock)
+				assert.Equal(t, uint64(17), progression.HighestBlock)
+			}
+		}
+	}
+
+	// The Avail blocks older than the ones processed are ignored.
+	p.advance(30, 3)
+	assert.Equal(t, hexutil.Uint64(120), p.Status().CurrentAvailBlock)
+	assert.InDelta(t, 100.0*2/3, p.Status().Percent, 0.01)
+
+	p.live(12)
+
+	assert.Nil(t, p.Status())
+	assert.Nil(t, p.Progression())
+
+	// The subscriber fell behind: the oldest events were dropped for the
+	// latest, ending with the live one. The percentage goes up past the move
+	// of the Avail head.
+	statuses := drainSyncProgress(events)
+	if assert.Len(t, statuses, syncProgressFeedSize) {
+		assert.Equal(t, hexutil.Uint64(120-(syncProgressFeedSize-2)), statuses[0].CurrentAvailBlock)
+
+		for i := 1; i < len(statuses)-1; i++ {
+			if statuses[i-1].HighestAvailBlock == statuses[i].HighestAvailBlock {
+				assert.Less(t, statuses[i-1].Percent, statuses[i].Percent)
+			}
+		}
+
+		last := statuses[len(statuses)-1]
+		assert.False(t, last.Syncing())
+		assert.Equal(t, hexutil.Uint64(12), last.CurrentBlock)
+	}
+
+	// Nothing is tracked past the sync.
+	p.advance(121, 13)
+	assert.Nil(t, p.Status())
+	assert.Empty(t, drainSyncProgress(events))
+}
+
+func TestSyncProgressOfReplay(t *testing.T) {
+	appID := avail_types.NewUCompactFromUInt(1)
+	fake := testutil.NewFake(appID)
+
+	blkA, _, childA := settleTestBlocks(t, fake)
+
+	// The backlog runs on past the blocks settled.
+	for i := 0; i < 20; i++ {
+		fake.Produce()
+	}
+
+	db, err := test.NewMemoryStorage()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := newTestSyncingAvail(t, fake, appID, db)
+	d.progress = newSyncProgress(systemClock{})
+
+	events := make(chan SyncStatus, fake.Head()+2)
+	d.progress.subscribeChan(events)
+
+	syncing, err := NewStatusAPI(d).Syncing()
+	assert.NoError(t, err)
+	assert.Equal(t, false, syncing)
+
+	if _, err := syncTestAvail(d, fake); err != nil {
+		t.Fatal(err)
+	}
+
+	statuses := drainSyncProgress(events)
+	if assert.NotEmpty(t, statuses) {
+		replay, last := statuses[:len(statuses)-1], statuses[len(statuses)-1]
+
+		for i, status := range replay {
+			assert.Equal(t, SyncStageReplay, status.Stage)
+			assert.Equal(t, hexutil.Uint64(fake.Head()), status.HighestAvailBlock)
+
+			if i > 0 {
+				assert.Greater(t, status.Percent, replay[i-1].Percent)
+			}
+		}
+
+		if assert.NotEmpty(t, replay) {
+			assert.Equal(t, 0.0, replay[0].Percent)
+			assert.Equal(t, 100.0, replay[len(replay)-1].Percent)
+			assert.Equal(t, hexutil.Uint64(childA.Number()), replay[len(replay)-1].CurrentBlock)
+		}
+
+		assert.Equal(t, SyncStageLive, last.Stage)
+		assert.Equal(t, hexutil.Uint64(childA.Number()), last.CurrentBlock)
+	}
+
+	// The node synced reports not syncing.
+	assert.Equal(t, blkA.Hash(), d.blockchain.Header().ParentHash)
+	assert.Nil(t, d.Status().Sync)
+	assert.Nil(t, d.GetSyncProgression())
+
+	syncing, err = NewStatusAPI(d).Syncing()
+	assert.NoError(t, err)
+	assert.Equal(t, false, syncing)
+}
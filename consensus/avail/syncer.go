@@ -1,6 +1,9 @@
 package avail
 
 import (
+	"context"
+
+	"github.com/0xPolygon/polygon-edge/types"
 	"github.com/availproject/op-evm/consensus/avail/validator"
 	"github.com/availproject/op-evm/pkg/avail"
 	avail_types "github.com/centrifuge/go-substrate-rpc-client/v4/types"
@@ -13,17 +16,31 @@ import (
 func (d *Avail) getNextAvailBlockNumber() uint64 {
 	head := d.blockchain.Header()
 
+	// The replay cut short by a restart picks up from its checkpoint.
+	if cursor := d.replay.resume(d.blockchain, d.executor); cursor > 0 {
+		return cursor
+	}
+
 	/// We have new blockchain. Allow syncing from last to 1st block
 	if head.Number == 0 {
 		return 1
 	}
 
-	callIdx, err := avail.FindCallIndex(d.availClient)
-	if err != nil {
+	// The head of the chain snapshot imported is known to be included at
+	// its Avail block.
+	if d.forkChoice != nil {
+		if settled := d.forkChoice.settled.Head(); settled.Hash == head.Hash && settled.AvailBlock > 0 {
+			return settled.AvailBlock
+		}
+	}
+
+	if _, err := avail.FindCallIndex(d.ctx, d.availClient); err != nil {
 		return 0
 	}
 
-	blk, err := d.availClient.SearchBlock(0, d.syncFunc(int64(head.Number), callIdx))
+	decoder := avail.NewBlockDecoder(d.availClient, d.availAppID, d.logger)
+
+	blk, err := d.availClient.SearchBlock(d.ctx, 0, d.syncFunc(int64(head.Number), decoder))
 	if err != nil {
 		d.logger.Error("failure to sync node", "error", err)
 		return 0
@@ -37,7 +54,7 @@ func (d *Avail) getNextAvailBlockNumber() uint64 {
 // the local node until it catches up to this block. In case of any error, it
 // logs the error message and returns the error.
 func (d *Avail) syncNode() (uint64, error) {
-	hdr, err := d.availClient.GetLatestHeader()
+	hdr, err := d.availClient.GetLatestHeader(d.ctx)
 	if err != nil {
 		d.logger.Error("couldn't fetch latest block hash from Avail", "error", err)
 		return 0, err
@@ -54,29 +71,114 @@ func (d *Avail) syncNode() (uint64, error) {
 // syncNodeUntil synchronizes the local node with the Avail chain until a
 // specified condition is met. It fetches the Avail blocks and validates
 // and writes them to the local blockchain. It continues this process until
-// the provided stopConditionFn function returns true. In case of any error,
-// it returns the number of the next Avail block to be fetched along with the error.
+// the provided stopConditionFn function returns true. The Avail history up to
+// the head at the start goes through the syncPipeline, under a pipeline
+// depth, and the blocks past it come off the block stream. The blocks already
+// in the chain are skipped and the conflicting ones left to the fork choice;
+// only the storage failing a write, or the history failing to fetch, stops
+// the syncing. In case of any error, it returns the number of the next Avail
+// block to be fetched along with the error.
 func (d *Avail) syncNodeUntil(stopConditionFn func(blk *avail_types.SignedBlock) bool) (uint64, error) {
 	availNextBlockNumber := d.getNextAvailBlockNumber()
 
-	callIdx, err := avail.FindCallIndex(d.availClient)
-	if err != nil {
+	defer d.replay.flush()
+
+	if _, err := avail.FindCallIndex(d.ctx, d.availClient); err != nil {
 		return availNextBlockNumber, err
 	}
 
-	fraudResolver := NewFraudResolver(d.logger, d.blockchain, d.executor, d.txpool, nil, nil, d.minerAddr, d.signKey, d.availSender, d.nodeType)
-	validator := validator.New(d.blockchain, d.minerAddr, d.logger)
+	decoder := avail.NewBlockDecoder(d.availClient, d.availAppID, d.logger)
+
+	// The replay is tracked towards the Avail head at its start, moving on
+	// with the blocks coming in past it.
+	var availHead, availLast uint64
+	if hdr, err := d.availClient.GetLatestHeader(d.ctx); err == nil {
+		availHead = uint64(hdr.Number)
+	}
+
+	if availNextBlockNumber > 0 {
+		availLast = availNextBlockNumber - 1
+	}
+
+	d.progress.begin(SyncStageReplay, availLast, availHead, d.blockchain.Header().Number)
+
+	fraudResolver := NewFraudResolver(d.ctx, d.logger, d.blockchain, d.executor, d.txpool, d.poolIndex, nil, nil, d.frauds, d.disputeFeed, nil, d.minerAddr, d.signKey, d.availSender, d.fraudTip, d.fraudQuorum, d.nodeType)
+	validator := validator.New(d.blockchain, d.minerAddr, d.logger, validator.WithMaxTimestampDrift(d.production.MaxTimestampDrift))
+
+	ctx, cancel := context.WithCancel(d.ctx)
+	defer cancel()
 
-	// BlockStream watcher must be started after the staking is done. Otherwise
-	// the stream is out-of-sync.
-	availBlockStream := d.availClient.BlockStream(availNextBlockNumber)
-	defer availBlockStream.Close()
+	// The history goes through the pipeline, the block stream opened once
+	// it's drained; the stream opened right away without it.
+	var (
+		pipeline *syncPipeline
+		prepared <-chan *preparedBlock
+	)
+
+	if d.catchUp.PipelineDepth > 0 && availNextBlockNumber > 0 && availNextBlockNumber <= availHead {
+		pipeline = newSyncPipeline(d.availClient, d.catchUp)
+		prepared = pipeline.run(ctx, d.blockchain, decoder, availNextBlockNumber, availHead)
+
+		d.logger.Info("syncing the Avail history", "from_avail_block", availNextBlockNumber, "avail_head", availHead, "pipeline_depth", d.catchUp.PipelineDepth)
+	}
+
+	var (
+		availBlockStream avail.BlockStream
+		availBlockCh     <-chan *avail_types.SignedBlock
+	)
+
+	defer func() {
+		if availBlockStream != nil {
+			availBlockStream.Close()
+		}
+	}()
+
+	openBlockStream := func(offset uint64) {
+		// BlockStream watcher must be started after the staking is done.
+		// Otherwise the stream is out-of-sync.
+		availBlockStream = d.availClient.BlockStream(ctx, offset)
+
+		// The stream channel is closed once the run context is canceled;
+		// the shutdown itself is handled on the close channel.
+		availBlockCh = availBlockStream.Chan()
+	}
+
+	if prepared == nil {
+		openBlockStream(availNextBlockNumber)
+	}
 
 	for {
-		var blk *avail_types.SignedBlock
+		var (
+			blk      *avail_types.SignedBlock
+			edgeBlks []avail.EdgeBlock
+			err      error
+		)
 
 		select {
-		case blk = <-availBlockStream.Chan():
+		case p, ok := <-prepared:
+			if !ok {
+				prepared = nil
+
+				if err := pipeline.Err(); err != nil {
+					d.logger.Error("failed to sync the Avail history", "avail_block", availNextBlockNumber, "error", err)
+					return availNextBlockNumber, err
+				}
+
+				openBlockStream(availNextBlockNumber + 1)
+				continue
+			}
+
+			blk = p.SignedBlock
+			edgeBlks, err = p.edgeBlocks(decoder)
+
+		case b, ok := <-availBlockCh:
+			if !ok {
+				availBlockCh = nil
+				continue
+			}
+
+			blk = b
+			edgeBlks, err = decoder.Decode(d.ctx, blk)
 
 		case <-d.closeCh:
 			if err := d.stakingNode.UnStake(d.signKey); err != nil {
@@ -86,30 +188,29 @@ func (d *Avail) syncNodeUntil(stopConditionFn func(blk *avail_types.SignedBlock)
 			return 0, nil
 		}
 
-		edgeBlks, err := avail.BlockFromAvail(blk, d.availAppID, callIdx, d.logger)
-		if len(edgeBlks) == 0 && err != nil {
-			if err != avail.ErrNoExtrinsicFound {
-				d.logger.Warn("unexpected error while extracting OpEVM blocks from Avail block", "error", err)
-				continue
-			}
+		// The Avail block failing to decode is passed over, the sync going
+		// on with the ones past it.
+		if len(edgeBlks) == 0 && err != nil && err != avail.ErrNoExtrinsicFound {
+			d.logger.Warn("unexpected error while extracting OpEVM blocks from Avail block", "block_number", blk.Block.Header.Number, "error", err)
 		}
 
 		// Write down blocks received from avail to make sure we're synced before processing with the
 		// fraud check or writing down new blocks...
-		for _, edgeBlk := range edgeBlks {
-			if !fraudResolver.IsFraudProofBlock(edgeBlk) {
+		for _, decoded := range edgeBlks {
+			edgeBlk := decoded.Block
+
+			if !fraudResolver.IsFraudProofBlock(edgeBlk) && !fraudResolver.IsDisputeDefenseBlock(edgeBlk) {
 				if err := validator.Check(edgeBlk); err == nil {
-					if err := d.blockchain.WriteBlock(edgeBlk, d.nodeType.String()); err != nil {
-						d.logger.Warn(
-							"failed to write edge block received from avail",
-							"edge_block_hash", edgeBlk.Hash(),
-							"error", err,
-						)
+					// The leader schedule is yet to be followed while syncing.
+					if _, err := writeAvailBlock(d.forkChoice, d.breaker, decoded, inclusionOf(blk, decoded, types.ZeroAddress), d.nodeType.String(), d.logger); err != nil {
+						return availNextBlockNumber, err
 					}
 				} else {
 					d.logger.Warn(
 						"failed to validate edge block received from avail",
 						"edge_block_hash", edgeBlk.Hash(),
+						"extrinsic_index", decoded.ExtrinsicIndex,
+						"submitter", decoded.Submitter.ToHexString(),
 						"error", err,
 					)
 				}
@@ -117,6 +218,9 @@ func (d *Avail) syncNodeUntil(stopConditionFn func(blk *avail_types.SignedBlock)
 		}
 
 		availNextBlockNumber = uint64(blk.Block.Header.Number)
+		d.replay.processed(availNextBlockNumber, d.blockchain.Header())
+		d.progress.advance(availNextBlockNumber, d.blockchain.Header().Number)
+		d.progress.seen(availNextBlockNumber)
 
 		// Stop syncing when stopCondition is met.
 		if stopConditionFn(blk) {
@@ -124,6 +228,9 @@ func (d *Avail) syncNodeUntil(stopConditionFn func(blk *avail_types.SignedBlock)
 		}
 	}
 
+	d.replay.complete()
+	d.progress.live(d.blockchain.Header().Number)
+
 	return availNextBlockNumber, nil
 }
 
@@ -133,9 +240,9 @@ func (d *Avail) syncNodeUntil(stopConditionFn func(blk *avail_types.SignedBlock)
 // each Edge block found. It then returns the smallest offset and a boolean
 // indicating whether the target Edge block was found in the Avail block. In
 // case of any error during the process, it returns the error.
-func (d *Avail) syncFunc(targetEdgeBlock int64, callIdx avail_types.CallIndex) avail.SearchFunc {
+func (d *Avail) syncFunc(targetEdgeBlock int64, decoder *avail.BlockDecoder) avail.SearchFunc {
 	return func(availBlk *avail_types.SignedBlock) (int64, bool, error) {
-		blks, err := avail.BlockFromAvail(availBlk, d.availAppID, callIdx, d.logger)
+		blks, err := decoder.Decode(d.ctx, availBlk)
 		if err != nil && err != avail.ErrNoExtrinsicFound {
 			return -1, false, err
 		}
@@ -143,21 +250,21 @@ func (d *Avail) syncFunc(targetEdgeBlock int64, callIdx avail_types.CallIndex) a
 		if blks == nil || len(blks) < 1 {
 			return -1, false, nil
 		}
-		availBlockNum := int64(availBlk.Block.Header.Number)
 
 		// Compute Avail block offsets for all the Edge blocks we can find from the
-		// current Avail block extrinsincs.
+		// current Avail block extrinsincs. The offsets are relative to the
+		// current Avail block, as SearchBlock moves by them.
 		offsets := []int64{}
 		for _, blk := range blks {
 			edgeBlockNum := int64(blk.Header.Number)
 
 			switch {
 			case edgeBlockNum > targetEdgeBlock:
-				offsets = append(offsets, availBlockNum-(edgeBlockNum-targetEdgeBlock))
+				offsets = append(offsets, -(edgeBlockNum - targetEdgeBlock))
 			case edgeBlockNum == targetEdgeBlock:
-				return int64(availBlk.Block.Header.Number), true, nil
+				return 0, true, nil
 			case edgeBlockNum < targetEdgeBlock:
-				offsets = append(offsets, availBlockNum+(targetEdgeBlock-edgeBlockNum))
+				offsets = append(offsets, targetEdgeBlock-edgeBlockNum)
 			}
 		}
 
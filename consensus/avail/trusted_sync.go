@@ -0,0 +1,114 @@
+package avail
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/pkg/snapshot"
+)
+
+// TrustedSyncConfig configures the trusted-root fast sync: the operator
+// trusts the state root at a height, such as the one of a publicly attested
+// checkpoint, and the replay of the Avail history checks the blocks at or
+// below it for their structure and the signatures of their producers only,
+// short of executing them. The block at the height is refused unless it has
+// the trusted state root; the state at it comes from the chain snapshot at
+// the height, and the blocks past it are checked in full.
+type TrustedSyncConfig struct {
+	// Height is the trusted height; zero disables the fast sync.
+	Height uint64
+
+	// StateRoot is the state root trusted at the height.
+	StateRoot types.Hash
+
+	// Snapshot is the path of the chain snapshot at the height the state is
+	// taken from, unless the node holds it already.
+	Snapshot string
+}
+
+// Enabled reports whether the trusted-root fast sync is configured.
+func (c TrustedSyncConfig) Enabled() bool {
+	return c.Height > 0
+}
+
+// TrustedSyncStatus is the trusted-root fast sync of the node, as reported by
+// `avail_getNodeStatus`, for the trust the chain of the node rests on to be
+// audited.
+type TrustedSyncStatus struct {
+	Height    uint64     `json:"height"`
+	StateRoot types.Hash `json:"stateRoot"`
+
+	// Reached is set once the block at the trusted height is in the chain;
+	// the blocks past it are checked in full.
+	Reached bool `json:"reached"`
+}
+
+// applyTrustedState writes the state at the trusted state root to the state
+// storage, out of the chain snapshot at the trusted height, unless the
+// storage holds it already. The snapshot is checked to chain up from the
+// genesis of the node to the trusted height, and its state to hash to the
+// trusted root.
+func (d *Avail) applyTrustedState() error {
+	if !d.trusted.Enabled() || d.trusted.StateRoot == types.EmptyRootHash {
+		return nil
+	}
+
+	if _, ok := d.stateStorage.Get(d.trusted.StateRoot.Bytes()); ok {
+		return nil
+	}
+
+	if d.trusted.Snapshot == "" {
+		return fmt.Errorf("state at the trusted root %s not held, and no snapshot to take it from", d.trusted.StateRoot)
+	}
+
+	f, err := os.Open(d.trusted.Snapshot)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	cs, err := snapshot.ReadChainSnapshot(f)
+	if err != nil {
+		return fmt.Errorf("failed to decode the trusted snapshot: %w", err)
+	}
+
+	headers, err := cs.DecodeHeaders()
+	if err != nil {
+		return err
+	}
+
+	if genesis := d.blockchain.Genesis(); headers[0].Hash != genesis {
+		return fmt.Errorf("trusted snapshot of genesis %s, expected %s", headers[0].Hash, genesis)
+	}
+
+	last := headers[len(headers)-1]
+	if last.Number != d.trusted.Height || last.StateRoot != d.trusted.StateRoot {
+		return fmt.Errorf("trusted snapshot at block %d of state root %s, expected block %d of %s", last.Number, last.StateRoot, d.trusted.Height, d.trusted.StateRoot)
+	}
+
+	if err := cs.ApplyState(d.trusted.StateRoot, d.stateStorage); err != nil {
+		return err
+	}
+
+	d.logger.Info("trusted state applied", "trusted_height", d.trusted.Height, "trusted_state_root", d.trusted.StateRoot)
+
+	return nil
+}
+
+// trustedSyncStatus returns the status of the trusted-root fast sync, if
+// enabled.
+func (d *Avail) trustedSyncStatus() *TrustedSyncStatus {
+	if !d.trusted.Enabled() {
+		return nil
+	}
+
+	status := &TrustedSyncStatus{Height: d.trusted.Height, StateRoot: d.trusted.StateRoot}
+
+	if h, ok := d.blockchain.GetHeaderByNumber(d.trusted.Height); ok {
+		status.Reached = h.StateRoot == d.trusted.StateRoot
+	}
+
+	return status
+}
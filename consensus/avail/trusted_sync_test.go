@@ -0,0 +1,142 @@
+package avail
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/availproject/op-evm/pkg/avail/testutil"
+	"github.com/availproject/op-evm/pkg/blockchain"
+	avail_types "github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestTrustedAvail returns the consensus of a node syncing the chain from
+// the fake Avail under the trusted-root fast sync of the given config.
+func newTestTrustedAvail(t *testing.T, fake *testutil.Fake, appID avail_types.UCompact, config TrustedSyncConfig) *Avail {
+	t.Helper()
+
+	d := newTestSnapshotAvail(t, fake, appID, 2)
+	d.trusted = config
+	d.blockchain.SetTrustedRoot(config.Height, config.StateRoot)
+
+	return d
+}
+
+func TestTrustedSync(t *testing.T) {
+	appID := avail_types.NewUCompactFromUInt(1)
+	fake := testutil.NewFake(appID)
+
+	producer := newTestGenesisAvail(t)
+	activity := newTestActivity(producer, fake)
+	activity.settle(t, 6)
+
+	// The snapshot at the trusted height is taken from a node past it.
+	source := newTestSnapshotAvail(t, fake, appID, 2)
+	followTestAvail(t, source, fake)
+
+	path := filepath.Join(t.TempDir(), "snapshot")
+
+	trusted, err := NewAdminAPI(source).ExportSnapshot(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	trustedHeader, _ := source.blockchain.GetHeaderByNumber(trusted.Number)
+
+	activity.settle(t, 3)
+
+	// The node verifying the history in full, and the fast-synced one, come
+	// to the same head over the same history.
+	verified := newTestSnapshotAvail(t, fake, appID, 2)
+	if _, err := syncTestAvail(verified, fake); err != nil {
+		t.Fatal(err)
+	}
+
+	config := TrustedSyncConfig{Height: trusted.Number, StateRoot: trustedHeader.StateRoot, Snapshot: path}
+	fast := newTestTrustedAvail(t, fake, appID, config)
+
+	assert.False(t, fast.Status().TrustedSync.Reached)
+
+	if err := fast.applyTrustedState(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := syncTestAvail(fast, fake); err != nil {
+		t.Fatal(err)
+	}
+
+	head := producer.blockchain.Header()
+	assert.Equal(t, head.Hash, verified.blockchain.Header().Hash)
+	assert.Equal(t, head.Hash, fast.blockchain.Header().Hash)
+	assert.Equal(t, verified.blockchain.Header().StateRoot, fast.blockchain.Header().StateRoot)
+	assert.Equal(t, activity.storage(t, verified), activity.storage(t, fast))
+
+	// The blocks at or below the trusted height weren't executed, the ones
+	// past it were.
+	for number := uint64(1); number <= head.Number; number++ {
+		h, _ := fast.blockchain.GetHeaderByNumber(number)
+
+		receipts, err := fast.blockchain.GetReceiptsByHash(h.Hash)
+		if number <= trusted.Number {
+			assert.Empty(t, receipts, "receipts of block %d", number)
+		} else {
+			assert.NoError(t, err)
+			assert.NotEmpty(t, receipts, "receipts of block %d", number)
+		}
+	}
+
+	status := fast.Status().TrustedSync
+	if assert.NotNil(t, status) {
+		assert.Equal(t, TrustedSyncStatus{Height: trusted.Number, StateRoot: trustedHeader.StateRoot, Reached: true}, *status)
+	}
+
+	assert.Nil(t, verified.Status().TrustedSync)
+
+	// The state held already is kept on the restart.
+	fast.trusted.Snapshot = ""
+	assert.NoError(t, fast.applyTrustedState())
+}
+
+func TestTrustedSyncRefusesUntrustedRoot(t *testing.T) {
+	appID := avail_types.NewUCompactFromUInt(1)
+	fake := testutil.NewFake(appID)
+
+	producer := newTestGenesisAvail(t)
+	activity := newTestActivity(producer, fake)
+	activity.settle(t, 6)
+
+	source := newTestSnapshotAvail(t, fake, appID, 2)
+	followTestAvail(t, source, fake)
+
+	path := filepath.Join(t.TempDir(), "snapshot")
+
+	trusted, err := NewAdminAPI(source).ExportSnapshot(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The state root of the block below is no ground for the state at the
+	// trusted height.
+	wrong, _ := source.blockchain.GetHeaderByNumber(trusted.Number - 1)
+	config := TrustedSyncConfig{Height: trusted.Number, StateRoot: wrong.StateRoot, Snapshot: path}
+
+	d := newTestTrustedAvail(t, fake, appID, config)
+	assert.Error(t, d.applyTrustedState())
+
+	// The block at the trusted height is refused, and the chain stops short
+	// of it.
+	if _, err := syncTestAvail(d, fake); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, trusted.Number-1, d.blockchain.Header().Number)
+	assert.False(t, d.Status().TrustedSync.Reached)
+
+	blk, _ := source.blockchain.GetBlockByNumber(trusted.Number, true)
+	assert.True(t, errors.Is(d.blockchain.WriteBlock(blk, "test"), blockchain.ErrUntrustedStateRoot))
+
+	// No trusted state comes without the snapshot.
+	d.trusted.Snapshot = ""
+	assert.Error(t, d.applyTrustedState())
+}
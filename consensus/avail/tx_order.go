@@ -0,0 +1,367 @@
+package avail
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/0xPolygon/polygon-edge/txpool"
+	"github.com/0xPolygon/polygon-edge/txpool/proto"
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/pkg/block"
+	"github.com/availproject/op-evm/pkg/staking"
+	"github.com/hashicorp/go-hclog"
+	"google.golang.org/grpc"
+)
+
+// TxOrdering determines the order the sequencers include the pending
+// transactions in. The transactions of a sender are always included in nonce
+// order, and the system transactions, dispute resolution ones first, come
+// before all the others.
+type TxOrdering string
+
+const (
+	// TxOrderingPrice includes the transactions by their effective gas tip,
+	// highest first, as block.EffectiveTip has it.
+	TxOrderingPrice TxOrdering = "price"
+
+	// TxOrderingFIFO includes the transactions in the order they arrived in the pool.
+	TxOrderingFIFO TxOrdering = "fifo"
+)
+
+// ParseTxOrdering parses the transaction ordering from its string representation.
+func ParseTxOrdering(ordering string) (TxOrdering, error) {
+	switch TxOrdering(ordering) {
+	case TxOrderingPrice, TxOrderingFIFO:
+		return TxOrdering(ordering), nil
+	default:
+		return "", fmt.Errorf("invalid tx ordering: %q", ordering)
+	}
+}
+
+// isSystemTx reports whether the transaction is a system one, i.e. a staking
+// or dispute resolution call of the staking contract signed by one of the
+// node's own keys. The calls of the staking contract by any other sender are
+// user transactions.
+func isSystemTx(tx *types.Transaction, own func(types.Address) bool) bool {
+	return tx.To != nil && *tx.To == staking.AddrStakingContract && own(tx.From)
+}
+
+// txKinds tells the kinds of the pending transactions of a block apart. The
+// dispute resolution transactions are the ones of the node, or of a staked
+// participant; the same calls of any other sender get neither the gas held
+// back for the disputes nor the inclusion ahead of the rest, and count as
+// user transactions. The stakes are looked up once per sender.
+type txKinds struct {
+	own    func(types.Address) bool
+	apq    staking.ActiveParticipants
+	logger hclog.Logger
+	staked map[types.Address]bool
+}
+
+// newTxKinds returns the txKinds of the next block of the sequencer.
+func (sw *SequencerWorker) newTxKinds() *txKinds {
+	return &txKinds{
+		own:    sw.keys.owns,
+		apq:    sw.apq,
+		logger: sw.logger,
+		staked: make(map[types.Address]bool),
+	}
+}
+
+// dispute reports whether the transaction is a dispute resolution one of the
+// node or of a staked participant.
+func (k *txKinds) dispute(tx *types.Transaction) bool {
+	if !staking.IsDisputeResolutionTx(tx) {
+		return false
+	}
+
+	if k.own(tx.From) {
+		return true
+	}
+
+	staked, ok := k.staked[tx.From]
+	if !ok {
+		staked = k.isStaked(tx.From)
+		k.staked[tx.From] = staked
+	}
+
+	return staked
+}
+
+// isStaked reports whether the address is of a staked watchtower or
+// sequencer; the ones failing to be looked up are taken for unstaked.
+func (k *txKinds) isStaked(addr types.Address) bool {
+	if k.apq == nil {
+		return false
+	}
+
+	for _, nodeType := range []staking.NodeType{staking.WatchTower, staking.Sequencer} {
+		staked, err := k.apq.Contains(addr, nodeType)
+		if err != nil {
+			k.logger.Debug("failed to check the stake of the dispute resolution transaction sender", "from", addr, "error", err)
+			return false
+		}
+
+		if staked {
+			return true
+		}
+	}
+
+	return false
+}
+
+// rank ranks the transaction by the kind: the dispute resolution ones go
+// first, then the rest of the system ones, and the user ones last.
+func (k *txKinds) rank(tx *types.Transaction) int {
+	switch {
+	case k.dispute(tx):
+		return 0
+	case isSystemTx(tx, k.own):
+		return 1
+	default:
+		return 2
+	}
+}
+
+// txArrivals tracks the order the transactions arrived in the pool.
+type txArrivals struct {
+	lock sync.Mutex
+	next uint64
+	seq  map[types.Hash]uint64
+}
+
+func newTxArrivals() *txArrivals {
+	return &txArrivals{seq: make(map[types.Hash]uint64)}
+}
+
+// arrived returns the arrival sequence number of the transaction. The ones
+// that haven't been reported yet are taken as arriving now.
+func (a *txArrivals) arrived(hash types.Hash) uint64 {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if seq, ok := a.seq[hash]; ok {
+		return seq
+	}
+
+	seq := a.next
+	a.seq[hash] = seq
+	a.next++
+
+	return seq
+}
+
+// mark returns the sequence number the next arrival gets.
+func (a *txArrivals) mark() uint64 {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	return a.next
+}
+
+// prune forgets the transactions that arrived before the mark and are no
+// longer pending.
+func (a *txArrivals) prune(mark uint64, pending func(types.Hash) bool) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	for hash, seq := range a.seq {
+		if seq < mark && !pending(hash) {
+			delete(a.seq, hash)
+		}
+	}
+}
+
+// len returns the number of transactions tracked.
+func (a *txArrivals) len() int {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	return len(a.seq)
+}
+
+// track records the transactions added to the pool until the context is done.
+func (a *txArrivals) track(ctx context.Context, pool *txpool.TxPool) error {
+	return pool.Subscribe(
+		&proto.SubscribeRequest{Types: []proto.EventType{proto.EventType_ADDED}},
+		&txEventStream{ctx: ctx, send: func(ev *proto.TxPoolEvent) {
+			a.arrived(types.StringToHash(ev.TxHash))
+		}},
+	)
+}
+
+// txEventStream receives the events of the pool subscription in-process.
+// The optional started is called as the subscription asks for the context,
+// once it's in place.
+type txEventStream struct {
+	grpc.ServerStream
+
+	ctx     context.Context
+	send    func(*proto.TxPoolEvent)
+	started func()
+}
+
+func (s *txEventStream) Send(ev *proto.TxPoolEvent) error {
+	s.send(ev)
+	return nil
+}
+
+func (s *txEventStream) Context() context.Context {
+	if s.started != nil {
+		s.started()
+	}
+
+	return s.ctx
+}
+
+// txQueue hands out the promoted transactions of the pool in the inclusion
+// order. The pool's own executables queue is drained of the next
+// transactions of the senders up front, for them to be ordered here; the
+// txpool hands out the next one of a sender once the one before it is
+// popped, under its lock.
+type txQueue struct {
+	heads txHeap
+	pool  *txpool.TxPool
+}
+
+// newTxQueue orders the next transactions of the senders of the pool,
+// prepared for the given base fee.
+func newTxQueue(pool *txpool.TxPool, baseFee uint64, less func(a, b *types.Transaction) bool) *txQueue {
+	q := &txQueue{
+		heads: txHeap{less: less},
+		pool:  pool,
+	}
+
+	pool.Prepare(baseFee)
+
+	for tx := pool.Peek(); tx != nil; tx = pool.Peek() {
+		q.heads.txs = append(q.heads.txs, tx)
+	}
+
+	heap.Init(&q.heads)
+
+	return q
+}
+
+// Peek returns the next transaction, or nil if there are none left.
+func (q *txQueue) Peek() *types.Transaction {
+	if len(q.heads.txs) == 0 {
+		return nil
+	}
+
+	return q.heads.txs[0]
+}
+
+// Shift pops the next transaction, written to the block, from the pool and
+// moves on to the next one of its sender.
+func (q *txQueue) Shift() {
+	tx := q.Peek()
+	if tx == nil {
+		return
+	}
+
+	q.pool.Pop(tx)
+
+	if next := q.pool.Peek(); next != nil {
+		q.heads.txs[0] = next
+		heap.Fix(&q.heads, 0)
+
+		return
+	}
+
+	heap.Pop(&q.heads)
+}
+
+// Skip moves past the next transaction along with the rest of its sender's,
+// leaving them in the pool.
+func (q *txQueue) Skip() {
+	if q.Peek() == nil {
+		return
+	}
+
+	heap.Pop(&q.heads)
+}
+
+// gas returns the total gas of the next transactions of the senders left in
+// the queue that match.
+func (q *txQueue) gas(match func(*types.Transaction) bool) uint64 {
+	var gas uint64
+
+	for _, tx := range q.heads.txs {
+		if match(tx) {
+			gas += tx.Gas
+		}
+	}
+
+	return gas
+}
+
+// txHeap is a heap of the next transactions of the senders.
+type txHeap struct {
+	txs  []*types.Transaction
+	less func(a, b *types.Transaction) bool
+}
+
+func (h txHeap) Len() int           { return len(h.txs) }
+func (h txHeap) Less(i, j int) bool { return h.less(h.txs[i], h.txs[j]) }
+func (h txHeap) Swap(i, j int)      { h.txs[i], h.txs[j] = h.txs[j], h.txs[i] }
+
+func (h *txHeap) Push(x interface{}) {
+	h.txs = append(h.txs, x.(*types.Transaction)) //nolint:forcetypeassert
+}
+
+func (h *txHeap) Pop() interface{} {
+	n := len(h.txs)
+	tx := h.txs[n-1]
+	h.txs = h.txs[:n-1]
+
+	return tx
+}
+
+// pendingTxs returns the promoted transactions of the pool in the inclusion
+// order of their kinds, along with the mark of the arrivals they were taken
+// at.
+func (sw *SequencerWorker) pendingTxs(baseFee uint64, kinds *txKinds) (*txQueue, uint64) {
+	mark := sw.txArrivals.mark()
+
+	arrived := func(a, b *types.Transaction) bool {
+		return sw.txArrivals.arrived(a.Hash) < sw.txArrivals.arrived(b.Hash)
+	}
+
+	less := func(a, b *types.Transaction) bool {
+		if ra, rb := kinds.rank(a), kinds.rank(b); ra != rb {
+			return ra < rb
+		}
+
+		if sw.production.TxOrdering != TxOrderingFIFO {
+			if c := block.EffectiveTip(a, baseFee).Cmp(block.EffectiveTip(b, baseFee)); c != 0 {
+				return c > 0
+			}
+		}
+
+		return arrived(a, b)
+	}
+
+	return newTxQueue(sw.txpool, baseFee, less), mark
+}
+
+// pruneTxArrivals forgets the arrivals of the transactions written to the
+// block and of the ones no longer in the pool.
+func (sw *SequencerWorker) pruneTxArrivals(mark uint64, written []*types.Transaction) {
+	included := make(map[types.Hash]struct{}, len(written))
+	for _, tx := range written {
+		included[tx.Hash] = struct{}{}
+	}
+
+	sw.txArrivals.prune(mark, func(hash types.Hash) bool {
+		if _, ok := included[hash]; ok {
+			return false
+		}
+
+		_, ok := sw.txpool.GetPendingTx(hash)
+
+		return ok
+	})
+}
@@ -0,0 +1,198 @@
+package avail
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/0xPolygon/polygon-edge/crypto"
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/pkg/avail/testutil"
+	"github.com/availproject/op-evm/pkg/staking"
+	"github.com/availproject/op-evm/pkg/test"
+	avail_types "github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+// testSender is a funded account sending the transactions of a test.
+type testSender struct {
+	addr  types.Address
+	key   *ecdsa.PrivateKey
+	nonce uint64
+}
+
+func newTestSender(t *testing.T, sw *SequencerWorker) *testSender {
+	t.Helper()
+
+	addr, key := test.NewAccount(t)
+	test.DepositBalance(t, addr, big.NewInt(0).Mul(big.NewInt(1000), big.NewInt(1e18)), sw.blockchain, sw.executor)
+
+	return &testSender{addr: addr, key: key}
+}
+
+// newNodeTestSender returns the account of the node's own key, funded in the
+// genesis, whose calls of the staking contract are system transactions.
+func newNodeTestSender(t *testing.T, sw *SequencerWorker) *testSender {
+	t.Helper()
+
+	return &testSender{addr: sw.nodeAddr, key: sw.nodeSignKey, nonce: sw.txpool.GetNonce(sw.nodeAddr)}
+}
+
+// sign signs the transaction with the next nonce of the sender.
+func (s *testSender) sign(t testing.TB, tx *types.Transaction, gasPrice int64) *types.Transaction {
+	t.Helper()
+
+	tx.From = s.addr
+	tx.Nonce = s.nonce
+	tx.GasPrice = big.NewInt(gasPrice)
+	s.nonce++
+
+	tx, err := (&crypto.FrontierSigner{}).SignTx(tx, s.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tx.ComputeHash()
+}
+
+func (s *testSender) transfer(t testing.TB, gasPrice int64) *types.Transaction {
+	t.Helper()
+
+	return s.sign(t, &types.Transaction{To: &types.ZeroAddress, Value: big.NewInt(1), Gas: 21_000}, gasPrice)
+}
+
+// awaitTxArrivals waits for the arrivals of the transactions added to the
+// pool to be tracked, probing the pool with the transactions of a sender of
+// its own, dropped once one is tracked.
+func awaitTxArrivals(t *testing.T, sw *SequencerWorker) {
+	t.Helper()
+
+	probe := newTestSender(t, sw)
+
+	var tx *types.Transaction
+
+	assert.Eventually(t, func() bool {
+		// The events come in order; the ones of the earlier probes are in.
+		if tx != nil && txArrived(sw, tx.Hash) {
+			return true
+		}
+
+		tx = probe.transfer(t, 1)
+		assert.NoError(t, sw.txpool.AddTx(tx))
+
+		return false
+	}, 5*time.Second, 20*time.Millisecond)
+
+	sw.txpool.Drop(tx)
+}
+
+// txArrived reports whether the arrival of the transaction is tracked.
+func txArrived(sw *SequencerWorker, hash types.Hash) bool {
+	sw.txArrivals.lock.Lock()
+	defer sw.txArrivals.lock.Unlock()
+
+	_, ok := sw.txArrivals.seq[hash]
+
+	return ok
+}
+
+func TestSequencerTxOrdering(t *testing.T) {
+	testCases := []struct {
+		ordering TxOrdering
+		want     []string
+	}{
+		{
+			// The best priced of the next transactions of the senders goes first.
+			ordering: TxOrderingPrice,
+			want:     []string{"d0", "b0", "c0", "b1", "a0", "a1", "a2"},
+		},
+		{
+			ordering: TxOrderingFIFO,
+			want:     []string{"d0", "a0", "b0", "a1", "c0", "b1", "a2"},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(string(tc.ordering), func(t *testing.T) {
+			sw, fraudResolver, _ := newTestSequencerWorker(t, testutil.NewFake(avail_types.NewUCompactFromUInt(1)))
+			sw.production.TxOrdering = tc.ordering
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			go func() { _ = sw.txArrivals.track(ctx, sw.txpool) }()
+
+			a, b, c, d := newTestSender(t, sw), newTestSender(t, sw), newTestSender(t, sw), newNodeTestSender(t, sw)
+
+			awaitTxArrivals(t, sw)
+
+			stake, err := staking.StakeTx(d.addr, big.NewInt(0), string(Sequencer), 1_000_000)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// In the order of arrival; the system transaction of the node
+			// pays the least.
+			txs := []struct {
+				name string
+				tx   *types.Transaction
+			}{
+				{"a0", a.transfer(t, 10)},
+				{"b0", b.transfer(t, 40)},
+				{"a1", a.transfer(t, 50)},
+				{"c0", c.transfer(t, 25)},
+				{"b1", b.transfer(t, 20)},
+				{"a2", a.transfer(t, 30)},
+				{"d0", d.sign(t, stake, 1)},
+			}
+
+			names := make(map[types.Hash]string, len(txs))
+
+			for _, tx := range txs {
+				names[tx.tx.Hash] = tx.name
+
+				if err := sw.txpool.AddTx(tx.tx); err != nil {
+					t.Fatal(err)
+				}
+
+				// Let the arrival register before the next one.
+				assert.Eventually(t, func() bool { return txArrived(sw, tx.tx.Hash) }, 5*time.Second, time.Millisecond)
+			}
+
+			assert.Eventually(t, func() bool { return sw.txpool.Length() == uint64(len(txs)) }, 5*time.Second, 10*time.Millisecond)
+
+			account := accounts.Account{Address: common.Address(sw.nodeAddr)}
+			if err := sw.writeBlock(fraudResolver, account, &keystore.Key{PrivateKey: sw.nodeSignKey}); err != nil {
+				t.Fatal(err)
+			}
+
+			blk, _ := sw.blockchain.GetBlockByNumber(sw.blockchain.Header().Number, true)
+
+			included := make([]string, 0, len(blk.Transactions))
+			for _, tx := range blk.Transactions {
+				included = append(included, names[tx.Hash])
+			}
+
+			assert.Equal(t, tc.want, included)
+
+			// The arrivals of the included transactions are forgotten.
+			assert.Zero(t, sw.txArrivals.len())
+		})
+	}
+}
+
+func TestParseTxOrdering(t *testing.T) {
+	ordering, err := ParseTxOrdering("fifo")
+	assert.NoError(t, err)
+	assert.Equal(t, TxOrderingFIFO, ordering)
+
+	_, err = ParseTxOrdering("random")
+	assert.Error(t, err)
+}
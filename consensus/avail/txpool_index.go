@@ -0,0 +1,152 @@
+package avail
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/0xPolygon/polygon-edge/txpool"
+	"github.com/0xPolygon/polygon-edge/txpool/proto"
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+// minTxPoolIndexPrune is the number of the transactions indexed past which
+// the ones gone from the txpool are pruned, at the least.
+const minTxPoolIndexPrune = 1024
+
+// txPoolIndex lists the transactions of the txpool by sender. The queues the
+// txpool hands out through GetTxs are its live ones, reordered as the
+// transactions get promoted once its lock is released, so the index follows
+// the transactions added to the txpool instead and looks them up in the
+// txpool's own locked index of them. The transactions gone from the txpool
+// are forgotten as they are looked up.
+type txPoolIndex struct {
+	pool *txpool.TxPool
+
+	lock    sync.Mutex
+	senders map[types.Address]map[types.Hash]struct{}
+	count   int
+	pruneAt int
+}
+
+// newTxPoolIndex returns the index of the transactions added to the txpool
+// from now on, until the context is done.
+func newTxPoolIndex(ctx context.Context, pool *txpool.TxPool) *txPoolIndex {
+	x := &txPoolIndex{
+		pool:    pool,
+		senders: make(map[types.Address]map[types.Hash]struct{}),
+		pruneAt: minTxPoolIndexPrune,
+	}
+
+	// The subscription is in place once the stream is first asked for its
+	// context.
+	subscribed := make(chan struct{})
+
+	var once sync.Once
+
+	go func() {
+		_ = pool.Subscribe(
+			&proto.SubscribeRequest{Types: []proto.EventType{proto.EventType_ADDED}},
+			&txEventStream{
+				ctx:     ctx,
+				send:    func(ev *proto.TxPoolEvent) { x.add(types.StringToHash(ev.TxHash)) },
+				started: func() { once.Do(func() { close(subscribed) }) },
+			},
+		)
+
+		once.Do(func() { close(subscribed) })
+	}()
+
+	<-subscribed
+
+	return x
+}
+
+// add indexes the transaction added to the txpool, unless it's gone already.
+func (x *txPoolIndex) add(hash types.Hash) {
+	tx, ok := x.pool.GetPendingTx(hash)
+	if !ok {
+		return
+	}
+
+	x.lock.Lock()
+	defer x.lock.Unlock()
+
+	hashes, ok := x.senders[tx.From]
+	if !ok {
+		hashes = make(map[types.Hash]struct{})
+		x.senders[tx.From] = hashes
+	}
+
+	if _, ok := hashes[hash]; ok {
+		return
+	}
+
+	hashes[hash] = struct{}{}
+	x.count++
+
+	if x.count >= x.pruneAt {
+		for addr := range x.senders {
+			x.lookup(addr)
+		}
+
+		x.pruneAt = 2 * x.count
+		if x.pruneAt < minTxPoolIndexPrune {
+			x.pruneAt = minTxPoolIndexPrune
+		}
+	}
+}
+
+// txs returns the transactions of the sender in the txpool, promoted and
+// enqueued alike, in the order of their nonces.
+func (x *txPoolIndex) txs(addr types.Address) []*types.Transaction {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+
+	return x.lookup(addr)
+}
+
+// all returns the transactions in the txpool by sender, in the order of
+// their nonces.
+func (x *txPoolIndex) all() map[types.Address][]*types.Transaction {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+
+	all := make(map[types.Address][]*types.Transaction, len(x.senders))
+
+	for addr := range x.senders {
+		if txs := x.lookup(addr); len(txs) > 0 {
+			all[addr] = txs
+		}
+	}
+
+	return all
+}
+
+// lookup looks the transactions of the sender up in the txpool, forgetting
+// the ones gone from it. The lock must be held.
+func (x *txPoolIndex) lookup(addr types.Address) []*types.Transaction {
+	hashes := x.senders[addr]
+
+	txs := make([]*types.Transaction, 0, len(hashes))
+
+	for hash := range hashes {
+		tx, ok := x.pool.GetPendingTx(hash)
+		if !ok {
+			delete(hashes, hash)
+			x.count--
+
+			continue
+		}
+
+		txs = append(txs, tx)
+	}
+
+	if len(hashes) == 0 {
+		delete(x.senders, addr)
+	}
+
+	sort.Slice(txs, func(i, j int) bool { return txs[i].Nonce < txs[j].Nonce })
+
+	return txs
+}
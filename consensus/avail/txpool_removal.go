@@ -0,0 +1,78 @@
+package avail
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+// errPoolTxNotFound is returned for the removal of a transaction not in the
+// txpool.
+var errPoolTxNotFound = errors.New("transaction not in the txpool")
+
+// PoolRemoval is the outcome of the removal of a transaction from the
+// txpool.
+type PoolRemoval struct {
+	// Removed are the transactions removed, in the order of their nonces.
+	Removed []types.Hash `json:"removed"`
+
+	// Requeued is the number of the other transactions of the sender put
+	// back into the txpool.
+	Requeued int `json:"requeued"`
+}
+
+// removePoolTx removes the transaction of the given hash from the txpool,
+// along with the later ones of its sender if dependents is set; the ones of
+// the sender kept go back into the txpool, the later ones queued behind the
+// nonce freed until it's taken again.
+//
+// The txpool drops the transactions of a sender all at once, so the ones
+// kept go back in from the nonce of the state on, as the sweep does.
+func (d *Avail) removePoolTx(hash types.Hash, dependents bool) (*PoolRemoval, error) {
+	tx, ok := d.txpool.GetPendingTx(hash)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", errPoolTxNotFound, hash)
+	}
+
+	head := d.blockchain.Header()
+
+	txn, err := d.executor.BeginTxn(head.StateRoot, head, types.ZeroAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	txs := d.poolIndex.txs(tx.From)
+
+	removal := &PoolRemoval{Removed: []types.Hash{}}
+
+	var kept []*types.Transaction
+
+	for _, pooled := range txs {
+		if pooled.Hash == tx.Hash || (dependents && pooled.Nonce > tx.Nonce) {
+			removal.Removed = append(removal.Removed, pooled.Hash)
+			continue
+		}
+
+		kept = append(kept, pooled)
+	}
+
+	// Dropping the sender rolls its next nonce back to the one of the
+	// transaction given, so it's the nonce of the state.
+	drop := tx.Copy()
+	drop.Nonce = txn.GetNonce(tx.From)
+	d.txpool.Drop(drop)
+
+	for _, pooled := range kept {
+		if err := d.txpool.AddTx(pooled.Copy()); err != nil {
+			d.logger.Debug("failed to put transaction back into the txpool", "hash", pooled.Hash, "error", err)
+			continue
+		}
+
+		removal.Requeued++
+	}
+
+	d.logger.Info("removed transaction from the txpool", "hash", hash, "from", tx.From, "nonce", tx.Nonce, "removed", len(removal.Removed), "requeued", removal.Requeued)
+
+	return removal, nil
+}
@@ -0,0 +1,101 @@
+package avail
+
+import (
+	"testing"
+	"time"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/pkg/avail/testutil"
+	avail_types "github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRemovePoolTransaction(t *testing.T) {
+	a, _ := NewTestAvail(t, Sequencer)
+	sw, _, _ := newTestSequencerWorkerOf(t, a, testutil.NewFake(avail_types.NewUCompactFromUInt(1)))
+
+	alice, bob := newTestSender(t, sw), newTestSender(t, sw)
+
+	// The transfers of alice of nonces 0 to 3, and one of bob, are pending.
+	var txs []*types.Transaction
+	for i := 0; i < 4; i++ {
+		txs = append(txs, alice.transfer(t, 1))
+	}
+
+	bobTx := bob.transfer(t, 1)
+
+	for _, tx := range append(txs, bobTx) {
+		if err := a.txpool.AddTx(tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The transactions of the sender in the txpool, promoted and enqueued.
+	pooled := func(addr types.Address) (promoted int, enqueued int) {
+		next := a.txpool.GetNonce(addr)
+
+		for _, tx := range a.poolIndex.txs(addr) {
+			if tx.Nonce < next {
+				promoted++
+			} else {
+				enqueued++
+			}
+		}
+
+		return promoted, enqueued
+	}
+
+	assert.Eventually(t, func() bool {
+		p, _ := pooled(alice.addr)
+		b, _ := pooled(bob.addr)
+
+		return p == 4 && b == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	srv := rpc.NewServer()
+	if err := srv.RegisterName(AdminNamespace, NewAdminAPI(a)); err != nil {
+		t.Fatal(err)
+	}
+
+	c := rpc.DialInProc(srv)
+	defer c.Close()
+
+	// The transfer of nonce 2 alone is removed; the ones before it stay
+	// pending, the one after it is queued behind the nonce freed.
+	var removal PoolRemoval
+	if assert.NoError(t, c.Call(&removal, AdminNamespace+"_removePoolTransaction", txs[2].Hash)) {
+		assert.Equal(t, []types.Hash{txs[2].Hash}, removal.Removed)
+		assert.Equal(t, 3, removal.Requeued)
+	}
+
+	assert.Eventually(t, func() bool {
+		p, q := pooled(alice.addr)
+		return p == 2 && q == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	_, ok := a.txpool.GetPendingTx(txs[2].Hash)
+	assert.False(t, ok)
+
+	// The transfer of nonce 1 is removed with the later ones.
+	removal = PoolRemoval{}
+	if assert.NoError(t, c.Call(&removal, AdminNamespace+"_removePoolTransaction", txs[1].Hash, true)) {
+		assert.Equal(t, []types.Hash{txs[1].Hash, txs[3].Hash}, removal.Removed)
+		assert.Equal(t, 1, removal.Requeued)
+	}
+
+	assert.Eventually(t, func() bool {
+		p, q := pooled(alice.addr)
+		return p == 1 && q == 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	_, ok = a.txpool.GetPendingTx(txs[0].Hash)
+	assert.True(t, ok)
+
+	// The other senders are left alone.
+	p, _ := pooled(bob.addr)
+	assert.Equal(t, 1, p)
+
+	// The transactions not in the txpool can't be removed.
+	assert.ErrorContains(t, c.Call(&removal, AdminNamespace+"_removePoolTransaction", txs[3].Hash), errPoolTxNotFound.Error())
+}
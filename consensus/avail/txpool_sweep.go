@@ -0,0 +1,114 @@
+package avail
+
+import (
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+// txPoolSweep is the outcome of a sweep of the txpool.
+type txPoolSweep struct {
+	// usedNonce is the number of transactions dropped for the nonce taken
+	// by a transaction in a block already.
+	usedNonce int
+
+	// underfunded is the number of the next transactions of their senders
+	// dropped for costing more than the sender holds.
+	underfunded int
+
+	// requeued is the number of the transactions of the swept senders put
+	// back into the txpool.
+	requeued int
+}
+
+// sweepTxPoolIfDue sweeps the txpool once the given number of blocks has
+// been applied to the chain since the last sweep.
+func (sw *SequencerWorker) sweepTxPoolIfDue() {
+	every := sw.production.TxPoolSweepBlocks
+	if every == 0 {
+		return
+	}
+
+	head := sw.blockchain.Header().Number
+	if head < sw.lastTxPoolSweep+every {
+		return
+	}
+
+	sw.lastTxPoolSweep = head
+
+	sweep, err := sw.sweepTxPool()
+	if err != nil {
+		sw.logger.Error("failed to sweep the txpool", "error", err)
+		return
+	}
+
+	observeTxPoolSweep(sweep)
+
+	if sweep.usedNonce > 0 || sweep.underfunded > 0 {
+		sw.logger.Info("swept the txpool", "used_nonce", sweep.usedNonce, "underfunded", sweep.underfunded, "requeued", sweep.requeued, "block_number", head)
+	}
+}
+
+// sweepTxPool re-validates the transactions of the txpool against the state
+// of the head and drops the ones that can never execute: the ones with a
+// nonce taken by a block already, and the next ones of their senders costing
+// more than the sender holds. The transactions waiting for a nonce yet to
+// come are kept.
+//
+// The txpool drops the transactions of a sender all at once, so the rest of
+// them go back into the txpool, from the nonce of the state on. It's called
+// in between the blocks produced, never while one is built off the txpool.
+func (sw *SequencerWorker) sweepTxPool() (txPoolSweep, error) {
+	var sweep txPoolSweep
+
+	head := sw.blockchain.Header()
+
+	txn, err := sw.executor.BeginTxn(head.StateRoot, head, types.ZeroAddress)
+	if err != nil {
+		return sweep, err
+	}
+
+	for addr, txs := range sw.poolIndex.all() {
+		nonce, balance := txn.GetNonce(addr), txn.GetBalance(addr)
+
+		var dropped, kept []*types.Transaction
+
+		for _, tx := range txs {
+			switch {
+			case tx.Nonce < nonce:
+				sweep.usedNonce++
+				dropped = append(dropped, tx)
+
+			case tx.Nonce == nonce && balance.Cmp(tx.Cost()) < 0:
+				sweep.underfunded++
+				dropped = append(dropped, tx)
+
+			default:
+				kept = append(kept, tx)
+			}
+		}
+
+		if len(dropped) == 0 {
+			continue
+		}
+
+		for _, tx := range dropped {
+			sw.logger.Debug("dropping transaction that can never execute", "hash", tx.Hash, "from", addr, "nonce", tx.Nonce, "state_nonce", nonce)
+		}
+
+		// Dropping the sender rolls its next nonce back to the one of the
+		// transaction given, so it's the nonce of the state.
+		drop := dropped[0].Copy()
+		drop.Nonce = nonce
+		sw.txpool.Drop(drop)
+
+		for _, tx := range kept {
+			if err := sw.txpool.AddTx(tx.Copy()); err != nil {
+				sw.logger.Debug("failed to put transaction back into the txpool", "hash", tx.Hash, "error", err)
+				continue
+			}
+
+			sweep.requeued++
+		}
+	}
+
+	return sweep, nil
+}
@@ -0,0 +1,92 @@
+package avail
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/pkg/avail/testutil"
+	"github.com/availproject/op-evm/pkg/block"
+	"github.com/availproject/op-evm/pkg/common"
+	avail_types "github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// pooledTxs returns the hashes of the transactions in the txpool.
+func pooledTxs(sw *SequencerWorker) map[types.Hash]bool {
+	hashes := make(map[types.Hash]bool)
+
+	for _, txs := range sw.poolIndex.all() {
+		for _, tx := range txs {
+			hashes[tx.Hash] = true
+		}
+	}
+
+	return hashes
+}
+
+func TestSequencerTxPoolSweep(t *testing.T) {
+	sw, _, _ := newTestSequencerWorker(t, testutil.NewFake(avail_types.NewUCompactFromUInt(1)))
+	sw.production.TxPoolSweepBlocks = 1
+
+	a, b, c := newTestSender(t, sw), newTestSender(t, sw), newTestSender(t, sw)
+
+	transfer := func(s *testSender, value *big.Int) *types.Transaction {
+		return s.sign(t, &types.Transaction{To: &types.ZeroAddress, Value: value, Gas: 21_000}, 1)
+	}
+
+	// The nonce of a0 is about to be taken, and so is the balance of the
+	// sender of b1; c5 waits for the nonces before it.
+	a0, a1 := transfer(a, big.NewInt(1)), transfer(a, big.NewInt(1))
+
+	b.nonce = 1
+	b1 := transfer(b, big.NewInt(0).Mul(big.NewInt(500), common.ETH))
+
+	c.nonce = 5
+	c5 := transfer(c, big.NewInt(1))
+
+	for _, tx := range []*types.Transaction{a0, a1, b1, c5} {
+		if err := sw.txpool.AddTx(tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	assert.Eventually(t, func() bool { return len(pooledTxs(sw)) == 4 }, 5*time.Second, 10*time.Millisecond)
+
+	// A block of another sequencer, unknown to the txpool, takes the nonce
+	// of a0 and drains the sender of b1.
+	a.nonce, b.nonce = 0, 0
+
+	bb, err := block.NewBlockBuilderFactory(sw.blockchain, sw.executor, sw.logger).FromBlockchainHead()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bb.SetCoinbaseAddress(sw.nodeAddr)
+	bb.SignWith(sw.nodeSignKey)
+	bb.AddTransactions(transfer(a, big.NewInt(2)), transfer(b, big.NewInt(0).Mul(big.NewInt(900), common.ETH)))
+
+	if err := bb.Write("test"); err != nil {
+		t.Fatal(err)
+	}
+
+	sw.sweepTxPoolIfDue()
+
+	pooled := pooledTxs(sw)
+	assert.False(t, pooled[a0.Hash], "used nonce transaction not swept")
+	assert.False(t, pooled[b1.Hash], "underfunded transaction not swept")
+	assert.True(t, pooled[c5.Hash], "future nonce transaction swept")
+
+	// The transaction after the used nonce is put back, up next.
+	assert.Eventually(t, func() bool {
+		txs := sw.poolIndex.txs(a.addr)
+		return len(txs) == 1 && txs[0].Hash == a1.Hash && sw.txpool.GetNonce(a.addr) == a1.Nonce+1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Nothing is left to drop.
+	sweep, err := sw.sweepTxPool()
+	assert.NoError(t, err)
+	assert.Equal(t, txPoolSweep{}, sweep)
+	assert.Equal(t, sw.blockchain.Header().Number, sw.lastTxPoolSweep)
+}
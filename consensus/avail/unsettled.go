@@ -0,0 +1,614 @@
+package avail
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/0xPolygon/polygon-edge/helper/hex"
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/pkg/avail"
+	avail_types "github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/hashicorp/go-hclog"
+)
+
+// UnsettledBlocksFileName is the name of the file, in the consensus data
+// directory, the unsettled blocks are persisted to.
+const UnsettledBlocksFileName = "unsettled_blocks.json"
+
+// unsettledSearchWindow is the number of Avail blocks queried at once when
+// searching Avail for the blocks whose submissions were pending.
+const unsettledSearchWindow = 64
+
+// unsettledQueue holds the blocks the sequencer produced until their
+// inclusion in Avail is confirmed. A block enters the queue before it's
+// submitted, so that a submission failing for good, or cut short by a
+// restart, is retried rather than lost. Along with each block, the queue
+// keeps the state of its submission, so that a block already in the Avail
+// pool when the node restarts isn't submitted twice. The queue is persisted
+// to a file in the data directory; without one, it's kept in memory only.
+type unsettledQueue struct {
+	path string
+
+	lock   sync.Mutex
+	blocks []*unsettledBlock
+}
+
+// submissionState is the state of the Avail submission of an unsettled block.
+type submissionState string
+
+const (
+	// submissionQueued is the state of the blocks yet to be submitted, or
+	// to be submitted again.
+	submissionQueued submissionState = ""
+
+	// submissionPending is the state of the blocks in the Avail pool,
+	// awaiting their inclusion.
+	submissionPending submissionState = "pending"
+
+	// submissionIncluded is the state of the blocks included in Avail, yet
+	// to be written to the local chain.
+	submissionIncluded submissionState = "included"
+
+	// submissionFinalized is the state of the blocks included in a
+	// finalized Avail block, yet to be written to the local chain.
+	submissionFinalized submissionState = "finalized"
+)
+
+// unsettledBlock is a block of the unsettled queue along with the
+// bookkeeping of its submission to Avail.
+type unsettledBlock struct {
+	*types.Block
+
+	State submissionState
+
+	// Nonce, Birth and Mortality are the ones of the latest extrinsic of
+	// the block accepted into the Avail pool; see avail.Submission.
+	Nonce     uint64
+	Birth     uint64
+	Mortality uint64
+
+	// Since is the number of the Avail block the earliest extrinsic of the
+	// block was born at; the block can't be included in Avail before it.
+	Since uint64
+
+	// AvailBlock is the number of the Avail block the block was included in.
+	AvailBlock uint64
+}
+
+// unsettledBlocksFile is the content of the unsettled blocks file; the
+// blocks are in the order they were produced in.
+type unsettledBlocksFile struct {
+	Blocks []unsettledBlockEntry `json:"blocks"`
+}
+
+// unsettledBlockEntry is an unsettled block in the unsettled blocks file;
+// the block is RLP encoded.
+type unsettledBlockEntry struct {
+	Block      string          `json:"block"`
+	State      submissionState `json:"state,omitempty"`
+	Nonce      uint64          `json:"nonce,omitempty"`
+	Birth      uint64          `json:"birth,omitempty"`
+	Mortality  uint64          `json:"mortality,omitempty"`
+	Since      uint64          `json:"since,omitempty"`
+	AvailBlock uint64          `json:"availBlock,omitempty"`
+}
+
+// UnmarshalJSON decodes the entry, or the bare RLP encoded block the files
+// written before the submissions were tracked hold; such a block is queued.
+func (e *unsettledBlockEntry) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &e.Block); err == nil {
+		return nil
+	}
+
+	type entry unsettledBlockEntry
+
+	return json.Unmarshal(data, (*entry)(e))
+}
+
+// newUnsettledQueue returns an empty unsettledQueue persisted to the given
+// data directory, or kept in memory if it's empty.
+func newUnsettledQueue(dataDir string) *unsettledQueue {
+	q := &unsettledQueue{}
+	if dataDir != "" {
+		q.path = filepath.Join(dataDir, UnsettledBlocksFileName)
+	}
+
+	return q
+}
+
+// loadUnsettledQueue returns the unsettledQueue persisted to the given data
+// directory, holding the blocks left unsettled by the previous run along
+// with the state of their submissions.
+func loadUnsettledQueue(dataDir string) (*unsettledQueue, error) {
+	q := newUnsettledQueue(dataDir)
+	if q.path == "" {
+		return q, nil
+	}
+
+	bs, err := os.ReadFile(q.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return q, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var f unsettledBlocksFile
+	if err := json.Unmarshal(bs, &f); err != nil {
+		return nil, fmt.Errorf("invalid unsettled blocks file %q: %w", q.path, err)
+	}
+
+	for i, e := range f.Blocks {
+		data, err := hex.DecodeHex(e.Block)
+		if err != nil {
+			return nil, fmt.Errorf("invalid unsettled block %d in %q: %w", i, q.path, err)
+		}
+
+		blk := &types.Block{}
+		if err := blk.UnmarshalRLP(data); err != nil {
+			return nil, fmt.Errorf("invalid unsettled block %d in %q: %w", i, q.path, err)
+		}
+
+		q.blocks = append(q.blocks, &unsettledBlock{
+			Block:      blk,
+			State:      e.State,
+			Nonce:      e.Nonce,
+			Birth:      e.Birth,
+			Mortality:  e.Mortality,
+			Since:      e.Since,
+			AvailBlock: e.AvailBlock,
+		})
+	}
+
+	observeUnsettledBlocks(len(q.blocks))
+
+	return q, nil
+}
+
+// Len returns the number of unsettled blocks.
+func (q *unsettledQueue) Len() int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	return len(q.blocks)
+}
+
+// list returns the unsettled blocks, in the order they were produced in.
+func (q *unsettledQueue) list() []*types.Block {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	blks := make([]*types.Block, 0, len(q.blocks))
+	for _, u := range q.blocks {
+		blks = append(blks, u.Block)
+	}
+
+	return blks
+}
+
+// entries returns copies of the unsettled blocks along with the state of
+// their submissions, in the order they were produced in.
+func (q *unsettledQueue) entries() []unsettledBlock {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	entries := make([]unsettledBlock, 0, len(q.blocks))
+	for _, u := range q.blocks {
+		entries = append(entries, *u)
+	}
+
+	return entries
+}
+
+// push adds the block, yet to be submitted, to the end of the queue.
+func (q *unsettledQueue) push(blk *types.Block) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	q.blocks = append(q.blocks, &unsettledBlock{Block: blk})
+
+	return q.saveLocked()
+}
+
+// update applies the change to the blocks with the given hashes, if still
+// queued, and persists the queue.
+func (q *unsettledQueue) update(change func(u *unsettledBlock), hashes ...types.Hash) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	for _, u := range q.blocks {
+		for _, hash := range hashes {
+			if u.Hash() == hash {
+				change(u)
+			}
+		}
+	}
+
+	return q.saveLocked()
+}
+
+// submitted records the extrinsic of the blocks accepted into the Avail pool.
+func (q *unsettledQueue) submitted(sub avail.Submission, hashes ...types.Hash) error {
+	return q.update(func(u *unsettledBlock) {
+		if u.State != submissionPending || sub.Birth < u.Since {
+			u.Since = sub.Birth
+		}
+
+		u.State, u.Nonce, u.Birth, u.Mortality = submissionPending, sub.Nonce, sub.Birth, sub.Mortality
+	}, hashes...)
+}
+
+// included records the inclusion of the blocks in the given Avail block.
+func (q *unsettledQueue) included(availBlock uint64, finalized bool, hashes ...types.Hash) error {
+	state := submissionIncluded
+	if finalized {
+		state = submissionFinalized
+	}
+
+	return q.update(func(u *unsettledBlock) {
+		u.State, u.AvailBlock = state, availBlock
+	}, hashes...)
+}
+
+// requeue records the submission of the blocks as failed for good; they're
+// to be submitted again.
+func (q *unsettledQueue) requeue(hashes ...types.Hash) error {
+	return q.update(func(u *unsettledBlock) {
+		*u = unsettledBlock{Block: u.Block}
+	}, hashes...)
+}
+
+// submittedHook returns the submit option recording the extrinsics of the
+// blocks accepted into the Avail pool; failing to persist them is logged
+// only, the submission going on regardless.
+func (q *unsettledQueue) submittedHook(logger hclog.Logger, blks ...*types.Block) avail.SubmitOption {
+	hashes := make([]types.Hash, 0, len(blks))
+	for _, blk := range blks {
+		hashes = append(hashes, blk.Hash())
+	}
+
+	return avail.WithSubmitted(func(sub avail.Submission) {
+		if err := q.submitted(sub, hashes...); err != nil {
+			logger.Error("failed to record the submission of unsettled blocks", "first_block_number", blks[0].Number(), "avail_nonce", sub.Nonce, "error", err)
+		}
+	})
+}
+
+// remove takes the block with the given hash off the queue.
+func (q *unsettledQueue) remove(hash types.Hash) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	for i, u := range q.blocks {
+		if u.Hash() == hash {
+			q.blocks = append(q.blocks[:i], q.blocks[i+1:]...)
+
+			return q.saveLocked()
+		}
+	}
+
+	return nil
+}
+
+// saveLocked persists the queue; it writes a temporary file first and
+// renames it over the queue file, so that a crash never leaves a truncated
+// queue behind. It must be called with the lock held.
+func (q *unsettledQueue) saveLocked() error {
+	observeUnsettledBlocks(len(q.blocks))
+
+	if q.path == "" {
+		return nil
+	}
+
+	f := unsettledBlocksFile{Blocks: make([]unsettledBlockEntry, 0, len(q.blocks))}
+	for _, u := range q.blocks {
+		f.Blocks = append(f.Blocks, unsettledBlockEntry{
+			Block:      hex.EncodeToHex(u.MarshalRLP()),
+			State:      u.State,
+			Nonce:      u.Nonce,
+			Birth:      u.Birth,
+			Mortality:  u.Mortality,
+			Since:      u.Since,
+			AvailBlock: u.AvailBlock,
+		})
+	}
+
+	bs, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(q.path), 0o700); err != nil {
+		return err
+	}
+
+	tmp := q.path + ".tmp"
+	if err := os.WriteFile(tmp, bs, 0o600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, q.path)
+}
+
+// lapsed reports whether the pending submission of the block, not found
+// in Avail by the given head, can't be included anymore: the nonce of its
+// extrinsic went to another one, or its mortal era lapsed.
+func (u unsettledBlock) lapsed(head, accountNonce uint64) bool {
+	return accountNonce > u.Nonce || (u.Mortality > 0 && head >= u.Birth+u.Mortality)
+}
+
+// mayLand reports whether the extrinsic of a submission failed with the
+// error may be included in Avail nonetheless: the submission was cut short,
+// rather than the extrinsic rejected or dropped by Avail.
+func mayLand(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, avail.ErrConnection) || errors.Is(err, avail.ErrSubscriptionClosed)
+}
+
+// failSubmission records the failed submission of the blocks: unless their
+// extrinsic may land yet, they're queued for resubmission.
+func (sw *SequencerWorker) failSubmission(err error, blks ...*types.Block) {
+	if mayLand(err) {
+		return
+	}
+
+	hashes := make([]types.Hash, 0, len(blks))
+	for _, blk := range blks {
+		hashes = append(hashes, blk.Hash())
+	}
+
+	if err := sw.unsettled.requeue(hashes...); err != nil {
+		sw.logger.Error("failed to requeue the unsettled blocks", "first_block_number", blks[0].Number(), "error", err)
+	}
+}
+
+// resubmitUnsettled resubmits the unsettled blocks to Avail, in order, and
+// writes them to the local chain once included. The blocks synced from Avail
+// in the meantime are settled already, while the ones the chain has moved on
+// from are dropped. The blocks whose submissions were pending, as left by a
+// restart, are reconciled against Avail first: the ones included meanwhile
+// are written as they are, and nothing is resubmitted while any may still
+// be included. Several blocks go as a single batch when the sender supports
+// it.
+func (sw *SequencerWorker) resubmitUnsettled() error {
+	var live []unsettledBlock
+
+	parent := sw.blockchain.Header().Hash
+
+	for _, u := range sw.unsettled.entries() {
+		if _, known := sw.blockchain.GetHeaderByHash(u.Hash()); known {
+			sw.logger.Info("unsettled block was synced from Avail", "block_number", u.Number(), "block_hash", u.Hash())
+
+			if err := sw.unsettled.remove(u.Hash()); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if u.ParentHash() != parent {
+			sw.logger.Warn(
+				"dropping unsettled block the chain has moved on from",
+				"block_number", u.Number(),
+				"block_hash", u.Hash(),
+				"block_parent_hash", u.ParentHash(),
+			)
+
+			if err := sw.unsettled.remove(u.Hash()); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		live = append(live, u)
+		parent = u.Hash()
+	}
+
+	live, err := sw.reconcileUnsettled(live)
+	if err != nil {
+		return fmt.Errorf("failed to reconcile the unsettled blocks with Avail: %w", err)
+	}
+
+	// The blocks included already are written first; the ones queued go
+	// next, up to the first one still pending in the Avail pool.
+	var included, blks []*types.Block
+
+collect:
+	for _, u := range live {
+		switch u.State {
+		case submissionIncluded, submissionFinalized:
+			if len(blks) > 0 {
+				break collect
+			}
+
+			included = append(included, u.Block)
+
+		case submissionPending:
+			sw.logger.Info("waiting for the pending submission of unsettled block", "block_number", u.Number(), "avail_nonce", u.Nonce)
+			break collect
+
+		default:
+			blks = append(blks, u.Block)
+		}
+	}
+
+	if len(included) > 0 {
+		if err := sw.settle(included...); err != nil {
+			return err
+		}
+	}
+
+	if len(blks) == 0 {
+		return nil
+	}
+
+	sw.logger.Info("resubmitting unsettled blocks to avail", "first_block_number", blks[0].Number(), "blocks", len(blks))
+
+	status := avail_types.ExtrinsicStatus{IsInBlock: true}
+
+	if batchSender, ok := sw.availSender.(avail.BatchSender); ok && len(blks) > 1 {
+		if _, err := batchSender.SendBatchAndWaitForStatus(sw.ctx, blks, status, sw.unsettled.submittedHook(sw.logger, blks...)); err != nil {
+			sw.failSubmission(err, blks...)
+			return err
+		}
+
+		return sw.settle(blks...)
+	}
+
+	for _, blk := range blks {
+		if _, err := sw.availSender.SendAndWaitForStatus(sw.ctx, blk, status, sw.unsettled.submittedHook(sw.logger, blk)); err != nil {
+			sw.failSubmission(err, blk)
+			return err
+		}
+
+		if err := sw.settle(blk); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// reconcileUnsettled checks the unsettled blocks whose submissions are
+// pending against Avail, recording the ones included meanwhile and queueing
+// the ones that can't be anymore for resubmission. It returns the blocks
+// with their submission states updated.
+func (sw *SequencerWorker) reconcileUnsettled(blks []unsettledBlock) ([]unsettledBlock, error) {
+	var (
+		pending = make(map[types.Hash]struct{})
+		since   uint64
+	)
+
+	for _, u := range blks {
+		if u.State != submissionPending {
+			continue
+		}
+
+		if len(pending) == 0 || u.Since < since {
+			since = u.Since
+		}
+
+		pending[u.Hash()] = struct{}{}
+	}
+
+	if len(pending) == 0 {
+		return blks, nil
+	}
+
+	// The nonce is read before Avail is searched: an extrinsic included
+	// past the read is found, while a nonce used by then without the block
+	// found went to another extrinsic.
+	var publicKey []byte
+	if sw.availAccount != nil {
+		publicKey = sw.availAccount.PublicKey()
+	}
+
+	nonce, err := avail.AccountNonce(sw.ctx, sw.availClient, publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	hdr, err := sw.availClient.GetLatestHeader(sw.ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	head := uint64(hdr.Number)
+
+	found, err := sw.findInAvail(since, head, pending)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, u := range blks {
+		if u.State != submissionPending {
+			continue
+		}
+
+		if number, ok := found[u.Hash()]; ok {
+			sw.logger.Info("pending unsettled block was included in Avail", "block_number", u.Number(), "avail_block_number", number)
+
+			if err := sw.unsettled.included(number, false, u.Hash()); err != nil {
+				return nil, err
+			}
+
+			blks[i].State, blks[i].AvailBlock = submissionIncluded, number
+
+			continue
+		}
+
+		if u.lapsed(head, nonce) {
+			sw.logger.Warn("pending unsettled block didn't make it to Avail; resubmitting", "block_number", u.Number(), "avail_nonce", u.Nonce, "avail_account_nonce", nonce)
+
+			if err := sw.unsettled.requeue(u.Hash()); err != nil {
+				return nil, err
+			}
+
+			blks[i] = unsettledBlock{Block: u.Block}
+		}
+	}
+
+	return blks, nil
+}
+
+// findInAvail returns the numbers of the Avail blocks, in the given range,
+// that include the blocks with the given hashes.
+func (sw *SequencerWorker) findInAvail(from, to uint64, hashes map[types.Hash]struct{}) (map[types.Hash]uint64, error) {
+	decoder := avail.NewBlockDecoder(sw.availClient, sw.availAppID, sw.logger)
+	found := make(map[types.Hash]uint64)
+
+	for from <= to {
+		end := from + unsettledSearchWindow - 1
+		if end > to {
+			end = to
+		}
+
+		availBlks, err := sw.availClient.Query(sw.ctx, from, end)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, availBlk := range availBlks {
+			// The blocks decoded are looked at even if the decoding failed
+			// part way.
+			edgeBlks, _ := decoder.Decode(sw.ctx, availBlk)
+
+			for _, edgeBlk := range edgeBlks {
+				if _, ok := hashes[edgeBlk.Hash()]; ok {
+					found[edgeBlk.Hash()] = uint64(availBlk.Block.Header.Number)
+				}
+			}
+		}
+
+		from = end + 1
+	}
+
+	return found, nil
+}
+
+// settle writes the blocks included in Avail to the local chain and takes
+// them off the unsettled queue.
+func (sw *SequencerWorker) settle(blks ...*types.Block) error {
+	for _, blk := range blks {
+		if err := sw.blockchain.WriteBlock(blk, sw.nodeType.String()); err != nil {
+			return err
+		}
+
+		if err := sw.unsettled.remove(blk.Hash()); err != nil {
+			return err
+		}
+
+		sw.logger.Info("settled unsettled block", "block_number", blk.Number(), "block_hash", blk.Hash())
+	}
+
+	sw.txpool.ResetWithHeaders(sw.blockchain.Header())
+
+	return nil
+}
@@ -0,0 +1,253 @@
+package avail
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/0xPolygon/polygon-edge/helper/hex"
+	"github.com/availproject/op-evm/pkg/avail"
+	"github.com/availproject/op-evm/pkg/avail/testutil"
+	avail_types "github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSequencerResubmitsUnsettledBlocks(t *testing.T) {
+	dataDir := t.TempDir()
+
+	fake := testutil.NewFake(avail_types.NewUCompactFromUInt(1))
+	d, _ := NewTestAvail(t, Sequencer)
+
+	sw, fraudResolver, _ := newTestSequencerWorkerOf(t, d, fake)
+	sw.unsettled = newUnsettledQueue(dataDir)
+
+	base := sw.blockchain.Header().Number
+	account := accounts.Account{Address: common.Address(sw.nodeAddr)}
+
+	// The submission fails for good.
+	fake.DropSubmissions(1)
+
+	err := sw.writeBlock(fraudResolver, account, &keystore.Key{PrivateKey: sw.nodeSignKey})
+	assert.ErrorIs(t, err, avail.ErrExtrinsicDropped)
+	assert.Equal(t, base, sw.blockchain.Header().Number)
+
+	unsettled := sw.unsettled.list()
+	if !assert.Len(t, unsettled, 1) {
+		return
+	}
+
+	blk := unsettled[0]
+	assert.Equal(t, base+1, blk.Number())
+
+	// The node restarts with the block unsettled.
+	sw, fraudResolver, _ = newTestSequencerWorkerOf(t, d, fake)
+
+	sw.unsettled, err = loadUnsettledQueue(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d.unsettled = sw.unsettled
+
+	status, err := NewStatusAPI(d).GetNodeStatus()
+	if assert.NoError(t, err) {
+		assert.Equal(t, 1, status.UnsettledBlocks)
+	}
+
+	clock, stop := startWriteBlocksLoop(t, sw, fraudResolver)
+	defer stop()
+
+	clock.tick()
+	waitForBlock(t, sw, base+1)
+
+	// The very block is resubmitted and settles.
+	assert.Equal(t, blk.Hash(), sw.blockchain.Header().Hash)
+	assert.Eventually(t, func() bool { return sw.unsettled.Len() == 0 }, 5*time.Second, 10*time.Millisecond)
+
+	submitted, err := fake.EdgeBlocks()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if assert.Len(t, submitted, 1) {
+		assert.Equal(t, blk.Hash(), submitted[0].Hash())
+	}
+
+	reloaded, err := loadUnsettledQueue(dataDir)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 0, reloaded.Len())
+	}
+
+	// The production resumes on top of it.
+	clock.tick()
+	waitForBlock(t, sw, base+2)
+}
+
+func TestUnsettledQueueDropsStaleBlocks(t *testing.T) {
+	fake := testutil.NewFake(avail_types.NewUCompactFromUInt(1))
+	sw, fraudResolver, _ := newTestSequencerWorker(t, fake)
+
+	clock := newFakeClock(time.Unix(int64(sw.blockchain.Header().Timestamp), 0))
+	sw.clock = clock
+
+	account := accounts.Account{Address: common.Address(sw.nodeAddr)}
+	key := &keystore.Key{PrivateKey: sw.nodeSignKey}
+
+	fake.DropSubmissions(1)
+	assert.Error(t, sw.writeBlock(fraudResolver, account, key))
+
+	stale := sw.unsettled.list()[0]
+
+	// Another block takes the height meanwhile.
+	clock.now = clock.now.Add(time.Minute)
+
+	if err := sw.unsettled.remove(stale.Hash()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sw.writeBlock(fraudResolver, account, key); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sw.unsettled.push(stale); err != nil {
+		t.Fatal(err)
+	}
+
+	head := sw.blockchain.Header()
+	assert.NotEqual(t, stale.Hash(), head.Hash)
+	assert.Equal(t, stale.Number(), head.Number)
+
+	assert.NoError(t, sw.resubmitUnsettled())
+	assert.Equal(t, 0, sw.unsettled.Len())
+	assert.Equal(t, head.Hash, sw.blockchain.Header().Hash)
+}
+
+func TestSequencerDoesNotResubmitPendingBlockAfterRestart(t *testing.T) {
+	dataDir := t.TempDir()
+
+	appID := avail_types.NewUCompactFromUInt(1)
+
+	fake := testutil.NewFake(appID, testutil.WithTxPool())
+	d, _ := NewTestAvail(t, Sequencer)
+
+	sw, fraudResolver, _ := newTestSequencerWorkerOf(t, d, fake)
+	sw.unsettled = newUnsettledQueue(dataDir)
+
+	ctx, kill := context.WithCancel(sw.ctx)
+	sw.ctx = ctx
+
+	base := sw.blockchain.Header().Number
+	account := accounts.Account{Address: common.Address(sw.nodeAddr)}
+
+	// The node is killed once the block is in the Avail pool, before its
+	// inclusion is confirmed.
+	written := make(chan error, 1)
+	go func() {
+		written <- sw.writeBlock(fraudResolver, account, &keystore.Key{PrivateKey: sw.nodeSignKey})
+	}()
+
+	assert.Eventually(t, func() bool { return fake.Pending() == 1 }, 5*time.Second, 10*time.Millisecond)
+	kill()
+	assert.ErrorIs(t, <-written, context.Canceled)
+
+	// The node restarts with the submission pending.
+	sw, fraudResolver, _ = newTestSequencerWorkerOf(t, d, fake)
+	sw.availClient, sw.availAppID = fake, appID
+
+	var err error
+	if sw.unsettled, err = loadUnsettledQueue(dataDir); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := sw.unsettled.entries()
+	if !assert.Len(t, entries, 1) {
+		return
+	}
+
+	blk := entries[0].Block
+	assert.Equal(t, submissionPending, entries[0].State)
+	assert.Equal(t, uint64(0), entries[0].Nonce)
+
+	clock, stop := startWriteBlocksLoop(t, sw, fraudResolver)
+	defer stop()
+
+	// Nothing is submitted while the pending extrinsic may still land.
+	clock.tick()
+	assert.Never(t, func() bool { return fake.Pending() != 1 || sw.blockchain.Header().Number != base }, 200*time.Millisecond, 10*time.Millisecond)
+
+	// Once included, the block settles as it is.
+	fake.Produce()
+
+	clock.tick()
+	waitForBlock(t, sw, base+1)
+	assert.Equal(t, blk.Hash(), sw.blockchain.Header().Hash)
+	assert.Eventually(t, func() bool { return sw.unsettled.Len() == 0 }, 5*time.Second, 10*time.Millisecond)
+
+	submitted, err := fake.EdgeBlocks()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if assert.Len(t, submitted, 1) {
+		assert.Equal(t, blk.Hash(), submitted[0].Hash())
+	}
+}
+
+func TestUnsettledBlockLapsed(t *testing.T) {
+	testCases := []struct {
+		name         string
+		u            unsettledBlock
+		head         uint64
+		accountNonce uint64
+		lapsed       bool
+	}{
+		{"in flight", unsettledBlock{Nonce: 3, Birth: 10, Mortality: 64}, 20, 3, false},
+		{"nonce used by another", unsettledBlock{Nonce: 3, Birth: 10, Mortality: 64}, 20, 4, true},
+		{"era lapsed", unsettledBlock{Nonce: 3, Birth: 10, Mortality: 64}, 74, 3, true},
+		{"immortal", unsettledBlock{Nonce: 3, Birth: 10}, 1000, 3, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.lapsed, tc.u.lapsed(tc.head, tc.accountNonce))
+		})
+	}
+}
+
+func TestUnsettledQueuePersistsSubmissions(t *testing.T) {
+	dataDir := t.TempDir()
+
+	sw, _, _ := newTestSequencerWorker(t, testutil.NewFake(avail_types.NewUCompactFromUInt(1)))
+	blk, _ := sw.blockchain.GetBlockByNumber(0, true)
+
+	q := newUnsettledQueue(dataDir)
+	assert.NoError(t, q.push(blk))
+	assert.NoError(t, q.submitted(avail.Submission{Nonce: 7, Birth: 40, Mortality: 64}, blk.Hash()))
+
+	reloaded, err := loadUnsettledQueue(dataDir)
+	if assert.NoError(t, err) && assert.Equal(t, 1, reloaded.Len()) {
+		u := reloaded.entries()[0]
+		assert.Equal(t, blk.Hash(), u.Hash())
+		assert.Equal(t, submissionPending, u.State)
+		assert.Equal(t, uint64(7), u.Nonce)
+		assert.Equal(t, uint64(40), u.Since)
+	}
+
+	// The files of the previous versions hold the blocks only.
+	legacy := `{"blocks":["` + hex.EncodeToHex(blk.MarshalRLP()) + `"]}`
+	if err := os.WriteFile(filepath.Join(dataDir, UnsettledBlocksFileName), []byte(legacy), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err = loadUnsettledQueue(dataDir)
+	if assert.NoError(t, err) && assert.Equal(t, 1, reloaded.Len()) {
+		u := reloaded.entries()[0]
+		assert.Equal(t, blk.Hash(), u.Hash())
+		assert.Equal(t, submissionQueued, u.State)
+	}
+}
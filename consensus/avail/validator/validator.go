@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/0xPolygon/polygon-edge/types"
 	"github.com/0xPolygon/polygon-edge/types/buildroot"
@@ -57,16 +58,49 @@ type validator struct {
 
 	logger           hclog.Logger
 	sequencerAddress types.Address
+
+	now               func() time.Time // Local clock the block timestamps are checked against
+	maxTimestampDrift time.Duration    // Time the block timestamps may run ahead of the clock
+}
+
+// Option configures the Validator.
+type Option func(v *validator)
+
+// WithMaxTimestampDrift sets the time the timestamps of the blocks may run
+// ahead of the local clock; zero leaves them unchecked.
+func WithMaxTimestampDrift(maxDrift time.Duration) Option {
+	return func(v *validator) {
+		v.maxTimestampDrift = maxDrift
+	}
+}
+
+// WithClock sets the local clock the timestamps of the blocks are checked
+// against.
+func WithClock(now func() time.Time) Option {
+	return func(v *validator) {
+		v.now = now
+	}
 }
 
 // New creates a new instance of Validator with the provided parameters.
-func New(blockchain *blockchain.Blockchain, sequencer types.Address, logger hclog.Logger) Validator {
-	return &validator{
+// The timestamps of the blocks may run ahead of the system clock by
+// block.DefaultMaxTimestampDrift, unless configured otherwise.
+func New(blockchain *blockchain.Blockchain, sequencer types.Address, logger hclog.Logger, opts ...Option) Validator {
+	v := &validator{
 		blockchain: blockchain,
 
 		logger:           logger.Named("validator"),
 		sequencerAddress: sequencer,
+
+		now:               time.Now,
+		maxTimestampDrift: block.DefaultMaxTimestampDrift,
+	}
+
+	for _, opt := range opts {
+		opt(v)
 	}
+
+	return v
 }
 
 // Apply applies a block to the blockchain by writing it to the blockchain.
@@ -186,7 +220,9 @@ func (v *validator) verifyHeader(header *types.Header) error {
 
 // verifyBlockParent verifies that the child block is in line with the locally saved parent block.
 // It checks the existence of the parent block, the matching of hashes, the matching of block numbers,
-// and the matching of gas limit/gas used.
+// the matching of gas limit/gas used, the Avail reference not going below the parent's, the timestamp
+// neither going below the parent's nor running ahead of the local clock, and the base fee following
+// the parent's, with the fees of the transactions holding against it.
 func (v *validator) verifyBlockParent(childBlk *types.Block) error {
 	// Grab the parent block
 	parentHash := childBlk.ParentHash()
@@ -239,6 +275,21 @@ func (v *validator) verifyBlockParent(childBlk *types.Block) error {
 		return fmt.Errorf("invalid gas limit, %w", gasLimitErr)
 	}
 
+	// Make sure the block isn't produced in a slot before the parent's
+	if err := block.VerifyAvailReference(childBlk.Header, parent); err != nil {
+		return err
+	}
+
+	// Make sure the block isn't stamped before the parent or far in the future
+	if err := block.VerifyTimestamp(childBlk.Header, parent, v.now(), v.maxTimestampDrift); err != nil {
+		return err
+	}
+
+	// Make sure the base fee follows the parent's and the transactions' fees hold against it
+	if err := block.VerifyFees(childBlk, v.blockchain.CalculateBaseFee(parent)); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -1,21 +1,21 @@
 package avail
 
 import (
-	"strings"
-
 	"github.com/0xPolygon/polygon-edge/types"
 	"github.com/availproject/op-evm/consensus/avail/watchtower"
 	"github.com/availproject/op-evm/pkg/avail"
 	"github.com/availproject/op-evm/pkg/block"
 	"github.com/availproject/op-evm/pkg/staking"
-	avail_types "github.com/centrifuge/go-substrate-rpc-client/v4/types"
 	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/accounts/keystore"
 )
 
 // runWatchTower is a method of the Avail structure that continuously monitors
 // and verifies the blockchain for the Avail system. It utilizes the watchtower concept
-// for blockchain monitoring and fraud detection. It operates until the node is closed.
+// for blockchain monitoring and fraud detection. It operates until the role is stopped,
+// on the close of the node or on the switch of its role.
+//
+// role is the run of the watchtower role.
 //
 // activeParticipantsQuerier is used to determine the active participants in the network.
 //
@@ -25,50 +25,90 @@ import (
 //
 // signKey is the private key used for signing the transactions.
 //
-// This function panics if it fails to find the avail call index.
-func (d *Avail) runWatchTower(activeParticipantsQuerier staking.ActiveParticipants, currentNodeSyncIndex uint64, myAccount accounts.Account, signKey *keystore.Key) {
+// Under the light sync, the blocks are written header-only, and re-executed
+// for a random sample of them only; see LightSyncConfig.
+//
+// This function panics if it fails to find the avail call index, or if the
+// storage fails to write a block.
+func (d *Avail) runWatchTower(role *roleRun, currentNodeSyncIndex uint64, myAccount accounts.Account, signKey *keystore.Key) {
 	logger := d.logger.Named("watchtower")
-	watchTower := watchtower.New(d.blockchain, d.executor, d.txpool, logger, types.Address(myAccount.Address), signKey.PrivateKey)
+	watchTower := watchtower.New(d.blockchain, d.executor, d.txpool, logger, types.Address(myAccount.Address), signKey.PrivateKey, d.production.MaxBlockSizeBytes, watchtower.WithMaxTimestampDrift(d.production.MaxTimestampDrift))
+
+	// The producers are checked against the active set of the slot, the
+	// same one the sequencers follow.
+	activeSet := staking.NewActiveSet(d.blockchain, d.executor, availBlockWindowLen)
 
 	// Start watching HEAD from Avail.
-	availBlockStream := d.availClient.BlockStream(currentNodeSyncIndex)
+	availBlockStream := d.availClient.BlockStream(role.ctx, currentNodeSyncIndex)
+
+	// The stream channel is closed once the run context is canceled; the
+	// shutdown itself is handled on the close channel.
+	availBlockCh := availBlockStream.Chan()
 
-	callIdx, err := avail.FindCallIndex(d.availClient)
-	if err != nil {
+	if _, err := avail.FindCallIndex(role.ctx, d.availClient); err != nil {
 		panic(err)
 	}
 
+	decoder := avail.NewBlockDecoder(d.availClient, d.availAppID, logger)
+
 	logger.Info("Watchtower started")
 
 	for {
 		select {
-		case <-d.closeCh:
+		case <-role.shutdown.closing():
 			if err := d.stakingNode.UnStake(signKey.PrivateKey); err != nil {
 				d.logger.Error("failed to unstake the node", "error", err)
 			}
 			availBlockStream.Close()
 			return
-		case availBlk := <-availBlockStream.Chan():
-			blks, err := avail.BlockFromAvail(availBlk, d.availAppID, callIdx, d.logger)
+		case availBlk, ok := <-availBlockCh:
+			if !ok {
+				availBlockCh = nil
+				continue
+			}
+
+			d.progress.seen(uint64(availBlk.Block.Header.Number))
+
+			if activeSet.Advance(uint64(availBlk.Block.Header.Number)) {
+				logger.Info("active participant set changed", "avail_block_number", availBlk.Block.Header.Number)
+			}
+
+			blks, err := decoder.Decode(role.ctx, availBlk)
 			if err != nil {
 				logger.Error("cannot extract Edge blocks from Avail block", "block_number", availBlk.Block.Header.Number, "error", err)
 				continue
 			}
 
 		blksLoop:
-			for _, blk := range blks {
+			for _, decoded := range blks {
+				blk := decoded.Block
+
 				d.logger.Debug("About to process block...", "block_number", blk.Header.Number, "hash", blk.Header.Hash.String(), "txns", len(blk.Transactions))
 
 				// Regardless of if block is malicious or not, apply it to the chain
-				if err := watchTower.Apply(blk); err != nil {
-					logger.Error("cannot apply block to blockchain", "block_number", blk.Header.Number, "block_hash", blk.Header.Hash, "error", err)
+				write, err := writeAvailBlock(d.forkChoice, d.breaker, decoded, inclusionOf(availBlk, decoded, types.ZeroAddress), block.SourceWatchTower, logger)
+				if err != nil {
+					availBlockStream.Close()
+					panic(err)
+				}
+
+				if write == blockWritten {
+					// Remove the transactions of the block from the txpool.
+					d.txpool.ResetWithHeaders(blk.Header)
+				}
+
+				// The blocks held back by the circuit breaker aren't checked.
+				if write == blockHalted {
+					continue blksLoop
 				}
 
+				stageActiveSet(activeSet, d.blockchain, blk, uint64(availBlk.Block.Header.Number), logger)
+
 				// Periodically verify that we are staked, before proceeding with watchtower
 				// logic. In the unexpected case of being slashed and dropping below the
 				// required watchtower staking threshold, we must stop processing, because
 				// otherwise we just get slashed more.
-				watchtowerStaked, sequencerError := activeParticipantsQuerier.Contains(d.minerAddr, staking.WatchTower)
+				watchtowerStaked, sequencerError := activeSet.ContainsParticipant(d.minerAddr, staking.WatchTower)
 				if sequencerError != nil {
 					d.logger.Error("failed to check if my account is among active staked watchtowers; cannot continue", "error", sequencerError)
 					continue blksLoop
@@ -79,42 +119,42 @@ func (d *Avail) runWatchTower(activeParticipantsQuerier staking.ActiveParticipan
 					continue blksLoop
 				}
 
-				err = watchTower.Check(blk)
-				if err != nil {
-					// TODO: We should implement something like SafeCheck() to not return errors that should not
-					// result in creating fraud proofs for blocks/transactions that should not be checked.
-					if err != nil {
-						if strings.Contains(err.Error(), "does not belong to active sequencers") {
-							continue blksLoop
-						}
-					}
+				// The blocks of the producers out of the active set aren't
+				// checked; they don't make it to the chain of the sequencers.
+				if active, err := activeProducer(activeSet, blk); err != nil || !active {
+					logger.Debug("skipping the block of a producer out of the active set", "block_number", blk.Number(), "block_hash", blk.Hash(), "error", err)
+					continue blksLoop
+				}
 
-					// Skip processing of fraudproof block. It's not written to blockchain on sequencers either.
-					_, exists := block.GetExtraDataFraudProofTarget(blk.Header)
-					if exists {
+				err = d.checkWatchedBlock(watchTower, blk)
+				if err != nil {
+					if !disputable(blk, err) {
 						continue blksLoop
 					}
 
 					logger.Info("Block verification failed. constructing fraudproof", "block_number", blk.Header.Number, "block_hash", blk.Header.Hash, "error", err)
 
-					fp, err := watchTower.ConstructFraudproof(blk)
-					if err != nil {
-						logger.Error("failed to construct fraudproof for block", "block_number", blk.Header.Number, "block_hash", blk.Header.Hash, "error", err)
-						continue blksLoop
+					if _, err := d.submitFraudProof(role.ctx, watchTower, blk, logger); err != nil {
+						logger.Error("failed to submit the fraudproof of the block", "block_number", blk.Header.Number, "block_hash", blk.Header.Hash, "error", err)
 					}
 
-					logger.Info("Submitting fraudproof", "block_hash", fp.Header.Hash)
-
-					err = d.availSender.SendAndWaitForStatus(fp, avail_types.ExtrinsicStatus{IsInBlock: true})
-					if err != nil {
-						logger.Error("Submitting fraud proof to avail failed", "error", err)
-						continue blksLoop
-					}
-
-					logger.Info("Submitted fraudproof", "block_number", fp.Header.Number, "block_hash", fp.Header.Hash, "txns", len(fp.Transactions))
 					continue blksLoop
 				}
 			}
+
+			// The blocks past their challenge window settle.
+			d.forkChoice.settle(uint64(availBlk.Block.Header.Number))
+
+			// The circuit breaker counts the fraud proofs and the disputes;
+			// the phase follows it. An illegal transition is logged by the
+			// phase machine.
+			d.breaker.observe(uint64(availBlk.Block.Header.Number), blks)
+
+			if d.breaker.Tripped() {
+				_ = d.phases.enter(PhaseCircuitBroken)
+			} else {
+				_ = d.phases.enter(PhaseActive)
+			}
 		}
 	}
 }
@@ -2,13 +2,16 @@ package watchtower
 
 import (
 	"crypto/ecdsa"
+	"encoding/binary"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/0xPolygon/polygon-edge/crypto"
 	"github.com/0xPolygon/polygon-edge/state"
 	"github.com/0xPolygon/polygon-edge/txpool"
 	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/consensus/avail/validator"
 	"github.com/availproject/op-evm/pkg/block"
 	"github.com/availproject/op-evm/pkg/blockchain"
 	"github.com/availproject/op-evm/pkg/staking"
@@ -30,6 +33,7 @@ var (
 type WatchTower interface {
 	Apply(blk *types.Block) error
 	Check(blk *types.Block) error
+	CheckStructure(blk *types.Block) error
 	ConstructFraudproof(blk *types.Block) (*types.Block, error)
 }
 
@@ -39,27 +43,68 @@ type watchTower struct {
 	executor            *state.Executor
 	txpool              *txpool.TxPool
 	blockBuilderFactory block.BlockBuilderFactory
+	validator           validator.Validator // Checks the blocks short of executing them, see CheckStructure
 	logger              hclog.Logger
+	maxBlockSize        uint64 // Size limit of the encoded blocks; zero means no limit
+
+	now               func() time.Time // Local clock the block timestamps are checked against
+	maxTimestampDrift time.Duration    // Time the block timestamps may run ahead of the clock
 
 	account types.Address
 	signKey *ecdsa.PrivateKey
 }
 
+// Option configures the WatchTower.
+type Option func(wt *watchTower)
+
+// WithMaxTimestampDrift sets the time the timestamps of the blocks may run
+// ahead of the local clock; zero leaves them unchecked.
+func WithMaxTimestampDrift(maxDrift time.Duration) Option {
+	return func(wt *watchTower) {
+		wt.maxTimestampDrift = maxDrift
+	}
+}
+
+// WithClock sets the local clock the timestamps of the blocks are checked
+// against.
+func WithClock(now func() time.Time) Option {
+	return func(wt *watchTower) {
+		wt.now = now
+	}
+}
+
 // New creates a new instance of WatchTower with the provided parameters.
-func New(blockchain *blockchain.Blockchain, executor *state.Executor, txp *txpool.TxPool, logger hclog.Logger, account types.Address, signKey *ecdsa.PrivateKey) WatchTower {
-	return &watchTower{
+// The blocks encoding larger than maxBlockSize bytes are invalid; zero means no limit.
+// The timestamps of the blocks may run ahead of the system clock by
+// block.DefaultMaxTimestampDrift, unless configured otherwise.
+func New(blockchain *blockchain.Blockchain, executor *state.Executor, txp *txpool.TxPool, logger hclog.Logger, account types.Address, signKey *ecdsa.PrivateKey, maxBlockSize uint64, opts ...Option) WatchTower {
+	wt := &watchTower{
 		blockchain:          blockchain,
 		executor:            executor,
 		txpool:              txp,
 		logger:              logger,
+		maxBlockSize:        maxBlockSize,
 		blockBuilderFactory: block.NewBlockBuilderFactory(blockchain, executor, hclog.Default()),
 
+		now:               time.Now,
+		maxTimestampDrift: block.DefaultMaxTimestampDrift,
+
 		account: account,
 		signKey: signKey,
 	}
+
+	for _, opt := range opts {
+		opt(wt)
+	}
+
+	wt.validator = validator.New(blockchain, account, logger, validator.WithMaxTimestampDrift(wt.maxTimestampDrift), validator.WithClock(wt.now))
+
+	return wt
 }
 
-// Check checks the validity of a block by verifying it using the local blockchain.
+// Check checks the validity of a block by verifying it using the local blockchain,
+// along with its Avail reference, its timestamp and its base fee against its parent
+// and the local clock, the fees of its transactions and its encoded size.
 // It returns an error if the block is invalid.
 func (wt *watchTower) Check(blk *types.Block) error {
 	if blk == nil {
@@ -70,11 +115,80 @@ func (wt *watchTower) Check(blk *types.Block) error {
 		return fmt.Errorf("%w: block.Header == nil", ErrInvalidBlock)
 	}
 
+	if err := block.VerifySize(blk, wt.maxBlockSize); err != nil {
+		wt.logger.Info("block cannot be verified", "block_number", blk.Number(), "block_hash", blk.Hash(), "parent_block_hash", blk.ParentHash(), "error", err)
+		return err
+	}
+
+	if err := block.VerifyTxSignatures(blk, wt.blockchain.TxSigner()); err != nil {
+		wt.logger.Info("block cannot be verified", "block_number", blk.Number(), "block_hash", blk.Hash(), "parent_block_hash", blk.ParentHash(), "error", err)
+		return err
+	}
+
 	if _, err := wt.blockchain.VerifyFinalizedBlock(blk); err != nil {
 		wt.logger.Info("block cannot be verified", "block_number", blk.Number(), "block_hash", blk.Hash(), "parent_block_hash", blk.ParentHash(), "error", err)
 		return err
 	}
 
+	parent, ok := wt.blockchain.GetHeaderByHash(blk.ParentHash())
+	if !ok {
+		return ErrParentBlockNotFound
+	}
+
+	if err := block.VerifyAvailReference(blk.Header, parent); err != nil {
+		wt.logger.Info("block cannot be verified", "block_number", blk.Number(), "block_hash", blk.Hash(), "parent_block_hash", blk.ParentHash(), "error", err)
+		return err
+	}
+
+	if err := block.VerifyTimestamp(blk.Header, parent, wt.now(), wt.maxTimestampDrift); err != nil {
+		wt.logger.Info("block cannot be verified", "block_number", blk.Number(), "block_hash", blk.Hash(), "parent_block_hash", blk.ParentHash(), "error", err)
+		return err
+	}
+
+	if err := block.VerifyFees(blk, wt.blockchain.CalculateBaseFee(parent)); err != nil {
+		wt.logger.Info("block cannot be verified", "block_number", blk.Number(), "block_hash", blk.Hash(), "parent_block_hash", blk.ParentHash(), "error", err)
+		return err
+	}
+
+	return nil
+}
+
+// CheckStructure checks the validity of a block the way Check does, short of
+// executing it: its encoded size, the signatures of its transactions, its seal
+// against the active sequencers, its transactions and uncles roots, and its
+// gas limit, Avail reference, timestamp and base fee against its parent, along
+// with the fees of its transactions. The blocks passing it may still fail
+// Check on their execution.
+// It returns an error if the block is invalid.
+func (wt *watchTower) CheckStructure(blk *types.Block) error {
+	if blk == nil {
+		return fmt.Errorf("%w: block == nil", ErrInvalidBlock)
+	}
+
+	if blk.Header == nil {
+		return fmt.Errorf("%w: block.Header == nil", ErrInvalidBlock)
+	}
+
+	if err := block.VerifySize(blk, wt.maxBlockSize); err != nil {
+		wt.logger.Info("block cannot be verified", "block_number", blk.Number(), "block_hash", blk.Hash(), "parent_block_hash", blk.ParentHash(), "error", err)
+		return err
+	}
+
+	if err := block.VerifyTxSignatures(blk, wt.blockchain.TxSigner()); err != nil {
+		wt.logger.Info("block cannot be verified", "block_number", blk.Number(), "block_hash", blk.Hash(), "parent_block_hash", blk.ParentHash(), "error", err)
+		return err
+	}
+
+	if err := wt.blockchain.GetConsensus().VerifyHeader(blk.Header); err != nil {
+		wt.logger.Info("block cannot be verified", "block_number", blk.Number(), "block_hash", blk.Hash(), "parent_block_hash", blk.ParentHash(), "error", err)
+		return err
+	}
+
+	if err := wt.validator.Check(blk); err != nil {
+		wt.logger.Info("block cannot be verified", "block_number", blk.Number(), "block_hash", blk.Hash(), "parent_block_hash", blk.ParentHash(), "error", err)
+		return err
+	}
+
 	return nil
 }
 
@@ -137,6 +251,15 @@ func (wt *watchTower) ConstructFraudproof(maliciousBlock *types.Block) (*types.B
 	)
 
 	// Build the block that is going to be sent out to the Avail.
+	// A transaction of an invalid signature is evidence on its own; the
+	// fraudproof points at it, for the verifiers to check it alone.
+	var sigErr *block.TxSignatureError
+	if errors.As(block.VerifyTxSignatures(maliciousBlock, wt.blockchain.TxSigner()), &sigErr) {
+		builder.
+			SetExtraDataField(block.KeyFraudProofViolation, []byte{byte(block.FraudViolationTxSignature)}).
+			SetExtraDataField(block.KeyFraudProofTxIndex, binary.BigEndian.AppendUint64(nil, uint64(sigErr.Index)))
+	}
+
 	blk, err := builder.
 		SetCoinbaseAddress(wt.account).
 		SetGasLimit(maliciousBlock.Header.GasLimit).
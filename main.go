@@ -8,7 +8,10 @@ import (
 
 	"github.com/availproject/op-evm/cmd/availaccount"
 	"github.com/availproject/op-evm/cmd/devnet"
+	"github.com/availproject/op-evm/cmd/genesis"
+	"github.com/availproject/op-evm/cmd/integrity"
 	"github.com/availproject/op-evm/cmd/server"
+	"github.com/availproject/op-evm/cmd/status"
 	"github.com/availproject/op-evm/cmd/tail"
 )
 
@@ -20,8 +23,11 @@ func main() {
 		server.GetCommand(),
 		availaccount.GetCommand(),
 		devnet.GetCommand(),
+		genesis.GetCommand(),
 		secrets.GetCommand(),
 		tail.GetCommand(),
+		integrity.GetCommand(),
+		status.GetCommand(),
 	)
 	if err := cmd.Execute(); err != nil {
 		log.Fatal(err)
@@ -1,6 +1,7 @@
 package avail
 
 import (
+	"context"
 	"fmt"
 	"math/big"
 	"os"
@@ -64,19 +65,19 @@ func AccountFromFile(filePath string) (signature.KeyringPair, error) {
 }
 
 // AccountExistsFromMnemonic checks if an Avail account exists on the blockchain using the provided mnemonic phrase.
-// It takes a client and the file path of the mnemonic phrase, and returns a boolean indicating if the account exists and an error if there is an issue.
-func AccountExistsFromMnemonic(client Client, filePath string) (bool, error) {
+// It takes a context, a client and the file path of the mnemonic phrase, and returns a boolean indicating if the account exists and an error if there is an issue.
+func AccountExistsFromMnemonic(ctx context.Context, client Client, filePath string) (bool, error) {
 	account, err := AccountFromFile(filePath)
 	if err != nil {
 		return false, err
 	}
 
-	api, err := instance(client)
+	c, err := endpoint(client)
 	if err != nil {
 		return false, err
 	}
 
-	meta, err := api.RPC.State.GetMetadataLatest()
+	meta, err := c.getMetadata(ctx, nil)
 	if err != nil {
 		return false, err
 	}
@@ -87,61 +88,50 @@ func AccountExistsFromMnemonic(client Client, filePath string) (bool, error) {
 	}
 
 	var accountInfo types.AccountInfo
-	return api.RPC.State.GetStorageLatest(key, &accountInfo)
+	return c.getStorageLatest(ctx, key, &accountInfo)
 }
 
 // DepositBalance deposits a specified amount of Avail tokens from the specified account to the specified recipient.
-// It takes a client, the account key pair, the amount to deposit, and the nonce increment.
+// It takes a context, a client, the SignatureProvider of the recipient account and the amount to deposit.
 // It returns an error if there is an issue.
-func DepositBalance(client Client, account signature.KeyringPair, amount, nonceIncrement uint64) error {
-	api, err := instance(client)
+func DepositBalance(ctx context.Context, client Client, account SignatureProvider, amount uint64) error {
+	c, err := endpoint(client)
 	if err != nil {
 		return err
 	}
 
-	meta, err := api.RPC.State.GetMetadataLatest()
+	meta, err := c.getMetadata(ctx, nil)
 	if err != nil {
 		return err
 	}
 
-	addr, err := types.NewMultiAddressFromAccountID(account.PublicKey)
+	addr, err := types.NewMultiAddressFromAccountID(account.PublicKey())
 	if err != nil {
 		return err
 	}
 
-	c, err := types.NewCall(meta, "Balances.transfer", addr, types.NewUCompactFromUInt(amount))
+	call, err := types.NewCall(meta, "Balances.transfer", addr, types.NewUCompactFromUInt(amount))
 	if err != nil {
 		return err
 	}
 
 	// Create the extrinsic
-	ext := types.NewExtrinsic(c)
+	ext := types.NewExtrinsic(call)
 
-	genesisHash, err := api.RPC.Chain.GetBlockHash(0)
-	if err != nil {
-		return err
-	}
+	genesisHash := c.genesisHash
 
-	rv, err := api.RPC.State.GetRuntimeVersionLatest()
+	rv, err := c.getRuntimeVersion(ctx, nil)
 	if err != nil {
 		return err
 	}
 
-	key, err := types.CreateStorageKey(meta, "System", "Account", signature.TestKeyringPairAlice.PublicKey, nil)
-	if err != nil {
-		return err
-	}
+	// Concurrent deposits are all signed by Alice; let the nonce manager
+	// serialize them.
+	nonces := accountNonceManager(client, signature.TestKeyringPairAlice.PublicKey)
 
-	var accountInfo types.AccountInfo
-	ok, err := api.RPC.State.GetStorageLatest(key, &accountInfo)
-	if err != nil || !ok {
-		return fmt.Errorf("couldn't fetch latest alice account storage info: %w", err)
-	}
-
-	nonce := uint64(accountInfo.Nonce)
-
-	if nonceIncrement > 0 {
-		nonce = nonce + nonceIncrement
+	nonce, err := nonces.Next(ctx)
+	if err != nil {
+		return fmt.Errorf("couldn't fetch latest alice account nonce: %w", err)
 	}
 
 	o := types.SignatureOptions{
@@ -156,17 +146,21 @@ func DepositBalance(client Client, account signature.KeyringPair, amount, nonceI
 	}
 
 	// Sign the transaction using Alice's default account
-	err = ext.Sign(signature.TestKeyringPairAlice, o)
+	err = signExtrinsic(&ext, NewKeyringSigner(signature.TestKeyringPairAlice), o)
 	if err != nil {
+		nonces.Failed(nonce, err)
 		return err
 	}
 
 	// Send the extrinsic
-	sub, err := api.RPC.Author.SubmitAndWatchExtrinsic(ext)
+	sub, err := c.submitAndWatchExtrinsic(ctx, ext)
 	if err != nil {
+		nonces.Failed(nonce, err)
 		return err
 	}
 
+	nonces.Done(nonce)
+
 	defer sub.Unsubscribe()
 
 	for {
@@ -180,39 +174,57 @@ func DepositBalance(client Client, account signature.KeyringPair, amount, nonceI
 				return nil
 			default:
 				if status.IsDropped || status.IsInvalid {
+					nonces.Reset()
 					return fmt.Errorf("unexpected extrinsic status from Avail: %#v", status)
 				}
 			}
 		case err := <-sub.Err():
 			// TODO: Consider re-connecting subscription channel on error?
 			return err
+		case <-ctx.Done():
+			return ctx.Err()
 		}
 	}
 }
 
 // GetBalance retrieves the Avail token balance of the specified account.
-// It takes a client and the account key pair, and returns the account balance as a *big.Int and an error if there is an issue.
-func GetBalance(client Client, account signature.KeyringPair) (*big.Int, error) {
-	api, err := instance(client)
+// It takes a context, a client and the SignatureProvider of the account, and returns the account balance as a *big.Int and an error if there is an issue.
+func GetBalance(ctx context.Context, client Client, account SignatureProvider) (*big.Int, error) {
+	balance, err := GetFreeBalance(ctx, client, account)
 	if err != nil {
 		return nil, err
 	}
 
-	meta, err := api.RPC.State.GetMetadataLatest()
+	return new(big.Int).Div(balance, big.NewInt(AVL)), nil
+}
+
+// GetFreeBalance retrieves the free balance of the specified account in Avail token fractions.
+// It takes a context, a client and the SignatureProvider of the account, and returns zero balance for accounts that do not exist yet.
+func GetFreeBalance(ctx context.Context, client Client, account SignatureProvider) (*big.Int, error) {
+	c, err := endpoint(client)
 	if err != nil {
 		return nil, err
 	}
 
-	key, err := types.CreateStorageKey(meta, "System", "Account", account.PublicKey, nil)
+	meta, err := c.getMetadata(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := types.CreateStorageKey(meta, "System", "Account", account.PublicKey(), nil)
 	if err != nil {
 		return nil, err
 	}
 
 	var accountInfo types.AccountInfo
-	ok, err := api.RPC.State.GetStorageLatest(key, &accountInfo)
-	if err != nil || !ok {
+	ok, err := c.getStorageLatest(ctx, key, &accountInfo)
+	if err != nil {
 		return nil, err
 	}
 
-	return new(big.Int).Div(new(big.Int).SetUint64(accountInfo.Data.Free.Uint64()), big.NewInt(AVL)), nil
+	if !ok || accountInfo.Data.Free.Int == nil {
+		return big.NewInt(0), nil
+	}
+
+	return new(big.Int).Set(accountInfo.Data.Free.Int), nil
 }
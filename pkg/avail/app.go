@@ -0,0 +1,98 @@
+package avail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+)
+
+var (
+	// ErrAppIDMismatch is the error returned when the configured AppID doesn't match the one registered on Avail.
+	ErrAppIDMismatch = errors.New("AppID doesn't match the application key")
+
+	// ErrEmptyApplicationKey is the error returned when no application key is configured.
+	ErrEmptyApplicationKey = errors.New("empty application key")
+)
+
+// AppConfig holds the Avail application configuration of a settlement chain.
+// Every chain submitting to the same Avail network must use its own
+// application key, so that its data can be told apart from the others.
+type AppConfig struct {
+	// Key is the Avail application key of the chain.
+	Key string
+
+	// ID is the expected AppID of the application key. Zero means that the
+	// AppID is taken from Avail as it is.
+	ID uint64
+
+	// Create determines whether the application key is created on Avail when it doesn't exist.
+	Create bool
+}
+
+// DefaultAppConfig returns the default Avail application configuration.
+func DefaultAppConfig() AppConfig {
+	return AppConfig{
+		Key:    ApplicationKey,
+		Create: true,
+	}
+}
+
+// ResolveAppID validates the application configuration against Avail and
+// returns the AppID to stamp on submissions and to filter incoming blocks with.
+// It takes a context, a client, the application configuration and the
+// SignatureProvider used when the application key has to be created.
+// It returns the AppID and an error if there is an issue.
+func ResolveAppID(ctx context.Context, client Client, cfg AppConfig, signer SignatureProvider) (types.UCompact, error) {
+	if cfg.Key == "" {
+		return types.NewUCompactFromUInt(0), ErrEmptyApplicationKey
+	}
+
+	var (
+		appID types.UCompact
+		err   error
+	)
+
+	if cfg.Create {
+		appID, err = EnsureApplicationKeyExists(ctx, client, cfg.Key, signer)
+	} else {
+		appID, err = QueryAppID(ctx, client, cfg.Key)
+	}
+
+	if err != nil {
+		return types.NewUCompactFromUInt(0), fmt.Errorf("application key %q: %w", cfg.Key, err)
+	}
+
+	if cfg.ID != 0 && uint64(appID.Int64()) != cfg.ID {
+		return types.NewUCompactFromUInt(0), fmt.Errorf("%w %q: configured %d, registered %d", ErrAppIDMismatch, cfg.Key, cfg.ID, appID.Int64())
+	}
+
+	return appID, nil
+}
+
+// FilterAppExtrinsics returns the extrinsics of the Avail block that were
+// submitted with the given AppID, keeping their order.
+func FilterAppExtrinsics(blk *types.SignedBlock, appID types.UCompact) []types.Extrinsic {
+	var extrinsics []types.Extrinsic
+
+	for _, extrinsic := range blk.Block.Extrinsics {
+		if extrinsic.Signature.AppID.Int64() == appID.Int64() {
+			extrinsics = append(extrinsics, extrinsic)
+		}
+	}
+
+	return extrinsics
+}
+
+// hasAppExtrinsics returns true when the Avail block contains at least one
+// extrinsic submitted with the given AppID.
+func hasAppExtrinsics(blk *types.SignedBlock, appID types.UCompact) bool {
+	for _, extrinsic := range blk.Block.Extrinsics {
+		if extrinsic.Signature.AppID.Int64() == appID.Int64() {
+			return true
+		}
+	}
+
+	return false
+}
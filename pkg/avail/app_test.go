@@ -0,0 +1,93 @@
+package avail
+
+import (
+	"context"
+	"testing"
+
+	edge_types "github.com/0xPolygon/polygon-edge/types"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types/codec"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+// blobExtrinsic builds a `submit_data` extrinsic carrying the given Edge block,
+// encoded the same way as the sender does it.
+func blobExtrinsic(t *testing.T, appID types.UCompact, callIdx types.CallIndex, blk *edge_types.Block) types.Extrinsic {
+	t.Helper()
+
+	encodedBlob, err := codec.Encode(Blob{Magic: BlobMagic, Data: blk.MarshalRLP()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	args, err := codec.Encode(encodedBlob)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ext := types.Extrinsic{}
+	ext.Method.CallIndex = callIdx
+	ext.Method.Args = args
+	ext.Signature.AppID = appID
+
+	return ext
+}
+
+func TestBlockFromAvailFiltersAppID(t *testing.T) {
+	var (
+		callIdx = types.CallIndex{SectionIndex: 7, MethodIndex: 1}
+		ourApp  = types.NewUCompactFromUInt(3)
+		their   = types.NewUCompactFromUInt(4)
+	)
+
+	edgeBlk := func(number uint64) *edge_types.Block {
+		return &edge_types.Block{Header: &edge_types.Header{Number: number, Difficulty: 1}}
+	}
+
+	blk := &types.SignedBlock{
+		Block: types.Block{
+			Header: types.Header{Number: 1},
+			Extrinsics: []types.Extrinsic{
+				blobExtrinsic(t, ourApp, callIdx, edgeBlk(1)),
+				blobExtrinsic(t, their, callIdx, edgeBlk(100)),
+				blobExtrinsic(t, their, callIdx, edgeBlk(101)),
+				blobExtrinsic(t, ourApp, callIdx, edgeBlk(2)),
+				blobExtrinsic(t, their, callIdx, edgeBlk(102)),
+				blobExtrinsic(t, ourApp, callIdx, edgeBlk(3)),
+			},
+		},
+	}
+
+	assert.Len(t, FilterAppExtrinsics(blk, ourApp), 3)
+	assert.Len(t, FilterAppExtrinsics(blk, their), 3)
+
+	for _, tc := range []struct {
+		appID  types.UCompact
+		expect []uint64
+	}{
+		{appID: ourApp, expect: []uint64{1, 2, 3}},
+		{appID: their, expect: []uint64{100, 101, 102}},
+	} {
+		blks, err := BlockFromAvail(blk, tc.appID, callIdx, hclog.NewNullLogger())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var numbers []uint64
+		for _, b := range blks {
+			numbers = append(numbers, b.Number())
+		}
+
+		assert.Equal(t, tc.expect, numbers)
+	}
+
+	// Blocks without any of our extrinsics are skipped.
+	_, err := BlockFromAvail(blk, types.NewUCompactFromUInt(5), callIdx, hclog.NewNullLogger())
+	assert.ErrorIs(t, err, ErrNoExtrinsicFound)
+}
+
+func TestResolveAppIDEmptyKey(t *testing.T) {
+	_, err := ResolveAppID(context.Background(), nil, AppConfig{}, nil)
+	assert.ErrorIs(t, err, ErrEmptyApplicationKey)
+}
@@ -1,10 +1,10 @@
 package avail
 
 import (
+	"context"
 	"errors"
 	"fmt"
 
-	"github.com/centrifuge/go-substrate-rpc-client/v4/signature"
 	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
 	"github.com/centrifuge/go-substrate-rpc-client/v4/types/codec"
 )
@@ -26,12 +26,12 @@ var (
 )
 
 // EnsureApplicationKeyExists checks if the application key exists on the blockchain. If it doesn't exist, it creates a new application key.
-// It takes a client, the application key string, and the signing key pair.
+// It takes a context, a client, the application key string, and the SignatureProvider of the signing account.
 // It returns the AppID and an error if there is an issue.
-func EnsureApplicationKeyExists(client Client, applicationKey string, signingKeyPair signature.KeyringPair) (types.UCompact, error) {
-	appID, err := QueryAppID(client, applicationKey)
+func EnsureApplicationKeyExists(ctx context.Context, client Client, applicationKey string, signer SignatureProvider) (types.UCompact, error) {
+	appID, err := QueryAppID(ctx, client, applicationKey)
 	if errors.Is(err, ErrAppIDNotFound) {
-		appID, err = CreateApplicationKey(client, applicationKey, signingKeyPair)
+		appID, err = CreateApplicationKey(ctx, client, applicationKey, signer)
 		if err != nil {
 			return types.NewUCompactFromUInt(0), err
 		}
@@ -43,15 +43,15 @@ func EnsureApplicationKeyExists(client Client, applicationKey string, signingKey
 }
 
 // QueryAppID retrieves the AppID associated with the application key.
-// It takes a client and the application key string.
+// It takes a context, a client and the application key string.
 // It returns the AppID and an error if there is an issue.
-func QueryAppID(client Client, applicationKey string) (types.UCompact, error) {
-	api, err := instance(client)
+func QueryAppID(ctx context.Context, client Client, applicationKey string) (types.UCompact, error) {
+	c, err := endpoint(client)
 	if err != nil {
 		return types.NewUCompactFromUInt(0), err
 	}
 
-	meta, err := api.RPC.State.GetMetadataLatest()
+	meta, err := c.getMetadata(ctx, nil)
 	if err != nil {
 		return types.NewUCompactFromUInt(0), err
 	}
@@ -72,7 +72,7 @@ func QueryAppID(client Client, applicationKey string) (types.UCompact, error) {
 	}
 
 	var aki AppKeyInfo
-	ok, err := api.RPC.State.GetStorageLatest(key, &aki)
+	ok, err := c.getStorageLatest(ctx, key, &aki)
 	if err != nil {
 		return types.NewUCompactFromUInt(0), err
 	}
@@ -85,15 +85,15 @@ func QueryAppID(client Client, applicationKey string) (types.UCompact, error) {
 }
 
 // CreateApplicationKey creates a new application key on the blockchain.
-// It takes a client, the application key string, and the signing key pair.
+// It takes a context, a client, the application key string, and the SignatureProvider of the signing account.
 // It returns the AppID and an error if there is an issue.
-func CreateApplicationKey(client Client, applicationKey string, signingKeyPair signature.KeyringPair) (types.UCompact, error) {
-	api, err := instance(client)
+func CreateApplicationKey(ctx context.Context, client Client, applicationKey string, signer SignatureProvider) (types.UCompact, error) {
+	c, err := endpoint(client)
 	if err != nil {
 		return types.NewUCompactFromUInt(0), err
 	}
 
-	meta, err := api.RPC.State.GetMetadataLatest()
+	meta, err := c.getMetadata(ctx, nil)
 	if err != nil {
 		return types.NewUCompactFromUInt(0), err
 	}
@@ -105,28 +105,20 @@ func CreateApplicationKey(client Client, applicationKey string, signingKeyPair s
 
 	ext := types.NewExtrinsic(call)
 
-	rv, err := api.RPC.State.GetRuntimeVersionLatest()
+	rv, err := c.getRuntimeVersion(ctx, nil)
 	if err != nil {
 		return types.NewUCompactFromUInt(0), err
 	}
 
-	key, err := types.CreateStorageKey(meta, "System", "Account", signingKeyPair.PublicKey)
-	if err != nil {
-		return types.NewUCompactFromUInt(0), err
-	}
+	genesisHash := c.genesisHash
 
-	var accountInfo types.AccountInfo
-	ok, err := api.RPC.State.GetStorageLatest(key, &accountInfo)
-	if err != nil || !ok {
-		return types.NewUCompactFromUInt(0), fmt.Errorf("couldn't fetch latest account storage info: %w", err)
-	}
+	nonces := accountNonceManager(client, signer.PublicKey())
 
-	genesisHash, err := api.RPC.Chain.GetBlockHash(0)
+	nonce, err := nonces.Next(ctx)
 	if err != nil {
-		return types.NewUCompactFromUInt(0), err
+		return types.NewUCompactFromUInt(0), fmt.Errorf("couldn't fetch latest account nonce: %w", err)
 	}
 
-	nonce := uint64(accountInfo.Nonce)
 	o := types.SignatureOptions{
 		// This transaction is Immortal (https://wiki.polkadot.network/docs/build-protocol-info#transaction-mortality)
 		// Hence BlockHash: Genesis Hash.
@@ -140,31 +132,39 @@ func CreateApplicationKey(client Client, applicationKey string, signingKeyPair s
 		TransactionVersion: rv.TransactionVersion,
 	}
 
-	err = ext.Sign(signingKeyPair, o)
+	err = signExtrinsic(&ext, signer, o)
 	if err != nil {
+		nonces.Failed(nonce, err)
 		return types.NewUCompactFromUInt(0), err
 	}
 
-	sub, err := api.RPC.Author.SubmitAndWatchExtrinsic(ext)
+	sub, err := c.submitAndWatchExtrinsic(ctx, ext)
 	if err != nil {
+		nonces.Failed(nonce, err)
 		return types.NewUCompactFromUInt(0), err
 	}
 
+	nonces.Done(nonce)
+
 	defer sub.Unsubscribe()
 
 	for {
 		select {
 		case status := <-sub.Chan():
 			if status.IsInBlock {
-				return QueryAppID(client, applicationKey)
+				return QueryAppID(ctx, client, applicationKey)
 			}
 
 			if status.IsDropped || status.IsInvalid {
+				nonces.Reset()
 				return types.NewUCompactFromUInt(0), fmt.Errorf("unexpected extrinsic status from Avail: %#v", status)
 			}
 
 		case err = <-sub.Err():
 			return types.NewUCompactFromUInt(0), fmt.Errorf("error while waiting for application key creation status: %w", err)
+
+		case <-ctx.Done():
+			return types.NewUCompactFromUInt(0), ctx.Err()
 		}
 	}
 }
@@ -0,0 +1,212 @@
+package avail
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+	"sync/atomic"
+
+	"github.com/armon/go-metrics"
+	"github.com/hashicorp/go-hclog"
+)
+
+// LowBalancePolicy determines how the node reacts when the Avail account
+// balance drops below the configured threshold.
+type LowBalancePolicy string
+
+const (
+	// LowBalancePolicyWarn only logs a warning when the balance is low.
+	LowBalancePolicyWarn LowBalancePolicy = "warn"
+
+	// LowBalancePolicyPause signals block production to pause until the account is topped up.
+	LowBalancePolicyPause LowBalancePolicy = "pause"
+)
+
+const (
+	// DefaultBalancePollBlocks is the default number of Avail blocks between balance polls.
+	DefaultBalancePollBlocks = 10
+
+	// DefaultMinSubmissionsLeft is the default number of estimated submissions
+	// left below which the balance is considered low.
+	DefaultMinSubmissionsLeft = 100
+)
+
+// DefaultSubmissionFee is the default estimated fee of a single block
+// submission, in Avail token fractions (0.01 AVL).
+var DefaultSubmissionFee = new(big.Int).Div(big.NewInt(AVL), big.NewInt(100))
+
+// ParseLowBalancePolicy parses the low-balance policy from its string representation.
+func ParseLowBalancePolicy(policy string) (LowBalancePolicy, error) {
+	switch LowBalancePolicy(policy) {
+	case LowBalancePolicyWarn, LowBalancePolicyPause:
+		return LowBalancePolicy(policy), nil
+	default:
+		return "", fmt.Errorf("invalid low balance policy: %q", policy)
+	}
+}
+
+// BalanceMonitorConfig holds the configuration of the BalanceMonitor.
+type BalanceMonitorConfig struct {
+	// Policy is the action to take when the balance is low.
+	Policy LowBalancePolicy
+
+	// PollBlocks is the number of Avail blocks between balance polls.
+	PollBlocks uint64
+
+	// SubmissionFee is the estimated fee of a single submission, in Avail token fractions.
+	SubmissionFee *big.Int
+
+	// MinSubmissionsLeft is the number of estimated submissions left below
+	// which the low-balance policy is triggered.
+	MinSubmissionsLeft uint64
+}
+
+// DefaultBalanceMonitorConfig returns the default BalanceMonitor configuration.
+func DefaultBalanceMonitorConfig() BalanceMonitorConfig {
+	return BalanceMonitorConfig{
+		Policy:             LowBalancePolicyWarn,
+		PollBlocks:         DefaultBalancePollBlocks,
+		SubmissionFee:      new(big.Int).Set(DefaultSubmissionFee),
+		MinSubmissionsLeft: DefaultMinSubmissionsLeft,
+	}
+}
+
+// BalanceFunc returns the current free balance of the submission account, in Avail token fractions.
+type BalanceFunc func(ctx context.Context) (*big.Int, error)
+
+// AccountBalanceFunc returns a BalanceFunc that queries the free balance of the given account from Avail.
+func AccountBalanceFunc(client Client, account SignatureProvider) BalanceFunc {
+	return func(ctx context.Context) (*big.Int, error) {
+		return GetFreeBalance(ctx, client, account)
+	}
+}
+
+// BalanceMonitor polls the balance of the Avail submission account every
+// configured number of Avail blocks, reports it as metrics and applies the
+// low-balance policy.
+type BalanceMonitor struct {
+	config    BalanceMonitorConfig
+	balanceFn BalanceFunc
+	logger    hclog.Logger
+
+	lock           sync.RWMutex
+	balance        *big.Int
+	submissionsCnt uint64
+	lastPolled     uint64
+	polled         bool
+
+	paused *atomic.Bool
+}
+
+// NewBalanceMonitor creates a new BalanceMonitor.
+func NewBalanceMonitor(balanceFn BalanceFunc, config BalanceMonitorConfig, logger hclog.Logger) *BalanceMonitor {
+	if config.PollBlocks == 0 {
+		config.PollBlocks = DefaultBalancePollBlocks
+	}
+
+	if config.SubmissionFee == nil || config.SubmissionFee.Sign() <= 0 {
+		config.SubmissionFee = new(big.Int).Set(DefaultSubmissionFee)
+	}
+
+	return &BalanceMonitor{
+		config:    config,
+		balanceFn: balanceFn,
+		logger:    logger.Named("balance_monitor"),
+		balance:   big.NewInt(0),
+		paused:    new(atomic.Bool),
+	}
+}
+
+// OnAvailBlock notifies the monitor about a new Avail block. The balance is
+// polled when at least PollBlocks Avail blocks have passed since the last poll.
+func (bm *BalanceMonitor) OnAvailBlock(ctx context.Context, number uint64) error {
+	bm.lock.RLock()
+	due := !bm.polled || number >= bm.lastPolled+bm.config.PollBlocks
+	bm.lock.RUnlock()
+
+	if !due {
+		return nil
+	}
+
+	if err := bm.Poll(ctx); err != nil {
+		return err
+	}
+
+	bm.lock.Lock()
+	bm.lastPolled = number
+	bm.lock.Unlock()
+
+	return nil
+}
+
+// Poll queries the account balance and applies the low-balance policy.
+func (bm *BalanceMonitor) Poll(ctx context.Context) error {
+	balance, err := bm.balanceFn(ctx)
+	if err != nil {
+		return err
+	}
+
+	submissions := new(big.Int).Div(balance, bm.config.SubmissionFee).Uint64()
+
+	bm.lock.Lock()
+	bm.balance = balance
+	bm.submissionsCnt = submissions
+	bm.polled = true
+	bm.lock.Unlock()
+
+	balanceAVL, _ := new(big.Float).Quo(new(big.Float).SetInt(balance), big.NewFloat(AVL)).Float32()
+	metrics.SetGauge([]string{"avail", "account_balance"}, balanceAVL)
+	metrics.SetGauge([]string{"avail", "submissions_remaining"}, float32(submissions))
+
+	if submissions < bm.config.MinSubmissionsLeft {
+		bm.logger.Warn(
+			"Avail account balance is low",
+			"balance", balance,
+			"submissions_remaining", submissions,
+			"threshold", bm.config.MinSubmissionsLeft,
+			"policy", bm.config.Policy,
+		)
+
+		if bm.config.Policy == LowBalancePolicyPause && bm.paused.CompareAndSwap(false, true) {
+			bm.logger.Error("pausing block production until Avail account is topped up", "balance", balance)
+		}
+
+		return nil
+	}
+
+	if bm.paused.CompareAndSwap(true, false) {
+		bm.logger.Info("Avail account topped up; resuming block production", "balance", balance, "submissions_remaining", submissions)
+	}
+
+	return nil
+}
+
+// Paused returns true when block production should be paused due to low balance.
+func (bm *BalanceMonitor) Paused() bool {
+	return bm.paused.Load()
+}
+
+// Polled reports whether the balance has been polled yet.
+func (bm *BalanceMonitor) Polled() bool {
+	bm.lock.RLock()
+	defer bm.lock.RUnlock()
+
+	return bm.polled
+}
+
+// Balance returns the last observed balance, in Avail token fractions.
+func (bm *BalanceMonitor) Balance() *big.Int {
+	bm.lock.RLock()
+	defer bm.lock.RUnlock()
+
+	return new(big.Int).Set(bm.balance)
+}
+
+// SubmissionsRemaining returns the estimated number of submissions the last observed balance can pay for.
+func (bm *BalanceMonitor) SubmissionsRemaining() uint64 {
+	bm.lock.RLock()
+	defer bm.lock.RUnlock()
+
+	return bm.submissionsCnt
+}
@@ -0,0 +1,90 @@
+package avail
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+// submissions returns the balance that pays for `n` submissions of given fee.
+func submissions(n int64, fee *big.Int) *big.Int {
+	return new(big.Int).Mul(big.NewInt(n), fee)
+}
+
+func TestBalanceMonitorLowBalancePolicy(t *testing.T) {
+	testCases := []struct {
+		name         string
+		policy       LowBalancePolicy
+		expectPaused bool
+	}{
+		{
+			name:         "warn policy keeps producing",
+			policy:       LowBalancePolicyWarn,
+			expectPaused: false,
+		},
+		{
+			name:         "pause policy pauses production",
+			policy:       LowBalancePolicyPause,
+			expectPaused: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fee := big.NewInt(1_000)
+			balance := submissions(150, fee)
+
+			bm := NewBalanceMonitor(func(context.Context) (*big.Int, error) { return balance, nil }, BalanceMonitorConfig{
+				Policy:             tc.policy,
+				PollBlocks:         5,
+				SubmissionFee:      fee,
+				MinSubmissionsLeft: 100,
+			}, hclog.NewNullLogger())
+
+			assert.NoError(t, bm.OnAvailBlock(context.Background(), 1))
+			assert.False(t, bm.Paused())
+			assert.Equal(t, uint64(150), bm.SubmissionsRemaining())
+
+			// Balance drops below threshold, but it's not polled until PollBlocks have passed.
+			balance = submissions(50, fee)
+
+			assert.NoError(t, bm.OnAvailBlock(context.Background(), 5))
+			assert.False(t, bm.Paused())
+			assert.Equal(t, uint64(150), bm.SubmissionsRemaining())
+
+			assert.NoError(t, bm.OnAvailBlock(context.Background(), 6))
+			assert.Equal(t, tc.expectPaused, bm.Paused())
+			assert.Equal(t, uint64(50), bm.SubmissionsRemaining())
+			assert.Equal(t, submissions(50, fee), bm.Balance())
+
+			// Account gets topped up.
+			balance = submissions(1_000, fee)
+
+			assert.NoError(t, bm.OnAvailBlock(context.Background(), 11))
+			assert.False(t, bm.Paused())
+			assert.Equal(t, uint64(1_000), bm.SubmissionsRemaining())
+		})
+	}
+}
+
+func TestBalanceMonitorPollError(t *testing.T) {
+	errBalance := errors.New("balance unavailable")
+
+	bm := NewBalanceMonitor(func(context.Context) (*big.Int, error) { return nil, errBalance }, BalanceMonitorConfig{Policy: LowBalancePolicyPause}, hclog.NewNullLogger())
+
+	assert.ErrorIs(t, bm.OnAvailBlock(context.Background(), 1), errBalance)
+	assert.False(t, bm.Paused())
+}
+
+func TestParseLowBalancePolicy(t *testing.T) {
+	policy, err := ParseLowBalancePolicy("pause")
+	assert.NoError(t, err)
+	assert.Equal(t, LowBalancePolicyPause, policy)
+
+	_, err = ParseLowBalancePolicy("panic")
+	assert.Error(t, err)
+}
@@ -0,0 +1,110 @@
+package avail
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/scale"
+)
+
+const (
+	// BatchMagic is required to be present in a `BlobBatch` read from Avail.
+	BatchMagic = byte(0b10101100)
+
+	// maxBatchBlocks is the maximum number of blocks in a single batch.
+	maxBatchBlocks = 1024
+)
+
+// ErrInvalidBatch is the error returned when the batch header is inconsistent.
+var ErrInvalidBatch = errors.New("invalid blob batch")
+
+// BlobBatch carries the data of several Edge blocks in a single Avail
+// extrinsic, in the order the blocks were submitted in.
+type BlobBatch struct {
+	Blocks [][]byte
+}
+
+// Encode encodes the batch into the provided scale.Encoder.
+func (b *BlobBatch) Encode(e scale.Encoder) error {
+	if len(b.Blocks) == 0 || len(b.Blocks) > maxBatchBlocks {
+		return fmt.Errorf("%w: %d blocks", ErrInvalidBatch, len(b.Blocks))
+	}
+
+	var size int
+	for _, data := range b.Blocks {
+		size += len(data)
+	}
+
+	if size > MaxBlobSize {
+		return ErrDataTooLong
+	}
+
+	err := e.PushByte(BatchMagic)
+	if err != nil {
+		return err
+	}
+
+	err = e.EncodeUintCompact(*big.NewInt(int64(len(b.Blocks))))
+	if err != nil {
+		return err
+	}
+
+	for _, data := range b.Blocks {
+		err = e.EncodeUintCompact(*big.NewInt(int64(len(data))))
+		if err != nil {
+			return err
+		}
+
+		err = e.Write(data)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Decode decodes the batch from the provided scale.Decoder.
+func (b *BlobBatch) Decode(d scale.Decoder) error {
+	magic, err := d.ReadOneByte()
+	if err != nil {
+		return err
+	}
+
+	if magic != BatchMagic {
+		return fmt.Errorf("%w got %d, expected %d", ErrInvalidBlobMagic, magic, BatchMagic)
+	}
+
+	count, err := d.DecodeUintCompact()
+	if err != nil {
+		return err
+	}
+
+	if !count.IsUint64() || count.Uint64() == 0 || count.Uint64() > maxBatchBlocks {
+		return fmt.Errorf("%w: %s blocks", ErrInvalidBatch, count)
+	}
+
+	b.Blocks = make([][]byte, count.Uint64())
+
+	var size int64
+	for i := range b.Blocks {
+		dataLen, err := d.DecodeUintCompact()
+		if err != nil {
+			return err
+		}
+
+		if !dataLen.IsInt64() || size+dataLen.Int64() > MaxBlobSize {
+			return ErrDataTooLong
+		}
+
+		size += dataLen.Int64()
+
+		b.Blocks[i] = make([]byte, dataLen.Int64())
+		if err := d.Read(b.Blocks[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -18,7 +18,7 @@ const (
 
 var (
 	// ErrDataTooLong is the error returned when the data length exceeds the maximum limit.
-	ErrDataTooLong = errors.New("data length exceeds maximum limit")
+	ErrDataTooLong = fmt.Errorf("%w: data length exceeds maximum limit", ErrBlobTooLarge)
 
 	// ErrInvalidBlobMagic is the error returned when the blob magic byte is invalid.
 	ErrInvalidBlobMagic = errors.New("invalid blob magic")
@@ -51,11 +51,54 @@ func (dbs *DummyBlockSource) DummyBlock(appID types.UCompact, callIdx types.Call
 // Error returned when no compatible extrinsic is found in Avail block's extrinsic data
 var ErrNoExtrinsicFound = errors.New("no compatible extrinsic found")
 
+// EdgeBlock is an Edge block decoded from an Avail block.
+type EdgeBlock struct {
+	*edge_types.Block
+
+	// ExtrinsicIndex is the index of the extrinsic carrying the block in the
+	// Avail block; for chunked blocks the one of the last chunk received.
+	ExtrinsicIndex int
+
+	// Submitter is the Avail account that signed the extrinsic.
+	Submitter types.AccountID
+}
+
 // BlockFromAvail converts Avail blocks into Edge blocks.
 // It takes an Avail block, appID, callIdx, and logger as parameters.
-// It returns a slice of Edge blocks or an error if conversion fails.
-func BlockFromAvail(avail_blk *types.SignedBlock, appID types.UCompact, callIdx types.CallIndex, logger hclog.Logger) ([]*edge_types.Block, error) {
-	toReturn := []*edge_types.Block{}
+// It returns the Edge blocks in the order of their extrinsics or an error if conversion fails.
+// Chunked block data is skipped; use BlockDecoder to reassemble it.
+func BlockFromAvail(avail_blk *types.SignedBlock, appID types.UCompact, callIdx types.CallIndex, logger hclog.Logger) ([]EdgeBlock, error) {
+	return blocksFromAvail(avail_blk, appID, callIdx, nil, logger)
+}
+
+// blocksFromAvail converts Avail blocks into Edge blocks, feeding the block
+// data chunks into the reassembler, when given. Malformed block data is
+// skipped without affecting the rest of the Avail block.
+func blocksFromAvail(avail_blk *types.SignedBlock, appID types.UCompact, callIdx types.CallIndex, chunks *Reassembler, logger hclog.Logger) ([]EdgeBlock, error) {
+	// Most of the Avail blocks don't carry any data of ours; skip those
+	// without going through their extrinsics one by one.
+	if !hasAppExtrinsics(avail_blk, appID) {
+		return nil, ErrNoExtrinsicFound
+	}
+
+	data := extractBlockData(avail_blk, appID, callIdx, chunks != nil, logger)
+
+	return assembleBlocks(avail_blk, data, chunks, logger)
+}
+
+// blockData is the data of ours carried by an extrinsic of an Avail block:
+// either an Edge block carried whole, or a chunk of the data of one.
+type blockData struct {
+	edge  EdgeBlock
+	chunk *BlobChunk
+}
+
+// extractBlockData decodes the data of ours carried by the extrinsics of the
+// Avail block, in their order, leaving the chunks out unless chunked is set.
+// It doesn't depend on the other Avail blocks, and may run for several of
+// them concurrently.
+func extractBlockData(avail_blk *types.SignedBlock, appID types.UCompact, callIdx types.CallIndex, chunked bool, logger hclog.Logger) []blockData {
+	data := []blockData{}
 
 	for i, extrinsic := range avail_blk.Block.Extrinsics {
 		if extrinsic.Signature.AppID.Int64() != appID.Int64() {
@@ -68,41 +111,116 @@ func BlockFromAvail(avail_blk *types.SignedBlock, appID types.UCompact, callIdx
 			continue
 		}
 
-		var blob Blob
-		{
-			// XXX: This decoding process is an inefficient hack to
-			// workaround problem in the encoding pipeline from client
-			// code to Avail server. See more information about this in
-			// sender.SubmitData().
-			var bs types.Bytes
-			err := codec.Decode(extrinsic.Method.Args, &bs)
-			if err != nil {
-				// Don't return just yet because there is no way of filtering
-				// uninteresting extrinsics / method.Args and failing decoding
-				// is the only way to distinct those.
-				logger.Info("decoding block extrinsic's raw bytes from args failed", "avail_block_number", avail_blk.Block.Header.Number, "extrinsic_index", i, "error", err)
+		// XXX: This decoding process is an inefficient hack to
+		// workaround problem in the encoding pipeline from client
+		// code to Avail server. See more information about this in
+		// sender.SubmitData().
+		var bs types.Bytes
+		err := codec.Decode(extrinsic.Method.Args, &bs)
+		if err != nil {
+			// Don't return just yet because there is no way of filtering
+			// uninteresting extrinsics / method.Args and failing decoding
+			// is the only way to distinct those.
+			logger.Info("decoding block extrinsic's raw bytes from args failed", "avail_block_number", avail_blk.Block.Header.Number, "extrinsic_index", i, "error", err)
+			continue
+		}
+
+		if len(bs) > 0 && bs[0] == ChunkMagic {
+			if !chunked {
+				logger.Debug("skipping block data chunk", "avail_block_number", avail_blk.Block.Header.Number, "extrinsic_index", i)
 				continue
 			}
 
-			decoder := scale.NewDecoder(bytes.NewBuffer(bs))
-			err = blob.Decode(*decoder)
-			if err != nil {
-				// Don't return just yet because there is no way of filtering
-				// uninteresting extrinsics / method.Args and failing decoding
-				// is the only way to distinct those.
-				logger.Info("decoding blob from extrinsic data failed", "avail_block_number", avail_blk.Block.Header.Number, "extrinsic_index", i, "error", err)
+			var chunk BlobChunk
+			if err := chunk.Decode(*scale.NewDecoder(bytes.NewBuffer(bs))); err != nil {
+				observeMalformedBlockData()
+				logger.Info("decoding block data chunk from extrinsic data failed", "avail_block_number", avail_blk.Block.Header.Number, "extrinsic_index", i, "error", err)
 				continue
 			}
+
+			data = append(data, blockData{edge: EdgeBlock{ExtrinsicIndex: i, Submitter: extrinsic.Signature.Signer.AsID}, chunk: &chunk})
+
+			continue
+		}
+
+		if len(bs) > 0 && bs[0] == BatchMagic {
+			var batch BlobBatch
+			if err := batch.Decode(*scale.NewDecoder(bytes.NewBuffer(bs))); err != nil {
+				observeMalformedBlockData()
+				logger.Info("decoding blob batch from extrinsic data failed", "avail_block_number", avail_blk.Block.Header.Number, "extrinsic_index", i, "error", err)
+				continue
+			}
+
+			for j, raw := range batch.Blocks {
+				blk := edge_types.Block{}
+				if err := blk.UnmarshalRLP(raw); err != nil {
+					observeMalformedBlockData()
+					logger.Warn("decoding edge block from blob batch failed", "avail_block_number", avail_blk.Block.Header.Number, "extrinsic_index", i, "batch_index", j, "error", err)
+					continue
+				}
+
+				logger.Info("Received new batched edge block from avail.", "hash", blk.Header.Hash, "parent_hash", blk.Header.ParentHash, "batch_index", j)
+
+				data = append(data, blockData{edge: EdgeBlock{Block: &blk, ExtrinsicIndex: i, Submitter: extrinsic.Signature.Signer.AsID}})
+			}
+
+			continue
+		}
+
+		var blob Blob
+		decoder := scale.NewDecoder(bytes.NewBuffer(bs))
+		err = blob.Decode(*decoder)
+		if err != nil {
+			// Don't return just yet because there is no way of filtering
+			// uninteresting extrinsics / method.Args and failing decoding
+			// is the only way to distinct those.
+			observeMalformedBlockData()
+			logger.Info("decoding blob from extrinsic data failed", "avail_block_number", avail_blk.Block.Header.Number, "extrinsic_index", i, "error", err)
+			continue
 		}
 
 		blk := edge_types.Block{}
 		if err := blk.UnmarshalRLP(blob.Data); err != nil {
-			return nil, err
+			observeMalformedBlockData()
+			logger.Warn("decoding edge block from blob failed", "avail_block_number", avail_blk.Block.Header.Number, "extrinsic_index", i, "error", err)
+			continue
 		}
 
 		logger.Info("Received new edge block from avail.", "hash", blk.Header.Hash, "parent_hash", blk.Header.ParentHash, "avail_block_number", blk.Header.Number)
 
-		toReturn = append(toReturn, &blk)
+		data = append(data, blockData{edge: EdgeBlock{Block: &blk, ExtrinsicIndex: i, Submitter: extrinsic.Signature.Signer.AsID}})
+	}
+
+	return data
+}
+
+// assembleBlocks returns the Edge blocks of the data extracted from the Avail
+// block, in the order of their extrinsics, feeding the chunks into the
+// reassembler. The reassembly completes a chunked block in the Avail block
+// carrying its last chunk, so the Avail blocks are assembled in their order.
+func assembleBlocks(avail_blk *types.SignedBlock, data []blockData, chunks *Reassembler, logger hclog.Logger) ([]EdgeBlock, error) {
+	toReturn := []EdgeBlock{}
+
+	for _, d := range data {
+		if d.chunk == nil {
+			toReturn = append(toReturn, d.edge)
+			continue
+		}
+
+		blk, err := chunks.Add(d.edge.Submitter, *d.chunk)
+		if err != nil {
+			observeMalformedBlockData()
+			logger.Warn("reassembling block data chunks failed", "avail_block_number", avail_blk.Block.Header.Number, "extrinsic_index", d.edge.ExtrinsicIndex, "error", err)
+			continue
+		}
+
+		if blk != nil {
+			logger.Info("Received new chunked edge block from avail.", "hash", blk.Header.Hash, "parent_hash", blk.Header.ParentHash, "chunks", d.chunk.Total)
+
+			edge := d.edge
+			edge.Block = blk
+			toReturn = append(toReturn, edge)
+		}
 	}
 
 	if len(toReturn) == 0 {
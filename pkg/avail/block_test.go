@@ -0,0 +1,108 @@
+package avail
+
+import (
+	"testing"
+
+	edge_types "github.com/0xPolygon/polygon-edge/types"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/signature"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types/codec"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlocksFromAvailMultipleBlocks(t *testing.T) {
+	sink := testMetricsSink(t)
+
+	var (
+		appID   = types.NewUCompactFromUInt(3)
+		callIdx = types.CallIndex{SectionIndex: 29, MethodIndex: 1}
+	)
+
+	submitter, err := types.NewMultiAddressFromAccountID(signature.TestKeyringPairAlice.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	edgeBlk := func(number uint64) types.Extrinsic {
+		ext := blobExtrinsic(t, appID, callIdx, &edge_types.Block{Header: &edge_types.Header{Number: number, Difficulty: 1}})
+		ext.Signature.Signer = submitter
+
+		return ext
+	}
+
+	// A well-formed blob carrying data that isn't an edge block.
+	encodedBlob, err := codec.Encode(Blob{Magic: BlobMagic, Data: []byte("garbage")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	garbage := payloadExtrinsic(t, appID, callIdx, encodedBlob)
+
+	blk := &types.SignedBlock{
+		Block: types.Block{
+			Header:     types.Header{Number: 1},
+			Extrinsics: []types.Extrinsic{edgeBlk(1), garbage, edgeBlk(2), edgeBlk(3)},
+		},
+	}
+
+	blks, err := BlockFromAvail(blk, appID, callIdx, hclog.NewNullLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		numbers []uint64
+		indices []int
+	)
+
+	for _, b := range blks {
+		numbers = append(numbers, b.Number())
+		indices = append(indices, b.ExtrinsicIndex)
+		assert.Equal(t, submitter.AsID, b.Submitter)
+	}
+
+	assert.Equal(t, []uint64{1, 2, 3}, numbers)
+	assert.Equal(t, []int{0, 2, 3}, indices)
+	assert.Equal(t, float64(1), counter(sink, "test.avail.decode.malformed"))
+}
+
+func TestBlocksFromAvailBatch(t *testing.T) {
+	var (
+		appID   = types.NewUCompactFromUInt(3)
+		callIdx = types.CallIndex{SectionIndex: 29, MethodIndex: 1}
+	)
+
+	batch := &BlobBatch{}
+	for i := uint64(1); i <= 3; i++ {
+		batch.Blocks = append(batch.Blocks, (&edge_types.Block{Header: &edge_types.Header{Number: i, Difficulty: 1}}).MarshalRLP())
+	}
+
+	payload, err := codec.Encode(batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	availBlk := &types.SignedBlock{Block: types.Block{Header: types.Header{Number: 1}, Extrinsics: []types.Extrinsic{
+		blobExtrinsic(t, appID, callIdx, &edge_types.Block{Header: &edge_types.Header{Number: 4, Difficulty: 1}}),
+		payloadExtrinsic(t, appID, callIdx, payload),
+	}}}
+
+	blks, err := BlockFromAvail(availBlk, appID, callIdx, hclog.NewNullLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if assert.Len(t, blks, 4) {
+		assert.Equal(t, uint64(4), blks[0].Number())
+
+		for i, blk := range blks[1:] {
+			assert.Equal(t, uint64(i+1), blk.Number())
+			assert.Equal(t, 1, blk.ExtrinsicIndex)
+		}
+	}
+
+	// Empty batches can't be encoded.
+	_, err = codec.Encode(&BlobBatch{})
+	assert.ErrorIs(t, err, ErrInvalidBatch)
+}
@@ -0,0 +1,188 @@
+package avail
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/hashicorp/go-hclog"
+)
+
+// FallbackSubmitDataCallIndex is the call index of CallSubmitData in the
+// Avail runtime at the time of writing. It's only used, when enabled, if the
+// call can't be found in the runtime metadata.
+var FallbackSubmitDataCallIndex = types.CallIndex{SectionIndex: 29, MethodIndex: 1}
+
+// WithCallIndexFallback enables the use of FallbackSubmitDataCallIndex when
+// CallSubmitData can't be found in the Avail runtime metadata.
+func WithCallIndexFallback(enabled bool) ClientOption {
+	return func(c *client) {
+		c.callIndexFallback = enabled
+	}
+}
+
+// callIndexer is implemented by the clients that know the call index of
+// CallSubmitData up front, such as the in-memory fake of Avail.
+type callIndexer interface {
+	SubmitDataCallIndex() types.CallIndex
+}
+
+// CallIndexResolver discovers the call index of CallSubmitData from the Avail
+// runtime metadata. The call index is cached per runtime spec version, so it
+// gets re-resolved whenever a runtime upgrade is detected. The latest call
+// index follows the runtime cached by the endpoint client.
+type CallIndexResolver struct {
+	client Client
+	logger hclog.Logger
+
+	lock     sync.Mutex
+	bySpec   map[types.U32]types.CallIndex
+	last     types.CallIndex
+	resolved bool
+}
+
+var (
+	callIndexResolversLock sync.Mutex
+	callIndexResolvers     = make(map[Client]*CallIndexResolver)
+)
+
+// CallIndexResolverFor returns the CallIndexResolver shared by all the users
+// of the given client.
+func CallIndexResolverFor(client Client) *CallIndexResolver {
+	callIndexResolversLock.Lock()
+	defer callIndexResolversLock.Unlock()
+
+	r, ok := callIndexResolvers[client]
+	if !ok {
+		r = NewCallIndexResolver(client)
+		callIndexResolvers[client] = r
+	}
+
+	return r
+}
+
+// NewCallIndexResolver creates a new CallIndexResolver for the given client.
+func NewCallIndexResolver(client Client) *CallIndexResolver {
+	return &CallIndexResolver{
+		client: client,
+		logger: clientLogger(client).Named("call_index"),
+		bySpec: make(map[types.U32]types.CallIndex),
+	}
+}
+
+// Latest returns the call index of CallSubmitData in the latest Avail runtime.
+func (r *CallIndexResolver) Latest(ctx context.Context) (types.CallIndex, error) {
+	if ci, ok := r.client.(callIndexer); ok {
+		return ci.SubmitDataCallIndex(), nil
+	}
+
+	c, err := endpoint(r.client)
+	if err != nil {
+		return types.CallIndex{}, err
+	}
+
+	rt, err := c.latestRuntime(ctx)
+	if err != nil {
+		return r.lastKnown(ctx, err)
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.resolveLocked(c, rt.version.SpecVersion, rt.metadata)
+}
+
+// At returns the call index of CallSubmitData in the runtime that produced
+// the Avail block at the given height.
+func (r *CallIndexResolver) At(ctx context.Context, number uint64) (types.CallIndex, error) {
+	if ci, ok := r.client.(callIndexer); ok {
+		return ci.SubmitDataCallIndex(), nil
+	}
+
+	c, err := endpoint(r.client)
+	if err != nil {
+		return types.CallIndex{}, err
+	}
+
+	blockHash, err := c.getBlockHash(ctx, number)
+	if err != nil {
+		return r.lastKnown(ctx, err)
+	}
+
+	rv, err := c.getRuntimeVersion(ctx, &blockHash)
+	if err != nil {
+		return r.lastKnown(ctx, err)
+	}
+
+	return r.resolve(ctx, c, rv.SpecVersion, blockHash)
+}
+
+// resolve returns the cached call index of the given runtime spec version,
+// or discovers it from the runtime metadata at the given block.
+func (r *CallIndexResolver) resolve(ctx context.Context, c *client, specVersion types.U32, blockHash types.Hash) (types.CallIndex, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if callIdx, ok := r.bySpec[specVersion]; ok {
+		return callIdx, nil
+	}
+
+	meta, err := c.getMetadata(ctx, &blockHash)
+	if err != nil {
+		reportResult(ctx, r.client, c, err)
+		return r.lastKnownLocked(ctx, err)
+	}
+
+	return r.resolveLocked(c, specVersion, meta)
+}
+
+// resolveLocked returns the cached call index of the given runtime spec
+// version, or discovers it from the given runtime metadata. It must be called
+// with the lock held.
+func (r *CallIndexResolver) resolveLocked(c *client, specVersion types.U32, meta *types.Metadata) (types.CallIndex, error) {
+	if callIdx, ok := r.bySpec[specVersion]; ok {
+		return callIdx, nil
+	}
+
+	callIdx, err := meta.FindCallIndex(CallSubmitData)
+	if err != nil {
+		if !c.callIndexFallback {
+			return types.CallIndex{}, fmt.Errorf("runtime spec version %d: %w", specVersion, err)
+		}
+
+		r.logger.Warn("call not found in Avail runtime metadata; using fallback call index", "call", CallSubmitData, "spec_version", specVersion, "call_index", FallbackSubmitDataCallIndex, "error", err)
+		callIdx = FallbackSubmitDataCallIndex
+	}
+
+	if r.resolved && callIdx != r.last {
+		r.logger.Warn("Avail runtime upgrade changed call index", "call", CallSubmitData, "spec_version", specVersion, "old", r.last, "new", callIdx)
+	}
+
+	r.bySpec[specVersion] = callIdx
+	r.last = callIdx
+	r.resolved = true
+
+	return callIdx, nil
+}
+
+// lastKnown returns the last resolved call index when the runtime can't be
+// queried, or the error if the call index has never been resolved or the
+// context is done.
+func (r *CallIndexResolver) lastKnown(ctx context.Context, err error) (types.CallIndex, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.lastKnownLocked(ctx, err)
+}
+
+// lastKnownLocked is lastKnown that must be called with the lock held.
+func (r *CallIndexResolver) lastKnownLocked(ctx context.Context, err error) (types.CallIndex, error) {
+	if !r.resolved || ctx.Err() != nil {
+		return types.CallIndex{}, err
+	}
+
+	r.logger.Warn("couldn't query Avail runtime; using last known call index", "call_index", r.last, "error", err)
+
+	return r.last, nil
+}
@@ -0,0 +1,152 @@
+package avail
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	edge_types "github.com/0xPolygon/polygon-edge/types"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/signature"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types/codec"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+// stubMetadata returns hex encoded metadata in which the Timestamp pallet
+// poses as the DataAvailability pallet at the given index, with `set` call
+// renamed to `submit_data`.
+func stubMetadata(t *testing.T, palletIdx uint8) string {
+	t.Helper()
+
+	var meta types.Metadata
+	if err := codec.DecodeFromHex(types.MetadataV14Data, &meta); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, p := range meta.AsMetadataV14.Pallets {
+		if p.Name != "Timestamp" {
+			continue
+		}
+
+		meta.AsMetadataV14.Pallets[i].Name = "DataAvailability"
+		meta.AsMetadataV14.Pallets[i].Index = types.NewU8(palletIdx)
+
+		for j, typ := range meta.AsMetadataV14.Lookup.Types {
+			if typ.ID.Int64() != p.Calls.Type.Int64() {
+				continue
+			}
+
+			for k, v := range typ.Type.Def.Variant.Variants {
+				if v.Name == "set" {
+					meta.AsMetadataV14.Lookup.Types[j].Type.Def.Variant.Variants[k].Name = "submit_data"
+				}
+			}
+		}
+	}
+
+	data, err := codec.EncodeToHex(meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+func TestCallIndexResolverFollowsRuntimeUpgrade(t *testing.T) {
+	chain := newStubChain(t, 10, time.Hour)
+	chain.runtimes[0].metadata = stubMetadata(t, 42)
+	e := newStubEndpoint(t, chain)
+
+	c, err := NewClient(e.URL, hclog.NewNullLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewCallIndexResolver(c)
+
+	callIdx, err := r.Latest(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, uint8(42), callIdx.SectionIndex)
+	submitDataIdx := callIdx.MethodIndex
+
+	// Runtime upgrade moves the pallet.
+	chain.upgrade(2, stubMetadata(t, 7))
+	chain.produce()
+
+	ep, err := endpoint(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The latest call index follows the runtime cached by the endpoint.
+	callIdx, err = r.Latest(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, uint8(42), callIdx.SectionIndex)
+
+	upgraded, err := ep.refreshRuntime(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.True(t, upgraded)
+
+	callIdx, err = r.Latest(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, types.CallIndex{SectionIndex: 7, MethodIndex: submitDataIdx}, callIdx)
+
+	// Blocks produced before the upgrade are still decoded with the old index.
+	callIdx, err = r.At(context.Background(), 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, types.CallIndex{SectionIndex: 42, MethodIndex: submitDataIdx}, callIdx)
+
+	// The sender encodes submissions with the discovered index.
+	s := NewSender(c, types.NewUCompactFromUInt(1), NewKeyringSigner(signature.TestKeyringPairAlice)).(*sender)
+
+	payloads, err := s.payloads(&edge_types.Block{Header: &edge_types.Header{Number: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ext, _, err := s.prepareExtrinsicForSend(context.Background(), ep, payloads[0], 0, s.opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, types.CallIndex{SectionIndex: 7, MethodIndex: submitDataIdx}, ext.Method.CallIndex)
+}
+
+func TestCallIndexResolverFallback(t *testing.T) {
+	chain := newStubChain(t, 1, time.Hour)
+	e := newStubEndpoint(t, chain)
+
+	c, err := NewClient(e.URL, hclog.NewNullLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The stub metadata has no DataAvailability pallet.
+	_, err = NewCallIndexResolver(c).Latest(context.Background())
+	assert.Error(t, err)
+
+	c, err = NewClient(e.URL, hclog.NewNullLogger(), WithCallIndexFallback(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	callIdx, err := NewCallIndexResolver(c).Latest(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, FallbackSubmitDataCallIndex, callIdx)
+}
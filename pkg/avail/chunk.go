@@ -0,0 +1,286 @@
+package avail
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	edge_types "github.com/0xPolygon/polygon-edge/types"
+	"github.com/armon/go-metrics"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/scale"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/hashicorp/go-hclog"
+)
+
+const (
+	// ChunkMagic is required to be present in a `BlobChunk` read from Avail.
+	ChunkMagic = byte(0b10101011)
+
+	// DefaultMaxChunkSize is the maximum length of block data submitted in
+	// a single Avail extrinsic. It leaves room for the blob and chunk headers
+	// within the 512 KiB limit of Avail application data.
+	DefaultMaxChunkSize = 512*1024 - chunkHeaderSize
+
+	// MaxBlockSize is the largest encoded block that settles on Avail, split
+	// into chunks: the blob limit less the headers of the chunks.
+	MaxBlockSize = MaxBlobSize - maxChunks*chunkHeaderSize
+
+	// chunkHeaderSize is the room left for the blob and chunk headers in
+	// every Avail extrinsic.
+	chunkHeaderSize = 128
+
+	// DefaultChunkSetTimeout is the time after which an incomplete set of
+	// block data chunks is dropped.
+	DefaultChunkSetTimeout = 5 * time.Minute
+
+	// maxChunks is the maximum number of chunks a block can be split into.
+	maxChunks = MaxBlobSize/DefaultMaxChunkSize + 1
+)
+
+var (
+	// ErrInvalidChunk is the error returned when the chunk header is inconsistent.
+	ErrInvalidChunk = errors.New("invalid blob chunk")
+
+	// ErrChunkHashMismatch is the error returned when the reassembled block doesn't match the hash in chunk headers.
+	ErrChunkHashMismatch = errors.New("reassembled block hash mismatch")
+)
+
+// BlobChunk is a part of block data that is too large to be stored in a
+// single Avail extrinsic.
+type BlobChunk struct {
+	BlockHash edge_types.Hash
+	Index     uint32
+	Total     uint32
+	Data      []byte
+}
+
+// Encode encodes the chunk into the provided scale.Encoder.
+func (c *BlobChunk) Encode(e scale.Encoder) error {
+	if c.Total == 0 || c.Total > maxChunks || c.Index >= c.Total {
+		return fmt.Errorf("%w: chunk %d of %d", ErrInvalidChunk, c.Index, c.Total)
+	}
+
+	if len(c.Data) > MaxBlobSize {
+		return ErrDataTooLong
+	}
+
+	err := e.PushByte(ChunkMagic)
+	if err != nil {
+		return err
+	}
+
+	err = e.Write(c.BlockHash[:])
+	if err != nil {
+		return err
+	}
+
+	err = e.EncodeUintCompact(*big.NewInt(int64(c.Index)))
+	if err != nil {
+		return err
+	}
+
+	err = e.EncodeUintCompact(*big.NewInt(int64(c.Total)))
+	if err != nil {
+		return err
+	}
+
+	err = e.EncodeUintCompact(*big.NewInt(int64(len(c.Data))))
+	if err != nil {
+		return err
+	}
+
+	return e.Write(c.Data)
+}
+
+// Decode decodes the chunk from the provided scale.Decoder.
+func (c *BlobChunk) Decode(d scale.Decoder) error {
+	magic, err := d.ReadOneByte()
+	if err != nil {
+		return err
+	}
+
+	if magic != ChunkMagic {
+		return fmt.Errorf("%w got %d, expected %d", ErrInvalidBlobMagic, magic, ChunkMagic)
+	}
+
+	err = d.Read(c.BlockHash[:])
+	if err != nil {
+		return err
+	}
+
+	index, err := d.DecodeUintCompact()
+	if err != nil {
+		return err
+	}
+
+	total, err := d.DecodeUintCompact()
+	if err != nil {
+		return err
+	}
+
+	if !total.IsUint64() || total.Uint64() == 0 || total.Uint64() > maxChunks || !index.IsUint64() || index.Uint64() >= total.Uint64() {
+		return fmt.Errorf("%w: chunk %s of %s", ErrInvalidChunk, index, total)
+	}
+
+	c.Index, c.Total = uint32(index.Uint64()), uint32(total.Uint64())
+
+	dataLen, err := d.DecodeUintCompact()
+	if err != nil {
+		return err
+	}
+
+	if !dataLen.IsInt64() || dataLen.Int64() > MaxBlobSize {
+		return ErrDataTooLong
+	}
+
+	c.Data = make([]byte, dataLen.Int64())
+
+	return d.Read(c.Data)
+}
+
+// SplitBlockData splits the encoded block data into chunks of at most
+// `maxSize` bytes. Data that fits in a single chunk is not split at all.
+func SplitBlockData(blockHash edge_types.Hash, data []byte, maxSize int) []BlobChunk {
+	if len(data) <= maxSize {
+		return nil
+	}
+
+	total := (len(data) + maxSize - 1) / maxSize
+	chunks := make([]BlobChunk, 0, total)
+
+	for i := 0; i < total; i++ {
+		end := (i + 1) * maxSize
+		if end > len(data) {
+			end = len(data)
+		}
+
+		chunks = append(chunks, BlobChunk{
+			BlockHash: blockHash,
+			Index:     uint32(i),
+			Total:     uint32(total),
+			Data:      data[i*maxSize : end],
+		})
+	}
+
+	return chunks
+}
+
+// chunkSetKey identifies the chunk set of a block submitted by an Avail
+// account. The chunks of the same block by different submitters are kept
+// apart, so the ones forged by anyone else can't get in the way of the
+// sequencer's own.
+type chunkSetKey struct {
+	submitter types.AccountID
+	hash      edge_types.Hash
+}
+
+// chunkSet holds the chunks of a single block received so far.
+type chunkSet struct {
+	chunks    [][]byte
+	received  uint32
+	firstSeen time.Time
+}
+
+// Reassembler collects block data chunks, in any order, and reassembles
+// the block once all of its chunks have arrived.
+type Reassembler struct {
+	timeout time.Duration
+	logger  hclog.Logger
+	now     func() time.Time
+
+	lock sync.Mutex
+	sets map[chunkSetKey]*chunkSet
+}
+
+// NewReassembler creates a new Reassembler that drops incomplete chunk sets after the given timeout.
+func NewReassembler(timeout time.Duration, logger hclog.Logger) *Reassembler {
+	return &Reassembler{
+		timeout: timeout,
+		logger:  logger.Named("reassembler"),
+		now:     time.Now,
+		sets:    make(map[chunkSetKey]*chunkSet),
+	}
+}
+
+// Add adds the chunk, signed by the given submitter, to the submitter's set
+// of the block. When the set is complete, the block is reassembled, verified
+// against the block hash in the chunk header and returned. It returns nil
+// when the set is not complete yet. A chunk inconsistent with the set
+// discards the set of its submitter, and only that one.
+func (r *Reassembler) Add(submitter types.AccountID, chunk BlobChunk) (*edge_types.Block, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	now := r.now()
+	r.expire(now)
+
+	key := chunkSetKey{submitter: submitter, hash: chunk.BlockHash}
+
+	set, ok := r.sets[key]
+	if !ok {
+		set = &chunkSet{chunks: make([][]byte, chunk.Total), firstSeen: now}
+		r.sets[key] = set
+	}
+
+	if uint32(len(set.chunks)) != chunk.Total || chunk.Index >= chunk.Total {
+		delete(r.sets, key)
+		return nil, fmt.Errorf("%w: chunk %d of %d for block %s", ErrInvalidChunk, chunk.Index, chunk.Total, chunk.BlockHash)
+	}
+
+	if set.chunks[chunk.Index] != nil {
+		// Duplicate chunk.
+		return nil, nil
+	}
+
+	set.chunks[chunk.Index] = chunk.Data
+	set.received++
+
+	if set.received < chunk.Total {
+		return nil, nil
+	}
+
+	delete(r.sets, key)
+
+	var data []byte
+	for _, c := range set.chunks {
+		data = append(data, c...)
+	}
+
+	blk := edge_types.Block{}
+	if err := blk.UnmarshalRLP(data); err != nil {
+		return nil, err
+	}
+
+	if blk.Hash() != chunk.BlockHash {
+		return nil, fmt.Errorf("%w: expected %s, got %s", ErrChunkHashMismatch, chunk.BlockHash, blk.Hash())
+	}
+
+	return &blk, nil
+}
+
+// Pending returns the number of incomplete chunk sets.
+func (r *Reassembler) Pending() int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.expire(r.now())
+
+	return len(r.sets)
+}
+
+// expire drops the incomplete chunk sets older than the timeout. It must be
+// called with the lock held.
+func (r *Reassembler) expire(now time.Time) {
+	for key, set := range r.sets {
+		if now.Sub(set.firstSeen) < r.timeout {
+			continue
+		}
+
+		r.logger.Warn("dropping incomplete block data chunk set", "block_hash", key.hash, "submitter", key.submitter, "received", set.received, "total", len(set.chunks))
+		metrics.IncrCounter([]string{"avail", "chunk_sets_expired"}, 1)
+
+		delete(r.sets, key)
+	}
+}
@@ -0,0 +1,296 @@
+package avail
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	edge_types "github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/pkg/test"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/scale"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types/codec"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+const testChunkSize = 1024
+
+// chunkedBlock returns an Edge block whose data needs three chunks of testChunkSize.
+func chunkedBlock(t *testing.T) *edge_types.Block {
+	t.Helper()
+
+	blk := &edge_types.Block{
+		Header: &edge_types.Header{
+			Number:     7,
+			Difficulty: 1,
+			ExtraData:  test.RandomBytes(t, 2*testChunkSize+testChunkSize/2),
+		},
+	}
+	blk.Header.ComputeHash()
+
+	return blk
+}
+
+// payloadExtrinsic wraps the sender payload into an extrinsic the way Avail stores it.
+func payloadExtrinsic(t *testing.T, appID types.UCompact, callIdx types.CallIndex, payload []byte) types.Extrinsic {
+	t.Helper()
+
+	args, err := codec.Encode(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ext := types.Extrinsic{}
+	ext.Method.CallIndex = callIdx
+	ext.Method.Args = args
+	ext.Signature.AppID = appID
+
+	return ext
+}
+
+func TestChunkedBlockRoundTrip(t *testing.T) {
+	var (
+		appID   = types.NewUCompactFromUInt(3)
+		callIdx = types.CallIndex{SectionIndex: 29, MethodIndex: 1}
+	)
+
+	s := &sender{maxChunkSize: testChunkSize}
+	blk := chunkedBlock(t)
+
+	payloads, err := s.payloads(blk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, payloads, 3)
+
+	// Chunks arrive out of order, spread over several Avail blocks.
+	availBlks := []*types.SignedBlock{
+		{Block: types.Block{Header: types.Header{Number: 1}, Extrinsics: []types.Extrinsic{payloadExtrinsic(t, appID, callIdx, payloads[2])}}},
+		{Block: types.Block{Header: types.Header{Number: 2}, Extrinsics: []types.Extrinsic{payloadExtrinsic(t, appID, callIdx, payloads[0])}}},
+		{Block: types.Block{Header: types.Header{Number: 3}, Extrinsics: []types.Extrinsic{payloadExtrinsic(t, appID, callIdx, payloads[1])}}},
+	}
+
+	chunks := NewReassembler(time.Minute, hclog.NewNullLogger())
+
+	for _, availBlk := range availBlks[:2] {
+		_, err := blocksFromAvail(availBlk, appID, callIdx, chunks, hclog.NewNullLogger())
+		assert.ErrorIs(t, err, ErrNoExtrinsicFound)
+	}
+
+	assert.Equal(t, 1, chunks.Pending())
+
+	edgeBlks, err := blocksFromAvail(availBlks[2], appID, callIdx, chunks, hclog.NewNullLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, edgeBlks, 1)
+	assert.Equal(t, blk.Hash(), edgeBlks[0].Hash())
+	assert.Equal(t, blk.Header.ExtraData, edgeBlks[0].Header.ExtraData)
+	assert.Equal(t, 0, chunks.Pending())
+
+	// Chunks are skipped when decoding without a reassembler.
+	_, err = BlockFromAvail(availBlks[0], appID, callIdx, hclog.NewNullLogger())
+	assert.ErrorIs(t, err, ErrNoExtrinsicFound)
+}
+
+// callIndexClient is the Avail client of the given call index of
+// CallSubmitData.
+type callIndexClient struct {
+	Client
+	callIdx types.CallIndex
+}
+
+func (c callIndexClient) SubmitDataCallIndex() types.CallIndex {
+	return c.callIdx
+}
+
+func TestDecoderExtractsConcurrentlyAndAssemblesInOrder(t *testing.T) {
+	var (
+		appID   = types.NewUCompactFromUInt(3)
+		callIdx = types.CallIndex{SectionIndex: 29, MethodIndex: 1}
+	)
+
+	s := &sender{maxChunkSize: testChunkSize}
+	chunked := chunkedBlock(t)
+
+	payloads, err := s.payloads(chunked)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	whole := &edge_types.Block{Header: &edge_types.Header{Number: 8, Difficulty: 1}}
+	whole.Header.ComputeHash()
+
+	wholePayloads, err := s.payloads(whole)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The chunks spread over the Avail blocks, the second one carrying a
+	// whole block as well.
+	availBlks := []*types.SignedBlock{
+		{Block: types.Block{Header: types.Header{Number: 1}, Extrinsics: []types.Extrinsic{payloadExtrinsic(t, appID, callIdx, payloads[0])}}},
+		{Block: types.Block{Header: types.Header{Number: 2}, Extrinsics: []types.Extrinsic{payloadExtrinsic(t, appID, callIdx, payloads[1]), payloadExtrinsic(t, appID, callIdx, wholePayloads[0])}}},
+		{Block: types.Block{Header: types.Header{Number: 3}, Extrinsics: []types.Extrinsic{payloadExtrinsic(t, appID, callIdx, payloads[2])}}},
+	}
+
+	d := NewBlockDecoder(callIndexClient{callIdx: callIdx}, appID, hclog.NewNullLogger())
+
+	// The Avail blocks are extracted out of their order, the whole block
+	// available right away.
+	extracted := make([]*ExtractedBlock, len(availBlks))
+	for i := len(availBlks) - 1; i >= 0; i-- {
+		extracted[i], err = d.Extract(context.Background(), availBlks[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	assert.Empty(t, extracted[0].Blocks())
+	if assert.Len(t, extracted[1].Blocks(), 1) {
+		assert.Equal(t, whole.Hash(), extracted[1].Blocks()[0].Hash())
+	}
+
+	// Assembled in their order, the chunked block completes with the Avail
+	// block carrying its last chunk.
+	_, err = d.Assemble(extracted[0])
+	assert.ErrorIs(t, err, ErrNoExtrinsicFound)
+
+	edgeBlks, err := d.Assemble(extracted[1])
+	if assert.NoError(t, err) && assert.Len(t, edgeBlks, 1) {
+		assert.Same(t, extracted[1].Blocks()[0], edgeBlks[0].Block)
+		assert.Equal(t, 1, edgeBlks[0].ExtrinsicIndex)
+	}
+
+	edgeBlks, err = d.Assemble(extracted[2])
+	if assert.NoError(t, err) && assert.Len(t, edgeBlks, 1) {
+		assert.Equal(t, chunked.Hash(), edgeBlks[0].Hash())
+		assert.Equal(t, 0, edgeBlks[0].ExtrinsicIndex)
+	}
+}
+
+func TestSingleChunkKeepsBlobFormat(t *testing.T) {
+	s := &sender{maxChunkSize: testChunkSize}
+	blk := &edge_types.Block{Header: &edge_types.Header{Number: 1, Difficulty: 1}}
+
+	payloads, err := s.payloads(blk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, payloads, 1)
+
+	var blob Blob
+	if err := blob.Decode(*scale.NewDecoder(bytes.NewBuffer(payloads[0]))); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, blk.MarshalRLP(), blob.Data)
+}
+
+func TestReassemblerMissingChunkTimeout(t *testing.T) {
+	blk := chunkedBlock(t)
+	chunks := SplitBlockData(blk.Hash(), blk.MarshalRLP(), testChunkSize)
+
+	now := time.Now()
+	r := NewReassembler(time.Minute, hclog.NewNullLogger())
+	r.now = func() time.Time { return now }
+
+	for _, i := range []int{0, 2} {
+		res, err := r.Add(types.AccountID{}, chunks[i])
+		assert.NoError(t, err)
+		assert.Nil(t, res)
+	}
+
+	assert.Equal(t, 1, r.Pending())
+
+	// The missing chunk doesn't arrive in time; the set is dropped.
+	now = now.Add(2 * time.Minute)
+	assert.Equal(t, 0, r.Pending())
+
+	res, err := r.Add(types.AccountID{}, chunks[1])
+	assert.NoError(t, err)
+	assert.Nil(t, res)
+	assert.Equal(t, 1, r.Pending())
+}
+
+func TestReassemblerHashMismatch(t *testing.T) {
+	blk := chunkedBlock(t)
+	chunks := SplitBlockData(edge_types.Hash{0x01}, blk.MarshalRLP(), testChunkSize)
+
+	r := NewReassembler(time.Minute, hclog.NewNullLogger())
+
+	var err error
+	for _, chunk := range chunks {
+		_, err = r.Add(types.AccountID{}, chunk)
+	}
+
+	assert.ErrorIs(t, err, ErrChunkHashMismatch)
+}
+
+func TestReassemblerForgedChunks(t *testing.T) {
+	blk := chunkedBlock(t)
+	chunks := SplitBlockData(blk.Hash(), blk.MarshalRLP(), testChunkSize)
+	total := uint32(len(chunks))
+
+	sequencer, forger := types.AccountID{0x01}, types.AccountID{0x02}
+
+	r := NewReassembler(time.Minute, hclog.NewNullLogger())
+
+	// The forger gets a chunk of the block in first, with a total of its own,
+	// and goes on interleaving the garbage of its set with the chunks of the
+	// sequencer.
+	forged := func(index, total uint32) BlobChunk {
+		return BlobChunk{BlockHash: blk.Hash(), Index: index, Total: total, Data: []byte{0xde, 0xad}}
+	}
+
+	res, err := r.Add(forger, forged(0, total+2))
+	assert.NoError(t, err)
+	assert.Nil(t, res)
+
+	for i, chunk := range chunks {
+		res, err := r.Add(forger, forged(uint32(i)+1, total+2))
+		assert.NoError(t, err)
+		assert.Nil(t, res)
+
+		res, err = r.Add(sequencer, chunk)
+		assert.NoError(t, err)
+
+		if i < len(chunks)-1 {
+			assert.Nil(t, res)
+			continue
+		}
+
+		// The block of the sequencer is reassembled all the same.
+		if assert.NotNil(t, res) {
+			assert.Equal(t, blk.Hash(), res.Hash())
+		}
+	}
+
+	// The set of the forger is left incomplete.
+	assert.Equal(t, 1, r.Pending())
+
+	// A chunk inconsistent with a set discards the set of its submitter
+	// alone.
+	_, err = r.Add(sequencer, chunks[0])
+	assert.NoError(t, err)
+
+	_, err = r.Add(forger, forged(0, total))
+	assert.ErrorIs(t, err, ErrInvalidChunk)
+	assert.Equal(t, 1, r.Pending())
+
+	for _, chunk := range chunks[1:] {
+		res, err = r.Add(sequencer, chunk)
+		assert.NoError(t, err)
+	}
+
+	if assert.NotNil(t, res) {
+		assert.Equal(t, blk.Hash(), res.Hash())
+	}
+
+	assert.Equal(t, 0, r.Pending())
+}
@@ -1,7 +1,10 @@
 package avail
 
 import (
+	"context"
 	"errors"
+	"sync"
+	"time"
 
 	gsrpc "github.com/centrifuge/go-substrate-rpc-client/v4"
 	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
@@ -13,17 +16,21 @@ var ErrUnsupportedClient = errors.New("unsupported client")
 
 // Client is an abstraction on Avail JSON-RPC client.
 type Client interface {
-	// BlockStream creates a new Avail block stream, starting from the specified block height offset.
-	BlockStream(offset uint64) BlockStream
+	// BlockStream creates a new Avail block stream, starting from the specified
+	// block height offset. The stream is closed when the context is canceled.
+	BlockStream(ctx context.Context, offset uint64) BlockStream
 
 	// GenesisHash returns the genesis hash of the Avail network.
 	GenesisHash() types.Hash
 
 	// GetLatestHeader retrieves the latest header from the Avail network.
-	GetLatestHeader() (*types.Header, error)
+	GetLatestHeader(ctx context.Context) (*types.Header, error)
+
+	// Query fetches the historical Avail blocks in the specified height range, inclusive.
+	Query(ctx context.Context, from, to uint64) ([]*types.SignedBlock, error)
 
 	// SearchBlock searches for a block at the specified offset using the provided search function.
-	SearchBlock(offset int64, searchFunc SearchFunc) (*types.SignedBlock, error)
+	SearchBlock(ctx context.Context, offset int64, searchFunc SearchFunc) (*types.SignedBlock, error)
 }
 
 // client is an implementation of the Client interface.
@@ -31,6 +38,15 @@ type client struct {
 	api         *gsrpc.SubstrateAPI
 	genesisHash types.Hash
 	logger      hclog.Logger
+	pageSize    uint64
+	callTimeout time.Duration
+
+	callIndexFallback bool
+
+	runtimeLock sync.Mutex
+	runtime     *runtimeState
+
+	nonces accountNonces
 }
 
 // NewClient constructs a new Avail Client for the specified URL.
@@ -38,61 +54,77 @@ type client struct {
 // Parameters:
 //   - url: The URL of the Avail JSON-RPC server.
 //   - logger: The logger instance.
+//   - opts: The optional client settings.
 //
 // Return:
 //   - Client: The Avail client instance.
 //   - error: An error if the client initialization fails.
-func NewClient(url string, logger hclog.Logger) (Client, error) {
+func NewClient(url string, logger hclog.Logger, opts ...ClientOption) (Client, error) {
 
 	api, err := gsrpc.NewSubstrateAPI(url)
 	if err != nil {
 		return nil, err
 	}
 
+	c := &client{
+		api:         api,
+		logger:      logger,
+		pageSize:    DefaultQueryPageSize,
+		callTimeout: DefaultCallTimeout,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
 	// Cache genesis hash as it will never change.
-	genesisHash, err := api.RPC.Chain.GetBlockHash(0)
+	c.genesisHash, err = c.getBlockHash(context.Background(), 0)
 	if err != nil {
+		api.Client.Close()
 		return nil, err
 	}
 
-	return &client{
-		api:         api,
-		genesisHash: genesisHash,
-		logger:      logger,
-	}, nil
+	return c, nil
 }
 
-// instance returns the underlying SubstrateAPI instance.
+// endpoint returns the client of the single Avail endpoint behind the given client.
 //
 // Return:
-//   - *gsrpc.SubstrateAPI: The SubstrateAPI instance.
-//   - error: An error if the client is not supported or found.
-func instance(c Client) (*gsrpc.SubstrateAPI, error) {
-	c2, ok := c.(*client)
-	if ok {
-		return c2.instance(), nil
+//   - *client: The endpoint client.
+//   - error: An error if the client is not supported.
+func endpoint(c Client) (*client, error) {
+	switch c2 := c.(type) {
+	case *client:
+		return c2, nil
+	case *failoverClient:
+		return c2.get(), nil
 	}
 
 	return nil, ErrUnsupportedClient
 }
 
-// instance returns the underlying SubstrateAPI instance.
+// clientLogger returns the logger of the given client, or a null logger if the client is not supported.
 //
 // Return:
-//   - *gsrpc.SubstrateAPI: The SubstrateAPI instance.
-func (c *client) instance() *gsrpc.SubstrateAPI {
-	return c.api
+//   - hclog.Logger: The client logger.
+func clientLogger(c Client) hclog.Logger {
+	if c2, err := endpoint(c); err == nil && c2.logger != nil {
+		return c2.logger
+	}
+
+	return hclog.NewNullLogger()
 }
 
 // BlockStream creates a new Avail block stream starting from the specified offset.
 //
 // Parameters:
+//   - ctx: The context that closes the stream when canceled.
 //   - offset: The block height offset to start the stream from.
 //
 // Return:
 //   - BlockStream: The block stream.
-func (c *client) BlockStream(offset uint64) BlockStream {
-	return newBlockStream(c, c.logger, offset)
+func (c *client) BlockStream(ctx context.Context, offset uint64) BlockStream {
+	return newBlockStream(ctx, c, c.logger, offset)
 }
 
 // GenesisHash returns the genesis hash of the Avail network.
@@ -105,38 +137,33 @@ func (c *client) GenesisHash() types.Hash {
 
 // GetLatestHeader retrieves the latest header from the Avail network.
 //
+// Parameters:
+//   - ctx: The context of the call.
+//
 // Return:
 //   - *types.Header: The latest header.
 //   - error: An error if the retrieval fails.
-func (c *client) GetLatestHeader() (*types.Header, error) {
-	return c.api.RPC.Chain.GetHeaderLatest()
+func (c *client) GetLatestHeader(ctx context.Context) (*types.Header, error) {
+	return c.getHeaderLatest(ctx)
 }
 
-// FindCallIndex finds the call index for CallSubmitData in the Avail network.
+// FindCallIndex finds the call index for CallSubmitData in the latest runtime of the Avail network.
 //
 // Parameters:
+//   - ctx: The context of the call.
 //   - client: The Avail client.
 //
 // Return:
 //   - types.CallIndex: The call index for CallSubmitData.
 //   - error: An error if the call index retrieval fails.
-func FindCallIndex(client Client) (types.CallIndex, error) {
-	api, err := instance(client)
-	if err == ErrUnsupportedClient {
-		return types.CallIndex{}, nil
-	} else if err != nil {
-		return types.CallIndex{}, err
+func FindCallIndex(ctx context.Context, client Client) (types.CallIndex, error) {
+	if ci, ok := client.(callIndexer); ok {
+		return ci.SubmitDataCallIndex(), nil
 	}
 
-	meta, err := api.RPC.State.GetMetadataLatest()
-	if err != nil {
-		return types.CallIndex{}, err
-	}
-
-	callIdx, err := meta.FindCallIndex(CallSubmitData)
-	if err != nil {
-		return types.CallIndex{}, err
+	if _, err := endpoint(client); err == ErrUnsupportedClient {
+		return types.CallIndex{}, nil
 	}
 
-	return callIdx, nil
+	return CallIndexResolverFor(client).Latest(ctx)
 }
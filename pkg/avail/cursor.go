@@ -0,0 +1,81 @@
+package avail
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+)
+
+const (
+	// CursorFileName is the name of the file, in the data directory, the
+	// processing cursor of the Avail block data watcher is persisted to.
+	CursorFileName = "avail_cursor.json"
+
+	// DefaultReorgRewind is the number of Avail blocks the watcher goes back
+	// when the persisted cursor is no longer on the chain.
+	DefaultReorgRewind = 16
+)
+
+// Cursor is the last Avail block fully processed by the block data watcher.
+type Cursor struct {
+	Number uint64     `json:"number"`
+	Hash   types.Hash `json:"hash"`
+}
+
+// CursorStore persists the processing cursor of the block data watcher.
+type CursorStore interface {
+	// Load returns the persisted cursor, or false if there is none yet.
+	Load() (Cursor, bool, error)
+
+	// Save persists the cursor.
+	Save(Cursor) error
+}
+
+// fileCursorStore is a CursorStore keeping the cursor in a JSON file.
+type fileCursorStore struct {
+	path string
+}
+
+// NewFileCursorStore returns a CursorStore persisting the cursor to
+// CursorFileName in the given data directory.
+func NewFileCursorStore(dataDir string) CursorStore {
+	return &fileCursorStore{path: filepath.Join(dataDir, CursorFileName)}
+}
+
+func (s *fileCursorStore) Load() (Cursor, bool, error) {
+	bs, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return Cursor{}, false, nil
+	}
+
+	if err != nil {
+		return Cursor{}, false, err
+	}
+
+	var c Cursor
+	if err := json.Unmarshal(bs, &c); err != nil {
+		return Cursor{}, false, fmt.Errorf("invalid Avail cursor file %q: %w", s.path, err)
+	}
+
+	return c, true, nil
+}
+
+// Save writes the cursor to a temporary file first and renames it over the
+// cursor file, so that a crash never leaves a truncated cursor behind.
+func (s *fileCursorStore) Save(c Cursor) error {
+	bs, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, bs, 0o600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, s.path)
+}
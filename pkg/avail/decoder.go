@@ -0,0 +1,93 @@
+package avail
+
+import (
+	"context"
+
+	edge_types "github.com/0xPolygon/polygon-edge/types"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/hashicorp/go-hclog"
+)
+
+// BlockDecoder extracts Edge blocks from a sequence of Avail blocks. It
+// decodes the extrinsics with the call index of the runtime that produced
+// each block and reassembles blocks that were submitted in chunks.
+type BlockDecoder struct {
+	appID     types.UCompact
+	callIndex *CallIndexResolver
+	chunks    *Reassembler
+	logger    hclog.Logger
+}
+
+// NewBlockDecoder creates a new BlockDecoder for the blocks of the given AppID.
+func NewBlockDecoder(client Client, appID types.UCompact, logger hclog.Logger) *BlockDecoder {
+	return &BlockDecoder{
+		appID:     appID,
+		callIndex: CallIndexResolverFor(client),
+		chunks:    NewReassembler(DefaultChunkSetTimeout, logger),
+		logger:    logger,
+	}
+}
+
+// Decode converts the Avail block into Edge blocks, in the order of their
+// extrinsics. Blocks without any
+// extrinsics of the decoder's AppID are skipped without querying Avail.
+// It returns ErrNoExtrinsicFound when the Avail block doesn't carry any
+// complete Edge block.
+func (d *BlockDecoder) Decode(ctx context.Context, blk *types.SignedBlock) ([]EdgeBlock, error) {
+	x, err := d.Extract(ctx, blk)
+	if err != nil {
+		return nil, err
+	}
+
+	return d.Assemble(x)
+}
+
+// ExtractedBlock is an Avail block decoded by BlockDecoder.Extract, its Edge
+// blocks yet to be assembled by BlockDecoder.Assemble.
+type ExtractedBlock struct {
+	*types.SignedBlock
+	data []blockData
+}
+
+// Blocks returns the Edge blocks the Avail block carries whole, in the order
+// of their extrinsics; the chunked ones come out of BlockDecoder.Assemble
+// only.
+func (x *ExtractedBlock) Blocks() []*edge_types.Block {
+	blks := []*edge_types.Block{}
+
+	for _, d := range x.data {
+		if d.chunk == nil {
+			blks = append(blks, d.edge.Block)
+		}
+	}
+
+	return blks
+}
+
+// Extract decodes the data of the Avail block, the first half of Decode. It
+// doesn't depend on the other Avail blocks, and may run for several of them
+// concurrently; the extracted blocks then go through Assemble in the order of
+// the Avail blocks. It returns ErrNoExtrinsicFound for the Avail block
+// without any extrinsics of the decoder's AppID.
+func (d *BlockDecoder) Extract(ctx context.Context, blk *types.SignedBlock) (*ExtractedBlock, error) {
+	if !hasAppExtrinsics(blk, d.appID) {
+		return nil, ErrNoExtrinsicFound
+	}
+
+	callIdx, err := d.callIndex.At(ctx, uint64(blk.Block.Header.Number))
+	if err != nil {
+		return nil, err
+	}
+
+	return &ExtractedBlock{SignedBlock: blk, data: extractBlockData(blk, d.appID, callIdx, true, d.logger)}, nil
+}
+
+// Assemble returns the Edge blocks of the extracted Avail block, in the order
+// of their extrinsics, reassembling the chunked ones, the second half of
+// Decode. The extracted blocks are assembled in the order of the Avail
+// blocks, the one carrying the last chunk of a chunked block completing it.
+// It returns ErrNoExtrinsicFound when the Avail block doesn't carry any
+// complete Edge block.
+func (d *BlockDecoder) Assemble(x *ExtractedBlock) ([]EdgeBlock, error) {
+	return assembleBlocks(x.SignedBlock, x.data, d.chunks, d.logger)
+}
@@ -0,0 +1,166 @@
+package avail
+
+import (
+	"context"
+	"errors"
+	"math/bits"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+)
+
+const (
+	// DefaultMortalityPeriod is the default number of Avail blocks a submitted
+	// extrinsic stays valid for.
+	DefaultMortalityPeriod = 64
+
+	// DefaultTip is the default tip paid for the inclusion of an extrinsic.
+	DefaultTip = 100
+
+	// minMortalityPeriod and maxMortalityPeriod bound the period of a mortal era.
+	minMortalityPeriod = 4
+	maxMortalityPeriod = 1 << 16
+)
+
+// ErrEraExpired is the error returned when the mortal era of the submitted
+// extrinsic lapses before the extrinsic is included in an Avail block.
+var ErrEraExpired = errors.New("extrinsic era expired")
+
+// IsEraExpiredError returns true when the error indicates that the extrinsic
+// wasn't included in Avail within its mortal era.
+func IsEraExpiredError(err error) bool {
+	return errors.Is(classifyError(err), ErrEraExpired)
+}
+
+// SubmitOption configures the extrinsics submitted by the sender.
+type SubmitOption func(*submitOptions)
+
+// submitOptions are the signing options of the submitted extrinsics.
+type submitOptions struct {
+	mortality uint64
+	tip       uint64
+	submitted func(Submission)
+}
+
+// WithMortality makes the submitted extrinsics valid for the given number of
+// Avail blocks only; 0 submits them immortal.
+func WithMortality(period uint64) SubmitOption {
+	return func(o *submitOptions) {
+		o.mortality = period
+	}
+}
+
+// WithTip sets the tip paid for the inclusion of the submitted extrinsics.
+func WithTip(tip uint64) SubmitOption {
+	return func(o *submitOptions) {
+		o.tip = tip
+	}
+}
+
+// WithSubmitted calls the hook with every extrinsic submitted, once it's
+// accepted into the Avail pool and before it's included in a block.
+func WithSubmitted(hook func(Submission)) SubmitOption {
+	return func(o *submitOptions) {
+		o.submitted = hook
+	}
+}
+
+// SubmittedHook returns the hook set by WithSubmitted among the options, or
+// nil; it lets the Senders outside of this package call it.
+func SubmittedHook(opts ...SubmitOption) func(Submission) {
+	return submitOptions{}.apply(opts).submitted
+}
+
+// apply returns a copy of the options with the given options applied.
+func (o submitOptions) apply(opts []SubmitOption) submitOptions {
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+// eraPeriod returns the actual period of the mortal era of the given period:
+// rounded up to the power of two and bound to the supported range.
+func eraPeriod(period uint64) uint64 {
+	if period&(period-1) != 0 {
+		period = 1 << bits.Len64(period)
+	}
+
+	if period < minMortalityPeriod {
+		period = minMortalityPeriod
+	}
+
+	if period > maxMortalityPeriod {
+		period = maxMortalityPeriod
+	}
+
+	return period
+}
+
+// mortalEra returns the mortal era of the given period, starting at the
+// current block, along with the number of its birth block. The encoding
+// follows the one of Substrate.
+func mortalEra(period, current uint64) (types.ExtrinsicEra, uint64) {
+	period = eraPeriod(period)
+
+	quantizeFactor := period >> 12
+	if quantizeFactor < 1 {
+		quantizeFactor = 1
+	}
+
+	phase := current % period / quantizeFactor * quantizeFactor
+
+	encoded := uint16(bits.TrailingZeros64(period) - 1)
+	if encoded > 15 {
+		encoded = 15
+	}
+	encoded |= uint16(phase/quantizeFactor) << 4
+
+	era := types.ExtrinsicEra{
+		IsMortalEra: true,
+		AsMortalEra: types.MortalEra{First: byte(encoded), Second: byte(encoded >> 8)},
+	}
+
+	birth := current - (current-phase)%period
+
+	return era, birth
+}
+
+// signingEra returns the era of the extrinsic signed now, and the hash and
+// the number of the block the era starts at.
+func (c *client) signingEra(ctx context.Context, mortality uint64) (types.ExtrinsicEra, types.Hash, uint64, error) {
+	if mortality == 0 {
+		// This transaction is Immortal (https://wiki.polkadot.network/docs/build-protocol-info#transaction-mortality)
+		// Hence BlockHash: Genesis Hash.
+		return types.ExtrinsicEra{IsImmortalEra: true}, c.genesisHash, 0, nil
+	}
+
+	hdr, err := c.getHeaderLatest(ctx)
+	if err != nil {
+		return types.ExtrinsicEra{}, types.Hash{}, 0, err
+	}
+
+	era, birth := mortalEra(mortality, uint64(hdr.Number))
+
+	birthHash, err := c.getBlockHash(ctx, birth)
+	if err != nil {
+		return types.ExtrinsicEra{}, types.Hash{}, 0, err
+	}
+
+	return era, birthHash, birth, nil
+}
+
+// eraExpired reports whether the mortal era of the given period, born at
+// the given block, has lapsed by the current Avail head.
+func (c *client) eraExpired(ctx context.Context, mortality, birth uint64) bool {
+	if mortality == 0 {
+		return false
+	}
+
+	hdr, err := c.getHeaderLatest(ctx)
+	if err != nil {
+		return false
+	}
+
+	return uint64(hdr.Number) >= birth+eraPeriod(mortality)
+}
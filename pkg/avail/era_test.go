@@ -0,0 +1,140 @@
+package avail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	edge_types "github.com/0xPolygon/polygon-edge/types"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/signature"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types/codec"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMortalEra(t *testing.T) {
+	testCases := []struct {
+		period, current uint64
+		first, second   byte
+		birth           uint64
+	}{
+		// Vectors from the Substrate era encoding tests.
+		{period: 64, current: 42, first: 165, second: 2, birth: 42},
+		{period: 32768, current: 20000, first: 78, second: 156, birth: 20000},
+		// Periods are rounded up to the power of two.
+		{period: 50, current: 42, first: 165, second: 2, birth: 42},
+		// Large periods quantize the phase.
+		{period: 1 << 16, current: 70007, first: 0x7f, second: 0x11, birth: 70000},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%d@%d", tc.period, tc.current), func(t *testing.T) {
+			era, birth := mortalEra(tc.period, tc.current)
+
+			assert.True(t, era.IsMortalEra)
+			assert.Equal(t, types.MortalEra{First: tc.first, Second: tc.second}, era.AsMortalEra)
+			assert.Equal(t, tc.birth, birth)
+		})
+	}
+}
+
+func TestSignedExtrinsicEraAndTip(t *testing.T) {
+	chain := newStubChain(t, 42, time.Hour)
+	chain.runtimes[0].metadata = stubMetadata(t, 42)
+	e := newStubEndpoint(t, chain)
+
+	c, err := NewClient(e.URL, hclog.NewNullLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ep, err := endpoint(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewSender(c, types.NewUCompactFromUInt(1), NewKeyringSigner(signature.TestKeyringPairAlice), WithTip(500)).(*sender)
+
+	payloads, err := s.payloads(&edge_types.Block{Header: &edge_types.Header{Number: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// signed returns the extrinsic as received by Avail.
+	signed := func(opts ...SubmitOption) (types.Extrinsic, uint64) {
+		ext, birth, err := s.prepareExtrinsicForSend(context.Background(), ep, payloads[0], 0, s.opts.apply(opts))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		enc, err := codec.EncodeToHex(ext)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var dec types.Extrinsic
+		if err := codec.DecodeFromHex(enc, &dec); err != nil {
+			t.Fatal(err)
+		}
+
+		return dec, birth
+	}
+
+	ext, birth := signed()
+	expectEra, _ := mortalEra(DefaultMortalityPeriod, 42)
+	assert.Equal(t, expectEra, ext.Signature.Era)
+	assert.Equal(t, types.NewUCompactFromUInt(500), ext.Signature.Tip)
+	assert.Equal(t, uint64(42), birth)
+
+	// Per-call options override the ones of the sender.
+	ext, _ = signed(WithTip(5000), WithMortality(256))
+	expectEra, _ = mortalEra(256, 42)
+	assert.Equal(t, expectEra, ext.Signature.Era)
+	assert.Equal(t, types.NewUCompactFromUInt(5000), ext.Signature.Tip)
+
+	ext, _ = signed(WithMortality(0))
+	assert.True(t, ext.Signature.Era.IsImmortalEra)
+}
+
+func TestEraExpiredIsResubmittable(t *testing.T) {
+	assert.True(t, IsEraExpiredError(fmt.Errorf("%w: extrinsic born at block 1", ErrEraExpired)))
+	assert.True(t, IsEraExpiredError(errors.New("1010: Invalid Transaction: Transaction has an ancient birth block")))
+	assert.False(t, IsEraExpiredError(errors.New("1010: Invalid Transaction: Transaction is outdated")))
+
+	assert.True(t, isResubmittable(ErrEraExpired))
+	assert.False(t, isResubmittable(ErrExtrinsicDropped))
+	assert.Equal(t, failureClassEraExpired, submissionFailureClass(ErrEraExpired))
+}
+
+func TestSenderNotifiesSubmitted(t *testing.T) {
+	chain := newStubChain(t, 42, time.Hour)
+	chain.runtimes[0].metadata = stubMetadata(t, 42)
+	e := newStubEndpoint(t, chain)
+
+	c, err := NewClient(e.URL, hclog.NewNullLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewSender(c, types.NewUCompactFromUInt(1), NewKeyringSigner(signature.TestKeyringPairAlice))
+	blk := &edge_types.Block{Header: &edge_types.Header{Number: 1}}
+
+	var subs []Submission
+	hook := WithSubmitted(func(sub Submission) { subs = append(subs, sub) })
+
+	assert.NoError(t, s.Send(context.Background(), blk, hook))
+	assert.NoError(t, s.Send(context.Background(), blk, hook, WithMortality(0)))
+
+	// Rejected extrinsics aren't reported.
+	e.rejectSubmissions("1002: Verification Error: Runtime error")
+	assert.Error(t, s.Send(context.Background(), blk, hook))
+
+	if assert.Len(t, subs, 2) {
+		assert.Equal(t, Submission{Nonce: subs[0].Nonce, Birth: 42, Mortality: DefaultMortalityPeriod}, subs[0])
+		// The immortal extrinsic is born at the head it's submitted at.
+		assert.Equal(t, Submission{Nonce: subs[0].Nonce + 1, Birth: 42}, subs[1])
+	}
+}
@@ -0,0 +1,225 @@
+package avail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	gethrpc "github.com/centrifuge/go-substrate-rpc-client/v4/gethrpc"
+)
+
+// The taxonomy of the errors returned by Avail. The raw errors of the
+// JSON-RPC calls are mapped into these at the client boundary, so the callers
+// can branch on them with errors.Is instead of matching the error messages.
+var (
+	// ErrConnection is the error returned when the Avail endpoint couldn't
+	// be reached, dropped the connection or didn't answer in time.
+	ErrConnection = errors.New("Avail connection failed")
+
+	// ErrSubscriptionClosed is the error returned when a subscription to
+	// Avail ended before delivering the awaited updates.
+	ErrSubscriptionClosed = errors.New("Avail subscription closed")
+
+	// ErrInsufficientBalance is the error returned when the Avail account
+	// can't pay the fees of the submitted extrinsic.
+	ErrInsufficientBalance = errors.New("insufficient Avail account balance")
+
+	// ErrNonceStale is the error returned when the nonce of the submitted
+	// extrinsic was already used, or is too far in the future.
+	ErrNonceStale = errors.New("stale Avail extrinsic nonce")
+
+	// ErrBlobTooLarge is the error returned when the block data doesn't fit
+	// in a blob or exceeds the limits of an Avail block.
+	ErrBlobTooLarge = errors.New("blob too large")
+
+	// ErrInvalidTransaction is the error returned when Avail rejects the
+	// submitted extrinsic as invalid for a reason not covered above.
+	ErrInvalidTransaction = errors.New("invalid Avail transaction")
+)
+
+// JSON-RPC error codes of the Substrate transaction pool.
+const (
+	rpcCodeInvalidTransaction = 1010
+	rpcCodeAlreadyImported    = 1013
+	rpcCodeTooLowPriority     = 1014
+)
+
+// Error is an Avail error classified into the taxonomy above.
+type Error struct {
+	// Kind is the class of the error, one of the sentinel errors above.
+	Kind error
+
+	// Code is the JSON-RPC error code; zero when there was no response.
+	Code int
+
+	// Err is the raw error.
+	Err error
+}
+
+// Error returns the class of the error followed by the raw error message.
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Kind, e.Err)
+}
+
+// Unwrap returns the raw error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether the error is of the target class.
+func (e *Error) Is(target error) bool {
+	return e.Kind == target
+}
+
+// errorRule maps the raw errors with any of the JSON-RPC codes or messages
+// into the class.
+type errorRule struct {
+	kind     error
+	codes    []int
+	messages []string
+}
+
+// errorRules are tried in order; the specific reasons of invalid transactions
+// take precedence over the generic one.
+var errorRules = []errorRule{
+	{
+		kind:     ErrInsufficientBalance,
+		messages: []string{"inability to pay some fees", "balance too low", "insufficientbalance"},
+	},
+	{
+		kind:     ErrNonceStale,
+		codes:    []int{rpcCodeAlreadyImported, rpcCodeTooLowPriority},
+		messages: []string{"transaction is outdated", "transaction will be valid in the future", "priority is too low", "already imported"},
+	},
+	{
+		kind:     ErrEraExpired,
+		messages: []string{"ancient birth block"},
+	},
+	{
+		kind:     ErrBlobTooLarge,
+		messages: []string{"exhaust the block limits", "exhausts the resources", "datatoolong"},
+	},
+	{
+		kind:     ErrInvalidTransaction,
+		codes:    []int{rpcCodeInvalidTransaction},
+		messages: []string{"invalid transaction", "bad signature"},
+	},
+	{
+		kind:     ErrSubscriptionClosed,
+		messages: []string{"subscription queue overflow", "subscription not found", "notifications not supported"},
+	},
+	{
+		kind:     ErrConnection,
+		messages: []string{"connection refused", "connection reset", "broken pipe", "use of closed network connection", "client is closed", "websocket: close", "no such host", "i/o timeout"},
+	},
+}
+
+// errorKinds are the classes of the taxonomy, including the ones of the
+// errors raised by the package itself.
+var errorKinds = []error{
+	ErrConnection, ErrSubscriptionClosed, ErrInsufficientBalance, ErrNonceStale,
+	ErrBlobTooLarge, ErrInvalidTransaction, ErrEraExpired,
+}
+
+// classifyError maps the raw error of an Avail JSON-RPC call into the
+// taxonomy. Errors that are already classified, context errors and the ones
+// of no known class are returned as they are.
+func classifyError(err error) error {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+
+	if isClassified(err) {
+		return err
+	}
+
+	var code int
+
+	var coded gethrpc.Error
+	if errors.As(err, &coded) {
+		code = coded.ErrorCode()
+	}
+
+	if kind := errorKind(err, code); kind != nil {
+		return &Error{Kind: kind, Code: code, Err: err}
+	}
+
+	return err
+}
+
+// isClassified reports whether the error belongs to any class of the taxonomy.
+func isClassified(err error) bool {
+	for _, kind := range errorKinds {
+		if errors.Is(err, kind) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// errorKind returns the class of the raw error, or nil if it's of no known class.
+func errorKind(err error, code int) error {
+	switch {
+	case errors.Is(err, gethrpc.ErrSubscriptionQueueOverflow), errors.Is(err, gethrpc.ErrNotificationsUnsupported):
+		return ErrSubscriptionClosed
+	case errors.Is(err, gethrpc.ErrClientQuit), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return ErrConnection
+	}
+
+	msg := strings.ToLower(err.Error())
+
+	for _, rule := range errorRules {
+		for _, c := range rule.codes {
+			if code == c {
+				return rule.kind
+			}
+		}
+
+		for _, m := range rule.messages {
+			if strings.Contains(msg, m) {
+				return rule.kind
+			}
+		}
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return ErrConnection
+	}
+
+	return nil
+}
+
+// callError classifies the error of a JSON-RPC call made with the given
+// context. The deadline of the call itself, unlike the one of the caller,
+// means the endpoint didn't answer in time.
+func callError(ctx context.Context, err error) error {
+	if err == nil || ctx.Err() != nil {
+		return err
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return &Error{Kind: ErrConnection, Err: err}
+	}
+
+	return classifyError(err)
+}
+
+// subscriptionError classifies the error received from a subscription; the
+// subscriptions ended without an error, or with one of no known class, are
+// reported as closed.
+func subscriptionError(err error) error {
+	if err == nil {
+		return &Error{Kind: ErrSubscriptionClosed, Err: errors.New("subscription ended")}
+	}
+
+	if err = classifyError(err); isClassified(err) {
+		return err
+	}
+
+	return &Error{Kind: ErrSubscriptionClosed, Err: err}
+}
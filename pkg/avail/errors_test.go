@@ -0,0 +1,156 @@
+package avail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+
+	gethrpc "github.com/centrifuge/go-substrate-rpc-client/v4/gethrpc"
+	"github.com/stretchr/testify/assert"
+)
+
+// rpcError is a JSON-RPC error response, as returned by gsrpc.
+type rpcError struct {
+	code int
+	msg  string
+}
+
+func (e rpcError) Error() string  { return e.msg }
+func (e rpcError) ErrorCode() int { return e.code }
+
+func TestClassifyError(t *testing.T) {
+	testCases := []struct {
+		name     string
+		err      error
+		kind     error
+		wantCode int
+	}{
+		{
+			name: "balance too low",
+			err:  rpcError{1010, "Invalid Transaction: Inability to pay some fees (e.g. account balance too low)"},
+			kind: ErrInsufficientBalance, wantCode: 1010,
+		},
+		{
+			name: "outdated nonce",
+			err:  errors.New("1010: Invalid Transaction: Transaction is outdated"),
+			kind: ErrNonceStale,
+		},
+		{
+			name: "future nonce",
+			err:  rpcError{1010, "Invalid Transaction: Transaction will be valid in the future"},
+			kind: ErrNonceStale, wantCode: 1010,
+		},
+		{
+			name: "replaced nonce",
+			err:  rpcError{1014, "Priority is too low: (3 vs 3)"},
+			kind: ErrNonceStale, wantCode: 1014,
+		},
+		{
+			name: "already imported",
+			err:  rpcError{1013, "Transaction Already Imported"},
+			kind: ErrNonceStale, wantCode: 1013,
+		},
+		{
+			name: "ancient birth block",
+			err:  rpcError{1010, "Invalid Transaction: Transaction has an ancient birth block"},
+			kind: ErrEraExpired, wantCode: 1010,
+		},
+		{
+			name: "exhausted block limits",
+			err:  rpcError{1010, "Invalid Transaction: Transaction would exhaust the block limits"},
+			kind: ErrBlobTooLarge, wantCode: 1010,
+		},
+		{
+			name: "local size limit",
+			err:  fmt.Errorf("encoding blob: %w", ErrDataTooLong),
+			kind: ErrBlobTooLarge,
+		},
+		{
+			name: "bad signature",
+			err:  rpcError{1010, "Invalid Transaction: Transaction has a bad signature"},
+			kind: ErrInvalidTransaction, wantCode: 1010,
+		},
+		{
+			name: "invalid transaction code",
+			err:  rpcError{1010, "Invalid Transaction"},
+			kind: ErrInvalidTransaction, wantCode: 1010,
+		},
+		{
+			name: "subscription queue overflow",
+			err:  gethrpc.ErrSubscriptionQueueOverflow,
+			kind: ErrSubscriptionClosed,
+		},
+		{
+			name: "connection refused",
+			err:  &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED},
+			kind: ErrConnection,
+		},
+		{
+			name: "connection dropped",
+			err:  io.ErrUnexpectedEOF,
+			kind: ErrConnection,
+		},
+		{
+			name: "client closed",
+			err:  gethrpc.ErrClientQuit,
+			kind: ErrConnection,
+		},
+		{
+			name: "unknown rpc error",
+			err:  rpcError{1002, "Verification Error: Runtime error"},
+		},
+		{
+			name: "canceled",
+			err:  context.Canceled,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			err := classifyError(tc.err)
+
+			// The raw error is preserved in any case.
+			assert.ErrorIs(t, err, tc.err)
+
+			if tc.kind == nil {
+				assert.Equal(t, tc.err, err)
+				return
+			}
+
+			assert.ErrorIs(t, err, tc.kind)
+
+			var classified *Error
+			if errors.As(err, &classified) {
+				assert.Equal(t, tc.wantCode, classified.Code)
+			}
+
+			// Classifying is idempotent.
+			assert.Equal(t, err, classifyError(err))
+		})
+	}
+}
+
+func TestCallErrorTimeout(t *testing.T) {
+	// The deadline of the call itself is a connection failure...
+	err := callError(context.Background(), context.DeadlineExceeded)
+	assert.ErrorIs(t, err, ErrConnection)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// ...while the one of the caller is not.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	assert.Equal(t, context.Canceled, callError(ctx, context.Canceled))
+}
+
+func TestSubscriptionError(t *testing.T) {
+	assert.ErrorIs(t, subscriptionError(nil), ErrSubscriptionClosed)
+	assert.ErrorIs(t, subscriptionError(errors.New("unexpected")), ErrSubscriptionClosed)
+	assert.ErrorIs(t, subscriptionError(io.EOF), ErrConnection)
+}
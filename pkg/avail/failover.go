@@ -0,0 +1,497 @@
+package avail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/armon/go-metrics"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/hashicorp/go-hclog"
+)
+
+const (
+	// MaxEndpointErrors is the number of consecutive errors tolerated on the
+	// active Avail endpoint before the client fails over to the next one.
+	MaxEndpointErrors = 3
+
+	// failoverRetryInterval is the delay between reconnection attempts when
+	// none of the configured Avail endpoints is healthy.
+	failoverRetryInterval = time.Second
+)
+
+var (
+	// ErrNoEndpoints indicates that no Avail endpoints were configured.
+	ErrNoEndpoints = errors.New("no Avail endpoints configured")
+
+	// ErrNoHealthyEndpoint indicates that none of the configured Avail endpoints passed the health check.
+	ErrNoHealthyEndpoint = errors.New("no healthy Avail endpoint available")
+)
+
+// EndpointSetter is the Client whose Avail endpoints can be replaced while
+// it runs.
+type EndpointSetter interface {
+	SetEndpoints(urls []string) error
+}
+
+// failoverClient is an implementation of the Client interface that spreads
+// over multiple Avail JSON-RPC endpoints. It always talks to the first healthy
+// endpoint and switches over to the next one after repeated errors.
+type failoverClient struct {
+	endpoints   []string
+	genesisHash types.Hash
+	logger      hclog.Logger
+	opts        []ClientOption
+
+	lock      sync.RWMutex
+	active    int
+	current   *client
+	errorsCnt int
+
+	// switching is closed once the switch away from the active endpoint,
+	// dialing the others without the lock held, is over; nil if none is
+	// going on.
+	switching chan struct{}
+
+	// The nonces go on across the endpoints, all of the same chain.
+	nonces accountNonces
+}
+
+// NewFailoverClient constructs a new Avail Client for the specified list of URLs.
+// The endpoints are health-checked in the given order and the first healthy
+// one becomes active.
+//
+// Parameters:
+//   - urls: The URLs of the Avail JSON-RPC servers, in the order of preference.
+//   - logger: The logger instance.
+//   - opts: The optional settings applied to every endpoint client.
+//
+// Return:
+//   - Client: The Avail client instance.
+//   - error: An error if none of the endpoints is healthy.
+func NewFailoverClient(urls []string, logger hclog.Logger, opts ...ClientOption) (Client, error) {
+	if len(urls) == 0 {
+		return nil, ErrNoEndpoints
+	}
+
+	fc := &failoverClient{
+		endpoints: urls,
+		logger:    logger.Named("avail_failover"),
+		opts:      opts,
+	}
+
+	active, c, err := fc.connect(urls, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	fc.activate(urls, active, c)
+	fc.genesisHash = c.genesisHash
+
+	return fc, nil
+}
+
+// connect health-checks the given endpoints, starting from the one at index
+// `from`, and returns the index and the client of the first healthy one. It
+// dials the endpoints, so it must be called without the lock held.
+func (fc *failoverClient) connect(endpoints []string, from int) (int, *client, error) {
+	for i := 0; i < len(endpoints); i++ {
+		idx := (from + i) % len(endpoints)
+		url := endpoints[idx]
+
+		c, err := fc.dial(url)
+		if err != nil {
+			fc.logger.Warn("Avail endpoint failed health check", "endpoint", url, "error", err)
+			continue
+		}
+
+		return idx, c, nil
+	}
+
+	return 0, nil, ErrNoHealthyEndpoint
+}
+
+// activate makes the client of the endpoint at index `active` of the given
+// endpoints the active one, closing the one it replaces. It must be called
+// with the lock held.
+func (fc *failoverClient) activate(endpoints []string, active int, c *client) {
+	if fc.current != nil {
+		fc.current.api.Client.Close()
+	}
+
+	fc.endpoints = endpoints
+	fc.active = active
+	fc.current = c
+	fc.errorsCnt = 0
+}
+
+// dial connects to the given endpoint and verifies that it is serving the
+// same Avail network as the previously active endpoints.
+func (fc *failoverClient) dial(url string) (*client, error) {
+	c, err := NewClient(url, fc.logger, fc.opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	c2 := c.(*client)
+
+	if fc.genesisHash != (types.Hash{}) && c2.genesisHash != fc.genesisHash {
+		c2.api.Client.Close()
+		return nil, fmt.Errorf("genesis hash mismatch: expected %s, got %s", fc.genesisHash.Hex(), c2.genesisHash.Hex())
+	}
+
+	if _, err := c2.GetLatestHeader(context.Background()); err != nil {
+		c2.api.Client.Close()
+		return nil, err
+	}
+
+	return c2, nil
+}
+
+// get returns the currently active endpoint client.
+func (fc *failoverClient) get() *client {
+	fc.lock.RLock()
+	defer fc.lock.RUnlock()
+
+	return fc.current
+}
+
+// Endpoint returns the URL of the currently active Avail endpoint.
+func (fc *failoverClient) Endpoint() string {
+	fc.lock.RLock()
+	defer fc.lock.RUnlock()
+
+	return fc.endpoints[fc.active]
+}
+
+// SetEndpoints replaces the endpoints of the client with the given ones, in
+// the order of preference. The active endpoint stays active if it's among
+// them; otherwise the first healthy one of them becomes active, and the
+// endpoints are left as they were if none is.
+func (fc *failoverClient) SetEndpoints(urls []string) error {
+	if len(urls) == 0 {
+		return ErrNoEndpoints
+	}
+
+	urls = append([]string(nil), urls...)
+
+	fc.lock.Lock()
+
+	from := fc.endpoints[fc.active]
+
+	for i, url := range urls {
+		if url == from {
+			fc.endpoints, fc.active = urls, i
+			fc.lock.Unlock()
+
+			return nil
+		}
+	}
+
+	fc.lock.Unlock()
+
+	active, c, err := fc.connect(urls, 0)
+	if err != nil {
+		return err
+	}
+
+	fc.lock.Lock()
+	defer fc.lock.Unlock()
+
+	// The endpoints given last win over a failover meanwhile.
+	fc.activate(urls, active, c)
+
+	fc.logger.Warn("switched Avail endpoint", "from", from, "to", urls[active], "reason", "endpoints replaced")
+	metrics.IncrCounter([]string{"avail", "endpoint_switches"}, 1)
+
+	return nil
+}
+
+// report records the outcome of a call made through the given endpoint client.
+// Consecutive errors on the active endpoint trigger a failover once they
+// reach MaxEndpointErrors.
+func (fc *failoverClient) report(c *client, err error) {
+	fc.lock.Lock()
+
+	// Ignore results from endpoints that are not active anymore.
+	if fc.current != c {
+		fc.lock.Unlock()
+		return
+	}
+
+	if err == nil {
+		fc.errorsCnt = 0
+		fc.lock.Unlock()
+
+		return
+	}
+
+	fc.errorsCnt++
+	reached := fc.errorsCnt >= MaxEndpointErrors
+	fc.lock.Unlock()
+
+	if reached {
+		_ = fc.failover(c, err)
+	}
+}
+
+// failover switches away from the given endpoint client immediately, unless
+// some other caller has done so already. A caller finding the switch under
+// way waits for it to be over.
+func (fc *failoverClient) failover(c *client, reason error) error {
+	fc.lock.Lock()
+
+	for fc.current == c && fc.switching != nil {
+		switching := fc.switching
+		fc.lock.Unlock()
+		<-switching
+		fc.lock.Lock()
+	}
+
+	if fc.current != c {
+		fc.lock.Unlock()
+		return nil
+	}
+
+	switching := make(chan struct{})
+	fc.switching = switching
+	endpoints, from := fc.endpoints, fc.active
+	fc.lock.Unlock()
+
+	active, next, err := fc.connect(endpoints, from+1)
+
+	fc.lock.Lock()
+	defer fc.lock.Unlock()
+
+	fc.switching = nil
+	close(switching)
+
+	if err != nil {
+		fc.logger.Error("failed to switch Avail endpoint", "from", endpoints[from], "reason", reason, "error", err)
+		return err
+	}
+
+	// The endpoints were replaced meanwhile.
+	if fc.current != c {
+		next.api.Client.Close()
+		return nil
+	}
+
+	fc.activate(endpoints, active, next)
+
+	fc.logger.Warn("switched Avail endpoint", "from", endpoints[from], "to", endpoints[active], "reason", reason)
+	metrics.IncrCounter([]string{"avail", "endpoint_switches"}, 1)
+
+	return nil
+}
+
+// BlockStream creates a new Avail block stream starting from the specified
+// offset. The stream transparently resubscribes on the next healthy endpoint
+// from the last processed height when the active endpoint fails.
+//
+// Parameters:
+//   - ctx: The context that closes the stream when canceled.
+//   - offset: The block height offset to start the stream from.
+//
+// Return:
+//   - BlockStream: The block stream.
+func (fc *failoverClient) BlockStream(ctx context.Context, offset uint64) BlockStream {
+	return newFailoverBlockStream(ctx, fc, offset)
+}
+
+// GenesisHash returns the genesis hash of the Avail network.
+//
+// Return:
+//   - types.Hash: The genesis hash.
+func (fc *failoverClient) GenesisHash() types.Hash {
+	return fc.genesisHash
+}
+
+// GetLatestHeader retrieves the latest header from the active Avail endpoint.
+//
+// Parameters:
+//   - ctx: The context of the call.
+//
+// Return:
+//   - *types.Header: The latest header.
+//   - error: An error if the retrieval fails.
+func (fc *failoverClient) GetLatestHeader(ctx context.Context) (*types.Header, error) {
+	c := fc.get()
+
+	hdr, err := c.GetLatestHeader(ctx)
+	if ctx.Err() == nil {
+		fc.report(c, err)
+	}
+
+	return hdr, err
+}
+
+// Query fetches the historical Avail blocks in the [from, to] height range
+// from the active Avail endpoint.
+//
+// Parameters:
+//   - ctx: The context of the query.
+//   - from: The height of the first block to fetch.
+//   - to: The height of the last block to fetch.
+//
+// Return:
+//   - []*types.SignedBlock: The fetched blocks.
+//   - error: An error if fetching any of the blocks fails.
+func (fc *failoverClient) Query(ctx context.Context, from, to uint64) ([]*types.SignedBlock, error) {
+	c := fc.get()
+
+	blks, err := c.Query(ctx, from, to)
+	if ctx.Err() == nil {
+		fc.report(c, err)
+	}
+
+	return blks, err
+}
+
+// SearchBlock searches for a block on the active Avail endpoint.
+//
+// Parameters:
+//   - ctx: The context of the search.
+//   - offset: The offset from the current block to start the search.
+//   - searchFunc: The search function that determines the seek offset.
+//
+// Return:
+//   - *types.SignedBlock: The found block.
+//   - error: An error if the block search fails.
+func (fc *failoverClient) SearchBlock(ctx context.Context, offset int64, searchFunc SearchFunc) (*types.SignedBlock, error) {
+	c := fc.get()
+
+	blk, err := c.SearchBlock(ctx, offset, searchFunc)
+	if ctx.Err() == nil {
+		fc.report(c, err)
+	}
+
+	return blk, err
+}
+
+// reportResult records the outcome of a call made through the given endpoint
+// client when the client supports failover. It is a no-op otherwise. Calls
+// abandoned because their context was canceled don't count as endpoint errors.
+func reportResult(ctx context.Context, c Client, endpoint *client, err error) {
+	if ctx.Err() != nil {
+		return
+	}
+
+	if fc, ok := c.(*failoverClient); ok {
+		fc.report(endpoint, err)
+	}
+}
+
+// failoverBlockStream implements the BlockStream interface on top of a
+// failoverClient. It keeps track of the next expected block height and
+// restarts the underlying stream on a healthy endpoint when it fails.
+type failoverBlockStream struct {
+	ctx     context.Context
+	client  *failoverClient
+	closed  *atomic.Bool
+	closeCh chan struct{}
+	dataCh  chan *types.SignedBlock
+	logger  hclog.Logger
+	next    uint64
+}
+
+// newFailoverBlockStream creates a new failover aware block stream.
+func newFailoverBlockStream(ctx context.Context, client *failoverClient, offset uint64) BlockStream {
+	fs := &failoverBlockStream{
+		ctx:     ctx,
+		client:  client,
+		closed:  new(atomic.Bool),
+		closeCh: make(chan struct{}),
+		dataCh:  make(chan *types.SignedBlock),
+		logger:  client.logger.Named("blockstream"),
+		next:    offset,
+	}
+
+	go fs.run()
+
+	return fs
+}
+
+// Close closes the block stream.
+func (fs *failoverBlockStream) Close() {
+	if fs.closed.CompareAndSwap(false, true) {
+		close(fs.closeCh)
+	}
+}
+
+// Chan returns the channel on which the signed blocks are received.
+func (fs *failoverBlockStream) Chan() <-chan *types.SignedBlock {
+	return fs.dataCh
+}
+
+// run streams blocks from the active endpoint and fails over to the next
+// healthy endpoint whenever the underlying stream dies or skips blocks.
+func (fs *failoverBlockStream) run() {
+	for {
+		c := fs.client.get()
+		bs := newBlockStream(fs.ctx, c, fs.logger, fs.next).(*blockStream)
+
+		reason := fs.forward(bs)
+		bs.Close()
+
+		if reason == nil {
+			close(fs.dataCh)
+			return
+		}
+
+		fs.logger.Warn("Avail block stream interrupted; failing over", "next_block", fs.next, "reason", reason)
+
+		for fs.client.failover(c, reason) != nil {
+			select {
+			case <-fs.closeCh:
+				close(fs.dataCh)
+				return
+			case <-fs.ctx.Done():
+				close(fs.dataCh)
+				return
+			case <-time.After(failoverRetryInterval):
+			}
+		}
+	}
+}
+
+// forward relays the blocks from the underlying stream to the consumer. It
+// returns nil when the stream was closed by the consumer, or the reason why
+// the underlying stream must be restarted.
+func (fs *failoverBlockStream) forward(bs *blockStream) error {
+	for {
+		select {
+		case <-fs.closeCh:
+			return nil
+
+		case <-fs.ctx.Done():
+			return nil
+
+		case <-bs.doneCh:
+			return errors.New("block stream terminated")
+
+		case blk := <-bs.dataCh:
+			number := uint64(blk.Block.Header.Number)
+
+			switch {
+			case fs.next != 0 && number < fs.next:
+				// Omit blocks that were already streamed.
+				continue
+			case fs.next != 0 && number > fs.next:
+				return fmt.Errorf("gap in block stream: expected %d, got %d", fs.next, number)
+			}
+
+			select {
+			case <-fs.closeCh:
+				return nil
+			case <-fs.ctx.Done():
+				return nil
+			case fs.dataCh <- blk:
+				fs.next = number + 1
+			}
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package avail
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFailoverClientNoEndpoints(t *testing.T) {
+	_, err := NewFailoverClient(nil, hclog.NewNullLogger())
+	assert.ErrorIs(t, err, ErrNoEndpoints)
+}
+
+func TestFailoverClientSkipsUnhealthyEndpoint(t *testing.T) {
+	chain := newStubChain(t, 5, 50*time.Millisecond)
+	primary := newStubEndpoint(t, chain)
+	secondary := newStubEndpoint(t, chain)
+
+	primary.Kill()
+
+	c, err := NewFailoverClient([]string{primary.URL, secondary.URL}, hclog.NewNullLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, secondary.URL, c.(*failoverClient).Endpoint())
+}
+
+func TestFailoverClientStreamContinuesOnSecondary(t *testing.T) {
+	const (
+		offset = 1
+		killAt = 10
+	)
+
+	// Historical blocks may be prefetched ahead of the kill, so keep reading
+	// until the secondary has served a few live blocks.
+	lastHeight := uint64(25)
+
+	chain := newStubChain(t, 5, 20*time.Millisecond)
+	primary := newStubEndpoint(t, chain)
+	secondary := newStubEndpoint(t, chain)
+
+	c, err := NewFailoverClient([]string{primary.URL, secondary.URL}, hclog.NewNullLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fc := c.(*failoverClient)
+	assert.Equal(t, primary.URL, fc.Endpoint())
+
+	bs := c.BlockStream(context.Background(), offset)
+	defer bs.Close()
+
+	timeout := time.After(20 * time.Second)
+
+	var blockSeq []uint64
+	for len(blockSeq) == 0 || blockSeq[len(blockSeq)-1] < lastHeight {
+		select {
+		case blk := <-bs.Chan():
+			blockSeq = append(blockSeq, uint64(blk.Block.Header.Number))
+			if blk.Block.Header.Number == killAt {
+				if head := uint64(chain.head().Number) + 5; head > lastHeight {
+					lastHeight = head
+				}
+
+				primary.Kill()
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for blocks; received: %v", blockSeq)
+		}
+	}
+
+	var expectSequence []uint64
+	for i := uint64(offset); i <= lastHeight; i++ {
+		expectSequence = append(expectSequence, i)
+	}
+
+	assert.Equal(t, expectSequence, blockSeq)
+	assert.Equal(t, secondary.URL, fc.Endpoint())
+
+	// Calls made through the underlying endpoint, such as block submission,
+	// must be served by the secondary endpoint from now on.
+	ep, err := endpoint(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Same(t, fc.get(), ep)
+
+	hdr, err := c.GetLatestHeader(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.GreaterOrEqual(t, uint64(hdr.Number), lastHeight)
+}
+
+func TestFailoverClientSwitchesOnRepeatedErrors(t *testing.T) {
+	chain := newStubChain(t, 5, 50*time.Millisecond)
+	primary := newStubEndpoint(t, chain)
+	secondary := newStubEndpoint(t, chain)
+
+	c, err := NewFailoverClient([]string{primary.URL, secondary.URL}, hclog.NewNullLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fc := c.(*failoverClient)
+	primary.Kill()
+
+	for i := 0; i < MaxEndpointErrors; i++ {
+		assert.Equal(t, primary.URL, fc.Endpoint())
+
+		_, err := c.GetLatestHeader(context.Background())
+		assert.Error(t, err)
+	}
+
+	assert.Equal(t, secondary.URL, fc.Endpoint())
+
+	_, err = c.GetLatestHeader(context.Background())
+	assert.NoError(t, err)
+}
+
+func TestFailoverClientSetEndpoints(t *testing.T) {
+	chain := newStubChain(t, 5, 50*time.Millisecond)
+	primary := newStubEndpoint(t, chain)
+	secondary := newStubEndpoint(t, chain)
+	dead := newStubEndpoint(t, chain)
+
+	dead.Kill()
+
+	c, err := NewFailoverClient([]string{primary.URL}, hclog.NewNullLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fc := c.(*failoverClient)
+
+	// The active endpoint stays active among the new ones.
+	assert.NoError(t, fc.SetEndpoints([]string{secondary.URL, primary.URL}))
+	assert.Equal(t, primary.URL, fc.Endpoint())
+
+	// Left out, the first healthy new one takes over.
+	assert.NoError(t, fc.SetEndpoints([]string{dead.URL, secondary.URL}))
+	assert.Equal(t, secondary.URL, fc.Endpoint())
+
+	_, err = c.GetLatestHeader(context.Background())
+	assert.NoError(t, err)
+
+	// None healthy, the endpoints are left as they were.
+	assert.ErrorIs(t, fc.SetEndpoints([]string{dead.URL}), ErrNoHealthyEndpoint)
+	assert.ErrorIs(t, fc.SetEndpoints(nil), ErrNoEndpoints)
+	assert.Equal(t, secondary.URL, fc.Endpoint())
+}
+
+func TestFailoverClientDialsWithoutLock(t *testing.T) {
+	chain := newStubChain(t, 5, 50*time.Millisecond)
+	primary := newStubEndpoint(t, chain)
+	secondary := newStubEndpoint(t, chain)
+
+	// An endpoint whose handshake hangs until released, then fails.
+	var once sync.Once
+
+	dialed, release := make(chan struct{}), make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		once.Do(func() { close(dialed) })
+		<-release
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer slow.Close()
+
+	c, err := NewFailoverClient([]string{primary.URL, "ws" + strings.TrimPrefix(slow.URL, "http"), secondary.URL}, hclog.NewNullLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fc := c.(*failoverClient)
+	primary.Kill()
+
+	switched := make(chan error, 1)
+	go func() { switched <- fc.failover(fc.get(), errors.New("killed")) }()
+
+	<-dialed
+
+	// The client is at hand while the endpoints are dialed.
+	endpoint := make(chan string, 1)
+	go func() { endpoint <- fc.Endpoint() }()
+
+	select {
+	case url := <-endpoint:
+		assert.Equal(t, primary.URL, url)
+	case <-time.After(time.Second):
+		t.Fatal("client locked while dialing")
+	}
+
+	close(release)
+
+	assert.NoError(t, <-switched)
+	assert.Equal(t, secondary.URL, fc.Endpoint())
+}
@@ -0,0 +1,89 @@
+package avail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/hash"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+)
+
+// MaxResubmissions is the number of times the block data is resubmitted when
+// it can't be verified in the Avail block it was reported to be included in.
+const MaxResubmissions = 3
+
+var (
+	// ErrExtrinsicNotIncluded is the error returned when the submitted extrinsic is not found in the Avail block.
+	ErrExtrinsicNotIncluded = errors.New("extrinsic not included in Avail block")
+
+	// ErrDataMismatch is the error returned when the extrinsic found in the Avail block carries different data than submitted.
+	ErrDataMismatch = errors.New("included data doesn't match submitted data")
+)
+
+// DataHash returns the hash of the data carried by the extrinsic.
+func DataHash(ext types.Extrinsic) (types.Hash, error) {
+	h, err := hash.NewBlake2b256(nil)
+	if err != nil {
+		return types.Hash{}, err
+	}
+
+	h.Write(ext.Method.Args)
+
+	return types.NewHash(h.Sum(nil)), nil
+}
+
+// VerifyInclusion fetches the Avail block with the given hash and verifies
+// that it contains the submitted extrinsic with unaltered data.
+// It takes a context, a client, the hash of the containing Avail block and the submitted extrinsic.
+// It returns the SubmitResult and an error if the verification fails.
+func VerifyInclusion(ctx context.Context, client Client, blockHash types.Hash, ext types.Extrinsic) (SubmitResult, error) {
+	c, err := endpoint(client)
+	if err != nil {
+		return SubmitResult{}, err
+	}
+
+	blk, err := c.getBlock(ctx, blockHash)
+	reportResult(ctx, client, c, err)
+	if err != nil {
+		return SubmitResult{}, err
+	}
+
+	return verifyInclusion(blk, blockHash, ext)
+}
+
+// verifyInclusion locates the submitted extrinsic in the Avail block by its
+// signer and nonce and compares the hash of its data to the submitted one.
+func verifyInclusion(blk *types.SignedBlock, blockHash types.Hash, ext types.Extrinsic) (SubmitResult, error) {
+	dataHash, err := DataHash(ext)
+	if err != nil {
+		return SubmitResult{}, err
+	}
+
+	for i, included := range blk.Block.Extrinsics {
+		if !included.IsSigned() ||
+			!reflect.DeepEqual(included.Signature.Signer, ext.Signature.Signer) ||
+			included.Signature.Nonce.Int64() != ext.Signature.Nonce.Int64() {
+			continue
+		}
+
+		includedHash, err := DataHash(included)
+		if err != nil {
+			return SubmitResult{}, err
+		}
+
+		if included.Method.CallIndex != ext.Method.CallIndex || includedHash != dataHash {
+			return SubmitResult{}, fmt.Errorf("%w: block %s extrinsic %d: expected data hash %s, got %s", ErrDataMismatch, blockHash.Hex(), i, dataHash.Hex(), includedHash.Hex())
+		}
+
+		return SubmitResult{
+			BlockNumber:    uint64(blk.Block.Header.Number),
+			BlockHash:      blockHash,
+			ExtrinsicIndex: uint32(i),
+			DataHash:       dataHash,
+		}, nil
+	}
+
+	return SubmitResult{}, fmt.Errorf("%w: block %s", ErrExtrinsicNotIncluded, blockHash.Hex())
+}
@@ -0,0 +1,94 @@
+package avail
+
+import (
+	"testing"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/signature"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// signedExtrinsic builds a `submit_data` extrinsic with given data, signed by Alice.
+func signedExtrinsic(t *testing.T, nonce uint64, data []byte) types.Extrinsic {
+	t.Helper()
+
+	ext := types.NewExtrinsic(types.Call{
+		CallIndex: types.CallIndex{SectionIndex: 29, MethodIndex: 1},
+		Args:      data,
+	})
+
+	err := ext.Sign(signature.TestKeyringPairAlice, types.SignatureOptions{
+		Era:                types.ExtrinsicEra{IsMortalEra: false},
+		Nonce:              types.NewUCompactFromUInt(nonce),
+		Tip:                types.NewUCompactFromUInt(100),
+		AppID:              types.NewUCompactFromUInt(1),
+		SpecVersion:        1,
+		TransactionVersion: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return ext
+}
+
+func TestVerifyInclusion(t *testing.T) {
+	submitted := signedExtrinsic(t, 7, []byte("block data"))
+	blockHash := types.NewHash([]byte{0x01, 0x02})
+
+	testCases := []struct {
+		name        string
+		extrinsics  []types.Extrinsic
+		expectIndex uint32
+		expectErr   error
+	}{
+		{
+			name:        "matching inclusion",
+			extrinsics:  []types.Extrinsic{signedExtrinsic(t, 6, []byte("previous")), submitted},
+			expectIndex: 1,
+		},
+		{
+			name:       "missing extrinsic",
+			extrinsics: []types.Extrinsic{signedExtrinsic(t, 6, []byte("previous")), signedExtrinsic(t, 8, []byte("block data"))},
+			expectErr:  ErrExtrinsicNotIncluded,
+		},
+		{
+			name:       "tampered blob",
+			extrinsics: []types.Extrinsic{signedExtrinsic(t, 7, []byte("block dat4"))},
+			expectErr:  ErrDataMismatch,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			blk := &types.SignedBlock{
+				Block: types.Block{
+					Header:     types.Header{Number: 12},
+					Extrinsics: tc.extrinsics,
+				},
+			}
+
+			res, err := verifyInclusion(blk, blockHash, submitted)
+			if tc.expectErr != nil {
+				assert.ErrorIs(t, err, tc.expectErr)
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			dataHash, err := DataHash(submitted)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, SubmitResult{
+				BlockNumber:    12,
+				BlockHash:      blockHash,
+				ExtrinsicIndex: tc.expectIndex,
+				DataHash:       dataHash,
+			}, res)
+		})
+	}
+}
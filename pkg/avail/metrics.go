@@ -0,0 +1,143 @@
+package avail
+
+import (
+	"errors"
+	"time"
+
+	"github.com/armon/go-metrics"
+)
+
+// Classes of the block data submission failures, as reported in the
+// `avail.submission.failures` metric.
+const (
+	failureClassNonce        = "nonce"
+	failureClassNotIncluded  = "not_included"
+	failureClassDataMismatch = "data_mismatch"
+	failureClassDropped      = "dropped"
+	failureClassEraExpired   = "era_expired"
+	failureClassSigning      = "signing"
+	failureClassBalance      = "insufficient_balance"
+	failureClassTooLarge     = "too_large"
+	failureClassInvalid      = "invalid_transaction"
+	failureClassConnection   = "connection"
+	failureClassRPC          = "rpc"
+)
+
+// Kinds of the duplicate blocks suppressed by the watcher, as reported in the
+// `avail.watcher.duplicates` metric.
+const (
+	duplicateAvailBlock = "avail_block"
+	duplicateEdgeBlock  = "edge_block"
+)
+
+// ErrExtrinsicDropped is the error returned when Avail drops or invalidates the submitted extrinsic.
+var ErrExtrinsicDropped = errors.New("extrinsic dropped by Avail")
+
+// submissionFailureClass classifies the block data submission error.
+func submissionFailureClass(err error) string {
+	switch {
+	case errors.Is(err, ErrSigningFailed):
+		return failureClassSigning
+	case errors.Is(err, ErrInsufficientBalance):
+		return failureClassBalance
+	case errors.Is(err, ErrNonceStale):
+		return failureClassNonce
+	case errors.Is(err, ErrEraExpired):
+		return failureClassEraExpired
+	case errors.Is(err, ErrBlobTooLarge):
+		return failureClassTooLarge
+	case errors.Is(err, ErrInvalidTransaction):
+		return failureClassInvalid
+	case errors.Is(err, ErrConnection), errors.Is(err, ErrSubscriptionClosed):
+		return failureClassConnection
+	case errors.Is(err, ErrExtrinsicNotIncluded):
+		return failureClassNotIncluded
+	case errors.Is(err, ErrDataMismatch):
+		return failureClassDataMismatch
+	case errors.Is(err, ErrExtrinsicDropped):
+		return failureClassDropped
+	default:
+		return failureClassRPC
+	}
+}
+
+// observeSubmissionFailure records a failed block data submission.
+func observeSubmissionFailure(err error) {
+	metrics.IncrCounterWithLabels([]string{"avail", "submission", "failures"}, 1, []metrics.Label{
+		{Name: "class", Value: submissionFailureClass(err)},
+	})
+}
+
+// observeSubmission records the size of the block data submitted to Avail.
+func observeSubmission(payload []byte) {
+	metrics.IncrCounter([]string{"avail", "submission", "bytes"}, float32(len(payload)))
+	metrics.IncrCounter([]string{"avail", "submission", "extrinsics"}, 1)
+}
+
+// observeInclusion records the time it took from the submission until the
+// extrinsic was included in an Avail block.
+func observeInclusion(start time.Time) {
+	metrics.MeasureSince([]string{"avail", "submission", "inclusion_latency"}, start)
+}
+
+// observeFinalization records the time it took from the submission until the
+// Avail block with the extrinsic was finalized.
+func observeFinalization(start time.Time) {
+	metrics.MeasureSince([]string{"avail", "submission", "finalization_latency"}, start)
+}
+
+// observeStreamHeight records the latest Avail head and the height of the
+// last block handed over for processing, along with the lag between them.
+func observeStreamHeight(head, processed uint64) {
+	var lag uint64
+	if head > processed {
+		lag = head - processed
+	}
+
+	metrics.SetGauge([]string{"avail", "head"}, float32(head))
+	metrics.SetGauge([]string{"avail", "processed_height"}, float32(processed))
+	metrics.SetGauge([]string{"avail", "processing_lag"}, float32(lag))
+}
+
+// observeDuplicate records a replayed block suppressed by the watcher.
+func observeDuplicate(kind string) {
+	metrics.IncrCounterWithLabels([]string{"avail", "watcher", "duplicates"}, 1, []metrics.Label{
+		{Name: "kind", Value: kind},
+	})
+}
+
+// observeMalformedBlockData records block data of ours that couldn't be
+// decoded into an edge block.
+func observeMalformedBlockData() {
+	metrics.IncrCounter([]string{"avail", "decode", "malformed"}, 1)
+}
+
+// observeRuntimeUpgrade records an Avail runtime upgrade handled by the client.
+func observeRuntimeUpgrade() {
+	metrics.IncrCounter([]string{"avail", "runtime", "upgrades"}, 1)
+}
+
+// observeSubmissionQueue records the number of blocks waiting in the queue of
+// the submission scheduler.
+func observeSubmissionQueue(depth int) {
+	metrics.SetGauge([]string{"avail", "scheduler", "queue_depth"}, float32(depth))
+}
+
+// observeBatch records the number of blocks coalesced into a single batch.
+func observeBatch(blocks int) {
+	metrics.IncrCounter([]string{"avail", "scheduler", "batches"}, 1)
+	metrics.IncrCounter([]string{"avail", "scheduler", "batched_blocks"}, float32(blocks))
+}
+
+// observeSettlementArchive records the settlement references compacted into
+// an archive file, and the ones left in memory.
+func observeSettlementArchive(archived, hot int) {
+	metrics.IncrCounter([]string{"avail", "settlement", "archived"}, float32(archived))
+	metrics.SetGauge([]string{"avail", "settlement", "hot"}, float32(hot))
+}
+
+// observeSettlementArchiveFailure records a failed compaction of the
+// settlement references.
+func observeSettlementArchiveFailure() {
+	metrics.IncrCounter([]string{"avail", "settlement", "archive_failures"}, 1)
+}
@@ -0,0 +1,133 @@
+package avail
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	edge_types "github.com/0xPolygon/polygon-edge/types"
+	"github.com/armon/go-metrics"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/signature"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+// testMetricsSink installs an in-memory sink as the global metrics sink.
+func testMetricsSink(t *testing.T) *metrics.InmemSink {
+	t.Helper()
+
+	sink := metrics.NewInmemSink(time.Hour, time.Hour)
+
+	conf := metrics.DefaultConfig("test")
+	conf.EnableHostname = false
+	conf.EnableRuntimeMetrics = false
+
+	if _, err := metrics.NewGlobal(conf, sink); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { _, _ = metrics.NewGlobal(metrics.DefaultConfig("test"), &metrics.BlackholeSink{}) })
+
+	return sink
+}
+
+// counter returns the sum of the counter with the given name prefix.
+func counter(sink *metrics.InmemSink, name string) float64 {
+	var sum float64
+
+	for _, interval := range sink.Data() {
+		interval.RLock()
+		for key, c := range interval.Counters {
+			if strings.HasPrefix(key, name) {
+				sum += c.Sum
+			}
+		}
+		interval.RUnlock()
+	}
+
+	return sum
+}
+
+// gauge returns the value of the gauge with the given name.
+func gauge(sink *metrics.InmemSink, name string) (float32, bool) {
+	for _, interval := range sink.Data() {
+		interval.RLock()
+		g, ok := interval.Gauges[name]
+		interval.RUnlock()
+
+		if ok {
+			return g.Value, true
+		}
+	}
+
+	return 0, false
+}
+
+func TestSenderMetrics(t *testing.T) {
+	sink := testMetricsSink(t)
+
+	chain := newStubChain(t, 1, time.Hour)
+	chain.runtimes[0].metadata = stubMetadata(t, 42)
+	e := newStubEndpoint(t, chain)
+
+	c, err := NewClient(e.URL, hclog.NewNullLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewSender(c, types.NewUCompactFromUInt(1), NewKeyringSigner(signature.TestKeyringPairAlice)).(*sender)
+	blk := &edge_types.Block{Header: &edge_types.Header{Number: 1}}
+
+	payloads, err := s.payloads(blk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NoError(t, s.Send(context.Background(), blk))
+	assert.Equal(t, float64(len(payloads[0])), counter(sink, "test.avail.submission.bytes"))
+	assert.Equal(t, float64(1), counter(sink, "test.avail.submission.extrinsics"))
+
+	e.rejectSubmissions("1010: Invalid Transaction: Transaction is outdated")
+	assert.Error(t, s.Send(context.Background(), blk))
+	assert.Equal(t, float64(1), counter(sink, "test.avail.submission.failures;class=nonce"))
+
+	e.rejectSubmissions("1002: Verification Error: Runtime error")
+	assert.Error(t, s.Send(context.Background(), blk))
+	assert.Equal(t, float64(1), counter(sink, "test.avail.submission.failures;class=rpc"))
+
+	// Failed submissions don't count towards the submitted bytes.
+	assert.Equal(t, float64(len(payloads[0])), counter(sink, "test.avail.submission.bytes"))
+}
+
+func TestStreamLagMetrics(t *testing.T) {
+	sink := testMetricsSink(t)
+
+	chain := newStubChain(t, 20, time.Hour)
+	e := newStubEndpoint(t, chain)
+
+	c, err := NewClient(e.URL, hclog.NewNullLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bs := c.BlockStream(context.Background(), 1)
+	defer bs.Close()
+
+	// Only the first historical block has been processed; the rest lag behind.
+	<-bs.Chan()
+
+	lag, ok := gauge(sink, "test.avail.processing_lag")
+	assert.True(t, ok)
+	assert.Equal(t, float32(19), lag)
+
+	head, _ := gauge(sink, "test.avail.head")
+	assert.Equal(t, float32(20), head)
+}
+
+func TestSubmissionFailureClass(t *testing.T) {
+	assert.Equal(t, failureClassNotIncluded, submissionFailureClass(ErrExtrinsicNotIncluded))
+	assert.Equal(t, failureClassDataMismatch, submissionFailureClass(ErrDataMismatch))
+	assert.Equal(t, failureClassDropped, submissionFailureClass(ErrExtrinsicDropped))
+}
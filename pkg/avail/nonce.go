@@ -0,0 +1,209 @@
+package avail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+)
+
+// NonceSource returns the next nonce of an Avail account as known by the chain.
+type NonceSource func(ctx context.Context) (uint64, error)
+
+// NonceManager serializes the nonce assignment for a single Avail account.
+// It tracks the in-flight extrinsics, re-uses the nonces of failed submissions
+// to fill the gaps and refreshes from the chain when Avail reports a stale or
+// future nonce.
+type NonceManager struct {
+	lock        sync.Mutex
+	source      NonceSource
+	initialized bool
+	next        uint64
+	inFlight    map[uint64]struct{}
+	gaps        []uint64
+}
+
+// NewNonceManager creates a new NonceManager that initializes from the given source.
+func NewNonceManager(source NonceSource) *NonceManager {
+	return &NonceManager{
+		source:   source,
+		inFlight: make(map[uint64]struct{}),
+	}
+}
+
+// Next reserves the next nonce for a submission. Every reserved nonce must be
+// released either with Done or with Failed.
+func (nm *NonceManager) Next(ctx context.Context) (uint64, error) {
+	nm.lock.Lock()
+	defer nm.lock.Unlock()
+
+	if !nm.initialized {
+		next, err := nm.source(ctx)
+		if err != nil {
+			return 0, err
+		}
+
+		nm.next = next
+		nm.gaps = nil
+		nm.initialized = true
+	}
+
+	// Fill the gaps left by failed submissions first; later extrinsics are
+	// stuck in the future queue until those get used.
+	if len(nm.gaps) > 0 {
+		nonce := nm.gaps[0]
+		nm.gaps = nm.gaps[1:]
+		nm.inFlight[nonce] = struct{}{}
+
+		return nonce, nil
+	}
+
+	for {
+		nonce := nm.next
+		nm.next++
+
+		if _, ok := nm.inFlight[nonce]; !ok {
+			nm.inFlight[nonce] = struct{}{}
+			return nonce, nil
+		}
+	}
+}
+
+// Done releases the nonce of a submission that was accepted by Avail.
+func (nm *NonceManager) Done(nonce uint64) {
+	nm.lock.Lock()
+	defer nm.lock.Unlock()
+
+	delete(nm.inFlight, nonce)
+}
+
+// Failed releases the nonce of a failed submission. On stale or future nonce
+// errors the manager refreshes from the chain before assigning the next nonce,
+// otherwise the nonce is handed out again to fill the gap.
+func (nm *NonceManager) Failed(nonce uint64, err error) {
+	nm.lock.Lock()
+	defer nm.lock.Unlock()
+
+	delete(nm.inFlight, nonce)
+
+	if IsNonceError(err) {
+		nm.initialized = false
+		return
+	}
+
+	if !nm.initialized || nonce >= nm.next {
+		return
+	}
+
+	nm.gaps = append(nm.gaps, nonce)
+	sort.Slice(nm.gaps, func(i, j int) bool { return nm.gaps[i] < nm.gaps[j] })
+}
+
+// Reset forces the manager to refresh the nonce from the chain on next use.
+func (nm *NonceManager) Reset() {
+	nm.lock.Lock()
+	defer nm.lock.Unlock()
+
+	nm.initialized = false
+}
+
+// IsNonceError returns true when the error returned by Avail indicates that
+// the extrinsic nonce was either already used or is too far in the future.
+func IsNonceError(err error) bool {
+	return errors.Is(classifyError(err), ErrNonceStale)
+}
+
+// accountNonces is the set of the NonceManagers of the Avail accounts
+// submitting through a client, one per account.
+type accountNonces struct {
+	lock     sync.Mutex
+	managers map[string]*NonceManager
+}
+
+// get returns the NonceManager of the Avail account with the given public
+// key, reading its nonce through the given client.
+func (an *accountNonces) get(client Client, publicKey []byte) *NonceManager {
+	an.lock.Lock()
+	defer an.lock.Unlock()
+
+	if an.managers == nil {
+		an.managers = make(map[string]*NonceManager)
+	}
+
+	key := fmt.Sprintf("%x", publicKey)
+
+	nm, ok := an.managers[key]
+	if !ok {
+		nm = NewNonceManager(accountNonceSource(client, publicKey))
+		an.managers[key] = nm
+	}
+
+	return nm
+}
+
+// accountNonceManager returns the NonceManager shared by all the submission
+// paths of the Avail account with the given public key through the given
+// client. The managers are owned by the client, so a new client starts over
+// from the nonces of the chain.
+func accountNonceManager(c Client, publicKey []byte) *NonceManager {
+	switch c2 := c.(type) {
+	case *client:
+		return c2.nonces.get(c2, publicKey)
+	case *failoverClient:
+		return c2.nonces.get(c2, publicKey)
+	}
+
+	return NewNonceManager(accountNonceSource(c, publicKey))
+}
+
+// accountNoncer is implemented by the clients that keep the nonces of the
+// Avail accounts themselves, such as the in-memory fake of Avail.
+type accountNoncer interface {
+	AccountNonce(ctx context.Context, publicKey []byte) (uint64, error)
+}
+
+// AccountNonce returns the nonce of the next extrinsic of the Avail account
+// with the given public key, as known by the latest Avail block; the
+// extrinsics waiting in the pool don't count.
+func AccountNonce(ctx context.Context, client Client, publicKey []byte) (uint64, error) {
+	if n, ok := client.(accountNoncer); ok {
+		return n.AccountNonce(ctx, publicKey)
+	}
+
+	return accountNonceSource(client, publicKey)(ctx)
+}
+
+// accountNonceSource returns a NonceSource that reads the account nonce from the Avail storage.
+func accountNonceSource(client Client, publicKey []byte) NonceSource {
+	return func(ctx context.Context) (uint64, error) {
+		c, err := endpoint(client)
+		if err != nil {
+			return 0, err
+		}
+
+		meta, err := c.getMetadata(ctx, nil)
+		if err != nil {
+			return 0, err
+		}
+
+		key, err := types.CreateStorageKey(meta, "System", "Account", publicKey)
+		if err != nil {
+			return 0, err
+		}
+
+		var accountInfo types.AccountInfo
+		ok, err := c.getStorageLatest(ctx, key, &accountInfo)
+		if err != nil {
+			return 0, err
+		}
+
+		if !ok {
+			return 0, fmt.Errorf("couldn't fetch latest account storage info")
+		}
+
+		return uint64(accountInfo.Nonce), nil
+	}
+}
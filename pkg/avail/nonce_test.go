@@ -0,0 +1,129 @@
+package avail
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// mockNonceChain mimics the Avail account nonce handling for submitted extrinsics.
+type mockNonceChain struct {
+	lock      sync.Mutex
+	nonce     uint64
+	submitted []uint64
+	fetches   int
+}
+
+func (mc *mockNonceChain) source(context.Context) (uint64, error) {
+	mc.lock.Lock()
+	defer mc.lock.Unlock()
+
+	mc.fetches++
+
+	return mc.nonce, nil
+}
+
+func (mc *mockNonceChain) submit(nonce uint64) {
+	mc.lock.Lock()
+	defer mc.lock.Unlock()
+
+	mc.submitted = append(mc.submitted, nonce)
+}
+
+func TestNonceManagerConcurrentSubmissions(t *testing.T) {
+	const submissions = 20
+
+	chain := &mockNonceChain{nonce: 42}
+	nm := NewNonceManager(chain.source)
+
+	var wg sync.WaitGroup
+	for i := 0; i < submissions; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			nonce, err := nm.Next(context.Background())
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			chain.submit(nonce)
+			nm.Done(nonce)
+		}()
+	}
+
+	wg.Wait()
+
+	sort.Slice(chain.submitted, func(i, j int) bool { return chain.submitted[i] < chain.submitted[j] })
+
+	expected := make([]uint64, submissions)
+	for i := range expected {
+		expected[i] = 42 + uint64(i)
+	}
+
+	assert.Equal(t, expected, chain.submitted)
+	assert.Equal(t, 1, chain.fetches)
+}
+
+func TestNonceManagerFillsGaps(t *testing.T) {
+	chain := &mockNonceChain{nonce: 7}
+	nm := NewNonceManager(chain.source)
+
+	n0, _ := nm.Next(context.Background())
+	n1, _ := nm.Next(context.Background())
+	n2, _ := nm.Next(context.Background())
+	assert.Equal(t, []uint64{7, 8, 9}, []uint64{n0, n1, n2})
+
+	nm.Done(n0)
+	nm.Failed(n1, errors.New("connection reset"))
+	nm.Done(n2)
+
+	// The failed nonce is handed out again before new ones.
+	n3, _ := nm.Next(context.Background())
+	assert.Equal(t, n1, n3)
+
+	n4, _ := nm.Next(context.Background())
+	assert.Equal(t, uint64(10), n4)
+	assert.Equal(t, 1, chain.fetches)
+}
+
+func TestNonceManagerRefreshesOnNonceError(t *testing.T) {
+	chain := &mockNonceChain{nonce: 1}
+	nm := NewNonceManager(chain.source)
+
+	n0, _ := nm.Next(context.Background())
+	assert.Equal(t, uint64(1), n0)
+
+	// Somebody else used the same account meanwhile.
+	chain.nonce = 5
+	nm.Failed(n0, errors.New("1010: Invalid Transaction: Transaction is outdated"))
+
+	n1, _ := nm.Next(context.Background())
+	assert.Equal(t, uint64(5), n1)
+	assert.Equal(t, 2, chain.fetches)
+}
+
+func TestAccountNonceManagerPerClient(t *testing.T) {
+	c1, c2 := &client{}, &client{}
+	alice, bob := []byte{1}, []byte{2}
+
+	// The accounts of a client share a manager each; a new client starts
+	// over.
+	assert.Same(t, accountNonceManager(c1, alice), accountNonceManager(c1, alice))
+	assert.NotSame(t, accountNonceManager(c1, alice), accountNonceManager(c1, bob))
+	assert.NotSame(t, accountNonceManager(c1, alice), accountNonceManager(c2, alice))
+}
+
+func TestIsNonceError(t *testing.T) {
+	assert.False(t, IsNonceError(nil))
+	assert.False(t, IsNonceError(errors.New("connection reset")))
+	assert.True(t, IsNonceError(errors.New("1010: Invalid Transaction: Transaction is outdated")))
+	assert.True(t, IsNonceError(errors.New("1010: Invalid Transaction: Transaction will be valid in the future")))
+	assert.True(t, IsNonceError(errors.New("1014: Priority is too low: (100 vs 100)")))
+}
@@ -0,0 +1,109 @@
+package avail
+
+import (
+	"context"
+	"sync"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+)
+
+// DefaultQueryPageSize is the default number of historical Avail blocks fetched concurrently in one page.
+const DefaultQueryPageSize = 32
+
+// ClientOption configures the Avail client.
+type ClientOption func(*client)
+
+// WithQueryPageSize sets the number of historical Avail blocks fetched
+// concurrently in one page when querying or catching up with the chain.
+func WithQueryPageSize(size uint64) ClientOption {
+	return func(c *client) {
+		if size > 0 {
+			c.pageSize = size
+		}
+	}
+}
+
+// Query fetches the historical Avail blocks in the [from, to] height range,
+// in the order of their heights. The blocks are fetched in pages of the
+// configured size.
+//
+// Parameters:
+//   - ctx: The context of the query.
+//   - from: The height of the first block to fetch.
+//   - to: The height of the last block to fetch.
+//
+// Return:
+//   - []*types.SignedBlock: The fetched blocks.
+//   - error: An error if fetching any of the blocks fails.
+func (c *client) Query(ctx context.Context, from, to uint64) ([]*types.SignedBlock, error) {
+	return queryBlocks(ctx, c, c.pageSize, from, to)
+}
+
+// queryBlocks fetches the blocks in the [from, to] height range page by page.
+func queryBlocks(ctx context.Context, c *client, pageSize, from, to uint64) ([]*types.SignedBlock, error) {
+	if to < from {
+		return nil, nil
+	}
+
+	blks := make([]*types.SignedBlock, 0, to-from+1)
+
+	for start := from; start <= to; start += pageSize {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		end := start + pageSize - 1
+		if end > to {
+			end = to
+		}
+
+		page, err := queryPage(ctx, c, start, end)
+		if err != nil {
+			return nil, err
+		}
+
+		blks = append(blks, page...)
+	}
+
+	return blks, nil
+}
+
+// queryPage concurrently fetches the blocks in the [from, to] height range.
+func queryPage(ctx context.Context, c *client, from, to uint64) ([]*types.SignedBlock, error) {
+	var (
+		wg   sync.WaitGroup
+		lock sync.Mutex
+		err  error
+	)
+
+	blks := make([]*types.SignedBlock, to-from+1)
+
+	for i := from; i <= to; i++ {
+		wg.Add(1)
+
+		go func(number uint64) {
+			defer wg.Done()
+
+			blk, fetchErr := c.getBlockAt(ctx, number)
+			if fetchErr != nil {
+				lock.Lock()
+				if err == nil {
+					err = fetchErr
+				}
+				lock.Unlock()
+
+				return
+			}
+
+			blks[number-from] = blk
+		}(i)
+	}
+
+	wg.Wait()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return blks, nil
+}
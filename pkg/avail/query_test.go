@@ -0,0 +1,84 @@
+package avail
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientQuery(t *testing.T) {
+	chain := newStubChain(t, 100, time.Hour)
+	e := newStubEndpoint(t, chain)
+
+	c, err := NewClient(e.URL, hclog.NewNullLogger(), WithQueryPageSize(7))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blks, err := c.Query(context.Background(), 3, 45)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var blockSeq []uint64
+	for _, blk := range blks {
+		blockSeq = append(blockSeq, uint64(blk.Block.Header.Number))
+	}
+
+	var expectSequence []uint64
+	for i := uint64(3); i <= 45; i++ {
+		expectSequence = append(expectSequence, i)
+	}
+
+	assert.Equal(t, expectSequence, blockSeq)
+
+	// Blocks beyond the tip can't be fetched.
+	_, err = c.Query(context.Background(), 95, 105)
+	assert.Error(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = c.Query(ctx, 1, 10)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestBlockStreamReplaysHistoryThenLive(t *testing.T) {
+	const (
+		historyLen = 100
+		lastHeight = 120
+	)
+
+	chain := newStubChain(t, historyLen, 20*time.Millisecond)
+	e := newStubEndpoint(t, chain)
+
+	c, err := NewClient(e.URL, hclog.NewNullLogger(), WithQueryPageSize(16))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bs := c.BlockStream(context.Background(), 1)
+	defer bs.Close()
+
+	timeout := time.After(20 * time.Second)
+
+	var blockSeq []uint64
+	for len(blockSeq) == 0 || blockSeq[len(blockSeq)-1] < lastHeight {
+		select {
+		case blk := <-bs.Chan():
+			blockSeq = append(blockSeq, uint64(blk.Block.Header.Number))
+		case <-timeout:
+			t.Fatalf("timed out waiting for blocks; received: %v", blockSeq)
+		}
+	}
+
+	var expectSequence []uint64
+	for i := uint64(1); i <= lastHeight; i++ {
+		expectSequence = append(expectSequence, i)
+	}
+
+	assert.Equal(t, expectSequence, blockSeq)
+}
@@ -0,0 +1,284 @@
+package avail
+
+import (
+	"context"
+	"time"
+
+	gethrpc "github.com/centrifuge/go-substrate-rpc-client/v4/gethrpc"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types/codec"
+)
+
+// DefaultCallTimeout is the default deadline of a single Avail JSON-RPC call.
+const DefaultCallTimeout = 30 * time.Second
+
+// WithCallTimeout sets the deadline of every single Avail JSON-RPC call. The
+// calls are bound by the context of the caller as well.
+func WithCallTimeout(timeout time.Duration) ClientOption {
+	return func(c *client) {
+		if timeout > 0 {
+			c.callTimeout = timeout
+		}
+	}
+}
+
+// contextCaller is implemented by the JSON-RPC connection of gsrpc. Its
+// typed RPC wrappers don't take a context, so the calls are made directly.
+type contextCaller interface {
+	CallContext(ctx context.Context, result interface{}, method string, args ...interface{}) error
+}
+
+// call makes the JSON-RPC call, bound by both the given context and the per-call timeout.
+func (c *client) call(ctx context.Context, result interface{}, method string, args ...interface{}) error {
+	cc, ok := c.api.Client.(contextCaller)
+	if !ok {
+		return ErrUnsupportedClient
+	}
+
+	callCtx, cancel := context.WithTimeout(ctx, c.callTimeout)
+	defer cancel()
+
+	return callError(ctx, cc.CallContext(callCtx, result, method, args...))
+}
+
+// callAt makes the JSON-RPC call at the state of the given block, or at the
+// latest state when the block hash is nil.
+func (c *client) callAt(ctx context.Context, result interface{}, method string, blockHash *types.Hash, args ...interface{}) error {
+	if blockHash != nil {
+		args = append(args, blockHash.Hex())
+	}
+
+	return c.call(ctx, result, method, args...)
+}
+
+// getBlockHash returns the hash of the Avail block at the given height.
+func (c *client) getBlockHash(ctx context.Context, number uint64) (types.Hash, error) {
+	var res string
+	if err := c.call(ctx, &res, "chain_getBlockHash", number); err != nil {
+		return types.Hash{}, err
+	}
+
+	return types.NewHashFromHexString(res)
+}
+
+// getBlockHashLatest returns the hash of the latest Avail block.
+func (c *client) getBlockHashLatest(ctx context.Context) (types.Hash, error) {
+	var res string
+	if err := c.call(ctx, &res, "chain_getBlockHash"); err != nil {
+		return types.Hash{}, err
+	}
+
+	return types.NewHashFromHexString(res)
+}
+
+// getFinalizedHead returns the hash of the latest finalized Avail block.
+func (c *client) getFinalizedHead(ctx context.Context) (types.Hash, error) {
+	var res string
+	if err := c.call(ctx, &res, "chain_getFinalizedHead"); err != nil {
+		return types.Hash{}, err
+	}
+
+	return types.NewHashFromHexString(res)
+}
+
+// getHeader returns the header of the Avail block with the given hash.
+func (c *client) getHeader(ctx context.Context, blockHash types.Hash) (*types.Header, error) {
+	var hdr types.Header
+	if err := c.call(ctx, &hdr, "chain_getHeader", blockHash.Hex()); err != nil {
+		return nil, err
+	}
+
+	return &hdr, nil
+}
+
+// getBlock returns the Avail block with the given hash.
+func (c *client) getBlock(ctx context.Context, blockHash types.Hash) (*types.SignedBlock, error) {
+	var blk types.SignedBlock
+	if err := c.call(ctx, &blk, "chain_getBlock", blockHash.Hex()); err != nil {
+		return nil, err
+	}
+
+	return &blk, nil
+}
+
+// getBlockAt returns the Avail block at the given height.
+func (c *client) getBlockAt(ctx context.Context, number uint64) (*types.SignedBlock, error) {
+	blockHash, err := c.getBlockHash(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.getBlock(ctx, blockHash)
+}
+
+// getHeaderLatest returns the latest Avail header.
+func (c *client) getHeaderLatest(ctx context.Context) (*types.Header, error) {
+	var hdr types.Header
+	if err := c.call(ctx, &hdr, "chain_getHeader"); err != nil {
+		return nil, err
+	}
+
+	return &hdr, nil
+}
+
+// getRuntimeVersion returns the runtime version at the given block, or the latest one.
+func (c *client) getRuntimeVersion(ctx context.Context, blockHash *types.Hash) (*types.RuntimeVersion, error) {
+	var rv types.RuntimeVersion
+	if err := c.callAt(ctx, &rv, "state_getRuntimeVersion", blockHash); err != nil {
+		return nil, err
+	}
+
+	return &rv, nil
+}
+
+// getMetadata returns the runtime metadata at the given block, or the latest one.
+func (c *client) getMetadata(ctx context.Context, blockHash *types.Hash) (*types.Metadata, error) {
+	var res string
+	if err := c.callAt(ctx, &res, "state_getMetadata", blockHash); err != nil {
+		return nil, err
+	}
+
+	var meta types.Metadata
+	if err := codec.DecodeFromHex(res, &meta); err != nil {
+		return nil, err
+	}
+
+	return &meta, nil
+}
+
+// getStorageLatest decodes the latest value of the storage key into target.
+// It returns false when the storage is empty.
+func (c *client) getStorageLatest(ctx context.Context, key types.StorageKey, target interface{}) (bool, error) {
+	var res string
+	if err := c.call(ctx, &res, "state_getStorage", key.Hex()); err != nil {
+		return false, err
+	}
+
+	raw, err := codec.HexDecodeString(res)
+	if err != nil {
+		return false, err
+	}
+
+	if len(raw) == 0 {
+		return false, nil
+	}
+
+	return true, codec.Decode(raw, target)
+}
+
+// submitExtrinsic submits the extrinsic to the transaction pool of Avail.
+func (c *client) submitExtrinsic(ctx context.Context, ext types.Extrinsic) (types.Hash, error) {
+	enc, err := codec.EncodeToHex(ext)
+	if err != nil {
+		return types.Hash{}, err
+	}
+
+	var res string
+	if err := c.call(ctx, &res, "author_submitExtrinsic", enc); err != nil {
+		return types.Hash{}, err
+	}
+
+	return types.NewHashFromHexString(res)
+}
+
+// unsubscribe ends the subscription. gsrpc makes the unsubscribe call without
+// a deadline, so the caller waits for it at most for the given timeout; an
+// unanswered call is abandoned and returns when the connection is closed.
+func unsubscribe(sub *gethrpc.ClientSubscription, timeout time.Duration) {
+	done := make(chan struct{})
+
+	go func() {
+		sub.Unsubscribe()
+		close(done)
+	}()
+
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+
+	select {
+	case <-done:
+	case <-t.C:
+	}
+}
+
+// headSubscription is a subscription to the new Avail block headers.
+type headSubscription struct {
+	sub     *gethrpc.ClientSubscription
+	ch      chan types.Header
+	timeout time.Duration
+}
+
+// Chan returns the channel on which the new headers are received.
+func (s *headSubscription) Chan() <-chan types.Header {
+	return s.ch
+}
+
+// Err returns the channel on which the subscription error is received.
+func (s *headSubscription) Err() <-chan error {
+	return s.sub.Err()
+}
+
+// Unsubscribe ends the subscription.
+func (s *headSubscription) Unsubscribe() {
+	unsubscribe(s.sub, s.timeout)
+}
+
+// subscribeNewHeads subscribes to the new Avail block headers. The context
+// bounds only the setup of the subscription.
+func (c *client) subscribeNewHeads(ctx context.Context) (*headSubscription, error) {
+	callCtx, cancel := context.WithTimeout(ctx, c.callTimeout)
+	defer cancel()
+
+	ch := make(chan types.Header)
+
+	sub, err := c.api.Client.Subscribe(callCtx, "chain", "subscribeNewHead", "unsubscribeNewHead", "newHead", ch)
+	if err != nil {
+		return nil, callError(ctx, err)
+	}
+
+	return &headSubscription{sub: sub, ch: ch, timeout: c.callTimeout}, nil
+}
+
+// extrinsicSubscription is a subscription to the status updates of a submitted extrinsic.
+type extrinsicSubscription struct {
+	sub     *gethrpc.ClientSubscription
+	ch      chan types.ExtrinsicStatus
+	timeout time.Duration
+}
+
+// Chan returns the channel on which the extrinsic status updates are received.
+func (s *extrinsicSubscription) Chan() <-chan types.ExtrinsicStatus {
+	return s.ch
+}
+
+// Err returns the channel on which the subscription error is received.
+func (s *extrinsicSubscription) Err() <-chan error {
+	return s.sub.Err()
+}
+
+// Unsubscribe ends the subscription.
+func (s *extrinsicSubscription) Unsubscribe() {
+	unsubscribe(s.sub, s.timeout)
+}
+
+// submitAndWatchExtrinsic submits the extrinsic to the transaction pool of
+// Avail and subscribes to its status updates. The context bounds only the
+// submission and the setup of the subscription.
+func (c *client) submitAndWatchExtrinsic(ctx context.Context, ext types.Extrinsic) (*extrinsicSubscription, error) {
+	enc, err := codec.EncodeToHex(ext)
+	if err != nil {
+		return nil, err
+	}
+
+	callCtx, cancel := context.WithTimeout(ctx, c.callTimeout)
+	defer cancel()
+
+	ch := make(chan types.ExtrinsicStatus)
+
+	sub, err := c.api.Client.Subscribe(callCtx, "author", "submitAndWatchExtrinsic", "unwatchExtrinsic", "extrinsicUpdate", ch, enc)
+	if err != nil {
+		return nil, callError(ctx, err)
+	}
+
+	return &extrinsicSubscription{sub: sub, ch: ch, timeout: c.callTimeout}, nil
+}
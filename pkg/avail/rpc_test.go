@@ -0,0 +1,77 @@
+package avail
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCallTimeout(t *testing.T) {
+	chain := newStubChain(t, 1, time.Hour)
+	e := newStubEndpoint(t, chain)
+
+	c, err := NewClient(e.URL, hclog.NewNullLogger(), WithCallTimeout(100*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e.hang()
+
+	start := time.Now()
+	_, err = c.GetLatestHeader(context.Background())
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), "unexpected error: %v", err)
+	assert.Less(t, time.Since(start), 5*time.Second)
+
+	// The context of the caller bounds the call as well.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = c.GetLatestHeader(ctx)
+	assert.True(t, errors.Is(err, context.Canceled), "unexpected error: %v", err)
+}
+
+func TestBlockStreamCanceledOnHungEndpoint(t *testing.T) {
+	chain := newStubChain(t, 1, time.Hour)
+	e := newStubEndpoint(t, chain)
+
+	baseline := runtime.NumGoroutine()
+
+	c, err := NewClient(e.URL, hclog.NewNullLogger(), WithCallTimeout(100*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	bs := c.BlockStream(ctx, 1)
+
+	select {
+	case <-bs.Chan():
+	case <-time.After(5 * time.Second):
+		t.Fatal("no block received")
+	}
+
+	// The stream is now waiting for new heads from an endpoint that stopped answering.
+	e.hang()
+	cancel()
+
+	timeout := time.After(5 * time.Second)
+	for closed := false; !closed; {
+		select {
+		case _, ok := <-bs.Chan():
+			closed = !ok
+		case <-timeout:
+			t.Fatal("block stream not closed after the context was canceled")
+		}
+	}
+
+	c.(*client).api.Client.Close()
+
+	assert.Eventually(t, func() bool {
+		return runtime.NumGoroutine() <= baseline
+	}, 5*time.Second, 50*time.Millisecond)
+}
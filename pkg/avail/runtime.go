@@ -0,0 +1,80 @@
+package avail
+
+import (
+	"context"
+	"errors"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+)
+
+// runtimeState is the Avail runtime the extrinsics are encoded and signed for.
+type runtimeState struct {
+	version  types.RuntimeVersion
+	metadata *types.Metadata
+}
+
+// IsRuntimeMismatchError returns true when Avail rejected the extrinsic as
+// invalid in a way that may be caused by signing it for an outdated runtime.
+func IsRuntimeMismatchError(err error) bool {
+	return errors.Is(classifyError(err), ErrInvalidTransaction)
+}
+
+// latestRuntime returns the cached runtime of the endpoint, loading it on first use.
+func (c *client) latestRuntime(ctx context.Context) (*runtimeState, error) {
+	c.runtimeLock.Lock()
+	rt := c.runtime
+	c.runtimeLock.Unlock()
+
+	if rt != nil {
+		return rt, nil
+	}
+
+	if _, err := c.refreshRuntime(ctx); err != nil {
+		return nil, err
+	}
+
+	c.runtimeLock.Lock()
+	defer c.runtimeLock.Unlock()
+
+	return c.runtime, nil
+}
+
+// refreshRuntime reloads the runtime version and, when the spec version
+// changed, the metadata of the runtime at the latest Avail block. Both are
+// read at the same block and replaced at once.
+// It returns true if a runtime upgrade was detected.
+func (c *client) refreshRuntime(ctx context.Context) (bool, error) {
+	c.runtimeLock.Lock()
+	defer c.runtimeLock.Unlock()
+
+	blockHash, err := c.getBlockHashLatest(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	rv, err := c.getRuntimeVersion(ctx, &blockHash)
+	if err != nil {
+		return false, err
+	}
+
+	old := c.runtime
+	if old != nil && old.version.SpecVersion == rv.SpecVersion && old.version.TransactionVersion == rv.TransactionVersion {
+		return false, nil
+	}
+
+	meta, err := c.getMetadata(ctx, &blockHash)
+	if err != nil {
+		return false, err
+	}
+
+	c.runtime = &runtimeState{version: *rv, metadata: meta}
+
+	if old == nil {
+		return false, nil
+	}
+
+	c.logger.Info("Avail runtime upgrade handled", "old_spec_version", old.version.SpecVersion, "new_spec_version", rv.SpecVersion, "transaction_version", rv.TransactionVersion)
+	observeRuntimeUpgrade()
+
+	return true, nil
+}
@@ -0,0 +1,58 @@
+package avail
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	edge_types "github.com/0xPolygon/polygon-edge/types"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/signature"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSenderHandlesRuntimeUpgrade(t *testing.T) {
+	sink := testMetricsSink(t)
+
+	chain := newStubChain(t, 1, time.Hour)
+	chain.runtimes[0].metadata = stubMetadata(t, 42)
+	e := newStubEndpoint(t, chain)
+
+	c, err := NewClient(e.URL, hclog.NewNullLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e.verifySignatures(signature.TestKeyringPairAlice.URI)
+
+	s := NewSender(c, types.NewUCompactFromUInt(1), NewKeyringSigner(signature.TestKeyringPairAlice), WithMortality(0))
+	ctx := context.Background()
+
+	for i := uint64(1); i <= 3; i++ {
+		if i == 2 {
+			// The runtime is upgraded after the sender cached it; the
+			// extrinsics signed for the old one are rejected.
+			chain.upgrade(2, stubMetadata(t, 7))
+			chain.produce()
+		}
+
+		if err := s.Send(ctx, &edge_types.Block{Header: &edge_types.Header{Number: i}}); err != nil {
+			t.Fatalf("block %d: %v", i, err)
+		}
+	}
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	// Every block made it to Avail, the ones after the upgrade with the call
+	// index of the new runtime.
+	if assert.Len(t, e.submitted, 3) {
+		assert.Equal(t, uint8(42), e.submitted[0].Method.CallIndex.SectionIndex)
+		assert.Equal(t, uint8(7), e.submitted[1].Method.CallIndex.SectionIndex)
+		assert.Equal(t, uint8(7), e.submitted[2].Method.CallIndex.SectionIndex)
+	}
+
+	assert.Equal(t, float64(1), counter(sink, "test.avail.runtime.upgrades"))
+	assert.Equal(t, float64(0), counter(sink, "test.avail.submission.failures;class=rpc"))
+}
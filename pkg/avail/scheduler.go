@@ -0,0 +1,376 @@
+package avail
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	edgetypes "github.com/0xPolygon/polygon-edge/types"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/hashicorp/go-hclog"
+)
+
+const (
+	// DefaultSubmissionQueueSize is the default number of blocks waiting for
+	// their submission to Avail before the submitters are held back.
+	DefaultSubmissionQueueSize = 16
+
+	// headStreamRetryInterval is the time waited before the Avail block
+	// stream the scheduler paces the submissions by is reopened.
+	headStreamRetryInterval = time.Second
+
+	// TargetBlockTime is the target time between the Avail blocks.
+	TargetBlockTime = 20 * time.Second
+)
+
+// ErrSchedulerClosed is the error returned for the blocks submitted through
+// a closed SubmissionScheduler.
+var ErrSchedulerClosed = errors.New("Avail submission scheduler closed")
+
+// SchedulerConfig configures the pacing of the submissions to Avail.
+type SchedulerConfig struct {
+	// MaxPerAvailBlock is the maximum number of submissions dispatched per
+	// Avail block; the rest wait in the queue for the following blocks.
+	MaxPerAvailBlock int
+
+	// QueueSize is the number of blocks that can wait in the queue; the
+	// submitters are blocked until there is room for their blocks.
+	QueueSize int
+
+	// Batching enables coalescing of the queued blocks into a single
+	// BlobBatch, when the sender supports it.
+	Batching bool
+}
+
+// MinInterval returns the shortest average interval between the blocks the
+// scheduler can submit without its queue growing, assuming Avail produces
+// blocks at TargetBlockTime. It's zero when the submissions aren't paced or
+// the queued blocks are batched.
+func (cfg SchedulerConfig) MinInterval() time.Duration {
+	if cfg.MaxPerAvailBlock <= 0 || cfg.Batching {
+		return 0
+	}
+
+	perBlock := time.Duration(cfg.MaxPerAvailBlock)
+
+	return (TargetBlockTime + perBlock - 1) / perBlock
+}
+
+// submission is a block waiting in the queue of the SubmissionScheduler.
+type submission struct {
+	ctx    context.Context
+	blk    *edgetypes.Block
+	size   int
+	wait   bool
+	status types.ExtrinsicStatus
+	opts   []SubmitOption
+	done   chan submissionResult
+}
+
+// submissionResult is the outcome of a submission.
+type submissionResult struct {
+	res SubmitResult
+	err error
+}
+
+// batchable reports whether the submission can share an extrinsic with the
+// other one; only the blocks submitted with the default options can.
+func (s *submission) batchable(other *submission) bool {
+	return len(s.opts) == 0 && len(other.opts) == 0 && s.wait == other.wait &&
+		s.status.IsReady == other.status.IsReady &&
+		s.status.IsInBlock == other.status.IsInBlock &&
+		s.status.IsFinalized == other.status.IsFinalized
+}
+
+// SubmissionScheduler is a Sender pacing the submissions of the wrapped
+// Sender to at most SchedulerConfig.MaxPerAvailBlock per Avail block. The
+// excess blocks are queued and, with batching enabled, coalesced into
+// batches. The queue is bounded: submitters block while it's full.
+type SubmissionScheduler struct {
+	client Client
+	sender Sender
+	cfg    SchedulerConfig
+	logger hclog.Logger
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	// room holds a token for every queued block.
+	room chan struct{}
+
+	lock    sync.Mutex
+	closed  bool
+	queue   []*submission
+	queued  chan struct{}
+	head    uint64
+	used    int
+	newHead chan struct{}
+}
+
+var _ Sender = (*SubmissionScheduler)(nil)
+
+// NewSubmissionScheduler creates a SubmissionScheduler submitting the blocks
+// with the sender, paced by the Avail blocks received from the client.
+// It must be closed with Close.
+func NewSubmissionScheduler(client Client, sender Sender, cfg SchedulerConfig) *SubmissionScheduler {
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = DefaultSubmissionQueueSize
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	s := &SubmissionScheduler{
+		client:  client,
+		sender:  sender,
+		cfg:     cfg,
+		logger:  clientLogger(client).Named("scheduler"),
+		ctx:     ctx,
+		cancel:  cancel,
+		room:    make(chan struct{}, cfg.QueueSize),
+		queued:  make(chan struct{}, 1),
+		newHead: make(chan struct{}),
+	}
+
+	s.wg.Add(2)
+	go s.watchHead()
+	go s.run()
+
+	return s
+}
+
+// Close stops the scheduler. The queued blocks fail with ErrSchedulerClosed;
+// the batches already dispatched are canceled.
+func (s *SubmissionScheduler) Close() {
+	s.cancel()
+	s.wg.Wait()
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.closed = true
+
+	for _, sub := range s.queue {
+		sub.done <- submissionResult{err: ErrSchedulerClosed}
+	}
+
+	s.queue = nil
+}
+
+// Send queues the block and returns once it's been submitted to Avail,
+// without waiting for any status response.
+func (s *SubmissionScheduler) Send(ctx context.Context, blk *edgetypes.Block, opts ...SubmitOption) error {
+	_, err := s.submit(ctx, &submission{blk: blk, opts: opts})
+
+	return err
+}
+
+// SendAndWaitForStatus queues the block and waits until it's been submitted
+// to Avail and reached the specified extrinsic status.
+func (s *SubmissionScheduler) SendAndWaitForStatus(ctx context.Context, blk *edgetypes.Block, status types.ExtrinsicStatus, opts ...SubmitOption) (SubmitResult, error) {
+	return s.submit(ctx, &submission{blk: blk, wait: true, status: status, opts: opts})
+}
+
+// submit queues the submission, waiting for room in the queue, and waits for its result.
+func (s *SubmissionScheduler) submit(ctx context.Context, sub *submission) (SubmitResult, error) {
+	sub.ctx = ctx
+	sub.done = make(chan submissionResult, 1)
+
+	if s.cfg.Batching {
+		sub.size = len(sub.blk.MarshalRLP())
+	}
+
+	select {
+	case s.room <- struct{}{}:
+	case <-ctx.Done():
+		return SubmitResult{}, ctx.Err()
+	case <-s.ctx.Done():
+		return SubmitResult{}, ErrSchedulerClosed
+	}
+
+	s.lock.Lock()
+	if s.closed {
+		s.lock.Unlock()
+		<-s.room
+
+		return SubmitResult{}, ErrSchedulerClosed
+	}
+
+	s.queue = append(s.queue, sub)
+	observeSubmissionQueue(len(s.queue))
+	s.lock.Unlock()
+
+	select {
+	case s.queued <- struct{}{}:
+	default:
+	}
+
+	select {
+	case r := <-sub.done:
+		return r.res, r.err
+	case <-ctx.Done():
+		return SubmitResult{}, ctx.Err()
+	}
+}
+
+// run dispatches the queued blocks, in order, as the Avail blocks allow.
+func (s *SubmissionScheduler) run() {
+	defer s.wg.Done()
+
+	for {
+		s.lock.Lock()
+		empty := len(s.queue) == 0
+		s.lock.Unlock()
+
+		if empty {
+			select {
+			case <-s.queued:
+				continue
+			case <-s.ctx.Done():
+				return
+			}
+		}
+
+		if !s.takeSlot() {
+			return
+		}
+
+		go s.dispatch(s.dequeue())
+	}
+}
+
+// takeSlot waits until a submission can be dispatched in the current Avail
+// block and takes it. It returns false when the scheduler is closed.
+func (s *SubmissionScheduler) takeSlot() bool {
+	for {
+		s.lock.Lock()
+		if s.cfg.MaxPerAvailBlock <= 0 || s.used < s.cfg.MaxPerAvailBlock {
+			s.used++
+			s.lock.Unlock()
+
+			return true
+		}
+
+		newHead := s.newHead
+		s.lock.Unlock()
+
+		select {
+		case <-newHead:
+		case <-s.ctx.Done():
+			return false
+		}
+	}
+}
+
+// dequeue takes the next block off the queue and, with batching enabled,
+// the following ones that can share its extrinsic.
+func (s *SubmissionScheduler) dequeue() []*submission {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	n := 1
+	if _, ok := s.sender.(BatchSender); ok && s.cfg.Batching {
+		size := s.queue[0].size
+		for n < len(s.queue) && n < maxBatchBlocks && s.queue[0].batchable(s.queue[n]) && size+s.queue[n].size <= DefaultMaxChunkSize {
+			size += s.queue[n].size
+			n++
+		}
+	}
+
+	batch := s.queue[:n:n]
+	s.queue = s.queue[n:]
+	observeSubmissionQueue(len(s.queue))
+
+	for range batch {
+		<-s.room
+	}
+
+	return batch
+}
+
+// dispatch submits the blocks, as a batch if there are several of them, and
+// hands the result over to their submitters.
+func (s *SubmissionScheduler) dispatch(batch []*submission) {
+	// Skip the blocks whose submitters gave up waiting.
+	live := batch[:0]
+	for _, sub := range batch {
+		if err := sub.ctx.Err(); err != nil {
+			sub.done <- submissionResult{err: err}
+			continue
+		}
+
+		live = append(live, sub)
+	}
+
+	var r submissionResult
+
+	switch {
+	case len(live) == 0:
+		return
+	case len(live) == 1 && !live[0].wait:
+		r.err = s.sender.Send(live[0].ctx, live[0].blk, live[0].opts...)
+	case len(live) == 1:
+		r.res, r.err = s.sender.SendAndWaitForStatus(live[0].ctx, live[0].blk, live[0].status, live[0].opts...)
+	default:
+		blks := make([]*edgetypes.Block, 0, len(live))
+		for _, sub := range live {
+			blks = append(blks, sub.blk)
+		}
+
+		status := live[0].status
+		if !live[0].wait {
+			status = types.ExtrinsicStatus{IsReady: true}
+		}
+
+		s.logger.Debug("submitting batched blocks", "first_block_number", blks[0].Number(), "blocks", len(blks))
+		observeBatch(len(blks))
+
+		r.res, r.err = s.sender.(BatchSender).SendBatchAndWaitForStatus(s.ctx, blks, status)
+	}
+
+	for _, sub := range live {
+		sub.done <- r
+	}
+}
+
+// watchHead follows the Avail head to grant new submission slots with every
+// new Avail block.
+func (s *SubmissionScheduler) watchHead() {
+	defer s.wg.Done()
+
+	for {
+		bs := s.client.BlockStream(s.ctx, 0)
+		s.followHead(bs)
+		bs.Close()
+
+		select {
+		case <-time.After(headStreamRetryInterval):
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// followHead resets the submission slots on every new Avail block received
+// from the stream, until the stream or the scheduler is closed.
+func (s *SubmissionScheduler) followHead(bs BlockStream) {
+	for {
+		select {
+		case blk, ok := <-bs.Chan():
+			if !ok {
+				return
+			}
+
+			s.lock.Lock()
+			if number := uint64(blk.Block.Header.Number); number > s.head {
+				s.head, s.used = number, 0
+				close(s.newHead)
+				s.newHead = make(chan struct{})
+			}
+			s.lock.Unlock()
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
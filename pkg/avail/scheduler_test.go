@@ -0,0 +1,157 @@
+package avail_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	edge_types "github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/pkg/avail"
+	"github.com/availproject/op-evm/pkg/avail/testutil"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/stretchr/testify/assert"
+)
+
+var testAppID = types.NewUCompactFromUInt(5)
+
+func testBlock(number uint64) *edge_types.Block {
+	blk := &edge_types.Block{Header: &edge_types.Header{Number: number, Difficulty: 1}}
+	blk.Header.ComputeHash()
+
+	return blk
+}
+
+// submitAll submits the blocks concurrently, one after another, and returns
+// the channel receiving their errors.
+func submitAll(s avail.Sender, from, to uint64) <-chan error {
+	errs := make(chan error, to-from+1)
+
+	for i := from; i <= to; i++ {
+		go func(blk *edge_types.Block) {
+			_, err := s.SendAndWaitForStatus(context.Background(), blk, types.ExtrinsicStatus{IsInBlock: true})
+			errs <- err
+		}(testBlock(i))
+
+		// Keep the order of the queue deterministic.
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	return errs
+}
+
+// produceUntil produces Avail blocks out of the pending submissions until the
+// given number of edge blocks has been delivered, asserting that no Avail
+// block carries more than `limit` of them.
+func produceUntil(t *testing.T, f *testutil.Fake, errs <-chan error, total, limit int) {
+	t.Helper()
+
+	for delivered := 0; delivered < total; {
+		assert.Eventually(t, func() bool { return f.Pending() > 0 }, 5*time.Second, time.Millisecond)
+
+		// Give the scheduler the chance to exceed its limit.
+		time.Sleep(20 * time.Millisecond)
+		assert.LessOrEqual(t, f.Pending(), limit)
+
+		f.Produce()
+
+		for delivered < total {
+			select {
+			case err := <-errs:
+				assert.NoError(t, err)
+				delivered++
+				continue
+			case <-time.After(50 * time.Millisecond):
+			}
+
+			break
+		}
+	}
+}
+
+func TestSchedulerPacesSubmissions(t *testing.T) {
+	f := testutil.NewFake(testAppID, testutil.WithTxPool())
+	s := avail.NewSubmissionScheduler(f, f, avail.SchedulerConfig{MaxPerAvailBlock: 2, QueueSize: 16})
+	defer s.Close()
+
+	errs := submitAll(s, 1, 10)
+	produceUntil(t, f, errs, 10, 2)
+
+	// Two submissions per Avail block.
+	assert.Equal(t, uint64(5), f.Head())
+
+	for n := uint64(1); n <= f.Head(); n++ {
+		blk, _ := f.Block(n)
+		assert.Len(t, blk.Block.Extrinsics, 2)
+	}
+
+	delivered, err := f.EdgeBlocks()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The blocks dispatched for the same Avail block may swap places.
+	if assert.Len(t, delivered, 10) {
+		for i, blk := range delivered {
+			assert.Equal(t, uint64(i/2), (blk.Number()-1)/2, "block %d delivered at %d", blk.Number(), i)
+		}
+	}
+}
+
+func TestSchedulerCoalescesQueuedBlocks(t *testing.T) {
+	f := testutil.NewFake(testAppID, testutil.WithTxPool())
+	s := avail.NewSubmissionScheduler(f, f, avail.SchedulerConfig{MaxPerAvailBlock: 2, QueueSize: 16, Batching: true})
+	defer s.Close()
+
+	errs := submitAll(s, 1, 10)
+	produceUntil(t, f, errs, 10, 2)
+
+	// The blocks queued behind the first Avail block ride together.
+	var extrinsics int
+	for n := uint64(1); n <= f.Head(); n++ {
+		blk, _ := f.Block(n)
+		extrinsics += len(blk.Block.Extrinsics)
+	}
+
+	assert.Less(t, extrinsics, 10)
+
+	delivered, err := f.EdgeBlocks()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	numbers := make([]uint64, 0, len(delivered))
+	for _, blk := range delivered {
+		numbers = append(numbers, blk.Number())
+	}
+
+	assert.ElementsMatch(t, []uint64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, numbers)
+}
+
+func TestSchedulerBackPressure(t *testing.T) {
+	f := testutil.NewFake(testAppID, testutil.WithTxPool())
+	s := avail.NewSubmissionScheduler(f, f, avail.SchedulerConfig{MaxPerAvailBlock: 1, QueueSize: 2})
+
+	// One submission is dispatched, two more fill up the queue.
+	errs := submitAll(s, 1, 3)
+	assert.Eventually(t, func() bool { return f.Pending() == 1 }, 5*time.Second, time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := s.SendAndWaitForStatus(ctx, testBlock(4), types.ExtrinsicStatus{IsInBlock: true})
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), "unexpected error: %v", err)
+
+	// The queued blocks fail once the scheduler is closed.
+	s.Close()
+	f.Produce()
+
+	var closed int
+	for i := 0; i < 3; i++ {
+		if err := <-errs; errors.Is(err, avail.ErrSchedulerClosed) {
+			closed++
+		}
+	}
+
+	assert.Equal(t, 2, closed)
+}
@@ -1,6 +1,7 @@
 package avail
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
@@ -15,29 +16,25 @@ type SearchFunc func(*types.SignedBlock) (int64, bool, error)
 // SearchBlock searches for a block at the specified offset using the provided search function.
 //
 // Parameters:
+//   - ctx: The context of the search.
 //   - offset: The offset from the current block to start the search. If offset is 0, it starts from the latest block.
 //   - searchFunc: The search function that determines the seek offset based on the current Avail block.
 //
 // Return:
 //   - *types.SignedBlock: The found block.
 //   - error: An error if the block search fails.
-func (c *client) SearchBlock(offset int64, searchFunc SearchFunc) (*types.SignedBlock, error) {
+func (c *client) SearchBlock(ctx context.Context, offset int64, searchFunc SearchFunc) (*types.SignedBlock, error) {
 	// In case offset is zero, it means that we have new chain node and we need to sync it
 	// from latest head in avail towards first block.
 	if offset == 0 {
-		header, err := c.api.RPC.Chain.GetHeaderLatest()
+		header, err := c.getHeaderLatest(ctx)
 		if err != nil {
 			return nil, err
 		}
 		offset = int64(header.Number)
 	}
 
-	blkHash, err := c.api.RPC.Chain.GetBlockHash(uint64(offset))
-	if err != nil {
-		return nil, err
-	}
-
-	blk, err := c.api.RPC.Chain.GetBlock(blkHash)
+	blk, err := c.getBlockAt(ctx, uint64(offset))
 	if err != nil {
 		return nil, err
 	}
@@ -58,12 +55,7 @@ func (c *client) SearchBlock(offset int64, searchFunc SearchFunc) (*types.Signed
 			break
 		}
 
-		blkHash, err := c.api.RPC.Chain.GetBlockHash(uint64(blk.Block.Header.Number) + uint64(offset))
-		if err != nil {
-			return nil, err
-		}
-
-		blk, err = c.api.RPC.Chain.GetBlock(blkHash)
+		blk, err = c.getBlockAt(ctx, uint64(blk.Block.Header.Number)+uint64(offset))
 		if err != nil {
 			return nil, err
 		}
@@ -1,42 +1,91 @@
 package avail
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"time"
 
 	edgetypes "github.com/0xPolygon/polygon-edge/types"
-	gsrpc "github.com/centrifuge/go-substrate-rpc-client/v4"
-	"github.com/centrifuge/go-substrate-rpc-client/v4/signature"
 	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
 	"github.com/centrifuge/go-substrate-rpc-client/v4/types/codec"
+	"github.com/hashicorp/go-hclog"
 )
 
 const (
 	// CallSubmitData is the RPC API call for submitting extrinsic data to Avail.
 	CallSubmitData = "DataAvailability.submit_data"
+
+	// resubmitBackoffInterval is the base of the linear backoff from the
+	// resubmissions failed due to connection errors.
+	resubmitBackoffInterval = 500 * time.Millisecond
 )
 
 // Sender is an interface for sending blocks to Avail.
 type Sender interface {
 	// Send sends a block to Avail without waiting for any status response.
-	Send(blk *edgetypes.Block) error
+	// The options override the ones of the sender for this block only.
+	Send(ctx context.Context, blk *edgetypes.Block, opts ...SubmitOption) error
 	// SendAndWaitForStatus sends a block to Avail and waits for the specified extrinsic status.
-	SendAndWaitForStatus(blk *edgetypes.Block, status types.ExtrinsicStatus) error
+	// The options override the ones of the sender for this block only.
+	SendAndWaitForStatus(ctx context.Context, blk *edgetypes.Block, status types.ExtrinsicStatus, opts ...SubmitOption) (SubmitResult, error)
+}
+
+// BatchSender is implemented by the Senders able to submit several blocks
+// in a single Avail extrinsic.
+type BatchSender interface {
+	// SendBatchAndWaitForStatus sends the blocks to Avail as a single batch
+	// and waits for the specified extrinsic status.
+	SendBatchAndWaitForStatus(ctx context.Context, blks []*edgetypes.Block, status types.ExtrinsicStatus, opts ...SubmitOption) (SubmitResult, error)
+}
+
+// SubmitResult represents the final result of block data submission; it
+// references the Avail extrinsic the data was included with.
+type SubmitResult struct {
+	// BlockNumber is the number of the Avail block the data was included in.
+	BlockNumber uint64
+
+	// BlockHash is the hash of the Avail block the data was included in.
+	BlockHash types.Hash
+
+	// ExtrinsicIndex is the index of the extrinsic in the Avail block.
+	ExtrinsicIndex uint32
+
+	// DataHash is the hash of the submitted data.
+	DataHash types.Hash
+
+	// Finalized tells whether the Avail block was finalized when the
+	// submission returned.
+	Finalized bool
 }
 
-// Result represents the final result of block data submission.
-type Result struct{}
+// Submission is an extrinsic of block data accepted into the Avail pool,
+// yet to be included in a block; see WithSubmitted.
+type Submission struct {
+	// Nonce is the nonce of the extrinsic on the Avail account.
+	Nonce uint64
+
+	// Birth is the number of the Avail block the mortal era of the
+	// extrinsic starts at, or of the Avail head it was submitted at when
+	// it's immortal.
+	Birth uint64
+
+	// Mortality is the number of Avail blocks the extrinsic is valid for
+	// from its birth; 0 when it's immortal.
+	Mortality uint64
+}
 
 // blackholeSender is an implementation of Sender that ignores sent blocks.
 type blackholeSender struct{}
 
 // Send ignores the sent block.
-func (t *blackholeSender) Send(blk *edgetypes.Block) error {
+func (t *blackholeSender) Send(ctx context.Context, blk *edgetypes.Block, opts ...SubmitOption) error {
 	return nil
 }
 
 // SendAndWaitForStatus ignores the sent block and the specified status.
-func (t *blackholeSender) SendAndWaitForStatus(blk *edgetypes.Block, status types.ExtrinsicStatus) error {
-	return nil
+func (t *blackholeSender) SendAndWaitForStatus(ctx context.Context, blk *edgetypes.Block, status types.ExtrinsicStatus, opts ...SubmitOption) (SubmitResult, error) {
+	return SubmitResult{}, nil
 }
 
 // NewBlackholeSender constructs an Avail block data sender that ignores sent
@@ -47,71 +96,298 @@ func NewBlackholeSender() Sender {
 
 // sender is an implementation of Sender for Avail block data submission.
 type sender struct {
-	appID          types.UCompact
-	client         Client
-	signingKeyPair signature.KeyringPair
-	nextNonce      uint64
+	appID        types.UCompact
+	client       Client
+	signer       SignatureProvider
+	nonces       *NonceManager
+	callIndex    *CallIndexResolver
+	maxChunkSize int
+	opts         submitOptions
+	logger       hclog.Logger
 }
 
 // NewSender constructs a block data sender for Avail.
-// It takes a Client instance, appID of type types.UCompact, and a signingKeyPair of type signature.KeyringPair.
+// It takes a Client instance, appID of type types.UCompact, the SignatureProvider of the Avail account
+// and the options of the submitted extrinsics; by default they are mortal for DefaultMortalityPeriod
+// blocks and pay DefaultTip.
 // It returns a Sender instance.
-func NewSender(client Client, appID types.UCompact, signingKeyPair signature.KeyringPair) Sender {
+func NewSender(client Client, appID types.UCompact, signer SignatureProvider, opts ...SubmitOption) Sender {
 	return &sender{
-		appID:          appID,
-		client:         client,
-		signingKeyPair: signingKeyPair,
+		appID:        appID,
+		client:       client,
+		signer:       signer,
+		nonces:       accountNonceManager(client, signer.PublicKey()),
+		callIndex:    CallIndexResolverFor(client),
+		maxChunkSize: DefaultMaxChunkSize,
+		opts:         submitOptions{mortality: DefaultMortalityPeriod, tip: DefaultTip}.apply(opts),
+		logger:       clientLogger(client).Named("sender"),
 	}
 }
 
 // Send submits data to Avail without waiting for any status response.
-// It takes a blk parameter of type *edgetypes.Block.
+// Blocks too large for a single extrinsic are submitted in chunks.
+// It takes a context, a blk parameter of type *edgetypes.Block and the options overriding the ones of the sender.
 // It returns an error if there was a problem sending the data.
-func (s *sender) Send(blk *edgetypes.Block) error {
-	api, err := instance(s.client)
+func (s *sender) Send(ctx context.Context, blk *edgetypes.Block, opts ...SubmitOption) error {
+	payloads, err := s.payloads(blk)
+	if err != nil {
+		return err
+	}
+
+	o := s.opts.apply(opts)
+
+	for _, payload := range payloads {
+		if err := s.send(ctx, payload, o); err != nil {
+			observeSubmissionFailure(err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// send submits a single payload to Avail without waiting for any status response.
+func (s *sender) send(ctx context.Context, payload []byte, o submitOptions) error {
+	c, err := endpoint(s.client)
 	if err != nil {
 		return err
 	}
 
-	ext, err := s.prepareExtrinsicForSend(api, blk)
+	nonce, err := s.nonces.Next(ctx)
 	if err != nil {
+		reportResult(ctx, s.client, c, err)
 		return err
 	}
 
-	_, err = api.RPC.Author.SubmitExtrinsic(ext)
+	ext, birth, err := s.prepareExtrinsicForSend(ctx, c, payload, nonce, o)
 	if err != nil {
+		s.nonces.Failed(nonce, err)
+		reportPrepareResult(ctx, s.client, c, err)
 		return err
 	}
 
+	_, err = c.submitExtrinsic(ctx, ext)
+	if s.runtimeUpgraded(ctx, c, err) {
+		// Retry once, signed for the upgraded runtime.
+		if ext, birth, err = s.prepareExtrinsicForSend(ctx, c, payload, nonce, o); err != nil {
+			s.nonces.Failed(nonce, err)
+			reportPrepareResult(ctx, s.client, c, err)
+			return err
+		}
+
+		_, err = c.submitExtrinsic(ctx, ext)
+	}
+
+	reportResult(ctx, s.client, c, err)
+	if err != nil {
+		s.nonces.Failed(nonce, err)
+		return err
+	}
+
+	s.nonces.Done(nonce)
+	observeSubmission(payload)
+	s.notifySubmitted(ctx, c, nonce, birth, o)
+
 	return nil
 }
 
 // SendAndWaitForStatus submits data to Avail and does not wait for the future blocks.
-// It takes blk parameter of type *edgetypes.Block and dstatus parameter of type types.ExtrinsicStatus.
-// It returns an error if there was a problem sending the data or if the specified status expectation is not supported.
-func (s *sender) SendAndWaitForStatus(blk *edgetypes.Block, dstatus types.ExtrinsicStatus) error {
+// When waiting for the extrinsic to be included in a block, the inclusion of
+// the data is verified against the containing Avail block and the data is
+// resubmitted, up to MaxResubmissions times, if it's missing or altered, or
+// if the mortal era of the extrinsic lapsed before its inclusion.
+// Blocks too large for a single extrinsic are submitted in chunks, one after
+// another; the SubmitResult refers to the last chunk.
+// It takes a context, blk parameter of type *edgetypes.Block, dstatus parameter of type types.ExtrinsicStatus
+// and the options overriding the ones of the sender.
+// It returns the SubmitResult and an error if there was a problem sending the data or if the specified status expectation is not supported.
+func (s *sender) SendAndWaitForStatus(ctx context.Context, blk *edgetypes.Block, dstatus types.ExtrinsicStatus, opts ...SubmitOption) (SubmitResult, error) {
 	// Only these three are supported for now.
-	// NOTE: If adding new types here, handle them correspondingly in the end of
-	//       the function as well!
+	// NOTE: If adding new types here, handle them correspondingly in
+	//       sendAndWaitForStatus() as well!
 	if !dstatus.IsFinalized && !dstatus.IsReady && !dstatus.IsInBlock {
-		return fmt.Errorf("unsupported extrinsic status expectation: %#v", dstatus)
+		return SubmitResult{}, fmt.Errorf("unsupported extrinsic status expectation: %#v", dstatus)
 	}
 
-	api, err := instance(s.client)
+	payloads, err := s.payloads(blk)
 	if err != nil {
-		return err
+		return SubmitResult{}, err
+	}
+
+	return s.submitPayloads(ctx, payloads, dstatus, s.opts.apply(opts), blk.Number())
+}
+
+// SendBatchAndWaitForStatus submits the blocks to Avail as a single
+// BlobBatch and waits for the specified extrinsic status, the same way
+// SendAndWaitForStatus does. Batches must fit in a single extrinsic.
+// It returns the SubmitResult shared by all the blocks of the batch.
+func (s *sender) SendBatchAndWaitForStatus(ctx context.Context, blks []*edgetypes.Block, dstatus types.ExtrinsicStatus, opts ...SubmitOption) (SubmitResult, error) {
+	if !dstatus.IsFinalized && !dstatus.IsReady && !dstatus.IsInBlock {
+		return SubmitResult{}, fmt.Errorf("unsupported extrinsic status expectation: %#v", dstatus)
+	}
+
+	if len(blks) == 0 {
+		return SubmitResult{}, fmt.Errorf("%w: no blocks", ErrInvalidBatch)
+	}
+
+	batch := BlobBatch{Blocks: make([][]byte, 0, len(blks))}
+	for _, blk := range blks {
+		batch.Blocks = append(batch.Blocks, blk.MarshalRLP())
 	}
 
-	ext, err := s.prepareExtrinsicForSend(api, blk)
+	payload, err := codec.Encode(&batch)
 	if err != nil {
-		return err
+		return SubmitResult{}, err
+	}
+
+	if len(payload) > s.maxChunkSize {
+		return SubmitResult{}, fmt.Errorf("%w: %d bytes exceed the extrinsic limit of %d", ErrInvalidBatch, len(payload), s.maxChunkSize)
+	}
+
+	return s.submitPayloads(ctx, [][]byte{payload}, dstatus, s.opts.apply(opts), blks[len(blks)-1].Number())
+}
+
+// submitPayloads submits the payloads one after another, resubmitting each
+// up to MaxResubmissions times, and returns the SubmitResult of the last one.
+func (s *sender) submitPayloads(ctx context.Context, payloads [][]byte, dstatus types.ExtrinsicStatus, o submitOptions, blockNumber uint64) (SubmitResult, error) {
+	var (
+		res SubmitResult
+		err error
+	)
+
+	for i, payload := range payloads {
+		for attempt := 0; ; attempt++ {
+			res, err = s.sendAndWaitForStatus(ctx, payload, dstatus, o)
+			if err == nil {
+				break
+			}
+
+			observeSubmissionFailure(err)
+
+			if !isResubmittable(err) || attempt >= MaxResubmissions || ctx.Err() != nil {
+				break
+			}
+
+			s.logger.Warn("couldn't get block data included in Avail; resubmitting", "block_number", blockNumber, "chunk", i, "attempt", attempt+1, "error", err)
+
+			if backoff := resubmitBackoff(err, attempt); backoff > 0 {
+				select {
+				case <-time.After(backoff):
+				case <-ctx.Done():
+					return SubmitResult{}, ctx.Err()
+				}
+			}
+		}
+
+		if err != nil {
+			return SubmitResult{}, err
+		}
+	}
+
+	return res, nil
+}
+
+// reportPrepareResult reports the result of preparing an extrinsic to the
+// failover client. Signing failures are not the endpoint's fault.
+func reportPrepareResult(ctx context.Context, client Client, c *client, err error) {
+	if errors.Is(err, ErrSigningFailed) {
+		return
+	}
+
+	reportResult(ctx, client, c, err)
+}
+
+// runtimeUpgraded reports whether the submission was rejected because Avail
+// upgraded its runtime since the runtime of the endpoint was cached. The
+// cached runtime is refreshed, so the extrinsic can be signed again for the
+// upgraded one.
+func (s *sender) runtimeUpgraded(ctx context.Context, c *client, err error) bool {
+	if !IsRuntimeMismatchError(err) {
+		return false
+	}
+
+	upgraded, rerr := c.refreshRuntime(ctx)
+	if rerr != nil {
+		s.logger.Warn("couldn't refresh Avail runtime after rejected submission", "submission_error", err, "error", rerr)
+		return false
+	}
+
+	return upgraded
+}
+
+// isResubmittable reports whether the block data submission failed in a way
+// that a fresh submission of the same data may succeed.
+func isResubmittable(err error) bool {
+	switch {
+	case errors.Is(err, ErrExtrinsicNotIncluded), errors.Is(err, ErrDataMismatch), errors.Is(err, ErrEraExpired):
+		return true
+	case errors.Is(err, ErrNonceStale):
+		// The nonce manager refreshes the nonce from the chain first.
+		return true
+	case errors.Is(err, ErrConnection), errors.Is(err, ErrSubscriptionClosed):
+		return true
+	default:
+		return false
+	}
+}
+
+// resubmitBackoff returns the time waited before the given resubmission
+// attempt; only the connection failures are backed off from, giving the
+// endpoint, or the failover client, the time to recover.
+func resubmitBackoff(err error, attempt int) time.Duration {
+	if !errors.Is(err, ErrConnection) && !errors.Is(err, ErrSubscriptionClosed) {
+		return 0
+	}
+
+	return time.Duration(attempt+1) * resubmitBackoffInterval
+}
+
+// sendAndWaitForStatus submits a single payload once and waits for the
+// specified extrinsic status, or until the context is done.
+func (s *sender) sendAndWaitForStatus(ctx context.Context, payload []byte, dstatus types.ExtrinsicStatus, o submitOptions) (SubmitResult, error) {
+	c, err := endpoint(s.client)
+	if err != nil {
+		return SubmitResult{}, err
 	}
 
-	sub, err := api.RPC.Author.SubmitAndWatchExtrinsic(ext)
+	nonce, err := s.nonces.Next(ctx)
 	if err != nil {
-		return err
+		reportResult(ctx, s.client, c, err)
+		return SubmitResult{}, err
+	}
+
+	ext, birth, err := s.prepareExtrinsicForSend(ctx, c, payload, nonce, o)
+	if err != nil {
+		s.nonces.Failed(nonce, err)
+		reportPrepareResult(ctx, s.client, c, err)
+		return SubmitResult{}, err
+	}
+
+	start := time.Now()
+
+	sub, err := c.submitAndWatchExtrinsic(ctx, ext)
+	if s.runtimeUpgraded(ctx, c, err) {
+		// Retry once, signed for the upgraded runtime.
+		if ext, birth, err = s.prepareExtrinsicForSend(ctx, c, payload, nonce, o); err != nil {
+			s.nonces.Failed(nonce, err)
+			reportPrepareResult(ctx, s.client, c, err)
+			return SubmitResult{}, err
+		}
+
+		sub, err = c.submitAndWatchExtrinsic(ctx, ext)
 	}
 
+	reportResult(ctx, s.client, c, err)
+	if err != nil {
+		s.nonces.Failed(nonce, err)
+		return SubmitResult{}, err
+	}
+
+	// The extrinsic is in the pool; its nonce is consumed.
+	s.nonces.Done(nonce)
+	observeSubmission(payload)
+	s.notifySubmitted(ctx, c, nonce, birth, o)
+
 	defer sub.Unsubscribe()
 
 	for {
@@ -121,98 +397,149 @@ func (s *sender) SendAndWaitForStatus(blk *edgetypes.Block, dstatus types.Extrin
 			if err != nil {
 				panic(err)
 			}
-			// NOTE: See first line of this function for supported extrinsic status expectations.
+			if status.IsInBlock {
+				observeInclusion(start)
+			}
+
+			if status.IsFinalized {
+				observeFinalization(start)
+			}
+
+			// NOTE: See first line of SendAndWaitForStatus() for supported extrinsic status expectations.
 			switch {
 			case dstatus.IsFinalized && status.IsFinalized:
-				return nil
+				res, err := VerifyInclusion(ctx, s.client, status.AsFinalized, ext)
+				res.Finalized = err == nil
+
+				return res, err
 			case dstatus.IsInBlock && status.IsInBlock:
-				return nil
+				return VerifyInclusion(ctx, s.client, status.AsInBlock, ext)
 			case dstatus.IsReady && status.IsReady:
-				return nil
+				return SubmitResult{}, nil
 			default:
 				if status.IsDropped || status.IsInvalid {
-					return fmt.Errorf("unexpected extrinsic status from Avail: %#v", status)
+					// Extrinsic never made it to a block; make sure following
+					// submissions don't end up waiting behind its nonce.
+					s.nonces.Reset()
+
+					if c.eraExpired(ctx, o.mortality, birth) {
+						return SubmitResult{}, fmt.Errorf("%w: extrinsic born at block %d: %#v", ErrEraExpired, birth, status)
+					}
+
+					return SubmitResult{}, fmt.Errorf("%w: unexpected extrinsic status from Avail: %#v", ErrExtrinsicDropped, status)
 				}
 			}
 		case err := <-sub.Err():
-			// TODO: Consider re-connecting subscription channel on error?
-			return err
+			// The extrinsic may still make it to Avail; the submission is
+			// retried from scratch, the watcher drops the duplicates.
+			err = subscriptionError(err)
+			reportResult(ctx, s.client, c, err)
+			return SubmitResult{}, err
+		case <-ctx.Done():
+			return SubmitResult{}, ctx.Err()
 		}
 	}
 }
 
-// prepareExtrinsicForSend prepares the extrinsic for sending the block data.
-// It takes api parameter of type *gsrpc.SubstrateAPI and blk parameter of type *edgetypes.Block.
-// It returns a types.Extrinsic and an error if there was a problem preparing the extrinsic.
-func (s *sender) prepareExtrinsicForSend(api *gsrpc.SubstrateAPI, blk *edgetypes.Block) (types.Extrinsic, error) {
-	meta, err := api.RPC.State.GetMetadataLatest()
-	if err != nil {
-		return types.Extrinsic{}, err
+// notifySubmitted calls the hook of WithSubmitted, if any, with the
+// extrinsic of the given nonce and era birth accepted into the pool. The
+// birth of an immortal extrinsic is the current Avail head, the earliest
+// block it may be included in.
+func (s *sender) notifySubmitted(ctx context.Context, c *client, nonce, birth uint64, o submitOptions) {
+	if o.submitted == nil {
+		return
 	}
 
-	blob := Blob{
-		Magic: BlobMagic,
-		Data:  blk.MarshalRLP(),
+	sub := Submission{Nonce: nonce, Birth: birth}
+
+	if o.mortality > 0 {
+		sub.Mortality = eraPeriod(o.mortality)
+	} else if hdr, err := c.getHeaderLatest(ctx); err == nil {
+		sub.Birth = uint64(hdr.Number)
 	}
 
-	var call types.Call
-	{
-		// XXX: This encoding process is an inefficient hack to workaround
-		// problem in the encoding pipeline from client code to Avail server.
-		// `Blob` implements `scale.Encodeable` interface, but it it's passed
-		// directly to `types.NewCall()`, the server will return an error. This
-		// requires further investigation to fix.
-		encodedBytes, err := codec.Encode(blob)
+	o.submitted(sub)
+}
+
+// payloads encodes the block data into the payloads of Avail extrinsics.
+// Blocks that fit in a single extrinsic are encoded as a single Blob, larger
+// ones are split into BlobChunks.
+func (s *sender) payloads(blk *edgetypes.Block) ([][]byte, error) {
+	data := blk.MarshalRLP()
+
+	chunks := SplitBlockData(blk.Hash(), data, s.maxChunkSize)
+	if len(chunks) == 0 {
+		encodedBytes, err := codec.Encode(Blob{Magic: BlobMagic, Data: data})
 		if err != nil {
-			return types.Extrinsic{}, err
+			return nil, err
 		}
 
-		call, err = types.NewCall(meta, CallSubmitData, encodedBytes)
+		return [][]byte{encodedBytes}, nil
+	}
+
+	payloads := make([][]byte, 0, len(chunks))
+	for i := range chunks {
+		encodedBytes, err := codec.Encode(&chunks[i])
 		if err != nil {
-			return types.Extrinsic{}, err
+			return nil, err
 		}
+
+		payloads = append(payloads, encodedBytes)
 	}
 
-	ext := types.NewExtrinsic(call)
+	return payloads, nil
+}
 
-	rv, err := api.RPC.State.GetRuntimeVersionLatest()
+// prepareExtrinsicForSend prepares the extrinsic for sending the block data.
+// It takes a context, the endpoint client, the encoded payload, the nonce reserved for the extrinsic and the signing options.
+// It returns a types.Extrinsic, the number of the block its era starts at and an error if there was a problem preparing the extrinsic.
+func (s *sender) prepareExtrinsicForSend(ctx context.Context, c *client, payload []byte, nonce uint64, o submitOptions) (types.Extrinsic, uint64, error) {
+	callIdx, err := s.callIndex.Latest(ctx)
 	if err != nil {
-		return types.Extrinsic{}, err
+		return types.Extrinsic{}, 0, err
 	}
 
-	key, err := types.CreateStorageKey(meta, "System", "Account", s.signingKeyPair.PublicKey)
+	// XXX: This encoding process is an inefficient hack to workaround
+	// problem in the encoding pipeline from client code to Avail server.
+	// `Blob` implements `scale.Encodeable` interface, but it it's passed
+	// directly to `types.NewCall()`, the server will return an error. This
+	// requires further investigation to fix.
+	args, err := codec.Encode(payload)
 	if err != nil {
-		return types.Extrinsic{}, err
+		return types.Extrinsic{}, 0, err
 	}
 
-	var accountInfo types.AccountInfo
-	ok, err := api.RPC.State.GetStorageLatest(key, &accountInfo)
-	if err != nil || !ok {
-		return types.Extrinsic{}, fmt.Errorf("couldn't fetch latest account storage info")
+	call := types.Call{CallIndex: callIdx, Args: args}
+
+	ext := types.NewExtrinsic(call)
+
+	rt, err := c.latestRuntime(ctx)
+	if err != nil {
+		return types.Extrinsic{}, 0, err
 	}
 
-	nonce := uint64(accountInfo.Nonce)
-	if s.nextNonce > nonce {
-		nonce = s.nextNonce
+	rv := rt.version
+
+	era, eraHash, birth, err := c.signingEra(ctx, o.mortality)
+	if err != nil {
+		return types.Extrinsic{}, 0, err
 	}
-	s.nextNonce = nonce + 1
-	o := types.SignatureOptions{
-		// This transaction is Immortal (https://wiki.polkadot.network/docs/build-protocol-info#transaction-mortality)
-		// Hence BlockHash: Genesis Hash.
-		BlockHash:          s.client.GenesisHash(),
-		Era:                types.ExtrinsicEra{IsMortalEra: false},
+
+	so := types.SignatureOptions{
+		BlockHash:          eraHash,
+		Era:                era,
 		GenesisHash:        s.client.GenesisHash(),
 		Nonce:              types.NewUCompactFromUInt(nonce),
 		SpecVersion:        rv.SpecVersion,
-		Tip:                types.NewUCompactFromUInt(100),
+		Tip:                types.NewUCompactFromUInt(o.tip),
 		AppID:              s.appID,
 		TransactionVersion: rv.TransactionVersion,
 	}
 
-	err = ext.Sign(s.signingKeyPair, o)
+	err = signExtrinsic(&ext, s.signer, so)
 	if err != nil {
-		return types.Extrinsic{}, err
+		return types.Extrinsic{}, 0, err
 	}
 
-	return ext, nil
+	return ext, birth, nil
 }
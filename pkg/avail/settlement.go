@@ -0,0 +1,279 @@
+package avail
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	edgetypes "github.com/0xPolygon/polygon-edge/types"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// SettlementNamespace is the JSON-RPC namespace the settlement info is served under.
+const SettlementNamespace = "avail"
+
+// SettlementIndex maps the hashes of the submitted edge blocks to the Avail
+// extrinsics they were included with. Under the archival, the references
+// older than the retention are compacted into the archive files, still
+// looked up past the ones held in memory. The blocks going into an Avail
+// block, and that going final, are published to the subscribers.
+type SettlementIndex struct {
+	lock    sync.RWMutex
+	records map[edgetypes.Hash]SubmitResult
+	archive *settlementArchive
+	latest  uint64
+
+	// unfinalized are the references of the blocks whose Avail blocks are
+	// yet to go final, tracked by CheckFinality.
+	unfinalized map[edgetypes.Hash]unfinalizedSettlement
+	subs        map[chan SettlementEvent]struct{}
+
+	// compactLock serializes the compactions.
+	compactLock sync.Mutex
+}
+
+// NewSettlementIndex returns an empty SettlementIndex.
+func NewSettlementIndex() *SettlementIndex {
+	return &SettlementIndex{
+		records:     make(map[edgetypes.Hash]SubmitResult),
+		unfinalized: make(map[edgetypes.Hash]unfinalizedSettlement),
+		subs:        make(map[chan SettlementEvent]struct{}),
+	}
+}
+
+// Record stores the result of the submission of the edge block with the given
+// hash and number, replacing the one of any earlier submission, and publishes
+// its inclusion in Avail, along with its finality if final already. Under the
+// archival, it compacts the old references once due; a failed compaction
+// leaves them in memory, for the next one to retry.
+func (idx *SettlementIndex) Record(blockHash edgetypes.Hash, blockNumber uint64, res SubmitResult) {
+	idx.lock.Lock()
+
+	prev, known := idx.records[blockHash]
+
+	// The same inclusion recorded anew stays final once it went final.
+	same := known && prev.BlockNumber == res.BlockNumber && prev.BlockHash == res.BlockHash && prev.ExtrinsicIndex == res.ExtrinsicIndex
+	if same && prev.Finalized {
+		res.Finalized = true
+	}
+
+	idx.records[blockHash] = res
+	if res.BlockNumber > idx.latest {
+		idx.latest = res.BlockNumber
+	}
+
+	if res.Finalized {
+		delete(idx.unfinalized, blockHash)
+	} else {
+		idx.unfinalized[blockHash] = unfinalizedSettlement{number: blockNumber, res: res}
+	}
+
+	if !same {
+		idx.publishLocked(SettlementIncluded, blockHash, blockNumber, res)
+	}
+
+	if res.Finalized && !(same && prev.Finalized) {
+		idx.publishLocked(SettlementFinalized, blockHash, blockNumber, res)
+	}
+
+	due := idx.archive != nil && idx.archive.compactionDue(idx.latest)
+
+	idx.lock.Unlock()
+
+	if due {
+		_ = idx.Compact()
+	}
+}
+
+// Get returns the result of the last submission of the edge block with the
+// given hash, or false if it hasn't been settled on Avail. The archive files
+// are looked up past the references held in memory, the latest first.
+func (idx *SettlementIndex) Get(blockHash edgetypes.Hash) (SubmitResult, bool) {
+	idx.lock.RLock()
+
+	res, ok := idx.records[blockHash]
+
+	var files []*settlementArchiveFile
+	if !ok && idx.archive != nil {
+		files = idx.archive.files
+	}
+
+	idx.lock.RUnlock()
+
+	for i := len(files) - 1; i >= 0 && !ok; i-- {
+		res, ok, _ = files[i].get(blockHash)
+	}
+
+	return res, ok
+}
+
+// GetInfo returns the SettlementInfo of the edge block with the given hash,
+// or false if it hasn't been settled on Avail.
+func (idx *SettlementIndex) GetInfo(blockHash edgetypes.Hash) (SettlementInfo, bool) {
+	res, ok := idx.Get(blockHash)
+	if !ok {
+		return SettlementInfo{}, false
+	}
+
+	return newSettlementInfo(blockHash, res), true
+}
+
+// Compact moves the references older than the retention into a new archive
+// file. It's a no-op without the archival.
+func (idx *SettlementIndex) Compact() error {
+	if idx.archive == nil {
+		return nil
+	}
+
+	idx.compactLock.Lock()
+	defer idx.compactLock.Unlock()
+
+	idx.lock.RLock()
+
+	if idx.latest < idx.archive.retention {
+		idx.lock.RUnlock()
+		return nil
+	}
+
+	cutoff := idx.latest - idx.archive.retention
+
+	var entries []settlementEntry
+
+	for hash, res := range idx.records {
+		if res.BlockNumber <= cutoff {
+			entries = append(entries, settlementEntry{hash: hash, res: res})
+		}
+	}
+
+	idx.lock.RUnlock()
+
+	var f *settlementArchiveFile
+
+	if len(entries) > 0 {
+		var err error
+		if f, err = idx.archive.write(entries); err != nil {
+			observeSettlementArchiveFailure()
+			return fmt.Errorf("failed to archive %d settlement references: %w", len(entries), err)
+		}
+	}
+
+	idx.lock.Lock()
+	defer idx.lock.Unlock()
+
+	// The references recorded anew since are kept. The archived ones are
+	// no longer tracked to finality.
+	for _, e := range entries {
+		if res, ok := idx.records[e.hash]; ok && res == e.res {
+			delete(idx.records, e.hash)
+			delete(idx.unfinalized, e.hash)
+		}
+	}
+
+	if f != nil {
+		idx.archive.files = append(idx.archive.files, f)
+	}
+
+	if cutoff > idx.archive.archived {
+		idx.archive.archived = cutoff
+	}
+
+	observeSettlementArchive(len(entries), len(idx.records))
+
+	return nil
+}
+
+// Close closes the archive files of the index.
+func (idx *SettlementIndex) Close() error {
+	if idx.archive == nil {
+		return nil
+	}
+
+	idx.compactLock.Lock()
+	defer idx.compactLock.Unlock()
+
+	idx.lock.Lock()
+	defer idx.lock.Unlock()
+
+	return idx.archive.close()
+}
+
+// settlementRecorder is a Sender recording the results of the submissions
+// of the wrapped Sender in a SettlementIndex.
+type settlementRecorder struct {
+	Sender
+	index *SettlementIndex
+}
+
+// RecordSettlements wraps the sender so that the results of the blocks
+// submitted with SendAndWaitForStatus are recorded in the index.
+// Submissions waiting for the IsReady status only aren't recorded, as they
+// don't reference an Avail block yet.
+func RecordSettlements(sender Sender, index *SettlementIndex) Sender {
+	return &settlementRecorder{Sender: sender, index: index}
+}
+
+func (r *settlementRecorder) SendAndWaitForStatus(ctx context.Context, blk *edgetypes.Block, status types.ExtrinsicStatus, opts ...SubmitOption) (SubmitResult, error) {
+	res, err := r.Sender.SendAndWaitForStatus(ctx, blk, status, opts...)
+	if err == nil && res.BlockNumber != 0 {
+		r.index.Record(blk.Hash(), blk.Number(), res)
+	}
+
+	return res, err
+}
+
+// SettlementInfo is the Avail inclusion reference of an edge block, as
+// returned by `avail_getSettlementInfo`.
+type SettlementInfo struct {
+	BlockHash           edgetypes.Hash `json:"blockHash"`
+	AvailBlockNumber    uint64         `json:"availBlockNumber"`
+	AvailBlockHash      string         `json:"availBlockHash"`
+	AvailExtrinsicIndex uint32         `json:"availExtrinsicIndex"`
+	AvailDataHash       string         `json:"availDataHash"`
+	AvailFinalized      bool           `json:"availFinalized"`
+}
+
+// SettlementAPI serves the settlement info of the SettlementIndex over JSON-RPC.
+type SettlementAPI struct {
+	index *SettlementIndex
+}
+
+// NewSettlementAPI returns the SettlementAPI of the index.
+func NewSettlementAPI(index *SettlementIndex) *SettlementAPI {
+	return &SettlementAPI{index: index}
+}
+
+// GetSettlementInfo returns the Avail inclusion reference of the edge block
+// with the given hash, or nil if it hasn't been settled on Avail.
+func (api *SettlementAPI) GetSettlementInfo(blockHash edgetypes.Hash) (*SettlementInfo, error) {
+	info, ok := api.index.GetInfo(blockHash)
+	if !ok {
+		return nil, nil
+	}
+
+	return &info, nil
+}
+
+// newSettlementInfo returns the SettlementInfo of the edge block with the
+// given hash settled with the given result.
+func newSettlementInfo(blockHash edgetypes.Hash, res SubmitResult) SettlementInfo {
+	return SettlementInfo{
+		BlockHash:           blockHash,
+		AvailBlockNumber:    res.BlockNumber,
+		AvailBlockHash:      res.BlockHash.Hex(),
+		AvailExtrinsicIndex: res.ExtrinsicIndex,
+		AvailDataHash:       res.DataHash.Hex(),
+		AvailFinalized:      res.Finalized,
+	}
+}
+
+// NewSettlementRPCServer returns a JSON-RPC server serving the settlement
+// info of the index under the SettlementNamespace.
+func NewSettlementRPCServer(index *SettlementIndex) (*rpc.Server, error) {
+	srv := rpc.NewServer()
+	if err := srv.RegisterName(SettlementNamespace, NewSettlementAPI(index)); err != nil {
+		return nil, err
+	}
+
+	return srv, nil
+}
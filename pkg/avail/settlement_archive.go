@@ -0,0 +1,369 @@
+package avail
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	edgetypes "github.com/0xPolygon/polygon-edge/types"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+)
+
+// SettlementArchiveVersion is the version of the settlement archive format
+// written.
+const SettlementArchiveVersion = 1
+
+const (
+	// settlementArchiveHeaderLen is the length of the header of a settlement
+	// archive: the magic, the version, the number of the records and of the
+	// fences, and the range of the Avail blocks of the records.
+	settlementArchiveHeaderLen = 8 + 4 + 4 + 4 + 8 + 8
+
+	// settlementRecordLen is the length of a settlement archive record: the
+	// hash of the edge block, and its SubmitResult.
+	settlementRecordLen = edgetypes.HashLength + 8 + 32 + 4 + 32 + 1
+
+	// settlementFenceStride is the number of the records between the fences
+	// of the index of a settlement archive; a lookup reads a single stride.
+	settlementFenceStride = 64
+)
+
+// settlementArchiveMagic opens every settlement archive.
+var settlementArchiveMagic = [8]byte{'o', 'p', 'e', 'v', 'm', 's', 'e', 't'}
+
+var (
+	// ErrSettlementArchiveVersion is returned for a settlement archive of a
+	// format version not supported.
+	ErrSettlementArchiveVersion = errors.New("settlement archive version not supported")
+
+	// ErrSettlementArchiveCorrupt is returned for a settlement archive failing
+	// its checks.
+	ErrSettlementArchiveCorrupt = errors.New("settlement archive corrupt")
+)
+
+// SettlementArchiveConfig configures the archival of the old settlement
+// references of a SettlementIndex.
+type SettlementArchiveConfig struct {
+	// Dir is the directory of the archive files.
+	Dir string
+
+	// Retention is the number of Avail blocks, back from the latest one
+	// recorded, the references of which stay in the index; the older ones
+	// are compacted into an archive file once as many more have passed. 0
+	// disables the archival.
+	Retention uint64
+}
+
+// settlementEntry is a settlement reference, as archived.
+type settlementEntry struct {
+	hash edgetypes.Hash
+	res  SubmitResult
+}
+
+// settlementArchiveFile is an archive file of settlement references: the
+// header, the records sorted by the hash of the edge block, the fences, the
+// hash of every settlementFenceStride-th record, and the SHA-256 checksum of
+// it all. The files are written once and never changed, self-contained, to
+// be copied elsewhere and checked with VerifySettlementArchive.
+type settlementArchiveFile struct {
+	path     string
+	file     *os.File
+	count    int
+	fences   []edgetypes.Hash
+	minAvail uint64
+	maxAvail uint64
+}
+
+// settlementArchive is the set of the archive files of a SettlementIndex, in
+// the order written.
+type settlementArchive struct {
+	dir       string
+	retention uint64
+	files     []*settlementArchiveFile
+	next      int
+	archived  uint64
+}
+
+// NewArchivedSettlementIndex returns the SettlementIndex compacting its old
+// references into the archive files of the configured directory, loading
+// the ones written earlier; it fails on an archive file failing its checks.
+// The archival is disabled under a zero retention.
+func NewArchivedSettlementIndex(cfg SettlementArchiveConfig) (*SettlementIndex, error) {
+	idx := NewSettlementIndex()
+	if cfg.Retention == 0 {
+		return idx, nil
+	}
+
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	archive := &settlementArchive{dir: cfg.Dir, retention: cfg.Retention}
+
+	paths, err := filepath.Glob(filepath.Join(cfg.Dir, "settlements-*.arc"))
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		f, err := openSettlementArchive(path)
+		if err != nil {
+			archive.close()
+			return nil, err
+		}
+
+		archive.files = append(archive.files, f)
+
+		if f.maxAvail > archive.archived {
+			archive.archived = f.maxAvail
+		}
+
+		var seq int
+		if _, err := fmt.Sscanf(filepath.Base(path), "settlements-%d.arc", &seq); err == nil && seq >= archive.next {
+			archive.next = seq + 1
+		}
+	}
+
+	// The temporary files of a compaction cut short are left over.
+	if leftovers, err := filepath.Glob(filepath.Join(cfg.Dir, "settlements-*.arc.*")); err == nil {
+		for _, path := range leftovers {
+			os.Remove(path)
+		}
+	}
+
+	idx.archive = archive
+	idx.latest = archive.archived
+
+	return idx, nil
+}
+
+// VerifySettlementArchive checks the settlement archive file at the given
+// path against its checksum and its format.
+func VerifySettlementArchive(path string) error {
+	f, err := openSettlementArchive(path)
+	if err != nil {
+		return err
+	}
+
+	return f.file.Close()
+}
+
+// compactionDue tells whether the latest Avail block recorded has moved a
+// retention past the one of the last compaction.
+func (a *settlementArchive) compactionDue(latest uint64) bool {
+	return latest >= 2*a.retention && latest-a.retention >= a.archived+a.retention
+}
+
+// write writes the entries into the next archive file, going through a
+// temporary file for it never to be seen incomplete.
+func (a *settlementArchive) write(entries []settlementEntry) (*settlementArchiveFile, error) {
+	sort.Slice(entries, func(i, j int) bool {
+		return bytes.Compare(entries[i].hash[:], entries[j].hash[:]) < 0
+	})
+
+	minAvail, maxAvail := entries[0].res.BlockNumber, entries[0].res.BlockNumber
+	for _, e := range entries {
+		if e.res.BlockNumber < minAvail {
+			minAvail = e.res.BlockNumber
+		}
+
+		if e.res.BlockNumber > maxAvail {
+			maxAvail = e.res.BlockNumber
+		}
+	}
+
+	fences := (len(entries) + settlementFenceStride - 1) / settlementFenceStride
+
+	buf := make([]byte, 0, settlementArchiveHeaderLen+len(entries)*settlementRecordLen+fences*edgetypes.HashLength+sha256.Size)
+	buf = append(buf, settlementArchiveMagic[:]...)
+	buf = binary.BigEndian.AppendUint32(buf, SettlementArchiveVersion)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(entries)))
+	buf = binary.BigEndian.AppendUint32(buf, uint32(fences))
+	buf = binary.BigEndian.AppendUint64(buf, minAvail)
+	buf = binary.BigEndian.AppendUint64(buf, maxAvail)
+
+	for _, e := range entries {
+		buf = appendSettlementRecord(buf, e)
+	}
+
+	for i := 0; i < len(entries); i += settlementFenceStride {
+		buf = append(buf, entries[i].hash[:]...)
+	}
+
+	sum := sha256.Sum256(buf)
+	buf = append(buf, sum[:]...)
+
+	path := filepath.Join(a.dir, fmt.Sprintf("settlements-%08d.arc", a.next))
+
+	tmp, err := os.CreateTemp(a.dir, filepath.Base(path)+".*")
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tmp.Write(buf)
+	if err == nil {
+		err = tmp.Sync()
+	}
+
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+
+	if err != nil {
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+
+	a.next++
+
+	return openSettlementArchive(path)
+}
+
+// close closes the archive files.
+func (a *settlementArchive) close() error {
+	var err error
+
+	for _, f := range a.files {
+		if closeErr := f.file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+
+	return err
+}
+
+// appendSettlementRecord appends the archive record of the entry to buf.
+func appendSettlementRecord(buf []byte, e settlementEntry) []byte {
+	buf = append(buf, e.hash[:]...)
+	buf = binary.BigEndian.AppendUint64(buf, e.res.BlockNumber)
+	buf = append(buf, e.res.BlockHash[:]...)
+	buf = binary.BigEndian.AppendUint32(buf, e.res.ExtrinsicIndex)
+	buf = append(buf, e.res.DataHash[:]...)
+
+	if e.res.Finalized {
+		return append(buf, 1)
+	}
+
+	return append(buf, 0)
+}
+
+// parseSettlementRecord parses the archive record at the head of buf.
+func parseSettlementRecord(buf []byte) settlementEntry {
+	var e settlementEntry
+
+	copy(e.hash[:], buf)
+	buf = buf[edgetypes.HashLength:]
+
+	e.res.BlockNumber = binary.BigEndian.Uint64(buf)
+	buf = buf[8:]
+
+	e.res.BlockHash = types.NewHash(buf[:32])
+	buf = buf[32:]
+
+	e.res.ExtrinsicIndex = binary.BigEndian.Uint32(buf)
+	buf = buf[4:]
+
+	e.res.DataHash = types.NewHash(buf[:32])
+	e.res.Finalized = buf[32] == 1
+
+	return e
+}
+
+// openSettlementArchive checks the settlement archive file at the given path
+// against its checksum and its format, and opens it for the lookups, its
+// fences loaded.
+func openSettlementArchive(path string) (*settlementArchiveFile, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(buf) < settlementArchiveHeaderLen+sha256.Size || !bytes.Equal(buf[:len(settlementArchiveMagic)], settlementArchiveMagic[:]) {
+		return nil, fmt.Errorf("%w: %s: not a settlement archive", ErrSettlementArchiveCorrupt, path)
+	}
+
+	body, sum := buf[:len(buf)-sha256.Size], buf[len(buf)-sha256.Size:]
+	if checksum := sha256.Sum256(body); !bytes.Equal(checksum[:], sum) {
+		return nil, fmt.Errorf("%w: %s: checksum mismatch", ErrSettlementArchiveCorrupt, path)
+	}
+
+	header := body[len(settlementArchiveMagic):]
+	if version := binary.BigEndian.Uint32(header); version != SettlementArchiveVersion {
+		return nil, fmt.Errorf("%w: %s: version %d", ErrSettlementArchiveVersion, path, version)
+	}
+
+	count := int(binary.BigEndian.Uint32(header[4:]))
+	fences := int(binary.BigEndian.Uint32(header[8:]))
+
+	if fences != (count+settlementFenceStride-1)/settlementFenceStride || len(body) != settlementArchiveHeaderLen+count*settlementRecordLen+fences*edgetypes.HashLength {
+		return nil, fmt.Errorf("%w: %s: %d records and %d fences in %d bytes", ErrSettlementArchiveCorrupt, path, count, fences, len(buf))
+	}
+
+	f := &settlementArchiveFile{
+		path:     path,
+		count:    count,
+		fences:   make([]edgetypes.Hash, fences),
+		minAvail: binary.BigEndian.Uint64(header[12:]),
+		maxAvail: binary.BigEndian.Uint64(header[20:]),
+	}
+
+	offset := settlementArchiveHeaderLen + count*settlementRecordLen
+	for i := range f.fences {
+		copy(f.fences[i][:], body[offset+i*edgetypes.HashLength:])
+	}
+
+	if f.file, err = os.Open(path); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// get looks the edge block with the given hash up in the archive file,
+// reading the single stride of the records its fence points to.
+func (f *settlementArchiveFile) get(hash edgetypes.Hash) (SubmitResult, bool, error) {
+	// The stride is the one of the last fence not past the hash.
+	stride := sort.Search(len(f.fences), func(i int) bool {
+		return bytes.Compare(f.fences[i][:], hash[:]) > 0
+	}) - 1
+	if stride < 0 {
+		return SubmitResult{}, false, nil
+	}
+
+	first := stride * settlementFenceStride
+
+	n := f.count - first
+	if n > settlementFenceStride {
+		n = settlementFenceStride
+	}
+
+	buf := make([]byte, n*settlementRecordLen)
+	if _, err := f.file.ReadAt(buf, int64(settlementArchiveHeaderLen+first*settlementRecordLen)); err != nil {
+		return SubmitResult{}, false, err
+	}
+
+	i := sort.Search(n, func(i int) bool {
+		return bytes.Compare(buf[i*settlementRecordLen:i*settlementRecordLen+edgetypes.HashLength], hash[:]) >= 0
+	})
+	if i == n {
+		return SubmitResult{}, false, nil
+	}
+
+	e := parseSettlementRecord(buf[i*settlementRecordLen:])
+	if e.hash != hash {
+		return SubmitResult{}, false, nil
+	}
+
+	return e.res, true, nil
+}
@@ -0,0 +1,187 @@
+package avail
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	edgetypes "github.com/0xPolygon/polygon-edge/types"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// testSettlement returns the synthetic settlement reference of the edge
+// block of the given number, included in the Avail block of the same.
+func testSettlement(number uint64) (edgetypes.Hash, SubmitResult) {
+	hash := func(kind byte) [32]byte {
+		return sha256.Sum256(binary.BigEndian.AppendUint64([]byte{kind}, number))
+	}
+
+	return edgetypes.Hash(hash('e')), SubmitResult{
+		BlockNumber:    number,
+		BlockHash:      types.Hash(hash('a')),
+		ExtrinsicIndex: uint32(number % 7),
+		DataHash:       types.Hash(hash('d')),
+		Finalized:      number%2 == 0,
+	}
+}
+
+func TestSettlementArchive(t *testing.T) {
+	const (
+		entries   = 3000
+		retention = 500
+	)
+
+	dir := t.TempDir()
+	cfg := SettlementArchiveConfig{Dir: dir, Retention: retention}
+
+	idx, err := NewArchivedSettlementIndex(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for number := uint64(1); number <= entries; number++ {
+		hash, res := testSettlement(number)
+		idx.Record(hash, number, res)
+	}
+
+	// The hot index holds the references of the retention, up to one more
+	// of them short of the next compaction.
+	idx.lock.RLock()
+	hot := len(idx.records)
+	idx.lock.RUnlock()
+
+	assert.GreaterOrEqual(t, hot, retention)
+	assert.Less(t, hot, 2*retention)
+
+	files, err := filepath.Glob(filepath.Join(dir, "settlements-*.arc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NotEmpty(t, files)
+
+	// assertSettled asserts the references of all the blocks are found, on
+	// both sides of the hot/cold boundary.
+	assertSettled := func(idx *SettlementIndex) {
+		t.Helper()
+
+		for number := uint64(1); number <= entries; number++ {
+			hash, want := testSettlement(number)
+
+			got, ok := idx.Get(hash)
+			if !assert.True(t, ok, "block %d", number) || !assert.Equal(t, want, got, "block %d", number) {
+				return
+			}
+		}
+
+		_, ok := idx.Get(edgetypes.StringToHash("0x01"))
+		assert.False(t, ok)
+	}
+
+	assertSettled(idx)
+
+	// The block submitted anew is found at its latest reference, over the
+	// archived one.
+	resubmitted, res := testSettlement(10)
+	res.BlockNumber, res.Finalized = entries+1, true
+	idx.Record(resubmitted, 10, res)
+
+	got, ok := idx.Get(resubmitted)
+	assert.True(t, ok)
+	assert.Equal(t, res, got)
+
+	assert.NoError(t, idx.Close())
+
+	// The archive files are checked and loaded anew; the references held in
+	// memory only are lost, as without the archival.
+	reloaded, err := NewArchivedSettlementIndex(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer reloaded.Close()
+
+	for number := uint64(1); number <= entries-2*retention; number++ {
+		hash, want := testSettlement(number)
+
+		got, ok := reloaded.Get(hash)
+		if !assert.True(t, ok, "block %d", number) || !assert.Equal(t, want, got, "block %d", number) {
+			break
+		}
+	}
+
+	// The archive files stay verifiable once copied elsewhere.
+	for _, path := range files {
+		buf, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		copied := filepath.Join(t.TempDir(), filepath.Base(path))
+		if err := os.WriteFile(copied, buf, 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		assert.NoError(t, VerifySettlementArchive(copied))
+	}
+}
+
+func TestSettlementArchiveCorrupt(t *testing.T) {
+	dir := t.TempDir()
+	cfg := SettlementArchiveConfig{Dir: dir, Retention: 100}
+
+	idx, err := NewArchivedSettlementIndex(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for number := uint64(1); number <= 1000; number++ {
+		hash, res := testSettlement(number)
+		idx.Record(hash, number, res)
+	}
+
+	assert.NoError(t, idx.Close())
+
+	files, err := filepath.Glob(filepath.Join(dir, "settlements-*.arc"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no archive files: %v", err)
+	}
+
+	path := files[len(files)/2]
+
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf[settlementArchiveHeaderLen+settlementRecordLen+10] ^= 0xff
+
+	if err := os.WriteFile(path, buf, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	err = VerifySettlementArchive(path)
+	assert.True(t, errors.Is(err, ErrSettlementArchiveCorrupt), "unexpected error: %v", err)
+
+	// The index refuses to load over the corrupt file.
+	_, err = NewArchivedSettlementIndex(cfg)
+	assert.True(t, errors.Is(err, ErrSettlementArchiveCorrupt), "unexpected error: %v", err)
+
+	// The file of another format version is refused, its checksum good.
+	buf[settlementArchiveHeaderLen+settlementRecordLen+10] ^= 0xff
+	buf[11] = 0xff
+
+	body := buf[:len(buf)-sha256.Size]
+	sum := sha256.Sum256(body)
+
+	if err := os.WriteFile(path, append(body, sum[:]...), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	err = VerifySettlementArchive(path)
+	assert.True(t, errors.Is(err, ErrSettlementArchiveVersion), "unexpected error: %v", err)
+}
@@ -0,0 +1,221 @@
+package avail
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	edgetypes "github.com/0xPolygon/polygon-edge/types"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+)
+
+// settlementFeedSize is the number of the settlement events buffered for
+// each subscriber.
+const settlementFeedSize = 256
+
+const (
+	// SettlementIncluded is the stage of the block included in an Avail
+	// block.
+	SettlementIncluded = "availIncluded"
+
+	// SettlementFinalized is the stage of the block whose Avail block went
+	// final.
+	SettlementFinalized = "availFinalized"
+)
+
+// SettlementEvent is the transition of an edge block to a settlement stage,
+// as pushed to the subscribers of the "settlements" subscription.
+type SettlementEvent struct {
+	Stage       string `json:"stage"`
+	BlockNumber uint64 `json:"blockNumber"`
+	SettlementInfo
+}
+
+// unfinalizedSettlement is the reference of an edge block whose Avail block
+// is yet to go final.
+type unfinalizedSettlement struct {
+	number uint64
+	res    SubmitResult
+}
+
+// finalityReader is implemented by the clients that tell the finalized Avail
+// blocks directly, such as the in-memory fake of Avail.
+type finalityReader interface {
+	GetFinalizedHead(ctx context.Context) (uint64, error)
+	GetBlockHash(ctx context.Context, number uint64) (types.Hash, error)
+}
+
+// Subscribe returns the channel the settlement events published from now on
+// are sent to, and the function to unsubscribe with. A subscriber falling
+// settlementFeedSize events behind misses the following ones.
+func (idx *SettlementIndex) Subscribe() (<-chan SettlementEvent, func()) {
+	ch := make(chan SettlementEvent, settlementFeedSize)
+
+	idx.lock.Lock()
+	idx.subs[ch] = struct{}{}
+	idx.lock.Unlock()
+
+	return ch, func() {
+		idx.lock.Lock()
+		delete(idx.subs, ch)
+		idx.lock.Unlock()
+	}
+}
+
+// publishLocked publishes the transition of the edge block to the given
+// stage. It must be called with the lock held.
+func (idx *SettlementIndex) publishLocked(stage string, blockHash edgetypes.Hash, blockNumber uint64, res SubmitResult) {
+	e := SettlementEvent{Stage: stage, BlockNumber: blockNumber, SettlementInfo: newSettlementInfo(blockHash, res)}
+
+	for ch := range idx.subs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// CheckFinality marks final the references of the blocks whose Avail blocks
+// went final since, publishing their finality. The references whose Avail
+// blocks were replaced on the way to finality are dropped; the blocks are
+// recorded anew on their resubmission.
+func (idx *SettlementIndex) CheckFinality(ctx context.Context, client Client) error {
+	idx.lock.RLock()
+
+	pending := make([]edgetypes.Hash, 0, len(idx.unfinalized))
+	for hash := range idx.unfinalized {
+		pending = append(pending, hash)
+	}
+
+	idx.lock.RUnlock()
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	finalized, err := finalizedHead(ctx, client)
+	if err != nil {
+		return fmt.Errorf("failed to get the finalized Avail head: %w", err)
+	}
+
+	idx.lock.RLock()
+
+	entries := make(map[edgetypes.Hash]unfinalizedSettlement, len(pending))
+	for _, hash := range pending {
+		if u, ok := idx.unfinalized[hash]; ok && u.res.BlockNumber <= finalized {
+			entries[hash] = u
+		}
+	}
+
+	idx.lock.RUnlock()
+
+	// The blocks are published final in their order.
+	sort.Slice(pending, func(i, j int) bool { return entries[pending[i]].number < entries[pending[j]].number })
+
+	hashes := make(map[uint64]types.Hash)
+
+	for _, hash := range pending {
+		u, ok := entries[hash]
+		if !ok {
+			continue
+		}
+
+		availHash, ok := hashes[u.res.BlockNumber]
+		if !ok {
+			if availHash, err = blockHashAt(ctx, client, u.res.BlockNumber); err != nil {
+				return fmt.Errorf("failed to get the hash of Avail block %d: %w", u.res.BlockNumber, err)
+			}
+
+			hashes[u.res.BlockNumber] = availHash
+		}
+
+		idx.finalize(hash, u, availHash == u.res.BlockHash)
+	}
+
+	return nil
+}
+
+// finalize marks final the reference of the edge block with the given hash,
+// unless recorded anew since, or drops it if its Avail block was replaced.
+func (idx *SettlementIndex) finalize(blockHash edgetypes.Hash, u unfinalizedSettlement, canonical bool) {
+	idx.lock.Lock()
+	defer idx.lock.Unlock()
+
+	if cur, ok := idx.unfinalized[blockHash]; !ok || cur != u {
+		return
+	}
+
+	delete(idx.unfinalized, blockHash)
+
+	if !canonical {
+		return
+	}
+
+	res := u.res
+	res.Finalized = true
+
+	if cur, ok := idx.records[blockHash]; ok && cur == u.res {
+		idx.records[blockHash] = res
+	}
+
+	idx.publishLocked(SettlementFinalized, blockHash, u.number, res)
+}
+
+// TrackFinality checks the finality of the references every interval, until
+// the context is done. Failed checks are retried on the next tick.
+func (idx *SettlementIndex) TrackFinality(ctx context.Context, client Client, interval time.Duration) {
+	logger := clientLogger(client)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := idx.CheckFinality(ctx, client); err != nil {
+				logger.Warn("failed to check the finality of the settlements", "error", err)
+			}
+		}
+	}
+}
+
+// finalizedHead returns the number of the latest finalized Avail block.
+func finalizedHead(ctx context.Context, client Client) (uint64, error) {
+	if fr, ok := client.(finalityReader); ok {
+		return fr.GetFinalizedHead(ctx)
+	}
+
+	c, err := endpoint(client)
+	if err != nil {
+		return 0, err
+	}
+
+	hash, err := c.getFinalizedHead(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	hdr, err := c.getHeader(ctx, hash)
+	if err != nil {
+		return 0, err
+	}
+
+	return uint64(hdr.Number), nil
+}
+
+// blockHashAt returns the hash of the Avail block at the given height.
+func blockHashAt(ctx context.Context, client Client, number uint64) (types.Hash, error) {
+	if fr, ok := client.(finalityReader); ok {
+		return fr.GetBlockHash(ctx, number)
+	}
+
+	c, err := endpoint(client)
+	if err != nil {
+		return types.Hash{}, err
+	}
+
+	return c.getBlockHash(ctx, number)
+}
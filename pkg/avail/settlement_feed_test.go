@@ -0,0 +1,83 @@
+package avail
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	edgetypes "github.com/0xPolygon/polygon-edge/types"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSettlementIndexCheckFinality(t *testing.T) {
+	chain := newStubChain(t, 10, time.Hour)
+	chain.finalityLag = 3
+	e := newStubEndpoint(t, chain)
+
+	c, err := NewClient(e.URL, hclog.NewNullLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idx := NewSettlementIndex()
+
+	events, unsubscribe := idx.Subscribe()
+	defer unsubscribe()
+
+	// The blocks go into the Avail blocks 6 and 7, the finalized head, and 8
+	// above it; the Avail block 7 is seen replaced on the way to finality.
+	final, replaced, pending := edgetypes.StringToHash("0x01"), edgetypes.StringToHash("0x02"), edgetypes.StringToHash("0x03")
+
+	idx.Record(final, 1, SubmitResult{BlockNumber: 6, BlockHash: stubHash(6)})
+	idx.Record(replaced, 2, SubmitResult{BlockNumber: 7, BlockHash: stubHash(70)})
+	idx.Record(pending, 3, SubmitResult{BlockNumber: 8, BlockHash: stubHash(8)})
+
+	for _, hash := range []edgetypes.Hash{final, replaced, pending} {
+		e := <-events
+		assert.Equal(t, SettlementIncluded, e.Stage)
+		assert.Equal(t, hash, e.BlockHash)
+		assert.False(t, e.AvailFinalized)
+	}
+
+	if err := idx.CheckFinality(context.Background(), c); err != nil {
+		t.Fatal(err)
+	}
+
+	// The block in the finalized Avail block alone goes final.
+	if assert.Len(t, events, 1) {
+		e := <-events
+		assert.Equal(t, SettlementFinalized, e.Stage)
+		assert.Equal(t, final, e.BlockHash)
+		assert.Equal(t, uint64(1), e.BlockNumber)
+		assert.True(t, e.AvailFinalized)
+	}
+
+	res, _ := idx.Get(final)
+	assert.True(t, res.Finalized)
+
+	// The replaced one is no longer tracked, and the pending one goes final
+	// with the next finalized Avail block.
+	chain.produce()
+
+	if err := idx.CheckFinality(context.Background(), c); err != nil {
+		t.Fatal(err)
+	}
+
+	if assert.Len(t, events, 1) {
+		e := <-events
+		assert.Equal(t, SettlementFinalized, e.Stage)
+		assert.Equal(t, pending, e.BlockHash)
+	}
+
+	res, _ = idx.Get(replaced)
+	assert.False(t, res.Finalized)
+
+	// Recorded anew, the final block stays final, without publishing it
+	// again.
+	idx.Record(final, 1, SubmitResult{BlockNumber: 6, BlockHash: stubHash(6)})
+	assert.Empty(t, events)
+
+	res, _ = idx.Get(final)
+	assert.True(t, res.Finalized)
+}
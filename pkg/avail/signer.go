@@ -0,0 +1,239 @@
+package avail
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/signature"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types/codec"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"golang.org/x/crypto/blake2b"
+)
+
+const (
+	// SignerMnemonic selects the signer holding the Avail account mnemonic in memory.
+	SignerMnemonic = "mnemonic"
+
+	// SignerKeystore selects the signer backed by a passphrase encrypted keystore file.
+	SignerKeystore = "keystore"
+
+	// keystoreVersion is the version of the Avail keystore file format.
+	keystoreVersion = 1
+)
+
+var (
+	// ErrSigningFailed is the error returned when the SignatureProvider fails
+	// to sign an extrinsic. It's fatal: resubmitting the same data won't help.
+	ErrSigningFailed = errors.New("signing Avail extrinsic failed")
+
+	// ErrWrongPassphrase is the error returned when the Avail keystore can't
+	// be decrypted with the given passphrase.
+	ErrWrongPassphrase = errors.New("wrong Avail keystore passphrase")
+
+	// ErrUnknownSigner is the error returned for an unsupported signer type.
+	ErrUnknownSigner = errors.New("unknown Avail signer type")
+)
+
+// keystoreScryptN and keystoreScryptP are the scrypt parameters the Avail
+// keystore is encrypted with.
+var (
+	keystoreScryptN = keystore.StandardScryptN
+	keystoreScryptP = keystore.StandardScryptP
+)
+
+// SignatureProvider signs the extrinsics submitted to Avail on behalf of the
+// Avail account. Implementations may keep the key in memory or delegate the
+// signing to an external signer.
+type SignatureProvider interface {
+	// PublicKey returns the sr25519 public key of the Avail account.
+	PublicKey() []byte
+
+	// Sign returns the sr25519 signature of the message.
+	Sign(msg []byte) ([]byte, error)
+}
+
+// keyringSigner is a SignatureProvider holding the key pair in memory.
+type keyringSigner struct {
+	kp signature.KeyringPair
+}
+
+// NewKeyringSigner returns a SignatureProvider signing with the given key pair.
+func NewKeyringSigner(kp signature.KeyringPair) SignatureProvider {
+	return &keyringSigner{kp: kp}
+}
+
+func (s *keyringSigner) PublicKey() []byte {
+	return s.kp.PublicKey
+}
+
+func (s *keyringSigner) Sign(msg []byte) ([]byte, error) {
+	return signature.Sign(msg, s.kp.URI)
+}
+
+// keystoreFile is the on-disk format of the Avail keystore.
+type keystoreFile struct {
+	Address string              `json:"address"`
+	Crypto  keystore.CryptoJSON `json:"crypto"`
+	Version int                 `json:"version"`
+}
+
+// WriteKeystore encrypts the secret of the key pair with the passphrase and
+// writes it to the keystore file at the given path.
+func WriteKeystore(path string, kp signature.KeyringPair, passphrase string) error {
+	cryptoJSON, err := keystore.EncryptDataV3([]byte(kp.URI), []byte(passphrase), keystoreScryptN, keystoreScryptP)
+	if err != nil {
+		return err
+	}
+
+	bs, err := json.Marshal(keystoreFile{Address: kp.Address, Crypto: cryptoJSON, Version: keystoreVersion})
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, bs, 0o600)
+}
+
+// NewKeystoreSigner decrypts the Avail keystore at the given path with the
+// passphrase and returns a SignatureProvider signing with its key pair.
+// It returns ErrWrongPassphrase when the passphrase doesn't match.
+func NewKeystoreSigner(path, passphrase string) (SignatureProvider, error) {
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failure to read keystore file '%s'", err)
+	}
+
+	var ks keystoreFile
+	if err := json.Unmarshal(bs, &ks); err != nil {
+		return nil, fmt.Errorf("invalid keystore file %q: %w", path, err)
+	}
+
+	if ks.Version != keystoreVersion {
+		return nil, fmt.Errorf("unsupported keystore version %d in %q", ks.Version, path)
+	}
+
+	secret, err := keystore.DecryptDataV3(ks.Crypto, passphrase)
+	if err != nil {
+		if errors.Is(err, keystore.ErrDecrypt) {
+			return nil, fmt.Errorf("%w: %s", ErrWrongPassphrase, path)
+		}
+
+		return nil, err
+	}
+
+	kp, err := NewAccountFromMnemonic(string(secret))
+	if err != nil {
+		return nil, err
+	}
+
+	if ks.Address != "" && ks.Address != kp.Address {
+		return nil, fmt.Errorf("keystore %q holds the key of %s, not %s", path, kp.Address, ks.Address)
+	}
+
+	return NewKeyringSigner(kp), nil
+}
+
+// SignerConfig selects the SignatureProvider of the Avail account.
+type SignerConfig struct {
+	// Type is the type of the signer; SignerMnemonic or SignerKeystore.
+	Type string
+
+	// Path is the path to the mnemonic or the keystore file.
+	Path string
+
+	// PassphraseFile is the path to the file holding the keystore passphrase.
+	PassphraseFile string
+}
+
+// NewSignatureProvider creates the SignatureProvider selected by the configuration.
+func NewSignatureProvider(cfg SignerConfig) (SignatureProvider, error) {
+	switch cfg.Type {
+	case SignerMnemonic, "":
+		kp, err := AccountFromFile(cfg.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		return NewKeyringSigner(kp), nil
+	case SignerKeystore:
+		passphrase, err := os.ReadFile(cfg.PassphraseFile)
+		if err != nil {
+			return nil, fmt.Errorf("failure to read keystore passphrase file '%s'", err)
+		}
+
+		return NewKeystoreSigner(cfg.Path, strings.TrimRight(string(passphrase), "\r\n"))
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownSigner, cfg.Type)
+	}
+}
+
+// signExtrinsic signs the extrinsic with the signer the same way
+// types.Extrinsic.Sign does with a key pair. Signing failures are wrapped
+// with ErrSigningFailed.
+func signExtrinsic(ext *types.Extrinsic, signer SignatureProvider, o types.SignatureOptions) error {
+	if ext.Type() != types.ExtrinsicVersion4 {
+		return fmt.Errorf("unsupported extrinsic version: %v (isSigned: %v, type: %v)", ext.Version, ext.IsSigned(), ext.Type())
+	}
+
+	mb, err := codec.Encode(ext.Method)
+	if err != nil {
+		return err
+	}
+
+	era := o.Era
+	if !o.Era.IsMortalEra {
+		era = types.ExtrinsicEra{IsImmortalEra: true}
+	}
+
+	payload := types.ExtrinsicPayloadV4{
+		ExtrinsicPayloadV3: types.ExtrinsicPayloadV3{
+			Method:      mb,
+			Era:         era,
+			Nonce:       o.Nonce,
+			Tip:         o.Tip,
+			SpecVersion: o.SpecVersion,
+			GenesisHash: o.GenesisHash,
+			BlockHash:   o.BlockHash,
+		},
+		TransactionVersion: o.TransactionVersion,
+		AppID:              o.AppID,
+	}
+
+	msg, err := codec.Encode(payload)
+	if err != nil {
+		return err
+	}
+
+	// Payloads longer than 256 bytes are signed by their hash.
+	if len(msg) > 256 {
+		h := blake2b.Sum256(msg)
+		msg = h[:]
+	}
+
+	signerID, err := types.NewMultiAddressFromAccountID(signer.PublicKey())
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrSigningFailed, err)
+	}
+
+	sig, err := signer.Sign(msg)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrSigningFailed, err)
+	}
+
+	ext.Signature = types.ExtrinsicSignatureV4{
+		Signer:    signerID,
+		Signature: types.MultiSignature{IsSr25519: true, AsSr25519: types.NewSignature(sig)},
+		Era:       era,
+		Nonce:     o.Nonce,
+		Tip:       o.Tip,
+		AppID:     o.AppID,
+	}
+
+	// Mark the extrinsic as signed.
+	ext.Version |= types.ExtrinsicBitSigned
+
+	return nil
+}
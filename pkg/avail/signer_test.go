@@ -0,0 +1,176 @@
+package avail
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	edge_types "github.com/0xPolygon/polygon-edge/types"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/signature"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types/codec"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+// lightKeystore makes the keystores written by the test cheap to decrypt.
+func lightKeystore(t *testing.T) {
+	n, p := keystoreScryptN, keystoreScryptP
+	keystoreScryptN, keystoreScryptP = keystore.LightScryptN, keystore.LightScryptP
+
+	t.Cleanup(func() { keystoreScryptN, keystoreScryptP = n, p })
+}
+
+// failingSigner is a SignatureProvider that fails to sign.
+type failingSigner struct {
+	publicKey []byte
+}
+
+func (s *failingSigner) PublicKey() []byte { return s.publicKey }
+
+func (s *failingSigner) Sign(msg []byte) ([]byte, error) {
+	return nil, errors.New("remote signer unavailable")
+}
+
+func TestSignExtrinsicMatchesKeyringPair(t *testing.T) {
+	kp := signature.TestKeyringPairAlice
+	o := types.SignatureOptions{
+		Era:                types.ExtrinsicEra{IsMortalEra: false},
+		Nonce:              types.NewUCompactFromUInt(3),
+		Tip:                types.NewUCompactFromUInt(100),
+		AppID:              types.NewUCompactFromUInt(1),
+		SpecVersion:        1,
+		TransactionVersion: 1,
+	}
+
+	call := types.Call{CallIndex: FallbackSubmitDataCallIndex, Args: []byte{0x01, 0x02}}
+
+	expected := types.NewExtrinsic(call)
+	if err := expected.Sign(kp, o); err != nil {
+		t.Fatal(err)
+	}
+
+	ext := types.NewExtrinsic(call)
+	if err := signExtrinsic(&ext, NewKeyringSigner(kp), o); err != nil {
+		t.Fatal(err)
+	}
+
+	// sr25519 signatures are randomized; everything else must match.
+	sig := ext.Signature.Signature.AsSr25519
+	expected.Signature.Signature.AsSr25519 = sig
+	assert.Equal(t, expected, ext)
+
+	mb, err := codec.Encode(call)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := codec.Encode(types.ExtrinsicPayloadV4{
+		ExtrinsicPayloadV3: types.ExtrinsicPayloadV3{
+			Method:      mb,
+			Era:         types.ExtrinsicEra{IsImmortalEra: true},
+			Nonce:       o.Nonce,
+			Tip:         o.Tip,
+			SpecVersion: o.SpecVersion,
+		},
+		TransactionVersion: o.TransactionVersion,
+		AppID:              o.AppID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ok, err := signature.Verify(msg, sig[:], kp.URI)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestKeystoreSignerSubmission(t *testing.T) {
+	lightKeystore(t)
+
+	chain := newStubChain(t, 1, time.Hour)
+	chain.runtimes[0].metadata = stubMetadata(t, 42)
+	e := newStubEndpoint(t, chain)
+
+	c, err := NewClient(e.URL, hclog.NewNullLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "account.json")
+	if err := WriteKeystore(path, signature.TestKeyringPairAlice, "correct horse"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The secret isn't stored in plaintext.
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NotContains(t, string(bs), signature.TestKeyringPairAlice.URI)
+
+	_, err = NewKeystoreSigner(path, "battery staple")
+	assert.True(t, errors.Is(err, ErrWrongPassphrase), "unexpected error: %v", err)
+
+	passphraseFile := filepath.Join(t.TempDir(), "passphrase")
+	if err := os.WriteFile(passphraseFile, []byte("correct horse\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	signer, err := NewSignatureProvider(SignerConfig{Type: SignerKeystore, Path: path, PassphraseFile: passphraseFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, signature.TestKeyringPairAlice.PublicKey, signer.PublicKey())
+
+	s := NewSender(c, types.NewUCompactFromUInt(1), signer)
+	if err := s.Send(context.Background(), &edge_types.Block{Header: &edge_types.Header{Number: 1}}); err != nil {
+		t.Fatal(err)
+	}
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if assert.Len(t, e.submitted, 1) {
+		ext := e.submitted[0]
+		assert.True(t, ext.IsSigned())
+		assert.Equal(t, signature.TestKeyringPairAlice.PublicKey, ext.Signature.Signer.AsID.ToBytes())
+	}
+}
+
+func TestSigningFailureIsFatal(t *testing.T) {
+	sink := testMetricsSink(t)
+
+	chain := newStubChain(t, 1, time.Hour)
+	chain.runtimes[0].metadata = stubMetadata(t, 42)
+	e := newStubEndpoint(t, chain)
+
+	c, err := NewClient(e.URL, hclog.NewNullLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dave, err := signature.KeyringPairFromSecret("//Dave", 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewSender(c, types.NewUCompactFromUInt(1), &failingSigner{publicKey: dave.PublicKey})
+
+	_, err = s.SendAndWaitForStatus(context.Background(), &edge_types.Block{Header: &edge_types.Header{Number: 1}}, types.ExtrinsicStatus{IsInBlock: true})
+	assert.True(t, errors.Is(err, ErrSigningFailed), "unexpected error: %v", err)
+	assert.False(t, isResubmittable(err))
+
+	// Nothing reached Avail and the submission wasn't retried.
+	assert.Empty(t, e.submitted)
+	assert.Equal(t, float64(1), counter(sink, "test.avail.submission.failures;class=signing"))
+
+	_, err = NewSignatureProvider(SignerConfig{Type: "hsm"})
+	assert.True(t, errors.Is(err, ErrUnknownSigner), "unexpected error: %v", err)
+}
@@ -1,10 +1,9 @@
 package avail
 
 import (
+	"context"
 	"fmt"
-	"sync/atomic"
 
-	gsrpc "github.com/centrifuge/go-substrate-rpc-client/v4"
 	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
 	"github.com/hashicorp/go-hclog"
 )
@@ -18,32 +17,42 @@ type BlockStream interface {
 	Close()
 }
 
+// maxCatchUpRetries is the number of attempts made to fetch a page of historical blocks.
+const maxCatchUpRetries = 3
+
 // blockStream implements the BlockStream interface.
 type blockStream struct {
-	closed  *atomic.Bool
-	closeCh chan struct{}
-	dataCh  chan *types.SignedBlock
-	api     *gsrpc.SubstrateAPI
-	logger  hclog.Logger
-	offset  uint64
+	ctx      context.Context
+	cancel   context.CancelFunc
+	dataCh   chan *types.SignedBlock
+	doneCh   chan struct{}
+	client   *client
+	logger   hclog.Logger
+	offset   uint64
+	pageSize uint64
 }
 
 // newBlockStream creates a new block stream.
-// It takes a client of type Client, a logger of type hclog.Logger, and an offset of type uint64.
+// It takes a context that closes the stream when canceled, a client of type
+// Client, a logger of type hclog.Logger, and an offset of type uint64.
 // It returns a BlockStream instance.
-func newBlockStream(client Client, logger hclog.Logger, offset uint64) BlockStream {
-	api, err := instance(client)
+func newBlockStream(ctx context.Context, client Client, logger hclog.Logger, offset uint64) BlockStream {
+	c, err := endpoint(client)
 	if err != nil {
 		panic("unsupported client in newBlockStream()")
 	}
 
+	ctx, cancel := context.WithCancel(ctx)
+
 	bs := &blockStream{
-		closed:  new(atomic.Bool),
-		closeCh: make(chan struct{}),
-		dataCh:  make(chan *types.SignedBlock),
-		api:     api,
-		logger:  logger.Named("blockstream"),
-		offset:  offset,
+		ctx:      ctx,
+		cancel:   cancel,
+		dataCh:   make(chan *types.SignedBlock),
+		doneCh:   make(chan struct{}),
+		client:   c,
+		logger:   logger.Named("blockstream"),
+		offset:   offset,
+		pageSize: c.pageSize,
 	}
 
 	go bs.watch()
@@ -53,9 +62,7 @@ func newBlockStream(client Client, logger hclog.Logger, offset uint64) BlockStre
 
 // Close closes the block stream.
 func (bs *blockStream) Close() {
-	if bs.closed.CompareAndSwap(false, true) {
-		close(bs.closeCh)
-	}
+	bs.cancel()
 }
 
 // Chan returns the channel on which the signed blocks are received.
@@ -64,10 +71,24 @@ func (bs *blockStream) Chan() <-chan *types.SignedBlock {
 }
 
 // watch continuously watches for new blocks and sends them to the data channel.
+// The done channel is closed when watch returns, either because the stream was
+// closed or because the underlying endpoint failed irrecoverably. The data
+// channel is closed only when the stream was closed or its context canceled.
 func (bs *blockStream) watch() {
-	hdr, err := bs.api.RPC.Chain.GetHeaderLatest()
+	defer func() {
+		if bs.ctx.Err() != nil {
+			close(bs.dataCh)
+		}
+
+		close(bs.doneCh)
+	}()
+
+	hdr, err := bs.client.getHeaderLatest(bs.ctx)
 	if err != nil {
-		bs.logger.Error("couldn't fetch latest block hash", "error", err)
+		if bs.ctx.Err() == nil {
+			bs.logger.Error("couldn't fetch latest block hash", "error", err)
+		}
+
 		return
 	}
 
@@ -75,7 +96,10 @@ func (bs *blockStream) watch() {
 	if bs.offset > 0 {
 		err = bs.catchUp(bs.offset, uint64(hdr.Number))
 		if err != nil {
-			bs.logger.Error("unable to catch up!", "error", err)
+			if bs.ctx.Err() == nil {
+				bs.logger.Error("unable to catch up!", "error", err)
+			}
+
 			return
 		}
 	} else {
@@ -84,93 +108,130 @@ func (bs *blockStream) watch() {
 
 	latestBlockNumber := hdr.Number + 1
 	for {
-		subscription, err := bs.api.RPC.Chain.SubscribeNewHeads()
+		subscription, err := bs.client.subscribeNewHeads(bs.ctx)
 		if err != nil {
-			bs.logger.Error("failed to subscribe to new heads", "error", err)
+			if bs.ctx.Err() == nil {
+				bs.logger.Error("failed to subscribe to new heads", "error", err)
+			}
+
 			return
 		}
 
-	receiveBlocksLoop:
-		for {
-			var hdr types.Header
-			select {
-			case <-bs.closeCh:
-				close(bs.dataCh)
-				return
-
-			case hdr = <-subscription.Chan():
-				switch {
-				case hdr.Number < latestBlockNumber:
-					// Omit blocks that were already streamed
-					bs.logger.Debug("block already registered, skipping", "block_number", hdr.Number, "latestBlockNumber", latestBlockNumber)
-					continue
-				case hdr.Number > latestBlockNumber:
-					// Do we need to catch up the last processed block
-					// This can happen in two cases:
-					// 1) The connection was interrupted for a while
-					// 2) There was a delay when catching up with the offset
-					err = bs.catchUp(uint64(latestBlockNumber), uint64(hdr.Number))
-					if err != nil {
-						bs.logger.Error("unable to catch up!", "error", err)
-						return
-					}
-					latestBlockNumber = hdr.Number + 1
-					continue
-				}
+		latestBlockNumber, err = bs.receive(subscription, latestBlockNumber)
+		subscription.Unsubscribe()
 
-				blockHash, err := bs.api.RPC.Chain.GetBlockHash(uint64(hdr.Number))
-				if err != nil {
-					bs.logger.Error("couldn't fetch block hash for block", "block_number", hdr.Number, "error", err)
-					continue
-				}
+		if err != nil {
+			if bs.ctx.Err() == nil {
+				bs.logger.Error("unable to catch up!", "error", err)
+			}
 
-				bs.logger.Info("Received new avail block", "nbr", hdr.Number, "hash", blockHash.Hex())
+			return
+		}
+	}
+}
 
-				blk, err := bs.api.RPC.Chain.GetBlock(blockHash)
+// receive streams the blocks announced by the new heads subscription, starting
+// from the given block number. It returns the next expected block number when
+// the subscription fails and must be restarted, or an error when the stream
+// must stop.
+func (bs *blockStream) receive(subscription *headSubscription, latestBlockNumber types.BlockNumber) (types.BlockNumber, error) {
+	for {
+		var hdr types.Header
+		select {
+		case <-bs.ctx.Done():
+			return latestBlockNumber, bs.ctx.Err()
+
+		case hdr = <-subscription.Chan():
+			switch {
+			case hdr.Number < latestBlockNumber:
+				// Omit blocks that were already streamed
+				bs.logger.Debug("block already registered, skipping", "block_number", hdr.Number, "latestBlockNumber", latestBlockNumber)
+				continue
+			case hdr.Number > latestBlockNumber:
+				// Do we need to catch up the last processed block
+				// This can happen in two cases:
+				// 1) The connection was interrupted for a while
+				// 2) There was a delay when catching up with the offset
+				err := bs.catchUp(uint64(latestBlockNumber), uint64(hdr.Number))
 				if err != nil {
-					bs.logger.Error("couldn't fetch block", "block_number", hdr.Number, "block_hash", blockHash, "error", err)
-					continue
+					return latestBlockNumber, err
 				}
+				latestBlockNumber = hdr.Number + 1
+				continue
+			}
 
-				select {
-				case <-bs.closeCh:
-					close(bs.dataCh)
-					return
-				case bs.dataCh <- blk:
-					latestBlockNumber = hdr.Number + 1
-				}
+			blockHash, err := bs.client.getBlockHash(bs.ctx, uint64(hdr.Number))
+			if err != nil {
+				bs.logger.Error("couldn't fetch block hash for block", "block_number", hdr.Number, "error", err)
+				continue
+			}
+
+			bs.logger.Info("Received new avail block", "nbr", hdr.Number, "hash", blockHash.Hex())
 
-			case err = <-subscription.Err():
-				bs.logger.Error("error in Avail's new heads subscription; restarting", "error", err)
-				break receiveBlocksLoop
+			blk, err := bs.client.getBlock(bs.ctx, blockHash)
+			if err != nil {
+				bs.logger.Error("couldn't fetch block", "block_number", hdr.Number, "block_hash", blockHash, "error", err)
+				continue
 			}
+
+			select {
+			case <-bs.ctx.Done():
+				return latestBlockNumber, bs.ctx.Err()
+			case bs.dataCh <- blk:
+				latestBlockNumber = hdr.Number + 1
+				observeStreamHeight(uint64(hdr.Number), uint64(hdr.Number))
+			}
+
+		case err := <-subscription.Err():
+			bs.logger.Error("error in Avail's new heads subscription; restarting", "error", err)
+			return latestBlockNumber, nil
 		}
 	}
 }
 
 // catchUp catches up the blocks from the given offset to the given target offset.
-// It returns an error if the catch-up fails.
-func (bs *blockStream) catchUp(fromOffset, toOffset uint64) (err error) {
-	// Have we reached the HEAD?
-	for i := fromOffset; i <= toOffset; i++ {
-		blockHash, err := bs.api.RPC.Chain.GetBlockHash(i)
-		if err != nil {
-			bs.logger.Error("couldn't fetch block hash for block", "block_number", i, "error", err)
-			continue
+// The historical blocks are fetched in pages; a page that fails to be fetched
+// is retried up to maxCatchUpRetries times before giving up, so that no block
+// is silently skipped.
+// It returns an error if the catch-up fails or the stream is closed.
+func (bs *blockStream) catchUp(fromOffset, toOffset uint64) error {
+	for start := fromOffset; start <= toOffset; start += bs.pageSize {
+		end := start + bs.pageSize - 1
+		if end > toOffset {
+			end = toOffset
+		}
+
+		var (
+			page []*types.SignedBlock
+			err  error
+		)
+
+		for attempt := 0; attempt < maxCatchUpRetries && bs.ctx.Err() == nil; attempt++ {
+			page, err = queryPage(bs.ctx, bs.client, start, end)
+			if err == nil {
+				break
+			}
+
+			bs.logger.Error("couldn't fetch historical blocks", "from", start, "to", end, "attempt", attempt+1, "error", err)
+		}
+
+		if err := bs.ctx.Err(); err != nil {
+			return err
 		}
 
-		blk, err := bs.api.RPC.Chain.GetBlock(blockHash)
 		if err != nil {
-			bs.logger.Error("couldn't fetch block", "block_number", i, "block_hash", blockHash, "error", err)
-			continue
+			return fmt.Errorf("fetching blocks %d-%d: %w", start, end, err)
 		}
 
-		select {
-		case <-bs.closeCh:
-			close(bs.dataCh)
-			return fmt.Errorf("stream closed")
-		case bs.dataCh <- blk:
+		for _, blk := range page {
+			select {
+			case <-bs.ctx.Done():
+				return bs.ctx.Err()
+			case bs.dataCh <- blk:
+				observeStreamHeight(toOffset, uint64(blk.Block.Header.Number))
+			}
 		}
 	}
+
 	return nil
 }
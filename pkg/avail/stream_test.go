@@ -1,6 +1,7 @@
 package avail
 
 import (
+	"context"
 	"testing"
 	"time"
 
@@ -18,7 +19,7 @@ func TestStreamBlocksCorrectSequence(t *testing.T) {
 	}
 	logger := hclog.New(&hclog.LoggerOptions{Name: "polygon", Level: hclog.Off})
 
-	bc := newBlockStream(availClient, logger, uint64(offset))
+	bc := newBlockStream(context.Background(), availClient, logger, uint64(offset))
 
 	timeout := time.After(20 * time.Second)
 	var blockSeq []uint64
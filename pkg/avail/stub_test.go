@@ -0,0 +1,504 @@
+package avail
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/signature"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types/codec"
+	"github.com/gorilla/websocket"
+)
+
+// stubChain is a minimal, in-memory Avail chain shared by stub endpoints.
+type stubChain struct {
+	lock      sync.RWMutex
+	headers   []types.Header
+	exts      map[uint64][]types.Extrinsic
+	runtimes  []stubRuntime
+	endpoints []*stubEndpoint
+	closeCh   chan struct{}
+
+	// finalityLag is the number of blocks the finalized head trails the head
+	// by.
+	finalityLag uint64
+}
+
+// newStubChain creates a stub chain with `n` blocks on top of genesis and
+// produces a new block every `interval`.
+func newStubChain(t *testing.T, n int, interval time.Duration) *stubChain {
+	t.Helper()
+
+	c := &stubChain{
+		closeCh:  make(chan struct{}),
+		exts:     make(map[uint64][]types.Extrinsic),
+		runtimes: []stubRuntime{{specVersion: 1, metadata: types.MetadataV14Data}},
+	}
+	for i := 0; i <= n; i++ {
+		c.headers = append(c.headers, types.Header{Number: types.BlockNumber(i)})
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-c.closeCh:
+				return
+			case <-ticker.C:
+				c.produce()
+			}
+		}
+	}()
+
+	t.Cleanup(func() { close(c.closeCh) })
+
+	return c
+}
+
+// produce appends a new block and notifies the subscribers of all endpoints.
+func (c *stubChain) produce() {
+	c.produceWith()
+}
+
+// produceWith appends a new block with the given extrinsics and notifies the
+// subscribers of all endpoints.
+func (c *stubChain) produceWith(exts ...types.Extrinsic) {
+	c.lock.Lock()
+	hdr := types.Header{
+		ParentHash: stubHash(uint64(len(c.headers) - 1)),
+		Number:     types.BlockNumber(len(c.headers)),
+	}
+	c.headers = append(c.headers, hdr)
+	c.exts[uint64(hdr.Number)] = exts
+	endpoints := append([]*stubEndpoint{}, c.endpoints...)
+	c.lock.Unlock()
+
+	for _, e := range endpoints {
+		e.notify(hdr)
+	}
+}
+
+// header returns the header at the given height.
+func (c *stubChain) header(n uint64) (types.Header, bool) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	if n >= uint64(len(c.headers)) {
+		return types.Header{}, false
+	}
+
+	return c.headers[n], true
+}
+
+// extrinsics returns the extrinsics of the block at the given height.
+func (c *stubChain) extrinsics(n uint64) []types.Extrinsic {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.exts[n]
+}
+
+// head returns the latest header.
+func (c *stubChain) head() types.Header {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.headers[len(c.headers)-1]
+}
+
+// finalized returns the height of the finalized head.
+func (c *stubChain) finalized() uint64 {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	head := uint64(len(c.headers) - 1)
+	if head < c.finalityLag {
+		return 0
+	}
+
+	return head - c.finalityLag
+}
+
+// stubRuntime is a runtime of the stub chain, active from the given height on.
+type stubRuntime struct {
+	from        uint64
+	specVersion uint32
+	metadata    string
+}
+
+// upgrade activates a new runtime with the given hex encoded metadata from the next block on.
+func (c *stubChain) upgrade(specVersion uint32, metadata string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.runtimes = append(c.runtimes, stubRuntime{
+		from:        uint64(len(c.headers)),
+		specVersion: specVersion,
+		metadata:    metadata,
+	})
+}
+
+// runtime returns the runtime active at the given height.
+func (c *stubChain) runtime(n uint64) stubRuntime {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	rt := c.runtimes[0]
+	for _, r := range c.runtimes {
+		if r.from <= n {
+			rt = r
+		}
+	}
+
+	return rt
+}
+
+// stubHash derives a block hash from the block number.
+func stubHash(n uint64) types.Hash {
+	var h types.Hash
+	binary.BigEndian.PutUint64(h[24:], n+1)
+	return h
+}
+
+// stubNumber is the inverse of stubHash.
+func stubNumber(h types.Hash) uint64 {
+	return binary.BigEndian.Uint64(h[24:]) - 1
+}
+
+type stubRequest struct {
+	ID     json.RawMessage   `json:"id"`
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+}
+
+type stubConn struct {
+	lock  sync.Mutex
+	ws    *websocket.Conn
+	subID string
+}
+
+func (sc *stubConn) write(v interface{}) error {
+	sc.lock.Lock()
+	defer sc.lock.Unlock()
+
+	return sc.ws.WriteJSON(v)
+}
+
+// stubEndpoint serves the subset of the Avail JSON-RPC API used by the block
+// stream over a websocket.
+type stubEndpoint struct {
+	chain *stubChain
+	srv   *httptest.Server
+	URL   string
+
+	lock      sync.Mutex
+	conns     map[*stubConn]struct{}
+	submitErr string
+	signerURI string
+	submitted []types.Extrinsic
+	hung      bool
+}
+
+// newStubEndpoint starts a new stub endpoint serving the given chain.
+func newStubEndpoint(t *testing.T, chain *stubChain) *stubEndpoint {
+	t.Helper()
+
+	e := &stubEndpoint{
+		chain: chain,
+		conns: make(map[*stubConn]struct{}),
+	}
+
+	e.srv = httptest.NewServer(http.HandlerFunc(e.serve))
+	e.URL = "ws" + strings.TrimPrefix(e.srv.URL, "http")
+
+	chain.lock.Lock()
+	chain.endpoints = append(chain.endpoints, e)
+	chain.lock.Unlock()
+
+	t.Cleanup(e.Kill)
+
+	return e
+}
+
+// Kill stops the endpoint and drops all of its client connections.
+func (e *stubEndpoint) Kill() {
+	e.lock.Lock()
+	for c := range e.conns {
+		c.ws.Close()
+		delete(e.conns, c)
+	}
+	e.lock.Unlock()
+
+	e.srv.CloseClientConnections()
+	e.srv.Close()
+}
+
+func (e *stubEndpoint) serve(w http.ResponseWriter, r *http.Request) {
+	upgrader := websocket.Upgrader{CheckOrigin: func(*http.Request) bool { return true }}
+
+	ws, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+
+	c := &stubConn{ws: ws}
+
+	e.lock.Lock()
+	e.conns[c] = struct{}{}
+	e.lock.Unlock()
+
+	defer func() {
+		e.lock.Lock()
+		delete(e.conns, c)
+		e.lock.Unlock()
+		ws.Close()
+	}()
+
+	for {
+		var req stubRequest
+		if err := ws.ReadJSON(&req); err != nil {
+			return
+		}
+
+		e.lock.Lock()
+		hung := e.hung
+		e.lock.Unlock()
+
+		if hung {
+			continue
+		}
+
+		result, err := e.handle(c, req)
+
+		resp := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+		if err != nil {
+			resp["error"] = map[string]interface{}{"code": -32000, "message": err.Error()}
+		} else {
+			resp["result"] = result
+		}
+
+		if err := c.write(resp); err != nil {
+			return
+		}
+	}
+}
+
+func (e *stubEndpoint) handle(c *stubConn, req stubRequest) (interface{}, error) {
+	switch req.Method {
+	case "state_getMetadata":
+		return e.runtimeAt(req).metadata, nil
+
+	case "state_getRuntimeVersion":
+		return types.RuntimeVersion{
+			SpecName:           "stub",
+			SpecVersion:        types.U32(e.runtimeAt(req).specVersion),
+			TransactionVersion: 1,
+		}, nil
+
+	case "chain_getBlockHash":
+		if len(req.Params) == 0 {
+			return stubHash(uint64(e.chain.head().Number)), nil
+		}
+
+		var n uint64
+		if err := json.Unmarshal(req.Params[0], &n); err != nil {
+			return nil, err
+		}
+
+		if _, ok := e.chain.header(n); !ok {
+			return nil, fmt.Errorf("unknown block %d", n)
+		}
+
+		return stubHash(n), nil
+
+	case "chain_getFinalizedHead":
+		return stubHash(e.chain.finalized()), nil
+
+	case "chain_getHeader":
+		if len(req.Params) == 0 {
+			return e.chain.head(), nil
+		}
+
+		var h types.Hash
+		if err := json.Unmarshal(req.Params[0], &h); err != nil {
+			return nil, err
+		}
+
+		hdr, ok := e.chain.header(stubNumber(h))
+		if !ok {
+			return nil, fmt.Errorf("unknown block %s", h.Hex())
+		}
+
+		return hdr, nil
+
+	case "chain_getBlock":
+		var h types.Hash
+		if err := json.Unmarshal(req.Params[0], &h); err != nil {
+			return nil, err
+		}
+
+		hdr, ok := e.chain.header(stubNumber(h))
+		if !ok {
+			return nil, fmt.Errorf("unknown block %s", h.Hex())
+		}
+
+		return types.SignedBlock{Block: types.Block{Header: hdr, Extrinsics: e.chain.extrinsics(uint64(hdr.Number))}}, nil
+
+	case "state_getStorage":
+		return codec.EncodeToHex(types.AccountInfo{Nonce: 0})
+
+	case "author_submitExtrinsic":
+		var ext types.Extrinsic
+		if err := json.Unmarshal(req.Params[0], &ext); err != nil {
+			return nil, err
+		}
+
+		e.lock.Lock()
+		defer e.lock.Unlock()
+
+		if e.submitErr != "" {
+			return nil, errors.New(e.submitErr)
+		}
+
+		if e.signerURI != "" && !e.validSignature(ext) {
+			return nil, errors.New("1010: Invalid Transaction: Transaction has a bad signature")
+		}
+
+		e.submitted = append(e.submitted, ext)
+
+		return types.Hash{}, nil
+
+	case "chain_subscribeNewHead":
+		e.lock.Lock()
+		c.subID = fmt.Sprintf("sub-%p", c)
+		e.lock.Unlock()
+
+		return c.subID, nil
+
+	case "chain_unsubscribeNewHead":
+		e.lock.Lock()
+		c.subID = ""
+		e.lock.Unlock()
+
+		return true, nil
+	}
+
+	return nil, fmt.Errorf("method %q not supported", req.Method)
+}
+
+// rejectSubmissions makes the endpoint reject the submitted extrinsics with
+// the given error message; an empty message accepts them again.
+func (e *stubEndpoint) rejectSubmissions(msg string) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.submitErr = msg
+}
+
+// verifySignatures makes the endpoint reject the submitted extrinsics that
+// aren't signed by the key with the given URI for the latest runtime.
+// Only immortal extrinsics are supported.
+func (e *stubEndpoint) verifySignatures(uri string) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.signerURI = uri
+}
+
+// validSignature reports whether the extrinsic is signed for the latest
+// runtime. It must be called with the lock held.
+func (e *stubEndpoint) validSignature(ext types.Extrinsic) bool {
+	mb, err := codec.Encode(ext.Method)
+	if err != nil {
+		return false
+	}
+
+	genesis := stubHash(0)
+	msg, err := codec.Encode(types.ExtrinsicPayloadV4{
+		ExtrinsicPayloadV3: types.ExtrinsicPayloadV3{
+			Method:      mb,
+			Era:         ext.Signature.Era,
+			Nonce:       ext.Signature.Nonce,
+			Tip:         ext.Signature.Tip,
+			SpecVersion: types.U32(e.chain.runtime(uint64(e.chain.head().Number)).specVersion),
+			GenesisHash: genesis,
+			BlockHash:   genesis,
+		},
+		TransactionVersion: 1,
+		AppID:              ext.Signature.AppID,
+	})
+	if err != nil {
+		return false
+	}
+
+	ok, err := signature.Verify(msg, ext.Signature.Signature.AsSr25519[:], e.signerURI)
+
+	return err == nil && ok
+}
+
+// hang makes the endpoint stop answering the requests while keeping the
+// connections open.
+func (e *stubEndpoint) hang() {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.hung = true
+}
+
+// subscribed reports whether any connection is subscribed to new heads.
+func (e *stubEndpoint) subscribed() bool {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	for c := range e.conns {
+		if c.subID != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// runtimeAt returns the runtime at the block given in the optional block hash
+// parameter of the request, or the latest one.
+func (e *stubEndpoint) runtimeAt(req stubRequest) stubRuntime {
+	n := uint64(e.chain.head().Number)
+
+	var h types.Hash
+	if len(req.Params) > 0 && json.Unmarshal(req.Params[0], &h) == nil {
+		n = stubNumber(h)
+	}
+
+	return e.chain.runtime(n)
+}
+
+// notify sends the new header to all subscribed connections.
+func (e *stubEndpoint) notify(hdr types.Header) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	for c := range e.conns {
+		if c.subID == "" {
+			continue
+		}
+
+		msg := map[string]interface{}{
+			"jsonrpc": "2.0",
+			"method":  "chain_newHead",
+			"params":  map[string]interface{}{"subscription": c.subID, "result": hdr},
+		}
+
+		// Errors surface on the reading side of the connection.
+		_ = c.write(msg)
+	}
+}
@@ -0,0 +1,640 @@
+// Package testutil provides an in-memory fake of Avail for deterministic tests.
+package testutil
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	edge_types "github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/pkg/avail"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types/codec"
+	"github.com/hashicorp/go-hclog"
+)
+
+// FakeOption configures the Fake.
+type FakeOption func(*Fake)
+
+// WithLatency delays the delivery of every Avail block to the block streams.
+func WithLatency(latency time.Duration) FakeOption {
+	return func(f *Fake) {
+		f.latency = latency
+	}
+}
+
+// WithQueryLatency delays the answer to every query, and the delivery of
+// every page of the given number of blocks to the block streams, as the round
+// trip of a page of Avail blocks fetched from a remote node.
+func WithQueryLatency(latency time.Duration, pageSize uint64) FakeOption {
+	return func(f *Fake) {
+		f.queryLatency, f.queryPageSize = latency, pageSize
+	}
+}
+
+// WithCallIndex sets the call index of CallSubmitData in the fake runtime.
+func WithCallIndex(callIdx types.CallIndex) FakeOption {
+	return func(f *Fake) {
+		f.callIdx = callIdx
+	}
+}
+
+// WithTxPool makes the submissions wait in the transaction pool for the next
+// call to Produce instead of getting an Avail block of their own.
+func WithTxPool() FakeOption {
+	return func(f *Fake) {
+		f.txPool = true
+	}
+}
+
+// Fake is an in-memory fake of Avail. It implements both avail.Client and
+// avail.Sender: every submission is included in a new synthetic Avail block,
+// or the next one produced with WithTxPool, which is then delivered to the
+// block streams of the fake.
+//
+// The zero value is not usable; use NewFake.
+type Fake struct {
+	appID   types.UCompact
+	callIdx types.CallIndex
+	latency time.Duration
+	txPool  bool
+
+	queryLatency  time.Duration
+	queryPageSize uint64
+
+	lock        sync.Mutex
+	blocks      []*types.SignedBlock
+	pool        []pooledExtrinsic
+	finalityLag uint64
+	drops       int
+	duplicate   bool
+	stalled     bool
+
+	// nonce is the nonce of the next submission, and included the number
+	// of the submissions included so far: the nonce of the account.
+	nonce    uint64
+	included uint64
+
+	// produced is closed and replaced whenever a block is produced.
+	produced chan struct{}
+}
+
+// pooledExtrinsic is a submission waiting in the transaction pool.
+type pooledExtrinsic struct {
+	ext types.Extrinsic
+
+	// included receives the inclusion of the extrinsic.
+	included chan inclusion
+}
+
+// inclusion is the position of an extrinsic in the chain.
+type inclusion struct {
+	number uint64
+	index  uint32
+}
+
+var (
+	_ avail.Client      = (*Fake)(nil)
+	_ avail.Sender      = (*Fake)(nil)
+	_ avail.BatchSender = (*Fake)(nil)
+)
+
+// NewFake creates a new Fake, holding the genesis block only, that submits
+// the blocks with the given AppID.
+func NewFake(appID types.UCompact, opts ...FakeOption) *Fake {
+	f := &Fake{
+		appID:    appID,
+		callIdx:  avail.FallbackSubmitDataCallIndex,
+		produced: make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(f)
+	}
+
+	f.blocks = []*types.SignedBlock{newBlock(0, types.Hash{})}
+
+	return f
+}
+
+// newBlock creates an empty Avail block with a hash derived from its number.
+func newBlock(number uint64, parent types.Hash) *types.SignedBlock {
+	return &types.SignedBlock{
+		Block: types.Block{
+			Header: types.Header{
+				ParentHash: parent,
+				Number:     types.BlockNumber(number),
+			},
+		},
+	}
+}
+
+// blockHash returns the hash of the Avail block at the given height.
+func blockHash(number uint64) types.Hash {
+	var h types.Hash
+	h[0] = 0xfa
+	binary.BigEndian.PutUint64(h[24:], number)
+
+	return h
+}
+
+// DropSubmissions makes Avail drop the next n submissions.
+func (f *Fake) DropSubmissions(n int) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.drops = n
+}
+
+// DelayFinality makes Avail finalize the blocks only once the given number
+// of blocks has been built on top of them. Zero finalizes every block as
+// soon as it's produced.
+func (f *Fake) DelayFinality(blocks uint64) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.finalityLag = blocks
+	f.notifyLocked()
+}
+
+// DuplicateDelivery makes the block streams deliver every Avail block twice.
+func (f *Fake) DuplicateDelivery(enabled bool) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.duplicate = enabled
+}
+
+// StallDelivery makes the block streams hold the Avail blocks back, as on a
+// congested Avail, until it's disabled again; the submissions are included
+// nonetheless.
+func (f *Fake) StallDelivery(stalled bool) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.stalled = stalled
+	f.notifyLocked()
+}
+
+// SubmitDataCallIndex returns the call index of CallSubmitData in the fake runtime.
+func (f *Fake) SubmitDataCallIndex() types.CallIndex {
+	return f.callIdx
+}
+
+// Produce appends a new Avail block, with the given extrinsics followed by
+// the ones in the transaction pool, to the chain.
+func (f *Fake) Produce(exts ...types.Extrinsic) *types.SignedBlock {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	return f.produceLocked(exts...)
+}
+
+// produceLocked is Produce that must be called with the lock held.
+func (f *Fake) produceLocked(exts ...types.Extrinsic) *types.SignedBlock {
+	number := uint64(len(f.blocks))
+
+	blk := newBlock(number, blockHash(number-1))
+	blk.Block.Extrinsics = exts
+
+	for _, p := range f.pool {
+		p.included <- inclusion{number: number, index: uint32(len(blk.Block.Extrinsics))}
+		blk.Block.Extrinsics = append(blk.Block.Extrinsics, p.ext)
+	}
+
+	f.included += uint64(len(f.pool))
+	f.pool = nil
+	f.blocks = append(f.blocks, blk)
+
+	f.notifyLocked()
+
+	return blk
+}
+
+// Pending returns the number of submissions waiting in the transaction pool.
+func (f *Fake) Pending() int {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	return len(f.pool)
+}
+
+// notifyLocked wakes up everyone waiting for a change of the chain.
+func (f *Fake) notifyLocked() {
+	close(f.produced)
+	f.produced = make(chan struct{})
+}
+
+// Head returns the number of the latest Avail block.
+func (f *Fake) Head() uint64 {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	return uint64(len(f.blocks) - 1)
+}
+
+// Finalized returns the number of the latest finalized Avail block.
+func (f *Fake) Finalized() uint64 {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	return f.finalizedLocked()
+}
+
+// GetFinalizedHead returns the number of the latest finalized Avail block.
+func (f *Fake) GetFinalizedHead(ctx context.Context) (uint64, error) {
+	return f.Finalized(), nil
+}
+
+// GetBlockHash returns the hash of the Avail block at the given height.
+func (f *Fake) GetBlockHash(ctx context.Context, number uint64) (types.Hash, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if number >= uint64(len(f.blocks)) {
+		return types.Hash{}, fmt.Errorf("Avail block %d not found", number)
+	}
+
+	return blockHash(number), nil
+}
+
+// finalizedLocked is Finalized that must be called with the lock held.
+func (f *Fake) finalizedLocked() uint64 {
+	head := uint64(len(f.blocks) - 1)
+	if head < f.finalityLag {
+		return 0
+	}
+
+	return head - f.finalityLag
+}
+
+// Block returns the Avail block at the given height.
+func (f *Fake) Block(number uint64) (*types.SignedBlock, bool) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if number >= uint64(len(f.blocks)) {
+		return nil, false
+	}
+
+	return f.blocks[number], true
+}
+
+// EdgeBlocks decodes all the edge blocks included in the chain so far.
+func (f *Fake) EdgeBlocks() ([]*edge_types.Block, error) {
+	f.lock.Lock()
+	blks := f.blocks
+	f.lock.Unlock()
+
+	var edgeBlks []*edge_types.Block
+
+	for _, blk := range blks {
+		decoded, err := avail.BlockFromAvail(blk, f.appID, f.callIdx, hclog.NewNullLogger())
+		if err != nil {
+			if errors.Is(err, avail.ErrNoExtrinsicFound) {
+				continue
+			}
+
+			return nil, err
+		}
+
+		for _, b := range decoded {
+			edgeBlks = append(edgeBlks, b.Block)
+		}
+	}
+
+	return edgeBlks, nil
+}
+
+// AccountNonce returns the nonce of the next submission to be included, as
+// the nonce of the Avail account would be; every submission shares it.
+func (f *Fake) AccountNonce(ctx context.Context, publicKey []byte) (uint64, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	return f.included, nil
+}
+
+// Send submits the block to the fake Avail. The submit options are ignored,
+// but for the hook of avail.WithSubmitted.
+func (f *Fake) Send(ctx context.Context, blk *edge_types.Block, opts ...avail.SubmitOption) error {
+	_, err := f.SendAndWaitForStatus(ctx, blk, types.ExtrinsicStatus{IsReady: true}, opts...)
+
+	return err
+}
+
+// SendAndWaitForStatus submits the block to the fake Avail, which includes it
+// in a new Avail block right away, or the next one produced with WithTxPool,
+// and waits for the given status. Dropped submissions fail with
+// avail.ErrExtrinsicDropped unless waiting for the IsReady status only.
+// The submit options are ignored, but for the hook of avail.WithSubmitted:
+// the extrinsics of the fake are immortal.
+func (f *Fake) SendAndWaitForStatus(ctx context.Context, blk *edge_types.Block, status types.ExtrinsicStatus, opts ...avail.SubmitOption) (avail.SubmitResult, error) {
+	ext, err := f.extrinsic(avail.Blob{Magic: avail.BlobMagic, Data: blk.MarshalRLP()})
+	if err != nil {
+		return avail.SubmitResult{}, err
+	}
+
+	return f.submit(ctx, ext, status, fmt.Sprintf("edge block %d", blk.Number()), avail.SubmittedHook(opts...))
+}
+
+// SendBatchAndWaitForStatus submits the blocks to the fake Avail as a single
+// avail.BlobBatch, the same way SendAndWaitForStatus does.
+func (f *Fake) SendBatchAndWaitForStatus(ctx context.Context, blks []*edge_types.Block, status types.ExtrinsicStatus, opts ...avail.SubmitOption) (avail.SubmitResult, error) {
+	batch := &avail.BlobBatch{}
+	for _, blk := range blks {
+		batch.Blocks = append(batch.Blocks, blk.MarshalRLP())
+	}
+
+	ext, err := f.extrinsic(batch)
+	if err != nil {
+		return avail.SubmitResult{}, err
+	}
+
+	return f.submit(ctx, ext, status, fmt.Sprintf("batch of %d edge blocks", len(blks)), avail.SubmittedHook(opts...))
+}
+
+// submit includes the extrinsic in the chain and waits for the given status;
+// the hook, if any, is called once the extrinsic is in the pool.
+func (f *Fake) submit(ctx context.Context, ext types.Extrinsic, status types.ExtrinsicStatus, desc string, submitted func(avail.Submission)) (avail.SubmitResult, error) {
+	if err := ctx.Err(); err != nil {
+		return avail.SubmitResult{}, err
+	}
+
+	f.lock.Lock()
+
+	if f.drops > 0 {
+		f.drops--
+		f.lock.Unlock()
+
+		if status.IsReady {
+			return avail.SubmitResult{}, nil
+		}
+
+		return avail.SubmitResult{}, fmt.Errorf("%w: %s", avail.ErrExtrinsicDropped, desc)
+	}
+
+	sub := avail.Submission{Nonce: f.nonce, Birth: uint64(len(f.blocks) - 1)}
+	f.nonce++
+
+	included := make(chan inclusion, 1)
+	f.pool = append(f.pool, pooledExtrinsic{ext: ext, included: included})
+
+	if !f.txPool {
+		f.produceLocked()
+	}
+
+	f.lock.Unlock()
+
+	if submitted != nil {
+		submitted(sub)
+	}
+
+	if status.IsReady {
+		return avail.SubmitResult{}, nil
+	}
+
+	var in inclusion
+	select {
+	case in = <-included:
+	case <-ctx.Done():
+		return avail.SubmitResult{}, ctx.Err()
+	}
+
+	dataHash, err := avail.DataHash(ext)
+	if err != nil {
+		return avail.SubmitResult{}, err
+	}
+
+	res := avail.SubmitResult{
+		BlockNumber:    in.number,
+		BlockHash:      blockHash(in.number),
+		ExtrinsicIndex: in.index,
+		DataHash:       dataHash,
+	}
+
+	if status.IsFinalized {
+		if err := f.waitFinalized(ctx, in.number); err != nil {
+			return avail.SubmitResult{}, err
+		}
+
+		res.Finalized = true
+	}
+
+	return res, nil
+}
+
+// extrinsic builds the `submit_data` extrinsic carrying the encoded blob.
+func (f *Fake) extrinsic(blob interface{}) (types.Extrinsic, error) {
+	payload, err := codec.Encode(blob)
+	if err != nil {
+		return types.Extrinsic{}, err
+	}
+
+	args, err := codec.Encode(payload)
+	if err != nil {
+		return types.Extrinsic{}, err
+	}
+
+	ext := types.NewExtrinsic(types.Call{CallIndex: f.callIdx, Args: args})
+	ext.Signature.AppID = f.appID
+
+	return ext, nil
+}
+
+// waitFinalized waits until the Avail block at the given height is finalized.
+func (f *Fake) waitFinalized(ctx context.Context, number uint64) error {
+	for {
+		f.lock.Lock()
+		finalized, produced := f.finalizedLocked(), f.produced
+		f.lock.Unlock()
+
+		if finalized >= number {
+			return nil
+		}
+
+		select {
+		case <-produced:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// BlockStream streams the Avail blocks starting from the given height, or
+// the ones produced from now on when the offset is 0.
+func (f *Fake) BlockStream(ctx context.Context, offset uint64) avail.BlockStream {
+	ctx, cancel := context.WithCancel(ctx)
+
+	if offset == 0 {
+		offset = f.Head() + 1
+	}
+
+	s := &blockStream{
+		ch:     make(chan *types.SignedBlock),
+		cancel: cancel,
+	}
+
+	go f.stream(ctx, s.ch, offset)
+
+	return s
+}
+
+// stream delivers the Avail blocks to the channel until the context is done.
+func (f *Fake) stream(ctx context.Context, ch chan<- *types.SignedBlock, next uint64) {
+	defer close(ch)
+
+	offset := next
+
+	for {
+		f.lock.Lock()
+		head, produced, duplicate, stalled := uint64(len(f.blocks)-1), f.produced, f.duplicate, f.stalled
+		f.lock.Unlock()
+
+		if next > head || stalled {
+			select {
+			case <-produced:
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		blk, _ := f.Block(next)
+
+		if f.latency > 0 {
+			select {
+			case <-time.After(f.latency):
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		if f.queryLatency > 0 && f.queryPageSize > 0 && (next-offset)%f.queryPageSize == 0 {
+			select {
+			case <-time.After(f.queryLatency):
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		deliveries := 1
+		if duplicate {
+			deliveries = 2
+		}
+
+		for i := 0; i < deliveries; i++ {
+			select {
+			case ch <- blk:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		next++
+	}
+}
+
+// GenesisHash returns the hash of the fake genesis block.
+func (f *Fake) GenesisHash() types.Hash {
+	return blockHash(0)
+}
+
+// GetLatestHeader returns the header of the latest Avail block.
+func (f *Fake) GetLatestHeader(ctx context.Context) (*types.Header, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	hdr := f.blocks[len(f.blocks)-1].Block.Header
+
+	return &hdr, nil
+}
+
+// Query returns the Avail blocks in the given height range, inclusive.
+func (f *Fake) Query(ctx context.Context, from, to uint64) ([]*types.SignedBlock, error) {
+	if f.queryLatency > 0 {
+		select {
+		case <-time.After(f.queryLatency):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if from > to || to >= uint64(len(f.blocks)) {
+		return nil, fmt.Errorf("invalid block range [%d, %d]", from, to)
+	}
+
+	blks := make([]*types.SignedBlock, 0, to-from+1)
+	blks = append(blks, f.blocks[from:to+1]...)
+
+	return blks, nil
+}
+
+// SearchBlock searches for a block the same way the Avail client does.
+func (f *Fake) SearchBlock(ctx context.Context, offset int64, searchFunc avail.SearchFunc) (*types.SignedBlock, error) {
+	if offset == 0 {
+		offset = int64(f.Head())
+	}
+
+	blk, ok := f.Block(uint64(offset))
+	if !ok {
+		return nil, fmt.Errorf("block %d not found", offset)
+	}
+
+	offset, _, err := searchFunc(blk)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		if offset == 0 {
+			return blk, nil
+		}
+
+		if offset < 0 && blk.Block.Header.Number <= 1 {
+			break
+		}
+
+		number := uint64(blk.Block.Header.Number) + uint64(offset)
+
+		blk, ok = f.Block(number)
+		if !ok {
+			return nil, fmt.Errorf("block %d not found", number)
+		}
+
+		var found bool
+
+		offset, found, err = searchFunc(blk)
+		if err != nil {
+			return nil, err
+		}
+
+		if found {
+			return blk, nil
+		}
+	}
+
+	return nil, fmt.Errorf("can't find block")
+}
+
+// blockStream is the avail.BlockStream of the Fake.
+type blockStream struct {
+	ch     chan *types.SignedBlock
+	cancel context.CancelFunc
+}
+
+// Chan returns the channel on which the Avail blocks are received.
+func (s *blockStream) Chan() <-chan *types.SignedBlock {
+	return s.ch
+}
+
+// Close closes the block stream.
+func (s *blockStream) Close() {
+	s.cancel()
+}
@@ -0,0 +1,249 @@
+package testutil
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	edge_types "github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/pkg/avail"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+var testAppID = types.NewUCompactFromUInt(7)
+
+func edgeBlock(number uint64) *edge_types.Block {
+	blk := &edge_types.Block{Header: &edge_types.Header{Number: number, Difficulty: 1}}
+	blk.Header.ComputeHash()
+
+	return blk
+}
+
+// receive returns the next block of the stream, failing the test on timeout.
+func receive(t *testing.T, bs avail.BlockStream) *types.SignedBlock {
+	t.Helper()
+
+	select {
+	case blk, ok := <-bs.Chan():
+		if !ok {
+			t.Fatal("block stream closed")
+		}
+
+		return blk
+	case <-time.After(5 * time.Second):
+		t.Fatal("no block received")
+	}
+
+	return nil
+}
+
+func TestFakeSubmissionsAreIncludedAndDecodable(t *testing.T) {
+	f := NewFake(testAppID)
+	ctx := context.Background()
+
+	res, err := f.SendAndWaitForStatus(ctx, edgeBlock(1), types.ExtrinsicStatus{IsInBlock: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, uint64(1), res.BlockNumber)
+	assert.Equal(t, uint64(1), f.Head())
+
+	if err := f.Send(ctx, edgeBlock(2)); err != nil {
+		t.Fatal(err)
+	}
+
+	blk, ok := f.Block(res.BlockNumber)
+	assert.True(t, ok)
+
+	// The blocks decode like the ones of Avail.
+	callIdx, err := avail.FindCallIndex(ctx, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, avail.FallbackSubmitDataCallIndex, callIdx)
+
+	decoded, err := avail.NewBlockDecoder(f, testAppID, hclog.NewNullLogger()).Decode(ctx, blk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, decoded, 1)
+	assert.Equal(t, edgeBlock(1).Hash(), decoded[0].Hash())
+
+	edgeBlks, err := f.EdgeBlocks()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, edgeBlks, 2)
+	assert.Equal(t, uint64(2), edgeBlks[1].Number())
+}
+
+func TestFakeBlockStream(t *testing.T) {
+	f := NewFake(testAppID, WithLatency(50*time.Millisecond))
+	ctx := context.Background()
+
+	f.Produce()
+	f.Produce()
+
+	// Historical blocks are streamed from the offset on.
+	bs := f.BlockStream(ctx, 1)
+	defer bs.Close()
+
+	// The stream from the head on only receives new blocks.
+	live := f.BlockStream(ctx, 0)
+
+	start := time.Now()
+	assert.Equal(t, types.BlockNumber(1), receive(t, bs).Block.Header.Number)
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+	assert.Equal(t, types.BlockNumber(2), receive(t, bs).Block.Header.Number)
+
+	if err := f.Send(ctx, edgeBlock(1)); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, types.BlockNumber(3), receive(t, bs).Block.Header.Number)
+	assert.Equal(t, types.BlockNumber(3), receive(t, live).Block.Header.Number)
+
+	live.Close()
+
+	for range live.Chan() {
+	}
+}
+
+func TestFakeDropSubmissions(t *testing.T) {
+	f := NewFake(testAppID)
+	ctx := context.Background()
+
+	f.DropSubmissions(1)
+
+	_, err := f.SendAndWaitForStatus(ctx, edgeBlock(1), types.ExtrinsicStatus{IsInBlock: true})
+	assert.True(t, errors.Is(err, avail.ErrExtrinsicDropped), "unexpected error: %v", err)
+	assert.Equal(t, uint64(0), f.Head())
+
+	// Only the given number of submissions is dropped.
+	_, err = f.SendAndWaitForStatus(ctx, edgeBlock(1), types.ExtrinsicStatus{IsInBlock: true})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), f.Head())
+}
+
+func TestFakeDelayFinality(t *testing.T) {
+	f := NewFake(testAppID)
+	f.DelayFinality(2)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := f.SendAndWaitForStatus(context.Background(), edgeBlock(1), types.ExtrinsicStatus{IsFinalized: true})
+		done <- err
+	}()
+
+	assert.Eventually(t, func() bool { return f.Head() == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	f.Produce()
+
+	select {
+	case <-done:
+		t.Fatal("block finalized too early")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	f.Produce()
+
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("block not finalized")
+	}
+
+	assert.Equal(t, uint64(1), f.Finalized())
+
+	// Waiting for finality honours the context.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := f.SendAndWaitForStatus(ctx, edgeBlock(2), types.ExtrinsicStatus{IsFinalized: true})
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), "unexpected error: %v", err)
+}
+
+func TestFakeDuplicateDelivery(t *testing.T) {
+	f := NewFake(testAppID)
+	f.DuplicateDelivery(true)
+
+	bs := f.BlockStream(context.Background(), 0)
+	defer bs.Close()
+
+	f.Produce()
+	f.Produce()
+
+	var numbers []types.BlockNumber
+	for i := 0; i < 4; i++ {
+		numbers = append(numbers, receive(t, bs).Block.Header.Number)
+	}
+
+	assert.Equal(t, []types.BlockNumber{1, 1, 2, 2}, numbers)
+}
+
+func TestFakeStallDelivery(t *testing.T) {
+	f := NewFake(testAppID)
+	f.StallDelivery(true)
+
+	bs := f.BlockStream(context.Background(), 0)
+	defer bs.Close()
+
+	// The submissions are included while the blocks are held back.
+	res, err := f.SendAndWaitForStatus(context.Background(), edgeBlock(1), types.ExtrinsicStatus{IsInBlock: true})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), res.BlockNumber)
+
+	select {
+	case blk := <-bs.Chan():
+		t.Fatalf("block %d delivered while stalled", blk.Block.Header.Number)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	f.StallDelivery(false)
+	assert.Equal(t, types.BlockNumber(1), receive(t, bs).Block.Header.Number)
+}
+
+func TestFakeQueryAndSearch(t *testing.T) {
+	f := NewFake(testAppID)
+	ctx := context.Background()
+
+	for i := 0; i < 5; i++ {
+		f.Produce()
+	}
+
+	blks, err := f.Query(ctx, 2, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, blks, 3)
+	assert.Equal(t, types.BlockNumber(2), blks[0].Block.Header.Number)
+
+	_, err = f.Query(ctx, 4, 6)
+	assert.Error(t, err)
+
+	hdr, err := f.GetLatestHeader(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, types.BlockNumber(5), hdr.Number)
+
+	// Search backwards from the head for block 2.
+	blk, err := f.SearchBlock(ctx, 0, func(blk *types.SignedBlock) (int64, bool, error) {
+		return -1, blk.Block.Header.Number == 2, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, types.BlockNumber(2), blk.Block.Header.Number)
+}
@@ -2,15 +2,21 @@ package avail
 
 import (
 	"bytes"
+	"context"
 	"log"
 
-	gsrpc "github.com/centrifuge/go-substrate-rpc-client/v4"
-	"github.com/centrifuge/go-substrate-rpc-client/v4/rpc/chain"
+	edge_types "github.com/0xPolygon/polygon-edge/types"
 	"github.com/centrifuge/go-substrate-rpc-client/v4/scale"
 	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
 	"github.com/centrifuge/go-substrate-rpc-client/v4/types/codec"
+	lru "github.com/hashicorp/golang-lru"
 )
 
+// DefaultDedupWindowSize is the number of the most recent Avail blocks, and
+// of the edge blocks embedded in them, remembered by the watcher to suppress
+// the blocks replayed on reconnection.
+const DefaultDedupWindowSize = 256
+
 // BlockDataHandler is an interface for handling Avail block data.
 type BlockDataHandler interface {
 	// HandleData is called when block data is received.
@@ -20,130 +26,363 @@ type BlockDataHandler interface {
 	HandleError(err error)
 }
 
+// WatcherOption configures the BlockDataWatcher.
+type WatcherOption func(*BlockDataWatcher)
+
+// WithCursorStore makes the watcher persist the last fully processed Avail
+// block to the store, and resume right after it when started again.
+func WithCursorStore(store CursorStore) WatcherOption {
+	return func(bw *BlockDataWatcher) {
+		bw.cursors = store
+	}
+}
+
+// WithGenesisOffset sets the Avail block the watcher starts from when there
+// is no persisted cursor yet; 0 starts from the blocks produced from now on.
+func WithGenesisOffset(offset uint64) WatcherOption {
+	return func(bw *BlockDataWatcher) {
+		bw.genesisOffset = offset
+	}
+}
+
+// WithReorgRewind sets the number of Avail blocks the watcher goes back when
+// the persisted cursor is no longer on the chain.
+func WithReorgRewind(blocks uint64) WatcherOption {
+	return func(bw *BlockDataWatcher) {
+		bw.reorgRewind = blocks
+	}
+}
+
 // BlockDataWatcher watches for new Avail blocks and filters extrinsics with embedded `Blob` data.
 // It invokes the handler with the decoded `Blob`.
 type BlockDataWatcher struct {
 	appID   types.UCompact
 	client  Client
 	handler BlockDataHandler
+	chunks  *Reassembler
+	dedup   *dedupWindow
 	stop    chan struct{}
+
+	cursors       CursorStore
+	cursor        Cursor
+	genesisOffset uint64
+	reorgRewind   uint64
 }
 
 // NewBlockDataWatcher creates and starts a new BlockDataWatcher.
-// It takes a client of type Client, an appID of type types.UCompact, and a handler of type BlockDataHandler.
+// It takes a client of type Client, an appID of type types.UCompact, a handler of type BlockDataHandler
+// and the options of the watcher.
 // It returns a pointer to the BlockDataWatcher instance and an error if any.
-func NewBlockDataWatcher(client Client, appID types.UCompact, handler BlockDataHandler) (*BlockDataWatcher, error) {
+func NewBlockDataWatcher(client Client, appID types.UCompact, handler BlockDataHandler, opts ...WatcherOption) (*BlockDataWatcher, error) {
+	dedup, err := newDedupWindow(DefaultDedupWindowSize)
+	if err != nil {
+		return nil, err
+	}
+
 	watcher := BlockDataWatcher{
-		appID:   appID,
-		client:  client,
-		handler: handler,
-		stop:    make(chan struct{}),
+		appID:       appID,
+		client:      client,
+		handler:     handler,
+		chunks:      NewReassembler(DefaultChunkSetTimeout, clientLogger(client)),
+		dedup:       dedup,
+		stop:        make(chan struct{}),
+		reorgRewind: DefaultReorgRewind,
 	}
+
+	for _, opt := range opts {
+		opt(&watcher)
+	}
+
 	return &watcher, nil
 }
 
 // Start starts the BlockDataWatcher and begins processing blocks.
+// With a cursor store, the watcher first catches up with the Avail blocks
+// produced since the persisted cursor, or since the genesis offset on the
+// first run.
+// The watcher stops when the context is canceled or Stop is called.
 // It returns an error if the watcher fails to start.
-func (bw *BlockDataWatcher) Start() error {
-	api, err := instance(bw.client)
+func (bw *BlockDataWatcher) Start(ctx context.Context) error {
+	c, err := endpoint(bw.client)
 	if err != nil {
 		return err
 	}
 
-	meta, err := api.RPC.State.GetMetadataLatest()
+	callIdx, err := CallIndexResolverFor(bw.client).Latest(ctx)
 	if err != nil {
 		return err
 	}
 
-	callIdx, err := meta.FindCallIndex(CallSubmitData)
+	next, err := bw.resumeFrom(ctx, c)
 	if err != nil {
 		return err
 	}
 
-	sub, err := api.RPC.Chain.SubscribeNewHeads()
+	// Subscribe before catching up, so that no block falls in between.
+	sub, err := c.subscribeNewHeads(ctx)
 	if err != nil {
 		return err
 	}
 
-	go bw.processBlocks(api, callIdx, sub)
+	go bw.processBlocks(ctx, c, callIdx, next, sub)
 
 	return nil
 }
 
-// processBlocks listens for new block heads and filters extrinsics with embedded `Blob` data.
-// It invokes the handler with the decoded `Blob` data.
-func (bw *BlockDataWatcher) processBlocks(api *gsrpc.SubstrateAPI, callIdx types.CallIndex, sub *chain.NewHeadsSubscription) {
+// resumeFrom returns the number of the first Avail block to process, or 0
+// to process only the blocks produced from now on.
+func (bw *BlockDataWatcher) resumeFrom(ctx context.Context, c *client) (uint64, error) {
+	if bw.cursors == nil {
+		return bw.genesisOffset, nil
+	}
+
+	cursor, ok, err := bw.cursors.Load()
+	if err != nil {
+		return 0, err
+	}
+
+	if !ok {
+		return bw.genesisOffset, nil
+	}
+
+	blockHash, err := c.getBlockHash(ctx, cursor.Number)
+	if err != nil {
+		return 0, err
+	}
+
+	if blockHash == cursor.Hash {
+		bw.cursor = cursor
+		return cursor.Number + 1, nil
+	}
+
+	// The cursor block was reorged out of the chain before its finality;
+	// reprocess the blocks since before the fork.
+	rewind := bw.reorgRewind
+	if rewind > cursor.Number {
+		rewind = cursor.Number
+	}
+
+	next := cursor.Number + 1 - rewind
+	if next == 0 {
+		next = 1
+	}
+
+	log.Printf("block %d: persisted cursor hash %s doesn't match the chain (%s), rewinding to block %d", cursor.Number, cursor.Hash.Hex(), blockHash.Hex(), next)
+
+	bw.cursor = Cursor{Number: next - 1}
+
+	return next, nil
+}
+
+// processBlocks catches up with the Avail blocks from the given height on,
+// if any, and then listens for new block heads. Blocks skipped by the head
+// subscription are filled in.
+func (bw *BlockDataWatcher) processBlocks(ctx context.Context, c *client, callIdx types.CallIndex, next uint64, sub *headSubscription) {
 	defer sub.Unsubscribe()
 
-	for {
-		select {
-		case head := <-sub.Chan():
-			blockHash, err := api.RPC.Chain.GetBlockHash(uint64(head.Number))
-			if err != nil {
-				bw.handler.HandleError(err)
+	if next > 0 {
+		head, err := c.getHeaderLatest(ctx)
+		if err != nil {
+			bw.handler.HandleError(err)
+			return
+		}
+
+		for ; next <= uint64(head.Number); next++ {
+			select {
+			case <-bw.stop:
+				return
+			case <-ctx.Done():
 				return
+			default:
 			}
 
-			availBatch, err := api.RPC.Chain.GetBlock(blockHash)
-			if err != nil {
+			if err := bw.processBlock(ctx, c, callIdx, next); err != nil {
 				bw.handler.HandleError(err)
 				return
 			}
+		}
+	}
 
-			for i, extrinsic := range availBatch.Block.Extrinsics {
-				if extrinsic.Signature.AppID.Int64() != bw.appID.Int64() {
-					log.Printf("block %d extrinsic %d: AppID doesn't match (%d vs. %d)", head.Number, i, extrinsic.Signature.AppID.Int64(), bw.appID.Int64())
-					continue
-				}
+	for {
+		select {
+		case head := <-sub.Chan():
+			number := uint64(head.Number)
 
-				if extrinsic.Method.CallIndex != callIdx {
-					log.Printf("block %d extrinsic %d: Method.CallIndex doesn't match (got %v, expected %v)", head.Number, i, extrinsic.Method.CallIndex, callIdx)
-					continue
+			for ; next > 0 && next < number; next++ {
+				if err := bw.processBlock(ctx, c, callIdx, next); err != nil {
+					bw.handler.HandleError(err)
+					return
 				}
+			}
 
-				log.Printf("block %d extrinsic %d: len(extrinsic.Method.Args): %d, extrinsic.Method.Args: '%v'", head.Number, i, len(extrinsic.Method.Args), extrinsic.Method.Args)
-
-				var blob Blob
-				{
-					// XXX: This decoding process is an inefficient hack to
-					// workaround problem in the encoding pipeline from client
-					// code to Avail server. See more information about this in
-					// sender.SubmitData().
-					var bs types.Bytes
-					err = codec.Decode(extrinsic.Method.Args, &bs)
-					if err != nil {
-						// Don't invoke HandleError() on this because there is no
-						// way of filtering uninteresting extrinsics / method.Args
-						// and failing decoding is the only way to distinct those.
-						log.Printf("block %d extrinsic %d: decoding raw bytes from args failed: %s", head.Number, i, err)
-						continue
-					}
-
-					decoder := scale.NewDecoder(bytes.NewBuffer(bs))
-					err = blob.Decode(*decoder)
-					if err != nil {
-						// Don't invoke HandleError() on this because there is no
-						// way of filtering uninteresting extrinsics / method.Args
-						// and failing decoding is the only way to distinct those.
-						log.Printf("block %d extrinsic %d: decoding blob from bytes failed: %s", head.Number, i, err)
-						continue
-					}
-				}
+			if err := bw.processBlock(ctx, c, callIdx, number); err != nil {
+				bw.handler.HandleError(err)
+				return
+			}
 
-				err = bw.handler.HandleData(blob.Data)
-				if err != nil {
-					log.Printf("block %d extrinsic %d: data handler returned an error: %s", head.Number, i, err)
-				}
+			if number >= next {
+				next = number + 1
 			}
 		case err := <-sub.Err():
+			err = subscriptionError(err)
 			log.Printf("block watcher error: %s", err)
 			bw.handler.HandleError(err)
 		case <-bw.stop:
 			return
+		case <-ctx.Done():
+			return
 		}
 	}
 }
 
+// processBlock filters extrinsics with embedded `Blob` data from the Avail
+// block at the given height and invokes the handler with the decoded `Blob`
+// data. The cursor is advanced once the whole block has been processed.
+func (bw *BlockDataWatcher) processBlock(ctx context.Context, c *client, callIdx types.CallIndex, number uint64) error {
+	blockHash, err := c.getBlockHash(ctx, number)
+	if err != nil {
+		return err
+	}
+
+	// The same Avail block is delivered again on reconnection.
+	if bw.dedup.seenAvailBlock(blockHash) {
+		log.Printf("block %d: already processed, skipping (hash %s)", number, blockHash.Hex())
+		return nil
+	}
+
+	availBatch, err := c.getBlock(ctx, blockHash)
+	if err != nil {
+		return err
+	}
+
+	bw.dedup.addAvailBlock(blockHash)
+
+	if hasAppExtrinsics(availBatch, bw.appID) {
+		bw.processExtrinsics(number, callIdx, availBatch)
+	}
+
+	bw.saveCursor(Cursor{Number: number, Hash: blockHash})
+
+	return nil
+}
+
+// saveCursor persists the cursor, unless it would move it backwards.
+func (bw *BlockDataWatcher) saveCursor(cursor Cursor) {
+	if bw.cursors == nil || cursor.Number < bw.cursor.Number {
+		return
+	}
+
+	if err := bw.cursors.Save(cursor); err != nil {
+		log.Printf("block %d: persisting cursor failed: %s", cursor.Number, err)
+		return
+	}
+
+	bw.cursor = cursor
+}
+
+// processExtrinsics invokes the handler with the block data of the
+// extrinsics of the Avail block.
+func (bw *BlockDataWatcher) processExtrinsics(number uint64, callIdx types.CallIndex, availBatch *types.SignedBlock) {
+	for i, extrinsic := range availBatch.Block.Extrinsics {
+		if extrinsic.Signature.AppID.Int64() != bw.appID.Int64() {
+			log.Printf("block %d extrinsic %d: AppID doesn't match (%d vs. %d)", number, i, extrinsic.Signature.AppID.Int64(), bw.appID.Int64())
+			continue
+		}
+
+		if extrinsic.Method.CallIndex != callIdx {
+			log.Printf("block %d extrinsic %d: Method.CallIndex doesn't match (got %v, expected %v)", number, i, extrinsic.Method.CallIndex, callIdx)
+			continue
+		}
+
+		log.Printf("block %d extrinsic %d: len(extrinsic.Method.Args): %d, extrinsic.Method.Args: '%v'", number, i, len(extrinsic.Method.Args), extrinsic.Method.Args)
+
+		var blob Blob
+		{
+			// XXX: This decoding process is an inefficient hack to
+			// workaround problem in the encoding pipeline from client
+			// code to Avail server. See more information about this in
+			// sender.SubmitData().
+			var bs types.Bytes
+			err := codec.Decode(extrinsic.Method.Args, &bs)
+			if err != nil {
+				// Don't invoke HandleError() on this because there is no
+				// way of filtering uninteresting extrinsics / method.Args
+				// and failing decoding is the only way to distinct those.
+				log.Printf("block %d extrinsic %d: decoding raw bytes from args failed: %s", number, i, err)
+				continue
+			}
+
+			if len(bs) > 0 && bs[0] == ChunkMagic {
+				bw.handleChunk(number, i, extrinsic.Signature.Signer.AsID, bs)
+				continue
+			}
+
+			decoder := scale.NewDecoder(bytes.NewBuffer(bs))
+			err = blob.Decode(*decoder)
+			if err != nil {
+				// Don't invoke HandleError() on this because there is no
+				// way of filtering uninteresting extrinsics / method.Args
+				// and failing decoding is the only way to distinct those.
+				observeMalformedBlockData()
+				log.Printf("block %d extrinsic %d: decoding blob from bytes failed: %s", number, i, err)
+				continue
+			}
+		}
+
+		bw.handleData(number, i, blob.Data)
+	}
+}
+
+// handleChunk feeds the block data chunk, signed by the submitter, to the
+// reassembler and invokes the handler with the block data once all of its
+// chunks have arrived.
+func (bw *BlockDataWatcher) handleChunk(number uint64, i int, submitter types.AccountID, bs []byte) {
+	var chunk BlobChunk
+	if err := chunk.Decode(*scale.NewDecoder(bytes.NewBuffer(bs))); err != nil {
+		observeMalformedBlockData()
+		log.Printf("block %d extrinsic %d: decoding chunk from bytes failed: %s", number, i, err)
+		return
+	}
+
+	blk, err := bw.chunks.Add(submitter, chunk)
+	if err != nil {
+		log.Printf("block %d extrinsic %d: reassembling chunks failed: %s", number, i, err)
+		return
+	}
+
+	if blk == nil {
+		return
+	}
+
+	bw.handleData(number, i, blk.MarshalRLP())
+}
+
+// handleData invokes the handler with the block data, unless the edge block
+// encoded in it has already been handed over.
+func (bw *BlockDataWatcher) handleData(number uint64, i int, data []byte) {
+	// Data that doesn't decode into an edge block is passed on as is.
+	var blk edge_types.Block
+	if err := blk.UnmarshalRLP(data); err != nil {
+		if err := bw.handler.HandleData(data); err != nil {
+			log.Printf("block %d extrinsic %d: data handler returned an error: %s", number, i, err)
+		}
+
+		return
+	}
+
+	if bw.dedup.seenEdgeBlock(blk.Hash()) {
+		log.Printf("block %d extrinsic %d: edge block %s already handled, skipping", number, i, blk.Hash())
+		return
+	}
+
+	if err := bw.handler.HandleData(data); err != nil {
+		log.Printf("block %d extrinsic %d: data handler returned an error: %s", number, i, err)
+		return
+	}
+
+	bw.dedup.addEdgeBlock(blk.Hash())
+}
+
 // Stop stops active watcher.
 func (bw *BlockDataWatcher) Stop() {
 	select {
@@ -153,3 +392,57 @@ func (bw *BlockDataWatcher) Stop() {
 		close(bw.stop)
 	}
 }
+
+// dedupWindow remembers the most recent Avail blocks by hash, and the edge
+// blocks embedded in them, so that replayed blocks are handled only once.
+// Different blocks at the same Avail height have different hashes and are
+// not suppressed.
+type dedupWindow struct {
+	availBlks *lru.Cache
+	edgeBlks  *lru.Cache
+}
+
+// newDedupWindow creates a dedup window remembering the given number of blocks.
+func newDedupWindow(size int) (*dedupWindow, error) {
+	availBlks, err := lru.New(size)
+	if err != nil {
+		return nil, err
+	}
+
+	edgeBlks, err := lru.New(size)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dedupWindow{availBlks: availBlks, edgeBlks: edgeBlks}, nil
+}
+
+// seenAvailBlock reports whether the Avail block has already been processed.
+func (d *dedupWindow) seenAvailBlock(hash types.Hash) bool {
+	if d.availBlks.Contains(hash) {
+		observeDuplicate(duplicateAvailBlock)
+		return true
+	}
+
+	return false
+}
+
+// addAvailBlock marks the Avail block as processed.
+func (d *dedupWindow) addAvailBlock(hash types.Hash) {
+	d.availBlks.Add(hash, struct{}{})
+}
+
+// seenEdgeBlock reports whether the edge block has already been handled.
+func (d *dedupWindow) seenEdgeBlock(hash edge_types.Hash) bool {
+	if d.edgeBlks.Contains(hash) {
+		observeDuplicate(duplicateEdgeBlock)
+		return true
+	}
+
+	return false
+}
+
+// addEdgeBlock marks the edge block as handled.
+func (d *dedupWindow) addEdgeBlock(hash edge_types.Hash) {
+	d.edgeBlks.Add(hash, struct{}{})
+}
@@ -0,0 +1,298 @@
+package avail
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	edge_types "github.com/0xPolygon/polygon-edge/types"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/signature"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types/codec"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+// testDataHandler collects the numbers of the edge blocks handed over by the watcher.
+type testDataHandler struct {
+	blks chan uint64
+}
+
+func (h *testDataHandler) HandleData(bs []byte) error {
+	var blk edge_types.Block
+	if err := blk.UnmarshalRLP(bs); err != nil {
+		return err
+	}
+
+	h.blks <- blk.Number()
+
+	return nil
+}
+
+func (h *testDataHandler) HandleError(err error) {}
+
+// edgeBlockExtrinsic builds a signed `submit_data` extrinsic carrying the edge block.
+func edgeBlockExtrinsic(t *testing.T, appID types.UCompact, callIdx types.CallIndex, number uint64) types.Extrinsic {
+	t.Helper()
+
+	blk := &edge_types.Block{Header: &edge_types.Header{Number: number, Difficulty: 1}}
+	blk.Header.ComputeHash()
+
+	payloads, err := (&sender{maxChunkSize: DefaultMaxChunkSize}).payloads(blk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	args, err := codec.Encode(payloads[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ext := types.NewExtrinsic(types.Call{CallIndex: callIdx, Args: args})
+
+	err = ext.Sign(signature.TestKeyringPairAlice, types.SignatureOptions{
+		Era:                types.ExtrinsicEra{IsMortalEra: false},
+		Nonce:              types.NewUCompactFromUInt(number),
+		AppID:              appID,
+		SpecVersion:        1,
+		TransactionVersion: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return ext
+}
+
+func TestBlockDataWatcherSuppressesReplayedBlocks(t *testing.T) {
+	sink := testMetricsSink(t)
+
+	chain := newStubChain(t, 1, time.Hour)
+	chain.runtimes[0].metadata = stubMetadata(t, 42)
+	e := newStubEndpoint(t, chain)
+
+	c, err := NewClient(e.URL, hclog.NewNullLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	callIdx, err := NewCallIndexResolver(c).Latest(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	appID := types.NewUCompactFromUInt(1)
+	handler := &testDataHandler{blks: make(chan uint64, 16)}
+
+	w, err := NewBlockDataWatcher(c, appID, handler)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := w.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	for n := uint64(1); n <= 3; n++ {
+		chain.produceWith(edgeBlockExtrinsic(t, appID, callIdx, n))
+	}
+
+	// Replay an overlapping range of Avail blocks, as on reconnection.
+	for n := uint64(2); n <= 4; n++ {
+		hdr, _ := chain.header(n)
+		e.notify(hdr)
+	}
+
+	// The same edge block resubmitted in another Avail block.
+	chain.produceWith(edgeBlockExtrinsic(t, appID, callIdx, 3))
+	chain.produceWith(edgeBlockExtrinsic(t, appID, callIdx, 4))
+
+	var received []uint64
+	for len(received) == 0 || received[len(received)-1] != 4 {
+		select {
+		case n := <-handler.blks:
+			received = append(received, n)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("edge blocks not received; got %v", received)
+		}
+	}
+
+	assert.Equal(t, []uint64{1, 2, 3, 4}, received)
+	assert.Equal(t, float64(3), counter(sink, "test.avail.watcher.duplicates;kind=avail_block"))
+	assert.Equal(t, float64(1), counter(sink, "test.avail.watcher.duplicates;kind=edge_block"))
+}
+
+func TestDedupWindow(t *testing.T) {
+	d, err := newDedupWindow(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Different Avail blocks at the same height are not duplicates.
+	d.addAvailBlock(types.NewHash([]byte{0x01}))
+	assert.True(t, d.seenAvailBlock(types.NewHash([]byte{0x01})))
+	assert.False(t, d.seenAvailBlock(types.NewHash([]byte{0x02})))
+
+	// The window is bounded; the oldest blocks are forgotten.
+	d.addAvailBlock(types.NewHash([]byte{0x02}))
+	d.addAvailBlock(types.NewHash([]byte{0x03}))
+	assert.False(t, d.seenAvailBlock(types.NewHash([]byte{0x01})))
+	assert.True(t, d.seenAvailBlock(types.NewHash([]byte{0x03})))
+
+	d.addEdgeBlock(edge_types.Hash{0x01})
+	assert.True(t, d.seenEdgeBlock(edge_types.Hash{0x01}))
+	assert.False(t, d.seenEdgeBlock(edge_types.Hash{0x02}))
+}
+
+// receiveUntil collects the edge block numbers handed over to the handler
+// until the given one arrives.
+func receiveUntil(t *testing.T, handler *testDataHandler, last uint64) []uint64 {
+	t.Helper()
+
+	var received []uint64
+	for len(received) == 0 || received[len(received)-1] != last {
+		select {
+		case n := <-handler.blks:
+			received = append(received, n)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("edge blocks not received; got %v", received)
+		}
+	}
+
+	return received
+}
+
+func TestBlockDataWatcherResumesFromCursor(t *testing.T) {
+	chain := newStubChain(t, 1, time.Hour)
+	chain.runtimes[0].metadata = stubMetadata(t, 42)
+	e := newStubEndpoint(t, chain)
+
+	c, err := NewClient(e.URL, hclog.NewNullLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	callIdx, err := NewCallIndexResolver(c).Latest(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	appID := types.NewUCompactFromUInt(1)
+	handler := &testDataHandler{blks: make(chan uint64, 16)}
+	store := NewFileCursorStore(t.TempDir())
+
+	// The first run starts from the genesis offset.
+	chain.produceWith(edgeBlockExtrinsic(t, appID, callIdx, 1))
+
+	w, err := NewBlockDataWatcher(c, appID, handler, WithCursorStore(store), WithGenesisOffset(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := w.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	chain.produceWith(edgeBlockExtrinsic(t, appID, callIdx, 2))
+	received := receiveUntil(t, handler, 2)
+
+	assert.Eventually(t, func() bool {
+		cursor, ok, err := store.Load()
+		return err == nil && ok && cursor == Cursor{Number: 3, Hash: stubHash(3)}
+	}, 5*time.Second, 10*time.Millisecond)
+
+	w.Stop()
+	assert.Eventually(t, func() bool { return !e.subscribed() }, 5*time.Second, 10*time.Millisecond)
+
+	// Blocks produced while the watcher is down.
+	chain.produceWith(edgeBlockExtrinsic(t, appID, callIdx, 3))
+	chain.produceWith()
+	chain.produceWith(edgeBlockExtrinsic(t, appID, callIdx, 4))
+
+	w, err = NewBlockDataWatcher(c, appID, handler, WithCursorStore(store), WithGenesisOffset(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	chain.produceWith(edgeBlockExtrinsic(t, appID, callIdx, 5))
+	received = append(received, receiveUntil(t, handler, 5)...)
+
+	assert.Equal(t, []uint64{1, 2, 3, 4, 5}, received)
+
+	assert.Eventually(t, func() bool {
+		cursor, _, _ := store.Load()
+		return cursor.Number == 7
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestBlockDataWatcherRewindsOnReorg(t *testing.T) {
+	chain := newStubChain(t, 1, time.Hour)
+	chain.runtimes[0].metadata = stubMetadata(t, 42)
+	e := newStubEndpoint(t, chain)
+
+	c, err := NewClient(e.URL, hclog.NewNullLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	callIdx, err := NewCallIndexResolver(c).Latest(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	appID := types.NewUCompactFromUInt(1)
+
+	// Avail blocks 2 to 5 carry the edge blocks 1 to 4.
+	for n := uint64(1); n <= 4; n++ {
+		chain.produceWith(edgeBlockExtrinsic(t, appID, callIdx, n))
+	}
+
+	testCases := []struct {
+		name     string
+		cursor   Cursor
+		expected []uint64
+	}{
+		{name: "matching cursor", cursor: Cursor{Number: 3, Hash: stubHash(3)}, expected: []uint64{3, 4}},
+		{name: "reorged cursor", cursor: Cursor{Number: 3, Hash: types.NewHash([]byte{0xff})}, expected: []uint64{1, 2, 3, 4}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := NewFileCursorStore(t.TempDir())
+			if err := store.Save(tc.cursor); err != nil {
+				t.Fatal(err)
+			}
+
+			handler := &testDataHandler{blks: make(chan uint64, 16)}
+
+			w, err := NewBlockDataWatcher(c, appID, handler, WithCursorStore(store), WithReorgRewind(2))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			if err := w.Start(ctx); err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, tc.expected, receiveUntil(t, handler, 4))
+
+			assert.Eventually(t, func() bool {
+				cursor, _, _ := store.Load()
+				return cursor == Cursor{Number: 5, Hash: stubHash(5)}
+			}, 5*time.Second, 10*time.Millisecond)
+		})
+	}
+}
@@ -38,6 +38,9 @@ type Builder interface {
 	// SetParentStateRoot sets the parent state root and returns the builder.
 	SetParentStateRoot(parentRoot types.Hash) Builder
 
+	// SetTimestamp sets the block timestamp, in seconds, and returns the builder.
+	SetTimestamp(timestamp uint64) Builder
+
 	// AddTransactions adds transactions to the block and returns the builder.
 	AddTransactions(txs ...*types.Transaction) Builder
 
@@ -52,6 +55,7 @@ type Builder interface {
 }
 
 type blockchain interface {
+	CalculateBaseFee(parent *types.Header) uint64
 	CalculateGasLimit(number uint64) (uint64, error)
 	GetHeaderByHash(types.Hash) (*types.Header, bool)
 	Header() *types.Header
@@ -69,11 +73,12 @@ type blockBuilder struct {
 	parentRoot *types.Hash
 	parentHash *types.Hash
 	gasLimit   *uint64
+	timestamp  *uint64
 
 	header *types.Header
 	parent *types.Header
 
-	transition   *state.Transition
+	transition   *Transition
 	extraData    map[string][]byte
 	transactions []*types.Transaction
 	signKey      *ecdsa.PrivateKey
@@ -178,6 +183,12 @@ func (bb *blockBuilder) SetParentStateRoot(parentRoot types.Hash) Builder {
 	return bb
 }
 
+// SetTimestamp sets the timestamp for the block builder.
+func (bb *blockBuilder) SetTimestamp(timestamp uint64) Builder {
+	bb.timestamp = &timestamp
+	return bb
+}
+
 // AddTransactions adds one or more transactions to the block builder.
 func (bb *blockBuilder) AddTransactions(tx ...*types.Transaction) Builder {
 	bb.transactions = append(bb.transactions, tx...)
@@ -230,6 +241,17 @@ func (bb *blockBuilder) setDefaults() {
 		bb.gasLimit = new(uint64)
 		*bb.gasLimit = 0
 	}
+
+	// The timestamp doesn't go below the parent's, which may run ahead of
+	// the clock with the blocks produced faster than a second apart.
+	if bb.timestamp == nil {
+		bb.timestamp = new(uint64)
+		*bb.timestamp = uint64(time.Now().Unix())
+
+		if *bb.timestamp < bb.parent.Timestamp {
+			*bb.timestamp = bb.parent.Timestamp
+		}
+	}
 }
 
 // Build creates a new block using the provided parameters.
@@ -249,7 +271,7 @@ func (bb *blockBuilder) Build() (*types.Block, error) {
 	bb.header.ExtraData = EncodeExtraDataFields(bb.extraData)
 	bb.header.GasLimit = *bb.gasLimit
 	bb.header.Miner = bb.coinbase.Bytes()
-	bb.header.Timestamp = uint64(time.Now().Unix())
+	bb.header.Timestamp = *bb.timestamp
 
 	// Copy gaslimit from genesis block if first post-genesis.
 	if bb.header.GasLimit == 0 && bb.parent.Number == 0 {
@@ -265,12 +287,16 @@ func (bb *blockBuilder) Build() (*types.Block, error) {
 		}
 	}
 
+	bb.header.BaseFee = bb.blockchain.CalculateBaseFee(bb.parent)
+
 	// Create a block transition.
-	bb.transition, err = bb.executor.BeginTxn(*bb.parentRoot, bb.header, *bb.coinbase)
+	txn, err := bb.executor.BeginTxn(*bb.parentRoot, bb.header, *bb.coinbase)
 	if err != nil {
 		return nil, err
 	}
 
+	bb.transition = NewTransition(txn)
+
 	// Write all transactions in-order.
 	for _, tx := range bb.transactions {
 		if tx.Nonce == 0 {
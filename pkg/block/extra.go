@@ -1,6 +1,7 @@
 package block
 
 import (
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"sort"
@@ -25,8 +26,50 @@ const (
 	// KeyEndDisputeResolutionOf used to understand which block hash was used to slash the node
 	// in order to end dispute resolution on all of the nodes
 	KeyEndDisputeResolutionOf = "END_DISPUTE_RESOLUTION_OF"
+
+	// KeyAvailReference is key that identifies the height of the Avail block
+	// of the slot the block was produced in, serialized in `ExtraData`.
+	KeyAvailReference = "AVAIL_REFERENCE"
+
+	// KeyFraudProofViolation is key that identifies the `FraudViolation` the
+	// fraudproof claims of the objected malicious block in `ExtraData` of the
+	// fraudproof block header.
+	KeyFraudProofViolation = "FRAUD_PROOF_VIOLATION"
+
+	// KeyFraudProofTxIndex is key that identifies the index of the transaction
+	// of the objected malicious block the violation is about in `ExtraData` of
+	// the fraudproof block header.
+	KeyFraudProofTxIndex = "FRAUD_PROOF_TX_INDEX"
+
+	// KeyDisputeDefenseOf is key that identifies the block hash defended by its sequencer against a
+	// fraudproof in `ExtraData` of the defense block header.
+	KeyDisputeDefenseOf = "DISPUTE_DEFENSE_OF"
+
+	// KeyDisputeDefenseResult is key that identifies the serialized `DisputeDefense` re-execution results
+	// of the defended block in `ExtraData` of the defense block header.
+	KeyDisputeDefenseResult = "DISPUTE_DEFENSE_RESULT"
+)
+
+// disputeDefenseSize is the size of the serialized `DisputeDefense`.
+const disputeDefenseSize = 2*types.HashLength + 8
+
+// FraudViolation is the violation a fraudproof claims of the objected block.
+type FraudViolation byte
+
+const (
+	// FraudViolationExecution is a block failing the checks of its
+	// re-execution; it's the violation of the fraudproofs claiming none.
+	FraudViolationExecution FraudViolation = iota
+
+	// FraudViolationTxSignature is a block with a transaction whose
+	// signature doesn't recover its sender.
+	FraudViolationTxSignature
 )
 
+// ErrAvailReferenceRegressed is returned when the Avail reference of a block
+// is lower than the one of its parent.
+var ErrAvailReferenceRegressed = errors.New("avail reference lower than the parent's")
+
 // EncodeExtraDataFields encodes the given map of extra data fields into a byte slice.
 // It takes a map of string keys to byte slice values and returns a byte slice representation of the encoded data.
 func EncodeExtraDataFields(data map[string][]byte) []byte {
@@ -219,6 +262,143 @@ func GetExtraDataEndDisputeResolutionTarget(h *types.Header) (types.Hash, bool)
 	return toReturn, true
 }
 
+// GetExtraDataFraudProofViolation returns the violation the fraudproof claims of the objected block, from
+// the extra data field in the header, along with the index of the transaction it's about, if any.
+// The fraudproofs without a violation claim FraudViolationExecution.
+// Returns false if the extra data field can't be decoded, or holds an unknown or incomplete violation.
+func GetExtraDataFraudProofViolation(h *types.Header) (FraudViolation, uint64, bool) {
+	kv, err := DecodeExtraDataFields(h.ExtraData)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	data, exists := kv[KeyFraudProofViolation]
+	if !exists {
+		return FraudViolationExecution, 0, true
+	}
+
+	if len(data) != 1 {
+		return 0, 0, false
+	}
+
+	switch violation := FraudViolation(data[0]); violation {
+	case FraudViolationExecution:
+		return violation, 0, true
+	case FraudViolationTxSignature:
+		index, exists := kv[KeyFraudProofTxIndex]
+		if !exists || len(index) != 8 {
+			return 0, 0, false
+		}
+
+		return violation, binary.BigEndian.Uint64(index), true
+	default:
+		return 0, 0, false
+	}
+}
+
+// DisputeDefense is the results of the re-execution of a block accused by a fraudproof, claimed by the
+// sequencer of the block in its defense.
+type DisputeDefense struct {
+	StateRoot    types.Hash
+	ReceiptsRoot types.Hash
+	GasUsed      uint64
+}
+
+// Bytes serializes the DisputeDefense for the extra data field.
+func (d DisputeDefense) Bytes() []byte {
+	bs := make([]byte, 0, disputeDefenseSize)
+	bs = append(bs, d.StateRoot.Bytes()...)
+	bs = append(bs, d.ReceiptsRoot.Bytes()...)
+
+	return binary.BigEndian.AppendUint64(bs, d.GasUsed)
+}
+
+// Matches reports whether the re-execution results are the ones of the header.
+func (d DisputeDefense) Matches(h *types.Header) bool {
+	return d.StateRoot == h.StateRoot && d.ReceiptsRoot == h.ReceiptsRoot && d.GasUsed == h.GasUsed
+}
+
+// GetExtraDataDisputeDefense returns the hash of the defended block and the DisputeDefense of it from the
+// extra data field in the header.
+// Returns false if the extra data field can't be decoded, or holds no or an incomplete defense.
+func GetExtraDataDisputeDefense(h *types.Header) (types.Hash, DisputeDefense, bool) {
+	kv, err := DecodeExtraDataFields(h.ExtraData)
+	if err != nil {
+		return types.ZeroHash, DisputeDefense{}, false
+	}
+
+	target := types.BytesToHash(kv[KeyDisputeDefenseOf])
+	if target == types.ZeroHash {
+		return types.ZeroHash, DisputeDefense{}, false
+	}
+
+	data, exists := kv[KeyDisputeDefenseResult]
+	if !exists || len(data) != disputeDefenseSize {
+		return types.ZeroHash, DisputeDefense{}, false
+	}
+
+	return target, DisputeDefense{
+		StateRoot:    types.BytesToHash(data[:types.HashLength]),
+		ReceiptsRoot: types.BytesToHash(data[types.HashLength : 2*types.HashLength]),
+		GasUsed:      binary.BigEndian.Uint64(data[2*types.HashLength:]),
+	}, true
+}
+
+// AssignExtraAvailReference adds the height of the Avail block of the slot the block is produced in
+// to the extra data field in the header.
+// Returns an error if there is an issue decoding or encoding the extra data field.
+func AssignExtraAvailReference(h *types.Header, availHeight uint64) error {
+	kv, err := DecodeExtraDataFields(h.ExtraData)
+	if err != nil {
+		return err
+	}
+
+	kv[KeyAvailReference] = binary.BigEndian.AppendUint64(nil, availHeight)
+
+	h.ExtraData = EncodeExtraDataFields(kv)
+
+	return nil
+}
+
+// GetExtraDataAvailReference returns the Avail reference from the extra data field in the header.
+// It takes the header and returns the height of the Avail block of the slot the block was produced in.
+// Returns the Avail reference and a boolean indicating if it was found in the extra data field; the
+// blocks of the producers predating the reference have none.
+func GetExtraDataAvailReference(h *types.Header) (uint64, bool) {
+	kv, err := DecodeExtraDataFields(h.ExtraData)
+	if err != nil {
+		return 0, false
+	}
+
+	data, exists := kv[KeyAvailReference]
+	if !exists || len(data) != 8 {
+		return 0, false
+	}
+
+	return binary.BigEndian.Uint64(data), true
+}
+
+// VerifyAvailReference verifies that the Avail reference of the header doesn't go below the one of its parent.
+// The check is skipped when either of the headers has no Avail reference.
+// Returns ErrAvailReferenceRegressed if the reference of the header is lower than the one of the parent.
+func VerifyAvailReference(h, parent *types.Header) error {
+	ref, ok := GetExtraDataAvailReference(h)
+	if !ok {
+		return nil
+	}
+
+	parentRef, ok := GetExtraDataAvailReference(parent)
+	if !ok {
+		return nil
+	}
+
+	if ref < parentRef {
+		return fmt.Errorf("%w: avail reference %d, parent's %d", ErrAvailReferenceRegressed, ref, parentRef)
+	}
+
+	return nil
+}
+
 // ValidatorExtra defines the structure of the extra data field for validators.
 type ValidatorExtra struct {
 	Validators    []types.Address
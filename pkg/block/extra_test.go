@@ -1,12 +1,16 @@
 package block
 
 import (
+	"encoding/binary"
+	"errors"
 	"flag"
 	"fmt"
 	"math/rand"
 	"reflect"
 	"testing"
 	"time"
+
+	"github.com/0xPolygon/polygon-edge/types"
 )
 
 func Test_ExtraData_Encoding(t *testing.T) {
@@ -93,6 +97,113 @@ func Test_ExtraData_Decoding(t *testing.T) {
 	}
 }
 
+func Test_ExtraData_AvailReference(t *testing.T) {
+	withRef := func(availHeight uint64) *types.Header {
+		h := &types.Header{}
+		if err := AssignExtraAvailReference(h, availHeight); err != nil {
+			t.Fatal(err)
+		}
+
+		return h
+	}
+
+	if _, ok := GetExtraDataAvailReference(&types.Header{}); ok {
+		t.Fatal("found avail reference in empty extra data")
+	}
+
+	if ref, ok := GetExtraDataAvailReference(withRef(42)); !ok || ref != 42 {
+		t.Fatalf("avail reference == %d, %t; want 42, true", ref, ok)
+	}
+
+	testCases := []struct {
+		name      string
+		h, parent *types.Header
+		regressed bool
+	}{
+		{"same slot", withRef(7), withRef(7), false},
+		{"later slot", withRef(8), withRef(7), false},
+		{"earlier slot", withRef(6), withRef(7), true},
+		{"parent without reference", withRef(6), &types.Header{}, false},
+		{"block without reference", &types.Header{}, withRef(7), false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := VerifyAvailReference(tc.h, tc.parent)
+			if errors.Is(err, ErrAvailReferenceRegressed) != tc.regressed {
+				t.Fatalf("error == %v, want regressed: %t", err, tc.regressed)
+			}
+		})
+	}
+}
+
+func Test_ExtraData_FraudProofViolation(t *testing.T) {
+	withFields := func(fields map[string][]byte) *types.Header {
+		return &types.Header{ExtraData: EncodeExtraDataFields(fields)}
+	}
+
+	index := binary.BigEndian.AppendUint64(nil, 3)
+
+	testCases := []struct {
+		name      string
+		h         *types.Header
+		violation FraudViolation
+		index     uint64
+		ok        bool
+	}{
+		{"no violation", withFields(map[string][]byte{}), FraudViolationExecution, 0, true},
+		{"execution", withFields(map[string][]byte{KeyFraudProofViolation: {byte(FraudViolationExecution)}}), FraudViolationExecution, 0, true},
+		{"tx signature", withFields(map[string][]byte{KeyFraudProofViolation: {byte(FraudViolationTxSignature)}, KeyFraudProofTxIndex: index}), FraudViolationTxSignature, 3, true},
+		{"tx signature without index", withFields(map[string][]byte{KeyFraudProofViolation: {byte(FraudViolationTxSignature)}}), 0, 0, false},
+		{"tx signature with short index", withFields(map[string][]byte{KeyFraudProofViolation: {byte(FraudViolationTxSignature)}, KeyFraudProofTxIndex: index[:4]}), 0, 0, false},
+		{"unknown violation", withFields(map[string][]byte{KeyFraudProofViolation: {0xff}}), 0, 0, false},
+		{"malformed violation", withFields(map[string][]byte{KeyFraudProofViolation: {0, 1}}), 0, 0, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			violation, index, ok := GetExtraDataFraudProofViolation(tc.h)
+			if violation != tc.violation || index != tc.index || ok != tc.ok {
+				t.Fatalf("violation == %d, %d, %t; want %d, %d, %t", violation, index, ok, tc.violation, tc.index, tc.ok)
+			}
+		})
+	}
+}
+
+func Test_ExtraData_DisputeDefense(t *testing.T) {
+	target := types.StringToHash("1")
+	defense := DisputeDefense{StateRoot: types.StringToHash("2"), ReceiptsRoot: types.StringToHash("3"), GasUsed: 21_000}
+
+	h := &types.Header{ExtraData: EncodeExtraDataFields(map[string][]byte{
+		KeyDisputeDefenseOf:     target.Bytes(),
+		KeyDisputeDefenseResult: defense.Bytes(),
+	})}
+
+	gotTarget, gotDefense, ok := GetExtraDataDisputeDefense(h)
+	if !ok || gotTarget != target || gotDefense != defense {
+		t.Fatalf("defense == %s, %+v, %t; want %s, %+v, true", gotTarget, gotDefense, ok, target, defense)
+	}
+
+	if !defense.Matches(&types.Header{StateRoot: defense.StateRoot, ReceiptsRoot: defense.ReceiptsRoot, GasUsed: defense.GasUsed}) {
+		t.Fatalf("defense doesn't match the header of its results")
+	}
+
+	if defense.Matches(&types.Header{StateRoot: defense.StateRoot, ReceiptsRoot: defense.ReceiptsRoot, GasUsed: defense.GasUsed + 1}) {
+		t.Fatalf("defense matches the header of other results")
+	}
+
+	for name, fields := range map[string]map[string][]byte{
+		"no defense":        {},
+		"no results":        {KeyDisputeDefenseOf: target.Bytes()},
+		"short results":     {KeyDisputeDefenseOf: target.Bytes(), KeyDisputeDefenseResult: defense.Bytes()[:40]},
+		"no defended block": {KeyDisputeDefenseResult: defense.Bytes()},
+	} {
+		if _, _, ok := GetExtraDataDisputeDefense(&types.Header{ExtraData: EncodeExtraDataFields(fields)}); ok {
+			t.Fatalf("%s: defense found", name)
+		}
+	}
+}
+
 // Seed is a global variable used in functions that generate random data.
 // It's value can be specified via a command-line flag `-seed`.
 // By default, it uses the current Unix time.
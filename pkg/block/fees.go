@@ -0,0 +1,134 @@
+package block
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/0xPolygon/polygon-edge/state"
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+var (
+	// ErrBaseFeeMismatch is returned when the base fee of the header isn't
+	// the one following its parent.
+	ErrBaseFeeMismatch = errors.New("base fee mismatch")
+
+	// ErrInvalidTxFees is returned when the fee fields of a dynamic-fee
+	// transaction don't hold against the base fee of the block.
+	ErrInvalidTxFees = errors.New("invalid transaction fees")
+)
+
+// Transition is the state transition of a block settling the fees of its
+// transactions against the base fee of the block, by EIP-1559:
+//
+//   - a dynamic-fee transaction pays the effective gas price, the base fee
+//     plus its tip up to its fee cap, for the gas used; the base fee part is
+//     burnt and the tip goes to the coinbase.
+//   - a legacy transaction pays its gas price in full to the coinbase, as it
+//     did before the base fee; none of it is burnt.
+//
+// The state transition of edge charges the dynamic-fee transactions the fee
+// cap, rather than the effective gas price, for the gas they don't use, and
+// mints the burnt base fee of the legacy ones; both are settled back here
+// once the transaction is written. Blocks without a base fee, before the
+// London fork, are left as they are.
+type Transition struct {
+	*state.Transition
+}
+
+// NewTransition returns the Transition settling the fees over the state
+// transition of the block.
+func NewTransition(txn *state.Transition) *Transition {
+	return &Transition{Transition: txn}
+}
+
+// Write writes the transaction to the block, settling its fees.
+func (t *Transition) Write(tx *types.Transaction) error {
+	if err := t.Transition.Write(tx); err != nil {
+		return err
+	}
+
+	ctx := t.GetTxContext()
+	if ctx.BaseFee == nil || ctx.BaseFee.Sign() == 0 || tx.Type == types.StateTx {
+		return nil
+	}
+
+	if tx.Type == types.DynamicFeeTx {
+		// The executor took the fee cap for all the gas bought, refunding
+		// the unused gas at the effective gas price.
+		excess := new(big.Int).Sub(tx.GasFeeCap, tx.GetGasPrice(ctx.BaseFee.Uint64()))
+		t.Txn().AddBalance(tx.From, excess.Mul(excess, new(big.Int).SetUint64(tx.Gas)))
+
+		return nil
+	}
+
+	// The executor minted the base fee of the gas used to the burn contract,
+	// on top of the gas price paid to the coinbase.
+	receipts := t.Receipts()
+
+	minted := new(big.Int).SetUint64(receipts[len(receipts)-1].GasUsed)
+	minted.Mul(minted, ctx.BaseFee)
+
+	if err := t.Txn().SubBalance(ctx.BurnContract, minted); err != nil {
+		return fmt.Errorf("failed to settle the fees of transaction %s: %w", tx.Hash, err)
+	}
+
+	return nil
+}
+
+// VerifyFees verifies the base fee of the block is the given one, following
+// its parent, and the fee fields of its dynamic-fee transactions hold against
+// it: no such transaction goes in a block without a base fee, before the
+// London fork, and the tip of each is up to its fee cap, itself at least the
+// base fee. The legacy transactions are left unchecked, their gas price under
+// the base fee standing.
+func VerifyFees(blk *types.Block, baseFee uint64) error {
+	if blk.Header.BaseFee != baseFee {
+		return fmt.Errorf("%w: base fee %d, expected %d", ErrBaseFeeMismatch, blk.Header.BaseFee, baseFee)
+	}
+
+	for _, tx := range blk.Transactions {
+		if tx.Type != types.DynamicFeeTx {
+			continue
+		}
+
+		switch {
+		case baseFee == 0:
+			return fmt.Errorf("%w: dynamic-fee transaction %s in a block without a base fee", ErrInvalidTxFees, tx.Hash)
+
+		case tx.GasFeeCap == nil || tx.GasTipCap == nil:
+			return fmt.Errorf("%w: transaction %s without its fee cap or tip", ErrInvalidTxFees, tx.Hash)
+
+		case tx.GasTipCap.Cmp(tx.GasFeeCap) > 0:
+			return fmt.Errorf("%w: transaction %s tip %s over its fee cap %s", ErrInvalidTxFees, tx.Hash, tx.GasTipCap, tx.GasFeeCap)
+
+		case tx.GasFeeCap.Cmp(new(big.Int).SetUint64(baseFee)) < 0:
+			return fmt.Errorf("%w: transaction %s fee cap %s under the base fee %d", ErrInvalidTxFees, tx.Hash, tx.GasFeeCap, baseFee)
+		}
+	}
+
+	return nil
+}
+
+// EffectiveTip returns the tip per gas the transaction offers over the given
+// base fee, by EIP-1559: the tip up to the fee cap less the base fee for a
+// dynamic-fee transaction, and the gas price less the base fee for a legacy
+// one, whatever part of it the Transition pays the coinbase. The legacy
+// transactions priced under the base fee stand on this chain and tip below
+// zero, so ranked by the tip they go after the ones paying over the base fee,
+// still in the order of their gas prices.
+func EffectiveTip(tx *types.Transaction, baseFee uint64) *big.Int {
+	fee := new(big.Int).SetUint64(baseFee)
+
+	if tx.Type != types.DynamicFeeTx || tx.GasFeeCap == nil || tx.GasTipCap == nil {
+		return fee.Sub(tx.GetGasPrice(baseFee), fee)
+	}
+
+	tip := fee.Sub(tx.GasFeeCap, fee)
+	if tip.Cmp(tx.GasTipCap) > 0 {
+		tip.Set(tx.GasTipCap)
+	}
+
+	return tip
+}
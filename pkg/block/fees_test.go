@@ -0,0 +1,96 @@
+package block
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+func Test_VerifyFees(t *testing.T) {
+	const baseFee = 100
+
+	block := func(baseFee uint64, txs ...*types.Transaction) *types.Block {
+		return &types.Block{Header: &types.Header{BaseFee: baseFee}, Transactions: txs}
+	}
+
+	dynamic := func(feeCap, tipCap int64) *types.Transaction {
+		return &types.Transaction{Type: types.DynamicFeeTx, GasFeeCap: big.NewInt(feeCap), GasTipCap: big.NewInt(tipCap)}
+	}
+
+	legacy := &types.Transaction{GasPrice: big.NewInt(1)}
+
+	testCases := []struct {
+		name    string
+		blk     *types.Block
+		baseFee uint64
+		want    error
+	}{
+		{"legacy under the base fee", block(baseFee, legacy), baseFee, nil},
+		{"dynamic fee over the base fee", block(baseFee, legacy, dynamic(200, 10)), baseFee, nil},
+		{"dynamic fee capped at the base fee", block(baseFee, dynamic(baseFee, baseFee)), baseFee, nil},
+		{"legacy without a base fee", block(0, legacy), 0, nil},
+		{"base fee off the parent's", block(baseFee + 1), baseFee, ErrBaseFeeMismatch},
+		{"dynamic fee without a base fee", block(0, dynamic(200, 10)), 0, ErrInvalidTxFees},
+		{"dynamic fee without its caps", block(baseFee, &types.Transaction{Type: types.DynamicFeeTx}), baseFee, ErrInvalidTxFees},
+		{"tip over the fee cap", block(baseFee, dynamic(200, 201)), baseFee, ErrInvalidTxFees},
+		{"fee cap under the base fee", block(baseFee, dynamic(baseFee-1, 0)), baseFee, ErrInvalidTxFees},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := VerifyFees(tc.blk, tc.baseFee)
+			if !errors.Is(err, tc.want) {
+				t.Fatalf("error == %v, want %v", err, tc.want)
+			}
+		})
+	}
+}
+
+func Test_EffectiveTip(t *testing.T) {
+	const baseFee = 100
+
+	dynamic := func(feeCap, tipCap int64) *types.Transaction {
+		return &types.Transaction{Type: types.DynamicFeeTx, GasFeeCap: big.NewInt(feeCap), GasTipCap: big.NewInt(tipCap)}
+	}
+
+	legacy := func(gasPrice int64) *types.Transaction {
+		return &types.Transaction{Type: types.LegacyTx, GasPrice: big.NewInt(gasPrice)}
+	}
+
+	testCases := []struct {
+		name string
+		tx   *types.Transaction
+		want int64
+	}{
+		{"legacy over the base fee", legacy(130), 30},
+		{"legacy at the base fee", legacy(baseFee), 0},
+		{"legacy under the base fee", legacy(60), -40},
+		{"dynamic fee up to its tip", dynamic(200, 30), 30},
+		{"dynamic fee up to its fee cap", dynamic(120, 30), 20},
+		{"dynamic fee capped at the base fee", dynamic(baseFee, 30), 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tip := EffectiveTip(tc.tx, baseFee); tip.Cmp(big.NewInt(tc.want)) != 0 {
+				t.Fatalf("tip == %s, want %d", tip, tc.want)
+			}
+		})
+	}
+
+	// A legacy and a dynamic-fee transaction paying as much per gas tip as
+	// much; the legacy ones under the base fee rank after both, by their gas
+	// prices.
+	tips := []*big.Int{
+		EffectiveTip(legacy(130), baseFee),
+		EffectiveTip(dynamic(130, 50), baseFee),
+		EffectiveTip(legacy(99), baseFee),
+		EffectiveTip(legacy(60), baseFee),
+	}
+
+	if tips[0].Cmp(tips[1]) != 0 || tips[1].Cmp(tips[2]) <= 0 || tips[2].Cmp(tips[3]) <= 0 {
+		t.Fatalf("tips == %v, want them equal, then decreasing", tips)
+	}
+}
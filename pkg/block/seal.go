@@ -6,6 +6,7 @@ import (
 	"github.com/0xPolygon/polygon-edge/crypto"
 	"github.com/0xPolygon/polygon-edge/helper/keccak"
 	"github.com/0xPolygon/polygon-edge/types"
+	lru "github.com/hashicorp/golang-lru"
 	"github.com/umbracle/fastrlp"
 )
 
@@ -93,8 +94,29 @@ func calculateHeaderHash(h *types.Header) ([]byte, error) {
 	return buf, nil
 }
 
+// sealCacheSize is the number of the signers recovered from the header seals
+// kept in the seal cache.
+const sealCacheSize = 4096
+
+// sealCache keeps the signers recovered from the header seals, by the signed
+// header hash and the seal, so that the signer recovered ahead of the checks
+// of a block, such as while catching up with Avail, isn't recovered again by
+// each of them.
+var sealCache = mustNewLRU(sealCacheSize)
+
+func mustNewLRU(size int) *lru.Cache {
+	c, err := lru.New(size)
+	if err != nil {
+		panic(err)
+	}
+
+	return c
+}
+
 // AddressRecoverFromHeader recovers the address from the header seal.
 // It takes the header and returns the recovered address and an error if there is an issue.
+// The recovered addresses are cached, the recovery of the same header seal
+// coming at the cost of hashing the header only.
 func AddressRecoverFromHeader(h *types.Header) (types.Address, error) {
 	// get the extra part that contains the seal
 	extra, err := getValidatorExtra(h)
@@ -108,7 +130,19 @@ func AddressRecoverFromHeader(h *types.Header) (types.Address, error) {
 		return types.Address{}, err
 	}
 
-	return addressRecoverImpl(extra.Seal, msg)
+	key := string(msg) + string(extra.Seal)
+	if signer, ok := sealCache.Get(key); ok {
+		return signer.(types.Address), nil
+	}
+
+	signer, err := addressRecoverImpl(extra.Seal, msg)
+	if err != nil {
+		return types.Address{}, err
+	}
+
+	sealCache.Add(key, signer)
+
+	return signer, nil
 }
 
 // addressRecoverImpl recovers the address from the signature and message.
@@ -39,3 +39,46 @@ func Test_WriteSeal(t *testing.T) {
 		t.Fatalf("signer != miner, signer: %q, miner: %q", signer, miner)
 	}
 }
+
+func Test_AddressRecoverFromHeaderCached(t *testing.T) {
+	hdr := &types.Header{Number: 1}
+	if err := PutValidatorExtra(hdr, &ValidatorExtra{}); err != nil {
+		t.Fatal(err)
+	}
+
+	signers := make([]types.Address, 2)
+	sealed := make([]*types.Header, 2)
+
+	for i := range sealed {
+		key := keystore.NewKeyForDirectICAP(rand.Reader)
+		signers[i] = crypto.PubKeyToAddress(&key.PrivateKey.PublicKey)
+
+		var err error
+		if sealed[i], err = WriteSeal(key.PrivateKey, hdr); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The same header sealed by each signer recovers each, the second time
+	// from the cache.
+	for round := 0; round < 2; round++ {
+		for i, h := range sealed {
+			signer, err := AddressRecoverFromHeader(h)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if signer != signers[i] {
+				t.Fatalf("round %d: signer == %s, want %s", round, signer, signers[i])
+			}
+		}
+	}
+
+	// A header changed under the seal doesn't recover the signer.
+	changed := sealed[0].Copy()
+	changed.GasLimit++
+
+	if signer, err := AddressRecoverFromHeader(changed); err == nil && signer == signers[0] {
+		t.Fatalf("signer of the changed header == %s", signer)
+	}
+}
@@ -0,0 +1,69 @@
+package block
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+// ErrInvalidTxSignature is returned when the signature of a transaction
+// doesn't recover its sender.
+var ErrInvalidTxSignature = errors.New("invalid transaction signature")
+
+// TxSigner recovers the senders of the transactions from their signatures.
+type TxSigner interface {
+	Sender(tx *types.Transaction) (types.Address, error)
+}
+
+// TxSignatureError is returned for the transaction of the block, at the given
+// index, whose signature doesn't recover its sender. It's an
+// ErrInvalidTxSignature.
+type TxSignatureError struct {
+	Index int
+	Hash  types.Hash
+	Err   error
+}
+
+// Error describes the transaction of the invalid signature.
+func (e *TxSignatureError) Error() string {
+	return fmt.Sprintf("%s: transaction %d (%s): %s", ErrInvalidTxSignature, e.Index, e.Hash, e.Err)
+}
+
+// Is reports whether the target is ErrInvalidTxSignature.
+func (e *TxSignatureError) Is(target error) bool {
+	return target == ErrInvalidTxSignature
+}
+
+// VerifyTxSignature verifies the signature of the transaction recovers its
+// sender; the transactions without one recover to any sender. The state
+// transactions, unsigned, pass.
+func VerifyTxSignature(tx *types.Transaction, signer TxSigner) error {
+	if tx.Type == types.StateTx {
+		return nil
+	}
+
+	sender, err := signer.Sender(tx)
+	if err != nil {
+		return err
+	}
+
+	if tx.From != types.ZeroAddress && tx.From != sender {
+		return fmt.Errorf("signed by %s, sent from %s", sender, tx.From)
+	}
+
+	return nil
+}
+
+// VerifyTxSignatures verifies the signature of every transaction of the block
+// recovers its sender. It returns the TxSignatureError of the first one that
+// doesn't.
+func VerifyTxSignatures(blk *types.Block, signer TxSigner) error {
+	for i, tx := range blk.Transactions {
+		if err := VerifyTxSignature(tx, signer); err != nil {
+			return &TxSignatureError{Index: i, Hash: tx.Hash, Err: err}
+		}
+	}
+
+	return nil
+}
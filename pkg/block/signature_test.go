@@ -0,0 +1,78 @@
+package block
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/0xPolygon/polygon-edge/crypto"
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+)
+
+func Test_VerifyTxSignatures(t *testing.T) {
+	signer := crypto.NewEIP155Signer(100, true)
+
+	key := keystore.NewKeyForDirectICAP(rand.Reader)
+	from := types.Address(key.Address)
+
+	// signedTx returns a transfer signed with the key, sent from the given
+	// address.
+	signedTx := func(nonce uint64, sentFrom types.Address) *types.Transaction {
+		tx := &types.Transaction{Nonce: nonce, To: &types.ZeroAddress, Value: big.NewInt(1), Gas: 21_000, GasPrice: big.NewInt(1)}
+
+		signed, err := signer.SignTx(tx, key.PrivateKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		signed.From = sentFrom
+		signed.ComputeHash()
+
+		return signed
+	}
+
+	testCases := []struct {
+		name string
+		tx   *types.Transaction
+		ok   bool
+	}{
+		{"signed by its sender", signedTx(0, from), true},
+		{"without sender", signedTx(0, types.ZeroAddress), true},
+		{"state transaction", &types.Transaction{Type: types.StateTx, From: types.StringToAddress("1")}, true},
+		{"signed by another sender", signedTx(0, types.StringToAddress("1")), false},
+		{"unsigned", &types.Transaction{From: from, V: big.NewInt(0), R: big.NewInt(0), S: big.NewInt(0)}, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := VerifyTxSignature(tc.tx, signer); (err == nil) != tc.ok {
+				t.Fatalf("error == %v, want valid: %t", err, tc.ok)
+			}
+		})
+	}
+
+	// The first transaction of an invalid signature is reported.
+	invalid := signedTx(2, types.StringToAddress("1"))
+
+	blk := &types.Block{
+		Header:       &types.Header{},
+		Transactions: []*types.Transaction{signedTx(0, from), signedTx(1, from), invalid, signedTx(3, types.StringToAddress("2"))},
+	}
+
+	err := VerifyTxSignatures(blk, signer)
+	if !errors.Is(err, ErrInvalidTxSignature) {
+		t.Fatalf("error == %v, want %v", err, ErrInvalidTxSignature)
+	}
+
+	var sigErr *TxSignatureError
+	if !errors.As(err, &sigErr) || sigErr.Index != 2 || sigErr.Hash != invalid.Hash {
+		t.Fatalf("error == %v, want transaction 2 (%s)", err, invalid.Hash)
+	}
+
+	blk.Transactions = blk.Transactions[:2]
+	if err := VerifyTxSignatures(blk, signer); err != nil {
+		t.Fatalf("error == %v, want nil", err)
+	}
+}
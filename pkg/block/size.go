@@ -0,0 +1,58 @@
+package block
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+// ErrBlockTooLarge is returned when the encoded block exceeds the size limit.
+var ErrBlockTooLarge = errors.New("block too large")
+
+const (
+	// sealSize is the room the seal takes in the encoded block, along with
+	// the longer length prefixes of the encodings holding it.
+	sealSize = 65 + 16
+
+	// listPrefixGrowth is the most the length prefixes of the block and of
+	// its transaction list grow by as transactions are added.
+	listPrefixGrowth = 2 * 8
+)
+
+// EncodedSize returns the size of the RLP encoding of the block.
+func EncodedSize(blk *types.Block) uint64 {
+	return uint64(len(blk.MarshalRLP()))
+}
+
+// EncodedTxSize returns the size the transaction takes in the RLP encoding
+// of a block.
+func EncodedTxSize(tx *types.Transaction) uint64 {
+	return uint64(len(tx.MarshalRLP()))
+}
+
+// TxsSizeBudget returns the bytes left for the transactions of the block
+// with the given header, yet to be sealed, for it to encode within the size
+// limit.
+func TxsSizeBudget(h *types.Header, max uint64) uint64 {
+	base := EncodedSize(&types.Block{Header: h}) + sealSize + listPrefixGrowth
+	if base >= max {
+		return 0
+	}
+
+	return max - base
+}
+
+// VerifySize verifies the encoded block is within the size limit; zero means
+// no limit.
+func VerifySize(blk *types.Block, max uint64) error {
+	if max == 0 {
+		return nil
+	}
+
+	if size := EncodedSize(blk); size > max {
+		return fmt.Errorf("%w: %d bytes encoded, limit %d", ErrBlockTooLarge, size, max)
+	}
+
+	return nil
+}
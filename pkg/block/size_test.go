@@ -0,0 +1,66 @@
+package block
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+)
+
+func Test_TxsSizeBudget(t *testing.T) {
+	const max = 64 * 1024
+
+	hdr := &types.Header{Number: 1}
+	if err := AssignExtraValidators(hdr, []types.Address{types.StringToAddress("1")}); err != nil {
+		t.Fatal(err)
+	}
+
+	budget := TxsSizeBudget(hdr, max)
+	if budget == 0 || budget >= max {
+		t.Fatalf("budget = %d", budget)
+	}
+
+	// Transactions filling up the budget keep the sealed block within the
+	// limit.
+	blk := &types.Block{Header: hdr}
+
+	var used uint64
+
+	for {
+		tx := &types.Transaction{Input: make([]byte, 1_000), Gas: 21_000, V: big.NewInt(27), R: big.NewInt(1), S: big.NewInt(1)}
+		if used+EncodedTxSize(tx) > budget {
+			break
+		}
+
+		used += EncodedTxSize(tx)
+		blk.Transactions = append(blk.Transactions, tx)
+	}
+
+	key := keystore.NewKeyForDirectICAP(rand.Reader)
+
+	sealed, err := WriteSeal(key.PrivateKey, hdr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blk.Header = sealed
+
+	if err := VerifySize(blk, max); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := VerifySize(blk, EncodedSize(blk)-1); !errors.Is(err, ErrBlockTooLarge) {
+		t.Fatalf("expected ErrBlockTooLarge, got %v", err)
+	}
+
+	if err := VerifySize(blk, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if TxsSizeBudget(hdr, 100) != 0 {
+		t.Fatal("expected no budget under the size of the header")
+	}
+}
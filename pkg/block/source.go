@@ -4,4 +4,5 @@ package block
 const (
 	SourceAvail      = "Avail"
 	SourceWatchTower = "WatchTower"
+	SourceArchive    = "Archive"
 )
@@ -0,0 +1,42 @@
+package block
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+// DefaultMaxTimestampDrift is the default time the timestamp of a block may
+// run ahead of the local clock.
+const DefaultMaxTimestampDrift = 15 * time.Minute
+
+var (
+	// ErrTimestampTooFarAhead is returned when the timestamp of the block
+	// runs ahead of the local clock past the drift allowed.
+	ErrTimestampTooFarAhead = errors.New("block timestamp too far in the future")
+
+	// ErrTimestampBeforeParent is returned when the timestamp of the block
+	// goes below the one of its parent.
+	ErrTimestampBeforeParent = errors.New("block timestamp before the parent's")
+)
+
+// VerifyTimestamp verifies the timestamp of the header doesn't go below the
+// one of its parent, nor run ahead of now by more than maxDrift; a zero
+// maxDrift leaves the timestamps ahead of now unchecked.
+func VerifyTimestamp(h, parent *types.Header, now time.Time, maxDrift time.Duration) error {
+	if h.Timestamp < parent.Timestamp {
+		return fmt.Errorf("%w: timestamp %d, parent's %d", ErrTimestampBeforeParent, h.Timestamp, parent.Timestamp)
+	}
+
+	if maxDrift == 0 {
+		return nil
+	}
+
+	if ahead := time.Unix(int64(h.Timestamp), 0).Sub(now); ahead > maxDrift {
+		return fmt.Errorf("%w: %s ahead of the local clock, at most %s", ErrTimestampTooFarAhead, ahead.Round(time.Second), maxDrift)
+	}
+
+	return nil
+}
@@ -0,0 +1,41 @@
+package block
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+func Test_VerifyTimestamp(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	parent := &types.Header{Timestamp: uint64(now.Unix()) - 10}
+
+	header := func(at time.Time) *types.Header {
+		return &types.Header{Timestamp: uint64(at.Unix())}
+	}
+
+	testCases := []struct {
+		name     string
+		h        *types.Header
+		maxDrift time.Duration
+		want     error
+	}{
+		{"now", header(now), time.Minute, nil},
+		{"same as the parent", header(now.Add(-10 * time.Second)), time.Minute, nil},
+		{"within the drift", header(now.Add(time.Minute)), time.Minute, nil},
+		{"an hour in the future", header(now.Add(time.Hour)), time.Minute, ErrTimestampTooFarAhead},
+		{"an hour in the future, unchecked", header(now.Add(time.Hour)), 0, nil},
+		{"before the parent", header(now.Add(-11 * time.Second)), time.Minute, ErrTimestampBeforeParent},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := VerifyTimestamp(tc.h, parent, now, tc.maxDrift)
+			if !errors.Is(err, tc.want) {
+				t.Fatalf("error == %v, want %v", err, tc.want)
+			}
+		})
+	}
+}
@@ -47,8 +47,17 @@ var (
 	ErrInvalidStateRoot     = errors.New("invalid block state root")
 	ErrInvalidGasUsed       = errors.New("invalid block gas used")
 	ErrInvalidReceiptsRoot  = errors.New("invalid block receipts root")
+	ErrReorgTooDeep         = errors.New("reorg too deep")
+	ErrStorage              = errors.New("storage failure")
+	ErrUntrustedStateRoot   = errors.New("state root differs from the trusted one")
 )
 
+// storageError marks the error of the storage failing a write as ErrStorage,
+// telling it apart from the blocks failing to write on their own.
+func storageError(err error) error {
+	return fmt.Errorf("%w: %v", ErrStorage, err)
+}
+
 // Blockchain is a blockchain reference
 type Blockchain struct {
 	logger hclog.Logger // The logger object
@@ -82,6 +91,16 @@ type Blockchain struct {
 
 	gpAverage *gasPriceAverage // A reference to the average gas price
 
+	hooks commitHooks // Hooks run around the block writes
+
+	// headerOnly is set for the blocks to be written without being executed;
+	// see SetHeaderOnly.
+	headerOnly atomic.Bool
+
+	// trusted is the trusted height and state root of the trusted-root fast
+	// sync, if any; see SetTrustedRoot.
+	trusted atomic.Pointer[trustedRoot]
+
 	writeLock sync.Mutex
 }
 
@@ -101,7 +120,7 @@ type Verifier interface {
 }
 
 type Executor interface {
-	ProcessBlock(parentRoot types.Hash, block *types.Block, blockCreator types.Address) (*state.Transition, error)
+	BeginTxn(parentRoot types.Hash, header *types.Header, coinbase types.Address) (*state.Transition, error)
 }
 
 type TxSigner interface {
@@ -305,6 +324,56 @@ func (b *Blockchain) SetConsensus(c Verifier) {
 	b.consensus = c
 }
 
+// SetHeaderOnly sets whether the blocks are written header-only: with their
+// headers and bodies, but without being executed, so without their receipts,
+// the state of the blocks being left to the state storage to provide on
+// demand. The blocks executed by VerifyFinalizedBlock are unaffected.
+func (b *Blockchain) SetHeaderOnly(headerOnly bool) {
+	b.headerOnly.Store(headerOnly)
+}
+
+// trustedRoot is the state root trusted at a height.
+type trustedRoot struct {
+	height    uint64
+	stateRoot types.Hash
+}
+
+// SetTrustedRoot sets the height and the state root trusted by the
+// trusted-root fast sync: the blocks at or below the height are written
+// without being executed, as the header-only ones, and the block at the
+// height is refused unless it has the trusted state root, the state at it
+// being left to the state storage to provide. A zero height disables it.
+func (b *Blockchain) SetTrustedRoot(height uint64, stateRoot types.Hash) {
+	if height == 0 {
+		b.trusted.Store(nil)
+		return
+	}
+
+	b.trusted.Store(&trustedRoot{height: height, stateRoot: stateRoot})
+}
+
+// checkTrusted checks the block at the trusted height has the trusted state
+// root.
+func (b *Blockchain) checkTrusted(block *types.Block) error {
+	trusted := b.trusted.Load()
+	if trusted == nil || block.Number() != trusted.height || block.Header.StateRoot == trusted.stateRoot {
+		return nil
+	}
+
+	return fmt.Errorf("%w: block %d has %s, trusted %s", ErrUntrustedStateRoot, block.Number(), block.Header.StateRoot, trusted.stateRoot)
+}
+
+// executed reports whether the block is executed on the write.
+func (b *Blockchain) executed(block *types.Block) bool {
+	if b.headerOnly.Load() {
+		return false
+	}
+
+	trusted := b.trusted.Load()
+
+	return trusted == nil || block.Number() > trusted.height
+}
+
 // setCurrentHeader sets the current header
 func (b *Blockchain) setCurrentHeader(h *types.Header, diff *big.Int) {
 	// Update the header (atomic)
@@ -331,6 +400,11 @@ func (b *Blockchain) Config() *chain.Params {
 	return b.config.Params
 }
 
+// TxSigner returns the signer recovering the senders of the transactions of the chain
+func (b *Blockchain) TxSigner() TxSigner {
+	return b.txSigner
+}
+
 // GetHeader returns the block header using the hash
 func (b *Blockchain) GetHeader(hash types.Hash, number uint64) (*types.Header, bool) {
 	return b.GetHeaderByHash(hash)
@@ -411,7 +485,7 @@ func (b *Blockchain) writeGenesisImpl(header *types.Header) error {
 
 	// Update the DB
 	if err := b.db.WriteHeader(header); err != nil {
-		return err
+		return storageError(err)
 	}
 
 	// Advance the head
@@ -455,7 +529,7 @@ func (b *Blockchain) writeCanonicalHeader(event *blockchain.Event, h *types.Head
 
 	newTD := big.NewInt(0).Add(parentTD, new(big.Int).SetUint64(h.Difficulty))
 	if err := b.db.WriteCanonicalHeader(h, newTD); err != nil {
-		return err
+		return storageError(err)
 	}
 
 	event.Type = blockchain.EventHead
@@ -471,17 +545,17 @@ func (b *Blockchain) writeCanonicalHeader(event *blockchain.Event, h *types.Head
 func (b *Blockchain) advanceHead(newHeader *types.Header) (*big.Int, error) {
 	// Write the current head hash into storage
 	if err := b.db.WriteHeadHash(newHeader.Hash); err != nil {
-		return nil, err
+		return nil, storageError(err)
 	}
 
 	// Write the current head number into storage
 	if err := b.db.WriteHeadNumber(newHeader.Number); err != nil {
-		return nil, err
+		return nil, storageError(err)
 	}
 
 	// Matches the current head number with the current hash
 	if err := b.db.WriteCanonicalHash(newHeader.Number, newHeader.Hash); err != nil {
-		return nil, err
+		return nil, storageError(err)
 	}
 
 	// Check if there was a parent difficulty
@@ -499,7 +573,7 @@ func (b *Blockchain) advanceHead(newHeader *types.Header) (*big.Int, error) {
 	// Calculate the new total difficulty
 	newTD := big.NewInt(0).Add(parentTD, big.NewInt(0).SetUint64(newHeader.Difficulty))
 	if err := b.db.WriteTotalDifficulty(newHeader.Hash, newTD); err != nil {
-		return nil, err
+		return nil, storageError(err)
 	}
 
 	// Update the blockchain reference
@@ -610,6 +684,27 @@ func (b *Blockchain) GetHeaderByNumber(n uint64) (*types.Header, bool) {
 	return h, true
 }
 
+// ReadCanonicalHeader reads the canonical header at the given height from the
+// storage, past the caches, for the integrity check of the storage: it
+// returns the canonical hash recorded at the height, false when missing, and
+// the header stored under it, its hash computed out of its fields, nil when
+// missing or undecodable.
+func (b *Blockchain) ReadCanonicalHeader(n uint64) (types.Hash, *types.Header, bool) {
+	hash, ok := b.db.ReadCanonicalHash(n)
+	if !ok {
+		return types.ZeroHash, nil, false
+	}
+
+	hdr, err := b.db.ReadHeader(hash)
+	if err != nil {
+		return hash, nil, true
+	}
+
+	hdr.ComputeHash()
+
+	return hash, hdr, true
+}
+
 // WriteHeaders writes an array of headers
 func (b *Blockchain) WriteHeaders(headers []*types.Header) error {
 	return b.WriteHeadersWithBodies(headers)
@@ -683,6 +778,18 @@ func (b *Blockchain) VerifyFinalizedBlock(block *types.Block) (*types.FullBlock,
 	return &types.FullBlock{Block: block, Receipts: receipts}, nil
 }
 
+// ExecuteBlock re-executes the transactions of the block on the state of its parent
+// and reports back the block execution result, without verifying the block against it.
+func (b *Blockchain) ExecuteBlock(blk *types.Block) (*BlockResult, error) {
+	return b.executeBlockTransactions(blk)
+}
+
+// ExecuteBlockOn is ExecuteBlock on the state held by the given executor,
+// such as one regenerating the pruned state in memory.
+func (b *Blockchain) ExecuteBlockOn(executor Executor, blk *types.Block) (*BlockResult, error) {
+	return b.executeBlockTransactionsOn(executor, blk)
+}
+
 // verifyBlock does the base (common) block verification steps by
 // verifying the block body as well as the parent information
 func (b *Blockchain) verifyBlock(block *types.Block) ([]*types.Receipt, error) {
@@ -872,9 +979,14 @@ func (br *BlockResult) verifyBlockResult(referenceBlock *types.Block) error {
 }
 
 // executeBlockTransactions executes the transactions in the block locally,
-// and reports back the block execution result
-func (b *Blockchain) executeBlockTransactions(block *types.Block) (*BlockResult, error) {
-	header := block.Header
+// settling their fees against the base fee of the block, and reports back
+// the block execution result
+func (b *Blockchain) executeBlockTransactions(blk *types.Block) (*BlockResult, error) {
+	return b.executeBlockTransactionsOn(b.executor, blk)
+}
+
+func (b *Blockchain) executeBlockTransactionsOn(executor Executor, blk *types.Block) (*BlockResult, error) {
+	header := blk.Header
 
 	parent, ok := b.readHeader(header.ParentHash)
 	if !ok {
@@ -886,12 +998,26 @@ func (b *Blockchain) executeBlockTransactions(block *types.Block) (*BlockResult,
 		return nil, err
 	}
 
-	txn, err := b.executor.ProcessBlock(parent.StateRoot, block, blockCreator)
+	begun, err := executor.BeginTxn(parent.StateRoot, header, blockCreator)
 	if err != nil {
 		return nil, err
 	}
 
-	if err := b.consensus.PreCommitState(header, txn); err != nil {
+	txn := block.NewTransition(begun)
+
+	for _, tx := range blk.Transactions {
+		// As the executor of edge does, the transactions over the gas limit
+		// of the block are skipped.
+		if tx.Gas > header.GasLimit {
+			continue
+		}
+
+		if err := txn.Write(tx); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := b.consensus.PreCommitState(header, begun); err != nil {
 		return nil, err
 	}
 
@@ -923,6 +1049,14 @@ func (b *Blockchain) WriteFullBlock(fblock *types.FullBlock, source string) erro
 		return nil
 	}
 
+	if err := b.checkTrusted(block); err != nil {
+		return err
+	}
+
+	if err := b.hooks.runPreCommit(block); err != nil {
+		return err
+	}
+
 	header := block.Header
 
 	if err := b.writeBody(block); err != nil {
@@ -939,7 +1073,7 @@ func (b *Blockchain) WriteFullBlock(fblock *types.FullBlock, source string) erro
 	// Otherwise, a client might ask for a header once the receipt is valid,
 	// but before it is written into the storage
 	if err := b.db.WriteReceipts(block.Hash(), fblock.Receipts); err != nil {
-		return err
+		return storageError(err)
 	}
 
 	// update snapshot
@@ -948,6 +1082,7 @@ func (b *Blockchain) WriteFullBlock(fblock *types.FullBlock, source string) erro
 	}
 
 	b.dispatchEvent(evnt)
+	b.hooks.runPostCommit(block, fblock.Receipts, b.logger)
 
 	// Update the average gas price
 	b.updateGasPriceAvgWithBlock(block)
@@ -982,6 +1117,14 @@ func (b *Blockchain) WriteBlock(block *types.Block, source string) error {
 		return nil
 	}
 
+	if err := b.checkTrusted(block); err != nil {
+		return err
+	}
+
+	if err := b.hooks.runPreCommit(block); err != nil {
+		return err
+	}
+
 	header := block.Header
 
 	if err := b.writeBody(block); err != nil {
@@ -994,16 +1137,11 @@ func (b *Blockchain) WriteBlock(block *types.Block, source string) error {
 		return err
 	}
 
-	// Fetch the block receipts
-	blockReceipts, receiptsErr := b.extractBlockReceipts(block)
-	if receiptsErr != nil {
-		return receiptsErr
-	}
-
 	// write the receipts, do it only after the header has been written.
 	// Otherwise, a client might ask for a header once the receipt is valid,
 	// but before it is written into the storage
-	if err := b.db.WriteReceipts(block.Hash(), blockReceipts); err != nil {
+	blockReceipts, err := b.writeReceipts(block)
+	if err != nil {
 		return err
 	}
 
@@ -1013,6 +1151,7 @@ func (b *Blockchain) WriteBlock(block *types.Block, source string) error {
 	}
 
 	b.dispatchEvent(evnt)
+	b.hooks.runPostCommit(block, blockReceipts, b.logger)
 
 	// Update the average gas price
 	b.updateGasPriceAvgWithBlock(block)
@@ -1035,6 +1174,156 @@ func (b *Blockchain) WriteBlock(block *types.Block, source string) error {
 	return nil
 }
 
+// WriteForkBlock writes a block competing with the canonical chain on top of
+// its known parent, executing it on the state of the parent, without making
+// it canonical; Reorg does that. The transaction lookups are left to the
+// canonical blocks.
+func (b *Blockchain) WriteForkBlock(block *types.Block, source string) error {
+	b.writeLock.Lock()
+	defer b.writeLock.Unlock()
+
+	header := block.Header
+
+	if _, ok := b.readHeader(header.Hash); ok {
+		return nil
+	}
+
+	if err := b.checkTrusted(block); err != nil {
+		return err
+	}
+
+	parentTD, ok := b.readTotalDifficulty(header.ParentHash)
+	if !ok {
+		return ErrParentNotFound
+	}
+
+	if err := b.recoverFromFieldsInBlock(block); err != nil {
+		return err
+	}
+
+	if err := b.db.WriteBody(header.Hash, block.Body()); err != nil {
+		return storageError(err)
+	}
+
+	if err := b.db.WriteHeader(header); err != nil {
+		return storageError(err)
+	}
+
+	td := big.NewInt(0).Add(parentTD, big.NewInt(0).SetUint64(header.Difficulty))
+	if err := b.db.WriteTotalDifficulty(header.Hash, td); err != nil {
+		return storageError(err)
+	}
+
+	b.headersCache.Add(header.Hash, header)
+
+	if _, err := b.writeReceipts(block); err != nil {
+		return err
+	}
+
+	if err := b.writeFork(header); err != nil {
+		return err
+	}
+
+	b.logger.Info("new fork block", "number", header.Number, "hash", header.Hash, "parent", header.ParentHash, "source", source)
+
+	return nil
+}
+
+// Reorg makes the known block of the given header the head of the chain. The
+// canonical blocks are rolled back down to the common ancestor, and the blocks
+// of the new branch applied on top of it. It returns the number of the blocks
+// rolled back, failing with ErrReorgTooDeep beyond maxDepth.
+func (b *Blockchain) Reorg(newHead *types.Header, maxDepth uint64, source string) (uint64, error) {
+	b.writeLock.Lock()
+	defer b.writeLock.Unlock()
+
+	oldHead := b.Header()
+
+	var (
+		oldChain, newChain []*types.Header
+		ok                 bool
+	)
+
+	oldHeader, newHeader := oldHead, newHead
+	for oldHeader.Hash != newHeader.Hash {
+		switch {
+		case oldHeader.Number > newHeader.Number:
+			oldChain = append(oldChain, oldHeader)
+			oldHeader, ok = b.readHeader(oldHeader.ParentHash)
+
+		case newHeader.Number > oldHeader.Number:
+			newChain = append(newChain, newHeader)
+			newHeader, ok = b.readHeader(newHeader.ParentHash)
+
+		default:
+			oldChain = append(oldChain, oldHeader)
+			newChain = append(newChain, newHeader)
+
+			if oldHeader, ok = b.readHeader(oldHeader.ParentHash); ok {
+				newHeader, ok = b.readHeader(newHeader.ParentHash)
+			}
+		}
+
+		if !ok {
+			return 0, fmt.Errorf("no common ancestor of '%s' and '%s' found", oldHead.Hash, newHead.Hash)
+		}
+	}
+
+	depth := uint64(len(oldChain))
+	if depth > maxDepth {
+		return depth, fmt.Errorf("%w: %d blocks, at most %d", ErrReorgTooDeep, depth, maxDepth)
+	}
+
+	evnt := &blockchain.Event{Source: source}
+
+	for _, h := range oldChain {
+		evnt.AddOldHeader(h)
+	}
+
+	// Apply the new branch from the common ancestor up.
+	for i := len(newChain) - 1; i >= 0; i-- {
+		h := newChain[i]
+
+		if err := b.db.WriteCanonicalHash(h.Number, h.Hash); err != nil {
+			return depth, storageError(err)
+		}
+
+		if body, ok := b.readBody(h.Hash); ok {
+			for _, txn := range body.Transactions {
+				if err := b.db.WriteTxLookup(txn.Hash, h.Hash); err != nil {
+					return depth, storageError(err)
+				}
+			}
+		}
+
+		evnt.AddNewHeader(h)
+	}
+
+	// The rolled back heights above the new head have no canonical block.
+	for n := newHead.Number + 1; n <= oldHead.Number; n++ {
+		if err := b.db.WriteCanonicalHash(n, types.ZeroHash); err != nil {
+			return depth, storageError(err)
+		}
+	}
+
+	diff, err := b.advanceHead(newHead)
+	if err != nil {
+		return depth, err
+	}
+
+	if err := b.writeFork(oldHead); err != nil {
+		return depth, fmt.Errorf("failed to write the old header as fork: %w", err)
+	}
+
+	evnt.Type = blockchain.EventReorg
+	evnt.SetDifficulty(diff)
+	b.dispatchEvent(evnt)
+
+	b.logger.Info("reorg", "depth", depth, "old_head", oldHead.Hash, "new_head", newHead.Hash, "number", newHead.Number, "source", source)
+
+	return depth, nil
+}
+
 // GetCachedReceipts retrieves cached receipts for given headerHash
 func (b *Blockchain) GetCachedReceipts(headerHash types.Hash) ([]*types.Receipt, error) {
 	receipts, found := b.receiptsCache.Get(headerHash)
@@ -1073,6 +1362,26 @@ func (b *Blockchain) extractBlockReceipts(block *types.Block) ([]*types.Receipt,
 	return extractedReceipts, nil
 }
 
+// writeReceipts writes the receipts of the block, executing it unless they're
+// cached, and returns them; the blocks written header-only, or at or below
+// the trusted height, have none.
+func (b *Blockchain) writeReceipts(block *types.Block) ([]*types.Receipt, error) {
+	if !b.executed(block) {
+		return nil, nil
+	}
+
+	receipts, err := b.extractBlockReceipts(block)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := b.db.WriteReceipts(block.Hash(), receipts); err != nil {
+		return nil, storageError(err)
+	}
+
+	return receipts, nil
+}
+
 // updateGasPriceAvgWithBlock extracts the gas price information from the
 // block, and updates the average gas price for the chain accordingly
 func (b *Blockchain) updateGasPriceAvgWithBlock(block *types.Block) {
@@ -1102,13 +1411,13 @@ func (b *Blockchain) writeBody(block *types.Block) error {
 
 	// Write the full body (txns + receipts)
 	if err := b.db.WriteBody(block.Header.Hash, block.Body()); err != nil {
-		return err
+		return storageError(err)
 	}
 
 	// Write txn lookups (txHash -> block)
 	for _, txn := range block.Transactions {
 		if err := b.db.WriteTxLookup(txn.Hash, block.Hash()); err != nil {
-			return err
+			return storageError(err)
 		}
 	}
 
@@ -1122,6 +1431,14 @@ func (b *Blockchain) ReadTxLookup(hash types.Hash) (types.Hash, bool) {
 	return v, ok
 }
 
+// RecoverSenders recovers the senders of the transactions of the block ahead
+// of writing it, so that the write doesn't recover them again. It's safe to
+// call concurrently for different blocks, and returns an error on the first
+// invalid signature.
+func (b *Blockchain) RecoverSenders(block *types.Block) error {
+	return b.recoverFromFieldsInBlock(block)
+}
+
 // recoverFromFieldsInBlock recovers 'from' fields in the transactions of the given block
 // return error if the invalid signature found
 func (b *Blockchain) recoverFromFieldsInBlock(block *types.Block) error {
@@ -1253,7 +1570,7 @@ func (b *Blockchain) writeHeaderImpl(evnt *blockchain.Event, header *types.Heade
 	}
 
 	if err := b.db.WriteHeader(header); err != nil {
-		return err
+		return storageError(err)
 	}
 
 	currentTD, ok := b.readTotalDifficulty(currentHeader.Hash)
@@ -1279,7 +1596,7 @@ func (b *Blockchain) writeHeaderImpl(evnt *blockchain.Event, header *types.Heade
 			big.NewInt(0).SetUint64(header.Difficulty),
 		),
 	); err != nil {
-		return err
+		return storageError(err)
 	}
 
 	// Update the headers cache
@@ -1311,7 +1628,7 @@ func (b *Blockchain) writeFork(header *types.Header) error {
 		if errors.Is(err, storage.ErrNotFound) {
 			forks = []types.Hash{}
 		} else {
-			return err
+			return storageError(err)
 		}
 	}
 
@@ -1325,7 +1642,7 @@ func (b *Blockchain) writeFork(header *types.Header) error {
 
 	newForks = append(newForks, header.Hash)
 	if err := b.db.WriteForks(newForks); err != nil {
-		return err
+		return storageError(err)
 	}
 
 	return nil
@@ -1412,7 +1729,7 @@ func (b *Blockchain) handleReorg(
 	// Update canonical chain numbers
 	for _, h := range newChain {
 		if err := b.db.WriteCanonicalHash(h.Number, h.Hash); err != nil {
-			return err
+			return storageError(err)
 		}
 	}
 
@@ -1481,13 +1798,31 @@ func (b *Blockchain) Close() error {
 	return b.db.Close()
 }
 
-// CalculateBaseFee calculates the basefee of the header.
+// CalculateBaseFee calculates the base fee of the block following the parent.
+// It's zero before the London fork, and the initial base fee of the genesis on
+// the first block of the fork, or following a parent without one.
 func (b *Blockchain) CalculateBaseFee(parent *types.Header) uint64 {
-	if !b.config.Params.Forks.IsActive(chain.London, parent.Number) {
+	if !b.config.Params.Forks.IsActive(chain.London, parent.Number+1) {
+		return 0
+	}
+
+	if !b.config.Params.Forks.IsActive(chain.London, parent.Number) || parent.BaseFee == 0 {
+		if b.config.Genesis.BaseFee != 0 {
+			return b.config.Genesis.BaseFee
+		}
+
 		return chain.GenesisBaseFee
 	}
 
-	parentGasTarget := parent.GasLimit / b.config.Genesis.BaseFeeEM
+	elasticity := b.config.Genesis.BaseFeeEM
+	if elasticity == 0 {
+		elasticity = chain.GenesisBaseFeeEM
+	}
+
+	parentGasTarget := parent.GasLimit / elasticity
+	if parentGasTarget == 0 {
+		return parent.BaseFee
+	}
 
 	// If the parent gasUsed is the same as the target, the baseFee remains unchanged.
 	if parent.GasUsed == parentGasTarget {
@@ -1309,9 +1309,9 @@ func TestBlockchain_VerifyBlockBody(t *testing.T) {
 
 		executorCallback := func(executor *mockExecutor) {
 			// This is executor processing
-			executor.HookProcessBlock(func(
+			executor.HookBeginTxn(func(
 				hash types.Hash,
-				block *types.Block,
+				header *types.Header,
 				address types.Address,
 			) (*state.Transition, error) {
 				return nil, errUnableToExecute
@@ -1359,6 +1359,9 @@ func TestBlockchain_CalculateBaseFee(t *testing.T) {
 		{6, chain.GenesisBaseFee, 20000000, 20000000, 1375000000, 4},           // usage full
 		{6, chain.GenesisBaseFee, 20000000, 0, 875000000, 2},                   // usage 0
 		{6, chain.GenesisBaseFee, 20000000, 0, 875000000, 4},                   // usage 0
+		{3, 0, 20000000, 10000000, 0, 2},                                       // before london
+		{4, 0, 20000000, 10000000, chain.GenesisBaseFee, 2},                    // first london block
+		{6, 0, 20000000, 10000000, chain.GenesisBaseFee, 2},                    // parent without base fee
 	}
 
 	for i, test := range tests {
@@ -0,0 +1,106 @@
+package blockchain
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/hashicorp/go-hclog"
+)
+
+// PreCommitHook is called with the block right before it's written to the
+// chain; an error vetoes the write of the block.
+type PreCommitHook func(blk *types.Block) error
+
+// PostCommitHook is called with the block and its receipts once the block is
+// written to the chain.
+type PostCommitHook func(blk *types.Block, receipts []*types.Receipt)
+
+// commitHooks are the hooks run around the writes of the blocks extending the
+// canonical chain, in the order they were registered. The blocks made
+// canonical by a reorg aren't passed through them; the reorg event reports
+// those.
+type commitHooks struct {
+	lock sync.Mutex
+	pre  []PreCommitHook
+	post []PostCommitHook
+
+	// last is closed once the post-commit hooks of the last block written
+	// are done; the hooks of the next block wait on it, so the blocks reach
+	// the hooks in the order they were written.
+	last chan struct{}
+}
+
+// RegisterPreCommitHook registers the hook to run before every block written
+// to the chain. The hooks run in the order they were registered, and the
+// first one failing aborts the write with its error.
+func (b *Blockchain) RegisterPreCommitHook(hook PreCommitHook) {
+	b.hooks.lock.Lock()
+	defer b.hooks.lock.Unlock()
+
+	b.hooks.pre = append(b.hooks.pre, hook)
+}
+
+// RegisterPostCommitHook registers the hook to run after every block written
+// to the chain. The hooks run asynchronously, in the order they were
+// registered, and a hook panicking is recovered and logged without affecting
+// the chain or the other hooks.
+func (b *Blockchain) RegisterPostCommitHook(hook PostCommitHook) {
+	b.hooks.lock.Lock()
+	defer b.hooks.lock.Unlock()
+
+	b.hooks.post = append(b.hooks.post, hook)
+}
+
+// runPreCommit runs the pre-commit hooks on the block, returning the error of
+// the first one vetoing it.
+func (h *commitHooks) runPreCommit(blk *types.Block) error {
+	h.lock.Lock()
+	hooks := h.pre
+	h.lock.Unlock()
+
+	for i, hook := range hooks {
+		if err := hook(blk); err != nil {
+			return fmt.Errorf("pre-commit hook %d vetoed block %d: %w", i, blk.Number(), err)
+		}
+	}
+
+	return nil
+}
+
+// runPostCommit runs the post-commit hooks on the written block in the
+// background, once the ones of the blocks written before it are done.
+func (h *commitHooks) runPostCommit(blk *types.Block, receipts []*types.Receipt, logger hclog.Logger) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if len(h.post) == 0 {
+		return
+	}
+
+	hooks, prev, done := h.post, h.last, make(chan struct{})
+	h.last = done
+
+	go func() {
+		defer close(done)
+
+		if prev != nil {
+			<-prev
+		}
+
+		for i, hook := range hooks {
+			runPostCommitHook(i, hook, blk, receipts, logger)
+		}
+	}()
+}
+
+// runPostCommitHook runs the post-commit hook, recovering it from a panic.
+func runPostCommitHook(i int, hook PostCommitHook, blk *types.Block, receipts []*types.Receipt, logger hclog.Logger) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Error("post-commit hook panicked", "hook", i, "block_number", blk.Number(), "block_hash", blk.Hash(), "panic", r)
+		}
+	}()
+
+	hook(blk, receipts)
+}
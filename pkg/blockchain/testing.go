@@ -313,28 +313,28 @@ func (m *MockVerifier) HookPreCommitState(fn preStateCommitDelegate) {
 
 // Executor delegators
 
-type processBlockDelegate func(types.Hash, *types.Block, types.Address) (*state.Transition, error)
+type beginTxnDelegate func(types.Hash, *types.Header, types.Address) (*state.Transition, error)
 
 // mockExecutor is a mock implementation of the Executor interface.
 type mockExecutor struct {
-	processBlockFn processBlockDelegate
+	beginTxnFn beginTxnDelegate
 }
 
-// ProcessBlock processes the given block.
-// It takes the parent root, block, and block creator as parameters.
-// It returns the state transition and an error if processing fails.
-func (m *mockExecutor) ProcessBlock(parentRoot types.Hash, block *types.Block, blockCreator types.Address) (*state.Transition, error) {
-	if m.processBlockFn != nil {
-		return m.processBlockFn(parentRoot, block, blockCreator)
+// BeginTxn begins the state transition of a block.
+// It takes the parent root, header, and coinbase as parameters.
+// It returns the state transition and an error if beginning it fails.
+func (m *mockExecutor) BeginTxn(parentRoot types.Hash, header *types.Header, coinbase types.Address) (*state.Transition, error) {
+	if m.beginTxnFn != nil {
+		return m.beginTxnFn(parentRoot, header, coinbase)
 	}
 
 	return nil, nil
 }
 
-// HookProcessBlock sets the processBlock callback function.
+// HookBeginTxn sets the beginTxn callback function.
 // It takes the callback function as a parameter.
-func (m *mockExecutor) HookProcessBlock(fn processBlockDelegate) {
-	m.processBlockFn = fn
+func (m *mockExecutor) HookBeginTxn(fn beginTxnDelegate) {
+	m.beginTxnFn = fn
 }
 
 type mockSigner struct {
@@ -9,6 +9,8 @@ import (
 	"github.com/0xPolygon/polygon-edge/server"
 	"github.com/hashicorp/go-hclog"
 
+	"github.com/availproject/op-evm/consensus/avail"
+
 	"encoding/json"
 	"fmt"
 	"os"
@@ -48,6 +50,17 @@ type Config struct {
 	Relayer               bool   `json:"relayer" yaml:"relayer"`
 	NumBlockConfirmations uint64 `json:"num_block_confirmations" yaml:"num_block_confirmations"`
 	NodeType              string `json:"node_type" yaml:"node_type"`
+
+	Avail *AvailConfig `json:"avail" yaml:"avail"`
+}
+
+// AvailConfig defines the operational params of the Avail consensus, taking the place of the engine params of the
+// chain and of the flags when set. They're reloaded along with the log level on SIGHUP or `availAdmin_reloadConfig`.
+type AvailConfig struct {
+	Addrs                    []string `json:"addrs" yaml:"addrs"`
+	PriceLimit               *uint64  `json:"price_limit" yaml:"price_limit"`
+	BlockGasTarget           *uint64  `json:"block_gas_target" yaml:"block_gas_target"`
+	DisputeEscalationWebhook *string  `json:"dispute_escalation_webhook" yaml:"dispute_escalation_webhook"`
 }
 
 // DefaultConfig returns the default server configuration.
@@ -212,3 +225,33 @@ func NewServerConfig(path string) (*CustomServerConfig, error) {
 		NodeType: nodeType.String(),
 	}, nil
 }
+
+// NewOperationalConfig reads the operational configuration of the node, the part of the configuration file at the
+// specified path the reload of the config applies, and the immutable parameters it checks for changes.
+func NewOperationalConfig(path string) (*avail.OperationalConfig, error) {
+	rawConfig, err := ReadConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	chain, err := ParseGenesisConfig(rawConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	op := &avail.OperationalConfig{
+		LogLevel:      rawConfig.LogLevel,
+		ChainID:       chain.Params.ChainID,
+		DataDir:       rawConfig.DataDir,
+		SecretsConfig: rawConfig.SecretsConfigPath,
+	}
+
+	if rawConfig.Avail != nil {
+		op.AvailAddrs = rawConfig.Avail.Addrs
+		op.PriceLimit = rawConfig.Avail.PriceLimit
+		op.BlockGasTarget = rawConfig.Avail.BlockGasTarget
+		op.EscalationWebhook = rawConfig.Avail.DisputeEscalationWebhook
+	}
+
+	return op, nil
+}
@@ -0,0 +1,17 @@
+//go:build byzantine
+
+package devnet
+
+import (
+	"net/netip"
+
+	consensus "github.com/availproject/op-evm/consensus/avail"
+	"github.com/hashicorp/go-hclog"
+)
+
+// StartByzantineNodes starts the devnet nodes like StartNodes, with the first
+// sequencer turned byzantine by the given policy, for testing the fraud
+// pipeline end to end. It's available in the builds with the byzantine tag only.
+func StartByzantineNodes(logger hclog.Logger, bindAddr netip.Addr, availAddr, accountsPath string, policy consensus.ByzantinePolicy, nodeTypes ...consensus.MechanismType) (*Context, error) {
+	return startNodes(logger, bindAddr, availAddr, accountsPath, policy, nodeTypes...)
+}
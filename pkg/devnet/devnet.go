@@ -5,6 +5,7 @@
 package devnet
 
 import (
+	"context"
 	_ "embed"
 	"encoding/json"
 	"errors"
@@ -53,11 +54,18 @@ type instance struct {
 	config      *edge_server.Config
 	server      *server.Server
 	fraudAddr   string
+	byzantine   consensus.ByzantinePolicy
 }
 
 // StartNodes starts the devnet nodes based on the provided parameters.
 // It creates the Avail accounts, configures and starts the nodes, and returns the devnet context.
 func StartNodes(logger hclog.Logger, bindAddr netip.Addr, availAddr, accountsPath string, nodeTypes ...consensus.MechanismType) (*Context, error) {
+	return startNodes(logger, bindAddr, availAddr, accountsPath, nil, nodeTypes...)
+}
+
+// startNodes starts the devnet nodes, the first sequencer turned byzantine by
+// the given policy, if any.
+func startNodes(logger hclog.Logger, bindAddr netip.Addr, availAddr, accountsPath string, byzantine consensus.ByzantinePolicy, nodeTypes ...consensus.MechanismType) (*Context, error) {
 	ctx := &Context{}
 	if err := createAvailAccounts(logger, availAddr, accountsPath, nodeTypes); err != nil {
 		return nil, err
@@ -79,12 +87,18 @@ func StartNodes(logger hclog.Logger, bindAddr netip.Addr, availAddr, accountsPat
 			return nil, err
 		}
 
-		ctx.servers = append(ctx.servers, instance{
+		si := instance{
 			nodeType:    nt,
 			config:      cfg.Config,
 			accountPath: nnh.nextAccountPath(nt),
 			fraudAddr:   fraudAddr.String(),
-		})
+		}
+
+		if nt == consensus.Sequencer && byzantine != nil {
+			si.byzantine, byzantine = byzantine, nil
+		}
+
+		ctx.servers = append(ctx.servers, si)
 	}
 
 	// Release allocated [TCP] ports to be used in Edge nodes.
@@ -134,7 +148,7 @@ func StartNodes(logger hclog.Logger, bindAddr netip.Addr, availAddr, accountsPat
 		si.config.Chain.Bootnodes = []string{bootnodeAddr}
 		si.config.Network.Chain.Bootnodes = []string{bootnodeAddr}
 
-		srv, err := startNode(logger, si.config, availAddr, si.accountPath, si.fraudAddr, si.nodeType)
+		srv, err := startNode(logger, si.config, availAddr, si.accountPath, si.fraudAddr, si.nodeType, si.byzantine)
 		if err != nil {
 			return nil, err
 		}
@@ -262,15 +276,15 @@ func configureNode(pa *PortAllocator, nodeType consensus.MechanismType) (*pkg_co
 	return cfg, nil
 }
 
-// startNode starts a devnet node based on the provided config, Avail address, account path, fraud listener address, and node type.
-// It returns the started server instance.
-func startNode(logger hclog.Logger, cfg *edge_server.Config, availAddr, accountPath, fraudListenerAddr string, nodeType consensus.MechanismType) (*server.Server, error) {
+// startNode starts a devnet node based on the provided config, Avail address, account path, fraud listener address, node type
+// and byzantine policy, if any. It returns the started server instance.
+func startNode(logger hclog.Logger, cfg *edge_server.Config, availAddr, accountPath, fraudListenerAddr string, nodeType consensus.MechanismType, byzantine consensus.ByzantinePolicy) (*server.Server, error) {
 	var bootnode bool
 	if nodeType == consensus.BootstrapSequencer {
 		bootnode = true
 	}
 
-	availAccount, err := avail.AccountFromFile(accountPath)
+	availAccount, err := avail.NewSignatureProvider(avail.SignerConfig{Type: avail.SignerMnemonic, Path: accountPath})
 	if err != nil {
 		log.Fatalf("failed to read Avail account from %q: %s\n", accountPath, err)
 	}
@@ -280,7 +294,7 @@ func startNode(logger hclog.Logger, cfg *edge_server.Config, availAddr, accountP
 		log.Fatalf("failed to create Avail client: %s\n", err)
 	}
 
-	appID, err := avail.EnsureApplicationKeyExists(availClient, avail.ApplicationKey, availAccount)
+	appID, err := avail.EnsureApplicationKeyExists(context.Background(), availClient, avail.ApplicationKey, availAccount)
 	if err != nil {
 		log.Fatalf("failed to get AppID from Avail: %s\n", err)
 	}
@@ -296,9 +310,10 @@ func startNode(logger hclog.Logger, cfg *edge_server.Config, availAddr, accountP
 		FraudListenerAddr: fraudListenerAddr,
 		NodeType:          string(nodeType),
 		AvailAppID:        appID,
+		ByzantinePolicy:   byzantine,
 	}
 
-	serverInstance, err := server.NewServer(cfg, consensusCfg)
+	serverInstance, err := server.NewServer(cfg, server.DefaultRPCLimitConfig(), consensusCfg)
 	if err != nil {
 		return nil, fmt.Errorf("failure to start node: %w", err)
 	}
@@ -403,22 +418,20 @@ func createAvailAccounts(logger hclog.Logger, availAddr, accountPath string, nod
 		return err
 	}
 
-	var nonceIncrement uint64
 	errCh := make(chan error)
 	for _, nt := range nodeTypes {
 		accountWg.Add(1)
 
-		go func(accountPath string, nonceIncrement uint64) {
+		go func(accountPath string) {
 			defer accountWg.Done()
 			// Initiate creation of the avail account if not present
-			err := createAvailAccount(logger, availClient, accountPath, nonceIncrement)
+			err := createAvailAccount(logger, availClient, accountPath)
 			if err != nil {
 				errCh <- fmt.Errorf("failed to create new avail account: %w", err)
 				return
 			}
-		}(nnh.nextAccountPath(nt), nonceIncrement)
+		}(nnh.nextAccountPath(nt))
 
-		nonceIncrement++
 		time.Sleep(250 * time.Millisecond)
 	}
 
@@ -439,14 +452,14 @@ func createAvailAccounts(logger hclog.Logger, availAddr, accountPath string, nod
 }
 
 // createAvailAccount creates a new Avail account and deposits initial balance.
-func createAvailAccount(logger hclog.Logger, availClient avail.Client, accountPath string, nonceIncrement uint64) error {
+func createAvailAccount(logger hclog.Logger, availClient avail.Client, accountPath string) error {
 	// If file exists, make sure that we return the file and not go through account creation process.
 	// In rare cases, funds may be depleted but in that case we can erase files and run it again.
 	// TODO: Potentially add lookup for account balance check and if it's too low, process with creation
 	if _, err := os.Stat(accountPath); !errors.Is(err, os.ErrNotExist) {
 		// In case that account path exists but is not visible in Avail (restart)
 		// make sure to go through the process of the account creation.
-		if ok, err := avail.AccountExistsFromMnemonic(availClient, accountPath); err == nil && ok {
+		if ok, err := avail.AccountExistsFromMnemonic(context.Background(), availClient, accountPath); err == nil && ok {
 			return nil
 		}
 	}
@@ -456,16 +469,16 @@ func createAvailAccount(logger hclog.Logger, availClient avail.Client, accountPa
 		return err
 	}
 
-	err = avail.DepositBalance(availClient, availAccount, 15*avail.AVL, nonceIncrement)
+	err = avail.DepositBalance(context.Background(), availClient, avail.NewKeyringSigner(availAccount), 15*avail.AVL)
 	if err != nil {
 		return err
 	}
 
-	if _, err := avail.QueryAppID(availClient, avail.ApplicationKey); err != nil {
+	if _, err := avail.QueryAppID(context.Background(), availClient, avail.ApplicationKey); err != nil {
 		if !errors.Is(err, avail.ErrAppIDNotFound) {
 			return err
 		}
-		_, err = avail.EnsureApplicationKeyExists(availClient, avail.ApplicationKey, availAccount)
+		_, err = avail.EnsureApplicationKeyExists(context.Background(), availClient, avail.ApplicationKey, avail.NewKeyringSigner(availAccount))
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,120 @@
+package pruning
+
+import (
+	"fmt"
+
+	"github.com/0xPolygon/polygon-edge/state"
+	itrie "github.com/0xPolygon/polygon-edge/state/immutable-trie"
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/umbracle/fastrlp"
+)
+
+// markState marks the trie nodes of the state at the given root, the ones of
+// the account trie and of the storage tries of the accounts, by hash. The
+// subtries of the nodes already marked are skipped, shared by the states
+// marked before. A state whose root isn't held is skipped.
+func markState(s itrie.Storage, root types.Hash, marked map[string]struct{}) error {
+	if root == types.EmptyRootHash {
+		return nil
+	}
+
+	if _, ok := s.Get(root.Bytes()); !ok {
+		return nil
+	}
+
+	return (&marker{storage: s, marked: marked}).markHash(root.Bytes(), false)
+}
+
+// marker walks the tries down from their roots, marking their nodes.
+type marker struct {
+	storage itrie.Storage
+	marked  map[string]struct{}
+}
+
+// markHash marks the trie node of the given hash, and the ones below it. The
+// nodes of the storage tries hold the storage slots, the others the accounts.
+func (m *marker) markHash(hash []byte, isStorage bool) error {
+	if _, ok := m.marked[string(hash)]; ok {
+		return nil
+	}
+
+	data, ok := m.storage.Get(hash)
+	if !ok {
+		return fmt.Errorf("trie node %s missing", types.BytesToHash(hash))
+	}
+
+	m.marked[string(hash)] = struct{}{}
+
+	// The values parsed point into the parser, so each node gets its own.
+	var p fastrlp.Parser
+
+	v, err := p.Parse(data)
+	if err != nil {
+		return fmt.Errorf("invalid trie node %s: %w", types.BytesToHash(hash), err)
+	}
+
+	return m.markNode(v, isStorage)
+}
+
+// markNode marks the trie nodes below the given one, either stored or
+// embedded in its parent.
+func (m *marker) markNode(v *fastrlp.Value, isStorage bool) error {
+	if v.Type() != fastrlp.TypeArray {
+		return fmt.Errorf("trie node expected to be a list")
+	}
+
+	switch v.Elems() {
+	case 2:
+		key := v.Get(0).Raw()
+		if len(key) > 0 && key[0]>>4 >= 2 {
+			// Leaf node, by the terminator flag of its compact key.
+			return m.markValue(v.Get(1).Raw(), isStorage)
+		}
+
+		return m.markChild(v.Get(1), isStorage)
+
+	case 17:
+		for i := 0; i < 16; i++ {
+			if err := m.markChild(v.Get(i), isStorage); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("trie node has incorrect number of elements")
+}
+
+// markChild marks the child of a trie node, a reference by hash, or a node
+// embedded; an empty one is skipped.
+func (m *marker) markChild(v *fastrlp.Value, isStorage bool) error {
+	if v.Type() == fastrlp.TypeArray {
+		return m.markNode(v, isStorage)
+	}
+
+	if len(v.Raw()) == 0 {
+		return nil
+	}
+
+	return m.markHash(v.Raw(), isStorage)
+}
+
+// markValue marks the storage trie of the account held by the leaf of the
+// account trie; the storage slots are skipped.
+func (m *marker) markValue(value []byte, isStorage bool) error {
+	if isStorage {
+		return nil
+	}
+
+	var account state.Account
+	if err := account.UnmarshalRlp(value); err != nil {
+		return fmt.Errorf("invalid account: %w", err)
+	}
+
+	if account.Root == types.EmptyRootHash || account.Root == types.ZeroHash {
+		return nil
+	}
+
+	return m.markHash(account.Root.Bytes(), true)
+}
@@ -0,0 +1,38 @@
+package pruning
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/0xPolygon/polygon-edge/state"
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+// ErrStatePruned is returned for the state at a root pruned from the storage.
+var ErrStatePruned = errors.New("state pruned")
+
+// prunedState is the state reporting the snapshots at the roots pruned from
+// the storage with ErrStatePruned; the tries cached in memory of the state
+// below no longer resolve.
+type prunedState struct {
+	state.State
+
+	storage *Storage
+}
+
+// NewState returns the state over the given one, whose storage is the given
+// Storage, reporting the snapshots at the roots pruned from it with
+// ErrStatePruned.
+func NewState(st state.State, storage *Storage) state.State {
+	return &prunedState{State: st, storage: storage}
+}
+
+// NewSnapshotAt returns the snapshot of the state at the given root, or
+// ErrStatePruned if it was pruned.
+func (s *prunedState) NewSnapshotAt(root types.Hash) (state.Snapshot, error) {
+	if s.storage.Pruned(root) {
+		return nil, fmt.Errorf("%w: root %s", ErrStatePruned, root)
+	}
+
+	return s.State.NewSnapshotAt(root)
+}
@@ -0,0 +1,317 @@
+// Package pruning provides the state storage pruned of the trie nodes of the
+// states no longer retained, along with the state reporting the queries
+// against them.
+package pruning
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	itrie "github.com/0xPolygon/polygon-edge/state/immutable-trie"
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/storage"
+)
+
+var (
+	// codePrefix is the prefix of the keys of the code, the same as the one
+	// of the itrie leveldb storage.
+	codePrefix = []byte("code")
+
+	// prunedKey is the key of the marker of the storage pruned at least
+	// once.
+	prunedKey = []byte("pruned")
+)
+
+// Storage is the leveldb state storage, in the format of the itrie leveldb
+// storage, with the trie nodes under their hash and the code under its hash
+// prefixed, that is pruned of the trie nodes unreachable from the roots
+// retained. The code is never pruned.
+type Storage struct {
+	db *leveldb.DB
+
+	lock    sync.Mutex
+	written map[string]struct{} // The trie nodes written during the prune in flight, if any
+
+	pruned atomic.Bool
+}
+
+// NewLevelDBStorage opens the Storage of the leveldb database at the given
+// path, created by either itself or the itrie leveldb storage.
+func NewLevelDBStorage(path string) (*Storage, error) {
+	db, err := leveldb.OpenFile(path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return newStorage(db)
+}
+
+// NewMemoryStorage returns the Storage on an in-memory leveldb database.
+func NewMemoryStorage() *Storage {
+	db, err := leveldb.Open(storage.NewMemStorage(), nil)
+	if err != nil {
+		panic(err)
+	}
+
+	s, err := newStorage(db)
+	if err != nil {
+		panic(err)
+	}
+
+	return s
+}
+
+func newStorage(db *leveldb.DB) (*Storage, error) {
+	s := &Storage{db: db}
+
+	ok, err := db.Has(prunedKey, nil)
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	s.pruned.Store(ok)
+
+	return s, nil
+}
+
+// Put writes the trie node.
+func (s *Storage) Put(k, v []byte) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.noteWritten(k)
+
+	_ = s.db.Put(k, v, nil)
+}
+
+// Get returns the trie node.
+func (s *Storage) Get(k []byte) ([]byte, bool) {
+	data, err := s.db.Get(k, nil)
+	if errors.Is(err, leveldb.ErrNotFound) {
+		return nil, false
+	}
+
+	if err != nil {
+		panic(err) //nolint:gocritic
+	}
+
+	return data, true
+}
+
+// Batch returns the batch write of the trie nodes.
+func (s *Storage) Batch() itrie.Batch {
+	return &batch{s: s, batch: new(leveldb.Batch)}
+}
+
+// SetCode writes the code.
+func (s *Storage) SetCode(hash types.Hash, code []byte) {
+	_ = s.db.Put(append(codePrefix, hash.Bytes()...), code, nil)
+}
+
+// GetCode returns the code.
+func (s *Storage) GetCode(hash types.Hash) ([]byte, bool) {
+	return s.Get(append(codePrefix, hash.Bytes()...))
+}
+
+// Close closes the database.
+func (s *Storage) Close() error {
+	return s.db.Close()
+}
+
+// NodeCount returns the number of the trie nodes held.
+func (s *Storage) NodeCount() (int, error) {
+	it := s.db.NewIterator(nil, nil)
+	defer it.Release()
+
+	n := 0
+
+	for it.Next() {
+		if len(it.Key()) == types.HashLength {
+			n++
+		}
+	}
+
+	return n, it.Error()
+}
+
+// Pruned reports whether the state at the given root was pruned: the storage
+// was pruned, and the root node isn't held.
+func (s *Storage) Pruned(root types.Hash) bool {
+	if root == types.EmptyRootHash || !s.pruned.Load() {
+		return false
+	}
+
+	_, ok := s.Get(root.Bytes())
+
+	return !ok
+}
+
+// PruneConfig is the pace of a prune: the trie nodes are deleted in batches
+// of the given size, every given interval.
+type PruneConfig struct {
+	BatchSize     int
+	BatchInterval time.Duration
+}
+
+// PruneResult is the outcome of a prune.
+type PruneResult struct {
+	Retained int // The trie nodes reachable from the roots retained
+	Pruned   int // The trie nodes deleted
+}
+
+// Prune deletes the trie nodes unreachable from the states at the roots
+// returned by the given function, the ones missing skipped, in the background
+// of the writes: the trie nodes written while the prune is in flight, from
+// right before the roots are taken, are kept, whether reachable or not. A
+// prune canceled with the context stops after the batch in flight, the trie
+// nodes deleted so far staying deleted. A trie node reachable from a root but
+// missing aborts the prune before any deletion.
+func (s *Storage) Prune(ctx context.Context, roots func() ([]types.Hash, error), config PruneConfig) (PruneResult, error) {
+	if config.BatchSize <= 0 {
+		return PruneResult{}, fmt.Errorf("prune batch size of %d not positive", config.BatchSize)
+	}
+
+	s.lock.Lock()
+	if s.written != nil {
+		s.lock.Unlock()
+		return PruneResult{}, fmt.Errorf("prune already in flight")
+	}
+
+	s.written = make(map[string]struct{})
+	s.lock.Unlock()
+
+	defer func() {
+		s.lock.Lock()
+		s.written = nil
+		s.lock.Unlock()
+	}()
+
+	retained, err := roots()
+	if err != nil {
+		return PruneResult{}, err
+	}
+
+	marked := make(map[string]struct{})
+
+	for _, root := range retained {
+		if err := ctx.Err(); err != nil {
+			return PruneResult{}, err
+		}
+
+		if err := markState(s, root, marked); err != nil {
+			return PruneResult{}, err
+		}
+	}
+
+	res := PruneResult{Retained: len(marked)}
+
+	if err := s.db.Put(prunedKey, []byte{1}, nil); err != nil {
+		return res, err
+	}
+
+	s.pruned.Store(true)
+
+	it := s.db.NewIterator(nil, nil)
+	defer it.Release()
+
+	unmarked := make([][]byte, 0, config.BatchSize)
+
+	for it.Next() {
+		if len(it.Key()) != types.HashLength {
+			continue
+		}
+
+		if _, ok := marked[string(it.Key())]; ok {
+			continue
+		}
+
+		unmarked = append(unmarked, append([]byte(nil), it.Key()...))
+		if len(unmarked) < config.BatchSize {
+			continue
+		}
+
+		n, err := s.deleteBatch(unmarked)
+		res.Pruned += n
+
+		if err != nil {
+			return res, err
+		}
+
+		unmarked = unmarked[:0]
+
+		select {
+		case <-ctx.Done():
+			return res, ctx.Err()
+		case <-time.After(config.BatchInterval):
+		}
+	}
+
+	if err := it.Error(); err != nil {
+		return res, err
+	}
+
+	n, err := s.deleteBatch(unmarked)
+	res.Pruned += n
+
+	return res, err
+}
+
+// deleteBatch deletes the given trie nodes but the ones written since the
+// prune started, and returns the number deleted.
+func (s *Storage) deleteBatch(keys [][]byte) (int, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	b := new(leveldb.Batch)
+
+	for _, k := range keys {
+		if _, ok := s.written[string(k)]; ok {
+			continue
+		}
+
+		b.Delete(k)
+	}
+
+	if err := s.db.Write(b, nil); err != nil {
+		return 0, err
+	}
+
+	return b.Len(), nil
+}
+
+// noteWritten notes the trie node written for the prune in flight, if any,
+// to keep it. The lock is held.
+func (s *Storage) noteWritten(k []byte) {
+	if s.written != nil {
+		s.written[string(k)] = struct{}{}
+	}
+}
+
+// batch is the batch write of the trie nodes of the Storage.
+type batch struct {
+	s     *Storage
+	batch *leveldb.Batch
+	keys  [][]byte
+}
+
+func (b *batch) Put(k, v []byte) {
+	b.batch.Put(k, v)
+	b.keys = append(b.keys, append([]byte(nil), k...))
+}
+
+func (b *batch) Write() {
+	b.s.lock.Lock()
+	defer b.s.lock.Unlock()
+
+	for _, k := range b.keys {
+		b.s.noteWritten(k)
+	}
+
+	_ = b.s.db.Write(b.batch, nil)
+}
@@ -0,0 +1,194 @@
+package pruning
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/0xPolygon/polygon-edge/state"
+	itrie "github.com/0xPolygon/polygon-edge/state/immutable-trie"
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/stretchr/testify/assert"
+)
+
+var testAccounts = []types.Address{
+	types.StringToAddress("0x1"),
+	types.StringToAddress("0x2"),
+	types.StringToAddress("0x3"),
+	types.StringToAddress("0x4"),
+}
+
+// testChain writes the states of a synthetic chain of the given length to the
+// state, each block updating the balance and a storage slot of an account,
+// and returns their roots.
+func testChain(t *testing.T, st state.State, blocks int) []types.Hash {
+	t.Helper()
+
+	roots := []types.Hash{types.EmptyRootHash}
+
+	for i := 1; i <= blocks; i++ {
+		roots = append(roots, testBlock(t, st, roots[len(roots)-1], i))
+	}
+
+	return roots
+}
+
+// testBlock writes the state of the i-th block of the synthetic chain on top
+// of the given parent state, and returns its root.
+func testBlock(t *testing.T, st state.State, parent types.Hash, i int) types.Hash {
+	t.Helper()
+
+	snap, err := st.NewSnapshotAt(parent)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addr := testAccounts[i%len(testAccounts)]
+
+	obj := &state.Object{Address: addr, Balance: big.NewInt(int64(i)), Root: types.EmptyRootHash}
+	if account, err := snap.GetAccount(addr); err == nil && account != nil {
+		obj.Root = account.Root
+	}
+
+	obj.Storage = []*state.StorageObject{{Key: types.BytesToHash([]byte{byte(i % 3)}).Bytes(), Val: []byte{byte(i)}}}
+
+	_, root := snap.Commit([]*state.Object{obj})
+
+	return types.BytesToHash(root)
+}
+
+func TestPruneRetainsRecentState(t *testing.T) {
+	s := NewMemoryStorage()
+	st := NewState(itrie.NewState(s), s)
+
+	roots := testChain(t, st, 20)
+
+	before, err := s.NodeCount()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The last five blocks are retained, along with a protected one.
+	retained := append([]types.Hash{roots[3]}, roots[16:]...)
+
+	res, err := s.Prune(context.Background(), func() ([]types.Hash, error) { return retained, nil }, PruneConfig{BatchSize: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	after, err := s.NodeCount()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Less(t, after, before)
+	assert.Equal(t, before-after, res.Pruned)
+	assert.Equal(t, after, res.Retained)
+
+	// The states retained are held in full, down to the storage tries.
+	for _, root := range retained {
+		_, err := st.NewSnapshotAt(root)
+		assert.NoError(t, err)
+		assert.NoError(t, markState(s, root, make(map[string]struct{})))
+	}
+
+	snap, err := st.NewSnapshotAt(roots[20])
+	if assert.NoError(t, err) {
+		account, err := snap.GetAccount(testAccounts[0])
+		if assert.NoError(t, err) {
+			assert.Equal(t, big.NewInt(20), account.Balance)
+			assert.Equal(t, types.BytesToHash([]byte{20}), snap.GetStorage(testAccounts[0], account.Root, types.BytesToHash([]byte{2})))
+		}
+	}
+
+	for _, root := range roots[1:16] {
+		if root == roots[3] {
+			continue
+		}
+
+		_, err := st.NewSnapshotAt(root)
+		assert.True(t, errors.Is(err, ErrStatePruned), "state at %s not pruned: %v", root, err)
+	}
+
+	// The chain goes on from the state retained.
+	next := testBlock(t, st, roots[20], 21)
+
+	_, err = st.NewSnapshotAt(next)
+	assert.NoError(t, err)
+}
+
+func TestPruneKeepsStateWrittenInFlight(t *testing.T) {
+	s := NewMemoryStorage()
+	st := NewState(itrie.NewState(s), s)
+
+	roots := testChain(t, st, 10)
+
+	// A block is written once the prune started, short of the roots taken.
+	var written types.Hash
+
+	_, err := s.Prune(context.Background(), func() ([]types.Hash, error) {
+		written = testBlock(t, st, roots[10], 11)
+		return roots[8:], nil
+	}, PruneConfig{BatchSize: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snap, err := st.NewSnapshotAt(written)
+	if assert.NoError(t, err) {
+		account, err := snap.GetAccount(testAccounts[11%len(testAccounts)])
+		if assert.NoError(t, err) {
+			assert.Equal(t, big.NewInt(11), account.Balance)
+		}
+	}
+
+	_, err = st.NewSnapshotAt(roots[2])
+	assert.ErrorIs(t, err, ErrStatePruned)
+}
+
+func TestPrunedAcrossRestarts(t *testing.T) {
+	dir := t.TempDir()
+
+	s, err := NewLevelDBStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roots := testChain(t, itrie.NewState(s), 6)
+
+	// Nothing is reported pruned before the first prune.
+	assert.False(t, s.Pruned(types.StringToHash("0x1")))
+
+	if _, err := s.Prune(context.Background(), func() ([]types.Hash, error) { return roots[5:], nil }, PruneConfig{BatchSize: 10}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err = NewLevelDBStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer s.Close()
+
+	assert.True(t, s.Pruned(roots[1]))
+	assert.False(t, s.Pruned(roots[6]))
+}
+
+func TestPruneCanceled(t *testing.T) {
+	s := NewMemoryStorage()
+	roots := testChain(t, itrie.NewState(s), 10)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := s.Prune(ctx, func() ([]types.Hash, error) { return roots[9:], nil }, PruneConfig{BatchSize: 1})
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// A prune canceled before the marking leaves the state untouched.
+	assert.False(t, s.Pruned(roots[1]))
+}
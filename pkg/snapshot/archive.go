@@ -0,0 +1,224 @@
+package snapshot
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+// ArchiveVersion is the version of the chain archive format written.
+const ArchiveVersion = 1
+
+// maxArchiveField is the largest field of a chain archive record read, past
+// which the length prefix is taken for corrupt.
+const maxArchiveField = 128 << 20
+
+// archiveMagic opens every chain archive.
+var archiveMagic = [8]byte{'o', 'p', 'e', 'v', 'm', 'a', 'r', 'c'}
+
+var (
+	// ErrArchiveVersion is returned for a chain archive of a format version
+	// not supported.
+	ErrArchiveVersion = errors.New("chain archive version not supported")
+
+	// ErrArchiveCorrupt is returned for a chain archive failing its checks.
+	ErrArchiveCorrupt = errors.New("chain archive corrupt")
+
+	// ErrArchiveTruncated is returned for a chain archive ending short of the
+	// range of its header; the blocks read before it are complete.
+	ErrArchiveTruncated = errors.New("chain archive truncated")
+)
+
+// ArchiveHeader opens a chain archive: the chain it's taken out of, by the
+// hash of its genesis, and the range of its blocks.
+type ArchiveHeader struct {
+	Version uint32
+	Genesis types.Hash
+	From    uint64
+	To      uint64
+}
+
+// ArchiveWriter writes the chain archive, a portable stream of the blocks of
+// a range of the chain, along with their receipts, independent of the
+// storage engine of the node: the header, and a record of each block in
+// order, its fields length-prefixed, and the record closed by its checksum.
+type ArchiveWriter struct {
+	w    io.Writer
+	next uint64
+	to   uint64
+}
+
+// NewArchiveWriter writes the header of the chain archive of the blocks of
+// the given range to w, and returns the ArchiveWriter of its blocks.
+func NewArchiveWriter(w io.Writer, genesis types.Hash, from, to uint64) (*ArchiveWriter, error) {
+	if from > to {
+		return nil, fmt.Errorf("no blocks to archive from block %d to %d", from, to)
+	}
+
+	buf := make([]byte, 0, len(archiveMagic)+4+types.HashLength+16)
+	buf = append(buf, archiveMagic[:]...)
+	buf = binary.BigEndian.AppendUint32(buf, ArchiveVersion)
+	buf = append(buf, genesis.Bytes()...)
+	buf = binary.BigEndian.AppendUint64(buf, from)
+	buf = binary.BigEndian.AppendUint64(buf, to)
+
+	if _, err := w.Write(buf); err != nil {
+		return nil, err
+	}
+
+	return &ArchiveWriter{w: w, next: from, to: to}, nil
+}
+
+// Write writes the record of the next block of the range, along with its
+// receipts.
+func (aw *ArchiveWriter) Write(blk *types.Block, receipts []*types.Receipt) error {
+	if blk.Number() != aw.next || aw.next > aw.to {
+		return fmt.Errorf("block %d out of the archive range, expected block %d up to %d", blk.Number(), aw.next, aw.to)
+	}
+
+	rec := appendArchiveField(nil, blk.MarshalRLP())
+	rec = appendArchiveField(rec, types.Receipts(receipts).MarshalStoreRLPTo(nil))
+	rec = binary.BigEndian.AppendUint32(rec, crc32.ChecksumIEEE(rec))
+
+	if _, err := aw.w.Write(rec); err != nil {
+		return err
+	}
+
+	aw.next++
+
+	return nil
+}
+
+// appendArchiveField appends the length-prefixed field to buf.
+func appendArchiveField(buf, field []byte) []byte {
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(field)))
+	return append(buf, field...)
+}
+
+// ArchiveReader reads the chain archive written by ArchiveWriter, checking
+// each record against its checksum, and the blocks to follow each other over
+// the range of the header.
+type ArchiveReader struct {
+	r      io.Reader
+	header ArchiveHeader
+	next   uint64
+}
+
+// NewArchiveReader reads the header of the chain archive from r, and returns
+// the ArchiveReader of its blocks.
+func NewArchiveReader(r io.Reader) (*ArchiveReader, error) {
+	buf := make([]byte, len(archiveMagic)+4+types.HashLength+16)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, fmt.Errorf("%w: header: %v", ErrArchiveTruncated, err)
+	}
+
+	if !bytes.Equal(buf[:len(archiveMagic)], archiveMagic[:]) {
+		return nil, fmt.Errorf("%w: not a chain archive", ErrArchiveCorrupt)
+	}
+
+	buf = buf[len(archiveMagic):]
+
+	h := ArchiveHeader{Version: binary.BigEndian.Uint32(buf)}
+	if h.Version != ArchiveVersion {
+		return nil, fmt.Errorf("%w: version %d", ErrArchiveVersion, h.Version)
+	}
+
+	buf = buf[4:]
+	h.Genesis = types.BytesToHash(buf[:types.HashLength])
+
+	buf = buf[types.HashLength:]
+	h.From, h.To = binary.BigEndian.Uint64(buf), binary.BigEndian.Uint64(buf[8:])
+
+	if h.From > h.To {
+		return nil, fmt.Errorf("%w: range from block %d to %d", ErrArchiveCorrupt, h.From, h.To)
+	}
+
+	return &ArchiveReader{r: r, header: h, next: h.From}, nil
+}
+
+// Header returns the header of the archive.
+func (ar *ArchiveReader) Header() ArchiveHeader {
+	return ar.header
+}
+
+// Next reads the next block of the archive, along with its receipts; it
+// returns io.EOF past the last block of the range, and ErrArchiveTruncated
+// for the archive ending short of it.
+func (ar *ArchiveReader) Next() (*types.Block, []*types.Receipt, error) {
+	if ar.next > ar.header.To {
+		return nil, nil, io.EOF
+	}
+
+	blkBytes, err := ar.readField()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	receiptsBytes, err := ar.readField()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var sum [4]byte
+	if _, err := io.ReadFull(ar.r, sum[:]); err != nil {
+		return nil, nil, ar.truncated(err)
+	}
+
+	rec := appendArchiveField(appendArchiveField(nil, blkBytes), receiptsBytes)
+	if crc32.ChecksumIEEE(rec) != binary.BigEndian.Uint32(sum[:]) {
+		return nil, nil, fmt.Errorf("%w: checksum of block %d", ErrArchiveCorrupt, ar.next)
+	}
+
+	blk := new(types.Block)
+	if err := blk.UnmarshalRLP(blkBytes); err != nil {
+		return nil, nil, fmt.Errorf("%w: block %d: %v", ErrArchiveCorrupt, ar.next, err)
+	}
+
+	if blk.Number() != ar.next {
+		return nil, nil, fmt.Errorf("%w: block %d in place of block %d", ErrArchiveCorrupt, blk.Number(), ar.next)
+	}
+
+	var receipts types.Receipts
+	if err := receipts.UnmarshalStoreRLP(receiptsBytes); err != nil {
+		return nil, nil, fmt.Errorf("%w: receipts of block %d: %v", ErrArchiveCorrupt, ar.next, err)
+	}
+
+	ar.next++
+
+	return blk, receipts, nil
+}
+
+// readField reads the next length-prefixed field of a record.
+func (ar *ArchiveReader) readField() ([]byte, error) {
+	var prefix [4]byte
+	if _, err := io.ReadFull(ar.r, prefix[:]); err != nil {
+		return nil, ar.truncated(err)
+	}
+
+	n := binary.BigEndian.Uint32(prefix[:])
+	if n > maxArchiveField {
+		return nil, fmt.Errorf("%w: field of %d bytes in block %d", ErrArchiveCorrupt, n, ar.next)
+	}
+
+	field := make([]byte, n)
+	if _, err := io.ReadFull(ar.r, field); err != nil {
+		return nil, ar.truncated(err)
+	}
+
+	return field, nil
+}
+
+// truncated returns ErrArchiveTruncated for the archive ending within the
+// record of the next block, or the error reading it.
+func (ar *ArchiveReader) truncated(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("%w: at block %d of %d", ErrArchiveTruncated, ar.next, ar.header.To)
+	}
+
+	return err
+}
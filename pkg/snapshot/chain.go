@@ -0,0 +1,304 @@
+package snapshot
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/0xPolygon/polygon-edge/crypto"
+	itrie "github.com/0xPolygon/polygon-edge/state/immutable-trie"
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/vedhavyas/go-subkey/scale"
+)
+
+// ErrIncompleteState is returned for a chain snapshot missing the trie nodes
+// or the code of the state it's taken at.
+var ErrIncompleteState = errors.New("chain snapshot state incomplete")
+
+// ChainSnapshot is a full snapshot of the chain at a block settled on Avail,
+// for a new node to start from instead of replaying the chain: the header
+// chain from the genesis to the block, and the state at it, the account trie
+// along with the storage tries and the code of the accounts. Unlike Snapshot,
+// it stands on its own, and is checked against the headers on the import.
+type ChainSnapshot struct {
+	// AvailBlock is the number of the Avail block the last header was
+	// included in.
+	AvailBlock uint64
+
+	// Headers are the RLP encoded headers, from the genesis on.
+	Headers [][]byte
+
+	// Nodes are the trie nodes of the state at the last header, by hash.
+	Nodes StateStorageSnapshot
+
+	// CodeHashes and Codes are the code of the accounts of the state, by hash.
+	CodeHashes [][]byte
+	Codes      [][]byte
+}
+
+// Encode encodes the ChainSnapshot using the provided scale.Encoder, field by
+// field.
+func (cs *ChainSnapshot) Encode(e scale.Encoder) error {
+	for _, v := range []interface{}{cs.AvailBlock, cs.Headers, cs.Nodes.Keys, cs.Nodes.Values, cs.CodeHashes, cs.Codes} {
+		if err := e.Encode(v); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Decode decodes the ChainSnapshot using the provided scale.Decoder, field by
+// field.
+func (cs *ChainSnapshot) Decode(d scale.Decoder) error {
+	for _, v := range []interface{}{&cs.AvailBlock, &cs.Headers, &cs.Nodes.Keys, &cs.Nodes.Values, &cs.CodeHashes, &cs.Codes} {
+		if err := d.Decode(v); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// NewChainSnapshot takes the ChainSnapshot of the given header chain, from the
+// genesis on, included in Avail at the given block, copying the state at the
+// last header out of the state storage.
+func NewChainSnapshot(headers []*types.Header, availBlock uint64, stateStorage itrie.Storage) (*ChainSnapshot, error) {
+	if len(headers) == 0 {
+		return nil, fmt.Errorf("no headers to snapshot")
+	}
+
+	cs := &ChainSnapshot{AvailBlock: availBlock}
+
+	for _, h := range headers {
+		cs.Headers = append(cs.Headers, h.MarshalRLP())
+	}
+
+	root := headers[len(headers)-1].StateRoot
+	if root == types.EmptyRootHash {
+		return cs, nil
+	}
+
+	rec := newStateRecorder()
+	if err := itrie.CopyTrie(root.Bytes(), stateStorage, rec, nil, false); err != nil {
+		return nil, fmt.Errorf("failed to copy the state at %s: %w", root, err)
+	}
+
+	cs.Nodes = rec.nodes
+	cs.CodeHashes, cs.Codes = rec.codeHashes, rec.codes
+
+	return cs, nil
+}
+
+// DecodeHeaders returns the headers of the snapshot, checking they chain up
+// from the genesis.
+func (cs *ChainSnapshot) DecodeHeaders() ([]*types.Header, error) {
+	headers, err := decodeHeaders(cs.Headers)
+	if err != nil {
+		return nil, err
+	}
+
+	if headers[0].Number != 0 {
+		return nil, fmt.Errorf("chain snapshot starts at header %d", headers[0].Number)
+	}
+
+	return headers, nil
+}
+
+// decodeHeaders decodes the RLP encoded headers, checking they chain up.
+func decodeHeaders(encoded [][]byte) ([]*types.Header, error) {
+	if len(encoded) == 0 {
+		return nil, fmt.Errorf("no headers in the snapshot")
+	}
+
+	headers := make([]*types.Header, len(encoded))
+
+	for i, bs := range encoded {
+		h := new(types.Header)
+		if err := h.UnmarshalRLP(bs); err != nil {
+			return nil, fmt.Errorf("failed to decode header %d: %w", i, err)
+		}
+
+		h.ComputeHash()
+
+		if i > 0 {
+			if prev := headers[i-1]; h.Number != prev.Number+1 || h.ParentHash != prev.Hash {
+				return nil, fmt.Errorf("header %d doesn't chain up to its parent", h.Number)
+			}
+		}
+
+		headers[i] = h
+	}
+
+	return headers, nil
+}
+
+// ApplyState writes the state of the snapshot to the state storage, once it
+// checks out against the given state root: every trie node and code matches
+// its hash, the account trie hashes to the root, and no node or code of the
+// state is missing.
+func (cs *ChainSnapshot) ApplyState(root types.Hash, stateStorage itrie.Storage) error {
+	if root == types.EmptyRootHash {
+		return nil
+	}
+
+	src, err := newStateSource(cs.Nodes, cs.CodeHashes, cs.Codes, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := src.check(root); err != nil {
+		return err
+	}
+
+	return itrie.CopyTrie(root.Bytes(), src, stateStorage, nil, false)
+}
+
+// WriteChainSnapshot encodes the ChainSnapshot to w.
+func WriteChainSnapshot(w io.Writer, cs *ChainSnapshot) error {
+	return cs.Encode(*scale.NewEncoder(w))
+}
+
+// ReadChainSnapshot decodes a ChainSnapshot from r.
+func ReadChainSnapshot(r io.Reader) (*ChainSnapshot, error) {
+	cs := new(ChainSnapshot)
+	if err := cs.Decode(*scale.NewDecoder(r)); err != nil {
+		return nil, err
+	}
+
+	return cs, nil
+}
+
+// stateRecorder is the itrie.Storage recording the trie nodes and the code
+// copied to it.
+type stateRecorder struct {
+	itrie.Storage
+
+	seen       map[string]bool
+	nodes      StateStorageSnapshot
+	codeHashes [][]byte
+	codes      [][]byte
+}
+
+func newStateRecorder() *stateRecorder {
+	return &stateRecorder{Storage: itrie.NewMemoryStorage(), seen: make(map[string]bool)}
+}
+
+// Put records the trie node, once for the ones shared by several tries.
+func (r *stateRecorder) Put(k, v []byte) {
+	if r.seen[string(k)] {
+		return
+	}
+
+	r.seen[string(k)] = true
+	r.nodes.Keys = append(r.nodes.Keys, append([]byte(nil), k...))
+	r.nodes.Values = append(r.nodes.Values, append([]byte(nil), v...))
+}
+
+// SetCode records the code, once for the one of several accounts.
+func (r *stateRecorder) SetCode(hash types.Hash, code []byte) {
+	if r.seen[string(codePrefix)+string(hash.Bytes())] {
+		return
+	}
+
+	r.seen[string(codePrefix)+string(hash.Bytes())] = true
+	r.codeHashes = append(r.codeHashes, hash.Bytes())
+	r.codes = append(r.codes, append([]byte(nil), code...))
+}
+
+// stateSource is the itrie.Storage of the trie nodes and the code of a
+// snapshot, on top of the ones of the fallback storage, if any, noting the
+// trie nodes looked up but missing from both.
+type stateSource struct {
+	itrie.Storage
+
+	fallback itrie.Storage
+	missing  [][]byte
+}
+
+// newStateSource returns the stateSource of the given trie nodes and code, on
+// top of the fallback storage, checking each matches its hash.
+func newStateSource(nodes StateStorageSnapshot, codeHashes, codes [][]byte, fallback itrie.Storage) (*stateSource, error) {
+	if len(nodes.Keys) != len(nodes.Values) || len(codeHashes) != len(codes) {
+		return nil, fmt.Errorf("malformed snapshot state")
+	}
+
+	src := &stateSource{Storage: itrie.NewMemoryStorage(), fallback: fallback}
+
+	for i, k := range nodes.Keys {
+		if !bytes.Equal(crypto.Keccak256(nodes.Values[i]), k) {
+			return nil, fmt.Errorf("trie node %x doesn't match its hash", k)
+		}
+
+		src.Put(k, nodes.Values[i])
+	}
+
+	for i, hash := range codeHashes {
+		if !bytes.Equal(crypto.Keccak256(codes[i]), hash) {
+			return nil, fmt.Errorf("code %x doesn't match its hash", hash)
+		}
+
+		src.SetCode(types.BytesToHash(hash), codes[i])
+	}
+
+	return src, nil
+}
+
+// Get looks the trie node up, noting it if missing.
+func (s *stateSource) Get(k []byte) ([]byte, bool) {
+	v, ok := s.Storage.Get(k)
+	if !ok && s.fallback != nil {
+		v, ok = s.fallback.Get(k)
+	}
+
+	if !ok {
+		s.missing = append(s.missing, k)
+	}
+
+	return v, ok
+}
+
+// GetCode looks the code up, in the fallback storage too.
+func (s *stateSource) GetCode(hash types.Hash) ([]byte, bool) {
+	code, ok := s.Storage.GetCode(hash)
+	if !ok && s.fallback != nil {
+		return s.fallback.GetCode(hash)
+	}
+
+	return code, ok
+}
+
+// check checks the state at the given root is complete, and hashes to the
+// root. The state is walked over first, so a missing node is reported rather
+// than computed over.
+func (s *stateSource) check(root types.Hash) error {
+	if err := itrie.CopyTrie(root.Bytes(), s, discardStorage{}, nil, false); err != nil {
+		return fmt.Errorf("%w: %v", ErrIncompleteState, err)
+	}
+
+	if len(s.missing) > 0 {
+		return fmt.Errorf("%w: node %x missing", ErrIncompleteState, s.missing[0])
+	}
+
+	computed, err := itrie.HashChecker(root.Bytes(), s)
+	if err != nil {
+		return fmt.Errorf("failed to compute the state root: %w", err)
+	}
+
+	if computed != root {
+		return fmt.Errorf("state root mismatch: snapshot header has %s, state computes %s", root, computed)
+	}
+
+	return nil
+}
+
+// discardStorage is the itrie.Storage the state is walked over to, dropping
+// the trie nodes and the code copied to it.
+type discardStorage struct {
+	itrie.Storage
+}
+
+func (discardStorage) Put(k, v []byte) {}
+
+func (discardStorage) SetCode(hash types.Hash, code []byte) {}
@@ -0,0 +1,209 @@
+package snapshot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/0xPolygon/polygon-edge/crypto"
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+// DefaultChunkSize is the size of the chunks the snapshots are served in.
+const DefaultChunkSize = 4 << 20
+
+// ErrChunkCorrupt is returned for a chunk of a snapshot not matching its hash
+// in the manifest.
+var ErrChunkCorrupt = errors.New("snapshot chunk corrupt")
+
+// Manifest describes a snapshot file served in chunks: its size, and the hash
+// of each of its chunks, all of ChunkSize bytes but the last one. The chunks
+// are checked against it on the download, and re-fetched when corrupt.
+type Manifest struct {
+	Size        uint64       `json:"size"`
+	ChunkSize   uint64       `json:"chunkSize"`
+	ChunkHashes []types.Hash `json:"chunkHashes"`
+}
+
+// NewManifest returns the Manifest of the snapshot file read from r, in chunks
+// of the given size.
+func NewManifest(r io.Reader, chunkSize uint64) (*Manifest, error) {
+	if chunkSize == 0 {
+		return nil, fmt.Errorf("chunk size expected to be positive")
+	}
+
+	m := &Manifest{ChunkSize: chunkSize}
+	buf := make([]byte, chunkSize)
+
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			m.Size += uint64(n)
+			m.ChunkHashes = append(m.ChunkHashes, types.BytesToHash(crypto.Keccak256(buf[:n])))
+		}
+
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return m, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Chunks returns the number of the chunks of the snapshot.
+func (m *Manifest) Chunks() int {
+	return len(m.ChunkHashes)
+}
+
+// chunkLen returns the length of the chunk of the given index.
+func (m *Manifest) chunkLen(index int) uint64 {
+	if last := uint64(index+1) * m.ChunkSize; last > m.Size {
+		return m.Size - uint64(index)*m.ChunkSize
+	}
+
+	return m.ChunkSize
+}
+
+// CheckChunk checks the chunk of the given index matches its hash.
+func (m *Manifest) CheckChunk(index int, chunk []byte) error {
+	if index < 0 || index >= m.Chunks() {
+		return fmt.Errorf("chunk %d out of the %d of the snapshot", index, m.Chunks())
+	}
+
+	if uint64(len(chunk)) != m.chunkLen(index) || types.BytesToHash(crypto.Keccak256(chunk)) != m.ChunkHashes[index] {
+		return fmt.Errorf("%w: chunk %d", ErrChunkCorrupt, index)
+	}
+
+	return nil
+}
+
+// check checks the manifest is consistent: the chunks add up to the size.
+func (m *Manifest) check() error {
+	if m.ChunkSize == 0 {
+		return fmt.Errorf("malformed manifest: no chunk size")
+	}
+
+	if chunks := (m.Size + m.ChunkSize - 1) / m.ChunkSize; chunks != uint64(m.Chunks()) {
+		return fmt.Errorf("malformed manifest: %d chunks of %d bytes for %d bytes", m.Chunks(), m.ChunkSize, m.Size)
+	}
+
+	return nil
+}
+
+// ReadChunk reads the chunk of the given index of the snapshot file of the
+// manifest.
+func (m *Manifest) ReadChunk(r io.ReaderAt, index int) ([]byte, error) {
+	if index < 0 || index >= m.Chunks() {
+		return nil, fmt.Errorf("chunk %d out of the %d of the snapshot", index, m.Chunks())
+	}
+
+	chunk := make([]byte, m.chunkLen(index))
+	if _, err := r.ReadAt(chunk, int64(index)*int64(m.ChunkSize)); err != nil {
+		return nil, err
+	}
+
+	return chunk, nil
+}
+
+// FetchChunkFunc fetches the chunk of the given index of a snapshot.
+type FetchChunkFunc func(ctx context.Context, index int) ([]byte, error)
+
+// Download downloads the snapshot of the manifest to the file of the given
+// path, chunk by chunk, checking each against its hash. A chunk failing the
+// check is re-fetched, up to the given number of retries. The download
+// resumes from the file left by an interrupted one: the chunks already in it
+// are kept as far as they check out, and the rest fetched. It returns the
+// number of the chunks fetched.
+func Download(ctx context.Context, path string, m *Manifest, fetch FetchChunkFunc, retries int) (int, error) {
+	if err := m.check(); err != nil {
+		return 0, err
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return 0, err
+	}
+
+	defer f.Close()
+
+	start, err := resumeDownload(f, m)
+	if err != nil {
+		return 0, err
+	}
+
+	fetched := 0
+
+	for index := start; index < m.Chunks(); index++ {
+		chunk, err := fetchChunk(ctx, m, index, fetch, retries)
+		if err != nil {
+			return fetched, err
+		}
+
+		if _, err := f.WriteAt(chunk, int64(index)*int64(m.ChunkSize)); err != nil {
+			return fetched, err
+		}
+
+		fetched++
+	}
+
+	if err := f.Truncate(int64(m.Size)); err != nil {
+		return fetched, err
+	}
+
+	return fetched, f.Sync()
+}
+
+// resumeDownload returns the index of the first chunk missing from the file
+// of the download, or not matching its hash.
+func resumeDownload(f *os.File, m *Manifest) (int, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	for index := 0; index < m.Chunks(); index++ {
+		if uint64(info.Size()) < uint64(index)*m.ChunkSize+m.chunkLen(index) {
+			return index, nil
+		}
+
+		chunk, err := m.ReadChunk(f, index)
+		if err != nil {
+			return 0, err
+		}
+
+		if m.CheckChunk(index, chunk) != nil {
+			return index, nil
+		}
+	}
+
+	return m.Chunks(), nil
+}
+
+// fetchChunk fetches the chunk of the given index, until it matches its hash
+// or the retries run out.
+func fetchChunk(ctx context.Context, m *Manifest, index int, fetch FetchChunkFunc, retries int) ([]byte, error) {
+	var err error
+
+	for attempt := 0; attempt <= retries; attempt++ {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+
+		var chunk []byte
+
+		chunk, err = fetch(ctx, index)
+		if err == nil {
+			err = m.CheckChunk(index, chunk)
+		}
+
+		if err == nil {
+			return chunk, nil
+		}
+	}
+
+	return nil, fmt.Errorf("failed to fetch chunk %d: %w", index, err)
+}
@@ -0,0 +1,162 @@
+package snapshot
+
+import (
+	"fmt"
+	"io"
+
+	itrie "github.com/0xPolygon/polygon-edge/state/immutable-trie"
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/vedhavyas/go-subkey/scale"
+)
+
+// StateDiff is the delta of the chain from a block to a later one, for a node
+// holding the state at the first, from a ChainSnapshot or a previous
+// StateDiff, to move on to the later one without a full snapshot: the headers
+// in between, and the trie nodes and the code of the state at the later block
+// missing from the state at the first. The state tries being content
+// addressed, the nodes are the ones on the paths to the account and storage
+// entries modified, added or deleted in between, and the code is the one of
+// the contracts created.
+type StateDiff struct {
+	// AvailBlock is the number of the Avail block the last header was
+	// included in.
+	AvailBlock uint64
+
+	// Headers are the RLP encoded headers, from the base block on.
+	Headers [][]byte
+
+	// Nodes are the trie nodes of the state at the last header missing from
+	// the state at the base block, by hash.
+	Nodes StateStorageSnapshot
+
+	// CodeHashes and Codes are the code of the state at the last header
+	// missing from the state at the base block, by hash.
+	CodeHashes [][]byte
+	Codes      [][]byte
+}
+
+// Encode encodes the StateDiff using the provided scale.Encoder, field by
+// field.
+func (sd *StateDiff) Encode(e scale.Encoder) error {
+	for _, v := range []interface{}{sd.AvailBlock, sd.Headers, sd.Nodes.Keys, sd.Nodes.Values, sd.CodeHashes, sd.Codes} {
+		if err := e.Encode(v); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Decode decodes the StateDiff using the provided scale.Decoder, field by
+// field.
+func (sd *StateDiff) Decode(d scale.Decoder) error {
+	for _, v := range []interface{}{&sd.AvailBlock, &sd.Headers, &sd.Nodes.Keys, &sd.Nodes.Values, &sd.CodeHashes, &sd.Codes} {
+		if err := d.Decode(v); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// NewStateDiff takes the StateDiff of the given header chain, from the base
+// block on, the last header included in Avail at the given block, comparing
+// the states at the first and the last header out of the state storage.
+func NewStateDiff(headers []*types.Header, availBlock uint64, stateStorage itrie.Storage) (*StateDiff, error) {
+	if len(headers) < 2 {
+		return nil, fmt.Errorf("no headers past the base block to diff")
+	}
+
+	sd := &StateDiff{AvailBlock: availBlock}
+
+	for _, h := range headers {
+		sd.Headers = append(sd.Headers, h.MarshalRLP())
+	}
+
+	base, target := headers[0].StateRoot, headers[len(headers)-1].StateRoot
+
+	// The nodes and the code of the base state are recorded as seen, for
+	// the target state to record the ones it doesn't share only.
+	seen := newStateRecorder()
+	if base != types.EmptyRootHash {
+		if err := itrie.CopyTrie(base.Bytes(), stateStorage, seen, nil, false); err != nil {
+			return nil, fmt.Errorf("failed to copy the state at %s: %w", base, err)
+		}
+	}
+
+	if target == types.EmptyRootHash {
+		return sd, nil
+	}
+
+	rec := &stateRecorder{Storage: seen.Storage, seen: seen.seen}
+	if err := itrie.CopyTrie(target.Bytes(), stateStorage, rec, nil, false); err != nil {
+		return nil, fmt.Errorf("failed to copy the state at %s: %w", target, err)
+	}
+
+	sd.Nodes = rec.nodes
+	sd.CodeHashes, sd.Codes = rec.codeHashes, rec.codes
+
+	return sd, nil
+}
+
+// DecodeHeaders returns the headers of the diff, checking they chain up from
+// the base block.
+func (sd *StateDiff) DecodeHeaders() ([]*types.Header, error) {
+	headers, err := decodeHeaders(sd.Headers)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(headers) < 2 {
+		return nil, fmt.Errorf("no headers past the base block in the diff")
+	}
+
+	return headers, nil
+}
+
+// ApplyState writes the trie nodes and the code of the diff to the state
+// storage holding the state at the base block, once the state at the given
+// root checks out over them: every trie node and code matches its hash, the
+// account trie hashes to the root, and no node or code of the state is
+// missing from either. A state storage not holding the base state is left
+// untouched.
+func (sd *StateDiff) ApplyState(root types.Hash, stateStorage itrie.Storage) error {
+	src, err := newStateSource(sd.Nodes, sd.CodeHashes, sd.Codes, stateStorage)
+	if err != nil {
+		return err
+	}
+
+	if root != types.EmptyRootHash {
+		if err := src.check(root); err != nil {
+			return err
+		}
+	}
+
+	batch := stateStorage.Batch()
+	for i, k := range sd.Nodes.Keys {
+		batch.Put(k, sd.Nodes.Values[i])
+	}
+
+	batch.Write()
+
+	for i, hash := range sd.CodeHashes {
+		stateStorage.SetCode(types.BytesToHash(hash), sd.Codes[i])
+	}
+
+	return nil
+}
+
+// WriteStateDiff encodes the StateDiff to w.
+func WriteStateDiff(w io.Writer, sd *StateDiff) error {
+	return sd.Encode(*scale.NewEncoder(w))
+}
+
+// ReadStateDiff decodes a StateDiff from r.
+func ReadStateDiff(r io.Reader) (*StateDiff, error) {
+	sd := new(StateDiff)
+	if err := sd.Decode(*scale.NewDecoder(r)); err != nil {
+		return nil, err
+	}
+
+	return sd, nil
+}
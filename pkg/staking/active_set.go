@@ -0,0 +1,257 @@
+package staking
+
+import (
+	"math/big"
+	"sync"
+
+	"github.com/0xPolygon/polygon-edge/state"
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/pkg/blockchain"
+)
+
+// participantSet is the set of the active participants as of a block.
+type participantSet struct {
+	sequencers  []types.Address
+	watchtowers []types.Address
+}
+
+// ActiveSet is the set of the active sequencers and watchtowers the leader
+// schedule and the producer checks follow. It's refreshed from the staking
+// contract as of the blocks calling it, and a refreshed set takes effect at
+// the start of the next slot of the leader schedule, in Avail blocks, so that
+// every node following the same Avail blocks switches over at the same Avail
+// block. Until the first refresh, the set is the one of the head of the chain.
+//
+// ActiveSet implements ActiveSequencers.
+type ActiveSet struct {
+	blockchain *blockchain.Blockchain
+	slotLen    uint64
+
+	// query returns the set as of the given block.
+	query func(header *types.Header) (*participantSet, error)
+
+	lock      sync.RWMutex
+	current   *participantSet
+	staged    *participantSet
+	effective uint64 // Avail height the staged set takes effect at
+}
+
+var _ ActiveSequencers = (*ActiveSet)(nil)
+
+// NewActiveSet returns the ActiveSet of the chain, switching over at the
+// slots of slotLen Avail blocks.
+func NewActiveSet(blockchain *blockchain.Blockchain, executor *state.Executor, slotLen uint64) *ActiveSet {
+	return &ActiveSet{
+		blockchain: blockchain,
+		slotLen:    slotLen,
+		query: func(header *types.Header) (*participantSet, error) {
+			return queryParticipantSet(blockchain, executor, header)
+		},
+	}
+}
+
+// TouchesStaking reports whether any transaction of the block calls the
+// staking contract, possibly changing the active set.
+func TouchesStaking(blk *types.Block) bool {
+	for _, tx := range blk.Transactions {
+		if tx.To != nil && *tx.To == AddrStakingContract {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Stage refreshes the set as of the given block, carried by the Avail block
+// at the given height; the refreshed set takes effect at the start of the
+// next slot, replacing any set staged before it in the same slot.
+func (s *ActiveSet) Stage(header *types.Header, availHeight uint64) error {
+	set, err := s.query(header)
+	if err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.staged = set
+	s.effective = (availHeight/s.slotLen + 1) * s.slotLen
+
+	return nil
+}
+
+// Advance switches over to the staged set once the Avail height reaches the
+// slot it takes effect in; it reports whether the set changed.
+func (s *ActiveSet) Advance(availHeight uint64) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.staged == nil || availHeight < s.effective {
+		return false
+	}
+
+	s.current, s.staged = s.staged, nil
+
+	return true
+}
+
+// Get returns the active sequencers.
+func (s *ActiveSet) Get() ([]types.Address, error) {
+	set, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+
+	return set.sequencers, nil
+}
+
+// Contains reports whether the address is among the active sequencers.
+func (s *ActiveSet) Contains(addr types.Address) (bool, error) {
+	return s.ContainsParticipant(addr, Sequencer)
+}
+
+// ContainsParticipant reports whether the address is among the active
+// participants of the given node type.
+func (s *ActiveSet) ContainsParticipant(addr types.Address, nodeType NodeType) (bool, error) {
+	set, err := s.load()
+	if err != nil {
+		return false, err
+	}
+
+	addrs := set.sequencers
+	if nodeType == WatchTower {
+		addrs = set.watchtowers
+	}
+
+	for _, a := range addrs {
+		if a == addr {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// load returns the current set, loading the one of the head of the chain
+// unless refreshed yet.
+func (s *ActiveSet) load() (*participantSet, error) {
+	s.lock.RLock()
+	set := s.current
+	s.lock.RUnlock()
+
+	if set != nil {
+		return set, nil
+	}
+
+	set, err := s.query(s.blockchain.Header())
+	if err != nil {
+		return nil, err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.current == nil {
+		s.current = set
+	}
+
+	return s.current, nil
+}
+
+// queryParticipantSet queries the active sequencers, the ones in probation
+// left out, and watchtowers from the staking contract as of the given block.
+func queryParticipantSet(blockchain *blockchain.Blockchain, executor *state.Executor, header *types.Header) (*participantSet, error) {
+	miner := types.BytesToAddress(header.Miner)
+
+	gasLimit, err := blockchain.CalculateGasLimit(header.Number + 1)
+	if err != nil {
+		return nil, err
+	}
+
+	// Every query runs on a transition of its own, on top of the block.
+	query := func(q func(*state.Transition, uint64, types.Address) ([]types.Address, error)) ([]types.Address, error) {
+		txn, err := beginQueryTxn(executor, header)
+		if err != nil {
+			return nil, err
+		}
+
+		return q(txn, gasLimit, miner)
+	}
+
+	sequencers, err := query(QuerySequencers)
+	if err != nil {
+		return nil, err
+	}
+
+	probation, err := query(QuerySequencersInProbation)
+	if err != nil {
+		return nil, err
+	}
+
+	watchtowers, err := query(QueryWatchtower)
+	if err != nil {
+		return nil, err
+	}
+
+	set := &participantSet{watchtowers: watchtowers}
+
+sequencers:
+	for _, addr := range sequencers {
+		for _, p := range probation {
+			if addr == p {
+				continue sequencers
+			}
+		}
+
+		set.sequencers = append(set.sequencers, addr)
+	}
+
+	return set, nil
+}
+
+// beginQueryTxn begins the transition the staking contract is queried on, on
+// top of the given block.
+func beginQueryTxn(executor *state.Executor, header *types.Header) (*state.Transition, error) {
+	return executor.BeginTxn(header.StateRoot, &types.Header{
+		ParentHash: header.Hash,
+		Number:     header.Number + 1,
+		Miner:      header.Miner,
+		GasLimit:   header.GasLimit,
+		Timestamp:  header.Timestamp,
+	}, types.BytesToAddress(header.Miner))
+}
+
+// QuerySequencerStake queries the stake of the sequencer from the staking
+// contract as of the given block, along with whether it's an active one, in
+// the set of the block.
+func QuerySequencerStake(blockchain *blockchain.Blockchain, executor *state.Executor, header *types.Header, addr types.Address) (*big.Int, bool, error) {
+	set, err := queryParticipantSet(blockchain, executor, header)
+	if err != nil {
+		return nil, false, err
+	}
+
+	miner := types.BytesToAddress(header.Miner)
+
+	gasLimit, err := blockchain.CalculateGasLimit(header.Number + 1)
+	if err != nil {
+		return nil, false, err
+	}
+
+	txn, err := beginQueryTxn(executor, header)
+	if err != nil {
+		return nil, false, err
+	}
+
+	stake, err := QueryParticipantBalance(txn, gasLimit, miner, addr)
+	if err != nil {
+		return nil, false, err
+	}
+
+	for _, sequencer := range set.sequencers {
+		if sequencer == addr {
+			return stake, true, nil
+		}
+	}
+
+	return stake, false, nil
+}
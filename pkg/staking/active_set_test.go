@@ -0,0 +1,69 @@
+package staking
+
+import (
+	"testing"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestActiveSetSwitchesAtNextSlot(t *testing.T) {
+	a := types.StringToAddress("0xC006b2443A1A61d7a1780B81Dbf7A591ceA0b2A0")
+	b := types.StringToAddress("0x40d170ea21c9477B8360D86CC3C2Baa0D9a9A438")
+	w := types.StringToAddress("0x8C037E6dA0A0ACfC2E38A5e046d3dB9EBD2b4Fcc")
+
+	// The staking contract as of each block number.
+	sets := map[uint64]*participantSet{
+		1: {sequencers: []types.Address{a}},
+		2: {sequencers: []types.Address{a, b}},
+		3: {sequencers: []types.Address{b}, watchtowers: []types.Address{w}},
+	}
+
+	s := &ActiveSet{
+		slotLen: 10,
+		current: sets[1],
+		query: func(header *types.Header) (*participantSet, error) {
+			return sets[header.Number], nil
+		},
+	}
+
+	contains := func(addr types.Address, nodeType NodeType) bool {
+		t.Helper()
+
+		ok, err := s.ContainsParticipant(addr, nodeType)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return ok
+	}
+
+	// The set staged in slot 1 takes effect at the start of slot 2.
+	assert.NoError(t, s.Stage(&types.Header{Number: 2}, 13))
+	assert.False(t, s.Advance(13))
+	assert.False(t, s.Advance(19))
+	assert.False(t, contains(b, Sequencer))
+
+	assert.True(t, s.Advance(20))
+	assert.True(t, contains(b, Sequencer))
+	assert.False(t, s.Advance(21))
+
+	// A later change in the same slot replaces the staged one.
+	assert.NoError(t, s.Stage(&types.Header{Number: 2}, 24))
+	assert.NoError(t, s.Stage(&types.Header{Number: 3}, 29))
+	assert.True(t, s.Advance(30))
+
+	sequencers, err := s.Get()
+	assert.NoError(t, err)
+	assert.Equal(t, []types.Address{b}, sequencers)
+	assert.False(t, contains(a, Sequencer))
+	assert.True(t, contains(w, WatchTower))
+	assert.False(t, contains(w, Sequencer))
+}
+
+func TestTouchesStaking(t *testing.T) {
+	other := types.StringToAddress("0x01")
+
+	assert.False(t, TouchesStaking(&types.Block{Transactions: []*types.Transaction{{To: &other}, {}}}))
+	assert.True(t, TouchesStaking(&types.Block{Transactions: []*types.Transaction{{To: &other}, {To: &AddrStakingContract}}}))
+}
@@ -1,6 +1,7 @@
 package staking
 
 import (
+	"bytes"
 	"crypto/ecdsa"
 	"errors"
 	"fmt"
@@ -487,6 +488,45 @@ func IsBeginDisputeResolutionTx(tx *types.Transaction) (bool, error) {
 	return false, nil
 }
 
+// disputeResolutionSelectors are the method selectors of the Staking contract beginning and ending a dispute resolution.
+var disputeResolutionSelectors = func() [][]byte {
+	stakingAbi := abi.MustNewABI(staking_contract.StakingABI)
+
+	return [][]byte{
+		stakingAbi.Methods["BeginDisputeResolution"].ID(),
+		stakingAbi.Methods["EndDisputeResolution"].ID(),
+	}
+}()
+
+// IsDisputeResolutionTx checks if the given transaction is a call of the Staking contract beginning or
+// ending a dispute resolution, by its target address and method selector.
+func IsDisputeResolutionTx(tx *types.Transaction) bool {
+	if tx == nil || tx.To == nil || *tx.To != AddrStakingContract || len(tx.Input) < 4 {
+		return false
+	}
+
+	for _, selector := range disputeResolutionSelectors {
+		if bytes.Equal(tx.Input[:4], selector) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// endDisputeResolutionSelector is the method selector of the Staking contract ending a dispute resolution.
+var endDisputeResolutionSelector = abi.MustNewABI(staking_contract.StakingABI).Methods["EndDisputeResolution"].ID()
+
+// EndedDispute returns the sequencer whose dispute the transaction ends, and whether the transaction is a call
+// of the Staking contract ending one.
+func EndedDispute(tx *types.Transaction) (types.Address, bool) {
+	if tx == nil || tx.To == nil || *tx.To != AddrStakingContract || len(tx.Input) < 4+32 || !bytes.Equal(tx.Input[:4], endDisputeResolutionSelector) {
+		return types.ZeroAddress, false
+	}
+
+	return types.BytesToAddress(tx.Input[4 : 4+32]), true
+}
+
 // EndDisputeResolutionTx constructs a transaction to conclude the dispute resolution process on the Staking contract.
 //
 // Similarly to BeginDisputeResolutionTx, it creates a transaction which includes the EndDisputeResolution method selector and the encoded input parameters.
@@ -723,3 +763,37 @@ func QueryDisputedWatchtowers(t *state.Transition, gasLimit uint64, from types.A
 
 	return DecodeParticipants(method, res.ReturnValue)
 }
+
+// disputeBeganID and disputeEndedID are the topics of the Staking contract events of a dispute resolution beginning and ending.
+var disputeBeganID, disputeEndedID = func() (types.Hash, types.Hash) {
+	stakingAbi := abi.MustNewABI(staking_contract.StakingABI)
+
+	return types.Hash(stakingAbi.Events["DisputeResolutionBegan"].ID()), types.Hash(stakingAbi.Events["DisputeResolutionEnded"].ID())
+}()
+
+// DisputeEvent is a dispute resolution beginning or ending on the Staking contract.
+type DisputeEvent struct {
+	// Account is the disputed sequencer of a dispute beginning; of a dispute ending, it's
+	// the sequencer or, when the watchtower was slashed, the watchtower.
+	Account types.Address
+
+	// Began tells a dispute beginning from a dispute ending.
+	Began bool
+}
+
+// ParseDisputeEvent decodes the dispute resolution event of the log emitted by the Staking contract.
+// It returns false for the other logs.
+func ParseDisputeEvent(log *types.Log) (DisputeEvent, bool) {
+	if log == nil || log.Address != AddrStakingContract || len(log.Topics) != 2 {
+		return DisputeEvent{}, false
+	}
+
+	switch log.Topics[0] {
+	case disputeBeganID:
+		return DisputeEvent{Account: types.BytesToAddress(log.Topics[1].Bytes()), Began: true}, true
+	case disputeEndedID:
+		return DisputeEvent{Account: types.BytesToAddress(log.Topics[1].Bytes())}, true
+	default:
+		return DisputeEvent{}, false
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"math/big"
 	"testing"
 
+	"github.com/0xPolygon/polygon-edge/types"
 	"github.com/availproject/op-evm/pkg/common"
 	"github.com/availproject/op-evm/pkg/test"
 	"github.com/hashicorp/go-hclog"
@@ -81,6 +82,46 @@ func TestIsBeginDisputeResolutionTx(t *testing.T) {
 	}
 }
 
+func TestIsDisputeResolutionTx(t *testing.T) {
+	from, _ := test.NewAccount(t)
+	probationAddr, _ := test.NewAccount(t)
+
+	begin, err := BeginDisputeResolutionTx(from, probationAddr, 1_000_000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	end, err := EndDisputeResolutionTx(from, probationAddr, 1_000_000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unstake, err := UnStakeTx(from, 1_000_000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The same call to any other contract.
+	elsewhere := end.Copy()
+	elsewhere.To = &probationAddr
+
+	tAssert := assert.New(t)
+	tAssert.True(IsDisputeResolutionTx(begin))
+	tAssert.True(IsDisputeResolutionTx(end))
+	tAssert.False(IsDisputeResolutionTx(unstake))
+	tAssert.False(IsDisputeResolutionTx(elsewhere))
+	tAssert.False(IsDisputeResolutionTx(&types.Transaction{To: &AddrStakingContract}))
+
+	ended, ok := EndedDispute(end)
+	tAssert.True(ok)
+	tAssert.Equal(probationAddr, ended)
+
+	_, ok = EndedDispute(begin)
+	tAssert.False(ok)
+	_, ok = EndedDispute(elsewhere)
+	tAssert.False(ok)
+}
+
 func TestEndDisputeResolution(t *testing.T) {
 	tAssert := assert.New(t)
 
@@ -0,0 +1,81 @@
+package staking
+
+import (
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+// ErrNoActiveSequencers is returned when there is no sequencer to lead a slot.
+var ErrNoActiveSequencers = errors.New("no active sequencers")
+
+// LeaderSchedule is the round-robin schedule of the sequencers allowed to
+// produce blocks. The Avail chain is divided into slots of a fixed number of
+// Avail blocks, and the active sequencers, ordered by address, take turns
+// leading the slots by the Avail block height. Whenever a number of Avail
+// blocks pass without a new block in them, the next sequencer in order takes
+// over the rest of the slot.
+//
+// The schedule is computed from the Avail blocks only, so it's the same on
+// every node that observes all the Avail blocks of the slot.
+type LeaderSchedule struct {
+	slotLen         uint64
+	failoverTimeout uint64
+
+	lock         sync.Mutex
+	observed     bool
+	slot         uint64
+	turn         uint64
+	lastProgress uint64
+}
+
+// NewLeaderSchedule returns the LeaderSchedule with slots of slotLen Avail
+// blocks, failing over to the next sequencer after failoverTimeout Avail
+// blocks without a new block; zero timeout disables the failover.
+func NewLeaderSchedule(slotLen, failoverTimeout uint64) *LeaderSchedule {
+	return &LeaderSchedule{
+		slotLen:         slotLen,
+		failoverTimeout: failoverTimeout,
+	}
+}
+
+// Observe advances the schedule to the Avail block at the given height;
+// produced reports whether the Avail block carried any block.
+func (s *LeaderSchedule) Observe(availHeight uint64, produced bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if slot := availHeight / s.slotLen; !s.observed || slot != s.slot {
+		s.observed = true
+		s.slot = slot
+		s.turn = 0
+		s.lastProgress = availHeight
+	}
+
+	switch {
+	case produced:
+		s.lastProgress = availHeight
+	case s.failoverTimeout > 0 && availHeight-s.lastProgress >= s.failoverTimeout:
+		s.turn++
+		s.lastProgress = availHeight
+	}
+}
+
+// Leader returns the sequencer leading the current turn of the slot, out of
+// the active sequencers.
+func (s *LeaderSchedule) Leader(sequencers []types.Address) (types.Address, error) {
+	if len(sequencers) == 0 {
+		return types.ZeroAddress, ErrNoActiveSequencers
+	}
+
+	ordered := make(addresses, len(sequencers))
+	copy(ordered, sequencers)
+	sort.Stable(ordered)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return ordered[(s.slot+s.turn)%uint64(len(ordered))], nil
+}
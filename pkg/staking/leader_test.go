@@ -0,0 +1,113 @@
+package staking
+
+import (
+	"testing"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// simulateSlots runs the sequencers over the slots, each following its own
+// schedule, and returns the producer of every Avail block. The blocks of the
+// muted sequencers never make it to Avail.
+func simulateSlots(t *testing.T, sequencers []types.Address, muted map[types.Address]bool, slots, slotLen, timeout uint64) []types.Address {
+	t.Helper()
+
+	schedules := make([]*LeaderSchedule, len(sequencers))
+	for i := range schedules {
+		schedules[i] = NewLeaderSchedule(slotLen, timeout)
+	}
+
+	producers := make([]types.Address, 0, slots*slotLen)
+
+	// Whether the Avail block carries the block produced after the previous one.
+	produced := false
+
+	for h := uint64(0); h < slots*slotLen; h++ {
+		var producer []types.Address
+
+		for i, s := range schedules {
+			s.Observe(h, produced)
+
+			leader, err := s.Leader(sequencers)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if leader == sequencers[i] {
+				producer = append(producer, sequencers[i])
+			}
+		}
+
+		if !assert.Len(t, producer, 1, "avail block %d", h) {
+			t.FailNow()
+		}
+
+		producers = append(producers, producer[0])
+		produced = !muted[producer[0]]
+	}
+
+	return producers
+}
+
+func TestLeaderScheduleRoundRobin(t *testing.T) {
+	sequencers := []types.Address{
+		types.StringToAddress("0xC006b2443A1A61d7a1780B81Dbf7A591ceA0b2A0"),
+		types.StringToAddress("0x40d170ea21c9477B8360D86CC3C2Baa0D9a9A438"),
+		types.StringToAddress("0x8C037E6dA0A0ACfC2E38A5e046d3dB9EBD2b4Fcc"),
+	}
+
+	ordered := []types.Address{sequencers[1], sequencers[2], sequencers[0]}
+
+	const slots, slotLen = 20, 7
+	producers := simulateSlots(t, sequencers, nil, slots, slotLen, 2)
+
+	// A single leader per slot, taking turns in the order of the addresses.
+	for h, producer := range producers {
+		slot := h / slotLen
+		assert.Equal(t, ordered[slot%len(ordered)], producer, "avail block %d", h)
+	}
+}
+
+func TestLeaderScheduleFailover(t *testing.T) {
+	sequencers := []types.Address{
+		types.StringToAddress("0x40d170ea21c9477B8360D86CC3C2Baa0D9a9A438"),
+		types.StringToAddress("0x8C037E6dA0A0ACfC2E38A5e046d3dB9EBD2b4Fcc"),
+		types.StringToAddress("0xC006b2443A1A61d7a1780B81Dbf7A591ceA0b2A0"),
+	}
+
+	const slots, slotLen, timeout = 20, 7, 2
+	producers := simulateSlots(t, sequencers, map[types.Address]bool{sequencers[1]: true}, slots, slotLen, timeout)
+
+	for slot := uint64(0); slot < slots; slot++ {
+		start := slot * slotLen
+		leader := sequencers[slot%3]
+
+		for h := start; h < start+slotLen; h++ {
+			switch {
+			case leader != sequencers[1] || h < start+timeout:
+				assert.Equal(t, leader, producers[h], "avail block %d", h)
+			default:
+				// The next in order takes over from the muted leader.
+				assert.Equal(t, sequencers[(slot+1)%3], producers[h], "avail block %d", h)
+			}
+		}
+	}
+}
+
+func TestLeaderScheduleWithoutFailover(t *testing.T) {
+	sequencers := []types.Address{
+		types.StringToAddress("0x40d170ea21c9477B8360D86CC3C2Baa0D9a9A438"),
+		types.StringToAddress("0x8C037E6dA0A0ACfC2E38A5e046d3dB9EBD2b4Fcc"),
+	}
+
+	producers := simulateSlots(t, sequencers, map[types.Address]bool{sequencers[0]: true}, 2, 7, 0)
+
+	// The muted leader keeps the slot.
+	for _, producer := range producers[:7] {
+		assert.Equal(t, sequencers[0], producer)
+	}
+
+	_, err := NewLeaderSchedule(7, 2).Leader(nil)
+	assert.ErrorIs(t, err, ErrNoActiveSequencers)
+}
@@ -0,0 +1,113 @@
+package staking
+
+import (
+	"math/big"
+	"sort"
+
+	"github.com/0xPolygon/polygon-edge/state"
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/pkg/blockchain"
+)
+
+// ParticipantStatus is the standing of a staked participant in the staking
+// contract as of a block.
+type ParticipantStatus struct {
+	Address  types.Address
+	NodeType NodeType
+	Stake    *big.Int
+
+	// InProbation is set for the sequencer in probation, accused by a fraud
+	// proof, and Disputed for either party of a dispute in progress.
+	InProbation bool
+	Disputed    bool
+}
+
+// QueryParticipantStatuses queries the standing of the staked sequencers and
+// watchtowers from the staking contract as of the given block, the
+// sequencers first, each by address.
+func QueryParticipantStatuses(blockchain *blockchain.Blockchain, executor *state.Executor, header *types.Header) ([]ParticipantStatus, error) {
+	miner := types.BytesToAddress(header.Miner)
+
+	gasLimit, err := blockchain.CalculateGasLimit(header.Number + 1)
+	if err != nil {
+		return nil, err
+	}
+
+	// Every query runs on a transition of its own, on top of the block.
+	query := func(q func(*state.Transition, uint64, types.Address) ([]types.Address, error)) ([]types.Address, error) {
+		txn, err := beginQueryTxn(executor, header)
+		if err != nil {
+			return nil, err
+		}
+
+		return q(txn, gasLimit, miner)
+	}
+
+	sequencers, err := query(QuerySequencers)
+	if err != nil {
+		return nil, err
+	}
+
+	probation, err := query(QuerySequencersInProbation)
+	if err != nil {
+		return nil, err
+	}
+
+	watchtowers, err := query(QueryWatchtower)
+	if err != nil {
+		return nil, err
+	}
+
+	disputed, err := query(QueryDisputedWatchtowers)
+	if err != nil {
+		return nil, err
+	}
+
+	inProbation := addressSet(probation)
+	disputing := addressSet(disputed)
+
+	statuses := make([]ParticipantStatus, 0, len(sequencers)+len(watchtowers))
+
+	for _, addrs := range []struct {
+		nodeType NodeType
+		addrs    []types.Address
+	}{{Sequencer, sequencers}, {WatchTower, watchtowers}} {
+		sorted := append([]types.Address(nil), addrs.addrs...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i].String() < sorted[j].String() })
+
+		for _, addr := range sorted {
+			txn, err := beginQueryTxn(executor, header)
+			if err != nil {
+				return nil, err
+			}
+
+			stake, err := QueryParticipantBalance(txn, gasLimit, miner, addr)
+			if err != nil {
+				return nil, err
+			}
+
+			status := ParticipantStatus{Address: addr, NodeType: addrs.nodeType, Stake: stake}
+
+			if addrs.nodeType == Sequencer {
+				_, status.InProbation = inProbation[addr]
+				status.Disputed = status.InProbation
+			} else {
+				_, status.Disputed = disputing[addr]
+			}
+
+			statuses = append(statuses, status)
+		}
+	}
+
+	return statuses, nil
+}
+
+// addressSet returns the set of the given addresses.
+func addressSet(addrs []types.Address) map[types.Address]struct{} {
+	set := make(map[types.Address]struct{}, len(addrs))
+	for _, addr := range addrs {
+		set[addr] = struct{}{}
+	}
+
+	return set
+}
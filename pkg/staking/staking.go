@@ -1,6 +1,7 @@
 package staking
 
 import (
+	"bytes"
 	"crypto/ecdsa"
 	"errors"
 	"math/big"
@@ -206,3 +207,49 @@ func SlashStakerTx(activeSequencerAddr types.Address, maliciousStakerAddr types.
 
 	return tx, nil
 }
+
+// slashSelector is the method selector of the Staking contract slashing a staker.
+var slashSelector = abi.MustNewABI(staking.StakingABI).Methods["slash"].ID()
+
+// SlashedStaker returns the staker slashed by the transaction, and whether the
+// transaction is a call of the Staking contract slashing one.
+func SlashedStaker(tx *types.Transaction) (types.Address, bool) {
+	if tx == nil || tx.To == nil || *tx.To != AddrStakingContract || len(tx.Input) < 4+32 || !bytes.Equal(tx.Input[:4], slashSelector) {
+		return types.ZeroAddress, false
+	}
+
+	return types.BytesToAddress(tx.Input[4 : 4+32]), true
+}
+
+// slashedID is the topic of the Staking contract event of a staker slashed.
+var slashedID = types.Hash(abi.MustNewABI(staking.StakingABI).Events["Slashed"].ID())
+
+// SlashEvent is a staker slashed on the Staking contract. The event doesn't
+// name the slashed staker; that's the one of the slash transaction emitting
+// it, see SlashedStaker.
+type SlashEvent struct {
+	// Slasher is the sequencer calling the slash, and FeeRecipient the
+	// watchtower rewarded with the slashed amount.
+	Slasher      types.Address
+	FeeRecipient types.Address
+
+	// TotalStaked is the stake left on the contract, and SlashedAmount the
+	// amount slashed off the staker.
+	TotalStaked   *big.Int
+	SlashedAmount *big.Int
+}
+
+// ParseSlashEvent decodes the slash event of the log emitted by the Staking contract.
+// It returns false for the other logs.
+func ParseSlashEvent(log *types.Log) (SlashEvent, bool) {
+	if log == nil || log.Address != AddrStakingContract || len(log.Topics) != 3 || log.Topics[0] != slashedID || len(log.Data) < 2*32 {
+		return SlashEvent{}, false
+	}
+
+	return SlashEvent{
+		Slasher:       types.BytesToAddress(log.Topics[1].Bytes()),
+		FeeRecipient:  types.BytesToAddress(log.Topics[2].Bytes()),
+		TotalStaked:   new(big.Int).SetBytes(log.Data[:32]),
+		SlashedAmount: new(big.Int).SetBytes(log.Data[32:64]),
+	}, true
+}
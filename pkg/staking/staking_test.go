@@ -223,6 +223,50 @@ func TestSlashStaker(t *testing.T) {
 	coinbaseSlashErr := Slash(blockchain, executor, hclog.Default(), sequencerAddr, sequencerSignKey, maliciousSequencerAddr, 1_000_000, "test")
 	tAssert.NoError(coinbaseSlashErr)
 
+	// The slash block calls the slash of the malicious sequencer, and the
+	// contract reports the amounts slashed.
+	slashBlk, ok := blockchain.GetBlockByHash(blockchain.Header().Hash, true)
+	tAssert.True(ok)
+
+	slashedAddr, isSlash := SlashedStaker(slashBlk.Transactions[0])
+	tAssert.True(isSlash)
+	tAssert.Equal(maliciousSequencerAddr, slashedAddr)
+
+	_, isSlash = SlashedStaker(&types.Transaction{To: &AddrStakingContract, Input: slashBlk.Transactions[0].Input[:4]})
+	tAssert.False(isSlash)
+
+	receipts, err := blockchain.GetReceiptsByHash(slashBlk.Hash())
+	tAssert.NoError(err)
+
+	var events []SlashEvent
+
+	for _, log := range receipts[0].Logs {
+		if event, ok := ParseSlashEvent(log); ok {
+			events = append(events, event)
+		}
+	}
+
+	if tAssert.Len(events, 1) {
+		tAssert.Equal(sequencerAddr, events[0].Slasher)
+		tAssert.Equal(coinbaseAddr, events[0].FeeRecipient)
+		tAssert.Equal("29900000000000000000", events[0].TotalStaked.String())
+		tAssert.Equal("100000000000000000", events[0].SlashedAmount.String())
+	}
+
+	// In probation until slashed, the malicious sequencer is active again
+	// with the stake left.
+	probationHeader, _ := blockchain.GetHeaderByHash(slashBlk.ParentHash())
+
+	stake, active, err := QuerySequencerStake(blockchain, executor, probationHeader, maliciousSequencerAddr)
+	tAssert.NoError(err)
+	tAssert.Equal("10000000000000000000", stake.String())
+	tAssert.False(active)
+
+	stake, active, err = QuerySequencerStake(blockchain, executor, slashBlk.Header, maliciousSequencerAddr)
+	tAssert.NoError(err)
+	tAssert.Equal("9900000000000000000", stake.String())
+	tAssert.True(active)
+
 	isProbationSequencer, isProbationSequencerErr = dr.Contains(maliciousSequencerAddr, Sequencer)
 	tAssert.NoError(isProbationSequencerErr)
 	tAssert.False(isProbationSequencer)
@@ -1,6 +1,7 @@
 package staking
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/0xPolygon/polygon-edge/state"
@@ -10,6 +11,9 @@ import (
 	"github.com/hashicorp/go-hclog"
 )
 
+// ErrNotActiveSequencer is returned when the signer of a header isn't among the active sequencers.
+var ErrNotActiveSequencer = errors.New("does not belong to active sequencers")
+
 // verifier is a struct that implements the blockchain.Verifier interface.
 type verifier struct {
 	activeSequencers ActiveParticipants
@@ -61,7 +65,7 @@ func (v *verifier) VerifyHeader(header *types.Header) error {
 
 	if !minerIsActiveSequencer {
 		v.logger.Error("failed to verify signer address", "address", signer)
-		return fmt.Errorf("signer address '%s' does not belong to active sequencers", signer)
+		return fmt.Errorf("signer address '%s' %w", signer, ErrNotActiveSequencer)
 	}
 
 	v.logger.Info("Seal signer address successfully verified!", "signer", signer)
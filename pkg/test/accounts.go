@@ -17,11 +17,11 @@ import (
 )
 
 // NewAccount is a test helper function that creates a new account with a private key and returns its address and private key.
-// It takes a pointer to a testing.T object as argument.
+// It takes the testing.TB of a test or a benchmark as argument.
 // This function should be used within tests to generate a new account.
 // Example usage (within a test):
 // address, privateKey := NewAccount(t)
-func NewAccount(t *testing.T) (types.Address, *ecdsa.PrivateKey) {
+func NewAccount(t testing.TB) (types.Address, *ecdsa.PrivateKey) {
 	t.Helper()
 
 	privateKey, err := ecdsa.GenerateKey(crypto.S256(), rand.Reader)
@@ -9,7 +9,7 @@ import (
 	"os"
 	"path/filepath"
 
-	"github.com/0xPolygon/polygon-edge/blockchain/storage/memory"
+	"github.com/0xPolygon/polygon-edge/blockchain/storage"
 	"github.com/0xPolygon/polygon-edge/chain"
 	edgechain "github.com/0xPolygon/polygon-edge/chain"
 	"github.com/0xPolygon/polygon-edge/crypto"
@@ -74,7 +74,7 @@ func NewBlockchain(verifier blockchain.Verifier, basepath string) (*state.Execut
 		),
 	)
 
-	db, err := memory.NewMemoryStorage(nil)
+	db, err := NewMemoryStorage()
 	if err != nil {
 		return nil, nil, err
 	}
@@ -98,7 +98,22 @@ func NewBlockchain(verifier blockchain.Verifier, basepath string) (*state.Execut
 // It also initializes a transaction pool with default parameters.
 // It returns an executor, a blockchain, a transaction pool, and an error if any occurred during the initialization.
 func NewBlockchainWithTxPool(chainSpec *chain.Chain, verifier blockchain.Verifier) (*state.Executor, *blockchain.Blockchain, *txpool.TxPool, error) {
-	executor := NewInMemExecutor(chainSpec)
+	db, err := NewMemoryStorage()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return NewBlockchainWithTxPoolOn(chainSpec, verifier, db)
+}
+
+// NewBlockchainWithTxPoolOn is NewBlockchainWithTxPool with the blockchain on the given storage.
+func NewBlockchainWithTxPoolOn(chainSpec *chain.Chain, verifier blockchain.Verifier, db storage.Storage) (*state.Executor, *blockchain.Blockchain, *txpool.TxPool, error) {
+	return NewBlockchainWithTxPoolOnState(chainSpec, verifier, db, itrie.NewMemoryStorage())
+}
+
+// NewBlockchainWithTxPoolOnState is NewBlockchainWithTxPoolOn with the state on the given storage.
+func NewBlockchainWithTxPoolOnState(chainSpec *chain.Chain, verifier blockchain.Verifier, db storage.Storage, stateStorage itrie.Storage) (*state.Executor, *blockchain.Blockchain, *txpool.TxPool, error) {
+	executor := state.NewExecutor(chainSpec.Params, itrie.NewState(stateStorage), hclog.Default())
 
 	gr, err := executor.WriteGenesis(chainSpec.Genesis.Alloc, types.ZeroHash)
 	if err != nil {
@@ -117,11 +132,6 @@ func NewBlockchainWithTxPool(chainSpec *chain.Chain, verifier blockchain.Verifie
 		),
 	)
 
-	db, err := memory.NewMemoryStorage(nil)
-	if err != nil {
-		return nil, nil, nil, err
-	}
-
 	bchain, err := blockchain.NewBlockchain(hclog.Default(), db, chainSpec, nil, executor, signer)
 	if err != nil {
 		return nil, nil, nil, err
@@ -140,7 +150,7 @@ func NewBlockchainWithTxPool(chainSpec *chain.Chain, verifier blockchain.Verifie
 		NewTxpoolHub(executor.State(), bchain),
 		nil,
 		nil,
-		&txpool.Config{MaxSlots: 10, MaxAccountEnqueued: 100},
+		&txpool.Config{MaxSlots: 64, MaxAccountEnqueued: 100},
 	)
 	if err != nil {
 		return nil, nil, nil, err
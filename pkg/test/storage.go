@@ -0,0 +1,42 @@
+package test
+
+import (
+	"sync"
+
+	"github.com/0xPolygon/polygon-edge/blockchain/storage"
+	"github.com/0xPolygon/polygon-edge/helper/hex"
+)
+
+// NewMemoryStorage creates a new in-memory blockchain storage. Unlike the one of edge, it's safe for
+// concurrent use, such as the blocks written by a running sequencer while a test reads the chain.
+func NewMemoryStorage() (storage.Storage, error) {
+	return storage.NewKeyValueStorage(nil, &memoryKV{db: make(map[string][]byte)}), nil
+}
+
+// memoryKV is an in-memory storage.KV guarded by a lock.
+type memoryKV struct {
+	lock sync.RWMutex
+	db   map[string][]byte
+}
+
+func (m *memoryKV) Set(p []byte, v []byte) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.db[hex.EncodeToHex(p)] = v
+
+	return nil
+}
+
+func (m *memoryKV) Get(p []byte) ([]byte, bool, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	v, ok := m.db[hex.EncodeToHex(p)]
+
+	return v, ok, nil
+}
+
+func (m *memoryKV) Close() error {
+	return nil
+}
@@ -0,0 +1,254 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+)
+
+// The block tags resolved to the settled head of the chain; the JSON-RPC
+// server of edge knows only `latest`, `pending` and `earliest`.
+const (
+	finalizedBlockTag = "finalized"
+	safeBlockTag      = "safe"
+)
+
+// blockTagParams are the fields of the object parameters naming a block.
+var blockTagParams = []string{"fromBlock", "toBlock", "blockNumber"}
+
+// blockTagHandler resolves the `finalized` and `safe` block tags of the
+// JSON-RPC requests, single or batched, to the number of the settled head
+// before handing them over to the next handler. The requests without the
+// tags go through untouched. The messages of the websocket clients come in
+// as the requests of the wsBridge, resolved the same.
+type blockTagHandler struct {
+	next    http.Handler
+	settled func() uint64
+	logger  hclog.Logger
+}
+
+// newBlockTagHandler returns the blockTagHandler resolving the tags to the
+// block number returned by settled.
+func newBlockTagHandler(next http.Handler, settled func() uint64, logger hclog.Logger) *blockTagHandler {
+	return &blockTagHandler{
+		next:    next,
+		settled: settled,
+		logger:  logger,
+	}
+}
+
+func (h *blockTagHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.Body == nil {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if resolved, ok := h.resolve(body); ok {
+		body = resolved
+	}
+
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+	r.Header.Del("Content-Length")
+
+	h.next.ServeHTTP(w, r)
+}
+
+// resolve returns the request body with the block tags resolved, and whether
+// there were any; the malformed bodies are left to the next handler to
+// reject.
+func (h *blockTagHandler) resolve(body []byte) ([]byte, bool) {
+	if !bytes.Contains(body, []byte(finalizedBlockTag)) && !bytes.Contains(body, []byte(safeBlockTag)) {
+		return nil, false
+	}
+
+	number := fmt.Sprintf("0x%x", h.settled())
+
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '[' {
+		var batch []map[string]json.RawMessage
+		if err := json.Unmarshal(body, &batch); err != nil {
+			return nil, false
+		}
+
+		changed := false
+		for _, req := range batch {
+			changed = resolveBlockTags(req, number) || changed
+		}
+
+		if !changed {
+			return nil, false
+		}
+
+		return h.marshal(batch)
+	}
+
+	var req map[string]json.RawMessage
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, false
+	}
+
+	if !resolveBlockTags(req, number) {
+		return nil, false
+	}
+
+	return h.marshal(req)
+}
+
+func (h *blockTagHandler) marshal(v interface{}) ([]byte, bool) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		h.logger.Error("failed to encode the request with the block tags resolved", "error", err)
+		return nil, false
+	}
+
+	return body, true
+}
+
+// resolveBlockTags replaces the block tags among the parameters of the
+// request with the given block number, reporting whether there were any.
+func resolveBlockTags(req map[string]json.RawMessage, number string) bool {
+	var params []json.RawMessage
+	if err := json.Unmarshal(req["params"], &params); err != nil {
+		return false
+	}
+
+	changed := false
+
+	for i, param := range params {
+		if resolved, ok := resolveBlockTag(param, number); ok {
+			params[i], changed = resolved, true
+			continue
+		}
+
+		var obj map[string]json.RawMessage
+		if err := json.Unmarshal(param, &obj); err != nil {
+			continue
+		}
+
+		objChanged := false
+
+		for _, key := range blockTagParams {
+			if resolved, ok := resolveBlockTag(obj[key], number); ok {
+				obj[key], objChanged = resolved, true
+			}
+		}
+
+		if !objChanged {
+			continue
+		}
+
+		raw, err := json.Marshal(obj)
+		if err != nil {
+			continue
+		}
+
+		params[i], changed = raw, true
+	}
+
+	if !changed {
+		return false
+	}
+
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return false
+	}
+
+	req["params"] = raw
+
+	return true
+}
+
+// resolveBlockTag returns the given block number in place of the parameter
+// if it's one of the block tags.
+func resolveBlockTag(param json.RawMessage, number string) (json.RawMessage, bool) {
+	var tag string
+	if err := json.Unmarshal(param, &tag); err != nil {
+		return nil, false
+	}
+
+	if tag = strings.ToLower(tag); tag != finalizedBlockTag && tag != safeBlockTag {
+		return nil, false
+	}
+
+	raw, err := json.Marshal(number)
+	if err != nil {
+		return nil, false
+	}
+
+	return raw, true
+}
+
+// loopbackAddr returns a free TCP address on the loopback interface.
+func loopbackAddr() (*net.TCPAddr, error) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	addr, ok := lis.Addr().(*net.TCPAddr)
+	if !ok {
+		_ = lis.Close()
+		return nil, errors.New("unexpected loopback address")
+	}
+
+	return addr, lis.Close()
+}
+
+// startJSONRPCProxy serves the JSON-RPC server of edge, listening on the
+// given upstream address, on the public listen address, resolving the
+// `finalized` and `safe` block tags to the block number returned by settled,
+// answering the fee methods edge lacks from the given store, the trace
+// methods from the given backend and the txpool methods from the given
+// txpool, within the given limits. The websocket clients are served the
+// same, through the wsBridge.
+func startJSONRPCProxy(listenAddr, upstream *net.TCPAddr, settled func() uint64, fees feeStore, priceLimit uint64, traces traceBackend, pool inspectedTxPool, limits RPCLimitConfig, logger hclog.Logger) (*http.Server, error) {
+	target := &url.URL{Scheme: "http", Host: upstream.String()}
+
+	proxy := newUpstreamHandler(httputil.NewSingleHostReverseProxy(target))
+
+	local := newFeeHandler(newTraceHandler(newTxPoolHandler(proxy, pool, logger), traces, logger), fees, priceLimit, logger)
+
+	limited, err := newRPCLimitHandler(newBlockTagHandler(local, settled, logger), limits, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	wsUpstream := &url.URL{Scheme: "ws", Host: upstream.String(), Path: wsPath}
+
+	srv := &http.Server{
+		Handler:           newWSBridge(limited, wsUpstream.String(), logger),
+		ReadHeaderTimeout: 60 * time.Second,
+	}
+
+	lis, err := net.Listen("tcp", listenAddr.String())
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("JSON-RPC proxy started", "addr", listenAddr.String(), "upstream", upstream.String())
+
+	go func() {
+		if err := srv.Serve(lis); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("JSON-RPC proxy Serve", "error", err)
+		}
+	}()
+
+	return srv, nil
+}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlockTagHandlerResolvesSettledHead(t *testing.T) {
+	var settled atomic.Uint64
+
+	var received string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, int64(len(body)), r.ContentLength)
+		received = string(body)
+	})
+
+	srv := httptest.NewServer(newBlockTagHandler(next, settled.Load, hclog.NewNullLogger()))
+	defer srv.Close()
+
+	post := func(body string) string {
+		t.Helper()
+
+		resp, err := http.Post(srv.URL, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resp.Body.Close()
+
+		return received
+	}
+
+	getBlock := `{"jsonrpc":"2.0","id":1,"method":"eth_getBlockByNumber","params":["finalized",false]}`
+
+	settled.Store(2)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":1,"method":"eth_getBlockByNumber","params":["0x2",false]}`, post(getBlock))
+
+	// The tags follow the settled head as it moves on.
+	settled.Store(26)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":1,"method":"eth_getBlockByNumber","params":["0x1a",false]}`, post(getBlock))
+
+	// The batches and the block fields of the object parameters resolve too.
+	assert.JSONEq(t,
+		`[{"jsonrpc":"2.0","id":1,"method":"eth_getLogs","params":[{"fromBlock":"0x1a","toBlock":"latest","topics":[]}]},{"jsonrpc":"2.0","id":2,"method":"eth_call","params":[{"to":"0x01","value":1000000000000000000000},{"blockNumber":"0x1a"}]}]`,
+		post(`[{"jsonrpc":"2.0","id":1,"method":"eth_getLogs","params":[{"fromBlock":"safe","toBlock":"latest","topics":[]}]},{"jsonrpc":"2.0","id":2,"method":"eth_call","params":[{"to":"0x01","value":1000000000000000000000},{"blockNumber":"safe"}]}]`),
+	)
+
+	// The requests without the tags go through untouched.
+	latest := `{"jsonrpc":"2.0","id":1, "method":"eth_getBlockByNumber","params":["latest",false]}`
+	assert.Equal(t, latest, post(latest))
+
+	malformed := `{"params":["finalized"`
+	assert.Equal(t, malformed, post(malformed))
+}
+
+func TestBlockTagHandlerWebsocket(t *testing.T) {
+	// The upstream answers with the block parameter it got.
+	upstream := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage   `json:"id"`
+			Params []json.RawMessage `json:"params"`
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatal(err)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(&rpcResponse{Version: "2.0", ID: req.ID, Result: req.Params[0]})
+	})
+
+	settled := func() uint64 { return 26 }
+	c := newTestWSBridge(t, newBlockTagHandler(newUpstreamHandler(upstream), settled, hclog.NewNullLogger()))
+
+	var block string
+	assert.NoError(t, c.Call(&block, "eth_getBlockByNumber", "finalized", false))
+	assert.Equal(t, "0x1a", block)
+
+	assert.NoError(t, c.Call(&block, "eth_getBlockByNumber", "latest", false))
+	assert.Equal(t, "latest", block)
+}
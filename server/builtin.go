@@ -1,6 +1,11 @@
 package server
 
 import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
 	"github.com/0xPolygon/polygon-edge/chain"
 	"github.com/0xPolygon/polygon-edge/secrets"
 	"github.com/0xPolygon/polygon-edge/secrets/awsssm"
@@ -8,6 +13,8 @@ import (
 	"github.com/0xPolygon/polygon-edge/secrets/hashicorpvault"
 	"github.com/0xPolygon/polygon-edge/secrets/local"
 	"github.com/0xPolygon/polygon-edge/state"
+
+	avail_consensus "github.com/availproject/op-evm/consensus/avail"
 )
 
 // GenesisFactoryHook is a type definition for a function that takes a chain configuration
@@ -19,8 +26,65 @@ type GenesisFactoryHook func(config *chain.Chain, engineName string) func(*state
 // This allows for the creation of different types of secrets manager depending on the desired backend,
 // including local storage, Hashicorp Vault, AWS SSM, and GCP SSM.
 var secretsManagerBackends = map[secrets.SecretsManagerType]secrets.SecretsManagerFactory{
-	secrets.Local:          local.SecretsManagerFactory,
+	secrets.Local:          localSecretsManagerFactory,
 	secrets.HashicorpVault: hashicorpvault.SecretsManagerFactory,
 	secrets.AWSSSM:         awsssm.SecretsManagerFactory,
 	secrets.GCPSSM:         gcpssm.SecretsManagerFactory,
 }
+
+// nextValidatorKeyLocal is the file name of the next validator key of the
+// key rotation, in the consensus folder of the local secrets manager.
+const nextValidatorKeyLocal = "validator-next.key"
+
+// localSecretsManager is the local secrets manager serving the next
+// validator key of the key rotation as well, which it has no file of its own
+// for.
+type localSecretsManager struct {
+	secrets.SecretsManager
+	nextValidatorKeyPath string
+}
+
+// localSecretsManagerFactory returns the local secrets manager, serving the
+// next validator key from the consensus folder.
+func localSecretsManagerFactory(config *secrets.SecretsManagerConfig, params *secrets.SecretsManagerParams) (secrets.SecretsManager, error) {
+	m, err := local.SecretsManagerFactory(config, params)
+	if err != nil {
+		return nil, err
+	}
+
+	path, _ := params.Extra[secrets.Path].(string)
+
+	return &localSecretsManager{
+		SecretsManager:       m,
+		nextValidatorKeyPath: filepath.Join(path, secrets.ConsensusFolderLocal, nextValidatorKeyLocal),
+	}, nil
+}
+
+// GetSecret gets the secret by name.
+func (m *localSecretsManager) GetSecret(name string) ([]byte, error) {
+	if name != avail_consensus.NextValidatorKey {
+		return m.SecretsManager.GetSecret(name)
+	}
+
+	bs, err := os.ReadFile(m.nextValidatorKeyPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, secrets.ErrSecretNotFound
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to read secret from disk (%s), %w", m.nextValidatorKeyPath, err)
+	}
+
+	return bs, nil
+}
+
+// HasSecret checks if the secret is present.
+func (m *localSecretsManager) HasSecret(name string) bool {
+	if name != avail_consensus.NextValidatorKey {
+		return m.SecretsManager.HasSecret(name)
+	}
+
+	_, err := os.Stat(m.nextValidatorKeyPath)
+
+	return err == nil
+}
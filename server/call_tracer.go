@@ -0,0 +1,213 @@
+package server
+
+import (
+	"errors"
+	"math/big"
+	"sync"
+
+	"github.com/0xPolygon/polygon-edge/state/runtime"
+	"github.com/0xPolygon/polygon-edge/state/runtime/evm"
+	"github.com/0xPolygon/polygon-edge/state/runtime/tracer"
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// callFrame is a call of the result of the call tracer, with the calls it
+// made, as by the callTracer of go-ethereum.
+type callFrame struct {
+	Type         string          `json:"type"`
+	From         types.Address   `json:"from"`
+	To           types.Address   `json:"to"`
+	Value        *hexutil.Big    `json:"value,omitempty"`
+	Gas          hexutil.Uint64  `json:"gas"`
+	GasUsed      hexutil.Uint64  `json:"gasUsed"`
+	Input        hexutil.Bytes   `json:"input"`
+	Output       hexutil.Bytes   `json:"output,omitempty"`
+	Error        string          `json:"error,omitempty"`
+	RevertReason string          `json:"revertReason,omitempty"`
+	Calls        []*callFrame    `json:"calls,omitempty"`
+	gasLeft      *hexutil.Uint64 // The gas left after the last instruction of the call, if any
+}
+
+// callTracerConfig is the configuration of the call tracer.
+type callTracerConfig struct {
+	// OnlyTopCall traces the call of the transaction only, leaving out the
+	// calls it made.
+	OnlyTopCall bool `json:"onlyTopCall"`
+}
+
+// callTracer is the tracer of the call hierarchy of a transaction, with
+// the gas, the output and the error, the revert reason decoded, of every
+// call; the calls reverted are kept with the calls they made.
+type callTracer struct {
+	config callTracerConfig
+
+	cancelLock sync.RWMutex
+	reason     error
+
+	gasLimit uint64
+	gasUsed  uint64
+	stack    []*callFrame
+	top      *callFrame
+}
+
+var _ tracer.Tracer = (*callTracer)(nil)
+
+func newCallTracer(config callTracerConfig) *callTracer {
+	return &callTracer{config: config}
+}
+
+// Cancel stops the execution at the next instruction, the trace failing with
+// the given error.
+func (t *callTracer) Cancel(err error) {
+	t.cancelLock.Lock()
+	defer t.cancelLock.Unlock()
+
+	t.reason = err
+}
+
+func (t *callTracer) cancelled() error {
+	t.cancelLock.RLock()
+	defer t.cancelLock.RUnlock()
+
+	return t.reason
+}
+
+func (t *callTracer) Clear() {
+	t.cancelLock.Lock()
+	t.reason = nil
+	t.cancelLock.Unlock()
+
+	t.gasLimit, t.gasUsed = 0, 0
+	t.stack, t.top = nil, nil
+}
+
+func (t *callTracer) GetResult() (interface{}, error) {
+	if err := t.cancelled(); err != nil {
+		return nil, err
+	}
+
+	if t.top == nil {
+		return nil, errors.New("no call traced")
+	}
+
+	// The call of the transaction is accounted the gas of the transaction,
+	// the intrinsic gas included.
+	t.top.Gas, t.top.GasUsed = hexutil.Uint64(t.gasLimit), hexutil.Uint64(t.gasUsed)
+
+	return t.top, nil
+}
+
+func (t *callTracer) TxStart(gasLimit uint64) {
+	t.gasLimit = gasLimit
+}
+
+func (t *callTracer) TxEnd(gasLeft uint64) {
+	t.gasUsed = t.gasLimit - gasLeft
+}
+
+func (t *callTracer) CallStart(depth int, from, to types.Address, callType int, gas uint64, value *big.Int, input []byte) {
+	if t.config.OnlyTopCall && depth > 1 {
+		return
+	}
+
+	frame := &callFrame{
+		Type:  callTypeName(callType),
+		From:  from,
+		To:    to,
+		Gas:   hexutil.Uint64(gas),
+		Input: append([]byte{}, input...),
+	}
+
+	// The delegate and static calls carry no value of their own.
+	if value != nil && callType != int(runtime.DelegateCall) && callType != int(runtime.StaticCall) {
+		frame.Value = (*hexutil.Big)(new(big.Int).Set(value))
+	}
+
+	if len(t.stack) > 0 {
+		parent := t.stack[len(t.stack)-1]
+		parent.Calls = append(parent.Calls, frame)
+	} else {
+		t.top = frame
+	}
+
+	t.stack = append(t.stack, frame)
+}
+
+func (t *callTracer) CallEnd(depth int, output []byte, err error) {
+	if (t.config.OnlyTopCall && depth > 1) || len(t.stack) == 0 {
+		return
+	}
+
+	frame := t.stack[len(t.stack)-1]
+	t.stack = t.stack[:len(t.stack)-1]
+
+	switch {
+	case err == nil || errors.Is(err, runtime.ErrExecutionReverted):
+		// The calls returning, or reverting, hand the gas left back.
+		if frame.gasLeft != nil && *frame.gasLeft <= frame.Gas {
+			frame.GasUsed = frame.Gas - *frame.gasLeft
+		}
+
+		if len(output) > 0 {
+			frame.Output = append([]byte{}, output...)
+		}
+	default:
+		// The calls failing otherwise consume all their gas.
+		frame.GasUsed = frame.Gas
+	}
+
+	if err != nil {
+		frame.Error = err.Error()
+
+		if errors.Is(err, runtime.ErrExecutionReverted) {
+			if reason, uerr := abi.UnpackRevert(output); uerr == nil {
+				frame.RevertReason = reason
+			}
+		}
+	}
+}
+
+// CaptureState halts the execution of the trace canceled.
+func (t *callTracer) CaptureState(_ []byte, _ []*big.Int, _ int, _ types.Address, _ int, _ tracer.RuntimeHost, state tracer.VMState) {
+	if t.cancelled() != nil {
+		state.Halt()
+	}
+}
+
+// ExecuteState keeps the gas left after the instruction executed by the
+// call in flight, the gas it hands back on returning.
+func (t *callTracer) ExecuteState(_ types.Address, _ uint64, _ string, availableGas, cost uint64, _ []byte, depth int, _ error, _ tracer.RuntimeHost) {
+	if len(t.stack) == 0 || (t.config.OnlyTopCall && depth > 1) {
+		return
+	}
+
+	left := hexutil.Uint64(0)
+	if cost < availableGas {
+		left = hexutil.Uint64(availableGas - cost)
+	}
+
+	t.stack[len(t.stack)-1].gasLeft = &left
+}
+
+// callTypeName returns the name of the call type reported to the tracers.
+// The executor reports the contract creations, by either opcode, as CREATE.
+func callTypeName(callType int) string {
+	switch callType {
+	case int(runtime.Call):
+		return "CALL"
+	case int(runtime.CallCode):
+		return "CALLCODE"
+	case int(runtime.DelegateCall):
+		return "DELEGATECALL"
+	case int(runtime.StaticCall):
+		return "STATICCALL"
+	case int(runtime.Create), evm.CREATE:
+		return "CREATE"
+	case int(runtime.Create2), evm.CREATE2:
+		return "CREATE2"
+	default:
+		return "UNKNOWN"
+	}
+}
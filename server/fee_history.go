@@ -0,0 +1,589 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"sort"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/pkg/block"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/hashicorp/go-hclog"
+)
+
+// The fee methods answered by the feeHandler; the JSON-RPC server of edge
+// has neither.
+const (
+	feeHistoryMethod           = "eth_feeHistory"
+	maxPriorityFeePerGasMethod = "eth_maxPriorityFeePerGas"
+)
+
+const (
+	// maxFeeHistory is the most blocks returned by eth_feeHistory, as by
+	// go-ethereum.
+	maxFeeHistory = 1024
+
+	// The tip suggested by eth_maxPriorityFeePerGas is the percentile of the
+	// lowest tips of the recent blocks, as by the gas price oracle of
+	// go-ethereum: up to tipSamples of each of the last tipBlocks, the tips
+	// under ignoreTip left out, capped at maxTip.
+	tipBlocks     = 20
+	tipSamples    = 3
+	tipPercentile = 60
+	ignoreTip     = 2
+	maxTip        = 500_000_000_000
+)
+
+// The JSON-RPC error codes of the fee methods, as by go-ethereum.
+const (
+	rpcInvalidParamsCode = -32602
+	rpcServerErrorCode   = -32000
+)
+
+var (
+	errInvalidPercentile = errors.New("invalid reward percentile")
+	errRequestBeyondHead = errors.New("request beyond head block")
+)
+
+// feeStore is the chain the fees are read from.
+type feeStore interface {
+	Header() *types.Header
+	GetHeaderByNumber(n uint64) (*types.Header, bool)
+	GetBlockByHash(hash types.Hash, full bool) (*types.Block, bool)
+	GetReceiptsByHash(hash types.Hash) ([]*types.Receipt, error)
+	CalculateBaseFee(parent *types.Header) uint64
+}
+
+// rpcRequest is a JSON-RPC request, single or within a batch.
+type rpcRequest struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+}
+
+// rpcResponse is the JSON-RPC response to a request answered by the proxy
+// itself.
+type rpcResponse struct {
+	Version string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// feeHistory is the result of eth_feeHistory.
+type feeHistory struct {
+	OldestBlock  hexutil.Uint64   `json:"oldestBlock"`
+	Reward       [][]*hexutil.Big `json:"reward,omitempty"`
+	BaseFee      []*hexutil.Big   `json:"baseFeePerGas,omitempty"`
+	GasUsedRatio []float64        `json:"gasUsedRatio"`
+}
+
+// feeHandler answers the eth_feeHistory and eth_maxPriorityFeePerGas
+// requests, single or batched, from the recent blocks of the chain, handing
+// the others over to the next handler; over websocket as well, through the
+// wsBridge.
+type feeHandler struct {
+	next       http.Handler
+	store      feeStore
+	priceLimit uint64
+	logger     hclog.Logger
+}
+
+// newFeeHandler returns the feeHandler reading the fees from the given store;
+// the tips it suggests lift the gas price of the next block up to the price
+// limit of the sequencer.
+func newFeeHandler(next http.Handler, store feeStore, priceLimit uint64, logger hclog.Logger) *feeHandler {
+	return &feeHandler{
+		next:       next,
+		store:      store,
+		priceLimit: priceLimit,
+		logger:     logger,
+	}
+}
+
+func (h *feeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	serveLocalRPC(w, r, h.next, []string{feeHistoryMethod, maxPriorityFeePerGasMethod}, h.answer, h.logger)
+}
+
+// serveLocalRPC answers the requests of the given methods, single or batched,
+// with the given function, handing the others over to the next handler. The
+// messages of the websocket clients come in as the requests of the wsBridge,
+// answered the same.
+func serveLocalRPC(w http.ResponseWriter, r *http.Request, next http.Handler, methods []string, answer func(*rpcRequest) *rpcResponse, logger hclog.Logger) {
+	if r.Method != http.MethodPost || r.Body == nil {
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	forward := func(body []byte) {
+		next.ServeHTTP(w, setBody(r, body))
+	}
+
+	local := func(method string) bool {
+		for _, m := range methods {
+			if m == method {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	mentioned := false
+	for _, m := range methods {
+		if bytes.Contains(body, []byte(m)) {
+			mentioned = true
+			break
+		}
+	}
+
+	if !mentioned {
+		forward(body)
+		return
+	}
+
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '[' {
+		serveLocalBatch(w, r, next, body, local, answer, logger)
+		return
+	}
+
+	var req rpcRequest
+	if err := json.Unmarshal(body, &req); err != nil || !local(req.Method) {
+		forward(body)
+		return
+	}
+
+	writeRPCResponse(w, answer(&req), logger)
+}
+
+// serveLocalBatch answers the local requests of the batch, handing the
+// others over to the next handler as a batch of their own, and merges the
+// responses back in the order of the requests. The response of the next
+// handler failing the batch as a whole is returned as it is.
+func serveLocalBatch(w http.ResponseWriter, r *http.Request, next http.Handler, body []byte, local func(string) bool, answer func(*rpcRequest) *rpcResponse, logger hclog.Logger) {
+	var batch []json.RawMessage
+	if err := json.Unmarshal(body, &batch); err != nil {
+		next.ServeHTTP(w, setBody(r, body))
+		return
+	}
+
+	var (
+		responses = make([]interface{}, len(batch))
+		rest      []json.RawMessage
+		restAt    []int
+	)
+
+	for i, raw := range batch {
+		var req rpcRequest
+		if err := json.Unmarshal(raw, &req); err != nil || !local(req.Method) {
+			rest, restAt = append(rest, raw), append(restAt, i)
+			continue
+		}
+
+		responses[i] = answer(&req)
+	}
+
+	if len(restAt) == len(batch) {
+		next.ServeHTTP(w, setBody(r, body))
+		return
+	}
+
+	if len(rest) > 0 {
+		restBody, err := json.Marshal(rest)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		buf := newResponseBuffer()
+		next.ServeHTTP(buf, setBody(r, restBody))
+
+		var restResponses []json.RawMessage
+		if err := json.Unmarshal(buf.body.Bytes(), &restResponses); err != nil || len(restResponses) != len(rest) {
+			buf.copyTo(w)
+			return
+		}
+
+		for i, at := range restAt {
+			responses[at] = restResponses[i]
+		}
+	}
+
+	writeRPCResponse(w, responses, logger)
+}
+
+// setBody sets the body of the request to the given one.
+func setBody(r *http.Request, body []byte) *http.Request {
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+	r.Header.Del("Content-Length")
+
+	return r
+}
+
+// writeRPCResponse writes the JSON-RPC response, single or batched.
+func writeRPCResponse(w http.ResponseWriter, v interface{}, logger hclog.Logger) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		logger.Error("failed to encode the JSON-RPC response", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	_, _ = w.Write(body)
+}
+
+// answer returns the response to the fee request, with the errors of its
+// parameters and of the method reported as go-ethereum does.
+func (h *feeHandler) answer(req *rpcRequest) *rpcResponse {
+	resp := &rpcResponse{Version: "2.0", ID: req.ID}
+
+	var params []json.RawMessage
+	if len(bytes.TrimSpace(req.Params)) > 0 {
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			resp.Error = &rpcError{Code: rpcInvalidParamsCode, Message: "non-array args"}
+			return resp
+		}
+	}
+
+	var (
+		result interface{}
+		err    error
+	)
+
+	switch req.Method {
+	case feeHistoryMethod:
+		var (
+			blockCount  rpc.DecimalOrHex
+			newest      rpc.BlockNumber
+			percentiles []float64
+		)
+
+		if perr := parseParams(params, &blockCount, &newest, &percentiles); perr != nil {
+			resp.Error = perr
+			return resp
+		}
+
+		result, err = h.feeHistory(uint64(blockCount), newest, percentiles)
+
+	case maxPriorityFeePerGasMethod:
+		if perr := parseParams(params); perr != nil {
+			resp.Error = perr
+			return resp
+		}
+
+		result, err = h.maxPriorityFeePerGas()
+	}
+
+	if err != nil {
+		resp.Error = &rpcError{Code: rpcServerErrorCode, Message: err.Error()}
+		return resp
+	}
+
+	resp.Result = result
+
+	return resp
+}
+
+// parseParams decodes the parameters of the request into the given
+// arguments, all of them required.
+func parseParams(params []json.RawMessage, args ...interface{}) *rpcError {
+	if len(params) > len(args) {
+		return &rpcError{Code: rpcInvalidParamsCode, Message: fmt.Sprintf("too many arguments, want at most %d", len(args))}
+	}
+
+	for i, arg := range args {
+		if i >= len(params) {
+			return &rpcError{Code: rpcInvalidParamsCode, Message: fmt.Sprintf("missing value for required argument %d", i)}
+		}
+
+		if err := json.Unmarshal(params[i], arg); err != nil {
+			return &rpcError{Code: rpcInvalidParamsCode, Message: fmt.Sprintf("invalid argument %d: %v", i, err)}
+		}
+	}
+
+	return nil
+}
+
+// feeHistory returns the base fees, the gas used ratios and the given
+// percentiles of the tips of up to blockCount blocks up to the newest one, as
+// eth_feeHistory of go-ethereum does. The base fees are zero before the
+// London fork; the rewards of the empty blocks are zero. The range is cut at
+// the genesis, and there's no pending block: the pending range is the one up
+// to the head, one block short.
+func (h *feeHandler) feeHistory(blockCount uint64, newest rpc.BlockNumber, percentiles []float64) (*feeHistory, error) {
+	if blockCount == 0 {
+		return &feeHistory{}, nil
+	}
+
+	if blockCount > maxFeeHistory {
+		blockCount = maxFeeHistory
+	}
+
+	for i, p := range percentiles {
+		if p < 0 || p > 100 {
+			return nil, fmt.Errorf("%w: %f", errInvalidPercentile, p)
+		}
+
+		if i > 0 && p < percentiles[i-1] {
+			return nil, fmt.Errorf("%w: #%d:%f > #%d:%f", errInvalidPercentile, i-1, percentiles[i-1], i, p)
+		}
+	}
+
+	head := h.store.Header()
+
+	last := head.Number
+
+	switch {
+	case newest == rpc.PendingBlockNumber:
+		blockCount--
+	case newest >= 0:
+		if uint64(newest) > head.Number {
+			return nil, fmt.Errorf("%w: requested %d, head %d", errRequestBeyondHead, newest, head.Number)
+		}
+
+		last = uint64(newest)
+	}
+
+	if blockCount == 0 {
+		return &feeHistory{}, nil
+	}
+
+	if blockCount > last+1 {
+		blockCount = last + 1
+	}
+
+	history := &feeHistory{OldestBlock: hexutil.Uint64(last + 1 - blockCount)}
+
+	for n := uint64(history.OldestBlock); n <= last; n++ {
+		hdr, ok := h.store.GetHeaderByNumber(n)
+		if !ok {
+			// The head moved back under the range; it stops here.
+			break
+		}
+
+		if len(history.BaseFee) == 0 {
+			history.BaseFee = append(history.BaseFee, (*hexutil.Big)(new(big.Int).SetUint64(hdr.BaseFee)))
+		}
+
+		history.BaseFee = append(history.BaseFee, (*hexutil.Big)(new(big.Int).SetUint64(h.store.CalculateBaseFee(hdr))))
+
+		ratio := 0.0
+		if hdr.GasLimit > 0 {
+			ratio = float64(hdr.GasUsed) / float64(hdr.GasLimit)
+		}
+
+		history.GasUsedRatio = append(history.GasUsedRatio, ratio)
+
+		if len(percentiles) == 0 {
+			continue
+		}
+
+		reward, err := h.blockReward(hdr, percentiles)
+		if err != nil {
+			return nil, err
+		}
+
+		history.Reward = append(history.Reward, reward)
+	}
+
+	if len(history.GasUsedRatio) == 0 {
+		return &feeHistory{}, nil
+	}
+
+	return history, nil
+}
+
+// blockReward returns the given percentiles of the tips of the block of the
+// header, weighted by the gas used by each transaction.
+func (h *feeHandler) blockReward(hdr *types.Header, percentiles []float64) ([]*hexutil.Big, error) {
+	reward := make([]*hexutil.Big, len(percentiles))
+
+	blk, ok := h.store.GetBlockByHash(hdr.Hash, true)
+	if !ok {
+		return nil, fmt.Errorf("block %s not found", hdr.Hash)
+	}
+
+	if len(blk.Transactions) == 0 {
+		for i := range reward {
+			reward[i] = (*hexutil.Big)(new(big.Int))
+		}
+
+		return reward, nil
+	}
+
+	receipts, err := h.store.GetReceiptsByHash(hdr.Hash)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(receipts) != len(blk.Transactions) {
+		return nil, fmt.Errorf("receipts of block %s not found", hdr.Hash)
+	}
+
+	type txTip struct {
+		gasUsed uint64
+		tip     *big.Int
+	}
+
+	tips := make([]txTip, len(blk.Transactions))
+	for i, tx := range blk.Transactions {
+		tip := block.EffectiveTip(tx, hdr.BaseFee)
+
+		// The legacy transactions priced under the base fee tip zero.
+		if tip.Sign() < 0 {
+			tip.SetUint64(0)
+		}
+
+		tips[i] = txTip{gasUsed: receipts[i].GasUsed, tip: tip}
+	}
+
+	sort.SliceStable(tips, func(i, j int) bool {
+		return tips[i].tip.Cmp(tips[j].tip) < 0
+	})
+
+	var (
+		at      int
+		gasUsed = tips[0].gasUsed
+	)
+
+	for i, p := range percentiles {
+		threshold := uint64(float64(hdr.GasUsed) * p / 100)
+
+		for gasUsed < threshold && at < len(tips)-1 {
+			at++
+			gasUsed += tips[at].gasUsed
+		}
+
+		reward[i] = (*hexutil.Big)(tips[at].tip)
+	}
+
+	return reward, nil
+}
+
+// maxPriorityFeePerGas returns the tip suggested for the next block: the
+// percentile of the lowest tips of the recent blocks, leaving out those of
+// the transactions sent by the miner of each, and at least what it takes for
+// the gas price to reach the price limit.
+func (h *feeHandler) maxPriorityFeePerGas() (*hexutil.Big, error) {
+	head := h.store.Header()
+
+	var tips []*big.Int
+
+	for n, i := head.Number, 0; n > 0 && i < tipBlocks; n, i = n-1, i+1 {
+		hdr, ok := h.store.GetHeaderByNumber(n)
+		if !ok {
+			break
+		}
+
+		blk, ok := h.store.GetBlockByHash(hdr.Hash, true)
+		if !ok {
+			return nil, fmt.Errorf("block %s not found", hdr.Hash)
+		}
+
+		tips = append(tips, lowestTips(blk, tipSamples)...)
+	}
+
+	tip := new(big.Int)
+
+	if len(tips) > 0 {
+		sort.Slice(tips, func(i, j int) bool {
+			return tips[i].Cmp(tips[j]) < 0
+		})
+
+		tip = tips[(len(tips)-1)*tipPercentile/100]
+	}
+
+	if limit := new(big.Int).SetUint64(maxTip); tip.Cmp(limit) > 0 {
+		tip = limit
+	}
+
+	if baseFee := h.store.CalculateBaseFee(head); h.priceLimit > baseFee {
+		if floor := new(big.Int).SetUint64(h.priceLimit - baseFee); tip.Cmp(floor) < 0 {
+			tip = floor
+		}
+	}
+
+	return (*hexutil.Big)(tip), nil
+}
+
+// lowestTips returns up to limit of the lowest tips of the transactions of
+// the block, but for those under ignoreTip and those sent by its miner.
+func lowestTips(blk *types.Block, limit int) []*big.Int {
+	miner := types.BytesToAddress(blk.Header.Miner)
+
+	tips := make([]*big.Int, 0, len(blk.Transactions))
+
+	for _, tx := range blk.Transactions {
+		if tx.From == miner {
+			continue
+		}
+
+		if tip := block.EffectiveTip(tx, blk.Header.BaseFee); tip.Cmp(big.NewInt(ignoreTip)) >= 0 {
+			tips = append(tips, tip)
+		}
+	}
+
+	sort.Slice(tips, func(i, j int) bool {
+		return tips[i].Cmp(tips[j]) < 0
+	})
+
+	if len(tips) > limit {
+		tips = tips[:limit]
+	}
+
+	return tips
+}
+
+// responseBuffer is the http.ResponseWriter holding the response of the next
+// handler to the requests of a batch not answered locally.
+type responseBuffer struct {
+	header http.Header
+	code   int
+	body   bytes.Buffer
+}
+
+func newResponseBuffer() *responseBuffer {
+	return &responseBuffer{header: make(http.Header), code: http.StatusOK}
+}
+
+func (b *responseBuffer) Header() http.Header { return b.header }
+
+func (b *responseBuffer) Write(p []byte) (int, error) { return b.body.Write(p) }
+
+func (b *responseBuffer) WriteHeader(code int) { b.code = code }
+
+// copyTo writes the buffered response to w, as it is.
+func (b *responseBuffer) copyTo(w http.ResponseWriter) {
+	for key, values := range b.header {
+		for _, v := range values {
+			w.Header().Add(key, v)
+		}
+	}
+
+	w.WriteHeader(b.code)
+
+	_, _ = w.Write(b.body.Bytes())
+}
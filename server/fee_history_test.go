@@ -0,0 +1,256 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+// feeFixture is the synthetic chain of testdata/fee_history.json along with
+// the eth_feeHistory requests on it and the responses of go-ethereum
+// v1.10.26, the London fork at block 2.
+type feeFixture struct {
+	Chain []struct {
+		Number       uint64 `json:"number"`
+		GasLimit     uint64 `json:"gasLimit"`
+		GasUsed      uint64 `json:"gasUsed"`
+		BaseFee      uint64 `json:"baseFee"`
+		NextBaseFee  uint64 `json:"nextBaseFee"`
+		Transactions []struct {
+			Type      string `json:"type"`
+			GasPrice  uint64 `json:"gasPrice"`
+			GasTipCap uint64 `json:"gasTipCap"`
+			GasFeeCap uint64 `json:"gasFeeCap"`
+			GasUsed   uint64 `json:"gasUsed"`
+		} `json:"transactions"`
+	} `json:"chain"`
+	Cases []struct {
+		Name     string          `json:"name"`
+		Request  json.RawMessage `json:"request"`
+		Response json.RawMessage `json:"response"`
+	} `json:"cases"`
+}
+
+// testFeeStore is the chain of the fee fixture.
+type testFeeStore struct {
+	blocks      []*types.Block
+	receipts    map[types.Hash][]*types.Receipt
+	nextBaseFee map[uint64]uint64
+}
+
+var testFeeSender = types.StringToAddress("0x1")
+
+func newTestFeeStore(t *testing.T) (*testFeeStore, *feeFixture) {
+	t.Helper()
+
+	raw, err := os.ReadFile("testdata/fee_history.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fixture feeFixture
+	if err := json.Unmarshal(raw, &fixture); err != nil {
+		t.Fatal(err)
+	}
+
+	store := &testFeeStore{receipts: map[types.Hash][]*types.Receipt{}, nextBaseFee: map[uint64]uint64{}}
+
+	for _, b := range fixture.Chain {
+		blk := &types.Block{Header: &types.Header{
+			Number:   b.Number,
+			GasLimit: b.GasLimit,
+			GasUsed:  b.GasUsed,
+			BaseFee:  b.BaseFee,
+		}}
+		blk.Header.ComputeHash()
+
+		var receipts []*types.Receipt
+
+		for i, tx := range b.Transactions {
+			etx := &types.Transaction{Nonce: uint64(i), From: testFeeSender, Gas: tx.GasUsed}
+
+			if tx.Type == "dynamic" {
+				etx.Type = types.DynamicFeeTx
+				etx.GasTipCap = new(big.Int).SetUint64(tx.GasTipCap)
+				etx.GasFeeCap = new(big.Int).SetUint64(tx.GasFeeCap)
+			} else {
+				etx.GasPrice = new(big.Int).SetUint64(tx.GasPrice)
+			}
+
+			blk.Transactions = append(blk.Transactions, etx)
+			receipts = append(receipts, &types.Receipt{GasUsed: tx.GasUsed})
+		}
+
+		store.blocks = append(store.blocks, blk)
+		store.receipts[blk.Hash()] = receipts
+		store.nextBaseFee[b.Number] = b.NextBaseFee
+	}
+
+	return store, &fixture
+}
+
+func (s *testFeeStore) Header() *types.Header {
+	return s.blocks[len(s.blocks)-1].Header
+}
+
+func (s *testFeeStore) GetHeaderByNumber(n uint64) (*types.Header, bool) {
+	if n >= uint64(len(s.blocks)) {
+		return nil, false
+	}
+
+	return s.blocks[n].Header, true
+}
+
+func (s *testFeeStore) GetBlockByHash(hash types.Hash, _ bool) (*types.Block, bool) {
+	for _, blk := range s.blocks {
+		if blk.Hash() == hash {
+			return blk, true
+		}
+	}
+
+	return nil, false
+}
+
+func (s *testFeeStore) GetReceiptsByHash(hash types.Hash) ([]*types.Receipt, error) {
+	return s.receipts[hash], nil
+}
+
+func (s *testFeeStore) CalculateBaseFee(parent *types.Header) uint64 {
+	return s.nextBaseFee[parent.Number]
+}
+
+// postFees posts the body to the handler, returning the body of its response.
+func postFees(t *testing.T, h http.Handler, body string) string {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	return rec.Body.String()
+}
+
+func TestFeeHistoryMatchesGoEthereum(t *testing.T) {
+	store, fixture := newTestFeeStore(t)
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("fee request handed over")
+	})
+
+	h := newFeeHandler(next, store, 0, hclog.NewNullLogger())
+
+	for _, c := range fixture.Cases {
+		t.Run(c.Name, func(t *testing.T) {
+			assert.JSONEq(t, string(c.Response), postFees(t, h, string(c.Request)))
+		})
+	}
+}
+
+func TestFeeHandlerBatch(t *testing.T) {
+	store, _ := newTestFeeStore(t)
+
+	// The next handler answers every request with its method.
+	var forwarded []string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var batch []rpcRequest
+		if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+			t.Fatal(err)
+		}
+
+		responses := make([]string, 0, len(batch))
+		for _, req := range batch {
+			forwarded = append(forwarded, req.Method)
+			responses = append(responses, fmt.Sprintf(`{"jsonrpc":"2.0","id":%s,"result":%q}`, req.ID, req.Method))
+		}
+
+		_, _ = w.Write([]byte("[" + strings.Join(responses, ",") + "]"))
+	})
+
+	h := newFeeHandler(next, store, 0, hclog.NewNullLogger())
+
+	// The fee requests are answered in place; the others are handed over as
+	// a batch of their own.
+	assert.JSONEq(t,
+		`[{"jsonrpc":"2.0","id":1,"result":"eth_chainId"},{"jsonrpc":"2.0","id":2,"result":{"oldestBlock":"0x4","baseFeePerGas":["0x3365d834","0x2f285287"],"gasUsedRatio":[0.17]}},{"jsonrpc":"2.0","id":3,"result":"eth_blockNumber"},{"jsonrpc":"2.0","id":4,"result":"0x77359400"}]`,
+		postFees(t, h, `[{"jsonrpc":"2.0","id":1,"method":"eth_chainId","params":[]},{"jsonrpc":"2.0","id":2,"method":"eth_feeHistory","params":["0x1","latest",[]]},{"jsonrpc":"2.0","id":3,"method":"eth_blockNumber","params":[]},{"jsonrpc":"2.0","id":4,"method":"eth_maxPriorityFeePerGas","params":[]}]`),
+	)
+	assert.Equal(t, []string{"eth_chainId", "eth_blockNumber"}, forwarded)
+
+	// The batch of fee requests alone isn't handed over.
+	forwarded = nil
+
+	assert.JSONEq(t,
+		`[{"jsonrpc":"2.0","id":1,"result":"0x77359400"}]`,
+		postFees(t, h, `[{"jsonrpc":"2.0","id":1,"method":"eth_maxPriorityFeePerGas"}]`),
+	)
+	assert.Empty(t, forwarded)
+
+	// The batch mentioning the methods, without calling them, goes through
+	// untouched.
+	assert.JSONEq(t,
+		`[{"jsonrpc":"2.0","id":1,"result":"eth_getCode"}]`,
+		postFees(t, h, `[{"jsonrpc":"2.0","id":1,"method":"eth_getCode","params":["eth_feeHistory","latest"]}]`),
+	)
+}
+
+func TestFeeHandlerWebsocket(t *testing.T) {
+	store, _ := newTestFeeStore(t)
+
+	c := newTestWSBridge(t, newFeeHandler(newUpstreamHandler(testRPCUpstream(nil, nil)), store, 0, hclog.NewNullLogger()))
+
+	// The fee requests are answered over the websocket too, the others
+	// handed over.
+	var tip hexutil.Big
+	assert.NoError(t, c.Call(&tip, "eth_maxPriorityFeePerGas"))
+	assert.Equal(t, big.NewInt(2_000_000_000), tip.ToInt())
+
+	var history feeHistory
+	assert.NoError(t, c.Call(&history, "eth_feeHistory", "0x1", "latest", []float64{}))
+	assert.Equal(t, hexutil.Uint64(4), history.OldestBlock)
+
+	var result string
+	assert.NoError(t, c.Call(&result, "eth_blockNumber"))
+	assert.Equal(t, "0x1", result)
+}
+
+func TestMaxPriorityFeePerGas(t *testing.T) {
+	store, _ := newTestFeeStore(t)
+
+	tip := func(priceLimit uint64) *big.Int {
+		t.Helper()
+
+		tip, err := newFeeHandler(nil, store, priceLimit, hclog.NewNullLogger()).maxPriorityFeePerGas()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return tip.ToInt()
+	}
+
+	// The tips sampled are 1 and 3.14 gwei of block 4, 0.1, 0.5 and 2 gwei
+	// of block 2, and 1, 2 and 3 gwei of block 1; the 60th percentile is 2
+	// gwei.
+	assert.Equal(t, big.NewInt(2_000_000_000), tip(0))
+
+	// The tip lifts the gas price of the next block, its base fee at
+	// 0.79 gwei, up to the price limit.
+	assert.Equal(t, big.NewInt(5_000_000_000-791_171_719), tip(5_000_000_000))
+
+	// The transactions sent by the miners aren't sampled.
+	for _, blk := range store.blocks {
+		blk.Header.Miner = testFeeSender.Bytes()
+	}
+
+	assert.Equal(t, big.NewInt(0), tip(0))
+}
@@ -0,0 +1,446 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/armon/go-metrics"
+	"github.com/hashicorp/go-hclog"
+	"golang.org/x/sync/semaphore"
+	"golang.org/x/time/rate"
+)
+
+// The method groups the JSON-RPC requests are rate limited by: the calls,
+// the traces, the log queries and the other, cheap, methods. The first three
+// are the expensive ones, capped in concurrency as well.
+const (
+	RPCGroupCall  = "call"
+	RPCGroupTrace = "trace"
+	RPCGroupLogs  = "logs"
+	RPCGroupOther = "other"
+)
+
+// rpcGroups are the method groups of the rate limits.
+var rpcGroups = []string{RPCGroupCall, RPCGroupTrace, RPCGroupLogs, RPCGroupOther}
+
+const (
+	// rpcLimitExceededCode is the JSON-RPC error code of the requests over
+	// the limits, as by EIP-1474.
+	rpcLimitExceededCode = -32005
+
+	// rpcParseErrorCode and rpcInvalidRequestCode are the JSON-RPC error
+	// codes of the bodies that don't parse and of the empty batches.
+	rpcParseErrorCode     = -32700
+	rpcInvalidRequestCode = -32600
+
+	// rpcBucketIdle is the time the token bucket of a client and method
+	// group is kept for unused.
+	rpcBucketIdle = 5 * time.Minute
+
+	// rpcConcurrencyRetryAfter is the time the requests over the
+	// concurrency cap are asked to retry after.
+	rpcConcurrencyRetryAfter = time.Second
+
+	// rpcMaxRequestSize is the largest JSON-RPC request, or websocket
+	// message, the proxy reads.
+	rpcMaxRequestSize = 5 * 1024 * 1024
+)
+
+// RPCLimitConfig is the rate limiting and the concurrency cap of the
+// JSON-RPC requests, served over HTTP and websocket alike; a batch counts
+// as the requests in it either way.
+type RPCLimitConfig struct {
+	// Rates is the number of requests per second of each method group a
+	// client IP may send, in bursts of up to a second's worth; the groups
+	// missing, or at 0, aren't limited.
+	Rates map[string]int
+
+	// MaxConcurrentExpensive is the most calls, traces and log queries
+	// served at once, to all the clients; 0 doesn't cap them.
+	MaxConcurrentExpensive int
+
+	// Exempt are the networks of the clients exempt from the limits, such
+	// as the loopback ones the components of the node call from.
+	Exempt []string
+}
+
+// DefaultRPCLimitConfig returns the default JSON-RPC limits, leaving the
+// cheap methods and the loopback clients unlimited.
+func DefaultRPCLimitConfig() RPCLimitConfig {
+	return RPCLimitConfig{
+		Rates: map[string]int{
+			RPCGroupCall:  50,
+			RPCGroupTrace: 5,
+			RPCGroupLogs:  10,
+		},
+		MaxConcurrentExpensive: 16,
+		Exempt:                 []string{"127.0.0.0/8", "::1/128"},
+	}
+}
+
+// rpcMethodGroup returns the method group of the JSON-RPC method.
+func rpcMethodGroup(method string) string {
+	switch {
+	case method == "eth_call" || method == "eth_estimateGas":
+		return RPCGroupCall
+	case strings.HasPrefix(method, "debug_"):
+		return RPCGroupTrace
+	case method == "eth_getLogs" || method == "eth_getFilterLogs":
+		return RPCGroupLogs
+	default:
+		return RPCGroupOther
+	}
+}
+
+// rpcBucketKey is the client IP and the method group of a token bucket.
+type rpcBucketKey struct {
+	ip    string
+	group string
+}
+
+type rpcBucket struct {
+	limiter *rate.Limiter
+	used    time.Time
+}
+
+// rpcLimitHandler rate limits the JSON-RPC requests, single or batched, per
+// client IP and method group, and caps the expensive ones served at once,
+// answering the requests over the limits with the "limit exceeded" error
+// and the time to retry after; the requests within them are handed over to
+// the next handler. A batch is limited as a whole, every request of it
+// counted. The messages of the websocket clients come in as the requests of
+// the wsBridge, from the same client IPs.
+type rpcLimitHandler struct {
+	next      http.Handler
+	rates     map[string]int
+	exempt    []*net.IPNet
+	expensive *semaphore.Weighted
+	maxWeight int64
+	now       func() time.Time
+	logger    hclog.Logger
+
+	lock    sync.Mutex
+	buckets map[rpcBucketKey]*rpcBucket
+	swept   time.Time
+}
+
+// newRPCLimitHandler returns the rpcLimitHandler of the limits of the given
+// configuration.
+func newRPCLimitHandler(next http.Handler, config RPCLimitConfig, logger hclog.Logger) (*rpcLimitHandler, error) {
+	h := &rpcLimitHandler{
+		next:    next,
+		rates:   make(map[string]int),
+		now:     time.Now,
+		logger:  logger,
+		buckets: make(map[rpcBucketKey]*rpcBucket),
+	}
+
+	for group, r := range config.Rates {
+		if rpcMethodGroupIndex(group) < 0 {
+			return nil, fmt.Errorf("unknown JSON-RPC method group %q, want one of %s", group, strings.Join(rpcGroups, ", "))
+		}
+
+		if r < 0 {
+			return nil, fmt.Errorf("negative JSON-RPC rate limit of the %q methods", group)
+		}
+
+		if r > 0 {
+			h.rates[group] = r
+		}
+	}
+
+	for _, cidr := range config.Exempt {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid JSON-RPC rate limit exemption %q: %w", cidr, err)
+		}
+
+		h.exempt = append(h.exempt, network)
+	}
+
+	if config.MaxConcurrentExpensive > 0 {
+		h.maxWeight = int64(config.MaxConcurrentExpensive)
+		h.expensive = semaphore.NewWeighted(h.maxWeight)
+	}
+
+	return h, nil
+}
+
+func rpcMethodGroupIndex(group string) int {
+	for i, g := range rpcGroups {
+		if g == group {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func (h *rpcLimitHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.Body == nil {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	// The exempt clients' requests are bounded all the same, for the
+	// handlers reading them next.
+	r.Body = http.MaxBytesReader(w, r.Body, rpcMaxRequestSize)
+
+	ip := clientIP(r)
+	if h.isExempt(ip) {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	setBody(r, body)
+
+	// The bodies that don't parse are rejected here, none of them going on
+	// past the limits uncounted.
+	reqs, batch, err := parseRPCRequests(body)
+	if err != nil {
+		code := rpcParseErrorCode
+		if errors.Is(err, errEmptyBatch) {
+			code = rpcInvalidRequestCode
+		}
+
+		writeRPCResponse(w, &rpcResponse{Version: "2.0", ID: json.RawMessage("null"), Error: &rpcError{Code: code, Message: err.Error()}}, h.logger)
+
+		return
+	}
+
+	// The malformed requests of a batch, of no method, count as the other
+	// ones.
+	counts := make(map[string]int)
+	for _, req := range reqs {
+		counts[rpcMethodGroup(req.Method)]++
+	}
+
+	if retryAfter, group, ok := h.allow(ip.String(), counts); !ok {
+		observeRPCLimited(group, "rate")
+		h.reject(w, reqs, batch, retryAfter)
+
+		return
+	}
+
+	if weight := int64(len(reqs) - counts[RPCGroupOther]); weight > 0 && h.expensive != nil {
+		// A batch heavier than the cap takes it all.
+		if weight > h.maxWeight {
+			weight = h.maxWeight
+		}
+
+		if !h.expensive.TryAcquire(weight) {
+			observeRPCLimited("expensive", "concurrency")
+			h.reject(w, reqs, batch, rpcConcurrencyRetryAfter)
+
+			return
+		}
+
+		defer h.expensive.Release(weight)
+	}
+
+	h.next.ServeHTTP(w, r)
+}
+
+// allow takes the tokens of the requests of every method group from the
+// buckets of the client, all or none; short of them, it returns the time to
+// retry after and the method group limited.
+func (h *rpcLimitHandler) allow(ip string, counts map[string]int) (time.Duration, string, bool) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	now := h.now()
+	h.sweep(now)
+
+	reservations := make([]*rate.Reservation, 0, len(counts))
+
+	cancel := func() {
+		for _, res := range reservations {
+			res.CancelAt(now)
+		}
+	}
+
+	for _, group := range rpcGroups {
+		n, limit := counts[group], h.rates[group]
+		if n == 0 || limit == 0 {
+			continue
+		}
+
+		bucket := h.bucket(rpcBucketKey{ip: ip, group: group}, limit, now)
+
+		res := bucket.limiter.ReserveN(now, n)
+		if !res.OK() {
+			// More requests at once than the burst ever allows.
+			cancel()
+			return time.Duration(n) * time.Second / time.Duration(limit), group, false
+		}
+
+		if delay := res.DelayFrom(now); delay > 0 {
+			res.CancelAt(now)
+			cancel()
+
+			return delay, group, false
+		}
+
+		reservations = append(reservations, res)
+	}
+
+	return 0, "", true
+}
+
+func (h *rpcLimitHandler) bucket(key rpcBucketKey, limit int, now time.Time) *rpcBucket {
+	bucket, ok := h.buckets[key]
+	if !ok {
+		bucket = &rpcBucket{limiter: rate.NewLimiter(rate.Limit(limit), limit)}
+		h.buckets[key] = bucket
+	}
+
+	bucket.used = now
+
+	return bucket
+}
+
+// sweep drops the buckets unused for rpcBucketIdle, once in a while.
+func (h *rpcLimitHandler) sweep(now time.Time) {
+	if now.Sub(h.swept) < rpcBucketIdle {
+		return
+	}
+
+	h.swept = now
+
+	for key, bucket := range h.buckets {
+		if now.Sub(bucket.used) >= rpcBucketIdle {
+			delete(h.buckets, key)
+		}
+	}
+}
+
+func (h *rpcLimitHandler) isExempt(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+
+	for _, network := range h.exempt {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// reject answers every request with the "limit exceeded" error, the time to
+// retry after in whole seconds in its data and in the Retry-After header.
+func (h *rpcLimitHandler) reject(w http.ResponseWriter, reqs []rpcRequest, batch bool, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+
+	responses := make([]*rpcResponse, 0, len(reqs))
+
+	for _, req := range reqs {
+		id := req.ID
+		if len(id) == 0 {
+			id = json.RawMessage("null")
+		}
+
+		responses = append(responses, &rpcResponse{
+			Version: "2.0",
+			ID:      id,
+			Error: &rpcError{
+				Code:    rpcLimitExceededCode,
+				Message: "limit exceeded",
+				Data:    map[string]int{"retryAfter": seconds},
+			},
+		})
+	}
+
+	var v interface{} = responses
+	if !batch {
+		v = responses[0]
+	}
+
+	body, err := json.Marshal(v)
+	if err != nil {
+		h.logger.Error("failed to encode the limit exceeded response", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	w.WriteHeader(http.StatusOK)
+
+	_, _ = w.Write(body)
+}
+
+// errEmptyBatch is the error of the batches of no requests.
+var errEmptyBatch = errors.New("empty batch")
+
+// parseRPCRequests returns the requests of the body, single or batched, and
+// whether it's a batch. The requests of a batch are parsed one by one, as by
+// the JSON-RPC server, the malformed ones returned without a method.
+func parseRPCRequests(body []byte) ([]rpcRequest, bool, error) {
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '[' {
+		var elems []json.RawMessage
+		if err := json.Unmarshal(body, &elems); err != nil {
+			return nil, true, err
+		}
+
+		if len(elems) == 0 {
+			return nil, true, errEmptyBatch
+		}
+
+		reqs := make([]rpcRequest, len(elems))
+
+		for i, elem := range elems {
+			if err := json.Unmarshal(elem, &reqs[i]); err != nil {
+				reqs[i] = rpcRequest{}
+			}
+		}
+
+		return reqs, true, nil
+	}
+
+	var req rpcRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, false, err
+	}
+
+	return []rpcRequest{req}, false, nil
+}
+
+// clientIP returns the IP the request comes from. The forwarding headers
+// are ignored, the clients being able to set them at will.
+func clientIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	return net.ParseIP(host)
+}
+
+// observeRPCLimited records a JSON-RPC request refused for going over the
+// rate limit of its method group, or the concurrency cap of the expensive
+// ones.
+func observeRPCLimited(group, limit string) {
+	metrics.IncrCounterWithLabels([]string{"jsonrpc", "limited_requests"}, 1, []metrics.Label{{Name: "group", Value: group}, {Name: "limit", Value: limit}})
+}
@@ -0,0 +1,323 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+// testRPCUpstream answers every JSON-RPC request, single or batched, with
+// the result "0x1"; with hold set, the calls to eth_call are signalled on
+// inFlight and held until it's closed.
+func testRPCUpstream(hold chan struct{}, inFlight chan struct{}) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		reqs, batch, _ := parseRPCRequests(body)
+
+		responses := make([]*rpcResponse, 0, len(reqs))
+		for _, req := range reqs {
+			if req.Method == "eth_call" && hold != nil {
+				inFlight <- struct{}{}
+				<-hold
+			}
+
+			responses = append(responses, &rpcResponse{Version: "2.0", ID: req.ID, Result: "0x1"})
+		}
+
+		var v interface{} = responses
+		if !batch {
+			v = responses[0]
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(v)
+	})
+}
+
+// newTestRPCLimitHandler limits the upstream on a clock standing still, the
+// buckets never refilling.
+func newTestRPCLimitHandler(t *testing.T, upstream http.Handler, config RPCLimitConfig) *rpcLimitHandler {
+	t.Helper()
+
+	h, err := newRPCLimitHandler(upstream, config, hclog.NewNullLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	h.now = func() time.Time { return now }
+
+	return h
+}
+
+// newTestRPCLimits serves the upstream within the limits, on a clock
+// standing still.
+func newTestRPCLimits(t *testing.T, upstream http.Handler, config RPCLimitConfig) *rpc.Client {
+	t.Helper()
+
+	srv := httptest.NewServer(newTestRPCLimitHandler(t, upstream, config))
+	t.Cleanup(srv.Close)
+
+	c, err := rpc.Dial(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(c.Close)
+
+	return c
+}
+
+// assertLimitExceeded asserts the call failed with the "limit exceeded"
+// error, and returns the time to retry after it hints.
+func assertLimitExceeded(t *testing.T, err error) int {
+	t.Helper()
+
+	var rpcErr rpc.Error
+	if !assert.True(t, errors.As(err, &rpcErr), "not a JSON-RPC error: %v", err) {
+		return 0
+	}
+
+	assert.Equal(t, rpcLimitExceededCode, rpcErr.ErrorCode())
+
+	var dataErr rpc.DataError
+	if !assert.True(t, errors.As(err, &dataErr)) {
+		return 0
+	}
+
+	data, ok := dataErr.ErrorData().(map[string]interface{})
+	if !assert.True(t, ok, "unexpected error data %v", dataErr.ErrorData()) {
+		return 0
+	}
+
+	retryAfter, _ := data["retryAfter"].(float64)
+
+	return int(retryAfter)
+}
+
+func TestRPCRateLimit(t *testing.T) {
+	c := newTestRPCLimits(t, testRPCUpstream(nil, nil), RPCLimitConfig{Rates: map[string]int{RPCGroupCall: 5, RPCGroupTrace: 1}})
+
+	var result string
+
+	// The calls go through up to the burst, the rest are throttled.
+	served, throttled := 0, 0
+
+	for i := 0; i < 50; i++ {
+		if err := c.Call(&result, "eth_call", map[string]interface{}{}, "latest"); err != nil {
+			assert.Equal(t, 1, assertLimitExceeded(t, err))
+			throttled++
+
+			continue
+		}
+
+		served++
+	}
+
+	assert.Equal(t, 5, served)
+	assert.Equal(t, 45, throttled)
+
+	// The other groups have buckets of their own; the cheap methods aren't
+	// limited at all.
+	assert.NoError(t, c.Call(&result, "debug_traceTransaction", "0x1"))
+	assertLimitExceeded(t, c.Call(&result, "debug_traceTransaction", "0x1"))
+
+	for i := 0; i < 200; i++ {
+		if !assert.NoError(t, c.Call(&result, "eth_blockNumber")) {
+			break
+		}
+	}
+
+	// A batch is limited as a whole: the cheap requests with the throttled
+	// call are refused too.
+	batch := []rpc.BatchElem{
+		{Method: "eth_blockNumber", Result: &result},
+		{Method: "eth_call", Args: []interface{}{map[string]interface{}{}, "latest"}, Result: &result},
+	}
+	assert.NoError(t, c.BatchCall(batch))
+
+	for _, elem := range batch {
+		assertLimitExceeded(t, elem.Error)
+	}
+}
+
+func TestRPCRateLimitWebsocket(t *testing.T) {
+	hold, inFlight := make(chan struct{}), make(chan struct{}, 1)
+	limits := newTestRPCLimitHandler(t, newUpstreamHandler(testRPCUpstream(hold, inFlight)), RPCLimitConfig{Rates: map[string]int{RPCGroupCall: 3}, MaxConcurrentExpensive: 1})
+
+	// The websocket clients share the buckets of their IPs with the HTTP
+	// ones.
+	srv := httptest.NewServer(newWSBridge(limits, testWSUpstream(t), hclog.NewNullLogger()))
+	t.Cleanup(srv.Close)
+
+	ws, err := rpc.Dial("ws" + strings.TrimPrefix(srv.URL, "http") + wsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(ws.Close)
+
+	done := make(chan error)
+
+	go func() {
+		var result string
+		done <- ws.Call(&result, "eth_call", map[string]interface{}{}, "latest")
+	}()
+
+	<-inFlight
+
+	// With a call in flight over the websocket, the next one over HTTP is
+	// refused by the concurrency cap.
+	c, err := rpc.Dial(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(c.Close)
+
+	var result string
+	assertLimitExceeded(t, c.Call(&result, "eth_call", map[string]interface{}{}, "latest"))
+
+	close(hold)
+	assert.NoError(t, <-done)
+
+	// The refused call took a token all the same; the next one takes the
+	// last, and the websocket is throttled past it.
+	assert.NoError(t, ws.Call(&result, "eth_call", map[string]interface{}{}, "latest"))
+	assert.Equal(t, 1, assertLimitExceeded(t, ws.Call(&result, "eth_call", map[string]interface{}{}, "latest")))
+}
+
+func TestRPCLimitRequestSize(t *testing.T) {
+	srv := httptest.NewServer(newTestRPCLimitHandler(t, testRPCUpstream(nil, nil), RPCLimitConfig{}))
+	defer srv.Close()
+
+	body := `{"jsonrpc":"2.0","id":1,"method":"eth_call","params":["` + strings.Repeat("0", rpcMaxRequestSize) + `"]}`
+
+	resp, err := http.Post(srv.URL, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp.Body.Close()
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+}
+
+func TestRPCRateLimitMalformedBatch(t *testing.T) {
+	upstreamCalls := 0
+	upstream := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamCalls++
+		testRPCUpstream(nil, nil).ServeHTTP(w, r)
+	})
+
+	srv := httptest.NewServer(newTestRPCLimitHandler(t, upstream, RPCLimitConfig{Rates: map[string]int{RPCGroupTrace: 1}}))
+	defer srv.Close()
+
+	post := func(body string) []rpcResponse {
+		t.Helper()
+
+		resp, err := http.Post(srv.URL, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer resp.Body.Close()
+
+		var responses []rpcResponse
+
+		raw, _ := io.ReadAll(resp.Body)
+		if err := json.Unmarshal(raw, &responses); err != nil {
+			var single rpcResponse
+			if err := json.Unmarshal(raw, &single); err != nil {
+				t.Fatal(err)
+			}
+
+			responses = []rpcResponse{single}
+		}
+
+		return responses
+	}
+
+	// A malformed request doesn't let the traces of its batch through
+	// uncounted.
+	trace := `{"jsonrpc":"2.0","id":1,"method":"debug_traceTransaction","params":["0x1"]}`
+	responses := post("[0," + strings.Repeat(trace+",", 49) + trace + "]")
+
+	if assert.Len(t, responses, 51) {
+		for _, resp := range responses {
+			if assert.NotNil(t, resp.Error) {
+				assert.Equal(t, rpcLimitExceededCode, resp.Error.Code)
+			}
+		}
+	}
+
+	// The bodies that don't parse are refused, none going on.
+	for body, code := range map[string]int{"[0,": rpcParseErrorCode, "[]": rpcInvalidRequestCode, "0": rpcParseErrorCode} {
+		if responses := post(body); assert.Len(t, responses, 1) && assert.NotNil(t, responses[0].Error) {
+			assert.Equal(t, code, responses[0].Error.Code)
+		}
+	}
+
+	assert.Equal(t, 0, upstreamCalls)
+}
+
+func TestRPCRateLimitExempt(t *testing.T) {
+	// The test clients call over the loopback interface, as the components
+	// of the node.
+	config := RPCLimitConfig{Rates: map[string]int{RPCGroupCall: 1}, Exempt: DefaultRPCLimitConfig().Exempt}
+	c := newTestRPCLimits(t, testRPCUpstream(nil, nil), config)
+
+	var result string
+	for i := 0; i < 20; i++ {
+		if !assert.NoError(t, c.Call(&result, "eth_call", map[string]interface{}{}, "latest")) {
+			break
+		}
+	}
+}
+
+func TestRPCConcurrencyCap(t *testing.T) {
+	hold, inFlight := make(chan struct{}), make(chan struct{}, 2)
+	c := newTestRPCLimits(t, testRPCUpstream(hold, inFlight), RPCLimitConfig{MaxConcurrentExpensive: 1})
+
+	done := make(chan error)
+
+	go func() {
+		var result string
+		done <- c.Call(&result, "eth_call", map[string]interface{}{}, "latest")
+	}()
+
+	<-inFlight
+
+	// With a call in flight, the next one is refused, the cheap methods
+	// still served.
+	var result string
+	assertLimitExceeded(t, c.Call(&result, "eth_call", map[string]interface{}{}, "latest"))
+	assert.NoError(t, c.Call(&result, "eth_blockNumber"))
+
+	close(hold)
+	assert.NoError(t, <-done)
+
+	// The slot freed, the calls go through again.
+	assert.NoError(t, c.Call(&result, "eth_call", map[string]interface{}{}, "latest"))
+}
+
+func TestRPCLimitConfigValidation(t *testing.T) {
+	_, err := newRPCLimitHandler(nil, RPCLimitConfig{Rates: map[string]int{"calls": 1}}, hclog.NewNullLogger())
+	assert.ErrorContains(t, err, "unknown JSON-RPC method group")
+
+	_, err = newRPCLimitHandler(nil, RPCLimitConfig{Exempt: []string{"127.0.0.1"}}, hclog.NewNullLogger())
+	assert.ErrorContains(t, err, "invalid JSON-RPC rate limit exemption")
+
+	_, err = newRPCLimitHandler(nil, DefaultRPCLimitConfig(), hclog.NewNullLogger())
+	assert.NoError(t, err)
+}
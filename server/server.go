@@ -16,6 +16,7 @@ import (
 	consensusPolyBFT "github.com/0xPolygon/polygon-edge/consensus/polybft"
 	"github.com/0xPolygon/polygon-edge/server"
 	avail_consensus "github.com/availproject/op-evm/consensus/avail"
+	"github.com/availproject/op-evm/pkg/pruning"
 	"github.com/availproject/op-evm/pkg/snapshot"
 
 	"github.com/0xPolygon/polygon-edge/archive"
@@ -59,6 +60,10 @@ type Server struct {
 	state        state.State
 	stateStorage itrie.Storage
 
+	// prunableStateStorage is the state storage underlying stateStorage,
+	// pruned by the consensus of the state past the retention
+	prunableStateStorage *pruning.Storage
+
 	consensus consensus.Consensus
 
 	// blockchain stack
@@ -74,6 +79,15 @@ type Server struct {
 	// jsonrpc stack
 	jsonrpcServer *jsonrpc.JSONRPC
 
+	// blockTagServer serves the jsonrpc server resolving the block tags
+	// edge doesn't know to the settled head of the Avail consensus, and
+	// answering the fee methods it lacks
+	blockTagServer *http.Server
+
+	// rpcLimits are the rate limits and the concurrency cap of the jsonrpc
+	// requests served by the blockTagServer
+	rpcLimits RPCLimitConfig
+
 	// system grpc server
 	grpcServer *grpc.Server
 
@@ -83,6 +97,9 @@ type Server struct {
 	// transaction pool
 	txpool *txpool.TxPool
 
+	// txpoolJournal persists the contents of the txpool across restarts
+	txpoolJournal *txPoolJournal
+
 	prometheusServer *http.Server
 
 	// secrets manager
@@ -137,7 +154,7 @@ func newLoggerFromConfig(config *server.Config) (hclog.Logger, error) {
 }
 
 // NewServer creates a new minimal server, using the passed in configuration.
-func NewServer(config *server.Config, consensusCfg avail_consensus.Config) (*Server, error) {
+func NewServer(config *server.Config, rpcLimits RPCLimitConfig, consensusCfg avail_consensus.Config) (*Server, error) {
 	logger, err := newLoggerFromConfig(config)
 	if err != nil {
 		return nil, fmt.Errorf("could not setup new logger instance, %w", err)
@@ -146,6 +163,7 @@ func NewServer(config *server.Config, consensusCfg avail_consensus.Config) (*Ser
 	m := &Server{
 		logger:             logger.Named("server"),
 		config:             config,
+		rpcLimits:          rpcLimits,
 		chain:              config.Chain,
 		grpcServer:         grpc.NewServer(grpc.UnaryInterceptor(unaryInterceptor)),
 		restoreProgression: progress.NewProgressionWrapper(progress.ChainSyncRestore),
@@ -197,11 +215,24 @@ func NewServer(config *server.Config, consensusCfg avail_consensus.Config) (*Ser
 	}
 
 	// start blockchain object
-	stateStorage, err := itrie.NewLevelDBStorage(filepath.Join(m.config.DataDir, "trie"), logger)
+	prunableStateStorage, err := pruning.NewLevelDBStorage(filepath.Join(m.config.DataDir, "trie"))
 	if err != nil {
 		return nil, err
 	}
 
+	m.prunableStateStorage = prunableStateStorage
+
+	var stateStorage itrie.Storage = prunableStateStorage
+
+	// The watchtower under the light sync fetches the state it's missing
+	// from its provider.
+	if consensusCfg.LightSync.Enabled() {
+		stateStorage, err = avail_consensus.NewProviderStateStorage(stateStorage, consensusCfg.LightSync.StateProvider, logger.Named("state_provider"))
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	m.stateStorage = stateStorage
 
 	blockchainDBPath := m.config.DataDir
@@ -216,7 +247,8 @@ func NewServer(config *server.Config, consensusCfg avail_consensus.Config) (*Ser
 
 	m.snapshotter = snapshotter
 
-	st := itrie.NewState(wrappedStateStorage)
+	// The queries against the state pruned fail with pruning.ErrStatePruned.
+	st := pruning.NewState(itrie.NewState(wrappedStateStorage), prunableStateStorage)
 	m.state = st
 
 	m.executor = state.NewExecutor(config.Chain.Params, st, logger)
@@ -328,6 +360,15 @@ func NewServer(config *server.Config, consensusCfg avail_consensus.Config) (*Ser
 		return nil, err
 	}
 
+	// add the transactions of the txpool of the previous run back, on the
+	// restored chain, for the stale ones to be rejected
+	m.txpoolJournal = newTxPoolJournal(config.DataDir, m.txpool, logger)
+	if _, err := m.txpoolJournal.load(); err != nil {
+		return nil, err
+	}
+
+	m.txpoolJournal.start(txPoolJournalInterval)
+
 	// start relayer
 	if config.Relayer {
 		if err := m.setupRelayer(); err != nil {
@@ -513,7 +554,7 @@ func (s *Server) setupConsensus(consensusCfg avail_consensus.Config) error {
 
 	// Fill-in server dependencies.
 	consensusCfg.Blockchain = s.blockchain
-	consensusCfg.BlockTime = uint64(blockTime.Seconds())
+	consensusCfg.BlockTime = blockTime.Duration
 	consensusCfg.Chain = s.config.Chain
 	consensusCfg.Config = config
 	consensusCfg.Context = context.Background()
@@ -523,6 +564,8 @@ func (s *Server) setupConsensus(consensusCfg avail_consensus.Config) error {
 	consensusCfg.TxPool = s.txpool
 	consensusCfg.SecretsManager = s.secretsManager
 	consensusCfg.Snapshotter = s.snapshotter
+	consensusCfg.StateStorage = s.stateStorage
+	consensusCfg.PrunableStateStorage = s.prunableStateStorage
 	consensusCfg.NumBlockConfirmations = s.config.NumBlockConfirmations
 
 	consensus, err := avail_consensus.New(consensusCfg)
@@ -554,7 +597,7 @@ func ExtractBlockTime(engineConfig map[string]interface{}) (common.Duration, err
 		return common.Duration{}, errBlockTimeInvalid
 	}
 
-	if blockTime.Seconds() < 1 {
+	if blockTime.Duration < avail_consensus.MinBlockTime {
 		return common.Duration{}, errBlockTimeInvalid
 	}
 
@@ -614,6 +657,17 @@ type jsonRPCHub struct {
 	consensus.BridgeDataProvider
 }
 
+// AddTx adds the transaction submitted over JSON-RPC to the transaction pool.
+// The Avail consensus rejects the transactions under the price limit of the
+// sequencer.
+func (j *jsonRPCHub) AddTx(tx *types.Transaction) error {
+	if d, ok := j.Consensus.(*avail_consensus.Avail); ok {
+		return d.AddTx(tx)
+	}
+
+	return j.TxPool.AddTx(tx)
+}
+
 // GetPeers returns the number of peers connected to the server.
 func (j *jsonRPCHub) GetPeers() int {
 	return len(j.Server.Peers())
@@ -848,6 +902,11 @@ func (j *jsonRPCHub) GetSyncProgression() *progress.Progression {
 // txpool, executor, and others) to create a new jsonRPCHub. It then constructs
 // a new JSONRPC server and assigns it to the server's jsonrpcServer property.
 //
+// With the Avail consensus, the JSONRPC server listens on the loopback
+// interface behind a proxy resolving the `finalized` and `safe` block tags to
+// the settled head of the chain, and answering `eth_feeHistory` and
+// `eth_maxPriorityFeePerGas` from the recent blocks.
+//
 // If an error occurs while creating the JSONRPC server, it is returned immediately.
 // Otherwise, the method returns nil.
 func (s *Server) setupJSONRPC() error {
@@ -873,6 +932,16 @@ func (s *Server) setupJSONRPC() error {
 		BlockRangeLimit:          s.config.JSONRPC.BlockRangeLimit,
 	}
 
+	d, settledHead := s.consensus.(*avail_consensus.Avail)
+	if settledHead {
+		upstream, err := loopbackAddr()
+		if err != nil {
+			return err
+		}
+
+		conf.Addr = upstream
+	}
+
 	srv, err := jsonrpc.NewJSONRPC(s.logger, conf)
 	if err != nil {
 		return err
@@ -880,6 +949,25 @@ func (s *Server) setupJSONRPC() error {
 
 	s.jsonrpcServer = srv
 
+	if settledHead {
+		settled := func() uint64 {
+			return d.SettledHead().Number
+		}
+
+		traces := traceBackend{
+			Blockchain: s.blockchain,
+			Executor:   s.executor,
+			Storage:    s.stateStorage,
+			Pruned:     s.prunableStateStorage.Pruned,
+			Retention:  d.StateRetention(),
+		}
+
+		s.blockTagServer, err = startJSONRPCProxy(s.config.JSONRPC.JSONRPCAddr, conf.Addr, settled, s.blockchain, s.config.PriceLimit, traces, s.txpool, s.rpcLimits, s.logger.Named("jsonrpc_proxy"))
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -915,6 +1003,11 @@ func (s *Server) Chain() *chain.Chain {
 	return s.chain
 }
 
+// Consensus retrieves the server's consensus mechanism.
+func (s *Server) Consensus() consensus.Consensus {
+	return s.consensus
+}
+
 // JoinPeer attempts to add a new peer to the server's network. The peer is
 // identified by the provided multiaddress. If an error occurs while joining the
 // peer, it is returned immediately.
@@ -947,6 +1040,12 @@ func (s *Server) Close() {
 		s.logger.Error("failed to close storage for trie", "error", err.Error())
 	}
 
+	if s.blockTagServer != nil {
+		if err := s.blockTagServer.Shutdown(context.Background()); err != nil {
+			s.logger.Error("JSON-RPC block tag proxy shutdown error", "error", err)
+		}
+	}
+
 	if s.prometheusServer != nil {
 		if err := s.prometheusServer.Shutdown(context.Background()); err != nil {
 			s.logger.Error("Prometheus server shutdown error", err)
@@ -958,6 +1057,13 @@ func (s *Server) Close() {
 		s.stateSyncRelayer.Stop()
 	}
 
+	// Save the contents of the txpool for the next run
+	if s.txpoolJournal != nil {
+		if err := s.txpoolJournal.close(); err != nil {
+			s.logger.Error("failed to save the txpool journal", "error", err)
+		}
+	}
+
 	// Close the txpool's main loop
 	s.txpool.Close()
 
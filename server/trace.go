@@ -0,0 +1,500 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/0xPolygon/polygon-edge/jsonrpc"
+	"github.com/0xPolygon/polygon-edge/state"
+	itrie "github.com/0xPolygon/polygon-edge/state/immutable-trie"
+	"github.com/0xPolygon/polygon-edge/state/runtime/tracer"
+	"github.com/0xPolygon/polygon-edge/state/runtime/tracer/structtracer"
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/pkg/block"
+	"github.com/availproject/op-evm/pkg/blockchain"
+	"github.com/availproject/op-evm/pkg/pruning"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/hashicorp/go-hclog"
+)
+
+// The trace methods answered by the traceHandler, in place of the ones of
+// the JSON-RPC server of edge, which replay the blocks without settling
+// their fees and have the struct logger only.
+const (
+	traceTransactionMethod   = "debug_traceTransaction"
+	traceBlockByNumberMethod = "debug_traceBlockByNumber"
+	traceBlockByHashMethod   = "debug_traceBlockByHash"
+)
+
+// The tracers of the trace methods: the struct logger, the default one, and
+// the call tracer.
+const (
+	structLoggerName = ""
+	callTracerName   = "callTracer"
+)
+
+const (
+	// defaultTraceTimeout is the time a transaction is traced for at most,
+	// unless the trace says otherwise, as by go-ethereum.
+	defaultTraceTimeout = 5 * time.Second
+
+	// defaultTraceReexec is the most blocks re-executed to regenerate the
+	// pruned state a trace starts from, unless the trace says otherwise, as
+	// by go-ethereum.
+	defaultTraceReexec = 128
+)
+
+var (
+	errTraceGenesis      = errors.New("genesis is not traceable")
+	errTraceTxNotFound   = errors.New("transaction not found")
+	errTraceBlockMissing = errors.New("block not found")
+)
+
+// traceConfig is the configuration of a trace, as by go-ethereum: the
+// tracer and its configuration, the time each transaction is traced for at
+// most, the most blocks re-executed to regenerate the state the trace starts
+// from, and the configuration of the struct logger.
+type traceConfig struct {
+	Tracer       string          `json:"tracer"`
+	TracerConfig json.RawMessage `json:"tracerConfig"`
+	Timeout      *string         `json:"timeout"`
+	Reexec       *uint64         `json:"reexec"`
+
+	EnableMemory     bool `json:"enableMemory"`
+	DisableStack     bool `json:"disableStack"`
+	DisableStorage   bool `json:"disableStorage"`
+	EnableReturnData bool `json:"enableReturnData"`
+}
+
+// txTraceResult is the trace of a transaction of a block.
+type txTraceResult struct {
+	TxHash types.Hash  `json:"txHash"`
+	Result interface{} `json:"result"`
+}
+
+// traceBackend is the chain the traces re-execute the blocks of.
+type traceBackend struct {
+	// Blockchain and Executor are the chain and the executor of the node.
+	Blockchain *blockchain.Blockchain
+	Executor   *state.Executor
+
+	// Storage is the state storage the pruned states are regenerated over,
+	// in memory, and Pruned reports the states pruned from it.
+	Storage itrie.Storage
+	Pruned  func(root types.Hash) bool
+
+	// Retention is the number of the latest blocks whose state is retained,
+	// the most blocks re-executed to regenerate a pruned state; 0 doesn't
+	// cap them.
+	Retention uint64
+}
+
+// traceHandler answers the debug_traceTransaction, debug_traceBlockByNumber
+// and debug_traceBlockByHash requests, single or batched, by re-executing
+// the transactions of the block from the state of its parent with the
+// tracer attached, handing the others over to the next handler. A pruned
+// state of the parent is regenerated by re-executing the blocks from the
+// closest state held, within the reexec depth; past it, the trace fails
+// with pruning.ErrStatePruned. The websocket clients are answered the same,
+// through the wsBridge.
+type traceHandler struct {
+	next    http.Handler
+	backend traceBackend
+	logger  hclog.Logger
+}
+
+func newTraceHandler(next http.Handler, backend traceBackend, logger hclog.Logger) *traceHandler {
+	return &traceHandler{
+		next:    next,
+		backend: backend,
+		logger:  logger,
+	}
+}
+
+func (h *traceHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	serveLocalRPC(w, r, h.next, []string{traceTransactionMethod, traceBlockByNumberMethod, traceBlockByHashMethod}, h.answer, h.logger)
+}
+
+// answer returns the response to the trace request, with the errors of its
+// parameters and of the method reported as go-ethereum does.
+func (h *traceHandler) answer(req *rpcRequest) *rpcResponse {
+	resp := &rpcResponse{Version: "2.0", ID: req.ID}
+
+	var params []json.RawMessage
+	if len(bytes.TrimSpace(req.Params)) > 0 {
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			resp.Error = &rpcError{Code: rpcInvalidParamsCode, Message: "non-array args"}
+			return resp
+		}
+	}
+
+	var (
+		config traceConfig
+		result interface{}
+		err    error
+	)
+
+	// The configuration of the trace is optional.
+	withConfig := func(arg interface{}) []interface{} {
+		if len(params) > 1 {
+			return []interface{}{arg, &config}
+		}
+
+		return []interface{}{arg}
+	}
+
+	switch req.Method {
+	case traceTransactionMethod:
+		var hash types.Hash
+
+		if perr := parseParams(params, withConfig(&hash)...); perr != nil {
+			resp.Error = perr
+			return resp
+		}
+
+		result, err = h.traceTransaction(hash, &config)
+
+	case traceBlockByNumberMethod:
+		var number rpc.BlockNumber
+
+		if perr := parseParams(params, withConfig(&number)...); perr != nil {
+			resp.Error = perr
+			return resp
+		}
+
+		result, err = h.traceBlockByNumber(number, &config)
+
+	case traceBlockByHashMethod:
+		var hash types.Hash
+
+		if perr := parseParams(params, withConfig(&hash)...); perr != nil {
+			resp.Error = perr
+			return resp
+		}
+
+		result, err = h.traceBlockByHash(hash, &config)
+	}
+
+	if err != nil {
+		resp.Error = &rpcError{Code: rpcServerErrorCode, Message: err.Error()}
+		return resp
+	}
+
+	resp.Result = result
+
+	return resp
+}
+
+func (h *traceHandler) traceTransaction(hash types.Hash, config *traceConfig) (interface{}, error) {
+	blockHash, ok := h.backend.Blockchain.ReadTxLookup(hash)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", errTraceTxNotFound, hash)
+	}
+
+	blk, ok := h.backend.Blockchain.GetBlockByHash(blockHash, true)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", errTraceBlockMissing, blockHash)
+	}
+
+	results, err := h.trace(blk, config, &hash)
+	if err != nil {
+		return nil, err
+	}
+
+	return results[0].Result, nil
+}
+
+func (h *traceHandler) traceBlockByNumber(number rpc.BlockNumber, config *traceConfig) ([]*txTraceResult, error) {
+	// There's no pending block; the finalized and safe ones are resolved by
+	// the blockTagHandler ahead.
+	n := h.backend.Blockchain.Header().Number
+	if number >= 0 {
+		n = uint64(number)
+	}
+
+	blk, ok := h.backend.Blockchain.GetBlockByNumber(n, true)
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", errTraceBlockMissing, n)
+	}
+
+	return h.trace(blk, config, nil)
+}
+
+func (h *traceHandler) traceBlockByHash(hash types.Hash, config *traceConfig) ([]*txTraceResult, error) {
+	blk, ok := h.backend.Blockchain.GetBlockByHash(hash, true)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", errTraceBlockMissing, hash)
+	}
+
+	return h.trace(blk, config, nil)
+}
+
+// trace re-executes the transactions of the block from the state of its
+// parent, as the chain executed them, and returns the traces of all of
+// them, or of the target one only. A transaction traced past the timeout
+// fails the trace as a whole, its state no longer the one of the chain.
+func (h *traceHandler) trace(blk *types.Block, config *traceConfig, target *types.Hash) ([]*txTraceResult, error) {
+	if blk.Number() == 0 {
+		return nil, errTraceGenesis
+	}
+
+	timeout := defaultTraceTimeout
+	if config.Timeout != nil {
+		var err error
+		if timeout, err = time.ParseDuration(*config.Timeout); err != nil {
+			return nil, fmt.Errorf("invalid trace timeout: %w", err)
+		}
+	}
+
+	// The tracer is checked before the state is regenerated.
+	if _, err := newTracer(config); err != nil {
+		return nil, err
+	}
+
+	parent, ok := h.backend.Blockchain.GetHeaderByHash(blk.ParentHash())
+	if !ok {
+		return nil, fmt.Errorf("%w: parent of block %d", errTraceBlockMissing, blk.Number())
+	}
+
+	reexec := uint64(defaultTraceReexec)
+	if config.Reexec != nil {
+		reexec = *config.Reexec
+	}
+
+	executor, err := h.stateAt(parent, reexec)
+	if err != nil {
+		return nil, err
+	}
+
+	creator, err := h.backend.Blockchain.GetConsensus().GetBlockCreator(blk.Header)
+	if err != nil {
+		return nil, err
+	}
+
+	begun, err := executor.BeginTxn(parent.StateRoot, blk.Header, creator)
+	if err != nil {
+		return nil, err
+	}
+
+	txn := block.NewTransition(begun)
+
+	var results []*txTraceResult
+
+	for _, tx := range blk.Transactions {
+		// As the chain does, the transactions over the gas limit of the
+		// block are skipped.
+		if tx.Gas > blk.Header.GasLimit {
+			continue
+		}
+
+		if target != nil && tx.Hash != *target {
+			if err := txn.Write(tx); err != nil {
+				return nil, err
+			}
+
+			continue
+		}
+
+		result, err := traceTx(txn, tx, config, timeout)
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, &txTraceResult{TxHash: tx.Hash, Result: result})
+
+		if target != nil {
+			return results, nil
+		}
+	}
+
+	if target != nil {
+		return nil, fmt.Errorf("%w: %s", errTraceTxNotFound, *target)
+	}
+
+	return results, nil
+}
+
+// traceTx writes the transaction with a new tracer of the configuration
+// attached, canceled past the timeout, and returns its result.
+func traceTx(txn *block.Transition, tx *types.Transaction, config *traceConfig, timeout time.Duration) (interface{}, error) {
+	t, err := newTracer(config)
+	if err != nil {
+		return nil, err
+	}
+
+	timer := time.AfterFunc(timeout, func() {
+		t.Cancel(jsonrpc.ErrExecutionTimeout)
+	})
+	defer timer.Stop()
+
+	txn.SetTracer(t)
+	defer txn.SetTracer(nil)
+
+	if err := txn.Write(tx); err != nil {
+		return nil, err
+	}
+
+	return t.GetResult()
+}
+
+// newTracer returns the tracer of the configuration.
+func newTracer(config *traceConfig) (tracer.Tracer, error) {
+	switch config.Tracer {
+	case structLoggerName:
+		return structtracer.NewStructTracer(structtracer.Config{
+			EnableMemory:     config.EnableMemory,
+			EnableStack:      !config.DisableStack,
+			EnableStorage:    !config.DisableStorage,
+			EnableReturnData: config.EnableReturnData,
+		}), nil
+
+	case callTracerName:
+		var cfg callTracerConfig
+		if len(bytes.TrimSpace(config.TracerConfig)) > 0 {
+			if err := json.Unmarshal(config.TracerConfig, &cfg); err != nil {
+				return nil, fmt.Errorf("invalid call tracer config: %w", err)
+			}
+		}
+
+		return newCallTracer(cfg), nil
+
+	default:
+		return nil, fmt.Errorf("unknown tracer %q, want the struct logger or %q", config.Tracer, callTracerName)
+	}
+}
+
+// stateAt returns the executor holding the state of the header: the one of
+// the node if it's held, or one regenerating it in memory, by re-executing
+// up to reexec blocks, capped at the retention, from the closest state held
+// before it.
+func (h *traceHandler) stateAt(header *types.Header, reexec uint64) (*state.Executor, error) {
+	if !h.backend.Pruned(header.StateRoot) {
+		return h.backend.Executor, nil
+	}
+
+	if h.backend.Retention > 0 && reexec > h.backend.Retention {
+		reexec = h.backend.Retention
+	}
+
+	// The blocks re-executed, from the last one.
+	var replay []*types.Header
+
+	base := header
+	for h.backend.Pruned(base.StateRoot) {
+		if uint64(len(replay)) >= reexec || base.Number == 0 {
+			return nil, fmt.Errorf("%w: block %d, no state held within %d blocks", pruning.ErrStatePruned, header.Number, reexec)
+		}
+
+		replay = append(replay, base)
+
+		parent, ok := h.backend.Blockchain.GetHeaderByHash(base.ParentHash)
+		if !ok {
+			return nil, fmt.Errorf("%w: parent of block %d", errTraceBlockMissing, base.Number)
+		}
+
+		base = parent
+	}
+
+	executor := state.NewExecutor(h.backend.Blockchain.Config(), itrie.NewState(newOverlayStorage(h.backend.Storage)), h.logger)
+	executor.GetHash = h.backend.Blockchain.GetHashHelper
+
+	for i := len(replay) - 1; i >= 0; i-- {
+		blk, ok := h.backend.Blockchain.GetBlockByHash(replay[i].Hash, true)
+		if !ok {
+			return nil, fmt.Errorf("%w: %d", errTraceBlockMissing, replay[i].Number)
+		}
+
+		res, err := h.backend.Blockchain.ExecuteBlockOn(executor, blk)
+		if err != nil {
+			return nil, fmt.Errorf("failed to regenerate the state of block %d: %w", blk.Number(), err)
+		}
+
+		if res.Root != blk.Header.StateRoot {
+			return nil, fmt.Errorf("failed to regenerate the state of block %d: root %s, want %s", blk.Number(), res.Root, blk.Header.StateRoot)
+		}
+	}
+
+	h.logger.Debug("regenerated the pruned state", "block", header.Number, "from", base.Number)
+
+	return executor, nil
+}
+
+// overlayStorage is the state storage holding the trie nodes and the code
+// written in memory, over the one they are read from otherwise.
+type overlayStorage struct {
+	under itrie.Storage
+
+	lock  sync.RWMutex
+	nodes map[string][]byte
+	code  map[types.Hash][]byte
+}
+
+func newOverlayStorage(under itrie.Storage) *overlayStorage {
+	return &overlayStorage{
+		under: under,
+		nodes: make(map[string][]byte),
+		code:  make(map[types.Hash][]byte),
+	}
+}
+
+func (s *overlayStorage) Put(k, v []byte) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.nodes[string(k)] = append([]byte{}, v...)
+}
+
+func (s *overlayStorage) Get(k []byte) ([]byte, bool) {
+	s.lock.RLock()
+	v, ok := s.nodes[string(k)]
+	s.lock.RUnlock()
+
+	if ok {
+		return v, true
+	}
+
+	return s.under.Get(k)
+}
+
+func (s *overlayStorage) Batch() itrie.Batch {
+	return &overlayBatch{s: s}
+}
+
+func (s *overlayStorage) SetCode(hash types.Hash, code []byte) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.code[hash] = append([]byte{}, code...)
+}
+
+func (s *overlayStorage) GetCode(hash types.Hash) ([]byte, bool) {
+	s.lock.RLock()
+	code, ok := s.code[hash]
+	s.lock.RUnlock()
+
+	if ok {
+		return code, true
+	}
+
+	return s.under.GetCode(hash)
+}
+
+// Close leaves the storage under open.
+func (s *overlayStorage) Close() error {
+	return nil
+}
+
+type overlayBatch struct {
+	s *overlayStorage
+}
+
+func (b *overlayBatch) Put(k, v []byte) {
+	b.s.Put(k, v)
+}
+
+func (b *overlayBatch) Write() {}
@@ -0,0 +1,285 @@
+package server
+
+import (
+	"context"
+	"encoding/hex"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/0xPolygon/polygon-edge/blockchain/storage/memory"
+	"github.com/0xPolygon/polygon-edge/chain"
+	"github.com/0xPolygon/polygon-edge/crypto"
+	"github.com/0xPolygon/polygon-edge/state"
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/pkg/blockchain"
+	"github.com/availproject/op-evm/pkg/pruning"
+	"github.com/availproject/op-evm/pkg/staking"
+	"github.com/availproject/op-evm/pkg/test"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	// traceCallerAddr is the contract calling the reverter, reverting with
+	// the data it reverted with.
+	traceCallerAddr = types.StringToAddress("0x1000000000000000000000000000000000000001")
+
+	// traceReverterAddr is the contract reverting with Error("boom").
+	traceReverterAddr = types.StringToAddress("0x1000000000000000000000000000000000000002")
+)
+
+// traceReverterCode is the code of the reverter: the ABI encoded
+// Error("boom") written to memory and reverted with.
+func traceReverterCode() []byte {
+	return mustDecodeHex(
+		"7f08c379a000000000000000000000000000000000000000000000000000000000" + // PUSH32 selector
+			"600052" + // MSTORE(0x00)
+			"6020600452" + // MSTORE(0x04, 0x20)
+			"6004602452" + // MSTORE(0x24, 4)
+			"7f626f6f6d00000000000000000000000000000000000000000000000000000000" + // PUSH32 "boom"
+			"604452" + // MSTORE(0x44)
+			"60646000fd", // REVERT(0x00, 0x64)
+	)
+}
+
+// traceCallerCode is the code of the caller: a CALL to the reverter with
+// all the gas, its return data copied to memory and reverted with.
+func traceCallerCode() []byte {
+	return mustDecodeHex(
+		"6000600060006000600073" + hex.EncodeToString(traceReverterAddr.Bytes()) + "5af1" + // CALL(gas, reverter, 0, 0, 0, 0, 0)
+			"50" + // POP
+			"3d600060003e" + // RETURNDATACOPY(0, 0, RETURNDATASIZE)
+			"3d6000fd", // REVERT(0, RETURNDATASIZE)
+	)
+}
+
+func mustDecodeHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+
+	return b
+}
+
+// traceStructLog is an entry of the struct logger result decoded.
+type traceStructLog struct {
+	Op string `json:"op"`
+}
+
+// traceFrame is the call tracer result decoded.
+type traceFrame struct {
+	Type         string        `json:"type"`
+	From         types.Address `json:"from"`
+	To           types.Address `json:"to"`
+	Error        string        `json:"error"`
+	RevertReason string        `json:"revertReason"`
+	Calls        []*traceFrame `json:"calls"`
+}
+
+type testTraceChain struct {
+	executor *state.Executor
+	bchain   *blockchain.Blockchain
+	storage  *pruning.Storage
+}
+
+// newTestTraceChain returns the chain of the caller and the reverter, its
+// state on a pruning storage.
+func newTestTraceChain(t *testing.T) *testTraceChain {
+	t.Helper()
+
+	spec, err := test.NewChain("..")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spec.Genesis.Alloc[traceCallerAddr] = &chain.GenesisAccount{Balance: big.NewInt(0), Code: traceCallerCode()}
+	spec.Genesis.Alloc[traceReverterAddr] = &chain.GenesisAccount{Balance: big.NewInt(0), Code: traceReverterCode()}
+
+	db, err := memory.NewMemoryStorage(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	storage := pruning.NewMemoryStorage()
+
+	executor, bchain, _, err := test.NewBlockchainWithTxPoolOnState(spec, staking.NewVerifier(new(staking.DumbActiveParticipants), hclog.NewNullLogger()), db, storage)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testTraceChain{executor: executor, bchain: bchain, storage: storage}
+}
+
+// dial returns the client of the trace handler on the chain, regenerating the
+// pruned states within the retention.
+func (c *testTraceChain) dial(t *testing.T, retention uint64) *rpc.Client {
+	t.Helper()
+
+	srv := httptest.NewServer(newTraceHandler(http.NotFoundHandler(), c.backend(retention), hclog.NewNullLogger()))
+	t.Cleanup(srv.Close)
+
+	client, err := rpc.Dial(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(client.Close)
+
+	return client
+}
+
+// backend returns the trace backend of the chain, holding the states of the
+// given number of the latest blocks.
+func (c *testTraceChain) backend(retention uint64) traceBackend {
+	return traceBackend{
+		Blockchain: c.bchain,
+		Executor:   c.executor,
+		Storage:    c.storage,
+		Pruned:     c.storage.Pruned,
+		Retention:  retention,
+	}
+}
+
+// testFaucetCall returns the call of the faucet to the given contract.
+func testFaucetCall(t *testing.T, bchain *blockchain.Blockchain, nonce uint64, to types.Address) *types.Transaction {
+	t.Helper()
+
+	tx, err := crypto.NewEIP155Signer(uint64(bchain.Config().ChainID), true).SignTx(&types.Transaction{
+		Nonce:    nonce,
+		To:       &to,
+		Value:    big.NewInt(0),
+		Gas:      200_000,
+		GasPrice: big.NewInt(1),
+	}, test.FaucetSignKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tx.ComputeHash()
+}
+
+func TestTraceRevertedCall(t *testing.T) {
+	c := newTestTraceChain(t)
+
+	call := testFaucetCall(t, c.bchain, 0, traceCallerAddr)
+	writeTestBlock(t, c.executor, c.bchain, call)
+
+	client := c.dial(t, 0)
+
+	var frame traceFrame
+	if err := client.Call(&frame, "debug_traceTransaction", call.Hash, map[string]interface{}{"tracer": "callTracer"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The call of the transaction reverted with the reason of the one it
+	// made.
+	assert.Equal(t, "CALL", frame.Type)
+	assert.Equal(t, test.FaucetAccount, frame.From)
+	assert.Equal(t, traceCallerAddr, frame.To)
+	assert.NotEmpty(t, frame.Error)
+	assert.Equal(t, "boom", frame.RevertReason)
+
+	if assert.Len(t, frame.Calls, 1) {
+		inner := frame.Calls[0]
+		assert.Equal(t, "CALL", inner.Type)
+		assert.Equal(t, traceCallerAddr, inner.From)
+		assert.Equal(t, traceReverterAddr, inner.To)
+		assert.NotEmpty(t, inner.Error)
+		assert.Equal(t, "boom", inner.RevertReason)
+		assert.Empty(t, inner.Calls)
+	}
+
+	// The top call only.
+	frame = traceFrame{}
+	assert.NoError(t, client.Call(&frame, "debug_traceTransaction", call.Hash, map[string]interface{}{"tracer": "callTracer", "tracerConfig": map[string]interface{}{"onlyTopCall": true}}))
+	assert.Equal(t, "boom", frame.RevertReason)
+	assert.Empty(t, frame.Calls)
+
+	// The block, with the struct logger by default.
+	var results []struct {
+		TxHash types.Hash `json:"txHash"`
+		Result struct {
+			Failed     bool             `json:"failed"`
+			StructLogs []traceStructLog `json:"structLogs"`
+		} `json:"result"`
+	}
+
+	if assert.NoError(t, client.Call(&results, "debug_traceBlockByNumber", "0x1")) && assert.Len(t, results, 1) {
+		assert.Equal(t, call.Hash, results[0].TxHash)
+		assert.True(t, results[0].Result.Failed)
+		assert.NotEmpty(t, results[0].Result.StructLogs)
+	}
+
+	var byHash []interface{}
+	assert.NoError(t, client.Call(&byHash, "debug_traceBlockByHash", c.bchain.Header().Hash, map[string]interface{}{"tracer": "callTracer"}))
+	assert.Len(t, byHash, 1)
+
+	var unknown interface{}
+	assert.ErrorContains(t, client.Call(&unknown, "debug_traceTransaction", call.Hash, map[string]interface{}{"tracer": "prestateTracer"}), "unknown tracer")
+}
+
+func TestTraceWebsocket(t *testing.T) {
+	c := newTestTraceChain(t)
+
+	call := testFaucetCall(t, c.bchain, 0, traceCallerAddr)
+	writeTestBlock(t, c.executor, c.bchain, call)
+
+	client := newTestWSBridge(t, newTraceHandler(newUpstreamHandler(http.NotFoundHandler()), c.backend(0), hclog.NewNullLogger()))
+
+	// The traces are answered over the websocket too.
+	var frame traceFrame
+	assert.NoError(t, client.Call(&frame, "debug_traceTransaction", call.Hash, map[string]interface{}{"tracer": "callTracer"}))
+	assert.Equal(t, "boom", frame.RevertReason)
+
+	var byNumber []interface{}
+	assert.NoError(t, client.Call(&byNumber, "debug_traceBlockByNumber", "0x1", map[string]interface{}{"tracer": "callTracer"}))
+	assert.Len(t, byNumber, 1)
+}
+
+func TestTracePrunedState(t *testing.T) {
+	c := newTestTraceChain(t)
+
+	genesis := c.bchain.Header()
+
+	writeTestBlock(t, c.executor, c.bchain, testFaucetTransfer(t, c.bchain, 0, 1))
+
+	call := testFaucetCall(t, c.bchain, 1, traceCallerAddr)
+	writeTestBlock(t, c.executor, c.bchain, call)
+
+	// The state of the first block, the parent of the call, is pruned.
+	head := c.bchain.Header()
+
+	_, err := c.storage.Prune(context.Background(), func() ([]types.Hash, error) {
+		return []types.Hash{genesis.StateRoot, head.StateRoot}, nil
+	}, pruning.PruneConfig{BatchSize: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parent, _ := c.bchain.GetHeaderByNumber(1)
+	if !c.storage.Pruned(parent.StateRoot) {
+		t.Fatal("the state of the parent wasn't pruned")
+	}
+
+	client := c.dial(t, 0)
+
+	// Without re-executing the blocks, the state is pruned.
+	var frame traceFrame
+	err = client.Call(&frame, "debug_traceTransaction", call.Hash, map[string]interface{}{"tracer": "callTracer", "reexec": 0})
+	if assert.Error(t, err) {
+		assert.True(t, strings.HasPrefix(err.Error(), pruning.ErrStatePruned.Error()), err.Error())
+	}
+
+	// Re-executing them, it's regenerated from the genesis.
+	assert.NoError(t, client.Call(&frame, "debug_traceTransaction", call.Hash, map[string]interface{}{"tracer": "callTracer"}))
+	assert.Equal(t, "boom", frame.RevertReason)
+	assert.Len(t, frame.Calls, 1)
+
+	// The storage is left as it was.
+	assert.True(t, c.storage.Pruned(parent.StateRoot))
+}
@@ -0,0 +1,217 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/0xPolygon/polygon-edge/helper/hex"
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/armon/go-metrics"
+	"github.com/hashicorp/go-hclog"
+)
+
+const (
+	// txPoolJournalFile is the file of the txpool journal in the data dir.
+	txPoolJournalFile = "txpool.journal"
+
+	// txPoolJournalVersion is the version of the format of the journal
+	// written, on its first line. Journals of other versions are ignored.
+	txPoolJournalVersion = "txpool-journal/1"
+
+	// txPoolJournalInterval is the interval at which the journal is saved
+	// while the node runs, for the contents of the txpool to survive a crash.
+	txPoolJournalInterval = time.Minute
+)
+
+// journaledTxPool is the txpool as seen by the journal.
+type journaledTxPool interface {
+	GetTxs(inclQueued bool) (allPromoted, allEnqueued map[types.Address][]*types.Transaction)
+	AddTx(tx *types.Transaction) error
+}
+
+// txPoolJournal persists the pending and queued transactions of the txpool
+// in the data dir, for them to be added back to the txpool when the node
+// restarts.
+//
+// The journal is the version line followed by a line for every transaction,
+// its RLP encoding in hex, the transactions of every sender in the order of
+// their nonces.
+type txPoolJournal struct {
+	logger hclog.Logger
+	path   string
+	pool   journaledTxPool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// txPoolJournalLoad is the outcome of loading the journal: the transactions
+// added back to the txpool, the ones it rejected, stale since saved, and the
+// corrupt entries skipped.
+type txPoolJournalLoad struct {
+	added    int
+	rejected int
+	corrupt  int
+}
+
+func newTxPoolJournal(dataDir string, pool journaledTxPool, logger hclog.Logger) *txPoolJournal {
+	return &txPoolJournal{
+		logger: logger.Named("txpool_journal"),
+		path:   filepath.Join(dataDir, txPoolJournalFile),
+		pool:   pool,
+	}
+}
+
+// load adds the transactions of the journal back to the txpool, through its
+// validation, dropping the ones no longer valid. Neither a corrupt entry nor
+// a journal of another version fails the load; the entries are skipped.
+func (j *txPoolJournal) load() (txPoolJournalLoad, error) {
+	var load txPoolJournalLoad
+
+	raw, err := os.ReadFile(j.path)
+	if os.IsNotExist(err) {
+		return load, nil
+	} else if err != nil {
+		return load, fmt.Errorf("failed to read the txpool journal: %w", err)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(raw))
+	scanner.Buffer(nil, len(raw)+1)
+
+	if !scanner.Scan() {
+		return load, nil
+	}
+
+	if version := strings.TrimSpace(scanner.Text()); version != txPoolJournalVersion {
+		j.logger.Warn("ignoring the txpool journal of an unknown version", "version", version)
+		return load, nil
+	}
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		tx := new(types.Transaction)
+
+		bs, err := hex.DecodeHex(line)
+		if err == nil {
+			err = tx.UnmarshalRLP(bs)
+		}
+
+		if err != nil {
+			load.corrupt++
+			continue
+		}
+
+		if err := j.pool.AddTx(tx); err != nil {
+			j.logger.Debug("dropping the journaled transaction", "hash", tx.Hash, "err", err)
+			load.rejected++
+
+			continue
+		}
+
+		load.added++
+	}
+
+	observeTxPoolJournalLoad(load)
+
+	j.logger.Info("loaded the txpool journal", "added", load.added, "rejected", load.rejected, "corrupt", load.corrupt)
+
+	return load, nil
+}
+
+// save writes the pending and queued transactions of the txpool to the
+// journal, replacing it at once.
+func (j *txPoolJournal) save() error {
+	promoted, enqueued := j.pool.GetTxs(true)
+
+	senders := make([]types.Address, 0, len(promoted)+len(enqueued))
+	for addr := range promoted {
+		senders = append(senders, addr)
+	}
+
+	for addr := range enqueued {
+		if _, ok := promoted[addr]; !ok {
+			senders = append(senders, addr)
+		}
+	}
+
+	sort.Slice(senders, func(i, k int) bool { return bytes.Compare(senders[i][:], senders[k][:]) < 0 })
+
+	var buf bytes.Buffer
+
+	buf.WriteString(txPoolJournalVersion + "\n")
+
+	n := 0
+
+	for _, addr := range senders {
+		txs := append(append([]*types.Transaction{}, promoted[addr]...), enqueued[addr]...)
+		sort.SliceStable(txs, func(i, k int) bool { return txs[i].Nonce < txs[k].Nonce })
+
+		for _, tx := range txs {
+			buf.WriteString(hex.EncodeToHex(tx.MarshalRLP()) + "\n")
+			n++
+		}
+	}
+
+	tmp := j.path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0o660); err != nil {
+		return fmt.Errorf("failed to write the txpool journal: %w", err)
+	}
+
+	if err := os.Rename(tmp, j.path); err != nil {
+		return fmt.Errorf("failed to replace the txpool journal: %w", err)
+	}
+
+	j.logger.Debug("saved the txpool journal", "txs", n)
+
+	return nil
+}
+
+// start saves the journal every interval until closed.
+func (j *txPoolJournal) start(interval time.Duration) {
+	j.stop, j.done = make(chan struct{}), make(chan struct{})
+
+	go func() {
+		defer close(j.done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-j.stop:
+				return
+			case <-ticker.C:
+				if err := j.save(); err != nil {
+					j.logger.Error("failed to save the txpool journal", "err", err)
+				}
+			}
+		}
+	}()
+}
+
+// close stops saving the journal periodically and saves it a last time.
+func (j *txPoolJournal) close() error {
+	if j.stop != nil {
+		close(j.stop)
+		<-j.done
+	}
+
+	return j.save()
+}
+
+// observeTxPoolJournalLoad records the outcome of loading the txpool journal.
+func observeTxPoolJournalLoad(load txPoolJournalLoad) {
+	metrics.IncrCounter([]string{"txpool_journal", "added_txs"}, float32(load.added))
+	metrics.IncrCounter([]string{"txpool_journal", "rejected_txs"}, float32(load.rejected))
+	metrics.IncrCounter([]string{"txpool_journal", "corrupt_entries"}, float32(load.corrupt))
+}
@@ -0,0 +1,194 @@
+package server
+
+import (
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/0xPolygon/polygon-edge/consensus"
+	"github.com/0xPolygon/polygon-edge/crypto"
+	"github.com/0xPolygon/polygon-edge/state"
+	"github.com/0xPolygon/polygon-edge/txpool"
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/pkg/blockchain"
+	"github.com/availproject/op-evm/pkg/staking"
+	"github.com/availproject/op-evm/pkg/test"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestJournaledTxPool returns a started txpool on the chain, as a node
+// starting on it would.
+func newTestJournaledTxPool(t *testing.T, executor *state.Executor, bchain *blockchain.Blockchain) *txpool.TxPool {
+	t.Helper()
+
+	pool, err := txpool.NewTxPool(
+		hclog.NewNullLogger(),
+		bchain.Config().Forks.At(0),
+		test.NewTxpoolHub(executor.State(), bchain),
+		nil,
+		nil,
+		&txpool.Config{MaxSlots: 64, MaxAccountEnqueued: 100},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool.SetSigner(crypto.NewEIP155Signer(uint64(bchain.Config().ChainID), true))
+	pool.Start()
+
+	return pool
+}
+
+// testFaucetTransfer returns the transfer of the faucet of the given nonce.
+func testFaucetTransfer(t *testing.T, bchain *blockchain.Blockchain, nonce uint64, value int64) *types.Transaction {
+	t.Helper()
+
+	tx, err := crypto.NewEIP155Signer(uint64(bchain.Config().ChainID), true).SignTx(&types.Transaction{
+		Nonce:    nonce,
+		To:       &types.ZeroAddress,
+		Value:    big.NewInt(value),
+		Gas:      21_000,
+		GasPrice: big.NewInt(1),
+	}, test.FaucetSignKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tx.ComputeHash()
+}
+
+// writeTestBlock writes the block of the transactions on the head of the
+// chain.
+func writeTestBlock(t *testing.T, executor *state.Executor, bchain *blockchain.Blockchain, txs ...*types.Transaction) {
+	t.Helper()
+
+	parent := bchain.Header()
+	header := &types.Header{
+		ParentHash: parent.Hash,
+		Number:     parent.Number + 1,
+		Miner:      types.ZeroAddress.Bytes(),
+		GasLimit:   parent.GasLimit,
+		Timestamp:  uint64(time.Now().Unix()),
+	}
+
+	transition, err := executor.BeginTxn(parent.StateRoot, header, types.ZeroAddress)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tx := range txs {
+		if err := transition.Write(tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, root := transition.Commit()
+	header.StateRoot = root
+	header.GasUsed = transition.TotalGas()
+
+	blk := consensus.BuildBlock(consensus.BuildBlockParams{Header: header, Txns: txs, Receipts: transition.Receipts()})
+	if err := bchain.WriteBlock(blk, "test"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTxPoolJournalRestart(t *testing.T) {
+	spec, err := test.NewChain("..")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	executor, bchain, _, err := test.NewBlockchainWithTxPool(spec, staking.NewVerifier(new(staking.DumbActiveParticipants), hclog.NewNullLogger()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dataDir := t.TempDir()
+
+	// The faucet's transactions of nonces 0 to 2 are pending, the one of
+	// nonce 4 queued behind the gap.
+	pool := newTestJournaledTxPool(t, executor, bchain)
+	journal := newTxPoolJournal(dataDir, pool, hclog.NewNullLogger())
+
+	txs := []*types.Transaction{
+		testFaucetTransfer(t, bchain, 0, 1),
+		testFaucetTransfer(t, bchain, 1, 1),
+		testFaucetTransfer(t, bchain, 2, 1),
+		testFaucetTransfer(t, bchain, 4, 1),
+	}
+
+	for _, tx := range txs {
+		if err := pool.AddTx(tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	assert.Eventually(t, func() bool {
+		promoted, enqueued := pool.GetTxs(true)
+		return len(promoted[test.FaucetAccount]) == 3 && len(enqueued[test.FaucetAccount]) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// The node stops, saving the journal.
+	assert.NoError(t, journal.close())
+	pool.Close()
+
+	// Meanwhile, another transaction of nonce 0 makes it to the chain,
+	// invalidating the pending one.
+	writeTestBlock(t, executor, bchain, testFaucetTransfer(t, bchain, 0, 2))
+
+	// The node restarts, a corrupt entry having made it to the journal.
+	f, err := os.OpenFile(filepath.Join(dataDir, txPoolJournalFile), os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := f.WriteString("0xnot-a-transaction\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	f.Close()
+
+	pool = newTestJournaledTxPool(t, executor, bchain)
+	defer pool.Close()
+
+	load, err := newTxPoolJournal(dataDir, pool, hclog.NewNullLogger()).load()
+	assert.NoError(t, err)
+	assert.Equal(t, txPoolJournalLoad{added: 3, rejected: 1, corrupt: 1}, load)
+
+	// The valid ones are back as they were; the invalidated one is dropped.
+	assert.Eventually(t, func() bool {
+		promoted, enqueued := pool.GetTxs(true)
+		return len(promoted[test.FaucetAccount]) == 2 && len(enqueued[test.FaucetAccount]) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	promoted, enqueued := pool.GetTxs(true)
+	if assert.Len(t, promoted[test.FaucetAccount], 2) && assert.Len(t, enqueued[test.FaucetAccount], 1) {
+		assert.Equal(t, txs[1].Hash, promoted[test.FaucetAccount][0].Hash)
+		assert.Equal(t, txs[2].Hash, promoted[test.FaucetAccount][1].Hash)
+		assert.Equal(t, txs[3].Hash, enqueued[test.FaucetAccount][0].Hash)
+	}
+
+	_, ok := pool.GetPendingTx(txs[0].Hash)
+	assert.False(t, ok)
+}
+
+func TestTxPoolJournalUnknownVersion(t *testing.T) {
+	dataDir := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(dataDir, txPoolJournalFile), []byte("txpool-journal/0\n0x00\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Neither the journal of another version nor a missing one fails the
+	// load.
+	load, err := newTxPoolJournal(dataDir, nil, hclog.NewNullLogger()).load()
+	assert.NoError(t, err)
+	assert.Equal(t, txPoolJournalLoad{}, load)
+
+	load, err = newTxPoolJournal(t.TempDir(), nil, hclog.NewNullLogger()).load()
+	assert.NoError(t, err)
+	assert.Equal(t, txPoolJournalLoad{}, load)
+}
@@ -0,0 +1,375 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/hashicorp/go-hclog"
+)
+
+// The txpool methods answered by the txPoolHandler, in place of the ones of
+// the JSON-RPC server of edge, whose shapes differ from the ones of
+// go-ethereum.
+const (
+	txPoolContentMethod     = "txpool_content"
+	txPoolContentFromMethod = "txpool_contentFrom"
+	txPoolStatusMethod      = "txpool_status"
+	txPoolInspectMethod     = "txpool_inspect"
+)
+
+// maxTxPoolContent is the most transactions returned by txpool_content and
+// txpool_inspect at once, unless asked for fewer.
+const maxTxPoolContent = 4096
+
+// inspectedTxPool is the txpool the txpool methods read.
+type inspectedTxPool interface {
+	GetTxs(inclQueued bool) (map[types.Address][]*types.Transaction, map[types.Address][]*types.Transaction)
+}
+
+// txPoolTx is a transaction of the txpool, as returned by go-ethereum; it's
+// in no block yet.
+type txPoolTx struct {
+	BlockHash        *types.Hash     `json:"blockHash"`
+	BlockNumber      *hexutil.Big    `json:"blockNumber"`
+	From             types.Address   `json:"from"`
+	Gas              hexutil.Uint64  `json:"gas"`
+	GasPrice         *hexutil.Big    `json:"gasPrice"`
+	GasFeeCap        *hexutil.Big    `json:"maxFeePerGas,omitempty"`
+	GasTipCap        *hexutil.Big    `json:"maxPriorityFeePerGas,omitempty"`
+	Hash             types.Hash      `json:"hash"`
+	Input            hexutil.Bytes   `json:"input"`
+	Nonce            hexutil.Uint64  `json:"nonce"`
+	To               *types.Address  `json:"to"`
+	TransactionIndex *hexutil.Uint64 `json:"transactionIndex"`
+	Value            *hexutil.Big    `json:"value"`
+	Type             hexutil.Uint64  `json:"type"`
+	V                *hexutil.Big    `json:"v"`
+	R                *hexutil.Big    `json:"r"`
+	S                *hexutil.Big    `json:"s"`
+}
+
+// txPoolPage is the page of the senders returned by txpool_content and
+// txpool_inspect: the ones after the given address, in the order of their
+// addresses, up to the given number of transactions.
+type txPoolPage struct {
+	After *types.Address `json:"after"`
+	Limit int            `json:"limit"`
+}
+
+// txPoolContent is the result of txpool_content: the pending and the queued
+// transactions of the senders, by the decimal nonce, and the last sender
+// returned if there are more.
+type txPoolContent struct {
+	Pending map[string]map[string]*txPoolTx `json:"pending"`
+	Queued  map[string]map[string]*txPoolTx `json:"queued"`
+	Next    *types.Address                  `json:"next,omitempty"`
+}
+
+// txPoolContentFrom is the result of txpool_contentFrom.
+type txPoolContentFrom struct {
+	Pending map[string]*txPoolTx `json:"pending"`
+	Queued  map[string]*txPoolTx `json:"queued"`
+}
+
+// txPoolStatus is the result of txpool_status.
+type txPoolStatus struct {
+	Pending hexutil.Uint `json:"pending"`
+	Queued  hexutil.Uint `json:"queued"`
+}
+
+// txPoolInspect is the result of txpool_inspect: the summaries of the
+// pending and the queued transactions of the senders, by the decimal nonce,
+// and the last sender returned if there are more.
+type txPoolInspect struct {
+	Pending map[string]map[string]string `json:"pending"`
+	Queued  map[string]map[string]string `json:"queued"`
+	Next    *types.Address               `json:"next,omitempty"`
+}
+
+// txPoolHandler answers the txpool_content, txpool_contentFrom,
+// txpool_status and txpool_inspect requests, single or batched, from the
+// txpool, in the shapes of go-ethereum, handing the others over to the next
+// handler. The content and the summaries of a large txpool are returned in
+// pages of whole senders. The websocket clients are answered through the
+// wsBridge.
+type txPoolHandler struct {
+	next   http.Handler
+	pool   inspectedTxPool
+	logger hclog.Logger
+}
+
+func newTxPoolHandler(next http.Handler, pool inspectedTxPool, logger hclog.Logger) *txPoolHandler {
+	return &txPoolHandler{
+		next:   next,
+		pool:   pool,
+		logger: logger,
+	}
+}
+
+func (h *txPoolHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	serveLocalRPC(w, r, h.next, []string{txPoolContentMethod, txPoolContentFromMethod, txPoolStatusMethod, txPoolInspectMethod}, h.answer, h.logger)
+}
+
+// answer returns the response to the txpool request, with the errors of its
+// parameters reported as go-ethereum does.
+func (h *txPoolHandler) answer(req *rpcRequest) *rpcResponse {
+	resp := &rpcResponse{Version: "2.0", ID: req.ID}
+
+	var params []json.RawMessage
+	if len(bytes.TrimSpace(req.Params)) > 0 {
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			resp.Error = &rpcError{Code: rpcInvalidParamsCode, Message: "non-array args"}
+			return resp
+		}
+	}
+
+	var perr *rpcError
+
+	switch req.Method {
+	case txPoolContentMethod:
+		var page txPoolPage
+		if page, perr = parsePageParams(params); perr == nil {
+			resp.Result = h.content(page)
+		}
+
+	case txPoolContentFromMethod:
+		var addr types.Address
+		if perr = parseParams(params, &addr); perr == nil {
+			resp.Result = h.contentFrom(addr)
+		}
+
+	case txPoolStatusMethod:
+		if perr = parseParams(params); perr == nil {
+			resp.Result = h.status()
+		}
+
+	case txPoolInspectMethod:
+		var page txPoolPage
+		if page, perr = parsePageParams(params); perr == nil {
+			resp.Result = h.inspect(page)
+		}
+	}
+
+	if perr != nil {
+		resp.Error = perr
+		resp.Result = nil
+	}
+
+	return resp
+}
+
+// parsePageParams returns the page of the params, which is optional.
+func parsePageParams(params []json.RawMessage) (txPoolPage, *rpcError) {
+	page := txPoolPage{Limit: maxTxPoolContent}
+
+	if len(params) == 0 {
+		return page, parseParams(params)
+	}
+
+	if perr := parseParams(params, &page); perr != nil {
+		return page, perr
+	}
+
+	if page.Limit <= 0 || page.Limit > maxTxPoolContent {
+		return page, &rpcError{Code: rpcInvalidParamsCode, Message: fmt.Sprintf("invalid limit %d, want 1 to %d", page.Limit, maxTxPoolContent)}
+	}
+
+	return page, nil
+}
+
+// senders returns the senders of the page, in the order of their addresses,
+// and the last of them if there are more; a sender's transactions are never
+// split across pages.
+func (p txPoolPage) senders(pending, queued map[types.Address][]*types.Transaction) ([]types.Address, *types.Address) {
+	var page []types.Address
+
+	n := 0
+
+	for _, addr := range txPoolSenders(pending, queued) {
+		if p.After != nil && bytes.Compare(addr.Bytes(), p.After.Bytes()) <= 0 {
+			continue
+		}
+
+		count := len(pending[addr]) + len(queued[addr])
+		if n > 0 && n+count > p.Limit {
+			last := page[len(page)-1]
+			return page, &last
+		}
+
+		page = append(page, addr)
+		n += count
+	}
+
+	return page, nil
+}
+
+// content returns the transactions of the senders of the page.
+func (h *txPoolHandler) content(page txPoolPage) *txPoolContent {
+	pending, queued := h.pool.GetTxs(true)
+
+	content := &txPoolContent{
+		Pending: make(map[string]map[string]*txPoolTx),
+		Queued:  make(map[string]map[string]*txPoolTx),
+	}
+
+	var senders []types.Address
+	senders, content.Next = page.senders(pending, queued)
+
+	for _, addr := range senders {
+		if txs := pending[addr]; len(txs) > 0 {
+			content.Pending[addr.String()] = txPoolTxsByNonce(txs)
+		}
+
+		if txs := queued[addr]; len(txs) > 0 {
+			content.Queued[addr.String()] = txPoolTxsByNonce(txs)
+		}
+	}
+
+	return content
+}
+
+func (h *txPoolHandler) contentFrom(addr types.Address) *txPoolContentFrom {
+	pending, queued := h.pool.GetTxs(true)
+
+	return &txPoolContentFrom{
+		Pending: txPoolTxsByNonce(pending[addr]),
+		Queued:  txPoolTxsByNonce(queued[addr]),
+	}
+}
+
+func (h *txPoolHandler) status() *txPoolStatus {
+	pending, queued := h.pool.GetTxs(true)
+
+	status := &txPoolStatus{}
+
+	for _, txs := range pending {
+		status.Pending += hexutil.Uint(len(txs))
+	}
+
+	for _, txs := range queued {
+		status.Queued += hexutil.Uint(len(txs))
+	}
+
+	return status
+}
+
+// inspect returns the summaries of the transactions of the senders of the
+// page.
+func (h *txPoolHandler) inspect(page txPoolPage) *txPoolInspect {
+	pending, queued := h.pool.GetTxs(true)
+
+	inspect := &txPoolInspect{
+		Pending: make(map[string]map[string]string),
+		Queued:  make(map[string]map[string]string),
+	}
+
+	var senders []types.Address
+	senders, inspect.Next = page.senders(pending, queued)
+
+	for _, addr := range senders {
+		if txs := pending[addr]; len(txs) > 0 {
+			inspect.Pending[addr.String()] = txPoolTxSummariesByNonce(txs)
+		}
+
+		if txs := queued[addr]; len(txs) > 0 {
+			inspect.Queued[addr.String()] = txPoolTxSummariesByNonce(txs)
+		}
+	}
+
+	return inspect
+}
+
+// txPoolSenders returns the senders of the pending and the queued
+// transactions, in the order of their addresses.
+func txPoolSenders(pending, queued map[types.Address][]*types.Transaction) []types.Address {
+	seen := make(map[types.Address]struct{}, len(pending)+len(queued))
+	senders := make([]types.Address, 0, len(pending)+len(queued))
+
+	for _, txs := range []map[types.Address][]*types.Transaction{pending, queued} {
+		for addr := range txs {
+			if _, ok := seen[addr]; !ok {
+				seen[addr] = struct{}{}
+				senders = append(senders, addr)
+			}
+		}
+	}
+
+	sort.Slice(senders, func(i, j int) bool { return bytes.Compare(senders[i].Bytes(), senders[j].Bytes()) < 0 })
+
+	return senders
+}
+
+func txPoolTxsByNonce(txs []*types.Transaction) map[string]*txPoolTx {
+	byNonce := make(map[string]*txPoolTx, len(txs))
+	for _, tx := range txs {
+		byNonce[strconv.FormatUint(tx.Nonce, 10)] = newTxPoolTx(tx)
+	}
+
+	return byNonce
+}
+
+func newTxPoolTx(tx *types.Transaction) *txPoolTx {
+	big := func(v *big.Int) *hexutil.Big {
+		if v == nil {
+			return nil
+		}
+
+		return (*hexutil.Big)(v)
+	}
+
+	rpcTx := &txPoolTx{
+		From:     tx.From,
+		Gas:      hexutil.Uint64(tx.Gas),
+		GasPrice: big(tx.GasPrice),
+		Hash:     tx.Hash,
+		Input:    tx.Input,
+		Nonce:    hexutil.Uint64(tx.Nonce),
+		To:       tx.To,
+		Value:    big(tx.Value),
+		Type:     hexutil.Uint64(tx.Type),
+		V:        big(tx.V),
+		R:        big(tx.R),
+		S:        big(tx.S),
+	}
+
+	// The dynamic fee transactions pay up to their fee cap, as go-ethereum
+	// reports for the ones in no block yet.
+	if tx.Type == types.DynamicFeeTx {
+		rpcTx.GasPrice = big(tx.GasFeeCap)
+		rpcTx.GasFeeCap = big(tx.GasFeeCap)
+		rpcTx.GasTipCap = big(tx.GasTipCap)
+	}
+
+	return rpcTx
+}
+
+func txPoolTxSummariesByNonce(txs []*types.Transaction) map[string]string {
+	byNonce := make(map[string]string, len(txs))
+	for _, tx := range txs {
+		byNonce[strconv.FormatUint(tx.Nonce, 10)] = txPoolTxSummary(tx)
+	}
+
+	return byNonce
+}
+
+// txPoolTxSummary returns the summary of the transaction of txpool_inspect,
+// as by go-ethereum.
+func txPoolTxSummary(tx *types.Transaction) string {
+	gasPrice := tx.GasPrice
+	if tx.Type == types.DynamicFeeTx {
+		gasPrice = tx.GasFeeCap
+	}
+
+	if tx.To == nil {
+		return fmt.Sprintf("contract creation: %v wei + %v gas × %v wei", tx.Value, tx.Gas, gasPrice)
+	}
+
+	return fmt.Sprintf("%s: %v wei + %v gas × %v wei", tx.To, tx.Value, tx.Gas, gasPrice)
+}
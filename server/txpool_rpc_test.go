@@ -0,0 +1,204 @@
+package server
+
+import (
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/0xPolygon/polygon-edge/chain"
+	"github.com/0xPolygon/polygon-edge/crypto"
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/availproject/op-evm/pkg/staking"
+	"github.com/availproject/op-evm/pkg/test"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTxPoolHandler(t *testing.T) {
+	spec, err := test.NewChain("..")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Another sender, of an address before or after the faucet's.
+	key, err := crypto.GenerateECDSAKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other := crypto.PubKeyToAddress(&key.PublicKey)
+	spec.Genesis.Alloc[other] = &chain.GenesisAccount{Balance: big.NewInt(1e18)}
+
+	executor, bchain, _, err := test.NewBlockchainWithTxPool(spec, staking.NewVerifier(new(staking.DumbActiveParticipants), hclog.NewNullLogger()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool := newTestJournaledTxPool(t, executor, bchain)
+	defer pool.Close()
+
+	// The faucet's transactions of nonces 0 to 2 are pending, the one of
+	// nonce 4 queued behind the gap; the other sender's one is pending.
+	txs := []*types.Transaction{
+		testFaucetTransfer(t, bchain, 0, 1),
+		testFaucetTransfer(t, bchain, 1, 1),
+		testFaucetTransfer(t, bchain, 2, 1),
+		testFaucetTransfer(t, bchain, 4, 1),
+	}
+
+	otherTx, err := crypto.NewEIP155Signer(uint64(bchain.Config().ChainID), true).SignTx(&types.Transaction{
+		Value:    big.NewInt(7),
+		Gas:      53_000,
+		GasPrice: big.NewInt(2),
+	}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	txs = append(txs, otherTx.ComputeHash())
+
+	for _, tx := range txs {
+		if err := pool.AddTx(tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	assert.Eventually(t, func() bool {
+		promoted, enqueued := pool.GetTxs(true)
+		return len(promoted[test.FaucetAccount]) == 3 && len(enqueued[test.FaucetAccount]) == 1 && len(promoted[other]) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	srv := httptest.NewServer(newTxPoolHandler(http.NotFoundHandler(), pool, hclog.NewNullLogger()))
+	defer srv.Close()
+
+	client, err := rpc.Dial(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer client.Close()
+
+	// The status, in hex.
+	var status map[string]hexutil.Uint
+	if assert.NoError(t, client.Call(&status, "txpool_status")) {
+		assert.Equal(t, map[string]hexutil.Uint{"pending": 4, "queued": 1}, status)
+	}
+
+	// The content, by the sender and the decimal nonce.
+	var content struct {
+		Pending map[types.Address]map[string]map[string]interface{} `json:"pending"`
+		Queued  map[types.Address]map[string]map[string]interface{} `json:"queued"`
+		Next    *types.Address                                      `json:"next"`
+	}
+
+	if assert.NoError(t, client.Call(&content, "txpool_content")) {
+		assert.Len(t, content.Pending, 2)
+		assert.Len(t, content.Queued, 1)
+		assert.Nil(t, content.Next)
+
+		if faucet := content.Pending[test.FaucetAccount]; assert.Len(t, faucet, 3) {
+			tx := faucet["1"]
+			assert.Equal(t, txs[1].Hash.String(), tx["hash"])
+			assert.Equal(t, "0x1", tx["nonce"])
+			assert.Equal(t, "0x5208", tx["gas"])
+			assert.Equal(t, "0x1", tx["gasPrice"])
+			assert.Equal(t, "0x0", tx["type"])
+			assert.Nil(t, tx["blockHash"])
+			assert.Nil(t, tx["blockNumber"])
+			assert.Nil(t, tx["transactionIndex"])
+		}
+
+		if queued := content.Queued[test.FaucetAccount]; assert.Len(t, queued, 1) {
+			assert.Equal(t, txs[3].Hash.String(), queued["4"]["hash"])
+		}
+
+		if tx := content.Pending[other]["0"]; assert.NotNil(t, tx) {
+			assert.Nil(t, tx["to"])
+			assert.Equal(t, "0x7", tx["value"])
+		}
+	}
+
+	// The content in pages of whole senders: the first sender only, then the
+	// one after it.
+	first, second := test.FaucetAccount, other
+	if string(other.Bytes()) < string(first.Bytes()) {
+		first, second = other, test.FaucetAccount
+	}
+
+	content.Pending, content.Queued, content.Next = nil, nil, nil
+	if assert.NoError(t, client.Call(&content, "txpool_content", map[string]interface{}{"limit": 1})) {
+		assert.Len(t, content.Pending, 1)
+		assert.Contains(t, content.Pending, first)
+
+		if assert.NotNil(t, content.Next) {
+			assert.Equal(t, first, *content.Next)
+		}
+	}
+
+	content.Pending, content.Queued, content.Next = nil, nil, nil
+	if assert.NoError(t, client.Call(&content, "txpool_content", map[string]interface{}{"after": first, "limit": 1})) {
+		assert.Len(t, content.Pending, 1)
+		assert.Contains(t, content.Pending, second)
+		assert.Nil(t, content.Next)
+	}
+
+	var unbounded interface{}
+	assert.ErrorContains(t, client.Call(&unbounded, "txpool_content", map[string]interface{}{"limit": maxTxPoolContent + 1}), "invalid limit")
+
+	// The content of a sender.
+	var from struct {
+		Pending map[string]map[string]interface{} `json:"pending"`
+		Queued  map[string]map[string]interface{} `json:"queued"`
+	}
+
+	if assert.NoError(t, client.Call(&from, "txpool_contentFrom", test.FaucetAccount)) {
+		assert.Len(t, from.Pending, 3)
+		assert.Len(t, from.Queued, 1)
+	}
+
+	// The summaries.
+	var inspect struct {
+		Pending map[types.Address]map[string]string `json:"pending"`
+		Queued  map[types.Address]map[string]string `json:"queued"`
+		Next    *types.Address                      `json:"next"`
+	}
+
+	if assert.NoError(t, client.Call(&inspect, "txpool_inspect")) {
+		assert.Equal(t, types.ZeroAddress.String()+": 1 wei + 21000 gas × 1 wei", inspect.Pending[test.FaucetAccount]["0"])
+		assert.Equal(t, "contract creation: 7 wei + 53000 gas × 2 wei", inspect.Pending[other]["0"])
+		assert.Len(t, inspect.Queued[test.FaucetAccount], 1)
+		assert.Nil(t, inspect.Next)
+	}
+
+	// The summaries in pages of whole senders, as the content.
+	inspect.Pending, inspect.Queued, inspect.Next = nil, nil, nil
+	if assert.NoError(t, client.Call(&inspect, "txpool_inspect", map[string]interface{}{"limit": 1})) {
+		assert.Len(t, inspect.Pending, 1)
+		assert.Contains(t, inspect.Pending, first)
+
+		if assert.NotNil(t, inspect.Next) {
+			assert.Equal(t, first, *inspect.Next)
+		}
+	}
+
+	inspect.Pending, inspect.Queued, inspect.Next = nil, nil, nil
+	if assert.NoError(t, client.Call(&inspect, "txpool_inspect", map[string]interface{}{"after": first, "limit": 1})) {
+		assert.Len(t, inspect.Pending, 1)
+		assert.Contains(t, inspect.Pending, second)
+		assert.Nil(t, inspect.Next)
+	}
+
+	assert.ErrorContains(t, client.Call(&unbounded, "txpool_inspect", map[string]interface{}{"limit": maxTxPoolContent + 1}), "invalid limit")
+
+	// The websocket clients are answered too.
+	wsClient := newTestWSBridge(t, newTxPoolHandler(newUpstreamHandler(http.NotFoundHandler()), pool, hclog.NewNullLogger()))
+
+	status = nil
+	if assert.NoError(t, wsClient.Call(&status, "txpool_status")) {
+		assert.Equal(t, map[string]hexutil.Uint{"pending": 4, "queued": 1}, status)
+	}
+}
@@ -0,0 +1,215 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/hashicorp/go-hclog"
+)
+
+// wsPath is the path of the websocket endpoint of the JSON-RPC server of edge.
+const wsPath = "/ws"
+
+// wsWriteTimeout is the time a message is given to be written to a websocket
+// client or to edge.
+const wsWriteTimeout = 10 * time.Second
+
+// The subscription methods, served by edge over websocket only.
+const (
+	subscribeMethod   = "eth_subscribe"
+	unsubscribeMethod = "eth_unsubscribe"
+)
+
+// wsMessageKey is the context key of the websocket message a request of the
+// bridge carries.
+type wsMessageKey struct{}
+
+// wsBridge serves the websocket clients of the JSON-RPC proxy. It terminates
+// their connections, putting each message through the next handler as a
+// POST request from the client, so the websocket traffic is limited, has
+// the block tags resolved and gets the methods the proxy answers itself
+// just as the HTTP requests do; the response is sent back over the
+// websocket. The subscriptions go on to a websocket connection to edge of
+// the client's own, whose responses and notifications are relayed back. The
+// rest of the HTTP traffic is handed over to the next handler as it is.
+type wsBridge struct {
+	next     http.Handler
+	upstream string
+	upgrader websocket.Upgrader
+	logger   hclog.Logger
+}
+
+// newWSBridge returns the wsBridge connecting the subscriptions to the
+// websocket endpoint of edge at the given URL.
+func newWSBridge(next http.Handler, upstream string, logger hclog.Logger) *wsBridge {
+	return &wsBridge{
+		next:     next,
+		upstream: upstream,
+		// The requests from anywhere are allowed, as by edge.
+		upgrader: websocket.Upgrader{CheckOrigin: func(*http.Request) bool { return true }},
+		logger:   logger,
+	}
+}
+
+// wsSession is a websocket client of the bridge and its connection to edge.
+type wsSession struct {
+	client   *websocket.Conn
+	upstream *websocket.Conn
+
+	lock sync.Mutex // Serializes the writes to the client
+}
+
+// send writes the message to the client.
+func (s *wsSession) send(msgType int, msg []byte) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	_ = s.client.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+
+	return s.client.WriteMessage(msgType, msg)
+}
+
+// wsMessage is a message of a websocket client, put through the handlers of
+// the proxy.
+type wsMessage struct {
+	session *wsSession
+	msgType int
+
+	// forwarded is set once the message went on to edge over the
+	// websocket, the response to come from there.
+	forwarded bool
+}
+
+func (b *wsBridge) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != wsPath || !websocket.IsWebSocketUpgrade(r) {
+		b.next.ServeHTTP(w, r)
+		return
+	}
+
+	upstream, resp, err := websocket.DefaultDialer.DialContext(r.Context(), b.upstream, nil)
+	if err != nil {
+		b.logger.Error("failed to connect to the websocket endpoint of the JSON-RPC server", "error", err)
+		http.Error(w, "JSON-RPC server unavailable", http.StatusBadGateway)
+
+		return
+	}
+
+	_ = resp.Body.Close()
+
+	defer upstream.Close()
+
+	client, err := b.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader replied with the error.
+		return
+	}
+
+	defer client.Close()
+
+	// A message is read whole before it's limited, so it's bounded like
+	// the HTTP requests.
+	client.SetReadLimit(rpcMaxRequestSize)
+
+	s := &wsSession{client: client, upstream: upstream}
+
+	go b.relay(s)
+
+	for {
+		msgType, msg, err := client.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		b.serveMessage(r, s, msgType, msg)
+	}
+}
+
+// relay sends the messages of edge to the client until either connection
+// closes.
+func (b *wsBridge) relay(s *wsSession) {
+	defer s.client.Close()
+
+	for {
+		msgType, msg, err := s.upstream.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		if err := s.send(msgType, msg); err != nil {
+			return
+		}
+	}
+}
+
+// serveMessage puts the message of the client through the next handler and
+// sends the response back, unless the message went on to edge.
+func (b *wsBridge) serveMessage(r *http.Request, s *wsSession, msgType int, msg []byte) {
+	m := &wsMessage{session: s, msgType: msgType}
+
+	req, err := http.NewRequestWithContext(context.WithValue(r.Context(), wsMessageKey{}, m), http.MethodPost, "/", bytes.NewReader(msg))
+	if err != nil {
+		b.logger.Error("failed to build the request of the websocket message", "error", err)
+		return
+	}
+
+	req.RemoteAddr = r.RemoteAddr
+	req.Header.Set("Content-Type", "application/json")
+
+	buf := newResponseBuffer()
+	b.next.ServeHTTP(buf, req)
+
+	if m.forwarded || buf.body.Len() == 0 {
+		return
+	}
+
+	if err := s.send(msgType, buf.body.Bytes()); err != nil {
+		b.logger.Debug("failed to send the response to the websocket client", "error", err)
+	}
+}
+
+// upstreamHandler hands the requests over to edge: the subscription requests
+// of the websocket clients of the bridge over their websocket connections to
+// it, and everything else to the next handler.
+type upstreamHandler struct {
+	next http.Handler
+}
+
+func newUpstreamHandler(next http.Handler) *upstreamHandler {
+	return &upstreamHandler{next: next}
+}
+
+func (h *upstreamHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m, ok := r.Context().Value(wsMessageKey{}).(*wsMessage)
+	if !ok || r.Method != http.MethodPost || r.Body == nil {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// The batches, which edge doesn't take over websocket, go over HTTP.
+	var req rpcRequest
+	if err := json.Unmarshal(body, &req); err != nil || (req.Method != subscribeMethod && req.Method != unsubscribeMethod) {
+		h.next.ServeHTTP(w, setBody(r, body))
+		return
+	}
+
+	_ = m.session.upstream.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+
+	if err := m.session.upstream.WriteMessage(m.msgType, body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	m.forwarded = true
+}
@@ -0,0 +1,137 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/gorilla/websocket"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+// testWSUpstream serves the websocket endpoint of edge, answering the
+// subscriptions with the ID "0x1" and notifying it right after; it returns
+// the URL of the endpoint.
+func testWSUpstream(t *testing.T) string {
+	t.Helper()
+
+	upgrader := websocket.Upgrader{}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+
+		defer conn.Close()
+
+		for {
+			_, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+
+			var req rpcRequest
+			if err := json.Unmarshal(msg, &req); err != nil || req.Method != subscribeMethod {
+				_ = conn.WriteJSON(&rpcResponse{Version: "2.0", ID: req.ID, Error: &rpcError{Code: -32601, Message: "unexpected request"}})
+				continue
+			}
+
+			_ = conn.WriteJSON(&rpcResponse{Version: "2.0", ID: req.ID, Result: "0x1"})
+			_ = conn.WriteJSON(map[string]interface{}{
+				"jsonrpc": "2.0",
+				"method":  "eth_subscription",
+				"params":  map[string]interface{}{"subscription": "0x1", "result": "0x2"},
+			})
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	return "ws" + strings.TrimPrefix(srv.URL, "http") + wsPath
+}
+
+// newTestWSBridge serves the next handler to the websocket clients through
+// the wsBridge, the subscriptions going to testWSUpstream, and returns a
+// websocket client of it.
+func newTestWSBridge(t *testing.T, next http.Handler) *rpc.Client {
+	t.Helper()
+
+	srv := httptest.NewServer(newWSBridge(next, testWSUpstream(t), hclog.NewNullLogger()))
+	t.Cleanup(srv.Close)
+
+	c, err := rpc.Dial("ws" + strings.TrimPrefix(srv.URL, "http") + wsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(c.Close)
+
+	return c
+}
+
+func TestWSBridge(t *testing.T) {
+	c := newTestWSBridge(t, newUpstreamHandler(testRPCUpstream(nil, nil)))
+
+	// The requests are served by the next handler, batched or not.
+	var result string
+	assert.NoError(t, c.Call(&result, "eth_blockNumber"))
+	assert.Equal(t, "0x1", result)
+
+	var first, second string
+
+	batch := []rpc.BatchElem{
+		{Method: "eth_blockNumber", Result: &first},
+		{Method: "eth_chainId", Result: &second},
+	}
+	assert.NoError(t, c.BatchCall(batch))
+
+	for _, elem := range batch {
+		assert.NoError(t, elem.Error)
+	}
+
+	assert.Equal(t, "0x1", first)
+	assert.Equal(t, "0x1", second)
+
+	// The subscriptions go to edge over the websocket, the notifications
+	// relayed back.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	notifications := make(chan string, 1)
+
+	sub, err := c.EthSubscribe(ctx, notifications, "newHeads")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer sub.Unsubscribe()
+
+	select {
+	case n := <-notifications:
+		assert.Equal(t, "0x2", n)
+	case <-ctx.Done():
+		t.Fatal("no notification relayed")
+	}
+}
+
+func TestWSBridgePassesHTTPThrough(t *testing.T) {
+	srv := httptest.NewServer(newWSBridge(testRPCUpstream(nil, nil), testWSUpstream(t), hclog.NewNullLogger()))
+	defer srv.Close()
+
+	c, err := rpc.Dial(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer c.Close()
+
+	var result string
+	assert.NoError(t, c.Call(&result, "eth_blockNumber"))
+	assert.Equal(t, "0x1", result)
+}
@@ -17,6 +17,8 @@ import (
 )
 
 func TestWatchTowerBlockCheck(t *testing.T) {
+	const maxBlockSize = 4 * 1024
+
 	coinbaseAddr, signKey := test.NewAccount(t)
 
 	testCases := []struct {
@@ -36,6 +38,15 @@ func TestWatchTowerBlockCheck(t *testing.T) {
 				return b
 			},
 		},
+		{
+			name: "oversized block",
+			block: func(blockBuilder block.Builder) *types.Block {
+				b, _ := blockBuilder.SignWith(signKey).Build()
+				b.Transactions = append(b.Transactions, &types.Transaction{Input: make([]byte, maxBlockSize), V: big.NewInt(27), R: big.NewInt(1), S: big.NewInt(1)})
+				return b
+			},
+			errorMatcher: func(err error) bool { return errors.Is(err, block.ErrBlockTooLarge) },
+		},
 	}
 
 	for i, tc := range testCases {
@@ -53,7 +64,7 @@ func TestWatchTowerBlockCheck(t *testing.T) {
 				t.Fatal(err)
 			}
 
-			wt := watchtower.New(blockchain, executor, nil, hclog.Default(), coinbaseAddr, signKey)
+			wt := watchtower.New(blockchain, executor, nil, hclog.Default(), coinbaseAddr, signKey, maxBlockSize)
 
 			err = wt.Check(tc.block(blockBuilder))
 			switch {
@@ -87,7 +98,7 @@ func TestWatchTowerBlockConstructFraudProof(t *testing.T) {
 	verifier = staking.NewVerifier(asq, hclog.Default())
 	blockchain.SetConsensus(verifier)
 
-	wt := watchtower.New(blockchain, executor, nil, hclog.Default(), coinbaseAddr, signKey)
+	wt := watchtower.New(blockchain, executor, nil, hclog.Default(), coinbaseAddr, signKey, 0)
 
 	stakeAmount := big.NewInt(0).Mul(big.NewInt(20), common.ETH)
 	sender := staking.NewTestAvailSender()